@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/sirupsen/logrus"
+
+	"github.com/primoPoker/server/internal/config"
+	"github.com/primoPoker/server/internal/database"
+	"github.com/primoPoker/server/internal/metrics"
+	"github.com/primoPoker/server/internal/repository"
+)
+
+// backfill-metrics rebuilds every player's stored metric totals from their
+// hand history. Run it once after deploying the totals table, or whenever
+// the totals are suspected to have drifted, while no tables are in play.
+// With -summaries-from it also rolls up the day and week hand summaries
+// since that date.
+func main() {
+	batchSize := flag.Int("batch-size", 500, "hands read per query")
+	summariesFrom := flag.String("summaries-from", "", "also roll up hand summaries from this date (YYYY-MM-DD)")
+	flag.Parse()
+
+	var summariesSince time.Time
+	if *summariesFrom != "" {
+		var err error
+		if summariesSince, err = time.Parse("2006-01-02", *summariesFrom); err != nil {
+			logrus.Fatalf("Invalid -summaries-from: %v", err)
+		}
+	}
+
+	if err := godotenv.Load(); err != nil {
+		logrus.Info("No .env file found, using system environment variables")
+	}
+
+	cfg := config.Load()
+	dbService, err := database.NewDB(database.Config{
+		Host:            cfg.Database.Host,
+		Port:            cfg.Database.Port,
+		User:            cfg.Database.User,
+		Password:        cfg.Database.Password,
+		DBName:          cfg.Database.DBName,
+		SSLMode:         cfg.Database.SSLMode,
+		TimeZone:        cfg.Database.TimeZone,
+		SocketPath:      cfg.Database.SocketPath,
+		ConnectionName:  cfg.Database.InstanceName,
+		MaxOpenConns:    5,
+		MaxIdleConns:    1,
+		ConnMaxLifetime: time.Hour,
+		ConnMaxIdleTime: 10 * time.Minute,
+	})
+	if err != nil {
+		logrus.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer func() {
+		if sqlDB, err := dbService.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	}()
+
+	if _, err := dbService.Migrate(); err != nil {
+		logrus.Fatalf("Failed to run database migrations: %v", err)
+	}
+
+	hands := repository.NewHandHistoryRepository(dbService.DB)
+	aggregator := metrics.NewAggregator(repository.NewPlayerStatRepository(dbService.DB), hands, cfg.Metrics.SessionGap)
+
+	started := time.Now()
+	players, err := aggregator.Backfill(*batchSize)
+	if err != nil {
+		logrus.Fatalf("Backfill stopped after %d players: %v", players, err)
+	}
+	logrus.Infof("Rebuilt metric totals for %d players in %s", players, time.Since(started).Round(time.Millisecond))
+
+	if summariesSince.IsZero() {
+		return
+	}
+	started = time.Now()
+	summaries, err := metrics.NewSummarizer(hands).Backfill(summariesSince)
+	if err != nil {
+		logrus.Fatalf("Summary backfill stopped after %d summaries: %v", summaries, err)
+	}
+	logrus.Infof("Rolled up %d hand summaries in %s", summaries, time.Since(started).Round(time.Millisecond))
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/sirupsen/logrus"
+
+	"github.com/primoPoker/server/internal/config"
+	"github.com/primoPoker/server/internal/database"
+)
+
+// migrate applies or rolls back database migrations by hand. The server
+// applies pending migrations as it starts, so this is mostly for rolling
+// back a bad release or checking what a database has applied:
+//
+//	migrate up            apply every pending migration
+//	migrate down -steps 1 roll back the last migration applied
+//	migrate status        list migrations and when each was applied
+func main() {
+	steps := flag.Int("steps", 1, "migrations to roll back with down")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: migrate [-steps n] up|down|status")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := godotenv.Load(); err != nil {
+		logrus.Info("No .env file found, using system environment variables")
+	}
+
+	cfg := config.Load()
+	dbService, err := database.NewDB(database.Config{
+		Host:            cfg.Database.Host,
+		Port:            cfg.Database.Port,
+		User:            cfg.Database.User,
+		Password:        cfg.Database.Password,
+		DBName:          cfg.Database.DBName,
+		SSLMode:         cfg.Database.SSLMode,
+		TimeZone:        cfg.Database.TimeZone,
+		SocketPath:      cfg.Database.SocketPath,
+		ConnectionName:  cfg.Database.InstanceName,
+		MaxOpenConns:    2,
+		MaxIdleConns:    1,
+		ConnMaxLifetime: time.Hour,
+		ConnMaxIdleTime: 10 * time.Minute,
+	})
+	if err != nil {
+		logrus.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer func() {
+		if sqlDB, err := dbService.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	}()
+
+	migrator := database.NewMigrator(dbService.DB, database.Migrations)
+	switch flag.Arg(0) {
+	case "up":
+		applied, err := migrator.Up()
+		if err != nil {
+			logrus.Fatalf("Migration stopped after applying %d: %v", applied, err)
+		}
+		logrus.Infof("Applied %d migrations", applied)
+	case "down":
+		rolledBack, err := migrator.Down(*steps)
+		if err != nil {
+			logrus.Fatalf("Rollback stopped after %d migrations: %v", rolledBack, err)
+		}
+		logrus.Infof("Rolled back %d migrations", rolledBack)
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			logrus.Fatalf("Failed to read migrations: %v", err)
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%-40s %-25s %s\n", status.ID, applied, status.Description)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
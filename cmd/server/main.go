@@ -3,25 +3,41 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
 	"github.com/sirupsen/logrus"
 
+	"github.com/primoPoker/server/internal/achievements"
 	"github.com/primoPoker/server/internal/auth"
+	"github.com/primoPoker/server/internal/cache"
 	"github.com/primoPoker/server/internal/config"
 	"github.com/primoPoker/server/internal/database"
+	"github.com/primoPoker/server/internal/flags"
 	"github.com/primoPoker/server/internal/game"
+	"github.com/primoPoker/server/internal/gcp"
 	"github.com/primoPoker/server/internal/handlers"
+	"github.com/primoPoker/server/internal/handrecord"
 	"github.com/primoPoker/server/internal/metrics"
 	"github.com/primoPoker/server/internal/middleware"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/monitoring"
+	"github.com/primoPoker/server/internal/oauth"
 	"github.com/primoPoker/server/internal/repository"
+	"github.com/primoPoker/server/internal/retention"
+	"github.com/primoPoker/server/internal/storage"
+	"github.com/primoPoker/server/internal/tablerecord"
+	"github.com/primoPoker/server/internal/tasks"
+	"github.com/primoPoker/server/internal/tracing"
 	"github.com/primoPoker/server/internal/websocket"
+	"github.com/primoPoker/server/pkg/poker"
 )
 
 func main() {
@@ -30,14 +46,83 @@ func main() {
 		logrus.Info("No .env file found, using system environment variables")
 	}
 
+	// "server config print" shows the configuration the server would run
+	// with and exits
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(configCommand(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
 	// Load configuration
 	cfg := config.Load()
+	if err := cfg.Validate(); err != nil {
+		logrus.Fatalf("Invalid configuration: %v", err)
+	}
+
+	// Settings can be reloaded with a SIGHUP or by an admin without a
+	// restart. Those read on use are read from settings; the rest are put
+	// into effect now and again after each reload.
+	settings := config.NewLiveSettings(cfg.Settings, reloadSettings)
 
 	// Setup logger
-	setupLogger(cfg.LogLevel)
+	setupLogger()
+	settings.OnReload(func(s config.Settings) {
+		setLogLevel(s.LogLevel)
+		middleware.SetRateLimit(s.RateLimitPerMinute, s.RateLimitBurst)
+
+		// Clients that still read cards as numbers can be kept working
+		// while they move to compact notation
+		poker.SetLegacyCardJSON(s.Game.LegacyCardJSON)
+	})
+
+	// Features being rolled out are gated by flags, which admins can also
+	// override while the server runs
+	features := flags.New(flags.Definitions...)
+	settings.OnReload(func(s config.Settings) {
+		if err := features.Configure(s.FeatureFlags); err != nil {
+			logrus.WithError(err).Error("Invalid feature flags; keeping the current ones")
+		}
+	})
+
+	// In production, logs also go to Cloud Logging, labelled with the
+	// service, revision and instance that wrote them. Failing to set it
+	// up leaves logs on stdout, which is no reason not to start.
+	instanceID := uuid.NewString()
+	var cloudLogging *gcp.CloudLogrusHook
+	if cfg.Environment == "production" && cfg.GCP.ProjectID != "" {
+		cloudLogger, err := gcp.NewCloudLogger(context.Background(), cfg.GCP.ProjectID, tracing.ServiceName)
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to set up Cloud Logging; logging to stdout only")
+		} else {
+			cloudLogging = gcp.NewCloudLogrusHook(cloudLogger, map[string]string{
+				"service":  tracing.ServiceName,
+				"revision": os.Getenv("K_REVISION"),
+				"instance": instanceID,
+			})
+			logrus.AddHook(cloudLogging)
+		}
+	}
 
 	logrus.Info("Starting PrimoPoker server...")
 
+	// Trace a sample of requests through the handlers, game manager,
+	// database and WebSocket notifications, when an exporter is configured
+	stopTracing, err := tracing.Setup(context.Background(), cfg.Tracing, cfg.GCP.ProjectID)
+	if err != nil {
+		logrus.Fatalf("Failed to set up tracing: %v", err)
+	}
+
+	// Report errors and recovered panics to Error Reporting, when a
+	// project is configured
+	var errorReporter *gcp.ErrorReporter
+	if cfg.GCP.ProjectID != "" {
+		errorReporter, err = gcp.NewErrorReporter(context.Background(), cfg.GCP.ProjectID, tracing.ServiceName)
+		if err != nil {
+			logrus.Fatalf("Failed to set up error reporting: %v", err)
+		}
+		go errorReporter.Run()
+		logrus.AddHook(errorReporter)
+	}
+
 	// Initialize database
 	dbConfig := database.Config{
 		Host:               cfg.Database.Host,
@@ -49,64 +134,403 @@ func main() {
 		TimeZone:           cfg.Database.TimeZone,
 		SocketPath:         cfg.Database.SocketPath,    // Cloud SQL Unix socket
 		ConnectionName:     cfg.Database.InstanceName,  // Cloud SQL connection name
-		MaxOpenConns:       25,                         // Cloud SQL optimized
-		MaxIdleConns:       5,                          // Keep connections warm
-		ConnMaxLifetime:    time.Hour,                  // Connection lifetime
-		ConnMaxIdleTime:    10 * time.Minute,          // Idle timeout
+		MaxOpenConns:       cfg.Database.MaxOpenConns,
+		MaxIdleConns:       cfg.Database.MaxIdleConns,
+		ConnMaxLifetime:    cfg.Database.ConnMaxLifetime,
+		ConnMaxIdleTime:    cfg.Database.ConnMaxIdleTime,
+		LogLevel:           cfg.Database.LogLevel,
+		SlowQueryThreshold: cfg.Database.SlowQueryThreshold,
+		ConnectTimeout:     cfg.Database.ConnectTimeout,
+		HealthLatencyWarning: cfg.Database.HealthLatencyWarning,
+		ReplicaDSN:          cfg.Database.ReplicaURL,
+		ReplicaMaxOpenConns: cfg.Database.ReplicaMaxOpenConns,
+		ReplicaMaxIdleConns: cfg.Database.ReplicaMaxIdleConns,
 	}
 	
 	dbService, err := database.NewDB(dbConfig)
 	if err != nil {
 		logrus.Fatalf("Failed to connect to database: %v", err)
 	}
-	defer func() {
-		if sqlDB, err := dbService.DB.DB(); err == nil {
-			sqlDB.Close()
-		}
-	}()
+	defer dbService.Close()
 
-	// Run database migrations
-	if err := dbService.AutoMigrate(); err != nil {
+	// Apply pending database migrations, or build the schema straight
+	// from the models in development
+	if cfg.Database.AutoMigrate {
+		if err := dbService.AutoMigrate(); err != nil {
+			logrus.Fatalf("Failed to auto-migrate database: %v", err)
+		}
+	} else if applied, err := dbService.Migrate(); err != nil {
 		logrus.Fatalf("Failed to run database migrations: %v", err)
+	} else if applied > 0 {
+		logrus.WithField("count", applied).Info("Applied database migrations")
 	}
 
 	// Initialize repositories
 	userRepo := repository.NewUserRepository(dbService.DB)
-	_ = repository.NewGameRepository(dbService.DB)     // Will be used later
+
+	// Promote the bootstrap admins named in ADMIN_USERS
+	if promoted, err := userRepo.PromoteUsers(cfg.Security.AdminUsers, models.RoleAdmin); err != nil {
+		logrus.Fatalf("Failed to promote admin users: %v", err)
+	} else if promoted > 0 {
+		logrus.WithField("count", promoted).Info("Promoted bootstrap admin users")
+	}
+
+	gameRepo := repository.NewGameRepository(dbService.DB)
 	handHistoryRepo := repository.NewHandHistoryRepository(dbService.DB)
+	tournamentRepo := repository.NewTournamentRepository(dbService.DB)
+	sessionRepo := repository.NewSessionRepository(dbService.DB)
+	reportRepo := repository.NewReportRepository(dbService.DB)
+	loginEventRepo := repository.NewLoginEventRepository(dbService.DB)
+	apiKeyRepo := repository.NewAPIKeyRepository(dbService.DB)
+	clubRepo := repository.NewClubRepository(dbService.DB)
+
+	// Share revoked sessions, idempotency keys, rate limits, cached
+	// metrics and presence between instances through Redis when it is
+	// configured; without it each instance keeps its own in memory
+	redisClient, err := cache.Connect(cfg)
+	if err != nil {
+		logrus.Fatalf("Failed to configure Redis: %v", err)
+	}
+	var sharedStore cache.Store = cache.NewMemory()
+	var rateLimitBuckets cache.Buckets
+	if redisClient != nil {
+		defer redisClient.Close()
+		sharedStore = cache.NewRedis(redisClient)
+		if err := sharedStore.Ping(context.Background()); err != nil {
+			logrus.WithError(err).Warn("Redis unreachable, falling back to memory until it answers")
+		}
+		rateLimitBuckets = cache.NewRedisBuckets(redisClient)
+		middleware.SetRateLimitBuckets(rateLimitBuckets)
+	} else if cfg.Environment == "production" {
+		logrus.Warn("Redis is not configured: rate limits, idempotency keys and revoked sessions are kept per instance and are not shared if more than one runs")
+	}
 
 	// Initialize auth service
-	authService := auth.NewService(cfg.JWTSecret, userRepo)
+	authService := auth.NewService(cfg.JWTSecret, cfg.Security, userRepo, sessionRepo, loginEventRepo, apiKeyRepo)
+	authService.SetRevocationList(sharedStore)
+	if cfg.Secrets != nil {
+		cfg.Secrets.OnChange(config.SecretJWT, authService.RotateJWTSecret)
+	}
+
+	// Stats, leaderboards and exports only read, so they go to the read
+	// replica when there is one, keeping those scans off the primary
+	readUserRepo := repository.NewUserRepository(dbService.Reader())
+	readHandHistoryRepo := repository.NewHandHistoryRepository(dbService.Reader())
 
 	// Initialize metrics service
-	metricsService := metrics.NewService(handHistoryRepo, userRepo)
+	playerStatRepo := repository.NewPlayerStatRepository(dbService.DB)
+	metricsService := metrics.NewService(readHandHistoryRepo, repository.NewPlayerStatRepository(dbService.Reader()), readUserRepo, cfg.Metrics)
+	if redisClient != nil && cfg.Metrics.CacheTTL > 0 {
+		metricsService = metrics.NewServiceWithCache(readHandHistoryRepo, repository.NewPlayerStatRepository(dbService.Reader()), readUserRepo, cfg.Metrics, metrics.NewSharedCache(sharedStore, cfg.Metrics.CacheTTL))
+	}
+
+	// Rebuild the stat leaderboards from hand history in the background.
+	// Building them writes, so only reading them goes to the replica.
+	leaderboardRepo := repository.NewLeaderboardRepository(dbService.DB).WithReader(dbService.Reader())
+	leaderboards := metrics.NewLeaderboards(leaderboardRepo, cfg.Metrics)
+	go refreshLeaderboards(leaderboards, cfg.Metrics.LeaderboardInterval)
 
-	// Initialize game manager
+	// Roll each finished day and week up into hand summaries
+	go rollUpSummaries(metrics.NewSummarizer(handHistoryRepo), cfg.Metrics.SummaryInterval)
+
+	// Initialize game manager, storing live tables so they survive a
+	// restart, and reopen the tables left open by the last run. Buy-ins
+	// and cash-outs move chips to and from players' balances, a buy-in
+	// storing the player's seat in the same transaction, and only a club's
+	// members may sit at its tables.
 	gameManager := game.NewManager()
+	gameManager.SetFlags(features)
+	settings.OnReload(func(s config.Settings) {
+		gameManager.SetDefaults(gameDefaults(s.Game))
+	})
+	tableStore := tablerecord.NewStore(gameRepo, tablerecord.DefaultQueueSize)
+	go tableStore.Run()
+	gameManager.SetStore(tableStore)
+	bank := tablerecord.NewBank(userRepo)
+	bank.StoreSeats(dbService, tableStore)
+	gameManager.SetBank(bank)
+	gameManager.SetClubs(tablerecord.NewClubs(clubRepo))
+	if restored, err := gameManager.RestoreTables(); err != nil {
+		logrus.WithError(err).Error("Failed to restore tables")
+	} else if restored > 0 {
+		logrus.WithField("count", restored).Info("Restored tables from the last run")
+	}
+	// Keep a table open for each standard table in the config file
+	if err := gameManager.SetTemplates(tableTemplates(cfg.TableTemplates)); err != nil {
+		logrus.WithError(err).Error("Failed to open template tables")
+	}
+
+	// Record hands played at live tables into hand history
+	// and drop cached statistics of the players in them
+	aggregator := metrics.NewAggregator(playerStatRepo, handHistoryRepo, cfg.Metrics.SessionGap)
+	handWriter := handrecord.NewWriter(dbService, aggregator, handrecord.DefaultQueueSize)
+	handWriter.SetListener(metricsService)
+
+	// Keep large exports, archived hands and avatars in the Cloud Storage
+	// buckets that are configured
+	var storageClient *gcp.Storage
+	var exportBucket, avatarBucket storage.Bucket
+	if cfg.GCP.UsesStorage() {
+		storageClient, err = gcp.NewStorage(context.Background())
+		if err != nil {
+			logrus.Fatalf("Failed to set up cloud storage: %v", err)
+		}
+		if cfg.GCP.ExportBucket != "" {
+			exportBucket = storageClient.Bucket(cfg.GCP.ExportBucket)
+		}
+		if cfg.GCP.AvatarBucket != "" {
+			avatarBucket = storageClient.Bucket(cfg.GCP.AvatarBucket)
+		}
+	}
 
-	// Initialize WebSocket hub
+	// Purge long-deleted users and games and archive old hand history.
+	// Totals are rebuilt before hands are archived, so metrics keep them.
+	retentionJob := retention.NewJob(repository.NewRetentionRepository(dbService.DB), aggregator, retention.Policy{
+		PurgeDeletedAfter:       cfg.Retention.PurgeDeletedAfter,
+		ArchiveHandsAfterMonths: cfg.Retention.ArchiveHandsAfterMonths,
+		BatchSize:               cfg.Retention.BatchSize,
+	})
+	if cfg.GCP.ArchiveBucket != "" {
+		retentionJob.SetArchive(storageClient.Bucket(cfg.GCP.ArchiveBucket))
+	}
+	if retentionJob.Enabled() {
+		go applyRetention(retentionJob, cfg.Retention.Interval)
+	}
+
+	// Award achievements for each hand as it is stored
+	achievementService := achievements.NewService(repository.NewAchievementRepository(dbService.DB), handHistoryRepo)
+	if err := achievementService.Seed(); err != nil {
+		logrus.WithError(err).Fatal("Failed to seed achievements")
+	}
+	handWriter.SetChecker(achievementService)
+	go handWriter.Run()
+	gameManager.SetHandObserver(handWriter)
+
+	// Publish table events to Pub/Sub for other services, when a project
+	// and topic are configured
+	var eventPublisher *gcp.EventPublisher
+	if cfg.GCP.ProjectID != "" && cfg.GCP.PubSubTopic != "" {
+		eventPublisher, err = gcp.NewEventPublisher(context.Background(), cfg.GCP.ProjectID, cfg.GCP.PubSubTopic, gcp.DefaultEventQueueSize)
+		if err != nil {
+			logrus.Fatalf("Failed to set up event publishing: %v", err)
+		}
+		go eventPublisher.Run()
+		gameManager.SetEventObserver(eventPublisher)
+	}
+
+	// Initialize WebSocket hub. With several instances, players at one
+	// table may be connected to different ones, so game broadcasts are
+	// relayed between them through Redis or Pub/Sub.
 	wsHub := websocket.NewHub()
+	wsHub.SetFlags(features)
+	if redisClient != nil {
+		wsHub.SetPresence(cache.NewRedisPresence(redisClient, instanceID))
+	}
+	switch {
+	case cfg.BroadcastFanout == "pubsub":
+		broadcastFanout, err := gcp.NewBroadcastFanout(context.Background(), cfg.GCP.ProjectID, cfg.GCP.BroadcastTopic, instanceID)
+		if err != nil {
+			logrus.Fatalf("Failed to set up broadcast relay: %v", err)
+		}
+		defer broadcastFanout.Close()
+		wsHub.SetFanout(broadcastFanout, instanceID)
+	case redisClient != nil:
+		wsHub.SetFanout(cache.NewRedisFanout(redisClient), instanceID)
+	}
+	settings.OnReload(func(s config.Settings) {
+		wsHub.SetTimings(websocket.Timings{
+			WriteWait:  s.WebSocket.WriteWait,
+			PongWait:   s.WebSocket.PongWait,
+			PingPeriod: s.WebSocket.PingPeriod,
+		})
+	})
 	go wsHub.Run()
 
+	// Register the OAuth providers that have a client configured
+	oauthProviders := make(map[string]oauth.Provider)
+	if cfg.OAuth.GoogleClientID != "" {
+		google := oauth.NewGoogleProvider(cfg.OAuth.GoogleClientID, cfg.OAuth.GoogleClientSecret, cfg.OAuth.GoogleRedirectURL)
+		oauthProviders[google.Name()] = google
+	}
+
 	// Initialize handlers
-	handler := handlers.New(gameManager, wsHub, authService, metricsService)
+	handler := handlers.New(gameManager, wsHub, authService, metricsService, leaderboards, achievementService, userRepo, gameRepo, handHistoryRepo, tournamentRepo, reportRepo, oauthProviders)
+	handler.SetExportRepositories(readUserRepo, readHandHistoryRepo)
+	handler.SetRetention(retentionJob)
+	handler.SetStorage(exportBucket, avatarBucket, cfg.GCP.ExportInlineLimit, cfg.GCP.SignedURLTTL)
+	handler.SetDatabase(dbService)
+	auditLogRepo := repository.NewAuditLogRepository(dbService.DB)
+	handler.SetAuditLog(auditLogRepo)
+	handler.SetClubs(clubRepo)
+	handler.SetSettings(settings)
+	handler.SetFlags(features)
+	if redisClient != nil {
+		handler.SetCache(sharedStore)
+	}
+
+	// Players are sent their table's state whenever it changes, whether a
+	// request or the table itself changed it
+	gameManager.AddObserver(handler.GameObserver())
+
+	// Secrets rotated in Secret Manager come into use as they are
+	// refreshed: the JWT secret and settings overrides at once, and the
+	// passwords, which open connections already hold, on the next restart
+	if cfg.Secrets != nil {
+		handler.SetSecrets(cfg.Secrets)
+		cfg.Secrets.OnChange(config.SecretSettings, func(string) { handler.ReloadSettings() })
+		for _, name := range []string{config.SecretDBPassword, config.SecretRedisPassword, config.SecretOAuthGoogle} {
+			name := name
+			cfg.Secrets.OnChange(name, func(string) {
+				logrus.WithField("secret", name).Warn("Secret rotated; restart to put it into use")
+			})
+		}
+		go cfg.Secrets.Run()
+	}
+
+	// Alert users to logins from devices they have not used before
+	authService.OnNewDevice(handler.NotifyNewDevice)
+
+	// Tell players the moment they unlock an achievement
+	achievementService.OnUnlock(handler.NotifyAchievement)
+
+	// Delayed operations are scheduled on Cloud Tasks when a queue is
+	// configured, so they survive restarts; otherwise they run from timers
+	// in process. Either way a task that is delivered twice runs once.
+	var taskQueue *gcp.TaskQueue
+	var localTasks *tasks.Local
+	taskKey := []byte(cfg.GCP.TasksSigningKey)
+	if cfg.GCP.TasksQueue != "" {
+		taskQueue, err = gcp.NewTaskQueue(context.Background(), cfg.GCP.ProjectID, cfg.GCP.Region, cfg.GCP.TasksQueue, cfg.GCP.TasksURL, taskKey)
+		if err != nil {
+			logrus.Fatalf("Failed to set up task scheduling: %v", err)
+		}
+		handler.SetTasks(taskQueue, taskKey, sharedStore)
+	} else {
+		localTasks = tasks.NewLocal(handler.RunTask)
+		handler.SetTasks(localTasks, taskKey, sharedStore)
+	}
+	if scheduled, err := handler.ScheduleIdleKicks(context.Background(), heldSeatGrace); err != nil {
+		logrus.WithError(err).Error("Failed to schedule freeing held seats")
+	} else if scheduled > 0 {
+		logrus.WithField("count", scheduled).Info("Scheduled freeing held seats")
+	}
+	go scheduleTournamentStarts(handler)
+
+	// Close tables nobody has played at for a while; zero keeps them open
+	cleanupCtx, stopCleanup := context.WithCancel(context.Background())
+	defer stopCleanup()
+	if cfg.Tables.IdleAfter > 0 {
+		go gameManager.RunCleanup(cleanupCtx, cfg.Tables.CleanupInterval, cfg.Tables.IdleAfter, handler.NotifyTableClosed)
+	}
+
+	// Prune login history past its retention period; zero keeps it forever
+	if cfg.Security.LoginHistoryRetention > 0 {
+		go pruneLoginHistory(loginEventRepo, cfg.Security.LoginHistoryRetention)
+	}
+
+	// Export operational metrics to Prometheus
+	monitor := monitoring.New()
+	monitor.WatchGames(gameManager)
+	monitor.WatchHub(wsHub)
+	monitor.WatchRateLimiters(middleware.RateLimiterStats)
+	if eventPublisher != nil {
+		monitor.WatchEvents(eventPublisher)
+	}
+	if errorReporter != nil {
+		monitor.WatchErrors(errorReporter)
+	}
+	monitor.WatchHealth(dbService)
+	if sqlDB, err := dbService.DB.DB(); err == nil {
+		monitor.WatchDB(sqlDB, cfg.Database.DBName)
+	}
+	if cfg.Database.ReplicaURL != "" {
+		if sqlDB, err := dbService.Reader().DB(); err == nil {
+			monitor.WatchDB(sqlDB, cfg.Database.DBName+"_replica")
+		}
+	}
 
 	// Setup router
-	router := setupRouter(handler, authService)
+	middleware.SetRateLimiterRetention(cfg.Security.RateLimitIdleTTL, cfg.Security.RateLimitMaxEntries)
+	if err := middleware.SetTrustedProxies(cfg.Security.TrustedProxies); err != nil {
+		logrus.Fatalf("Invalid configuration: %v", err)
+	}
+
+	// Signed-in users are rate limited per user, taken from buckets in
+	// Redis when it is configured so each is held to one budget across
+	// instances
+	var userLimiter middleware.RateLimiter = middleware.NewMemoryRateLimiter()
+	if rateLimitBuckets != nil {
+		userLimiter = middleware.NewSharedRateLimiter(rateLimitBuckets, userLimiter)
+	}
+	userRateLimit := middleware.UserRateLimit(userLimiter, userRateLimits(cfg.Security))
+	router := setupRouter(handler, authService, monitor, sharedStore, userRateLimit, auditLogRepo)
+
+	// CORS wraps the whole router rather than being a mux middleware: mux
+	// only runs middleware on matched routes, and preflight OPTIONS requests
+	// never match routes registered for other methods
+	cors := middleware.LiveCORS(func() middleware.CORSConfig {
+		current := settings.Current()
+		return middleware.CORSConfig{
+			AllowedOrigins: current.AllowedOrigins,
+			AllowAnyOrigin: cfg.Environment == "development",
+			MaxAge:         current.CORSMaxAge,
+		}
+	})
+
+	// Request bodies are capped per route class before any handler reads
+	// them, and large JSON such as lobby listings and hand histories is
+	// gzipped for clients that accept it
+	api := middleware.LimitBody(middleware.BodyLimits{
+		Auth:    cfg.Server.BodyLimitAuth,
+		Game:    cfg.Server.BodyLimitGame,
+		Avatar:  cfg.Server.BodyLimitAvatar,
+		Default: cfg.Server.BodyLimitDefault,
+	})(router)
+	if cfg.Server.Compression {
+		api = middleware.Compress(cfg.Server.CompressionMinSize)(api)
+	}
 
 	// Create HTTP server
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%s", cfg.Port),
-		Handler:      router,
+		Handler:      cors(api),
 		ReadTimeout:  cfg.Server.ReadTimeout,
 		WriteTimeout: cfg.Server.WriteTimeout,
 		IdleTimeout:  cfg.Server.IdleTimeout,
 	}
 
+	// Serve metrics on their own listener, away from the public API
+	var monitoringServer *http.Server
+	if cfg.Server.MonitoringAddr != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", monitor.Handler())
+		monitoringServer = &http.Server{
+			Addr:         cfg.Server.MonitoringAddr,
+			Handler:      metricsMux,
+			ReadTimeout:  cfg.Server.ReadTimeout,
+			WriteTimeout: cfg.Server.WriteTimeout,
+		}
+		go func() {
+			logrus.Infof("Metrics available on %s/metrics", cfg.Server.MonitoringAddr)
+			if err := monitoringServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logrus.WithError(err).Error("Metrics server failed")
+			}
+		}()
+	}
+
 	// Channel to listen for interrupt signal to terminate server
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 
+	// Reload settings on SIGHUP
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		for range hangup {
+			handler.ReloadSettings()
+		}
+	}()
+
 	// Start server in a goroutine
 	go func() {
 		logrus.Infof("Server starting on port %s", cfg.Port)
@@ -121,21 +545,250 @@ func main() {
 	logrus.Info("Shutting down server...")
 
 	// Create a context with timeout for graceful shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
-	// Shutdown server
+	// Stop closing idle tables and let the hands being played finish,
+	// while players can still act on them, then store every table
+	stopCleanup()
+	handsCtx, cancelHands := context.WithTimeout(ctx, cfg.Tables.ShutdownHandWait)
+	if err := gameManager.Shutdown(handsCtx); err != nil {
+		logrus.WithError(err).Warn("Tables stopped before their hands finished")
+	}
+	cancelHands()
+
+	// Tell players to reconnect elsewhere, then stop serving requests
+	if err := wsHub.Shutdown(ctx); err != nil {
+		logrus.WithError(err).Warn("WebSocket connections left open")
+	}
 	if err := server.Shutdown(ctx); err != nil {
-		logrus.Fatalf("Server forced to shutdown: %v", err)
+		logrus.WithError(err).Error("Server forced to shutdown")
+	}
+	if monitoringServer != nil {
+		monitoringServer.Shutdown(ctx)
+	}
+
+	// Write out the hands and tables stored before shutdown
+	handWriter.Close()
+	tableStore.Close()
+	if eventPublisher != nil {
+		eventPublisher.Close()
+	}
+	if cfg.Secrets != nil {
+		cfg.Secrets.Close()
+	}
+	if err := stopTracing(ctx); err != nil {
+		logrus.WithError(err).Warn("Failed to flush traces")
+	}
+	if taskQueue != nil {
+		taskQueue.Close()
+	}
+	if localTasks != nil {
+		localTasks.Close()
+	}
+	if storageClient != nil {
+		storageClient.Close()
+	}
+	if errorReporter != nil {
+		errorReporter.Close()
 	}
 
 	logrus.Info("Server gracefully stopped")
+	if cloudLogging != nil {
+		// Send the last entries before the instance goes away
+		if err := cloudLogging.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to flush logs to cloud logging: %v\n", err)
+		}
+	}
 }
 
-func setupLogger(level string) {
+// heldSeatGrace is how long players have to come back to the seats held
+// for them after a restart before they are cashed out
+const heldSeatGrace = 10 * time.Minute
+
+// Tournament starts are scheduled every tournamentScheduleInterval, for
+// those starting within tournamentScheduleHorizon
+const (
+	tournamentScheduleInterval = 10 * time.Minute
+	tournamentScheduleHorizon  = 24 * time.Hour
+)
+
+// scheduleTournamentStarts schedules the starts of tournaments coming due,
+// once at startup and then periodically. A start already scheduled is not
+// scheduled again, so repeating it only picks up new tournaments.
+func scheduleTournamentStarts(handler *handlers.Handler) {
+	ticker := time.NewTicker(tournamentScheduleInterval)
+	defer ticker.Stop()
+
+	for {
+		scheduled, err := handler.ScheduleTournamentStarts(context.Background(), tournamentScheduleHorizon)
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to schedule tournament starts")
+		} else if scheduled > 0 {
+			logrus.WithField("count", scheduled).Debug("Scheduled tournament starts")
+		}
+		<-ticker.C
+	}
+}
+
+// loginHistoryPruneInterval is how often expired login history is deleted
+const loginHistoryPruneInterval = time.Hour
+
+// pruneLoginHistory deletes login events older than retention, once at
+// startup and then periodically
+func pruneLoginHistory(repo *repository.LoginEventRepository, retention time.Duration) {
+	ticker := time.NewTicker(loginHistoryPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := repo.DeleteBefore(time.Now().Add(-retention))
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to prune login history")
+		} else if deleted > 0 {
+			logrus.WithField("count", deleted).Info("Pruned login history")
+		}
+		<-ticker.C
+	}
+}
+
+// applyRetention applies the retention policy once at startup and then
+// every interval
+func applyRetention(job *retention.Job, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		started := time.Now()
+		report, err := job.Run()
+		log := logrus.WithFields(logrus.Fields{
+			"users_purged":   report.UsersPurged,
+			"games_purged":   report.GamesPurged,
+			"totals_rebuilt": report.TotalsRebuilt,
+			"hands_archived": report.HandsArchived,
+			"duration":       time.Since(started).String(),
+		})
+		if err != nil {
+			log.WithError(err).Warn("Failed to apply retention policy")
+		} else {
+			log.Info("Applied retention policy")
+		}
+		<-ticker.C
+	}
+}
+
+// refreshLeaderboards rebuilds the leaderboards once at startup and then
+// every interval
+func refreshLeaderboards(leaderboards *metrics.Leaderboards, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		started := time.Now()
+		if err := leaderboards.Refresh(); err != nil {
+			logrus.WithError(err).Warn("Failed to refresh leaderboards")
+		} else {
+			logrus.WithField("duration", time.Since(started).String()).Info("Refreshed leaderboards")
+		}
+		<-ticker.C
+	}
+}
+
+// configCommand runs a config subcommand, returning the exit code. print
+// writes the effective configuration as YAML with its secrets redacted, so
+// operators can check what the environment and config file add up to.
+func configCommand(args []string, stdout, stderr io.Writer) int {
+	if len(args) != 1 || args[0] != "print" {
+		fmt.Fprintln(stderr, "usage: server config print")
+		return 2
+	}
+
+	cfg := config.Load()
+	if err := cfg.WriteRedacted(stdout); err != nil {
+		fmt.Fprintf(stderr, "Failed to print configuration: %v\n", err)
+		return 1
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(stderr, "Invalid configuration: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// reloadSettings re-reads the .env file, whose values win over the
+// environment's on a reload, and loads the settings afresh
+func reloadSettings() (config.Settings, error) {
+	if err := godotenv.Overload(); err != nil && !os.IsNotExist(err) {
+		return config.Settings{}, fmt.Errorf("failed to read .env: %w", err)
+	}
+	return config.LoadSettings()
+}
+
+// gameDefaults converts the configured game defaults for the game manager
+func gameDefaults(cfg config.GameConfig) game.GameConfig {
+	return game.GameConfig{
+		MaxTablesPerUser:   cfg.MaxTablesPerUser,
+		MaxPlayersPerTable: cfg.MaxPlayersPerTable,
+		MinPlayersPerTable: cfg.MinPlayersPerTable,
+		DefaultBuyIn:       cfg.DefaultBuyIn,
+		MaxBuyIn:           cfg.MaxBuyIn,
+		MinBuyIn:           cfg.MinBuyIn,
+		SmallBlind:         cfg.SmallBlind,
+		BigBlind:           cfg.BigBlind,
+		TurnTimeout:        cfg.TurnTimeout,
+		DecisionTimeout:    cfg.DecisionTimeout,
+	}
+}
+
+// adminBodyFields are the request body fields the admin access log keeps
+// for each route; the values of any others are redacted. Reasons and notes
+// are left out: they are free text, kept in the audit entry itself.
+var adminBodyFields = map[string][]string{
+	"/api/v1/admin/reports/{reportId}/resolve":   {"outcome"},
+	"/api/v1/admin/games/{gameId}/close":         {"in_minutes"},
+	"/api/v1/admin/games/{gameId}/config":        {"small_blind", "big_blind", "turn_timeout_seconds"},
+	"/api/v1/admin/games/{gameId}/move/{userId}": {"to_game_id"},
+	"/api/v1/admin/users/{userId}/role":          {"role"},
+	"/api/v1/admin/flags/{flag}":                 {"enabled", "percentage", "users"},
+}
+
+// userRateLimits converts the configured per-user rate limits for the
+// middleware
+func userRateLimits(cfg config.SecurityConfig) middleware.UserRateLimits {
+	return middleware.UserRateLimits{
+		Auth:    cfg.UserRateLimitAuth,
+		Game:    cfg.UserRateLimitGame,
+		Export:  cfg.UserRateLimitExport,
+		Default: cfg.UserRateLimitDefault,
+	}
+}
+
+// tableTemplates converts the configured table templates for the game
+// manager
+func tableTemplates(templates []config.TableTemplate) []game.Template {
+	converted := make([]game.Template, 0, len(templates))
+	for _, template := range templates {
+		converted = append(converted, game.Template{
+			ID:         template.TemplateID(),
+			Name:       template.Name,
+			SmallBlind: template.SmallBlind,
+			BigBlind:   template.BigBlind,
+			Ante:       template.Ante,
+			MinBuyIn:   template.MinBuyIn,
+			MaxBuyIn:   template.MaxBuyIn,
+			MaxPlayers: template.MaxPlayers,
+			Bots:       template.Bots,
+		})
+	}
+	return converted
+}
+
+func setupLogger() {
 	logrus.SetFormatter(&logrus.JSONFormatter{})
 	logrus.SetOutput(os.Stdout)
+}
 
+// setLogLevel sets the level logged at, info for an unknown level
+func setLogLevel(level string) {
 	switch level {
 	case "debug":
 		logrus.SetLevel(logrus.DebugLevel)
@@ -150,47 +803,173 @@ func setupLogger(level string) {
 	}
 }
 
-func setupRouter(handler *handlers.Handler, authService *auth.Service) *mux.Router {
+func setupRouter(handler *handlers.Handler, authService *auth.Service, monitor *monitoring.Monitor, idempotencyKeys cache.Store, userRateLimit mux.MiddlewareFunc, auditLogs middleware.AuditStore) *mux.Router {
 	router := mux.NewRouter()
 
 	// Apply middleware
-	router.Use(middleware.CORS)
+	router.Use(middleware.RequestID)
+	router.Use(middleware.Trace)
+	router.Use(middleware.Instrument(monitor))
 	router.Use(middleware.Logging)
-	router.Use(middleware.RateLimit)
+	router.Use(middleware.Recover)
+	router.Use(middleware.APIKeyAuth(authService))
 	router.Use(middleware.SecurityHeaders)
 
 	// API routes
 	api := router.PathPrefix("/api/v1").Subrouter()
+
+	// Routes needing no sign-in, rate limited per client IP
+	public := api.PathPrefix("").Subrouter()
+	public.Use(middleware.RateLimit)
 	
 	// API documentation endpoint - shows available endpoints when accessing /api/v1
-	api.HandleFunc("", handler.APIDocumentation).Methods("GET")
-	api.HandleFunc("/", handler.APIDocumentation).Methods("GET")
+	public.HandleFunc("", handler.APIDocumentation).Methods("GET")
+	public.HandleFunc("/", handler.APIDocumentation).Methods("GET")
 	
 	// Authentication routes
-	api.HandleFunc("/auth/login", handler.Login).Methods("POST")
-	api.HandleFunc("/auth/register", handler.Register).Methods("POST")
-	api.HandleFunc("/auth/refresh", handler.RefreshToken).Methods("POST")
-
-	// Protected game routes
+	public.HandleFunc("/auth/login", handler.Login).Methods("POST")
+	public.HandleFunc("/auth/register", handler.Register).Methods("POST")
+	public.HandleFunc("/auth/refresh", handler.RefreshToken).Methods("POST")
+	public.HandleFunc("/auth/oauth/{provider}/start", handler.OAuthStart).Methods("GET")
+	public.HandleFunc("/auth/oauth/{provider}/callback", handler.OAuthCallback).Methods("GET")
+	public.HandleFunc("/auth/oauth/{provider}/link", handler.OAuthLink).Methods("POST")
+
+	// A retried account deletion, whose session the first attempt revoked
+	api.Handle("/users/me", middleware.RateLimit(http.HandlerFunc(handler.AccountDeleted))).
+		Methods("DELETE").MatcherFunc(middleware.DeletedAccount(authService))
+
+	// Protected game routes, rate limited per user
 	protected := api.PathPrefix("").Subrouter()
 	protected.Use(middleware.JWTAuthMiddleware(authService))
+	protected.Use(middleware.Recover) // again, to report the user
+	protected.Use(userRateLimit)
+	protected.Use(middleware.ScopeByMethod)
+	protected.Use(middleware.Idempotency(idempotencyKeys))
 	
 	protected.HandleFunc("/games", handler.ListGames).Methods("GET")
 	protected.HandleFunc("/games", handler.CreateGame).Methods("POST")
+	protected.HandleFunc("/games/history", handler.GetGameHistory).Methods("GET")
 	protected.HandleFunc("/games/{gameId}", handler.GetGame).Methods("GET")
 	protected.HandleFunc("/games/{gameId}/join", handler.JoinGame).Methods("POST")
 	protected.HandleFunc("/games/{gameId}/leave", handler.LeaveGame).Methods("POST")
+	protected.HandleFunc("/games/{gameId}/hud", handler.GetGameHUD).Methods("GET")
+	protected.HandleFunc("/games/{gameId}/report", handler.ReportPlayer).Methods("POST")
+
+	// Account routes; managed by the user themselves, never with an API key
+	account := protected.PathPrefix("/users/me").Subrouter()
+	account.Use(middleware.RequireSession)
+
+	account.HandleFunc("", handler.DeleteAccount).Methods("DELETE")
+	account.HandleFunc("/export", handler.ExportAccount).Methods("GET")
+	account.HandleFunc("/logins", handler.ListLogins).Methods("GET")
+	account.HandleFunc("/achievements", handler.GetMyAchievements).Methods("GET")
+	account.HandleFunc("/tables", handler.ListMyTables).Methods("GET")
+	account.HandleFunc("/sessions", handler.ListSessions).Methods("GET")
+	account.HandleFunc("/sessions", handler.RevokeOtherSessions).Methods("DELETE")
+	account.HandleFunc("/sessions/{sessionId}", handler.RevokeSession).Methods("DELETE")
+	account.HandleFunc("/password", handler.ChangePassword).Methods("PUT")
+	account.HandleFunc("/avatar", handler.UploadAvatar).Methods("PUT")
+	account.HandleFunc("/api-keys", handler.ListAPIKeys).Methods("GET")
+	account.HandleFunc("/api-keys", handler.CreateAPIKey).Methods("POST")
+	account.HandleFunc("/api-keys/{keyId}", handler.RevokeAPIKey).Methods("DELETE")
 
 	// Metrics routes
 	protected.HandleFunc("/metrics", handler.GetPlayerMetrics).Methods("GET")
 	protected.HandleFunc("/metrics/comparison", handler.GetPlayerMetricsComparison).Methods("GET")
+	protected.HandleFunc("/metrics/me/sessions", handler.ListPlaySessions).Methods("GET")
+	protected.HandleFunc("/metrics/me/sessions/{sessionId}", handler.GetPlaySession).Methods("GET")
+	protected.HandleFunc("/metrics/me/starting-hands", handler.GetStartingHands).Methods("GET")
+	protected.HandleFunc("/metrics/me/export", handler.ExportMetrics).Methods("GET")
+	protected.HandleFunc("/metrics/me/summaries", handler.GetHandSummaries).Methods("GET")
+	protected.HandleFunc("/metrics/me/bankroll", handler.GetBankroll).Methods("GET")
+	protected.HandleFunc("/metrics/me/vs/{username}", handler.GetHeadToHead).Methods("GET")
 	protected.HandleFunc("/users/{userId}/metrics", handler.GetUserMetrics).Methods("GET")
-
-	// WebSocket endpoint
-	router.HandleFunc("/ws", handler.HandleWebSocket)
+	protected.HandleFunc("/users/{userId}/stats", handler.GetUserStats).Methods("GET")
+	protected.HandleFunc("/users/{userId}/profile", handler.GetUserProfile).Methods("GET")
+	protected.HandleFunc("/users/{userId}/ranks", handler.GetUserRanks).Methods("GET")
+	protected.HandleFunc("/leaderboard", handler.GetLeaderboard).Methods("GET")
+	protected.HandleFunc("/leaderboard/{metric}", handler.GetStatLeaderboard).Methods("GET")
+
+	// Hand history routes
+	protected.HandleFunc("/hands", handler.GetHandHistory).Methods("GET")
+	protected.HandleFunc("/hands/search", handler.SearchHands).Methods("GET")
+	protected.HandleFunc("/hands/export", handler.ExportHands).Methods("GET")
+
+	// Tournament routes
+	protected.HandleFunc("/tournaments", handler.ListTournaments).Methods("GET")
+	protected.HandleFunc("/tournaments/{id}", handler.GetTournament).Methods("GET")
+	protected.HandleFunc("/tournaments/{id}/players", handler.GetTournamentPlayers).Methods("GET")
+	protected.HandleFunc("/tournaments/{id}/register", handler.RegisterTournament).Methods("POST")
+	protected.HandleFunc("/tournaments/{id}/unregister", handler.UnregisterTournament).Methods("POST")
+
+	// Club routes
+	protected.HandleFunc("/clubs", handler.ListMyClubs).Methods("GET")
+	protected.HandleFunc("/clubs", handler.CreateClub).Methods("POST")
+	protected.HandleFunc("/clubs/join", handler.JoinClub).Methods("POST")
+	protected.HandleFunc("/clubs/{clubId}", handler.GetClub).Methods("GET")
+	protected.HandleFunc("/clubs/{clubId}/leave", handler.LeaveClub).Methods("POST")
+	protected.HandleFunc("/clubs/{clubId}/leaderboard", handler.GetClubLeaderboard).Methods("GET")
+
+	// Moderation routes; registered before the admin prefix so moderators reach them
+	moderation := protected.PathPrefix("/admin/reports").Subrouter()
+	moderation.Use(middleware.AdminAccessLog(auditLogs, adminBodyFields))
+	moderation.Use(middleware.RequireScope(models.ScopeAdmin))
+	moderation.Use(middleware.RequireRole(authService, models.RoleModerator))
+	moderation.Use(middleware.RequireAudit)
+
+	moderation.HandleFunc("", handler.AdminListReports).Methods("GET")
+	moderation.HandleFunc("/{reportId}", handler.AdminGetReport).Methods("GET")
+	moderation.HandleFunc("/{reportId}/resolve", handler.AdminResolveReport).Methods("POST")
+
+	// Admin routes
+	admin := protected.PathPrefix("/admin").Subrouter()
+	admin.Use(middleware.AdminAccessLog(auditLogs, adminBodyFields))
+	admin.Use(middleware.RequireScope(models.ScopeAdmin))
+	admin.Use(middleware.RequireRole(authService, models.RoleAdmin))
+	admin.Use(middleware.RequireAudit)
+
+	admin.HandleFunc("/audit", handler.AdminListAuditLog).Methods("GET")
+
+	admin.HandleFunc("/games/{gameId}/close", handler.AdminCloseGame).Methods("POST")
+	admin.HandleFunc("/games/{gameId}/force-start", handler.AdminForceStartGame).Methods("POST")
+	admin.HandleFunc("/games/{gameId}/kick/{userId}", handler.AdminKickPlayer).Methods("POST")
+	admin.HandleFunc("/games/{gameId}/move/{userId}", handler.AdminMovePlayer).Methods("POST")
+	admin.HandleFunc("/games/{gameId}/config", handler.AdminUpdateGameConfig).Methods("PUT")
+	admin.HandleFunc("/users/{userId}/role", handler.AdminSetUserRole).Methods("PUT")
+	admin.HandleFunc("/retention/dry-run", handler.AdminRetentionDryRun).Methods("POST")
+	admin.HandleFunc("/settings/reload", handler.AdminReloadSettings).Methods("POST")
+	admin.HandleFunc("/flags", handler.AdminListFlags).Methods("GET")
+	admin.HandleFunc("/flags/{flag}", handler.AdminSetFlag).Methods("PUT")
+	admin.HandleFunc("/flags/{flag}", handler.AdminClearFlag).Methods("DELETE")
+
+	// Delayed operations delivered by Cloud Tasks, authenticated by their
+	// signature
+	router.HandleFunc(tasks.Path+"{type}", handler.HandleTask).Methods("POST")
+
+	// WebSocket endpoint; bots may connect with a play-scoped API key
+	router.Handle("/ws", middleware.RateLimit(middleware.RequireScope(models.ScopePlay)(http.HandlerFunc(handler.HandleWebSocket))))
 
 	// Health check
-	router.HandleFunc("/health", handler.HealthCheck).Methods("GET")
+	router.Handle("/health", middleware.RateLimit(http.HandlerFunc(handler.HealthCheck))).Methods("GET")
+	router.Handle("/ready", middleware.RateLimit(http.HandlerFunc(handler.Readiness))).Methods("GET")
 
 	return router
 }
+
+// rollUpSummaries summarises the latest finished day and week once at
+// startup and then every interval. Rolling up is idempotent, so repeating
+// a period until the next one ends is harmless.
+func rollUpSummaries(summarizer *metrics.Summarizer, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		written, err := summarizer.RollUpCompleted()
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to roll up hand summaries")
+		} else if written > 0 {
+			logrus.WithField("count", written).Info("Rolled up hand summaries")
+		}
+		<-ticker.C
+	}
+}
@@ -9,9 +9,12 @@ import (
 	"testing"
 
 	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/primoPoker/server/internal/config"
+	"github.com/primoPoker/server/internal/game"
 	"github.com/primoPoker/server/internal/handlers"
 )
 
@@ -124,4 +127,32 @@ func TestHealthCheck(t *testing.T) {
 	data := response["data"].(map[string]interface{})
 	assert.Equal(t, "healthy", data["status"])
 	assert.Contains(t, data, "timestamp")
-}
\ No newline at end of file
+}
+func TestReloadingSettings(t *testing.T) {
+	defer logrus.SetLevel(logrus.GetLevel())
+
+	next := config.Settings{LogLevel: "info"}
+	next.Game = config.GameConfig{MaxPlayersPerTable: 9, MinPlayersPerTable: 2, DefaultBuyIn: 10000, SmallBlind: 50, BigBlind: 100}
+	settings := config.NewLiveSettings(next, func() (config.Settings, error) { return next, nil })
+
+	gameManager := game.NewManager()
+	settings.OnReload(func(s config.Settings) {
+		setLogLevel(s.LogLevel)
+		gameManager.SetDefaults(gameDefaults(s.Game))
+	})
+	assert.False(t, logrus.IsLevelEnabled(logrus.DebugLevel))
+	before, err := gameManager.CreateGame("before", "Before")
+	require.NoError(t, err)
+
+	next.LogLevel = "debug"
+	next.Game.SmallBlind, next.Game.BigBlind = 100, 200
+	changes, err := settings.Reload()
+	require.NoError(t, err)
+	assert.Len(t, changes, 3)
+	assert.True(t, logrus.IsLevelEnabled(logrus.DebugLevel), "the new log level applies straight away")
+
+	after, err := gameManager.CreateGame("after", "After")
+	require.NoError(t, err)
+	assert.Equal(t, int64(200), after.BigBlind, "new tables take the new defaults")
+	assert.Equal(t, int64(100), before.BigBlind, "running tables keep theirs")
+}
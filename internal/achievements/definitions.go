@@ -0,0 +1,114 @@
+// Package achievements awards players milestones for what happens in their
+// hands. Each achievement is a row of data and a predicate; new ones are
+// added to Definitions and seeded into the database at startup.
+package achievements
+
+import (
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/pkg/poker"
+)
+
+// Definition is an achievement and the rule that unlocks it
+type Definition struct {
+	models.Achievement
+
+	// Unlocked reports whether the hand being checked earns the achievement
+	Unlocked func(c *Check) (bool, error)
+}
+
+// winStreakLength is how many hands in a row must be won for the streak
+// achievement
+const winStreakLength = 10
+
+// Definitions lists every achievement in display order
+var Definitions = []Definition{
+	{
+		Achievement: models.Achievement{Code: "first_win", Name: "First Blood", Description: "Win your first hand", Badge: "trophy"},
+		Unlocked:    func(c *Check) (bool, error) { return c.Hand.IsWinner, nil },
+	},
+	{
+		Achievement: models.Achievement{Code: "hands_100", Name: "Regular", Description: "Play 100 hands", Badge: "chip"},
+		Unlocked:    handsPlayed(100),
+	},
+	{
+		Achievement: models.Achievement{Code: "hands_1000", Name: "Grinder", Description: "Play 1,000 hands", Badge: "chip-stack"},
+		Unlocked:    handsPlayed(1000),
+	},
+	{
+		Achievement: models.Achievement{Code: "hands_10000", Name: "Iron Seat", Description: "Play 10,000 hands", Badge: "chair"},
+		Unlocked:    handsPlayed(10000),
+	},
+	{
+		Achievement: models.Achievement{Code: "win_streak_10", Name: "On Fire", Description: "Win 10 hands in a row", Badge: "flame"},
+		Unlocked:    wonLastHands(winStreakLength),
+	},
+	{
+		Achievement: models.Achievement{Code: "triple_up", Name: "Triple Up", Description: "Finish a hand with at least three times the chips you started it with", Badge: "rocket"},
+		Unlocked: func(c *Check) (bool, error) {
+			return c.Hand.StartingChips > 0 && c.Hand.EndingChips >= 3*c.Hand.StartingChips, nil
+		},
+	},
+	{
+		Achievement: models.Achievement{Code: "the_hammer", Name: "The Hammer", Description: "Win a hand holding seven-deuce offsuit", Badge: "hammer"},
+		Unlocked: func(c *Check) (bool, error) {
+			h := c.Hand
+			ranks := h.HoleCard1Rank + h.HoleCard2Rank
+			return h.IsWinner && (ranks == "72" || ranks == "27") && h.HoleCard1Suit != h.HoleCard2Suit, nil
+		},
+	},
+	{
+		Achievement: models.Achievement{Code: "quads", Name: "Quads", Description: "Win a hand at showdown with four of a kind", Badge: "four"},
+		Unlocked:    wonWith(poker.FourOfAKind),
+	},
+	{
+		Achievement: models.Achievement{Code: "straight_flush", Name: "Straight Flush", Description: "Win a hand at showdown with a straight flush", Badge: "lightning"},
+		Unlocked:    wonWith(poker.StraightFlush, poker.RoyalFlush),
+	},
+	{
+		Achievement: models.Achievement{Code: "royal_flush", Name: "Royalty", Description: "Win a hand at showdown with a royal flush", Badge: "crown"},
+		Unlocked:    wonWith(poker.RoyalFlush),
+	},
+}
+
+// handsPlayed unlocks once the player has played n hands
+func handsPlayed(n int64) func(c *Check) (bool, error) {
+	return func(c *Check) (bool, error) {
+		played, err := c.HandsPlayed()
+		return played >= n, err
+	}
+}
+
+// wonLastHands unlocks when the hand checked completes a run of n won
+// hands, counting hands at every table in the order they started
+func wonLastHands(n int) func(c *Check) (bool, error) {
+	return func(c *Check) (bool, error) {
+		if !c.Hand.IsWinner {
+			return false, nil
+		}
+		latest, err := c.LatestHands(n)
+		if err != nil || len(latest) < n {
+			return false, err
+		}
+		for _, hand := range latest {
+			if !hand.IsWinner {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+}
+
+// wonWith unlocks when the player wins holding one of the given hands
+func wonWith(ranks ...poker.HandRank) func(c *Check) (bool, error) {
+	return func(c *Check) (bool, error) {
+		if !c.Hand.IsWinner {
+			return false, nil
+		}
+		for _, rank := range ranks {
+			if c.Hand.HandRank == rank.String() {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
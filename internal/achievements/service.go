@@ -0,0 +1,196 @@
+package achievements
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/repository"
+)
+
+// Service checks stored hands against the achievement definitions and
+// records what players unlock
+type Service struct {
+	repo        *repository.AchievementRepository
+	hands       *repository.HandHistoryRepository
+	definitions []Definition
+	onUnlock    func(userID uuid.UUID, achievement models.Achievement)
+}
+
+// NewService creates an achievement service using the standard definitions
+func NewService(repo *repository.AchievementRepository, hands *repository.HandHistoryRepository) *Service {
+	return &Service{repo: repo, hands: hands, definitions: Definitions}
+}
+
+// OnUnlock registers a function called each time a player unlocks an
+// achievement
+func (s *Service) OnUnlock(fn func(userID uuid.UUID, achievement models.Achievement)) {
+	s.onUnlock = fn
+}
+
+// Seed stores the definitions' achievements so they can be listed and
+// unlocked
+func (s *Service) Seed() error {
+	achievements := make([]models.Achievement, len(s.definitions))
+	for i, definition := range s.definitions {
+		achievements[i] = definition.Achievement
+		achievements[i].SortOrder = i
+	}
+	return s.repo.Seed(achievements)
+}
+
+// Check is a stored hand being checked for achievements. The player's
+// wider history is loaded only when a definition asks for it.
+type Check struct {
+	Hand *models.HandHistory
+
+	hands       *repository.HandHistoryRepository
+	handsPlayed *int64
+}
+
+// HandsPlayed counts every hand the player has played, this one included
+func (c *Check) HandsPlayed() (int64, error) {
+	if c.handsPlayed == nil {
+		count, err := c.hands.CountUserHands(c.Hand.UserID)
+		if err != nil {
+			return 0, err
+		}
+		c.handsPlayed = &count
+	}
+	return *c.handsPlayed, nil
+}
+
+// LatestHands gets the player's n most recently started hands, newest first
+func (c *Check) LatestHands(n int) ([]models.HandHistory, error) {
+	return c.hands.GetLatestUserHands(c.Hand.UserID, n)
+}
+
+// Evaluate checks a stored hand against every achievement its player has
+// not unlocked yet and records those it earns, returning them. Checking a
+// hand again never unlocks anything twice.
+func (s *Service) Evaluate(hand *models.HandHistory) ([]models.Achievement, error) {
+	unlocked, err := s.repo.ListUnlocked(hand.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get unlocked achievements: %w", err)
+	}
+	have := make(map[string]bool, len(unlocked))
+	for _, u := range unlocked {
+		have[u.AchievementCode] = true
+	}
+
+	check := &Check{Hand: hand, hands: s.hands}
+	var earned []models.Achievement
+	for _, definition := range s.definitions {
+		if have[definition.Code] {
+			continue
+		}
+
+		ok, err := definition.Unlocked(check)
+		if err != nil {
+			return earned, fmt.Errorf("failed to check %s: %w", definition.Code, err)
+		}
+		if !ok {
+			continue
+		}
+
+		handID := hand.ID
+		created, err := s.repo.Unlock(&models.UserAchievement{
+			UserID:          hand.UserID,
+			AchievementCode: definition.Code,
+			HandID:          &handID,
+			UnlockedAt:      time.Now(),
+		})
+		if err != nil {
+			return earned, fmt.Errorf("failed to unlock %s: %w", definition.Code, err)
+		}
+		if created {
+			earned = append(earned, definition.Achievement)
+		}
+	}
+	return earned, nil
+}
+
+// CheckHand evaluates a hand that has just been stored and announces what
+// it unlocks. Failures are logged, as the hand itself is already saved.
+func (s *Service) CheckHand(hand *models.HandHistory) {
+	earned, err := s.Evaluate(hand)
+	if err != nil {
+		logrus.WithError(err).WithField("user_id", hand.UserID).Error("Failed to check achievements")
+	}
+
+	for _, achievement := range earned {
+		logrus.WithFields(logrus.Fields{
+			"user_id":     hand.UserID,
+			"achievement": achievement.Code,
+		}).Info("Achievement unlocked")
+
+		if s.onUnlock != nil {
+			s.onUnlock(hand.UserID, achievement)
+		}
+	}
+}
+
+// Progress is an achievement and whether the player has unlocked it
+type Progress struct {
+	models.Achievement
+	Unlocked   bool       `json:"unlocked"`
+	UnlockedAt *time.Time `json:"unlocked_at,omitempty"`
+}
+
+// GetProgress lists every achievement with whether the user has unlocked it
+func (s *Service) GetProgress(userID uuid.UUID) ([]Progress, error) {
+	achievements, err := s.repo.List()
+	if err != nil {
+		return nil, err
+	}
+	unlocked, err := s.repo.ListUnlocked(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	unlockedAt := make(map[string]time.Time, len(unlocked))
+	for _, u := range unlocked {
+		unlockedAt[u.AchievementCode] = u.UnlockedAt
+	}
+
+	progress := make([]Progress, len(achievements))
+	for i, achievement := range achievements {
+		progress[i] = Progress{Achievement: achievement}
+		if at, ok := unlockedAt[achievement.Code]; ok {
+			progress[i].Unlocked = true
+			progress[i].UnlockedAt = &at
+		}
+	}
+	return progress, nil
+}
+
+// Badge is an unlocked achievement as shown on a player's public profile
+type Badge struct {
+	Code       string    `json:"code"`
+	Name       string    `json:"name"`
+	Badge      string    `json:"badge"`
+	UnlockedAt time.Time `json:"unlocked_at"`
+}
+
+// GetBadges lists the achievements a user has unlocked, first unlocked
+// first
+func (s *Service) GetBadges(userID uuid.UUID) ([]Badge, error) {
+	unlocked, err := s.repo.ListUnlocked(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	badges := make([]Badge, len(unlocked))
+	for i, u := range unlocked {
+		badges[i] = Badge{
+			Code:       u.AchievementCode,
+			Name:       u.Achievement.Name,
+			Badge:      u.Achievement.Badge,
+			UnlockedAt: u.UnlockedAt,
+		}
+	}
+	return badges, nil
+}
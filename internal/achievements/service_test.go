@@ -0,0 +1,143 @@
+package achievements
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/repository"
+	"github.com/primoPoker/server/internal/testutil"
+)
+
+// achievementFixture is a seeded service and one player to check hands for
+type achievementFixture struct {
+	db       *gorm.DB
+	service  *Service
+	userID   uuid.UUID
+	unlocked []string
+	start    time.Time
+	hands    int
+}
+
+func newAchievementFixture(t *testing.T) *achievementFixture {
+	t.Helper()
+
+	db := testutil.NewDB(t, &models.User{}, &models.Game{}, &models.HandHistory{}, &models.Achievement{}, &models.UserAchievement{})
+	f := &achievementFixture{
+		db:      db,
+		service: NewService(repository.NewAchievementRepository(db), repository.NewHandHistoryRepository(db)),
+		userID:  uuid.New(),
+		start:   time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC),
+	}
+	require.NoError(t, f.service.Seed())
+	f.service.OnUnlock(func(userID uuid.UUID, achievement models.Achievement) {
+		assert.Equal(t, f.userID, userID)
+		f.unlocked = append(f.unlocked, achievement.Code)
+	})
+	return f
+}
+
+// play stores a hand as the writer would and checks it
+func (f *achievementFixture) play(t *testing.T, hand models.HandHistory) *models.HandHistory {
+	t.Helper()
+
+	f.hands++
+	hand.ID = uuid.New()
+	hand.GameID = uuid.New()
+	hand.UserID = f.userID
+	hand.HandNumber = f.hands
+	hand.StartedAt = f.start.Add(time.Duration(f.hands) * time.Minute)
+	hand.FinishedAt = hand.StartedAt.Add(30 * time.Second)
+	require.NoError(t, f.db.Omit(clause.Associations).Create(&hand).Error)
+
+	f.service.CheckHand(&hand)
+	return &hand
+}
+
+func TestWinStreak(t *testing.T) {
+	f := newAchievementFixture(t)
+
+	// Nine wins, broken by a loss, then nine more
+	for i := 0; i < winStreakLength-1; i++ {
+		f.play(t, models.HandHistory{IsWinner: true})
+	}
+	f.play(t, models.HandHistory{IsWinner: false})
+	for i := 0; i < winStreakLength-1; i++ {
+		f.play(t, models.HandHistory{IsWinner: true})
+	}
+	assert.NotContains(t, f.unlocked, "win_streak_10")
+
+	f.play(t, models.HandHistory{IsWinner: true})
+	assert.Contains(t, f.unlocked, "win_streak_10")
+}
+
+func TestTheHammer(t *testing.T) {
+	f := newAchievementFixture(t)
+
+	f.play(t, models.HandHistory{IsWinner: true, HoleCard1Rank: "7", HoleCard1Suit: "hearts", HoleCard2Rank: "2", HoleCard2Suit: "hearts"})
+	assert.NotContains(t, f.unlocked, "the_hammer", "suited seven-deuce is not the hammer")
+
+	f.play(t, models.HandHistory{IsWinner: false, HoleCard1Rank: "2", HoleCard1Suit: "clubs", HoleCard2Rank: "7", HoleCard2Suit: "hearts"})
+	assert.NotContains(t, f.unlocked, "the_hammer", "it has to win")
+
+	f.play(t, models.HandHistory{IsWinner: true, HoleCard1Rank: "2", HoleCard1Suit: "clubs", HoleCard2Rank: "7", HoleCard2Suit: "hearts"})
+	assert.Contains(t, f.unlocked, "the_hammer")
+}
+
+func TestUnlockOnlyOnce(t *testing.T) {
+	f := newAchievementFixture(t)
+
+	hand := f.play(t, models.HandHistory{IsWinner: true, WentToShowdown: true, HandRank: "Royal Flush"})
+	assert.ElementsMatch(t, []string{"first_win", "straight_flush", "royal_flush"}, f.unlocked)
+
+	// The same hand checked again, and another like it, unlock nothing more
+	f.service.CheckHand(hand)
+	f.play(t, models.HandHistory{IsWinner: true, WentToShowdown: true, HandRank: "Royal Flush"})
+	assert.Len(t, f.unlocked, 3)
+
+	var count int64
+	require.NoError(t, f.db.Model(&models.UserAchievement{}).Where("user_id = ?", f.userID).Count(&count).Error)
+	assert.Equal(t, int64(3), count)
+
+	progress, err := f.service.GetProgress(f.userID)
+	require.NoError(t, err)
+	require.Len(t, progress, len(Definitions))
+	for i, p := range progress {
+		assert.Equal(t, Definitions[i].Code, p.Code, "listed in definition order")
+		wantUnlocked := p.Code == "first_win" || p.Code == "straight_flush" || p.Code == "royal_flush"
+		assert.Equal(t, wantUnlocked, p.Unlocked, p.Code)
+		assert.Equal(t, wantUnlocked, p.UnlockedAt != nil, p.Code)
+	}
+
+	badges, err := f.service.GetBadges(f.userID)
+	require.NoError(t, err)
+	require.Len(t, badges, 3)
+	assert.Equal(t, "crown", badgeFor(badges, "royal_flush"))
+}
+
+func TestHandsPlayed(t *testing.T) {
+	f := newAchievementFixture(t)
+
+	for i := 0; i < 99; i++ {
+		f.play(t, models.HandHistory{})
+	}
+	assert.Empty(t, f.unlocked)
+
+	f.play(t, models.HandHistory{})
+	assert.Equal(t, []string{"hands_100"}, f.unlocked)
+}
+
+func badgeFor(badges []Badge, code string) string {
+	for _, badge := range badges {
+		if badge.Code == code {
+			return badge.Badge
+		}
+	}
+	return ""
+}
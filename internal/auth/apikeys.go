@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/primoPoker/server/internal/models"
+)
+
+// apiKeyPrefix starts every API key so leaked keys are easy to recognise
+const apiKeyPrefix = "pk_"
+
+// apiKeyDisplayLength is how much of a key is kept in the clear to tell
+// a user's keys apart
+const apiKeyDisplayLength = len(apiKeyPrefix) + 8
+
+// maxAPIKeysPerUser caps how many live keys one account may hold
+const maxAPIKeysPerUser = 20
+
+// apiKeyTouchInterval limits how often a key's last use is written back,
+// since bots may call the API many times a second
+const apiKeyTouchInterval = time.Minute
+
+// API key errors
+var (
+	ErrInvalidAPIKey   = errors.New("invalid or expired API key")
+	ErrAPIKeyNotFound  = errors.New("API key not found")
+	ErrInvalidAPIScope = errors.New("invalid API key scope")
+	ErrScopeNotAllowed = errors.New("scope not allowed for this account")
+	ErrTooManyAPIKeys  = errors.New("too many API keys")
+)
+
+// CreateAPIKey issues a new API key for a user. The key itself is returned
+// only here; just its hash is stored. The admin scope is only granted to
+// moderators and admins.
+func (s *Service) CreateAPIKey(userID uuid.UUID, name string, scopes []models.APIScope, expiresAt *time.Time) (string, *models.APIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		return "", nil, errors.New("name must be between 1 and 100 characters")
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return "", nil, errors.New("expiry must be in the future")
+	}
+
+	if len(scopes) == 0 {
+		return "", nil, ErrInvalidAPIScope
+	}
+	seen := make(map[models.APIScope]bool, len(scopes))
+	var unique []models.APIScope
+	for _, scope := range scopes {
+		if !scope.IsValid() {
+			return "", nil, ErrInvalidAPIScope
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			unique = append(unique, scope)
+		}
+	}
+
+	if seen[models.ScopeAdmin] {
+		role, err := s.CurrentRole(userID)
+		if err != nil {
+			return "", nil, err
+		}
+		if !role.Includes(models.RoleModerator) {
+			return "", nil, ErrScopeNotAllowed
+		}
+	}
+
+	count, err := s.apiKeys.CountActiveByUser(userID)
+	if err != nil {
+		return "", nil, err
+	}
+	if count >= maxAPIKeysPerUser {
+		return "", nil, ErrTooManyAPIKeys
+	}
+
+	secret, err := generateAPIKey()
+	if err != nil {
+		return "", nil, err
+	}
+
+	key := &models.APIKey{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    secret[:apiKeyDisplayLength],
+		KeyHash:   hashAPIKey(secret),
+		ExpiresAt: expiresAt,
+	}
+	key.SetScopes(unique)
+	if err := s.apiKeys.Create(key); err != nil {
+		return "", nil, err
+	}
+
+	return secret, key, nil
+}
+
+// AuthenticateAPIKey returns the user and key a presented API key belongs
+// to. Revoked and expired keys and keys of disabled accounts are refused.
+func (s *Service) AuthenticateAPIKey(secret string) (*models.User, *models.APIKey, error) {
+	if !strings.HasPrefix(secret, apiKeyPrefix) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	key, err := s.apiKeys.GetByHash(hashAPIKey(secret))
+	now := time.Now()
+	if err != nil || !key.IsActive(now) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	user, err := s.GetUser(key.UserID)
+	if err != nil {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	if user.IsBanned || !user.IsActive {
+		return nil, nil, ErrAccountDisabled
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+		if err := s.apiKeys.TouchLastUsed(key.ID, now); err != nil {
+			logrus.WithError(err).WithField("api_key_id", key.ID).Warn("Failed to record API key use")
+		}
+		key.LastUsedAt = &now
+	}
+
+	return user, key, nil
+}
+
+// ListAPIKeys returns a user's unrevoked API keys
+func (s *Service) ListAPIKeys(userID uuid.UUID) ([]models.APIKey, error) {
+	return s.apiKeys.ListByUser(userID)
+}
+
+// RevokeAPIKey revokes one of a user's API keys
+func (s *Service) RevokeAPIKey(userID, keyID uuid.UUID) error {
+	revoked, err := s.apiKeys.Revoke(userID, keyID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// generateAPIKey returns a random API key
+func generateAPIKey() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashAPIKey hashes an API key for storage and lookup. The key is random
+// enough that a fast hash cannot be brute forced.
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/internal/models"
+)
+
+func TestAPIKeyLifecycle(t *testing.T) {
+	service, _ := newTestService(t)
+
+	user, err := service.CreateUser("alice", "correct-horse-42", "alice@example.com")
+	require.NoError(t, err)
+
+	secret, key, err := service.CreateAPIKey(user.ID, "my bot", []models.APIScope{models.ScopePlay, models.ScopePlay}, nil)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(secret, apiKeyPrefix))
+	assert.True(t, strings.HasPrefix(secret, key.Prefix))
+	assert.NotContains(t, key.KeyHash, secret)
+	assert.Equal(t, []models.APIScope{models.ScopePlay}, key.ScopeList())
+
+	authenticated, found, err := service.AuthenticateAPIKey(secret)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, authenticated.ID)
+	assert.Equal(t, key.ID, found.ID)
+	assert.NotNil(t, found.LastUsedAt)
+	assert.True(t, found.HasScope(models.ScopeRead))
+	assert.False(t, found.HasScope(models.ScopeAdmin))
+
+	_, _, err = service.AuthenticateAPIKey(secret + "x")
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+	_, _, err = service.AuthenticateAPIKey("not-a-key")
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+
+	keys, err := service.ListAPIKeys(user.ID)
+	require.NoError(t, err)
+	assert.Len(t, keys, 1)
+
+	require.NoError(t, service.RevokeAPIKey(user.ID, key.ID))
+	assert.ErrorIs(t, service.RevokeAPIKey(user.ID, key.ID), ErrAPIKeyNotFound)
+	_, _, err = service.AuthenticateAPIKey(secret)
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+}
+
+func TestAPIKeyExpiry(t *testing.T) {
+	service, db, user := newLockoutService(t)
+
+	past := time.Now().Add(-time.Minute)
+	_, _, err := service.CreateAPIKey(user.ID, "bot", []models.APIScope{models.ScopeRead}, &past)
+	assert.Error(t, err)
+
+	soon := time.Now().Add(time.Hour)
+	secret, key, err := service.CreateAPIKey(user.ID, "bot", []models.APIScope{models.ScopeRead}, &soon)
+	require.NoError(t, err)
+
+	require.NoError(t, db.Model(key).Update("expires_at", past).Error)
+	_, _, err = service.AuthenticateAPIKey(secret)
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	service, _ := newTestService(t)
+
+	user, err := service.CreateUser("alice", "correct-horse-42", "alice@example.com")
+	require.NoError(t, err)
+
+	_, _, err = service.CreateAPIKey(user.ID, "bot", nil, nil)
+	assert.ErrorIs(t, err, ErrInvalidAPIScope)
+	_, _, err = service.CreateAPIKey(user.ID, "bot", []models.APIScope{"root"}, nil)
+	assert.ErrorIs(t, err, ErrInvalidAPIScope)
+
+	// Only staff may hold admin keys
+	_, _, err = service.CreateAPIKey(user.ID, "bot", []models.APIScope{models.ScopeAdmin}, nil)
+	assert.ErrorIs(t, err, ErrScopeNotAllowed)
+	require.NoError(t, service.SetRole(user.ID, models.RoleAdmin))
+	_, _, err = service.CreateAPIKey(user.ID, "tools", []models.APIScope{models.ScopeAdmin}, nil)
+	assert.NoError(t, err)
+}
+
+func TestAPIKeyRefusedForDisabledAccount(t *testing.T) {
+	service, userRepo := newTestService(t)
+
+	user, err := service.CreateUser("alice", "correct-horse-42", "alice@example.com")
+	require.NoError(t, err)
+	secret, _, err := service.CreateAPIKey(user.ID, "bot", []models.APIScope{models.ScopePlay}, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, userRepo.BanUser(user.ID, "abuse"))
+	service.InvalidateUser(user.ID)
+
+	_, _, err = service.AuthenticateAPIKey(secret)
+	assert.ErrorIs(t, err, ErrAccountDisabled)
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/pagination"
+)
+
+// passwordLoginMethod is the login history method of username and password sign-ins
+const passwordLoginMethod = "password"
+
+// Column sizes of the free-form login event fields
+const (
+	maxLoggedUsername  = 50
+	maxLoggedUserAgent = 500
+)
+
+// OnNewDevice registers a function called after a successful login from a
+// device the account has not signed in from before
+func (s *Service) OnNewDevice(fn func(user *models.User, event *models.LoginEvent)) {
+	s.onNewDevice = fn
+}
+
+// ListLoginEvents lists a user's login history, most recent first
+func (s *Service) ListLoginEvents(userID uuid.UUID, page pagination.PageRequest) (*pagination.PageResponse[models.LoginEvent], error) {
+	return s.loginEvents.ListByUser(userID, page)
+}
+
+// recordLogin adds a login attempt to the history. user is the account the
+// attempt was for, if it exists, and loginErr the reason it failed. Failing
+// to record is logged rather than failing the login.
+func (s *Service) recordLogin(user *models.User, username, method, ipAddress, userAgent string, loginErr error) {
+	outcome, ok := loginOutcome(loginErr)
+	if !ok {
+		return
+	}
+
+	event := &models.LoginEvent{
+		Username:          truncate(username, maxLoggedUsername),
+		Method:            method,
+		IPAddress:         ipAddress,
+		UserAgent:         truncate(userAgent, maxLoggedUserAgent),
+		Outcome:           outcome,
+		DeviceFingerprint: deviceFingerprint(ipAddress, userAgent),
+	}
+	if user != nil {
+		event.UserID = &user.ID
+	}
+
+	if outcome == models.LoginSucceeded {
+		isNew, _, err := s.loginEvents.IsNewDevice(user.ID, event.DeviceFingerprint)
+		if err != nil {
+			logrus.WithError(err).WithField("user_id", user.ID).Warn("Failed to check login device")
+		}
+		event.NewDevice = isNew
+	}
+
+	if err := s.loginEvents.Create(event); err != nil {
+		logrus.WithError(err).WithField("username", event.Username).Warn("Failed to record login event")
+		return
+	}
+
+	if event.NewDevice && s.onNewDevice != nil {
+		s.onNewDevice(user, event)
+	}
+}
+
+// loginOutcome maps the result of a login to its history outcome; errors
+// that say nothing about the attempt itself, such as database failures,
+// are not recorded
+func loginOutcome(err error) (models.LoginOutcome, bool) {
+	switch {
+	case err == nil:
+		return models.LoginSucceeded, true
+	case errors.Is(err, ErrInvalidCredentials):
+		return models.LoginInvalidCredentials, true
+	case errors.Is(err, ErrAccountLocked):
+		return models.LoginLocked, true
+	case errors.Is(err, ErrTooManyAttempts):
+		return models.LoginThrottled, true
+	case errors.Is(err, ErrAccountDisabled):
+		return models.LoginDisabled, true
+	}
+	return "", false
+}
+
+// deviceFingerprint identifies a device as a user agent at an IP address
+func deviceFingerprint(ipAddress, userAgent string) string {
+	sum := sha256.Sum256([]byte(ipAddress + "|" + userAgent))
+	return hex.EncodeToString(sum[:])
+}
+
+// truncate shortens s to at most n bytes without splitting a character
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
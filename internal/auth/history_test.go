@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/pagination"
+)
+
+func TestAuthenticateUserRecordsHistory(t *testing.T) {
+	service, db, user := newLockoutService(t)
+
+	_, err := service.AuthenticateUser("alice", "wrong-password", "127.0.0.1", "firefox")
+	require.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = service.AuthenticateUser("alice", "correct-horse-42", "127.0.0.1", "firefox")
+	require.NoError(t, err)
+	_, err = service.AuthenticateUser("nobody", "correct-horse-42", "127.0.0.1", "firefox")
+	require.ErrorIs(t, err, ErrInvalidCredentials)
+
+	page, err := service.ListLoginEvents(user.ID, pagination.PageRequest{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 2)
+	assert.Equal(t, models.LoginSucceeded, page.Items[0].Outcome)
+	assert.Equal(t, models.LoginInvalidCredentials, page.Items[1].Outcome)
+	assert.Equal(t, "127.0.0.1", page.Items[0].IPAddress)
+	assert.Equal(t, "firefox", page.Items[0].UserAgent)
+	assert.Equal(t, "password", page.Items[0].Method)
+
+	// The attempt on a missing account is kept, attributed to no one
+	var unattributed []models.LoginEvent
+	require.NoError(t, db.Where("user_id IS NULL").Find(&unattributed).Error)
+	require.Len(t, unattributed, 1)
+	assert.Equal(t, "nobody", unattributed[0].Username)
+}
+
+func TestNewDeviceAlert(t *testing.T) {
+	service, _ := newTestService(t)
+
+	var alerts []*models.LoginEvent
+	service.OnNewDevice(func(user *models.User, event *models.LoginEvent) {
+		alerts = append(alerts, event)
+	})
+
+	_, err := service.CreateUser("alice", "correct-horse-42", "alice@example.com")
+	require.NoError(t, err)
+
+	login := func(ip, userAgent string) {
+		t.Helper()
+		_, err := service.AuthenticateUser("alice", "correct-horse-42", ip, userAgent)
+		require.NoError(t, err)
+	}
+
+	// The first login sets the baseline, and known devices stay quiet
+	login("127.0.0.1", "firefox")
+	login("127.0.0.1", "firefox")
+	assert.Empty(t, alerts)
+
+	login("198.51.100.7", "firefox")
+	login("127.0.0.1", "chrome")
+	require.Len(t, alerts, 2)
+	assert.Equal(t, "198.51.100.7", alerts[0].IPAddress)
+	assert.True(t, alerts[0].NewDevice)
+	assert.Equal(t, "chrome", alerts[1].UserAgent)
+
+	login("198.51.100.7", "firefox")
+	assert.Len(t, alerts, 2)
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "abc", truncate("abc", 5))
+	assert.Equal(t, "ab", truncate("abcdef", 2))
+	// A multi-byte character is dropped rather than split
+	assert.Equal(t, "a", truncate("añ", 2))
+	assert.Len(t, truncate(strings.Repeat("x", 600), maxLoggedUserAgent), maxLoggedUserAgent)
+}
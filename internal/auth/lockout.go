@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Lockout defaults used when SecurityConfig leaves them unset
+const (
+	defaultMaxLoginAttempts    = 5
+	defaultLoginAttemptsWindow = 15 * time.Minute
+)
+
+// A failed login from an IP the account has signed in from before counts in
+// full; one from an unfamiliar IP counts half, so a stranger alone cannot
+// lock someone out at the configured rate
+const (
+	knownIPFailureWeight   = 2
+	unknownIPFailureWeight = 1
+)
+
+// maxTrackedLoginSources caps the throttle's memory; it is cleared when full
+const maxTrackedLoginSources = 10000
+
+// Lockout errors
+var (
+	ErrAccountLocked   = errors.New("account temporarily locked")
+	ErrTooManyAttempts = errors.New("too many failed login attempts")
+)
+
+// loginThrottle counts failed logins per username and IP. Once one source
+// reaches the limit it is refused outright, so its further guesses neither
+// reach the password check nor add to the account's lockout count.
+type loginThrottle struct {
+	mu       sync.Mutex
+	failures map[string]*loginFailures
+}
+
+// loginFailures is the failed login count of one username and IP pair
+type loginFailures struct {
+	count int
+	since time.Time
+}
+
+func newLoginThrottle() *loginThrottle {
+	return &loginThrottle{failures: make(map[string]*loginFailures)}
+}
+
+// blocked checks if a source has used up its attempts within the window
+func (t *loginThrottle) blocked(username, ipAddress string, limit int, window time.Duration, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.failures[username+"|"+ipAddress]
+	return ok && now.Sub(entry.since) <= window && entry.count >= limit
+}
+
+// fail records a failed login from a source
+func (t *loginThrottle) fail(username, ipAddress string, window time.Duration, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := username + "|" + ipAddress
+	entry, ok := t.failures[key]
+	if !ok || now.Sub(entry.since) > window {
+		if len(t.failures) >= maxTrackedLoginSources {
+			t.failures = make(map[string]*loginFailures)
+		}
+		entry = &loginFailures{since: now}
+		t.failures[key] = entry
+	}
+	entry.count++
+}
+
+// reset forgets a source's failures after it signs in
+func (t *loginThrottle) reset(username, ipAddress string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.failures, username+"|"+ipAddress)
+}
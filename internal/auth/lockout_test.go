@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/primoPoker/server/internal/config"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/repository"
+	"github.com/primoPoker/server/internal/testutil"
+)
+
+const ownerIP = "192.0.2.1"
+
+// newLockoutService returns a service allowing three attempts, with a user
+// "alice" who has previously signed in from ownerIP
+func newLockoutService(t *testing.T) (*Service, *gorm.DB, *models.User) {
+	t.Helper()
+
+	db := testutil.NewDB(t, &models.User{}, &models.Session{}, &models.RotatedRefreshToken{}, &models.LoginEvent{}, &models.APIKey{})
+	service := NewService("test-secret", config.SecurityConfig{
+		MaxLoginAttempts:    3,
+		LoginAttemptsWindow: 15 * time.Minute,
+	}, repository.NewUserRepository(db), repository.NewSessionRepository(db), repository.NewLoginEventRepository(db), repository.NewAPIKeyRepository(db))
+
+	user, err := service.CreateUser("alice", "correct-horse-42", "alice@example.com")
+	require.NoError(t, err)
+	_, err = service.StartSession(user, ownerIP, "test")
+	require.NoError(t, err)
+
+	return service, db, user
+}
+
+func TestLoginLocksAfterMaxAttempts(t *testing.T) {
+	service, _, _ := newLockoutService(t)
+
+	for i := 0; i < 2; i++ {
+		_, err := service.AuthenticateUser("alice", "wrong-password", ownerIP, "test")
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	}
+	_, err := service.AuthenticateUser("alice", "wrong-password", ownerIP, "test")
+	assert.ErrorIs(t, err, ErrAccountLocked)
+
+	// The right password does not get through a lock, from any address
+	_, err = service.AuthenticateUser("alice", "correct-horse-42", ownerIP, "test")
+	assert.ErrorIs(t, err, ErrAccountLocked)
+	_, err = service.AuthenticateUser("alice", "correct-horse-42", "198.51.100.7", "test")
+	assert.ErrorIs(t, err, ErrAccountLocked)
+}
+
+func TestLoginUnlocksAfterWindow(t *testing.T) {
+	service, db, user := newLockoutService(t)
+
+	// Unfamiliar addresses count half, so it takes two of them to lock
+	for _, ip := range []string{"198.51.100.7", "198.51.100.8"} {
+		for i := 0; i < 3; i++ {
+			_, err := service.AuthenticateUser("alice", "wrong-password", ip, "test")
+			require.Error(t, err)
+		}
+	}
+	_, err := service.AuthenticateUser("alice", "correct-horse-42", ownerIP, "test")
+	require.ErrorIs(t, err, ErrAccountLocked)
+
+	// Wind the clock past the lock window
+	past := time.Now().Add(-time.Hour)
+	require.NoError(t, db.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"locked_until":         past,
+		"last_failed_login_at": past,
+	}).Error)
+
+	authenticated, err := service.AuthenticateUser("alice", "correct-horse-42", ownerIP, "test")
+	require.NoError(t, err)
+	assert.Nil(t, authenticated.LockedUntil)
+
+	var stored models.User
+	require.NoError(t, db.First(&stored, "id = ?", user.ID).Error)
+	assert.False(t, stored.IsBanned)
+	assert.Nil(t, stored.LockedUntil)
+	assert.Equal(t, 0, stored.LoginAttempts)
+}
+
+func TestLoginSuccessResetsAttempts(t *testing.T) {
+	service, _, _ := newLockoutService(t)
+
+	for round := 0; round < 3; round++ {
+		for i := 0; i < 2; i++ {
+			_, err := service.AuthenticateUser("alice", "wrong-password", ownerIP, "test")
+			require.ErrorIs(t, err, ErrInvalidCredentials)
+		}
+		_, err := service.AuthenticateUser("alice", "correct-horse-42", ownerIP, "test")
+		require.NoError(t, err, "round %d", round)
+	}
+}
+
+func TestLoginThrottlesUnknownSource(t *testing.T) {
+	service, _, _ := newLockoutService(t)
+
+	const attackerIP = "203.0.113.9"
+	for i := 0; i < 3; i++ {
+		_, err := service.AuthenticateUser("alice", "wrong-password", attackerIP, "test")
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	}
+
+	// The attacker is cut off without having locked the owner out
+	_, err := service.AuthenticateUser("alice", "correct-horse-42", attackerIP, "test")
+	assert.ErrorIs(t, err, ErrTooManyAttempts)
+	_, err = service.AuthenticateUser("alice", "correct-horse-42", ownerIP, "test")
+	assert.NoError(t, err)
+}
+
+func TestLoginThrottleWindow(t *testing.T) {
+	throttle := newLoginThrottle()
+	start := time.Now()
+
+	for i := 0; i < 3; i++ {
+		throttle.fail("alice", ownerIP, time.Minute, start)
+	}
+	assert.True(t, throttle.blocked("alice", ownerIP, 3, time.Minute, start.Add(30*time.Second)))
+	assert.False(t, throttle.blocked("alice", "198.51.100.7", 3, time.Minute, start))
+	assert.False(t, throttle.blocked("alice", ownerIP, 3, time.Minute, start.Add(2*time.Minute)))
+
+	throttle.reset("alice", ownerIP)
+	assert.False(t, throttle.blocked("alice", ownerIP, 3, time.Minute, start))
+}
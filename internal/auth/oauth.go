@@ -0,0 +1,206 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/oauth"
+)
+
+// linkTokenLifetime is how long a user has to confirm linking a provider
+// identity to their existing account
+const linkTokenLifetime = 10 * time.Minute
+
+// linkTokenPurpose marks link tokens so they cannot pass for anything else
+const linkTokenPurpose = "oauth_link"
+
+// OAuth errors
+var (
+	ErrOAuthLinkRequired    = errors.New("an account with this email already exists")
+	ErrOAuthEmailUnverified = errors.New("provider email is not verified")
+	ErrInvalidLinkToken     = errors.New("invalid or expired link token")
+)
+
+// usernameDisallowed matches what is stripped from an email to derive a username
+var usernameDisallowed = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
+
+// OAuthLogin returns the user a provider identity signs in as, creating one
+// on first sign-in. An identity whose email already belongs to a password
+// account is not merged silently: it returns ErrOAuthLinkRequired and the
+// owner has to confirm with LinkOAuthIdentity.
+func (s *Service) OAuthLogin(identity *oauth.Identity, ipAddress, userAgent string) (*models.User, error) {
+	user, err := s.userRepo.GetByOAuthIdentity(identity.Provider, identity.Subject)
+	if err == nil {
+		return s.completeOAuthLogin(user, identity.Provider, ipAddress, userAgent)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, ErrOAuthEmailUnverified
+	}
+	available, err := s.userRepo.IsEmailAvailable(identity.Email)
+	if err != nil {
+		return nil, err
+	}
+	if !available {
+		return nil, ErrOAuthLinkRequired
+	}
+
+	username, err := s.oauthUsername(identity.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	// Accounts created here have no password; an empty hash never verifies
+	user = &models.User{
+		ID:          uuid.New(),
+		Username:    username,
+		Email:       identity.Email,
+		DisplayName: identity.Name,
+	}
+	if err := s.userRepo.CreateWithOAuthIdentity(user, &models.OAuthIdentity{
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	}); err != nil {
+		return nil, err
+	}
+
+	return s.completeOAuthLogin(user, identity.Provider, ipAddress, userAgent)
+}
+
+// LinkOAuthIdentity links the identity in a link token to the account with
+// the same email once its owner proves it with their password
+func (s *Service) LinkOAuthIdentity(linkToken, password, ipAddress, userAgent string) (*models.User, error) {
+	identity, err := s.parseLinkToken(linkToken)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.userRepo.GetByEmail(identity.Email)
+	if err != nil {
+		return nil, ErrInvalidLinkToken
+	}
+
+	// The password check applies the usual lockout and disabled account rules
+	user, err := s.AuthenticateUser(existing.Username, password, ipAddress, userAgent)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepo.LinkOAuthIdentity(&models.OAuthIdentity{
+		UserID:   user.ID,
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	}); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// completeOAuthLogin refuses accounts that are locked or disabled and
+// records the sign-in in the login history under the provider's name
+func (s *Service) completeOAuthLogin(user *models.User, provider, ipAddress, userAgent string) (*models.User, error) {
+	err := s.checkOAuthLogin(user)
+	s.recordLogin(user, user.Username, provider, ipAddress, userAgent, err)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// checkOAuthLogin refuses accounts that are locked or disabled and marks the sign-in
+func (s *Service) checkOAuthLogin(user *models.User) error {
+	now := time.Now()
+	if user.IsLocked(now) {
+		return ErrAccountLocked
+	}
+	if user.IsBanned || !user.IsActive {
+		return ErrAccountDisabled
+	}
+
+	if err := s.userRepo.UpdateLastLogin(user.ID); err != nil {
+		return err
+	}
+	user.LastLoginAt = &now
+	s.InvalidateUser(user.ID)
+
+	return nil
+}
+
+// oauthUsername derives an unused username from an email address
+func (s *Service) oauthUsername(email string) (string, error) {
+	base, _, _ := strings.Cut(email, "@")
+	base = usernameDisallowed.ReplaceAllString(base, "")
+	if len(base) > 20 {
+		base = base[:20]
+	}
+	if len(base) < 3 {
+		base = "player"
+	}
+
+	candidate := base
+	for i := 0; i < 5; i++ {
+		available, err := s.userRepo.IsUsernameAvailable(candidate)
+		if err != nil {
+			return "", err
+		}
+		if available {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s%04d", base, rand.IntN(10000))
+	}
+	return base + "_" + uuid.New().String()[:8], nil
+}
+
+// GenerateLinkToken issues a short-lived token carrying an identity that
+// still needs its owner's confirmation before it is linked
+func (s *Service) GenerateLinkToken(identity *oauth.Identity) (string, error) {
+	claims := jwt.MapClaims{
+		"purpose":  linkTokenPurpose,
+		"provider": identity.Provider,
+		"sub":      identity.Subject,
+		"email":    identity.Email,
+		"exp":      time.Now().Add(linkTokenLifetime).Unix(),
+		"iat":      time.Now().Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(s.signingKey())
+}
+
+// parseLinkToken validates a link token and returns the identity it carries
+func (s *Service) parseLinkToken(tokenString string) (*oauth.Identity, error) {
+	token, err := s.parseToken(tokenString)
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidLinkToken
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != linkTokenPurpose {
+		return nil, ErrInvalidLinkToken
+	}
+
+	identity := &oauth.Identity{EmailVerified: true}
+	identity.Provider, _ = claims["provider"].(string)
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Email, _ = claims["email"].(string)
+	if identity.Provider == "" || identity.Subject == "" || identity.Email == "" {
+		return nil, ErrInvalidLinkToken
+	}
+
+	return identity, nil
+}
@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/internal/config"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/oauth"
+	"github.com/primoPoker/server/internal/repository"
+	"github.com/primoPoker/server/internal/testutil"
+)
+
+func newOAuthTestService(t *testing.T) (*Service, *repository.UserRepository) {
+	t.Helper()
+
+	db := testutil.NewDB(t, &models.User{}, &models.Session{}, &models.RotatedRefreshToken{}, &models.OAuthIdentity{}, &models.LoginEvent{}, &models.APIKey{})
+	userRepo := repository.NewUserRepository(db)
+	return NewService("test-secret", config.SecurityConfig{}, userRepo, repository.NewSessionRepository(db), repository.NewLoginEventRepository(db), repository.NewAPIKeyRepository(db)), userRepo
+}
+
+func googleIdentity(subject, email string) *oauth.Identity {
+	return &oauth.Identity{
+		Provider:      "google",
+		Subject:       subject,
+		Email:         email,
+		EmailVerified: true,
+		Name:          "Alice Example",
+	}
+}
+
+func TestOAuthLoginCreatesUser(t *testing.T) {
+	service, _ := newOAuthTestService(t)
+
+	user, err := service.OAuthLogin(googleIdentity("g-1", "alice.smith@example.com"), "127.0.0.1", "test")
+	require.NoError(t, err)
+	assert.Equal(t, "alicesmith", user.Username)
+	assert.Equal(t, "alice.smith@example.com", user.Email)
+	assert.Equal(t, "Alice Example", user.DisplayName)
+
+	// The subject, not the email, identifies the account on later sign-ins
+	again, err := service.OAuthLogin(googleIdentity("g-1", "renamed@example.com"), "127.0.0.1", "test")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, again.ID)
+
+	// Accounts created this way cannot sign in with an empty password
+	_, err = service.AuthenticateUser("alicesmith", "", "127.0.0.1", "test")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestOAuthLoginPicksFreeUsername(t *testing.T) {
+	service, _ := newOAuthTestService(t)
+
+	_, err := service.CreateUser("alice", "correct-horse-42", "alice@example.com")
+	require.NoError(t, err)
+
+	user, err := service.OAuthLogin(googleIdentity("g-1", "alice@other.example.com"), "127.0.0.1", "test")
+	require.NoError(t, err)
+	assert.NotEqual(t, "alice", user.Username)
+	assert.Contains(t, user.Username, "alice")
+}
+
+func TestOAuthLoginRequiresVerifiedEmail(t *testing.T) {
+	service, _ := newOAuthTestService(t)
+
+	identity := googleIdentity("g-1", "alice@example.com")
+	identity.EmailVerified = false
+	_, err := service.OAuthLogin(identity, "127.0.0.1", "test")
+	assert.ErrorIs(t, err, ErrOAuthEmailUnverified)
+}
+
+func TestOAuthLinkExistingAccount(t *testing.T) {
+	service, _ := newOAuthTestService(t)
+
+	existing, err := service.CreateUser("alice", "correct-horse-42", "alice@example.com")
+	require.NoError(t, err)
+
+	identity := googleIdentity("g-1", "alice@example.com")
+	_, err = service.OAuthLogin(identity, "127.0.0.1", "test")
+	require.ErrorIs(t, err, ErrOAuthLinkRequired)
+
+	linkToken, err := service.GenerateLinkToken(identity)
+	require.NoError(t, err)
+
+	_, err = service.LinkOAuthIdentity(linkToken, "wrong-password", "127.0.0.1", "test")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = service.LinkOAuthIdentity(linkToken+"x", "correct-horse-42", "127.0.0.1", "test")
+	assert.ErrorIs(t, err, ErrInvalidLinkToken)
+
+	linked, err := service.LinkOAuthIdentity(linkToken, "correct-horse-42", "127.0.0.1", "test")
+	require.NoError(t, err)
+	assert.Equal(t, existing.ID, linked.ID)
+
+	user, err := service.OAuthLogin(identity, "127.0.0.1", "test")
+	require.NoError(t, err)
+	assert.Equal(t, existing.ID, user.ID)
+
+	// A link token is not an access token
+	_, _, err = service.ValidateSession(linkToken)
+	assert.Error(t, err)
+}
+
+func TestOAuthLoginRefusesDisabledAccounts(t *testing.T) {
+	service, userRepo := newOAuthTestService(t)
+
+	user, err := service.OAuthLogin(googleIdentity("g-1", "alice@example.com"), "127.0.0.1", "test")
+	require.NoError(t, err)
+
+	require.NoError(t, userRepo.BanUser(user.ID, "abuse"))
+	_, err = service.OAuthLogin(googleIdentity("g-1", "alice@example.com"), "127.0.0.1", "test")
+	assert.ErrorIs(t, err, ErrAccountDisabled)
+
+	stored, err := userRepo.GetByID(user.ID)
+	require.NoError(t, err)
+	assert.True(t, stored.IsBanned)
+	assert.False(t, stored.IsActive)
+}
+
+func TestOAuthLoginRefusesLockedAccounts(t *testing.T) {
+	service, userRepo := newOAuthTestService(t)
+
+	user, err := service.OAuthLogin(googleIdentity("g-1", "alice@example.com"), "127.0.0.1", "test")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		_, err = userRepo.RecordFailedLogin(user.ID, knownIPFailureWeight, defaultMaxLoginAttempts*knownIPFailureWeight, defaultLoginAttemptsWindow)
+		require.NoError(t, err)
+	}
+
+	_, err = service.OAuthLogin(googleIdentity("g-1", "alice@example.com"), "127.0.0.1", "test")
+	assert.ErrorIs(t, err, ErrAccountLocked)
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/primoPoker/server/internal/cache"
+)
+
+// revocationTimeout bounds a revocation list lookup, after which the
+// session is checked against the database alone
+const revocationTimeout = 250 * time.Millisecond
+
+// SetRevocationList shares the list of revoked sessions through store, so
+// a session signed out on one instance is refused by all of them before
+// their database lookup. The list defaults to one in memory.
+func (s *Service) SetRevocationList(store cache.Store) {
+	s.revocations = store
+}
+
+// revokedKey names a revoked session in the revocation list
+func revokedKey(sessionID uuid.UUID) string {
+	return "revoked-session:" + sessionID.String()
+}
+
+// isRevoked reports whether the session is on the revocation list. A list
+// that cannot be read is treated as empty; the database stays authoritative.
+func (s *Service) isRevoked(sessionID uuid.UUID) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), revocationTimeout)
+	defer cancel()
+
+	_, err := s.revocations.Get(ctx, revokedKey(sessionID))
+	if err != nil && !errors.Is(err, cache.ErrMiss) {
+		logrus.WithError(err).Warn("Failed to read the session revocation list")
+	}
+	return err == nil
+}
+
+// listRevoked adds sessions to the revocation list until any access token
+// issued for them has expired
+func (s *Service) listRevoked(sessionIDs ...uuid.UUID) {
+	ctx, cancel := context.WithTimeout(context.Background(), revocationTimeout)
+	defer cancel()
+
+	for _, sessionID := range sessionIDs {
+		if err := s.revocations.Set(ctx, revokedKey(sessionID), "1", s.accessTokenLifetime()); err != nil {
+			logrus.WithError(err).WithField("session_id", sessionID).Warn("Failed to list a revoked session")
+			return
+		}
+	}
+}
+
+// revokeAllExcept revokes every active session of a user other than keep,
+// listing each as revoked
+func (s *Service) revokeAllExcept(userID, keep uuid.UUID) (int64, error) {
+	sessions, err := s.sessionRepo.GetActiveByUser(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	revoked, err := s.sessionRepo.RevokeAllExcept(userID, keep)
+	if err != nil {
+		return 0, err
+	}
+
+	sessionIDs := make([]uuid.UUID, 0, len(sessions))
+	for _, session := range sessions {
+		if session.ID != keep {
+			sessionIDs = append(sessionIDs, session.ID)
+		}
+	}
+	s.listRevoked(sessionIDs...)
+	return revoked, nil
+}
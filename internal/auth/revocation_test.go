@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/internal/cache"
+)
+
+func TestRevocationListIsShared(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	defer client.Close()
+	store := cache.NewRedis(client)
+
+	service, _ := newTestService(t)
+	service.SetRevocationList(store)
+
+	user, err := service.CreateUser("alice", "correct-horse-42", "alice@example.com")
+	require.NoError(t, err)
+	current, err := service.StartSession(user, "127.0.0.1", "laptop")
+	require.NoError(t, err)
+	other, err := service.StartSession(user, "127.0.0.1", "phone")
+	require.NoError(t, err)
+
+	revoked, err := service.RevokeOtherSessions(user.ID, current.SessionID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), revoked)
+
+	ctx := context.Background()
+	_, err = store.Get(ctx, revokedKey(other.SessionID))
+	assert.NoError(t, err, "the revoked session is listed")
+	_, err = store.Get(ctx, revokedKey(current.SessionID))
+	assert.ErrorIs(t, err, cache.ErrMiss)
+
+	// A session listed by another instance is refused before the database
+	// is asked, even if this instance's view of it is still active
+	require.NoError(t, store.Set(ctx, revokedKey(current.SessionID), "1", service.accessTokenLifetime()))
+	_, _, err = service.ValidateSession(current.AccessToken)
+	assert.ErrorIs(t, err, ErrSessionInvalid)
+}
+
+func TestRevocationListOutageFallsBackToDatabase(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+	defer client.Close()
+
+	service, _ := newTestService(t)
+	service.SetRevocationList(cache.NewRedis(client))
+
+	user, err := service.CreateUser("alice", "correct-horse-42", "alice@example.com")
+	require.NoError(t, err)
+	tokens, err := service.StartSession(user, "127.0.0.1", "laptop")
+	require.NoError(t, err)
+
+	server.Close()
+	_, _, err = service.ValidateSession(tokens.AccessToken)
+	assert.NoError(t, err)
+	require.NoError(t, service.RevokeSession(user.ID, tokens.SessionID))
+	_, _, err = service.ValidateSession(tokens.AccessToken)
+	assert.ErrorIs(t, err, ErrSessionInvalid)
+}
@@ -1,51 +1,162 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/sirupsen/logrus"
 
+	"github.com/primoPoker/server/internal/cache"
+	"github.com/primoPoker/server/internal/config"
 	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/password"
 	"github.com/primoPoker/server/internal/repository"
 )
 
+// Defaults used when SecurityConfig leaves them unset
+const (
+	defaultJWTExpirationHours = 24
+	defaultRefreshTokenDays   = 30
+	defaultPasswordMinLength  = 8
+)
+
+// Users are cached for a short time since every authenticated request looks
+// one up; the cache is cleared outright if it ever grows past its cap
+const (
+	userCacheTTL   = 30 * time.Second
+	maxCachedUsers = 10000
+)
+
+// Session errors
+var (
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrSessionInvalid     = errors.New("session revoked or expired")
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+// Account errors
+var (
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrAccountDisabled    = errors.New("account is disabled")
+	ErrUserNotFound       = errors.New("user not found")
+)
+
 // Service handles authentication operations
 type Service struct {
-	jwtSecret string
-	userRepo  *repository.UserRepository
+	keyMu          sync.RWMutex
+	jwtSecret      string
+	previousSecret string
+	previousUntil  time.Time
+
+	security    config.SecurityConfig
+	userRepo    *repository.UserRepository
+	sessionRepo *repository.SessionRepository
+	loginEvents *repository.LoginEventRepository
+	apiKeys     *repository.APIKeyRepository
+	passwords   *password.Policy
+	rules       password.Rules
+	throttle    *loginThrottle
+	revocations cache.Store
+
+	cacheMu   sync.Mutex
+	userCache map[uuid.UUID]cachedUser
+
+	onNewDevice func(*models.User, *models.LoginEvent)
+}
+
+// cachedUser is a user loaded from the database and when it goes stale
+type cachedUser struct {
+	user      models.User
+	expiresAt time.Time
 }
 
 // NewService creates a new authentication service
-func NewService(jwtSecret string, userRepo *repository.UserRepository) *Service {
+func NewService(jwtSecret string, security config.SecurityConfig, userRepo *repository.UserRepository, sessionRepo *repository.SessionRepository, loginEvents *repository.LoginEventRepository, apiKeys *repository.APIKeyRepository) *Service {
+	if security.JWTExpirationHours <= 0 {
+		security.JWTExpirationHours = defaultJWTExpirationHours
+	}
+	if security.PasswordMinLength <= 0 {
+		security.PasswordMinLength = defaultPasswordMinLength
+	}
+	if security.RefreshTokenDays <= 0 {
+		security.RefreshTokenDays = defaultRefreshTokenDays
+	}
+	if security.MaxLoginAttempts <= 0 {
+		security.MaxLoginAttempts = defaultMaxLoginAttempts
+	}
+	if security.LoginAttemptsWindow <= 0 {
+		security.LoginAttemptsWindow = defaultLoginAttemptsWindow
+	}
+
+	// New hashes use argon2id; bcrypt hashes from before it still verify
+	// and are replaced on the next successful login
+	passwords := password.NewPolicy(password.NewArgon2id(password.Argon2Params{
+		Memory:  uint32(security.Argon2MemoryKiB),
+		Time:    uint32(security.Argon2Iterations),
+		Threads: uint8(security.Argon2Threads),
+	}), password.NewBcrypt(0))
+
 	return &Service{
-		jwtSecret: jwtSecret,
-		userRepo:  userRepo,
+		jwtSecret:   jwtSecret,
+		security:    security,
+		userRepo:    userRepo,
+		sessionRepo: sessionRepo,
+		loginEvents: loginEvents,
+		apiKeys:     apiKeys,
+		passwords:   passwords,
+		rules: password.Rules{
+			MinLength:     security.PasswordMinLength,
+			RequireUpper:  security.PasswordRequireUpper,
+			RequireLower:  security.PasswordRequireLower,
+			RequireDigit:  security.PasswordRequireDigit,
+			RequireSymbol: security.PasswordRequireSymbol,
+		},
+		throttle:    newLoginThrottle(),
+		revocations: cache.NewMemory(),
+		userCache:   make(map[uuid.UUID]cachedUser),
 	}
 }
 
+// TokenPair is the access and refresh token issued for a session
+type TokenPair struct {
+	AccessToken  string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	SessionID    uuid.UUID `json:"session_id"`
+}
+
 // CreateUser creates a new user
 func (s *Service) CreateUser(username, password, email string) (*models.User, error) {
 	// Check if user already exists
-	existingUser, _ := s.userRepo.GetByUsername(username)
-	if existingUser != nil {
+	available, err := s.userRepo.IsUsernameAvailable(username)
+	if err != nil {
+		return nil, err
+	}
+	if !available {
 		return nil, errors.New("username already exists")
 	}
-	
-	existingUser, _ = s.userRepo.GetByEmail(email)
-	if existingUser != nil {
+
+	available, err = s.userRepo.IsEmailAvailable(email)
+	if err != nil {
+		return nil, err
+	}
+	if !available {
 		return nil, errors.New("email already exists")
 	}
 
 	// Validate password strength
-	if len(password) < 8 {
-		return nil, errors.New("password must be at least 8 characters long")
+	if err := s.CheckPassword(password, username, email); err != nil {
+		return nil, err
 	}
 
 	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hashedPassword, err := s.passwords.Hash(password)
 	if err != nil {
 		return nil, err
 	}
@@ -55,7 +166,7 @@ func (s *Service) CreateUser(username, password, email string) (*models.User, er
 		ID:           uuid.New(),
 		Username:     username,
 		Email:        email,
-		PasswordHash: string(hashedPassword),
+		PasswordHash: hashedPassword,
 	}
 
 	// Save user to database
@@ -66,95 +177,492 @@ func (s *Service) CreateUser(username, password, email string) (*models.User, er
 	return user, nil
 }
 
-// AuthenticateUser authenticates a user with username and password
-func (s *Service) AuthenticateUser(username, password string) (*models.User, error) {
+// AuthenticateUser authenticates a user with username and password from an
+// IP address. Repeated failures lock the account for the configured window,
+// and a single IP that keeps failing is refused before its guesses count.
+// Every attempt is recorded in the login history.
+func (s *Service) AuthenticateUser(username, password, ipAddress, userAgent string) (*models.User, error) {
+	user, err := s.authenticate(username, password, ipAddress)
+	s.recordLogin(user, username, passwordLoginMethod, ipAddress, userAgent, err)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// authenticate checks a password login. The account the username belongs
+// to is returned even when the login fails, so the failure can be recorded
+// against it.
+func (s *Service) authenticate(username, password, ipAddress string) (*models.User, error) {
 	// Get user by username
 	user, err := s.userRepo.GetByUsername(username)
-	if err != nil {
-		return nil, errors.New("invalid credentials")
+	if err != nil || user == nil {
+		return nil, ErrInvalidCredentials
 	}
-	if user == nil {
-		return nil, errors.New("invalid credentials")
+
+	now := time.Now()
+	if user.IsLocked(now) {
+		return user, ErrAccountLocked
+	}
+	if s.throttle.blocked(username, ipAddress, s.security.MaxLoginAttempts, s.security.LoginAttemptsWindow, now) {
+		return user, ErrTooManyAttempts
 	}
 
 	// Verify password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
-		return nil, errors.New("invalid credentials")
+	rehash, err := s.passwords.Verify(password, user.PasswordHash)
+	if err != nil {
+		return user, s.recordFailedLogin(user, ipAddress, now)
+	}
+
+	// Only report a disabled account once the password proves who is asking
+	if user.IsBanned || !user.IsActive {
+		return user, ErrAccountDisabled
+	}
+
+	if rehash {
+		s.upgradePasswordHash(user, password)
 	}
 
+	if err := s.userRepo.UpdateLastLogin(user.ID); err != nil {
+		return user, err
+	}
+	s.throttle.reset(username, ipAddress)
+	user.LastLoginAt = &now
+	user.LoginAttempts = 0
+	user.LastFailedLoginAt = nil
+	user.LockedUntil = nil
+	s.InvalidateUser(user.ID)
+
 	return user, nil
 }
 
-// GenerateToken generates a JWT token for a user
-func (s *Service) GenerateToken(user *models.User) (string, error) {
+// upgradePasswordHash re-hashes a verified password with the current
+// algorithm and parameters. Failing to do so does not fail the login; the
+// old hash still works and is upgraded next time.
+func (s *Service) upgradePasswordHash(user *models.User, plaintext string) {
+	hash, err := s.passwords.Hash(plaintext)
+	if err == nil {
+		err = s.userRepo.UpdatePasswordHash(user.ID, hash)
+	}
+	if err != nil {
+		logrus.WithError(err).WithField("user_id", user.ID).Warn("Failed to upgrade password hash")
+		return
+	}
+	user.PasswordHash = hash
+}
+
+// recordFailedLogin counts a wrong password against the account and its
+// source, returning the error to report to the client
+func (s *Service) recordFailedLogin(user *models.User, ipAddress string, now time.Time) error {
+	s.throttle.fail(user.Username, ipAddress, s.security.LoginAttemptsWindow, now)
+
+	weight := unknownIPFailureWeight
+	known, err := s.sessionRepo.HasUsedIP(user.ID, ipAddress)
+	if err != nil {
+		return err
+	}
+	if known {
+		weight = knownIPFailureWeight
+	}
+
+	limit := s.security.MaxLoginAttempts * knownIPFailureWeight
+	lockedUntil, err := s.userRepo.RecordFailedLogin(user.ID, weight, limit, s.security.LoginAttemptsWindow)
+	if err != nil {
+		return err
+	}
+	if lockedUntil != nil {
+		return ErrAccountLocked
+	}
+	return ErrInvalidCredentials
+}
+
+// StartSession opens a new session for a user and issues its tokens
+func (s *Service) StartSession(user *models.User, ipAddress, userAgent string) (*TokenPair, error) {
+	refreshToken, err := generateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	session := &models.Session{
+		UserID:           user.ID,
+		RefreshTokenHash: hashRefreshToken(refreshToken),
+		IPAddress:        ipAddress,
+		UserAgent:        userAgent,
+		LastUsedAt:       time.Now(),
+		ExpiresAt:        time.Now().Add(s.refreshTokenLifetime()),
+	}
+	if err := s.sessionRepo.Create(session); err != nil {
+		return nil, err
+	}
+
+	accessToken, err := s.GenerateToken(user, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		SessionID:    session.ID,
+	}, nil
+}
+
+// GenerateToken generates a JWT access token for a user's session
+func (s *Service) GenerateToken(user *models.User, sessionID uuid.UUID) (string, error) {
 	claims := jwt.MapClaims{
-		"user_id":  user.ID.String(),
-		"username": user.Username,
-		"exp":      time.Now().Add(24 * time.Hour).Unix(), // 24 hours
-		"iat":      time.Now().Unix(),
+		"user_id":    user.ID.String(),
+		"username":   user.Username,
+		"role":       string(user.Role),
+		"session_id": sessionID.String(),
+		"exp":        time.Now().Add(s.accessTokenLifetime()).Unix(),
+		"iat":        time.Now().Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.jwtSecret))
+	return token.SignedString(s.signingKey())
 }
 
 // ValidateToken validates a JWT token and returns user information
 func (s *Service) ValidateToken(tokenString string) (*models.User, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("invalid signing method")
-		}
-		return []byte(s.jwtSecret), nil
-	})
+	user, _, err := s.ValidateSession(tokenString)
+	return user, err
+}
 
+// ValidateSession validates a JWT token and returns the user and session it
+// belongs to. Tokens of revoked or expired sessions are rejected.
+func (s *Service) ValidateSession(tokenString string) (*models.User, uuid.UUID, error) {
+	userID, sessionID, err := s.tokenSession(tokenString)
 	if err != nil {
-		return nil, err
+		return nil, uuid.Nil, err
+	}
+
+	if s.isRevoked(sessionID) {
+		return nil, uuid.Nil, ErrSessionInvalid
+	}
+	session, err := s.sessionRepo.GetByID(sessionID)
+	if err != nil || session.UserID != userID || !session.IsActive(time.Now()) {
+		return nil, uuid.Nil, ErrSessionInvalid
+	}
+
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+	if user.IsBanned || !user.IsActive {
+		return nil, uuid.Nil, ErrAccountDisabled
+	}
+
+	return user, sessionID, nil
+}
+
+// DeletedAccountID returns the user a token was issued to if their account
+// has since been deleted. Deleting an account revokes its sessions, so this
+// is all such a token still proves; it lets a retried deletion be answered
+// as done rather than refused.
+func (s *Service) DeletedAccountID(tokenString string) (uuid.UUID, bool) {
+	userID, sessionID, err := s.tokenSession(tokenString)
+	if err != nil {
+		return uuid.Nil, false
+	}
+
+	session, err := s.sessionRepo.GetByID(sessionID)
+	if err != nil || session.UserID != userID {
+		return uuid.Nil, false
+	}
+
+	if !s.IsDeleted(userID) {
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// IsDeleted reports whether a user's account has been deleted
+func (s *Service) IsDeleted(userID uuid.UUID) bool {
+	deleted, err := s.userRepo.IsDeleted(userID)
+	return err == nil && deleted
+}
+
+// tokenSession checks a JWT token's signature and expiry and returns the
+// user and session it was issued for
+func (s *Service) tokenSession(tokenString string) (uuid.UUID, uuid.UUID, error) {
+	token, err := s.parseToken(tokenString)
+
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
 	}
 
 	if !token.Valid {
-		return nil, errors.New("invalid token")
+		return uuid.Nil, uuid.Nil, errors.New("invalid token")
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
-		return nil, errors.New("invalid token claims")
+		return uuid.Nil, uuid.Nil, errors.New("invalid token claims")
 	}
 
 	userIDStr, ok := claims["user_id"].(string)
 	if !ok {
-		return nil, errors.New("invalid user_id in token")
+		return uuid.Nil, uuid.Nil, errors.New("invalid user_id in token")
 	}
 
 	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
-		return nil, errors.New("invalid user_id format")
+		return uuid.Nil, uuid.Nil, errors.New("invalid user_id format")
 	}
 
-	user, err := s.userRepo.GetByID(userID)
-	if err != nil || user == nil {
-		return nil, errors.New("user not found")
+	sessionIDStr, ok := claims["session_id"].(string)
+	if !ok {
+		return uuid.Nil, uuid.Nil, errors.New("invalid session_id in token")
 	}
 
-	return user, nil
+	sessionID, err := uuid.Parse(sessionIDStr)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, errors.New("invalid session_id format")
+	}
+
+	return userID, sessionID, nil
 }
 
-// RefreshToken creates a new token from a refresh token
-func (s *Service) RefreshToken(refreshToken string) (string, error) {
-	// For simplicity, using the same validation logic
-	// In production, you'd have separate refresh token logic
-	user, err := s.ValidateToken(refreshToken)
+// RefreshSession exchanges a refresh token for a new token pair. The refresh
+// token is rotated, so each one can only be used once; presenting a token
+// that was already exchanged revokes the session it belonged to, since
+// either the client or an attacker holds a stolen copy.
+func (s *Service) RefreshSession(refreshToken, ipAddress, userAgent string) (*TokenPair, error) {
+	tokenHash := hashRefreshToken(refreshToken)
+
+	session, err := s.sessionRepo.GetByRefreshTokenHash(tokenHash)
 	if err != nil {
-		return "", err
+		if rotated, rotatedErr := s.sessionRepo.GetRotatedToken(tokenHash); rotatedErr == nil {
+			return nil, s.revokeReusedSession(rotated.SessionID)
+		}
+		return nil, ErrSessionInvalid
+	}
+	if !session.IsActive(time.Now()) {
+		return nil, ErrSessionInvalid
+	}
+
+	user, err := s.GetUser(session.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	newRefreshToken, err := generateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(s.refreshTokenLifetime())
+	rotated, err := s.sessionRepo.Rotate(session.ID, tokenHash, hashRefreshToken(newRefreshToken), ipAddress, userAgent, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		// A concurrent request exchanged the same token first
+		return nil, s.revokeReusedSession(session.ID)
+	}
+
+	accessToken, err := s.GenerateToken(user, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: newRefreshToken,
+		SessionID:    session.ID,
+	}, nil
+}
+
+// revokeReusedSession ends a session whose refresh token was presented twice
+func (s *Service) revokeReusedSession(sessionID uuid.UUID) error {
+	if err := s.sessionRepo.RevokeByID(sessionID); err != nil {
+		return err
+	}
+	s.listRevoked(sessionID)
+	return ErrRefreshTokenReused
+}
+
+// accessTokenLifetime is how long an access token is accepted after issue
+func (s *Service) accessTokenLifetime() time.Duration {
+	return time.Duration(s.security.JWTExpirationHours) * time.Hour
+}
+
+// refreshTokenLifetime is how long a session stays valid without being refreshed
+func (s *Service) refreshTokenLifetime() time.Duration {
+	return time.Duration(s.security.RefreshTokenDays) * 24 * time.Hour
+}
+
+// ListSessions returns a user's active sessions
+func (s *Service) ListSessions(userID uuid.UUID) ([]models.Session, error) {
+	return s.sessionRepo.GetActiveByUser(userID)
+}
+
+// RevokeSession revokes one of a user's sessions
+func (s *Service) RevokeSession(userID, sessionID uuid.UUID) error {
+	revoked, err := s.sessionRepo.Revoke(userID, sessionID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrSessionNotFound
+	}
+	s.listRevoked(sessionID)
+	return nil
+}
+
+// RevokeOtherSessions revokes all of a user's sessions except the current one
+func (s *Service) RevokeOtherSessions(userID, currentSessionID uuid.UUID) (int64, error) {
+	return s.revokeAllExcept(userID, currentSessionID)
+}
+
+// ChangePassword sets a new password after checking the current one, then
+// signs out every other session
+func (s *Service) ChangePassword(userID, currentSessionID uuid.UUID, currentPassword, newPassword string) error {
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return err
 	}
 
-	return s.GenerateToken(user)
+	if _, err := s.passwords.Verify(currentPassword, user.PasswordHash); err != nil {
+		return ErrInvalidCredentials
+	}
+
+	if err := s.CheckPassword(newPassword, user.Username, user.Email); err != nil {
+		return err
+	}
+
+	hashedPassword, err := s.passwords.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+
+	// Only the hash is written: the cached user may be behind on columns
+	// others change, such as the chip balance
+	if err := s.userRepo.UpdatePasswordHash(userID, hashedPassword); err != nil {
+		return err
+	}
+	s.InvalidateUser(userID)
+
+	_, err = s.revokeAllExcept(userID, currentSessionID)
+	return err
+}
+
+// CheckPassword validates a new password for an account against the
+// password policy, returning a *password.ValidationError listing every rule
+// it breaks. Registration, password changes and resets all go through it.
+func (s *Service) CheckPassword(newPassword, username, email string) error {
+	return s.rules.Check(newPassword, username, email)
 }
 
-// GetUser returns a user by ID
+// GetUser returns a user by ID. Lookups are served from a short-lived cache;
+// the returned user is a copy the caller may modify.
 func (s *Service) GetUser(userID uuid.UUID) (*models.User, error) {
+	now := time.Now()
+
+	s.cacheMu.Lock()
+	entry, ok := s.userCache[userID]
+	s.cacheMu.Unlock()
+	if ok && now.Before(entry.expiresAt) {
+		user := entry.user
+		return &user, nil
+	}
+
 	user, err := s.userRepo.GetByID(userID)
 	if err != nil || user == nil {
-		return nil, errors.New("user not found")
+		return nil, ErrUserNotFound
+	}
+
+	s.cacheMu.Lock()
+	if len(s.userCache) >= maxCachedUsers {
+		s.userCache = make(map[uuid.UUID]cachedUser)
 	}
+	s.userCache[userID] = cachedUser{user: *user, expiresAt: now.Add(userCacheTTL)}
+	s.cacheMu.Unlock()
+
 	return user, nil
 }
+
+// CurrentRole reads a user's role straight from the database, bypassing the
+// user cache, so a demotion takes effect on the very next privileged request
+func (s *Service) CurrentRole(userID uuid.UUID) (models.Role, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil || user == nil {
+		return "", ErrUserNotFound
+	}
+	if user.IsBanned || !user.IsActive {
+		return "", ErrAccountDisabled
+	}
+	if user.Role == "" {
+		return models.RolePlayer, nil
+	}
+	return user.Role, nil
+}
+
+// SetRole changes a user's role
+func (s *Service) SetRole(userID uuid.UUID, role models.Role) error {
+	if err := s.userRepo.UpdateRole(userID, role); err != nil {
+		return err
+	}
+	s.InvalidateUser(userID)
+	return nil
+}
+
+// SetAvatar changes the URL of a user's avatar
+func (s *Service) SetAvatar(userID uuid.UUID, avatar string) error {
+	if err := s.userRepo.UpdateAvatar(userID, avatar); err != nil {
+		return err
+	}
+	s.InvalidateUser(userID)
+	return nil
+}
+
+// InvalidateUser drops a user from the cache after it changes in the database
+func (s *Service) InvalidateUser(userID uuid.UUID) {
+	s.cacheMu.Lock()
+	delete(s.userCache, userID)
+	s.cacheMu.Unlock()
+}
+
+// VerifyPassword re-confirms a signed-in user's password before a sensitive operation
+func (s *Service) VerifyPassword(userID uuid.UUID, password string) error {
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.passwords.Verify(password, user.PasswordHash); err != nil {
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+// DeleteAccount anonymizes and deletes a user, revoking all of their sessions
+func (s *Service) DeleteAccount(userID uuid.UUID) error {
+	sessions, err := s.sessionRepo.GetActiveByUser(userID)
+	if err != nil {
+		return err
+	}
+	if err := s.userRepo.DeleteAccount(userID); err != nil {
+		return err
+	}
+	s.InvalidateUser(userID)
+	for _, session := range sessions {
+		s.listRevoked(session.ID)
+	}
+	return nil
+}
+
+// generateRefreshToken returns a random opaque refresh token
+func generateRefreshToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashRefreshToken hashes a refresh token for storage so a database leak does not expose live tokens
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,266 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/internal/config"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/password"
+	"github.com/primoPoker/server/internal/repository"
+	"github.com/primoPoker/server/internal/testutil"
+)
+
+func newTestService(t *testing.T) (*Service, *repository.UserRepository) {
+	t.Helper()
+
+	db := testutil.NewDB(t, &models.User{}, &models.Session{}, &models.RotatedRefreshToken{}, &models.LoginEvent{}, &models.APIKey{})
+	userRepo := repository.NewUserRepository(db)
+	return NewService("test-secret", config.SecurityConfig{}, userRepo, repository.NewSessionRepository(db), repository.NewLoginEventRepository(db), repository.NewAPIKeyRepository(db)), userRepo
+}
+
+func TestCreateUserPersists(t *testing.T) {
+	service, userRepo := newTestService(t)
+
+	user, err := service.CreateUser("alice", "correct-horse-42", "alice@example.com")
+	require.NoError(t, err)
+
+	stored, err := userRepo.GetByUsername("alice")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, stored.ID)
+	assert.NotEqual(t, "correct-horse-42", stored.PasswordHash)
+
+	_, err = service.CreateUser("alice", "correct-horse-42", "other@example.com")
+	assert.EqualError(t, err, "username already exists")
+
+	_, err = service.CreateUser("bob", "correct-horse-42", "alice@example.com")
+	assert.EqualError(t, err, "email already exists")
+
+	_, err = service.CreateUser("carol", "short", "carol@example.com")
+	assert.Error(t, err)
+
+	// Breached passwords are refused however long they are
+	var invalid *password.ValidationError
+	_, err = service.CreateUser("dave", "password123", "dave@example.com")
+	require.ErrorAs(t, err, &invalid)
+	assert.Equal(t, "breached", invalid.Violations[0].Rule)
+}
+
+func TestAuthenticateUserTracksAttempts(t *testing.T) {
+	service, userRepo := newTestService(t)
+
+	user, err := service.CreateUser("alice", "correct-horse-42", "alice@example.com")
+	require.NoError(t, err)
+
+	_, err = service.AuthenticateUser("alice", "wrong-password", "127.0.0.1", "test")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = service.AuthenticateUser("nobody", "correct-horse-42", "127.0.0.1", "test")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	stored, err := userRepo.GetByID(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stored.LoginAttempts)
+	assert.Nil(t, stored.LastLoginAt)
+
+	authenticated, err := service.AuthenticateUser("alice", "correct-horse-42", "127.0.0.1", "test")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, authenticated.ID)
+
+	stored, err = userRepo.GetByID(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 0, stored.LoginAttempts)
+	assert.NotNil(t, stored.LastLoginAt)
+}
+
+func TestAuthenticateUserUpgradesBcryptHash(t *testing.T) {
+	service, userRepo := newTestService(t)
+
+	user, err := service.CreateUser("alice", "correct-horse-42", "alice@example.com")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(user.PasswordHash, "$argon2id$"))
+
+	// Accounts from before argon2id still hold bcrypt hashes
+	legacy, err := password.NewBcrypt(0).Hash("correct-horse-42")
+	require.NoError(t, err)
+	require.NoError(t, userRepo.UpdatePasswordHash(user.ID, legacy))
+
+	_, err = service.AuthenticateUser("alice", "correct-horse-42", "127.0.0.1", "test")
+	require.NoError(t, err)
+
+	stored, err := userRepo.GetByID(user.ID)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(stored.PasswordHash, "$argon2id$"))
+
+	// The upgraded hash verifies and the old password still signs in
+	_, err = service.AuthenticateUser("alice", "correct-horse-42", "127.0.0.1", "test")
+	assert.NoError(t, err)
+	_, err = service.AuthenticateUser("alice", "wrong-password", "127.0.0.1", "test")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestAuthenticateUserRejectsBanned(t *testing.T) {
+	service, userRepo := newTestService(t)
+
+	user, err := service.CreateUser("alice", "correct-horse-42", "alice@example.com")
+	require.NoError(t, err)
+	require.NoError(t, userRepo.BanUser(user.ID, "abuse"))
+
+	_, err = service.AuthenticateUser("alice", "correct-horse-42", "127.0.0.1", "test")
+	assert.ErrorIs(t, err, ErrAccountDisabled)
+
+	_, err = service.AuthenticateUser("alice", "wrong-password", "127.0.0.1", "test")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestSessionLifecycle(t *testing.T) {
+	service, _ := newTestService(t)
+
+	user, err := service.CreateUser("alice", "correct-horse-42", "alice@example.com")
+	require.NoError(t, err)
+
+	tokens, err := service.StartSession(user, "127.0.0.1", "test")
+	require.NoError(t, err)
+
+	validated, sessionID, err := service.ValidateSession(tokens.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, validated.ID)
+	assert.Equal(t, tokens.SessionID, sessionID)
+
+	refreshed, err := service.RefreshSession(tokens.RefreshToken, "127.0.0.1", "test")
+	require.NoError(t, err)
+	assert.NotEqual(t, tokens.RefreshToken, refreshed.RefreshToken)
+	assert.Equal(t, tokens.SessionID, refreshed.SessionID)
+
+	require.NoError(t, service.RevokeSession(user.ID, tokens.SessionID))
+	_, _, err = service.ValidateSession(refreshed.AccessToken)
+	assert.ErrorIs(t, err, ErrSessionInvalid)
+	_, err = service.RefreshSession(refreshed.RefreshToken, "127.0.0.1", "test")
+	assert.ErrorIs(t, err, ErrSessionInvalid)
+	assert.ErrorIs(t, service.RevokeSession(user.ID, tokens.SessionID), ErrSessionNotFound)
+}
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	service, _ := newTestService(t)
+
+	user, err := service.CreateUser("alice", "correct-horse-42", "alice@example.com")
+	require.NoError(t, err)
+
+	tokens, err := service.StartSession(user, "127.0.0.1", "test")
+	require.NoError(t, err)
+
+	first, err := service.RefreshSession(tokens.RefreshToken, "127.0.0.1", "test")
+	require.NoError(t, err)
+	second, err := service.RefreshSession(first.RefreshToken, "127.0.0.1", "test")
+	require.NoError(t, err)
+
+	// Replaying any earlier token ends the session for everyone holding it
+	_, err = service.RefreshSession(tokens.RefreshToken, "10.0.0.1", "attacker")
+	assert.ErrorIs(t, err, ErrRefreshTokenReused)
+
+	_, _, err = service.ValidateSession(second.AccessToken)
+	assert.ErrorIs(t, err, ErrSessionInvalid)
+	_, err = service.RefreshSession(second.RefreshToken, "127.0.0.1", "test")
+	assert.ErrorIs(t, err, ErrSessionInvalid)
+
+	_, err = service.RefreshSession("never-issued", "127.0.0.1", "test")
+	assert.ErrorIs(t, err, ErrSessionInvalid)
+}
+
+func TestRefreshTokenLifetimeFromConfig(t *testing.T) {
+	db := testutil.NewDB(t, &models.User{}, &models.Session{}, &models.RotatedRefreshToken{}, &models.LoginEvent{}, &models.APIKey{})
+	sessionRepo := repository.NewSessionRepository(db)
+	service := NewService("test-secret", config.SecurityConfig{RefreshTokenDays: 7}, repository.NewUserRepository(db), sessionRepo, repository.NewLoginEventRepository(db), repository.NewAPIKeyRepository(db))
+
+	user, err := service.CreateUser("alice", "correct-horse-42", "alice@example.com")
+	require.NoError(t, err)
+
+	tokens, err := service.StartSession(user, "127.0.0.1", "test")
+	require.NoError(t, err)
+
+	session, err := sessionRepo.GetByID(tokens.SessionID)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(7*24*time.Hour), session.ExpiresAt, time.Minute)
+}
+
+func TestAccessTokenLifetimeFromConfig(t *testing.T) {
+	db := testutil.NewDB(t, &models.User{}, &models.Session{}, &models.RotatedRefreshToken{}, &models.LoginEvent{}, &models.APIKey{})
+	service := NewService("test-secret", config.SecurityConfig{JWTExpirationHours: 2}, repository.NewUserRepository(db), repository.NewSessionRepository(db), repository.NewLoginEventRepository(db), repository.NewAPIKeyRepository(db))
+
+	user, err := service.CreateUser("alice", "correct-horse-42", "alice@example.com")
+	require.NoError(t, err)
+
+	tokens, err := service.StartSession(user, "127.0.0.1", "test")
+	require.NoError(t, err)
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(tokens.AccessToken, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte("test-secret"), nil
+	})
+	require.NoError(t, err)
+	expiresAt, err := claims.GetExpirationTime()
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(2*time.Hour), expiresAt.Time, time.Minute)
+
+	// A service with another secret rejects the token
+	other := NewService("other-secret", config.SecurityConfig{}, repository.NewUserRepository(db), repository.NewSessionRepository(db), repository.NewLoginEventRepository(db), repository.NewAPIKeyRepository(db))
+	_, _, err = other.ValidateSession(tokens.AccessToken)
+	assert.Error(t, err)
+}
+
+func TestChangePasswordRevokesOtherSessions(t *testing.T) {
+	service, _ := newTestService(t)
+
+	user, err := service.CreateUser("alice", "correct-horse-42", "alice@example.com")
+	require.NoError(t, err)
+
+	current, err := service.StartSession(user, "127.0.0.1", "laptop")
+	require.NoError(t, err)
+	other, err := service.StartSession(user, "127.0.0.1", "phone")
+	require.NoError(t, err)
+
+	// Prime the user cache so the new hash has to replace a cached one
+	_, _, err = service.ValidateSession(current.AccessToken)
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, service.ChangePassword(user.ID, current.SessionID, "wrong-password", "newpassword1"), ErrInvalidCredentials)
+	var invalid *password.ValidationError
+	require.ErrorAs(t, service.ChangePassword(user.ID, current.SessionID, "correct-horse-42", "alice-rocks"), &invalid)
+	assert.Equal(t, "contains_username", invalid.Violations[0].Rule)
+	require.NoError(t, service.ChangePassword(user.ID, current.SessionID, "correct-horse-42", "newpassword1"))
+
+	_, _, err = service.ValidateSession(current.AccessToken)
+	assert.NoError(t, err)
+	_, _, err = service.ValidateSession(other.AccessToken)
+	assert.ErrorIs(t, err, ErrSessionInvalid)
+
+	assert.NoError(t, service.VerifyPassword(user.ID, "newpassword1"))
+	assert.ErrorIs(t, service.VerifyPassword(user.ID, "correct-horse-42"), ErrInvalidCredentials)
+}
+
+func TestChangePasswordKeepsChipsCreditedMeanwhile(t *testing.T) {
+	service, userRepo := newTestService(t)
+
+	user, err := service.CreateUser("alice", "correct-horse-42", "alice@example.com")
+	require.NoError(t, err)
+	session, err := service.StartSession(user, "127.0.0.1", "laptop")
+	require.NoError(t, err)
+
+	// The user is cached with their balance before a cash-out credits them
+	cached, err := service.GetUser(user.ID)
+	require.NoError(t, err)
+	balance, err := userRepo.CreditChips(user.ID, 500)
+	require.NoError(t, err)
+	require.Equal(t, cached.ChipBalance+500, balance)
+
+	require.NoError(t, service.ChangePassword(user.ID, session.SessionID, "correct-horse-42", "newpassword1"))
+
+	stored, err := userRepo.GetByID(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, balance, stored.ChipBalance, "the password change leaves the balance alone")
+	assert.NoError(t, service.VerifyPassword(user.ID, "newpassword1"))
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// RotateJWTSecret signs tokens with secret from now on. Tokens signed with
+// the secret it replaces are still accepted until they would have expired,
+// so rotating the secret signs no one out.
+func (s *Service) RotateJWTSecret(secret string) {
+	if secret == "" {
+		return
+	}
+
+	s.keyMu.Lock()
+	defer s.keyMu.Unlock()
+	if secret == s.jwtSecret {
+		return
+	}
+
+	lifetime := s.accessTokenLifetime()
+	if lifetime < linkTokenLifetime {
+		lifetime = linkTokenLifetime
+	}
+	s.previousSecret = s.jwtSecret
+	s.previousUntil = time.Now().Add(lifetime)
+	s.jwtSecret = secret
+	logrus.WithField("previous_accepted_until", s.previousUntil).Info("JWT secret rotated")
+}
+
+// signingKey returns the secret new tokens are signed with
+func (s *Service) signingKey() []byte {
+	s.keyMu.RLock()
+	defer s.keyMu.RUnlock()
+	return []byte(s.jwtSecret)
+}
+
+// parseToken parses a token signed with the current secret or, while
+// tokens it signed may still be live, the secret it replaced
+func (s *Service) parseToken(tokenString string) (*jwt.Token, error) {
+	s.keyMu.RLock()
+	current, previous, previousUntil := s.jwtSecret, s.previousSecret, s.previousUntil
+	s.keyMu.RUnlock()
+
+	token, err := jwt.Parse(tokenString, hmacKey(current))
+	if err != nil && previous != "" && time.Now().Before(previousUntil) && errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+		return jwt.Parse(tokenString, hmacKey(previous))
+	}
+	return token, err
+}
+
+// hmacKey returns a key function accepting only HMAC-signed tokens
+func hmacKey(secret string) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("invalid signing method")
+		}
+		return []byte(secret), nil
+	}
+}
@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotateJWTSecretKeepsLiveTokensValid(t *testing.T) {
+	service, _ := newTestService(t)
+	user, err := service.CreateUser("alice", "correct-horse-42", "alice@example.com")
+	require.NoError(t, err)
+	before, err := service.StartSession(user, "127.0.0.1", "laptop")
+	require.NoError(t, err)
+
+	service.RotateJWTSecret("rotated-secret")
+	after, err := service.StartSession(user, "127.0.0.1", "phone")
+	require.NoError(t, err)
+
+	_, err = service.ValidateToken(after.AccessToken)
+	assert.NoError(t, err, "new tokens are signed with the new secret")
+	_, err = service.ValidateToken(before.AccessToken)
+	assert.NoError(t, err, "tokens signed before the rotation are still accepted")
+
+	// Once tokens signed with the old secret have expired, it is dropped
+	service.keyMu.Lock()
+	service.previousUntil = time.Now().Add(-time.Second)
+	service.keyMu.Unlock()
+	_, err = service.ValidateToken(before.AccessToken)
+	assert.Error(t, err)
+
+	// A second rotation retires the first secret at once
+	service.RotateJWTSecret("another-secret")
+	service.RotateJWTSecret("yet-another-secret")
+	_, err = service.ValidateToken(after.AccessToken)
+	assert.Error(t, err)
+}
@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Buckets are token buckets shared by every instance, for rate limits that
+// hold however many instances a client reaches
+type Buckets interface {
+	// Take takes a token from key's bucket if it has one. The bucket holds
+	// up to burst tokens and refills at perMinute tokens a minute.
+	Take(ctx context.Context, key string, perMinute, burst int) (Bucket, error)
+}
+
+// Bucket is the outcome of a Take
+type Bucket struct {
+	Allowed   bool
+	Remaining int           // Whole tokens left
+	Reset     time.Duration // Until the bucket is full again
+}
+
+// takeScript refills a bucket for the time since it was last used and
+// takes a token, in one step so instances taking at once cannot both take
+// the last token. Redis's clock is used, so instances need not agree on
+// the time. An idle bucket expires once it would be full again.
+var takeScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local clock = redis.call('TIME')
+local now = tonumber(clock[1]) * 1000 + math.floor(tonumber(clock[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens, ts = burst, now
+end
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+local reset = math.ceil((burst - tokens) / rate)
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('PEXPIRE', KEYS[1], reset + 1000)
+return {allowed, math.floor(tokens), reset}
+`)
+
+// RedisBuckets keeps token buckets in Redis
+type RedisBuckets struct {
+	client *redis.Client
+}
+
+// NewRedisBuckets creates token buckets kept in Redis
+func NewRedisBuckets(client *redis.Client) *RedisBuckets {
+	return &RedisBuckets{client: client}
+}
+
+// Take takes a token from key's bucket if it has one
+func (b *RedisBuckets) Take(ctx context.Context, key string, perMinute, burst int) (Bucket, error) {
+	perMillisecond := float64(perMinute) / float64(time.Minute/time.Millisecond)
+	result, err := takeScript.Run(ctx, b.client, []string{"bucket:" + key}, perMillisecond, max(burst, 1)).Int64Slice()
+	if err != nil {
+		return Bucket{}, err
+	}
+	return Bucket{
+		Allowed:   result[0] == 1,
+		Remaining: int(result[1]),
+		Reset:     time.Duration(result[2]) * time.Millisecond,
+	}, nil
+}
@@ -0,0 +1,88 @@
+// Package cache holds state shared between server instances: short-lived
+// keys and counters, which users are connected where, and the game
+// broadcasts relayed between them. Each piece has a Redis implementation
+// for deployments running more than one instance; a single instance keeps
+// keys in memory, used when Redis is not configured, knows who is
+// connected from its own connections, and needs no relay.
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/primoPoker/server/internal/config"
+)
+
+// ErrMiss is returned by Get for a key that is not set or has expired
+var ErrMiss = errors.New("cache miss")
+
+// Store holds string values under keys that expire
+type Store interface {
+	// Get returns the value of key, or ErrMiss
+	Get(ctx context.Context, key string) (string, error)
+	// Set stores value under key for ttl
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	// SetNX stores value under key for ttl unless the key is already set,
+	// reporting whether it stored it
+	SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+	// Delete removes key
+	Delete(ctx context.Context, key string) error
+	// Incr adds one to the counter under key and returns the new count. A
+	// counter created by Incr expires after ttl.
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+	// Ping checks the store can be reached
+	Ping(ctx context.Context) error
+}
+
+// Presence tracks which users hold a WebSocket connection on any instance,
+// and the game each connection follows
+type Presence interface {
+	// Join records that the user connected to this instance, following
+	// gameID unless it is empty. The record lapses after ttl unless Join
+	// is called again, so the users of an instance that stopped without
+	// leaving go offline.
+	Join(ctx context.Context, userID, gameID string, ttl time.Duration) error
+	// Leave records that the user's connection to this instance closed
+	Leave(ctx context.Context, userID, gameID string) error
+	// IsOnline reports whether the user is connected to any instance
+	IsOnline(ctx context.Context, userID string) (bool, error)
+	// GameUsers returns the users following a game on any instance
+	GameUsers(ctx context.Context, gameID string) ([]string, error)
+}
+
+// Fanout relays game broadcasts between instances, so players at one table
+// see its updates whichever instance they are connected to
+type Fanout interface {
+	// Publish sends a game's broadcast to every instance, this one included
+	Publish(ctx context.Context, gameID string, data []byte) error
+	// Subscribe passes deliver each broadcast published by any instance,
+	// each game's in the order they were published, until ctx is done
+	Subscribe(ctx context.Context, deliver func(gameID string, data []byte)) error
+}
+
+// Connect opens the Redis client the configuration names: the Memorystore
+// instance on GCP, otherwise REDIS_URL. It returns nil when neither is set,
+// in which case callers keep their state in memory.
+func Connect(cfg *config.Config) (*redis.Client, error) {
+	var options *redis.Options
+	switch {
+	case cfg.GCP.MemorystoreRedis != "":
+		options = &redis.Options{Addr: cfg.GCP.MemorystoreRedis}
+	case cfg.RedisURL != "":
+		parsed, err := redis.ParseURL(cfg.RedisURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis URL: %w", err)
+		}
+		options = parsed
+	default:
+		return nil, nil
+	}
+	if cfg.RedisPassword != "" {
+		options.Password = cfg.RedisPassword
+	}
+	return redis.NewClient(options), nil
+}
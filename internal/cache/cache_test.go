@@ -0,0 +1,200 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/internal/config"
+)
+
+// newMiniredis starts an in-process Redis and returns a client of it
+func newMiniredis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return server, client
+}
+
+func TestStores(t *testing.T) {
+	server, client := newMiniredis(t)
+	memory := NewMemory()
+	clock := time.Now()
+	memory.now = func() time.Time { return clock }
+
+	stores := map[string]struct {
+		store   Store
+		advance func(time.Duration)
+	}{
+		"memory": {memory, func(d time.Duration) { clock = clock.Add(d) }},
+		"redis":  {NewRedis(client), server.FastForward},
+	}
+
+	for name, tc := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := tc.store
+			require.NoError(t, store.Ping(ctx))
+
+			_, err := store.Get(ctx, "missing")
+			assert.ErrorIs(t, err, ErrMiss)
+
+			require.NoError(t, store.Set(ctx, "key", "value", time.Minute))
+			value, err := store.Get(ctx, "key")
+			require.NoError(t, err)
+			assert.Equal(t, "value", value)
+
+			stored, err := store.SetNX(ctx, "key", "other", time.Minute)
+			require.NoError(t, err)
+			assert.False(t, stored, "SetNX leaves a key that is set")
+			stored, err = store.SetNX(ctx, "fresh", "other", time.Minute)
+			require.NoError(t, err)
+			assert.True(t, stored)
+
+			require.NoError(t, store.Delete(ctx, "key"))
+			_, err = store.Get(ctx, "key")
+			assert.ErrorIs(t, err, ErrMiss)
+
+			for want := int64(1); want <= 3; want++ {
+				count, err := store.Incr(ctx, "counter", time.Minute)
+				require.NoError(t, err)
+				assert.Equal(t, want, count)
+			}
+
+			// Later increments do not push the counter's expiry back
+			tc.advance(61 * time.Second)
+			_, err = store.Get(ctx, "fresh")
+			assert.ErrorIs(t, err, ErrMiss, "keys expire")
+			count, err := store.Incr(ctx, "counter", time.Minute)
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), count, "an expired counter starts over")
+		})
+	}
+}
+
+func TestRedisBuckets(t *testing.T) {
+	server, client := newMiniredis(t)
+	clock := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	server.SetTime(clock)
+	buckets := NewRedisBuckets(client)
+	ctx := context.Background()
+
+	// 60 a minute in bursts of 3: a token a second
+	for i := 2; i >= 0; i-- {
+		bucket, err := buckets.Take(ctx, "ip:198.51.100.7", 60, 3)
+		require.NoError(t, err)
+		assert.True(t, bucket.Allowed)
+		assert.Equal(t, i, bucket.Remaining)
+	}
+	bucket, err := buckets.Take(ctx, "ip:198.51.100.7", 60, 3)
+	require.NoError(t, err)
+	assert.False(t, bucket.Allowed)
+	assert.Equal(t, 3*time.Second, bucket.Reset)
+
+	server.SetTime(clock.Add(1500 * time.Millisecond))
+	bucket, err = buckets.Take(ctx, "ip:198.51.100.7", 60, 3)
+	require.NoError(t, err)
+	assert.True(t, bucket.Allowed, "a token has been refilled")
+	assert.Equal(t, 0, bucket.Remaining)
+
+	// An idle bucket expires once it would be full
+	assert.True(t, server.Exists("bucket:ip:198.51.100.7"))
+	server.FastForward(5 * time.Second)
+	assert.False(t, server.Exists("bucket:ip:198.51.100.7"))
+}
+
+func TestPresence(t *testing.T) {
+	server, client := newMiniredis(t)
+	ctx := context.Background()
+	east := NewRedisPresence(client, "east")
+	west := NewRedisPresence(client, "west")
+
+	require.NoError(t, east.Join(ctx, "alice", "table-1", time.Minute))
+	online, err := west.IsOnline(ctx, "alice")
+	require.NoError(t, err)
+	assert.True(t, online, "a user connected to one instance is online on all of them")
+
+	// Moving instances: the new connection opens before the old closes
+	require.NoError(t, west.Join(ctx, "alice", "table-1", time.Minute))
+	require.NoError(t, east.Leave(ctx, "alice", "table-1"))
+	online, _ = east.IsOnline(ctx, "alice")
+	assert.True(t, online)
+
+	require.NoError(t, west.Leave(ctx, "alice", "table-1"))
+	online, _ = east.IsOnline(ctx, "alice")
+	assert.False(t, online)
+	assert.False(t, server.Exists("user:alice:conn:west"))
+}
+
+func TestPresenceGameUsers(t *testing.T) {
+	server, client := newMiniredis(t)
+	ctx := context.Background()
+	east := NewRedisPresence(client, "east")
+	west := NewRedisPresence(client, "west")
+
+	require.NoError(t, east.Join(ctx, "alice", "table-1", time.Minute))
+	require.NoError(t, west.Join(ctx, "bob", "table-1", time.Minute))
+	require.NoError(t, west.Join(ctx, "alice", "table-1", time.Minute))
+	require.NoError(t, west.Join(ctx, "carol", "table-2", time.Minute))
+	require.NoError(t, east.Join(ctx, "dave", "", time.Minute))
+
+	users, err := east.GameUsers(ctx, "table-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice", "bob"}, users, "the users at a table on every instance, each once")
+	users, _ = west.GameUsers(ctx, "table-2")
+	assert.Equal(t, []string{"carol"}, users)
+	users, _ = west.GameUsers(ctx, "table-3")
+	assert.Empty(t, users)
+
+	// Reconnecting to another table moves the connection off the first
+	require.NoError(t, west.Join(ctx, "carol", "table-1", time.Minute))
+	users, _ = west.GameUsers(ctx, "table-2")
+	assert.Empty(t, users)
+	members, _ := server.Members("game:table-2:users")
+	assert.Empty(t, members, "moved connections are pruned")
+
+	// Only one instance keeps refreshing its connections; the other's lapse
+	server.FastForward(40 * time.Second)
+	require.NoError(t, west.Join(ctx, "bob", "table-1", time.Minute))
+	require.NoError(t, west.Join(ctx, "carol", "table-1", time.Minute))
+	server.FastForward(40 * time.Second)
+
+	users, _ = east.GameUsers(ctx, "table-1")
+	assert.Equal(t, []string{"bob", "carol"}, users)
+	online, err := east.IsOnline(ctx, "alice")
+	require.NoError(t, err)
+	assert.False(t, online, "the connections of an instance that stopped refreshing them lapse")
+	online, _ = east.IsOnline(ctx, "dave")
+	assert.False(t, online)
+	online, _ = east.IsOnline(ctx, "bob")
+	assert.True(t, online)
+	members, _ = server.Members("game:table-1:users")
+	assert.ElementsMatch(t, []string{"bob|west", "carol|west"}, members)
+}
+
+func TestConnect(t *testing.T) {
+	client, err := Connect(&config.Config{})
+	require.NoError(t, err)
+	assert.Nil(t, client, "without Redis configured, state stays in memory")
+
+	client, err = Connect(&config.Config{RedisURL: "redis://:secret@cache.internal:6380/2"})
+	require.NoError(t, err)
+	assert.Equal(t, "cache.internal:6380", client.Options().Addr)
+	assert.Equal(t, 2, client.Options().DB)
+
+	client, err = Connect(&config.Config{
+		RedisURL: "redis://localhost:6379",
+		GCP:      config.GCPConfig{MemorystoreRedis: "10.0.0.3:6379"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.3:6379", client.Options().Addr, "Memorystore is preferred")
+
+	_, err = Connect(&config.Config{RedisURL: "not a url"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"context"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// fanoutPrefix starts the channel each game's broadcasts are published on
+const fanoutPrefix = "broadcast:"
+
+// RedisFanout relays game broadcasts through Redis pub/sub, on a channel
+// per game. Redis delivers a channel's messages in the order published,
+// and drops those sent while a subscriber is disconnected.
+type RedisFanout struct {
+	client *redis.Client
+}
+
+// NewRedisFanout relays broadcasts through client
+func NewRedisFanout(client *redis.Client) *RedisFanout {
+	return &RedisFanout{client: client}
+}
+
+// Publish sends a game's broadcast to every subscribed instance
+func (f *RedisFanout) Publish(ctx context.Context, gameID string, data []byte) error {
+	return f.client.Publish(ctx, fanoutPrefix+gameID, data).Err()
+}
+
+// Subscribe passes deliver every game's broadcasts until ctx is done
+func (f *RedisFanout) Subscribe(ctx context.Context, deliver func(gameID string, data []byte)) error {
+	subscription := f.client.PSubscribe(ctx, fanoutPrefix+"*")
+	defer subscription.Close()
+
+	// Wait for the subscription, so broadcasts published once Subscribe
+	// has been called for a while are not missed
+	if _, err := subscription.Receive(ctx); err != nil {
+		return err
+	}
+
+	messages := subscription.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case message, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			deliver(strings.TrimPrefix(message.Channel, fanoutPrefix), []byte(message.Payload))
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Memory is a Store for a single instance. Expired keys are dropped when
+// they are next read or written, and swept once the store has grown.
+type Memory struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	now     func() time.Time
+}
+
+// memoryEntry is a stored value and when it expires
+type memoryEntry struct {
+	value   string
+	expires time.Time
+}
+
+// sweepAt is how many keys Memory holds before a write sweeps out the
+// expired ones
+const sweepAt = 10000
+
+// NewMemory creates an empty in-memory store
+func NewMemory() *Memory {
+	return &Memory{
+		entries: make(map[string]memoryEntry),
+		now:     time.Now,
+	}
+}
+
+// Get returns the value of key, or ErrMiss
+func (m *Memory) Get(ctx context.Context, key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.lookup(key)
+	if !ok {
+		return "", ErrMiss
+	}
+	return entry.value, nil
+}
+
+// Set stores value under key for ttl
+func (m *Memory) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.store(key, value, ttl)
+	return nil
+}
+
+// SetNX stores value under key for ttl unless the key is already set
+func (m *Memory) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.lookup(key); ok {
+		return false, nil
+	}
+	m.store(key, value, ttl)
+	return true, nil
+}
+
+// Delete removes key
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.entries, key)
+	return nil
+}
+
+// Incr adds one to the counter under key, keeping the expiry it was
+// created with
+func (m *Memory) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.lookup(key)
+	if !ok {
+		m.store(key, "1", ttl)
+		return 1, nil
+	}
+	count, err := strconv.ParseInt(entry.value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	count++
+	entry.value = strconv.FormatInt(count, 10)
+	m.entries[key] = entry
+	return count, nil
+}
+
+// Ping always succeeds
+func (m *Memory) Ping(ctx context.Context) error {
+	return nil
+}
+
+// lookup returns an unexpired entry, dropping it if it has expired; m.mu
+// must be held
+func (m *Memory) lookup(key string) (memoryEntry, bool) {
+	entry, ok := m.entries[key]
+	if !ok {
+		return memoryEntry{}, false
+	}
+	if !m.now().Before(entry.expires) {
+		delete(m.entries, key)
+		return memoryEntry{}, false
+	}
+	return entry, true
+}
+
+// store writes an entry; m.mu must be held
+func (m *Memory) store(key, value string, ttl time.Duration) {
+	now := m.now()
+	if len(m.entries) >= sweepAt {
+		for name, entry := range m.entries {
+			if !now.Before(entry.expires) {
+				delete(m.entries, name)
+			}
+		}
+	}
+	m.entries[key] = memoryEntry{value: value, expires: now.Add(ttl)}
+}
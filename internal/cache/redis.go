@@ -0,0 +1,214 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis is a Store shared by every instance connected to the same Redis
+type Redis struct {
+	client *redis.Client
+}
+
+// NewRedis creates a store on client
+func NewRedis(client *redis.Client) *Redis {
+	return &Redis{client: client}
+}
+
+// Get returns the value of key, or ErrMiss
+func (r *Redis) Get(ctx context.Context, key string) (string, error) {
+	value, err := r.client.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrMiss
+	}
+	return value, err
+}
+
+// Set stores value under key for ttl
+func (r *Redis) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	return r.client.Set(ctx, key, value, ttl).Err()
+}
+
+// SetNX stores value under key for ttl unless the key is already set
+func (r *Redis) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	return r.client.SetNX(ctx, key, value, ttl).Result()
+}
+
+// Delete removes key
+func (r *Redis) Delete(ctx context.Context, key string) error {
+	return r.client.Del(ctx, key).Err()
+}
+
+// Incr adds one to the counter under key. The expiry is set in the same
+// round trip as the first increment, so a counter never outlives its ttl.
+func (r *Redis) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	var incr *redis.IntCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, key)
+		pipe.ExpireNX(ctx, key, ttl)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+// Ping checks Redis answers
+func (r *Redis) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
+// RedisPresence is a Presence shared by every instance. Each connection
+// is a key, user:{id}:conn:{instance}, holding the game it follows and
+// expiring unless refreshed, so the connections of an instance that died
+// lapse on their own. A set of the instances each user has connected to,
+// and of the connections following each game, lets them be found without
+// scanning; members whose connection has lapsed or moved to another game
+// are pruned as they are read.
+type RedisPresence struct {
+	client   *redis.Client
+	instance string
+}
+
+// NewRedisPresence tracks the connections of the named instance on client
+func NewRedisPresence(client *redis.Client, instance string) *RedisPresence {
+	return &RedisPresence{client: client, instance: instance}
+}
+
+// connKey holds the game a user's connection to an instance follows
+func connKey(userID, instance string) string {
+	return "user:" + userID + ":conn:" + instance
+}
+
+// userInstancesKey is the set of instances a user has connected to
+func userInstancesKey(userID string) string {
+	return "user:" + userID + ":instances"
+}
+
+// gameUsersKey is the set of connections following a game, each a user ID
+// and instance separated by gameMemberSep
+func gameUsersKey(gameID string) string {
+	return "game:" + gameID + ":users"
+}
+
+// gameMemberSep separates the user ID and instance of a gameUsersKey
+// member; neither contains it
+const gameMemberSep = "|"
+
+// Join records the user as connected to this instance, following gameID
+// unless it is empty, for ttl
+func (p *RedisPresence) Join(ctx context.Context, userID, gameID string, ttl time.Duration) error {
+	_, err := p.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, connKey(userID, p.instance), gameID, ttl)
+		instances := userInstancesKey(userID)
+		pipe.SAdd(ctx, instances, p.instance)
+		pipe.Expire(ctx, instances, ttl)
+		if gameID != "" {
+			users := gameUsersKey(gameID)
+			pipe.SAdd(ctx, users, userID+gameMemberSep+p.instance)
+			pipe.Expire(ctx, users, ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+// Leave records the user's connection to this instance as closed
+func (p *RedisPresence) Leave(ctx context.Context, userID, gameID string) error {
+	_, err := p.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, connKey(userID, p.instance))
+		pipe.SRem(ctx, userInstancesKey(userID), p.instance)
+		if gameID != "" {
+			pipe.SRem(ctx, gameUsersKey(gameID), userID+gameMemberSep+p.instance)
+		}
+		return nil
+	})
+	return err
+}
+
+// IsOnline reports whether the user is connected to any instance
+func (p *RedisPresence) IsOnline(ctx context.Context, userID string) (bool, error) {
+	key := userInstancesKey(userID)
+	instances, err := p.client.SMembers(ctx, key).Result()
+	if err != nil || len(instances) == 0 {
+		return false, err
+	}
+
+	exists := make([]*redis.IntCmd, len(instances))
+	_, err = p.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, instance := range instances {
+			exists[i] = pipe.Exists(ctx, connKey(userID, instance))
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	online := false
+	var lapsed []interface{}
+	for i, instance := range instances {
+		if exists[i].Val() > 0 {
+			online = true
+		} else {
+			lapsed = append(lapsed, instance)
+		}
+	}
+	p.prune(ctx, key, lapsed)
+	return online, nil
+}
+
+// GameUsers returns the users following a game on any instance, each once
+func (p *RedisPresence) GameUsers(ctx context.Context, gameID string) ([]string, error) {
+	key := gameUsersKey(gameID)
+	members, err := p.client.SMembers(ctx, key).Result()
+	if err != nil || len(members) == 0 {
+		return nil, err
+	}
+
+	games := make([]*redis.StringCmd, len(members))
+	// A lapsed connection fails with redis.Nil, so each is checked below
+	_, _ = p.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, member := range members {
+			userID, instance, _ := strings.Cut(member, gameMemberSep)
+			games[i] = pipe.Get(ctx, connKey(userID, instance))
+		}
+		return nil
+	})
+
+	var users []string
+	var lapsed []interface{}
+	seen := make(map[string]bool)
+	for i, member := range members {
+		game, err := games[i].Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return nil, err
+		}
+		if game != gameID {
+			lapsed = append(lapsed, member)
+			continue
+		}
+		userID, _, _ := strings.Cut(member, gameMemberSep)
+		if !seen[userID] {
+			seen[userID] = true
+			users = append(users, userID)
+		}
+	}
+	sort.Strings(users)
+	p.prune(ctx, key, lapsed)
+	return users, nil
+}
+
+// prune removes lapsed members from a set. It is only tidying, since
+// lapsed members are skipped whenever they are read, so it may fail.
+func (p *RedisPresence) prune(ctx context.Context, key string, lapsed []interface{}) {
+	if len(lapsed) > 0 {
+		p.client.SRem(ctx, key, lapsed...)
+	}
+}
@@ -3,212 +3,789 @@ package config
 import (
 	"context"
 	"fmt"
+	"net/netip"
 	"os"
-	"strconv"
+	"regexp"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/primoPoker/server/internal/flags"
 	"github.com/primoPoker/server/internal/gcp"
 )
 
-// Config holds all configuration for the application
+// DefaultJWTSecret is the placeholder secret used when JWT_SECRET is unset.
+// It is public, so production refuses to start with it.
+const DefaultJWTSecret = "your-super-secret-jwt-key-change-this-in-production"
+
+// Config holds all configuration for the application. Each value is taken
+// from its environment variable, then the config file named by CONFIG_FILE,
+// then its default. Settings may be reloaded while the server runs; the
+// rest is read once at startup.
 type Config struct {
-	Port         string
-	LogLevel     string
-	JWTSecret    string
-	DatabaseURL  string
-	RedisURL     string
-	Environment  string
-	ProjectID    string
-	Server       ServerConfig
-	Database     DatabaseConfig
-	Game         GameConfig
-	Security     SecurityConfig
-	GCP          GCPConfig
+	Port        string `yaml:"port" env:"PORT"`
+	JWTSecret   string `yaml:"jwt_secret" env:"JWT_SECRET"`
+	DatabaseURL string `yaml:"database_url" env:"DATABASE_URL"`
+	// RedisURL is the Redis shared by every instance; unset, each keeps
+	// its caches, rate limits and presence in memory
+	RedisURL    string          `yaml:"redis_url" env:"REDIS_URL"`
+	Environment string          `yaml:"environment" env:"ENVIRONMENT"`
+	ProjectID   string          `yaml:"project_id" env:"GOOGLE_CLOUD_PROJECT"`
+	Server      ServerConfig    `yaml:"server"`
+	Database    DatabaseConfig  `yaml:"database"`
+	Security    SecurityConfig  `yaml:"security"`
+	Metrics     MetricsConfig   `yaml:"metrics"`
+	Retention   RetentionConfig `yaml:"retention"`
+	Tables      TablesConfig    `yaml:"tables"`
+	Tracing     TracingConfig   `yaml:"tracing"`
+	OAuth       OAuthConfig     `yaml:"oauth"`
+	GCP         GCPConfig       `yaml:"gcp"`
+	Settings    Settings        `yaml:",inline"`
+
+	// RedisPassword authenticates to Redis, over any password in RedisURL
+	RedisPassword string `yaml:"redis_password" env:"REDIS_PASSWORD"`
+
+	// BroadcastFanout relays game broadcasts between instances through
+	// "redis" or "pubsub"; unset, Redis is used when it is configured
+	BroadcastFanout string `yaml:"broadcast_fanout" env:"BROADCAST_FANOUT"`
+
+	// Secrets is the Secret Manager cache the JWT secret and passwords are
+	// read from in production, nil elsewhere; run it to keep them fresh
+	Secrets *gcp.Secrets `yaml:"-"`
+
+	// TableTemplates are the standard tables the lobby always has one of
+	// open with a free seat; they are only read from the config file
+	TableTemplates []TableTemplate `yaml:"table_templates"`
+
+	// fileErr is why the config file could not be loaded, for Validate
+	fileErr error
+}
+
+// Settings holds the configuration that can change without a restart;
+// see LiveSettings
+type Settings struct {
+	LogLevel           string          `yaml:"log_level" env:"LOG_LEVEL"`
+	RateLimitPerMinute int             `yaml:"rate_limit_per_minute" env:"RATE_LIMIT_PER_MINUTE"`
+	RateLimitBurst     int             `yaml:"rate_limit_burst" env:"RATE_LIMIT_BURST"`
+	AllowedOrigins     []string        `yaml:"allowed_origins" env:"ALLOWED_ORIGINS"`
+	CORSMaxAge         time.Duration   `yaml:"cors_max_age" env:"CORS_MAX_AGE"`
+	Game               GameConfig      `yaml:"game"`
+	WebSocket          WebSocketConfig `yaml:"websocket"`
+	// FeatureFlags sets feature flags as name=on, name=off or name=25%;
+	// admins can override them while the server runs
+	FeatureFlags []string `yaml:"feature_flags" env:"FEATURE_FLAGS"`
+}
+
+// WebSocketConfig holds the timings of WebSocket connections, which
+// connections opened after a reload pick up
+type WebSocketConfig struct {
+	// WriteWait is how long a message may take to write to a client
+	WriteWait time.Duration `yaml:"write_wait" env:"WS_WRITE_WAIT"`
+	// PongWait is how long a client may go without answering a ping
+	PongWait time.Duration `yaml:"pong_wait" env:"WS_PONG_WAIT"`
+	// PingPeriod is how often clients are pinged; it must be under PongWait
+	PingPeriod time.Duration `yaml:"ping_period" env:"WS_PING_PERIOD"`
+}
+
+// OAuthConfig holds the OAuth clients used for third-party sign-in; a
+// provider without a client ID is disabled
+type OAuthConfig struct {
+	GoogleClientID     string `yaml:"google_client_id" env:"GOOGLE_OAUTH_CLIENT_ID"`
+	GoogleClientSecret string `yaml:"google_client_secret" env:"GOOGLE_OAUTH_CLIENT_SECRET"`
+	GoogleRedirectURL  string `yaml:"google_redirect_url" env:"GOOGLE_OAUTH_REDIRECT_URL"`
 }
 
 // GCPConfig holds Google Cloud Platform specific configuration
 type GCPConfig struct {
-	ProjectID          string
-	Region             string
-	PubSubTopic        string
-	SecretManagerPath  string
-	CloudSQLInstance   string
-	MemorystoreRedis   string
+	ProjectID         string `yaml:"project_id" env:"GOOGLE_CLOUD_PROJECT"`
+	Region            string `yaml:"region" env:"GOOGLE_CLOUD_REGION"`
+	PubSubTopic       string `yaml:"pubsub_topic" env:"PUBSUB_TOPIC"`
+	SecretManagerPath string `yaml:"secret_manager_path" env:"SECRET_MANAGER_PATH"`
+	CloudSQLInstance  string `yaml:"cloud_sql_instance" env:"CLOUD_SQL_INSTANCE"`
+	MemorystoreRedis  string `yaml:"memorystore_redis" env:"MEMORYSTORE_REDIS"`
+
+	// SecretRefreshInterval is how often secrets are fetched again, so a
+	// rotated secret comes into use without a restart
+	SecretRefreshInterval time.Duration `yaml:"secret_refresh_interval" env:"SECRET_REFRESH_INTERVAL"`
+
+	// BroadcastTopic carries game broadcasts between instances when they
+	// are relayed through Pub/Sub
+	BroadcastTopic string `yaml:"broadcast_topic" env:"PUBSUB_BROADCAST_TOPIC"`
+
+	// TasksQueue is the Cloud Tasks queue, in Region, that delayed game
+	// operations are scheduled on; without one they run from timers in
+	// process and do not survive a restart. Cloud Tasks delivers them to
+	// the server at TasksURL, signed with TasksSigningKey.
+	TasksQueue      string `yaml:"tasks_queue" env:"CLOUD_TASKS_QUEUE"`
+	TasksURL        string `yaml:"tasks_url" env:"CLOUD_TASKS_URL"`
+	TasksSigningKey string `yaml:"tasks_signing_key" env:"CLOUD_TASKS_SIGNING_KEY"`
+
+	// Cloud Storage buckets, each optional. A hand history export larger
+	// than ExportInlineLimit bytes is written to ExportBucket and fetched
+	// from a URL signed for SignedURLTTL; archived hands are written to
+	// ArchiveBucket; avatars are uploaded to AvatarBucket, which must be
+	// publicly readable.
+	ExportBucket      string        `yaml:"export_bucket" env:"GCS_EXPORT_BUCKET"`
+	ArchiveBucket     string        `yaml:"archive_bucket" env:"GCS_ARCHIVE_BUCKET"`
+	AvatarBucket      string        `yaml:"avatar_bucket" env:"GCS_AVATAR_BUCKET"`
+	ExportInlineLimit int           `yaml:"export_inline_limit" env:"GCS_EXPORT_INLINE_LIMIT"`
+	SignedURLTTL      time.Duration `yaml:"signed_url_ttl" env:"GCS_SIGNED_URL_TTL"`
 }
 
 // DatabaseConfig holds database-related configuration
 type DatabaseConfig struct {
-	Host          string
-	Port          int
-	User          string
-	Password      string
-	DBName        string
-	SSLMode       string
-	TimeZone      string
-	SocketPath    string // For Cloud SQL Unix sockets
-	InstanceName  string // Cloud SQL instance name
+	Host         string `yaml:"host" env:"DB_HOST"`
+	Port         int    `yaml:"port" env:"DB_PORT"`
+	User         string `yaml:"user" env:"DB_USER"`
+	Password     string `yaml:"password" env:"DB_PASSWORD"`
+	DBName       string `yaml:"name" env:"DB_NAME"`
+	SSLMode      string `yaml:"sslmode" env:"DB_SSLMODE"`
+	TimeZone     string `yaml:"timezone" env:"DB_TIMEZONE"`
+	SocketPath   string `yaml:"socket_path" env:"DB_SOCKET_PATH"`       // For Cloud SQL Unix sockets
+	InstanceName string `yaml:"instance_name" env:"CLOUD_SQL_INSTANCE"` // Cloud SQL instance name
+	// AutoMigrate builds the schema straight from the models instead of
+	// applying migrations; for development only
+	AutoMigrate bool `yaml:"auto_migrate" env:"DB_AUTO_MIGRATE"`
+	// Connection pool; Cloud SQL caps connections per instance, so the
+	// pools of every server replica together must stay under its limit
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME"`
+	// LogLevel is how much GORM logs: silent, error, warn or info. At warn,
+	// only failed statements and those slower than SlowQueryThreshold are
+	// logged; at info, every statement is.
+	LogLevel           string        `yaml:"log_level" env:"DB_LOG_LEVEL"`
+	SlowQueryThreshold time.Duration `yaml:"slow_query_threshold" env:"DB_SLOW_QUERY_THRESHOLD"`
+	// ConnectTimeout is how long startup keeps retrying to connect and
+	// migrate while the database, or the Cloud SQL proxy in front of it,
+	// is not ready yet
+	ConnectTimeout time.Duration `yaml:"connect_timeout" env:"DB_CONNECT_TIMEOUT"`
+	// HealthLatencyWarning is how slow a readiness ping may be before the
+	// database is reported degraded
+	HealthLatencyWarning time.Duration `yaml:"health_latency_warning" env:"DB_HEALTH_LATENCY_WARNING"`
+	// ReplicaURL is a read replica's DSN. Leaderboards, stats and exports
+	// read from it when set, and from the primary otherwise.
+	ReplicaURL          string `yaml:"replica_url" env:"DB_REPLICA_URL"`
+	ReplicaMaxOpenConns int    `yaml:"replica_max_open_conns" env:"DB_REPLICA_MAX_OPEN_CONNS"`
+	ReplicaMaxIdleConns int    `yaml:"replica_max_idle_conns" env:"DB_REPLICA_MAX_IDLE_CONNS"`
 }
 
 // ServerConfig holds server-specific configuration
 type ServerConfig struct {
-	ReadTimeout  time.Duration
-	WriteTimeout time.Duration
-	IdleTimeout  time.Duration
+	ReadTimeout  time.Duration `yaml:"read_timeout" env:"SERVER_READ_TIMEOUT"`
+	WriteTimeout time.Duration `yaml:"write_timeout" env:"SERVER_WRITE_TIMEOUT"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout" env:"SERVER_IDLE_TIMEOUT"`
+	// ShutdownTimeout is how long the server has to stop once signalled,
+	// which should be within the platform's grace period
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SERVER_SHUTDOWN_TIMEOUT"`
+	// MonitoringAddr is where Prometheus metrics are served, apart from the
+	// public API; empty turns them off
+	MonitoringAddr string `yaml:"monitoring_addr" env:"MONITORING_ADDR"`
+	// Compression gzips responses of at least CompressionMinSize bytes for
+	// clients that accept it
+	Compression        bool `yaml:"compression" env:"COMPRESSION_ENABLED"`
+	CompressionMinSize int  `yaml:"compression_min_size" env:"COMPRESSION_MIN_SIZE"`
+	// The largest request bodies, in bytes, accepted by the sign-in and
+	// account routes, by the game routes, by avatar uploads, and by the rest
+	BodyLimitAuth    int64 `yaml:"body_limit_auth" env:"BODY_LIMIT_AUTH"`
+	BodyLimitGame    int64 `yaml:"body_limit_game" env:"BODY_LIMIT_GAME"`
+	BodyLimitAvatar  int64 `yaml:"body_limit_avatar" env:"BODY_LIMIT_AVATAR"`
+	BodyLimitDefault int64 `yaml:"body_limit_default" env:"BODY_LIMIT_DEFAULT"`
 }
 
 // GameConfig holds game-specific configuration
 type GameConfig struct {
-	MaxTablesPerUser int
-	MaxPlayersPerTable int
-	MinPlayersPerTable int
-	DefaultBuyIn       int64
-	MaxBuyIn          int64
-	MinBuyIn          int64
-	SmallBlind        int64
-	BigBlind          int64
-	TurnTimeout       time.Duration
-	DecisionTimeout   time.Duration
+	MaxTablesPerUser   int           `yaml:"max_tables_per_user" env:"MAX_TABLES_PER_USER"`
+	MaxPlayersPerTable int           `yaml:"max_players_per_table" env:"MAX_PLAYERS_PER_TABLE"`
+	MinPlayersPerTable int           `yaml:"min_players_per_table" env:"MIN_PLAYERS_PER_TABLE"`
+	DefaultBuyIn       int64         `yaml:"default_buy_in" env:"DEFAULT_BUY_IN"`
+	MaxBuyIn           int64         `yaml:"max_buy_in" env:"MAX_BUY_IN"`
+	MinBuyIn           int64         `yaml:"min_buy_in" env:"MIN_BUY_IN"`
+	SmallBlind         int64         `yaml:"small_blind" env:"SMALL_BLIND"`
+	BigBlind           int64         `yaml:"big_blind" env:"BIG_BLIND"`
+	TurnTimeout        time.Duration `yaml:"turn_timeout" env:"TURN_TIMEOUT"`
+	DecisionTimeout    time.Duration `yaml:"decision_timeout" env:"DECISION_TIMEOUT"`
+	// LegacyCardJSON keeps sending cards to clients as rank and suit
+	// numbers instead of compact notation such as "As"
+	LegacyCardJSON bool `yaml:"legacy_card_json" env:"LEGACY_CARD_JSON"`
+}
+
+// TableTemplate describes a standard table, such as "Micro 25/50". Fields
+// left out take the game defaults.
+type TableTemplate struct {
+	// ID identifies the template's tables; it defaults to the name in
+	// lower case with runs of other characters replaced by a dash
+	ID         string `yaml:"id"`
+	Name       string `yaml:"name"`
+	GameType   string `yaml:"game_type"`
+	Structure  string `yaml:"structure"`
+	SmallBlind int64  `yaml:"small_blind"`
+	BigBlind   int64  `yaml:"big_blind"`
+	Ante       int64  `yaml:"ante"`
+	MinBuyIn   int64  `yaml:"min_buy_in"`
+	MaxBuyIn   int64  `yaml:"max_buy_in"`
+	MaxPlayers int    `yaml:"max_players"`
+	// Bots are seated at the template's tables while fewer players are,
+	// so the lobby never shows them empty
+	Bots int `yaml:"bots"`
+}
+
+// nonSlugChars are the runs of characters a template's default ID drops
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// TemplateID returns the template's ID, or the one made from its name
+func (t TableTemplate) TemplateID() string {
+	if t.ID != "" {
+		return t.ID
+	}
+	return strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(t.Name), "-"), "-")
+}
+
+// Validate reports a template no table could be opened from. Only no-limit
+// Texas Hold'em is dealt.
+func (t TableTemplate) Validate() error {
+	if t.Name == "" {
+		return fmt.Errorf("table template needs a name")
+	}
+	if t.TemplateID() == "" {
+		return fmt.Errorf("table template %q needs an id", t.Name)
+	}
+	switch t.GameType {
+	case "", "texas_holdem":
+	default:
+		return fmt.Errorf("table template %q: game type %q is not supported", t.Name, t.GameType)
+	}
+	switch t.Structure {
+	case "", "no_limit":
+	default:
+		return fmt.Errorf("table template %q: structure %q is not supported", t.Name, t.Structure)
+	}
+	if t.SmallBlind < 0 || t.BigBlind < 0 || t.Ante < 0 || t.MinBuyIn < 0 || t.MaxBuyIn < 0 || t.MaxPlayers < 0 || t.Bots < 0 {
+		return fmt.Errorf("table template %q cannot have negative amounts", t.Name)
+	}
+	if (t.SmallBlind > 0) != (t.BigBlind > 0) {
+		return fmt.Errorf("table template %q needs both blinds or neither", t.Name)
+	}
+	if t.SmallBlind > t.BigBlind {
+		return fmt.Errorf("table template %q: small blind cannot be more than the big blind", t.Name)
+	}
+	if t.MinBuyIn > t.MaxBuyIn {
+		return fmt.Errorf("table template %q: minimum buy-in cannot be more than the maximum", t.Name)
+	}
+	if t.MaxPlayers == 1 {
+		return fmt.Errorf("table template %q needs at least 2 seats", t.Name)
+	}
+	if t.MaxPlayers > 0 && t.Bots >= t.MaxPlayers {
+		return fmt.Errorf("table template %q needs a seat bots leave free", t.Name)
+	}
+	return nil
 }
 
 // SecurityConfig holds security-specific configuration
 type SecurityConfig struct {
-	PasswordMinLength int
-	JWTExpirationHours int
-	RefreshTokenDays   int
-	MaxLoginAttempts   int
-	LoginAttemptsWindow time.Duration
-	RateLimitPerMinute int
+	PasswordMinLength     int           `yaml:"password_min_length" env:"PASSWORD_MIN_LENGTH"`
+	PasswordRequireUpper  bool          `yaml:"password_require_upper" env:"PASSWORD_REQUIRE_UPPER"`
+	PasswordRequireLower  bool          `yaml:"password_require_lower" env:"PASSWORD_REQUIRE_LOWER"`
+	PasswordRequireDigit  bool          `yaml:"password_require_digit" env:"PASSWORD_REQUIRE_DIGIT"`
+	PasswordRequireSymbol bool          `yaml:"password_require_symbol" env:"PASSWORD_REQUIRE_SYMBOL"`
+	Argon2MemoryKiB       int           `yaml:"argon2_memory_kib" env:"ARGON2_MEMORY_KIB"`
+	Argon2Iterations      int           `yaml:"argon2_iterations" env:"ARGON2_ITERATIONS"`
+	Argon2Threads         int           `yaml:"argon2_threads" env:"ARGON2_THREADS"`
+	JWTExpirationHours    int           `yaml:"jwt_expiration_hours" env:"JWT_EXPIRATION_HOURS"`
+	RefreshTokenDays      int           `yaml:"refresh_token_days" env:"REFRESH_TOKEN_DAYS"`
+	MaxLoginAttempts      int           `yaml:"max_login_attempts" env:"MAX_LOGIN_ATTEMPTS"`
+	LoginAttemptsWindow   time.Duration `yaml:"login_attempts_window" env:"LOGIN_ATTEMPTS_WINDOW"`
+	LoginHistoryRetention time.Duration `yaml:"login_history_retention" env:"LOGIN_HISTORY_RETENTION"`
+	AdminUsers            []string      `yaml:"admin_users" env:"ADMIN_USERS"`
+	// TrustedProxies are the addresses or CIDR ranges of the proxies in
+	// front of the server, whose X-Forwarded-For headers are believed
+	TrustedProxies []string `yaml:"trusted_proxies" env:"TRUSTED_PROXIES"`
+
+	// Requests a minute each signed-in user may make to the routes
+	// managing passwords, sessions and API keys, to tables, to exports,
+	// and to everything else. Zero takes the default budget for a class,
+	// and a zero default turns per-user limits off.
+	UserRateLimitAuth    int `yaml:"user_rate_limit_auth" env:"USER_RATE_LIMIT_AUTH"`
+	UserRateLimitGame    int `yaml:"user_rate_limit_game" env:"USER_RATE_LIMIT_GAME"`
+	UserRateLimitExport  int `yaml:"user_rate_limit_export" env:"USER_RATE_LIMIT_EXPORT"`
+	UserRateLimitDefault int `yaml:"user_rate_limit_default" env:"USER_RATE_LIMIT_DEFAULT"`
+
+	// How long a client's rate limit state is kept after its last request,
+	// and how many clients' are kept per IPs, API keys and users before the
+	// least recently seen are dropped
+	RateLimitIdleTTL    time.Duration `yaml:"rate_limit_idle_ttl" env:"RATE_LIMIT_IDLE_TTL"`
+	RateLimitMaxEntries int           `yaml:"rate_limit_max_entries" env:"RATE_LIMIT_MAX_ENTRIES"`
 }
 
-// Load returns a new Config instance with values from environment variables
+// TracingConfig holds the configuration of request tracing
+type TracingConfig struct {
+	// Exporter is where traces are sent: "cloudtrace" for Cloud Trace,
+	// "otlp" for an OpenTelemetry collector at OTEL_EXPORTER_OTLP_ENDPOINT,
+	// or "stdout" to print them; empty turns tracing off
+	Exporter string `yaml:"exporter" env:"TRACING_EXPORTER"`
+	// SampleRate is the fraction of requests traced, unless the caller
+	// already decided whether its trace is sampled
+	SampleRate float64 `yaml:"sample_rate" env:"TRACING_SAMPLE_RATE"`
+}
+
+// MetricsConfig holds player statistics configuration
+type MetricsConfig struct {
+	// SessionGap is the longest break between hands that still counts as
+	// one playing session
+	SessionGap time.Duration `yaml:"session_gap" env:"METRICS_SESSION_GAP"`
+	// CacheTTL is how long calculated metrics are served before being
+	// recalculated; zero turns the cache off
+	CacheTTL time.Duration `yaml:"cache_ttl" env:"METRICS_CACHE_TTL"`
+	// CacheSize is how many sets of metrics the cache holds
+	CacheSize int `yaml:"cache_size" env:"METRICS_CACHE_SIZE"`
+	// HUDEnabled shows players their opponents' stats at the table; turn it
+	// off for anonymous tables
+	HUDEnabled bool `yaml:"hud_enabled" env:"METRICS_HUD_ENABLED"`
+	// HUDMinHands is how many hands a player needs before their HUD stats
+	// are shown
+	HUDMinHands int `yaml:"hud_min_hands" env:"METRICS_HUD_MIN_HANDS"`
+	// HeadToHeadMinHands is how many hands two players must have been dealt
+	// into together before their head-to-head record is shown
+	HeadToHeadMinHands int `yaml:"head_to_head_min_hands" env:"METRICS_HEAD_TO_HEAD_MIN_HANDS"`
+	// LeaderboardMinHands is how many hands a player needs to be ranked on
+	// the stat leaderboards
+	LeaderboardMinHands int `yaml:"leaderboard_min_hands" env:"METRICS_LEADERBOARD_MIN_HANDS"`
+	// LeaderboardInterval is how often the leaderboards are rebuilt
+	LeaderboardInterval time.Duration `yaml:"leaderboard_interval" env:"METRICS_LEADERBOARD_INTERVAL"`
+	// SummaryInterval is how often the latest finished day and week are
+	// rolled up into hand summaries
+	SummaryInterval time.Duration `yaml:"summary_interval" env:"METRICS_SUMMARY_INTERVAL"`
+}
+
+// RetentionConfig holds how long deleted and old records are kept
+type RetentionConfig struct {
+	// PurgeDeletedAfter is how long soft-deleted users and games are kept
+	// before being removed for good; zero keeps them forever
+	PurgeDeletedAfter time.Duration `yaml:"purge_deleted_after" env:"RETENTION_PURGE_DELETED_AFTER"`
+	// ArchiveHandsAfterMonths is the age in months at which hand histories
+	// move to the archive table; zero keeps every hand live
+	ArchiveHandsAfterMonths int `yaml:"archive_hands_after_months" env:"RETENTION_ARCHIVE_HANDS_MONTHS"`
+	// BatchSize is how many hands are archived in each transaction
+	BatchSize int `yaml:"batch_size" env:"RETENTION_BATCH_SIZE"`
+	// Interval is how often the retention policy is applied
+	Interval time.Duration `yaml:"interval" env:"RETENTION_INTERVAL"`
+}
+
+// TablesConfig holds when tables nobody is playing at are closed, and how
+// long their hands may run on as the server stops
+type TablesConfig struct {
+	// IdleAfter is how long a table nobody is seated at stays open; zero
+	// keeps them open
+	IdleAfter time.Duration `yaml:"idle_after" env:"TABLE_IDLE_AFTER"`
+	// CleanupInterval is how often idle tables are looked for
+	CleanupInterval time.Duration `yaml:"cleanup_interval" env:"TABLE_CLEANUP_INTERVAL"`
+	// ShutdownHandWait is how long hands being played are left to finish
+	// as the server stops, before they are voided
+	ShutdownHandWait time.Duration `yaml:"shutdown_hand_wait" env:"TABLE_SHUTDOWN_HAND_WAIT"`
+}
+
+// Load returns a new Config instance with values from environment
+// variables, the config file named by CONFIG_FILE if there is one, and the
+// defaults, in that order. A config file that cannot be read is reported by
+// Validate.
 func Load() *Config {
-	cfg := &Config{
-		Port:        getEnv("PORT", "8080"),
-		LogLevel:    getEnv("LOG_LEVEL", "info"),
-		JWTSecret:   getEnv("JWT_SECRET", "your-super-secret-jwt-key-change-this-in-production"),
-		DatabaseURL: getEnv("DATABASE_URL", "postgres://localhost/primopoker?sslmode=disable"),
-		RedisURL:    getEnv("REDIS_URL", "redis://localhost:6379"),
-		Environment: getEnv("ENVIRONMENT", "development"),
-		ProjectID:   getEnv("GOOGLE_CLOUD_PROJECT", ""),
-		
+	cfg := defaults()
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		cfg.fileErr = loadFile(cfg, path)
+	}
+	applyEnv(cfg)
+
+	// Load secrets from Secret Manager in production, with any overrides
+	// of the settings
+	if cfg.Environment == "production" && cfg.GCP.ProjectID != "" {
+		applySecrets(cfg)
+		applyEnv(&cfg.Settings)
+	}
+
+	// Override database connection for Cloud SQL
+	if cfg.GCP.CloudSQLInstance != "" {
+		setupCloudSQLConnection(cfg)
+	}
+
+	return cfg
+}
+
+// defaults returns the configuration used where neither the environment nor
+// the config file sets a value
+func defaults() *Config {
+	return &Config{
+		Port:        "8080",
+		JWTSecret:   DefaultJWTSecret,
+		DatabaseURL: "postgres://localhost/primopoker?sslmode=disable",
+		Environment: "development",
+
 		Server: ServerConfig{
-			ReadTimeout:  getDurationEnv("SERVER_READ_TIMEOUT", 15*time.Second),
-			WriteTimeout: getDurationEnv("SERVER_WRITE_TIMEOUT", 15*time.Second),
-			IdleTimeout:  getDurationEnv("SERVER_IDLE_TIMEOUT", 60*time.Second),
+			ReadTimeout:        15 * time.Second,
+			WriteTimeout:       15 * time.Second,
+			IdleTimeout:        60 * time.Second,
+			ShutdownTimeout:    9 * time.Second,
+			MonitoringAddr:     ":9090",
+			Compression:        true,
+			CompressionMinSize: 1024,
+			BodyLimitAuth:      4 << 10,
+			BodyLimitGame:      8 << 10,
+			BodyLimitAvatar:    1 << 20,
+			BodyLimitDefault:   64 << 10,
 		},
-		
+
 		Database: DatabaseConfig{
-			Host:         getEnv("DB_HOST", "localhost"),
-			Port:         getIntEnv("DB_PORT", 5432),
-			User:         getEnv("DB_USER", "postgres"),
-			Password:     getEnv("DB_PASSWORD", ""),
-			DBName:       getEnv("DB_NAME", "primopoker"),
-			SSLMode:      getEnv("DB_SSLMODE", "disable"),
-			TimeZone:     getEnv("DB_TIMEZONE", "UTC"),
-			SocketPath:   getEnv("DB_SOCKET_PATH", ""), // For Cloud SQL Unix sockets
-			InstanceName: getEnv("CLOUD_SQL_INSTANCE", ""),
+			Host:     "localhost",
+			Port:     5432,
+			User:     "postgres",
+			DBName:   "primopoker",
+			SSLMode:  "disable",
+			TimeZone: "UTC",
+
+			MaxOpenConns:         25,
+			MaxIdleConns:         5,
+			ConnMaxLifetime:      time.Hour,
+			ConnMaxIdleTime:      10 * time.Minute,
+			LogLevel:             "warn",
+			SlowQueryThreshold:   200 * time.Millisecond,
+			ConnectTimeout:       60 * time.Second,
+			HealthLatencyWarning: 100 * time.Millisecond,
+
+			ReplicaMaxOpenConns: 10,
+			ReplicaMaxIdleConns: 2,
 		},
-		
+
 		GCP: GCPConfig{
-			ProjectID:          getEnv("GOOGLE_CLOUD_PROJECT", ""),
-			Region:             getEnv("GOOGLE_CLOUD_REGION", "us-central1"),
-			PubSubTopic:        getEnv("PUBSUB_TOPIC", "poker-events"),
-			SecretManagerPath:  getEnv("SECRET_MANAGER_PATH", "projects/$PROJECT_ID/secrets"),
-			CloudSQLInstance:   getEnv("CLOUD_SQL_INSTANCE", ""),
-			MemorystoreRedis:   getEnv("MEMORYSTORE_REDIS", ""),
-		},
-		
-		Game: GameConfig{
-			MaxTablesPerUser:   getIntEnv("MAX_TABLES_PER_USER", 3),
-			MaxPlayersPerTable: getIntEnv("MAX_PLAYERS_PER_TABLE", 10),
-			MinPlayersPerTable: getIntEnv("MIN_PLAYERS_PER_TABLE", 2),
-			DefaultBuyIn:       getInt64Env("DEFAULT_BUY_IN", 10000), // 100 big blinds
-			MaxBuyIn:          getInt64Env("MAX_BUY_IN", 50000),     // 500 big blinds
-			MinBuyIn:          getInt64Env("MIN_BUY_IN", 2000),      // 20 big blinds
-			SmallBlind:        getInt64Env("SMALL_BLIND", 50),
-			BigBlind:          getInt64Env("BIG_BLIND", 100),
-			TurnTimeout:       getDurationEnv("TURN_TIMEOUT", 30*time.Second),
-			DecisionTimeout:   getDurationEnv("DECISION_TIMEOUT", 15*time.Second),
+			Region:                "us-central1",
+			PubSubTopic:           "poker-events",
+			BroadcastTopic:        "poker-broadcasts",
+			SecretManagerPath:     "projects/$PROJECT_ID/secrets",
+			SecretRefreshInterval: gcp.DefaultSecretRefreshInterval,
+			ExportInlineLimit:     8 << 20,
+			SignedURLTTL:          15 * time.Minute,
 		},
-		
+
 		Security: SecurityConfig{
-			PasswordMinLength:   getIntEnv("PASSWORD_MIN_LENGTH", 8),
-			JWTExpirationHours:  getIntEnv("JWT_EXPIRATION_HOURS", 24),
-			RefreshTokenDays:    getIntEnv("REFRESH_TOKEN_DAYS", 30),
-			MaxLoginAttempts:    getIntEnv("MAX_LOGIN_ATTEMPTS", 5),
-			LoginAttemptsWindow: getDurationEnv("LOGIN_ATTEMPTS_WINDOW", 15*time.Minute),
-			RateLimitPerMinute:  getIntEnv("RATE_LIMIT_PER_MINUTE", 100),
+			PasswordMinLength:     8,
+			Argon2MemoryKiB:       64 * 1024,
+			Argon2Iterations:      3,
+			Argon2Threads:         4,
+			JWTExpirationHours:    24,
+			RefreshTokenDays:      30,
+			MaxLoginAttempts:      5,
+			LoginAttemptsWindow:   15 * time.Minute,
+			LoginHistoryRetention: 90 * 24 * time.Hour,
+			UserRateLimitAuth:     20,
+			UserRateLimitGame:     600,
+			UserRateLimitExport:   5,
+			UserRateLimitDefault:  300,
+			RateLimitIdleTTL:      10 * time.Minute,
+			RateLimitMaxEntries:   100000,
+		},
+
+		Tracing: TracingConfig{
+			SampleRate: 0.1,
+		},
+
+		Metrics: MetricsConfig{
+			SessionGap:          45 * time.Minute,
+			CacheTTL:            30 * time.Second,
+			CacheSize:           10000,
+			HUDEnabled:          true,
+			HUDMinHands:         20,
+			HeadToHeadMinHands:  20,
+			LeaderboardMinHands: 500,
+			LeaderboardInterval: time.Hour,
+			SummaryInterval:     time.Hour,
+		},
+
+		Retention: RetentionConfig{
+			PurgeDeletedAfter: 30 * 24 * time.Hour,
+			BatchSize:         1000,
+			Interval:          24 * time.Hour,
+		},
+
+		Tables: TablesConfig{
+			IdleAfter:        time.Hour,
+			CleanupInterval:  5 * time.Minute,
+			ShutdownHandWait: 6 * time.Second,
+		},
+
+		OAuth: OAuthConfig{
+			GoogleRedirectURL: "http://localhost:8080/api/v1/auth/oauth/google/callback",
+		},
+
+		Settings: Settings{
+			LogLevel:           "info",
+			RateLimitPerMinute: 100,
+			CORSMaxAge:         10 * time.Minute,
+
+			Game: GameConfig{
+				MaxTablesPerUser:   3,
+				MaxPlayersPerTable: 10,
+				MinPlayersPerTable: 2,
+				DefaultBuyIn:       10000, // 100 big blinds
+				MaxBuyIn:           50000, // 500 big blinds
+				MinBuyIn:           2000,  // 20 big blinds
+				SmallBlind:         50,
+				BigBlind:           100,
+				TurnTimeout:        30 * time.Second,
+				DecisionTimeout:    15 * time.Second,
+			},
+
+			WebSocket: WebSocketConfig{
+				WriteWait:  10 * time.Second,
+				PongWait:   60 * time.Second,
+				PingPeriod: 54 * time.Second,
+			},
 		},
 	}
-	
-	// Load secrets from Secret Manager in production
-	if cfg.Environment == "production" && cfg.GCP.ProjectID != "" {
-		loadSecretsFromGCP(cfg)
+}
+
+// Validate reports configuration that is unsafe to run with
+func (c *Config) Validate() error {
+	if c.fileErr != nil {
+		return c.fileErr
 	}
-	
-	// Override database connection for Cloud SQL
-	if cfg.GCP.CloudSQLInstance != "" {
-		setupCloudSQLConnection(cfg)
+	if c.Environment == "production" && (c.JWTSecret == "" || c.JWTSecret == DefaultJWTSecret) {
+		return fmt.Errorf("JWT_SECRET must be set in production")
 	}
-	
-	return cfg
+	if c.Environment == "production" && c.Database.AutoMigrate {
+		return fmt.Errorf("DB_AUTO_MIGRATE is for development and cannot be used in production")
+	}
+	switch strings.ToLower(c.Database.LogLevel) {
+	case "", "silent", "error", "warn", "info":
+	default:
+		return fmt.Errorf("DB_LOG_LEVEL must be silent, error, warn or info, not %q", c.Database.LogLevel)
+	}
+	if c.Security.UserRateLimitAuth < 0 || c.Security.UserRateLimitGame < 0 ||
+		c.Security.UserRateLimitExport < 0 || c.Security.UserRateLimitDefault < 0 {
+		return fmt.Errorf("USER_RATE_LIMIT_* cannot be negative")
+	}
+	if c.Server.CompressionMinSize < 0 {
+		return fmt.Errorf("COMPRESSION_MIN_SIZE cannot be negative")
+	}
+	if c.Server.BodyLimitAuth < 0 || c.Server.BodyLimitGame < 0 || c.Server.BodyLimitDefault < 0 {
+		return fmt.Errorf("BODY_LIMIT_* cannot be negative")
+	}
+	for _, proxy := range c.Security.TrustedProxies {
+		if _, err := netip.ParsePrefix(proxy); err != nil {
+			if _, err := netip.ParseAddr(proxy); err != nil {
+				return fmt.Errorf("TRUSTED_PROXIES must be IP addresses or CIDR ranges, not %q", proxy)
+			}
+		}
+	}
+	if c.Security.RateLimitIdleTTL < 0 || c.Security.RateLimitMaxEntries < 0 {
+		return fmt.Errorf("RATE_LIMIT_IDLE_TTL and RATE_LIMIT_MAX_ENTRIES cannot be negative")
+	}
+	if c.Tables.IdleAfter < 0 {
+		return fmt.Errorf("TABLE_IDLE_AFTER cannot be negative")
+	}
+	if c.Tables.IdleAfter > 0 && c.Tables.CleanupInterval <= 0 {
+		return fmt.Errorf("TABLE_CLEANUP_INTERVAL must be positive when TABLE_IDLE_AFTER is set")
+	}
+	if c.Server.ShutdownTimeout < 0 || c.Tables.ShutdownHandWait < 0 {
+		return fmt.Errorf("SERVER_SHUTDOWN_TIMEOUT and TABLE_SHUTDOWN_HAND_WAIT cannot be negative")
+	}
+	if c.Tables.ShutdownHandWait > 0 && c.Tables.ShutdownHandWait >= c.Server.ShutdownTimeout {
+		return fmt.Errorf("TABLE_SHUTDOWN_HAND_WAIT must be less than SERVER_SHUTDOWN_TIMEOUT, leaving time to store tables and disconnect players")
+	}
+	switch c.Tracing.Exporter {
+	case "", "cloudtrace", "otlp", "stdout":
+	default:
+		return fmt.Errorf("TRACING_EXPORTER must be cloudtrace, otlp or stdout, not %q", c.Tracing.Exporter)
+	}
+	if c.Tracing.SampleRate < 0 || c.Tracing.SampleRate > 1 {
+		return fmt.Errorf("TRACING_SAMPLE_RATE must be between 0 and 1")
+	}
+	if c.GCP.TasksQueue != "" {
+		if c.GCP.ProjectID == "" || c.GCP.Region == "" || c.GCP.TasksURL == "" {
+			return fmt.Errorf("CLOUD_TASKS_QUEUE needs GOOGLE_CLOUD_PROJECT, GOOGLE_CLOUD_REGION and CLOUD_TASKS_URL")
+		}
+		if len(c.GCP.TasksSigningKey) < 32 {
+			return fmt.Errorf("CLOUD_TASKS_SIGNING_KEY must be at least 32 characters")
+		}
+	}
+	if c.GCP.UsesStorage() && c.GCP.ProjectID == "" {
+		return fmt.Errorf("GCS_EXPORT_BUCKET, GCS_ARCHIVE_BUCKET and GCS_AVATAR_BUCKET need GOOGLE_CLOUD_PROJECT")
+	}
+	if c.GCP.ExportBucket != "" {
+		if c.GCP.ExportInlineLimit < 0 {
+			return fmt.Errorf("GCS_EXPORT_INLINE_LIMIT must not be negative")
+		}
+		// Cloud Storage signs URLs for at most seven days
+		if c.GCP.SignedURLTTL < time.Minute || c.GCP.SignedURLTTL > 7*24*time.Hour {
+			return fmt.Errorf("GCS_SIGNED_URL_TTL must be between 1m and 168h")
+		}
+	}
+	switch c.BroadcastFanout {
+	case "":
+	case "redis":
+		if c.RedisURL == "" && c.GCP.MemorystoreRedis == "" {
+			return fmt.Errorf("BROADCAST_FANOUT=redis needs REDIS_URL or MEMORYSTORE_REDIS")
+		}
+	case "pubsub":
+		if c.GCP.ProjectID == "" || c.GCP.BroadcastTopic == "" {
+			return fmt.Errorf("BROADCAST_FANOUT=pubsub needs GOOGLE_CLOUD_PROJECT and PUBSUB_BROADCAST_TOPIC")
+		}
+	default:
+		return fmt.Errorf("BROADCAST_FANOUT must be redis or pubsub, not %q", c.BroadcastFanout)
+	}
+	templateIDs := make(map[string]bool, len(c.TableTemplates))
+	for _, template := range c.TableTemplates {
+		if err := template.Validate(); err != nil {
+			return err
+		}
+		if templateIDs[template.TemplateID()] {
+			return fmt.Errorf("table template id %q is used more than once", template.TemplateID())
+		}
+		templateIDs[template.TemplateID()] = true
+	}
+	return c.Settings.Validate()
 }
 
-// Helper functions to get environment variables with defaults
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// Validate reports settings that are unsafe to run with, so a reload
+// that would bring them in can be refused
+func (s Settings) Validate() error {
+	switch s.LogLevel {
+	case "", "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("LOG_LEVEL must be debug, info, warn or error, not %q", s.LogLevel)
+	}
+	if s.RateLimitPerMinute < 0 {
+		return fmt.Errorf("RATE_LIMIT_PER_MINUTE cannot be negative")
+	}
+	if s.RateLimitBurst < 0 {
+		return fmt.Errorf("RATE_LIMIT_BURST cannot be negative")
+	}
+	if s.Game.SmallBlind > s.Game.BigBlind {
+		return fmt.Errorf("SMALL_BLIND cannot be more than BIG_BLIND")
+	}
+	if s.Game.MinBuyIn > s.Game.MaxBuyIn {
+		return fmt.Errorf("MIN_BUY_IN cannot be more than MAX_BUY_IN")
 	}
-	return defaultValue
+	if s.WebSocket.PingPeriod >= s.WebSocket.PongWait && s.WebSocket.PongWait > 0 {
+		return fmt.Errorf("WS_PING_PERIOD must be shorter than WS_PONG_WAIT")
+	}
+	if err := flags.Validate(s.FeatureFlags); err != nil {
+		return fmt.Errorf("FEATURE_FLAGS: %w", err)
+	}
+	return nil
 }
 
-func getIntEnv(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if intValue, err := strconv.Atoi(value); err == nil {
-			return intValue
-		}
+// Secrets read from Secret Manager in production, over their environment
+// variables
+const (
+	SecretJWT           = "primopoker-jwt-secret"
+	SecretDBPassword    = "primopoker-db-password"
+	SecretRedisPassword = "primopoker-redis-password"
+	SecretOAuthGoogle   = "primopoker-google-oauth-client-secret"
+	// SecretSettings holds overrides of the settings as KEY=value lines, so
+	// settings can be changed in production without a redeploy
+	SecretSettings = "primopoker-settings"
+)
+
+// secretsLoadTimeout bounds fetching the secrets at startup
+const secretsLoadTimeout = 30 * time.Second
+
+// loadedSecrets is the Secret Manager cache made by the first Load. Later
+// loads, such as settings reloads, read it rather than fetching again;
+// refreshing it keeps them current.
+var (
+	secretsMu     sync.Mutex
+	loadedSecrets *gcp.Secrets
+)
+
+// UsesStorage reports whether any Cloud Storage bucket is configured
+func (g GCPConfig) UsesStorage() bool {
+	return g.ExportBucket != "" || g.ArchiveBucket != "" || g.AvatarBucket != ""
+}
+
+// SecretPath is SecretManagerPath with $PROJECT_ID replaced by the project
+func (g GCPConfig) SecretPath() string {
+	path := g.SecretManagerPath
+	if path == "" {
+		path = "projects/$PROJECT_ID/secrets"
 	}
-	return defaultValue
+	return strings.NewReplacer("${PROJECT_ID}", g.ProjectID, "$PROJECT_ID", g.ProjectID).Replace(path)
 }
 
-func getInt64Env(key string, defaultValue int64) int64 {
-	if value := os.Getenv(key); value != "" {
-		if intValue, err := strconv.ParseInt(value, 10, 64); err == nil {
-			return intValue
-		}
+// secretFields returns the fields set from each secret
+func (c *Config) secretFields() map[string]*string {
+	return map[string]*string{
+		SecretJWT:           &c.JWTSecret,
+		SecretDBPassword:    &c.Database.Password,
+		SecretRedisPassword: &c.RedisPassword,
+		SecretOAuthGoogle:   &c.OAuth.GoogleClientSecret,
 	}
-	return defaultValue
 }
 
-func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
-	if value := os.Getenv(key); value != "" {
-		if duration, err := time.ParseDuration(value); err == nil {
-			return duration
+// applySecrets sets the configuration from the Secret Manager cache,
+// loading it on first use. Later loads are settings reloads, which fetch
+// the settings overrides afresh.
+func applySecrets(cfg *Config) {
+	secretsMu.Lock()
+	secrets, reload := loadedSecrets, loadedSecrets != nil
+	if !reload {
+		secrets = loadSecretsFromGCP(cfg)
+		loadedSecrets = secrets
+	}
+	secretsMu.Unlock()
+	if secrets == nil {
+		return
+	}
+
+	if reload {
+		ctx, cancel := context.WithTimeout(context.Background(), secretsLoadTimeout)
+		if err := secrets.Fetch(ctx, SecretSettings); err != nil {
+			fmt.Printf("Warning: Failed to fetch settings overrides: %v\n", err)
+		}
+		cancel()
+	}
+
+	cfg.Secrets = secrets
+	for name, field := range cfg.secretFields() {
+		if value, ok := secrets.Value(name); ok {
+			*field = value
 		}
 	}
-	return defaultValue
+	if overrides, ok := secrets.Value(SecretSettings); ok {
+		applyOverrides(overrides)
+	}
 }
 
-// loadSecretsFromGCP loads secrets from Google Cloud Secret Manager
-func loadSecretsFromGCP(cfg *Config) {
-	ctx := context.Background()
-	secretsClient, err := gcp.NewSecretManager(ctx, cfg.GCP.ProjectID)
+// loadSecretsFromGCP creates the Secret Manager cache and fills it, or
+// returns nil if Secret Manager cannot be reached at all
+func loadSecretsFromGCP(cfg *Config) *gcp.Secrets {
+	ctx, cancel := context.WithTimeout(context.Background(), secretsLoadTimeout)
+	defer cancel()
+
+	secretsClient, err := gcp.NewSecretManager(ctx, cfg.GCP.SecretPath())
 	if err != nil {
 		// Log error but don't fail - fallback to environment variables
 		fmt.Printf("Warning: Failed to create secrets client: %v\n", err)
-		return
+		return nil
 	}
-	defer secretsClient.Close()
 
-	// Load JWT secret
-	if jwtSecret, err := secretsClient.GetSecret(ctx, "primopoker-jwt-secret"); err == nil {
-		cfg.JWTSecret = jwtSecret
+	secrets := gcp.NewSecrets(secretsClient, cfg.GCP.SecretRefreshInterval,
+		SecretJWT, SecretDBPassword, SecretRedisPassword, SecretOAuthGoogle, SecretSettings)
+	if err := secrets.Refresh(ctx); err != nil {
+		fmt.Printf("Warning: Failed to load secrets: %v\n", err)
 	}
+	return secrets
+}
 
-	// Load database password
-	if dbPassword, err := secretsClient.GetSecret(ctx, "primopoker-db-password"); err == nil {
-		cfg.Database.Password = dbPassword
+// applyOverrides sets the environment variables given as KEY=value lines,
+// as in a .env file; blank lines and lines starting with # are skipped
+func applyOverrides(overrides string) {
+	for _, line := range strings.Split(overrides, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok {
+			os.Setenv(strings.TrimSpace(key), strings.TrimSpace(value))
+		}
 	}
 }
 
@@ -219,12 +796,12 @@ func setupCloudSQLConnection(cfg *Config) {
 		// Default Cloud SQL Unix socket path
 		cfg.Database.SocketPath = fmt.Sprintf("/cloudsql/%s", cfg.GCP.CloudSQLInstance)
 	}
-	
+
 	// Override SSL mode for Cloud SQL Unix socket connections
 	if cfg.Database.SocketPath != "" {
 		cfg.Database.SSLMode = "disable" // Unix sockets don't need SSL
 	}
-	
+
 	// Use TCP connection if no Unix socket is available
 	if cfg.Database.Host == "" && cfg.Database.SocketPath == "" {
 		// Parse Cloud SQL instance name to get host
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateRejectsDefaultSecretInProduction(t *testing.T) {
+	cfg := &Config{Environment: "production", JWTSecret: DefaultJWTSecret}
+	assert.Error(t, cfg.Validate())
+
+	cfg.JWTSecret = ""
+	assert.Error(t, cfg.Validate())
+
+	cfg.JWTSecret = "a-real-secret"
+	assert.NoError(t, cfg.Validate())
+
+	// The placeholder is fine outside production
+	cfg = &Config{Environment: "development", JWTSecret: DefaultJWTSecret}
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestValidateRejectsAutoMigrateInProduction(t *testing.T) {
+	cfg := &Config{Environment: "production", JWTSecret: "a-real-secret"}
+	cfg.Database.AutoMigrate = true
+	assert.Error(t, cfg.Validate())
+
+	cfg.Environment = "development"
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestValidateRejectsUnknownDatabaseLogLevel(t *testing.T) {
+	cfg := &Config{Environment: "development"}
+	for _, level := range []string{"", "silent", "error", "warn", "info"} {
+		cfg.Database.LogLevel = level
+		assert.NoError(t, cfg.Validate(), level)
+	}
+
+	cfg.Database.LogLevel = "verbose"
+	assert.Error(t, cfg.Validate())
+}
+
+func TestValidateChecksBroadcastFanout(t *testing.T) {
+	cfg := &Config{Environment: "development"}
+	assert.NoError(t, cfg.Validate(), "broadcasts stay on each instance")
+
+	cfg.BroadcastFanout = "redis"
+	assert.Error(t, cfg.Validate(), "redis needs Redis configured")
+	cfg.RedisURL = "redis://localhost:6379"
+	assert.NoError(t, cfg.Validate())
+
+	cfg.BroadcastFanout = "pubsub"
+	assert.Error(t, cfg.Validate(), "pubsub needs a project")
+	cfg.GCP.ProjectID, cfg.GCP.BroadcastTopic = "primopoker", "poker-broadcasts"
+	assert.NoError(t, cfg.Validate())
+
+	cfg.BroadcastFanout = "kafka"
+	assert.Error(t, cfg.Validate())
+}
+
+func TestValidateChecksTaskQueue(t *testing.T) {
+	cfg := &Config{Environment: "development"}
+	cfg.GCP.TasksQueue = "game-operations"
+	assert.Error(t, cfg.Validate(), "the queue needs a project and a URL to deliver to")
+
+	cfg.GCP.ProjectID, cfg.GCP.Region = "primopoker", "us-central1"
+	cfg.GCP.TasksURL = "https://poker.example.com"
+	cfg.GCP.TasksSigningKey = "short"
+	assert.Error(t, cfg.Validate(), "the signing key must be long enough to resist guessing")
+
+	cfg.GCP.TasksSigningKey = strings.Repeat("k", 32)
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestValidateChecksStorage(t *testing.T) {
+	cfg := &Config{Environment: "development"}
+	cfg.GCP.ExportBucket = "primopoker-exports"
+	cfg.GCP.SignedURLTTL = 15 * time.Minute
+	assert.Error(t, cfg.Validate(), "buckets belong to the configured project")
+
+	cfg.GCP.ProjectID = "primopoker"
+	assert.NoError(t, cfg.Validate())
+
+	cfg.GCP.SignedURLTTL = 8 * 24 * time.Hour
+	assert.Error(t, cfg.Validate(), "Cloud Storage signs URLs for a week at most")
+}
+
+func TestValidateChecksTracing(t *testing.T) {
+	cfg := &Config{Environment: "development"}
+	for _, exporter := range []string{"", "cloudtrace", "otlp", "stdout"} {
+		cfg.Tracing.Exporter = exporter
+		assert.NoError(t, cfg.Validate(), exporter)
+	}
+
+	cfg.Tracing.Exporter = "jaeger"
+	assert.Error(t, cfg.Validate())
+
+	cfg.Tracing = TracingConfig{Exporter: "stdout", SampleRate: 1.5}
+	assert.Error(t, cfg.Validate())
+}
+
+func TestValidateChecksShutdown(t *testing.T) {
+	cfg := &Config{Environment: "development"}
+	cfg.Server.ShutdownTimeout = 9 * time.Second
+	cfg.Tables.ShutdownHandWait = 6 * time.Second
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Tables.ShutdownHandWait = 9 * time.Second
+	assert.Error(t, cfg.Validate(), "tables must be stored before the server is made to stop")
+
+	cfg.Tables.ShutdownHandWait = -time.Second
+	assert.Error(t, cfg.Validate())
+}
+
+func TestValidateChecksTrustedProxies(t *testing.T) {
+	cfg := &Config{Environment: "development"}
+	cfg.Security.TrustedProxies = []string{"10.0.0.0/8", "192.0.2.7", "2001:db8::/32"}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Security.TrustedProxies = []string{"10.0.0.0/33"}
+	assert.Error(t, cfg.Validate())
+	cfg.Security.TrustedProxies = []string{"load-balancer"}
+	assert.Error(t, cfg.Validate())
+}
+
+func TestSecretPathInterpolatesProject(t *testing.T) {
+	gcp := GCPConfig{ProjectID: "primopoker", SecretManagerPath: "projects/$PROJECT_ID/secrets"}
+	assert.Equal(t, "projects/primopoker/secrets", gcp.SecretPath())
+
+	gcp.SecretManagerPath = "projects/${PROJECT_ID}/secrets"
+	assert.Equal(t, "projects/primopoker/secrets", gcp.SecretPath())
+
+	gcp.SecretManagerPath = ""
+	assert.Equal(t, "projects/primopoker/secrets", gcp.SecretPath())
+
+	gcp.SecretManagerPath = "projects/shared-secrets/secrets"
+	assert.Equal(t, "projects/shared-secrets/secrets", gcp.SecretPath())
+}
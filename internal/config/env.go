@@ -0,0 +1,73 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// applyEnv sets each field of the struct target points to from the
+// environment variable named by its env tag, descending into nested
+// structs. Fields whose variable is unset, or set to something that does
+// not parse, keep their value.
+func applyEnv(target interface{}) {
+	applyEnvFields(reflect.ValueOf(target).Elem())
+}
+
+func applyEnvFields(v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		field, value := v.Type().Field(i), v.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		key := field.Tag.Get("env")
+		if key == "" {
+			if value.Kind() == reflect.Struct {
+				applyEnvFields(value)
+			}
+			continue
+		}
+		if env := os.Getenv(key); env != "" {
+			setFromEnv(value, env)
+		}
+	}
+}
+
+// setFromEnv parses env into a field, leaving the field alone if it does
+// not parse
+func setFromEnv(value reflect.Value, env string) {
+	switch {
+	case value.Type() == durationType:
+		if duration, err := time.ParseDuration(env); err == nil {
+			value.SetInt(int64(duration))
+		}
+	case value.Kind() == reflect.String:
+		value.SetString(env)
+	case value.Kind() == reflect.Int, value.Kind() == reflect.Int64:
+		if intValue, err := strconv.ParseInt(env, 10, 64); err == nil {
+			value.SetInt(intValue)
+		}
+	case value.Kind() == reflect.Float64:
+		if floatValue, err := strconv.ParseFloat(env, 64); err == nil {
+			value.SetFloat(floatValue)
+		}
+	case value.Kind() == reflect.Bool:
+		if boolValue, err := strconv.ParseBool(env); err == nil {
+			value.SetBool(boolValue)
+		}
+	case value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.String:
+		// Comma-separated lists
+		var values []string
+		for _, item := range strings.Split(env, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+		value.Set(reflect.ValueOf(values))
+	}
+}
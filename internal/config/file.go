@@ -0,0 +1,81 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted stands in for a secret when the configuration is printed
+const redacted = "REDACTED"
+
+// loadFile reads a YAML or JSON config file over cfg. Keys the file leaves
+// out keep their value, and a key the configuration does not have is an
+// error, so a misspelt setting is not silently ignored.
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	// JSON is YAML too, so one decoder reads either
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Redacted returns a copy of the configuration with its secrets, and the
+// passwords in its connection strings, replaced
+func (c *Config) Redacted() *Config {
+	copied := *c
+	copied.JWTSecret = redact(c.JWTSecret)
+	copied.Database.Password = redact(c.Database.Password)
+	copied.OAuth.GoogleClientSecret = redact(c.OAuth.GoogleClientSecret)
+	copied.RedisPassword = redact(c.RedisPassword)
+	copied.DatabaseURL = redactDSN(c.DatabaseURL)
+	copied.RedisURL = redactDSN(c.RedisURL)
+	copied.Database.ReplicaURL = redactDSN(c.Database.ReplicaURL)
+	return &copied
+}
+
+// WriteRedacted writes the configuration as YAML, in the config file's
+// format, with its secrets redacted
+func (c *Config) WriteRedacted(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c.Redacted()); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// redact hides a secret, leaving an unset one visibly unset
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redacted
+}
+
+// dsnPassword matches the password of a key=value connection string
+var dsnPassword = regexp.MustCompile(`(password=)\S+`)
+
+// redactDSN hides the password of a URL or key=value connection string
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+		}
+		return u.String()
+	}
+	return dsnPassword.ReplaceAllString(dsn, "${1}"+redacted)
+}
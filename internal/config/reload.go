@@ -0,0 +1,105 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// Change is a setting a reload changed, with its old and new values
+type Change struct {
+	Setting string `json:"setting"`
+	Old     string `json:"old"`
+	New     string `json:"new"`
+}
+
+// LiveSettings holds the Settings in effect. A reload swaps in a whole new
+// snapshot, so readers see all of one reload or none of it; read Current
+// on each use rather than keeping the result.
+type LiveSettings struct {
+	current atomic.Pointer[Settings]
+	load    func() (Settings, error)
+	apply   []func(Settings)
+	mu      sync.Mutex // Serializes reloads
+}
+
+// NewLiveSettings starts from settings and reloads with load
+func NewLiveSettings(settings Settings, load func() (Settings, error)) *LiveSettings {
+	l := &LiveSettings{load: load}
+	l.current.Store(&settings)
+	return l
+}
+
+// LoadSettings reads the configuration afresh and returns its settings,
+// or an error if the configuration is invalid
+func LoadSettings() (Settings, error) {
+	cfg := Load()
+	if err := cfg.Validate(); err != nil {
+		return Settings{}, err
+	}
+	return cfg.Settings, nil
+}
+
+// Current returns the settings in effect
+func (l *LiveSettings) Current() *Settings {
+	return l.current.Load()
+}
+
+// OnReload calls apply with the current settings now and with the new
+// ones after each reload that changes something, for settings that must
+// be pushed rather than read on use
+func (l *LiveSettings) OnReload(apply func(Settings)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.apply = append(l.apply, apply)
+	apply(*l.Current())
+}
+
+// Reload loads the settings again and swaps them in, returning what
+// changed. Invalid settings are refused and the current ones kept.
+func (l *LiveSettings) Reload() ([]Change, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	next, err := l.load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load settings: %w", err)
+	}
+	changes := Diff(*l.Current(), next)
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	l.current.Store(&next)
+	for _, apply := range l.apply {
+		apply(next)
+	}
+	return changes, nil
+}
+
+// Diff lists the settings that differ between old and new, named by their
+// field path such as "Game.BigBlind"
+func Diff(old, new Settings) []Change {
+	var changes []Change
+	diffFields("", reflect.ValueOf(old), reflect.ValueOf(new), &changes)
+	return changes
+}
+
+// diffFields appends the fields of two structs that differ, recursing
+// into nested structs
+func diffFields(prefix string, old, new reflect.Value, changes *[]Change) {
+	for i := 0; i < old.NumField(); i++ {
+		name := prefix + old.Type().Field(i).Name
+		before, after := old.Field(i), new.Field(i)
+		if before.Kind() == reflect.Struct {
+			diffFields(name+".", before, after, changes)
+			continue
+		}
+		// Compared as printed, so an empty list read from a file is no
+		// change from an unset one
+		if oldValue, newValue := fmt.Sprint(before.Interface()), fmt.Sprint(after.Interface()); oldValue != newValue {
+			*changes = append(*changes, Change{Setting: name, Old: oldValue, New: newValue})
+		}
+	}
+}
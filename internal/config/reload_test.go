@@ -0,0 +1,70 @@
+package config
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffNamesChangedSettings(t *testing.T) {
+	old := Settings{LogLevel: "info", RateLimitPerMinute: 100, AllowedOrigins: []string{"https://a.example.com"}}
+	old.Game.BigBlind = 100
+
+	next := old
+	next.LogLevel = "debug"
+	next.AllowedOrigins = []string{"https://a.example.com", "https://b.example.com"}
+	next.Game.BigBlind = 200
+
+	assert.Equal(t, []Change{
+		{Setting: "LogLevel", Old: "info", New: "debug"},
+		{Setting: "AllowedOrigins", Old: "[https://a.example.com]", New: "[https://a.example.com https://b.example.com]"},
+		{Setting: "Game.BigBlind", Old: "100", New: "200"},
+	}, Diff(old, next))
+	assert.Empty(t, Diff(old, old))
+}
+
+func TestLiveSettingsReload(t *testing.T) {
+	next := Settings{LogLevel: "info", RateLimitPerMinute: 100}
+	var loadErr error
+	live := NewLiveSettings(next, func() (Settings, error) { return next, loadErr })
+
+	var applied []Settings
+	live.OnReload(func(s Settings) { applied = append(applied, s) })
+	require.Len(t, applied, 1, "the current settings are applied straight away")
+
+	// Nothing changed, so nothing is applied
+	changes, err := live.Reload()
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+	assert.Len(t, applied, 1)
+
+	next.RateLimitPerMinute = 10
+	changes, err = live.Reload()
+	require.NoError(t, err)
+	assert.Equal(t, []Change{{Setting: "RateLimitPerMinute", Old: "100", New: "10"}}, changes)
+	assert.Equal(t, 10, live.Current().RateLimitPerMinute)
+	require.Len(t, applied, 2)
+	assert.Equal(t, 10, applied[1].RateLimitPerMinute)
+
+	// A failed load keeps the settings in effect
+	next.RateLimitPerMinute = 1000
+	loadErr = errors.New("LOG_LEVEL must be debug, info, warn or error")
+	_, err = live.Reload()
+	assert.Error(t, err)
+	assert.Equal(t, 10, live.Current().RateLimitPerMinute)
+	assert.Len(t, applied, 2)
+}
+
+func TestValidateRejectsBadSettings(t *testing.T) {
+	assert.NoError(t, Settings{}.Validate(), "unset settings take their defaults")
+
+	assert.Error(t, Settings{LogLevel: "verbose"}.Validate())
+	assert.Error(t, Settings{RateLimitPerMinute: -1}.Validate())
+	assert.Error(t, Settings{Game: GameConfig{SmallBlind: 200, BigBlind: 100}}.Validate())
+	assert.Error(t, Settings{WebSocket: WebSocketConfig{PongWait: 10, PingPeriod: 10}}.Validate())
+
+	cfg := &Config{Environment: "development", Settings: Settings{LogLevel: "verbose"}}
+	assert.Error(t, cfg.Validate(), "the configuration is refused at startup too")
+}
@@ -0,0 +1,503 @@
+// Package baseline is the database schema as it stood when versioned
+// migrations were introduced. The first migration creates it, or leaves an
+// existing database built by AutoMigrate as it is.
+//
+// These types are a frozen copy of the models: they must not change with
+// them. Later schema changes are made by new migrations.
+package baseline
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Models lists the baseline tables in the order they are created
+func Models() []interface{} {
+	return []interface{}{
+		&User{},
+		&Game{},
+		&GameParticipation{},
+		&HandHistory{},
+		&HandSummary{},
+		&HandSettlement{},
+		&PlayerStatAggregate{},
+		&LeaderboardEntry{},
+		&Achievement{},
+		&UserAchievement{},
+		&Tournament{},
+		&TournamentRegistration{},
+		&Session{},
+		&RotatedRefreshToken{},
+		&PlayerReport{},
+		&OAuthIdentity{},
+		&LoginEvent{},
+		&APIKey{},
+	}
+}
+
+type Achievement struct {
+	Code        string `gorm:"primaryKey;size:50"`
+	Name        string `gorm:"not null;size:100"`
+	Description string `gorm:"size:255"`
+	Badge       string `gorm:"size:50"`
+	SortOrder   int
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type UserAchievement struct {
+	ID              uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID          uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_user_achievements_user_code"`
+	AchievementCode string     `gorm:"not null;size:50;uniqueIndex:idx_user_achievements_user_code"`
+	HandID          *uuid.UUID `gorm:"type:uuid"`
+	UnlockedAt      time.Time
+	Achievement     Achievement `gorm:"foreignKey:AchievementCode;references:Code"`
+}
+
+type APIScope string
+
+type APIKey struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID     uuid.UUID `gorm:"type:uuid;not null;index"`
+	Name       string    `gorm:"not null;size:100"`
+	Prefix     string    `gorm:"not null;size:16"`
+	KeyHash    string    `gorm:"uniqueIndex;not null;size:64"`
+	Scopes     string    `gorm:"not null;size:100"`
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+	User       User `gorm:"foreignKey:UserID"`
+}
+
+type GameStatus string
+
+type GameType string
+
+type Game struct {
+	ID              uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Name            string     `gorm:"not null;size:100"`
+	GameType        GameType   `gorm:"not null;default:'texas_holdem'"`
+	Status          GameStatus `gorm:"not null;default:'waiting'"`
+	MaxPlayers      int        `gorm:"not null;default:10"`
+	MinPlayers      int        `gorm:"not null;default:2"`
+	SmallBlind      int64      `gorm:"not null"`
+	BigBlind        int64      `gorm:"not null"`
+	BuyIn           int64      `gorm:"not null"`
+	MaxBuyIn        int64
+	MinBuyIn        int64
+	CurrentHand     int        `gorm:"default:0"`
+	TotalHands      int        `gorm:"default:0"`
+	VoidedHands     int        `gorm:"default:0"`
+	TotalPot        int64      `gorm:"default:0"`
+	CurrentPot      int64      `gorm:"default:0"`
+	DealerPosition  int        `gorm:"default:0"`
+	TurnTimeout     int        `gorm:"default:30"`
+	DecisionTimeout int        `gorm:"default:15"`
+	WinnerID        *uuid.UUID `gorm:"type:uuid"`
+	Winner          *User      `gorm:"foreignKey:WinnerID"`
+	StartedAt       *time.Time
+	FinishedAt      *time.Time
+	Duration        int
+	IsPrivate       bool           `gorm:"default:false"`
+	Password        string         `gorm:"size:255"`
+	Description     string         `gorm:"size:500"`
+	Tags            []string       `gorm:"serializer:json"`
+	Settings        map[string]any `gorm:"serializer:json"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	DeletedAt       gorm.DeletedAt      `gorm:"index"`
+	Participations  []GameParticipation `gorm:"foreignKey:GameID"`
+	HandHistories   []HandHistory       `gorm:"foreignKey:GameID"`
+}
+
+type GameParticipation struct {
+	ID            uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	GameID        uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_game_participations_active,where:is_active = true;index:idx_game_participations_user_game,priority:2"`
+	UserID        uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_game_participations_active,where:is_active = true;index:idx_game_participations_user_game,priority:1"`
+	SeatPosition  int       `gorm:"not null"`
+	BuyInAmount   int64     `gorm:"not null"`
+	CurrentChips  int64     `gorm:"not null"`
+	TotalWinnings int64     `gorm:"default:0"`
+	TotalLosses   int64     `gorm:"default:0"`
+	HandsPlayed   int       `gorm:"default:0"`
+	HandsWon      int       `gorm:"default:0"`
+	HandsFolded   int       `gorm:"default:0"`
+	TotalBets     int64     `gorm:"default:0"`
+	TotalCalls    int64     `gorm:"default:0"`
+	TotalRaises   int64     `gorm:"default:0"`
+	BiggestWin    int64     `gorm:"default:0"`
+	BiggestLoss   int64     `gorm:"default:0"`
+	IsActive      bool      `gorm:"default:true"`
+	IsEliminated  bool      `gorm:"default:false"`
+	LeftAt        *time.Time
+	Placement     int
+	JoinedAt      time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	DeletedAt     gorm.DeletedAt `gorm:"index"`
+	Game          Game           `gorm:"foreignKey:GameID"`
+	User          User           `gorm:"foreignKey:UserID"`
+}
+
+type HandPhase string
+
+type PlayerAction string
+
+type HandHistory struct {
+	ID               uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	GameID           uuid.UUID `gorm:"type:uuid;not null;index:idx_hand_histories_game_hand;uniqueIndex:idx_hand_histories_hand_user,priority:1"`
+	UserID           uuid.UUID `gorm:"type:uuid;not null;index:idx_hand_histories_user_started,priority:1;index:idx_hand_histories_user_winner,priority:1;uniqueIndex:idx_hand_histories_hand_user,priority:3"`
+	HandNumber       int       `gorm:"not null;index:idx_hand_histories_game_hand;uniqueIndex:idx_hand_histories_hand_user,priority:2"`
+	TableName        string    `gorm:"size:100"`
+	DealerPosition   int
+	SeatPosition     int
+	Position         string `gorm:"size:8"`
+	HoleCard1Rank    string `gorm:"size:2"`
+	HoleCard1Suit    string `gorm:"size:10"`
+	HoleCard2Rank    string `gorm:"size:2"`
+	HoleCard2Suit    string `gorm:"size:10"`
+	FlopCard1Rank    string `gorm:"size:2"`
+	FlopCard1Suit    string `gorm:"size:10"`
+	FlopCard2Rank    string `gorm:"size:2"`
+	FlopCard2Suit    string `gorm:"size:10"`
+	FlopCard3Rank    string `gorm:"size:2"`
+	FlopCard3Suit    string `gorm:"size:10"`
+	TurnCardRank     string `gorm:"size:2"`
+	TurnCardSuit     string `gorm:"size:10"`
+	RiverCardRank    string `gorm:"size:2"`
+	RiverCardSuit    string `gorm:"size:10"`
+	SmallBlind       int64
+	BigBlind         int64
+	StartingChips    int64
+	EndingChips      int64
+	NetResult        int64
+	PotSize          int64
+	AmountWon        int64
+	PreFlopActions   []PlayerActionRecord `gorm:"serializer:json"`
+	FlopActions      []PlayerActionRecord `gorm:"serializer:json"`
+	TurnActions      []PlayerActionRecord `gorm:"serializer:json"`
+	RiverActions     []PlayerActionRecord `gorm:"serializer:json"`
+	HandRank         string               `gorm:"size:50"`
+	BestHand         string               `gorm:"size:200"`
+	IsWinner         bool                 `gorm:"default:false;index:idx_hand_histories_user_winner,priority:2"`
+	WentToShowdown   bool                 `gorm:"default:false"`
+	FoldedPhase      HandPhase            `gorm:"size:20"`
+	VPIPPercent      float64              `gorm:"column:vpip_percent"`
+	PFRPercent       float64
+	AggressionFactor float64
+	WinRate          float64
+	StartedAt        time.Time `gorm:"index:idx_hand_histories_user_started,priority:2,sort:desc"`
+	FinishedAt       time.Time
+	Duration         int
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	DeletedAt        gorm.DeletedAt `gorm:"index"`
+	Game             Game           `gorm:"foreignKey:GameID"`
+	User             User           `gorm:"foreignKey:UserID"`
+}
+
+type PlayerActionRecord struct {
+	PlayerID    uuid.UUID
+	Username    string
+	Action      PlayerAction
+	Amount      int64
+	Timestamp   time.Time
+	ChipsBefore int64
+	ChipsAfter  int64
+	Position    string
+}
+
+type SummaryPeriod string
+
+type HandSummary struct {
+	ID               uuid.UUID     `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID           uuid.UUID     `gorm:"type:uuid;not null;uniqueIndex:idx_hand_summaries_user_period"`
+	GameID           *uuid.UUID    `gorm:"type:uuid"`
+	Period           SummaryPeriod `gorm:"size:10;uniqueIndex:idx_hand_summaries_user_period"`
+	TotalHands       int
+	HandsWon         int
+	HandsLost        int
+	HandsFolded      int
+	WinRate          float64
+	TotalWagered     int64
+	TotalWon         int64
+	NetResult        int64
+	AvgPotSize       float64
+	AvgWinAmount     float64
+	VPIPPercent      float64
+	PFRPercent       float64
+	AggressionFactor float64
+	FoldToSteal      float64
+	PocketPairs      int
+	SuitedCards      int
+	ConnectedCards   int
+	PeriodStart      time.Time `gorm:"uniqueIndex:idx_hand_summaries_user_period"`
+	PeriodEnd        time.Time
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	DeletedAt        gorm.DeletedAt `gorm:"index"`
+	User             User           `gorm:"foreignKey:UserID"`
+	Game             Game           `gorm:"foreignKey:GameID"`
+}
+
+type HandSettlement struct {
+	GameID     uuid.UUID `gorm:"type:uuid;primaryKey"`
+	HandNumber int       `gorm:"primaryKey;autoIncrement:false"`
+	SettledAt  time.Time
+}
+
+type LeaderboardMetric string
+
+type LeaderboardEntry struct {
+	Day         time.Time         `gorm:"primaryKey"`
+	Metric      LeaderboardMetric `gorm:"size:30;primaryKey"`
+	UserID      uuid.UUID         `gorm:"type:uuid;primaryKey"`
+	Rank        int               `gorm:"not null;index"`
+	Username    string            `gorm:"size:50"`
+	Value       float64
+	HandsPlayed int
+	CreatedAt   time.Time
+}
+
+type LoginOutcome string
+
+type LoginEvent struct {
+	ID                uuid.UUID    `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID            *uuid.UUID   `gorm:"type:uuid;index:idx_login_events_user_created"`
+	Username          string       `gorm:"size:50"`
+	Method            string       `gorm:"not null;size:20"`
+	IPAddress         string       `gorm:"size:45"`
+	UserAgent         string       `gorm:"size:500"`
+	Outcome           LoginOutcome `gorm:"not null;size:30"`
+	DeviceFingerprint string       `gorm:"size:64;index"`
+	NewDevice         bool
+	CreatedAt         time.Time `gorm:"index:idx_login_events_user_created;index"`
+}
+
+type OAuthIdentity struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	Provider  string    `gorm:"not null;size:20;uniqueIndex:idx_oauth_provider_subject"`
+	Subject   string    `gorm:"not null;size:255;uniqueIndex:idx_oauth_provider_subject"`
+	Email     string    `gorm:"size:255"`
+	CreatedAt time.Time
+	User      User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
+
+func (OAuthIdentity) TableName() string {
+	return "oauth_identities"
+}
+
+type PlayerStatAggregate struct {
+	UserID              uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Day                 time.Time `gorm:"primaryKey"`
+	GameType            GameType  `gorm:"size:20;primaryKey"`
+	SmallBlind          int64     `gorm:"primaryKey;autoIncrement:false"`
+	BigBlind            int64     `gorm:"primaryKey;autoIncrement:false"`
+	FirstHandAt         time.Time
+	Hands               int
+	HandsWon            int
+	HandsFolded         int
+	Showdowns           int
+	ShowdownsWon        int
+	WonDollarAtShowdown int64
+	FlopsSeen           int
+	FlopShowdowns       int
+	FlopShowdownsWon    int
+	WonWhenSawFlop      int
+	TotalWagered        int64
+	TotalWon            int64
+	BiggestWin          int64
+	BiggestLoss         int64
+	PotSizeSum          int64
+	BigBlindsWon        float64
+	TableTime           time.Duration
+	VPIPHands           int `gorm:"column:vpip_hands"`
+	PFRHands            int
+	ThreeBetChances     int
+	ThreeBets           int
+	FacedThreeBet       int
+	FoldsToThreeBet     int
+	FourBetChances      int
+	FourBets            int
+	FacedFourBet        int
+	FoldsToFourBet      int
+	StealChances        int
+	Steals              int
+	FacedSteal          int
+	FoldsToSteal        int
+	ThreeBetsVsSteal    int
+	BigBlindHands       int
+	Walks               int
+	CBets               int
+	FoldToCBets         int
+	AggressiveActions   int
+	PassiveActions      int
+	UpdatedAt           time.Time
+}
+
+type ReportCategory string
+
+type ReportStatus string
+
+type ReportOutcome string
+
+type PlayerReport struct {
+	ID             uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	ReporterID     uuid.UUID `gorm:"type:uuid;not null;index"`
+	SubjectID      uuid.UUID `gorm:"type:uuid;not null;index"`
+	GameID         string    `gorm:"not null;size:64;index"`
+	HandNumber     *int
+	Category       ReportCategory `gorm:"not null;size:20"`
+	Description    string         `gorm:"size:2000"`
+	Context        ReportContext  `gorm:"serializer:json"`
+	Status         ReportStatus   `gorm:"not null;default:'open';index"`
+	Outcome        ReportOutcome  `gorm:"size:20"`
+	ResolutionNote string         `gorm:"size:1000"`
+	ResolvedBy     string         `gorm:"size:50"`
+	ResolvedAt     *time.Time
+	CreatedAt      time.Time `gorm:"index"`
+	UpdatedAt      time.Time
+	Reporter       User `gorm:"foreignKey:ReporterID"`
+	Subject        User `gorm:"foreignKey:SubjectID"`
+}
+
+type ReportContext struct {
+	HandNumber int
+	Chat       []ReportChatMessage
+	Actions    []ReportAction
+}
+
+type ReportChatMessage struct {
+	UserID  string
+	Message json.RawMessage
+	Time    time.Time
+}
+
+type ReportAction struct {
+	PlayerID string
+	Action   string
+	Amount   int64
+	Time     time.Time
+}
+
+type Session struct {
+	ID               uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID           uuid.UUID `gorm:"type:uuid;not null;index"`
+	RefreshTokenHash string    `gorm:"uniqueIndex;not null;size:64"`
+	IPAddress        string    `gorm:"size:45"`
+	UserAgent        string    `gorm:"size:500"`
+	LastUsedAt       time.Time
+	ExpiresAt        time.Time `gorm:"index"`
+	RevokedAt        *time.Time
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	User             User `gorm:"foreignKey:UserID"`
+}
+
+type RotatedRefreshToken struct {
+	TokenHash string    `gorm:"primaryKey;size:64"`
+	SessionID uuid.UUID `gorm:"type:uuid;not null;index"`
+	RotatedAt time.Time
+	Session   Session `gorm:"foreignKey:SessionID;constraint:OnDelete:CASCADE"`
+}
+
+type TournamentStatus string
+
+type BlindLevel struct {
+	Level      int
+	SmallBlind int64
+	BigBlind   int64
+	Ante       int64
+	Minutes    int
+}
+
+type PayoutTier struct {
+	Place   int
+	Percent float64
+}
+
+type Tournament struct {
+	ID                   uuid.UUID        `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Name                 string           `gorm:"not null;size:100"`
+	GameType             GameType         `gorm:"not null;default:'texas_holdem'"`
+	Status               TournamentStatus `gorm:"not null;default:'scheduled';index"`
+	Description          string           `gorm:"size:500"`
+	BuyIn                int64            `gorm:"not null"`
+	StartingStack        int64            `gorm:"not null"`
+	MinPlayers           int              `gorm:"not null;default:2"`
+	MaxPlayers           int              `gorm:"not null"`
+	TableSize            int              `gorm:"not null;default:9"`
+	BlindStructure       []BlindLevel     `gorm:"serializer:json"`
+	Payouts              []PayoutTier     `gorm:"serializer:json"`
+	RegistrationOpensAt  time.Time
+	RegistrationClosesAt time.Time
+	StartsAt             time.Time
+	StartedAt            *time.Time
+	FinishedAt           *time.Time
+	CurrentLevel         int `gorm:"default:0"`
+	LevelStartedAt       *time.Time
+	PrizePool            int64 `gorm:"default:0"`
+	CreatedAt            time.Time
+	UpdatedAt            time.Time
+	DeletedAt            gorm.DeletedAt           `gorm:"index"`
+	Registrations        []TournamentRegistration `gorm:"foreignKey:TournamentID"`
+}
+
+type TournamentRegistration struct {
+	ID             uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	TournamentID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_tournament_user"`
+	UserID         uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_tournament_user"`
+	BuyInPaid      int64     `gorm:"not null"`
+	RegisteredAt   time.Time
+	Chips          int64  `gorm:"default:0"`
+	TableID        string `gorm:"size:100"`
+	SeatPosition   int    `gorm:"default:0"`
+	IsEliminated   bool   `gorm:"default:false"`
+	FinishPosition int    `gorm:"default:0"`
+	Prize          int64  `gorm:"default:0"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Tournament     Tournament `gorm:"foreignKey:TournamentID"`
+	User           User       `gorm:"foreignKey:UserID"`
+}
+
+type Role string
+
+type User struct {
+	ID                 uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Username           string     `gorm:"uniqueIndex;not null;size:50"`
+	Email              string     `gorm:"uniqueIndex;not null;size:255"`
+	PasswordHash       string     `gorm:"not null;size:255"`
+	DisplayName        string     `gorm:"size:100"`
+	Avatar             string     `gorm:"size:500"`
+	ChipBalance        int64      `gorm:"default:10000"`
+	GamesPlayed        int        `gorm:"default:0"`
+	GamesWon           int        `gorm:"default:0"`
+	HandsPlayed        int        `gorm:"default:0"`
+	HandsWon           int        `gorm:"default:0"`
+	TotalWinnings      int64      `gorm:"default:0"`
+	TotalLosses        int64      `gorm:"default:0"`
+	BiggestWin         int64      `gorm:"default:0"`
+	BiggestLoss        int64      `gorm:"default:0"`
+	IsActive           bool       `gorm:"default:true"`
+	IsVerified         bool       `gorm:"default:false"`
+	IsBanned           bool       `gorm:"default:false"`
+	Role               Role       `gorm:"not null;default:'player';size:20"`
+	LastLoginAt        *time.Time `gorm:"index"`
+	LoginAttempts      int        `gorm:"default:0"`
+	LastFailedLoginAt  *time.Time
+	LockedUntil        *time.Time
+	Timezone           string `gorm:"default:'UTC';size:50"`
+	Language           string `gorm:"default:'en';size:10"`
+	Theme              string `gorm:"default:'dark';size:20"`
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+	DeletedAt          gorm.DeletedAt      `gorm:"index"`
+	GameParticipations []GameParticipation `gorm:"foreignKey:UserID"`
+	HandHistories      []HandHistory       `gorm:"foreignKey:UserID"`
+}
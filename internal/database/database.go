@@ -1,18 +1,30 @@
 package database
 
 import (
+	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/primoPoker/server/internal/models"
+	"github.com/sirupsen/logrus"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
-// DB holds the database connection
+// DB holds the database connection. Writes and anything that must read
+// its own writes go through the embedded primary; heavy reads that can
+// stand some replication lag go through Reader.
 type DB struct {
 	*gorm.DB
+	reader *gorm.DB
+	retry  RetryPolicy
+
+	// healthWarning is the ping latency past which Check reports the
+	// database degraded
+	healthWarning time.Duration
+	health        healthState
 }
 
 // Config holds database configuration
@@ -31,6 +43,21 @@ type Config struct {
 	MaxIdleConns       int    // Maximum idle connections
 	ConnMaxLifetime    time.Duration // Connection maximum lifetime
 	ConnMaxIdleTime    time.Duration // Connection maximum idle time
+	// Query logging: LogLevel is silent, error, warn or info, and at warn
+	// statements slower than SlowQueryThreshold are logged
+	LogLevel           string
+	SlowQueryThreshold time.Duration
+	// ConnectTimeout is how long connecting and migrating keep retrying
+	// while the database is not ready; zero tries once
+	ConnectTimeout time.Duration
+	// HealthLatencyWarning is the ping latency past which health checks
+	// report the database degraded; zero uses DefaultLatencyWarning
+	HealthLatencyWarning time.Duration
+	// Read replica, optional. It has its own pool so analytical queries
+	// cannot starve the primary of connections.
+	ReplicaDSN          string
+	ReplicaMaxOpenConns int
+	ReplicaMaxIdleConns int
 }
 
 // NewDB creates a new database connection
@@ -62,20 +89,22 @@ func NewDB(config Config) (*DB, error) {
 		)
 	}
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
-		NowFunc: func() time.Time {
-			return time.Now().UTC()
-		},
-	})
+	level, err := ParseLogLevel(config.LogLevel)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
+		return nil, err
 	}
+	slowQueryThreshold := config.SlowQueryThreshold
+	if slowQueryThreshold == 0 {
+		slowQueryThreshold = DefaultSlowQueryThreshold
+	}
+	queryLog := newQueryLogger(logrus.StandardLogger(), level, slowQueryThreshold)
 
-	// Get underlying sql.DB to configure connection pool
-	sqlDB, err := db.DB()
+	retry := RetryPolicy{Timeout: config.ConnectTimeout}
+	db, err := connect(retry, "connect to database", func() (*gorm.DB, error) {
+		return open(dsn, queryLog)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get underlying sql.DB: %w", err)
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
 	// Configure connection pool with Cloud SQL optimizations
@@ -99,27 +128,240 @@ func NewDB(config Config) (*DB, error) {
 		connMaxIdleTime = 10 * time.Minute
 	}
 
+	if err := configurePool(db, maxOpenConns, maxIdleConns, connMaxLifetime, connMaxIdleTime); err != nil {
+		return nil, err
+	}
+
+	if config.ReplicaDSN == "" {
+		return &DB{DB: db, retry: retry, healthWarning: config.HealthLatencyWarning}, nil
+	}
+
+	replica, err := connect(retry, "connect to read replica", func() (*gorm.DB, error) {
+		return open(config.ReplicaDSN, queryLog.withDatabase("replica"))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to read replica: %w", err)
+	}
+
+	replicaMaxOpenConns := config.ReplicaMaxOpenConns
+	if replicaMaxOpenConns == 0 {
+		replicaMaxOpenConns = 10
+	}
+
+	replicaMaxIdleConns := config.ReplicaMaxIdleConns
+	if replicaMaxIdleConns == 0 {
+		replicaMaxIdleConns = 2
+	}
+
+	if err := configurePool(replica, replicaMaxOpenConns, replicaMaxIdleConns, connMaxLifetime, connMaxIdleTime); err != nil {
+		return nil, err
+	}
+
+	replicated := NewReplicatedDB(db, replica)
+	replicated.retry = retry
+	replicated.healthWarning = config.HealthLatencyWarning
+	return replicated, nil
+}
+
+// NewReplicatedDB wraps a primary and a read replica connected elsewhere.
+// A nil replica sends reads to the primary.
+func NewReplicatedDB(primary, replica *gorm.DB) *DB {
+	return &DB{DB: primary, reader: replica}
+}
+
+// open connects to Postgres at dsn, logging queries through queryLog and
+// tracing them
+func open(dsn string, queryLog logger.Interface) (*gorm.DB, error) {
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		Logger: queryLog,
+		NowFunc: func() time.Time {
+			return time.Now().UTC()
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := Trace(db); err != nil {
+		return nil, fmt.Errorf("failed to trace queries: %w", err)
+	}
+	return db, nil
+}
+
+// connect opens a connection with dial, retrying under policy while the
+// database is not ready
+func connect(policy RetryPolicy, operation string, dial func() (*gorm.DB, error)) (*gorm.DB, error) {
+	var db *gorm.DB
+	err := policy.Do(operation, func() error {
+		var err error
+		db, err = dial()
+		return err
+	})
+	return db, err
+}
+
+// configurePool sizes a connection's underlying pool
+func configurePool(db *gorm.DB, maxOpenConns, maxIdleConns int, connMaxLifetime, connMaxIdleTime time.Duration) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get underlying sql.DB: %w", err)
+	}
+
 	sqlDB.SetMaxOpenConns(maxOpenConns)
 	sqlDB.SetMaxIdleConns(maxIdleConns)
 	sqlDB.SetConnMaxLifetime(connMaxLifetime)
 	sqlDB.SetConnMaxIdleTime(connMaxIdleTime)
+	return nil
+}
+
+// Writer returns the primary, for writes and reads that must see them
+func (db *DB) Writer() *gorm.DB {
+	return db.DB
+}
 
-	return &DB{db}, nil
+// Reader returns the read replica, or the primary when none is configured.
+// Reads from it may lag the primary's latest writes.
+func (db *DB) Reader() *gorm.DB {
+	if db.reader != nil {
+		return db.reader
+	}
+	return db.DB
 }
 
-// AutoMigrate runs database migrations
+// Migrate applies the migrations not yet applied to the database and
+// returns how many it applied, retrying like the connection while the
+// database is not ready
+func (db *DB) Migrate() (int, error) {
+	var applied int
+	err := db.retry.Do("apply migrations", func() error {
+		var err error
+		applied, err = NewMigrator(db.DB, Migrations).Up()
+		return err
+	})
+	return applied, err
+}
+
+// AutoMigrate brings the schema in line with the models without recording
+// any migration. It is for development only: it cannot rename or backfill,
+// so databases built by it drift from migrated ones.
 func (db *DB) AutoMigrate() error {
-	return db.DB.AutoMigrate(
+	return db.retry.Do("auto-migrate", db.autoMigrate)
+}
+
+func (db *DB) autoMigrate() error {
+	if err := removeDuplicateHandHistories(db.DB); err != nil {
+		return fmt.Errorf("failed to remove duplicate hand histories: %w", err)
+	}
+
+	if err := db.DB.AutoMigrate(Models()...); err != nil {
+		return err
+	}
+
+	if err := createHandHistoryArchive(db.DB); err != nil {
+		return fmt.Errorf("failed to create hand history archive: %w", err)
+	}
+	return addArchiveColumns(db.DB)
+}
+
+// createHandHistoryArchive creates the table hand histories are archived
+// to, with hand_histories' columns but only the indexes archived hands are
+// looked up by
+func createHandHistoryArchive(db *gorm.DB) error {
+	if !db.Migrator().HasTable(models.HandHistoryArchiveTable) {
+		create := `CREATE TABLE hand_histories_archive (LIKE hand_histories INCLUDING DEFAULTS, PRIMARY KEY (id))`
+		if db.Dialector.Name() != "postgres" {
+			// SQLite, which tests run on, has no LIKE, so the archive is
+			// created from hand_histories' own definition
+			var definition string
+			if err := db.Raw(`SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'hand_histories'`).Scan(&definition).Error; err != nil {
+				return err
+			}
+			create = strings.Replace(definition, "hand_histories", models.HandHistoryArchiveTable, 1)
+		}
+		if err := db.Exec(create).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_hand_histories_archive_user_started ON hand_histories_archive (user_id, started_at)`).Error; err != nil {
+		return err
+	}
+	return db.Exec(`CREATE INDEX IF NOT EXISTS idx_hand_histories_archive_game ON hand_histories_archive (game_id)`).Error
+}
+
+// addArchiveColumns adds the columns AutoMigrate added to hand_histories
+// to its archive, which migrations do by hand
+func addArchiveColumns(db *gorm.DB) error {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(&models.HandHistory{}); err != nil {
+		return err
+	}
+
+	archive := db.Table(models.HandHistoryArchiveTable)
+	for _, column := range stmt.Schema.DBNames {
+		if archive.Migrator().HasColumn(&models.HandHistory{}, column) {
+			continue
+		}
+		if err := archive.Migrator().AddColumn(&models.HandHistory{}, column); err != nil {
+			return fmt.Errorf("failed to add %s to hand history archive: %w", column, err)
+		}
+	}
+	return nil
+}
+
+// removeDuplicateHandHistories keeps only the first row stored of each
+// player's hand, which servers before hands were settled once could write
+// twice, so the unique index on them can be built
+func removeDuplicateHandHistories(db *gorm.DB) error {
+	if db.Dialector.Name() != "postgres" || !db.Migrator().HasTable("hand_histories") {
+		return nil
+	}
+
+	return db.Exec(`
+		DELETE FROM hand_histories AS dup
+		USING hand_histories AS kept
+		WHERE dup.game_id = kept.game_id
+		  AND dup.hand_number = kept.hand_number
+		  AND dup.user_id = kept.user_id
+		  AND (dup.created_at, dup.id) > (kept.created_at, kept.id)`).Error
+}
+
+// Models lists every model stored in the database, in the order their
+// tables are created
+func Models() []interface{} {
+	return []interface{}{
 		&models.User{},
 		&models.Game{},
 		&models.GameParticipation{},
+		&models.Hand{},
 		&models.HandHistory{},
 		&models.HandSummary{},
-	)
+		&models.HandSettlement{},
+		&models.PlayerStatAggregate{},
+		&models.LeaderboardEntry{},
+		&models.Achievement{},
+		&models.UserAchievement{},
+		&models.Tournament{},
+		&models.TournamentRegistration{},
+		&models.Session{},
+		&models.RotatedRefreshToken{},
+		&models.PlayerReport{},
+		&models.OAuthIdentity{},
+		&models.LoginEvent{},
+		&models.APIKey{},
+		&models.AuditLog{},
+		&models.Club{},
+		&models.ClubMember{},
+	}
 }
 
-// Close closes the database connection
+// Close closes the database connection and the replica's, if any
 func (db *DB) Close() error {
+	if db.reader != nil {
+		if sqlDB, err := db.reader.DB(); err == nil {
+			sqlDB.Close()
+		}
+	}
+
 	sqlDB, err := db.DB.DB()
 	if err != nil {
 		return err
@@ -127,13 +369,40 @@ func (db *DB) Close() error {
 	return sqlDB.Close()
 }
 
-// Health checks the database connection
-func (db *DB) Health() error {
-	sqlDB, err := db.DB.DB()
-	if err != nil {
-		return err
+// PoolStats is a snapshot of a connection pool's usage
+type PoolStats struct {
+	MaxOpen      int   `json:"max_open"`
+	Open         int   `json:"open"`
+	InUse        int   `json:"in_use"`
+	Idle         int   `json:"idle"`
+	WaitCount    int64 `json:"wait_count"`
+	WaitDuration int64 `json:"wait_duration_ms"`
+}
+
+// PoolStats reports the primary's connection pool, and the replica's
+// when there is one, keyed by "primary" and "replica"
+func (db *DB) PoolStats() map[string]PoolStats {
+	stats := make(map[string]PoolStats, 2)
+	if sqlDB, err := db.DB.DB(); err == nil {
+		stats["primary"] = poolStats(sqlDB.Stats())
+	}
+	if db.reader != nil {
+		if sqlDB, err := db.reader.DB(); err == nil {
+			stats["replica"] = poolStats(sqlDB.Stats())
+		}
+	}
+	return stats
+}
+
+func poolStats(s sql.DBStats) PoolStats {
+	return PoolStats{
+		MaxOpen:      s.MaxOpenConnections,
+		Open:         s.OpenConnections,
+		InUse:        s.InUse,
+		Idle:         s.Idle,
+		WaitCount:    s.WaitCount,
+		WaitDuration: s.WaitDuration.Milliseconds(),
 	}
-	return sqlDB.Ping()
 }
 
 // Transaction runs a function within a database transaction
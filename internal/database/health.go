@@ -0,0 +1,105 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// HealthTimeout bounds how long a readiness check waits on the database
+const HealthTimeout = 500 * time.Millisecond
+
+// DefaultLatencyWarning is the round trip past which the database is
+// reported degraded when no threshold is configured
+const DefaultLatencyWarning = 100 * time.Millisecond
+
+// HealthStatus is how a health check found the database
+type HealthStatus string
+
+const (
+	HealthOK       HealthStatus = "ok"       // Answered within the warning threshold
+	HealthDegraded HealthStatus = "degraded" // Answered, but slowly
+	HealthDown     HealthStatus = "down"     // Did not answer before the timeout
+)
+
+// HealthReport is the result of checking the database
+type HealthReport struct {
+	Status    HealthStatus         `json:"status"`
+	Latency   time.Duration        `json:"-"`
+	LatencyMS float64              `json:"latency_ms"`
+	Pools     map[string]PoolStats `json:"pools"`
+	// ActiveTransactions counts the transactions open on the primary
+	// across every client, not only this server. Only Postgres reports it.
+	ActiveTransactions *int64    `json:"active_transactions,omitempty"`
+	Error              string    `json:"error,omitempty"`
+	CheckedAt          time.Time `json:"checked_at"`
+}
+
+// healthState is the latest health check, kept for the metrics exporter
+type healthState struct {
+	mu     sync.Mutex
+	report *HealthReport
+}
+
+// Health pings the primary
+func (db *DB) Health(ctx context.Context) error {
+	sqlDB, err := db.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// Check pings the primary, timing the round trip, and reports it along
+// with the connection pools and the open transactions. A ping slower than
+// the latency warning threshold is reported degraded; one that fails, down.
+func (db *DB) Check(ctx context.Context) HealthReport {
+	report := HealthReport{Status: HealthOK, CheckedAt: time.Now()}
+
+	start := time.Now()
+	err := db.Health(ctx)
+	report.Latency = time.Since(start)
+	report.LatencyMS = float64(report.Latency.Microseconds()) / 1000
+	report.Pools = db.PoolStats()
+
+	switch {
+	case err != nil:
+		report.Status, report.Error = HealthDown, err.Error()
+	case report.Latency > db.latencyWarning():
+		report.Status = HealthDegraded
+	}
+
+	if err == nil && db.Dialector.Name() == "postgres" {
+		var active int64
+		err := db.WithContext(ctx).Raw(
+			"SELECT count(*) FROM pg_stat_activity WHERE datname = current_database() AND xact_start IS NOT NULL AND pid <> pg_backend_pid()",
+		).Scan(&active).Error
+		if err == nil {
+			report.ActiveTransactions = &active
+		} else {
+			report.Status, report.Error = HealthDegraded, err.Error()
+		}
+	}
+
+	db.health.mu.Lock()
+	db.health.report = &report
+	db.health.mu.Unlock()
+	return report
+}
+
+// LastHealth returns the latest check's report, and false before the first
+func (db *DB) LastHealth() (HealthReport, bool) {
+	db.health.mu.Lock()
+	defer db.health.mu.Unlock()
+	if db.health.report == nil {
+		return HealthReport{}, false
+	}
+	return *db.health.report, true
+}
+
+func (db *DB) latencyWarning() time.Duration {
+	if db.healthWarning > 0 {
+		return db.healthWarning
+	}
+	return DefaultLatencyWarning
+}
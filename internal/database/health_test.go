@@ -0,0 +1,38 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/internal/testutil"
+)
+
+func TestCheck(t *testing.T) {
+	db := NewReplicatedDB(testutil.NewDB(t), nil)
+	_, checked := db.LastHealth()
+	assert.False(t, checked)
+
+	report := db.Check(context.Background())
+	assert.Equal(t, HealthOK, report.Status)
+	assert.Positive(t, report.Latency)
+	assert.Contains(t, report.Pools, "primary")
+	assert.Nil(t, report.ActiveTransactions, "only Postgres reports open transactions")
+
+	db.healthWarning = time.Nanosecond
+	assert.Equal(t, HealthDegraded, db.Check(context.Background()).Status, "a slow ping is degraded")
+
+	last, checked := db.LastHealth()
+	require.True(t, checked)
+	assert.Equal(t, HealthDegraded, last.Status)
+
+	sqlDB, err := db.DB.DB()
+	require.NoError(t, err)
+	require.NoError(t, sqlDB.Close())
+	report = db.Check(context.Background())
+	assert.Equal(t, HealthDown, report.Status)
+	assert.NotEmpty(t, report.Error)
+}
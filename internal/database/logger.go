@@ -0,0 +1,121 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// DefaultSlowQueryThreshold is how long a statement runs before it is
+// logged as slow when the config does not say
+const DefaultSlowQueryThreshold = 200 * time.Millisecond
+
+// ParseLogLevel reads a GORM log level by name: silent, error, warn or
+// info. Empty means warn.
+func ParseLogLevel(name string) (logger.LogLevel, error) {
+	switch strings.ToLower(name) {
+	case "silent":
+		return logger.Silent, nil
+	case "error":
+		return logger.Error, nil
+	case "", "warn":
+		return logger.Warn, nil
+	case "info":
+		return logger.Info, nil
+	default:
+		return 0, fmt.Errorf("unknown database log level %q", name)
+	}
+}
+
+// queryLogger logs GORM's statements through logrus. At warn, only
+// failed statements and those slower than the threshold are logged; at
+// info, every statement is.
+type queryLogger struct {
+	log           logrus.FieldLogger
+	level         logger.LogLevel
+	slowThreshold time.Duration
+}
+
+// newQueryLogger creates a logger at level, logging statements slower than
+// slowThreshold; a zero threshold logs no statement as slow
+func newQueryLogger(log logrus.FieldLogger, level logger.LogLevel, slowThreshold time.Duration) *queryLogger {
+	return &queryLogger{log: log, level: level, slowThreshold: slowThreshold}
+}
+
+// LogMode returns a copy of the logger at level
+func (l *queryLogger) LogMode(level logger.LogLevel) logger.Interface {
+	copied := *l
+	copied.level = level
+	return &copied
+}
+
+// Info logs GORM's own informational messages
+func (l *queryLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Info {
+		l.log.Infof(msg, args...)
+	}
+}
+
+// Warn logs GORM's own warnings
+func (l *queryLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Warn {
+		l.log.Warnf(msg, args...)
+	}
+}
+
+// Error logs GORM's own errors
+func (l *queryLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= logger.Error {
+		l.log.Errorf(msg, args...)
+	}
+}
+
+// Trace logs a statement that has run, with how long it took and how many
+// rows it touched. A missing record is an answer, not a failure, so it is
+// not logged as one.
+func (l *queryLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= logger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	failed := err != nil && !errors.Is(err, gorm.ErrRecordNotFound)
+	slow := l.slowThreshold > 0 && elapsed > l.slowThreshold
+
+	switch {
+	case failed && l.level >= logger.Error:
+		l.entry(fc, elapsed).WithError(err).Error("Database query failed")
+	case slow && l.level >= logger.Warn:
+		l.entry(fc, elapsed).WithField("threshold", l.slowThreshold.String()).Warn("Slow database query")
+	case l.level >= logger.Info:
+		l.entry(fc, elapsed).Info("Database query")
+	}
+}
+
+// entry carries a statement's SQL, duration and row count
+func (l *queryLogger) entry(fc func() (string, int64), elapsed time.Duration) *logrus.Entry {
+	sql, rows := fc()
+	fields := logrus.Fields{
+		"sql":         sql,
+		"duration_ms": float64(elapsed.Microseconds()) / 1000,
+	}
+	// Statements that return no row count report -1
+	if rows >= 0 {
+		fields["rows"] = rows
+	}
+	return l.log.WithFields(fields)
+}
+
+// withDatabase returns a copy of the logger that tags its entries with
+// which database ran the statement
+func (l *queryLogger) withDatabase(name string) *queryLogger {
+	copied := *l
+	copied.log = l.log.WithField("database", name)
+	return &copied
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/primoPoker/server/internal/testutil"
+)
+
+func TestParseLogLevel(t *testing.T) {
+	for name, want := range map[string]logger.LogLevel{
+		"":       logger.Warn,
+		"silent": logger.Silent,
+		"error":  logger.Error,
+		"WARN":   logger.Warn,
+		"info":   logger.Info,
+	} {
+		level, err := ParseLogLevel(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, level, name)
+	}
+
+	_, err := ParseLogLevel("verbose")
+	assert.Error(t, err)
+}
+
+func TestQueryLogger(t *testing.T) {
+	log, hook := logtest.NewNullLogger()
+	queryLog := newQueryLogger(log, logger.Warn, 100*time.Millisecond)
+	statement := func() (string, int64) { return "SELECT * FROM users", 3 }
+	ctx := context.Background()
+
+	// Fast statements and missing records are not worth a line at warn
+	queryLog.Trace(ctx, time.Now(), statement, nil)
+	queryLog.Trace(ctx, time.Now(), statement, gorm.ErrRecordNotFound)
+	assert.Empty(t, hook.AllEntries())
+
+	queryLog.Trace(ctx, time.Now().Add(-250*time.Millisecond), statement, nil)
+	require.Len(t, hook.AllEntries(), 1)
+	slow := hook.LastEntry()
+	assert.Equal(t, logrus.WarnLevel, slow.Level)
+	assert.Equal(t, "SELECT * FROM users", slow.Data["sql"])
+	assert.Equal(t, int64(3), slow.Data["rows"])
+	assert.GreaterOrEqual(t, slow.Data["duration_ms"], float64(250))
+
+	hook.Reset()
+	queryLog.Trace(ctx, time.Now(), func() (string, int64) { return "CREATE INDEX", -1 }, errors.New("disk full"))
+	require.Len(t, hook.AllEntries(), 1)
+	failed := hook.LastEntry()
+	assert.Equal(t, logrus.ErrorLevel, failed.Level)
+	assert.NotContains(t, failed.Data, "rows", "no row count to report")
+
+	// Info logs every statement; silent logs none
+	hook.Reset()
+	queryLog.LogMode(logger.Info).Trace(ctx, time.Now(), statement, nil)
+	require.Len(t, hook.AllEntries(), 1)
+	assert.Equal(t, logrus.InfoLevel, hook.LastEntry().Level)
+
+	hook.Reset()
+	queryLog.LogMode(logger.Silent).Trace(ctx, time.Now().Add(-time.Second), statement, errors.New("disk full"))
+	assert.Empty(t, hook.AllEntries())
+}
+
+func TestPoolStats(t *testing.T) {
+	primary, replica := testutil.NewDB(t), testutil.NewDB(t)
+
+	stats := NewReplicatedDB(primary, nil).PoolStats()
+	assert.Contains(t, stats, "primary")
+	assert.NotContains(t, stats, "replica")
+
+	// The test databases hold a single connection
+	stats = NewReplicatedDB(primary, replica).PoolStats()
+	require.Contains(t, stats, "replica")
+	assert.Equal(t, 1, stats["primary"].MaxOpen)
+	assert.Equal(t, 1, stats["replica"].MaxOpen)
+}
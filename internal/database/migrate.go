@@ -0,0 +1,218 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// migrationLockKey is the Postgres advisory lock held while migrating, so
+// instances starting together apply each migration once
+const migrationLockKey = 7263548201
+
+// ErrUnknownMigration is returned when the database has a migration applied
+// that this build does not know, i.e. it was migrated by a newer build
+var ErrUnknownMigration = errors.New("database has a migration applied that is unknown to this build")
+
+// Migration is one versioned change to the schema. Up and Down each run in
+// a transaction together with recording the change.
+type Migration struct {
+	ID          string // Sortable, e.g. 0002_add_table_notes
+	Description string
+	Up          func(tx *gorm.DB) error
+	Down        func(tx *gorm.DB) error
+}
+
+// SchemaMigration records a migration applied to the database
+type SchemaMigration struct {
+	ID        string `gorm:"primaryKey;size:100"`
+	AppliedAt time.Time
+}
+
+// TableName keeps the table name independent of the type name
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// MigrationStatus is whether a migration has been applied
+type MigrationStatus struct {
+	ID          string
+	Description string
+	AppliedAt   *time.Time
+}
+
+// Migrator applies an ordered list of migrations and rolls them back
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// NewMigrator creates a migrator for the given migrations, which are
+// applied in ID order
+func NewMigrator(db *gorm.DB, migrations []Migration) *Migrator {
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	return &Migrator{db: db, migrations: sorted}
+}
+
+// Up applies every pending migration in order and returns how many it
+// applied. It stops at the first that fails, leaving the ones before it
+// applied.
+func (m *Migrator) Up() (int, error) {
+	applied := 0
+	err := m.locked(func(db *gorm.DB) error {
+		done, err := m.applied(db)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.ID]; ok {
+				continue
+			}
+			if err := m.run(db, migration, true); err != nil {
+				return err
+			}
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the last steps migrations applied, newest first, and
+// returns how many it rolled back
+func (m *Migrator) Down(steps int) (int, error) {
+	rolledBack := 0
+	err := m.locked(func(db *gorm.DB) error {
+		done, err := m.applied(db)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && rolledBack < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := done[migration.ID]; !ok {
+				continue
+			}
+			if err := m.run(db, migration, false); err != nil {
+				return err
+			}
+			rolledBack++
+		}
+		return nil
+	})
+	return rolledBack, err
+}
+
+// Status lists every migration with when it was applied, if it has been
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	if err := m.db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, fmt.Errorf("failed to create migrations table: %w", err)
+	}
+	done, err := m.applied(m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := MigrationStatus{ID: migration.ID, Description: migration.Description}
+		if appliedAt, ok := done[migration.ID]; ok {
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// applied returns when each applied migration was applied. Migrations this
+// build does not know are an error: rolling forward past them is unsafe.
+func (m *Migrator) applied(db *gorm.DB) (map[string]time.Time, error) {
+	var records []SchemaMigration
+	if err := db.Order("id").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+
+	known := make(map[string]bool, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.ID] = true
+	}
+
+	done := make(map[string]time.Time, len(records))
+	for _, record := range records {
+		if !known[record.ID] {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownMigration, record.ID)
+		}
+		done[record.ID] = record.AppliedAt
+	}
+	return done, nil
+}
+
+// run applies or rolls back one migration, recording it in the same
+// transaction
+func (m *Migrator) run(db *gorm.DB, migration Migration, up bool) error {
+	log := logrus.WithField("migration", migration.ID)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if !up {
+			if migration.Down == nil {
+				return errors.New("migration cannot be rolled back")
+			}
+			if err := migration.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{ID: migration.ID}).Error
+		}
+
+		if err := migration.Up(tx); err != nil {
+			return err
+		}
+		return tx.Create(&SchemaMigration{ID: migration.ID, AppliedAt: time.Now()}).Error
+	})
+	if err != nil {
+		if up {
+			return fmt.Errorf("failed to apply migration %s: %w", migration.ID, err)
+		}
+		return fmt.Errorf("failed to roll back migration %s: %w", migration.ID, err)
+	}
+
+	if up {
+		log.Info("Applied database migration")
+	} else {
+		log.Info("Rolled back database migration")
+	}
+	return nil
+}
+
+// locked runs fn with the migrations table in place, holding the migration
+// lock on Postgres. The lock is a session lock, so fn runs on the one
+// connection holding it.
+func (m *Migrator) locked(fn func(db *gorm.DB) error) error {
+	if m.db.Dialector.Name() != "postgres" {
+		if err := m.db.AutoMigrate(&SchemaMigration{}); err != nil {
+			return fmt.Errorf("failed to create migrations table: %w", err)
+		}
+		return fn(m.db)
+	}
+
+	return m.db.Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", migrationLockKey).Error; err != nil {
+			return fmt.Errorf("failed to take migration lock: %w", err)
+		}
+		defer func() {
+			if err := conn.Exec("SELECT pg_advisory_unlock(?)", migrationLockKey).Error; err != nil {
+				logrus.WithError(err).Error("Failed to release migration lock")
+			}
+		}()
+
+		if err := conn.AutoMigrate(&SchemaMigration{}); err != nil {
+			return fmt.Errorf("failed to create migrations table: %w", err)
+		}
+		return fn(conn)
+	})
+}
@@ -0,0 +1,214 @@
+package database
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/primoPoker/server/internal/database/baseline"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/testutil"
+)
+
+// newMigrationDB opens an empty database the migrations can run against
+func newMigrationDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db := testutil.NewDB(t)
+	testutil.Portable(t, db, baseline.Models()...)
+	testutil.Portable(t, db, &auditLog{}, &hand{}, &club{}, &clubMember{})
+	return db
+}
+
+func TestMigrationsBuildModelSchema(t *testing.T) {
+	db := newMigrationDB(t)
+	migrator := NewMigrator(db, Migrations)
+
+	applied, err := migrator.Up()
+	require.NoError(t, err)
+	assert.Equal(t, len(Migrations), applied)
+
+	applied, err = migrator.Up()
+	require.NoError(t, err)
+	assert.Zero(t, applied, "applied migrations are not run again")
+
+	// Every column and index the models expect has a migration creating it
+	for _, model := range Models() {
+		stmt := &gorm.Statement{DB: db}
+		require.NoError(t, stmt.Parse(model))
+		require.True(t, db.Migrator().HasTable(model), stmt.Schema.Table)
+
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" {
+				assert.True(t, db.Migrator().HasColumn(model, field.DBName), "%s.%s", stmt.Schema.Table, field.DBName)
+			}
+		}
+		for _, index := range stmt.Schema.ParseIndexes() {
+			assert.True(t, db.Migrator().HasIndex(model, index.Name), "%s index %s", stmt.Schema.Table, index.Name)
+		}
+	}
+
+	// Hands keep every column when archived
+	archive := db.Table(models.HandHistoryArchiveTable).Migrator()
+	stmt := &gorm.Statement{DB: db}
+	require.NoError(t, stmt.Parse(&models.HandHistory{}))
+	for _, column := range stmt.Schema.DBNames {
+		assert.True(t, archive.HasColumn(&models.HandHistory{}, column), "archive column %s", column)
+	}
+}
+
+func TestMigrationsRollBack(t *testing.T) {
+	db := newMigrationDB(t)
+	migrator := NewMigrator(db, Migrations)
+
+	_, err := migrator.Up()
+	require.NoError(t, err)
+
+	rolledBack, err := migrator.Down(len(Migrations))
+	require.NoError(t, err)
+	assert.Equal(t, len(Migrations), rolledBack)
+	for _, model := range Models() {
+		assert.False(t, db.Migrator().HasTable(model))
+	}
+	assert.False(t, db.Migrator().HasTable(models.HandHistoryArchiveTable))
+
+	statuses, err := migrator.Status()
+	require.NoError(t, err)
+	for _, status := range statuses {
+		assert.Nil(t, status.AppliedAt, status.ID)
+	}
+
+	// The chain applies again from nothing
+	applied, err := migrator.Up()
+	require.NoError(t, err)
+	assert.Equal(t, len(Migrations), applied)
+}
+
+// noteTable is a table made by the runner tests' migrations
+type noteTable struct {
+	ID   uint
+	Text string
+}
+
+func TestMigratorStopsAtFailure(t *testing.T) {
+	db := testutil.NewDB(t)
+	migrations := []Migration{
+		{
+			ID: "0002_fail",
+			Up: func(tx *gorm.DB) error {
+				if err := tx.Migrator().AddColumn(&noteTable{}, "Text"); err != nil {
+					return err
+				}
+				return errors.New("backfill failed")
+			},
+		},
+		{
+			ID:   "0001_notes",
+			Up:   func(tx *gorm.DB) error { return tx.Migrator().CreateTable(&noteTable{}) },
+			Down: func(tx *gorm.DB) error { return tx.Migrator().DropTable(&noteTable{}) },
+		},
+		{
+			ID: "0003_never",
+			Up: func(tx *gorm.DB) error {
+				t.Error("migrations after a failure are not run")
+				return nil
+			},
+		},
+	}
+
+	applied, err := NewMigrator(db, migrations).Up()
+	assert.ErrorContains(t, err, "0002_fail")
+	assert.Equal(t, 1, applied, "migrations run in ID order")
+
+	statuses, err := NewMigrator(db, migrations).Status()
+	require.NoError(t, err)
+	assert.Equal(t, "0001_notes", statuses[0].ID)
+	assert.NotNil(t, statuses[0].AppliedAt)
+	assert.Nil(t, statuses[1].AppliedAt, "a failed migration is not recorded")
+	assert.True(t, db.Migrator().HasTable(&noteTable{}))
+
+	rolledBack, err := NewMigrator(db, migrations).Down(1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, rolledBack)
+	assert.False(t, db.Migrator().HasTable(&noteTable{}))
+}
+
+func TestMigratorRefusesUnknownMigrations(t *testing.T) {
+	db := testutil.NewDB(t)
+	newer := []Migration{
+		{ID: "0001_a", Up: func(tx *gorm.DB) error { return nil }},
+		{ID: "0002_b", Up: func(tx *gorm.DB) error { return nil }},
+	}
+	_, err := NewMigrator(db, newer).Up()
+	require.NoError(t, err)
+
+	_, err = NewMigrator(db, newer).Down(1)
+	assert.ErrorContains(t, err, "cannot be rolled back")
+
+	_, err = NewMigrator(db, newer[:1]).Up()
+	assert.ErrorIs(t, err, ErrUnknownMigration, "an older build does not run against a newer schema")
+}
+
+func TestPlayersDealtInBackfill(t *testing.T) {
+	db := newMigrationDB(t)
+	_, err := NewMigrator(db, Migrations[:2]).Up()
+	require.NoError(t, err)
+
+	headsUp, sixHanded := uuid.New(), uuid.New()
+	for i, gameID := range []uuid.UUID{headsUp, headsUp, sixHanded, sixHanded, sixHanded} {
+		require.NoError(t, db.Table("hand_histories").Create(map[string]interface{}{
+			"id":            uuid.New(),
+			"game_id":       gameID,
+			"user_id":       uuid.New(),
+			"hand_number":   1,
+			"seat_position": i,
+		}).Error)
+	}
+
+	_, err = NewMigrator(db, Migrations).Up()
+	require.NoError(t, err)
+
+	var dealt []int
+	require.NoError(t, db.Table("hand_histories").Order("seat_position").Pluck("players_dealt_in", &dealt).Error)
+	assert.Equal(t, []int{2, 2, 3, 3, 3}, dealt)
+}
+
+func TestHandSearchBackfill(t *testing.T) {
+	db := newMigrationDB(t)
+	_, err := NewMigrator(db, Migrations[:7]).Up()
+	require.NoError(t, err)
+
+	boards := []struct {
+		board            string
+		paired, straight bool
+	}{
+		{"Ah Ad 7c", true, false},
+		{"Ts 9h 7d 2c", false, true},
+		{"Ah 2d 4c Kh", false, true}, // The wheel
+		{"Kh 8h 2c", false, false},
+		{"", false, false},
+	}
+	for i, board := range boards {
+		require.NoError(t, db.Table("hands").Create(map[string]interface{}{
+			"id":          uuid.New(),
+			"game_id":     uuid.New(),
+			"hand_number": i + 1,
+			"board":       board.board,
+		}).Error)
+	}
+
+	_, err = NewMigrator(db, Migrations).Up()
+	require.NoError(t, err)
+
+	var hands []models.Hand
+	require.NoError(t, db.Order("hand_number").Find(&hands).Error)
+	require.Len(t, hands, len(boards))
+	for i, board := range boards {
+		assert.Equal(t, board.paired, hands[i].BoardPaired, board.board)
+		assert.Equal(t, board.straight, hands[i].BoardStraight, board.board)
+	}
+}
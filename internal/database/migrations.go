@@ -0,0 +1,334 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/primoPoker/server/internal/database/baseline"
+	"github.com/primoPoker/server/internal/models"
+)
+
+// Migrations is every change made to the schema, oldest first. Add new
+// ones to the end; never edit one that has been released. A migration
+// changing hand_histories' columns must make the same change to its
+// archive.
+var Migrations = []Migration{
+	{
+		ID:          "0001_baseline",
+		Description: "Create the schema built by AutoMigrate before versioned migrations",
+		Up: func(tx *gorm.DB) error {
+			// Databases built by AutoMigrate may hold a player's hand
+			// twice, which the unique index on hand histories refuses
+			if err := removeDuplicateHandHistories(tx); err != nil {
+				return fmt.Errorf("failed to remove duplicate hand histories: %w", err)
+			}
+			return tx.AutoMigrate(baseline.Models()...)
+		},
+		Down: func(tx *gorm.DB) error {
+			tables := baseline.Models()
+			for i := len(tables) - 1; i >= 0; i-- {
+				if err := tx.Migrator().DropTable(tables[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		ID:          "0002_hand_history_archive",
+		Description: "Index hand histories by start time and create the archive old ones move to",
+		Up: func(tx *gorm.DB) error {
+			// Hands are archived oldest first
+			if err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_hand_histories_started ON hand_histories (started_at)`).Error; err != nil {
+				return err
+			}
+			return createHandHistoryArchive(tx)
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable(models.HandHistoryArchiveTable); err != nil {
+				return err
+			}
+			return tx.Exec(`DROP INDEX IF EXISTS idx_hand_histories_started`).Error
+		},
+	},
+	{
+		ID:          "0003_players_dealt_in",
+		Description: "Record how many players were dealt into each hand",
+		Up: func(tx *gorm.DB) error {
+			// Every player dealt in has a row, so earlier hands are
+			// counted from their rows
+			for _, table := range []string{"hand_histories", models.HandHistoryArchiveTable} {
+				if err := tx.Exec(`ALTER TABLE ` + table + ` ADD COLUMN players_dealt_in bigint NOT NULL DEFAULT 0`).Error; err != nil {
+					return err
+				}
+				if err := tx.Exec(`UPDATE ` + table + ` SET players_dealt_in = (
+					SELECT COUNT(*) FROM ` + table + ` AS dealt
+					WHERE dealt.game_id = ` + table + `.game_id AND dealt.hand_number = ` + table + `.hand_number)`).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, table := range []string{models.HandHistoryArchiveTable, "hand_histories"} {
+				if err := tx.Exec(`ALTER TABLE ` + table + ` DROP COLUMN players_dealt_in`).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		ID:          "0004_audit_logs",
+		Description: "Create the audit log of administrative and financial actions",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&auditLog{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&auditLog{})
+		},
+	},
+	{
+		ID:          "0005_hands",
+		Description: "Store each hand's board, pot and winners once in a table-level record its players' rows reference",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().CreateTable(&hand{}); err != nil {
+				return err
+			}
+			// Earlier hands have no record; their board stays on the
+			// players' rows, which readers fall back to
+			for _, table := range []string{"hand_histories", models.HandHistoryArchiveTable} {
+				if err := tx.Exec(`ALTER TABLE ` + table + ` ADD COLUMN hand_id uuid`).Error; err != nil {
+					return err
+				}
+			}
+			return tx.Exec(`CREATE INDEX idx_hand_histories_hand_id ON hand_histories (hand_id)`).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Exec(`DROP INDEX IF EXISTS idx_hand_histories_hand_id`).Error; err != nil {
+				return err
+			}
+			for _, table := range []string{models.HandHistoryArchiveTable, "hand_histories"} {
+				if err := tx.Exec(`ALTER TABLE ` + table + ` DROP COLUMN hand_id`).Error; err != nil {
+					return err
+				}
+			}
+			return tx.Migrator().DropTable(&hand{})
+		},
+	},
+	{
+		ID:          "0006_clubs",
+		Description: "Add clubs, their members, and the club a game's table is kept to",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().CreateTable(&club{}, &clubMember{}); err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE games ADD COLUMN club_id uuid`).Error; err != nil {
+				return err
+			}
+			return tx.Exec(`CREATE INDEX idx_games_club_id ON games (club_id)`).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Exec(`DROP INDEX IF EXISTS idx_games_club_id`).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`ALTER TABLE games DROP COLUMN club_id`).Error; err != nil {
+				return err
+			}
+			return tx.Migrator().DropTable(&clubMember{}, &club{})
+		},
+	},
+	{
+		ID:          "0007_aggregate_pots_and_rake",
+		Description: "Keep the biggest pot won and rake paid in players' daily totals, for the leaderboards and bankroll graphs",
+		Up: func(tx *gorm.DB) error {
+			for _, column := range []string{"biggest_pot_won", "rake_paid"} {
+				if err := tx.Exec(`ALTER TABLE player_stat_aggregates ADD COLUMN ` + column + ` bigint NOT NULL DEFAULT 0`).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, column := range []string{"rake_paid", "biggest_pot_won"} {
+				if err := tx.Exec(`ALTER TABLE player_stat_aggregates DROP COLUMN ` + column).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		ID:          "0008_hand_search",
+		Description: "Keep each hand's board texture and a summary to search, with a full-text index on Postgres",
+		Up: func(tx *gorm.DB) error {
+			for _, column := range []string{
+				"board_paired boolean NOT NULL DEFAULT false",
+				"board_straight boolean NOT NULL DEFAULT false",
+				"summary text NOT NULL DEFAULT ''",
+			} {
+				if err := tx.Exec(`ALTER TABLE hands ADD COLUMN ` + column).Error; err != nil {
+					return err
+				}
+			}
+			for _, column := range []string{"board_paired", "board_straight", "pot"} {
+				if err := tx.Exec(`CREATE INDEX idx_hands_` + column + ` ON hands (` + column + `)`).Error; err != nil {
+					return err
+				}
+			}
+			if tx.Dialector.Name() == "postgres" {
+				if err := tx.Exec(`CREATE INDEX idx_hands_summary_search ON hands USING GIN (to_tsvector('english', summary))`).Error; err != nil {
+					return err
+				}
+			}
+
+			// The texture of stored boards is worked out from their cards.
+			// Earlier hands keep an empty summary: the players' usernames
+			// and hole cards it names are not all on the hand.
+			var boards []struct {
+				ID    uuid.UUID
+				Board string
+			}
+			if err := tx.Table("hands").Select("id, board").Where("board <> ''").Find(&boards).Error; err != nil {
+				return err
+			}
+			for _, board := range boards {
+				var texture models.Hand
+				texture.SetBoard(strings.Fields(board.Board))
+				if err := tx.Table("hands").Where("id = ?", board.ID).Updates(map[string]interface{}{
+					"board_paired":   texture.BoardPaired,
+					"board_straight": texture.BoardStraight,
+				}).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, index := range []string{"idx_hands_summary_search", "idx_hands_pot", "idx_hands_board_straight", "idx_hands_board_paired"} {
+				if err := tx.Exec(`DROP INDEX IF EXISTS ` + index).Error; err != nil {
+					return err
+				}
+			}
+			for _, column := range []string{"summary", "board_straight", "board_paired"} {
+				if err := tx.Exec(`ALTER TABLE hands DROP COLUMN ` + column).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		ID:          "0009_audit_access",
+		Description: "Record the request each audit entry came in on, from the admin routes' access log",
+		Up: func(tx *gorm.DB) error {
+			for _, column := range auditAccessColumns {
+				if err := tx.Exec(`ALTER TABLE audit_logs ADD COLUMN ` + column).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for i := len(auditAccessColumns) - 1; i >= 0; i-- {
+				name, _, _ := strings.Cut(auditAccessColumns[i], " ")
+				if err := tx.Exec(`ALTER TABLE audit_logs DROP COLUMN ` + name).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		ID:          "0010_hands_against_bots",
+		Description: "Mark the hands bots were dealt into, which players' metrics leave out",
+		Up: func(tx *gorm.DB) error {
+			for _, table := range []string{"hand_histories", models.HandHistoryArchiveTable} {
+				if err := tx.Exec(`ALTER TABLE ` + table + ` ADD COLUMN against_bots boolean NOT NULL DEFAULT false`).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, table := range []string{models.HandHistoryArchiveTable, "hand_histories"} {
+				if err := tx.Exec(`ALTER TABLE ` + table + ` DROP COLUMN against_bots`).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+}
+
+// auditAccessColumns are the columns migration 0009 adds to audit_logs
+var auditAccessColumns = []string{
+	"method varchar(10) NOT NULL DEFAULT ''",
+	"route varchar(200) NOT NULL DEFAULT ''",
+	"route_vars jsonb",
+	"request_body jsonb",
+	"status integer NOT NULL DEFAULT 0",
+	"latency_ms bigint NOT NULL DEFAULT 0",
+	"request_id varchar(128) NOT NULL DEFAULT ''",
+}
+
+// auditLog is audit_logs as migration 0004 creates it. Like the
+// baseline models, it must not change with models.AuditLog.
+type auditLog struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	ActorID       *uuid.UUID `gorm:"type:uuid;index:idx_audit_logs_actor_created,priority:1"`
+	ActorUsername string     `gorm:"size:50"`
+	Action        string     `gorm:"not null;size:50;index:idx_audit_logs_action"`
+	TargetType    string     `gorm:"not null;size:20;index:idx_audit_logs_target,priority:1"`
+	TargetID      string     `gorm:"size:64;index:idx_audit_logs_target,priority:2"`
+	Before        []byte     `gorm:"type:jsonb"`
+	After         []byte     `gorm:"type:jsonb"`
+	Reason        string     `gorm:"size:1000"`
+	IPAddress     string     `gorm:"size:45"`
+	CreatedAt     time.Time  `gorm:"index:idx_audit_logs_actor_created,priority:2;index:idx_audit_logs_created_at"`
+}
+
+// hand is hands as migration 0005 creates it
+type hand struct {
+	ID             uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	GameID         uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_hands_game_hand,priority:1"`
+	HandNumber     int       `gorm:"not null;uniqueIndex:idx_hands_game_hand,priority:2"`
+	TableName      string    `gorm:"size:100"`
+	DealerPosition int
+	PlayersDealtIn int
+	SmallBlind     int64
+	BigBlind       int64
+	Board          string `gorm:"size:14"`
+	BoardSuited    int    `gorm:"not null;default:0;index:idx_hands_board_suited"`
+	Pot            int64
+	Rake           int64  `gorm:"not null;default:0"`
+	Winners        string `gorm:"type:text"`
+	StartedAt      time.Time
+	FinishedAt     time.Time
+	CreatedAt      time.Time
+}
+
+// club and clubMember are clubs and club_members as migration 0006 creates
+// them
+type club struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Name        string    `gorm:"not null;size:100"`
+	Description string    `gorm:"size:500"`
+	OwnerID     uuid.UUID `gorm:"type:uuid;not null;index:idx_clubs_owner_id"`
+	JoinCode    string    `gorm:"not null;size:16;uniqueIndex:idx_clubs_join_code"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index:idx_clubs_deleted_at"`
+}
+
+type clubMember struct {
+	ID       uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	ClubID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_club_members_club_user,priority:1"`
+	UserID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_club_members_club_user,priority:2;index:idx_club_members_user_id"`
+	Role     string    `gorm:"not null;size:10;default:'member'"`
+	JoinedAt time.Time
+}
@@ -0,0 +1,168 @@
+package database
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/primoPoker/server/internal/config"
+	"github.com/primoPoker/server/internal/metrics"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/pagination"
+	"github.com/primoPoker/server/internal/repository"
+	"github.com/primoPoker/server/internal/testutil"
+)
+
+// queryLog records which handle served each statement
+type queryLog struct {
+	mu     sync.Mutex
+	served map[string][]string
+}
+
+func newQueryLog() *queryLog {
+	return &queryLog{served: make(map[string][]string)}
+}
+
+func (l *queryLog) record(handle string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.served[handle] = append(l.served[handle], db.Statement.SQL.String())
+	}
+}
+
+func (l *queryLog) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.served = make(map[string][]string)
+}
+
+func (l *queryLog) statements(handle string) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.served[handle]
+}
+
+// recordingDialector is an in-memory SQLite database that logs every
+// statement it serves under its handle's name
+type recordingDialector struct {
+	gorm.Dialector
+	handle string
+	log    *queryLog
+}
+
+func (d recordingDialector) Initialize(db *gorm.DB) error {
+	if err := d.Dialector.Initialize(db); err != nil {
+		return err
+	}
+
+	record := d.log.record(d.handle)
+	callbacks := db.Callback()
+	if err := callbacks.Create().After("gorm:create").Register("test:record", record); err != nil {
+		return err
+	}
+	if err := callbacks.Query().After("gorm:query").Register("test:record", record); err != nil {
+		return err
+	}
+	if err := callbacks.Update().After("gorm:update").Register("test:record", record); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().After("gorm:delete").Register("test:record", record); err != nil {
+		return err
+	}
+	if err := callbacks.Raw().After("gorm:raw").Register("test:record", record); err != nil {
+		return err
+	}
+	return callbacks.Row().After("gorm:row").Register("test:record", record)
+}
+
+// openRecording opens a recording database with the given tables
+func openRecording(t *testing.T, handle string, log *queryLog, tables ...interface{}) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(recordingDialector{Dialector: sqlite.Open(":memory:"), handle: handle, log: log}, &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	testutil.Portable(t, db, tables...)
+	require.NoError(t, db.AutoMigrate(tables...))
+	return db
+}
+
+func TestReaderFallsBackToPrimary(t *testing.T) {
+	primary := testutil.NewDB(t)
+
+	db := NewReplicatedDB(primary, nil)
+	assert.Same(t, primary, db.Writer())
+	assert.Same(t, primary, db.Reader())
+
+	replica := testutil.NewDB(t)
+	db = NewReplicatedDB(primary, replica)
+	assert.Same(t, primary, db.Writer())
+	assert.Same(t, replica, db.Reader())
+}
+
+func TestReplicaRouting(t *testing.T) {
+	tables := []interface{}{
+		&models.User{}, &models.Game{}, &models.GameParticipation{},
+		&models.HandHistory{}, &models.PlayerStatAggregate{}, &models.LeaderboardEntry{},
+	}
+	log := newQueryLog()
+	db := NewReplicatedDB(openRecording(t, "primary", log, tables...), openRecording(t, "replica", log, tables...))
+
+	// The same user on both, as replication would leave them
+	user := &models.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com", PasswordHash: "hash"}
+	require.NoError(t, db.Writer().Create(user).Error)
+	require.NoError(t, db.Reader().Create(user).Error)
+
+	// Wired as the server wires them
+	readUsers := repository.NewUserRepository(db.Reader())
+	readHands := repository.NewHandHistoryRepository(db.Reader())
+	metricsService := metrics.NewService(readHands, repository.NewPlayerStatRepository(db.Reader()), readUsers, config.MetricsConfig{})
+	leaderboardRepo := repository.NewLeaderboardRepository(db.Writer()).WithReader(db.Reader())
+
+	t.Run("reads go to the replica", func(t *testing.T) {
+		log.reset()
+
+		_, err := metricsService.GetPlayerMetrics(user.ID, nil, metrics.Filter{})
+		require.NoError(t, err)
+
+		day, err := leaderboardRepo.LatestLeaderboardDay()
+		require.NoError(t, err)
+		_, err = leaderboardRepo.GetLeaderboard(day, models.LeaderboardMetrics[0], pagination.PageRequest{Limit: 10})
+		require.NoError(t, err)
+
+		far := time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+		require.NoError(t, readHands.StreamUserHands(user.ID, time.Time{}, far, 100, func([]models.HandHistory) error { return nil }))
+		require.NoError(t, readUsers.ExportAccount(user.ID, 100,
+			func(*models.User, []models.GameParticipation) error { return nil },
+			func([]models.HandHistory) error { return nil }))
+
+		assert.NotEmpty(t, log.statements("replica"))
+		assert.Empty(t, log.statements("primary"), "no read reaches the primary")
+	})
+
+	t.Run("writes stay on the primary", func(t *testing.T) {
+		log.reset()
+
+		_, err := repository.NewUserRepository(db.Writer()).CreditChips(user.ID, 100)
+		require.NoError(t, err)
+		require.NoError(t, leaderboardRepo.Materialize(time.Now().Truncate(24*time.Hour), repository.LeaderboardOptions{MinHands: 1}))
+
+		assert.NotEmpty(t, log.statements("primary"))
+		assert.Empty(t, log.statements("replica"), "no write reaches the replica")
+	})
+}
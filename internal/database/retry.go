@@ -0,0 +1,80 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Backoff between attempts to reach a database that is not ready
+const (
+	DefaultRetryInitialDelay = 500 * time.Millisecond
+	DefaultRetryMaxDelay     = 8 * time.Second
+)
+
+// RetryPolicy is how long to keep trying an operation on a database that
+// may not be accepting connections yet, as happens when a server starts
+// before the Cloud SQL proxy beside it. The delay between attempts doubles
+// from InitialDelay up to MaxDelay, and the last error is returned once
+// Timeout has passed. A zero Timeout tries once.
+type RetryPolicy struct {
+	Timeout      time.Duration
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+
+	sleep func(time.Duration)
+	now   func() time.Time
+}
+
+// Do runs fn until it succeeds or the policy's timeout passes, logging
+// each failed attempt. operation names what fn does in logs and errors.
+func (p RetryPolicy) Do(operation string, fn func() error) error {
+	sleep, now := p.sleep, p.now
+	if sleep == nil {
+		sleep = time.Sleep
+	}
+	if now == nil {
+		now = time.Now
+	}
+
+	delay := p.InitialDelay
+	if delay <= 0 {
+		delay = DefaultRetryInitialDelay
+	}
+	maxDelay := p.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = DefaultRetryMaxDelay
+	}
+
+	deadline := now().Add(p.Timeout)
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		remaining := deadline.Sub(now())
+		if remaining <= 0 {
+			if attempt == 1 {
+				return err
+			}
+			return fmt.Errorf("failed to %s after %d attempts: %w", operation, attempt, err)
+		}
+		if delay > remaining {
+			delay = remaining
+		}
+
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"operation": operation,
+			"attempt":   attempt,
+			"retry_in":  delay.String(),
+		}).Warn("Database not ready, retrying")
+
+		sleep(delay)
+		delay *= 2
+		if delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/primoPoker/server/internal/testutil"
+)
+
+// fakeClock is a clock that only moves when slept on, recording each sleep
+type fakeClock struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) policy(timeout time.Duration) RetryPolicy {
+	return RetryPolicy{
+		Timeout:      timeout,
+		InitialDelay: time.Second,
+		MaxDelay:     4 * time.Second,
+		sleep: func(d time.Duration) {
+			c.sleeps = append(c.sleeps, d)
+			c.now = c.now.Add(d)
+		},
+		now: func() time.Time { return c.now },
+	}
+}
+
+// flakyDialer fails until it has been dialled failures times
+type flakyDialer struct {
+	db       *gorm.DB
+	failures int
+	attempts int
+}
+
+func (d *flakyDialer) dial() (*gorm.DB, error) {
+	d.attempts++
+	if d.attempts <= d.failures {
+		return nil, errors.New("dial tcp 127.0.0.1:5432: connect: connection refused")
+	}
+	return d.db, nil
+}
+
+func TestConnectRetriesWithBackoff(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	dialer := &flakyDialer{db: testutil.NewDB(t), failures: 5}
+
+	db, err := connect(clock.policy(time.Minute), "connect to database", dialer.dial)
+	require.NoError(t, err)
+	assert.Same(t, dialer.db, db)
+	assert.Equal(t, 6, dialer.attempts)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second, 4 * time.Second}, clock.sleeps)
+}
+
+func TestConnectGivesUpAtTimeout(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	dialer := &flakyDialer{failures: 100}
+
+	_, err := connect(clock.policy(10*time.Second), "connect to database", dialer.dial)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "connection refused")
+	assert.Contains(t, err.Error(), "after 5 attempts")
+
+	// The last wait is cut short so the timeout is never overrun
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 3 * time.Second}, clock.sleeps)
+}
+
+func TestConnectWithoutTimeoutTriesOnce(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	dialer := &flakyDialer{failures: 1}
+
+	_, err := connect(clock.policy(0), "connect to database", dialer.dial)
+	require.Error(t, err)
+	assert.Equal(t, 1, dialer.attempts)
+	assert.Empty(t, clock.sleeps)
+}
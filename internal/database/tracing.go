@@ -0,0 +1,14 @@
+package database
+
+import (
+	"github.com/uptrace/opentelemetry-go-extra/otelgorm"
+	"gorm.io/gorm"
+)
+
+// Trace records each of db's queries as a span, part of the trace in the
+// query's context, so the queries of a request made with its context
+// appear in its trace. Query arguments are left out of the spans: they
+// may hold password hashes and tokens.
+func Trace(db *gorm.DB) error {
+	return db.Use(otelgorm.NewPlugin(otelgorm.WithoutQueryVariables(), otelgorm.WithoutMetrics()))
+}
@@ -0,0 +1,55 @@
+package database
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"github.com/primoPoker/server/internal/repository"
+)
+
+// UnitOfWork hands out repositories that all write through one
+// transaction, so an operation spanning several of them is stored whole or
+// not at all. It is only valid inside the function it is passed to.
+type UnitOfWork struct {
+	tx *gorm.DB
+}
+
+// UnitOfWork runs fn in a transaction on the primary, its queries part of
+// ctx's trace. The transaction commits if fn returns nil and rolls back if
+// it returns an error or panics; a panic is raised again once rolled back.
+func (db *DB) UnitOfWork(ctx context.Context, fn func(uow *UnitOfWork) error) error {
+	return db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&UnitOfWork{tx: tx})
+	})
+}
+
+// UnitOfWork runs fn in a savepoint of the enclosing transaction. An error
+// or panic in fn rolls back only what fn wrote; the caller decides whether
+// the enclosing transaction goes on.
+func (u *UnitOfWork) UnitOfWork(fn func(uow *UnitOfWork) error) error {
+	return u.tx.Transaction(func(tx *gorm.DB) error {
+		return fn(&UnitOfWork{tx: tx})
+	})
+}
+
+// Tx is the transaction itself, for the *WithTransaction methods of
+// repositories the unit of work does not hand out
+func (u *UnitOfWork) Tx() *gorm.DB {
+	return u.tx
+}
+
+// Users returns the user repository in the transaction
+func (u *UnitOfWork) Users() *repository.UserRepository {
+	return repository.NewUserRepository(u.tx)
+}
+
+// Games returns the game repository in the transaction
+func (u *UnitOfWork) Games() *repository.GameRepository {
+	return repository.NewGameRepository(u.tx)
+}
+
+// Hands returns the hand history repository in the transaction
+func (u *UnitOfWork) Hands() *repository.HandHistoryRepository {
+	return repository.NewHandHistoryRepository(u.tx)
+}
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"context"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/testutil"
+)
+
+// createTable stores a user, a game and the user's seat at it through uow
+func createTable(t *testing.T, uow *UnitOfWork) (models.User, models.Game) {
+	t.Helper()
+
+	user := models.User{ID: uuid.New(), Username: "u" + uuid.NewString()[:8], PasswordHash: "x"}
+	user.Email = user.Username + "@example.com"
+	require.NoError(t, uow.Users().Create(&user))
+	table := models.Game{ID: uuid.New(), Name: "Table", MaxPlayers: 6, MinPlayers: 2, SmallBlind: 10, BigBlind: 20, BuyIn: 2000}
+	require.NoError(t, uow.Games().Create(&table))
+	require.NoError(t, uow.Games().EnsureParticipation(&models.GameParticipation{
+		GameID: table.ID, UserID: user.ID, BuyInAmount: 2000, CurrentChips: 2000, IsActive: true,
+	}))
+	return user, table
+}
+
+func TestUnitOfWork(t *testing.T) {
+	gormDB := testutil.NewDB(t, &models.User{}, &models.Game{}, &models.GameParticipation{})
+	db := NewReplicatedDB(gormDB, nil)
+	rows := func() [3]int64 {
+		var counts [3]int64
+		for i, model := range []interface{}{&models.User{}, &models.Game{}, &models.GameParticipation{}} {
+			require.NoError(t, gormDB.Model(model).Count(&counts[i]).Error)
+		}
+		return counts
+	}
+	failed := errors.New("failed")
+
+	t.Run("commits", func(t *testing.T) {
+		var user models.User
+		require.NoError(t, db.UnitOfWork(context.Background(), func(uow *UnitOfWork) error {
+			user, _ = createTable(t, uow)
+			return nil
+		}))
+		var stored models.User
+		require.NoError(t, gormDB.First(&stored, "id = ?", user.ID).Error)
+		assert.Equal(t, user.Username, stored.Username)
+	})
+	before := rows()
+
+	t.Run("rolls back on error", func(t *testing.T) {
+		err := db.UnitOfWork(context.Background(), func(uow *UnitOfWork) error {
+			createTable(t, uow)
+			return failed
+		})
+		assert.ErrorIs(t, err, failed)
+		assert.Equal(t, before, rows(), "no partial rows are left")
+	})
+
+	t.Run("rolls back on panic", func(t *testing.T) {
+		assert.Panics(t, func() {
+			_ = db.UnitOfWork(context.Background(), func(uow *UnitOfWork) error {
+				createTable(t, uow)
+				panic("settling")
+			})
+		})
+		assert.Equal(t, before, rows(), "no partial rows are left")
+	})
+
+	t.Run("nested rolls back to its savepoint", func(t *testing.T) {
+		var kept models.User
+		require.NoError(t, db.UnitOfWork(context.Background(), func(uow *UnitOfWork) error {
+			kept, _ = createTable(t, uow)
+			err := uow.UnitOfWork(func(nested *UnitOfWork) error {
+				createTable(t, nested)
+				return failed
+			})
+			assert.ErrorIs(t, err, failed)
+			return nil
+		}))
+		after := rows()
+		for i := range after {
+			assert.Equal(t, before[i]+1, after[i], "only the outer unit's rows are kept")
+		}
+		var user models.User
+		assert.NoError(t, gormDB.First(&user, "id = ?", kept.ID).Error)
+	})
+
+	t.Run("nested error fails the whole unit", func(t *testing.T) {
+		before := rows()
+		err := db.UnitOfWork(context.Background(), func(uow *UnitOfWork) error {
+			createTable(t, uow)
+			return uow.UnitOfWork(func(nested *UnitOfWork) error {
+				createTable(t, nested)
+				return failed
+			})
+		})
+		assert.ErrorIs(t, err, failed)
+		assert.Equal(t, before, rows())
+	})
+
+	t.Run("repositories share the transaction", func(t *testing.T) {
+		require.NoError(t, db.UnitOfWork(context.Background(), func(uow *UnitOfWork) error {
+			_, table := createTable(t, uow)
+			var seats int64
+			require.NoError(t, uow.Tx().Model(&models.GameParticipation{}).Where("game_id = ?", table.ID).Count(&seats).Error)
+			assert.Equal(t, int64(1), seats, "each repository sees the others' writes")
+			return nil
+		}))
+	})
+}
@@ -0,0 +1,297 @@
+// Package flags rolls risky features out gradually. Flags are declared in
+// code with a default, configured by the FEATURE_FLAGS setting, and
+// overridden by admins while the server runs. A flag can be on for
+// everyone, for a percentage of users, or for a list of them.
+package flags
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Flags of features being rolled out
+const (
+	RunItTwice     = "run_it_twice"
+	WSDeltaUpdates = "ws_delta_updates"
+	NewEvaluator   = "new_evaluator"
+)
+
+// Definitions are the flags the server knows, with their defaults
+var Definitions = []Definition{
+	{Name: RunItTwice, Description: "Let all-in players agree to run the board twice"},
+	{Name: WSDeltaUpdates, Description: "Send table updates over WebSocket as changes rather than whole states"},
+	{Name: NewEvaluator, Description: "Evaluate hands with the new evaluator"},
+}
+
+// Where a flag's state comes from, in increasing precedence
+const (
+	SourceDefault = "default"
+	SourceConfig  = "config"
+	SourceAdmin   = "admin"
+)
+
+// ErrUnknownFlag is returned for a flag that is not defined
+var ErrUnknownFlag = errors.New("unknown feature flag")
+
+// Definition is a flag declared in code
+type Definition struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Default     bool   `json:"default"`
+}
+
+// State is how a flag is set. It is on for a user if it is enabled, if the
+// user is listed, or if the user falls in its percentage; a user's bucket
+// is fixed per flag, so raising the percentage only adds users.
+type State struct {
+	Enabled    bool     `json:"enabled"`
+	Percentage int      `json:"percentage,omitempty"`
+	Users      []string `json:"users,omitempty"`
+}
+
+// Status is a flag's definition and the state in effect
+type Status struct {
+	Definition
+	State
+	Source string `json:"source"`
+}
+
+// Flags holds the state of every defined flag. Admin overrides take
+// precedence over the configuration, which takes precedence over the
+// defaults; overrides are kept in memory, per instance.
+type Flags struct {
+	mu          sync.RWMutex
+	definitions map[string]Definition
+	configured  map[string]State
+	overrides   map[string]State
+}
+
+// New creates flags with the given definitions, all at their defaults
+func New(definitions ...Definition) *Flags {
+	f := &Flags{
+		definitions: make(map[string]Definition, len(definitions)),
+		configured:  make(map[string]State),
+		overrides:   make(map[string]State),
+	}
+	for _, definition := range definitions {
+		f.definitions[definition.Name] = definition
+	}
+	return f
+}
+
+// Enabled reports whether a flag is on for the user making the request,
+// taken from the context as the auth middleware stores it. Without a user
+// only flags enabled for everyone are on. A nil Flags has every flag off.
+func (f *Flags) Enabled(ctx context.Context, name string) bool {
+	userID, _ := ctx.Value("user_id").(string)
+	return f.EnabledFor(name, userID)
+}
+
+// EnabledFor reports whether a flag is on for a user, for callers such as
+// the game engine that have a user but no request
+func (f *Flags) EnabledFor(name, userID string) bool {
+	if f == nil {
+		return false
+	}
+	status, err := f.Status(name)
+	if err != nil {
+		return false
+	}
+	return status.State.enabledFor(name, userID)
+}
+
+func (s State) enabledFor(name, userID string) bool {
+	if s.Enabled {
+		return true
+	}
+	if userID == "" {
+		return false
+	}
+	for _, user := range s.Users {
+		if user == userID {
+			return true
+		}
+	}
+	return s.Percentage > 0 && bucket(name, userID) < s.Percentage
+}
+
+// bucket places a user in one of 100 buckets for a flag. Hashing the flag
+// name in means each flag rolls out to a different set of users.
+func bucket(name, userID string) int {
+	hash := fnv.New32a()
+	hash.Write([]byte(name))
+	hash.Write([]byte{0})
+	hash.Write([]byte(userID))
+	return int(hash.Sum32() % 100)
+}
+
+// Status returns a flag's state and where it came from
+func (f *Flags) Status(name string) (Status, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.status(name)
+}
+
+// status returns a flag's state (assumes lock is held)
+func (f *Flags) status(name string) (Status, error) {
+	definition, ok := f.definitions[name]
+	if !ok {
+		return Status{}, fmt.Errorf("%w: %s", ErrUnknownFlag, name)
+	}
+	if state, ok := f.overrides[name]; ok {
+		return Status{Definition: definition, State: state, Source: SourceAdmin}, nil
+	}
+	if state, ok := f.configured[name]; ok {
+		return Status{Definition: definition, State: state, Source: SourceConfig}, nil
+	}
+	return Status{Definition: definition, State: State{Enabled: definition.Default}, Source: SourceDefault}, nil
+}
+
+// List returns every flag's status, sorted by name
+func (f *Flags) List() []Status {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	statuses := make([]Status, 0, len(f.definitions))
+	for name := range f.definitions {
+		status, _ := f.status(name)
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// Set overrides a flag's state until it is cleared, returning its status
+// before
+func (f *Flags) Set(name string, state State) (Status, error) {
+	if state.Percentage < 0 || state.Percentage > 100 {
+		return Status{}, fmt.Errorf("percentage must be between 0 and 100")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	before, err := f.status(name)
+	if err != nil {
+		return Status{}, err
+	}
+	f.overrides[name] = state
+	logChange(before, Status{Definition: before.Definition, State: state, Source: SourceAdmin})
+	return before, nil
+}
+
+// Clear removes an admin override, returning a flag to its configured
+// state, and returns its status before
+func (f *Flags) Clear(name string) (Status, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	before, err := f.status(name)
+	if err != nil {
+		return Status{}, err
+	}
+	delete(f.overrides, name)
+	after, _ := f.status(name)
+	logChange(before, after)
+	return before, nil
+}
+
+// Configure replaces the configured states with those in specs, as read
+// from FEATURE_FLAGS. Nothing changes if any spec is invalid.
+func (f *Flags) Configure(specs []string) error {
+	configured, err := Parse(specs)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for name := range configured {
+		if _, ok := f.definitions[name]; !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownFlag, name)
+		}
+	}
+	before := make(map[string]Status, len(f.definitions))
+	for name := range f.definitions {
+		before[name], _ = f.status(name)
+	}
+	f.configured = configured
+	for name := range f.definitions {
+		if after, _ := f.status(name); fmt.Sprint(after) != fmt.Sprint(before[name]) {
+			logChange(before[name], after)
+		}
+	}
+	return nil
+}
+
+// Parse reads flag specs of the form name=on, name=off or name=25%; a bare
+// name is on
+func Parse(specs []string) (map[string]State, error) {
+	states := make(map[string]State, len(specs))
+	for _, spec := range specs {
+		name, value, found := strings.Cut(strings.TrimSpace(spec), "=")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if name == "" {
+			continue
+		}
+
+		switch {
+		case !found || value == "on" || value == "true":
+			states[name] = State{Enabled: true}
+		case value == "off" || value == "false":
+			states[name] = State{}
+		case strings.HasSuffix(value, "%"):
+			percentage, err := strconv.Atoi(strings.TrimSuffix(value, "%"))
+			if err != nil || percentage < 0 || percentage > 100 {
+				return nil, fmt.Errorf("feature flag %s: percentage must be 0%% to 100%%, not %q", name, value)
+			}
+			states[name] = State{Percentage: percentage}
+		default:
+			return nil, fmt.Errorf("feature flag %s must be on, off or a percentage, not %q", name, value)
+		}
+	}
+	return states, nil
+}
+
+// Validate checks that specs are well formed and name defined flags
+func Validate(specs []string) error {
+	states, err := Parse(specs)
+	if err != nil {
+		return err
+	}
+	for name := range states {
+		if !defined(name) {
+			return fmt.Errorf("%w: %s", ErrUnknownFlag, name)
+		}
+	}
+	return nil
+}
+
+// defined reports whether a flag is among the Definitions
+func defined(name string) bool {
+	for _, definition := range Definitions {
+		if definition.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// logChange records a change to a flag's state
+func logChange(before, after Status) {
+	logrus.WithFields(logrus.Fields{
+		"flag":   after.Name,
+		"before": before.State,
+		"after":  after.State,
+		"source": after.Source,
+	}).Info("Feature flag changed")
+}
@@ -0,0 +1,113 @@
+package flags
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestFlags() *Flags {
+	return New(
+		Definition{Name: "beta", Description: "A feature in beta"},
+		Definition{Name: "stable", Description: "A feature on by default", Default: true},
+	)
+}
+
+func TestPercentageBucketingIsDeterministic(t *testing.T) {
+	flags := newTestFlags()
+	_, err := flags.Set("beta", State{Percentage: 30})
+	require.NoError(t, err)
+
+	enabled := 0
+	for i := 0; i < 1000; i++ {
+		user := fmt.Sprintf("user-%d", i)
+		first := flags.EnabledFor("beta", user)
+		for repeat := 0; repeat < 3; repeat++ {
+			assert.Equal(t, first, flags.EnabledFor("beta", user), "a user stays in the same bucket")
+		}
+		if first {
+			enabled++
+		}
+	}
+	assert.InDelta(t, 300, enabled, 60, "roughly the percentage of users have the flag")
+
+	// Raising the percentage keeps everyone who already had the flag
+	var had []string
+	for i := 0; i < 1000; i++ {
+		if user := fmt.Sprintf("user-%d", i); flags.EnabledFor("beta", user) {
+			had = append(had, user)
+		}
+	}
+	_, err = flags.Set("beta", State{Percentage: 60})
+	require.NoError(t, err)
+	for _, user := range had {
+		assert.True(t, flags.EnabledFor("beta", user))
+	}
+}
+
+func TestBucketsDifferByFlag(t *testing.T) {
+	same := 0
+	for i := 0; i < 100; i++ {
+		user := fmt.Sprintf("user-%d", i)
+		if bucket("beta", user) == bucket("stable", user) {
+			same++
+		}
+	}
+	assert.Less(t, same, 20, "each flag rolls out to its own set of users")
+}
+
+func TestEnabledPrecedence(t *testing.T) {
+	flags := newTestFlags()
+	ctx := context.WithValue(context.Background(), "user_id", "alice")
+
+	assert.False(t, flags.Enabled(ctx, "beta"))
+	assert.True(t, flags.Enabled(ctx, "stable"), "flags start at their defaults")
+	assert.False(t, flags.Enabled(ctx, "missing"), "unknown flags are off")
+
+	require.NoError(t, flags.Configure([]string{"beta=on", "stable=off"}))
+	assert.True(t, flags.Enabled(ctx, "beta"))
+	assert.False(t, flags.Enabled(ctx, "stable"))
+
+	_, err := flags.Set("beta", State{Users: []string{"bob"}})
+	require.NoError(t, err)
+	assert.False(t, flags.Enabled(ctx, "beta"), "an admin override wins over the configuration")
+	assert.True(t, flags.EnabledFor("beta", "bob"), "listed users have the flag")
+	assert.False(t, flags.Enabled(context.Background(), "beta"), "without a user only flags on for everyone are on")
+
+	before, err := flags.Clear("beta")
+	require.NoError(t, err)
+	assert.Equal(t, SourceAdmin, before.Source)
+	status, _ := flags.Status("beta")
+	assert.Equal(t, SourceConfig, status.Source)
+	assert.True(t, flags.Enabled(ctx, "beta"), "clearing the override returns to the configuration")
+
+	var none *Flags
+	assert.False(t, none.Enabled(ctx, "beta"))
+}
+
+func TestConfigure(t *testing.T) {
+	flags := newTestFlags()
+	require.NoError(t, flags.Configure([]string{"beta=25%"}))
+	status, _ := flags.Status("beta")
+	assert.Equal(t, 25, status.Percentage)
+
+	assert.ErrorIs(t, flags.Configure([]string{"beta", "unknown=on"}), ErrUnknownFlag)
+	assert.Error(t, flags.Configure([]string{"beta=sometimes"}))
+	assert.Error(t, flags.Configure([]string{"beta=150%"}))
+	status, _ = flags.Status("beta")
+	assert.Equal(t, 25, status.Percentage, "invalid flags change nothing")
+
+	_, err := flags.Set("beta", State{Percentage: 101})
+	assert.Error(t, err)
+	_, err = flags.Set("unknown", State{Enabled: true})
+	assert.ErrorIs(t, err, ErrUnknownFlag)
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate(nil))
+	assert.NoError(t, Validate([]string{RunItTwice + "=10%", NewEvaluator}))
+	assert.ErrorIs(t, Validate([]string{"no_such_flag"}), ErrUnknownFlag)
+}
@@ -0,0 +1,303 @@
+package game
+
+import (
+	"context"
+	"time"
+)
+
+// TableConfigUpdate holds table settings an operator wants applied from the
+// next hand onwards; nil fields are left unchanged
+type TableConfigUpdate struct {
+	SmallBlind  *int64         `json:"small_blind,omitempty"`
+	BigBlind    *int64         `json:"big_blind,omitempty"`
+	TurnTimeout *time.Duration `json:"turn_timeout,omitempty"`
+}
+
+// CloseGame shuts a table down, cashing out every seated player, and
+// removes it from the manager. It returns each player's final stack. The
+// table deals no hand from then on, as Shutdown does, and the one being
+// played is left to finish and be paid until ctx is done; one still going
+// then is voided with every bet returned.
+func (m *Manager) CloseGame(ctx context.Context, gameID string) (map[string]int64, error) {
+	game, err := m.GetGame(gameID)
+	if err != nil {
+		return nil, err
+	}
+
+	game.pause()
+	waitUntil(ctx, func() bool {
+		return !game.playing()
+	})
+
+	m.mu.Lock()
+	if m.games[gameID] != game {
+		m.mu.Unlock()
+		return nil, ErrGameNotFound
+	}
+	stacks := m.closeGame(game)
+	m.removeGame(gameID)
+	m.mu.Unlock()
+
+	m.cashOut(stacks)
+	if game.TemplateID != "" {
+		m.ensureTemplateTables()
+	}
+
+	return stacks, nil
+}
+
+// ForceStartGame deals a hand at a table that is waiting for players but
+// already has enough of them seated
+func (m *Manager) ForceStartGame(gameID string) error {
+	if m.stopping() {
+		return ErrShuttingDown
+	}
+	game, err := m.GetGame(gameID)
+	if err != nil {
+		return err
+	}
+
+	return game.ForceStart()
+}
+
+// KickPlayer removes a player from a table and returns the stack they were cashed out with
+func (m *Manager) KickPlayer(gameID, playerID string) (int64, error) {
+	return m.kickPlayer(gameID, playerID, false)
+}
+
+// KickIdlePlayer removes a player who is not at their seat, such as one
+// whose seat has been held since a restart, as KickPlayer does. A player
+// who is back is left seated with ErrPlayerNotIdle.
+func (m *Manager) KickIdlePlayer(gameID, playerID string) (int64, error) {
+	return m.kickPlayer(gameID, playerID, true)
+}
+
+// kickPlayer removes a player from a table, if idleOnly only when they are
+// not at their seat
+func (m *Manager) kickPlayer(gameID, playerID string, idleOnly bool) (int64, error) {
+	m.mu.Lock()
+	game, exists := m.games[gameID]
+	if !exists {
+		m.mu.Unlock()
+		return 0, ErrGameNotFound
+	}
+
+	stack, unseated, err := game.kick(playerID, idleOnly)
+	if err != nil {
+		m.mu.Unlock()
+		return 0, err
+	}
+	// The table stays on the player's list until their seat is freed
+	if unseated {
+		m.detach(playerID, gameID)
+	}
+	m.mu.Unlock()
+
+	m.cashOut(map[string]int64{playerID: stack})
+	return stack, nil
+}
+
+// UpdateGameConfig schedules new table settings for the next hand
+func (m *Manager) UpdateGameConfig(gameID string, update TableConfigUpdate) error {
+	game, err := m.GetGame(gameID)
+	if err != nil {
+		return err
+	}
+
+	return game.UpdateConfig(update)
+}
+
+// Close ends the game and returns every player's stack, less any chips won
+// from bots, which are not cashed out. A hand that is still being played
+// cannot be finished without the players' decisions, so it is voided and
+// each player's contribution to the pot is returned; CloseGame gives it
+// time to finish first.
+func (g *Game) Close() map[string]int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.voidHand()
+
+	// Store the players' final stacks while they are still seated
+	g.closed = true
+	g.saveTable()
+	g.tableEvent(EventGameFinished)
+
+	// Bots' chips are the table's, so they are not cashed out
+	stacks := make(map[string]int64, len(g.Players))
+	for playerID, player := range g.Players {
+		if player.bot == nil {
+			stacks[playerID] = player.cashable()
+		}
+		player.Connected = false
+		player.IsActive = false
+	}
+
+	// Moving out of Showdown also keeps a next hand whose timer has
+	// already fired from being dealt
+	if g.nextHand != nil {
+		g.nextHand.Stop()
+		g.nextHand = nil
+	}
+	g.setPhase(GameOver)
+	g.LastActivity = g.clock.Now()
+	g.stateChanged()
+
+	return stacks
+}
+
+// idleSince reports whether nobody is at the table or holding a seat there,
+// and nothing has happened there since cutoff
+func (g *Game) idleSince(cutoff time.Time) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	if !g.LastActivity.Before(cutoff) {
+		return false
+	}
+	for _, player := range g.Players {
+		if player.bot == nil && (player.Connected || player.held) {
+			return false
+		}
+	}
+	return true
+}
+
+// ForceStart starts a new hand at a table stuck waiting for players
+func (g *Game) ForceStart() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.Phase != WaitingForPlayers && g.Phase != GameOver {
+		return ErrHandInProgress
+	}
+	if g.paused {
+		return ErrShuttingDown
+	}
+
+	ready := g.readyPlayers()
+	if ready < g.MinPlayers || ready < 2 {
+		return ErrNotEnoughPlayers
+	}
+
+	g.LastActivity = g.clock.Now()
+	g.startNewHand()
+	return nil
+}
+
+// Kick folds a player out of any hand in progress and cashes out their
+// stack. The seat is freed once the hand finishes.
+func (g *Game) Kick(playerID string) (int64, error) {
+	stack, _, err := g.kick(playerID, false)
+	return stack, err
+}
+
+// kick kicks a player, if idleOnly only when they are not connected. It
+// reports whether their seat was freed at once, between hands.
+func (g *Game) kick(playerID string, idleOnly bool) (int64, bool, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	player, exists := g.Players[playerID]
+	if !exists {
+		return 0, false, ErrPlayerNotInGame
+	}
+	if idleOnly && player.Connected {
+		return 0, false, ErrPlayerNotIdle
+	}
+
+	if g.handInProgress() && !player.HasFolded {
+		if g.getCurrentPlayerID() == playerID && player.CanAct() {
+			if err := g.processAction(playerID, Fold, 0); err != nil {
+				return 0, false, err
+			}
+		} else {
+			player.Fold()
+			if len(g.getActivePlayers()) <= 1 {
+				g.endHand()
+			} else if g.isBettingRoundComplete() {
+				g.advancePhase()
+			}
+		}
+	}
+
+	stack := player.cashable()
+	player.ChipCount = 0
+	player.botChips = 0
+	player.Connected = false
+	player.IsActive = false
+	player.held = false
+
+	// Players with no chips who are disconnected are dropped between hands,
+	// and as the hand ends when one is being played
+	unseated := !g.handInProgress()
+	if unseated {
+		g.removeEliminatedPlayers()
+	} else {
+		player.leaving = true
+	}
+
+	g.LastActivity = g.clock.Now()
+	g.stateChanged()
+	return stack, unseated, nil
+}
+
+// UpdateConfig validates new table settings and queues them for the next hand
+func (g *Game) UpdateConfig(update TableConfigUpdate) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	pending := TableConfigUpdate{}
+	if g.pendingConfig != nil {
+		pending = *g.pendingConfig
+	}
+	if update.SmallBlind != nil {
+		pending.SmallBlind = update.SmallBlind
+	}
+	if update.BigBlind != nil {
+		pending.BigBlind = update.BigBlind
+	}
+	if update.TurnTimeout != nil {
+		pending.TurnTimeout = update.TurnTimeout
+	}
+
+	smallBlind, bigBlind := g.SmallBlind, g.BigBlind
+	if pending.SmallBlind != nil {
+		smallBlind = *pending.SmallBlind
+	}
+	if pending.BigBlind != nil {
+		bigBlind = *pending.BigBlind
+	}
+	if smallBlind <= 0 || bigBlind < smallBlind {
+		return ErrInvalidTableConfig
+	}
+	if pending.TurnTimeout != nil && *pending.TurnTimeout <= 0 {
+		return ErrInvalidTableConfig
+	}
+
+	g.pendingConfig = &pending
+	return nil
+}
+
+// applyPendingConfig applies queued table settings (assumes lock is held)
+func (g *Game) applyPendingConfig() {
+	if g.pendingConfig == nil {
+		return
+	}
+
+	if g.pendingConfig.SmallBlind != nil {
+		g.SmallBlind = *g.pendingConfig.SmallBlind
+	}
+	if g.pendingConfig.BigBlind != nil {
+		g.BigBlind = *g.pendingConfig.BigBlind
+	}
+	if g.pendingConfig.TurnTimeout != nil {
+		g.TurnTimeout = *g.pendingConfig.TurnTimeout
+	}
+	g.pendingConfig = nil
+}
+
+// handInProgress checks if a hand is being played (assumes lock is held)
+func (g *Game) handInProgress() bool {
+	return g.Phase >= PreFlop && g.Phase <= River
+}
@@ -0,0 +1,46 @@
+package game
+
+import "context"
+
+// Bank holds players' chips while they are away from the tables. A player's
+// buy-in is taken from it as they sit down and their stack is paid back into
+// it as they are cashed out.
+type Bank interface {
+	// BuyIn takes a seated player's buy-in from their balance, failing if
+	// they cannot cover it. recordID is the ID the table is stored under,
+	// empty before it is stored, so a bank may store the seat along with
+	// the buy-in. ctx carries the trace of the join. It is called with the
+	// seat held but neither the manager nor the table locked.
+	BuyIn(ctx context.Context, recordID string, seat SeatState) error
+
+	// CashOut pays amount back into a player's balance. It is called with
+	// neither the manager nor the table locked, and failures are the bank's
+	// to report.
+	CashOut(playerID string, amount int64)
+}
+
+// SetBank registers the bank buy-ins are taken from and stacks cashed out
+// to. Without one, chips are brought to and taken from tables freely.
+func (m *Manager) SetBank(bank Bank) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.bank = bank
+}
+
+// cashOut pays players' stacks back to the bank. It is called once the
+// manager is unlocked, so a slow bank holds up no other table.
+func (m *Manager) cashOut(stacks map[string]int64) {
+	m.mu.RLock()
+	bank := m.bank
+	m.mu.RUnlock()
+
+	if bank == nil {
+		return
+	}
+	for playerID, stack := range stacks {
+		if stack > 0 {
+			bank.CashOut(playerID, stack)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package game
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/primoPoker/server/pkg/poker"
+)
+
+// defaultBotDelay is the longest a bot thinks before acting, so its play
+// reads as a player's rather than an instant reply
+const defaultBotDelay = 2 * time.Second
+
+// botStrengthBoards is how many boards a bot deals out to judge its hand
+const botStrengthBoards = 300
+
+// Bot plays a seat the table fills to seed it until players sit down. It
+// is asked for its decision with the table unlocked, and the action it
+// returns is taken as any player's would be: one the table refuses is
+// replaced by a check, or a fold when there is a bet to face.
+type Bot interface {
+	Decide(turn BotTurn) (PlayerAction, int64)
+}
+
+// BotTurn is what a bot knows when it is its turn to act
+type BotTurn struct {
+	HoleCards []poker.Card
+	Board     []poker.Card
+	Phase     GamePhase
+	Pot       int64
+	ToCall    int64 // Chips it takes to stay in the hand
+	MinRaise  int64 // The least a raise may add on top of the call
+	Chips     int64 // The bot's stack
+	BigBlind  int64
+	Opponents int // Players still in the hand besides the bot
+}
+
+// Strength estimates the bot's chance of winning the hand from here: its
+// equity against any two cards, once for each opponent still in it
+func (t BotTurn) Strength() float64 {
+	result, err := poker.HandVsRange(t.HoleCards, anyTwoCards, t.Board, nil, poker.EquityOptions{
+		Iterations: botStrengthBoards,
+		Workers:    1,
+	})
+	if err != nil {
+		return 0
+	}
+	return math.Pow(result.Players[0].Equity/100, float64(max(t.Opponents, 1)))
+}
+
+// raise raises by about half the pot, never less than the minimum. Without
+// the chips for even that, a strong hand goes all in and any other calls.
+func (t BotTurn) raise(strong bool) (PlayerAction, int64) {
+	by := max(t.MinRaise, (t.Pot+t.ToCall)/2)
+	switch {
+	case t.ToCall+by < t.Chips:
+		return Raise, by
+	case strong:
+		return AllIn, 0
+	case t.ToCall == 0:
+		return Check, 0
+	}
+	return Call, 0
+}
+
+// anyTwoCards is the range of every starting hand, each as likely
+var anyTwoCards = func() *poker.Range {
+	r := poker.NewRange()
+	for high := poker.Two; high <= poker.Ace; high++ {
+		for low := poker.Two; low <= high; low++ {
+			r.Add(poker.StartingHand{High: high, Low: low}, 1)
+			if low < high {
+				r.Add(poker.StartingHand{High: high, Low: low, Suited: true}, 1)
+			}
+		}
+	}
+	return r
+}()
+
+// TightPassive plays few hands and never raises: it checks when it can,
+// and calls only with a strong hand, or a fair one for no more than a big
+// blind
+type TightPassive struct{}
+
+func (TightPassive) Decide(turn BotTurn) (PlayerAction, int64) {
+	if turn.ToCall == 0 {
+		return Check, 0
+	}
+	strength := turn.Strength()
+	if strength >= 0.6 || strength >= 0.45 && turn.ToCall <= turn.BigBlind {
+		return Call, 0
+	}
+	return Fold, 0
+}
+
+// LooseAggressive plays many hands and bets them hard: it raises a good
+// hand, calls a fair one and only gives up a weak one facing a bet
+type LooseAggressive struct{}
+
+func (LooseAggressive) Decide(turn BotTurn) (PlayerAction, int64) {
+	strength := turn.Strength()
+	switch {
+	case strength >= 0.55:
+		return turn.raise(strength >= 0.75)
+	case turn.ToCall == 0:
+		return Check, 0
+	case strength >= 0.3:
+		return Call, 0
+	}
+	return Fold, 0
+}
+
+// defaultBots are the strategies bots take turns at when the table sets none
+var defaultBots = []Bot{TightPassive{}, LooseAggressive{}}
+
+// WithBots keeps bots seated at the table so it is never empty: count of
+// them while nobody is seated, one fewer for each player who sits down.
+// Bots play the strategies given in turn, the built-in ones if none are.
+func WithBots(count int, strategies ...Bot) GameOption {
+	return func(config *GameConfig) {
+		config.Bots = count
+		config.BotStrategies = strategies
+	}
+}
+
+// IsBot reports whether a bot plays the seat
+func (p *Player) IsBot() bool {
+	return p.bot != nil
+}
+
+// cashable returns the part of a player's stack they are cashed out with:
+// all of it but the chips won from bots, and none of a bot's
+func (p *Player) cashable() int64 {
+	if p.bot != nil {
+		return 0
+	}
+	return p.ChipCount - min(p.botChips, p.ChipCount)
+}
+
+// settleBotChips tracks, once a hand's pots are paid, how much of each
+// player's stack was won from bots. What the losers put into the pots is
+// pooled, a player giving up the chips they had won from bots before their
+// own, and each winner's share of the pool carries the part of it that came
+// from bots. Chips won from bots this way stay theirs to play with, at this
+// table or another they are moved to, but are never paid out (assumes lock
+// is held).
+func (g *Game) settleBotChips() {
+	var lost, fromBots int64
+	for _, player := range g.Players {
+		loss := player.TotalBet - g.hand.winnings[player.ID]
+		if loss <= 0 {
+			continue
+		}
+		lost += loss
+		if player.bot != nil {
+			fromBots += loss
+			continue
+		}
+		given := min(player.botChips, loss)
+		player.botChips -= given
+		fromBots += given
+	}
+	if fromBots == 0 {
+		return
+	}
+
+	for _, player := range g.Players {
+		won := g.hand.winnings[player.ID] - player.TotalBet
+		if won > 0 && player.bot == nil {
+			// Rounded up, so not a chip of the bots' is cashed out
+			player.botChips += (won*fromBots + lost - 1) / lost
+		}
+	}
+}
+
+// seedBots seats the table's bots as it opens
+func (g *Game) seedBots() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.botSeats > 0 {
+		g.seatBots()
+		g.stateChanged()
+	}
+}
+
+// setBotSeats changes how many bots the table keeps seated, from the next
+// time there is no hand in progress
+func (g *Game) setBotSeats(count int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.botSeats == count {
+		return
+	}
+	g.botSeats = count
+	g.seatBots()
+	g.stateChanged()
+}
+
+// seatBots seats or unseats bots between hands, so that until botSeats
+// players are seated, bots make up the difference, always leaving a seat
+// for a player to take. Bots that have lost their stack make way for new
+// ones. Their chips are the table's own, neither taken from nor paid to
+// anyone, so the chips players win from them are kept apart from those
+// cashed out; see settleBotChips (assumes lock is held).
+func (g *Game) seatBots() {
+	if g.handInProgress() || g.closed || g.paused {
+		return
+	}
+
+	players := 0
+	var bots []*Player
+	for _, playerID := range g.PlayerOrder {
+		player := g.Players[playerID]
+		switch {
+		case player.bot == nil:
+			if player.Connected || player.held {
+				players++
+			}
+		case player.ChipCount > 0:
+			bots = append(bots, player)
+		default:
+			player.Connected = false
+		}
+	}
+
+	seats := g.botSeats
+	if seats >= g.MaxPlayers {
+		seats = g.MaxPlayers - 1
+	}
+	wanted := max(seats-players, 0)
+	for ; len(bots) > wanted; bots = bots[:len(bots)-1] {
+		leaving := bots[len(bots)-1]
+		leaving.ChipCount = 0
+		leaving.Connected = false
+		leaving.IsActive = false
+	}
+	g.removeEliminatedPlayers()
+
+	for len(bots) < wanted {
+		seat := g.freeSeat()
+		if seat == -1 {
+			break
+		}
+		bot := NewPlayer(uuid.NewString(), fmt.Sprintf("Bot %d", seat+1), g.BuyIn, seat)
+		bot.bot = g.botStrategies[len(bots)%len(g.botStrategies)]
+		g.Players[bot.ID] = bot
+		g.PlayerOrder = append(g.PlayerOrder, bot.ID)
+		bots = append(bots, bot)
+	}
+}
+
+// waitForPlayers sends a table kept seeded with bots back to waiting once
+// too few players are ready for another hand, so bots never play on among
+// themselves. It reports whether it did (assumes lock is held).
+func (g *Game) waitForPlayers() bool {
+	if g.botSeats == 0 || g.readyPlayers() >= g.MinPlayers {
+		return false
+	}
+	g.setPhase(WaitingForPlayers)
+	g.stateChanged()
+	return true
+}
+
+// promptBot has the bot whose turn it is act once it has thought a while,
+// unless one is already thinking (assumes lock is held)
+func (g *Game) promptBot() {
+	if g.botTurn != nil || !g.handInProgress() {
+		return
+	}
+	player := g.Players[g.getCurrentPlayerID()]
+	if player == nil || player.bot == nil || !player.CanAct() {
+		return
+	}
+
+	delay := g.botDelay
+	if delay <= 0 {
+		delay = defaultBotDelay
+	}
+	handNumber, acted := g.HandNumber, len(g.Actions)
+	g.botTurn = g.clock.AfterFunc(delay/2+rand.N(delay/2+1), func() {
+		defer g.recoverPanic()
+		g.playBot(player, handNumber, acted)
+	})
+}
+
+// playBot asks a bot for its decision and takes it, as long as nothing has
+// happened at the table since it was prompted
+func (g *Game) playBot(bot *Player, handNumber, acted int) {
+	g.mu.Lock()
+	if !g.botsTurn(bot, handNumber, acted) {
+		g.botTurn = nil
+		g.promptBot()
+		g.mu.Unlock()
+		return
+	}
+	turn := g.turnOf(bot)
+	g.mu.Unlock()
+
+	action, amount := bot.bot.Decide(turn)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.botTurn = nil
+	if !g.botsTurn(bot, handNumber, acted) {
+		g.promptBot()
+		return
+	}
+	if g.processAction(bot.ID, action, amount) == nil {
+		return
+	}
+	if g.processAction(bot.ID, Check, 0) != nil {
+		g.processAction(bot.ID, Fold, 0)
+	}
+}
+
+// botsTurn reports whether it is still a bot's turn in the hand and at the
+// point it was prompted at (assumes lock is held)
+func (g *Game) botsTurn(bot *Player, handNumber, acted int) bool {
+	return g.handInProgress() && g.HandNumber == handNumber && len(g.Actions) == acted &&
+		g.getCurrentPlayerID() == bot.ID
+}
+
+// turnOf describes the table as a bot sees it on its turn (assumes lock
+// is held)
+func (g *Game) turnOf(bot *Player) BotTurn {
+	opponents := 0
+	for _, player := range g.getActivePlayers() {
+		if player != bot {
+			opponents++
+		}
+	}
+	return BotTurn{
+		HoleCards: append([]poker.Card(nil), bot.HoleCards...),
+		Board:     append([]poker.Card(nil), g.CommunityCards...),
+		Phase:     g.Phase,
+		Pot:       g.Pot,
+		ToCall:    min(max(g.LastRaise-bot.CurrentBet, 0), bot.ChipCount),
+		MinRaise:  g.MinRaise,
+		Chips:     bot.ChipCount,
+		BigBlind:  g.BigBlind,
+		Opponents: opponents,
+	}
+}
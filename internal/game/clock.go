@@ -0,0 +1,107 @@
+package game
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells a table the time and runs its timers. Tables keep the
+// system's unless their config gives another, as scenarios do to deal hand
+// after hand without waiting for the hand delay.
+type Clock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a timer started by a Clock. Stop keeps it from firing, and
+// reports whether it had yet to.
+type Timer interface {
+	Stop() bool
+}
+
+// systemClock is the system's clock
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer { return time.AfterFunc(d, f) }
+
+// FakeClock is a Clock that stands still until it is moved on, firing the
+// timers that come due as it goes
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer // Waiting to fire
+}
+
+// NewFakeClock returns a clock that reads now until it is moved on
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the time the clock reads
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// AfterFunc calls f once the clock has been moved on by d
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	timer := &fakeTimer{clock: c, at: c.now.Add(d), f: f}
+	c.timers = append(c.timers, timer)
+	return timer
+}
+
+// Advance moves the clock on by d. Each timer that comes due fires in turn,
+// on the caller's goroutine and with the clock reading the time it was due
+// at, so one started by another fires too if it comes due within d.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	c.mu.Unlock()
+
+	for {
+		c.mu.Lock()
+		next := -1
+		for i, timer := range c.timers {
+			if !timer.at.After(end) && (next == -1 || timer.at.Before(c.timers[next].at)) {
+				next = i
+			}
+		}
+		if next == -1 {
+			c.now = end
+			c.mu.Unlock()
+			return
+		}
+		timer := c.timers[next]
+		c.timers = append(c.timers[:next], c.timers[next+1:]...)
+		c.now = timer.at
+		c.mu.Unlock()
+
+		timer.f()
+	}
+}
+
+// fakeTimer is a timer started by a FakeClock
+type fakeTimer struct {
+	clock *FakeClock
+	at    time.Time
+	f     func()
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	for i, timer := range t.clock.timers {
+		if timer == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
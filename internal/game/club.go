@@ -0,0 +1,38 @@
+package game
+
+// Clubs tells which players belong to the clubs whose tables only their
+// members may join
+type Clubs interface {
+	// IsMember reports whether a player belongs to a club. It is called
+	// as the player joins, with neither the manager nor the table locked.
+	IsMember(clubID, playerID string) (bool, error)
+}
+
+// SetClubs registers where club membership is checked as players sit down.
+// Without one, no one may join a club's table.
+func (m *Manager) SetClubs(clubs Clubs) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.clubs = clubs
+}
+
+// admit checks a player may sit at a table, which only a club's tables
+// restrict, against the manager's clubs
+func admit(clubs Clubs, game *Game, playerID string) error {
+	if game.ClubID == "" {
+		return nil
+	}
+	if clubs == nil {
+		return ErrNotClubMember
+	}
+
+	member, err := clubs.IsMember(game.ClubID, playerID)
+	if err != nil {
+		return err
+	}
+	if !member {
+		return ErrNotClubMember
+	}
+	return nil
+}
@@ -17,4 +17,11 @@ var (
 	ErrCannotAct         = errors.New("player cannot act")
 	ErrGameNotStarted    = errors.New("game not started")
 	ErrGameOver          = errors.New("game is over")
+	ErrHandInProgress    = errors.New("hand in progress")
+	ErrNotEnoughPlayers  = errors.New("not enough players to start")
+	ErrInvalidTableConfig = errors.New("invalid table configuration")
+	ErrNotClubMember     = errors.New("table is for club members only")
+	ErrPlayerNotIdle     = errors.New("player is back at the table")
+	ErrShuttingDown      = errors.New("server is shutting down")
+	ErrStackOutOfRange   = errors.New("stack is outside the table's buy-in range")
 )
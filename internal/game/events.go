@@ -0,0 +1,71 @@
+package game
+
+import "time"
+
+// EventType names something that happened at a table
+type EventType string
+
+const (
+	EventGameCreated      EventType = "game.created"      // The table opened
+	EventGameStarted      EventType = "game.started"      // Its first hand was dealt
+	EventGameFinished     EventType = "game.finished"     // The table closed
+	EventHandCompleted    EventType = "hand.completed"    // A hand was paid out
+	EventPlayerEliminated EventType = "player.eliminated" // A player lost their last chip
+)
+
+// Event is something that happened at a table. Table is set on game
+// events, Hand on hand and elimination events, and Player on eliminations.
+type Event struct {
+	Type   EventType
+	GameID string
+	Time   time.Time
+	Table  *TableState
+	Hand   *CompletedHand
+	Player *HandPlayer
+}
+
+// EventObserver is told about the life of every table: it opening, its
+// first hand, each completed hand, the players knocked out and it closing.
+// It is called with the table locked, so it must hand the work off rather
+// than block.
+type EventObserver interface {
+	TableEvent(event Event)
+}
+
+// SetEventObserver registers the observer told about table events, at
+// every table current and future
+func (m *Manager) SetEventObserver(observer EventObserver) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.events = observer
+	for _, game := range m.games {
+		game.mu.Lock()
+		game.events = observer
+		game.mu.Unlock()
+	}
+}
+
+// tableEvent tells the event observer about a change to the table itself
+// (assumes lock is held)
+func (g *Game) tableEvent(eventType EventType) {
+	if g.events == nil {
+		return
+	}
+	table := g.tableState()
+	g.events.TableEvent(Event{Type: eventType, GameID: g.ID, Time: g.clock.Now(), Table: &table})
+}
+
+// handEvents tells the event observer about a completed hand and anyone
+// it knocked out (assumes lock is held)
+func (g *Game) handEvents(hand *CompletedHand) {
+	if g.events == nil {
+		return
+	}
+	g.events.TableEvent(Event{Type: EventHandCompleted, GameID: g.ID, Time: hand.FinishedAt, Hand: hand})
+	for i := range hand.Players {
+		if player := &hand.Players[i]; player.EndingChips == 0 {
+			g.events.TableEvent(Event{Type: EventPlayerEliminated, GameID: g.ID, Time: hand.FinishedAt, Hand: hand, Player: player})
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package game
+
+import "github.com/primoPoker/server/internal/flags"
+
+// SetFlags sets the feature flags checked at every table, current and
+// future, before a hand takes the path of a feature being rolled out. A
+// table without flags has every flag off.
+func (m *Manager) SetFlags(features *flags.Flags) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.features = features
+	for _, game := range m.games {
+		game.mu.Lock()
+		game.features = features
+		game.mu.Unlock()
+	}
+}
@@ -3,9 +3,15 @@ package game
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/sirupsen/logrus"
+
+	"github.com/primoPoker/server/internal/flags"
+	"github.com/primoPoker/server/internal/logging"
 	"github.com/primoPoker/server/pkg/poker"
 )
 
@@ -59,6 +65,12 @@ type Action struct {
 	Action   PlayerAction  `json:"action"`
 	Amount   int64         `json:"amount"`
 	Time     time.Time     `json:"time"`
+
+	// Phase is the betting round the action was taken in, and the chip
+	// counts show what it put into the pot
+	Phase       GamePhase `json:"phase"`
+	ChipsBefore int64     `json:"chips_before"`
+	ChipsAfter  int64     `json:"chips_after"`
 }
 
 // Player represents a player in the game
@@ -76,6 +88,11 @@ type Player struct {
 	LastAction   *Action     `json:"last_action,omitempty"`
 	Connected    bool        `json:"connected"`
 	ActionTime   time.Time   `json:"action_time"`
+	buyIn        int64
+	held         bool // seat held since a restart until the player rejoins
+	leaving      bool // left during a hand, to be cashed out once it is paid
+	bot          Bot  // plays the seat for the table; nil for a player
+	botChips     int64 // of the stack, chips won from bots, which are not cashed out
 	mu           sync.RWMutex
 }
 
@@ -86,6 +103,7 @@ func NewPlayer(id, username string, buyIn int64, seatPosition int) *Player {
 		Username:     username,
 		ChipCount:    buyIn,
 		SeatPosition: seatPosition,
+		buyIn:        buyIn,
 		IsActive:     true,
 		Connected:    true,
 		HoleCards:    make([]poker.Card, 0, 2),
@@ -119,6 +137,21 @@ func (p *Player) Bet(amount int64) error {
 	return nil
 }
 
+// postAnte puts up to amount into the pot without adding to the player's
+// current bet, returning how much was put in
+func (p *Player) postAnte(amount int64) int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	amount = min(amount, p.ChipCount)
+	p.ChipCount -= amount
+	p.TotalBet += amount
+	if p.ChipCount == 0 {
+		p.IsAllIn = true
+	}
+	return amount
+}
+
 // Fold folds the player's hand
 func (p *Player) Fold() {
 	p.mu.Lock()
@@ -170,6 +203,33 @@ type Game struct {
 	Created       time.Time         `json:"created"`
 	LastActivity  time.Time         `json:"last_activity"`
 	TurnTimeout   time.Duration     `json:"turn_timeout"`
+	ClubID        string            `json:"club_id,omitempty"`
+	Ante          int64             `json:"ante,omitempty"`
+	MinBuyIn      int64             `json:"min_buy_in,omitempty"`
+	MaxBuyIn      int64             `json:"max_buy_in,omitempty"`
+	TemplateID    string            `json:"template_id,omitempty"`
+	pendingConfig *TableConfigUpdate
+	observer      HandObserver
+	events        EventObserver
+	features      *flags.Flags
+	handsCompleted *atomic.Uint64
+	store         TableStore
+	recordID      string
+	closed        bool
+	paused        bool // Deals no more hands, as the server is shutting down
+	reserved      map[int]string // Seats held for players buying in, by position
+	departed      map[string]int64 // Players unseated as hands ended, with what they left with, until the manager cashes them out
+	handDelay     time.Duration
+	summary       atomic.Pointer[GameInfo] // The table as the lobby lists it
+	lobbyVersion  *atomic.Uint64           // Counts changes to the manager's lobby
+	nextHand      Timer       // Deals the next hand once handDelay has passed
+	botSeats      int         // Seats kept filled with bots until players take them
+	botStrategies []Bot
+	botDelay      time.Duration
+	botTurn       Timer       // Has a bot act once it has thought
+	clock         Clock
+	notices       noticeQueue // Calls waiting to be made on the table's observers
+	hand          handRecord
 	mu            sync.RWMutex
 }
 
@@ -181,7 +241,15 @@ type SidePot struct {
 
 // NewGame creates a new poker game
 func NewGame(id, name string, config GameConfig) *Game {
-	return &Game{
+	clock := config.Clock
+	if clock == nil {
+		clock = systemClock{}
+	}
+	deck := poker.NewDeck()
+	if config.Shuffler != nil {
+		deck = poker.NewDeckWithShuffler(config.Shuffler)
+	}
+	game := &Game{
 		ID:            id,
 		Name:          name,
 		MaxPlayers:    config.MaxPlayersPerTable,
@@ -193,21 +261,40 @@ func NewGame(id, name string, config GameConfig) *Game {
 		PlayerOrder:   make([]string, 0),
 		Phase:         WaitingForPlayers,
 		CommunityCards: make([]poker.Card, 0, 5),
-		Deck:          poker.NewDeck(),
+		Deck:          deck,
 		Actions:       make([]Action, 0),
-		Created:       time.Now(),
-		LastActivity:  time.Now(),
+		Created:       clock.Now(),
+		LastActivity:  clock.Now(),
 		TurnTimeout:   config.TurnTimeout,
+		ClubID:        config.ClubID,
+		Ante:          config.Ante,
+		MinBuyIn:      config.MinBuyIn,
+		MaxBuyIn:      config.MaxBuyIn,
+		TemplateID:    config.TemplateID,
 		MinRaise:      config.BigBlind,
+		handDelay:     config.HandDelay,
+		botSeats:      config.Bots,
+		botStrategies: config.BotStrategies,
+		botDelay:      config.BotDelay,
+		clock:         clock,
 	}
+	if len(game.botStrategies) == 0 {
+		game.botStrategies = defaultBots
+	}
+	game.publishInfo()
+	return game
 }
 
 // AddPlayer adds a player to the game
 func (g *Game) AddPlayer(player *Player) error {
 	g.mu.Lock()
 	defer g.mu.Unlock()
+	return g.addPlayer(player)
+}
 
-	if len(g.Players) >= g.MaxPlayers {
+// addPlayer adds a player to the game (assumes lock is held)
+func (g *Game) addPlayer(player *Player) error {
+	if len(g.Players)+len(g.reserved) >= g.MaxPlayers {
 		return errors.New("game is full")
 	}
 
@@ -217,16 +304,94 @@ func (g *Game) AddPlayer(player *Player) error {
 
 	g.Players[player.ID] = player
 	g.PlayerOrder = append(g.PlayerOrder, player.ID)
-	g.LastActivity = time.Now()
+	g.LastActivity = g.clock.Now()
+	g.seatBots()
 
 	// Start game if we have enough players
-	if len(g.Players) >= g.MinPlayers && g.Phase == WaitingForPlayers {
+	if g.readyPlayers() >= g.MinPlayers && g.Phase == WaitingForPlayers && !g.paused {
 		g.startNewHand()
 	}
 
+	g.stateChanged()
 	return nil
 }
 
+// reserveSeat holds the lowest free seat for a player while they buy in,
+// so no one joining at the same time takes it. It returns -1 when every
+// seat is taken or held, and ErrGameNotFound once the table has closed.
+func (g *Game) reserveSeat(playerID string) (int, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.closed {
+		return -1, ErrGameNotFound
+	}
+
+	seat := g.freeSeat()
+	if seat == -1 {
+		return -1, nil
+	}
+	if g.reserved == nil {
+		g.reserved = make(map[int]string)
+	}
+	g.reserved[seat] = playerID
+	return seat, nil
+}
+
+// freeSeat returns the lowest seat nobody is in or holding, or -1 when
+// there is none (assumes lock is held)
+func (g *Game) freeSeat() int {
+	occupied := make(map[int]bool, len(g.Players))
+	for _, player := range g.Players {
+		occupied[player.SeatPosition] = true
+	}
+	for seat := 0; seat < g.MaxPlayers; seat++ {
+		if _, held := g.reserved[seat]; !held && !occupied[seat] {
+			return seat
+		}
+	}
+	return -1
+}
+
+// takeSeat seats a player at the seat reserved for them, which is freed
+// whether or not they can sit
+func (g *Game) takeSeat(player *Player) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.reserved, player.SeatPosition)
+	if g.closed {
+		return ErrGameNotFound
+	}
+	return g.addPlayer(player)
+}
+
+// releaseSeat frees a seat reserved for a player who will not take it
+func (g *Game) releaseSeat(seat int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.reserved, seat)
+}
+
+// readyPlayers counts the players who could be dealt in. Bots are only
+// dealt in against a player, so none are ready without one (assumes lock
+// is held).
+func (g *Game) readyPlayers() int {
+	ready, bots := 0, 0
+	for _, player := range g.Players {
+		if player.Connected && player.ChipCount > 0 {
+			ready++
+			if player.bot != nil {
+				bots++
+			}
+		}
+	}
+	if ready == bots {
+		return 0
+	}
+	return ready
+}
+
 // RemovePlayer removes a player from the game
 func (g *Game) RemovePlayer(playerID string) error {
 	g.mu.Lock()
@@ -237,18 +402,22 @@ func (g *Game) RemovePlayer(playerID string) error {
 		return errors.New("player not in game")
 	}
 
-	// Mark player as disconnected instead of removing immediately
-	player.Connected = false
-	player.IsActive = false
-
-	// If it's the player's turn, automatically fold
-	if g.getCurrentPlayerID() == playerID && g.Phase != WaitingForPlayers {
+	// If it's the player's turn, automatically fold, while they can still
+	// act
+	if g.getCurrentPlayerID() == playerID && g.handInProgress() {
 		if err := g.processAction(playerID, Fold, 0); err != nil {
 			// Log error but continue with player removal
 		}
 	}
 
-	g.LastActivity = time.Now()
+	// Mark player as disconnected instead of removing immediately
+	player.Connected = false
+	player.IsActive = false
+	player.held = false
+
+	g.LastActivity = g.clock.Now()
+	g.seatBots()
+	g.stateChanged()
 	return nil
 }
 
@@ -279,6 +448,8 @@ func (g *Game) processAction(playerID string, action PlayerAction, amount int64)
 		return errors.New("player cannot act")
 	}
 
+	chipsBefore := player.ChipCount
+
 	// Validate and process the action
 	switch action {
 	case Fold:
@@ -326,21 +497,40 @@ func (g *Game) processAction(playerID string, action PlayerAction, amount int64)
 
 	// Record the action
 	actionRecord := Action{
-		PlayerID: playerID,
-		Action:   action,
-		Amount:   amount,
-		Time:     time.Now(),
+		PlayerID:    playerID,
+		Action:      action,
+		Amount:      amount,
+		Time:        g.clock.Now(),
+		Phase:       g.Phase,
+		ChipsBefore: chipsBefore,
+		ChipsAfter:  player.ChipCount,
 	}
 	player.LastAction = &actionRecord
 	g.Actions = append(g.Actions, actionRecord)
-	g.LastActivity = time.Now()
+	g.LastActivity = g.clock.Now()
 
 	// Move to next player or next phase
 	g.advanceGame()
+	g.stateChanged()
 
 	return nil
 }
 
+// legalActions returns the actions processAction would take from a player
+// on their turn, raising by the minimum (assumes lock is held)
+func (g *Game) legalActions(player *Player) []PlayerAction {
+	actions := []PlayerAction{Fold}
+	if player.CurrentBet >= g.LastRaise {
+		actions = append(actions, Check)
+	} else {
+		actions = append(actions, Call)
+	}
+	if g.LastRaise+g.MinRaise-player.CurrentBet <= player.ChipCount {
+		actions = append(actions, Raise)
+	}
+	return append(actions, AllIn)
+}
+
 // getCurrentPlayerID returns the ID of the current player to act
 func (g *Game) getCurrentPlayerID() string {
 	if len(g.PlayerOrder) == 0 || g.CurrentPlayer >= len(g.PlayerOrder) {
@@ -408,15 +598,15 @@ func (g *Game) advancePhase() {
 	switch g.Phase {
 	case PreFlop:
 		g.dealFlop()
-		g.Phase = Flop
+		g.setPhase(Flop)
 	case Flop:
 		g.dealTurn()
-		g.Phase = Turn
+		g.setPhase(Turn)
 	case Turn:
 		g.dealRiver()
-		g.Phase = River
+		g.setPhase(River)
 	case River:
-		g.Phase = Showdown
+		g.setPhase(Showdown)
 		g.endHand()
 		return
 	}
@@ -449,8 +639,10 @@ func (g *Game) moveToNextActivePlayer() {
 
 // startNewHand starts a new hand
 func (g *Game) startNewHand() {
+	g.applyPendingConfig()
+
 	g.HandNumber++
-	g.Phase = PreFlop
+	g.setPhase(PreFlop)
 	g.Pot = 0
 	g.SidePots = nil
 	g.CommunityCards = g.CommunityCards[:0]
@@ -462,6 +654,7 @@ func (g *Game) startNewHand() {
 	for _, player := range g.Players {
 		player.ResetForNewHand()
 	}
+	g.hand.start(g.Players, g.clock.Now())
 
 	// Move dealer button
 	g.moveDealerButton()
@@ -470,40 +663,75 @@ func (g *Game) startNewHand() {
 	g.Deck.Reset()
 	g.dealHoleCards()
 
-	// Post blinds
+	// Post antes and blinds
+	g.postAntes()
 	g.postBlinds()
 
 	// Set current player (first to act after big blind)
 	g.CurrentPlayer = (g.BigBlindPos + 1) % len(g.PlayerOrder)
 	g.moveToNextActivePlayer()
+
+	g.saveTable()
+	g.handStarted()
+	g.stateChanged()
+	if g.HandNumber == 1 {
+		g.tableEvent(EventGameStarted)
+	}
 }
 
-// moveDealerButton moves the dealer button to the next active player
+// moveDealerButton moves the button and blinds on for a new hand. After a
+// hand dealt three or more handed the dead button rule applies: the big
+// blind moves on to the next player dealt in, the small blind to the seat
+// the big blind left and the button to the one the small blind left, so a
+// player busting makes nobody miss a blind or post one twice. That can
+// leave the button, or a small blind that is not posted, on a seat nobody
+// is dealt in from.
 func (g *Game) moveDealerButton() {
-	if len(g.PlayerOrder) < 2 {
+	n := len(g.PlayerOrder)
+	if n < 2 {
 		return
 	}
 
-	// Move dealer button
-	g.DealerPos = (g.DealerPos + 1) % len(g.PlayerOrder)
-	
-	// Ensure dealer is an active player
-	for i := 0; i < len(g.PlayerOrder); i++ {
-		if g.Players[g.PlayerOrder[g.DealerPos]].IsActive {
-			break
+	dealtIn := 0
+	for _, playerID := range g.PlayerOrder {
+		if g.Players[playerID].IsActive {
+			dealtIn++
 		}
-		g.DealerPos = (g.DealerPos + 1) % len(g.PlayerOrder)
 	}
 
-	// Set blind positions
-	if len(g.PlayerOrder) == 2 {
+	if g.HandNumber > 1 && g.SmallBlindPos != g.DealerPos && g.BigBlindPos < n {
+		bigBlind := g.nextActiveSeat(g.BigBlindPos)
+		if dealtIn > 2 {
+			g.DealerPos, g.SmallBlindPos, g.BigBlindPos = g.SmallBlindPos, g.BigBlindPos, bigBlind
+			return
+		}
+		// Down to heads-up, the player not in the big blind has the button
+		g.BigBlindPos = bigBlind
+		g.DealerPos = g.nextActiveSeat(bigBlind)
+		g.SmallBlindPos = g.DealerPos
+		return
+	}
+
+	g.DealerPos = g.nextActiveSeat(g.DealerPos)
+	if dealtIn == 2 {
 		// Heads-up: dealer is small blind
 		g.SmallBlindPos = g.DealerPos
-		g.BigBlindPos = (g.DealerPos + 1) % len(g.PlayerOrder)
 	} else {
-		g.SmallBlindPos = (g.DealerPos + 1) % len(g.PlayerOrder)
-		g.BigBlindPos = (g.DealerPos + 2) % len(g.PlayerOrder)
+		g.SmallBlindPos = g.nextActiveSeat(g.DealerPos)
 	}
+	g.BigBlindPos = g.nextActiveSeat(g.SmallBlindPos)
+}
+
+// nextActiveSeat returns the position in PlayerOrder of the next player
+// after pos who is dealt in, or the next position if nobody is
+func (g *Game) nextActiveSeat(pos int) int {
+	n := len(g.PlayerOrder)
+	for i := 1; i <= n; i++ {
+		if next := (pos + i) % n; g.Players[g.PlayerOrder[next]].IsActive {
+			return next
+		}
+	}
+	return (pos + 1) % n
 }
 
 // dealHoleCards deals hole cards to all active players
@@ -523,34 +751,40 @@ func (g *Game) dealHoleCards() {
 	}
 }
 
-// postBlinds posts the small and big blinds
+// postBlinds posts the small and big blinds. A dead small blind, on a seat
+// nobody is dealt in from, is not posted.
 func (g *Game) postBlinds() {
-	smallBlindPlayer := g.Players[g.PlayerOrder[g.SmallBlindPos]]
-	bigBlindPlayer := g.Players[g.PlayerOrder[g.BigBlindPos]]
+	g.Pot += postBlind(g.Players[g.PlayerOrder[g.SmallBlindPos]], g.SmallBlind)
+	g.Pot += postBlind(g.Players[g.PlayerOrder[g.BigBlindPos]], g.BigBlind)
+}
 
-	// Post small blind
-	sbAmount := min(g.SmallBlind, smallBlindPlayer.ChipCount)
-	if err := smallBlindPlayer.Bet(sbAmount); err != nil {
-		// Handle error - player cannot bet (should not happen)
-		sbAmount = smallBlindPlayer.ChipCount
-		if err := smallBlindPlayer.Bet(sbAmount); err != nil {
-			// Final fallback - force bet to 0
-			sbAmount = 0
-		}
+// postBlind has a player dealt in bet a blind, or all they have if that is
+// less, returning how much they put in
+func postBlind(player *Player, blind int64) int64 {
+	if !player.IsActive {
+		return 0
+	}
+	amount := min(blind, player.ChipCount)
+	if err := player.Bet(amount); err != nil {
+		return 0
+	}
+	return amount
+}
+
+// postAntes takes the ante from every player dealt in. Antes are dead
+// money: they go into the pot without counting towards the bet to call.
+func (g *Game) postAntes() {
+	if g.Ante <= 0 {
+		return
 	}
-	g.Pot += sbAmount
 
-	// Post big blind
-	bbAmount := min(g.BigBlind, bigBlindPlayer.ChipCount)
-	if err := bigBlindPlayer.Bet(bbAmount); err != nil {
-		// Handle error - player cannot bet (should not happen)
-		bbAmount = bigBlindPlayer.ChipCount
-		if err := bigBlindPlayer.Bet(bbAmount); err != nil {
-			// Final fallback - force bet to 0
-			bbAmount = 0
+	for _, playerID := range g.PlayerOrder {
+		player := g.Players[playerID]
+		if !player.IsActive {
+			continue
 		}
+		g.Pot += player.postAnte(g.Ante)
 	}
-	g.Pot += bbAmount
 }
 
 // dealFlop deals the flop (3 community cards)
@@ -608,50 +842,97 @@ func (g *Game) dealRiver() {
 
 // endHand ends the current hand and determines winners
 func (g *Game) endHand() {
-	g.Phase = Showdown
+	g.setPhase(Showdown)
+	potSize := g.Pot
 	
 	// Calculate side pots if there are all-in players
 	g.calculateSidePots()
 	
 	// Determine winners and distribute pots
 	g.distributePots()
+	g.settleBotChips()
+
+	g.reportHand(potSize)
 	
-	// Remove players with no chips
+	// Remove players who left during the hand, and those with no chips
+	g.recordDepartures()
 	g.removeEliminatedPlayers()
+	g.seatBots()
+	g.saveTable()
 	
 	// Check if game should continue
-	if len(g.getActivePlayers()) < g.MinPlayers {
-		g.Phase = GameOver
+	if g.waitForPlayers() {
 		return
 	}
+	if g.botSeats == 0 && len(g.getActivePlayers()) < g.MinPlayers {
+		g.setPhase(GameOver)
+		g.stateChanged()
+		return
+	}
+	g.stateChanged()
 	
 	// Start next hand after a brief delay
-	time.AfterFunc(5*time.Second, func() {
+	delay := g.handDelay
+	if delay <= 0 {
+		delay = defaultHandDelay
+	}
+	g.nextHand = g.clock.AfterFunc(delay, func() {
+		defer g.recoverPanic()
 		g.mu.Lock()
 		defer g.mu.Unlock()
-		if g.Phase == Showdown {
+		g.nextHand = nil
+		if g.Phase == Showdown && !g.paused && !g.waitForPlayers() {
 			g.startNewHand()
 		}
 	})
 }
 
-// calculateSidePots calculates side pots for all-in situations
+// recoverPanic logs a panic in one of the game's timers, which would
+// otherwise take every other table down with the server
+func (g *Game) recoverPanic() {
+	if recovered := recover(); recovered != nil {
+		logging.Panic(logrus.WithField("game_id", g.ID), recovered)
+	}
+}
+
+// calculateSidePots splits the pot by how much each player still in the
+// hand put into it. A pot is contested by the players who put in at least
+// its level; chips put in beyond what any of them matched go to the last
+// pot, and those of players no longer seated to the main pot.
 func (g *Game) calculateSidePots() {
-	// This is a simplified version - a full implementation would be more complex
-	// For now, we'll just use the main pot
-	g.SidePots = []SidePot{
-		{
-			Amount: g.Pot,
-			EligiblePlayers: func() []string {
-				var eligible []string
-				for _, playerID := range g.PlayerOrder {
-					if !g.Players[playerID].HasFolded {
-						eligible = append(eligible, playerID)
-					}
-				}
-				return eligible
-			}(),
-		},
+	var levels []int64
+	for _, player := range g.getActivePlayers() {
+		levels = append(levels, player.TotalBet)
+	}
+	slices.Sort(levels)
+	levels = slices.Compact(levels)
+
+	g.SidePots = nil
+	var previous, total int64
+	for i, level := range levels {
+		pot := SidePot{}
+		for _, playerID := range g.PlayerOrder {
+			player := g.Players[playerID]
+			bet := player.TotalBet
+			if i < len(levels)-1 {
+				bet = min(bet, level)
+			}
+			if bet > previous {
+				pot.Amount += bet - previous
+			}
+			if !player.HasFolded && player.TotalBet >= level {
+				pot.EligiblePlayers = append(pot.EligiblePlayers, playerID)
+			}
+		}
+		previous = level
+		if pot.Amount > 0 {
+			g.SidePots = append(g.SidePots, pot)
+			total += pot.Amount
+		}
+	}
+
+	if len(g.SidePots) > 0 {
+		g.SidePots[0].Amount += g.Pot - total
 	}
 }
 
@@ -666,27 +947,36 @@ func (g *Game) distributePots() {
 		// Only one player left, they win everything
 		winner := activePlayers[0]
 		winner.ChipCount += g.Pot
+		g.hand.won(winner.ID, g.Pot)
 		g.Pot = 0
 		return
 	}
 
-	// Showdown - compare hands
-	winners := g.determineWinners(activePlayers)
-	
-	// Split pot among winners
+	// Showdown - each pot goes to the best hands of those contesting it,
+	// split between them on a tie
 	for _, sidePot := range g.SidePots {
+		contenders := make([]*Player, 0, len(sidePot.EligiblePlayers))
+		for _, playerID := range sidePot.EligiblePlayers {
+			contenders = append(contenders, g.Players[playerID])
+		}
+		winners := g.determineWinners(contenders)
+		if len(winners) == 0 {
+			// Without a full board there are no hands to compare
+			winners = contenders
+		}
+
 		potShare := sidePot.Amount / int64(len(winners))
 		remainder := sidePot.Amount % int64(len(winners))
-		
 		for i, winner := range winners {
 			share := potShare
 			if i < int(remainder) {
 				share++ // Distribute remainder chips
 			}
 			winner.ChipCount += share
+			g.hand.won(winner.ID, share)
 		}
 	}
-	
+
 	g.Pot = 0
 }
 
@@ -696,7 +986,7 @@ func (g *Game) determineWinners(players []*Player) []*Player {
 		return players
 	}
 
-	var bestHand *poker.Hand
+	var best poker.Strength
 	var winners []*Player
 
 	for _, player := range players {
@@ -709,21 +999,15 @@ func (g *Game) determineWinners(players []*Player) []*Player {
 		allCards = append(allCards, player.HoleCards...)
 		allCards = append(allCards, g.CommunityCards...)
 
-		playerHand := poker.GetBestHand(allCards)
+		strength, _ := poker.EvaluateSeven(allCards)
 
-		if bestHand == nil {
-			bestHand = playerHand
+		if winners == nil || strength > best {
+			// New best hand
+			best = strength
 			winners = []*Player{player}
-		} else {
-			comparison := poker.CompareHands(playerHand, bestHand)
-			if comparison > 0 {
-				// New best hand
-				bestHand = playerHand
-				winners = []*Player{player}
-			} else if comparison == 0 {
-				// Tie
-				winners = append(winners, player)
-			}
+		} else if strength == best {
+			// Tie
+			winners = append(winners, player)
 		}
 	}
 
@@ -758,11 +1042,26 @@ func (g *Game) removeEliminatedPlayers() {
 	}
 }
 
+// RecentActions returns the current hand number and a copy of the actions
+// taken in it so far
+func (g *Game) RecentActions() (int, []Action) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	actions := make([]Action, len(g.Actions))
+	copy(actions, g.Actions)
+	return g.HandNumber, actions
+}
+
 // GetGameState returns the current game state for a specific player
 func (g *Game) GetGameState(playerID string) GameState {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
+	return g.gameState(playerID)
+}
 
+// gameState is the game state shown to a player (assumes lock is held)
+func (g *Game) gameState(playerID string) GameState {
 	state := GameState{
 		GameID:         g.ID,
 		Phase:          g.Phase,
@@ -787,6 +1086,7 @@ func (g *Game) GetGameState(playerID string) GameState {
 			IsAllIn:      player.IsAllIn,
 			SeatPosition: player.SeatPosition,
 			Connected:    player.Connected,
+			IsBot:        player.bot != nil,
 		}
 
 		// Show hole cards only to the player themselves
@@ -833,6 +1133,12 @@ type PlayerState struct {
 	SeatPosition int           `json:"seat_position"`
 	Connected    bool          `json:"connected"`
 	LastAction   *ActionState  `json:"last_action,omitempty"`
+	// IsBot marks a seat a bot plays, for clients to label
+	IsBot        bool          `json:"is_bot,omitempty"`
+
+	// HUD holds the player's stats as shown to their opponents, when the
+	// server has HUD stats enabled
+	HUD interface{} `json:"hud,omitempty"`
 }
 
 // ActionState represents an action state
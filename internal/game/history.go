@@ -0,0 +1,188 @@
+package game
+
+import (
+	"time"
+
+	"github.com/primoPoker/server/pkg/poker"
+)
+
+// HandObserver is told about every hand a table completes. It is called
+// with the table locked, so it must hand the work off rather than block.
+type HandObserver interface {
+	HandCompleted(hand CompletedHand)
+}
+
+// CompletedHand is a snapshot of a finished hand, taken once the pot has
+// been paid out
+type CompletedHand struct {
+	GameID         string
+	RecordID       string // ID the table is stored under, if it is stored
+	TableName      string
+	HandNumber     int
+	SmallBlind     int64
+	BigBlind       int64
+	BuyIn          int64
+	MaxPlayers     int
+	DealerSeat     int
+	CommunityCards []poker.Card
+	Pot            int64
+	Actions        []Action
+	Players        []HandPlayer
+	StartedAt      time.Time
+	FinishedAt     time.Time
+}
+
+// HandPlayer is one player's part in a completed hand
+type HandPlayer struct {
+	ID             string
+	Username       string
+	SeatPosition   int
+	Position       poker.Position
+	HoleCards      []poker.Card
+	StartingChips  int64
+	EndingChips    int64
+	AmountWon      int64
+	Folded         bool
+	WentToShowdown bool
+	Bot            bool // A bot played the seat
+
+	// BestHand is the player's best five cards, for hands that reached showdown
+	BestHand *poker.Hand
+}
+
+// handRecord tracks what a hand's snapshot needs beyond the table state
+type handRecord struct {
+	startedAt     time.Time
+	startingChips map[string]int64
+	winnings      map[string]int64
+}
+
+// start resets the record for a new hand, before the blinds are posted
+func (h *handRecord) start(players map[string]*Player, startedAt time.Time) {
+	h.startedAt = startedAt
+	h.startingChips = make(map[string]int64, len(players))
+	h.winnings = make(map[string]int64)
+	for id, player := range players {
+		h.startingChips[id] = player.ChipCount
+	}
+}
+
+// won credits a player with chips from the pot
+func (h *handRecord) won(playerID string, amount int64) {
+	if h.winnings != nil {
+		h.winnings[playerID] += amount
+	}
+}
+
+// SetHandObserver registers the observer told about completed hands, at
+// every table current and future
+func (m *Manager) SetHandObserver(observer HandObserver) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.observer = observer
+	for _, game := range m.games {
+		game.mu.Lock()
+		game.observer = observer
+		game.mu.Unlock()
+	}
+}
+
+// reportHand passes a snapshot of the hand that just ended to the table's
+// observers (assumes lock is held)
+func (g *Game) reportHand(potSize int64) {
+	if g.handsCompleted != nil {
+		g.handsCompleted.Add(1)
+	}
+	if !g.observed() || g.hand.startingChips == nil {
+		return
+	}
+
+	showdown := len(g.getActivePlayers()) > 1 && len(g.CommunityCards) == 5
+
+	hand := CompletedHand{
+		GameID:         g.ID,
+		RecordID:       g.recordID,
+		TableName:      g.Name,
+		HandNumber:     g.HandNumber,
+		SmallBlind:     g.SmallBlind,
+		BigBlind:       g.BigBlind,
+		BuyIn:          g.BuyIn,
+		MaxPlayers:     g.MaxPlayers,
+		CommunityCards: append([]poker.Card(nil), g.CommunityCards...),
+		Pot:            potSize,
+		Actions:        append([]Action(nil), g.Actions...),
+		StartedAt:      g.hand.startedAt,
+		FinishedAt:     g.clock.Now(),
+	}
+	if g.DealerPos < len(g.PlayerOrder) {
+		hand.DealerSeat = g.Players[g.PlayerOrder[g.DealerPos]].SeatPosition
+	}
+
+	positions := g.positions()
+	for _, playerID := range g.PlayerOrder {
+		player := g.Players[playerID]
+		startingChips, dealtIn := g.hand.startingChips[playerID]
+		if !dealtIn || len(player.HoleCards) != 2 {
+			continue
+		}
+
+		hp := HandPlayer{
+			ID:            player.ID,
+			Username:      player.Username,
+			SeatPosition:  player.SeatPosition,
+			Position:      positions[playerID],
+			HoleCards:     append([]poker.Card(nil), player.HoleCards...),
+			StartingChips: startingChips,
+			EndingChips:   player.ChipCount,
+			AmountWon:     g.hand.winnings[playerID],
+			Folded:        player.HasFolded,
+			Bot:           player.bot != nil,
+		}
+		if showdown && !player.HasFolded {
+			hp.WentToShowdown = true
+			cards := append(append(make([]poker.Card, 0, 7), player.HoleCards...), g.CommunityCards...)
+			if best, err := poker.BestHandFrom(cards); err == nil {
+				hp.BestHand = best
+			}
+		}
+		hand.Players = append(hand.Players, hp)
+	}
+
+	if g.observer != nil {
+		g.observer.HandCompleted(hand)
+	}
+	g.handEvents(&hand)
+	g.handResult(&hand)
+}
+
+// positions names the betting position of each player dealt into the hand,
+// going round the table from the blinds set by moveDealerButton
+// (assumes lock is held)
+func (g *Game) positions() map[string]poker.Position {
+	n := len(g.PlayerOrder)
+	positions := make(map[string]poker.Position, n)
+	if n < 2 || g.DealerPos >= n || g.SmallBlindPos >= n || g.BigBlindPos >= n {
+		return positions
+	}
+
+	dealer := g.PlayerOrder[g.DealerPos]
+	positions[g.PlayerOrder[g.BigBlindPos]] = poker.PositionBB
+	if g.SmallBlindPos != g.DealerPos {
+		positions[g.PlayerOrder[g.SmallBlindPos]] = poker.PositionSB
+	}
+	// Heads-up the button posts the small blind and is named for the button
+	positions[dealer] = poker.PositionButton
+
+	var middle []string
+	for i := (g.BigBlindPos + 1) % n; i != g.DealerPos; i = (i + 1) % n {
+		if len(g.Players[g.PlayerOrder[i]].HoleCards) == 2 {
+			middle = append(middle, g.PlayerOrder[i])
+		}
+	}
+	for i, position := range poker.MiddlePositions(len(middle)) {
+		positions[middle[i]] = position
+	}
+
+	return positions
+}
@@ -0,0 +1,127 @@
+package game
+
+import "sort"
+
+// lobby is a listing of the open tables in lobby order, taken at version
+type lobby struct {
+	games   []GameInfo
+	version uint64
+}
+
+// ListGames returns all active games, lowest stakes first and then the
+// oldest. They are listed from a snapshot of the summaries the tables
+// publish as players come and go and hands start and end, taken again
+// only after one of them changes or a table opens or closes. Listing the
+// lobby never locks a table.
+func (m *Manager) ListGames() []*GameInfo {
+	snapshot := m.lobby.Load()
+	if !snapshot.current(m.lobbyVersion.Load()) {
+		snapshot = m.refreshLobby()
+	}
+
+	games := make([]*GameInfo, len(snapshot.games))
+	for i := range snapshot.games {
+		info := snapshot.games[i]
+		games[i] = &info
+	}
+	return games
+}
+
+// current reports whether the snapshot can still be listed
+func (l *lobby) current(version uint64) bool {
+	return l != nil && l.version == version
+}
+
+// lobbyChanged marks the lobby snapshot out of date
+func (m *Manager) lobbyChanged() {
+	m.lobbyVersion.Add(1)
+}
+
+// refreshLobby takes a new snapshot of the lobby. One is taken at a time:
+// callers arriving while it is taken wait to list it rather than take
+// their own.
+func (m *Manager) refreshLobby() *lobby {
+	m.lobbyMu.Lock()
+	defer m.lobbyMu.Unlock()
+
+	// The version is read first, so a change while the snapshot is taken
+	// leaves it out of date
+	version := m.lobbyVersion.Load()
+	if snapshot := m.lobby.Load(); snapshot.current(version) {
+		return snapshot
+	}
+	snapshot := &lobby{version: version}
+
+	m.mu.RLock()
+	snapshot.games = make([]GameInfo, 0, len(m.games))
+	for _, game := range m.games {
+		snapshot.games = append(snapshot.games, *game.summary.Load())
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(snapshot.games, func(i, j int) bool {
+		return snapshot.games[i].Before(&snapshot.games[j])
+	})
+	m.lobby.Store(snapshot)
+	return snapshot
+}
+
+// Before reports whether a table is listed before other in the lobby:
+// lower stakes first, then the older, with the ID settling any tie
+func (info *GameInfo) Before(other *GameInfo) bool {
+	switch {
+	case info.BigBlind != other.BigBlind:
+		return info.BigBlind < other.BigBlind
+	case info.SmallBlind != other.SmallBlind:
+		return info.SmallBlind < other.SmallBlind
+	case !info.Created.Equal(other.Created):
+		return info.Created.Before(other.Created)
+	default:
+		return info.ID < other.ID
+	}
+}
+
+// publishInfo updates the summary the lobby lists the table by, and marks
+// the manager's lobby out of date (assumes lock is held)
+func (g *Game) publishInfo() {
+	bots := 0
+	for _, player := range g.Players {
+		if player.bot != nil {
+			bots++
+		}
+	}
+	g.summary.Store(&GameInfo{
+		ID:          g.ID,
+		Name:        g.Name,
+		PlayerCount: len(g.Players),
+		Bots:        bots,
+		MaxPlayers:  g.MaxPlayers,
+		SmallBlind:  g.SmallBlind,
+		BigBlind:    g.BigBlind,
+		BuyIn:       g.BuyIn,
+		Phase:       g.Phase,
+		ClubID:      g.ClubID,
+		TemplateID:  g.TemplateID,
+		Ante:        g.Ante,
+		Created:     g.Created,
+	})
+	if g.lobbyVersion != nil {
+		g.lobbyVersion.Add(1)
+	}
+}
+
+// playerCount counts the players seated at the table, including those who
+// have left but keep their seat until it is freed. Bots are not counted,
+// so a table left to them is empty.
+func (g *Game) playerCount() int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	count := 0
+	for _, player := range g.Players {
+		if player.bot == nil {
+			count++
+		}
+	}
+	return count
+}
@@ -1,8 +1,18 @@
 package game
 
 import (
+	"context"
+	"fmt"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/primoPoker/server/internal/flags"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/primoPoker/server/pkg/poker"
 )
 
 // GameConfig holds game-specific configuration
@@ -17,21 +27,56 @@ type GameConfig struct {
 	BigBlind          int64
 	TurnTimeout       time.Duration
 	DecisionTimeout   time.Duration
+	ClubID            string // Set for tables only the club's members may join
+	Ante              int64
+	TemplateID        string // Set for tables opened from a template
+	HandDelay         time.Duration // Pause between hands, defaultHandDelay if unset
+	Bots              int           // Bots seated while the table has fewer players; see WithBots
+	BotStrategies     []Bot         // Played by the bots in turn, defaultBots if unset
+	BotDelay          time.Duration // Longest a bot thinks, defaultBotDelay if unset
+	Shuffler          poker.Shuffler // Shuffles the table's deck, crypto/rand if unset
+	Clock             Clock          // Times the table, the system clock if unset
 }
 
-// Manager manages all poker games
+// defaultHandDelay is how long a table pauses between hands, so players
+// see how the last one ended
+const defaultHandDelay = 5 * time.Second
+
+// Manager manages all poker games. Its lock guards only its own maps and
+// settings and is held briefly; work on a table, and calls out to the
+// store, bank and clubs, happen under the table's lock or no lock at all,
+// so a busy table does not hold up the others.
 type Manager struct {
-	games   map[string]*Game
-	players map[string][]string // playerID -> list of gameIDs
-	mu      sync.RWMutex
-	config  GameConfig
+	games    map[string]*Game
+	players  map[string][]string // playerID -> list of gameIDs
+	creating map[string]*Game    // Tables being stored before they open, by ID
+	stopped  bool                // Shutting down: no table opens or seats anyone
+	mu       sync.RWMutex
+	config   GameConfig
+
+	// The lobby as last listed, and a count of the changes to it, the
+	// tables' included
+	lobby        atomic.Pointer[lobby]
+	lobbyVersion atomic.Uint64
+	lobbyMu      sync.Mutex
+
+	observer       HandObserver
+	events         EventObserver
+	gameObservers  []GameObserver
+	store          TableStore
+	bank           Bank
+	clubs          Clubs
+	features       *flags.Flags
+	templates      []Template
+	handsCompleted atomic.Uint64
 }
 
 // NewManager creates a new game manager
 func NewManager() *Manager {
-	return &Manager{
-		games:   make(map[string]*Game),
-		players: make(map[string][]string),
+	m := &Manager{
+		games:    make(map[string]*Game),
+		players:  make(map[string][]string),
+		creating: make(map[string]*Game),
 		config: GameConfig{
 			MaxTablesPerUser:   3,
 			MaxPlayersPerTable: 10,
@@ -45,28 +90,114 @@ func NewManager() *Manager {
 			DecisionTimeout:   15 * time.Second,
 		},
 	}
+	m.gameObservers = []GameObserver{seatKeeper{manager: m}}
+	return m
 }
 
-// CreateGame creates a new game
-func (m *Manager) CreateGame(gameID, name string, options ...GameOption) (*Game, error) {
+// SetDefaults sets the configuration new tables are created with. Tables
+// already running keep the configuration they were created with.
+func (m *Manager) SetDefaults(config GameConfig) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.config = config
+}
 
-	if _, exists := m.games[gameID]; exists {
+// CreateGame creates a new game. The table is stored without holding the
+// manager's lock, its ID held meanwhile so it cannot be created twice.
+func (m *Manager) CreateGame(gameID, name string, options ...GameOption) (*Game, error) {
+	m.mu.Lock()
+	if m.stopped {
+		m.mu.Unlock()
+		return nil, ErrShuttingDown
+	}
+	if _, exists := m.games[gameID]; exists || m.creating[gameID] != nil {
+		m.mu.Unlock()
 		return nil, ErrGameAlreadyExists
 	}
-
 	config := m.config
 	for _, option := range options {
 		option(&config)
 	}
+	game := m.reserveGame(gameID, name, config)
+	m.mu.Unlock()
 
-	game := NewGame(gameID, name, config)
-	m.games[gameID] = game
-
+	if err := m.storeGame(game); err != nil {
+		return nil, err
+	}
 	return game, nil
 }
 
+// reserveGame creates a table and holds its ID until storeGame opens it
+// (assumes lock is held)
+func (m *Manager) reserveGame(gameID, name string, config GameConfig) *Game {
+	game := m.newGame(gameID, name, config)
+	m.creating[gameID] = game
+	return game
+}
+
+// storeGame stores a table reserved with reserveGame and opens it, or
+// gives its ID up if it cannot be stored. The manager is locked only to
+// open it.
+func (m *Manager) storeGame(game *Game) error {
+	err := game.storeTable()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.creating, game.ID)
+	if err != nil {
+		return err
+	}
+	m.openGame(game)
+	return nil
+}
+
+// openGame adds a stored table to the manager (assumes lock is held)
+func (m *Manager) openGame(game *Game) {
+	// A table stored as the server began shutting down deals no hand
+	if m.stopped {
+		game.pause()
+	}
+	m.games[game.ID] = game
+	game.seedBots()
+	game.tableEvent(EventGameCreated)
+	m.lobbyChanged()
+}
+
+// removeGame removes a closed table from the manager (assumes lock is
+// held)
+func (m *Manager) removeGame(gameID string) {
+	delete(m.games, gameID)
+	m.lobbyChanged()
+}
+
+// storeTable stores a new table, before anyone else can reach it, if the
+// manager has a store
+func (g *Game) storeTable() error {
+	if g.store == nil {
+		return nil
+	}
+	recordID, err := g.store.CreateTable(g.tableState())
+	if err != nil {
+		return fmt.Errorf("failed to store game: %w", err)
+	}
+	g.recordID = recordID
+	return nil
+}
+
+// newGame creates a game reporting to the manager's observers and store
+// (assumes lock is held)
+func (m *Manager) newGame(gameID, name string, config GameConfig) *Game {
+	game := NewGame(gameID, name, config)
+	game.observer = m.observer
+	game.events = m.events
+	game.notices.observers = m.gameObservers
+	game.features = m.features
+	game.store = m.store
+	game.handsCompleted = &m.handsCompleted
+	game.lobbyVersion = &m.lobbyVersion
+	return game
+}
+
 // GetGame returns a game by ID
 func (m *Manager) GetGame(gameID string) (*Game, error) {
 	m.mu.RLock()
@@ -80,104 +211,165 @@ func (m *Manager) GetGame(gameID string) (*Game, error) {
 	return game, nil
 }
 
-// ListGames returns all active games
-func (m *Manager) ListGames() []*GameInfo {
+// GetPlayerGames returns the IDs of the games a player is seated at
+func (m *Manager) GetPlayerGames(playerID string) []string {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	games := make([]*GameInfo, 0, len(m.games))
-	for _, game := range m.games {
-		game.mu.RLock()
-		info := &GameInfo{
-			ID:          game.ID,
-			Name:        game.Name,
-			PlayerCount: len(game.Players),
-			MaxPlayers:  game.MaxPlayers,
-			SmallBlind:  game.SmallBlind,
-			BigBlind:    game.BigBlind,
-			BuyIn:       game.BuyIn,
-			Phase:       game.Phase,
-			Created:     game.Created,
-		}
-		game.mu.RUnlock()
-		games = append(games, info)
-	}
-
-	return games
+	return append([]string(nil), m.players[playerID]...)
 }
 
-// JoinGame adds a player to a game
-func (m *Manager) JoinGame(gameID, playerID, username string, buyIn int64) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// JoinGame adds a player to a game. The manager is locked only to count
+// the table against the player's limit, and the table only to hold them a
+// seat and then seat them, so their buy-in is taken with neither locked.
+func (m *Manager) JoinGame(ctx context.Context, gameID, playerID, username string, buyIn int64) (err error) {
+	ctx, span := startSpan(ctx, "Manager.JoinGame", gameID, playerID)
+	defer func() { endSpan(span, err) }()
 
-	game, exists := m.games[gameID]
-	if !exists {
-		return ErrGameNotFound
+	if m.stopping() {
+		return ErrShuttingDown
+	}
+	game, err := m.GetGame(gameID)
+	if err != nil {
+		return err
 	}
 
-	// Check if player is already in too many games
-	playerGames := m.players[playerID]
-	if len(playerGames) >= m.config.MaxTablesPerUser {
-		return ErrTooManyTables
+	// Players whose seat was held over a restart take it back as it was
+	if game.rejoin(playerID) {
+		return nil
+	}
+
+	m.mu.RLock()
+	config, clubs, bank := m.config, m.clubs, m.bank
+	m.mu.RUnlock()
+
+	if err := admit(clubs, game, playerID); err != nil {
+		return err
 	}
 
-	// Validate buy-in amount
-	if buyIn < m.config.MinBuyIn || buyIn > m.config.MaxBuyIn {
+	// Validate buy-in amount against the table's range, if it has one
+	minBuyIn, maxBuyIn := config.MinBuyIn, config.MaxBuyIn
+	if game.MaxBuyIn > 0 {
+		minBuyIn, maxBuyIn = game.MinBuyIn, game.MaxBuyIn
+	}
+	if buyIn < minBuyIn || buyIn > maxBuyIn {
 		return ErrInvalidBuyIn
 	}
 
-	// Find an available seat
-	seatPosition := m.findAvailableSeat(game)
-	if seatPosition == -1 {
-		return ErrGameFull
+	// The table counts against the player's limit from here, so joining
+	// several at once cannot take them past it
+	if err := m.trackPlayer(ctx, playerID, gameID); err != nil {
+		return err
+	}
+
+	seatPosition, err := game.reserveSeat(playerID)
+	if err == nil && seatPosition == -1 {
+		err = ErrGameFull
+	}
+	if err != nil {
+		m.dropPlayer(ctx, playerID, gameID)
+		return err
+	}
+
+	if bank != nil {
+		seat := SeatState{PlayerID: playerID, Username: username, SeatPosition: seatPosition, BuyIn: buyIn, Chips: buyIn}
+		if err := bank.BuyIn(ctx, game.RecordID(), seat); err != nil {
+			game.releaseSeat(seatPosition)
+			m.dropPlayer(ctx, playerID, gameID)
+			return err
+		}
 	}
 
 	// Create and add player
 	player := NewPlayer(playerID, username, buyIn, seatPosition)
-	if err := game.AddPlayer(player); err != nil {
+	if err := game.takeSeat(player); err != nil {
+		if bank != nil {
+			bank.CashOut(playerID, buyIn)
+		}
+		m.dropPlayer(ctx, playerID, gameID)
 		return err
 	}
 
-	// Track player's games
-	m.players[playerID] = append(m.players[playerID], gameID)
+	// Open another table for the template if this one just filled
+	if game.TemplateID != "" {
+		m.ensureTemplateTables()
+	}
 
 	return nil
 }
 
-// LeaveGame removes a player from a game
-func (m *Manager) LeaveGame(gameID, playerID string) error {
-	m.mu.Lock()
+// trackPlayer adds a game to a player's table list, unless they are at as
+// many tables as they may be or it has closed
+func (m *Manager) trackPlayer(ctx context.Context, playerID, gameID string) error {
+	m.lock(ctx)
 	defer m.mu.Unlock()
 
-	game, exists := m.games[gameID]
-	if !exists {
+	if m.stopped {
+		return ErrShuttingDown
+	}
+	if _, exists := m.games[gameID]; !exists {
 		return ErrGameNotFound
 	}
+	if len(m.players[playerID]) >= m.config.MaxTablesPerUser {
+		return ErrTooManyTables
+	}
+	m.players[playerID] = append(m.players[playerID], gameID)
+	return nil
+}
+
+// dropPlayer takes a game back off the table list of a player who did not
+// sit down
+func (m *Manager) dropPlayer(ctx context.Context, playerID, gameID string) {
+	m.lock(ctx)
+	defer m.mu.Unlock()
+	m.detach(playerID, gameID)
+}
 
-	if err := game.RemovePlayer(playerID); err != nil {
+// LeaveGame takes a player away from a game and cashes them out. A player
+// still in the hand being played keeps their seat until it is paid, and is
+// cashed out then; otherwise their stack is paid back at once, and their
+// seat freed at once or as the hand ends.
+func (m *Manager) LeaveGame(ctx context.Context, gameID, playerID string) (err error) {
+	ctx, span := startSpan(ctx, "Manager.LeaveGame", gameID, playerID)
+	defer func() { endSpan(span, err) }()
+
+	game, err := m.GetGame(gameID)
+	if err != nil {
 		return err
 	}
 
-	// Remove from player's game list
-	playerGames := m.players[playerID]
-	for i, gid := range playerGames {
-		if gid == gameID {
-			m.players[playerID] = append(playerGames[:i], playerGames[i+1:]...)
-			break
-		}
+	stack, unseated, err := game.leave(playerID)
+	if err != nil {
+		return err
 	}
 
-	// Clean up empty game
-	if len(game.Players) == 0 {
-		delete(m.games, gameID)
+	m.lock(ctx)
+
+	// The table stays on the player's list until their seat is freed
+	if unseated {
+		m.detach(playerID, gameID)
+	}
+
+	// Clean up empty game, unless it was closed meanwhile
+	stacks := map[string]int64{playerID: stack}
+	if m.games[gameID] == game && game.playerCount() == 0 && !m.keepOpen(game) {
+		for id, left := range m.closeGame(game) {
+			stacks[id] += left
+		}
+		m.removeGame(gameID)
 	}
+	m.mu.Unlock()
 
+	m.cashOut(stacks)
 	return nil
 }
 
 // ProcessAction processes a player's action in a game
-func (m *Manager) ProcessAction(gameID, playerID string, action PlayerAction, amount int64) error {
+func (m *Manager) ProcessAction(ctx context.Context, gameID, playerID string, action PlayerAction, amount int64) (err error) {
+	_, span := startSpan(ctx, "Manager.ProcessAction", gameID, playerID)
+	span.SetAttributes(attribute.String("action", action.String()))
+	defer func() { endSpan(span, err) }()
+
 	game, err := m.GetGame(gameID)
 	if err != nil {
 		return err
@@ -197,41 +389,76 @@ func (m *Manager) GetGameState(gameID, playerID string) (*GameState, error) {
 	return &state, nil
 }
 
-// findAvailableSeat finds an available seat position in the game
-func (m *Manager) findAvailableSeat(game *Game) int {
-	occupiedSeats := make(map[int]bool)
-	
-	game.mu.RLock()
-	for _, player := range game.Players {
-		occupiedSeats[player.SeatPosition] = true
-	}
-	game.mu.RUnlock()
+// CleanupInactiveGames closes the tables nobody has been seated at, nor
+// done anything at, for idleAfter, and returns their IDs. Each is closed,
+// so a next hand it was waiting to deal never is, and removed with the
+// manager locked; any players left are then cashed out and each table
+// passed to closing, if set, once it is unlocked. Template tables the
+// lobby needs are kept, and any closed table still on a player's table
+// list is dropped from it.
+func (m *Manager) CleanupInactiveGames(idleAfter time.Duration, closing func(gameID string)) []string {
+	m.mu.Lock()
+	cutoff := time.Now().Add(-idleAfter)
+	var closed []string
+	stacks := make(map[string]map[string]int64)
+	for gameID, game := range m.games {
+		if !game.idleSince(cutoff) || m.keepOpen(game) {
+			continue
+		}
 
-	for seat := 0; seat < game.MaxPlayers; seat++ {
-		if !occupiedSeats[seat] {
-			return seat
+		stacks[gameID] = m.closeGame(game)
+		m.removeGame(gameID)
+		closed = append(closed, gameID)
+	}
+	m.reconcilePlayers()
+	m.mu.Unlock()
+
+	sort.Strings(closed)
+	for _, gameID := range closed {
+		m.cashOut(stacks[gameID])
+		if closing != nil {
+			closing(gameID)
 		}
 	}
 
-	return -1 // No available seats
-}
+	// Retry any template table that failed to open
+	m.ensureTemplateTables()
 
-// CleanupInactiveGames removes games that have been inactive for too long
-func (m *Manager) CleanupInactiveGames() {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	return closed
+}
 
-	cutoff := time.Now().Add(-1 * time.Hour) // 1 hour timeout
+// RunCleanup closes idle tables, as CleanupInactiveGames does, every
+// interval until ctx is done
+func (m *Manager) RunCleanup(ctx context.Context, interval, idleAfter time.Duration, closing func(gameID string)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if closed := m.CleanupInactiveGames(idleAfter, closing); len(closed) > 0 {
+				logrus.WithField("games", closed).Info("Closed idle tables")
+			}
+		}
+	}
+}
 
-	for gameID, game := range m.games {
-		game.mu.RLock()
-		inactive := game.LastActivity.Before(cutoff) && len(game.Players) == 0
-		game.mu.RUnlock()
+// closeGame closes a table and drops it from its players' table lists,
+// returning their stacks. The caller then removes it with removeGame and,
+// once the manager is unlocked, cashes them out (assumes lock is held).
+func (m *Manager) closeGame(game *Game) map[string]int64 {
+	stacks := game.Close()
+	for playerID := range stacks {
+		m.detach(playerID, game.ID)
+	}
 
-		if inactive {
-			delete(m.games, gameID)
-		}
+	// Those unseated as its last hand ended may not have been cashed out yet
+	for playerID, stack := range m.departures(game) {
+		stacks[playerID] += stack
 	}
+	return stacks
 }
 
 // GameInfo represents basic game information for listing
@@ -239,11 +466,15 @@ type GameInfo struct {
 	ID          string    `json:"id"`
 	Name        string    `json:"name"`
 	PlayerCount int       `json:"player_count"`
+	Bots        int       `json:"bots,omitempty"` // Of the players, how many are bots
 	MaxPlayers  int       `json:"max_players"`
 	SmallBlind  int64     `json:"small_blind"`
 	BigBlind    int64     `json:"big_blind"`
 	BuyIn       int64     `json:"buy_in"`
 	Phase       GamePhase `json:"phase"`
+	ClubID      string    `json:"club_id,omitempty"`
+	TemplateID  string    `json:"template_id,omitempty"`
+	Ante        int64     `json:"ante,omitempty"`
 	Created     time.Time `json:"created"`
 }
 
@@ -275,6 +506,13 @@ func WithPlayerLimits(minPlayers, maxPlayers int) GameOption {
 	}
 }
 
+// WithClub keeps the table to the members of a club
+func WithClub(clubID string) GameOption {
+	return func(config *GameConfig) {
+		config.ClubID = clubID
+	}
+}
+
 // WithTimeouts sets the timeout durations
 func WithTimeouts(turnTimeout, decisionTimeout time.Duration) GameOption {
 	return func(config *GameConfig) {
@@ -0,0 +1,131 @@
+package game
+
+// MovePlayer moves a player from one table to another, taking their stack
+// with them, as an operator separating players or filling a short-handed
+// table. Neither table may be playing a hand. The player takes the lowest
+// free seat at the destination, whose buy-in range must cover their stack.
+// Both tables are stored, so the player's participation moves with them,
+// and their players told of the change. It returns the seat taken.
+func (m *Manager) MovePlayer(fromGameID, toGameID, playerID string) (int, error) {
+	if fromGameID == toGameID {
+		return -1, ErrPlayerAlreadyInGame
+	}
+
+	// Club membership is checked before locking, as JoinGame does
+	to, err := m.GetGame(toGameID)
+	if err != nil {
+		return -1, err
+	}
+	m.mu.RLock()
+	clubs, config := m.clubs, m.config
+	m.mu.RUnlock()
+	if err := admit(clubs, to, playerID); err != nil {
+		return -1, err
+	}
+	minBuyIn, maxBuyIn := config.MinBuyIn, config.MaxBuyIn
+	if to.MaxBuyIn > 0 {
+		minBuyIn, maxBuyIn = to.MinBuyIn, to.MaxBuyIn
+	}
+
+	seat, err := m.reseat(fromGameID, to, playerID, minBuyIn, maxBuyIn)
+	if err != nil {
+		return -1, err
+	}
+
+	// Open another table for the template if the move filled this one
+	if to.TemplateID != "" {
+		m.ensureTemplateTables()
+	}
+	return seat, nil
+}
+
+// reseat moves a player's seat and their place on their table list. The
+// manager is locked only to find the tables and then to move the table
+// list, so a table being dealt to or stored holds up no other.
+func (m *Manager) reseat(fromGameID string, to *Game, playerID string, minBuyIn, maxBuyIn int64) (int, error) {
+	m.mu.RLock()
+	from, exists := m.games[fromGameID]
+	stopped := m.stopped
+	current := m.games[to.ID] == to
+	m.mu.RUnlock()
+	if stopped {
+		return -1, ErrShuttingDown
+	}
+	if !exists || !current {
+		return -1, ErrGameNotFound
+	}
+
+	seat, err := moveSeat(from, to, playerID, minBuyIn, maxBuyIn)
+	if err != nil {
+		return -1, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.detach(playerID, fromGameID)
+	// A table closed since has detached its players already
+	if m.games[to.ID] == to {
+		m.players[playerID] = append(m.players[playerID], to.ID)
+	}
+	return seat, nil
+}
+
+// moveSeat unseats a player at one table and seats them with the same
+// stack at another, between hands at both
+func moveSeat(from, to *Game, playerID string, minBuyIn, maxBuyIn int64) (int, error) {
+	// Tables are locked in order of their IDs, so moves each way between
+	// two tables at once cannot deadlock
+	first, second := from, to
+	if second.ID < first.ID {
+		first, second = second, first
+	}
+	first.mu.Lock()
+	defer first.mu.Unlock()
+	second.mu.Lock()
+	defer second.mu.Unlock()
+
+	if from.closed || to.closed {
+		return -1, ErrGameNotFound
+	}
+	player, seated := from.Players[playerID]
+	if !seated {
+		return -1, ErrPlayerNotInGame
+	}
+	if _, seated := to.Players[playerID]; seated {
+		return -1, ErrPlayerAlreadyInGame
+	}
+	if from.handInProgress() || to.handInProgress() {
+		return -1, ErrHandInProgress
+	}
+	stack := player.ChipCount
+	if stack < minBuyIn || stack > maxBuyIn {
+		return -1, ErrStackOutOfRange
+	}
+	seat := to.freeSeat()
+	if seat == -1 {
+		return -1, ErrGameFull
+	}
+
+	moved := NewPlayer(playerID, player.Username, stack, seat)
+	moved.botChips = player.botChips
+	moved.Connected = player.Connected
+	moved.held = player.held
+	if err := to.addPlayer(moved); err != nil {
+		return -1, err
+	}
+	to.saveTable()
+
+	// Left with no chips and away, the player is dropped from the table
+	player.ChipCount = 0
+	player.botChips = 0
+	player.Connected = false
+	player.IsActive = false
+	player.held = false
+	from.removeEliminatedPlayers()
+	from.seatBots()
+	from.LastActivity = from.clock.Now()
+	from.saveTable()
+	from.stateChanged()
+
+	return seat, nil
+}
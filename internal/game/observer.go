@@ -0,0 +1,185 @@
+package game
+
+import (
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/primoPoker/server/internal/logging"
+)
+
+// GameObserver is told what happens at every table, whether a player or
+// the table itself made it happen, such as a hand dealt once the last one
+// has been paid out. It is called from a goroutine of the table's own,
+// in order and never with the table locked, so it may read the table
+// back; while it runs, later calls for that table wait.
+type GameObserver interface {
+	// OnStateChanged is called after anything a player could see changes
+	OnStateChanged(gameID string)
+	// OnHandStarted is called once a hand has been dealt and its blinds posted
+	OnHandStarted(gameID string, handNumber int)
+	// OnHandResult is called once a hand has been paid out
+	OnHandResult(hand CompletedHand)
+	// OnPlayerEliminated is called for each player a hand left with no chips
+	OnPlayerEliminated(gameID string, player HandPlayer)
+	// OnPhaseChanged is called as the table moves from one phase to the next
+	OnPhaseChanged(gameID string, phase GamePhase)
+}
+
+// NopObserver does nothing when called. Observers embed it to be told
+// only some of what happens.
+type NopObserver struct{}
+
+func (NopObserver) OnStateChanged(gameID string)                        {}
+func (NopObserver) OnHandStarted(gameID string, handNumber int)         {}
+func (NopObserver) OnHandResult(hand CompletedHand)                     {}
+func (NopObserver) OnPlayerEliminated(gameID string, player HandPlayer) {}
+func (NopObserver) OnPhaseChanged(gameID string, phase GamePhase)       {}
+
+// AddObserver registers an observer at every table current and future
+func (m *Manager) AddObserver(observer GameObserver) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.gameObservers = append(m.gameObservers, observer)
+	for _, game := range m.games {
+		game.notices.add(observer)
+	}
+}
+
+// notice is a call to be made on each of a table's observers
+type notice func(observer GameObserver)
+
+// noticeQueue holds a table's notices until its observers are called. A
+// notice is queued with the table locked; the queue is then drained by a
+// goroutine started for it, which holds no lock while it calls them.
+type noticeQueue struct {
+	mu        sync.Mutex
+	observers []GameObserver
+	pending   []notice
+	changed   bool // The last notice pending is a state change
+	draining  bool
+}
+
+// add registers an observer for the notices queued from now on
+func (q *noticeQueue) add(observer GameObserver) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.observers = append(q.observers[:len(q.observers):len(q.observers)], observer)
+}
+
+// push queues a notice, starting to drain the queue if nobody is. A state
+// change straight after another is dropped, since observers read the
+// state back when told of one.
+func (q *noticeQueue) push(gameID string, call notice, stateChange bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.observers) == 0 || stateChange && q.changed {
+		return
+	}
+	q.pending = append(q.pending, call)
+	q.changed = stateChange
+	if !q.draining {
+		q.draining = true
+		go q.drain(gameID)
+	}
+}
+
+// drain calls the observers with each notice until none are left
+func (q *noticeQueue) drain(gameID string) {
+	for {
+		q.mu.Lock()
+		pending, observers := q.pending, q.observers
+		q.pending, q.changed = nil, false
+		if len(pending) == 0 {
+			q.draining = false
+			q.mu.Unlock()
+			return
+		}
+		q.mu.Unlock()
+
+		for _, call := range pending {
+			for _, observer := range observers {
+				deliver(gameID, call, observer)
+			}
+		}
+	}
+}
+
+// idle reports whether every notice queued has been delivered
+func (q *noticeQueue) idle() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return !q.draining
+}
+
+// deliver calls one observer, so one that panics neither takes the server
+// down nor keeps the others from being told
+func deliver(gameID string, call notice, observer GameObserver) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			logging.Panic(logrus.WithField("game_id", gameID), recovered)
+		}
+	}()
+	call(observer)
+}
+
+// stateChanged publishes the table's summary to the lobby, tells its
+// observers the state changed and prompts a bot whose turn it now is
+// (assumes lock is held)
+func (g *Game) stateChanged() {
+	g.publishInfo()
+	g.notices.push(g.ID, func(observer GameObserver) {
+		observer.OnStateChanged(g.ID)
+	}, true)
+	g.promptBot()
+}
+
+// setPhase moves the table to a phase, telling its observers if it is a
+// new one (assumes lock is held)
+func (g *Game) setPhase(phase GamePhase) {
+	if g.Phase == phase {
+		return
+	}
+	g.Phase = phase
+	g.notices.push(g.ID, func(observer GameObserver) {
+		observer.OnPhaseChanged(g.ID, phase)
+	}, false)
+}
+
+// handStarted tells the table's observers a hand was dealt (assumes lock
+// is held)
+func (g *Game) handStarted() {
+	handNumber := g.HandNumber
+	g.notices.push(g.ID, func(observer GameObserver) {
+		observer.OnHandStarted(g.ID, handNumber)
+	}, false)
+}
+
+// handResult tells the table's observers about a completed hand and anyone
+// it knocked out (assumes lock is held)
+func (g *Game) handResult(hand *CompletedHand) {
+	result := *hand
+	g.notices.push(g.ID, func(observer GameObserver) {
+		observer.OnHandResult(result)
+	}, false)
+	for _, player := range hand.Players {
+		if player.EndingChips == 0 {
+			g.notices.push(g.ID, func(observer GameObserver) {
+				observer.OnPlayerEliminated(g.ID, player)
+			}, false)
+		}
+	}
+}
+
+// observed reports whether anyone is told about the table's hands
+// (assumes lock is held)
+func (g *Game) observed() bool {
+	if g.observer != nil || g.events != nil {
+		return true
+	}
+	g.notices.mu.Lock()
+	defer g.notices.mu.Unlock()
+	return len(g.notices.observers) > 0
+}
@@ -0,0 +1,476 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/primoPoker/server/pkg/poker"
+)
+
+// Scenario scripts one or more hands at a table: who sits down with what,
+// the cards each hand is dealt, what the players do and how the table
+// should look as they go. Run plays it at a table of its own, dealt from
+// a deck arranged to the script and timed by a clock that only moves when
+// a step waits, so a rule is pinned by describing a hand rather than by
+// driving a table step by step.
+type Scenario struct {
+	Name string
+
+	// Config sets the table's blinds, ante and limits. The blinds default
+	// to 50 and 100, the seats to nine and the players a hand needs to
+	// everyone in the scenario; the deck and clock are the scenario's own.
+	Config GameConfig
+
+	// Players sit down in order, each in the seat of their place in the
+	// list. The button moves before every hand, so the second player
+	// listed has it first.
+	Players []ScenarioPlayer
+
+	// Deals are the cards dealt to each hand in turn. Cards a deal leaves
+	// out come from the rest of the deck in order, as do those of hands
+	// dealt once the deals run out.
+	Deals []ScenarioDeal
+
+	Steps []ScenarioStep
+	Final []Expectation // Checked once every step has been played
+}
+
+// ScenarioPlayer is a player in a scenario, sitting down with a stack
+type ScenarioPlayer struct {
+	ID    string
+	Stack int64
+}
+
+// ScenarioDeal is the cards a hand is dealt
+type ScenarioDeal struct {
+	Hole  map[string][]poker.Card // By player ID
+	Board []poker.Card            // As much of it as the script needs
+}
+
+// ScenarioStep is something happening at the table, and how the table
+// should look once it has
+type ScenarioStep struct {
+	// Wait moves the clock on before anything else, firing the timers that
+	// come due, such as the one dealing the next hand
+	Wait time.Duration
+
+	// Player takes an action, unless Do is set to do something else to the
+	// table, such as closing it or kicking a player
+	Player string
+	Action PlayerAction
+	Amount int64
+	Do     func(g *Game) error
+
+	Rejected bool  // The action or Do is to fail
+	Err      error // If set, the error it is to fail with
+	Expect   []Expectation
+}
+
+// Expectation checks the table in the course of a scenario, describing
+// how it differs from what was expected
+type Expectation func(run *scenarioRun) error
+
+// Run plays the scenario, returning an error that describes the first
+// step which did not go as the script says
+func (s Scenario) Run() error {
+	run, err := s.start()
+	if err != nil {
+		return err
+	}
+	for i, step := range s.Steps {
+		if err := run.play(step); err != nil {
+			return fmt.Errorf("%s: step %d (%s): %w", s.Name, i+1, step, err)
+		}
+	}
+	if err := run.check(s.Final); err != nil {
+		return fmt.Errorf("%s: at the end: %w", s.Name, err)
+	}
+	return nil
+}
+
+// start opens the scenario's table and seats its players
+func (s Scenario) start() (*scenarioRun, error) {
+	for i, deal := range s.Deals {
+		if err := deal.validate(); err != nil {
+			return nil, fmt.Errorf("%s: deal %d: %w", s.Name, i+1, err)
+		}
+	}
+
+	config := s.Config
+	if config.BigBlind == 0 {
+		config.SmallBlind, config.BigBlind = 50, 100
+	}
+	if config.MaxPlayersPerTable == 0 {
+		config.MaxPlayersPerTable = 9
+	}
+	if config.MinPlayersPerTable == 0 {
+		config.MinPlayersPerTable = max(len(s.Players), 2)
+	}
+	deck := &riggedDeck{deals: s.Deals}
+	clock := NewFakeClock(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+	config.Shuffler = deck
+	config.Clock = clock
+
+	run := &scenarioRun{game: NewGame("scenario", s.Name, config), clock: clock}
+	deck.game = run.game
+	run.game.observer = run
+
+	for seat, player := range s.Players {
+		if err := run.game.AddPlayer(NewPlayer(player.ID, player.ID, player.Stack, seat)); err != nil {
+			return nil, fmt.Errorf("%s: seating %s: %w", s.Name, player.ID, err)
+		}
+	}
+	return run, nil
+}
+
+// validate checks the deal could come from one deck
+func (d ScenarioDeal) validate() error {
+	if len(d.Board) > 5 {
+		return fmt.Errorf("a board has five cards, not %d", len(d.Board))
+	}
+	cards := slices.Clone(d.Board)
+	for playerID, hole := range d.Hole {
+		if len(hole) > 2 {
+			return fmt.Errorf("%s is dealt %d hole cards, not two", playerID, len(hole))
+		}
+		cards = append(cards, hole...)
+	}
+	seen := make(map[poker.Card]bool, len(cards))
+	for _, card := range cards {
+		if seen[card] {
+			return fmt.Errorf("%s is dealt twice", card)
+		}
+		seen[card] = true
+	}
+	return nil
+}
+
+func (step ScenarioStep) String() string {
+	switch {
+	case step.Do != nil:
+		return "table operation"
+	case step.Player == "" && step.Wait == 0:
+		return "the table as it stands"
+	case step.Player == "":
+		return fmt.Sprintf("waiting %s", step.Wait)
+	case step.Action == Raise:
+		return fmt.Sprintf("%s raises %d", step.Player, step.Amount)
+	}
+	return fmt.Sprintf("%s: %s", step.Player, step.Action)
+}
+
+// scenarioRun is a scenario being played
+type scenarioRun struct {
+	game  *Game
+	clock *FakeClock
+	hand  *CompletedHand // The last hand the table completed
+}
+
+// HandCompleted keeps the hand for the expectations of the steps after it
+func (r *scenarioRun) HandCompleted(hand CompletedHand) {
+	r.hand = &hand
+}
+
+// play plays a step and checks the table after it
+func (r *scenarioRun) play(step ScenarioStep) error {
+	if step.Wait > 0 {
+		r.clock.Advance(step.Wait)
+	}
+
+	var err error
+	switch {
+	case step.Do != nil:
+		err = step.Do(r.game)
+	case step.Player != "":
+		err = r.game.ProcessAction(step.Player, step.Action, step.Amount)
+	}
+	rejected := step.Rejected || step.Err != nil
+	switch {
+	case rejected && err == nil:
+		return fmt.Errorf("was taken, but should have been refused")
+	case !rejected && err != nil:
+		return err
+	case step.Err != nil && !errors.Is(err, step.Err):
+		return fmt.Errorf("was refused with %q, want %q", err, step.Err)
+	}
+	return r.check(step.Expect)
+}
+
+// check checks the table against expectations
+func (r *scenarioRun) check(expectations []Expectation) error {
+	r.game.mu.RLock()
+	defer r.game.mu.RUnlock()
+
+	for _, expect := range expectations {
+		if err := expect(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExpectPhase expects the table to be in a phase
+func ExpectPhase(phase GamePhase) Expectation {
+	return func(run *scenarioRun) error {
+		if run.game.Phase != phase {
+			return fmt.Errorf("phase is %s, want %s", run.game.Phase, phase)
+		}
+		return nil
+	}
+}
+
+// ExpectHandNumber expects the table to be on a hand
+func ExpectHandNumber(handNumber int) Expectation {
+	return func(run *scenarioRun) error {
+		if run.game.HandNumber != handNumber {
+			return fmt.Errorf("hand is number %d, want %d", run.game.HandNumber, handNumber)
+		}
+		return nil
+	}
+}
+
+// ExpectPot expects the chips in the pot
+func ExpectPot(chips int64) Expectation {
+	return func(run *scenarioRun) error {
+		if run.game.Pot != chips {
+			return fmt.Errorf("pot is %d, want %d", run.game.Pot, chips)
+		}
+		return nil
+	}
+}
+
+// ExpectBoard expects the cards dealt to the board so far
+func ExpectBoard(cards ...poker.Card) Expectation {
+	return func(run *scenarioRun) error {
+		if !slices.Equal(run.game.CommunityCards, cards) {
+			return fmt.Errorf("board is %v, want %v", run.game.CommunityCards, cards)
+		}
+		return nil
+	}
+}
+
+// ExpectBlinds expects the blinds the table posts
+func ExpectBlinds(smallBlind, bigBlind int64) Expectation {
+	return func(run *scenarioRun) error {
+		if run.game.SmallBlind != smallBlind || run.game.BigBlind != bigBlind {
+			return fmt.Errorf("blinds are %d/%d, want %d/%d", run.game.SmallBlind, run.game.BigBlind, smallBlind, bigBlind)
+		}
+		return nil
+	}
+}
+
+// ExpectButton expects the player on the button and those posting the
+// blinds
+func ExpectButton(dealer, smallBlind, bigBlind string) Expectation {
+	return func(run *scenarioRun) error {
+		g := run.game
+		got := []string{g.PlayerOrder[g.DealerPos], g.PlayerOrder[g.SmallBlindPos], g.PlayerOrder[g.BigBlindPos]}
+		if want := []string{dealer, smallBlind, bigBlind}; !slices.Equal(got, want) {
+			return fmt.Errorf("button and blinds are %v, want %v", got, want)
+		}
+		return nil
+	}
+}
+
+// ExpectToAct expects it to be a player's turn, or nobody's once no hand
+// is being played
+func ExpectToAct(playerID string) Expectation {
+	return func(run *scenarioRun) error {
+		current := ""
+		if run.game.handInProgress() {
+			current = run.game.getCurrentPlayerID()
+		}
+		if current != playerID {
+			return fmt.Errorf("%q is to act, want %q", current, playerID)
+		}
+		return nil
+	}
+}
+
+// ExpectLegal expects the actions the player whose turn it is may take,
+// raising by the minimum
+func ExpectLegal(actions ...PlayerAction) Expectation {
+	return func(run *scenarioRun) error {
+		player := run.game.Players[run.game.getCurrentPlayerID()]
+		if player == nil || !run.game.handInProgress() {
+			return fmt.Errorf("nobody is to act, want %v", actions)
+		}
+		if legal := run.game.legalActions(player); !slices.Equal(legal, actions) {
+			return fmt.Errorf("%s may %v, want %v", player.ID, legal, actions)
+		}
+		return nil
+	}
+}
+
+// ExpectMinRaise expects the least a raise may add on top of a call
+func ExpectMinRaise(chips int64) Expectation {
+	return func(run *scenarioRun) error {
+		if run.game.MinRaise != chips {
+			return fmt.Errorf("minimum raise is %d, want %d", run.game.MinRaise, chips)
+		}
+		return nil
+	}
+}
+
+// ExpectSeated expects the players seated at the table, in seat order
+func ExpectSeated(playerIDs ...string) Expectation {
+	return func(run *scenarioRun) error {
+		if !slices.Equal(run.game.PlayerOrder, playerIDs) {
+			return fmt.Errorf("%v are seated, want %v", run.game.PlayerOrder, playerIDs)
+		}
+		return nil
+	}
+}
+
+// ExpectDisconnected expects players to keep their seats while
+// disconnected, sitting out of the hands dealt
+func ExpectDisconnected(playerIDs ...string) Expectation {
+	return func(run *scenarioRun) error {
+		for _, playerID := range playerIDs {
+			player := run.game.Players[playerID]
+			if player == nil {
+				return fmt.Errorf("%s is not seated", playerID)
+			}
+			if player.Connected || player.IsActive {
+				return fmt.Errorf("%s is still connected and dealt in", playerID)
+			}
+		}
+		return nil
+	}
+}
+
+// ExpectSees expects the hole cards a player is shown of everyone seated:
+// their own, and none of anyone else's. Players left out of hole are
+// expected to be shown none.
+func ExpectSees(playerID string, hole map[string][]poker.Card) Expectation {
+	return func(run *scenarioRun) error {
+		state := run.game.gameState(playerID)
+		if state.GameID != run.game.ID || state.Phase != run.game.Phase || state.Pot != run.game.Pot {
+			return fmt.Errorf("%s is shown game %s in phase %s with %d in the pot, want %s in %s with %d",
+				playerID, state.GameID, state.Phase, state.Pot, run.game.ID, run.game.Phase, run.game.Pot)
+		}
+		for _, player := range state.Players {
+			if !slices.Equal(player.HoleCards, hole[player.ID]) {
+				return fmt.Errorf("%s is shown %v of %s, want %v", playerID, player.HoleCards, player.ID, hole[player.ID])
+			}
+		}
+		return nil
+	}
+}
+
+// ExpectStacks expects the chips in front of players, counting a player
+// no longer seated as having none. Players left out are not checked.
+func ExpectStacks(stacks map[string]int64) Expectation {
+	return func(run *scenarioRun) error {
+		for _, playerID := range slices.Sorted(maps.Keys(stacks)) {
+			var chips int64
+			if player := run.game.Players[playerID]; player != nil {
+				chips = player.ChipCount
+			}
+			if chips != stacks[playerID] {
+				return fmt.Errorf("%s has %d chips, want %d", playerID, chips, stacks[playerID])
+			}
+		}
+		return nil
+	}
+}
+
+// ExpectWinners expects what each player won from the pot of the last hand
+// completed; those left out are expected to have won nothing
+func ExpectWinners(won map[string]int64) Expectation {
+	return func(run *scenarioRun) error {
+		if run.hand == nil {
+			return fmt.Errorf("no hand has been completed")
+		}
+		got := make(map[string]int64)
+		for _, player := range run.hand.Players {
+			if player.AmountWon > 0 {
+				got[player.ID] = player.AmountWon
+			}
+		}
+		if !maps.Equal(got, won) {
+			return fmt.Errorf("hand %d was won %v, want %v", run.hand.HandNumber, got, won)
+		}
+		return nil
+	}
+}
+
+// riggedDeck is a shuffler that stacks the deck for each hand of a
+// scenario, dealing its players and board the cards the script gives them
+type riggedDeck struct {
+	game  *Game
+	deals []ScenarioDeal
+	dealt int
+}
+
+// Shuffle puts the cards of the next deal where the table will deal them
+// from: hole cards a card at a time round the players dealt in, then the
+// board with a card burned before the flop, turn and river. It is called
+// as the table deals, so with the table locked.
+func (d *riggedDeck) Shuffle(cards []poker.Card) {
+	if d.dealt >= len(d.deals) {
+		return
+	}
+	script := d.deals[d.dealt]
+	d.dealt++
+
+	rigged := make(map[poker.Card]bool)
+	for _, hole := range script.Hole {
+		for _, card := range hole {
+			rigged[card] = true
+		}
+	}
+	for _, card := range script.Board {
+		rigged[card] = true
+	}
+	var rest []poker.Card
+	for _, card := range cards {
+		if !rigged[card] {
+			rest = append(rest, card)
+		}
+	}
+	next := func() poker.Card {
+		card := rest[0]
+		rest = rest[1:]
+		return card
+	}
+
+	stacked := make([]poker.Card, 0, len(cards))
+	deal := func(card poker.Card) {
+		stacked = append(stacked, card)
+		delete(rigged, card)
+	}
+	for round := 0; round < 2; round++ {
+		for _, playerID := range d.game.PlayerOrder {
+			if !d.game.Players[playerID].IsActive {
+				continue
+			}
+			if hole := script.Hole[playerID]; round < len(hole) {
+				deal(hole[round])
+			} else {
+				deal(next())
+			}
+		}
+	}
+	for i := 0; i < 5; i++ {
+		if i == 0 || i >= 3 {
+			deal(next())
+		}
+		if i < len(script.Board) {
+			deal(script.Board[i])
+		} else {
+			deal(next())
+		}
+	}
+
+	// Hole cards scripted for a player not dealt in go to the bottom
+	for _, card := range cards {
+		if rigged[card] {
+			stacked = append(stacked, card)
+		}
+	}
+	copy(cards, append(stacked, rest...))
+}
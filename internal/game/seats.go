@@ -0,0 +1,209 @@
+package game
+
+import (
+	"sort"
+
+	"github.com/sirupsen/logrus"
+)
+
+// PlayerTable is a table a player is seated at, with their seat and stack
+type PlayerTable struct {
+	GameInfo
+	SeatPosition int   `json:"seat_position"`
+	ChipCount    int64 `json:"chip_count"`
+}
+
+// PlayerTables returns the tables a player is seated at, in the order
+// they sat down
+func (m *Manager) PlayerTables(playerID string) []PlayerTable {
+	m.mu.RLock()
+	games := make([]*Game, 0, len(m.players[playerID]))
+	for _, gameID := range m.players[playerID] {
+		if game, exists := m.games[gameID]; exists {
+			games = append(games, game)
+		}
+	}
+	m.mu.RUnlock()
+
+	tables := make([]PlayerTable, 0, len(games))
+	for _, game := range games {
+		game.mu.RLock()
+		if player, seated := game.Players[playerID]; seated {
+			tables = append(tables, PlayerTable{
+				GameInfo:     *game.summary.Load(),
+				SeatPosition: player.SeatPosition,
+				ChipCount:    player.ChipCount,
+			})
+		}
+		game.mu.RUnlock()
+	}
+	return tables
+}
+
+// SeatedGames returns the IDs of the tables a player has a seat at, found
+// by looking at every table rather than at their table list, so a player
+// can be taken away from all of them even were the list wrong
+func (m *Manager) SeatedGames(playerID string) []string {
+	m.mu.RLock()
+	games := m.gameList()
+	m.mu.RUnlock()
+
+	var seated []string
+	for _, game := range games {
+		game.mu.RLock()
+		if _, exists := game.Players[playerID]; exists {
+			seated = append(seated, game.ID)
+		}
+		game.mu.RUnlock()
+	}
+	sort.Strings(seated)
+	return seated
+}
+
+// detach drops a game from a player's table list. Every way a player
+// leaves a table comes through here, so the list is what the limit on
+// tables is counted against (assumes lock is held).
+func (m *Manager) detach(playerID, gameID string) {
+	playerGames := m.players[playerID]
+	for i, gid := range playerGames {
+		if gid == gameID {
+			m.players[playerID] = append(playerGames[:i], playerGames[i+1:]...)
+			break
+		}
+	}
+	if len(m.players[playerID]) == 0 {
+		delete(m.players, playerID)
+	}
+}
+
+// reconcilePlayers drops the tables no longer open from every player's
+// table list, returning the players whose lists held one. Each should
+// already have been detached, so any found are a bug (assumes lock is
+// held).
+func (m *Manager) reconcilePlayers() []string {
+	var stale []string
+	for playerID, gameIDs := range m.players {
+		open := gameIDs[:0]
+		for _, gameID := range gameIDs {
+			if _, exists := m.games[gameID]; exists {
+				open = append(open, gameID)
+			}
+		}
+		if len(open) == len(gameIDs) {
+			continue
+		}
+
+		stale = append(stale, playerID)
+		if len(open) == 0 {
+			delete(m.players, playerID)
+		} else {
+			m.players[playerID] = open
+		}
+	}
+
+	if len(stale) > 0 {
+		sort.Strings(stale)
+		logrus.WithField("players", stale).Warn("Dropped closed tables from players' table lists")
+	}
+	return stale
+}
+
+// seatKeeper cashes out and detaches the players unseated as a hand ends,
+// once it has been paid
+type seatKeeper struct {
+	NopObserver
+	manager *Manager
+}
+
+func (k seatKeeper) OnHandResult(hand CompletedHand) {
+	m := k.manager
+	m.mu.Lock()
+	var stacks map[string]int64
+	if game, exists := m.games[hand.GameID]; exists {
+		stacks = m.departures(game)
+	}
+	m.mu.Unlock()
+
+	m.cashOut(stacks)
+}
+
+// departures takes the players a table has unseated as its hands ended and
+// detaches each, returning what they left with for them to be cashed out
+// once the manager is unlocked (assumes lock is held)
+func (m *Manager) departures(game *Game) map[string]int64 {
+	game.mu.Lock()
+	departed := game.departed
+	game.departed = nil
+	game.mu.Unlock()
+
+	for playerID := range departed {
+		m.detach(playerID, game.ID)
+	}
+	return departed
+}
+
+// leave takes a player away from the table for good, folding them first if
+// it is their turn, as RemovePlayer does. A player still in the hand after
+// that plays it out from where they left it, and is cashed out once it is
+// paid. Anyone else is cashed out now, with the stack returned. Between
+// hands their seat is freed at once, which it reports, and otherwise as
+// the hand ends.
+func (g *Game) leave(playerID string) (int64, bool, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.closed {
+		return 0, false, ErrGameNotFound
+	}
+	player, exists := g.Players[playerID]
+	if !exists {
+		return 0, false, ErrPlayerNotInGame
+	}
+
+	if g.getCurrentPlayerID() == playerID && g.handInProgress() && player.CanAct() {
+		if err := g.processAction(playerID, Fold, 0); err != nil {
+			return 0, false, err
+		}
+	}
+
+	player.Connected = false
+	player.IsActive = false
+	player.held = false
+	g.LastActivity = g.clock.Now()
+	defer g.stateChanged()
+
+	var stack int64
+	if !g.handInProgress() || player.HasFolded {
+		stack = player.cashable()
+		player.ChipCount = 0
+		player.botChips = 0
+	}
+	if g.handInProgress() {
+		player.leaving = true
+		return stack, false, nil
+	}
+
+	g.removeEliminatedPlayers()
+	g.seatBots()
+	return stack, true, nil
+}
+
+// recordDepartures cashes out the players who left during the hand just
+// paid, and keeps them, with anyone away it left with no chips, for the
+// manager to detach before they are unseated. A player it knocked out who
+// is still at the table keeps their seat until they leave (assumes lock is
+// held).
+func (g *Game) recordDepartures() {
+	for _, player := range g.Players {
+		if player.bot != nil || !player.leaving && (player.Connected || player.ChipCount > 0) {
+			continue
+		}
+		if g.departed == nil {
+			g.departed = make(map[string]int64)
+		}
+		g.departed[player.ID] += player.cashable()
+		player.ChipCount = 0
+		player.botChips = 0
+		player.leaving = false
+	}
+}
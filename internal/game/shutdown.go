@@ -0,0 +1,156 @@
+package game
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// shutdownPoll is how often Shutdown looks for hands still being played
+	shutdownPoll = 20 * time.Millisecond
+	// shutdownNoticeWait bounds how long Shutdown waits for players to be
+	// sent their tables' final state
+	shutdownNoticeWait = time.Second
+)
+
+// Shutdown stops the tables for the server to exit. From then on no table
+// opens or seats another player, and none deals another hand. The hands
+// being played are left to finish until ctx is done, and those still going
+// then are voided with every bet returned. Each table is then stored as it
+// stands, without closing it, so RestoreTables reopens it with its seats
+// held, and its players are sent its final state. It returns ctx's error if
+// any hand had to be voided.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	m.stopped = true
+	games := m.gameList()
+	m.mu.Unlock()
+
+	for _, game := range games {
+		game.pause()
+	}
+
+	waitUntil(ctx, func() bool {
+		for _, game := range games {
+			if game.playing() {
+				return false
+			}
+		}
+		return true
+	})
+
+	// Tables opened since are paused as they open
+	m.mu.RLock()
+	games = m.gameList()
+	m.mu.RUnlock()
+
+	var voided []string
+	for _, game := range games {
+		if game.suspend() {
+			voided = append(voided, game.ID)
+		}
+	}
+
+	noticesCtx, cancel := context.WithTimeout(context.Background(), shutdownNoticeWait)
+	defer cancel()
+	waitUntil(noticesCtx, func() bool {
+		for _, game := range games {
+			if !game.notices.idle() {
+				return false
+			}
+		}
+		return true
+	})
+
+	if len(voided) > 0 {
+		return fmt.Errorf("voided the hands being played at %s: %w", strings.Join(voided, ", "), ctx.Err())
+	}
+	return nil
+}
+
+// stopping reports whether the manager is shutting down
+func (m *Manager) stopping() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.stopped
+}
+
+// gameList returns the open tables (assumes lock is held)
+func (m *Manager) gameList() []*Game {
+	games := make([]*Game, 0, len(m.games))
+	for _, game := range m.games {
+		games = append(games, game)
+	}
+	return games
+}
+
+// waitUntil polls done until it reports true or ctx is done
+func waitUntil(ctx context.Context, done func() bool) {
+	ticker := time.NewTicker(shutdownPoll)
+	defer ticker.Stop()
+	for !done() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pause stops the table dealing another hand; the one being played, if
+// any, carries on
+func (g *Game) pause() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.paused = true
+	if g.nextHand != nil {
+		g.nextHand.Stop()
+		g.nextHand = nil
+	}
+}
+
+// playing reports whether a hand is being played at the table
+func (g *Game) playing() bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.handInProgress()
+}
+
+// suspend stores the table, then voids the hand still being played, if
+// any, and tells its players its state. It reports whether a hand was
+// voided.
+func (g *Game) suspend() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	// The hand is stored with its pot, for the store to count it voided
+	// when the table is reopened; the stacks stored are those before it
+	g.saveTable()
+	voided := g.voidHand()
+	if voided {
+		g.setPhase(WaitingForPlayers)
+	}
+	g.LastActivity = g.clock.Now()
+	g.stateChanged()
+	return voided
+}
+
+// voidHand calls off the hand being played, returning each player's
+// contribution to the pot. It reports whether there was one to call off
+// (assumes lock is held).
+func (g *Game) voidHand() bool {
+	if !g.handInProgress() {
+		return false
+	}
+	for _, player := range g.Players {
+		player.ChipCount += player.TotalBet
+		player.CurrentBet = 0
+		player.TotalBet = 0
+	}
+	g.Pot = 0
+	g.SidePots = nil
+	return true
+}
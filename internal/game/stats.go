@@ -0,0 +1,26 @@
+package game
+
+// Stats is a snapshot of the games the manager is running
+type Stats struct {
+	Games          int    `json:"games"`
+	SeatedPlayers  int    `json:"seated_players"`
+	HandsCompleted uint64 `json:"hands_completed"`
+}
+
+// Stats counts the running games, the players seated at them and the hands
+// completed since the manager started
+func (m *Manager) Stats() Stats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stats := Stats{
+		Games:          len(m.games),
+		HandsCompleted: m.handsCompleted.Load(),
+	}
+	for _, game := range m.games {
+		game.mu.RLock()
+		stats.SeatedPlayers += len(game.Players)
+		game.mu.RUnlock()
+	}
+	return stats
+}
@@ -0,0 +1,279 @@
+package game
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// TableStore persists live tables so they outlast the process. CreateTable
+// and ReopenTables may block; SaveTable is called with the table locked, so
+// it must hand the work off rather than block.
+type TableStore interface {
+	// CreateTable stores a new table and returns the ID it is stored under
+	CreateTable(table TableState) (string, error)
+
+	// SaveTable stores a table's state at a hand boundary or on close
+	SaveTable(table TableState)
+
+	// ReopenTables returns the tables left open by a previous run
+	ReopenTables() ([]TableState, error)
+}
+
+// TableStatus is where a table is in its life, as stored
+type TableStatus string
+
+const (
+	TableWaiting   TableStatus = "waiting"   // No hand dealt yet
+	TablePlaying   TableStatus = "playing"   // Dealing hands
+	TableFinished  TableStatus = "finished"  // Closed after playing hands
+	TableAbandoned TableStatus = "abandoned" // Closed before any hand was dealt
+)
+
+// TableState is a snapshot of a table between actions, taken when a hand
+// starts or ends and when the table closes. Its stacks are those held
+// between hands, so restoring it voids any hand in progress.
+type TableState struct {
+	ID          string
+	RecordID    string // ID the table is stored under, empty before it is stored
+	Name        string
+	Status      TableStatus
+	SmallBlind  int64
+	BigBlind    int64
+	BuyIn       int64
+	MinPlayers  int
+	MaxPlayers  int
+	TurnTimeout time.Duration
+	ClubID      string // Empty for tables open to everyone
+	Ante        int64
+	MinBuyIn    int64
+	MaxBuyIn    int64
+	TemplateID  string // Set for tables opened from a template
+	HandNumber  int
+	DealerSeat  int
+	Pot         int64 // In the hand in progress, zero between hands
+	Seats       []SeatState
+}
+
+// SeatState is one seated player's part in a TableState
+type SeatState struct {
+	PlayerID     string
+	Username     string
+	SeatPosition int
+	BuyIn        int64
+	Chips        int64
+}
+
+// SetStore registers the store live tables are persisted through, at every
+// table current and future. Tables already open are not stored until their
+// next hand.
+func (m *Manager) SetStore(store TableStore) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.store = store
+	for _, game := range m.games {
+		game.mu.Lock()
+		game.store = store
+		game.mu.Unlock()
+	}
+}
+
+// RestoreTables reopens the tables a previous run left open under their old
+// IDs, carrying on their hand numbering. Each player is seated where they
+// were with the stack they held between hands, so a hand in progress when
+// that run stopped is voided with every bet returned. Their seats are held
+// until they rejoin, and the table waits for enough of them to deal again.
+// It returns how many tables were restored.
+func (m *Manager) RestoreTables() (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.store == nil {
+		return 0, nil
+	}
+
+	tables, err := m.store.ReopenTables()
+	if err != nil {
+		return 0, fmt.Errorf("failed to reopen tables: %w", err)
+	}
+
+	restored := 0
+	for _, table := range tables {
+		if _, exists := m.games[table.ID]; exists {
+			continue
+		}
+
+		config := m.config
+		config.SmallBlind = table.SmallBlind
+		config.BigBlind = table.BigBlind
+		config.DefaultBuyIn = table.BuyIn
+		config.MinPlayersPerTable = table.MinPlayers
+		config.MaxPlayersPerTable = table.MaxPlayers
+		config.ClubID = table.ClubID
+		config.Ante = table.Ante
+		config.TemplateID = table.TemplateID
+		if table.MaxBuyIn > 0 {
+			config.MinBuyIn = table.MinBuyIn
+			config.MaxBuyIn = table.MaxBuyIn
+		}
+		if table.TurnTimeout > 0 {
+			config.TurnTimeout = table.TurnTimeout
+		}
+
+		game := m.newGame(table.ID, table.Name, config)
+		game.recordID = table.RecordID
+		game.HandNumber = table.HandNumber
+
+		seats := append([]SeatState(nil), table.Seats...)
+		sort.Slice(seats, func(i, j int) bool { return seats[i].SeatPosition < seats[j].SeatPosition })
+		for _, seat := range seats {
+			if seat.Chips <= 0 {
+				continue
+			}
+			player := NewPlayer(seat.PlayerID, seat.Username, seat.Chips, seat.SeatPosition)
+			player.buyIn = seat.BuyIn
+			player.Connected = false
+			player.IsActive = false
+			player.held = true
+			game.Players[player.ID] = player
+			game.PlayerOrder = append(game.PlayerOrder, player.ID)
+			m.players[player.ID] = append(m.players[player.ID], table.ID)
+		}
+		game.publishInfo()
+
+		m.games[table.ID] = game
+		restored++
+	}
+	m.lobbyChanged()
+
+	return restored, nil
+}
+
+// HeldSeats returns the players at each table whose seats are held for
+// them since a restart
+func (m *Manager) HeldSeats() map[string][]string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	held := make(map[string][]string)
+	for gameID, game := range m.games {
+		game.mu.RLock()
+		for _, playerID := range game.PlayerOrder {
+			if game.Players[playerID].held {
+				held[gameID] = append(held[gameID], playerID)
+			}
+		}
+		game.mu.RUnlock()
+	}
+	return held
+}
+
+// rejoin gives a player back the seat held for them since a restart,
+// dealing a hand once enough players are back. It reports whether a seat
+// was held for them.
+func (g *Game) rejoin(playerID string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	player, exists := g.Players[playerID]
+	if !exists || !player.held {
+		return false
+	}
+
+	player.held = false
+	player.Connected = true
+	player.IsActive = player.ChipCount > 0
+	g.LastActivity = g.clock.Now()
+
+	if g.readyPlayers() >= g.MinPlayers && g.Phase == WaitingForPlayers {
+		g.startNewHand()
+	}
+	g.stateChanged()
+	return true
+}
+
+// RecordID returns the ID the table is stored under, or an empty string
+// when it is not stored
+func (g *Game) RecordID() string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.recordID
+}
+
+// tableStatus returns the table's status as stored (assumes lock is held)
+func (g *Game) tableStatus() TableStatus {
+	switch {
+	case g.closed && g.HandNumber > 0:
+		return TableFinished
+	case g.closed:
+		return TableAbandoned
+	case g.HandNumber > 0:
+		return TablePlaying
+	default:
+		return TableWaiting
+	}
+}
+
+// tableState takes a snapshot of the table for its store (assumes lock is
+// held)
+func (g *Game) tableState() TableState {
+	state := TableState{
+		ID:          g.ID,
+		RecordID:    g.recordID,
+		Name:        g.Name,
+		Status:      g.tableStatus(),
+		SmallBlind:  g.SmallBlind,
+		BigBlind:    g.BigBlind,
+		BuyIn:       g.BuyIn,
+		MinPlayers:  g.MinPlayers,
+		MaxPlayers:  g.MaxPlayers,
+		TurnTimeout: g.TurnTimeout,
+		ClubID:      g.ClubID,
+		Ante:        g.Ante,
+		MinBuyIn:    g.MinBuyIn,
+		MaxBuyIn:    g.MaxBuyIn,
+		TemplateID:  g.TemplateID,
+		HandNumber:  g.HandNumber,
+	}
+	if g.handInProgress() {
+		state.Pot = g.Pot
+	}
+	if g.DealerPos < len(g.PlayerOrder) {
+		state.DealerSeat = g.Players[g.PlayerOrder[g.DealerPos]].SeatPosition
+	}
+
+	// Players who have left the table keep their seat until it is freed,
+	// but no longer hold a stake in it, and bots never do. Stacks are stored as they were
+	// before the hand in progress, which is voided if it never finishes, and
+	// without the chips won from bots, which do not outlast the table's bots.
+	for _, playerID := range g.PlayerOrder {
+		player := g.Players[playerID]
+		if !player.Connected && !player.held || player.bot != nil {
+			continue
+		}
+		chips := player.ChipCount
+		if startingChips, dealtIn := g.hand.startingChips[playerID]; dealtIn && g.handInProgress() {
+			chips = startingChips
+		}
+		chips -= min(player.botChips, chips)
+		state.Seats = append(state.Seats, SeatState{
+			PlayerID:     player.ID,
+			Username:     player.Username,
+			SeatPosition: player.SeatPosition,
+			BuyIn:        player.buyIn,
+			Chips:        chips,
+		})
+	}
+
+	return state
+}
+
+// saveTable passes the table's state to its store, if it has one and the
+// table was stored when it opened (assumes lock is held)
+func (g *Game) saveTable() {
+	if g.store == nil || g.recordID == "" {
+		return
+	}
+	g.store.SaveTable(g.tableState())
+}
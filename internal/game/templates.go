@@ -0,0 +1,182 @@
+package game
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Template describes a standard table the lobby always has open, such as
+// "Micro 25/50". Zero fields take the manager's defaults.
+type Template struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	SmallBlind int64  `json:"small_blind"`
+	BigBlind   int64  `json:"big_blind"`
+	Ante       int64  `json:"ante,omitempty"`
+	MinBuyIn   int64  `json:"min_buy_in"`
+	MaxBuyIn   int64  `json:"max_buy_in"`
+	MaxPlayers int    `json:"max_players"`
+	// Bots are seated at the template's tables while they have fewer
+	// players, as WithBots seats them
+	Bots int `json:"bots,omitempty"`
+}
+
+// WithTemplate configures a table from a template
+func WithTemplate(template Template) GameOption {
+	return func(config *GameConfig) {
+		config.TemplateID = template.ID
+		if template.SmallBlind > 0 && template.BigBlind > 0 {
+			config.SmallBlind = template.SmallBlind
+			config.BigBlind = template.BigBlind
+		}
+		if template.Ante > 0 {
+			config.Ante = template.Ante
+		}
+		if template.MaxBuyIn > 0 {
+			config.MinBuyIn = template.MinBuyIn
+			config.MaxBuyIn = template.MaxBuyIn
+			config.DefaultBuyIn = min(max(config.DefaultBuyIn, template.MinBuyIn), template.MaxBuyIn)
+		}
+		if template.MaxPlayers > 0 {
+			config.MaxPlayersPerTable = template.MaxPlayers
+		}
+		config.Bots = template.Bots
+	}
+}
+
+// SetTemplates sets the templates the manager keeps a table open for, and
+// opens a table for each that has none with a free seat. Tables of
+// templates no longer set keep running until they empty, and the open
+// tables of those still set, restored ones among them, take up their
+// number of bots.
+func (m *Manager) SetTemplates(templates []Template) error {
+	m.mu.Lock()
+	m.templates = append([]Template(nil), templates...)
+	for _, template := range m.templates {
+		for _, table := range m.templateTables(template.ID) {
+			table.setBotSeats(template.Bots)
+		}
+	}
+	m.mu.Unlock()
+
+	return m.ensureTemplateTables()
+}
+
+// Templates returns the templates the manager keeps a table open for
+func (m *Manager) Templates() []Template {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return append([]Template(nil), m.templates...)
+}
+
+// Template looks a template up by ID or, ignoring case, by name
+func (m *Manager) Template(nameOrID string) (Template, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, template := range m.templates {
+		if template.ID == nameOrID || strings.EqualFold(template.Name, nameOrID) {
+			return template, true
+		}
+	}
+	return Template{}, false
+}
+
+// ensureTemplateTables opens a table for every template whose tables are
+// all full, so each always has a seat free. The tables are stored with the
+// manager unlocked, as CreateGame stores them; one being stored counts as
+// the template's free seat meanwhile. A table that fails to open is tried
+// again the next time a template table fills or closes, or inactive tables
+// are cleaned up.
+func (m *Manager) ensureTemplateTables() error {
+	m.mu.Lock()
+	var opening []*Game
+	for _, template := range m.templates {
+		open := m.templateTables(template.ID)
+		if m.stopped || hasFreeSeat(open) || m.creatingTemplateTable(template.ID) {
+			continue
+		}
+
+		name := template.Name
+		if len(open) > 0 {
+			name = fmt.Sprintf("%s #%d", template.Name, len(open)+1)
+		}
+		config := m.config
+		WithTemplate(template)(&config)
+		opening = append(opening, m.reserveGame(uuid.NewString(), name, config))
+	}
+	m.mu.Unlock()
+
+	var errs []error
+	for _, game := range opening {
+		if err := m.storeGame(game); err != nil {
+			errs = append(errs, fmt.Errorf("template %s: %w", game.TemplateID, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to open template tables: %v", errs)
+	}
+	return nil
+}
+
+// keepOpen reports whether an empty table must stay open as its template's
+// table with a free seat (assumes lock is held)
+func (m *Manager) keepOpen(game *Game) bool {
+	if game.TemplateID == "" {
+		return false
+	}
+	known := false
+	for _, template := range m.templates {
+		known = known || template.ID == game.TemplateID
+	}
+	if !known {
+		return false
+	}
+
+	var others []*Game
+	for _, table := range m.templateTables(game.TemplateID) {
+		if table != game {
+			others = append(others, table)
+		}
+	}
+	return !hasFreeSeat(others)
+}
+
+// templateTables returns the open tables of a template (assumes lock is
+// held)
+func (m *Manager) templateTables(templateID string) []*Game {
+	var tables []*Game
+	for _, game := range m.games {
+		if game.TemplateID == templateID {
+			tables = append(tables, game)
+		}
+	}
+	return tables
+}
+
+// creatingTemplateTable reports whether a table of a template is being
+// stored before it opens (assumes lock is held)
+func (m *Manager) creatingTemplateTable(templateID string) bool {
+	for _, game := range m.creating {
+		if game.TemplateID == templateID {
+			return true
+		}
+	}
+	return false
+}
+
+// hasFreeSeat reports whether any of the tables has a seat free
+func hasFreeSeat(tables []*Game) bool {
+	for _, table := range tables {
+		table.mu.RLock()
+		free := len(table.Players) < table.MaxPlayers
+		table.mu.RUnlock()
+		if free {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package game
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer starts the spans of manager operations
+var tracer = otel.Tracer("github.com/primoPoker/server/internal/game")
+
+// startSpan starts the span of a player's operation on a game, part of
+// the trace in ctx
+func startSpan(ctx context.Context, name, gameID, playerID string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(
+		attribute.String("game_id", gameID),
+		attribute.String("player_id", playerID),
+	))
+}
+
+// endSpan ends an operation's span, marking it failed if err is set
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// lock takes the manager's lock, recording the wait as a span: an
+// operation on one table can be slow because another holds the lock
+func (m *Manager) lock(ctx context.Context) {
+	_, span := tracer.Start(ctx, "Manager.lock")
+	m.mu.Lock()
+	span.End()
+}
@@ -0,0 +1,105 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/pubsub/v2"
+	"cloud.google.com/go/pubsub/v2/apiv1/pubsubpb"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/api/option"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// broadcastSubscriptionExpiry is how long the subscription of an instance
+// that stopped without deleting it is kept unused; Pub/Sub allows no less
+const broadcastSubscriptionExpiry = 24 * time.Hour
+
+// broadcastRetention is how long a broadcast waits for an instance that
+// is slow to receive it; Pub/Sub allows no less
+const broadcastRetention = 10 * time.Minute
+
+// BroadcastFanout relays game broadcasts between instances through a
+// Pub/Sub topic. Each instance receives them through a subscription of its
+// own, created when the fanout is and deleted when it closes. A game's
+// broadcasts carry its ID as their ordering key, so each instance receives
+// them in the order they were published.
+type BroadcastFanout struct {
+	client       *pubsub.Client
+	publisher    *pubsub.Publisher
+	subscription string
+	pending      sync.WaitGroup
+}
+
+// NewBroadcastFanout creates a fanout through a topic of the project, with
+// a subscription named after the topic and instance
+func NewBroadcastFanout(ctx context.Context, projectID, topicID, instance string, opts ...option.ClientOption) (*BroadcastFanout, error) {
+	client, err := pubsub.NewClient(ctx, projectID, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pubsub client: %w", err)
+	}
+
+	topic := fmt.Sprintf("projects/%s/topics/%s", projectID, topicID)
+	subscription := fmt.Sprintf("projects/%s/subscriptions/%s-%s", projectID, topicID, instance)
+	_, err = client.SubscriptionAdminClient.CreateSubscription(ctx, &pubsubpb.Subscription{
+		Name:                     subscription,
+		Topic:                    topic,
+		EnableMessageOrdering:    true,
+		AckDeadlineSeconds:       10,
+		MessageRetentionDuration: durationpb.New(broadcastRetention),
+		ExpirationPolicy:         &pubsubpb.ExpirationPolicy{Ttl: durationpb.New(broadcastSubscriptionExpiry)},
+	})
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to create broadcast subscription: %w", err)
+	}
+
+	// Players are waiting on broadcasts, so they are batched for less time
+	// than table events
+	publisher := client.Publisher(topic)
+	publisher.EnableMessageOrdering = true
+	publisher.PublishSettings.DelayThreshold = 5 * time.Millisecond
+
+	return &BroadcastFanout{client: client, publisher: publisher, subscription: subscription}, nil
+}
+
+// Publish sends a game's broadcast to every instance. It returns without
+// waiting for Pub/Sub to accept it; a broadcast it refuses is logged.
+func (f *BroadcastFanout) Publish(ctx context.Context, gameID string, data []byte) error {
+	result := f.publisher.Publish(ctx, &pubsub.Message{Data: data, OrderingKey: gameID})
+
+	f.pending.Add(1)
+	go func() {
+		defer f.pending.Done()
+		if _, err := result.Get(context.Background()); err != nil {
+			logrus.WithError(err).WithField("game_id", gameID).Warn("Failed to publish game broadcast")
+			// A failure pauses the game's ordering key until resumed
+			f.publisher.ResumePublish(gameID)
+		}
+	}()
+	return nil
+}
+
+// Subscribe passes deliver every game's broadcasts until ctx is done
+func (f *BroadcastFanout) Subscribe(ctx context.Context, deliver func(gameID string, data []byte)) error {
+	return f.client.Subscriber(f.subscription).Receive(ctx, func(_ context.Context, message *pubsub.Message) {
+		deliver(message.OrderingKey, message.Data)
+		message.Ack()
+	})
+}
+
+// Close waits for Pub/Sub to accept or refuse the broadcasts published and
+// deletes the instance's subscription
+func (f *BroadcastFanout) Close() error {
+	f.publisher.Stop()
+	f.pending.Wait()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := f.client.SubscriptionAdminClient.DeleteSubscription(ctx, &pubsubpb.DeleteSubscriptionRequest{Subscription: f.subscription}); err != nil {
+		logrus.WithError(err).WithField("subscription", f.subscription).Warn("Failed to delete broadcast subscription")
+	}
+	return f.client.Close()
+}
@@ -0,0 +1,198 @@
+package gcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"cloud.google.com/go/errorreporting"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/api/option"
+
+	"github.com/primoPoker/server/internal/logging"
+)
+
+// DefaultErrorQueueSize is how many error reports are held while waiting
+// to be sent
+const DefaultErrorQueueSize = 1024
+
+// reportedFields are the log fields added to a report's message, so the
+// request, game or connection it came from can be found
+var reportedFields = []string{"request_id", "game_id", "client_id", "route"}
+
+// ErrorReportingClient sends error reports; *errorreporting.Client is one
+type ErrorReportingClient interface {
+	Report(entry errorreporting.Entry)
+	Flush()
+	Close() error
+}
+
+// ErrorReporter reports error and fatal log entries, recovered panics
+// among them, to Error Reporting. It is a logrus hook: entries are queued
+// without blocking the code that logged them and sent from the reporter's
+// own goroutine, which the client batches; when the queue is full reports
+// are dropped and counted. A fatal entry is sent and flushed before
+// logrus exits.
+type ErrorReporter struct {
+	client ErrorReportingClient
+
+	mu     sync.RWMutex
+	queue  chan errorreporting.Entry
+	closed bool
+	done   chan struct{}
+
+	reported atomic.Uint64
+	dropped  atomic.Uint64
+}
+
+// ErrorReporterStats counts the errors reported and those dropped because
+// the queue was full
+type ErrorReporterStats struct {
+	Reported uint64 `json:"reported"`
+	Dropped  uint64 `json:"dropped"`
+}
+
+// NewErrorReporter creates a reporter to Error Reporting in the project,
+// naming the service the errors came from
+func NewErrorReporter(ctx context.Context, projectID, service string, opts ...option.ClientOption) (*ErrorReporter, error) {
+	client, err := errorreporting.NewClient(ctx, projectID, errorreporting.Config{
+		ServiceName: service,
+		OnError: func(err error) {
+			logrus.WithError(err).Warn("Failed to send error report")
+		},
+	}, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create error reporting client: %w", err)
+	}
+	return NewErrorReporterWithClient(client, DefaultErrorQueueSize), nil
+}
+
+// NewErrorReporterWithClient creates a reporter sending through client,
+// holding up to queueSize reports in memory
+func NewErrorReporterWithClient(client ErrorReportingClient, queueSize int) *ErrorReporter {
+	if queueSize <= 0 {
+		queueSize = DefaultErrorQueueSize
+	}
+	return &ErrorReporter{
+		client: client,
+		queue:  make(chan errorreporting.Entry, queueSize),
+		done:   make(chan struct{}),
+	}
+}
+
+// Run sends queued reports until the reporter is closed
+func (r *ErrorReporter) Run() {
+	defer close(r.done)
+	for entry := range r.queue {
+		r.client.Report(entry)
+		r.reported.Add(1)
+	}
+}
+
+// Levels returns the levels reported
+func (r *ErrorReporter) Levels() []logrus.Level {
+	return []logrus.Level{logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel}
+}
+
+// Fire queues a report of a log entry. It never blocks: when the queue is
+// full the report is dropped.
+func (r *ErrorReporter) Fire(entry *logrus.Entry) error {
+	report := newErrorReport(entry)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return nil
+	}
+
+	// logrus exits once the hooks of a fatal entry have run, so it is
+	// sent at once, after whatever is already waiting
+	if entry.Level <= logrus.FatalLevel {
+		r.drain()
+		r.client.Report(report)
+		r.reported.Add(1)
+		r.client.Flush()
+		return nil
+	}
+
+	select {
+	case r.queue <- report:
+	default:
+		// Logged below error level, so it is not reported in turn
+		if r.dropped.Add(1)%100 == 1 {
+			logrus.WithField("dropped", r.dropped.Load()).Warn("Error report queue is full, dropping reports")
+		}
+	}
+	return nil
+}
+
+// drain sends the reports queued so far
+func (r *ErrorReporter) drain() {
+	for {
+		select {
+		case entry := <-r.queue:
+			r.client.Report(entry)
+			r.reported.Add(1)
+		default:
+			return
+		}
+	}
+}
+
+// Stats returns the reporter's counters
+func (r *ErrorReporter) Stats() ErrorReporterStats {
+	return ErrorReporterStats{
+		Reported: r.reported.Load(),
+		Dropped:  r.dropped.Load(),
+	}
+}
+
+// Close stops accepting reports and sends the queued ones
+func (r *ErrorReporter) Close() error {
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.queue)
+	}
+	r.mu.Unlock()
+
+	<-r.done
+	return r.client.Close()
+}
+
+// newErrorReport builds the report of a log entry. A recovered panic
+// carries the stack it was raised on; any other entry gets the stack it
+// was logged from.
+func newErrorReport(entry *logrus.Entry) errorreporting.Entry {
+	message := entry.Message
+	if err, ok := entry.Data[logrus.ErrorKey].(error); ok {
+		message += ": " + err.Error()
+	}
+	if recovered, ok := entry.Data["panic"]; ok {
+		message += ": " + fmt.Sprint(recovered)
+	}
+
+	var fields []string
+	for _, field := range reportedFields {
+		if value, ok := entry.Data[field]; ok {
+			fields = append(fields, fmt.Sprintf("%s=%v", field, value))
+		}
+	}
+	if len(fields) > 0 {
+		message += " [" + strings.Join(fields, " ") + "]"
+	}
+
+	report := errorreporting.Entry{Error: errors.New(message)}
+	report.User, _ = entry.Data["user_id"].(string)
+	if stack, ok := entry.Data[logging.StackField].(string); ok {
+		report.Stack = []byte(stack)
+	} else {
+		report.Stack = debug.Stack()
+	}
+	return report
+}
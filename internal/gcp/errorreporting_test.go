@@ -0,0 +1,105 @@
+package gcp
+
+import (
+	"io"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/errorreporting"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/internal/logging"
+)
+
+// fakeErrorReporting keeps the reports it is sent
+type fakeErrorReporting struct {
+	mu      sync.Mutex
+	reports []errorreporting.Entry
+	flushes int
+	closed  bool
+}
+
+func (f *fakeErrorReporting) Report(entry errorreporting.Entry) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reports = append(f.reports, entry)
+}
+
+func (f *fakeErrorReporting) Flush() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.flushes++
+}
+
+func (f *fakeErrorReporting) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	return nil
+}
+
+// newTestLogger returns a logger reporting to reporter and writing nowhere
+func newTestLogger(reporter *ErrorReporter) *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	logger.AddHook(reporter)
+	return logger
+}
+
+// dealHand panics the way a broken table would
+func dealHand() {
+	var deck []string
+	_ = deck[52]
+}
+
+func TestErrorReporterReportsRecoveredPanicOnce(t *testing.T) {
+	client := &fakeErrorReporting{}
+	reporter := NewErrorReporterWithClient(client, 16)
+	go reporter.Run()
+	logger := newTestLogger(reporter)
+
+	func() {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				logging.Panic(logger.WithFields(logrus.Fields{
+					"request_id": "req-7",
+					"user_id":    "alice",
+					"game_id":    "table-1",
+				}), recovered)
+			}
+		}()
+		dealHand()
+	}()
+	logger.Warn("Not an error")
+
+	require.NoError(t, reporter.Close())
+	assert.True(t, client.closed)
+	require.Len(t, client.reports, 1)
+
+	report := client.reports[0]
+	assert.Contains(t, report.Error.Error(), "index out of range")
+	assert.Contains(t, report.Error.Error(), "request_id=req-7")
+	assert.Contains(t, report.Error.Error(), "game_id=table-1")
+	assert.Equal(t, "alice", report.User)
+	assert.Contains(t, string(report.Stack), "gcp.dealHand", "the stack is the one that panicked")
+	assert.Equal(t, ErrorReporterStats{Reported: 1}, reporter.Stats())
+}
+
+func TestErrorReporterDropsWhenQueueIsFull(t *testing.T) {
+	client := &fakeErrorReporting{}
+	reporter := NewErrorReporterWithClient(client, 1)
+	logger := newTestLogger(reporter)
+
+	// Not running, so the queue fills at once and logging carries on
+	logger.Error("first")
+	logger.Error("second")
+	logger.Error("third")
+	assert.Equal(t, uint64(2), reporter.Stats().Dropped)
+
+	go reporter.Run()
+	require.NoError(t, reporter.Close())
+	require.Len(t, client.reports, 1)
+	assert.Equal(t, "first", client.reports[0].Error.Error())
+}
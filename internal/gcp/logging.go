@@ -3,107 +3,169 @@ package gcp
 import (
 	"context"
 	"fmt"
+	"os"
+	"sync"
 
 	"cloud.google.com/go/logging"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/api/option"
 )
 
-// CloudLogger wraps Google Cloud Logging
+// LoggingClient sends entries to Cloud Logging. It is implemented by
+// CloudLogger, and faked in tests.
+type LoggingClient interface {
+	Log(entry logging.Entry)
+	Flush() error
+	Close() error
+}
+
+// CloudLogger writes to a log in Cloud Logging. Entries are buffered and
+// sent in the background.
 type CloudLogger struct {
 	client *logging.Client
 	logger *logging.Logger
 }
 
-// NewCloudLogger creates a new Cloud Logging client
-func NewCloudLogger(ctx context.Context, projectID, logName string) (*CloudLogger, error) {
-	client, err := logging.NewClient(ctx, projectID)
+// NewCloudLogger creates a Cloud Logging client writing to the log called
+// logName in the project
+func NewCloudLogger(ctx context.Context, projectID, logName string, opts ...option.ClientOption) (*CloudLogger, error) {
+	client, err := logging.NewClient(ctx, projectID, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create logging client: %w", err)
 	}
 
-	logger := client.Logger(logName)
+	// Failing to send entries cannot be logged through logrus, which
+	// would only send more of them
+	client.OnError = func(err error) {
+		fmt.Fprintf(os.Stderr, "failed to send logs to cloud logging: %v\n", err)
+	}
 
 	return &CloudLogger{
 		client: client,
-		logger: logger,
+		logger: client.Logger(logName),
 	}, nil
 }
 
-// LogEntry represents a structured log entry
-type LogEntry struct {
-	Severity string                 `json:"severity"`
-	Message  string                 `json:"message"`
-	Labels   map[string]string      `json:"labels,omitempty"`
-	Data     map[string]interface{} `json:"data,omitempty"`
+// Log buffers an entry to be sent
+func (cl *CloudLogger) Log(entry logging.Entry) {
+	cl.logger.Log(entry)
 }
 
-// Log sends a structured log entry to Cloud Logging
-func (cl *CloudLogger) Log(entry LogEntry) {
-	logEntry := logging.Entry{
-		Severity: parseSeverity(entry.Severity),
-		Payload:  entry,
-		Labels:   entry.Labels,
-	}
-
-	cl.logger.Log(logEntry)
+// Flush sends the buffered entries
+func (cl *CloudLogger) Flush() error {
+	return cl.logger.Flush()
 }
 
-// Close closes the logging client
+// Close sends the buffered entries and closes the client
 func (cl *CloudLogger) Close() error {
 	return cl.client.Close()
 }
 
-// parseSeverity converts string severity to logging.Severity
-func parseSeverity(s string) logging.Severity {
-	switch s {
-	case "debug":
-		return logging.Debug
-	case "info":
-		return logging.Info
-	case "warn", "warning":
-		return logging.Warning
-	case "error":
-		return logging.Error
-	case "critical":
-		return logging.Critical
-	default:
-		return logging.Info
-	}
-}
+// labelFields are the entry fields also set as labels, so the logs of a
+// request or a table can be picked out
+var labelFields = []string{"request_id", "game_id"}
 
-// CloudLogrusHook is a logrus hook for Cloud Logging
+// CloudLogrusHook sends logrus entries to Cloud Logging, with each level
+// mapped to its severity
 type CloudLogrusHook struct {
-	cloudLogger *CloudLogger
+	client LoggingClient
+	labels map[string]string
+
+	mu     sync.RWMutex
+	closed bool
 }
 
-// NewCloudLogrusHook creates a new logrus hook for Cloud Logging
-func NewCloudLogrusHook(cloudLogger *CloudLogger) *CloudLogrusHook {
-	return &CloudLogrusHook{
-		cloudLogger: cloudLogger,
+// NewCloudLogrusHook creates a hook sending entries through client, each
+// labelled with labels. Labels with no value are left out.
+func NewCloudLogrusHook(client LoggingClient, labels map[string]string) *CloudLogrusHook {
+	defaults := make(map[string]string, len(labels))
+	for key, value := range labels {
+		if value != "" {
+			defaults[key] = value
+		}
 	}
+	return &CloudLogrusHook{client: client, labels: defaults}
 }
 
-// Fire is called when a log entry is fired
+// Fire sends an entry. A fatal or panicking entry is sent at once, since
+// the process is about to stop.
 func (hook *CloudLogrusHook) Fire(entry *logrus.Entry) error {
-	logEntry := LogEntry{
-		Severity: entry.Level.String(),
-		Message:  entry.Message,
-		Labels: map[string]string{
-			"service": "primopoker",
-		},
-		Data: make(map[string]interface{}),
+	hook.mu.RLock()
+	defer hook.mu.RUnlock()
+	if hook.closed {
+		return nil
+	}
+
+	labels := make(map[string]string, len(hook.labels)+len(labelFields))
+	for key, value := range hook.labels {
+		labels[key] = value
+	}
+	for _, field := range labelFields {
+		if value, ok := entry.Data[field]; ok {
+			labels[field] = fmt.Sprint(value)
+		}
 	}
 
-	// Add fields as data
-	for k, v := range entry.Data {
-		logEntry.Data[k] = v
+	payload := make(map[string]interface{}, len(entry.Data)+1)
+	for key, value := range entry.Data {
+		// Errors would otherwise be encoded as empty objects
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		payload[key] = value
 	}
+	payload["message"] = entry.Message
+
+	hook.client.Log(logging.Entry{
+		Timestamp: entry.Time,
+		Severity:  severity(entry.Level),
+		Labels:    labels,
+		Payload:   payload,
+	})
 
-	hook.cloudLogger.Log(logEntry)
+	if entry.Level <= logrus.FatalLevel {
+		return hook.client.Flush()
+	}
 	return nil
 }
 
-// Levels returns the available logging levels
+// Levels returns every level; the logger's own level filters entries
+// before they reach the hook
 func (hook *CloudLogrusHook) Levels() []logrus.Level {
 	return logrus.AllLevels
 }
+
+// Close sends the entries still buffered and closes the client. Entries
+// logged afterwards only go to the logger's output.
+func (hook *CloudLogrusHook) Close() error {
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	if hook.closed {
+		return nil
+	}
+	hook.closed = true
+
+	flushErr := hook.client.Flush()
+	if err := hook.client.Close(); err != nil {
+		return err
+	}
+	return flushErr
+}
+
+// severity maps a logrus level to a Cloud Logging severity
+func severity(level logrus.Level) logging.Severity {
+	switch level {
+	case logrus.PanicLevel:
+		return logging.Alert
+	case logrus.FatalLevel:
+		return logging.Critical
+	case logrus.ErrorLevel:
+		return logging.Error
+	case logrus.WarnLevel:
+		return logging.Warning
+	case logrus.InfoLevel:
+		return logging.Info
+	default:
+		return logging.Debug
+	}
+}
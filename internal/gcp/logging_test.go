@@ -0,0 +1,111 @@
+package gcp
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/logging"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLogging keeps the entries it is sent
+type fakeLogging struct {
+	mu      sync.Mutex
+	entries []logging.Entry
+	flushes int
+	closed  bool
+}
+
+func (f *fakeLogging) Log(entry logging.Entry) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.entries = append(f.entries, entry)
+}
+
+func (f *fakeLogging) Flush() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.flushes++
+	return nil
+}
+
+func (f *fakeLogging) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	return nil
+}
+
+func TestCloudLogrusHookMapsFields(t *testing.T) {
+	client := &fakeLogging{}
+	hook := NewCloudLogrusHook(client, map[string]string{
+		"service":  "primopoker",
+		"revision": "primopoker-00042-abc",
+		"instance": "instance-1",
+		"region":   "",
+	})
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	logger.AddHook(hook)
+
+	logger.WithFields(logrus.Fields{
+		"request_id": "req-1",
+		"game_id":    "table-7",
+		"seat":       3,
+	}).WithError(errors.New("seat taken")).Warn("Failed to join game")
+	logger.Info("Server started")
+
+	require.Len(t, client.entries, 2)
+	entry := client.entries[0]
+	assert.Equal(t, logging.Warning, entry.Severity)
+	assert.Equal(t, map[string]string{
+		"service":    "primopoker",
+		"revision":   "primopoker-00042-abc",
+		"instance":   "instance-1",
+		"request_id": "req-1",
+		"game_id":    "table-7",
+	}, entry.Labels, "labels with no value are left out")
+	assert.Equal(t, map[string]interface{}{
+		"message":    "Failed to join game",
+		"request_id": "req-1",
+		"game_id":    "table-7",
+		"seat":       3,
+		"error":      "seat taken",
+	}, entry.Payload)
+	assert.False(t, entry.Timestamp.IsZero())
+
+	assert.Equal(t, logging.Info, client.entries[1].Severity)
+	assert.Equal(t, map[string]string{
+		"service":  "primopoker",
+		"revision": "primopoker-00042-abc",
+		"instance": "instance-1",
+	}, client.entries[1].Labels)
+	assert.Zero(t, client.flushes, "entries are sent in the background")
+}
+
+func TestCloudLogrusHookFlushes(t *testing.T) {
+	client := &fakeLogging{}
+	hook := NewCloudLogrusHook(client, nil)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	logger.ExitFunc = func(int) {}
+	logger.AddHook(hook)
+
+	logger.Fatal("Failed to connect to database")
+	require.Len(t, client.entries, 1)
+	assert.Equal(t, logging.Critical, client.entries[0].Severity)
+	assert.Equal(t, 1, client.flushes, "a fatal entry is sent before the process exits")
+
+	require.NoError(t, hook.Close())
+	assert.Equal(t, 2, client.flushes)
+	assert.True(t, client.closed)
+
+	logger.Info("Closing database")
+	assert.Len(t, client.entries, 1, "nothing is sent once closed")
+	require.NoError(t, hook.Close())
+	assert.Equal(t, 2, client.flushes)
+}
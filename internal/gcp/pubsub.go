@@ -0,0 +1,286 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"cloud.google.com/go/pubsub/v2"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/api/option"
+
+	"github.com/primoPoker/server/internal/game"
+	"github.com/primoPoker/server/pkg/poker"
+)
+
+// DefaultEventQueueSize is how many table events may wait to be published
+const DefaultEventQueueSize = 4096
+
+// publishTimeout bounds how long a published event is retried before it
+// is counted as failed
+const publishTimeout = time.Minute
+
+// EventPublisher publishes table events to a Pub/Sub topic as JSON. Events
+// are queued without blocking and published from the publisher's own
+// goroutine, so a Pub/Sub outage never holds up a table; when the queue is
+// full events are dropped and counted. Each game's events carry its ID as
+// their ordering key, so subscribers see a table's events in order. It
+// implements game.EventObserver.
+type EventPublisher struct {
+	client    *pubsub.Client
+	publisher *pubsub.Publisher
+
+	mu      sync.RWMutex
+	queue   chan game.Event
+	closed  bool
+	done    chan struct{}
+	pending sync.WaitGroup
+
+	published atomic.Uint64
+	dropped   atomic.Uint64
+	failed    atomic.Uint64
+}
+
+// PublisherStats counts the events published, dropped because the queue
+// was full, and given up on after Pub/Sub refused them
+type PublisherStats struct {
+	Published uint64 `json:"published"`
+	Dropped   uint64 `json:"dropped"`
+	Failed    uint64 `json:"failed"`
+}
+
+// NewEventPublisher creates a publisher to a topic of the project, holding
+// up to queueSize events in memory
+func NewEventPublisher(ctx context.Context, projectID, topicID string, queueSize int, opts ...option.ClientOption) (*EventPublisher, error) {
+	client, err := pubsub.NewClient(ctx, projectID, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pubsub client: %w", err)
+	}
+
+	if queueSize <= 0 {
+		queueSize = DefaultEventQueueSize
+	}
+	publisher := client.Publisher(topicID)
+	publisher.EnableMessageOrdering = true
+	publisher.PublishSettings.DelayThreshold = 50 * time.Millisecond
+	publisher.PublishSettings.Timeout = publishTimeout
+
+	return &EventPublisher{
+		client:    client,
+		publisher: publisher,
+		queue:     make(chan game.Event, queueSize),
+		done:      make(chan struct{}),
+	}, nil
+}
+
+// Run publishes queued events until the publisher is closed
+func (p *EventPublisher) Run() {
+	defer close(p.done)
+	for event := range p.queue {
+		p.publish(event)
+	}
+}
+
+// TableEvent queues an event to be published. It never blocks: when the
+// queue is full the event is dropped.
+func (p *EventPublisher) TableEvent(event game.Event) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return
+	}
+
+	select {
+	case p.queue <- event:
+	default:
+		if p.dropped.Add(1)%100 == 1 {
+			logrus.WithFields(logrus.Fields{
+				"game_id": event.GameID,
+				"type":    event.Type,
+				"dropped": p.dropped.Load(),
+			}).Error("Pub/Sub event queue is full, dropping events")
+		}
+	}
+}
+
+// Stats returns the publisher's counters
+func (p *EventPublisher) Stats() PublisherStats {
+	return PublisherStats{
+		Published: p.published.Load(),
+		Dropped:   p.dropped.Load(),
+		Failed:    p.failed.Load(),
+	}
+}
+
+// Close stops accepting events, publishes the queued ones and waits for
+// Pub/Sub to accept or refuse them
+func (p *EventPublisher) Close() error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+
+	<-p.done
+	p.publisher.Stop()
+	p.pending.Wait()
+	return p.client.Close()
+}
+
+// publish sends one event. The client batches and retries it; the result
+// is awaited on the side so later events are not held up.
+func (p *EventPublisher) publish(event game.Event) {
+	data, err := json.Marshal(newPublishedEvent(event))
+	if err != nil {
+		p.failed.Add(1)
+		logrus.WithError(err).WithField("game_id", event.GameID).Error("Failed to encode table event")
+		return
+	}
+
+	result := p.publisher.Publish(context.Background(), &pubsub.Message{
+		Data:        data,
+		OrderingKey: event.GameID,
+		Attributes:  map[string]string{"type": string(event.Type), "game_id": event.GameID},
+	})
+
+	p.pending.Add(1)
+	go func() {
+		defer p.pending.Done()
+		if _, err := result.Get(context.Background()); err != nil {
+			p.failed.Add(1)
+			logrus.WithError(err).WithFields(logrus.Fields{
+				"game_id": event.GameID,
+				"type":    event.Type,
+			}).Warn("Failed to publish table event")
+			// A failure pauses the game's ordering key until resumed
+			p.publisher.ResumePublish(event.GameID)
+			return
+		}
+		p.published.Add(1)
+	}()
+}
+
+// publishedEvent is the JSON form of a table event on the topic
+type publishedEvent struct {
+	Type   game.EventType   `json:"type"`
+	GameID string           `json:"game_id"`
+	Time   time.Time        `json:"time"`
+	Table  *publishedTable  `json:"table,omitempty"`
+	Hand   *publishedHand   `json:"hand,omitempty"`
+	Player *publishedPlayer `json:"player,omitempty"`
+}
+
+type publishedTable struct {
+	Name       string           `json:"name"`
+	Status     game.TableStatus `json:"status"`
+	SmallBlind int64            `json:"small_blind"`
+	BigBlind   int64            `json:"big_blind"`
+	BuyIn      int64            `json:"buy_in"`
+	MaxPlayers int              `json:"max_players"`
+	ClubID     string           `json:"club_id,omitempty"`
+	HandNumber int              `json:"hand_number"`
+	Seats      []publishedSeat  `json:"seats"`
+}
+
+type publishedSeat struct {
+	PlayerID string `json:"player_id"`
+	Username string `json:"username"`
+	Seat     int    `json:"seat"`
+	Chips    int64  `json:"chips"`
+}
+
+type publishedHand struct {
+	Number         int               `json:"number"`
+	Pot            int64             `json:"pot"`
+	CommunityCards []poker.Card      `json:"community_cards"`
+	StartedAt      time.Time         `json:"started_at"`
+	FinishedAt     time.Time         `json:"finished_at"`
+	Players        []publishedPlayer `json:"players"`
+}
+
+type publishedPlayer struct {
+	ID             string         `json:"id"`
+	Username       string         `json:"username"`
+	Seat           int            `json:"seat"`
+	Position       poker.Position `json:"position,omitempty"`
+	StartingChips  int64          `json:"starting_chips"`
+	EndingChips    int64          `json:"ending_chips"`
+	AmountWon      int64          `json:"amount_won"`
+	Folded         bool           `json:"folded"`
+	WentToShowdown bool           `json:"went_to_showdown"`
+	HandRank       string         `json:"hand_rank,omitempty"`
+	BestHand       []poker.Card   `json:"best_hand,omitempty"`
+}
+
+// newPublishedEvent converts an event to the form published. Hole cards
+// are left out of hand events: the topic is read by other services, and
+// only players who showed down reveal their hand.
+func newPublishedEvent(event game.Event) publishedEvent {
+	published := publishedEvent{Type: event.Type, GameID: event.GameID, Time: event.Time}
+
+	if table := event.Table; table != nil {
+		published.Table = &publishedTable{
+			Name:       table.Name,
+			Status:     table.Status,
+			SmallBlind: table.SmallBlind,
+			BigBlind:   table.BigBlind,
+			BuyIn:      table.BuyIn,
+			MaxPlayers: table.MaxPlayers,
+			ClubID:     table.ClubID,
+			HandNumber: table.HandNumber,
+			Seats:      make([]publishedSeat, 0, len(table.Seats)),
+		}
+		for _, seat := range table.Seats {
+			published.Table.Seats = append(published.Table.Seats, publishedSeat{
+				PlayerID: seat.PlayerID,
+				Username: seat.Username,
+				Seat:     seat.SeatPosition,
+				Chips:    seat.Chips,
+			})
+		}
+	}
+
+	if hand := event.Hand; hand != nil {
+		published.Hand = &publishedHand{
+			Number:         hand.HandNumber,
+			Pot:            hand.Pot,
+			CommunityCards: hand.CommunityCards,
+			StartedAt:      hand.StartedAt,
+			FinishedAt:     hand.FinishedAt,
+			Players:        make([]publishedPlayer, 0, len(hand.Players)),
+		}
+		for _, player := range hand.Players {
+			published.Hand.Players = append(published.Hand.Players, newPublishedPlayer(player))
+		}
+	}
+
+	if event.Player != nil {
+		player := newPublishedPlayer(*event.Player)
+		published.Player = &player
+	}
+	return published
+}
+
+func newPublishedPlayer(player game.HandPlayer) publishedPlayer {
+	published := publishedPlayer{
+		ID:             player.ID,
+		Username:       player.Username,
+		Seat:           player.SeatPosition,
+		Position:       player.Position,
+		StartingChips:  player.StartingChips,
+		EndingChips:    player.EndingChips,
+		AmountWon:      player.AmountWon,
+		Folded:         player.Folded,
+		WentToShowdown: player.WentToShowdown,
+	}
+	if player.BestHand != nil {
+		published.HandRank = player.BestHand.Rank.String()
+		published.BestHand = player.BestHand.Cards
+	}
+	return published
+}
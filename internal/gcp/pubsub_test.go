@@ -0,0 +1,114 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"cloud.google.com/go/pubsub/v2/apiv1/pubsubpb"
+	"cloud.google.com/go/pubsub/v2/pstest"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/primoPoker/server/internal/game"
+)
+
+// newEmulatedPublisher starts an in-process Pub/Sub emulator with the
+// topic created and returns a publisher to it
+func newEmulatedPublisher(t *testing.T, queueSize int) (*EventPublisher, *pstest.Server) {
+	t.Helper()
+	ctx := context.Background()
+
+	server := pstest.NewServer()
+	t.Cleanup(func() { server.Close() })
+	conn, err := grpc.NewClient(server.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	publisher, err := NewEventPublisher(ctx, "primopoker", "game-events", queueSize, option.WithGRPCConn(conn))
+	require.NoError(t, err)
+	_, err = publisher.client.TopicAdminClient.CreateTopic(ctx, &pubsubpb.Topic{Name: "projects/primopoker/topics/game-events"})
+	require.NoError(t, err)
+	return publisher, server
+}
+
+func TestEventPublisherPublishesTableEvents(t *testing.T) {
+	publisher, server := newEmulatedPublisher(t, 0)
+	go publisher.Run()
+
+	manager := game.NewManager()
+	manager.SetEventObserver(publisher)
+	table, err := manager.CreateGame(uuid.New().String(), "Published")
+	require.NoError(t, err)
+	require.NoError(t, manager.JoinGame(context.Background(), table.ID, "alice", "alice", 10000))
+	require.NoError(t, manager.JoinGame(context.Background(), table.ID, "bob", "bob", 10000))
+	require.NoError(t, manager.ProcessAction(context.Background(), table.ID, table.GetGameState("").CurrentPlayer, game.Fold, 0))
+	_, err = manager.CloseGame(context.Background(), table.ID)
+	require.NoError(t, err)
+
+	require.NoError(t, publisher.Close())
+	assert.Equal(t, PublisherStats{Published: 4}, publisher.Stats())
+
+	messages := server.Messages()
+	require.Len(t, messages, 4)
+	var types []string
+	for _, message := range messages {
+		assert.Equal(t, table.ID, message.OrderingKey, "a table's events are ordered by its ID")
+		assert.Equal(t, table.ID, message.Attributes["game_id"])
+		types = append(types, message.Attributes["type"])
+	}
+	assert.Equal(t, []string{"game.created", "game.started", "hand.completed", "game.finished"}, types)
+
+	var hand map[string]interface{}
+	require.NoError(t, json.Unmarshal(messages[2].Data, &hand))
+	assert.Equal(t, "hand.completed", hand["type"])
+	details := hand["hand"].(map[string]interface{})
+	assert.Equal(t, float64(1), details["number"])
+	assert.Equal(t, float64(150), details["pot"])
+	players := details["players"].([]interface{})
+	require.Len(t, players, 2)
+	assert.NotContains(t, players[0], "hole_cards", "hole cards are not published")
+
+	var finished publishedEvent
+	require.NoError(t, json.Unmarshal(messages[3].Data, &finished))
+	assert.Equal(t, game.TableFinished, finished.Table.Status)
+	assert.Len(t, finished.Table.Seats, 2)
+}
+
+func TestEventPublisherPublishesEliminations(t *testing.T) {
+	publisher, server := newEmulatedPublisher(t, 0)
+	go publisher.Run()
+
+	hand := &game.CompletedHand{GameID: "table-1", HandNumber: 12, Players: []game.HandPlayer{
+		{ID: "alice", StartingChips: 500, EndingChips: 1000, AmountWon: 1000},
+		{ID: "bob", StartingChips: 500, EndingChips: 0},
+	}}
+	publisher.TableEvent(game.Event{Type: game.EventPlayerEliminated, GameID: "table-1", Hand: hand, Player: &hand.Players[1]})
+	require.NoError(t, publisher.Close())
+
+	messages := server.Messages()
+	require.Len(t, messages, 1)
+	var event publishedEvent
+	require.NoError(t, json.Unmarshal(messages[0].Data, &event))
+	assert.Equal(t, game.EventPlayerEliminated, event.Type)
+	assert.Equal(t, "bob", event.Player.ID)
+	assert.Equal(t, 12, event.Hand.Number)
+}
+
+func TestEventPublisherDropsWhenQueueIsFull(t *testing.T) {
+	publisher, _ := newEmulatedPublisher(t, 1)
+
+	// Not running, so the queue fills at once and the table carries on
+	publisher.TableEvent(game.Event{Type: game.EventGameCreated, GameID: "table-1"})
+	publisher.TableEvent(game.Event{Type: game.EventGameStarted, GameID: "table-1"})
+	publisher.TableEvent(game.Event{Type: game.EventGameFinished, GameID: "table-1"})
+	assert.Equal(t, uint64(2), publisher.Stats().Dropped)
+
+	go publisher.Run()
+	require.NoError(t, publisher.Close())
+	assert.Equal(t, uint64(1), publisher.Stats().Published)
+}
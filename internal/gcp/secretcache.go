@@ -0,0 +1,245 @@
+package gcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultSecretRefreshInterval is how often cached secrets are fetched
+// again, and so how long a rotated secret takes to come into use
+const DefaultSecretRefreshInterval = 5 * time.Minute
+
+// Each fetch of a secret is bounded, and retried with doubling backoff
+// unless the error cannot go away by itself
+const (
+	secretFetchTimeout  = 5 * time.Second
+	secretFetchAttempts = 3
+	secretRetryBackoff  = 500 * time.Millisecond
+)
+
+// SecretAccessor reads the latest version of a secret. SecretManager is
+// one; tests supply their own.
+type SecretAccessor interface {
+	GetSecret(ctx context.Context, name string) (string, error)
+}
+
+// Secrets caches a declared set of secrets and fetches them again in the
+// background, so a secret rotated in Secret Manager comes into use within
+// the refresh interval. A secret that cannot be fetched keeps its last
+// value, and the failure is reported by Health. Secrets that do not exist
+// are left unset rather than counted as failures.
+type Secrets struct {
+	source   SecretAccessor
+	names    []string
+	interval time.Duration
+	backoff  time.Duration
+
+	mu          sync.RWMutex
+	values      map[string]string
+	failures    map[string]SecretFailure
+	watchers    map[string][]func(string)
+	refreshed   bool
+	lastRefresh time.Time
+
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// SecretFailure is why a secret could not last be fetched, and when
+type SecretFailure struct {
+	Error string    `json:"error"`
+	At    time.Time `json:"at"`
+}
+
+// SecretsHealth reports the cached secrets for the readiness probe. Status
+// is "up", or "degraded" while any secret is failing to fetch.
+type SecretsHealth struct {
+	Status      string                   `json:"status"`
+	Loaded      int                      `json:"loaded"`
+	LastRefresh time.Time                `json:"last_refresh"`
+	Failures    map[string]SecretFailure `json:"failures,omitempty"`
+}
+
+// NewSecrets creates a cache of the named secrets read from source, fetched
+// again every interval once Run is started
+func NewSecrets(source SecretAccessor, interval time.Duration, names ...string) *Secrets {
+	if interval <= 0 {
+		interval = DefaultSecretRefreshInterval
+	}
+	return &Secrets{
+		source:   source,
+		names:    names,
+		interval: interval,
+		backoff:  secretRetryBackoff,
+		values:   make(map[string]string),
+		failures: make(map[string]SecretFailure),
+		watchers: make(map[string][]func(string)),
+		stop:     make(chan struct{}),
+	}
+}
+
+// Value returns the cached value of a secret, and whether it has one
+func (s *Secrets) Value(name string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	value, ok := s.values[name]
+	return value, ok
+}
+
+// OnChange calls apply with a secret's new value each time a refresh finds
+// it has changed
+func (s *Secrets) OnChange(name string, apply func(value string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.watchers[name] = append(s.watchers[name], apply)
+}
+
+// Refresh fetches every secret, returning the errors of those that could
+// not be fetched. Watchers of the secrets that changed are called once the
+// cache is updated; the first refresh, which fills the cache, calls none.
+func (s *Secrets) Refresh(ctx context.Context) error {
+	fetched := make(map[string]string, len(s.names))
+	failed := make(map[string]error)
+	for _, name := range s.names {
+		value, err := s.fetch(ctx, name)
+		switch {
+		case status.Code(err) == codes.NotFound:
+		case err != nil:
+			failed[name] = err
+		default:
+			fetched[name] = value
+		}
+	}
+
+	s.mu.Lock()
+	now := time.Now()
+	var changed []func()
+	for name, value := range fetched {
+		if previous, ok := s.values[name]; s.refreshed && (!ok || previous != value) {
+			for _, apply := range s.watchers[name] {
+				apply, value := apply, value
+				changed = append(changed, func() { apply(value) })
+			}
+		}
+		s.values[name] = value
+		delete(s.failures, name)
+	}
+	errs := make([]error, 0, len(failed))
+	for name, err := range failed {
+		s.failures[name] = SecretFailure{Error: err.Error(), At: now}
+		errs = append(errs, err)
+	}
+	s.refreshed = true
+	s.lastRefresh = now
+	s.mu.Unlock()
+
+	for _, apply := range changed {
+		apply()
+	}
+	return errors.Join(errs...)
+}
+
+// Fetch fetches one secret now and caches it without calling its
+// watchers, for a caller that puts the new value into use itself. As in a
+// refresh, a secret that does not exist is left unset.
+func (s *Secrets) Fetch(ctx context.Context, name string) error {
+	value, err := s.fetch(ctx, name)
+	if status.Code(err) == codes.NotFound {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.failures[name] = SecretFailure{Error: err.Error(), At: time.Now()}
+		return err
+	}
+	s.values[name] = value
+	delete(s.failures, name)
+	return nil
+}
+
+// fetch reads one secret, retrying errors that may be transient
+func (s *Secrets) fetch(ctx context.Context, name string) (string, error) {
+	backoff := s.backoff
+	var value string
+	var err error
+	for attempt := 1; ; attempt++ {
+		callCtx, cancel := context.WithTimeout(ctx, secretFetchTimeout)
+		value, err = s.source.GetSecret(callCtx, name)
+		cancel()
+		if err == nil || attempt == secretFetchAttempts || !retryable(err) {
+			break
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return "", fmt.Errorf("failed to fetch secret %s: %w", name, ctx.Err())
+		}
+		backoff *= 2
+	}
+	return value, err
+}
+
+// retryable reports whether fetching a secret again might succeed
+func retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.NotFound, codes.PermissionDenied, codes.InvalidArgument, codes.Unauthenticated:
+		return false
+	}
+	return true
+}
+
+// Health reports how many secrets are cached and which are failing
+func (s *Secrets) Health() SecretsHealth {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	health := SecretsHealth{Status: "up", Loaded: len(s.values), LastRefresh: s.lastRefresh}
+	if len(s.failures) > 0 {
+		health.Status = "degraded"
+		health.Failures = make(map[string]SecretFailure, len(s.failures))
+		for name, failure := range s.failures {
+			health.Failures[name] = failure
+		}
+	}
+	return health
+}
+
+// Run refreshes the secrets every interval until Close is called
+func (s *Secrets) Run() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.Refresh(context.Background()); err != nil {
+				logrus.WithError(err).Warn("Failed to refresh secrets, keeping their last values")
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Close stops refreshing and closes the source if it can be closed
+func (s *Secrets) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		if closer, ok := s.source.(io.Closer); ok {
+			err = closer.Close()
+		}
+	})
+	return err
+}
@@ -0,0 +1,136 @@
+package gcp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeSecretManager serves secrets from a map, failing with the errors
+// queued for a secret before serving it
+type fakeSecretManager struct {
+	mu      sync.Mutex
+	secrets map[string]string
+	errs    map[string][]error
+	calls   map[string]int
+}
+
+func newFakeSecretManager(secrets map[string]string) *fakeSecretManager {
+	return &fakeSecretManager{secrets: secrets, errs: make(map[string][]error), calls: make(map[string]int)}
+}
+
+func (f *fakeSecretManager) GetSecret(ctx context.Context, name string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls[name]++
+	if errs := f.errs[name]; len(errs) > 0 {
+		f.errs[name] = errs[1:]
+		return "", errs[0]
+	}
+	value, ok := f.secrets[name]
+	if !ok {
+		return "", status.Error(codes.NotFound, "secret not found")
+	}
+	return value, nil
+}
+
+func (f *fakeSecretManager) set(name, value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.secrets[name] = value
+}
+
+func (f *fakeSecretManager) fail(name string, errs ...error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.errs[name] = append(f.errs[name], errs...)
+}
+
+func newTestSecrets(source SecretAccessor, names ...string) *Secrets {
+	secrets := NewSecrets(source, time.Minute, names...)
+	secrets.backoff = time.Millisecond
+	return secrets
+}
+
+func TestSecretsRefreshCallsWatchersOnChange(t *testing.T) {
+	source := newFakeSecretManager(map[string]string{"jwt": "first"})
+	secrets := newTestSecrets(source, "jwt", "redis")
+
+	var rotated []string
+	secrets.OnChange("jwt", func(value string) { rotated = append(rotated, value) })
+
+	require.NoError(t, secrets.Refresh(context.Background()))
+	value, ok := secrets.Value("jwt")
+	assert.True(t, ok)
+	assert.Equal(t, "first", value)
+	_, ok = secrets.Value("redis")
+	assert.False(t, ok, "a secret that does not exist is left unset")
+	assert.Empty(t, rotated, "filling the cache is not a change")
+
+	require.NoError(t, secrets.Refresh(context.Background()))
+	assert.Empty(t, rotated)
+
+	source.set("jwt", "second")
+	require.NoError(t, secrets.Refresh(context.Background()))
+	assert.Equal(t, []string{"second"}, rotated)
+	value, _ = secrets.Value("jwt")
+	assert.Equal(t, "second", value)
+
+	// A secret fetched by hand is put into use by whoever fetched it
+	source.set("jwt", "third")
+	require.NoError(t, secrets.Fetch(context.Background(), "jwt"))
+	require.NoError(t, secrets.Refresh(context.Background()))
+	assert.Equal(t, []string{"second"}, rotated)
+	value, _ = secrets.Value("jwt")
+	assert.Equal(t, "third", value)
+	assert.NoError(t, secrets.Fetch(context.Background(), "redis"))
+}
+
+func TestSecretsRetryTransientErrors(t *testing.T) {
+	source := newFakeSecretManager(map[string]string{"jwt": "secret", "db": "password"})
+	secrets := newTestSecrets(source, "jwt", "db")
+
+	source.fail("jwt", status.Error(codes.Unavailable, "try again"), status.Error(codes.DeadlineExceeded, "too slow"))
+	source.fail("db", status.Error(codes.PermissionDenied, "no access"))
+
+	err := secrets.Refresh(context.Background())
+	require.Error(t, err)
+	assert.Equal(t, 3, source.calls["jwt"], "unavailable secrets are retried")
+	value, _ := secrets.Value("jwt")
+	assert.Equal(t, "secret", value)
+	assert.Equal(t, 1, source.calls["db"], "errors that cannot clear by themselves are not retried")
+}
+
+func TestSecretsKeepLastValueAndReportFailures(t *testing.T) {
+	source := newFakeSecretManager(map[string]string{"jwt": "secret"})
+	secrets := newTestSecrets(source, "jwt")
+	require.NoError(t, secrets.Refresh(context.Background()))
+
+	health := secrets.Health()
+	assert.Equal(t, "up", health.Status)
+	assert.Equal(t, 1, health.Loaded)
+
+	unavailable := status.Error(codes.Unavailable, "secret manager down")
+	source.fail("jwt", unavailable, unavailable, unavailable)
+	err := secrets.Refresh(context.Background())
+	assert.True(t, errors.Is(err, unavailable))
+
+	value, ok := secrets.Value("jwt")
+	assert.True(t, ok)
+	assert.Equal(t, "secret", value, "a failed refresh keeps the last value")
+	health = secrets.Health()
+	assert.Equal(t, "degraded", health.Status)
+	require.Contains(t, health.Failures, "jwt")
+	assert.Contains(t, health.Failures["jwt"].Error, "secret manager down")
+
+	require.NoError(t, secrets.Refresh(context.Background()))
+	assert.Equal(t, "up", secrets.Health().Status, "a successful fetch clears the failure")
+}
@@ -3,6 +3,7 @@ package gcp
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
 	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
@@ -10,27 +11,28 @@ import (
 
 // SecretManager handles Google Cloud Secret Manager operations
 type SecretManager struct {
-	client    *secretmanager.Client
-	projectID string
+	client *secretmanager.Client
+	path   string
 }
 
-// NewSecretManager creates a new Secret Manager client
-func NewSecretManager(ctx context.Context, projectID string) (*SecretManager, error) {
+// NewSecretManager creates a new Secret Manager client reading the secrets
+// under path, such as "projects/my-project/secrets"
+func NewSecretManager(ctx context.Context, path string) (*SecretManager, error) {
 	client, err := secretmanager.NewClient(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create secret manager client: %w", err)
 	}
 
 	return &SecretManager{
-		client:    client,
-		projectID: projectID,
+		client: client,
+		path:   strings.TrimSuffix(path, "/"),
 	}, nil
 }
 
 // GetSecret retrieves a secret value from Secret Manager
 func (sm *SecretManager) GetSecret(ctx context.Context, secretName string) (string, error) {
 	// Build the resource name
-	name := fmt.Sprintf("projects/%s/secrets/%s/versions/latest", sm.projectID, secretName)
+	name := fmt.Sprintf("%s/%s/versions/latest", sm.path, secretName)
 
 	// Access the secret version
 	req := &secretmanagerpb.AccessSecretVersionRequest{
@@ -0,0 +1,87 @@
+package gcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	gcs "cloud.google.com/go/storage"
+	"google.golang.org/api/option"
+
+	"github.com/primoPoker/server/internal/storage"
+)
+
+// Storage is a Cloud Storage client, shared by the buckets taken from it
+type Storage struct {
+	client *gcs.Client
+}
+
+// NewStorage creates a Cloud Storage client
+func NewStorage(ctx context.Context, opts ...option.ClientOption) (*Storage, error) {
+	client, err := gcs.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cloud storage client: %w", err)
+	}
+	return &Storage{client: client}, nil
+}
+
+// Bucket returns the bucket called name. It implements storage.Bucket.
+func (s *Storage) Bucket(name string) *Bucket {
+	return &Bucket{handle: s.client.Bucket(name), name: name}
+}
+
+// Close closes the Cloud Storage client
+func (s *Storage) Close() error {
+	return s.client.Close()
+}
+
+// Bucket is a Cloud Storage bucket
+type Bucket struct {
+	handle *gcs.BucketHandle
+	name   string
+}
+
+// NewWriter starts uploading an object, which is stored when the writer is
+// closed
+func (b *Bucket) NewWriter(ctx context.Context, name string, opts storage.ObjectOptions) io.WriteCloser {
+	w := b.handle.Object(name).NewWriter(ctx)
+	w.ContentType = opts.ContentType
+	w.ContentEncoding = opts.ContentEncoding
+	w.ContentDisposition = opts.ContentDisposition
+	w.CacheControl = opts.CacheControl
+	return w
+}
+
+// Delete removes an object
+func (b *Bucket) Delete(ctx context.Context, name string) error {
+	err := b.handle.Object(name).Delete(ctx)
+	if errors.Is(err, gcs.ErrObjectNotExist) {
+		return storage.ErrNotFound
+	}
+	return err
+}
+
+// SignedURL signs a V4 URL to get the object until ttl has passed. Off
+// Google Cloud the credentials must hold a private key; on it, the service
+// account signs through IAM, which needs the Service Account Token Creator
+// role on itself.
+func (b *Bucket) SignedURL(ctx context.Context, name string, ttl time.Duration) (string, error) {
+	signed, err := b.handle.SignedURL(name, &gcs.SignedURLOptions{
+		Scheme:  gcs.SigningSchemeV4,
+		Method:  "GET",
+		Expires: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to sign url for %s: %w", name, err)
+	}
+	return signed, nil
+}
+
+// PublicURL returns the URL an object is served at when the bucket is
+// publicly readable
+func (b *Bucket) PublicURL(name string) string {
+	return "https://storage.googleapis.com/" + b.name + "/" + (&url.URL{Path: name}).EscapedPath()
+}
@@ -0,0 +1,84 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	cloudtasks "cloud.google.com/go/cloudtasks/apiv2"
+	"cloud.google.com/go/cloudtasks/apiv2/cloudtaskspb"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/primoPoker/server/internal/tasks"
+)
+
+// TaskQueue schedules tasks on a Cloud Tasks queue. Each is delivered when
+// it comes due as a POST of the task to the server's task endpoint, signed
+// so the endpoint can tell it from a forgery, and retried by Cloud Tasks
+// until the server accepts it. It implements tasks.Scheduler.
+type TaskQueue struct {
+	client *cloudtasks.Client
+	queue  string
+	url    string
+	key    []byte
+}
+
+// NewTaskQueue creates a scheduler on a queue of the project in region,
+// delivering tasks to the server at baseURL signed with key
+func NewTaskQueue(ctx context.Context, projectID, region, queueID, baseURL string, key []byte, opts ...option.ClientOption) (*TaskQueue, error) {
+	client, err := cloudtasks.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cloud tasks client: %w", err)
+	}
+
+	return &TaskQueue{
+		client: client,
+		queue:  fmt.Sprintf("projects/%s/locations/%s/queues/%s", projectID, region, queueID),
+		url:    strings.TrimSuffix(baseURL, "/") + tasks.Path,
+		key:    key,
+	}, nil
+}
+
+// Schedule creates a task on the queue. Cloud Tasks refuses a second task
+// under the same name, so scheduling one already there does nothing.
+func (q *TaskQueue) Schedule(ctx context.Context, task tasks.Task) error {
+	body, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("failed to encode task: %w", err)
+	}
+
+	_, err = q.client.CreateTask(ctx, &cloudtaskspb.CreateTaskRequest{
+		Parent: q.queue,
+		Task: &cloudtaskspb.Task{
+			Name:         q.queue + "/tasks/" + task.ID,
+			ScheduleTime: timestamppb.New(task.RunAt),
+			MessageType: &cloudtaskspb.Task_HttpRequest{
+				HttpRequest: &cloudtaskspb.HttpRequest{
+					HttpMethod: cloudtaskspb.HttpMethod_POST,
+					Url:        q.url + string(task.Type),
+					Headers: map[string]string{
+						"Content-Type":        "application/json",
+						tasks.SignatureHeader: tasks.Sign(q.key, body),
+					},
+					Body: body,
+				},
+			},
+		},
+	})
+	if status.Code(err) == codes.AlreadyExists {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create task %s: %w", task.ID, err)
+	}
+	return nil
+}
+
+// Close closes the Cloud Tasks client
+func (q *TaskQueue) Close() error {
+	return q.client.Close()
+}
@@ -0,0 +1,76 @@
+// Package handexport renders stored hand histories in formats consumed by
+// third-party analysis tools (PokerStars text, CSV and JSON).
+package handexport
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/primoPoker/server/internal/models"
+)
+
+// DefaultBatchSize is the number of hands read from the database per chunk
+const DefaultBatchSize = 200
+
+// HandSource is the subset of the hand history repository the exporter needs
+type HandSource interface {
+	StreamUserHands(userID uuid.UUID, from, to time.Time, batchSize int, fn func([]models.HandHistory) error) error
+	GetHandsParticipants(gameIDs []uuid.UUID, handNumbers []int) ([]models.HandHistory, error)
+}
+
+type handKey struct {
+	gameID     uuid.UUID
+	handNumber int
+}
+
+// Export streams every hand the user played between from and to into w,
+// flushing after each batch so large exports are sent as chunks. It returns
+// the number of hands written.
+func Export(w Writer, source HandSource, userID uuid.UUID, from, to time.Time) (int, error) {
+	count := 0
+
+	err := source.StreamUserHands(userID, from, to, DefaultBatchSize, func(batch []models.HandHistory) error {
+		gameIDs := make([]uuid.UUID, 0, len(batch))
+		handNumbers := make([]int, 0, len(batch))
+		for _, row := range batch {
+			gameIDs = append(gameIDs, row.GameID)
+			handNumbers = append(handNumbers, row.HandNumber)
+		}
+
+		participants, err := source.GetHandsParticipants(gameIDs, handNumbers)
+		if err != nil {
+			return fmt.Errorf("failed to load hand participants: %w", err)
+		}
+
+		rowsByHand := make(map[handKey][]models.HandHistory)
+		for _, row := range participants {
+			key := handKey{gameID: row.GameID, handNumber: row.HandNumber}
+			rowsByHand[key] = append(rowsByHand[key], row)
+		}
+
+		for _, row := range batch {
+			rows := rowsByHand[handKey{gameID: row.GameID, handNumber: row.HandNumber}]
+			if len(rows) == 0 {
+				rows = []models.HandHistory{row}
+			}
+
+			hand, err := Build(rows, userID)
+			if err != nil {
+				return err
+			}
+			if err := w.WriteHand(hand); err != nil {
+				return err
+			}
+			count++
+		}
+
+		return w.Flush()
+	})
+	if err != nil {
+		return count, err
+	}
+
+	return count, w.Close()
+}
@@ -0,0 +1,305 @@
+package handexport
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/internal/models"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+var (
+	gameID  = uuid.MustParse("6f1c2a9e-4b7d-4c1e-9a3f-2d5e8b7c6a10")
+	aliceID = uuid.MustParse("00000000-0000-0000-0000-00000000000a")
+	bobID   = uuid.MustParse("00000000-0000-0000-0000-00000000000b")
+	carolID = uuid.MustParse("00000000-0000-0000-0000-00000000000c")
+)
+
+func action(id uuid.UUID, name string, act models.PlayerAction, before, after int64) models.PlayerActionRecord {
+	return models.PlayerActionRecord{
+		PlayerID:    id,
+		Username:    name,
+		Action:      act,
+		Amount:      before - after,
+		ChipsBefore: before,
+		ChipsAfter:  after,
+	}
+}
+
+// fixtureHands returns two hands as stored per player: a three-way pot won
+// uncontested on the flop and a heads-up hand that reaches showdown
+func fixtureHands() []models.HandHistory {
+	game := models.Game{ID: gameID, Name: "Main Table", MaxPlayers: 6}
+	started := time.Date(2024, 3, 1, 18, 30, 0, 0, time.UTC)
+
+	hand1Actions := struct{ pre, flop []models.PlayerActionRecord }{
+		pre: []models.PlayerActionRecord{
+			action(aliceID, "alice", models.ActionRaise, 10000, 9700),
+			action(bobID, "bob", models.ActionFold, 9950, 9950),
+			action(carolID, "carol", models.ActionCall, 9900, 9700),
+		},
+		flop: []models.PlayerActionRecord{
+			action(carolID, "carol", models.ActionCheck, 9700, 9700),
+			action(aliceID, "alice", models.ActionBet, 9700, 9300),
+			action(carolID, "carol", models.ActionFold, 9700, 9700),
+		},
+	}
+
+	base := func(user uuid.UUID, name string, seat int) models.HandHistory {
+		return models.HandHistory{
+			GameID:         gameID,
+			UserID:         user,
+			HandNumber:     1,
+			TableName:      "Main Table",
+			DealerPosition: 0,
+			SeatPosition:   seat,
+			SmallBlind:     50,
+			BigBlind:       100,
+			FlopCard1Rank:  "2",
+			FlopCard1Suit:  "Clubs",
+			FlopCard2Rank:  "7",
+			FlopCard2Suit:  "Diamonds",
+			FlopCard3Rank:  "10",
+			FlopCard3Suit:  "Spades",
+			PotSize:        650,
+			StartedAt:      started,
+			PreFlopActions: hand1Actions.pre,
+			FlopActions:    hand1Actions.flop,
+			Game:           game,
+			User:           models.User{ID: user, Username: name},
+		}
+	}
+
+	alice := base(aliceID, "alice", 0)
+	alice.HoleCard1Rank, alice.HoleCard1Suit = "A", "Hearts"
+	alice.HoleCard2Rank, alice.HoleCard2Suit = "K", "Diamonds"
+	alice.StartingChips, alice.EndingChips = 10000, 10350
+	alice.AmountWon, alice.NetResult, alice.IsWinner = 650, 350, true
+
+	bob := base(bobID, "bob", 1)
+	bob.HoleCard1Rank, bob.HoleCard1Suit = "9", "Clubs"
+	bob.HoleCard2Rank, bob.HoleCard2Suit = "4", "Hearts"
+	bob.StartingChips, bob.EndingChips, bob.NetResult = 10000, 9950, -50
+	bob.FoldedPhase = models.HandPhasePreFlop
+
+	carol := base(carolID, "carol", 2)
+	carol.HoleCard1Rank, carol.HoleCard1Suit = "Q", "Spades"
+	carol.HoleCard2Rank, carol.HoleCard2Suit = "J", "Spades"
+	carol.StartingChips, carol.EndingChips, carol.NetResult = 10000, 9700, -300
+	carol.FoldedPhase = models.HandPhaseFlop
+
+	pre2 := []models.PlayerActionRecord{
+		action(bobID, "bob", models.ActionCall, 9950, 9900),
+		action(aliceID, "alice", models.ActionCheck, 10250, 10250),
+	}
+	flop2 := []models.PlayerActionRecord{
+		action(aliceID, "alice", models.ActionCheck, 10250, 10250),
+		action(bobID, "bob", models.ActionCheck, 9900, 9900),
+	}
+	turn2 := []models.PlayerActionRecord{
+		action(aliceID, "alice", models.ActionBet, 10250, 10050),
+		action(bobID, "bob", models.ActionCall, 9900, 9700),
+	}
+	river2 := []models.PlayerActionRecord{
+		action(aliceID, "alice", models.ActionCheck, 10050, 10050),
+		action(bobID, "bob", models.ActionCheck, 9700, 9700),
+	}
+
+	second := func(user uuid.UUID, name string, seat int) models.HandHistory {
+		return models.HandHistory{
+			GameID:         gameID,
+			UserID:         user,
+			HandNumber:     2,
+			TableName:      "Main Table",
+			DealerPosition: 1,
+			SeatPosition:   seat,
+			SmallBlind:     50,
+			BigBlind:       100,
+			FlopCard1Rank:  "K",
+			FlopCard1Suit:  "Hearts",
+			FlopCard2Rank:  "8",
+			FlopCard2Suit:  "Clubs",
+			FlopCard3Rank:  "3",
+			FlopCard3Suit:  "Spades",
+			TurnCardRank:   "J",
+			TurnCardSuit:   "Diamonds",
+			RiverCardRank:  "2",
+			RiverCardSuit:  "Hearts",
+			PotSize:        600,
+			StartedAt:      started.Add(2 * time.Minute),
+			PreFlopActions: pre2,
+			FlopActions:    flop2,
+			TurnActions:    turn2,
+			RiverActions:   river2,
+			WentToShowdown: true,
+			Game:           game,
+			User:           models.User{ID: user, Username: name},
+		}
+	}
+
+	alice2 := second(aliceID, "alice", 0)
+	alice2.HoleCard1Rank, alice2.HoleCard1Suit = "K", "Clubs"
+	alice2.HoleCard2Rank, alice2.HoleCard2Suit = "Q", "Diamonds"
+	alice2.StartingChips, alice2.EndingChips = 10350, 10650
+	alice2.AmountWon, alice2.NetResult, alice2.IsWinner = 600, 300, true
+	alice2.HandRank = "One Pair"
+
+	bob2 := second(bobID, "bob", 1)
+	bob2.HoleCard1Rank, bob2.HoleCard1Suit = "J", "Clubs"
+	bob2.HoleCard2Rank, bob2.HoleCard2Suit = "10", "Clubs"
+	bob2.StartingChips, bob2.EndingChips, bob2.NetResult = 10000, 9700, -300
+	bob2.HandRank = "One Pair"
+
+	return []models.HandHistory{alice, bob, carol, alice2, bob2}
+}
+
+// fakeSource serves fixture rows the way the repository would
+type fakeSource struct {
+	rows []models.HandHistory
+}
+
+func (f *fakeSource) StreamUserHands(userID uuid.UUID, from, to time.Time, batchSize int, fn func([]models.HandHistory) error) error {
+	var batch []models.HandHistory
+	for _, row := range f.rows {
+		if row.UserID != userID || row.StartedAt.Before(from) || row.StartedAt.After(to) {
+			continue
+		}
+		batch = append(batch, row)
+		if len(batch) == batchSize {
+			if err := fn(batch); err != nil {
+				return err
+			}
+			batch = nil
+		}
+	}
+	if len(batch) > 0 {
+		return fn(batch)
+	}
+	return nil
+}
+
+func (f *fakeSource) GetHandsParticipants(gameIDs []uuid.UUID, handNumbers []int) ([]models.HandHistory, error) {
+	var out []models.HandHistory
+	for _, row := range f.rows {
+		for i := range gameIDs {
+			if row.GameID == gameIDs[i] && row.HandNumber == handNumbers[i] {
+				out = append(out, row)
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		require.NoError(t, os.WriteFile(path, got, 0o644))
+	}
+
+	want, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(want), string(got))
+}
+
+func TestExportGolden(t *testing.T) {
+	for _, format := range []Format{FormatPokerStars, FormatCSV, FormatJSON} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			writer, err := NewWriter(&buf, format)
+			require.NoError(t, err)
+
+			source := &fakeSource{rows: fixtureHands()}
+			count, err := Export(writer, source, aliceID, time.Time{}, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+			require.NoError(t, err)
+			assert.Equal(t, 2, count)
+
+			checkGolden(t, "alice."+format.Extension()+".golden", buf.Bytes())
+		})
+	}
+}
+
+func TestExportEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	writer, err := NewWriter(&buf, FormatJSON)
+	require.NoError(t, err)
+
+	count, err := Export(writer, &fakeSource{}, aliceID, time.Time{}, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+	assert.Equal(t, "[]\n", buf.String())
+}
+
+func TestBuildHidesOpponentCardsWithoutShowdown(t *testing.T) {
+	rows := fixtureHands()[:3]
+
+	hand, err := Build(rows, aliceID)
+	require.NoError(t, err)
+
+	require.Len(t, hand.Seats, 3)
+	assert.Equal(t, []string{"Ah", "Kd"}, hand.Seats[0].HoleCards)
+	assert.Empty(t, hand.Seats[1].HoleCards)
+	assert.Empty(t, hand.Seats[2].HoleCards)
+	assert.Equal(t, []string{"2c", "7d", "Ts"}, hand.Board)
+
+	_, err = Build(rows, uuid.New())
+	assert.ErrorIs(t, err, ErrHeroNotFound)
+}
+
+func TestBuildPrefersTableHand(t *testing.T) {
+	rows := fixtureHands()[:3]
+	record := &models.Hand{
+		GameID:         gameID,
+		HandNumber:     1,
+		TableName:      "Renamed Table",
+		DealerPosition: 2,
+		SmallBlind:     25,
+		BigBlind:       50,
+		Board:          "2c 7d Ts Qh",
+		Pot:            700,
+	}
+	for i := range rows {
+		rows[i].Hand = record
+	}
+
+	hand, err := Build(rows, aliceID)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Renamed Table", hand.TableName)
+	assert.Equal(t, 3, hand.ButtonSeat)
+	assert.Equal(t, int64(50), hand.BigBlind)
+	assert.Equal(t, []string{"2c", "7d", "Ts", "Qh"}, hand.Board)
+	assert.Equal(t, int64(700), hand.Pot)
+}
+
+func TestFormatCard(t *testing.T) {
+	assert.Equal(t, "Th", FormatCard("10", "Hearts"))
+	assert.Equal(t, "As", FormatCard("A", "Spades"))
+	assert.Equal(t, "2c", FormatCard("2", "c"))
+	assert.Equal(t, "Kd", FormatCard("K", "♦"))
+	assert.Equal(t, "", FormatCard("", "Hearts"))
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := ParseFormat("")
+	require.NoError(t, err)
+	assert.Equal(t, FormatPokerStars, format)
+
+	format, err = ParseFormat("CSV")
+	require.NoError(t, err)
+	assert.Equal(t, FormatCSV, format)
+
+	_, err = ParseFormat("xml")
+	assert.Error(t, err)
+}
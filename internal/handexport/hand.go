@@ -0,0 +1,279 @@
+package handexport
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/primoPoker/server/internal/models"
+)
+
+// Street identifies a betting round within a hand
+type Street int
+
+const (
+	StreetPreFlop Street = iota
+	StreetFlop
+	StreetTurn
+	StreetRiver
+)
+
+var streetNames = []string{"Pre-Flop", "Flop", "Turn", "River"}
+
+func (s Street) String() string {
+	return streetNames[s]
+}
+
+// Hand is the table-level view of a single hand, assembled from the
+// per-player HandHistory rows that share a game ID and hand number
+type Hand struct {
+	ID         string      `json:"id"`
+	GameID     uuid.UUID   `json:"game_id"`
+	HandNumber int         `json:"hand_number"`
+	TableName  string      `json:"table_name"`
+	MaxSeats   int         `json:"max_seats"`
+	SmallBlind int64       `json:"small_blind"`
+	BigBlind   int64       `json:"big_blind"`
+	ButtonSeat int         `json:"button_seat"`
+	StartedAt  time.Time   `json:"started_at"`
+	Board      []string    `json:"board"`
+	Pot        int64       `json:"pot"`
+	HeroID     uuid.UUID   `json:"hero_id"`
+	Seats      []Seat      `json:"seats"`
+	Actions    [4][]Action `json:"actions"`
+}
+
+// Seat describes a single player's participation in the hand
+type Seat struct {
+	Seat           int              `json:"seat"`
+	UserID         uuid.UUID        `json:"user_id"`
+	Username       string           `json:"username"`
+	StartingChips  int64            `json:"starting_chips"`
+	EndingChips    int64            `json:"ending_chips"`
+	HoleCards      []string         `json:"hole_cards,omitempty"`
+	AmountWon      int64            `json:"amount_won"`
+	NetResult      int64            `json:"net_result"`
+	IsWinner       bool             `json:"is_winner"`
+	WentToShowdown bool             `json:"went_to_showdown"`
+	FoldedPhase    models.HandPhase `json:"folded_phase,omitempty"`
+	HandRank       string           `json:"hand_rank,omitempty"`
+}
+
+// Action is a single betting action; Amount is the number of chips the
+// action put into the pot
+type Action struct {
+	Username string              `json:"username"`
+	Action   models.PlayerAction `json:"action"`
+	Amount   int64               `json:"amount"`
+}
+
+// ErrHeroNotFound is returned when the hero has no row in the supplied hand
+var ErrHeroNotFound = errors.New("hero not found in hand")
+
+// Build assembles a Hand from every player's HandHistory rows for one hand.
+// The action sequence and board are taken from the hero's row, and hole
+// cards of other players are only kept when they went to showdown.
+func Build(rows []models.HandHistory, heroID uuid.UUID) (*Hand, error) {
+	var hero *models.HandHistory
+	for i := range rows {
+		if rows[i].UserID == heroID {
+			hero = &rows[i]
+			break
+		}
+	}
+	if hero == nil {
+		return nil, ErrHeroNotFound
+	}
+
+	hand := &Hand{
+		ID:         HandID(hero.GameID, hero.HandNumber),
+		GameID:     hero.GameID,
+		HandNumber: hero.HandNumber,
+		TableName:  hero.TableName,
+		MaxSeats:   hero.Game.MaxPlayers,
+		SmallBlind: hero.SmallBlind,
+		BigBlind:   hero.BigBlind,
+		ButtonSeat: hero.DealerPosition + 1,
+		StartedAt:  hero.StartedAt.UTC(),
+		Board:      boardCards(hero),
+		Pot:        hero.PotSize,
+		HeroID:     heroID,
+	}
+	if hand.MaxSeats == 0 {
+		hand.MaxSeats = 10
+	}
+	if record := hero.Hand; record != nil {
+		hand.TableName = record.TableName
+		hand.SmallBlind = record.SmallBlind
+		hand.BigBlind = record.BigBlind
+		hand.ButtonSeat = record.DealerPosition + 1
+		hand.Board = record.BoardCards()
+		hand.Pot = record.Pot
+	}
+	if hand.TableName == "" {
+		hand.TableName = hero.Game.Name
+	}
+
+	for _, row := range rows {
+		seat := Seat{
+			Seat:           row.SeatPosition + 1,
+			UserID:         row.UserID,
+			Username:       row.User.Username,
+			StartingChips:  row.StartingChips,
+			EndingChips:    row.EndingChips,
+			AmountWon:      row.AmountWon,
+			NetResult:      row.NetResult,
+			IsWinner:       row.IsWinner,
+			WentToShowdown: row.WentToShowdown,
+			FoldedPhase:    row.FoldedPhase,
+			HandRank:       row.HandRank,
+		}
+		if seat.Username == "" {
+			seat.Username = usernameFromActions(hero, row.UserID)
+		}
+		if row.UserID == heroID || row.WentToShowdown {
+			seat.HoleCards = holeCards(&row)
+		}
+		hand.Seats = append(hand.Seats, seat)
+	}
+	sort.Slice(hand.Seats, func(i, j int) bool {
+		return hand.Seats[i].Seat < hand.Seats[j].Seat
+	})
+
+	for street, records := range [][]models.PlayerActionRecord{
+		hero.PreFlopActions, hero.FlopActions, hero.TurnActions, hero.RiverActions,
+	} {
+		for _, record := range records {
+			hand.Actions[street] = append(hand.Actions[street], Action{
+				Username: record.Username,
+				Action:   record.Action,
+				Amount:   chipsCommitted(record),
+			})
+		}
+	}
+
+	return hand, nil
+}
+
+// Hero returns the seat of the player the hand was exported for
+func (h *Hand) Hero() *Seat {
+	for i := range h.Seats {
+		if h.Seats[i].UserID == h.HeroID {
+			return &h.Seats[i]
+		}
+	}
+	return nil
+}
+
+// blindSeats returns the indexes into Seats of the small and big blind,
+// derived from the button the same way the game engine assigns them
+func (h *Hand) blindSeats() (int, int) {
+	if len(h.Seats) < 2 {
+		return -1, -1
+	}
+
+	button := 0
+	for i, seat := range h.Seats {
+		if seat.Seat == h.ButtonSeat {
+			button = i
+			break
+		}
+	}
+
+	if len(h.Seats) == 2 {
+		// Heads-up: dealer is small blind
+		return button, (button + 1) % 2
+	}
+	return (button + 1) % len(h.Seats), (button + 2) % len(h.Seats)
+}
+
+// HandID derives a stable numeric hand identifier, which third-party
+// trackers require, from the game ID and hand number
+func HandID(gameID uuid.UUID, handNumber int) string {
+	return fmt.Sprintf("%d%06d", crc32.ChecksumIEEE(gameID[:]), handNumber)
+}
+
+// FormatCard converts a rank and suit as stored on HandHistory ("10",
+// "Hearts") into two-character notation ("Th"); empty input yields ""
+func FormatCard(rank, suit string) string {
+	if rank == "" || suit == "" {
+		return ""
+	}
+
+	rank = strings.ToUpper(rank)
+	if rank == "10" {
+		rank = "T"
+	}
+
+	switch suit {
+	case "♥":
+		suit = "h"
+	case "♦":
+		suit = "d"
+	case "♣":
+		suit = "c"
+	case "♠":
+		suit = "s"
+	default:
+		suit = strings.ToLower(suit[:1])
+	}
+
+	return rank + suit
+}
+
+func holeCards(row *models.HandHistory) []string {
+	var cards []string
+	for _, card := range []string{
+		FormatCard(row.HoleCard1Rank, row.HoleCard1Suit),
+		FormatCard(row.HoleCard2Rank, row.HoleCard2Suit),
+	} {
+		if card != "" {
+			cards = append(cards, card)
+		}
+	}
+	return cards
+}
+
+func boardCards(row *models.HandHistory) []string {
+	var cards []string
+	for _, card := range []string{
+		FormatCard(row.FlopCard1Rank, row.FlopCard1Suit),
+		FormatCard(row.FlopCard2Rank, row.FlopCard2Suit),
+		FormatCard(row.FlopCard3Rank, row.FlopCard3Suit),
+		FormatCard(row.TurnCardRank, row.TurnCardSuit),
+		FormatCard(row.RiverCardRank, row.RiverCardSuit),
+	} {
+		if card == "" {
+			break
+		}
+		cards = append(cards, card)
+	}
+	return cards
+}
+
+// chipsCommitted prefers the stack delta recorded on the action and falls
+// back to the raw amount for records without chip snapshots
+func chipsCommitted(record models.PlayerActionRecord) int64 {
+	if record.ChipsBefore != 0 || record.ChipsAfter != 0 {
+		return record.ChipsBefore - record.ChipsAfter
+	}
+	return record.Amount
+}
+
+func usernameFromActions(row *models.HandHistory, userID uuid.UUID) string {
+	for _, records := range [][]models.PlayerActionRecord{
+		row.PreFlopActions, row.FlopActions, row.TurnActions, row.RiverActions,
+	} {
+		for _, record := range records {
+			if record.PlayerID == userID && record.Username != "" {
+				return record.Username
+			}
+		}
+	}
+	return userID.String()[:8]
+}
@@ -0,0 +1,440 @@
+package handexport
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/primoPoker/server/internal/models"
+)
+
+// Format identifies a hand history export format
+type Format string
+
+const (
+	FormatPokerStars Format = "pokerstars"
+	FormatCSV        Format = "csv"
+	FormatJSON       Format = "json"
+)
+
+// ParseFormat validates a format name, defaulting to PokerStars text
+func ParseFormat(s string) (Format, error) {
+	switch Format(strings.ToLower(s)) {
+	case "", FormatPokerStars:
+		return FormatPokerStars, nil
+	case FormatCSV:
+		return FormatCSV, nil
+	case FormatJSON:
+		return FormatJSON, nil
+	}
+	return "", fmt.Errorf("unsupported export format %q", s)
+}
+
+// ContentType returns the MIME type for the format
+func (f Format) ContentType() string {
+	switch f {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatJSON:
+		return "application/json"
+	default:
+		return "text/plain; charset=utf-8"
+	}
+}
+
+// Extension returns the file extension for the format
+func (f Format) Extension() string {
+	switch f {
+	case FormatCSV:
+		return "csv"
+	case FormatJSON:
+		return "json"
+	default:
+		return "txt"
+	}
+}
+
+// Writer encodes hands one at a time so exports never need to be held in memory
+type Writer interface {
+	// WriteHand encodes a single hand
+	WriteHand(hand *Hand) error
+	// Flush pushes buffered output to the underlying writer
+	Flush() error
+	// Close writes any trailing output and flushes
+	Close() error
+}
+
+// NewWriter creates a Writer for the given format
+func NewWriter(w io.Writer, format Format) (Writer, error) {
+	switch format {
+	case FormatPokerStars:
+		return &pokerStarsWriter{out: w, buf: bufio.NewWriter(w)}, nil
+	case FormatCSV:
+		return &csvWriter{out: w, csv: csv.NewWriter(w)}, nil
+	case FormatJSON:
+		return &jsonWriter{out: w, buf: bufio.NewWriter(w)}, nil
+	}
+	return nil, fmt.Errorf("unsupported export format %q", format)
+}
+
+// flushUnderlying flushes the destination when it supports it (e.g. an
+// http.ResponseWriter), so chunks reach the client as they are produced
+func flushUnderlying(w io.Writer) {
+	if f, ok := w.(interface{ Flush() }); ok {
+		f.Flush()
+	}
+}
+
+// pokerStarsWriter produces PokerStars-style text hand histories
+type pokerStarsWriter struct {
+	out     io.Writer
+	buf     *bufio.Writer
+	written int
+}
+
+func (w *pokerStarsWriter) WriteHand(hand *Hand) error {
+	if w.written > 0 {
+		w.buf.WriteString("\n\n")
+	}
+	w.written++
+
+	p := func(format string, args ...interface{}) {
+		fmt.Fprintf(w.buf, format+"\n", args...)
+	}
+
+	p("PokerStars Hand #%s:  Hold'em No Limit (%d/%d) - %s UTC",
+		hand.ID, hand.SmallBlind, hand.BigBlind, hand.StartedAt.Format("2006/01/02 15:04:05"))
+	p("Table '%s' %d-max Seat #%d is the button", hand.TableName, hand.MaxSeats, hand.ButtonSeat)
+	for _, seat := range hand.Seats {
+		p("Seat %d: %s (%d in chips)", seat.Seat, seat.Username, seat.StartingChips)
+	}
+
+	// Contributions per player for the current street, used to phrase raises
+	// as "raises X to Y" and to detect uncalled bets
+	committed := make(map[string]int64)
+	var totalPot int64
+
+	sb, bb := hand.blindSeats()
+	if sb >= 0 {
+		sbSeat, bbSeat := hand.Seats[sb], hand.Seats[bb]
+		sbAmount := min64(hand.SmallBlind, sbSeat.StartingChips)
+		bbAmount := min64(hand.BigBlind, bbSeat.StartingChips)
+		p("%s: posts small blind %d", sbSeat.Username, sbAmount)
+		p("%s: posts big blind %d", bbSeat.Username, bbAmount)
+		committed[sbSeat.Username] = sbAmount
+		committed[bbSeat.Username] = bbAmount
+		totalPot += sbAmount + bbAmount
+	}
+
+	p("*** HOLE CARDS ***")
+	if hero := hand.Hero(); hero != nil && len(hero.HoleCards) > 0 {
+		p("Dealt to %s [%s]", hero.Username, strings.Join(hero.HoleCards, " "))
+	}
+
+	for street := StreetPreFlop; street <= StreetRiver; street++ {
+		switch street {
+		case StreetFlop:
+			if len(hand.Board) < 3 {
+				continue
+			}
+			p("*** FLOP *** [%s]", strings.Join(hand.Board[:3], " "))
+		case StreetTurn:
+			if len(hand.Board) < 4 {
+				continue
+			}
+			p("*** TURN *** [%s] [%s]", strings.Join(hand.Board[:3], " "), hand.Board[3])
+		case StreetRiver:
+			if len(hand.Board) < 5 {
+				continue
+			}
+			p("*** RIVER *** [%s] [%s]", strings.Join(hand.Board[:4], " "), hand.Board[4])
+		}
+
+		if street != StreetPreFlop {
+			committed = make(map[string]int64)
+		}
+
+		for _, action := range hand.Actions[street] {
+			highest := maxCommitted(committed)
+			committed[action.Username] += action.Amount
+			totalPot += action.Amount
+			to := committed[action.Username]
+
+			switch action.Action {
+			case models.ActionFold:
+				p("%s: folds", action.Username)
+			case models.ActionCheck:
+				p("%s: checks", action.Username)
+			case models.ActionCall:
+				p("%s: calls %d", action.Username, action.Amount)
+			case models.ActionBet, models.ActionRaise:
+				if highest == 0 {
+					p("%s: bets %d", action.Username, action.Amount)
+				} else {
+					p("%s: raises %d to %d", action.Username, to-highest, to)
+				}
+			case models.ActionAllIn:
+				switch {
+				case to <= highest:
+					p("%s: calls %d and is all-in", action.Username, action.Amount)
+				case highest == 0:
+					p("%s: bets %d and is all-in", action.Username, action.Amount)
+				default:
+					p("%s: raises %d to %d and is all-in", action.Username, to-highest, to)
+				}
+			}
+		}
+
+		if name, uncalled := uncalledBet(committed); uncalled > 0 {
+			p("Uncalled bet (%d) returned to %s", uncalled, name)
+			totalPot -= uncalled
+		}
+	}
+
+	showdown := false
+	for _, seat := range hand.Seats {
+		if seat.WentToShowdown {
+			showdown = true
+			break
+		}
+	}
+	if showdown {
+		p("*** SHOW DOWN ***")
+		for _, seat := range hand.Seats {
+			if seat.WentToShowdown && len(seat.HoleCards) > 0 {
+				p("%s: shows [%s] (%s)", seat.Username, strings.Join(seat.HoleCards, " "), seat.HandRank)
+			}
+		}
+	}
+	for _, seat := range hand.Seats {
+		if seat.AmountWon > 0 {
+			p("%s collected %d from pot", seat.Username, seat.AmountWon)
+		}
+	}
+
+	if totalPot <= 0 {
+		totalPot = hand.Pot
+	}
+
+	p("*** SUMMARY ***")
+	p("Total pot %d | Rake 0", totalPot)
+	if len(hand.Board) > 0 {
+		p("Board [%s]", strings.Join(hand.Board, " "))
+	}
+	for i, seat := range hand.Seats {
+		label := fmt.Sprintf("Seat %d: %s", seat.Seat, seat.Username)
+		if seat.Seat == hand.ButtonSeat {
+			label += " (button)"
+		}
+		if i == sb {
+			label += " (small blind)"
+		} else if i == bb {
+			label += " (big blind)"
+		}
+		p("%s %s", label, seatOutcome(seat))
+	}
+
+	return nil
+}
+
+func (w *pokerStarsWriter) Flush() error {
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	flushUnderlying(w.out)
+	return nil
+}
+
+func (w *pokerStarsWriter) Close() error {
+	if w.written > 0 {
+		w.buf.WriteString("\n")
+	}
+	return w.Flush()
+}
+
+// seatOutcome renders the summary line ending for a seat
+func seatOutcome(seat Seat) string {
+	cards := strings.Join(seat.HoleCards, " ")
+	switch {
+	case seat.FoldedPhase == models.HandPhasePreFlop:
+		return "folded before Flop"
+	case seat.FoldedPhase != "":
+		return "folded on the " + phaseName(seat.FoldedPhase)
+	case seat.WentToShowdown && cards != "" && seat.AmountWon > 0:
+		return fmt.Sprintf("showed [%s] and won (%d) with %s", cards, seat.AmountWon, seat.HandRank)
+	case seat.WentToShowdown && cards != "":
+		return fmt.Sprintf("showed [%s] and lost with %s", cards, seat.HandRank)
+	case seat.AmountWon > 0:
+		return fmt.Sprintf("collected (%d)", seat.AmountWon)
+	default:
+		return "mucked"
+	}
+}
+
+func phaseName(phase models.HandPhase) string {
+	switch phase {
+	case models.HandPhaseFlop:
+		return "Flop"
+	case models.HandPhaseTurn:
+		return "Turn"
+	case models.HandPhaseRiver:
+		return "River"
+	default:
+		return string(phase)
+	}
+}
+
+func maxCommitted(committed map[string]int64) int64 {
+	var highest int64
+	for _, amount := range committed {
+		if amount > highest {
+			highest = amount
+		}
+	}
+	return highest
+}
+
+// uncalledBet returns the player whose street contribution exceeds everyone
+// else's, and by how much
+func uncalledBet(committed map[string]int64) (string, int64) {
+	var top, second int64
+	var name string
+	for player, amount := range committed {
+		switch {
+		case amount > top:
+			second = top
+			top = amount
+			name = player
+		case amount > second:
+			second = amount
+		}
+	}
+	if len(committed) < 2 {
+		return "", 0
+	}
+	return name, top - second
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// csvColumns is the stable column order of the CSV export
+var csvColumns = []string{
+	"hand_id", "game_id", "hand_number", "table", "started_at",
+	"small_blind", "big_blind", "seat", "hole_cards", "board",
+	"starting_chips", "ending_chips", "pot_size", "amount_won", "net_result",
+	"went_to_showdown", "is_winner", "hand_rank", "folded_phase",
+}
+
+// csvWriter produces one row per hand from the hero's perspective
+type csvWriter struct {
+	out           io.Writer
+	csv           *csv.Writer
+	headerWritten bool
+}
+
+func (w *csvWriter) writeHeader() error {
+	if w.headerWritten {
+		return nil
+	}
+	w.headerWritten = true
+	return w.csv.Write(csvColumns)
+}
+
+func (w *csvWriter) WriteHand(hand *Hand) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+
+	hero := hand.Hero()
+	if hero == nil {
+		return ErrHeroNotFound
+	}
+
+	return w.csv.Write([]string{
+		hand.ID,
+		hand.GameID.String(),
+		strconv.Itoa(hand.HandNumber),
+		hand.TableName,
+		hand.StartedAt.Format("2006-01-02T15:04:05Z07:00"),
+		strconv.FormatInt(hand.SmallBlind, 10),
+		strconv.FormatInt(hand.BigBlind, 10),
+		strconv.Itoa(hero.Seat),
+		strings.Join(hero.HoleCards, " "),
+		strings.Join(hand.Board, " "),
+		strconv.FormatInt(hero.StartingChips, 10),
+		strconv.FormatInt(hero.EndingChips, 10),
+		strconv.FormatInt(hand.Pot, 10),
+		strconv.FormatInt(hero.AmountWon, 10),
+		strconv.FormatInt(hero.NetResult, 10),
+		strconv.FormatBool(hero.WentToShowdown),
+		strconv.FormatBool(hero.IsWinner),
+		hero.HandRank,
+		string(hero.FoldedPhase),
+	})
+}
+
+func (w *csvWriter) Flush() error {
+	w.csv.Flush()
+	if err := w.csv.Error(); err != nil {
+		return err
+	}
+	flushUnderlying(w.out)
+	return nil
+}
+
+func (w *csvWriter) Close() error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// jsonWriter streams hands as the elements of a single JSON array
+type jsonWriter struct {
+	out     io.Writer
+	buf     *bufio.Writer
+	written int
+}
+
+func (w *jsonWriter) WriteHand(hand *Hand) error {
+	data, err := json.Marshal(hand)
+	if err != nil {
+		return err
+	}
+
+	if w.written == 0 {
+		w.buf.WriteString("[\n")
+	} else {
+		w.buf.WriteString(",\n")
+	}
+	w.written++
+
+	_, err = w.buf.Write(data)
+	return err
+}
+
+func (w *jsonWriter) Flush() error {
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	flushUnderlying(w.out)
+	return nil
+}
+
+func (w *jsonWriter) Close() error {
+	if w.written == 0 {
+		w.buf.WriteString("[]\n")
+	} else {
+		w.buf.WriteString("\n]\n")
+	}
+	return w.Flush()
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/primoPoker/server/internal/logging"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/websocket"
+)
+
+// exportBatchSize is how many hand histories are written per flush of an account export
+const exportBatchSize = 200
+
+// DeleteAccount removes the authenticated user's account after re-confirming their password.
+// They are cashed out of any tables first; the account itself is anonymized rather than erased
+// so other players' hand histories stay intact.
+func (h *Handler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := h.sessionRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Password string `json:"password"`
+	}
+
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	// A retry racing the attempt that went through finds nothing left to check the password against
+	if h.authService.IsDeleted(userID) {
+		h.AccountDeleted(w, r)
+		return
+	}
+
+	if err := h.authService.VerifyPassword(userID, req.Password); err != nil {
+		h.writeError(w, http.StatusUnauthorized, "Invalid credentials")
+		return
+	}
+
+	// Leaving tables is safe to repeat, so a retry after a failed delete picks up where it stopped
+	playerID := userID.String()
+	h.removeFromAllGames(r.Context(), playerID)
+
+	user, err := h.authService.GetUser(userID)
+	if err != nil {
+		h.writeError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err := h.authService.DeleteAccount(userID); err != nil {
+		logging.FromContext(r.Context()).WithError(err).WithField("user_id", playerID).Error("Failed to delete account")
+		h.writeError(w, http.StatusInternalServerError, "Failed to delete account")
+		return
+	}
+	h.deleteAvatar(r.Context(), user.Avatar)
+
+	logging.FromContext(r.Context()).WithField("user_id", playerID).Info("Account deleted")
+
+	h.writeSuccess(w, map[string]string{
+		"message": "Account deleted",
+	})
+}
+
+// AccountDeleted answers a retried account deletion whose first attempt went
+// through: the account is already gone, which is what the caller asked for.
+func (h *Handler) AccountDeleted(w http.ResponseWriter, r *http.Request) {
+	h.writeSuccess(w, map[string]string{
+		"message": "Account deleted",
+	})
+}
+
+// ListMyTables lists the tables the authenticated user is seated at, with
+// their seat and stack at each
+func (h *Handler) ListMyTables(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.requestUserID(w, r)
+	if !ok {
+		return
+	}
+
+	h.writeSuccess(w, h.gameManager.PlayerTables(userID.String()))
+}
+
+// removeFromAllGames cashes a player out of every table they are seated at,
+// looking at each table for their seat
+func (h *Handler) removeFromAllGames(ctx context.Context, playerID string) {
+	for _, gameID := range h.gameManager.SeatedGames(playerID) {
+		stack, err := h.gameManager.KickPlayer(gameID, playerID)
+		if err != nil {
+			logrus.WithError(err).WithField("game_id", gameID).Warn("Failed to remove player from game")
+			continue
+		}
+
+		h.wsHub.BroadcastToGame(ctx, gameID, websocket.Message{
+			Type:      websocket.MessageTypePlayerLeft,
+			GameID:    gameID,
+			PlayerID:  playerID,
+			Data:      mustMarshal(map[string]int64{"cash_out": stack}),
+			Timestamp: time.Now(),
+		})
+	}
+}
+
+// ExportAccount streams a JSON archive of everything held about the authenticated user
+func (h *Handler) ExportAccount(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := h.sessionRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	started := false
+	hands := 0
+	encoder := json.NewEncoder(w)
+
+	err := h.exportUserRepo.ExportAccount(userID, exportBatchSize,
+		func(user *models.User, participations []models.GameParticipation) error {
+			filename := "primopoker-export-" + time.Now().UTC().Format("20060102") + ".json"
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+			w.WriteHeader(http.StatusOK)
+			started = true
+
+			header := struct {
+				ExportedAt         time.Time                  `json:"exported_at"`
+				Profile            *models.User               `json:"profile"`
+				GameParticipations []models.GameParticipation `json:"game_participations"`
+			}{time.Now().UTC(), user, participations}
+
+			// Reopen the encoded object so hand histories can be appended as they stream
+			data, err := json.Marshal(header)
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(data[:len(data)-1]); err != nil {
+				return err
+			}
+			_, err = w.Write([]byte(`,"hand_histories":[`))
+			return err
+		},
+		func(batch []models.HandHistory) error {
+			for i := range batch {
+				if hands > 0 {
+					if _, err := w.Write([]byte(",")); err != nil {
+						return err
+					}
+				}
+				if err := encoder.Encode(&batch[i]); err != nil {
+					return err
+				}
+				hands++
+			}
+			if flusher, ok := w.(http.Flusher); ok {
+				flusher.Flush()
+			}
+			return nil
+		},
+	)
+
+	if err != nil {
+		if !started {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				h.writeError(w, http.StatusNotFound, "User not found")
+				return
+			}
+			h.writeError(w, http.StatusInternalServerError, "Failed to export account")
+			return
+		}
+		// Headers are already sent, so the truncated body is all the client gets
+		logging.FromContext(r.Context()).WithError(err).WithFields(logrus.Fields{
+			"user_id": userID,
+			"hands":   hands,
+		}).Error("Account export failed")
+		return
+	}
+
+	if _, err := w.Write([]byte("]}\n")); err != nil {
+		logging.FromContext(r.Context()).WithError(err).WithField("user_id", userID).Error("Account export failed")
+		return
+	}
+
+	logging.FromContext(r.Context()).WithFields(logrus.Fields{
+		"user_id": userID,
+		"hands":   hands,
+	}).Info("Account exported")
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/internal/auth"
+	"github.com/primoPoker/server/internal/config"
+	"github.com/primoPoker/server/internal/game"
+	"github.com/primoPoker/server/internal/middleware"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/repository"
+	"github.com/primoPoker/server/internal/testutil"
+	"github.com/primoPoker/server/internal/websocket"
+)
+
+func TestDeleteAccount(t *testing.T) {
+	db := testutil.NewDB(t, &models.User{}, &models.Session{}, &models.RotatedRefreshToken{}, &models.LoginEvent{},
+		&models.APIKey{}, &models.OAuthIdentity{}, &models.HandHistory{})
+	authService := auth.NewService("test-secret", config.SecurityConfig{}, repository.NewUserRepository(db),
+		repository.NewSessionRepository(db), repository.NewLoginEventRepository(db), repository.NewAPIKeyRepository(db))
+	handler := &Handler{authService: authService, gameManager: game.NewManager(), wsHub: websocket.NewHub()}
+
+	// Routed as the server routes them
+	router := mux.NewRouter()
+	router.Handle("/api/v1/users/me", http.HandlerFunc(handler.AccountDeleted)).
+		Methods("DELETE").MatcherFunc(middleware.DeletedAccount(authService))
+	protected := router.PathPrefix("/api/v1").Subrouter()
+	protected.Use(middleware.JWTAuthMiddleware(authService))
+	protected.HandleFunc("/users/me", handler.DeleteAccount).Methods("DELETE")
+	protected.HandleFunc("/users/me/logins", handler.ListLogins).Methods("GET")
+
+	user, err := authService.CreateUser("alice", "correct-horse-42", "alice@example.com")
+	require.NoError(t, err)
+	tokens, err := authService.StartSession(user, "192.0.2.1", "firefox")
+	require.NoError(t, err)
+
+	send := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	deleteAccount := func(password string) *httptest.ResponseRecorder {
+		return send(http.MethodDelete, "/api/v1/users/me", `{"password": "`+password+`"}`)
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, deleteAccount("wrong").Code)
+	_, err = authService.GetUser(user.ID)
+	require.NoError(t, err, "a wrong password deletes nothing")
+
+	rr := deleteAccount("correct-horse-42")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.True(t, authService.IsDeleted(user.ID))
+
+	// A retry, say after the first response was lost, is told the account
+	// is gone even though its session no longer signs anything else in
+	rr = deleteAccount("correct-horse-42")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var response struct {
+		Data map[string]string `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, "Account deleted", response.Data["message"])
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodGet, "/api/v1/users/me/logins", "").Code)
+
+	// As is one that got past authentication before the first went through
+	rr = httptest.NewRecorder()
+	req := asUser(jsonRequest(http.MethodDelete, "/api/v1/users/me", `{"password": "correct-horse-42"}`), user.ID, "alice")
+	req = req.WithContext(context.WithValue(req.Context(), "session_id", tokens.SessionID.String()))
+	handler.DeleteAccount(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+// paidOut is a bank that records what it pays out
+type paidOut struct {
+	mu   sync.Mutex
+	paid map[string]int64
+}
+
+func (b *paidOut) BuyIn(context.Context, string, game.SeatState) error { return nil }
+
+func (b *paidOut) CashOut(playerID string, amount int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.paid[playerID] += amount
+}
+
+func TestDeleteAccountCashesOutEverySeat(t *testing.T) {
+	db := testutil.NewDB(t, &models.User{}, &models.Session{}, &models.RotatedRefreshToken{}, &models.LoginEvent{},
+		&models.APIKey{}, &models.OAuthIdentity{}, &models.HandHistory{})
+	authService := auth.NewService("test-secret", config.SecurityConfig{}, repository.NewUserRepository(db),
+		repository.NewSessionRepository(db), repository.NewLoginEventRepository(db), repository.NewAPIKeyRepository(db))
+	manager := game.NewManager()
+	bank := &paidOut{paid: make(map[string]int64)}
+	manager.SetBank(bank)
+	hub := websocket.NewHub()
+	go hub.Run()
+	handler := &Handler{authService: authService, gameManager: manager, wsHub: hub}
+
+	user, err := authService.CreateUser("alice", "correct-horse-42", "alice@example.com")
+	require.NoError(t, err)
+	tokens, err := authService.StartSession(user, "192.0.2.1", "firefox")
+	require.NoError(t, err)
+	alice := user.ID.String()
+	ctx := context.Background()
+
+	// Alice waits at one table and plays a hand with Bob at another
+	waiting, err := manager.CreateGame("waiting", "Waiting")
+	require.NoError(t, err)
+	require.NoError(t, manager.JoinGame(ctx, waiting.ID, alice, "alice", 5000))
+	playing, err := manager.CreateGame("playing", "Playing")
+	require.NoError(t, err)
+	require.NoError(t, manager.JoinGame(ctx, playing.ID, alice, "alice", 5000))
+	require.NoError(t, manager.JoinGame(ctx, playing.ID, "bob", "bob", 5000))
+	if playing.GetGameState("").CurrentPlayer == alice {
+		require.NoError(t, manager.ProcessAction(ctx, playing.ID, alice, game.Call, 0))
+	}
+
+	// She leaves the hand before her turn, keeping her seat until it ends,
+	// then deletes her account
+	require.NoError(t, manager.LeaveGame(ctx, playing.ID, alice))
+	var stack int64
+	for _, player := range playing.GetGameState("").Players {
+		if player.ID == alice {
+			stack = player.ChipCount
+		}
+	}
+	require.NotZero(t, stack, "she is still in the hand")
+
+	rr := httptest.NewRecorder()
+	req := asUser(jsonRequest(http.MethodDelete, "/api/v1/users/me", `{"password": "correct-horse-42"}`), user.ID, "alice")
+	req = req.WithContext(context.WithValue(req.Context(), "session_id", tokens.SessionID.String()))
+	handler.DeleteAccount(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	// Both seats are cashed out and freed before the account goes
+	assert.Empty(t, manager.SeatedGames(alice))
+	assert.Empty(t, manager.GetPlayerGames(alice))
+	bank.mu.Lock()
+	defer bank.mu.Unlock()
+	assert.Equal(t, map[string]int64{alice: 5000 + stack}, bank.paid)
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+
+	"github.com/primoPoker/server/internal/achievements"
+	"github.com/primoPoker/server/internal/logging"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/websocket"
+)
+
+// GetMyAchievements lists every achievement with whether the authenticated
+// user has unlocked it
+func (h *Handler) GetMyAchievements(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.requestUserID(w, r)
+	if !ok {
+		return
+	}
+
+	progress, err := h.achievements.GetProgress(userID)
+	if err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to get achievements")
+		h.writeError(w, http.StatusInternalServerError, "Failed to get achievements")
+		return
+	}
+
+	h.writeSuccess(w, progress)
+}
+
+// PublicProfile is what any signed-in player may see of another
+type PublicProfile struct {
+	ID          uuid.UUID            `json:"id"`
+	Username    string               `json:"username"`
+	DisplayName string               `json:"display_name"`
+	Avatar      string               `json:"avatar"`
+	MemberSince time.Time            `json:"member_since"`
+	Badges      []achievements.Badge `json:"badges"`
+}
+
+// GetUserProfile returns a player's public profile and badges
+func (h *Handler) GetUserProfile(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.requestUserID(w, r); !ok {
+		return
+	}
+
+	userID, err := uuid.Parse(mux.Vars(r)["userId"])
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	user, err := h.userRepo.GetByID(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		h.writeError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to get user")
+		h.writeError(w, http.StatusInternalServerError, "Failed to get profile")
+		return
+	}
+
+	badges, err := h.achievements.GetBadges(userID)
+	if err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to get badges")
+		h.writeError(w, http.StatusInternalServerError, "Failed to get profile")
+		return
+	}
+
+	h.writeSuccess(w, PublicProfile{
+		ID:          user.ID,
+		Username:    user.Username,
+		DisplayName: user.DisplayName,
+		Avatar:      user.Avatar,
+		MemberSince: user.CreatedAt,
+		Badges:      badges,
+	})
+}
+
+// NotifyAchievement tells a user's open connections they have unlocked an
+// achievement. It is registered with the achievement service.
+func (h *Handler) NotifyAchievement(userID uuid.UUID, achievement models.Achievement) {
+	h.wsHub.SendToUser(userID.String(), websocket.Message{
+		Type:      websocket.MessageTypeAchievementUnlocked,
+		PlayerID:  userID.String(),
+		Data:      mustMarshal(achievement),
+		Timestamp: time.Now(),
+	})
+}
@@ -0,0 +1,418 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+
+	"github.com/primoPoker/server/internal/game"
+	"github.com/primoPoker/server/internal/logging"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/websocket"
+)
+
+// AdminNotice is the payload of an admin_notice WebSocket message
+type AdminNotice struct {
+	Action   string                  `json:"action"`
+	Reason   string                  `json:"reason"`
+	CashOut  int64                   `json:"cash_out,omitempty"`
+	CashOuts map[string]int64        `json:"cash_outs,omitempty"`
+	Config   *game.TableConfigUpdate `json:"config,omitempty"`
+	ClosesAt *time.Time              `json:"closes_at,omitempty"`
+	ToGameID string                  `json:"to_game_id,omitempty"`
+	Seat     *int                    `json:"seat_position,omitempty"`
+}
+
+// adminRequest is the body shared by the admin table endpoints
+type adminRequest struct {
+	Reason string `json:"reason"`
+}
+
+// AdminCloseGame closes a table, cashing out every player. Given a number
+// of minutes, the close is scheduled instead and the players are warned.
+func (h *Handler) AdminCloseGame(w http.ResponseWriter, r *http.Request) {
+	gameID := mux.Vars(r)["gameId"]
+
+	var req struct {
+		Reason    string `json:"reason"`
+		InMinutes int    `json:"in_minutes"`
+	}
+	if !h.decodeOptionalJSON(w, r, &req) {
+		return
+	}
+	if req.InMinutes < 0 || time.Duration(req.InMinutes)*time.Minute > maxCloseDelay {
+		h.writeError(w, http.StatusBadRequest, fmt.Sprintf("in_minutes must be between 0 and %d", int(maxCloseDelay/time.Minute)))
+		return
+	}
+	if req.InMinutes > 0 {
+		h.scheduleCloseGame(w, r, gameID, req.Reason, time.Now().Add(time.Duration(req.InMinutes)*time.Minute))
+		return
+	}
+
+	table := h.tableSnapshot(gameID)
+	ctx, cancel := context.WithTimeout(r.Context(), closeHandWait)
+	defer cancel()
+	stacks, err := h.gameManager.CloseGame(ctx, gameID)
+	if err != nil {
+		h.writeAdminGameError(w, err)
+		return
+	}
+
+	h.notifyAdminAction(r.Context(), gameID, "", AdminNotice{
+		Action:   "close",
+		Reason:   req.Reason,
+		CashOuts: stacks,
+	})
+
+	h.recordAudit(r, newAuditEntry(r, "close_game", models.AuditTargetGame, gameID, req.Reason, table, map[string]interface{}{
+		"cash_outs": stacks,
+	}))
+
+	h.writeSuccess(w, map[string]interface{}{
+		"message":   "Game closed",
+		"cash_outs": stacks,
+	})
+}
+
+// scheduleCloseGame answers an admin's request to close a table later
+func (h *Handler) scheduleCloseGame(w http.ResponseWriter, r *http.Request, gameID, reason string, closesAt time.Time) {
+	if _, err := h.gameManager.GetGame(gameID); err != nil {
+		h.writeAdminGameError(w, err)
+		return
+	}
+	if h.tasks == nil {
+		h.writeError(w, http.StatusServiceUnavailable, "Scheduled closes are not available")
+		return
+	}
+	if err := h.scheduleClose(r.Context(), gameID, reason, closesAt); err != nil {
+		logging.FromContext(r.Context()).WithError(err).WithField("game_id", gameID).Error("Failed to schedule table close")
+		h.writeError(w, http.StatusInternalServerError, "Failed to schedule the close")
+		return
+	}
+
+	h.recordAudit(r, newAuditEntry(r, "schedule_close_game", models.AuditTargetGame, gameID, reason, h.tableSnapshot(gameID), map[string]interface{}{
+		"closes_at": closesAt,
+	}))
+
+	h.writeJSON(w, http.StatusAccepted, Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"message":   "Game closing",
+			"closes_at": closesAt,
+		},
+	})
+}
+
+// AdminForceStartGame deals a hand at a table stuck waiting for players
+func (h *Handler) AdminForceStartGame(w http.ResponseWriter, r *http.Request) {
+	gameID := mux.Vars(r)["gameId"]
+
+	var req adminRequest
+	if !h.decodeOptionalJSON(w, r, &req) {
+		return
+	}
+
+	if err := h.gameManager.ForceStartGame(gameID); err != nil {
+		h.writeAdminGameError(w, err)
+		return
+	}
+
+	h.notifyAdminAction(r.Context(), gameID, "", AdminNotice{
+		Action: "force_start",
+		Reason: req.Reason,
+	})
+
+	h.recordAudit(r, newAuditEntry(r, "force_start_game", models.AuditTargetGame, gameID, req.Reason, nil, nil))
+
+	h.writeSuccess(w, map[string]string{
+		"message": "Game started",
+	})
+}
+
+// AdminKickPlayer removes a player from a table with their stack cashed out
+func (h *Handler) AdminKickPlayer(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	gameID := vars["gameId"]
+	playerID := vars["userId"]
+
+	var req adminRequest
+	if !h.decodeOptionalJSON(w, r, &req) {
+		return
+	}
+
+	stack, err := h.gameManager.KickPlayer(gameID, playerID)
+	if err != nil {
+		h.writeAdminGameError(w, err)
+		return
+	}
+
+	h.notifyAdminAction(r.Context(), gameID, playerID, AdminNotice{
+		Action:  "kick",
+		Reason:  req.Reason,
+		CashOut: stack,
+	})
+
+	h.recordAudit(r, newAuditEntry(r, "kick_player", models.AuditTargetUser, playerID, req.Reason, nil, map[string]interface{}{
+		"game_id":  gameID,
+		"cash_out": stack,
+	}))
+
+	h.writeSuccess(w, map[string]interface{}{
+		"message":  "Player removed from game",
+		"cash_out": stack,
+	})
+}
+
+// AdminMovePlayer moves a player and their stack to another table between
+// hands. Both tables are told, and the player is sent the new table's state
+// to follow it from.
+func (h *Handler) AdminMovePlayer(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	gameID := vars["gameId"]
+	playerID := vars["userId"]
+
+	var req struct {
+		ToGameID string `json:"to_game_id"`
+		Reason   string `json:"reason"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+	if req.ToGameID == "" {
+		h.writeError(w, http.StatusBadRequest, "to_game_id is required")
+		return
+	}
+
+	seat, err := h.gameManager.MovePlayer(gameID, req.ToGameID, playerID)
+	switch {
+	case errors.Is(err, game.ErrGameFull):
+		h.writeErrorCode(w, http.StatusConflict, "table_full", "The destination table has no free seat")
+		return
+	case errors.Is(err, game.ErrHandInProgress):
+		h.writeErrorCode(w, http.StatusConflict, "hand_in_progress", "Players can only be moved between hands")
+		return
+	case errors.Is(err, game.ErrStackOutOfRange):
+		h.writeErrorCode(w, http.StatusConflict, "stack_out_of_range", "The player's stack is outside the destination's buy-in range")
+		return
+	case errors.Is(err, game.ErrPlayerAlreadyInGame):
+		h.writeErrorCode(w, http.StatusConflict, "already_seated", "The player is already at the destination table")
+		return
+	case errors.Is(err, game.ErrNotClubMember):
+		h.writeErrorCode(w, http.StatusForbidden, "club_members_only", err.Error())
+		return
+	case err != nil:
+		h.writeAdminGameError(w, err)
+		return
+	}
+
+	notice := AdminNotice{
+		Action:   "move",
+		Reason:   req.Reason,
+		ToGameID: req.ToGameID,
+		Seat:     &seat,
+	}
+	h.notifyAdminAction(r.Context(), gameID, playerID, notice)
+	h.notifyAdminAction(r.Context(), req.ToGameID, playerID, notice)
+	h.sendGameState(r.Context(), req.ToGameID, playerID)
+
+	h.recordAudit(r, newAuditEntry(r, "move_player", models.AuditTargetUser, playerID, req.Reason, map[string]string{
+		"game_id": gameID,
+	}, map[string]interface{}{
+		"game_id":       req.ToGameID,
+		"seat_position": seat,
+	}))
+
+	h.writeSuccess(w, map[string]interface{}{
+		"message":       "Player moved",
+		"game_id":       req.ToGameID,
+		"seat_position": seat,
+	})
+}
+
+// AdminUpdateGameConfig changes the turn timeout or blinds from the next hand
+func (h *Handler) AdminUpdateGameConfig(w http.ResponseWriter, r *http.Request) {
+	gameID := mux.Vars(r)["gameId"]
+
+	var req struct {
+		SmallBlind         *int64 `json:"small_blind"`
+		BigBlind           *int64 `json:"big_blind"`
+		TurnTimeoutSeconds *int   `json:"turn_timeout_seconds"`
+		Reason             string `json:"reason"`
+	}
+	if !h.decodeOptionalJSON(w, r, &req) {
+		return
+	}
+
+	update := game.TableConfigUpdate{
+		SmallBlind: req.SmallBlind,
+		BigBlind:   req.BigBlind,
+	}
+	if req.TurnTimeoutSeconds != nil {
+		timeout := time.Duration(*req.TurnTimeoutSeconds) * time.Second
+		update.TurnTimeout = &timeout
+	}
+	if update.SmallBlind == nil && update.BigBlind == nil && update.TurnTimeout == nil {
+		h.writeError(w, http.StatusBadRequest, "No configuration changes provided")
+		return
+	}
+
+	table := h.tableSnapshot(gameID)
+	if err := h.gameManager.UpdateGameConfig(gameID, update); err != nil {
+		h.writeAdminGameError(w, err)
+		return
+	}
+
+	h.notifyAdminAction(r.Context(), gameID, "", AdminNotice{
+		Action: "update_config",
+		Reason: req.Reason,
+		Config: &update,
+	})
+
+	h.recordAudit(r, newAuditEntry(r, "update_game_config", models.AuditTargetGame, gameID, req.Reason, table, map[string]interface{}{
+		"small_blind":          req.SmallBlind,
+		"big_blind":            req.BigBlind,
+		"turn_timeout_seconds": req.TurnTimeoutSeconds,
+	}))
+
+	h.writeSuccess(w, map[string]string{
+		"message": "Configuration will apply from the next hand",
+	})
+}
+
+// writeAdminGameError maps game manager errors onto status codes
+func (h *Handler) writeAdminGameError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, game.ErrGameNotFound), errors.Is(err, game.ErrPlayerNotInGame):
+		h.writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, game.ErrHandInProgress), errors.Is(err, game.ErrNotEnoughPlayers):
+		h.writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, game.ErrInvalidTableConfig):
+		h.writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, game.ErrShuttingDown):
+		h.writeShuttingDown(w)
+	default:
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// notifyAdminAction tells everyone at the table what an operator did and why
+func (h *Handler) notifyAdminAction(ctx context.Context, gameID, playerID string, notice AdminNotice) {
+	h.wsHub.BroadcastToGame(ctx, gameID, websocket.Message{
+		Type:      websocket.MessageTypeAdminNotice,
+		GameID:    gameID,
+		PlayerID:  playerID,
+		Data:      mustMarshal(notice),
+		Timestamp: time.Now(),
+	})
+}
+
+// sendGameState sends a player a table's state, as seen from their seat
+func (h *Handler) sendGameState(ctx context.Context, gameID, userID string) {
+	gameState, err := h.gameState(gameID, userID)
+	if err != nil {
+		logging.FromContext(ctx).WithError(err).Error("Failed to get game state for notification")
+		return
+	}
+	h.wsHub.SendToUser(userID, websocket.Message{
+		Type:      websocket.MessageTypeGameState,
+		GameID:    gameID,
+		Data:      mustMarshal(gameState),
+		Timestamp: time.Now(),
+	})
+}
+
+// NotifyTableClosed tells anyone still following a table that it was
+// closed for sitting idle. It is passed to the game manager's cleanup.
+func (h *Handler) NotifyTableClosed(gameID string) {
+	h.wsHub.BroadcastToGame(context.Background(), gameID, websocket.Message{
+		Type:      websocket.MessageTypeTableClosed,
+		GameID:    gameID,
+		Data:      mustMarshal(map[string]string{"reason": "idle"}),
+		Timestamp: time.Now(),
+	})
+}
+
+// tableSnapshot returns a table's settings and player count for the audit
+// log, or nil if there is no such table
+func (h *Handler) tableSnapshot(gameID string) interface{} {
+	for _, info := range h.gameManager.ListGames() {
+		if info.ID == gameID {
+			return info
+		}
+	}
+	return nil
+}
+
+// AdminSetUserRole promotes or demotes a user
+func (h *Handler) AdminSetUserRole(w http.ResponseWriter, r *http.Request) {
+	targetID, err := uuid.Parse(mux.Vars(r)["userId"])
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var req struct {
+		Role   models.Role `json:"role"`
+		Reason string      `json:"reason"`
+	}
+	if !h.decodeOptionalJSON(w, r, &req) {
+		return
+	}
+	if !req.Role.IsValid() {
+		h.writeError(w, http.StatusBadRequest, "Invalid role")
+		return
+	}
+	if targetID.String() == getUserIDFromContext(r) && req.Role != models.RoleAdmin {
+		h.writeError(w, http.StatusBadRequest, "Cannot remove your own admin role")
+		return
+	}
+
+	var before interface{}
+	if user, err := h.userRepo.GetByID(targetID); err == nil && user != nil {
+		before = map[string]models.Role{"role": user.Role}
+	}
+
+	if err := h.authService.SetRole(targetID, req.Role); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			h.writeError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		h.writeError(w, http.StatusInternalServerError, "Failed to update role")
+		return
+	}
+
+	h.recordAudit(r, newAuditEntry(r, "set_user_role", models.AuditTargetUser, targetID.String(), req.Reason, before, map[string]models.Role{
+		"role": req.Role,
+	}))
+
+	h.writeSuccess(w, map[string]string{
+		"message": "Role updated",
+		"role":    string(req.Role),
+	})
+}
+
+// AdminRetentionDryRun reports what the retention policy would purge and
+// archive if it ran now, without changing anything
+func (h *Handler) AdminRetentionDryRun(w http.ResponseWriter, r *http.Request) {
+	if h.retention == nil || !h.retention.Enabled() {
+		h.writeError(w, http.StatusNotFound, "No retention policy is configured")
+		return
+	}
+
+	report, err := h.retention.DryRun()
+	if err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to dry run retention policy")
+		h.writeError(w, http.StatusInternalServerError, "Failed to dry run retention policy")
+		return
+	}
+
+	h.recordAudit(r, newAuditEntry(r, "retention_dry_run", models.AuditTargetRetention, "", "", nil, report))
+
+	h.writeSuccess(w, report)
+}
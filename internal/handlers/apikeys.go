@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/primoPoker/server/internal/auth"
+	"github.com/primoPoker/server/internal/models"
+)
+
+// APIKeyInfo describes an API key to its owner without the key itself
+type APIKeyInfo struct {
+	ID         uuid.UUID         `json:"id"`
+	Name       string            `json:"name"`
+	Prefix     string            `json:"prefix"`
+	Scopes     []models.APIScope `json:"scopes"`
+	CreatedAt  time.Time         `json:"created_at"`
+	ExpiresAt  *time.Time        `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time        `json:"last_used_at,omitempty"`
+}
+
+// newAPIKeyInfo describes an API key
+func newAPIKeyInfo(key *models.APIKey) APIKeyInfo {
+	return APIKeyInfo{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.ScopeList(),
+		CreatedAt:  key.CreatedAt,
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+	}
+}
+
+// CreateAPIKey issues an API key for the authenticated user. The key is in
+// the response only this once.
+func (h *Handler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.requestUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Name      string            `json:"name"`
+		Scopes    []models.APIScope `json:"scopes"`
+		ExpiresAt *time.Time        `json:"expires_at"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	secret, key, err := h.authService.CreateAPIKey(userID, req.Name, req.Scopes, req.ExpiresAt)
+	switch {
+	case errors.Is(err, auth.ErrInvalidAPIScope):
+		h.writeErrorCode(w, http.StatusBadRequest, "invalid_scope", "Scopes must be one or more of read, play and admin")
+		return
+	case errors.Is(err, auth.ErrScopeNotAllowed):
+		h.writeErrorCode(w, http.StatusForbidden, "scope_not_allowed", "Only moderators and admins may create keys with the admin scope")
+		return
+	case errors.Is(err, auth.ErrTooManyAPIKeys):
+		h.writeErrorCode(w, http.StatusConflict, "too_many_api_keys", "Revoke an existing API key before creating another")
+		return
+	case err != nil:
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.writeJSON(w, http.StatusCreated, Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"key":     secret,
+			"api_key": newAPIKeyInfo(key),
+		},
+	})
+}
+
+// ListAPIKeys lists the authenticated user's API keys
+func (h *Handler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.requestUserID(w, r)
+	if !ok {
+		return
+	}
+
+	keys, err := h.authService.ListAPIKeys(userID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to list API keys")
+		return
+	}
+
+	infos := make([]APIKeyInfo, len(keys))
+	for i := range keys {
+		infos[i] = newAPIKeyInfo(&keys[i])
+	}
+
+	h.writeSuccess(w, infos)
+}
+
+// RevokeAPIKey revokes one of the authenticated user's API keys
+func (h *Handler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.requestUserID(w, r)
+	if !ok {
+		return
+	}
+
+	keyID, err := uuid.Parse(mux.Vars(r)["keyId"])
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid API key ID")
+		return
+	}
+
+	if err := h.authService.RevokeAPIKey(userID, keyID); err != nil {
+		if errors.Is(err, auth.ErrAPIKeyNotFound) {
+			h.writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		h.writeError(w, http.StatusInternalServerError, "Failed to revoke API key")
+		return
+	}
+
+	h.writeSuccess(w, map[string]string{
+		"message": "API key revoked",
+	})
+}
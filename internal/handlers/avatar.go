@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/primoPoker/server/internal/logging"
+	"github.com/primoPoker/server/internal/storage"
+)
+
+// avatarCacheControl lets browsers and the CDN keep an avatar for good.
+// Each upload is stored under a name of its own, so none ever changes.
+const avatarCacheControl = "public, max-age=31536000, immutable"
+
+// avatarTypes are the image types accepted as avatars, by extension
+var avatarTypes = map[string]string{
+	"image/png":  "png",
+	"image/jpeg": "jpg",
+	"image/gif":  "gif",
+	"image/webp": "webp",
+}
+
+// UploadAvatar stores the image in the request body as the authenticated
+// user's avatar, replacing the one they had
+func (h *Handler) UploadAvatar(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := h.sessionRequestIDs(w, r)
+	if !ok {
+		return
+	}
+	if h.avatars == nil {
+		h.writeErrorCode(w, http.StatusServiceUnavailable, "avatars_disabled", "Avatar uploads are not enabled")
+		return
+	}
+
+	image, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.writeDecodeError(w, err)
+		return
+	}
+
+	// The type is sniffed from the image, whatever the client says it is
+	contentType := http.DetectContentType(image)
+	extension, ok := avatarTypes[contentType]
+	if !ok {
+		h.writeErrorCode(w, http.StatusUnsupportedMediaType, "unsupported_image", "Avatar must be a PNG, JPEG, GIF or WebP image")
+		return
+	}
+
+	sum := sha256.Sum256(image)
+	name := "avatars/" + userID.String() + "/" + hex.EncodeToString(sum[:16]) + "." + extension
+	object := h.avatars.NewWriter(r.Context(), name, storage.ObjectOptions{
+		ContentType:  contentType,
+		CacheControl: avatarCacheControl,
+	})
+	if _, err := object.Write(image); err == nil {
+		err = object.Close()
+	}
+	log := logging.FromContext(r.Context()).WithField("user_id", userID)
+	if err != nil {
+		log.WithError(err).Error("Failed to store avatar")
+		h.writeError(w, http.StatusInternalServerError, "Failed to store avatar")
+		return
+	}
+
+	user, err := h.authService.GetUser(userID)
+	if err != nil {
+		h.writeError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	avatar := h.avatars.PublicURL(name)
+	if err := h.authService.SetAvatar(userID, avatar); err != nil {
+		log.WithError(err).Error("Failed to set avatar")
+		h.writeError(w, http.StatusInternalServerError, "Failed to store avatar")
+		return
+	}
+	if user.Avatar != avatar {
+		h.deleteAvatar(r.Context(), user.Avatar)
+	}
+
+	log.WithField("object", name).Info("Avatar uploaded")
+	h.writeSuccess(w, map[string]string{
+		"avatar": avatar,
+	})
+}
+
+// deleteAvatar removes an uploaded avatar from the avatar bucket. An
+// avatar set some other way, such as from a sign-in provider, is left.
+func (h *Handler) deleteAvatar(ctx context.Context, avatar string) {
+	if h.avatars == nil || avatar == "" {
+		return
+	}
+	name := strings.TrimPrefix(avatar, h.avatars.PublicURL(""))
+	if name == avatar || !strings.HasPrefix(name, "avatars/") {
+		return
+	}
+
+	if err := h.avatars.Delete(ctx, name); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		logging.FromContext(ctx).WithError(err).WithField("object", name).Warn("Failed to delete avatar")
+	}
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/primoPoker/server/internal/logging"
+)
+
+// bankrollDaysShown is how many days the bankroll graph covers by default
+const bankrollDaysShown = 30
+
+// GetBankroll graphs the authenticated user's results a day at a time over
+// the optional from and to parameters, the last thirty days by default
+func (h *Handler) GetBankroll(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.requestUserID(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	to := time.Now()
+	if value := query.Get("to"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid to format")
+			return
+		}
+		to = parsed
+	}
+
+	from := to.AddDate(0, 0, -bankrollDaysShown+1)
+	if value := query.Get("from"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid from format")
+			return
+		}
+		from = parsed
+	}
+
+	if to.Before(from) {
+		h.writeError(w, http.StatusBadRequest, "to must not be before from")
+		return
+	}
+
+	days, err := h.metricsService.GetDailyBankroll(userID, from, to)
+	if err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to get bankroll")
+		h.writeError(w, http.StatusInternalServerError, "Failed to get bankroll")
+		return
+	}
+
+	h.writeSuccess(w, days)
+}
@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+
+	"github.com/primoPoker/server/internal/game"
+	"github.com/primoPoker/server/internal/logging"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/repository"
+)
+
+// clubLeaderboardSize is how many members a club's leaderboard ranks
+const clubLeaderboardSize = 100
+
+// ClubDetail is a club as its members see it
+type ClubDetail struct {
+	models.Club
+	Tables []string `json:"tables"`
+}
+
+// CreateClub creates a club owned by the authenticated user
+func (h *Handler) CreateClub(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.requestUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		h.writeError(w, http.StatusBadRequest, "Club name must be between 1 and 100 characters")
+		return
+	}
+	if len(req.Description) > 500 {
+		h.writeError(w, http.StatusBadRequest, "Description must be at most 500 characters")
+		return
+	}
+
+	club := &models.Club{
+		Name:        req.Name,
+		Description: req.Description,
+		OwnerID:     userID,
+	}
+	if err := h.clubs.Create(club); err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to create club")
+		h.writeError(w, http.StatusInternalServerError, "Failed to create club")
+		return
+	}
+
+	h.writeJSON(w, http.StatusCreated, Response{Success: true, Data: club})
+}
+
+// ListMyClubs lists the clubs the authenticated user belongs to
+func (h *Handler) ListMyClubs(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.requestUserID(w, r)
+	if !ok {
+		return
+	}
+
+	clubs, err := h.clubs.ListForUser(userID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get clubs")
+		return
+	}
+
+	h.writeSuccess(w, clubs)
+}
+
+// JoinClub adds the authenticated user to the club with the given join code
+func (h *Handler) JoinClub(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.requestUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		JoinCode string `json:"join_code"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+	if req.JoinCode == "" {
+		h.writeError(w, http.StatusBadRequest, "A join code is required")
+		return
+	}
+
+	club, err := h.clubs.Join(strings.ToUpper(strings.TrimSpace(req.JoinCode)), userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		h.writeErrorCode(w, http.StatusNotFound, "club_not_found", "No club has that join code")
+		return
+	}
+	if err != nil {
+		h.writeClubError(w, r, err, "Failed to join club")
+		return
+	}
+
+	h.writeSuccess(w, club)
+}
+
+// LeaveClub removes the authenticated user from a club
+func (h *Handler) LeaveClub(w http.ResponseWriter, r *http.Request) {
+	clubID, userID, ok := h.clubRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.clubs.Leave(clubID, userID); err != nil {
+		h.writeClubError(w, r, err, "Failed to leave club")
+		return
+	}
+
+	h.writeSuccess(w, map[string]string{
+		"message": "Left the club",
+	})
+}
+
+// GetClub shows a club, its members and its open tables to its members
+func (h *Handler) GetClub(w http.ResponseWriter, r *http.Request) {
+	club, ok := h.memberClub(w, r)
+	if !ok {
+		return
+	}
+
+	members, err := h.clubs.ListMembers(club.ID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get club members")
+		return
+	}
+	club.Members = members
+
+	detail := ClubDetail{Club: *club, Tables: []string{}}
+	for _, info := range h.gameManager.ListGames() {
+		if info.ClubID == club.ID.String() {
+			detail.Tables = append(detail.Tables, info.ID)
+		}
+	}
+
+	h.writeSuccess(w, detail)
+}
+
+// GetClubLeaderboard ranks a club's members by their results at its
+// tables, over the hands played since the optional since parameter
+func (h *Handler) GetClubLeaderboard(w http.ResponseWriter, r *http.Request) {
+	club, ok := h.memberClub(w, r)
+	if !ok {
+		return
+	}
+
+	var since time.Time
+	if value := r.URL.Query().Get("since"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid since format")
+			return
+		}
+		since = parsed
+	}
+
+	entries, err := h.clubs.Leaderboard(club.ID, since, clubLeaderboardSize)
+	if err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to get club leaderboard")
+		h.writeError(w, http.StatusInternalServerError, "Failed to get club leaderboard")
+		return
+	}
+
+	h.writeSuccess(w, entries)
+}
+
+// visibleGames lists the live tables a user may see: those open to
+// everyone and their own clubs'. With clubs=mine, only their clubs' tables
+// are listed.
+func (h *Handler) visibleGames(r *http.Request) ([]*game.GameInfo, error) {
+	mine := make(map[string]bool)
+	if h.clubs != nil {
+		if userID, err := uuid.Parse(getUserIDFromContext(r)); err == nil {
+			clubIDs, err := h.clubs.ClubIDs(userID)
+			if err != nil {
+				return nil, err
+			}
+			for _, clubID := range clubIDs {
+				mine[clubID.String()] = true
+			}
+		}
+	}
+	clubsOnly := r.URL.Query().Get("clubs") == "mine"
+
+	games := make([]*game.GameInfo, 0)
+	for _, info := range h.gameManager.ListGames() {
+		if info.ClubID == "" && !clubsOnly || mine[info.ClubID] {
+			games = append(games, info)
+		}
+	}
+	return games, nil
+}
+
+// clubMember checks the authenticated user belongs to a club, writing the
+// error response if they do not
+func (h *Handler) clubMember(w http.ResponseWriter, r *http.Request, club string) bool {
+	userID, ok := h.requestUserID(w, r)
+	if !ok {
+		return false
+	}
+	clubID, err := uuid.Parse(club)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid club ID")
+		return false
+	}
+	if h.clubs == nil {
+		h.writeErrorCode(w, http.StatusNotFound, "club_not_found", "Club not found")
+		return false
+	}
+
+	member, err := h.clubs.IsMember(clubID, userID)
+	if err != nil {
+		h.writeClubError(w, r, err, "Failed to check club membership")
+		return false
+	}
+	if !member {
+		h.writeErrorCode(w, http.StatusForbidden, "club_members_only", "Only the club's members can open its tables")
+		return false
+	}
+	return true
+}
+
+// memberClub loads the club named in the route, writing the error response
+// unless the authenticated user belongs to it. Non-members are told the
+// club does not exist.
+func (h *Handler) memberClub(w http.ResponseWriter, r *http.Request) (*models.Club, bool) {
+	clubID, userID, ok := h.clubRequestIDs(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if _, err := h.clubs.GetMember(clubID, userID); err != nil {
+		h.writeClubError(w, r, err, "Failed to get club")
+		return nil, false
+	}
+
+	club, err := h.clubs.GetByID(clubID)
+	if err != nil {
+		h.writeClubError(w, r, err, "Failed to get club")
+		return nil, false
+	}
+	return club, true
+}
+
+// clubRequestIDs parses the club ID from the route and the user ID from the context
+func (h *Handler) clubRequestIDs(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	userID, ok := h.requestUserID(w, r)
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	clubID, err := uuid.Parse(mux.Vars(r)["clubId"])
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid club ID")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return clubID, userID, true
+}
+
+// writeClubError maps repository errors onto status codes and error codes
+func (h *Handler) writeClubError(w http.ResponseWriter, r *http.Request, err error, fallback string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, repository.ErrNotClubMember):
+		h.writeErrorCode(w, http.StatusNotFound, "club_not_found", "Club not found")
+	case errors.Is(err, repository.ErrAlreadyClubMember):
+		h.writeErrorCode(w, http.StatusConflict, "already_member", err.Error())
+	case errors.Is(err, repository.ErrClubOwnerLeaving):
+		h.writeErrorCode(w, http.StatusConflict, "club_owner", err.Error())
+	default:
+		logging.FromContext(r.Context()).WithError(err).Error(fallback)
+		h.writeError(w, http.StatusInternalServerError, fallback)
+	}
+}
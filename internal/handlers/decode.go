@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// decodeJSON reads a request's JSON body into dst, writing the error
+// response and returning false when it cannot. The body must be sent as
+// application/json and hold a single object with no fields dst lacks.
+func (h *Handler) decodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	return h.decodeBody(w, r, dst, false)
+}
+
+// decodeOptionalJSON is decodeJSON for a body that may be left out,
+// leaving dst as it is then
+func (h *Handler) decodeOptionalJSON(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	return h.decodeBody(w, r, dst, true)
+}
+
+func (h *Handler) decodeBody(w http.ResponseWriter, r *http.Request, dst interface{}, optional bool) bool {
+	body := r.Body
+	if optional && r.Header.Get("Content-Type") == "" {
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			h.writeDecodeError(w, err)
+			return false
+		}
+		if len(bytes.TrimSpace(data)) == 0 {
+			return true
+		}
+		body = io.NopCloser(bytes.NewReader(data))
+	}
+
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		h.writeErrorCode(w, http.StatusUnsupportedMediaType, "unsupported_media_type", "Request body must be sent as application/json")
+		return false
+	}
+
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(dst)
+	if errors.Is(err, io.EOF) && optional {
+		return true
+	}
+	if err == nil && decoder.More() {
+		err = errTrailingData
+	}
+	if err != nil {
+		h.writeDecodeError(w, err)
+		return false
+	}
+	return true
+}
+
+// errTrailingData is returned for a body holding more than one value
+var errTrailingData = errors.New("request body must hold a single JSON value")
+
+// writeDecodeError answers a body that could not be decoded: 413 when it
+// was over the route's size limit, and 400 naming what was wrong otherwise
+func (h *Handler) writeDecodeError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &tooLarge):
+		h.writeErrorCode(w, http.StatusRequestEntityTooLarge, "body_too_large",
+			fmt.Sprintf("Request body must be at most %d bytes", tooLarge.Limit))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		h.writeErrorCode(w, http.StatusBadRequest, "unknown_field",
+			"Unknown field "+strings.TrimPrefix(err.Error(), "json: unknown field "))
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		h.writeErrorCode(w, http.StatusBadRequest, "invalid_field",
+			fmt.Sprintf("Field %q must be a %s", typeErr.Field, typeErr.Type))
+	case errors.Is(err, io.EOF):
+		h.writeErrorCode(w, http.StatusBadRequest, "invalid_json", "Request body is empty")
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, errTrailingData):
+		h.writeErrorCode(w, http.StatusBadRequest, "invalid_json", "Request body is not a valid JSON object")
+	default:
+		h.writeErrorCode(w, http.StatusBadRequest, "invalid_json", "Invalid request body")
+	}
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/internal/game"
+	"github.com/primoPoker/server/internal/middleware"
+	"github.com/primoPoker/server/internal/websocket"
+)
+
+// jsonRequest makes a request with a JSON body
+func jsonRequest(method, target, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+// errorCode returns the code of an error response
+func errorCode(t *testing.T, rr *httptest.ResponseRecorder) string {
+	var response Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response), rr.Body.String())
+	assert.False(t, response.Success)
+	return response.Code
+}
+
+func TestDecodeJSONRejectsBadBodies(t *testing.T) {
+	handler := &Handler{gameManager: game.NewManager(), wsHub: websocket.NewHub()}
+	_, err := handler.gameManager.CreateGame("table-1", "Decode")
+	require.NoError(t, err)
+
+	join := middleware.LimitBody(middleware.BodyLimits{Game: 64})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = mux.SetURLVars(r, map[string]string{"gameId": "table-1"})
+		handler.JoinGame(w, asUser(r, uuid.New(), "alice"))
+	}))
+	send := func(req *http.Request) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		join.ServeHTTP(rr, req)
+		return rr
+	}
+	const target = "/api/v1/games/table-1/join"
+
+	rr := send(jsonRequest(http.MethodPost, target, `{"buy_in": 5000, "note": "`+strings.Repeat("x", 100)+`"}`))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	assert.Equal(t, "body_too_large", errorCode(t, rr))
+
+	rr = send(jsonRequest(http.MethodPost, target, `{"buy_in": 5000, "seat": 3}`))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "unknown_field", errorCode(t, rr))
+	assert.Contains(t, rr.Body.String(), `seat`)
+
+	rr = send(jsonRequest(http.MethodPost, target, `{"buy_in": "lots"}`))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "invalid_field", errorCode(t, rr))
+
+	rr = send(jsonRequest(http.MethodPost, target, `{"buy_in": 5000} {}`))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "invalid_json", errorCode(t, rr))
+
+	rr = send(httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{"buy_in": 5000}`)))
+	assert.Equal(t, http.StatusUnsupportedMediaType, rr.Code)
+	assert.Equal(t, "unsupported_media_type", errorCode(t, rr))
+
+	req := jsonRequest(http.MethodPost, target, `{"buy_in": 5000}`)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	assert.Equal(t, http.StatusOK, send(req).Code)
+}
+
+func TestDecodeOptionalJSONAllowsNoBody(t *testing.T) {
+	handler := &Handler{}
+	var req struct {
+		Reason string `json:"reason"`
+	}
+
+	rr := httptest.NewRecorder()
+	assert.True(t, handler.decodeOptionalJSON(rr, httptest.NewRequest(http.MethodPost, "/", nil), &req))
+
+	rr = httptest.NewRecorder()
+	assert.False(t, handler.decodeOptionalJSON(rr, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"reason": "x"}`)), &req))
+	assert.Equal(t, http.StatusUnsupportedMediaType, rr.Code, "a body must still say it is JSON")
+
+	rr = httptest.NewRecorder()
+	assert.False(t, handler.decodeOptionalJSON(rr, jsonRequest(http.MethodPost, "/", `{"reason": "x", "force": true}`), &req))
+	assert.Equal(t, "unknown_field", errorCode(t, rr))
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/primoPoker/server/internal/flags"
+	"github.com/primoPoker/server/internal/models"
+)
+
+// SetFlags sets the feature flags admins can list and override
+func (h *Handler) SetFlags(features *flags.Flags) {
+	h.features = features
+}
+
+// AdminListFlags lists every feature flag with the state in effect and
+// where it came from
+func (h *Handler) AdminListFlags(w http.ResponseWriter, r *http.Request) {
+	if h.features == nil {
+		h.writeError(w, http.StatusNotFound, "Feature flags are not enabled")
+		return
+	}
+
+	h.writeSuccess(w, map[string]interface{}{
+		"flags": h.features.List(),
+	})
+}
+
+// AdminSetFlag overrides a feature flag on this instance: on for
+// everyone, for a percentage of users, or for a list of user IDs
+func (h *Handler) AdminSetFlag(w http.ResponseWriter, r *http.Request) {
+	if h.features == nil {
+		h.writeError(w, http.StatusNotFound, "Feature flags are not enabled")
+		return
+	}
+	name := mux.Vars(r)["flag"]
+
+	var req struct {
+		Enabled    bool     `json:"enabled"`
+		Percentage int      `json:"percentage"`
+		Users      []string `json:"users"`
+		Reason     string   `json:"reason"`
+	}
+	if !h.decodeOptionalJSON(w, r, &req) {
+		return
+	}
+
+	state := flags.State{Enabled: req.Enabled, Percentage: req.Percentage, Users: req.Users}
+	before, err := h.features.Set(name, state)
+	if err != nil {
+		h.writeFlagError(w, err)
+		return
+	}
+
+	after, _ := h.features.Status(name)
+	h.recordAudit(r, newAuditEntry(r, "set_feature_flag", models.AuditTargetFlag, name, req.Reason, before, after))
+	h.writeSuccess(w, after)
+}
+
+// AdminClearFlag removes an admin override, returning a flag to its
+// configured state
+func (h *Handler) AdminClearFlag(w http.ResponseWriter, r *http.Request) {
+	if h.features == nil {
+		h.writeError(w, http.StatusNotFound, "Feature flags are not enabled")
+		return
+	}
+	name := mux.Vars(r)["flag"]
+
+	var req adminRequest
+	if !h.decodeOptionalJSON(w, r, &req) {
+		return
+	}
+
+	before, err := h.features.Clear(name)
+	if err != nil {
+		h.writeFlagError(w, err)
+		return
+	}
+
+	after, _ := h.features.Status(name)
+	h.recordAudit(r, newAuditEntry(r, "clear_feature_flag", models.AuditTargetFlag, name, req.Reason, before, after))
+	h.writeSuccess(w, after)
+}
+
+// writeFlagError maps feature flag errors onto status codes
+func (h *Handler) writeFlagError(w http.ResponseWriter, err error) {
+	if errors.Is(err, flags.ErrUnknownFlag) {
+		h.writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	h.writeError(w, http.StatusBadRequest, err.Error())
+}
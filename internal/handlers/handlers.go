@@ -1,48 +1,161 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 
+	"github.com/primoPoker/server/internal/achievements"
 	"github.com/primoPoker/server/internal/auth"
+	"github.com/primoPoker/server/internal/cache"
+	"github.com/primoPoker/server/internal/config"
+	"github.com/primoPoker/server/internal/database"
+	"github.com/primoPoker/server/internal/flags"
 	"github.com/primoPoker/server/internal/game"
+	"github.com/primoPoker/server/internal/gcp"
+	"github.com/primoPoker/server/internal/handexport"
+	"github.com/primoPoker/server/internal/logging"
 	"github.com/primoPoker/server/internal/metrics"
+	"github.com/primoPoker/server/internal/middleware"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/oauth"
+	"github.com/primoPoker/server/internal/pagination"
+	"github.com/primoPoker/server/internal/password"
+	"github.com/primoPoker/server/internal/repository"
+	"github.com/primoPoker/server/internal/retention"
+	"github.com/primoPoker/server/internal/storage"
+	"github.com/primoPoker/server/internal/tasks"
 	"github.com/primoPoker/server/internal/websocket"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Handler contains all HTTP handlers
 type Handler struct {
-	gameManager    *game.Manager
-	wsHub          *websocket.Hub
-	authService    *auth.Service
-	metricsService *metrics.Service
+	gameManager     *game.Manager
+	wsHub           *websocket.Hub
+	authService     *auth.Service
+	metricsService  *metrics.Service
+	leaderboards    *metrics.Leaderboards
+	achievements    *achievements.Service
+	userRepo        repository.UserStore
+	gameRepo        repository.GameStore
+	handHistoryRepo repository.HandHistoryStore
+	tournamentRepo  *repository.TournamentRepository
+	reportRepo      *repository.ReportRepository
+	oauthProviders  map[string]oauth.Provider
+
+	// Exports read through these, which may be on a read replica
+	exportUserRepo repository.UserStore
+	exportHandRepo repository.HandHistoryStore
+
+	retention *retention.Job
+	database  *database.DB
+	auditLogs *repository.AuditLogRepository
+	clubs     *repository.ClubRepository
+	settings  *config.LiveSettings
+	cache     cache.Store
+	secrets   *gcp.Secrets
+	features  *flags.Flags
+
+	// Delayed operations, and the key and claims of those delivered
+	tasks      tasks.Scheduler
+	taskKey    []byte
+	taskClaims cache.Store
+
+	// Buckets for large exports and avatars, nil when not configured
+	exports           storage.Bucket
+	avatars           storage.Bucket
+	exportInlineLimit int
+	signedURLTTL      time.Duration
 }
 
 // New creates a new handler instance
-func New(gameManager *game.Manager, wsHub *websocket.Hub, authService *auth.Service, metricsService *metrics.Service) *Handler {
+func New(gameManager *game.Manager, wsHub *websocket.Hub, authService *auth.Service, metricsService *metrics.Service, leaderboards *metrics.Leaderboards, achievementService *achievements.Service, userRepo repository.UserStore, gameRepo repository.GameStore, handHistoryRepo repository.HandHistoryStore, tournamentRepo *repository.TournamentRepository, reportRepo *repository.ReportRepository, oauthProviders map[string]oauth.Provider) *Handler {
 	return &Handler{
-		gameManager:    gameManager,
-		wsHub:          wsHub,
-		authService:    authService,
-		metricsService: metricsService,
+		gameManager:     gameManager,
+		wsHub:           wsHub,
+		authService:     authService,
+		metricsService:  metricsService,
+		leaderboards:    leaderboards,
+		achievements:    achievementService,
+		userRepo:        userRepo,
+		gameRepo:        gameRepo,
+		handHistoryRepo: handHistoryRepo,
+		tournamentRepo:  tournamentRepo,
+		reportRepo:      reportRepo,
+		oauthProviders:  oauthProviders,
+		exportUserRepo:  userRepo,
+		exportHandRepo:  handHistoryRepo,
 	}
 }
 
+// SetRetention sets the retention job admins can dry run
+func (h *Handler) SetRetention(job *retention.Job) {
+	h.retention = job
+}
+
+// SetDatabase sets the database whose connection pools the health check
+// reports and whose connection the readiness probe checks
+func (h *Handler) SetDatabase(db *database.DB) {
+	h.database = db
+}
+
+// SetCache sets the shared cache the readiness probe checks
+func (h *Handler) SetCache(store cache.Store) {
+	h.cache = store
+}
+
+// SetSecrets sets the Secret Manager cache whose fetch failures the
+// readiness probe reports
+func (h *Handler) SetSecrets(secrets *gcp.Secrets) {
+	h.secrets = secrets
+}
+
+// SetAuditLog sets the audit log admin actions are recorded in
+func (h *Handler) SetAuditLog(auditLogs *repository.AuditLogRepository) {
+	h.auditLogs = auditLogs
+}
+
+// SetClubs sets the clubs users create, join and keep tables to
+func (h *Handler) SetClubs(clubs *repository.ClubRepository) {
+	h.clubs = clubs
+}
+
+// SetExportRepositories sets the repositories account and hand history
+// exports read from, so long exports can run against a read replica
+func (h *Handler) SetExportRepositories(userRepo repository.UserStore, handHistoryRepo repository.HandHistoryStore) {
+	h.exportUserRepo = userRepo
+	h.exportHandRepo = handHistoryRepo
+}
+
 // Response represents a standard API response
 type Response struct {
 	Success bool        `json:"success"`
 	Data    interface{} `json:"data,omitempty"`
 	Error   string      `json:"error,omitempty"`
+	Code    string      `json:"code,omitempty"`
+
+	// RequestID lets an error be matched to the request's log entries
+	RequestID string `json:"request_id,omitempty"`
 }
 
 // writeJSON writes a JSON response
 func (h *Handler) writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	if response, ok := data.(Response); ok && !response.Success {
+		response.RequestID = w.Header().Get(logging.Header)
+		data = response
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	if err := json.NewEncoder(w).Encode(data); err != nil {
@@ -59,6 +172,22 @@ func (h *Handler) writeError(w http.ResponseWriter, status int, message string)
 	})
 }
 
+// writeErrorCode writes an error response with a machine-readable code
+func (h *Handler) writeErrorCode(w http.ResponseWriter, status int, code, message string) {
+	h.writeJSON(w, status, Response{
+		Success: false,
+		Error:   message,
+		Code:    code,
+	})
+}
+
+// writeShuttingDown tells the client this instance is shutting down, and
+// when to try again, by when another will have taken over
+func (h *Handler) writeShuttingDown(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(websocket.ShutdownRetryAfter/time.Second)))
+	h.writeErrorCode(w, http.StatusServiceUnavailable, "shutting_down", "Server is shutting down")
+}
+
 // writeSuccess writes a success response
 func (h *Handler) writeSuccess(w http.ResponseWriter, data interface{}) {
 	h.writeJSON(w, http.StatusOK, Response{
@@ -67,12 +196,75 @@ func (h *Handler) writeSuccess(w http.ResponseWriter, data interface{}) {
 	})
 }
 
+// paginationParams documents the query parameters shared by paginated list endpoints
+var paginationParams = map[string]string{
+	"limit":  "number of items (optional, default 50, max 200)",
+	"cursor": "next_cursor from the previous page (optional)",
+	"offset": "deprecated, use cursor",
+}
+
 // HealthCheck handles health check requests
 func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
-	h.writeSuccess(w, map[string]interface{}{
+	health := map[string]interface{}{
 		"status":    "healthy",
 		"timestamp": time.Now(),
-	})
+	}
+	if h.database != nil {
+		health["database_pools"] = h.database.PoolStats()
+	}
+	h.writeSuccess(w, health)
+}
+
+// Readiness reports whether the server can take traffic. It pings the
+// database, and the shared cache if there is one, with a short timeout: a
+// slow database or an unreachable cache is reported degraded but still
+// ready, since the server falls back to its own memory without the cache,
+// and only a failed database returns 503.
+func (h *Handler) Readiness(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), database.HealthTimeout)
+	defer cancel()
+
+	readiness := map[string]interface{}{"status": "ready"}
+	if h.cache != nil {
+		report := map[string]interface{}{"status": "up"}
+		if err := h.cache.Ping(ctx); err != nil {
+			report = map[string]interface{}{"status": "down", "error": err.Error()}
+			readiness["status"] = "degraded"
+			logging.FromContext(r.Context()).WithError(err).Warn("Readiness check: shared cache unreachable")
+		}
+		readiness["cache"] = report
+	}
+	if h.secrets != nil {
+		// Secrets that fail to refresh keep their last values, so the
+		// server can still serve
+		report := h.secrets.Health()
+		if report.Status != "up" {
+			readiness["status"] = "degraded"
+		}
+		readiness["secrets"] = report
+	}
+	if h.database == nil {
+		h.writeSuccess(w, readiness)
+		return
+	}
+
+	report := h.database.Check(ctx)
+	readiness["database"] = report
+	switch report.Status {
+	case database.HealthDegraded:
+		readiness["status"] = "degraded"
+	case database.HealthDown:
+		readiness["status"] = "unavailable"
+		logging.FromContext(r.Context()).WithField("error", report.Error).Warn("Readiness check failed: database unreachable")
+		h.writeJSON(w, http.StatusServiceUnavailable, Response{
+			Success: false,
+			Data:    readiness,
+			Error:   "Database unavailable",
+			Code:    "database_unavailable",
+		})
+		return
+	}
+	h.writeSuccess(w, readiness)
 }
 
 // APIDocumentation handles the API base URL and provides API documentation
@@ -91,7 +283,8 @@ func (h *Handler) APIDocumentation(w http.ResponseWriter, r *http.Request) {
 						"username": "string",
 						"password": "string",
 					},
-					"response": "JWT token and user information",
+					"response":    "JWT token and user information",
+					"error_codes": "account_locked, too_many_attempts",
 				},
 				"POST /api/v1/auth/register": map[string]interface{}{
 					"description": "User registration",
@@ -100,21 +293,121 @@ func (h *Handler) APIDocumentation(w http.ResponseWriter, r *http.Request) {
 						"password": "string",
 						"email":    "string",
 					},
-					"response": "JWT token and user information",
+					"response":    "JWT token and user information",
+					"error_codes": "invalid_password (data.fields.password lists the failed rules)",
 				},
 				"POST /api/v1/auth/refresh": map[string]interface{}{
 					"description": "Refresh JWT token",
 					"body": map[string]string{
 						"refresh_token": "string",
 					},
-					"response": "New JWT token",
+					"response": "New JWT token and rotated refresh token",
+				},
+				"GET /api/v1/auth/oauth/{provider}/start": map[string]interface{}{
+					"description": "Redirect to the provider's sign-in page (provider: google)",
+				},
+				"GET /api/v1/auth/oauth/{provider}/callback": map[string]interface{}{
+					"description": "Provider redirect target; signs in or creates the linked account",
+					"response":    "JWT token and user information",
+					"error_codes": "oauth_link_required (data.link_token is returned), oauth_email_unverified, oauth_state_mismatch, account_locked",
+				},
+				"POST /api/v1/auth/oauth/{provider}/link": map[string]interface{}{
+					"description": "Link a provider identity to the existing account with its email",
+					"body": map[string]string{
+						"link_token": "string",
+						"password":   "string",
+					},
+					"response":    "JWT token and user information",
+					"error_codes": "invalid_link_token, account_locked, too_many_attempts",
+				},
+			},
+			"account": map[string]interface{}{
+				"GET /api/v1/users/me/sessions": map[string]interface{}{
+					"description":    "List active sessions with IP, user agent and last use",
+					"authentication": "Bearer token required",
+				},
+				"DELETE /api/v1/users/me/sessions": map[string]interface{}{
+					"description":    "Revoke all sessions except the current one",
+					"authentication": "Bearer token required",
+				},
+				"GET /api/v1/users/me/logins": map[string]interface{}{
+					"description":    "Login history with IP, user agent, outcome and whether the device was new",
+					"authentication": "Bearer token required",
+					"query_params":   paginationParams,
+					"response":       "Page of login events, most recent first",
+				},
+				"GET /api/v1/users/me/achievements": map[string]interface{}{
+					"description":    "Every achievement with whether you have unlocked it; unlocks are also pushed over WebSocket as achievement_unlocked",
+					"authentication": "Bearer token required",
+					"response":       "Array of code, name, description, badge, unlocked and unlocked_at",
+				},
+				"GET /api/v1/users/me/tables": map[string]interface{}{
+					"description":    "The tables you are seated at, with your seat and stack at each",
+					"authentication": "Bearer token required",
+					"response":       "Array of table listings with seat_position and chip_count",
+				},
+				"DELETE /api/v1/users/me/sessions/{sessionId}": map[string]interface{}{
+					"description":    "Revoke a session",
+					"authentication": "Bearer token required",
+				},
+				"POST /api/v1/users/me/api-keys": map[string]interface{}{
+					"description":    "Create an API key for bots and tools; the key is only shown in this response",
+					"authentication": "Bearer token required (API keys cannot manage account routes)",
+					"body": map[string]string{
+						"name":       "string",
+						"scopes":     "array of read, play and admin",
+						"expires_at": "RFC 3339 time (optional)",
+					},
+					"response":    "key and its description",
+					"error_codes": "invalid_scope, scope_not_allowed, too_many_api_keys",
+				},
+				"GET /api/v1/users/me/api-keys": map[string]interface{}{
+					"description":    "List API keys with their scopes, expiry and last use",
+					"authentication": "Bearer token required",
+				},
+				"DELETE /api/v1/users/me/api-keys/{keyId}": map[string]interface{}{
+					"description":    "Revoke an API key",
+					"authentication": "Bearer token required",
+				},
+				"DELETE /api/v1/users/me": map[string]interface{}{
+					"description":    "Delete the account: cash out of tables, anonymize the profile and revoke all sessions",
+					"authentication": "Bearer token required",
+					"body":           map[string]string{"password": "string"},
+				},
+				"GET /api/v1/users/me/export": map[string]interface{}{
+					"description":    "Download a JSON archive of the profile, participations and hand histories",
+					"authentication": "Bearer token required",
+					"response":       "Streamed JSON file",
+				},
+				"PUT /api/v1/users/me/password": map[string]interface{}{
+					"description":    "Change password, revoking all other sessions",
+					"authentication": "Bearer token required",
+					"body": map[string]string{
+						"current_password": "string",
+						"new_password":     "string",
+					},
+					"error_codes": "invalid_password (data.fields.new_password lists the failed rules)",
+				},
+				"PUT /api/v1/users/me/avatar": map[string]interface{}{
+					"description":    "Upload a PNG, JPEG, GIF or WebP image as your avatar",
+					"authentication": "Bearer token required",
+					"body":           "The image itself, up to BODY_LIMIT_AVATAR bytes",
+					"response":       "URL of the avatar",
+					"error_codes":    "unsupported_image (415), body_too_large (413), avatars_disabled (503)",
 				},
 			},
 			"games": map[string]interface{}{
 				"GET /api/v1/games": map[string]interface{}{
-					"description":    "List all active games",
+					"description":    "List active games open to everyone and to your clubs",
 					"authentication": "Bearer token required",
-					"response":       "Array of game objects",
+					"query_params":   paginationParams,
+					"response":       "Page of game objects (clubs=mine limits to your clubs' tables); tables opened from a template carry its template_id",
+				},
+				"GET /api/v1/games/history": map[string]interface{}{
+					"description":    "List finished games, most recent first",
+					"authentication": "Bearer token required",
+					"query_params":   paginationParams,
+					"response":       "Page of finished games (mine=true limits to your own games)",
 				},
 				"POST /api/v1/games": map[string]interface{}{
 					"description":    "Create a new game",
@@ -125,13 +418,22 @@ func (h *Handler) APIDocumentation(w http.ResponseWriter, r *http.Request) {
 						"big_blind":   "number",
 						"buy_in":      "number",
 						"max_players": "number",
+						"club_id":     "string (optional, keeps the table to the club's members)",
+						"template":    "string (optional, name or id of a table template whose stakes and seats to use instead)",
 					},
-					"response": "Created game object",
+					"response":    "Created game object",
+					"error_codes": "club_members_only",
 				},
 				"GET /api/v1/games/{gameId}": map[string]interface{}{
 					"description":    "Get specific game details",
 					"authentication": "Bearer token required",
-					"response":       "Game state object",
+					"response":       "Game state object; seated players also get each opponent's hud stats when enabled",
+				},
+				"GET /api/v1/games/{gameId}/hud": map[string]interface{}{
+					"description":    "Get hands tracked, VPIP, PFR, 3-bet, aggression factor and steal stats for each opponent at a table you are seated at",
+					"authentication": "Bearer token required",
+					"response":       "Array of HUD stats in seat order; stats show as \"-\" until a player has enough hands",
+					"error_codes":    "hud_disabled",
 				},
 				"POST /api/v1/games/{gameId}/join": map[string]interface{}{
 					"description":    "Join a game",
@@ -139,22 +441,38 @@ func (h *Handler) APIDocumentation(w http.ResponseWriter, r *http.Request) {
 					"body": map[string]string{
 						"buy_in": "number",
 					},
-					"response": "Updated game state",
+					"response":    "Updated game state",
+					"error_codes": "club_members_only, insufficient_balance",
 				},
 				"POST /api/v1/games/{gameId}/leave": map[string]interface{}{
 					"description":    "Leave a game",
 					"authentication": "Bearer token required",
 					"response":       "Success message",
 				},
+				"POST /api/v1/games/{gameId}/report": map[string]interface{}{
+					"description":    "Report a player for abusive chat, collusion or cheating",
+					"authentication": "Bearer token required",
+					"body": map[string]string{
+						"player_id":   "string",
+						"category":    "abusive_chat, collusion, cheating or other",
+						"description": "string",
+						"hand_number": "number (optional)",
+					},
+					"error_codes": "report_rate_limited",
+				},
 			},
 			"metrics": map[string]interface{}{
 				"GET /api/v1/metrics": map[string]interface{}{
 					"description":    "Get player metrics for authenticated user",
 					"authentication": "Bearer token required",
 					"query_params": map[string]string{
-						"since": "ISO 8601 timestamp (optional)",
+						"since":         "ISO 8601 timestamp (optional)",
+						"game_type":     "texas_holdem, omaha or stud (optional)",
+						"min_big_blind": "Smallest big blind to include (optional)",
+						"max_big_blind": "Largest big blind to include (optional)",
 					},
-					"response": "Player statistics and metrics",
+					"response":   "Player statistics and metrics, with a breakdown by game type and blind level; win rates as bb_per_100, chips_per_hour and bb_per_hour, flagged small_sample under 100 hands",
+					"deprecated": "went_to_showdown, won_at_showdown and showdown_win_rate count hands that ended pre-flop; use wtsd_percent, wsd_percent and wwsf_percent",
 				},
 				"GET /api/v1/metrics/comparison": map[string]interface{}{
 					"description":    "Compare player metrics between time periods",
@@ -165,15 +483,324 @@ func (h *Handler) APIDocumentation(w http.ResponseWriter, r *http.Request) {
 						"period2_start": "ISO 8601 timestamp",
 						"period2_end":   "ISO 8601 timestamp",
 					},
-					"response": "Comparative metrics analysis",
+					"response": "period1 and period2 metrics, and change: period 2's bb_per_100, bb_per_hour and chips_per_hour less period 1's",
+				},
+				"GET /api/v1/metrics/me/sessions": map[string]interface{}{
+					"description":    "List playing sessions, newest first; hands closer together than the session gap share a session",
+					"authentication": "Bearer token required",
+					"query_params": map[string]string{
+						"limit":  "number of items (optional, default 50, max 200)",
+						"cursor": "next_cursor from the previous page (optional)",
+					},
+					"response": "Page of sessions with duration, hands, net result, bb/100, biggest pot and tables",
+				},
+				"GET /api/v1/metrics/me/sessions/{sessionId}": map[string]interface{}{
+					"description":    "Get one playing session with its hands",
+					"authentication": "Bearer token required",
+					"response":       "Session statistics and hands",
+				},
+				"GET /api/v1/metrics/me/starting-hands": map[string]interface{}{
+					"description":    "Results with each of the 169 starting hands, as the 13x13 grid row by row from AA; suited hands above the diagonal, offsuit below",
+					"authentication": "Bearer token required",
+					"query_params": map[string]string{
+						"position": "UTG, UTG+1, UTG+2, MP, LJ, HJ, CO, BTN, SB or BB (optional)",
+						"from":     "ISO 8601 timestamp (optional)",
+						"to":       "ISO 8601 timestamp (optional)",
+					},
+					"response": "169 entries of hand, count, vpip_percent, net_result and bb_per_100",
+				},
+				"GET /api/v1/metrics/me/summaries": map[string]interface{}{
+					"description":    "Hand summaries for each day or week played, including pocket pair, suited and connected hole card counts",
+					"authentication": "Bearer token required",
+					"query_params": map[string]string{
+						"period": "day or week, weeks running Monday to Sunday UTC (optional, default week)",
+						"from":   "ISO 8601 timestamp (optional, default 12 periods before to)",
+						"to":     "ISO 8601 timestamp (optional, default now)",
+					},
+					"response": "Summaries oldest first; the period still running is totalled from its hands so far",
+				},
+				"GET /api/v1/metrics/me/bankroll": map[string]interface{}{
+					"description":    "Daily results with a running bankroll, read from the stored daily totals",
+					"authentication": "Bearer token required",
+					"query_params": map[string]string{
+						"from": "ISO 8601 timestamp (optional, default 29 days before to)",
+						"to":   "ISO 8601 timestamp (optional, default now)",
+					},
+					"response": "Days played oldest first, with hands, net result, big blinds won and rake paid",
+				},
+				"GET /api/v1/metrics/me/vs/{username}": map[string]interface{}{
+					"description":    "Head-to-head record against another player over the hands both were dealt into",
+					"authentication": "Bearer token required",
+					"response":       "hands_together, and over the pots the two contested: pots and showdowns won and lost, and net chips and big blinds won from the opponent; 404 until enough hands have been played together",
+				},
+				"GET /api/v1/metrics/me/export": map[string]interface{}{
+					"description":    "Download lifetime metrics as a file, a row for each stake, position or month played",
+					"authentication": "Bearer token required",
+					"query_params": map[string]string{
+						"format":   "csv or json (optional, default csv)",
+						"group_by": "stake, position or month (optional, default stake)",
+					},
+					"response": "CSV with a header row, or a JSON array of objects with the same keys in the same order",
 				},
 				"GET /api/v1/users/{userId}/metrics": map[string]interface{}{
 					"description":    "Get metrics for specific user (self only)",
 					"authentication": "Bearer token required",
 					"query_params": map[string]string{
-						"since": "ISO 8601 timestamp (optional)",
+						"since":         "ISO 8601 timestamp (optional)",
+						"game_type":     "texas_holdem, omaha or stud (optional)",
+						"min_big_blind": "Smallest big blind to include (optional)",
+						"max_big_blind": "Largest big blind to include (optional)",
+					},
+					"response": "User statistics and metrics, with a breakdown by game type and blind level",
+				},
+				"GET /api/v1/users/{userId}/stats": map[string]interface{}{
+					"description":    "Hand totals for a user, filtered (self only)",
+					"authentication": "Bearer token required",
+					"query_params": map[string]string{
+						"since":         "ISO 8601 timestamp (optional)",
+						"game_type":     "texas_holdem, omaha or stud (optional)",
+						"min_big_blind": "Smallest big blind to include (optional)",
+						"max_big_blind": "Largest big blind to include (optional)",
+						"table_size":    "heads_up, six_max or full_ring (optional)",
+						"winners_only":  "true to total only hands won (optional)",
+					},
+					"response": "Hand counts, chips won and wagered, and average VPIP, PFR and aggression",
+				},
+				"GET /api/v1/users/{userId}/profile": map[string]interface{}{
+					"description":    "Public profile of any player",
+					"authentication": "Bearer token required",
+					"response":       "id, username, display_name, avatar, member_since and the badges the player has unlocked",
+				},
+				"GET /api/v1/users/{userId}/ranks": map[string]interface{}{
+					"description":    "A player's place on each stat leaderboard, ranked live over a period",
+					"authentication": "Bearer token required",
+					"query_params": map[string]string{
+						"period": "day, week, month or all_time (optional, defaults to all_time)",
+					},
+					"response": "Metric, rank, value, hands played, players ranked and top percent for each board the player is ranked on",
+				},
+			},
+			"leaderboard": map[string]interface{}{
+				"GET /api/v1/leaderboard": map[string]interface{}{
+					"description":    "List players by total winnings",
+					"authentication": "Bearer token required",
+					"query_params":   paginationParams,
+					"response":       "Page of players",
+				},
+				"GET /api/v1/leaderboard/{metric}": map[string]interface{}{
+					"description":    "Rank players by bb_per_100, biggest_pot, winning_streak or showdown_win_rate, rebuilt periodically from hand history, or ranked live over a period",
+					"authentication": "Bearer token required",
+					"query_params": map[string]string{
+						"period": "day, week, month or all_time to rank live over the period (optional, defaults to the latest rebuilt board)",
+						"limit":  paginationParams["limit"],
+						"cursor": paginationParams["cursor"],
+					},
+					"response": "Board day (or period), minimum hands to be ranked, page of entries and the authenticated user's own entry (null when unranked); live entries also give the players ranked and the top percent each is in",
+				},
+			},
+			"hands": map[string]interface{}{
+				"GET /api/v1/hands": map[string]interface{}{
+					"description":    "List the authenticated user's hands, newest first",
+					"authentication": "Bearer token required",
+					"query_params":   paginationParams,
+					"response":       "Page of hand histories",
+				},
+				"GET /api/v1/hands/search": map[string]interface{}{
+					"description":    "Search the authenticated user's hands recorded with a table-level record; every parameter given must match",
+					"authentication": "Bearer token required",
+					"query_params": map[string]string{
+						"q":          "Words the hand's summary must contain (optional; stemmed full-text search on Postgres)",
+						"hole_cards": "Starting hand such as AA, AKs, AKo or AK (optional)",
+						"board":      "Comma-separated flush_possible, paired, straight_possible (optional)",
+						"min_pot":    "Smallest pot in chips (optional)",
+						"max_pot":    "Largest pot in chips (optional)",
+						"min_result": "Smallest net result in chips, negative for losses (optional)",
+						"max_result": "Largest net result in chips (optional)",
+						"opponent":   "Username of another player dealt in (optional)",
+						"sort":       "date or relevance (optional, defaults to date; relevance needs q)",
+						"limit":      paginationParams["limit"],
+						"cursor":     paginationParams["cursor"],
+					},
+					"response": "Page of hand histories with the table-level hand and, sorted by relevance, each hand's relevance",
+				},
+				"GET /api/v1/hands/export": map[string]interface{}{
+					"description":    "Export hand histories for the authenticated user",
+					"authentication": "Bearer token required",
+					"query_params": map[string]string{
+						"from":   "ISO 8601 timestamp (optional)",
+						"to":     "ISO 8601 timestamp (optional, defaults to now)",
+						"format": "pokerstars, csv or json (optional, defaults to pokerstars)",
 					},
-					"response": "User statistics and metrics",
+					"response": "Streamed hand history file; a large export is stored instead and answered with 303 See Other to a short-lived URL, also given in data.url",
+				},
+			},
+			"tournaments": map[string]interface{}{
+				"GET /api/v1/tournaments": map[string]interface{}{
+					"description":    "List upcoming and running tournaments",
+					"authentication": "Bearer token required",
+					"response":       "Tournaments with structure, buy-in and registration counts",
+				},
+				"GET /api/v1/tournaments/{id}": map[string]interface{}{
+					"description":    "Get tournament details",
+					"authentication": "Bearer token required",
+					"response":       "Blind structure, payout table, current level and remaining players",
+				},
+				"GET /api/v1/tournaments/{id}/players": map[string]interface{}{
+					"description":    "List tournament players",
+					"authentication": "Bearer token required",
+					"response":       "Players with stacks and table assignments",
+				},
+				"POST /api/v1/tournaments/{id}/register": map[string]interface{}{
+					"description":    "Register for a tournament, debiting the buy-in",
+					"authentication": "Bearer token required",
+					"error_codes":    "registration_closed, tournament_full, already_registered, insufficient_balance",
+				},
+				"POST /api/v1/tournaments/{id}/unregister": map[string]interface{}{
+					"description":    "Unregister from a tournament before it starts, refunding the buy-in",
+					"authentication": "Bearer token required",
+					"error_codes":    "tournament_started, not_registered",
+				},
+			},
+			"clubs": map[string]interface{}{
+				"GET /api/v1/clubs": map[string]interface{}{
+					"description":    "List the clubs you belong to",
+					"authentication": "Bearer token required",
+				},
+				"POST /api/v1/clubs": map[string]interface{}{
+					"description":    "Create a club you own, with a join code for inviting players",
+					"authentication": "Bearer token required",
+					"body": map[string]string{
+						"name":        "string",
+						"description": "string (optional)",
+					},
+				},
+				"POST /api/v1/clubs/join": map[string]interface{}{
+					"description":    "Join a club",
+					"authentication": "Bearer token required",
+					"body":           map[string]string{"join_code": "string"},
+					"error_codes":    "club_not_found, already_member",
+				},
+				"GET /api/v1/clubs/{clubId}": map[string]interface{}{
+					"description":    "Get a club you belong to, with its members and open tables",
+					"authentication": "Bearer token required",
+					"error_codes":    "club_not_found",
+				},
+				"POST /api/v1/clubs/{clubId}/leave": map[string]interface{}{
+					"description":    "Leave a club; its owner cannot",
+					"authentication": "Bearer token required",
+					"error_codes":    "club_not_found, club_owner",
+				},
+				"GET /api/v1/clubs/{clubId}/leaderboard": map[string]interface{}{
+					"description":    "Rank a club's members by net result over the hands they played at its tables",
+					"authentication": "Bearer token required",
+					"query_params":   map[string]string{"since": "ISO 8601 timestamp (optional)"},
+					"error_codes":    "club_not_found",
+				},
+			},
+			"admin": map[string]interface{}{
+				"POST /api/v1/admin/games/{gameId}/close": map[string]interface{}{
+					"description":    "Close a table, voiding any hand in progress and cashing out all players",
+					"authentication": "Bearer token required (admin)",
+					"body":           map[string]string{"reason": "string"},
+				},
+				"POST /api/v1/admin/games/{gameId}/force-start": map[string]interface{}{
+					"description":    "Start a hand at a table waiting for players",
+					"authentication": "Bearer token required (admin)",
+					"body":           map[string]string{"reason": "string"},
+				},
+				"POST /api/v1/admin/games/{gameId}/kick/{userId}": map[string]interface{}{
+					"description":    "Remove a player from a table with their stack cashed out",
+					"authentication": "Bearer token required (admin)",
+					"body":           map[string]string{"reason": "string"},
+				},
+				"POST /api/v1/admin/games/{gameId}/move/{userId}": map[string]interface{}{
+					"description":    "Move a player and their stack to another table between hands, at its lowest free seat",
+					"authentication": "Bearer token required (admin)",
+					"body": map[string]string{
+						"to_game_id": "string",
+						"reason":     "string",
+					},
+					"error_codes": "table_full, hand_in_progress, stack_out_of_range, already_seated, club_members_only",
+				},
+				"PUT /api/v1/admin/users/{userId}/role": map[string]interface{}{
+					"description":    "Change a user's role",
+					"authentication": "Bearer token required (admin)",
+					"body": map[string]string{
+						"role":   "player, moderator or admin",
+						"reason": "string",
+					},
+				},
+				"PUT /api/v1/admin/games/{gameId}/config": map[string]interface{}{
+					"description":    "Change blinds or turn timeout from the next hand",
+					"authentication": "Bearer token required (admin)",
+					"body": map[string]string{
+						"small_blind":          "number (optional)",
+						"big_blind":            "number (optional)",
+						"turn_timeout_seconds": "number (optional)",
+						"reason":               "string",
+					},
+				},
+				"POST /api/v1/admin/retention/dry-run": map[string]interface{}{
+					"description":    "Report what the retention policy would purge and archive if it ran now",
+					"authentication": "Bearer token required (admin)",
+					"response":       "Counts of deleted users and games to purge, player totals to rebuild and hands to archive, with the cutoffs used",
+				},
+				"POST /api/v1/admin/settings/reload": map[string]interface{}{
+					"description":    "Re-read the log level, rate limit, CORS origins, game defaults and WebSocket timings without a restart, as a SIGHUP does; tables already running keep their settings",
+					"authentication": "Bearer token required (admin)",
+					"body":           map[string]string{"reason": "string"},
+					"response":       "The settings that changed, with their old and new values",
+				},
+				"GET /api/v1/admin/flags": map[string]interface{}{
+					"description":    "List the feature flags with the state in effect and whether it comes from the default, the configuration or an admin",
+					"authentication": "Bearer token required (admin)",
+					"response":       "Each flag's name, description, default, enabled, percentage, users and source",
+				},
+				"PUT /api/v1/admin/flags/{flag}": map[string]interface{}{
+					"description":    "Override a feature flag on this instance: on for everyone, for a percentage of users, or for listed user IDs",
+					"authentication": "Bearer token required (admin)",
+					"body":           map[string]string{"enabled": "boolean", "percentage": "integer (0-100)", "users": "array of user IDs", "reason": "string"},
+					"response":       "The flag's new state",
+					"error_codes":    "404 for an unknown flag",
+				},
+				"DELETE /api/v1/admin/flags/{flag}": map[string]interface{}{
+					"description":    "Remove an admin override, returning the flag to its configured state",
+					"authentication": "Bearer token required (admin)",
+					"body":           map[string]string{"reason": "string"},
+					"response":       "The flag's state after the override is removed",
+				},
+				"GET /api/v1/admin/audit": map[string]interface{}{
+					"description":    "List audit log entries for admin and moderator actions, most recent first",
+					"authentication": "Bearer token required (admin)",
+					"query_params": map[string]string{
+						"actor_id":    "UUID of the user who acted (optional)",
+						"target_type": "user, game, report, retention, settings or flag (optional)",
+						"target_id":   "ID of the target (optional)",
+						"from":        "ISO 8601 timestamp, inclusive (optional)",
+						"to":          "ISO 8601 timestamp, exclusive (optional)",
+						"limit":       "Page size (optional)",
+						"cursor":      "next_cursor from the previous page (optional)",
+					},
+					"response": "Entries with the actor, action, target, before and after snapshots, reason and IP address",
+				},
+				"GET /api/v1/admin/reports": map[string]interface{}{
+					"description":    "List player reports, oldest first",
+					"authentication": "Bearer token required (moderator)",
+					"query_params":   paginationParams,
+					"response":       "Page of reports (status=open by default, or resolved)",
+				},
+				"GET /api/v1/admin/reports/{reportId}": map[string]interface{}{
+					"description":    "Get a report with the chat and actions captured when it was filed",
+					"authentication": "Bearer token required (moderator)",
+				},
+				"POST /api/v1/admin/reports/{reportId}/resolve": map[string]interface{}{
+					"description":    "Resolve a report; ban bans the reported player",
+					"authentication": "Bearer token required (moderator)",
+					"body": map[string]string{
+						"outcome": "dismiss, warn or ban",
+						"note":    "string",
+					},
+					"error_codes": "report_resolved",
 				},
 			},
 			"websocket": map[string]interface{}{
@@ -192,6 +819,11 @@ func (h *Handler) APIDocumentation(w http.ResponseWriter, r *http.Request) {
 					"description": "Health check endpoint",
 					"response":    "Server health status",
 				},
+				"GET /ready": map[string]interface{}{
+					"description": "Readiness probe; pings the database with a 500ms timeout",
+					"response":    "ready, or degraded when the database answers slowly, with its latency, pools and open transactions; 503 when it does not answer",
+					"error_codes": "database_unavailable",
+				},
 			},
 		},
 		"authentication": map[string]interface{}{
@@ -199,6 +831,14 @@ func (h *Handler) APIDocumentation(w http.ResponseWriter, r *http.Request) {
 			"header":      "Authorization: Bearer <JWT_TOKEN>",
 			"description": "Most endpoints require JWT authentication. Get token from /auth/login or /auth/register",
 		},
+		"pagination": map[string]interface{}{
+			"description": "List endpoints return {items, next_cursor, has_more}; pass next_cursor back as cursor for the next page",
+			"deprecated":  "offset is still accepted when no cursor is given but will be removed",
+		},
+		"request_bodies": map[string]interface{}{
+			"description": "Bodies must be sent with Content-Type: application/json and hold one object with only the documented fields",
+			"error_codes": "unsupported_media_type (415), body_too_large (413), unknown_field, invalid_field, invalid_json",
+		},
 		"websocket_usage": map[string]interface{}{
 			"description": "For real-time gameplay, connect to WebSocket endpoint after authentication",
 			"url":         "ws://host/ws?user_id=<USER_ID>&game_id=<GAME_ID>",
@@ -215,29 +855,65 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 		Password string `json:"password"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.writeError(w, http.StatusBadRequest, "Invalid request body")
+	if !h.decodeJSON(w, r, &req) {
 		return
 	}
 
 	// Validate credentials (this is a simplified version)
-	user, err := h.authService.AuthenticateUser(req.Username, req.Password)
+	user, err := h.authService.AuthenticateUser(req.Username, req.Password, middleware.ClientIP(r), r.UserAgent())
 	if err != nil {
-		h.writeError(w, http.StatusUnauthorized, "Invalid credentials")
+		h.writeLoginError(w, err)
 		return
 	}
 
-	// Generate JWT token
-	token, err := h.authService.GenerateToken(user)
+	// Open a session and issue its tokens
+	tokens, err := h.authService.StartSession(user, middleware.ClientIP(r), r.UserAgent())
 	if err != nil {
 		h.writeError(w, http.StatusInternalServerError, "Failed to generate token")
 		return
 	}
 
 	h.writeSuccess(w, map[string]interface{}{
-		"token": token,
-		"user":  user,
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"user":          user,
+	})
+}
+
+// writeLoginError reports why a sign-in was refused
+func (h *Handler) writeLoginError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, auth.ErrAccountDisabled):
+		h.writeError(w, http.StatusForbidden, "Account disabled")
+	case errors.Is(err, auth.ErrAccountLocked):
+		h.writeErrorCode(w, http.StatusLocked, "account_locked", "Account temporarily locked, try again later")
+	case errors.Is(err, auth.ErrTooManyAttempts):
+		h.writeErrorCode(w, http.StatusTooManyRequests, "too_many_attempts", "Too many failed login attempts, try again later")
+	default:
+		h.writeError(w, http.StatusUnauthorized, "Invalid credentials")
+	}
+}
+
+// writePasswordError reports a password that breaks the password policy,
+// listing the failed rules under the request field that carried it. It
+// returns false if err is not a policy violation.
+func (h *Handler) writePasswordError(w http.ResponseWriter, field string, err error) bool {
+	var invalid *password.ValidationError
+	if !errors.As(err, &invalid) {
+		return false
+	}
+
+	h.writeJSON(w, http.StatusBadRequest, Response{
+		Success: false,
+		Error:   "Password does not meet the requirements",
+		Code:    "invalid_password",
+		Data: map[string]interface{}{
+			"fields": map[string][]password.Violation{
+				field: invalid.Violations,
+			},
+		},
 	})
+	return true
 }
 
 // Register handles user registration
@@ -248,28 +924,31 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 		Email    string `json:"email"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.writeError(w, http.StatusBadRequest, "Invalid request body")
+	if !h.decodeJSON(w, r, &req) {
 		return
 	}
 
 	// Create user (this is a simplified version)
 	user, err := h.authService.CreateUser(req.Username, req.Password, req.Email)
+	if h.writePasswordError(w, "password", err) {
+		return
+	}
 	if err != nil {
 		h.writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// Generate JWT token
-	token, err := h.authService.GenerateToken(user)
+	// Open a session and issue its tokens
+	tokens, err := h.authService.StartSession(user, middleware.ClientIP(r), r.UserAgent())
 	if err != nil {
 		h.writeError(w, http.StatusInternalServerError, "Failed to generate token")
 		return
 	}
 
 	h.writeSuccess(w, map[string]interface{}{
-		"token": token,
-		"user":  user,
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"user":          user,
 	})
 }
 
@@ -279,27 +958,89 @@ func (h *Handler) RefreshToken(w http.ResponseWriter, r *http.Request) {
 		RefreshToken string `json:"refresh_token"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.writeError(w, http.StatusBadRequest, "Invalid request body")
+	if !h.decodeJSON(w, r, &req) {
 		return
 	}
 
-	// Validate refresh token and generate new access token
-	token, err := h.authService.RefreshToken(req.RefreshToken)
+	// Validate and rotate the refresh token, issuing a new access token
+	tokens, err := h.authService.RefreshSession(req.RefreshToken, middleware.ClientIP(r), r.UserAgent())
+	if errors.Is(err, auth.ErrRefreshTokenReused) {
+		logging.FromContext(r.Context()).WithFields(logrus.Fields{
+			"remote_ip":  middleware.ClientIP(r),
+			"user_agent": r.UserAgent(),
+		}).Warn("Rotated refresh token reused, session revoked")
+	}
 	if err != nil {
 		h.writeError(w, http.StatusUnauthorized, "Invalid refresh token")
 		return
 	}
 
 	h.writeSuccess(w, map[string]interface{}{
-		"token": token,
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
 	})
 }
 
-// ListGames handles listing all active games
+// ListGames handles listing all active games in the manager's lobby order:
+// lowest stakes first, then the oldest table
 func (h *Handler) ListGames(w http.ResponseWriter, r *http.Request) {
-	games := h.gameManager.ListGames()
-	h.writeSuccess(w, games)
+	page, ok := h.parsePageRequest(w, r)
+	if !ok {
+		return
+	}
+
+	games, err := h.visibleGames(r)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to list games")
+		return
+	}
+
+	// Tables live in memory, so the keyset is applied to the sorted slice
+	start := 0
+	if page.Cursor != nil {
+		after, err := gameCursorInfo(page.Cursor)
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		start = sort.Search(len(games), func(i int) bool {
+			return after.Before(games[i])
+		})
+	} else if page.Offset > 0 {
+		start = min(page.Offset, len(games))
+	}
+
+	end := min(start+page.Limit+1, len(games))
+	h.writeSuccess(w, pagination.NewPage(games[start:end], page, gameCursor))
+}
+
+// gameCursor builds a cursor for a table's place in the lobby, keyed by
+// its stakes and when it was opened
+func gameCursor(info *game.GameInfo) pagination.Cursor {
+	cursor := pagination.TimeCursor(info.Created, info.ID)
+	cursor.Key = fmt.Sprintf("%d/%d/%s", info.BigBlind, info.SmallBlind, cursor.Key)
+	return cursor
+}
+
+// gameCursorInfo decodes a lobby cursor into the table it was taken after
+func gameCursorInfo(cursor *pagination.Cursor) (*game.GameInfo, error) {
+	parts := strings.SplitN(cursor.Key, "/", 3)
+	if len(parts) != 3 {
+		return nil, pagination.ErrInvalidCursor
+	}
+	bigBlind, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, pagination.ErrInvalidCursor
+	}
+	smallBlind, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, pagination.ErrInvalidCursor
+	}
+	created, err := pagination.Cursor{Key: parts[2]}.Time()
+	if err != nil {
+		return nil, err
+	}
+	return &game.GameInfo{ID: cursor.ID, BigBlind: bigBlind, SmallBlind: smallBlind, Created: created}, nil
 }
 
 // CreateGame handles creating a new game
@@ -310,29 +1051,58 @@ func (h *Handler) CreateGame(w http.ResponseWriter, r *http.Request) {
 		BigBlind   int64  `json:"big_blind"`
 		BuyIn      int64  `json:"buy_in"`
 		MaxPlayers int    `json:"max_players"`
+		ClubID     string `json:"club_id"`
+		// Template opens a table like the standard one of that name or ID,
+		// in place of the stakes and seats above
+		Template string `json:"template"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.writeError(w, http.StatusBadRequest, "Invalid request body")
+	if !h.decodeJSON(w, r, &req) {
 		return
 	}
 
+	// Club tables are opened by the club's members, for its members
+	if req.ClubID != "" {
+		if !h.clubMember(w, r, req.ClubID) {
+			return
+		}
+	}
+
 	// Generate game ID
 	gameID := generateGameID()
 
 	// Create game with options
 	var options []game.GameOption
-	if req.SmallBlind > 0 && req.BigBlind > 0 {
-		options = append(options, game.WithBlinds(req.SmallBlind, req.BigBlind))
-	}
-	if req.BuyIn > 0 {
-		options = append(options, game.WithBuyIn(req.BuyIn, req.BuyIn/10, req.BuyIn*5))
+	if req.Template != "" {
+		template, ok := h.gameManager.Template(req.Template)
+		if !ok {
+			h.writeError(w, http.StatusBadRequest, "Unknown table template")
+			return
+		}
+		options = append(options, game.WithTemplate(template))
+		if req.Name == "" {
+			req.Name = template.Name
+		}
+	} else {
+		if req.SmallBlind > 0 && req.BigBlind > 0 {
+			options = append(options, game.WithBlinds(req.SmallBlind, req.BigBlind))
+		}
+		if req.BuyIn > 0 {
+			options = append(options, game.WithBuyIn(req.BuyIn, req.BuyIn/10, req.BuyIn*5))
+		}
+		if req.MaxPlayers > 0 {
+			options = append(options, game.WithPlayerLimits(2, req.MaxPlayers))
+		}
 	}
-	if req.MaxPlayers > 0 {
-		options = append(options, game.WithPlayerLimits(2, req.MaxPlayers))
+	if req.ClubID != "" {
+		options = append(options, game.WithClub(req.ClubID))
 	}
 
 	gameInstance, err := h.gameManager.CreateGame(gameID, req.Name, options...)
+	if errors.Is(err, game.ErrShuttingDown) {
+		h.writeShuttingDown(w)
+		return
+	}
 	if err != nil {
 		h.writeError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -352,7 +1122,7 @@ func (h *Handler) GetGame(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	gameState, err := h.gameManager.GetGameState(gameID, userID)
+	gameState, err := h.gameState(gameID, userID)
 	if err != nil {
 		h.writeError(w, http.StatusNotFound, err.Error())
 		return
@@ -377,8 +1147,7 @@ func (h *Handler) JoinGame(w http.ResponseWriter, r *http.Request) {
 		BuyIn int64 `json:"buy_in"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.writeError(w, http.StatusBadRequest, "Invalid request body")
+	if !h.decodeJSON(w, r, &req) {
 		return
 	}
 
@@ -386,22 +1155,31 @@ func (h *Handler) JoinGame(w http.ResponseWriter, r *http.Request) {
 		req.BuyIn = 10000 // Default buy-in
 	}
 
-	err := h.gameManager.JoinGame(gameID, userID, username, req.BuyIn)
+	err := h.gameManager.JoinGame(r.Context(), gameID, userID, username, req.BuyIn)
+	if errors.Is(err, game.ErrShuttingDown) {
+		h.writeShuttingDown(w)
+		return
+	}
+	if errors.Is(err, game.ErrNotClubMember) {
+		h.writeErrorCode(w, http.StatusForbidden, "club_members_only", err.Error())
+		return
+	}
+	if errors.Is(err, repository.ErrInsufficientBalance) {
+		h.writeErrorCode(w, http.StatusPaymentRequired, "insufficient_balance", err.Error())
+		return
+	}
 	if err != nil {
 		h.writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	// Get updated game state
-	gameState, err := h.gameManager.GetGameState(gameID, userID)
+	gameState, err := h.gameState(gameID, userID)
 	if err != nil {
 		h.writeError(w, http.StatusInternalServerError, "Failed to get game state")
 		return
 	}
 
-	// Notify other players
-	h.notifyGameUpdate(gameID, userID)
-
 	h.writeSuccess(w, gameState)
 }
 
@@ -416,15 +1194,12 @@ func (h *Handler) LeaveGame(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.gameManager.LeaveGame(gameID, userID)
+	err := h.gameManager.LeaveGame(r.Context(), gameID, userID)
 	if err != nil {
 		h.writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// Notify other players
-	h.notifyGameUpdate(gameID, userID)
-
 	h.writeSuccess(w, map[string]string{
 		"message": "Successfully left the game",
 	})
@@ -435,21 +1210,30 @@ func (h *Handler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	userID := r.URL.Query().Get("user_id")
 	gameID := r.URL.Query().Get("game_id")
 
+	// Bots connecting with an API key act as the key's owner
+	if keyUserID := getUserIDFromContext(r); keyUserID != "" {
+		userID = keyUserID
+	}
+
 	if userID == "" {
 		h.writeError(w, http.StatusBadRequest, "user_id is required")
 		return
 	}
 
 	client, err := h.wsHub.UpgradeConnection(w, r, userID, gameID)
+	if errors.Is(err, websocket.ErrHubClosed) {
+		h.writeShuttingDown(w)
+		return
+	}
 	if err != nil {
-		logrus.WithError(err).Error("Failed to upgrade WebSocket connection")
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to upgrade WebSocket connection")
 		h.writeError(w, http.StatusInternalServerError, "Failed to upgrade connection")
 		return
 	}
 
 	// Send initial game state if in a game
 	if gameID != "" {
-		gameState, err := h.gameManager.GetGameState(gameID, userID)
+		gameState, err := h.gameState(gameID, userID)
 		if err == nil {
 			message := websocket.Message{
 				Type:      websocket.MessageTypeGameState,
@@ -461,7 +1245,7 @@ func (h *Handler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	logrus.WithFields(logrus.Fields{
+	logging.FromContext(r.Context()).WithFields(logrus.Fields{
 		"user_id": userID,
 		"game_id": gameID,
 	}).Info("WebSocket connection established")
@@ -490,10 +1274,16 @@ func (h *Handler) GetPlayerMetrics(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	filter, err := parseMetricsFilter(r)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	// Get player metrics
-	metrics, err := h.metricsService.GetPlayerMetrics(userUUID, since)
+	metrics, err := h.metricsService.GetPlayerMetrics(userUUID, since, filter)
 	if err != nil {
-		logrus.WithError(err).Error("Failed to get player metrics")
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to get player metrics")
 		h.writeError(w, http.StatusInternalServerError, "Failed to get player metrics")
 		return
 	}
@@ -554,7 +1344,7 @@ func (h *Handler) GetPlayerMetricsComparison(w http.ResponseWriter, r *http.Requ
 	// Get metrics comparison
 	comparison, err := h.metricsService.GetPlayerMetricsComparison(userUUID, period1Start, period1End, period2Start, period2End)
 	if err != nil {
-		logrus.WithError(err).Error("Failed to get player metrics comparison")
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to get player metrics comparison")
 		h.writeError(w, http.StatusInternalServerError, "Failed to get player metrics comparison")
 		return
 	}
@@ -581,9 +1371,8 @@ func (h *Handler) GetUserMetrics(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// For now allow users to view their own metrics
-	// In the future, could add admin permissions for viewing other users
-	if requestingUserID != targetUserID {
+	// Users can view their own metrics; moderators can view anyone's
+	if !canActOnUser(r, targetUUID) {
 		h.writeError(w, http.StatusForbidden, "You can only view your own metrics")
 		return
 	}
@@ -596,10 +1385,16 @@ func (h *Handler) GetUserMetrics(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	filter, err := parseMetricsFilter(r)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	// Get player metrics
-	metrics, err := h.metricsService.GetPlayerMetrics(targetUUID, since)
+	metrics, err := h.metricsService.GetPlayerMetrics(targetUUID, since, filter)
 	if err != nil {
-		logrus.WithError(err).Error("Failed to get user metrics")
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to get user metrics")
 		h.writeError(w, http.StatusInternalServerError, "Failed to get user metrics")
 		return
 	}
@@ -607,21 +1402,125 @@ func (h *Handler) GetUserMetrics(w http.ResponseWriter, r *http.Request) {
 	h.writeSuccess(w, metrics)
 }
 
-// ProcessGameAction handles game actions received via WebSocket or HTTP
-func (h *Handler) ProcessGameAction(gameID, userID string, action game.PlayerAction, amount int64) error {
-	err := h.gameManager.ProcessAction(gameID, userID, action, amount)
+// parseMetricsFilter reads the game_type, min_big_blind and max_big_blind
+// query parameters that narrow which hands metrics are calculated from
+func parseMetricsFilter(r *http.Request) (metrics.Filter, error) {
+	query := r.URL.Query()
+	filter := metrics.Filter{GameType: models.GameType(query.Get("game_type"))}
+
+	bounds := []struct {
+		param string
+		value *int64
+	}{
+		{"min_big_blind", &filter.MinBigBlind},
+		{"max_big_blind", &filter.MaxBigBlind},
+	}
+	for _, bound := range bounds {
+		value := query.Get(bound.param)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return metrics.Filter{}, errors.New("invalid " + bound.param)
+		}
+		*bound.value = parsed
+	}
+
+	if err := filter.Validate(); err != nil {
+		return metrics.Filter{}, err
+	}
+	return filter, nil
+}
+
+// ExportHands streams the authenticated user's hand histories in the requested
+// format, or hands a large export over to the export bucket when there is one
+func (h *Handler) ExportHands(w http.ResponseWriter, r *http.Request) {
+	userID := getUserIDFromContext(r)
+	if userID == "" {
+		h.writeError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	format, err := handexport.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	from := time.Time{}
+	if fromParam := r.URL.Query().Get("from"); fromParam != "" {
+		if from, err = time.Parse(time.RFC3339, fromParam); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid from format")
+			return
+		}
+	}
+
+	to := time.Now()
+	if toParam := r.URL.Query().Get("to"); toParam != "" {
+		if to, err = time.Parse(time.RFC3339, toParam); err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid to format")
+			return
+		}
+	}
+
+	if to.Before(from) {
+		h.writeError(w, http.StatusBadRequest, "to must not be before from")
+		return
+	}
+
+	if h.exports != nil {
+		h.exportHandsToBucket(w, r, userUUID, format, from, to)
+		return
+	}
+
+	writer, err := handexport.NewWriter(w, format)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// No Content-Length is set, so the body goes out with chunked encoding as batches are flushed
+	filename := "hands-" + time.Now().UTC().Format("20060102") + "." + format.Extension()
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.WriteHeader(http.StatusOK)
+
+	count, err := handexport.Export(writer, h.exportHandRepo, userUUID, from, to)
 	if err != nil {
-		return err
+		// Headers are already sent, so the truncated body is all the client gets
+		logging.FromContext(r.Context()).WithError(err).WithFields(logrus.Fields{
+			"user_id": userID,
+			"format":  format,
+			"hands":   count,
+		}).Error("Hand history export failed")
+		return
 	}
 
-	// Notify all players in the game
-	h.notifyGameUpdate(gameID, "")
+	logging.FromContext(r.Context()).WithFields(logrus.Fields{
+		"user_id": userID,
+		"format":  format,
+		"hands":   count,
+	}).Info("Hand history exported")
+}
 
-	return nil
+// ProcessGameAction handles game actions received via WebSocket or HTTP.
+// The players are sent the new state by the table's observer.
+func (h *Handler) ProcessGameAction(ctx context.Context, gameID, userID string, action game.PlayerAction, amount int64) error {
+	return h.gameManager.ProcessAction(ctx, gameID, userID, action, amount)
 }
 
 // notifyGameUpdate sends game state updates to all players in a game
-func (h *Handler) notifyGameUpdate(gameID, excludeUserID string) {
+func (h *Handler) notifyGameUpdate(ctx context.Context, gameID, excludeUserID string) {
+	_, span := tracer.Start(ctx, "Handler.notifyGameUpdate", trace.WithAttributes(attribute.String("game_id", gameID)))
+	defer span.End()
+
 	connectedUsers := h.wsHub.GetConnectedUsers(gameID)
 	
 	for _, userID := range connectedUsers {
@@ -629,7 +1528,7 @@ func (h *Handler) notifyGameUpdate(gameID, excludeUserID string) {
 			continue
 		}
 
-		gameState, err := h.gameManager.GetGameState(gameID, userID)
+		gameState, err := h.gameState(gameID, userID)
 		if err != nil {
 			logrus.WithError(err).Error("Failed to get game state for notification")
 			continue
@@ -648,9 +1547,10 @@ func (h *Handler) notifyGameUpdate(gameID, excludeUserID string) {
 
 // Helper functions
 
+// generateGameID returns the ID of a new table. It is a UUID so the
+// table's hands can be stored against it.
 func generateGameID() string {
-	// Simple implementation - in production, use a proper UUID library
-	return "game_" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	return uuid.New().String()
 }
 
 func getUserIDFromContext(r *http.Request) string {
@@ -660,6 +1560,13 @@ func getUserIDFromContext(r *http.Request) string {
 	return ""
 }
 
+func getSessionIDFromContext(r *http.Request) string {
+	if sessionID := r.Context().Value("session_id"); sessionID != nil {
+		return sessionID.(string)
+	}
+	return ""
+}
+
 func getUsernameFromContext(r *http.Request) string {
 	if username := r.Context().Value("username"); username != nil {
 		return username.(string)
@@ -667,6 +1574,19 @@ func getUsernameFromContext(r *http.Request) string {
 	return ""
 }
 
+func getRoleFromContext(r *http.Request) models.Role {
+	if role, ok := r.Context().Value("role").(string); ok && role != "" {
+		return models.Role(role)
+	}
+	return models.RolePlayer
+}
+
+// canActOnUser checks if the requester owns a user's resources or may
+// override ownership as a moderator
+func canActOnUser(r *http.Request, ownerID uuid.UUID) bool {
+	return getUserIDFromContext(r) == ownerID.String() || getRoleFromContext(r).Includes(models.RoleModerator)
+}
+
 func mustMarshal(v interface{}) json.RawMessage {
 	data, err := json.Marshal(v)
 	if err != nil {
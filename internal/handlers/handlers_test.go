@@ -1,13 +1,41 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+
+	"github.com/primoPoker/server/internal/auth"
+	"github.com/primoPoker/server/internal/cache"
+	"github.com/primoPoker/server/internal/config"
+	"github.com/primoPoker/server/internal/database"
+	"github.com/primoPoker/server/internal/flags"
+	"github.com/primoPoker/server/internal/game"
+	"github.com/primoPoker/server/internal/gcp"
+	"github.com/primoPoker/server/internal/logging"
+	"github.com/primoPoker/server/internal/metrics"
+	"github.com/primoPoker/server/internal/middleware"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/password"
+	"github.com/primoPoker/server/internal/repository"
+	"github.com/primoPoker/server/internal/repository/repositorytest"
+	"github.com/primoPoker/server/internal/testutil"
+	"github.com/primoPoker/server/internal/websocket"
+	"github.com/primoPoker/server/pkg/poker"
 )
 
 func TestAPIDocumentation(t *testing.T) {
@@ -121,4 +149,608 @@ func TestHealthCheck(t *testing.T) {
 	// Verify essential fields are present
 	assert.Equal(t, "healthy", data["status"])
 	assert.Contains(t, data, "timestamp")
-}
\ No newline at end of file
+}
+
+func TestWriteTournamentErrorCodes(t *testing.T) {
+	handler := &Handler{}
+
+	cases := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{gorm.ErrRecordNotFound, http.StatusNotFound, "tournament_not_found"},
+		{repository.ErrRegistrationClosed, http.StatusConflict, "registration_closed"},
+		{repository.ErrTournamentFull, http.StatusConflict, "tournament_full"},
+		{repository.ErrAlreadyRegistered, http.StatusConflict, "already_registered"},
+		{repository.ErrNotRegistered, http.StatusNotFound, "not_registered"},
+		{repository.ErrInsufficientBalance, http.StatusPaymentRequired, "insufficient_balance"},
+	}
+
+	for _, tc := range cases {
+		rr := httptest.NewRecorder()
+		handler.writeTournamentError(rr, httptest.NewRequest(http.MethodPost, "/", nil), tc.err, "fallback")
+
+		assert.Equal(t, tc.status, rr.Code)
+
+		var response Response
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.False(t, response.Success)
+		assert.Equal(t, tc.code, response.Code)
+	}
+}
+
+func TestErrorResponsesCarryRequestID(t *testing.T) {
+	handler := &Handler{}
+	routed := middleware.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.writeErrorCode(w, http.StatusConflict, "table_full", "Table is full")
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/games/1/join", nil)
+	req.Header.Set(logging.Header, "join-42")
+	rr := httptest.NewRecorder()
+	routed.ServeHTTP(rr, req)
+
+	var response Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, "join-42", response.RequestID)
+	assert.Equal(t, "join-42", rr.Header().Get(logging.Header))
+
+	rr = httptest.NewRecorder()
+	handler.writeSuccess(rr, "ok")
+	assert.NotContains(t, rr.Body.String(), "request_id", "only errors carry the request ID")
+}
+
+func TestWriteReportErrorCodes(t *testing.T) {
+	handler := &Handler{}
+
+	rr := httptest.NewRecorder()
+	handler.writeReportError(rr, gorm.ErrRecordNotFound)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = httptest.NewRecorder()
+	handler.writeReportError(rr, repository.ErrReportResolved)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	var response Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, "report_resolved", response.Code)
+}
+
+func TestParseMetricsFilter(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/metrics?game_type=omaha&min_big_blind=100&max_big_blind=1000", nil)
+	filter, err := parseMetricsFilter(r)
+	require.NoError(t, err)
+	assert.Equal(t, metrics.Filter{GameType: models.GameTypeOmaha, MinBigBlind: 100, MaxBigBlind: 1000}, filter)
+
+	for _, query := range []string{"game_type=razz", "min_big_blind=lots", "min_big_blind=1000&max_big_blind=100"} {
+		_, err := parseMetricsFilter(httptest.NewRequest(http.MethodGet, "/api/v1/metrics?"+query, nil))
+		assert.Error(t, err, query)
+	}
+}
+
+func TestParseStartingHandFilter(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/metrics/me/starting-hands?position=BTN&from=2026-01-01T00:00:00Z&to=2026-02-01T00:00:00Z", nil)
+	filter, err := parseStartingHandFilter(r)
+	require.NoError(t, err)
+	assert.Equal(t, poker.PositionButton, filter.Position)
+	assert.Equal(t, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), filter.From)
+	assert.Equal(t, time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), filter.To)
+
+	for _, query := range []string{"position=dealer", "from=yesterday", "from=2026-02-01T00:00:00Z&to=2026-01-01T00:00:00Z"} {
+		_, err := parseStartingHandFilter(httptest.NewRequest(http.MethodGet, "/api/v1/metrics/me/starting-hands?"+query, nil))
+		assert.Error(t, err, query)
+	}
+}
+
+func TestWritePasswordError(t *testing.T) {
+	handler := &Handler{}
+
+	rr := httptest.NewRecorder()
+	assert.False(t, handler.writePasswordError(rr, "password", errors.New("username already exists")))
+	assert.False(t, handler.writePasswordError(rr, "password", nil))
+
+	err := password.Rules{MinLength: 8}.Check("alice", "alice", "alice@example.com")
+	require.True(t, handler.writePasswordError(rr, "password", err))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	var response struct {
+		Code string `json:"code"`
+		Data struct {
+			Fields map[string][]password.Violation `json:"fields"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, "invalid_password", response.Code)
+
+	var rules []string
+	for _, v := range response.Data.Fields["password"] {
+		rules = append(rules, v.Rule)
+	}
+	assert.ElementsMatch(t, []string{"min_length", "contains_username", "contains_email"}, rules)
+}
+
+func TestListLogins(t *testing.T) {
+	db := testutil.NewDB(t, &models.User{}, &models.Session{}, &models.RotatedRefreshToken{}, &models.LoginEvent{}, &models.APIKey{})
+	authService := auth.NewService("test-secret", config.SecurityConfig{}, repository.NewUserRepository(db),
+		repository.NewSessionRepository(db), repository.NewLoginEventRepository(db), repository.NewAPIKeyRepository(db))
+	handler := &Handler{authService: authService}
+
+	user, err := authService.CreateUser("alice", "correct-horse-42", "alice@example.com")
+	require.NoError(t, err)
+	for _, userAgent := range []string{"firefox", "chrome", "safari"} {
+		_, err := authService.AuthenticateUser("alice", "correct-horse-42", "192.0.2.1", userAgent)
+		require.NoError(t, err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/users/me/logins?limit=2", nil)
+	req = req.WithContext(context.WithValue(req.Context(), "user_id", user.ID.String()))
+	rr := httptest.NewRecorder()
+	handler.ListLogins(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var response struct {
+		Data struct {
+			Items      []models.LoginEvent `json:"items"`
+			NextCursor string              `json:"next_cursor"`
+			HasMore    bool                `json:"has_more"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	require.Len(t, response.Data.Items, 2)
+	assert.True(t, response.Data.HasMore)
+	assert.NotEmpty(t, response.Data.NextCursor)
+	assert.Equal(t, "safari", response.Data.Items[0].UserAgent)
+	assert.Equal(t, models.LoginSucceeded, response.Data.Items[0].Outcome)
+
+	// Without an authenticated user there is no history to show
+	rr = httptest.NewRecorder()
+	handler.ListLogins(rr, httptest.NewRequest("GET", "/api/v1/users/me/logins", nil))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestGetGameHUD(t *testing.T) {
+	db := testutil.NewDB(t, &models.User{}, &models.Game{}, &models.HandHistory{}, &models.PlayerStatAggregate{})
+	users := repository.NewUserRepository(db)
+	cfg := config.MetricsConfig{HUDEnabled: true, HUDMinHands: 1}
+	metricsService := metrics.NewServiceWithCache(repository.NewHandHistoryRepository(db), repository.NewPlayerStatRepository(db), users, cfg, nil)
+	handler := &Handler{gameManager: game.NewManager(), metricsService: metricsService}
+
+	alice := models.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com", PasswordHash: "x"}
+	bob := models.User{ID: uuid.New(), Username: "bob", Email: "bob@example.com", PasswordHash: "x"}
+	require.NoError(t, db.Create(&[]models.User{alice, bob}).Error)
+
+	table, err := handler.gameManager.CreateGame(uuid.New().String(), "HUD")
+	require.NoError(t, err)
+	require.NoError(t, handler.gameManager.JoinGame(context.Background(), table.ID, alice.ID.String(), "alice", 10000))
+	require.NoError(t, handler.gameManager.JoinGame(context.Background(), table.ID, bob.ID.String(), "bob", 10000))
+
+	getHUD := func(userID uuid.UUID) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/games/"+table.ID+"/hud", nil)
+		req = mux.SetURLVars(req, map[string]string{"gameId": table.ID})
+		req = req.WithContext(context.WithValue(req.Context(), "user_id", userID.String()))
+		rr := httptest.NewRecorder()
+		handler.GetGameHUD(rr, req)
+		return rr
+	}
+
+	rr := getHUD(alice.ID)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var response struct {
+		Data []map[string]interface{} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	require.Len(t, response.Data, 1)
+	assert.Equal(t, "bob", response.Data[0]["username"])
+	assert.Equal(t, float64(0), response.Data[0]["hands"])
+	assert.Equal(t, "-", response.Data[0]["vpip"])
+
+	// The game state carries the same stats for opponents only
+	state, err := handler.gameState(table.ID, alice.ID.String())
+	require.NoError(t, err)
+	for _, player := range state.Players {
+		if player.ID == alice.ID.String() {
+			assert.Nil(t, player.HUD)
+		} else {
+			assert.NotNil(t, player.HUD)
+		}
+	}
+
+	// Only players seated at the table may look
+	assert.Equal(t, http.StatusForbidden, getHUD(uuid.New()).Code)
+
+	handler.metricsService = metrics.NewServiceWithCache(nil, nil, users, config.MetricsConfig{}, nil)
+	rr = getHUD(alice.ID)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Contains(t, rr.Body.String(), "hud_disabled")
+
+	state, err = handler.gameState(table.ID, alice.ID.String())
+	require.NoError(t, err)
+	for _, player := range state.Players {
+		assert.Nil(t, player.HUD)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	db := testutil.NewDB(t, &models.User{}, &models.Session{}, &models.RotatedRefreshToken{}, &models.LoginEvent{}, &models.APIKey{})
+	authService := auth.NewService("test-secret", config.SecurityConfig{}, repository.NewUserRepository(db),
+		repository.NewSessionRepository(db), repository.NewLoginEventRepository(db), repository.NewAPIKeyRepository(db))
+	handler := &Handler{authService: authService}
+
+	user, err := authService.CreateUser("alice", "correct-horse-42", "alice@example.com")
+	require.NoError(t, err)
+
+	login := func(body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler.Login(rr, jsonRequest(http.MethodPost, "/api/v1/auth/login", body))
+		return rr
+	}
+
+	rr := login(`{"username": "alice", "password": "correct-horse-42"}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var response struct {
+		Data struct {
+			Token        string      `json:"token"`
+			RefreshToken string      `json:"refresh_token"`
+			User         models.User `json:"user"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.NotEmpty(t, response.Data.RefreshToken)
+	assert.Equal(t, user.ID, response.Data.User.ID)
+
+	tokenUser, err := authService.ValidateToken(response.Data.Token)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, tokenUser.ID)
+
+	assert.Equal(t, http.StatusUnauthorized, login(`{"username": "alice", "password": "wrong"}`).Code)
+	assert.Equal(t, http.StatusUnauthorized, login(`{"username": "nobody", "password": "correct-horse-42"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, login(`{"username":`).Code)
+}
+
+func TestFailedLoginCountsTheAddressThatConnected(t *testing.T) {
+	db := testutil.NewDB(t, &models.User{}, &models.Session{}, &models.RotatedRefreshToken{}, &models.LoginEvent{}, &models.APIKey{})
+	users := repository.NewUserRepository(db)
+	authService := auth.NewService("test-secret", config.SecurityConfig{}, users,
+		repository.NewSessionRepository(db), repository.NewLoginEventRepository(db), repository.NewAPIKeyRepository(db))
+	handler := &Handler{authService: authService}
+
+	user, err := authService.CreateUser("alice", "correct-horse-42", "alice@example.com")
+	require.NoError(t, err)
+	login := func(remoteAddr, forwardedFor, password string) int {
+		req := jsonRequest(http.MethodPost, "/api/v1/auth/login", `{"username": "alice", "password": "`+password+`"}`)
+		req.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		rr := httptest.NewRecorder()
+		handler.Login(rr, req)
+		return rr.Code
+	}
+	attempts := func() int {
+		stored, err := users.GetByID(user.ID)
+		require.NoError(t, err)
+		return stored.LoginAttempts
+	}
+
+	require.Equal(t, http.StatusOK, login("192.0.2.1:1234", "", "correct-horse-42"))
+
+	// A stranger claiming alice's address is still a stranger, whose
+	// failures count for less
+	assert.Equal(t, http.StatusUnauthorized, login("203.0.113.9:1234", "192.0.2.1", "wrong"))
+	assert.Equal(t, 1, attempts())
+	assert.Equal(t, http.StatusUnauthorized, login("192.0.2.1:1234", "", "wrong"))
+	assert.Equal(t, 3, attempts())
+}
+
+// brokeBank refuses every buy-in
+type brokeBank struct{}
+
+func (brokeBank) BuyIn(context.Context, string, game.SeatState) error {
+	return repository.ErrInsufficientBalance
+}
+func (brokeBank) CashOut(string, int64) {}
+
+func TestJoinGame(t *testing.T) {
+	handler := &Handler{gameManager: game.NewManager(), wsHub: websocket.NewHub()}
+	table, err := handler.gameManager.CreateGame(uuid.New().String(), "Join")
+	require.NoError(t, err)
+
+	join := func(gameID string, userID uuid.UUID, body string) *httptest.ResponseRecorder {
+		req := jsonRequest(http.MethodPost, "/api/v1/games/"+gameID+"/join", body)
+		req = mux.SetURLVars(req, map[string]string{"gameId": gameID})
+		if userID != uuid.Nil {
+			req = asUser(req, userID, "player-"+userID.String()[:8])
+		}
+		rr := httptest.NewRecorder()
+		handler.JoinGame(rr, req)
+		return rr
+	}
+
+	alice := uuid.New()
+	rr := join(table.ID, alice, `{"buy_in": 5000}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var response struct {
+		Data game.GameState `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	require.Len(t, response.Data.Players, 1)
+	assert.Equal(t, alice.String(), response.Data.Players[0].ID)
+	assert.Equal(t, int64(5000), response.Data.Players[0].ChipCount)
+
+	assert.Equal(t, http.StatusBadRequest, join(uuid.New().String(), uuid.New(), `{}`).Code, "no such game")
+	assert.Equal(t, http.StatusBadRequest, join(table.ID, uuid.New(), `{"buy_in":`).Code)
+	assert.Equal(t, http.StatusUnauthorized, join(table.ID, uuid.Nil, `{}`).Code)
+
+	handler.gameManager.SetBank(brokeBank{})
+	rr = join(table.ID, uuid.New(), `{}`)
+	assert.Equal(t, http.StatusPaymentRequired, rr.Code)
+	assert.Contains(t, rr.Body.String(), "insufficient_balance")
+}
+
+func TestListMyTables(t *testing.T) {
+	handler := &Handler{gameManager: game.NewManager(), wsHub: websocket.NewHub()}
+	alice := uuid.New()
+	var tables []string
+	for _, name := range []string{"First", "Second"} {
+		table, err := handler.gameManager.CreateGame(uuid.New().String(), name)
+		require.NoError(t, err)
+		require.NoError(t, handler.gameManager.JoinGame(context.Background(), table.ID, alice.String(), "alice", 5000))
+		tables = append(tables, table.ID)
+	}
+	_, err := handler.gameManager.CreateGame(uuid.New().String(), "Not seated")
+	require.NoError(t, err)
+	require.NoError(t, handler.gameManager.LeaveGame(context.Background(), tables[0], alice.String()))
+
+	rr := httptest.NewRecorder()
+	handler.ListMyTables(rr, asUser(httptest.NewRequest(http.MethodGet, "/api/v1/users/me/tables", nil), alice, "alice"))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var response struct {
+		Data []game.PlayerTable `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	require.Len(t, response.Data, 1, "a table left is no longer listed")
+	assert.Equal(t, tables[1], response.Data[0].ID)
+	assert.Equal(t, "Second", response.Data[0].Name)
+	assert.Equal(t, int64(5000), response.Data[0].ChipCount)
+	assert.Equal(t, 0, response.Data[0].SeatPosition)
+
+	rr = httptest.NewRecorder()
+	handler.ListMyTables(rr, asUser(httptest.NewRequest(http.MethodGet, "/api/v1/users/me/tables", nil), uuid.New(), "bob"))
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"success":true,"data":[]}`, rr.Body.String())
+}
+
+func TestAdminMovePlayer(t *testing.T) {
+	hub := websocket.NewHub()
+	go hub.Run()
+	handler := &Handler{gameManager: game.NewManager(), wsHub: hub}
+	router := mux.NewRouter()
+	router.HandleFunc("/admin/games/{gameId}/move/{userId}", handler.AdminMovePlayer).Methods("POST")
+	move := func(gameID, userID, toGameID string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, jsonRequest(http.MethodPost, "/admin/games/"+gameID+"/move/"+userID,
+			`{"to_game_id": "`+toGameID+`", "reason": "collusion review"}`))
+		return rr
+	}
+
+	ctx := context.Background()
+	from, err := handler.gameManager.CreateGame(uuid.New().String(), "From")
+	require.NoError(t, err)
+	to, err := handler.gameManager.CreateGame(uuid.New().String(), "To", func(config *game.GameConfig) {
+		config.MaxPlayersPerTable = 2
+		config.MinPlayersPerTable = 3
+	})
+	require.NoError(t, err)
+	alice, bob, carol := uuid.NewString(), uuid.NewString(), uuid.NewString()
+	require.NoError(t, handler.gameManager.JoinGame(ctx, from.ID, alice, "alice", 5000))
+	require.NoError(t, handler.gameManager.JoinGame(ctx, from.ID, bob, "bob", 5000))
+	require.NoError(t, handler.gameManager.JoinGame(ctx, to.ID, carol, "carol", 5000))
+
+	rr := move(from.ID, alice, to.ID)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Equal(t, "hand_in_progress", errorCode(t, rr))
+
+	require.NoError(t, handler.gameManager.ProcessAction(ctx, from.ID, from.GetGameState("").CurrentPlayer, game.Fold, 0))
+	rr = move(from.ID, alice, to.ID)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Contains(t, rr.Body.String(), `"seat_position":1`)
+	assert.Equal(t, []string{to.ID}, handler.gameManager.GetPlayerGames(alice))
+
+	rr = move(from.ID, bob, to.ID)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Equal(t, "table_full", errorCode(t, rr))
+	assert.Equal(t, http.StatusNotFound, move(from.ID, carol, uuid.NewString()).Code)
+	assert.Equal(t, "already_seated", errorCode(t, move(to.ID, alice, to.ID)))
+}
+
+func TestGetPlayerMetrics(t *testing.T) {
+	hero := models.User{ID: uuid.New(), Username: "hero"}
+	start := time.Date(2026, 5, 1, 20, 0, 0, 0, time.UTC)
+
+	hands := repositorytest.NewHandHistories()
+	for i, won := range []bool{true, false, true} {
+		at := start.Add(time.Duration(i) * time.Minute)
+		hand := models.HandHistory{
+			ID: uuid.New(), UserID: hero.ID, HandNumber: i + 1,
+			SmallBlind: 50, BigBlind: 100, StartingChips: 10000, EndingChips: 9900, NetResult: -100,
+			StartedAt: at, FinishedAt: at.Add(time.Minute),
+		}
+		if won {
+			hand.IsWinner, hand.AmountWon, hand.EndingChips, hand.NetResult = true, 300, 10200, 200
+		}
+		hands.Add(hand)
+	}
+	users := repositorytest.NewUsers(hands, hero)
+	handler := &Handler{metricsService: metrics.NewServiceWithCache(hands, nil, users, config.MetricsConfig{}, nil)}
+
+	getMetrics := func(userID uuid.UUID) *httptest.ResponseRecorder {
+		req := asUser(httptest.NewRequest(http.MethodGet, "/api/v1/metrics", nil), userID, "hero")
+		rr := httptest.NewRecorder()
+		handler.GetPlayerMetrics(rr, req)
+		return rr
+	}
+
+	rr := getMetrics(hero.ID)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var response struct {
+		Data metrics.PlayerMetrics `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, "hero", response.Data.Username)
+	assert.Equal(t, 3, response.Data.HandsPlayed)
+	assert.Equal(t, 2, response.Data.HandsWon)
+	assert.Equal(t, int64(300), response.Data.NetResult)
+
+	// Someone else's metrics are only for moderators
+	other := uuid.New()
+	req := asUser(httptest.NewRequest(http.MethodGet, "/api/v1/users/"+hero.ID.String()+"/metrics", nil), other, "other")
+	req = mux.SetURLVars(req, map[string]string{"userId": hero.ID.String()})
+	rr = httptest.NewRecorder()
+	handler.GetUserMetrics(rr, req)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	assert.Equal(t, http.StatusInternalServerError, getMetrics(other).Code, "unknown user")
+
+	hands.Err = errors.New("connection refused")
+	handler.metricsService = metrics.NewServiceWithCache(hands, nil, users, config.MetricsConfig{}, nil)
+	assert.Equal(t, http.StatusInternalServerError, getMetrics(hero.ID).Code)
+}
+
+func TestHealthCheckReportsPools(t *testing.T) {
+	handler := &Handler{}
+	handler.SetDatabase(database.NewReplicatedDB(testutil.NewDB(t), nil))
+
+	rr := httptest.NewRecorder()
+	handler.HealthCheck(rr, httptest.NewRequest(http.MethodGet, "/health", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var response struct {
+		Data struct {
+			Pools map[string]database.PoolStats `json:"database_pools"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	require.Contains(t, response.Data.Pools, "primary")
+	assert.Equal(t, 1, response.Data.Pools["primary"].MaxOpen)
+}
+
+func TestReadiness(t *testing.T) {
+	gormDB := testutil.NewDB(t)
+	handler := &Handler{}
+	handler.SetDatabase(database.NewReplicatedDB(gormDB, nil))
+
+	ready := func() (int, map[string]interface{}) {
+		rr := httptest.NewRecorder()
+		handler.Readiness(rr, httptest.NewRequest(http.MethodGet, "/ready", nil))
+		var response struct {
+			Data map[string]interface{} `json:"data"`
+			Code string                 `json:"code"`
+		}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		return rr.Code, response.Data
+	}
+
+	code, data := ready()
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, []interface{}{"ready", "degraded"}, data["status"])
+	db, ok := data["database"].(map[string]interface{})
+	require.True(t, ok)
+	assert.Contains(t, db, "latency_ms")
+	assert.Contains(t, db, "pools")
+
+	sqlDB, err := gormDB.DB()
+	require.NoError(t, err)
+	require.NoError(t, sqlDB.Close())
+	code, data = ready()
+	assert.Equal(t, http.StatusServiceUnavailable, code, "only an unreachable database fails the probe")
+	assert.Equal(t, "unavailable", data["status"])
+}
+
+func TestReadinessChecksSharedCache(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+	defer client.Close()
+	handler := &Handler{}
+	handler.SetCache(cache.NewRedis(client))
+
+	ready := func() (int, map[string]interface{}) {
+		rr := httptest.NewRecorder()
+		handler.Readiness(rr, httptest.NewRequest(http.MethodGet, "/ready", nil))
+		var response struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		return rr.Code, response.Data
+	}
+
+	code, data := ready()
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ready", data["status"])
+	assert.Equal(t, map[string]interface{}{"status": "up"}, data["cache"])
+
+	server.Close()
+	code, data = ready()
+	assert.Equal(t, http.StatusOK, code, "the server runs on without the shared cache")
+	assert.Equal(t, "degraded", data["status"])
+	assert.Equal(t, "down", data["cache"].(map[string]interface{})["status"])
+}
+
+// secretSource serves one secret, or fails while err is set
+type secretSource struct{ err error }
+
+func (s *secretSource) GetSecret(ctx context.Context, name string) (string, error) {
+	return "secret", s.err
+}
+
+func TestReadinessReportsSecretFailures(t *testing.T) {
+	source := &secretSource{}
+	secrets := gcp.NewSecrets(source, time.Minute, "primopoker-jwt-secret")
+	require.NoError(t, secrets.Refresh(context.Background()))
+	handler := &Handler{}
+	handler.SetSecrets(secrets)
+
+	rr := httptest.NewRecorder()
+	handler.Readiness(rr, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	assert.Contains(t, rr.Body.String(), `"status":"ready"`)
+
+	source.err = status.Error(codes.PermissionDenied, "permission denied")
+	secrets.Refresh(context.Background())
+	rr = httptest.NewRecorder()
+	handler.Readiness(rr, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	assert.Equal(t, http.StatusOK, rr.Code, "secrets keep their last values, so the server still serves")
+	assert.Contains(t, rr.Body.String(), `"status":"degraded"`)
+	assert.Contains(t, rr.Body.String(), "permission denied")
+}
+
+func TestAdminFlags(t *testing.T) {
+	handler := &Handler{}
+	handler.SetFlags(flags.New(flags.Definitions...))
+	router := mux.NewRouter()
+	router.HandleFunc("/admin/flags", handler.AdminListFlags).Methods("GET")
+	router.HandleFunc("/admin/flags/{flag}", handler.AdminSetFlag).Methods("PUT")
+	router.HandleFunc("/admin/flags/{flag}", handler.AdminClearFlag).Methods("DELETE")
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, jsonRequest(http.MethodPut, "/admin/flags/"+flags.RunItTwice,
+		`{"percentage": 10, "users": ["alice"], "reason": "beta"}`))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.True(t, handler.features.EnabledFor(flags.RunItTwice, "alice"))
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/flags", nil))
+	assert.Contains(t, rr.Body.String(), `"name":"run_it_twice"`)
+	assert.Contains(t, rr.Body.String(), `"source":"admin"`)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, jsonRequest(http.MethodPut, "/admin/flags/no_such_flag", `{"enabled": true}`))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, jsonRequest(http.MethodPut, "/admin/flags/"+flags.RunItTwice, `{"percentage": 200}`))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/admin/flags/"+flags.RunItTwice, nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.False(t, handler.features.EnabledFor(flags.RunItTwice, "alice"))
+}
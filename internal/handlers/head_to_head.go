@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/primoPoker/server/internal/logging"
+	"github.com/primoPoker/server/internal/metrics"
+)
+
+// GetHeadToHead returns how the authenticated user has done against the
+// player named in the path
+func (h *Handler) GetHeadToHead(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.requestUserID(w, r)
+	if !ok {
+		return
+	}
+
+	h2h, err := h.metricsService.GetHeadToHead(userID, mux.Vars(r)["username"])
+	if err != nil {
+		switch {
+		case errors.Is(err, metrics.ErrOpponentNotFound):
+			h.writeError(w, http.StatusNotFound, "User not found")
+		case errors.Is(err, metrics.ErrOwnHeadToHead):
+			h.writeError(w, http.StatusBadRequest, "Cannot compare against yourself")
+		case errors.Is(err, metrics.ErrTooFewSharedHands):
+			h.writeError(w, http.StatusNotFound, "Not enough hands played together")
+		default:
+			logging.FromContext(r.Context()).WithError(err).Error("Failed to get head-to-head statistics")
+			h.writeError(w, http.StatusInternalServerError, "Failed to get head-to-head statistics")
+		}
+		return
+	}
+
+	h.writeSuccess(w, h2h)
+}
@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/primoPoker/server/internal/metrics"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/pagination"
+	"github.com/primoPoker/server/internal/repository"
+)
+
+// GetHandHistory lists the authenticated user's hands, newest first
+func (h *Handler) GetHandHistory(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.requestUserID(w, r)
+	if !ok {
+		return
+	}
+
+	page, ok := h.parsePageRequest(w, r)
+	if !ok {
+		return
+	}
+
+	hands, err := h.handHistoryRepo.GetUserHandHistory(userID, page)
+	if err != nil {
+		h.writePageError(w, err, "Failed to get hand history")
+		return
+	}
+
+	h.writeSuccess(w, hands)
+}
+
+// SearchHands lists the authenticated user's hands matching a search, newest
+// or most relevant first
+func (h *Handler) SearchHands(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.requestUserID(w, r)
+	if !ok {
+		return
+	}
+
+	search, err := parseHandSearch(r)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, ok := h.parsePageRequest(w, r)
+	if !ok {
+		return
+	}
+
+	hands, err := h.handHistoryRepo.SearchHands(userID, search, page)
+	if err != nil {
+		h.writePageError(w, err, "Failed to search hands")
+		return
+	}
+
+	h.writeSuccess(w, hands)
+}
+
+// parseHandSearch reads a hand search from the query string
+func parseHandSearch(r *http.Request) (repository.HandSearch, error) {
+	query := r.URL.Query()
+	search := repository.HandSearch{
+		Text:     query.Get("q"),
+		Opponent: query.Get("opponent"),
+		Order:    repository.HandSearchOrder(query.Get("sort")),
+	}
+
+	if holeCards := query.Get("hole_cards"); holeCards != "" {
+		cards, err := repository.ParseHoleCards(holeCards)
+		if err != nil {
+			return repository.HandSearch{}, err
+		}
+		search.HoleCards = &cards
+	}
+
+	if board := query.Get("board"); board != "" {
+		for _, texture := range strings.Split(board, ",") {
+			search.Board = append(search.Board, repository.BoardTexture(strings.TrimSpace(texture)))
+		}
+	}
+
+	bounds := []struct {
+		param string
+		value **int64
+	}{
+		{"min_pot", &search.MinPot},
+		{"max_pot", &search.MaxPot},
+		{"min_result", &search.MinResult},
+		{"max_result", &search.MaxResult},
+	}
+	for _, bound := range bounds {
+		value := query.Get(bound.param)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return repository.HandSearch{}, errors.New("invalid " + bound.param)
+		}
+		*bound.value = &parsed
+	}
+
+	if err := search.Validate(); err != nil {
+		return repository.HandSearch{}, err
+	}
+	return search, nil
+}
+
+// GetGameHistory lists finished games, most recent first; mine=true limits it to the user's own games
+func (h *Handler) GetGameHistory(w http.ResponseWriter, r *http.Request) {
+	page, ok := h.parsePageRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var userFilter *uuid.UUID
+	if r.URL.Query().Get("mine") == "true" {
+		userID, ok := h.requestUserID(w, r)
+		if !ok {
+			return
+		}
+		userFilter = &userID
+	}
+
+	games, err := h.gameRepo.GetGameHistory(page, userFilter)
+	if err != nil {
+		h.writePageError(w, err, "Failed to get game history")
+		return
+	}
+
+	h.writeSuccess(w, games)
+}
+
+// GetLeaderboard lists players by total winnings
+func (h *Handler) GetLeaderboard(w http.ResponseWriter, r *http.Request) {
+	page, ok := h.parsePageRequest(w, r)
+	if !ok {
+		return
+	}
+
+	players, err := h.userRepo.GetTopPlayers(page)
+	if err != nil {
+		h.writePageError(w, err, "Failed to get leaderboard")
+		return
+	}
+
+	h.writeSuccess(w, players)
+}
+
+// GetStatLeaderboard lists players on one of the stat leaderboards, with
+// the authenticated user's own place. Without a period it serves the
+// latest materialized board; with one, the board is ranked live over it.
+func (h *Handler) GetStatLeaderboard(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.requestUserID(w, r)
+	if !ok {
+		return
+	}
+
+	page, ok := h.parsePageRequest(w, r)
+	if !ok {
+		return
+	}
+
+	metric := models.LeaderboardMetric(mux.Vars(r)["metric"])
+	var board interface{}
+	var err error
+	if period := r.URL.Query().Get("period"); period != "" {
+		board, err = h.leaderboards.GetRanked(metric, models.LeaderboardPeriod(period), userID, page)
+	} else {
+		board, err = h.leaderboards.Get(metric, userID, page)
+	}
+	if h.writeLeaderboardError(w, err) {
+		return
+	}
+
+	h.writeSuccess(w, board)
+}
+
+// GetUserRanks gets a player's place on each stat leaderboard ranked live
+// over a period, all time by default, leaving out boards they are not on
+func (h *Handler) GetUserRanks(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.requestUserID(w, r); !ok {
+		return
+	}
+
+	userID, err := uuid.Parse(mux.Vars(r)["userId"])
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	period := models.LeaderboardPeriod(r.URL.Query().Get("period"))
+	if period == "" {
+		period = models.LeaderboardPeriodAllTime
+	}
+
+	ranks, err := h.leaderboards.Ranks(userID, period)
+	if h.writeLeaderboardError(w, err) {
+		return
+	}
+
+	h.writeSuccess(w, ranks)
+}
+
+// writeLeaderboardError writes the response for a leaderboard error and
+// reports whether there was one
+func (h *Handler) writeLeaderboardError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, metrics.ErrUnknownLeaderboard):
+		h.writeError(w, http.StatusNotFound, "Unknown leaderboard")
+	case errors.Is(err, repository.ErrUnknownLeaderboardPeriod):
+		h.writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		h.writePageError(w, err, "Failed to get leaderboard")
+	}
+	return true
+}
+
+// parsePageRequest reads pagination parameters, flagging deprecated offset paging to the client
+func (h *Handler) parsePageRequest(w http.ResponseWriter, r *http.Request) (pagination.PageRequest, bool) {
+	page, err := pagination.ParseRequest(r)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return page, false
+	}
+
+	if page.UsesOffset() {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Warning", `299 - "offset pagination is deprecated, use cursor"`)
+	}
+
+	return page, true
+}
+
+// writePageError reports a bad cursor as a client error and anything else as a server error
+func (h *Handler) writePageError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, pagination.ErrInvalidCursor) {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.writeError(w, http.StatusInternalServerError, message)
+}
+
+// requestUserID parses the authenticated user's ID from the context
+func (h *Handler) requestUserID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	userID := getUserIDFromContext(r)
+	if userID == "" {
+		h.writeError(w, http.StatusUnauthorized, "User not authenticated")
+		return uuid.Nil, false
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid user ID")
+		return uuid.Nil, false
+	}
+
+	return userUUID, true
+}
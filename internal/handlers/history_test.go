@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/internal/config"
+	"github.com/primoPoker/server/internal/metrics"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/repository"
+	"github.com/primoPoker/server/internal/repository/repositorytest"
+	"github.com/primoPoker/server/internal/testutil"
+)
+
+// pageResponse is a paginated list endpoint's response
+type pageResponse[T any] struct {
+	Success bool `json:"success"`
+	Data    struct {
+		Items      []T    `json:"items"`
+		NextCursor string `json:"next_cursor"`
+		HasMore    bool   `json:"has_more"`
+	} `json:"data"`
+}
+
+// asUser makes req come from an authenticated user
+func asUser(req *http.Request, userID uuid.UUID, username string) *http.Request {
+	ctx := context.WithValue(req.Context(), "user_id", userID.String())
+	ctx = context.WithValue(ctx, "username", username)
+	return req.WithContext(ctx)
+}
+
+func TestGetHandHistory(t *testing.T) {
+	hero, villain := uuid.New(), uuid.New()
+	start := time.Date(2026, 5, 1, 20, 0, 0, 0, time.UTC)
+
+	hands := repositorytest.NewHandHistories()
+	for i := 0; i < 3; i++ {
+		at := start.Add(time.Duration(i) * time.Minute)
+		hands.Add(
+			models.HandHistory{ID: uuid.New(), UserID: hero, HandNumber: i + 1, StartedAt: at, FinishedAt: at.Add(time.Minute)},
+			models.HandHistory{ID: uuid.New(), UserID: villain, HandNumber: i + 1, StartedAt: at, FinishedAt: at.Add(time.Minute)},
+		)
+	}
+	handler := &Handler{handHistoryRepo: hands}
+
+	getHands := func(query url.Values) *httptest.ResponseRecorder {
+		req := asUser(httptest.NewRequest(http.MethodGet, "/api/v1/hands?"+query.Encode(), nil), hero, "hero")
+		rr := httptest.NewRecorder()
+		handler.GetHandHistory(rr, req)
+		return rr
+	}
+
+	rr := getHands(url.Values{"limit": {"2"}})
+	require.Equal(t, http.StatusOK, rr.Code)
+	var first pageResponse[models.HandHistory]
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &first))
+	require.Len(t, first.Data.Items, 2)
+	assert.True(t, first.Data.HasMore)
+	assert.Equal(t, 3, first.Data.Items[0].HandNumber, "newest first")
+	assert.Equal(t, 2, first.Data.Items[1].HandNumber)
+	for _, hand := range first.Data.Items {
+		assert.Equal(t, hero, hand.UserID, "only the user's own hands")
+	}
+
+	rr = getHands(url.Values{"limit": {"2"}, "cursor": {first.Data.NextCursor}})
+	require.Equal(t, http.StatusOK, rr.Code)
+	var second pageResponse[models.HandHistory]
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &second))
+	require.Len(t, second.Data.Items, 1)
+	assert.False(t, second.Data.HasMore)
+	assert.Equal(t, 1, second.Data.Items[0].HandNumber)
+
+	assert.Equal(t, http.StatusBadRequest, getHands(url.Values{"cursor": {"not-a-cursor"}}).Code)
+
+	hands.Err = errors.New("connection refused")
+	assert.Equal(t, http.StatusInternalServerError, getHands(nil).Code)
+
+	rr = httptest.NewRecorder()
+	handler.GetHandHistory(rr, httptest.NewRequest(http.MethodGet, "/api/v1/hands", nil))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestSearchHands(t *testing.T) {
+	hero := uuid.New()
+	at := time.Date(2026, 5, 1, 20, 0, 0, 0, time.UTC)
+	hands := repositorytest.NewHandHistories(models.HandHistory{ID: uuid.New(), UserID: hero, HandNumber: 1, StartedAt: at, FinishedAt: at})
+	handler := &Handler{handHistoryRepo: hands}
+
+	search := func(query string) *httptest.ResponseRecorder {
+		req := asUser(httptest.NewRequest(http.MethodGet, "/api/v1/hands/search?"+query, nil), hero, "hero")
+		rr := httptest.NewRecorder()
+		handler.SearchHands(rr, req)
+		return rr
+	}
+
+	rr := search("q=pocket+aces&hole_cards=AKs&board=paired,+flush_possible&min_pot=100&max_result=-1&opponent=villain&sort=relevance")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var found pageResponse[repository.HandSearchResult]
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &found))
+	require.Len(t, found.Data.Items, 1)
+
+	suited, pot, result := true, int64(100), int64(-1)
+	assert.Equal(t, &repository.HandSearch{
+		Text:      "pocket aces",
+		HoleCards: &repository.HoleCards{High: "A", Low: "K", Suited: &suited},
+		Board:     []repository.BoardTexture{repository.BoardPaired, repository.BoardFlushPossible},
+		MinPot:    &pot,
+		MaxResult: &result,
+		Opponent:  "villain",
+		Order:     repository.HandSearchByRelevance,
+	}, hands.LastSearch)
+
+	for _, query := range []string{"hole_cards=AKx", "board=rainbow", "min_pot=lots", "min_result=5&max_result=-5", "sort=relevance", "sort=popularity", "cursor=not-a-cursor"} {
+		assert.Equal(t, http.StatusBadRequest, search(query).Code, query)
+	}
+
+	hands.Err = errors.New("connection refused")
+	assert.Equal(t, http.StatusInternalServerError, search("").Code)
+
+	rr = httptest.NewRecorder()
+	handler.SearchHands(rr, httptest.NewRequest(http.MethodGet, "/api/v1/hands/search", nil))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestGetGameHistory(t *testing.T) {
+	hero := uuid.New()
+	finished := time.Date(2026, 5, 1, 22, 0, 0, 0, time.UTC)
+	earlier := finished.Add(-time.Hour)
+
+	games := repositorytest.NewGames()
+	games.AddFinished(models.Game{ID: uuid.New(), Name: "Played", Status: models.GameStatusFinished, FinishedAt: &earlier}, hero)
+	games.AddFinished(models.Game{ID: uuid.New(), Name: "Watched", Status: models.GameStatusFinished, FinishedAt: &finished}, uuid.New())
+	handler := &Handler{gameRepo: games}
+
+	getGames := func(target string) pageResponse[models.Game] {
+		t.Helper()
+
+		rr := httptest.NewRecorder()
+		handler.GetGameHistory(rr, asUser(httptest.NewRequest(http.MethodGet, target, nil), hero, "hero"))
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		var response pageResponse[models.Game]
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		return response
+	}
+
+	all := getGames("/api/v1/games/history")
+	require.Len(t, all.Data.Items, 2)
+	assert.Equal(t, "Watched", all.Data.Items[0].Name, "most recently finished first")
+
+	mine := getGames("/api/v1/games/history?mine=true")
+	require.Len(t, mine.Data.Items, 1)
+	assert.Equal(t, "Played", mine.Data.Items[0].Name)
+}
+
+func TestGetLeaderboard(t *testing.T) {
+	users := repositorytest.NewUsers(nil,
+		models.User{ID: uuid.New(), Username: "second", TotalWinnings: 500},
+		models.User{ID: uuid.New(), Username: "first", TotalWinnings: 900},
+		models.User{ID: uuid.New(), Username: "third", TotalWinnings: 100},
+	)
+	handler := &Handler{userRepo: users}
+
+	rr := httptest.NewRecorder()
+	handler.GetLeaderboard(rr, httptest.NewRequest(http.MethodGet, "/api/v1/leaderboard?limit=2", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var response pageResponse[models.User]
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	require.Len(t, response.Data.Items, 2)
+	assert.Equal(t, "first", response.Data.Items[0].Username)
+	assert.Equal(t, "second", response.Data.Items[1].Username)
+	assert.True(t, response.Data.HasMore)
+}
+
+func TestLiveLeaderboardRequests(t *testing.T) {
+	db := testutil.NewDB(t, &models.User{}, &models.HandHistory{}, &models.PlayerStatAggregate{}, &models.LeaderboardEntry{})
+	hero := models.User{ID: uuid.New(), Username: "hero", Email: "hero@example.com", PasswordHash: "x"}
+	require.NoError(t, db.Create(&hero).Error)
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	require.NoError(t, db.Create(&models.PlayerStatAggregate{
+		UserID: hero.ID, Day: today, GameType: models.GameTypeTexasHoldem, SmallBlind: 1, BigBlind: 2,
+		FirstHandAt: today, Hands: 20, BigBlindsWon: 10, BiggestPotWon: 40,
+	}).Error)
+	handler := &Handler{leaderboards: metrics.NewLeaderboards(repository.NewLeaderboardRepository(db), config.MetricsConfig{LeaderboardMinHands: 10})}
+
+	get := func(handle http.HandlerFunc, target string, vars map[string]string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(asUser(httptest.NewRequest(http.MethodGet, target, nil), hero.ID, "hero"), vars)
+		rr := httptest.NewRecorder()
+		handle(rr, req)
+		return rr
+	}
+
+	rr := get(handler.GetStatLeaderboard, "/api/v1/leaderboard/bb_per_100?period=day", map[string]string{"metric": "bb_per_100"})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var board struct {
+		Data metrics.RankedLeaderboard `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &board))
+	assert.Equal(t, models.LeaderboardPeriodDay, board.Data.Period)
+	require.Len(t, board.Data.Entries.Items, 1)
+	require.NotNil(t, board.Data.Me)
+	assert.Equal(t, 1, board.Data.Me.Rank)
+	assert.Equal(t, 50.0, board.Data.Me.Value)
+
+	rr = get(handler.GetUserRanks, "/api/v1/users/"+hero.ID.String()+"/ranks", map[string]string{"userId": hero.ID.String()})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var ranks struct {
+		Data []repository.LeaderboardRank `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &ranks))
+	require.Len(t, ranks.Data, 2, "no showdowns or sessions to rank")
+	assert.Equal(t, models.LeaderboardBBPer100, ranks.Data[0].Metric)
+	assert.Equal(t, models.LeaderboardBiggestPot, ranks.Data[1].Metric)
+	assert.Equal(t, 100.0, ranks.Data[1].TopPercent)
+
+	assert.Equal(t, http.StatusBadRequest, get(handler.GetStatLeaderboard, "/api/v1/leaderboard/bb_per_100?period=fortnight", map[string]string{"metric": "bb_per_100"}).Code)
+	assert.Equal(t, http.StatusNotFound, get(handler.GetStatLeaderboard, "/api/v1/leaderboard/most_chips?period=day", map[string]string{"metric": "most_chips"}).Code)
+	assert.Equal(t, http.StatusBadRequest, get(handler.GetUserRanks, "/api/v1/users/x/ranks?period=fortnight", map[string]string{"userId": hero.ID.String()}).Code)
+	assert.Equal(t, http.StatusBadRequest, get(handler.GetUserRanks, "/api/v1/users/x/ranks", map[string]string{"userId": "x"}).Code)
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+
+	"github.com/primoPoker/server/internal/game"
+	"github.com/primoPoker/server/internal/logging"
+	"github.com/primoPoker/server/internal/metrics"
+)
+
+// GetGameHUD returns HUD stats for each opponent at a table the
+// authenticated user is seated at, in seat order
+func (h *Handler) GetGameHUD(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.requestUserID(w, r)
+	if !ok {
+		return
+	}
+
+	if !h.metricsService.HUDEnabled() {
+		h.writeErrorCode(w, http.StatusForbidden, "hud_disabled", "HUD stats are not available on this server")
+		return
+	}
+
+	state, err := h.gameManager.GetGameState(mux.Vars(r)["gameId"], userID.String())
+	if err != nil {
+		h.writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if !isSeated(state, userID.String()) {
+		h.writeError(w, http.StatusForbidden, "You are not seated at this table")
+		return
+	}
+
+	stats, err := h.opponentHUD(state, userID.String())
+	if err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to get HUD stats")
+		h.writeError(w, http.StatusInternalServerError, "Failed to get HUD stats")
+		return
+	}
+
+	hud := make([]*metrics.HUDStats, 0, len(stats))
+	for _, player := range state.Players {
+		if stat, ok := stats[player.ID]; ok {
+			hud = append(hud, stat)
+		}
+	}
+	h.writeSuccess(w, hud)
+}
+
+// gameState returns a table's state as seen by a player, with their
+// opponents' HUD stats when those are enabled. HUD stats that fail to load
+// are left out rather than failing the update.
+func (h *Handler) gameState(gameID, userID string) (*game.GameState, error) {
+	state, err := h.gameManager.GetGameState(gameID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if h.metricsService == nil || !h.metricsService.HUDEnabled() || !isSeated(state, userID) {
+		return state, nil
+	}
+
+	stats, err := h.opponentHUD(state, userID)
+	if err != nil {
+		logrus.WithError(err).WithField("game_id", gameID).Warn("Failed to get HUD stats")
+		return state, nil
+	}
+	for i := range state.Players {
+		if stat, ok := stats[state.Players[i].ID]; ok {
+			state.Players[i].HUD = stat
+		}
+	}
+	return state, nil
+}
+
+// opponentHUD gets the HUD stats of everyone at the table but the viewer,
+// keyed by player ID and named as they are seated
+func (h *Handler) opponentHUD(state *game.GameState, viewerID string) (map[string]*metrics.HUDStats, error) {
+	var userIDs []uuid.UUID
+	for _, player := range state.Players {
+		if player.ID == viewerID {
+			continue
+		}
+		if userID, err := uuid.Parse(player.ID); err == nil {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+
+	stats, err := h.metricsService.GetHUDStats(userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	byPlayer := make(map[string]*metrics.HUDStats, len(stats))
+	for _, player := range state.Players {
+		userID, err := uuid.Parse(player.ID)
+		if err != nil {
+			continue
+		}
+		if stat, ok := stats[userID]; ok {
+			stat.Username = player.Username
+			byPlayer[player.ID] = stat
+		}
+	}
+	return byPlayer, nil
+}
+
+// isSeated reports whether the player has a seat in the game
+func isSeated(state *game.GameState, playerID string) bool {
+	for _, player := range state.Players {
+		if player.ID == playerID {
+			return true
+		}
+	}
+	return false
+}
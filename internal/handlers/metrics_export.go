@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/primoPoker/server/internal/logging"
+	"github.com/primoPoker/server/internal/metrics"
+)
+
+// ExportMetrics downloads the authenticated user's lifetime metrics as CSV
+// or JSON, a row for each stake, position or month played
+func (h *Handler) ExportMetrics(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.requestUserID(w, r)
+	if !ok {
+		return
+	}
+
+	format, err := metrics.ParseExportFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	groupBy, err := metrics.ParseGroupBy(r.URL.Query().Get("group_by"))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	groups, err := h.metricsService.GetGroupedMetrics(userID, groupBy)
+	if err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to get grouped metrics")
+		h.writeError(w, http.StatusInternalServerError, "Failed to export metrics")
+		return
+	}
+
+	filename := "metrics-by-" + string(groupBy) + "-" + time.Now().UTC().Format("20060102") + "." + format.Extension()
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.WriteHeader(http.StatusOK)
+
+	if err := metrics.WriteExport(w, format, groupBy, groups); err != nil {
+		// Headers are already sent, so the truncated body is all the client gets
+		logging.FromContext(r.Context()).WithError(err).WithField("user_id", userID).Error("Metrics export failed")
+	}
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/primoPoker/server/internal/auth"
+	"github.com/primoPoker/server/internal/logging"
+	"github.com/primoPoker/server/internal/middleware"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/oauth"
+)
+
+// OAuth state cookie settings. The state ties the provider's redirect back
+// to the browser that started the flow.
+const (
+	oauthStateCookie = "oauth_state"
+	oauthStatePath   = "/api/v1/auth/oauth/"
+	oauthStateMaxAge = 600
+)
+
+// OAuthStart redirects to the provider's consent page
+func (h *Handler) OAuthStart(w http.ResponseWriter, r *http.Request) {
+	provider, ok := h.oauthProvider(w, r)
+	if !ok {
+		return
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to start sign-in")
+		return
+	}
+	state := base64.RawURLEncoding.EncodeToString(buf)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    state,
+		Path:     oauthStatePath,
+		MaxAge:   oauthStateMaxAge,
+		HttpOnly: true,
+		Secure:   isSecureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, provider.AuthCodeURL(state), http.StatusFound)
+}
+
+// OAuthCallback completes the code flow and signs the user in
+func (h *Handler) OAuthCallback(w http.ResponseWriter, r *http.Request) {
+	provider, ok := h.oauthProvider(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	if query.Get("error") != "" {
+		h.writeErrorCode(w, http.StatusBadRequest, "oauth_denied", "Sign-in was cancelled")
+		return
+	}
+
+	cookie, err := r.Cookie(oauthStateCookie)
+	state := query.Get("state")
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		h.writeErrorCode(w, http.StatusBadRequest, "oauth_state_mismatch", "Sign-in expired or was started elsewhere, please try again")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Path:     oauthStatePath,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   isSecureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	identity, err := provider.Exchange(r.Context(), query.Get("code"))
+	if err != nil {
+		logging.FromContext(r.Context()).WithError(err).WithField("provider", provider.Name()).Warn("OAuth code exchange failed")
+		h.writeError(w, http.StatusUnauthorized, "Sign-in failed")
+		return
+	}
+
+	user, err := h.authService.OAuthLogin(identity, middleware.ClientIP(r), r.UserAgent())
+	switch {
+	case errors.Is(err, auth.ErrOAuthLinkRequired):
+		h.writeLinkRequired(w, identity)
+		return
+	case errors.Is(err, auth.ErrOAuthEmailUnverified):
+		h.writeErrorCode(w, http.StatusForbidden, "oauth_email_unverified", "Your email address is not verified with this provider")
+		return
+	case errors.Is(err, auth.ErrAccountDisabled), errors.Is(err, auth.ErrAccountLocked):
+		h.writeLoginError(w, err)
+		return
+	case err != nil:
+		logging.FromContext(r.Context()).WithError(err).WithField("provider", provider.Name()).Error("OAuth sign-in failed")
+		h.writeError(w, http.StatusInternalServerError, "Sign-in failed")
+		return
+	}
+
+	h.writeSignedIn(w, r, user)
+}
+
+// OAuthLink links a provider identity to the existing account with the same
+// email, after the account owner confirms with their password
+func (h *Handler) OAuthLink(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.oauthProvider(w, r); !ok {
+		return
+	}
+
+	var req struct {
+		LinkToken string `json:"link_token"`
+		Password  string `json:"password"`
+	}
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	user, err := h.authService.LinkOAuthIdentity(req.LinkToken, req.Password, middleware.ClientIP(r), r.UserAgent())
+	if errors.Is(err, auth.ErrInvalidLinkToken) {
+		h.writeErrorCode(w, http.StatusBadRequest, "invalid_link_token", "Link request is invalid or has expired, please sign in again")
+		return
+	}
+	if err != nil {
+		h.writeLoginError(w, err)
+		return
+	}
+
+	h.writeSignedIn(w, r, user)
+}
+
+// oauthProvider looks up the provider named in the route
+func (h *Handler) oauthProvider(w http.ResponseWriter, r *http.Request) (oauth.Provider, bool) {
+	provider, ok := h.oauthProviders[mux.Vars(r)["provider"]]
+	if !ok {
+		h.writeError(w, http.StatusNotFound, "Unknown sign-in provider")
+		return nil, false
+	}
+	return provider, true
+}
+
+// writeLinkRequired tells the client the identity's email belongs to an
+// existing account, with the token to confirm linking it
+func (h *Handler) writeLinkRequired(w http.ResponseWriter, identity *oauth.Identity) {
+	linkToken, err := h.authService.GenerateLinkToken(identity)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Sign-in failed")
+		return
+	}
+
+	h.writeJSON(w, http.StatusConflict, Response{
+		Success: false,
+		Error:   "An account with this email already exists, sign in with its password to link it",
+		Code:    "oauth_link_required",
+		Data: map[string]string{
+			"link_token": linkToken,
+			"email":      identity.Email,
+		},
+	})
+}
+
+// writeSignedIn opens a session for the user and responds like Login
+func (h *Handler) writeSignedIn(w http.ResponseWriter, r *http.Request, user *models.User) {
+	tokens, err := h.authService.StartSession(user, middleware.ClientIP(r), r.UserAgent())
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	h.writeSuccess(w, map[string]interface{}{
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"user":          user,
+	})
+}
+
+// isSecureRequest checks if the client reached us over HTTPS, directly or
+// through a TLS-terminating proxy
+func isSecureRequest(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/primoPoker/server/internal/game"
+)
+
+// tableObserver sends the players following a table its new state whenever
+// it changes, whatever changed it
+type tableObserver struct {
+	game.NopObserver
+	handler *Handler
+}
+
+// GameObserver returns the observer keeping connected players up to date
+// with their tables. It is registered with the game manager.
+func (h *Handler) GameObserver() game.GameObserver {
+	return tableObserver{handler: h}
+}
+
+func (o tableObserver) OnStateChanged(gameID string) {
+	o.handler.notifyGameUpdate(context.Background(), gameID, "")
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/primoPoker/server/internal/logging"
+	"github.com/primoPoker/server/internal/metrics"
+)
+
+// ListPlaySessions returns a page of the authenticated user's playing
+// sessions, newest first
+func (h *Handler) ListPlaySessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.requestUserID(w, r)
+	if !ok {
+		return
+	}
+
+	page, ok := h.parsePageRequest(w, r)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.metricsService.GetSessions(userID, page)
+	if err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to get playing sessions")
+		h.writePageError(w, err, "Failed to get playing sessions")
+		return
+	}
+
+	h.writeSuccess(w, sessions)
+}
+
+// GetPlaySession returns one of the authenticated user's playing sessions
+// with its hands
+func (h *Handler) GetPlaySession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.requestUserID(w, r)
+	if !ok {
+		return
+	}
+
+	sessionID, err := uuid.Parse(mux.Vars(r)["sessionId"])
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid session ID")
+		return
+	}
+
+	session, err := h.metricsService.GetSession(userID, sessionID)
+	if err != nil {
+		if errors.Is(err, metrics.ErrSessionNotFound) {
+			h.writeError(w, http.StatusNotFound, "Session not found")
+			return
+		}
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to get playing session")
+		h.writeError(w, http.StatusInternalServerError, "Failed to get playing session")
+		return
+	}
+
+	h.writeSuccess(w, session)
+}
@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/primoPoker/server/internal/game"
+	"github.com/primoPoker/server/internal/logging"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/repository"
+	"github.com/primoPoker/server/internal/websocket"
+)
+
+// Reporters may file at most maxReportsPerWindow reports per reportWindow
+const (
+	maxReportsPerWindow = 5
+	reportWindow        = time.Hour
+)
+
+// maxReportDescription is the longest free-text description accepted on a report
+const maxReportDescription = 2000
+
+// ReportPlayer files a report against another player at a table, capturing
+// the table's recent chat and actions so reviewers see what happened
+func (h *Handler) ReportPlayer(w http.ResponseWriter, r *http.Request) {
+	reporterID, ok := h.requestUserID(w, r)
+	if !ok {
+		return
+	}
+
+	gameID := mux.Vars(r)["gameId"]
+
+	var req struct {
+		PlayerID    string                `json:"player_id"`
+		Category    models.ReportCategory `json:"category"`
+		Description string                `json:"description"`
+		HandNumber  *int                  `json:"hand_number"`
+	}
+
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	if !req.Category.IsValid() {
+		h.writeError(w, http.StatusBadRequest, "Invalid report category")
+		return
+	}
+	if len(req.Description) > maxReportDescription {
+		h.writeError(w, http.StatusBadRequest, "Description is too long")
+		return
+	}
+
+	subjectID, err := uuid.Parse(req.PlayerID)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid player ID")
+		return
+	}
+	if subjectID == reporterID {
+		h.writeError(w, http.StatusBadRequest, "Cannot report yourself")
+		return
+	}
+
+	g, err := h.gameManager.GetGame(gameID)
+	if err != nil {
+		h.writeError(w, http.StatusNotFound, "Game not found")
+		return
+	}
+
+	if _, err := h.userRepo.GetByID(subjectID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			h.writeError(w, http.StatusNotFound, "Player not found")
+			return
+		}
+		h.writeError(w, http.StatusInternalServerError, "Failed to get player")
+		return
+	}
+
+	recent, err := h.reportRepo.CountByReporterSince(reporterID, time.Now().Add(-reportWindow))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to file report")
+		return
+	}
+	if recent >= maxReportsPerWindow {
+		h.writeErrorCode(w, http.StatusTooManyRequests, "report_rate_limited", "Too many reports, try again later")
+		return
+	}
+
+	report := &models.PlayerReport{
+		ReporterID:  reporterID,
+		SubjectID:   subjectID,
+		GameID:      gameID,
+		HandNumber:  req.HandNumber,
+		Category:    req.Category,
+		Description: req.Description,
+		Context:     h.reportContext(g),
+		Status:      models.ReportStatusOpen,
+	}
+
+	if err := h.reportRepo.Create(report); err != nil {
+		logging.FromContext(r.Context()).WithError(err).WithField("game_id", gameID).Error("Failed to create player report")
+		h.writeError(w, http.StatusInternalServerError, "Failed to file report")
+		return
+	}
+
+	logging.FromContext(r.Context()).WithFields(logrus.Fields{
+		"report_id": report.ID,
+		"game_id":   gameID,
+		"reporter":  reporterID,
+		"subject":   subjectID,
+		"category":  report.Category,
+	}).Info("Player reported")
+
+	h.writeSuccess(w, map[string]interface{}{
+		"message":   "Report submitted",
+		"report_id": report.ID,
+	})
+}
+
+// reportContext snapshots a table's recent chat and current hand's actions
+func (h *Handler) reportContext(g *game.Game) models.ReportContext {
+	handNumber, actions := g.RecentActions()
+	chat := h.wsHub.RecentChat(g.ID)
+
+	ctx := models.ReportContext{
+		HandNumber: handNumber,
+		Chat:       make([]models.ReportChatMessage, 0, len(chat)),
+		Actions:    make([]models.ReportAction, 0, len(actions)),
+	}
+	for _, entry := range chat {
+		ctx.Chat = append(ctx.Chat, models.ReportChatMessage{
+			UserID:  entry.UserID,
+			Message: entry.Message,
+			Time:    entry.Time,
+		})
+	}
+	for _, action := range actions {
+		ctx.Actions = append(ctx.Actions, models.ReportAction{
+			PlayerID: action.PlayerID,
+			Action:   action.Action.String(),
+			Amount:   action.Amount,
+			Time:     action.Time,
+		})
+	}
+
+	return ctx
+}
+
+// AdminListReports lists reports awaiting review, oldest first; status=resolved lists closed ones
+func (h *Handler) AdminListReports(w http.ResponseWriter, r *http.Request) {
+	status := models.ReportStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "":
+		status = models.ReportStatusOpen
+	case models.ReportStatusOpen, models.ReportStatusResolved:
+	default:
+		h.writeError(w, http.StatusBadRequest, "Invalid report status")
+		return
+	}
+
+	page, ok := h.parsePageRequest(w, r)
+	if !ok {
+		return
+	}
+
+	reports, err := h.reportRepo.ListByStatus(status, page)
+	if err != nil {
+		h.writePageError(w, err, "Failed to get reports")
+		return
+	}
+
+	h.writeSuccess(w, reports)
+}
+
+// AdminGetReport returns a report together with the table context captured when it was filed
+func (h *Handler) AdminGetReport(w http.ResponseWriter, r *http.Request) {
+	reportID, err := uuid.Parse(mux.Vars(r)["reportId"])
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid report ID")
+		return
+	}
+
+	report, err := h.reportRepo.GetByID(reportID)
+	if err != nil {
+		h.writeReportError(w, err)
+		return
+	}
+
+	h.writeSuccess(w, report)
+}
+
+// AdminResolveReport closes a report with an outcome. Warnings are sent to the
+// reported player; bans go through the user ban and also end their sessions
+// and seats so the ban takes effect immediately.
+func (h *Handler) AdminResolveReport(w http.ResponseWriter, r *http.Request) {
+	reportID, err := uuid.Parse(mux.Vars(r)["reportId"])
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid report ID")
+		return
+	}
+
+	var req struct {
+		Outcome models.ReportOutcome `json:"outcome"`
+		Note    string               `json:"note"`
+	}
+
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	if !req.Outcome.IsValid() {
+		h.writeError(w, http.StatusBadRequest, "Invalid report outcome")
+		return
+	}
+
+	// The entry is written with the resolution, and any ban, or not at all
+	audit := newAuditEntry(r, "resolve_report", models.AuditTargetReport, reportID.String(), req.Note,
+		map[string]models.ReportStatus{"status": models.ReportStatusOpen},
+		map[string]interface{}{"status": models.ReportStatusResolved, "outcome": req.Outcome})
+	report, err := h.reportRepo.Resolve(reportID, req.Outcome, req.Note, getUsernameFromContext(r), audit)
+	if err != nil {
+		h.writeReportError(w, err)
+		return
+	}
+	h.audited(r, audit)
+
+	subjectID := report.SubjectID.String()
+	switch report.Outcome {
+	case models.ReportOutcomeWarn:
+		h.wsHub.SendToUser(subjectID, websocket.Message{
+			Type:      websocket.MessageTypeAdminNotice,
+			GameID:    report.GameID,
+			PlayerID:  subjectID,
+			Data:      mustMarshal(AdminNotice{Action: "warn", Reason: req.Note}),
+			Timestamp: time.Now(),
+		})
+
+	case models.ReportOutcomeBan:
+		h.authService.InvalidateUser(report.SubjectID)
+		if _, err := h.authService.RevokeOtherSessions(report.SubjectID, uuid.Nil); err != nil {
+			logging.FromContext(r.Context()).WithError(err).WithField("user_id", subjectID).Error("Failed to revoke banned user's sessions")
+		}
+		h.removeFromAllGames(r.Context(), subjectID)
+	}
+
+	h.writeSuccess(w, report)
+}
+
+// writeReportError maps report repository errors onto status codes
+func (h *Handler) writeReportError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		h.writeError(w, http.StatusNotFound, "Report not found")
+	case errors.Is(err, repository.ErrReportResolved):
+		h.writeErrorCode(w, http.StatusConflict, "report_resolved", err.Error())
+	default:
+		h.writeError(w, http.StatusInternalServerError, "Failed to process report")
+	}
+}
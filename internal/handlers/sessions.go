@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/primoPoker/server/internal/auth"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/websocket"
+)
+
+// SessionInfo describes an active session to its owner
+type SessionInfo struct {
+	ID         uuid.UUID `json:"id"`
+	IPAddress  string    `json:"ip_address"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+// ListSessions lists the authenticated user's active sessions
+func (h *Handler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	userID, sessionID, ok := h.sessionRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.authService.ListSessions(userID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to list sessions")
+		return
+	}
+
+	infos := make([]SessionInfo, len(sessions))
+	for i, session := range sessions {
+		infos[i] = SessionInfo{
+			ID:         session.ID,
+			IPAddress:  session.IPAddress,
+			UserAgent:  session.UserAgent,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			ExpiresAt:  session.ExpiresAt,
+			Current:    session.ID == sessionID,
+		}
+	}
+
+	h.writeSuccess(w, infos)
+}
+
+// RevokeSession revokes one of the authenticated user's sessions
+func (h *Handler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, _, ok := h.sessionRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	targetID, err := uuid.Parse(mux.Vars(r)["sessionId"])
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid session ID")
+		return
+	}
+
+	if err := h.authService.RevokeSession(userID, targetID); err != nil {
+		if errors.Is(err, auth.ErrSessionNotFound) {
+			h.writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		h.writeError(w, http.StatusInternalServerError, "Failed to revoke session")
+		return
+	}
+
+	h.writeSuccess(w, map[string]string{
+		"message": "Session revoked",
+	})
+}
+
+// RevokeOtherSessions revokes every session of the authenticated user except the current one
+func (h *Handler) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	userID, sessionID, ok := h.sessionRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	revoked, err := h.authService.RevokeOtherSessions(userID, sessionID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to revoke sessions")
+		return
+	}
+
+	h.writeSuccess(w, map[string]interface{}{
+		"message": "Other sessions revoked",
+		"revoked": revoked,
+	})
+}
+
+// ChangePassword changes the authenticated user's password and signs out their other sessions
+func (h *Handler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, sessionID, ok := h.sessionRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+
+	err := h.authService.ChangePassword(userID, sessionID, req.CurrentPassword, req.NewPassword)
+	if h.writePasswordError(w, "new_password", err) {
+		return
+	}
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.writeSuccess(w, map[string]string{
+		"message": "Password changed",
+	})
+}
+
+// ListLogins lists the authenticated user's login history, most recent first
+func (h *Handler) ListLogins(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.requestUserID(w, r)
+	if !ok {
+		return
+	}
+
+	page, ok := h.parsePageRequest(w, r)
+	if !ok {
+		return
+	}
+
+	events, err := h.authService.ListLoginEvents(userID, page)
+	if err != nil {
+		h.writePageError(w, err, "Failed to get login history")
+		return
+	}
+
+	h.writeSuccess(w, events)
+}
+
+// NewDeviceAlert is the payload of a new_device_login WebSocket message
+type NewDeviceAlert struct {
+	Method    string    `json:"method"`
+	IPAddress string    `json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+	Time      time.Time `json:"time"`
+}
+
+// NotifyNewDevice alerts a user's open connections to a login from a device
+// they have not used before. It is registered with the auth service.
+func (h *Handler) NotifyNewDevice(user *models.User, event *models.LoginEvent) {
+	h.wsHub.SendToUser(user.ID.String(), websocket.Message{
+		Type:     websocket.MessageTypeNewDeviceLogin,
+		PlayerID: user.ID.String(),
+		Data: mustMarshal(NewDeviceAlert{
+			Method:    event.Method,
+			IPAddress: event.IPAddress,
+			UserAgent: event.UserAgent,
+			Time:      event.CreatedAt,
+		}),
+		Timestamp: time.Now(),
+	})
+}
+
+// sessionRequestIDs parses the user and session IDs from the context
+func (h *Handler) sessionRequestIDs(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	userID := getUserIDFromContext(r)
+	if userID == "" {
+		h.writeError(w, http.StatusUnauthorized, "User not authenticated")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid user ID")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	sessionUUID, err := uuid.Parse(getSessionIDFromContext(r))
+	if err != nil {
+		h.writeError(w, http.StatusUnauthorized, "User not authenticated")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return userUUID, sessionUUID, true
+}
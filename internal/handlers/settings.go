@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/primoPoker/server/internal/config"
+	"github.com/primoPoker/server/internal/models"
+)
+
+// SetSettings sets the live settings admins can reload
+func (h *Handler) SetSettings(settings *config.LiveSettings) {
+	h.settings = settings
+}
+
+// AdminReloadSettings re-reads the reloadable settings from the environment
+// and Secret Manager and puts them into effect, as a SIGHUP does
+func (h *Handler) AdminReloadSettings(w http.ResponseWriter, r *http.Request) {
+	if h.settings == nil {
+		h.writeError(w, http.StatusNotFound, "Settings cannot be reloaded")
+		return
+	}
+
+	var req adminRequest
+	if !h.decodeOptionalJSON(w, r, &req) {
+		return
+	}
+
+	changes, err := h.reloadSettings()
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.recordAudit(r, newAuditEntry(r, "reload_settings", models.AuditTargetSettings, "", req.Reason, nil, changes))
+
+	h.writeSuccess(w, map[string]interface{}{
+		"changes": changes,
+	})
+}
+
+// ReloadSettings reloads the settings when the server is sent a SIGHUP,
+// recording what changed in the audit log with no actor
+func (h *Handler) ReloadSettings() {
+	if h.settings == nil {
+		return
+	}
+	changes, err := h.reloadSettings()
+	if err != nil {
+		return
+	}
+
+	if h.auditLogs == nil {
+		return
+	}
+	entry := &models.AuditLog{
+		Action:     "reload_settings",
+		TargetType: models.AuditTargetSettings,
+		After:      auditSnapshot(changes),
+		Reason:     "SIGHUP",
+	}
+	if err := h.auditLogs.Create(entry); err != nil {
+		logrus.WithError(err).WithField("action", entry.Action).Error("Failed to write audit log entry")
+	}
+}
+
+// reloadSettings reloads the settings and logs what changed
+func (h *Handler) reloadSettings() ([]config.Change, error) {
+	changes, err := h.settings.Reload()
+	if err != nil {
+		logrus.WithError(err).Error("Failed to reload settings; keeping the current ones")
+		return nil, err
+	}
+
+	logrus.WithField("changes", changes).Info("Reloaded settings")
+	return changes, nil
+}
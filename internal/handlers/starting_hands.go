@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/primoPoker/server/internal/logging"
+	"github.com/primoPoker/server/internal/metrics"
+	"github.com/primoPoker/server/pkg/poker"
+)
+
+// GetStartingHands returns how each of the 169 starting hands has played
+// for the authenticated user, as the 13x13 grid row by row
+func (h *Handler) GetStartingHands(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.requestUserID(w, r)
+	if !ok {
+		return
+	}
+
+	filter, err := parseStartingHandFilter(r)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	hands, err := h.metricsService.GetStartingHands(userID, filter)
+	if err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to get starting hands")
+		h.writeError(w, http.StatusInternalServerError, "Failed to get starting hands")
+		return
+	}
+
+	h.writeSuccess(w, hands)
+}
+
+// parseStartingHandFilter reads the optional position, from and to query
+// parameters
+func parseStartingHandFilter(r *http.Request) (metrics.StartingHandFilter, error) {
+	query := r.URL.Query()
+	filter := metrics.StartingHandFilter{Position: poker.Position(query.Get("position"))}
+
+	bounds := []struct {
+		param string
+		value *time.Time
+	}{
+		{"from", &filter.From},
+		{"to", &filter.To},
+	}
+	for _, bound := range bounds {
+		value := query.Get(bound.param)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return metrics.StartingHandFilter{}, errors.New("Invalid " + bound.param + " format")
+		}
+		*bound.value = parsed
+	}
+
+	if err := filter.Validate(); err != nil {
+		return metrics.StartingHandFilter{}, err
+	}
+	return filter, nil
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/primoPoker/server/internal/logging"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/repository"
+)
+
+// GetUserStats totals a user's hands passing the filter in the query
+// string. Users can see their own stats; moderators can see anyone's.
+func (h *Handler) GetUserStats(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.requestUserID(w, r); !ok {
+		return
+	}
+
+	targetUUID, err := uuid.Parse(mux.Vars(r)["userId"])
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if !canActOnUser(r, targetUUID) {
+		h.writeError(w, http.StatusForbidden, "You can only view your own stats")
+		return
+	}
+
+	filter, err := parseStatsFilter(r)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	stats, err := h.handHistoryRepo.GetUserStats(targetUUID, filter)
+	if err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to get user stats")
+		h.writeError(w, http.StatusInternalServerError, "Failed to get user stats")
+		return
+	}
+
+	h.writeSuccess(w, stats)
+}
+
+// parseStatsFilter reads the metrics filter's game type and stakes, and
+// the optional since, table_size and winners_only query parameters
+func parseStatsFilter(r *http.Request) (repository.StatsFilter, error) {
+	stakes, err := parseMetricsFilter(r)
+	if err != nil {
+		return repository.StatsFilter{}, err
+	}
+
+	query := r.URL.Query()
+	filter := repository.StatsFilter{
+		GameType:    stakes.GameType,
+		MinBigBlind: stakes.MinBigBlind,
+		MaxBigBlind: stakes.MaxBigBlind,
+		TableSize:   models.TableSize(query.Get("table_size")),
+	}
+
+	if since := query.Get("since"); since != "" {
+		parsed, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return repository.StatsFilter{}, errors.New("Invalid since format")
+		}
+		filter.Since = &parsed
+	}
+
+	if filter.TableSize != "" {
+		if _, _, ok := filter.TableSize.PlayersDealtIn(); !ok {
+			return repository.StatsFilter{}, errors.New("table_size must be heads_up, six_max or full_ring")
+		}
+	}
+
+	if winnersOnly := query.Get("winners_only"); winnersOnly != "" {
+		if filter.WinnersOnly, err = strconv.ParseBool(winnersOnly); err != nil {
+			return repository.StatsFilter{}, errors.New("invalid winners_only")
+		}
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/repository/repositorytest"
+)
+
+func TestGetUserStats(t *testing.T) {
+	hero, villain := uuid.New(), uuid.New()
+	start := time.Date(2026, 5, 1, 20, 0, 0, 0, time.UTC)
+	hand := func(number, dealtIn int, won bool) models.HandHistory {
+		return models.HandHistory{
+			ID: uuid.New(), UserID: hero, HandNumber: number, PlayersDealtIn: dealtIn, IsWinner: won,
+			BigBlind: 20, AmountWon: 100, StartedAt: start.Add(time.Duration(number) * time.Minute),
+		}
+	}
+	handler := &Handler{handHistoryRepo: repositorytest.NewHandHistories(
+		hand(1, 2, true),
+		hand(2, 2, false),
+		hand(3, 6, true),
+		hand(4, 9, false),
+	)}
+
+	getStats := func(userID uuid.UUID, query url.Values) *httptest.ResponseRecorder {
+		req := asUser(httptest.NewRequest(http.MethodGet, "/api/v1/users/"+userID.String()+"/stats?"+query.Encode(), nil), hero, "hero")
+		req = mux.SetURLVars(req, map[string]string{"userId": userID.String()})
+		rr := httptest.NewRecorder()
+		handler.GetUserStats(rr, req)
+		return rr
+	}
+	totalHands := func(rr *httptest.ResponseRecorder) int {
+		var response struct {
+			Data models.HandSummary `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		return response.Data.TotalHands
+	}
+
+	rr := getStats(hero, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 4, totalHands(rr))
+
+	rr = getStats(hero, url.Values{"table_size": {"heads_up"}})
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 2, totalHands(rr))
+
+	rr = getStats(hero, url.Values{"table_size": {"heads_up"}, "winners_only": {"true"}})
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 1, totalHands(rr))
+
+	rr = getStats(hero, url.Values{"since": {start.Add(3 * time.Minute).Format(time.RFC3339)}})
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 2, totalHands(rr))
+
+	for _, query := range []url.Values{
+		{"table_size": {"eight_max"}},
+		{"winners_only": {"sometimes"}},
+		{"since": {"yesterday"}},
+		{"min_big_blind": {"lots"}},
+	} {
+		assert.Equal(t, http.StatusBadRequest, getStats(hero, query).Code, query.Encode())
+	}
+
+	assert.Equal(t, http.StatusForbidden, getStats(villain, nil).Code)
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/primoPoker/server/internal/handexport"
+	"github.com/primoPoker/server/internal/logging"
+	"github.com/primoPoker/server/internal/storage"
+)
+
+// SetStorage sets the buckets exports and avatars are kept in; either may
+// be nil. A hand history export larger than inlineLimit bytes is stored
+// in exports and fetched from a URL signed for urlTTL.
+func (h *Handler) SetStorage(exports, avatars storage.Bucket, inlineLimit int, urlTTL time.Duration) {
+	h.exports = exports
+	h.avatars = avatars
+	h.exportInlineLimit = inlineLimit
+	h.signedURLTTL = urlTTL
+}
+
+// exportHandsToBucket renders a hand history export before answering. An
+// export of up to exportInlineLimit bytes is sent as the response; a larger
+// one is stored in the export bucket, and the client is sent to a signed
+// URL to fetch it from.
+func (h *Handler) exportHandsToBucket(w http.ResponseWriter, r *http.Request, userID uuid.UUID, format handexport.Format, from, to time.Time) {
+	// Cancelling abandons an export that spilled to the bucket but failed
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	filename := "hands-" + time.Now().UTC().Format("20060102") + "." + format.Extension()
+	disposition := `attachment; filename="` + filename + `"`
+	name := fmt.Sprintf("hands/%s/%s-%s", userID, uuid.NewString(), filename)
+	spill := storage.NewSpillWriter(ctx, h.exports, name, storage.ObjectOptions{
+		ContentType:        format.ContentType(),
+		ContentDisposition: disposition,
+	}, h.exportInlineLimit)
+
+	writer, err := handexport.NewWriter(spill, format)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	log := logging.FromContext(ctx).WithFields(logrus.Fields{
+		"user_id": userID,
+		"format":  format,
+	})
+	count, err := handexport.Export(writer, h.exportHandRepo, userID, from, to)
+	if err == nil {
+		err = spill.Close()
+	}
+	if err != nil {
+		log.WithError(err).WithField("hands", count).Error("Hand history export failed")
+		h.writeError(w, http.StatusInternalServerError, "Failed to export hand history")
+		return
+	}
+	log = log.WithField("hands", count)
+
+	if !spill.Spilled() {
+		w.Header().Set("Content-Type", format.ContentType())
+		w.Header().Set("Content-Disposition", disposition)
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write(spill.Bytes()); err != nil {
+			log.WithError(err).Warn("Failed to send hand history export")
+			return
+		}
+		log.Info("Hand history exported")
+		return
+	}
+
+	expiresAt := time.Now().Add(h.signedURLTTL)
+	url, err := h.exports.SignedURL(ctx, name, h.signedURLTTL)
+	if err != nil {
+		log.WithError(err).Error("Failed to sign hand history export URL")
+		h.writeError(w, http.StatusInternalServerError, "Failed to export hand history")
+		return
+	}
+
+	w.Header().Set("Location", url)
+	h.writeJSON(w, http.StatusSeeOther, Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"url":        url,
+			"expires_at": expiresAt.UTC(),
+			"hands":      count,
+		},
+	})
+	log.WithField("object", name).Info("Hand history exported to storage")
+}
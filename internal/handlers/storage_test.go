@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/internal/auth"
+	"github.com/primoPoker/server/internal/config"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/repository"
+	"github.com/primoPoker/server/internal/repository/repositorytest"
+	"github.com/primoPoker/server/internal/storage"
+	"github.com/primoPoker/server/internal/testutil"
+)
+
+// pngHeader is enough of a PNG for its type to be sniffed
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00")
+
+func TestExportHandsStoresLargeExportsInTheBucket(t *testing.T) {
+	hero := uuid.New()
+	start := time.Date(2026, 5, 1, 20, 0, 0, 0, time.UTC)
+	hands := repositorytest.NewHandHistories()
+	for i := 0; i < 20; i++ {
+		at := start.Add(time.Duration(i) * time.Minute)
+		hands.Add(models.HandHistory{ID: uuid.New(), GameID: uuid.New(), UserID: hero, HandNumber: i + 1,
+			SmallBlind: 50, BigBlind: 100, StartedAt: at, FinishedAt: at.Add(time.Minute)})
+	}
+	bucket := storage.NewMemory("exports")
+	handler := &Handler{exportHandRepo: hands}
+
+	export := func() *httptest.ResponseRecorder {
+		req := asUser(httptest.NewRequest(http.MethodGet, "/api/v1/hands/export?format=csv", nil), hero, "hero")
+		rr := httptest.NewRecorder()
+		handler.ExportHands(rr, req)
+		return rr
+	}
+
+	handler.SetStorage(bucket, nil, 1<<20, 15*time.Minute)
+	rr := export()
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "text/csv; charset=utf-8", rr.Header().Get("Content-Type"))
+	inline := rr.Body.String()
+	assert.Equal(t, 21, strings.Count(inline, "\n"), "a header and each hand")
+	assert.Empty(t, bucket.Names(""), "a small export is sent as it is")
+
+	handler.SetStorage(bucket, nil, 512, 15*time.Minute)
+	rr = export()
+	require.Equal(t, http.StatusSeeOther, rr.Code, rr.Body.String())
+
+	names := bucket.Names("hands/" + hero.String() + "/")
+	require.Len(t, names, 1)
+	object, _ := bucket.Object(names[0])
+	assert.Equal(t, inline, string(object.Data))
+	assert.Equal(t, "text/csv; charset=utf-8", object.ContentType)
+	assert.Contains(t, object.ContentDisposition, "attachment")
+
+	var response struct {
+		Data struct {
+			URL       string    `json:"url"`
+			ExpiresAt time.Time `json:"expires_at"`
+			Hands     int       `json:"hands"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, rr.Header().Get("Location"), response.Data.URL)
+	assert.True(t, strings.HasPrefix(response.Data.URL, bucket.PublicURL(names[0])))
+	assert.WithinDuration(t, time.Now().Add(15*time.Minute), response.Data.ExpiresAt, time.Minute)
+	assert.Equal(t, 20, response.Data.Hands)
+}
+
+func TestUploadAvatar(t *testing.T) {
+	db := testutil.NewDB(t, &models.User{}, &models.Session{}, &models.RotatedRefreshToken{}, &models.LoginEvent{}, &models.APIKey{})
+	authService := auth.NewService("test-secret", config.SecurityConfig{}, repository.NewUserRepository(db),
+		repository.NewSessionRepository(db), repository.NewLoginEventRepository(db), repository.NewAPIKeyRepository(db))
+	handler := &Handler{authService: authService}
+
+	user, err := authService.CreateUser("alice", "correct-horse-42", "alice@example.com")
+	require.NoError(t, err)
+
+	upload := func(image []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/users/me/avatar", bytes.NewReader(image))
+		ctx := context.WithValue(req.Context(), "user_id", user.ID.String())
+		ctx = context.WithValue(ctx, "session_id", uuid.NewString())
+		rr := httptest.NewRecorder()
+		handler.UploadAvatar(rr, req.WithContext(ctx))
+		return rr
+	}
+
+	rr := upload(pngHeader)
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code, "no bucket, no uploads")
+
+	bucket := storage.NewMemory("avatars")
+	handler.SetStorage(nil, bucket, 0, 0)
+
+	rr = upload(pngHeader)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	prefix := "avatars/" + user.ID.String() + "/"
+	names := bucket.Names(prefix)
+	require.Len(t, names, 1)
+	first, _ := bucket.Object(names[0])
+	assert.Equal(t, "image/png", first.ContentType)
+	assert.Equal(t, avatarCacheControl, first.CacheControl)
+
+	stored, err := authService.GetUser(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, bucket.PublicURL(names[0]), stored.Avatar)
+
+	rr = upload(append(append([]byte{}, pngHeader...), 0x01))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	replaced := bucket.Names(prefix)
+	require.Len(t, replaced, 1, "the old avatar is deleted")
+	assert.NotEqual(t, names[0], replaced[0])
+
+	rr = upload([]byte("<svg onload=alert(1)></svg>"))
+	assert.Equal(t, http.StatusUnsupportedMediaType, rr.Code)
+	assert.Equal(t, "unsupported_image", errorCode(t, rr))
+	assert.Equal(t, replaced, bucket.Names(prefix))
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/primoPoker/server/internal/logging"
+	"github.com/primoPoker/server/internal/models"
+)
+
+// summaryPeriodsShown is how many days or weeks of summaries are returned
+// when no from is given
+const summaryPeriodsShown = 12
+
+// GetHandSummaries returns the authenticated user's day or week hand
+// summaries, oldest first
+func (h *Handler) GetHandSummaries(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.requestUserID(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	period := models.SummaryPeriod(query.Get("period"))
+	switch period {
+	case "":
+		period = models.SummaryPeriodWeek
+	case models.SummaryPeriodDay, models.SummaryPeriodWeek:
+	default:
+		h.writeError(w, http.StatusBadRequest, "period must be day or week")
+		return
+	}
+
+	to := time.Now()
+	if value := query.Get("to"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid to format")
+			return
+		}
+		to = parsed
+	}
+
+	from := to.AddDate(0, 0, -summaryPeriodsShown)
+	if period == models.SummaryPeriodWeek {
+		from = to.AddDate(0, 0, -7*summaryPeriodsShown)
+	}
+	if value := query.Get("from"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "Invalid from format")
+			return
+		}
+		from = parsed
+	}
+
+	if to.Before(from) {
+		h.writeError(w, http.StatusBadRequest, "to must not be before from")
+		return
+	}
+
+	summaries, err := h.metricsService.GetSummaries(userID, period, from, to)
+	if err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("Failed to get hand summaries")
+		h.writeError(w, http.StatusInternalServerError, "Failed to get hand summaries")
+		return
+	}
+
+	h.writeSuccess(w, summaries)
+}
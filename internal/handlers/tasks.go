@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/primoPoker/server/internal/cache"
+	"github.com/primoPoker/server/internal/logging"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/repository"
+	"github.com/primoPoker/server/internal/tasks"
+)
+
+// taskClaimTTL is how long a task that has run is remembered, so that it
+// is not run again when delivered again
+const taskClaimTTL = 7 * 24 * time.Hour
+
+// closeWarning is how long before a scheduled close the table is warned
+const closeWarning = 5 * time.Minute
+
+// maxCloseDelay is the furthest ahead a table's close can be scheduled
+const maxCloseDelay = 24 * time.Hour
+
+// closeHandWait bounds how long closing a table waits for the hand being
+// played there to be paid, inside the server's write timeout so the admin
+// closing it is still answered
+const closeHandWait = 10 * time.Second
+
+// startTournamentTask is the payload of a start_tournament task. A task for
+// a tournament since moved to another time does nothing.
+type startTournamentTask struct {
+	TournamentID uuid.UUID `json:"tournament_id"`
+	StartsAt     time.Time `json:"starts_at"`
+}
+
+// closeTableTask is the payload of a close_table task, which warns the
+// table that it is closing or closes it
+type closeTableTask struct {
+	GameID   string    `json:"game_id"`
+	Reason   string    `json:"reason"`
+	ClosesAt time.Time `json:"closes_at"`
+	Warning  bool      `json:"warning"`
+}
+
+// kickIdlePlayerTask is the payload of a kick_idle_player task
+type kickIdlePlayerTask struct {
+	GameID   string `json:"game_id"`
+	PlayerID string `json:"player_id"`
+}
+
+// SetTasks sets the scheduler of delayed operations and the key delivered
+// tasks are signed with. Tasks that have run are recorded in claims, so a
+// task delivered more than once runs once across every instance.
+func (h *Handler) SetTasks(scheduler tasks.Scheduler, key []byte, claims cache.Store) {
+	h.tasks = scheduler
+	h.taskKey = key
+	h.taskClaims = claims
+}
+
+// HandleTask runs a task delivered by Cloud Tasks. Its body must be signed
+// with the task key; anything else is refused before it is read as a
+// task. Failing with 500 has Cloud Tasks deliver the task again later.
+func (h *Handler) HandleTask(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.writeDecodeError(w, err)
+		return
+	}
+
+	if !tasks.Verify(h.taskKey, body, r.Header.Get(tasks.SignatureHeader)) {
+		logging.FromContext(r.Context()).WithField("remote_ip", r.RemoteAddr).Warn("Refused task with an invalid signature")
+		h.writeErrorCode(w, http.StatusUnauthorized, "invalid_signature", "Task signature is invalid")
+		return
+	}
+
+	var task tasks.Task
+	if err := json.Unmarshal(body, &task); err != nil {
+		h.writeDecodeError(w, err)
+		return
+	}
+	if string(task.Type) != mux.Vars(r)["type"] {
+		h.writeErrorCode(w, http.StatusBadRequest, "invalid_task", "Task type does not match the route")
+		return
+	}
+
+	if err := h.RunTask(r.Context(), task); err != nil {
+		logging.FromContext(r.Context()).WithError(err).WithField("task_id", task.ID).Error("Task failed")
+		h.writeError(w, http.StatusInternalServerError, "Task failed")
+		return
+	}
+
+	h.writeSuccess(w, map[string]string{
+		"task_id": task.ID,
+	})
+}
+
+// RunTask runs a task that has come due, unless it has already run. It is
+// the tasks.Runner of the in-process scheduler, and what HandleTask runs.
+func (h *Handler) RunTask(ctx context.Context, task tasks.Task) error {
+	log := logging.FromContext(ctx).WithFields(logrus.Fields{
+		"task_id": task.ID,
+		"type":    task.Type,
+	})
+
+	claim := "task:" + task.ID
+	claimed, err := h.taskClaims.SetNX(ctx, claim, time.Now().Format(time.RFC3339), taskClaimTTL)
+	if err != nil {
+		return fmt.Errorf("failed to claim task: %w", err)
+	}
+	if !claimed {
+		log.Info("Task has already run")
+		return nil
+	}
+
+	if err := h.runTask(ctx, log, task); err != nil {
+		// Let the next delivery run it
+		if err := h.taskClaims.Delete(ctx, claim); err != nil {
+			log.WithError(err).Warn("Failed to release task claim")
+		}
+		return err
+	}
+	return nil
+}
+
+// runTask runs a task by its type. Only failures that may pass are
+// returned; a task that has nothing left to do, or can never run, is done.
+func (h *Handler) runTask(ctx context.Context, log *logrus.Entry, task tasks.Task) error {
+	var payload interface{}
+	switch task.Type {
+	case tasks.StartTournament:
+		payload = &startTournamentTask{}
+	case tasks.CloseTable:
+		payload = &closeTableTask{}
+	case tasks.KickIdlePlayer:
+		payload = &kickIdlePlayerTask{}
+	default:
+		log.Error("Unknown task type")
+		return nil
+	}
+	if err := json.Unmarshal(task.Payload, payload); err != nil {
+		log.WithError(err).Error("Invalid task payload")
+		return nil
+	}
+
+	switch payload := payload.(type) {
+	case *startTournamentTask:
+		return h.startTournament(log, payload)
+	case *closeTableTask:
+		h.closeTable(ctx, log, payload)
+	case *kickIdlePlayerTask:
+		h.kickIdlePlayer(ctx, log, payload)
+	}
+	return nil
+}
+
+// startTournament starts a tournament that has come due, or cancels it
+func (h *Handler) startTournament(log *logrus.Entry, payload *startTournamentTask) error {
+	log = log.WithField("tournament_id", payload.TournamentID)
+
+	tournament, err := h.tournamentRepo.Start(payload.TournamentID, payload.StartsAt, time.Now())
+	switch {
+	case errors.Is(err, repository.ErrTournamentStarted), errors.Is(err, repository.ErrTournamentNotDue), errors.Is(err, gorm.ErrRecordNotFound):
+		log.WithError(err).Info("Tournament left as it was")
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to start tournament: %w", err)
+	}
+
+	log.WithField("status", tournament.Status).Info("Tournament came due")
+	return nil
+}
+
+// closeTable warns a table that it is closing, or closes it
+func (h *Handler) closeTable(ctx context.Context, log *logrus.Entry, payload *closeTableTask) {
+	log = log.WithField("game_id", payload.GameID)
+
+	if payload.Warning {
+		if _, err := h.gameManager.GetGame(payload.GameID); err != nil {
+			log.WithError(err).Info("Table closed before its warning")
+			return
+		}
+		h.notifyAdminAction(ctx, payload.GameID, "", AdminNotice{
+			Action:   "closing",
+			Reason:   payload.Reason,
+			ClosesAt: &payload.ClosesAt,
+		})
+		return
+	}
+
+	closeCtx, cancel := context.WithTimeout(ctx, closeHandWait)
+	defer cancel()
+	stacks, err := h.gameManager.CloseGame(closeCtx, payload.GameID)
+	if err != nil {
+		log.WithError(err).Info("Table already closed")
+		return
+	}
+	h.notifyAdminAction(ctx, payload.GameID, "", AdminNotice{
+		Action:   "close",
+		Reason:   payload.Reason,
+		CashOuts: stacks,
+	})
+	log.WithField("cash_outs", stacks).Info("Closed table as scheduled")
+}
+
+// kickIdlePlayer frees the seat of a player who has not come back to it
+func (h *Handler) kickIdlePlayer(ctx context.Context, log *logrus.Entry, payload *kickIdlePlayerTask) {
+	log = log.WithFields(logrus.Fields{
+		"game_id":   payload.GameID,
+		"player_id": payload.PlayerID,
+	})
+
+	stack, err := h.gameManager.KickIdlePlayer(payload.GameID, payload.PlayerID)
+	if err != nil {
+		log.WithError(err).Info("Idle player no longer to be kicked")
+		return
+	}
+	h.notifyAdminAction(ctx, payload.GameID, payload.PlayerID, AdminNotice{
+		Action:  "kick",
+		Reason:  "Did not return to the table",
+		CashOut: stack,
+	})
+	log.WithField("cash_out", stack).Info("Kicked idle player")
+}
+
+// scheduleClose warns a table's players and closes it at closesAt. The
+// warning goes out closeWarning beforehand, or at once if that is past.
+func (h *Handler) scheduleClose(ctx context.Context, gameID, reason string, closesAt time.Time) error {
+	key := fmt.Sprintf("%s-%d", gameID, closesAt.Unix())
+	warning := closeTableTask{GameID: gameID, Reason: reason, ClosesAt: closesAt, Warning: true}
+	warnAt := closesAt.Add(-closeWarning)
+	if warnAt.Before(time.Now()) {
+		warnAt = time.Now()
+	}
+
+	warn, err := tasks.New(tasks.CloseTable, key+"-warning", warnAt, warning)
+	if err != nil {
+		return err
+	}
+	warning.Warning = false
+	closing, err := tasks.New(tasks.CloseTable, key, closesAt, warning)
+	if err != nil {
+		return err
+	}
+
+	if err := h.tasks.Schedule(ctx, warn); err != nil {
+		return err
+	}
+	return h.tasks.Schedule(ctx, closing)
+}
+
+// ScheduleTournamentStarts schedules the start of every tournament due to
+// start within horizon and returns how many it scheduled. A start already
+// scheduled is not scheduled again, so this can run as often as new
+// tournaments need picking up.
+func (h *Handler) ScheduleTournamentStarts(ctx context.Context, horizon time.Duration) (int, error) {
+	tournaments, err := h.tournamentRepo.ListActive()
+	if err != nil {
+		return 0, err
+	}
+
+	scheduled := 0
+	for _, tournament := range tournaments {
+		if tournament.Status != models.TournamentStatusScheduled && tournament.Status != models.TournamentStatusRegistering {
+			continue
+		}
+		if time.Until(tournament.StartsAt) > horizon {
+			continue
+		}
+
+		task, err := tasks.New(tasks.StartTournament, fmt.Sprintf("%s-%d", tournament.ID, tournament.StartsAt.Unix()), tournament.StartsAt, startTournamentTask{
+			TournamentID: tournament.ID,
+			StartsAt:     tournament.StartsAt,
+		})
+		if err != nil {
+			return scheduled, err
+		}
+		if err := h.tasks.Schedule(ctx, task); err != nil {
+			return scheduled, err
+		}
+		scheduled++
+	}
+	return scheduled, nil
+}
+
+// ScheduleIdleKicks schedules freeing each seat held since a restart,
+// after grace, for players who have not come back by then. It returns
+// how many it scheduled.
+func (h *Handler) ScheduleIdleKicks(ctx context.Context, grace time.Duration) (int, error) {
+	kickAt := time.Now().Add(grace)
+
+	scheduled := 0
+	for gameID, playerIDs := range h.gameManager.HeldSeats() {
+		for _, playerID := range playerIDs {
+			task, err := tasks.New(tasks.KickIdlePlayer, fmt.Sprintf("%s-%s-%d", gameID, playerID, kickAt.Unix()), kickAt, kickIdlePlayerTask{
+				GameID:   gameID,
+				PlayerID: playerID,
+			})
+			if err != nil {
+				return scheduled, err
+			}
+			if err := h.tasks.Schedule(ctx, task); err != nil {
+				return scheduled, err
+			}
+			scheduled++
+		}
+	}
+	return scheduled, nil
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/primoPoker/server/internal/cache"
+	"github.com/primoPoker/server/internal/game"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/repository"
+	"github.com/primoPoker/server/internal/tasks"
+	"github.com/primoPoker/server/internal/testutil"
+	"github.com/primoPoker/server/internal/websocket"
+)
+
+var testTaskKey = []byte("0123456789abcdef0123456789abcdef")
+
+// taskRouter serves the task endpoint of a handler over db
+func taskRouter(db *gorm.DB) (*Handler, http.Handler) {
+	handler := &Handler{
+		gameManager:    game.NewManager(),
+		wsHub:          websocket.NewHub(),
+		tournamentRepo: repository.NewTournamentRepository(db),
+	}
+	handler.SetTasks(tasks.NewLocal(handler.RunTask), testTaskKey, cache.NewMemory())
+
+	router := mux.NewRouter()
+	router.HandleFunc(tasks.Path+"{type}", handler.HandleTask).Methods("POST")
+	return handler, router
+}
+
+// deliverTask posts a task body to its endpoint as Cloud Tasks would
+func deliverTask(router http.Handler, taskType tasks.Type, body []byte, signature string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, tasks.Path+string(taskType), bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if signature != "" {
+		req.Header.Set(tasks.SignatureHeader, signature)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+// dueTournament creates a tournament due to start now with a registration
+// for each of players, who paid buyIn
+func dueTournament(t *testing.T, db *gorm.DB, minPlayers int, buyIn int64, players ...*models.User) (*models.Tournament, tasks.Task) {
+	startsAt := time.Now().Add(-time.Second).Truncate(time.Microsecond)
+	tournament := &models.Tournament{
+		Name:                 "Sunday Major",
+		Status:               models.TournamentStatusRegistering,
+		BuyIn:                buyIn,
+		StartingStack:        5000,
+		MinPlayers:           minPlayers,
+		MaxPlayers:           100,
+		RegistrationClosesAt: startsAt,
+		StartsAt:             startsAt,
+		PrizePool:            buyIn * int64(len(players)),
+	}
+	require.NoError(t, db.Create(tournament).Error)
+	for _, player := range players {
+		require.NoError(t, db.Create(player).Error)
+		require.NoError(t, db.Create(&models.TournamentRegistration{
+			TournamentID: tournament.ID,
+			UserID:       player.ID,
+			BuyInPaid:    buyIn,
+			Chips:        tournament.StartingStack,
+		}).Error)
+	}
+
+	task, err := tasks.New(tasks.StartTournament, tournament.ID.String(), startsAt, startTournamentTask{
+		TournamentID: tournament.ID,
+		StartsAt:     startsAt,
+	})
+	require.NoError(t, err)
+	return tournament, task
+}
+
+func TestHandleTaskRefusesForgedSignatures(t *testing.T) {
+	db := testutil.NewDB(t, &models.User{}, &models.Tournament{}, &models.TournamentRegistration{})
+	_, router := taskRouter(db)
+	tournament, task := dueTournament(t, db, 2, 0,
+		&models.User{Username: "alice", Email: "alice@example.com"},
+		&models.User{Username: "bob", Email: "bob@example.com"})
+
+	body, err := json.Marshal(task)
+	require.NoError(t, err)
+	tampered := bytes.Replace(body, []byte(tournament.ID.String()), []byte(uuid.NewString()), 1)
+
+	for name, forged := range map[string]struct {
+		body      []byte
+		signature string
+	}{
+		"unsigned":      {body, ""},
+		"wrong key":     {body, tasks.Sign([]byte("not-the-server-key-not-the-server"), body)},
+		"tampered body": {tampered, tasks.Sign(testTaskKey, body)},
+		"not hex":       {body, "sha256=" + tasks.Sign(testTaskKey, body)},
+		"truncated":     {body, tasks.Sign(testTaskKey, body)[:32]},
+	} {
+		rr := deliverTask(router, tasks.StartTournament, forged.body, forged.signature)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code, name)
+		assert.Equal(t, "invalid_signature", errorCode(t, rr), name)
+	}
+
+	stored, err := repository.NewTournamentRepository(db).GetByID(tournament.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.TournamentStatusRegistering, stored.Status, "no forged task ran")
+
+	rr := deliverTask(router, tasks.CloseTable, body, tasks.Sign(testTaskKey, body))
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "a signed task only runs at its own route")
+
+	rr = deliverTask(router, tasks.StartTournament, body, tasks.Sign(testTaskKey, body))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	stored, err = repository.NewTournamentRepository(db).GetByID(tournament.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.TournamentStatusRunning, stored.Status)
+	assert.Equal(t, 1, stored.CurrentLevel)
+	require.NotNil(t, stored.StartedAt)
+}
+
+func TestHandleTaskRunsRetriedTasksOnce(t *testing.T) {
+	db := testutil.NewDB(t, &models.User{}, &models.Tournament{}, &models.TournamentRegistration{})
+	handler, router := taskRouter(db)
+	alice := &models.User{Username: "alice", Email: "alice@example.com", ChipBalance: 1000}
+	tournament, task := dueTournament(t, db, 2, 250, alice)
+
+	body, err := json.Marshal(task)
+	require.NoError(t, err)
+	signature := tasks.Sign(testTaskKey, body)
+
+	// Cloud Tasks delivers at least once, so the same task can arrive again
+	for i := 0; i < 3; i++ {
+		rr := deliverTask(router, tasks.StartTournament, body, signature)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	stored, err := repository.NewTournamentRepository(db).GetByID(tournament.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.TournamentStatusCancelled, stored.Status, "too few players registered")
+	assert.Zero(t, stored.PrizePool)
+
+	var refunded models.User
+	require.NoError(t, db.First(&refunded, "id = ?", alice.ID).Error)
+	assert.Equal(t, int64(1250), refunded.ChipBalance, "the buy-in is refunded once")
+
+	claimed, err := handler.taskClaims.SetNX(context.Background(), "task:"+task.ID, "again", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, claimed, "the task is recorded as run")
+}
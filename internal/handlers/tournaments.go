@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/primoPoker/server/internal/logging"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/repository"
+)
+
+// TournamentSummary is a tournament as shown in the lobby listing
+type TournamentSummary struct {
+	ID                   uuid.UUID               `json:"id"`
+	Name                 string                  `json:"name"`
+	GameType             models.GameType         `json:"game_type"`
+	Status               models.TournamentStatus `json:"status"`
+	BuyIn                int64                   `json:"buy_in"`
+	StartingStack        int64                   `json:"starting_stack"`
+	MaxPlayers           int                     `json:"max_players"`
+	TableSize            int                     `json:"table_size"`
+	Registered           int64                   `json:"registered"`
+	RegistrationOpen     bool                    `json:"registration_open"`
+	RegistrationClosesAt time.Time               `json:"registration_closes_at"`
+	StartsAt             time.Time               `json:"starts_at"`
+	Levels               int                     `json:"levels"`
+	PrizePool            int64                   `json:"prize_pool"`
+}
+
+// TournamentPayout is one row of a tournament's payout table
+type TournamentPayout struct {
+	Place   int     `json:"place"`
+	Percent float64 `json:"percent"`
+	Amount  int64   `json:"amount"`
+}
+
+// TournamentDetail is the full view of a single tournament
+type TournamentDetail struct {
+	TournamentSummary
+	Description      string              `json:"description"`
+	BlindStructure   []models.BlindLevel `json:"blind_structure"`
+	Payouts          []TournamentPayout  `json:"payouts"`
+	CurrentLevel     *models.BlindLevel  `json:"current_level"`
+	LevelStartedAt   *time.Time          `json:"level_started_at"`
+	RemainingPlayers int                 `json:"remaining_players"`
+	StartedAt        *time.Time          `json:"started_at"`
+	FinishedAt       *time.Time          `json:"finished_at"`
+}
+
+// TournamentPlayer is a registered player with their in-tournament state
+type TournamentPlayer struct {
+	UserID         uuid.UUID `json:"user_id"`
+	Username       string    `json:"username"`
+	Chips          int64     `json:"chips"`
+	TableID        string    `json:"table_id,omitempty"`
+	SeatPosition   int       `json:"seat_position"`
+	IsEliminated   bool      `json:"is_eliminated"`
+	FinishPosition int       `json:"finish_position,omitempty"`
+	Prize          int64     `json:"prize,omitempty"`
+}
+
+func newTournamentSummary(t *models.Tournament, registered int64) TournamentSummary {
+	return TournamentSummary{
+		ID:                   t.ID,
+		Name:                 t.Name,
+		GameType:             t.GameType,
+		Status:               t.Status,
+		BuyIn:                t.BuyIn,
+		StartingStack:        t.StartingStack,
+		MaxPlayers:           t.MaxPlayers,
+		TableSize:            t.TableSize,
+		Registered:           registered,
+		RegistrationOpen:     t.IsRegistrationOpen(time.Now()) && (t.MaxPlayers == 0 || registered < int64(t.MaxPlayers)),
+		RegistrationClosesAt: t.RegistrationClosesAt,
+		StartsAt:             t.StartsAt,
+		Levels:               len(t.BlindStructure),
+		PrizePool:            t.PrizePool,
+	}
+}
+
+// ListTournaments lists upcoming and running tournaments
+func (h *Handler) ListTournaments(w http.ResponseWriter, r *http.Request) {
+	tournaments, err := h.tournamentRepo.ListActive()
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to list tournaments")
+		return
+	}
+
+	ids := make([]uuid.UUID, len(tournaments))
+	for i := range tournaments {
+		ids[i] = tournaments[i].ID
+	}
+
+	counts, err := h.tournamentRepo.CountRegistrations(ids)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to list tournaments")
+		return
+	}
+
+	summaries := make([]TournamentSummary, len(tournaments))
+	for i := range tournaments {
+		summaries[i] = newTournamentSummary(&tournaments[i], counts[tournaments[i].ID])
+	}
+
+	h.writeSuccess(w, summaries)
+}
+
+// GetTournament gets a tournament's structure, payouts and progress
+func (h *Handler) GetTournament(w http.ResponseWriter, r *http.Request) {
+	tournament, ok := h.loadTournament(w, r)
+	if !ok {
+		return
+	}
+
+	registrations, err := h.tournamentRepo.GetRegistrations(tournament.ID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get tournament players")
+		return
+	}
+
+	remaining := 0
+	for _, registration := range registrations {
+		if !registration.IsEliminated {
+			remaining++
+		}
+	}
+
+	amounts := tournament.GetPayoutAmounts()
+	payouts := make([]TournamentPayout, len(tournament.Payouts))
+	for i, tier := range tournament.Payouts {
+		payouts[i] = TournamentPayout{
+			Place:   tier.Place,
+			Percent: tier.Percent,
+			Amount:  amounts[tier.Place],
+		}
+	}
+
+	h.writeSuccess(w, TournamentDetail{
+		TournamentSummary: newTournamentSummary(tournament, int64(len(registrations))),
+		Description:       tournament.Description,
+		BlindStructure:    tournament.BlindStructure,
+		Payouts:           payouts,
+		CurrentLevel:      tournament.GetCurrentBlindLevel(),
+		LevelStartedAt:    tournament.LevelStartedAt,
+		RemainingPlayers:  remaining,
+		StartedAt:         tournament.StartedAt,
+		FinishedAt:        tournament.FinishedAt,
+	})
+}
+
+// GetTournamentPlayers lists a tournament's players with stacks and table assignments
+func (h *Handler) GetTournamentPlayers(w http.ResponseWriter, r *http.Request) {
+	tournament, ok := h.loadTournament(w, r)
+	if !ok {
+		return
+	}
+
+	registrations, err := h.tournamentRepo.GetRegistrations(tournament.ID)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to get tournament players")
+		return
+	}
+
+	players := make([]TournamentPlayer, len(registrations))
+	for i, registration := range registrations {
+		players[i] = TournamentPlayer{
+			UserID:         registration.UserID,
+			Username:       registration.User.Username,
+			Chips:          registration.Chips,
+			TableID:        registration.TableID,
+			SeatPosition:   registration.SeatPosition,
+			IsEliminated:   registration.IsEliminated,
+			FinishPosition: registration.FinishPosition,
+			Prize:          registration.Prize,
+		}
+	}
+
+	h.writeSuccess(w, players)
+}
+
+// RegisterTournament registers the authenticated user for a tournament
+func (h *Handler) RegisterTournament(w http.ResponseWriter, r *http.Request) {
+	tournamentID, userID, ok := h.tournamentRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	registration, err := h.tournamentRepo.Register(tournamentID, userID)
+	if err != nil {
+		h.writeTournamentError(w, r, err, "Failed to register for tournament")
+		return
+	}
+
+	logging.FromContext(r.Context()).WithFields(logrus.Fields{
+		"tournament_id": tournamentID,
+		"user_id":       userID,
+		"buy_in":        registration.BuyInPaid,
+	}).Info("Player registered for tournament")
+
+	h.writeSuccess(w, registration)
+}
+
+// UnregisterTournament removes the authenticated user from a tournament
+func (h *Handler) UnregisterTournament(w http.ResponseWriter, r *http.Request) {
+	tournamentID, userID, ok := h.tournamentRequestIDs(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.tournamentRepo.Unregister(tournamentID, userID); err != nil {
+		h.writeTournamentError(w, r, err, "Failed to unregister from tournament")
+		return
+	}
+
+	logging.FromContext(r.Context()).WithFields(logrus.Fields{
+		"tournament_id": tournamentID,
+		"user_id":       userID,
+	}).Info("Player unregistered from tournament")
+
+	h.writeSuccess(w, map[string]string{"message": "Successfully unregistered from tournament"})
+}
+
+// loadTournament fetches the tournament named in the route, writing the error response on failure
+func (h *Handler) loadTournament(w http.ResponseWriter, r *http.Request) (*models.Tournament, bool) {
+	tournamentID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid tournament ID")
+		return nil, false
+	}
+
+	tournament, err := h.tournamentRepo.GetByID(tournamentID)
+	if err != nil {
+		h.writeTournamentError(w, r, err, "Failed to get tournament")
+		return nil, false
+	}
+
+	return tournament, true
+}
+
+// tournamentRequestIDs parses the tournament ID from the route and the user ID from the context
+func (h *Handler) tournamentRequestIDs(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	userID := getUserIDFromContext(r)
+	if userID == "" {
+		h.writeError(w, http.StatusUnauthorized, "User not authenticated")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid user ID")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	tournamentID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid tournament ID")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return tournamentID, userUUID, true
+}
+
+// writeTournamentError maps repository errors onto status codes and error codes
+func (h *Handler) writeTournamentError(w http.ResponseWriter, r *http.Request, err error, fallback string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		h.writeErrorCode(w, http.StatusNotFound, "tournament_not_found", "Tournament not found")
+	case errors.Is(err, repository.ErrRegistrationClosed):
+		h.writeErrorCode(w, http.StatusConflict, "registration_closed", err.Error())
+	case errors.Is(err, repository.ErrTournamentFull):
+		h.writeErrorCode(w, http.StatusConflict, "tournament_full", err.Error())
+	case errors.Is(err, repository.ErrAlreadyRegistered):
+		h.writeErrorCode(w, http.StatusConflict, "already_registered", err.Error())
+	case errors.Is(err, repository.ErrTournamentStarted):
+		h.writeErrorCode(w, http.StatusConflict, "tournament_started", err.Error())
+	case errors.Is(err, repository.ErrNotRegistered):
+		h.writeErrorCode(w, http.StatusNotFound, "not_registered", err.Error())
+	case errors.Is(err, repository.ErrInsufficientBalance):
+		h.writeErrorCode(w, http.StatusPaymentRequired, "insufficient_balance", err.Error())
+	default:
+		logging.FromContext(r.Context()).WithError(err).Error(fallback)
+		h.writeError(w, http.StatusInternalServerError, fallback)
+	}
+}
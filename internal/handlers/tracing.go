@@ -0,0 +1,7 @@
+package handlers
+
+import "go.opentelemetry.io/otel"
+
+// tracer starts the spans of the work handlers do after the game manager,
+// such as telling the other players at a table what changed
+var tracer = otel.Tracer("github.com/primoPoker/server/internal/handlers")
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/primoPoker/server/internal/database"
+	"github.com/primoPoker/server/internal/game"
+	"github.com/primoPoker/server/internal/middleware"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/repository"
+	"github.com/primoPoker/server/internal/tablerecord"
+	"github.com/primoPoker/server/internal/testutil"
+	"github.com/primoPoker/server/internal/websocket"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestJoinGameTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	db := testutil.NewDB(t, &models.User{}, &models.Game{}, &models.GameParticipation{})
+	require.NoError(t, database.Trace(db))
+	alice := models.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com", PasswordHash: "x", ChipBalance: 20000}
+	require.NoError(t, db.Create(&alice).Error)
+
+	// Tables and buy-ins are stored as they are in production
+	store := tablerecord.NewStore(repository.NewGameRepository(db), 16)
+	bank := tablerecord.NewBank(repository.NewUserRepository(db))
+	bank.StoreSeats(database.NewReplicatedDB(db, nil), store)
+	manager := game.NewManager()
+	manager.SetStore(store)
+	manager.SetBank(bank)
+	table, err := manager.CreateGame(uuid.New().String(), "Traced")
+	require.NoError(t, err)
+
+	handler := &Handler{gameManager: manager, wsHub: websocket.NewHub()}
+	router := mux.NewRouter()
+	router.Use(middleware.RequestID)
+	router.Use(middleware.Trace)
+	router.HandleFunc("/api/v1/games/{gameId}/join", func(w http.ResponseWriter, r *http.Request) {
+		handler.JoinGame(w, asUser(r, alice.ID, "alice"))
+	}).Methods("POST")
+
+	recorder.Reset()
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, jsonRequest(http.MethodPost, "/api/v1/games/"+table.ID+"/join", `{"buy_in": 10000}`))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	spans := recorder.Ended()
+	byName := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range spans {
+		byName[span.Name()] = span
+	}
+	childOf := func(parent sdktrace.ReadOnlySpan) []string {
+		var names []string
+		for _, span := range spans {
+			if span.Parent().SpanID() == parent.SpanContext().SpanID() {
+				names = append(names, span.Name())
+			}
+		}
+		return names
+	}
+
+	root := byName["POST /api/v1/games/{gameId}/join"]
+	require.NotNil(t, root, "the request has a span named after its route")
+	assert.False(t, root.Parent().IsValid(), "the request's span is the root")
+	assert.Equal(t, trace.SpanKindServer, root.SpanKind())
+	assert.Contains(t, root.Attributes(), attribute.String("request_id", rr.Header().Get("X-Request-ID")))
+	assert.Equal(t, []string{"Manager.JoinGame"}, childOf(root), "the other players are sent the new state by the table's observer")
+
+	join := byName["Manager.JoinGame"]
+	children := childOf(join)
+	assert.Contains(t, children, "Manager.lock", "the wait for the manager's lock is its own span")
+	var queries int
+	for _, span := range spans {
+		if span.Parent().SpanID() == join.SpanContext().SpanID() && span.SpanKind() == trace.SpanKindClient {
+			queries++
+			assert.Equal(t, root.SpanContext().TraceID(), span.SpanContext().TraceID())
+		}
+	}
+	assert.Positive(t, queries, "the buy-in's queries are part of the join, not traces of their own: %v", children)
+
+	for _, span := range spans {
+		assert.Equal(t, root.SpanContext().TraceID(), span.SpanContext().TraceID(), "%s is in the request's trace", span.Name())
+	}
+}
@@ -0,0 +1,238 @@
+package handrecord
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/primoPoker/server/internal/game"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/pkg/poker"
+)
+
+// Histories builds the HandHistory rows of a completed hand, one per player
+// dealt in. Every row carries the whole table's actions so a hand can be
+// replayed from any player's record. Bots have no row of their own, and
+// the rows of a hand they were dealt into are marked against bots.
+func Histories(gameID uuid.UUID, hand game.CompletedHand) ([]models.HandHistory, error) {
+	players := make(map[string]game.HandPlayer, len(hand.Players))
+	userIDs := make(map[string]uuid.UUID, len(hand.Players))
+	againstBots := false
+	for _, player := range hand.Players {
+		userID, err := uuid.Parse(player.ID)
+		if err != nil {
+			return nil, fmt.Errorf("player %q: %w", player.ID, err)
+		}
+		players[player.ID] = player
+		userIDs[player.ID] = userID
+		againstBots = againstBots || player.Bot
+	}
+
+	streets := make(map[game.GamePhase][]models.PlayerActionRecord)
+	opened := make(map[game.GamePhase]bool)
+	foldedIn := make(map[string]game.GamePhase)
+	for _, action := range hand.Actions {
+		userID, ok := userIDs[action.PlayerID]
+		if !ok {
+			continue
+		}
+
+		record := models.PlayerActionRecord{
+			PlayerID:    userID,
+			Username:    players[action.PlayerID].Username,
+			Action:      recordedAction(action, opened[action.Phase]),
+			Amount:      action.ChipsBefore - action.ChipsAfter,
+			Timestamp:   action.Time,
+			ChipsBefore: action.ChipsBefore,
+			ChipsAfter:  action.ChipsAfter,
+			Position:    string(players[action.PlayerID].Position),
+		}
+		switch record.Action {
+		case models.ActionBet, models.ActionRaise, models.ActionAllIn:
+			opened[action.Phase] = true
+		case models.ActionFold:
+			foldedIn[action.PlayerID] = action.Phase
+		}
+		streets[action.Phase] = append(streets[action.Phase], record)
+	}
+
+	voluntary, raised := preFlopFlags(streets[game.PreFlop])
+
+	histories := make([]models.HandHistory, 0, len(hand.Players))
+	for _, player := range hand.Players {
+		if player.Bot {
+			continue
+		}
+		h := models.HandHistory{
+			GameID:         gameID,
+			UserID:         userIDs[player.ID],
+			HandNumber:     hand.HandNumber,
+			TableName:      hand.TableName,
+			DealerPosition: hand.DealerSeat,
+			SeatPosition:   player.SeatPosition,
+			Position:       string(player.Position),
+			PlayersDealtIn: len(hand.Players),
+			SmallBlind:     hand.SmallBlind,
+			BigBlind:       hand.BigBlind,
+			StartingChips:  player.StartingChips,
+			EndingChips:    player.EndingChips,
+			NetResult:      player.EndingChips - player.StartingChips,
+			PotSize:        hand.Pot,
+			AmountWon:      player.AmountWon,
+			PreFlopActions: streets[game.PreFlop],
+			FlopActions:    streets[game.Flop],
+			TurnActions:    streets[game.Turn],
+			RiverActions:   streets[game.River],
+			VPIPPercent:    percentFlag(voluntary[userIDs[player.ID]]),
+			PFRPercent:     percentFlag(raised[userIDs[player.ID]]),
+			IsWinner:       player.AmountWon > 0,
+			WentToShowdown: player.WentToShowdown,
+			AgainstBots:    againstBots,
+			StartedAt:      hand.StartedAt,
+			FinishedAt:     hand.FinishedAt,
+			Duration:       int(hand.FinishedAt.Sub(hand.StartedAt).Seconds()),
+		}
+
+		h.HoleCard1Rank, h.HoleCard1Suit = cardFields(player.HoleCards, 0)
+		h.HoleCard2Rank, h.HoleCard2Suit = cardFields(player.HoleCards, 1)
+		h.FlopCard1Rank, h.FlopCard1Suit = cardFields(hand.CommunityCards, 0)
+		h.FlopCard2Rank, h.FlopCard2Suit = cardFields(hand.CommunityCards, 1)
+		h.FlopCard3Rank, h.FlopCard3Suit = cardFields(hand.CommunityCards, 2)
+		h.TurnCardRank, h.TurnCardSuit = cardFields(hand.CommunityCards, 3)
+		h.RiverCardRank, h.RiverCardSuit = cardFields(hand.CommunityCards, 4)
+
+		if phase, ok := foldedIn[player.ID]; ok && player.Folded {
+			h.FoldedPhase = handPhase(phase)
+		}
+
+		if player.BestHand != nil {
+			h.HandRank = player.BestHand.Rank.String()
+			cards := make([]string, len(player.BestHand.Cards))
+			for i, card := range player.BestHand.Cards {
+				cards[i] = card.Code()
+			}
+			h.BestHand = strings.Join(cards, " ")
+		}
+
+		histories = append(histories, h)
+	}
+
+	return histories, nil
+}
+
+// TableHand builds the table-level record of a completed hand, which its
+// players' HandHistory rows reference
+func TableHand(gameID uuid.UUID, hand game.CompletedHand) *models.Hand {
+	record := &models.Hand{
+		ID:             uuid.New(),
+		GameID:         gameID,
+		HandNumber:     hand.HandNumber,
+		TableName:      hand.TableName,
+		DealerPosition: hand.DealerSeat,
+		PlayersDealtIn: len(hand.Players),
+		SmallBlind:     hand.SmallBlind,
+		BigBlind:       hand.BigBlind,
+		Pot:            hand.Pot,
+		StartedAt:      hand.StartedAt,
+		FinishedAt:     hand.FinishedAt,
+	}
+
+	board := make([]string, len(hand.CommunityCards))
+	for i, card := range hand.CommunityCards {
+		board[i] = card.Code()
+	}
+	record.SetBoard(board)
+	record.Summary = summary(hand, board)
+
+	for _, player := range hand.Players {
+		if player.AmountWon > 0 && !player.Bot {
+			if userID, err := uuid.Parse(player.ID); err == nil {
+				record.Winners = append(record.Winners, userID)
+			}
+		}
+	}
+	return record
+}
+
+// recordedAction maps an engine action to its stored form. The engine has
+// no separate bet, so a raise on a street nobody has opened is recorded as
+// one; the big blind opens the pre-flop betting.
+func recordedAction(action game.Action, opened bool) models.PlayerAction {
+	switch action.Action {
+	case game.Fold:
+		return models.ActionFold
+	case game.Check:
+		return models.ActionCheck
+	case game.Call:
+		return models.ActionCall
+	case game.AllIn:
+		return models.ActionAllIn
+	}
+	if action.Phase != game.PreFlop && !opened {
+		return models.ActionBet
+	}
+	return models.ActionRaise
+}
+
+// preFlopFlags finds the players who put chips in voluntarily before the
+// flop and those who raised. An all-in raises when it puts the player ahead
+// of everyone else.
+func preFlopFlags(records []models.PlayerActionRecord) (voluntary, raised map[uuid.UUID]bool) {
+	voluntary = make(map[uuid.UUID]bool)
+	raised = make(map[uuid.UUID]bool)
+	committed := make(map[uuid.UUID]int64)
+	var highest int64
+	for _, record := range records {
+		committed[record.PlayerID] += record.Amount
+		switch record.Action {
+		case models.ActionRaise, models.ActionBet:
+			raised[record.PlayerID] = true
+		case models.ActionAllIn:
+			if committed[record.PlayerID] > highest {
+				raised[record.PlayerID] = true
+			}
+		}
+		if record.Amount > 0 {
+			voluntary[record.PlayerID] = true
+		}
+		if committed[record.PlayerID] > highest {
+			highest = committed[record.PlayerID]
+		}
+	}
+	return voluntary, raised
+}
+
+// percentFlag stores a per-hand yes or no as a percentage, so averaging a
+// column over many hands gives the rate
+func percentFlag(set bool) float64 {
+	if set {
+		return 100
+	}
+	return 0
+}
+
+// handPhase maps an engine phase to the stored one
+func handPhase(phase game.GamePhase) models.HandPhase {
+	switch phase {
+	case game.Flop:
+		return models.HandPhaseFlop
+	case game.Turn:
+		return models.HandPhaseTurn
+	case game.River:
+		return models.HandPhaseRiver
+	case game.Showdown:
+		return models.HandPhaseShowdown
+	default:
+		return models.HandPhasePreFlop
+	}
+}
+
+// cardFields returns the stored rank and suit of cards[i], or empty strings
+// when the hand ended before it was dealt
+func cardFields(cards []poker.Card, i int) (string, string) {
+	if i >= len(cards) {
+		return "", ""
+	}
+	return cards[i].Rank.String(), cards[i].Suit.String()
+}
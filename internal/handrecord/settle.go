@@ -0,0 +1,232 @@
+package handrecord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+
+	"github.com/primoPoker/server/internal/database"
+	"github.com/primoPoker/server/internal/game"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/repository"
+)
+
+// settleAttempts is how many times a hand is settled before giving up when
+// the database aborts it for clashing with another transaction
+const settleAttempts = 3
+
+// settleBackoff is the wait before retrying a settlement, doubled each time
+var settleBackoff = 50 * time.Millisecond
+
+// HandResult is everything a completed hand changes in the database
+type HandResult struct {
+	Game       *models.Game // The table, stored with the hand if it is not yet
+	HandNumber int
+	Pot        int64
+	Hand       *models.Hand         // What the players shared
+	Histories  []models.HandHistory // One per player dealt in, referencing Hand
+	Players    []PlayerResult
+}
+
+// PlayerResult is how a hand changed one player's part in the game. Bots
+// have no part to change.
+type PlayerResult struct {
+	UserID        uuid.UUID
+	SeatPosition  int
+	StartingChips int64
+	EndingChips   int64
+	Won           bool
+	Folded        bool
+}
+
+// NewHandResult builds the result of a hand completed at the table stored
+// under gameID
+func NewHandResult(gameID uuid.UUID, hand game.CompletedHand) (*HandResult, error) {
+	histories, err := Histories(gameID, hand)
+	if err != nil {
+		return nil, err
+	}
+	record := TableHand(gameID, hand)
+	for i := range histories {
+		histories[i].HandID = &record.ID
+	}
+
+	result := &HandResult{
+		Game: &models.Game{
+			ID:         gameID,
+			Name:       hand.TableName,
+			GameType:   models.GameTypeTexasHoldem,
+			Status:     models.GameStatusActive,
+			MaxPlayers: hand.MaxPlayers,
+			MinPlayers: 2,
+			SmallBlind: hand.SmallBlind,
+			BigBlind:   hand.BigBlind,
+			BuyIn:      hand.BuyIn,
+			StartedAt:  &hand.StartedAt,
+		},
+		HandNumber: hand.HandNumber,
+		Pot:        hand.Pot,
+		Hand:       record,
+		Histories:  histories,
+	}
+	for _, player := range hand.Players {
+		if player.Bot {
+			continue
+		}
+		// Histories has already checked every player has a UUID
+		result.Players = append(result.Players, PlayerResult{
+			UserID:        uuid.MustParse(player.ID),
+			SeatPosition:  player.SeatPosition,
+			StartingChips: player.StartingChips,
+			EndingChips:   player.EndingChips,
+			Won:           player.AmountWon > 0,
+			Folded:        player.Folded,
+		})
+	}
+	return result, nil
+}
+
+// participation is the row a player's result is added to, stored with the
+// hand if their seat has not been stored yet. Chips held before a player's
+// first hand are their buy-in.
+func (p PlayerResult) participation(gameID uuid.UUID) *models.GameParticipation {
+	return &models.GameParticipation{
+		GameID:       gameID,
+		UserID:       p.UserID,
+		SeatPosition: p.SeatPosition,
+		BuyInAmount:  p.StartingChips,
+		CurrentChips: p.StartingChips,
+		IsActive:     true,
+	}
+}
+
+// stats builds the updates the hand makes to the player's participation
+func (p PlayerResult) stats() map[string]interface{} {
+	stats := map[string]interface{}{
+		"current_chips": p.EndingChips,
+		"hands_played":  gorm.Expr("hands_played + ?", 1),
+	}
+	if p.Won {
+		stats["hands_won"] = gorm.Expr("hands_won + ?", 1)
+	}
+	if p.Folded {
+		stats["hands_folded"] = gorm.Expr("hands_folded + ?", 1)
+	}
+
+	net := p.EndingChips - p.StartingChips
+	if net > 0 {
+		stats["total_winnings"] = gorm.Expr("total_winnings + ?", net)
+	} else if net < 0 {
+		stats["total_losses"] = gorm.Expr("total_losses + ?", -net)
+	}
+	return stats
+}
+
+// settle stores a hand's result in one unit of work: the hand is claimed,
+// then its record, history, metric totals, participations and table totals are
+// written, so a failure part way through leaves no trace of the hand. It
+// reports false for a hand that was settled before.
+func (w *Writer) settle(result *HandResult) (bool, error) {
+	gameID := result.Game.ID
+
+	var settled bool
+	err := retrySerializable(func() error {
+		settled = false
+		return w.work.UnitOfWork(context.Background(), func(uow *database.UnitOfWork) error {
+			claimed, err := uow.Hands().ClaimSettlement(gameID, result.HandNumber)
+			if err != nil {
+				return fmt.Errorf("failed to claim hand: %w", err)
+			}
+			if !claimed {
+				return nil
+			}
+
+			// Tables opened without a store are only stored once their
+			// first hand completes
+			games := uow.Games()
+			if err := games.EnsureExists(result.Game); err != nil {
+				return fmt.Errorf("failed to store table: %w", err)
+			}
+			if err := games.UpdateGameState(gameID, map[string]interface{}{
+				"total_hands": gorm.Expr("total_hands + ?", 1),
+				"total_pot":   gorm.Expr("total_pot + ?", result.Pot),
+			}); err != nil {
+				return fmt.Errorf("failed to update table totals: %w", err)
+			}
+
+			hands := uow.Hands()
+			if err := hands.CreateHand(result.Hand); err != nil {
+				return fmt.Errorf("failed to write hand: %w", err)
+			}
+			if err := hands.CreateBatch(result.Histories); err != nil {
+				return fmt.Errorf("failed to write hand histories: %w", err)
+			}
+			for i := range result.Histories {
+				history := &result.Histories[i]
+				history.Hand = result.Hand
+				if err := w.aggregator.AddHand(uow.Tx(), history); err != nil {
+					return fmt.Errorf("failed to add hand to totals of %s: %w", history.UserID, err)
+				}
+			}
+
+			for _, player := range result.Players {
+				if err := settlePlayer(games, gameID, player); err != nil {
+					return err
+				}
+			}
+
+			settled = true
+			return nil
+		})
+	})
+	return settled, err
+}
+
+// settlePlayer adds a hand to the participation of a player dealt in. The
+// table may have stored them leaving, even closed, before the hand is
+// settled, so the hand goes to their latest participation whether or not
+// they are still seated; one is only stored if they have none.
+func settlePlayer(games *repository.GameRepository, gameID uuid.UUID, player PlayerResult) error {
+	updated, err := games.UpdateLastParticipation(gameID, player.UserID, player.stats())
+	if err != nil {
+		return fmt.Errorf("failed to update stack of %s: %w", player.UserID, err)
+	}
+	if updated {
+		return nil
+	}
+
+	if err := games.EnsureParticipation(player.participation(gameID)); err != nil {
+		return fmt.Errorf("failed to store seat of %s: %w", player.UserID, err)
+	}
+	if _, err := games.UpdateLastParticipation(gameID, player.UserID, player.stats()); err != nil {
+		return fmt.Errorf("failed to update stack of %s: %w", player.UserID, err)
+	}
+	return nil
+}
+
+// retrySerializable runs fn until it succeeds, fails for another reason
+// than a serialization failure, or has been tried settleAttempts times
+func retrySerializable(fn func() error) error {
+	backoff := settleBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt == settleAttempts || !isSerializationFailure(err) {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// isSerializationFailure checks if err is Postgres aborting a transaction
+// that clashed with a concurrent one (a serialization failure or deadlock),
+// which is safe to run again
+func isSerializationFailure(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (pgErr.Code == "40001" || pgErr.Code == "40P01")
+}
@@ -0,0 +1,258 @@
+package handrecord
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/primoPoker/server/internal/database"
+	"github.com/primoPoker/server/internal/game"
+	"github.com/primoPoker/server/internal/metrics"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/repository"
+	"github.com/primoPoker/server/internal/testutil"
+	"github.com/primoPoker/server/pkg/poker"
+)
+
+// settlement is a writer settling hands directly, without its goroutine
+type settlement struct {
+	db      *gorm.DB
+	writer  *Writer
+	games   *repository.GameRepository
+	gameID  uuid.UUID
+	players []string
+	written writtenUsers
+}
+
+func newSettlement(t *testing.T) *settlement {
+	t.Helper()
+
+	db := testutil.NewDB(t, &models.User{}, &models.Game{}, &models.GameParticipation{}, &models.Hand{}, &models.HandHistory{}, &models.HandSettlement{}, &models.PlayerStatAggregate{})
+	hands := repository.NewHandHistoryRepository(db)
+	s := &settlement{
+		db:      db,
+		games:   repository.NewGameRepository(db),
+		gameID:  uuid.New(),
+		players: []string{uuid.New().String(), uuid.New().String()},
+	}
+	s.writer = NewWriter(database.NewReplicatedDB(db, nil), metrics.NewAggregator(repository.NewPlayerStatRepository(db), hands, 0), 16)
+	s.writer.SetListener(&s.written)
+	return s
+}
+
+// foldedHand is a heads-up hand the button folds, giving the blinds to the
+// big blind
+func (s *settlement) foldedHand() game.CompletedHand {
+	start := time.Date(2026, 5, 1, 20, 0, 0, 0, time.UTC)
+	return game.CompletedHand{
+		GameID:     s.gameID.String(),
+		TableName:  "Settled",
+		HandNumber: 1,
+		SmallBlind: 50,
+		BigBlind:   100,
+		BuyIn:      10000,
+		MaxPlayers: 6,
+		Pot:        150,
+		Actions: []game.Action{
+			{PlayerID: s.players[0], Action: game.Fold, Phase: game.PreFlop, Time: start, ChipsBefore: 9950, ChipsAfter: 9950},
+		},
+		Players: []game.HandPlayer{
+			{
+				ID: s.players[0], Username: "alice", SeatPosition: 0, Position: poker.PositionButton,
+				HoleCards:     []poker.Card{poker.NewCard(poker.Seven, poker.Clubs), poker.NewCard(poker.Two, poker.Hearts)},
+				StartingChips: 10000, EndingChips: 9950, Folded: true,
+			},
+			{
+				ID: s.players[1], Username: "bob", SeatPosition: 1, Position: poker.PositionBB,
+				HoleCards:     []poker.Card{poker.NewCard(poker.Ace, poker.Spades), poker.NewCard(poker.King, poker.Spades)},
+				StartingChips: 10000, EndingChips: 10050, AmountWon: 150,
+			},
+		},
+		StartedAt:  start,
+		FinishedAt: start.Add(time.Minute),
+	}
+}
+
+// count returns how many rows a model's table holds
+func (s *settlement) count(t *testing.T, model interface{}) int64 {
+	t.Helper()
+
+	var n int64
+	require.NoError(t, s.db.Model(model).Count(&n).Error)
+	return n
+}
+
+// failOn registers a callback on processor failing statements against table
+// with the error err returns, when it returns one
+func failOn(t *testing.T, processor interface {
+	Register(name string, fn func(*gorm.DB)) error
+}, table string, err func() error) {
+	t.Helper()
+
+	require.NoError(t, processor.Register("test:fail", func(tx *gorm.DB) {
+		if tx.Statement.Table == table {
+			if err := err(); err != nil {
+				tx.AddError(err)
+			}
+		}
+	}))
+}
+
+func TestSettleStoresHandOnce(t *testing.T) {
+	s := newSettlement(t)
+	hand := s.foldedHand()
+
+	s.writer.write(hand)
+	s.writer.write(hand)
+
+	assert.Equal(t, int64(2), s.count(t, &models.HandHistory{}), "a replayed hand is not written again")
+	assert.Equal(t, int64(1), s.count(t, &models.Hand{}))
+	assert.Equal(t, int64(1), s.count(t, &models.HandSettlement{}))
+	assert.Len(t, s.written.users, 2, "listeners hear of the hand once")
+
+	record, err := s.games.GetByID(s.gameID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, record.TotalHands)
+	assert.Equal(t, int64(150), record.TotalPot)
+
+	var stored models.Hand
+	require.NoError(t, s.db.First(&stored).Error)
+	assert.Empty(t, stored.Board, "the hand ended before the flop")
+	assert.Equal(t, int64(150), stored.Pot)
+	assert.Equal(t, []uuid.UUID{uuid.MustParse(s.players[1])}, stored.Winners)
+
+	// Seats not yet stored by the table are stored with the hand
+	require.Len(t, record.Participations, 2)
+	for _, p := range record.Participations {
+		assert.Equal(t, 1, p.HandsPlayed)
+		assert.Equal(t, int64(10000), p.BuyInAmount)
+		if p.UserID.String() == s.players[0] {
+			assert.Equal(t, int64(9950), p.CurrentChips)
+			assert.Equal(t, int64(50), p.TotalLosses)
+		} else {
+			assert.Equal(t, int64(10050), p.CurrentChips)
+			assert.Equal(t, 1, p.HandsWon)
+		}
+	}
+}
+
+func TestSettleLeavesNothingOnFailure(t *testing.T) {
+	s := newSettlement(t)
+	hand := s.foldedHand()
+
+	// The hand's last writes fail, after its history is written
+	failOn(t, s.db.Callback().Update().Before("gorm:update"), "game_participations", func() error {
+		return errors.New("disk full")
+	})
+	s.writer.write(hand)
+
+	assert.Zero(t, s.count(t, &models.Hand{}))
+	assert.Zero(t, s.count(t, &models.HandHistory{}))
+	assert.Zero(t, s.count(t, &models.HandSettlement{}), "the hand is not claimed")
+	assert.Zero(t, s.count(t, &models.PlayerStatAggregate{}))
+	assert.Zero(t, s.count(t, &models.GameParticipation{}))
+	assert.Zero(t, s.count(t, &models.Game{}))
+	assert.Empty(t, s.written.users)
+
+	// Replaying the hand once the database recovers settles it in full
+	require.NoError(t, s.db.Callback().Update().Remove("test:fail"))
+	s.writer.write(hand)
+
+	assert.Equal(t, int64(2), s.count(t, &models.HandHistory{}))
+	assert.Equal(t, int64(2), s.count(t, &models.PlayerStatAggregate{}))
+	assert.Equal(t, int64(2), s.count(t, &models.GameParticipation{}))
+}
+
+func TestSettleRetriesSerializationFailure(t *testing.T) {
+	defer func(backoff time.Duration) { settleBackoff = backoff }(settleBackoff)
+	settleBackoff = time.Millisecond
+
+	s := newSettlement(t)
+	failures := 2
+	failOn(t, s.db.Callback().Create().Before("gorm:create"), "hand_histories", func() error {
+		if failures == 0 {
+			return nil
+		}
+		failures--
+		return &pgconn.PgError{Code: "40001", Message: "could not serialize access"}
+	})
+	s.writer.write(s.foldedHand())
+
+	assert.Zero(t, failures)
+	assert.Equal(t, int64(2), s.count(t, &models.HandHistory{}), "the third attempt succeeds")
+	assert.Equal(t, int64(1), s.count(t, &models.HandSettlement{}))
+}
+
+func TestSettleGivesUpOnOtherErrors(t *testing.T) {
+	s := newSettlement(t)
+	attempts := 0
+	failOn(t, s.db.Callback().Create().Before("gorm:create"), "hand_histories", func() error {
+		attempts++
+		return errors.New("constraint violated")
+	})
+	s.writer.write(s.foldedHand())
+
+	assert.Equal(t, 1, attempts, "only serialization failures are retried")
+	assert.Zero(t, s.count(t, &models.HandSettlement{}))
+}
+
+func TestSettleAfterPlayersLeave(t *testing.T) {
+	s := newSettlement(t)
+	hand := s.foldedHand()
+
+	// The table is stored closed before its last hand is settled
+	require.NoError(t, s.games.EnsureExists(&models.Game{ID: s.gameID, Name: "Settled", GameType: models.GameTypeTexasHoldem, MaxPlayers: 6}))
+	for i, playerID := range s.players {
+		require.NoError(t, s.games.EnsureParticipation(&models.GameParticipation{
+			GameID: s.gameID, UserID: uuid.MustParse(playerID), SeatPosition: i,
+			BuyInAmount: 10000, CurrentChips: 10000, IsActive: true,
+		}))
+	}
+	require.NoError(t, s.games.LeaveAll(s.gameID))
+	s.writer.write(hand)
+
+	record, err := s.games.GetByID(s.gameID)
+	require.NoError(t, err)
+	require.Len(t, record.Participations, 2, "players who left are not seated again")
+	for _, p := range record.Participations {
+		assert.False(t, p.IsActive)
+		assert.Equal(t, 1, p.HandsPlayed)
+	}
+}
+
+func TestSettleHandAgainstBot(t *testing.T) {
+	s := newSettlement(t)
+	checked := &writtenUsers{}
+	s.writer.SetChecker(checked)
+	hand := s.foldedHand()
+	hand.Players[1].Bot = true
+
+	s.writer.write(hand)
+
+	// Only the player has a row, which still holds the bot's part
+	var rows []models.HandHistory
+	require.NoError(t, s.db.Find(&rows).Error)
+	require.Len(t, rows, 1)
+	assert.Equal(t, s.players[0], rows[0].UserID.String())
+	assert.True(t, rows[0].AgainstBots)
+	assert.Equal(t, 2, rows[0].PlayersDealtIn)
+
+	var stored models.Hand
+	require.NoError(t, s.db.First(&stored).Error)
+	assert.Empty(t, stored.Winners, "a bot is no winner on record")
+
+	record, err := s.games.GetByID(s.gameID)
+	require.NoError(t, err)
+	require.Len(t, record.Participations, 1, "bots have no seat on record")
+	assert.Equal(t, s.players[0], record.Participations[0].UserID.String())
+
+	assert.Zero(t, s.count(t, &models.PlayerStatAggregate{}), "hands against bots are left out of metrics")
+	assert.Empty(t, checked.checked, "no achievements are earned against bots")
+	assert.Equal(t, []string{s.players[0]}, s.written.users)
+}
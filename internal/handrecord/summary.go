@@ -0,0 +1,85 @@
+package handrecord
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/primoPoker/server/internal/game"
+	"github.com/primoPoker/server/pkg/poker"
+)
+
+// rankWords names each rank, singular and plural, as players say them
+var rankWords = map[poker.Rank][2]string{
+	poker.Two: {"two", "twos"}, poker.Three: {"three", "threes"}, poker.Four: {"four", "fours"},
+	poker.Five: {"five", "fives"}, poker.Six: {"six", "sixes"}, poker.Seven: {"seven", "sevens"},
+	poker.Eight: {"eight", "eights"}, poker.Nine: {"nine", "nines"}, poker.Ten: {"ten", "tens"},
+	poker.Jack: {"jack", "jacks"}, poker.Queen: {"queen", "queens"}, poker.King: {"king", "kings"},
+	poker.Ace: {"ace", "aces"},
+}
+
+// summary tells a completed hand in words, for free-text search: the
+// board, then what each player did with it and what they won or lost. Hole
+// cards are only named for players who showed them down.
+func summary(hand game.CompletedHand, board []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Hand #%d at %s, blinds %d/%d.", hand.HandNumber, hand.TableName, hand.SmallBlind, hand.BigBlind)
+	if len(board) > 0 {
+		fmt.Fprintf(&b, " Board %s.", strings.Join(board, " "))
+	}
+
+	foldedIn := make(map[string]game.GamePhase)
+	for _, action := range hand.Actions {
+		if action.Action == game.Fold {
+			foldedIn[action.PlayerID] = action.Phase
+		}
+	}
+
+	for _, player := range hand.Players {
+		fmt.Fprintf(&b, " %s", player.Username)
+		switch {
+		case player.WentToShowdown && len(player.HoleCards) == 2:
+			cards := make([]string, len(player.HoleCards))
+			for i, card := range player.HoleCards {
+				cards[i] = card.Code()
+			}
+			fmt.Fprintf(&b, " showed %s, %s", strings.Join(cards, " "), holeCardWords(player.HoleCards))
+			if player.BestHand != nil {
+				fmt.Fprintf(&b, ", for %s", player.BestHand.Rank)
+			}
+		case player.Folded:
+			fmt.Fprintf(&b, " folded on the %s", strings.ReplaceAll(string(handPhase(foldedIn[player.ID])), "_", "-"))
+		}
+
+		switch net := player.EndingChips - player.StartingChips; {
+		case player.AmountWon > 0 && !player.WentToShowdown:
+			fmt.Fprintf(&b, " and won %d uncontested.", player.AmountWon)
+		case player.AmountWon > 0:
+			fmt.Fprintf(&b, " and won %d.", player.AmountWon)
+		case net < 0:
+			fmt.Fprintf(&b, " and lost %d.", -net)
+		default:
+			b.WriteString(".")
+		}
+	}
+
+	fmt.Fprintf(&b, " Pot %d.", hand.Pot)
+	return b.String()
+}
+
+// holeCardWords names two hole cards as players do: "pocket aces", "ace
+// king suited" or "ten nine offsuit"
+func holeCardWords(cards []poker.Card) string {
+	high, low := cards[0], cards[1]
+	if low.Rank > high.Rank {
+		high, low = low, high
+	}
+	if high.Rank == low.Rank {
+		return "pocket " + rankWords[high.Rank][1]
+	}
+
+	suited := "offsuit"
+	if high.Suit == low.Suit {
+		suited = "suited"
+	}
+	return rankWords[high.Rank][0] + " " + rankWords[low.Rank][0] + " " + suited
+}
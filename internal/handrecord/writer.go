@@ -0,0 +1,164 @@
+// Package handrecord persists hands played at live tables as HandHistory
+// rows. The game engine reports each completed hand to a Writer, which
+// settles it from its own goroutine so play never waits on the database.
+package handrecord
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/primoPoker/server/internal/database"
+	"github.com/primoPoker/server/internal/game"
+	"github.com/primoPoker/server/internal/metrics"
+	"github.com/primoPoker/server/internal/models"
+)
+
+// DefaultQueueSize is how many completed hands may wait to be written
+const DefaultQueueSize = 1024
+
+// Listener is told whose hands have just been stored, e.g. to drop
+// statistics calculated without them
+type Listener interface {
+	HandsWritten(userIDs []uuid.UUID)
+}
+
+// HandChecker is given each hand once it is stored, e.g. to award
+// achievements. It is called from the writer's goroutine.
+type HandChecker interface {
+	CheckHand(hand *models.HandHistory)
+}
+
+// Work runs a function in a database.UnitOfWork. *database.DB is one.
+type Work interface {
+	UnitOfWork(ctx context.Context, fn func(uow *database.UnitOfWork) error) error
+}
+
+// Writer stores completed hands in the background. It implements
+// game.HandObserver.
+type Writer struct {
+	work       Work
+	aggregator *metrics.Aggregator
+	listener   Listener
+	checker    HandChecker
+
+	mu     sync.RWMutex
+	queue  chan game.CompletedHand
+	closed bool
+	done   chan struct{}
+}
+
+// NewWriter creates a writer holding up to queueSize hands in memory. Each
+// hand is settled in a unit of work and added to its players' metric totals
+// through aggregator as it is stored.
+func NewWriter(work Work, aggregator *metrics.Aggregator, queueSize int) *Writer {
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+	return &Writer{
+		work:       work,
+		aggregator: aggregator,
+		queue:      make(chan game.CompletedHand, queueSize),
+		done:       make(chan struct{}),
+	}
+}
+
+// SetListener registers a listener for stored hands. It must be called
+// before Run.
+func (w *Writer) SetListener(listener Listener) {
+	w.listener = listener
+}
+
+// SetChecker registers a checker for each stored hand. It must be called
+// before Run.
+func (w *Writer) SetChecker(checker HandChecker) {
+	w.checker = checker
+}
+
+// Run writes queued hands until the writer is closed
+func (w *Writer) Run() {
+	defer close(w.done)
+	for hand := range w.queue {
+		w.write(hand)
+	}
+}
+
+// HandCompleted queues a hand to be written. It never blocks: when the
+// queue is full the hand is dropped and logged.
+func (w *Writer) HandCompleted(hand game.CompletedHand) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.closed {
+		return
+	}
+
+	select {
+	case w.queue <- hand:
+	default:
+		logrus.WithFields(logrus.Fields{
+			"game_id":     hand.GameID,
+			"hand_number": hand.HandNumber,
+		}).Error("Hand history queue is full, dropping hand")
+	}
+}
+
+// Close stops accepting hands and waits for the queued ones to be written
+func (w *Writer) Close() {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+
+	<-w.done
+}
+
+// write settles one hand, with one history row per player dealt in
+func (w *Writer) write(hand game.CompletedHand) {
+	log := logrus.WithFields(logrus.Fields{
+		"game_id":     hand.GameID,
+		"hand_number": hand.HandNumber,
+	})
+
+	recordID := hand.GameID
+	if hand.RecordID != "" {
+		recordID = hand.RecordID
+	}
+	gameID, err := uuid.Parse(recordID)
+	if err != nil {
+		log.Warn("Not recording hand of a table without a UUID")
+		return
+	}
+
+	result, err := NewHandResult(gameID, hand)
+	if err != nil {
+		log.WithError(err).Error("Failed to build hand history")
+		return
+	}
+	settled, err := w.settle(result)
+	if err != nil {
+		log.WithError(err).Error("Failed to settle hand")
+		return
+	}
+	if !settled {
+		log.Warn("Not recording hand that was already settled")
+		return
+	}
+
+	written := make([]uuid.UUID, 0, len(result.Histories))
+	for i := range result.Histories {
+		written = append(written, result.Histories[i].UserID)
+		// Achievements are not earned against bots
+		if w.checker != nil && !result.Histories[i].AgainstBots {
+			w.checker.CheckHand(&result.Histories[i])
+		}
+	}
+
+	if w.listener != nil && len(written) > 0 {
+		w.listener.HandsWritten(written)
+	}
+}
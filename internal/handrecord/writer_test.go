@@ -0,0 +1,230 @@
+package handrecord
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"context"
+	"github.com/primoPoker/server/internal/database"
+	"github.com/primoPoker/server/internal/game"
+	"github.com/primoPoker/server/internal/handexport"
+	"github.com/primoPoker/server/internal/metrics"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/repository"
+	"github.com/primoPoker/server/internal/testutil"
+	"github.com/primoPoker/server/pkg/poker"
+)
+
+// table is a heads-up table whose hands are recorded by a writer
+type table struct {
+	manager *game.Manager
+	game    *game.Game
+	writer  *Writer
+	hands   *repository.HandHistoryRepository
+	games   *repository.GameRepository
+	stats   *repository.PlayerStatRepository
+	players []string
+	written writtenUsers
+}
+
+// writtenUsers records the users a writer reports new hands for and the
+// hands it has checked
+type writtenUsers struct {
+	mu      sync.Mutex
+	users   []string
+	checked []uuid.UUID
+}
+
+func (u *writtenUsers) HandsWritten(userIDs []uuid.UUID) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for _, userID := range userIDs {
+		u.users = append(u.users, userID.String())
+	}
+}
+
+func (u *writtenUsers) CheckHand(hand *models.HandHistory) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.checked = append(u.checked, hand.ID)
+}
+
+func newTable(t *testing.T) *table {
+	t.Helper()
+
+	db := testutil.NewDB(t, &models.User{}, &models.Game{}, &models.GameParticipation{}, &models.Hand{}, &models.HandHistory{}, &models.HandSettlement{}, &models.PlayerStatAggregate{})
+	tbl := &table{
+		manager: game.NewManager(),
+		hands:   repository.NewHandHistoryRepository(db),
+		games:   repository.NewGameRepository(db),
+		stats:   repository.NewPlayerStatRepository(db),
+		players: []string{uuid.New().String(), uuid.New().String()},
+	}
+	tbl.writer = NewWriter(database.NewReplicatedDB(db, nil), metrics.NewAggregator(tbl.stats, tbl.hands, 0), 16)
+	tbl.writer.SetListener(&tbl.written)
+	tbl.writer.SetChecker(&tbl.written)
+	go tbl.writer.Run()
+	tbl.manager.SetHandObserver(tbl.writer)
+
+	var err error
+	tbl.game, err = tbl.manager.CreateGame(uuid.New().String(), "Recorded")
+	require.NoError(t, err)
+	require.NoError(t, tbl.manager.JoinGame(context.Background(), tbl.game.ID, tbl.players[0], "alice", 10000))
+	require.NoError(t, tbl.manager.JoinGame(context.Background(), tbl.game.ID, tbl.players[1], "bob", 10000))
+
+	return tbl
+}
+
+// current returns the ID of the player whose turn it is
+func (tbl *table) current() string {
+	return tbl.game.GetGameState("").CurrentPlayer
+}
+
+// rows waits for the writer and returns the table's recorded hands
+func (tbl *table) rows(t *testing.T) []models.HandHistory {
+	t.Helper()
+
+	tbl.writer.Close()
+	rows, err := tbl.hands.GetGameHandHistory(uuid.MustParse(tbl.game.ID))
+	require.NoError(t, err)
+	return rows
+}
+
+func TestWriterRecordsFoldedHand(t *testing.T) {
+	tbl := newTable(t)
+
+	folder := tbl.current()
+	require.NoError(t, tbl.manager.ProcessAction(context.Background(), tbl.game.ID, folder, game.Fold, 0))
+
+	rows := tbl.rows(t)
+	require.Len(t, rows, 2)
+
+	var net int64
+	for _, row := range rows {
+		net += row.NetResult
+		assert.Equal(t, 1, row.HandNumber)
+		assert.Equal(t, "Recorded", row.TableName)
+		assert.Equal(t, int64(150), row.PotSize)
+		assert.False(t, row.WentToShowdown)
+		assert.NotEmpty(t, row.HoleCard1Rank)
+		assert.Empty(t, row.FlopCard1Rank)
+		require.Len(t, row.PreFlopActions, 1)
+		assert.Equal(t, models.ActionFold, row.PreFlopActions[0].Action)
+		assert.Zero(t, row.VPIPPercent, "nobody put chips in voluntarily")
+
+		// Heads-up the button posts the small blind and acts first pre-flop
+		assert.Equal(t, string(poker.PositionButton), row.PreFlopActions[0].Position)
+
+		if row.UserID.String() == folder {
+			assert.Equal(t, string(poker.PositionButton), row.Position)
+			assert.False(t, row.IsWinner)
+			assert.Equal(t, models.HandPhasePreFlop, row.FoldedPhase)
+		} else {
+			assert.Equal(t, string(poker.PositionBB), row.Position)
+			assert.True(t, row.IsWinner)
+			assert.Equal(t, int64(150), row.AmountWon)
+			assert.Empty(t, row.FoldedPhase)
+		}
+	}
+	assert.Zero(t, net, "chips won and lost must balance")
+	assert.ElementsMatch(t, tbl.players, []string{rows[0].UserID.String(), rows[1].UserID.String()})
+	assert.ElementsMatch(t, tbl.players, tbl.written.users)
+	assert.ElementsMatch(t, []uuid.UUID{rows[0].ID, rows[1].ID}, tbl.written.checked, "each stored hand is checked")
+
+	require.NotNil(t, rows[0].Hand)
+	assert.Contains(t, rows[0].Hand.Summary, "Hand #1 at Recorded, blinds 50/100.")
+	assert.Contains(t, rows[0].Hand.Summary, "folded on the pre-flop and lost 50.")
+	assert.Contains(t, rows[0].Hand.Summary, "won 150 uncontested.")
+	assert.NotContains(t, rows[0].Hand.Summary, "showed", "hole cards not shown down stay private")
+
+	stored, err := tbl.games.GetByID(uuid.MustParse(tbl.game.ID))
+	require.NoError(t, err)
+	assert.Equal(t, "Recorded", stored.Name)
+
+	// Each player's totals took the hand in as it was stored
+	var won int
+	for _, player := range tbl.players {
+		aggregates, err := tbl.stats.GetUserAggregates(uuid.MustParse(player), time.Time{})
+		require.NoError(t, err)
+		require.Len(t, aggregates, 1)
+		assert.Equal(t, 1, aggregates[0].Hands)
+		assert.Equal(t, int64(100), aggregates[0].BigBlind)
+		assert.Equal(t, models.GameTypeTexasHoldem, aggregates[0].GameType)
+		won += aggregates[0].HandsWon
+	}
+	assert.Equal(t, 1, won)
+}
+
+func TestWriterRecordsShowdown(t *testing.T) {
+	tbl := newTable(t)
+
+	// Check or call every street down to the river
+	for i := 0; i < 20; i++ {
+		phase := tbl.game.GetGameState("").Phase
+		if phase == game.Showdown || phase == game.GameOver {
+			break
+		}
+		player := tbl.current()
+		if err := tbl.manager.ProcessAction(context.Background(), tbl.game.ID, player, game.Check, 0); err != nil {
+			require.NoError(t, tbl.manager.ProcessAction(context.Background(), tbl.game.ID, player, game.Call, 0))
+		}
+	}
+
+	rows := tbl.rows(t)
+	require.Len(t, rows, 2)
+
+	var net, won int64
+	for _, row := range rows {
+		net += row.NetResult
+		won += row.AmountWon
+		assert.Equal(t, int64(200), row.PotSize)
+		assert.True(t, row.WentToShowdown)
+		assert.Empty(t, row.FoldedPhase)
+		assert.NotEmpty(t, row.RiverCardRank)
+		assert.NotEmpty(t, row.HandRank)
+		assert.NotEmpty(t, row.BestHand)
+		assert.NotEmpty(t, row.PreFlopActions)
+		assert.NotEmpty(t, row.RiverActions)
+		assert.Equal(t, models.ActionCall, row.PreFlopActions[0].Action)
+		assert.Equal(t, int64(50), row.PreFlopActions[0].Amount)
+		if row.UserID == row.PreFlopActions[0].PlayerID {
+			assert.Equal(t, float64(100), row.VPIPPercent, "completing the small blind is voluntary")
+		} else {
+			assert.Zero(t, row.VPIPPercent, "checking the big blind is not")
+		}
+		assert.Zero(t, row.PFRPercent)
+	}
+	assert.Zero(t, net, "chips won and lost must balance")
+	assert.Equal(t, int64(200), won)
+
+	// Both rows share one table-level record of the hand
+	require.NotNil(t, rows[0].Hand)
+	assert.Equal(t, rows[0].HandID, rows[1].HandID)
+	assert.Len(t, rows[0].Hand.BoardCards(), 5)
+	assert.Equal(t, int64(200), rows[0].Hand.Pot)
+	assert.Equal(t, 2, rows[0].Hand.PlayersDealtIn)
+	assert.NotEmpty(t, rows[0].Hand.Winners)
+	assert.Equal(t, handexport.FormatCard(rows[0].RiverCardRank, rows[0].RiverCardSuit), rows[0].Hand.BoardCards()[4])
+	assert.Contains(t, rows[0].Hand.Summary, " Board "+rows[0].Hand.Board+".")
+	for _, row := range rows {
+		holeCards := handexport.FormatCard(row.HoleCard1Rank, row.HoleCard1Suit) + " " + handexport.FormatCard(row.HoleCard2Rank, row.HoleCard2Suit)
+		assert.Contains(t, rows[0].Hand.Summary, " showed "+holeCards+", ", "hole cards shown down are named")
+	}
+}
+
+func TestWriterIgnoresHandsAfterClose(t *testing.T) {
+	tbl := newTable(t)
+	tbl.writer.Close()
+
+	require.NoError(t, tbl.manager.ProcessAction(context.Background(), tbl.game.ID, tbl.current(), game.Fold, 0))
+
+	rows, err := tbl.hands.GetGameHandHistory(uuid.MustParse(tbl.game.ID))
+	require.NoError(t, err)
+	assert.Empty(t, rows)
+	assert.Empty(t, tbl.written.users)
+}
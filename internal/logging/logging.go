@@ -0,0 +1,70 @@
+// Package logging ties log entries to the request they were written for.
+// Each request carries an ID, from its X-Request-ID header or generated,
+// that is stored in its context; entries from FromContext carry it, so a
+// request can be followed through the HTTP log, handlers and WebSocket.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"runtime/debug"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Header carries a request's ID in and out
+const Header = "X-Request-ID"
+
+// requestIDKey is the context key of the request ID
+type requestIDKey struct{}
+
+// validID matches the IDs taken from clients; anything else is replaced,
+// so a client cannot write arbitrary text into the logs
+var validID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// NewRequestID returns id if it is usable as a request ID, or a new one
+func NewRequestID(id string) string {
+	if validID.MatchString(id) {
+		return id
+	}
+	return uuid.NewString()
+}
+
+// NewContext returns ctx carrying a request ID
+func NewContext(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID returns the request ID in ctx, or an empty string
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// FromContext returns a log entry carrying the request ID in ctx and, once
+// the request is authenticated, the user making it
+func FromContext(ctx context.Context) *logrus.Entry {
+	fields := logrus.Fields{}
+	if requestID := RequestID(ctx); requestID != "" {
+		fields["request_id"] = requestID
+	}
+	if userID, ok := ctx.Value("user_id").(string); ok && userID != "" {
+		fields["user_id"] = userID
+	}
+	return logrus.WithFields(fields)
+}
+
+// StackField is the field holding the stack of a recovered panic
+const StackField = "stack"
+
+// Panic logs a panic recovered by log's caller as an error, with the stack
+// of the goroutine that panicked. It must be called from the deferred
+// function that recovered, while that stack is still there.
+func Panic(log *logrus.Entry, recovered interface{}) {
+	log.WithFields(logrus.Fields{
+		"panic":    fmt.Sprint(recovered),
+		StackField: string(debug.Stack()),
+	}).Error("Recovered from panic")
+}
@@ -0,0 +1,307 @@
+package metrics
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/repository"
+)
+
+// Aggregator keeps the stored per-day, per-stake totals of each player up
+// to date, so metrics over whole days never re-read their hands
+type Aggregator struct {
+	stats      *repository.PlayerStatRepository
+	hands      *repository.HandHistoryRepository
+	sessionGap time.Duration
+}
+
+// NewAggregator creates an aggregator storing totals through stats. Breaks
+// between hands of sessionGap or more are not counted as table time.
+func NewAggregator(stats *repository.PlayerStatRepository, hands *repository.HandHistoryRepository, sessionGap time.Duration) *Aggregator {
+	return &Aggregator{stats: stats, hands: hands, sessionGap: sessionGap}
+}
+
+// AddHand adds a hand being stored to its player's totals. It runs in the
+// transaction storing the hand, so the totals never miss or double count
+// it. Its table time is measured from the hands already stored, so a hand
+// stored after one that started later may overlap it a little; a backfill
+// evens that out. Hands against bots are not counted.
+func (a *Aggregator) AddHand(tx *gorm.DB, hand *models.HandHistory) error {
+	if hand.AgainstBots {
+		return nil
+	}
+	previous, err := a.hands.GetLastFinishedBeforeWithTransaction(tx, hand.UserID, hand.StartedAt, hand.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get previous hand: %w", err)
+	}
+	var lastFinish time.Time
+	if previous != nil {
+		lastFinish = previous.FinishedAt
+	}
+
+	var added tally
+	added.addHand(hand, tableTime(hand, lastFinish, a.sessionGap))
+
+	aggregate, err := a.stats.LockAggregate(tx, aggregateKey(hand.UserID, hand.StartedAt, handStake(hand)))
+	if err != nil {
+		return fmt.Errorf("failed to lock player totals: %w", err)
+	}
+
+	totals := tallyFromAggregate(aggregate)
+	totals.merge(&added)
+	totals.storeIn(aggregate)
+	return a.stats.SaveAggregate(tx, aggregate)
+}
+
+// Backfill rebuilds every player's totals from their hand history,
+// reading batchSize hands at a time. Hands stored while it runs may be
+// missed, so it is meant for when no tables are being played.
+func (a *Aggregator) Backfill(batchSize int) (int, error) {
+	userIDs, err := a.hands.GetPlayerIDs()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list players: %w", err)
+	}
+
+	for i, userID := range userIDs {
+		if err := a.RebuildUser(userID, batchSize); err != nil {
+			return i, fmt.Errorf("failed to rebuild totals of %s: %w", userID, err)
+		}
+	}
+	return len(userIDs), nil
+}
+
+// RebuildUser recalculates a player's totals from their hand history,
+// archived hands included
+func (a *Aggregator) RebuildUser(userID uuid.UUID, batchSize int) error {
+	type dayStake struct {
+		day   time.Time
+		stake stakeKey
+	}
+	tallies := make(map[dayStake]*tally)
+	var lastFinish time.Time
+
+	addBatch := func(batch []models.HandHistory) error {
+		for i := range batch {
+			if batch[i].AgainstBots {
+				continue
+			}
+			key := dayStake{day: dayStart(batch[i].StartedAt), stake: handStake(&batch[i])}
+			t, ok := tallies[key]
+			if !ok {
+				t = &tally{}
+				tallies[key] = t
+			}
+			t.addHand(&batch[i], tableTime(&batch[i], lastFinish, a.sessionGap))
+			if batch[i].FinishedAt.After(lastFinish) {
+				lastFinish = batch[i].FinishedAt
+			}
+		}
+		return nil
+	}
+
+	// Archived hands are older than any still live, so walking the
+	// archive first keeps the hands in order
+	now := time.Now()
+	if err := a.hands.StreamUserArchivedHands(userID, time.Time{}, now, batchSize, addBatch); err != nil {
+		return err
+	}
+	if err := a.hands.StreamUserHands(userID, time.Time{}, now, batchSize, addBatch); err != nil {
+		return err
+	}
+
+	aggregates := make([]models.PlayerStatAggregate, 0, len(tallies))
+	for key, t := range tallies {
+		aggregate := aggregateKey(userID, key.day, key.stake)
+		t.storeIn(&aggregate)
+		aggregates = append(aggregates, aggregate)
+	}
+	return a.stats.ReplaceUserAggregates(userID, aggregates)
+}
+
+// calculateFromAggregates calculates a user's metrics since the given time
+// from their stored totals. Only the hands on the part day before the
+// first whole one are read for the totals, though the variance walks every
+// hand in the period; with no since every hand ever played counts.
+func (s *Service) calculateFromAggregates(userID uuid.UUID, username string, since *time.Time, filter Filter) (*PlayerMetrics, error) {
+	var from time.Time
+	var hands []models.HandHistory
+	if since != nil {
+		from = dayStart(*since)
+		if from.Before(*since) {
+			from = from.AddDate(0, 0, 1)
+
+			partDay, err := s.handHistoryRepo.GetHandsByTimeRange(userID, *since, from)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get hand history: %w", err)
+			}
+			for _, hand := range partDay {
+				if hand.StartedAt.Before(from) {
+					hands = append(hands, hand)
+				}
+			}
+		}
+	}
+
+	aggregates, err := s.aggregates.GetUserAggregates(userID, from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get player totals: %w", err)
+	}
+
+	var overall tally
+	stakes := make(map[stakeKey]*tally)
+	for i := range aggregates {
+		stake := stakeKey{
+			gameType:   aggregates[i].GameType,
+			smallBlind: aggregates[i].SmallBlind,
+			bigBlind:   aggregates[i].BigBlind,
+		}
+		if !filter.matchesStake(stake) {
+			continue
+		}
+		totals := tallyFromAggregate(&aggregates[i])
+		overall.merge(&totals)
+		stakeTally(stakes, stake).merge(&totals)
+	}
+	hands = filter.apply(hands)
+	times := tableTimes(hands, s.sessionGap)
+	for i := range hands {
+		overall.addHand(&hands[i], times[i])
+		stakeTally(stakes, handStake(&hands[i])).addHand(&hands[i], times[i])
+	}
+
+	if overall.hands == 0 {
+		return s.emptyMetrics(userID, username, since), nil
+	}
+
+	// Streaks and downswings follow the order hands were played in, which
+	// the totals do not keep
+	metrics := overall.metrics(userID, username, since)
+	metrics.Variance, err = s.streamVariance(userID, since, filter)
+	if err != nil {
+		return nil, err
+	}
+	metrics.Stakes = stakeMetrics(userID, username, stakes, since)
+	return metrics, nil
+}
+
+// dayStart returns the start of the UTC day containing t
+func dayStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// aggregateKey returns an empty totals row for a user's hands at a stake
+// on the day containing at
+func aggregateKey(userID uuid.UUID, at time.Time, stake stakeKey) models.PlayerStatAggregate {
+	return models.PlayerStatAggregate{
+		UserID:     userID,
+		Day:        dayStart(at),
+		GameType:   stake.gameType,
+		SmallBlind: stake.smallBlind,
+		BigBlind:   stake.bigBlind,
+	}
+}
+
+// tallyFromAggregate reads stored totals
+func tallyFromAggregate(a *models.PlayerStatAggregate) tally {
+	return tally{
+		hands:       a.Hands,
+		handsWon:    a.HandsWon,
+		handsFolded: a.HandsFolded,
+		firstHandAt: a.FirstHandAt,
+
+		showdowns:           a.Showdowns,
+		showdownsWon:        a.ShowdownsWon,
+		wonDollarAtShowdown: a.WonDollarAtShowdown,
+		flopsSeen:           a.FlopsSeen,
+		flopShowdowns:       a.FlopShowdowns,
+		flopShowdownsWon:    a.FlopShowdownsWon,
+		wonWhenSawFlop:      a.WonWhenSawFlop,
+
+		totalWagered:  a.TotalWagered,
+		totalWon:      a.TotalWon,
+		biggestWin:    a.BiggestWin,
+		biggestLoss:   a.BiggestLoss,
+		biggestPotWon: a.BiggestPotWon,
+		potSizeSum:    a.PotSizeSum,
+		bigBlindsWon:  a.BigBlindsWon,
+		rakePaid:      a.RakePaid,
+		tableTime:     a.TableTime,
+
+		counts: actionCounts{
+			vpip:             a.VPIPHands,
+			pfr:              a.PFRHands,
+			threeBetChances:  a.ThreeBetChances,
+			threeBets:        a.ThreeBets,
+			facedThreeBet:    a.FacedThreeBet,
+			foldsToThreeBet:  a.FoldsToThreeBet,
+			fourBetChances:   a.FourBetChances,
+			fourBets:         a.FourBets,
+			facedFourBet:     a.FacedFourBet,
+			foldsToFourBet:   a.FoldsToFourBet,
+			stealChances:     a.StealChances,
+			steals:           a.Steals,
+			facedSteal:       a.FacedSteal,
+			foldsToSteal:     a.FoldsToSteal,
+			threeBetsVsSteal: a.ThreeBetsVsSteal,
+			bigBlindHands:    a.BigBlindHands,
+			walks:            a.Walks,
+			cBets:            a.CBets,
+			foldToCBets:      a.FoldToCBets,
+			aggressive:       a.AggressiveActions,
+			passive:          a.PassiveActions,
+		},
+	}
+}
+
+// storeIn writes the totals into a row, leaving its key alone
+func (t *tally) storeIn(a *models.PlayerStatAggregate) {
+	a.Hands = t.hands
+	a.HandsWon = t.handsWon
+	a.HandsFolded = t.handsFolded
+	a.FirstHandAt = t.firstHandAt
+
+	a.Showdowns = t.showdowns
+	a.ShowdownsWon = t.showdownsWon
+	a.WonDollarAtShowdown = t.wonDollarAtShowdown
+	a.FlopsSeen = t.flopsSeen
+	a.FlopShowdowns = t.flopShowdowns
+	a.FlopShowdownsWon = t.flopShowdownsWon
+	a.WonWhenSawFlop = t.wonWhenSawFlop
+
+	a.TotalWagered = t.totalWagered
+	a.TotalWon = t.totalWon
+	a.BiggestWin = t.biggestWin
+	a.BiggestLoss = t.biggestLoss
+	a.BiggestPotWon = t.biggestPotWon
+	a.PotSizeSum = t.potSizeSum
+	a.BigBlindsWon = t.bigBlindsWon
+	a.RakePaid = t.rakePaid
+	a.TableTime = t.tableTime
+
+	a.VPIPHands = t.counts.vpip
+	a.PFRHands = t.counts.pfr
+	a.ThreeBetChances = t.counts.threeBetChances
+	a.ThreeBets = t.counts.threeBets
+	a.FacedThreeBet = t.counts.facedThreeBet
+	a.FoldsToThreeBet = t.counts.foldsToThreeBet
+	a.FourBetChances = t.counts.fourBetChances
+	a.FourBets = t.counts.fourBets
+	a.FacedFourBet = t.counts.facedFourBet
+	a.FoldsToFourBet = t.counts.foldsToFourBet
+	a.StealChances = t.counts.stealChances
+	a.Steals = t.counts.steals
+	a.FacedSteal = t.counts.facedSteal
+	a.FoldsToSteal = t.counts.foldsToSteal
+	a.ThreeBetsVsSteal = t.counts.threeBetsVsSteal
+	a.BigBlindHands = t.counts.bigBlindHands
+	a.Walks = t.counts.walks
+	a.CBets = t.counts.cBets
+	a.FoldToCBets = t.counts.foldToCBets
+	a.AggressiveActions = t.counts.aggressive
+	a.PassiveActions = t.counts.passive
+}
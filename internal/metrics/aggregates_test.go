@@ -0,0 +1,363 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/primoPoker/server/internal/config"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/repository"
+	"github.com/primoPoker/server/internal/testutil"
+	"github.com/primoPoker/server/pkg/poker"
+)
+
+// aggregateFixture is a player's hands stored through the aggregator
+type aggregateFixture struct {
+	service    *Service
+	aggregator *Aggregator
+	stats      *repository.PlayerStatRepository
+	user       models.User
+	hands      []models.HandHistory
+	start      time.Time
+}
+
+// newAggregateFixture stores sixty varied hands over four days at three
+// stakes, adding each to the player's totals as it is written
+func newAggregateFixture(t *testing.T) *aggregateFixture {
+	t.Helper()
+
+	db := testutil.NewDB(t, &models.User{}, &models.Game{}, &models.HandHistory{}, &models.HandSummary{}, &models.PlayerStatAggregate{})
+	hands := repository.NewHandHistoryRepository(db)
+	stats := repository.NewPlayerStatRepository(db)
+
+	cfg := config.MetricsConfig{SessionGap: 45 * time.Minute}
+	f := &aggregateFixture{
+		service:    NewServiceWithCache(hands, stats, repository.NewUserRepository(db), cfg, nil),
+		aggregator: NewAggregator(stats, hands, cfg.SessionGap),
+		stats:      stats,
+		user:       models.User{ID: uuid.New(), Username: "hero", Email: "hero@example.com", PasswordHash: "x"},
+		start:      time.Date(2026, 4, 1, 18, 0, 0, 0, time.UTC),
+	}
+	require.NoError(t, db.Create(&f.user).Error)
+
+	games := []models.Game{
+		{ID: uuid.New(), Name: "Small", GameType: models.GameTypeTexasHoldem, SmallBlind: 50, BigBlind: 100},
+		{ID: uuid.New(), Name: "Big", GameType: models.GameTypeTexasHoldem, SmallBlind: 500, BigBlind: 1000},
+		{ID: uuid.New(), Name: "Omaha", GameType: models.GameTypeOmaha, SmallBlind: 50, BigBlind: 100},
+	}
+	require.NoError(t, db.Create(&games).Error)
+
+	hero, villain, other := f.user.ID, uuid.New(), uuid.New()
+	lines := [][]models.PlayerActionRecord{
+		{seated(poker.PositionCO, act(hero, models.ActionRaise, 300)), act(villain, models.ActionCall, 300)},
+		{act(villain, models.ActionRaise, 300), act(hero, models.ActionRaise, 900), act(villain, models.ActionRaise, 1800), act(hero, models.ActionFold, 0)},
+		{act(villain, models.ActionRaise, 300), act(hero, models.ActionCall, 300)},
+		{act(hero, models.ActionRaise, 300), act(villain, models.ActionRaise, 900), act(hero, models.ActionFold, 0)},
+		{seated(poker.PositionButton, act(villain, models.ActionRaise, 300)), act(other, models.ActionFold, 0), act(hero, models.ActionFold, 0)},
+		{act(villain, models.ActionFold, 0), act(other, models.ActionFold, 0)},
+		{act(villain, models.ActionCall, 100), act(hero, models.ActionCheck, 0)},
+	}
+
+	// Hole cards: a pocket pair, suited connectors, offsuit rags, the
+	// suited wheel connector and offsuit connectors
+	holeCards := [][4]string{
+		{"A", "Hearts", "A", "Spades"},
+		{"Q", "Clubs", "K", "Clubs"},
+		{"7", "Diamonds", "2", "Hearts"},
+		{"A", "Spades", "2", "Spades"},
+		{"10", "Hearts", "9", "Clubs"},
+	}
+
+	for i := 0; i < 60; i++ {
+		game := games[i%len(games)]
+		cards := holeCards[(i/3)%len(holeCards)]
+		at := f.start.Add(time.Duration(i) * 97 * time.Minute) // about four days
+		hand := models.HandHistory{
+			ID:             uuid.New(),
+			GameID:         game.ID,
+			UserID:         hero,
+			HandNumber:     i + 1,
+			SmallBlind:     game.SmallBlind,
+			BigBlind:       game.BigBlind,
+			StartingChips:  50000,
+			PotSize:        int64(3+i%7) * game.BigBlind,
+			PreFlopActions: lines[i%len(lines)],
+			HoleCard1Rank:  cards[0],
+			HoleCard1Suit:  cards[1],
+			HoleCard2Rank:  cards[2],
+			HoleCard2Suit:  cards[3],
+			StartedAt:      at,
+			FinishedAt:     at.Add(time.Minute),
+		}
+		switch position := []poker.Position{poker.PositionCO, poker.PositionButton, poker.PositionBB, poker.PositionSB}[i%4]; position {
+		default:
+			hand.Position = string(position)
+		}
+
+		switch i % 5 {
+		case 0: // folded pre-flop
+			hand.FoldedPhase = models.HandPhasePreFlop
+			hand.EndingChips = hand.StartingChips - game.BigBlind
+		case 1: // won without a showdown after betting the flop
+			hand.FlopCard1Rank = "K"
+			hand.FlopActions = []models.PlayerActionRecord{act(hero, models.ActionBet, 2*game.BigBlind), act(villain, models.ActionFold, 0)}
+			hand.IsWinner, hand.AmountWon = true, hand.PotSize
+			hand.EndingChips = hand.StartingChips + hand.PotSize/2
+		case 2: // won at showdown
+			hand.FlopCard1Rank, hand.RiverCardRank = "7", "2"
+			hand.FlopActions = []models.PlayerActionRecord{act(hero, models.ActionCheck, 0)}
+			hand.RiverActions = []models.PlayerActionRecord{act(hero, models.ActionCall, game.BigBlind)}
+			hand.WentToShowdown, hand.IsWinner, hand.AmountWon = true, true, hand.PotSize
+			hand.EndingChips = hand.StartingChips + hand.PotSize/2
+		case 3: // lost at showdown
+			hand.FlopCard1Rank, hand.RiverCardRank = "Q", "9"
+			hand.TurnActions = []models.PlayerActionRecord{act(villain, models.ActionBet, game.BigBlind), act(hero, models.ActionRaise, 3*game.BigBlind)}
+			hand.WentToShowdown = true
+			hand.EndingChips = hand.StartingChips - hand.PotSize/2
+		case 4: // folded to a flop bet
+			hand.FlopCard1Rank = "A"
+			hand.FlopActions = []models.PlayerActionRecord{act(villain, models.ActionBet, game.BigBlind), act(hero, models.ActionFold, 0)}
+			hand.FoldedPhase = models.HandPhaseFlop
+			hand.EndingChips = hand.StartingChips - 2*game.BigBlind
+		}
+		hand.NetResult = hand.EndingChips - hand.StartingChips
+		hand.Game = game
+
+		stored := hand
+		require.NoError(t, hands.CreateWith(&stored, func(tx *gorm.DB) error {
+			return f.aggregator.AddHand(tx, &stored)
+		}))
+		f.hands = append(f.hands, hand)
+	}
+
+	return f
+}
+
+// fromScratch calculates metrics directly from the fixture's hands that
+// started at or after since and pass the filter
+func (f *aggregateFixture) fromScratch(t *testing.T, since *time.Time, filter Filter) *PlayerMetrics {
+	t.Helper()
+
+	var hands []models.HandHistory
+	for _, hand := range filter.apply(f.hands) {
+		if since == nil || !hand.StartedAt.Before(*since) {
+			hands = append(hands, hand)
+		}
+	}
+	require.NotEmpty(t, hands)
+
+	metrics, err := f.service.calculateMetrics(f.user.ID, f.user.Username, hands, since)
+	require.NoError(t, err)
+	metrics.Stakes, err = f.service.calculateStakeMetrics(f.user.ID, f.user.Username, hands, since)
+	require.NoError(t, err)
+	return metrics
+}
+
+// assertSameMetrics compares metrics field by field, allowing for floating
+// point sums taken in a different order
+func assertSameMetrics(t *testing.T, want, got *PlayerMetrics) {
+	t.Helper()
+	assert.Equal(t, normalizedMetrics(t, want), normalizedMetrics(t, got))
+}
+
+func normalizedMetrics(t *testing.T, metrics *PlayerMetrics) interface{} {
+	t.Helper()
+
+	copied := *metrics
+	copied.PeriodStart = copied.PeriodStart.UTC()
+	copied.PeriodEnd = time.Time{}
+	stakes := make([]StakeMetrics, len(copied.Stakes))
+	for i, stake := range copied.Stakes {
+		stake.PeriodStart = stake.PeriodStart.UTC()
+		stake.PeriodEnd = time.Time{}
+		stakes[i] = stake
+	}
+	copied.Stakes = stakes
+
+	data, err := json.Marshal(copied)
+	require.NoError(t, err)
+	var fields interface{}
+	require.NoError(t, json.Unmarshal(data, &fields))
+	return roundFloats(fields)
+}
+
+func roundFloats(value interface{}) interface{} {
+	switch v := value.(type) {
+	case float64:
+		return math.Round(v*1e6) / 1e6
+	case map[string]interface{}:
+		for key, field := range v {
+			v[key] = roundFloats(field)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = roundFloats(item)
+		}
+	}
+	return value
+}
+
+func TestAggregatesMatchFullCalculation(t *testing.T) {
+	f := newAggregateFixture(t)
+
+	midDay := f.start.Add(30 * time.Hour).Add(17 * time.Minute)
+	nextMidnight := dayStart(f.start).AddDate(0, 0, 2)
+
+	cases := []struct {
+		name   string
+		since  *time.Time
+		filter Filter
+	}{
+		{name: "lifetime"},
+		{name: "since part way through a day", since: &midDay},
+		{name: "since midnight", since: &nextMidnight},
+		{name: "one game type", filter: Filter{GameType: models.GameTypeTexasHoldem}},
+		{name: "stake range from part way through a day", since: &midDay, filter: Filter{MinBigBlind: 100, MaxBigBlind: 100}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := f.service.GetPlayerMetrics(f.user.ID, tc.since, tc.filter)
+			require.NoError(t, err)
+			want := f.fromScratch(t, tc.since, tc.filter)
+
+			assert.NotZero(t, got.HandsPlayed)
+			assertSameMetrics(t, want, got)
+		})
+	}
+}
+
+func TestBackfillRebuildsAggregates(t *testing.T) {
+	f := newAggregateFixture(t)
+
+	incremental, err := f.stats.GetUserAggregates(f.user.ID, time.Time{})
+	require.NoError(t, err)
+	require.NotEmpty(t, incremental)
+
+	// Throw the totals away and rebuild them from the hands
+	require.NoError(t, f.stats.ReplaceUserAggregates(f.user.ID, nil))
+	metrics, err := f.service.GetPlayerMetrics(f.user.ID, nil, Filter{})
+	require.NoError(t, err)
+	assert.Zero(t, metrics.HandsPlayed)
+
+	players, err := f.aggregator.Backfill(7)
+	require.NoError(t, err)
+	assert.Equal(t, 1, players)
+
+	rebuilt, err := f.stats.GetUserAggregates(f.user.ID, time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, aggregateRows(incremental), aggregateRows(rebuilt))
+
+	metrics, err = f.service.GetPlayerMetrics(f.user.ID, nil, Filter{})
+	require.NoError(t, err)
+	assertSameMetrics(t, f.fromScratch(t, nil, Filter{}), metrics)
+}
+
+func TestDailyTotalsMatchHands(t *testing.T) {
+	f := newAggregateFixture(t)
+
+	// Each day's totals worked out straight from its hands
+	type day struct {
+		hands, won, showdowns int
+		net                   int64
+		bigBlinds             float64
+		biggestPot            int64
+	}
+	want := make(map[string]*day)
+	for _, hand := range f.hands {
+		key := dayStart(hand.StartedAt).Format("2006-01-02")
+		if want[key] == nil {
+			want[key] = &day{}
+		}
+		d := want[key]
+		d.hands++
+		d.net += hand.NetResult
+		d.bigBlinds += float64(hand.NetResult) / float64(hand.BigBlind)
+		if hand.IsWinner {
+			d.won++
+			if hand.PotSize > d.biggestPot {
+				d.biggestPot = hand.PotSize
+			}
+		}
+		if hand.WentToShowdown {
+			d.showdowns++
+		}
+	}
+
+	check := func(t *testing.T) {
+		end := f.start.AddDate(0, 0, 10)
+		totals, err := f.stats.GetDailyTotals(f.user.ID, time.Time{}, end)
+		require.NoError(t, err)
+		require.Len(t, totals, len(want))
+
+		aggregates, err := f.stats.GetUserAggregatesBetween(f.user.ID, time.Time{}, end)
+		require.NoError(t, err)
+		biggestPot := make(map[string]int64)
+		for _, aggregate := range aggregates {
+			key := aggregate.Day.UTC().Format("2006-01-02")
+			biggestPot[key] = max(biggestPot[key], aggregate.BiggestPotWon)
+		}
+
+		for _, total := range totals {
+			key := total.Day.UTC().Format("2006-01-02")
+			d := want[key]
+			require.NotNil(t, d, key)
+			assert.Equal(t, d.hands, total.Hands, key)
+			assert.Equal(t, d.won, total.HandsWon, key)
+			assert.Equal(t, d.showdowns, total.Showdowns, key)
+			assert.Equal(t, d.net, total.NetResult, key)
+			assert.InDelta(t, d.bigBlinds, total.BigBlindsWon, 1e-6, key)
+			assert.Equal(t, d.biggestPot, biggestPot[key], key)
+		}
+	}
+
+	t.Run("incremental", check)
+
+	// A day rebuilt by the backfill matches as well
+	require.NoError(t, f.stats.ReplaceUserAggregates(f.user.ID, nil))
+	_, err := f.aggregator.Backfill(7)
+	require.NoError(t, err)
+	t.Run("rebuilt", check)
+
+	days, err := f.service.GetDailyBankroll(f.user.ID, f.start, f.start.AddDate(0, 0, 10))
+	require.NoError(t, err)
+	require.NotEmpty(t, days)
+	var net int64
+	for _, hand := range f.hands {
+		net += hand.NetResult
+	}
+	assert.Equal(t, net, days[len(days)-1].Bankroll, "the bankroll ends at the period's net result")
+}
+
+func TestRakePaidSplitsBetweenWinners(t *testing.T) {
+	alice, bob := uuid.New(), uuid.New()
+	record := &models.Hand{Rake: 7, Winners: []uuid.UUID{alice, bob}}
+
+	assert.Equal(t, int64(4), rakePaid(&models.HandHistory{UserID: alice, Hand: record}), "the first winner takes the odd chip")
+	assert.Equal(t, int64(3), rakePaid(&models.HandHistory{UserID: bob, Hand: record}))
+	assert.Zero(t, rakePaid(&models.HandHistory{UserID: uuid.New(), Hand: record}), "losers pay no rake")
+	assert.Zero(t, rakePaid(&models.HandHistory{UserID: alice}), "hands without a record paid none")
+}
+
+// aggregateRows describes stored totals independently of write times and
+// time zones
+func aggregateRows(aggregates []models.PlayerStatAggregate) map[string]models.PlayerStatAggregate {
+	rows := make(map[string]models.PlayerStatAggregate, len(aggregates))
+	for _, aggregate := range aggregates {
+		aggregate.Day = aggregate.Day.UTC()
+		aggregate.FirstHandAt = aggregate.FirstHandAt.UTC()
+		aggregate.UpdatedAt = time.Time{}
+		aggregate.BigBlindsWon = math.Round(aggregate.BigBlindsWon*1e6) / 1e6
+		key := fmt.Sprintf("%s %s %d/%d", aggregate.Day.Format("2006-01-02"), aggregate.GameType, aggregate.SmallBlind, aggregate.BigBlind)
+		rows[key] = aggregate
+	}
+	return rows
+}
@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/primoPoker/server/internal/repository"
+)
+
+// BankrollDay is one day on a player's bankroll graph
+type BankrollDay struct {
+	repository.DailyTotal
+	// Bankroll is the player's net result over the graph's days up to the
+	// end of this one
+	Bankroll int64 `json:"bankroll"`
+}
+
+// GetDailyBankroll graphs a player's results a day at a time, for the days
+// (UTC) from the one containing from through the one containing to. Days
+// without hands are left out. It reads the stored daily totals, never the
+// hands themselves.
+func (s *Service) GetDailyBankroll(userID uuid.UUID, from, to time.Time) ([]BankrollDay, error) {
+	totals, err := s.aggregates.GetDailyTotals(userID, dayStart(from), dayStart(to).AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+
+	days := make([]BankrollDay, len(totals))
+	var bankroll int64
+	for i, total := range totals {
+		bankroll += total.NetResult
+		days[i] = BankrollDay{DailyTotal: total, Bankroll: bankroll}
+	}
+	return days, nil
+}
@@ -0,0 +1,218 @@
+package metrics
+
+import (
+	"container/list"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CacheKey identifies one calculation of a user's metrics
+type CacheKey struct {
+	UserID uuid.UUID
+	Since  *time.Time
+	Filter Filter
+}
+
+// String renders the key for caches that store by string
+func (k CacheKey) String() string {
+	since := "all"
+	if k.Since != nil {
+		since = k.Since.UTC().Format(time.RFC3339Nano)
+	}
+	return k.UserID.String() + ":" + since + ":" + string(k.Filter.GameType) + ":" +
+		strconv.FormatInt(k.Filter.MinBigBlind, 10) + ":" + strconv.FormatInt(k.Filter.MaxBigBlind, 10)
+}
+
+// Cache holds calculated metrics for a short while. Entries are shared
+// between requests, so callers must not modify them.
+type Cache interface {
+	Get(key CacheKey) (*PlayerMetrics, bool)
+	Set(key CacheKey, metrics *PlayerMetrics)
+	// InvalidateUser drops every entry for the user, for when they have
+	// played new hands
+	InvalidateUser(userID uuid.UUID)
+}
+
+// LRUCache is an in-memory Cache that expires entries after a TTL and
+// evicts the least recently used once full
+type LRUCache struct {
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	order   *list.List // most recently used first
+	entries map[string]*list.Element
+	users   map[uuid.UUID]map[string]struct{}
+	now     func() time.Time
+}
+
+// lruEntry is one cached calculation
+type lruEntry struct {
+	key     string
+	userID  uuid.UUID
+	metrics *PlayerMetrics
+	expires time.Time
+}
+
+// NewLRUCache creates a cache holding up to size entries for ttl each
+func NewLRUCache(size int, ttl time.Duration) *LRUCache {
+	return &LRUCache{
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+		users:   make(map[uuid.UUID]map[string]struct{}),
+		now:     time.Now,
+	}
+}
+
+// Get returns the cached metrics for key unless they have expired
+func (c *LRUCache) Get(key CacheKey) (*PlayerMetrics, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key.String()]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*lruEntry)
+	if !c.now().Before(entry.expires) {
+		c.remove(element)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return entry.metrics, true
+}
+
+// Set caches metrics for key, evicting the least recently used entry when
+// the cache is full
+func (c *LRUCache) Set(key CacheKey, metrics *PlayerMetrics) {
+	if c.size <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	name := key.String()
+	if element, ok := c.entries[name]; ok {
+		c.remove(element)
+	}
+	for c.order.Len() >= c.size {
+		c.remove(c.order.Back())
+	}
+
+	c.entries[name] = c.order.PushFront(&lruEntry{
+		key:     name,
+		userID:  key.UserID,
+		metrics: metrics,
+		expires: c.now().Add(c.ttl),
+	})
+	if c.users[key.UserID] == nil {
+		c.users[key.UserID] = make(map[string]struct{})
+	}
+	c.users[key.UserID][name] = struct{}{}
+}
+
+// InvalidateUser drops every entry for the user
+func (c *LRUCache) InvalidateUser(userID uuid.UUID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for name := range c.users[userID] {
+		c.remove(c.entries[name])
+	}
+}
+
+// Len returns how many entries are cached, expired or not
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// remove drops an entry; c.mu must be held
+func (c *LRUCache) remove(element *list.Element) {
+	entry := c.order.Remove(element).(*lruEntry)
+	delete(c.entries, entry.key)
+	if keys := c.users[entry.userID]; keys != nil {
+		delete(keys, entry.key)
+		if len(keys) == 0 {
+			delete(c.users, entry.userID)
+		}
+	}
+}
+
+// flight is a metrics calculation in progress. Requests for the same key
+// wait for it rather than starting their own.
+type flight struct {
+	userID  uuid.UUID
+	done    chan struct{}
+	metrics *PlayerMetrics
+	err     error
+	// stale is set when the user plays a hand mid-calculation, so the
+	// result is returned to the waiting requests but not cached
+	stale bool
+}
+
+// cachedMetrics serves metrics from the cache, calculating them once for
+// all concurrent requests on a miss
+func (s *Service) cachedMetrics(key CacheKey, calculate func() (*PlayerMetrics, error)) (*PlayerMetrics, error) {
+	if s.cache == nil {
+		return calculate()
+	}
+	if metrics, ok := s.cache.Get(key); ok {
+		return metrics, nil
+	}
+
+	name := key.String()
+	s.flightsMu.Lock()
+	if f, ok := s.flights[name]; ok {
+		s.flightsMu.Unlock()
+		<-f.done
+		return f.metrics, f.err
+	}
+	f := &flight{userID: key.UserID, done: make(chan struct{})}
+	s.flights[name] = f
+	s.flightsMu.Unlock()
+
+	f.metrics, f.err = calculate()
+
+	s.flightsMu.Lock()
+	delete(s.flights, name)
+	if f.err == nil && !f.stale {
+		s.cache.Set(key, f.metrics)
+	}
+	s.flightsMu.Unlock()
+	close(f.done)
+
+	return f.metrics, f.err
+}
+
+// InvalidateUser drops a user's cached metrics, including any being
+// calculated now
+func (s *Service) InvalidateUser(userID uuid.UUID) {
+	if s.cache == nil {
+		return
+	}
+
+	s.flightsMu.Lock()
+	for _, f := range s.flights {
+		if f.userID == userID {
+			f.stale = true
+		}
+	}
+	s.cache.InvalidateUser(userID)
+	s.flightsMu.Unlock()
+}
+
+// HandsWritten drops the cached metrics of the players in newly stored
+// hands. It implements handrecord.Listener.
+func (s *Service) HandsWritten(userIDs []uuid.UUID) {
+	for _, userID := range userIDs {
+		s.InvalidateUser(userID)
+	}
+}
@@ -0,0 +1,164 @@
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/internal/config"
+)
+
+func TestCacheKeyString(t *testing.T) {
+	user := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	since := time.Date(2026, 3, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+
+	assert.Equal(t, user.String()+":all::0:0", CacheKey{UserID: user}.String())
+	assert.Equal(t, user.String()+":2026-03-01T11:00:00Z:omaha:100:200", CacheKey{
+		UserID: user,
+		Since:  &since,
+		Filter: Filter{GameType: "omaha", MinBigBlind: 100, MaxBigBlind: 200},
+	}.String())
+}
+
+func TestLRUCacheExpiry(t *testing.T) {
+	now := time.Now()
+	cache := NewLRUCache(10, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	key := CacheKey{UserID: uuid.New()}
+	cache.Set(key, &PlayerMetrics{HandsPlayed: 7})
+
+	got, ok := cache.Get(key)
+	require.True(t, ok)
+	assert.Equal(t, 7, got.HandsPlayed)
+
+	now = now.Add(time.Minute)
+	_, ok = cache.Get(key)
+	assert.False(t, ok)
+	assert.Zero(t, cache.Len())
+}
+
+func TestLRUCacheEviction(t *testing.T) {
+	cache := NewLRUCache(2, time.Minute)
+	a, b, c := CacheKey{UserID: uuid.New()}, CacheKey{UserID: uuid.New()}, CacheKey{UserID: uuid.New()}
+
+	cache.Set(a, &PlayerMetrics{})
+	cache.Set(b, &PlayerMetrics{})
+	_, ok := cache.Get(a) // b is now the least recently used
+	require.True(t, ok)
+	cache.Set(c, &PlayerMetrics{})
+
+	_, ok = cache.Get(b)
+	assert.False(t, ok)
+	_, ok = cache.Get(a)
+	assert.True(t, ok)
+	_, ok = cache.Get(c)
+	assert.True(t, ok)
+	assert.Equal(t, 2, cache.Len())
+}
+
+func TestLRUCacheInvalidateUser(t *testing.T) {
+	cache := NewLRUCache(10, time.Minute)
+	user, other := uuid.New(), uuid.New()
+	since := time.Now().Add(-time.Hour)
+
+	cache.Set(CacheKey{UserID: user}, &PlayerMetrics{})
+	cache.Set(CacheKey{UserID: user, Since: &since}, &PlayerMetrics{})
+	cache.Set(CacheKey{UserID: user, Filter: Filter{GameType: "omaha"}}, &PlayerMetrics{})
+	cache.Set(CacheKey{UserID: other}, &PlayerMetrics{})
+
+	cache.InvalidateUser(user)
+	assert.Equal(t, 1, cache.Len())
+	_, ok := cache.Get(CacheKey{UserID: other})
+	assert.True(t, ok)
+}
+
+func TestCachedMetricsSingleFlight(t *testing.T) {
+	service := NewServiceWithCache(nil, nil, nil, config.MetricsConfig{}, NewLRUCache(10, time.Minute))
+	key := CacheKey{UserID: uuid.New()}
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	calculate := func() (*PlayerMetrics, error) {
+		calls.Add(1)
+		<-release
+		return &PlayerMetrics{HandsPlayed: 3}, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]*PlayerMetrics, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = service.cachedMetrics(key, calculate)
+		}(i)
+	}
+
+	// Let every request find the calculation in flight before it finishes
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+	for _, metrics := range results {
+		assert.Same(t, results[0], metrics)
+	}
+
+	// Later requests are served from the cache
+	_, err := service.cachedMetrics(key, calculate)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestCachedMetricsInvalidatedMidCalculation(t *testing.T) {
+	service := NewServiceWithCache(nil, nil, nil, config.MetricsConfig{}, NewLRUCache(10, time.Minute))
+	key := CacheKey{UserID: uuid.New()}
+
+	var calls int
+	calculate := func() (*PlayerMetrics, error) {
+		calls++
+		if calls == 1 {
+			// A hand is stored while the first calculation reads
+			service.HandsWritten([]uuid.UUID{key.UserID})
+		}
+		return &PlayerMetrics{HandsPlayed: calls}, nil
+	}
+
+	metrics, err := service.cachedMetrics(key, calculate)
+	require.NoError(t, err)
+	assert.Equal(t, 1, metrics.HandsPlayed)
+
+	// The stale result was not kept
+	metrics, err = service.cachedMetrics(key, calculate)
+	require.NoError(t, err)
+	assert.Equal(t, 2, metrics.HandsPlayed)
+
+	metrics, err = service.cachedMetrics(key, calculate)
+	require.NoError(t, err)
+	assert.Equal(t, 2, metrics.HandsPlayed)
+}
+
+func TestCachedMetricsWithoutCache(t *testing.T) {
+	service := &Service{}
+
+	var calls int
+	calculate := func() (*PlayerMetrics, error) {
+		calls++
+		return &PlayerMetrics{}, nil
+	}
+	for i := 0; i < 3; i++ {
+		_, err := service.cachedMetrics(CacheKey{}, calculate)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 3, calls)
+
+	// Invalidation is a no-op
+	service.HandsWritten([]uuid.UUID{uuid.New()})
+}
@@ -0,0 +1,339 @@
+package metrics
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/pkg/poker"
+)
+
+// GroupBy is how the metrics export splits a player's hands into rows
+type GroupBy string
+
+const (
+	GroupByStake    GroupBy = "stake"
+	GroupByPosition GroupBy = "position"
+	GroupByMonth    GroupBy = "month"
+)
+
+// ParseGroupBy validates a grouping name, defaulting to stake
+func ParseGroupBy(s string) (GroupBy, error) {
+	switch GroupBy(strings.ToLower(s)) {
+	case "", GroupByStake:
+		return GroupByStake, nil
+	case GroupByPosition:
+		return GroupByPosition, nil
+	case GroupByMonth:
+		return GroupByMonth, nil
+	}
+	return "", fmt.Errorf("unsupported grouping %q", s)
+}
+
+// ExportFormat is the encoding of a metrics export
+type ExportFormat string
+
+const (
+	ExportCSV  ExportFormat = "csv"
+	ExportJSON ExportFormat = "json"
+)
+
+// ParseExportFormat validates a format name, defaulting to CSV
+func ParseExportFormat(s string) (ExportFormat, error) {
+	switch ExportFormat(strings.ToLower(s)) {
+	case "", ExportCSV:
+		return ExportCSV, nil
+	case ExportJSON:
+		return ExportJSON, nil
+	}
+	return "", fmt.Errorf("unsupported export format %q", s)
+}
+
+// ContentType returns the MIME type for the format
+func (f ExportFormat) ContentType() string {
+	if f == ExportJSON {
+		return "application/json"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Extension returns the file extension for the format
+func (f ExportFormat) Extension() string {
+	return string(f)
+}
+
+// unknownPosition groups hands recorded before positions were stored
+const unknownPosition = "unknown"
+
+// positionOrder is the order position rows are exported in, first to act
+// pre-flop first
+var positionOrder = []string{
+	string(poker.PositionUTG), string(poker.PositionUTG1), string(poker.PositionUTG2),
+	string(poker.PositionMP), string(poker.PositionLJ), string(poker.PositionHJ),
+	string(poker.PositionCO), string(poker.PositionButton),
+	string(poker.PositionSB), string(poker.PositionBB),
+	unknownPosition,
+}
+
+// GroupMetrics is a player's metrics for one row of an export. Only the
+// fields of its grouping are set.
+type GroupMetrics struct {
+	GameType   models.GameType
+	SmallBlind int64
+	BigBlind   int64
+	Position   string
+	Month      string // YYYY-MM, UTC
+	PlayerMetrics
+}
+
+// groupKey identifies the row a hand is counted in
+type groupKey struct {
+	stake    stakeKey
+	position string
+	month    string
+}
+
+// handGroup returns the row a hand belongs to under the grouping
+func handGroup(hand *models.HandHistory, groupBy GroupBy) groupKey {
+	switch groupBy {
+	case GroupByPosition:
+		if hand.Position == "" {
+			return groupKey{position: unknownPosition}
+		}
+		return groupKey{position: hand.Position}
+	case GroupByMonth:
+		return groupKey{month: hand.StartedAt.UTC().Format("2006-01")}
+	default:
+		return groupKey{stake: handStake(hand)}
+	}
+}
+
+// GetGroupedMetrics calculates a user's lifetime metrics for each stake,
+// position or month they have played, reading their hands a batch at a
+// time. Rows are ordered by game type then stakes, by position, or by
+// month.
+func (s *Service) GetGroupedMetrics(userID uuid.UUID, groupBy GroupBy) ([]GroupMetrics, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	tallies := make(map[groupKey]*tally)
+	var lastFinish time.Time
+	err = s.handHistoryRepo.StreamUserHands(userID, time.Time{}, time.Now(), sessionBatchSize, func(batch []models.HandHistory) error {
+		for i := range batch {
+			hand := &batch[i]
+			key := handGroup(hand, groupBy)
+			t, ok := tallies[key]
+			if !ok {
+				t = &tally{}
+				tallies[key] = t
+			}
+			t.addHand(hand, tableTime(hand, lastFinish, s.sessionGap))
+			if hand.FinishedAt.After(lastFinish) {
+				lastFinish = hand.FinishedAt
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hand history: %w", err)
+	}
+
+	groups := make([]GroupMetrics, 0, len(tallies))
+	for key, t := range tallies {
+		group := GroupMetrics{
+			Position:      key.position,
+			Month:         key.month,
+			PlayerMetrics: *t.metrics(userID, user.Username, nil),
+		}
+		if groupBy == GroupByStake {
+			group.GameType = key.stake.gameType
+			group.SmallBlind = key.stake.smallBlind
+			group.BigBlind = key.stake.bigBlind
+		}
+		groups = append(groups, group)
+	}
+
+	positions := make(map[string]int, len(positionOrder))
+	for i, position := range positionOrder {
+		positions[position] = i
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		a, b := groups[i], groups[j]
+		switch groupBy {
+		case GroupByPosition:
+			return positions[a.Position] < positions[b.Position]
+		case GroupByMonth:
+			return a.Month < b.Month
+		}
+		if a.GameType != b.GameType {
+			return a.GameType < b.GameType
+		}
+		if a.BigBlind != b.BigBlind {
+			return a.BigBlind < b.BigBlind
+		}
+		return a.SmallBlind < b.SmallBlind
+	})
+
+	return groups, nil
+}
+
+// exportColumn is one column of the export and how to read it from a row
+type exportColumn struct {
+	name  string
+	value func(g *GroupMetrics) interface{}
+}
+
+// groupColumns are the columns identifying each row, by grouping
+var groupColumns = map[GroupBy][]exportColumn{
+	GroupByStake: {
+		{"game_type", func(g *GroupMetrics) interface{} { return string(g.GameType) }},
+		{"small_blind", func(g *GroupMetrics) interface{} { return g.SmallBlind }},
+		{"big_blind", func(g *GroupMetrics) interface{} { return g.BigBlind }},
+	},
+	GroupByPosition: {
+		{"position", func(g *GroupMetrics) interface{} { return g.Position }},
+	},
+	GroupByMonth: {
+		{"month", func(g *GroupMetrics) interface{} { return g.Month }},
+	},
+}
+
+// metricColumns are the metrics exported for every row. Spreadsheets are
+// built on this layout: add new columns at the end and never reorder them.
+var metricColumns = []exportColumn{
+	{"hands_played", func(g *GroupMetrics) interface{} { return g.HandsPlayed }},
+	{"hands_won", func(g *GroupMetrics) interface{} { return g.HandsWon }},
+	{"hands_lost", func(g *GroupMetrics) interface{} { return g.HandsLost }},
+	{"hands_folded", func(g *GroupMetrics) interface{} { return g.HandsFolded }},
+	{"win_rate", func(g *GroupMetrics) interface{} { return g.WinRate }},
+	{"vpip_percent", func(g *GroupMetrics) interface{} { return g.VPIPPercent }},
+	{"pfr_percent", func(g *GroupMetrics) interface{} { return g.PFRPercent }},
+	{"three_bet_percent", func(g *GroupMetrics) interface{} { return g.ThreeBetPercent }},
+	{"fold_to_three_bet_percent", func(g *GroupMetrics) interface{} { return g.FoldToThreeBetPercent }},
+	{"four_bet_percent", func(g *GroupMetrics) interface{} { return g.FourBetPercent }},
+	{"fold_to_four_bet_percent", func(g *GroupMetrics) interface{} { return g.FoldToFourBetPercent }},
+	{"attempt_to_steal_percent", func(g *GroupMetrics) interface{} { return g.AttemptToStealPercent }},
+	{"fold_to_steal_percent", func(g *GroupMetrics) interface{} { return g.FoldToStealPercent }},
+	{"three_bet_vs_steal_percent", func(g *GroupMetrics) interface{} { return g.ThreeBetVsStealPercent }},
+	{"walk_percent", func(g *GroupMetrics) interface{} { return g.WalkPercent }},
+	{"cbet_percent", func(g *GroupMetrics) interface{} { return g.CBetPercent }},
+	{"fold_to_cbet_percent", func(g *GroupMetrics) interface{} { return g.FoldToCBetPercent }},
+	{"aggression_factor", func(g *GroupMetrics) interface{} { return g.AggressionFactor }},
+	{"flops_seen", func(g *GroupMetrics) interface{} { return g.FlopsSeen }},
+	{"wtsd_percent", func(g *GroupMetrics) interface{} { return g.WTSDPercent }},
+	{"wsd_percent", func(g *GroupMetrics) interface{} { return g.WSDPercent }},
+	{"wwsf_percent", func(g *GroupMetrics) interface{} { return g.WWSFPercent }},
+	{"won_dollar_at_showdown", func(g *GroupMetrics) interface{} { return g.WonDollarAtShowdown }},
+	{"total_wagered", func(g *GroupMetrics) interface{} { return g.TotalWagered }},
+	{"total_won", func(g *GroupMetrics) interface{} { return g.TotalWon }},
+	{"net_result", func(g *GroupMetrics) interface{} { return g.NetResult }},
+	{"avg_pot_size", func(g *GroupMetrics) interface{} { return g.AvgPotSize }},
+	{"avg_win_amount", func(g *GroupMetrics) interface{} { return g.AvgWinAmount }},
+	{"biggest_win", func(g *GroupMetrics) interface{} { return g.BiggestWin }},
+	{"biggest_loss", func(g *GroupMetrics) interface{} { return g.BiggestLoss }},
+	{"bb_per_100", func(g *GroupMetrics) interface{} { return g.BBPer100 }},
+	{"table_time", func(g *GroupMetrics) interface{} { return g.TableTime }},
+	{"chips_per_hour", func(g *GroupMetrics) interface{} { return g.ChipsPerHour }},
+	{"bb_per_hour", func(g *GroupMetrics) interface{} { return g.BBPerHour }},
+	{"small_sample", func(g *GroupMetrics) interface{} { return g.SmallSample }},
+}
+
+// exportColumns returns every column of an export under the grouping, in
+// order
+func exportColumns(groupBy GroupBy) []exportColumn {
+	columns := make([]exportColumn, 0, len(groupColumns[groupBy])+len(metricColumns))
+	columns = append(columns, groupColumns[groupBy]...)
+	return append(columns, metricColumns...)
+}
+
+// exportValue normalises a column value for both encodings; percentages
+// and rates are rounded to two decimal places
+func exportValue(value interface{}) interface{} {
+	if f, ok := value.(float64); ok {
+		return math.Round(f*100) / 100
+	}
+	return value
+}
+
+// WriteExport encodes grouped metrics to w, one row at a time
+func WriteExport(w io.Writer, format ExportFormat, groupBy GroupBy, groups []GroupMetrics) error {
+	if format == ExportJSON {
+		return writeJSONExport(w, groupBy, groups)
+	}
+	return writeCSVExport(w, groupBy, groups)
+}
+
+// writeCSVExport writes a header row and then a row per group
+func writeCSVExport(w io.Writer, groupBy GroupBy, groups []GroupMetrics) error {
+	columns := exportColumns(groupBy)
+	out := csv.NewWriter(w)
+
+	record := make([]string, len(columns))
+	for i, column := range columns {
+		record[i] = column.name
+	}
+	if err := out.Write(record); err != nil {
+		return err
+	}
+
+	for i := range groups {
+		for j, column := range columns {
+			switch v := exportValue(column.value(&groups[i])).(type) {
+			case float64:
+				record[j] = strconv.FormatFloat(v, 'f', 2, 64)
+			default:
+				record[j] = fmt.Sprint(v)
+			}
+		}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// writeJSONExport writes an array with an object per group, its keys in
+// the same order as the CSV columns
+func writeJSONExport(w io.Writer, groupBy GroupBy, groups []GroupMetrics) error {
+	columns := exportColumns(groupBy)
+	buf := bufio.NewWriter(w)
+
+	buf.WriteString("[")
+	for i := range groups {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n  {")
+		for j, column := range columns {
+			if j > 0 {
+				buf.WriteString(",")
+			}
+			value, err := json.Marshal(exportValue(column.value(&groups[i])))
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(buf, "%q:%s", column.name, value)
+		}
+		buf.WriteString("}")
+	}
+	if len(groups) > 0 {
+		buf.WriteString("\n")
+	}
+	buf.WriteString("]\n")
+
+	return buf.Flush()
+}
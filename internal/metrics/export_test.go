@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportGolden(t *testing.T) {
+	f := newAggregateFixture(t)
+
+	for _, groupBy := range []GroupBy{GroupByStake, GroupByPosition, GroupByMonth} {
+		groups, err := f.service.GetGroupedMetrics(f.user.ID, groupBy)
+		require.NoError(t, err)
+
+		var hands int
+		for _, group := range groups {
+			hands += group.HandsPlayed
+		}
+		assert.Equal(t, len(f.hands), hands, "every hand is in exactly one row")
+
+		for _, format := range []ExportFormat{ExportCSV, ExportJSON} {
+			var buf bytes.Buffer
+			require.NoError(t, WriteExport(&buf, format, groupBy, groups))
+			checkGolden(t, "export_"+string(groupBy)+"."+format.Extension()+".golden", buf.Bytes())
+
+			if format == ExportCSV {
+				records, err := csv.NewReader(bytes.NewReader(buf.Bytes())).ReadAll()
+				require.NoError(t, err)
+				assert.Len(t, records, len(groups)+1)
+			} else {
+				var rows []map[string]interface{}
+				require.NoError(t, json.Unmarshal(buf.Bytes(), &rows))
+				assert.Len(t, rows, len(groups))
+			}
+		}
+	}
+}
+
+func TestExportStakeRowsMatchMetrics(t *testing.T) {
+	f := newAggregateFixture(t)
+
+	groups, err := f.service.GetGroupedMetrics(f.user.ID, GroupByStake)
+	require.NoError(t, err)
+	metrics, err := f.service.GetPlayerMetrics(f.user.ID, nil, Filter{})
+	require.NoError(t, err)
+
+	require.Len(t, groups, len(metrics.Stakes))
+	for i, stake := range metrics.Stakes {
+		assert.Equal(t, stake.GameType, groups[i].GameType)
+		assert.Equal(t, stake.BigBlind, groups[i].BigBlind)
+		assertSameMetrics(t, &stake.PlayerMetrics, &groups[i].PlayerMetrics)
+	}
+}
+
+func TestParseExportOptions(t *testing.T) {
+	format, err := ParseExportFormat("")
+	require.NoError(t, err)
+	assert.Equal(t, ExportCSV, format)
+	_, err = ParseExportFormat("xlsx")
+	assert.Error(t, err)
+
+	groupBy, err := ParseGroupBy("Month")
+	require.NoError(t, err)
+	assert.Equal(t, GroupByMonth, groupBy)
+	_, err = ParseGroupBy("table")
+	assert.Error(t, err)
+}
@@ -0,0 +1,180 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/primoPoker/server/internal/models"
+)
+
+var (
+	// ErrOpponentNotFound is returned for a head-to-head against an unknown
+	// username
+	ErrOpponentNotFound = errors.New("opponent not found")
+
+	// ErrOwnHeadToHead is returned for a head-to-head against oneself
+	ErrOwnHeadToHead = errors.New("cannot compare a player with themselves")
+
+	// ErrTooFewSharedHands is returned while two players have been dealt into
+	// fewer hands together than the configured minimum
+	ErrTooFewSharedHands = errors.New("too few hands played together")
+)
+
+// HeadToHead is how a player has done against one opponent over the hands
+// both were dealt into. A pot is contested when the two clashed in it: both
+// put chips in voluntarily, or one folded to the other's bet. Hands where
+// one of them folded before the other did anything are left out of every
+// count but HandsTogether.
+type HeadToHead struct {
+	UserID        uuid.UUID `json:"user_id"`
+	OpponentID    uuid.UUID `json:"opponent_id"`
+	Opponent      string    `json:"opponent"`
+	HandsTogether int       `json:"hands_together"`
+	PotsContested int       `json:"pots_contested"`
+	PotsWon       int       `json:"pots_won"`  // Contested pots the player won and the opponent did not
+	PotsLost      int       `json:"pots_lost"` // Contested pots the opponent won and the player did not
+	Showdowns     int       `json:"showdowns"`
+	ShowdownsWon  int       `json:"showdowns_won"`
+	ShowdownsLost int       `json:"showdowns_lost"`
+	NetChips      int64     `json:"net_chips"` // Chips won from the opponent, negative when lost to them
+	NetBB         float64   `json:"net_bb"`    // The same in big blinds, each hand in its own big blind
+}
+
+// GetHeadToHead returns how a user has done against the player with the
+// given username
+func (s *Service) GetHeadToHead(userID uuid.UUID, opponentName string) (*HeadToHead, error) {
+	opponent, err := s.userRepo.GetByUsername(opponentName)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrOpponentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get opponent: %w", err)
+	}
+	if opponent.ID == userID {
+		return nil, ErrOwnHeadToHead
+	}
+
+	rows, err := s.handHistoryRepo.GetSharedHands(userID, opponent.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shared hands: %w", err)
+	}
+
+	h2h := headToHead(userID, opponent.ID, rows)
+	if h2h.HandsTogether < s.headToHeadMinHands {
+		return nil, ErrTooFewSharedHands
+	}
+	h2h.Opponent = opponent.Username
+	return h2h, nil
+}
+
+// headToHead pairs up the two players' rows of each hand and totals how the
+// first did against the second
+func headToHead(userID, opponentID uuid.UUID, rows []models.HandHistory) *HeadToHead {
+	type handKey struct {
+		gameID     uuid.UUID
+		handNumber int
+	}
+	theirs := make(map[handKey]*models.HandHistory)
+	for i := range rows {
+		if rows[i].UserID == opponentID {
+			theirs[handKey{rows[i].GameID, rows[i].HandNumber}] = &rows[i]
+		}
+	}
+
+	h2h := &HeadToHead{UserID: userID, OpponentID: opponentID}
+	for i := range rows {
+		mine := &rows[i]
+		if mine.UserID != userID {
+			continue
+		}
+		other, ok := theirs[handKey{mine.GameID, mine.HandNumber}]
+		if !ok {
+			continue
+		}
+		h2h.HandsTogether++
+
+		if !clashed(mine, other) {
+			continue
+		}
+		h2h.PotsContested++
+		switch {
+		case mine.IsWinner && !other.IsWinner:
+			h2h.PotsWon++
+		case other.IsWinner && !mine.IsWinner:
+			h2h.PotsLost++
+		}
+		if mine.WentToShowdown && other.WentToShowdown {
+			h2h.Showdowns++
+			switch {
+			case mine.IsWinner && !other.IsWinner:
+				h2h.ShowdownsWon++
+			case other.IsWinner && !mine.IsWinner:
+				h2h.ShowdownsLost++
+			}
+		}
+
+		chips := chipsWonFrom(mine, other) - chipsWonFrom(other, mine)
+		h2h.NetChips += chips
+		if mine.BigBlind > 0 {
+			h2h.NetBB += float64(chips) / float64(mine.BigBlind)
+		}
+	}
+	return h2h
+}
+
+// clashed checks whether two players met in a hand, from the whole table's
+// actions recorded with it. Hands stored without actions count when neither
+// player folded pre-flop.
+func clashed(mine, theirs *models.HandHistory) bool {
+	streets := [][]models.PlayerActionRecord{mine.PreFlopActions, mine.FlopActions, mine.TurnActions, mine.RiverActions}
+	recorded := false
+	inPot := make(map[uuid.UUID]bool, 2)
+	for _, actions := range streets {
+		var aggressor uuid.UUID // last to bet or raise on this street
+		for _, action := range actions {
+			recorded = true
+			if action.PlayerID != mine.UserID && action.PlayerID != theirs.UserID {
+				if isAggressive(action.Action) {
+					aggressor = action.PlayerID
+				}
+				continue
+			}
+
+			if action.Action == models.ActionFold {
+				// Folding to the other's bet is a pot lost to them
+				if aggressor != uuid.Nil && aggressor != action.PlayerID &&
+					(aggressor == mine.UserID || aggressor == theirs.UserID) {
+					return true
+				}
+				continue
+			}
+			inPot[action.PlayerID] = true
+			if isAggressive(action.Action) {
+				aggressor = action.PlayerID
+			}
+		}
+	}
+
+	if !recorded {
+		return mine.FoldedPhase != models.HandPhasePreFlop && theirs.FoldedPhase != models.HandPhasePreFlop
+	}
+	return inPot[mine.UserID] && inPot[theirs.UserID]
+}
+
+// isAggressive checks if an action bets or raises
+func isAggressive(action models.PlayerAction) bool {
+	return action == models.ActionBet || action == models.ActionRaise || action == models.ActionAllIn
+}
+
+// chipsWonFrom returns how much of what loser lost in a hand went to
+// winner. A loser's chips are shared between the winners in proportion to
+// what each took from the pot.
+func chipsWonFrom(winner, loser *models.HandHistory) int64 {
+	if winner.NetResult <= 0 || loser.NetResult >= 0 || winner.PotSize <= 0 {
+		return 0
+	}
+	return -loser.NetResult * winner.AmountWon / winner.PotSize
+}
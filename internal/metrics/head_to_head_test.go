@@ -0,0 +1,203 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/clause"
+
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/repository"
+	"github.com/primoPoker/server/internal/testutil"
+)
+
+var (
+	hero    = uuid.MustParse("00000000-0000-0000-0000-0000000000e1")
+	villain = uuid.MustParse("00000000-0000-0000-0000-0000000000e2")
+	carol   = uuid.MustParse("00000000-0000-0000-0000-0000000000e3")
+)
+
+// sharedHand is one hand at a three-handed table and each player's result
+// in it; players left out of results were not dealt in
+type sharedHand struct {
+	actions  [][]models.PlayerActionRecord // by street
+	pot      int64
+	showdown bool
+	results  map[uuid.UUID]handResult
+}
+
+type handResult struct {
+	net       int64
+	won       int64
+	preFolded bool
+}
+
+// headToHeadRows builds every player's row of each hand, as the hand
+// writer stores them
+func headToHeadRows(hands []sharedHand) []models.HandHistory {
+	game := uuid.MustParse("00000000-0000-0000-0000-0000000000f0")
+	start := time.Date(2026, 6, 1, 20, 0, 0, 0, time.UTC)
+
+	var rows []models.HandHistory
+	for i, hand := range hands {
+		for _, player := range []uuid.UUID{hero, villain, carol} {
+			result, ok := hand.results[player]
+			if !ok {
+				continue
+			}
+			row := models.HandHistory{
+				ID:             uuid.New(),
+				GameID:         game,
+				UserID:         player,
+				HandNumber:     i + 1,
+				BigBlind:       100,
+				NetResult:      result.net,
+				AmountWon:      result.won,
+				PotSize:        hand.pot,
+				IsWinner:       result.won > 0,
+				WentToShowdown: hand.showdown && !result.preFolded,
+				StartedAt:      start.Add(time.Duration(i) * time.Minute),
+				FinishedAt:     start.Add(time.Duration(i)*time.Minute + 30*time.Second),
+			}
+			if result.preFolded {
+				row.FoldedPhase = models.HandPhasePreFlop
+			}
+			streets := []*[]models.PlayerActionRecord{&row.PreFlopActions, &row.FlopActions, &row.TurnActions, &row.RiverActions}
+			for street, actions := range hand.actions {
+				*streets[street] = actions
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// headToHeadHands are crafted so that each kind of hand counts once:
+//
+//  1. hero folds first, villain raises: not contested
+//  2. villain folds to hero's raise: contested, 100 won from villain
+//  3. both see a showdown villain wins: 500 lost to villain
+//  4. a showdown split between the two: nothing changes hands
+//  5. hero wins a three-way pot: 200 of it from villain
+//  6. villain folds to carol's raise, hero calls: not contested
+//  7. hero and carol only: not shared
+//  8. no actions recorded and hero folded pre-flop: not contested
+func headToHeadHands() []sharedHand {
+	return []sharedHand{
+		{
+			actions: [][]models.PlayerActionRecord{{act(hero, models.ActionFold, 0), act(villain, models.ActionRaise, 300), act(carol, models.ActionFold, 0)}},
+			pot:     450,
+			results: map[uuid.UUID]handResult{hero: {}, villain: {net: 150, won: 450}, carol: {net: -100}},
+		},
+		{
+			actions: [][]models.PlayerActionRecord{{act(hero, models.ActionRaise, 300), act(carol, models.ActionFold, 0), act(villain, models.ActionFold, 0)}},
+			pot:     450,
+			results: map[uuid.UUID]handResult{hero: {net: 150, won: 450}, villain: {net: -100}, carol: {net: -50}},
+		},
+		{
+			actions: [][]models.PlayerActionRecord{
+				{act(hero, models.ActionCall, 100), act(carol, models.ActionCall, 50), act(villain, models.ActionCheck, 0)},
+				{act(carol, models.ActionCheck, 0), act(villain, models.ActionBet, 400), act(hero, models.ActionCall, 400), act(carol, models.ActionFold, 0)},
+			},
+			pot:      1100,
+			showdown: true,
+			results:  map[uuid.UUID]handResult{hero: {net: -500}, villain: {net: 600, won: 1100}, carol: {net: -100}},
+		},
+		{
+			actions: [][]models.PlayerActionRecord{
+				{act(hero, models.ActionCall, 100), act(carol, models.ActionFold, 0), act(villain, models.ActionCheck, 0)},
+				{act(villain, models.ActionCheck, 0), act(hero, models.ActionCheck, 0)},
+			},
+			pot:      250,
+			showdown: true,
+			results:  map[uuid.UUID]handResult{hero: {net: 25, won: 125}, villain: {net: 25, won: 125}, carol: {net: -50}},
+		},
+		{
+			actions: [][]models.PlayerActionRecord{
+				{act(hero, models.ActionRaise, 200), act(carol, models.ActionCall, 150), act(villain, models.ActionCall, 100)},
+				{act(carol, models.ActionCheck, 0), act(villain, models.ActionCheck, 0), act(hero, models.ActionBet, 300), act(carol, models.ActionFold, 0), act(villain, models.ActionFold, 0)},
+			},
+			pot:     900,
+			results: map[uuid.UUID]handResult{hero: {net: 400, won: 900}, villain: {net: -200}, carol: {net: -200}},
+		},
+		{
+			actions: [][]models.PlayerActionRecord{{act(carol, models.ActionRaise, 300), act(villain, models.ActionFold, 0), act(hero, models.ActionCall, 200)}},
+			pot:     700,
+			results: map[uuid.UUID]handResult{hero: {net: -300}, villain: {}, carol: {net: 400, won: 700}},
+		},
+		{
+			actions: [][]models.PlayerActionRecord{{act(hero, models.ActionRaise, 300), act(carol, models.ActionFold, 0)}},
+			pot:     350,
+			results: map[uuid.UUID]handResult{hero: {net: 50, won: 350}, carol: {net: -50}},
+		},
+		{
+			pot:     300,
+			results: map[uuid.UUID]handResult{hero: {preFolded: true}, villain: {net: 150, won: 300}, carol: {net: -100}},
+		},
+	}
+}
+
+func TestHeadToHead(t *testing.T) {
+	rows := headToHeadRows(headToHeadHands())
+
+	got := headToHead(hero, villain, rows)
+	assert.Equal(t, &HeadToHead{
+		UserID:        hero,
+		OpponentID:    villain,
+		HandsTogether: 7,
+		PotsContested: 4,
+		PotsWon:       2,
+		PotsLost:      1,
+		Showdowns:     2,
+		ShowdownsLost: 1,
+		NetChips:      -200,
+		NetBB:         -2,
+	}, got)
+
+	// The same hands from the other side
+	got = headToHead(villain, hero, rows)
+	assert.Equal(t, 4, got.PotsContested)
+	assert.Equal(t, 1, got.PotsWon)
+	assert.Equal(t, 2, got.PotsLost)
+	assert.Equal(t, 1, got.ShowdownsWon)
+	assert.Equal(t, int64(200), got.NetChips)
+}
+
+func TestGetHeadToHead(t *testing.T) {
+	db := testutil.NewDB(t, &models.User{}, &models.Game{}, &models.HandHistory{})
+	for _, user := range []models.User{
+		{ID: hero, Username: "hero", Email: "hero@example.com", PasswordHash: "x"},
+		{ID: villain, Username: "villain", Email: "villain@example.com", PasswordHash: "x"},
+		{ID: carol, Username: "carol", Email: "carol@example.com", PasswordHash: "x"},
+	} {
+		require.NoError(t, db.Create(&user).Error)
+	}
+	rows := headToHeadRows(headToHeadHands())
+	require.NoError(t, db.Omit(clause.Associations).Create(&rows).Error)
+
+	service := &Service{
+		handHistoryRepo:    repository.NewHandHistoryRepository(db),
+		userRepo:           repository.NewUserRepository(db),
+		headToHeadMinHands: 7,
+	}
+
+	got, err := service.GetHeadToHead(hero, "villain")
+	require.NoError(t, err)
+	assert.Equal(t, "villain", got.Opponent)
+	assert.Equal(t, 7, got.HandsTogether)
+	assert.Equal(t, 4, got.PotsContested)
+	assert.Equal(t, int64(-200), got.NetChips)
+
+	service.headToHeadMinHands = 8
+	_, err = service.GetHeadToHead(hero, "villain")
+	assert.ErrorIs(t, err, ErrTooFewSharedHands)
+
+	_, err = service.GetHeadToHead(hero, "hero")
+	assert.ErrorIs(t, err, ErrOwnHeadToHead)
+
+	_, err = service.GetHeadToHead(hero, "nobody")
+	assert.ErrorIs(t, err, ErrOpponentNotFound)
+}
@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrHUDDisabled is returned for HUD stats when they are turned off site-wide
+var ErrHUDDisabled = errors.New("HUD stats are disabled")
+
+// HUDStat is one figure shown beside a player at the table. Until the
+// player has played enough hands for it to mean anything it is shown as
+// "-" rather than a number.
+type HUDStat struct {
+	Value float64
+	Shown bool
+}
+
+// MarshalJSON writes the figure to one decimal place, or "-" when hidden
+func (s HUDStat) MarshalJSON() ([]byte, error) {
+	if !s.Shown {
+		return []byte(`"-"`), nil
+	}
+	return json.Marshal(math.Round(s.Value*10) / 10)
+}
+
+// HUDStats is the read on a player given to the others at their table
+type HUDStats struct {
+	UserID           uuid.UUID `json:"user_id"`
+	Username         string    `json:"username"`
+	Hands            int       `json:"hands"`
+	VPIP             HUDStat   `json:"vpip"`
+	PFR              HUDStat   `json:"pfr"`
+	ThreeBet         HUDStat   `json:"three_bet"`
+	AggressionFactor HUDStat   `json:"af"`
+	AttemptToSteal   HUDStat   `json:"attempt_to_steal"`
+	FoldToSteal      HUDStat   `json:"fold_to_steal"`
+}
+
+// HUDEnabled reports whether players may see their opponents' stats
+func (s *Service) HUDEnabled() bool {
+	return s.hudEnabled
+}
+
+// GetHUDStats returns the HUD stats of each of the given players over every
+// hand they have played, from their cached or stored totals. Players with
+// no account, such as bots, have no hands tracked.
+func (s *Service) GetHUDStats(userIDs []uuid.UUID) (map[uuid.UUID]*HUDStats, error) {
+	if !s.hudEnabled {
+		return nil, ErrHUDDisabled
+	}
+
+	stats := make(map[uuid.UUID]*HUDStats, len(userIDs))
+	for _, userID := range userIDs {
+		metrics, err := s.GetPlayerMetrics(userID, nil, Filter{})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			stats[userID] = &HUDStats{UserID: userID}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		stats[userID] = s.hudStats(metrics)
+	}
+	return stats, nil
+}
+
+// hudStats picks the HUD figures out of a player's metrics, hiding them
+// while the sample is below the configured minimum
+func (s *Service) hudStats(metrics *PlayerMetrics) *HUDStats {
+	shown := metrics.HandsPlayed > 0 && metrics.HandsPlayed >= s.hudMinHands
+	return &HUDStats{
+		UserID:           metrics.UserID,
+		Username:         metrics.Username,
+		Hands:            metrics.HandsPlayed,
+		VPIP:             HUDStat{Value: metrics.VPIPPercent, Shown: shown},
+		PFR:              HUDStat{Value: metrics.PFRPercent, Shown: shown},
+		ThreeBet:         HUDStat{Value: metrics.ThreeBetPercent, Shown: shown},
+		AggressionFactor: HUDStat{Value: metrics.AggressionFactor, Shown: shown},
+		AttemptToSteal:   HUDStat{Value: metrics.AttemptToStealPercent, Shown: shown},
+		FoldToSteal:      HUDStat{Value: metrics.FoldToStealPercent, Shown: shown},
+	}
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetHUDStats(t *testing.T) {
+	f := newAggregateFixture(t)
+	f.service.hudEnabled = true
+	f.service.hudMinHands = 50
+	stranger := uuid.New()
+
+	stats, err := f.service.GetHUDStats([]uuid.UUID{f.user.ID, stranger})
+	require.NoError(t, err)
+	require.Len(t, stats, 2)
+
+	hero := stats[f.user.ID]
+	assert.Equal(t, "hero", hero.Username)
+	assert.Equal(t, 60, hero.Hands)
+	assert.Equal(t, HUDStat{Value: 60, Shown: true}, hero.VPIP)
+	assert.Equal(t, HUDStat{Value: 45, Shown: true}, hero.PFR)
+	assert.True(t, hero.ThreeBet.Shown)
+	assert.True(t, hero.AggressionFactor.Shown)
+	assert.Equal(t, HUDStat{Value: 100, Shown: true}, hero.AttemptToSteal)
+	assert.Equal(t, HUDStat{Value: 100, Shown: true}, hero.FoldToSteal)
+
+	// Players without an account have nothing tracked
+	assert.Equal(t, &HUDStats{UserID: stranger}, stats[stranger])
+
+	// Below the minimum sample the figures are hidden but the count is not
+	f.service.hudMinHands = 61
+	stats, err = f.service.GetHUDStats([]uuid.UUID{f.user.ID})
+	require.NoError(t, err)
+	assert.Equal(t, 60, stats[f.user.ID].Hands)
+	assert.False(t, stats[f.user.ID].VPIP.Shown)
+
+	f.service.hudEnabled = false
+	_, err = f.service.GetHUDStats([]uuid.UUID{f.user.ID})
+	assert.ErrorIs(t, err, ErrHUDDisabled)
+}
+
+func TestHUDStatJSON(t *testing.T) {
+	data, err := json.Marshal(HUDStats{
+		Hands:            12,
+		VPIP:             HUDStat{Value: 23.456, Shown: true},
+		PFR:              HUDStat{Value: 18, Shown: true},
+		ThreeBet:         HUDStat{Value: 7.5},
+		AggressionFactor: HUDStat{},
+	})
+	require.NoError(t, err)
+
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &fields))
+	assert.Equal(t, 23.5, fields["vpip"])
+	assert.Equal(t, float64(18), fields["pfr"])
+	assert.Equal(t, "-", fields["three_bet"])
+	assert.Equal(t, "-", fields["af"])
+}
@@ -0,0 +1,142 @@
+package metrics
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/primoPoker/server/internal/config"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/pagination"
+	"github.com/primoPoker/server/internal/repository"
+)
+
+// leaderboardKeepDays is how many days of leaderboards are kept
+const leaderboardKeepDays = 30
+
+// ErrUnknownLeaderboard is returned for a metric without a leaderboard
+var ErrUnknownLeaderboard = repository.ErrUnknownLeaderboard
+
+// Leaderboards builds the daily leaderboards in the background and serves
+// the latest ones
+type Leaderboards struct {
+	repo       *repository.LeaderboardRepository
+	minHands   int
+	sessionGap time.Duration
+	now        func() time.Time
+}
+
+// NewLeaderboards creates the leaderboards, ranking players with at least
+// the configured number of hands
+func NewLeaderboards(repo *repository.LeaderboardRepository, cfg config.MetricsConfig) *Leaderboards {
+	return &Leaderboards{
+		repo:       repo,
+		minHands:   cfg.LeaderboardMinHands,
+		sessionGap: cfg.SessionGap,
+		now:        time.Now,
+	}
+}
+
+// Leaderboard is a page of one leaderboard along with the requesting
+// player's own place on it, which is nil when they are not ranked
+type Leaderboard struct {
+	Metric   models.LeaderboardMetric                          `json:"metric"`
+	Day      *time.Time                                        `json:"day"`
+	MinHands int                                               `json:"min_hands"`
+	Entries  *pagination.PageResponse[models.LeaderboardEntry] `json:"entries"`
+	Me       *models.LeaderboardEntry                          `json:"me"`
+}
+
+// RankedLeaderboard is a page of a leaderboard ranked live over a period
+// along with the requesting player's own place on it, which is nil when
+// they are not ranked
+type RankedLeaderboard struct {
+	Metric   models.LeaderboardMetric                             `json:"metric"`
+	Period   models.LeaderboardPeriod                             `json:"period"`
+	MinHands int                                                  `json:"min_hands"`
+	Entries  *pagination.PageResponse[repository.LeaderboardRank] `json:"entries"`
+	Me       *repository.LeaderboardRank                          `json:"me"`
+}
+
+// Refresh rebuilds today's leaderboards (UTC) from hand history
+func (l *Leaderboards) Refresh() error {
+	return l.repo.Materialize(dayStart(l.now()), repository.LeaderboardOptions{
+		MinHands:   l.minHands,
+		SessionGap: l.sessionGap,
+		KeepDays:   leaderboardKeepDays,
+	})
+}
+
+// Get returns a page of the latest board for metric, with userID's place
+func (l *Leaderboards) Get(metric models.LeaderboardMetric, userID uuid.UUID, page pagination.PageRequest) (*Leaderboard, error) {
+	if !metric.IsValid() {
+		return nil, ErrUnknownLeaderboard
+	}
+
+	board := &Leaderboard{
+		Metric:   metric,
+		MinHands: l.minHands,
+		Entries:  pagination.NewPage([]models.LeaderboardEntry(nil), page, nil),
+	}
+
+	day, err := l.repo.LatestLeaderboardDay()
+	if err != nil {
+		return nil, err
+	}
+	if day.IsZero() {
+		return board, nil
+	}
+	board.Day = &day
+
+	board.Entries, err = l.repo.GetLeaderboard(day, metric, page)
+	if err != nil {
+		return nil, err
+	}
+
+	board.Me, err = l.repo.GetLeaderboardEntry(day, metric, userID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return board, nil
+}
+
+// GetRanked returns a page of metric's board ranked live from the hands
+// played since period began, with userID's place
+func (l *Leaderboards) GetRanked(metric models.LeaderboardMetric, period models.LeaderboardPeriod, userID uuid.UUID, page pagination.PageRequest) (*RankedLeaderboard, error) {
+	opts := l.liveOptions()
+	entries, err := l.repo.GetLeaderboardPage(metric, period, opts, page)
+	if err != nil {
+		return nil, err
+	}
+
+	me, err := l.repo.GetUserRank(metric, period, userID, opts)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return &RankedLeaderboard{Metric: metric, Period: period, MinHands: l.minHands, Entries: entries, Me: me}, nil
+}
+
+// Ranks returns userID's place on every board ranked live over period,
+// leaving out the boards they are not ranked on
+func (l *Leaderboards) Ranks(userID uuid.UUID, period models.LeaderboardPeriod) ([]repository.LeaderboardRank, error) {
+	opts := l.liveOptions()
+	ranks := []repository.LeaderboardRank{}
+	for _, metric := range models.LeaderboardMetrics {
+		rank, err := l.repo.GetUserRank(metric, period, userID, opts)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		ranks = append(ranks, *rank)
+	}
+	return ranks, nil
+}
+
+// liveOptions are the rules boards ranked live are built with
+func (l *Leaderboards) liveOptions() repository.LeaderboardOptions {
+	return repository.LeaderboardOptions{MinHands: l.minHands, SessionGap: l.sessionGap, Now: l.now()}
+}
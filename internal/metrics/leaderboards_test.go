@@ -0,0 +1,284 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/primoPoker/server/internal/config"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/pagination"
+	"github.com/primoPoker/server/internal/repository"
+	"github.com/primoPoker/server/internal/testutil"
+)
+
+// leaderboardFixture is a few players' hand histories and the boards
+// built from them
+type leaderboardFixture struct {
+	db           *gorm.DB
+	leaderboards *Leaderboards
+	users        map[string]uuid.UUID
+	day          time.Time
+}
+
+// leaderboardHand is the part of a fixture hand that matters to the boards
+type leaderboardHand struct {
+	offset   time.Duration // from the fixture's first hand
+	net      int64
+	pot      int64
+	showdown bool
+}
+
+func newLeaderboardFixture(t *testing.T) *leaderboardFixture {
+	t.Helper()
+
+	db := testutil.NewDB(t, &models.User{}, &models.Game{}, &models.HandHistory{}, &models.PlayerStatAggregate{}, &models.LeaderboardEntry{})
+	f := &leaderboardFixture{
+		db:           db,
+		leaderboards: NewLeaderboards(repository.NewLeaderboardRepository(db), config.MetricsConfig{LeaderboardMinHands: 5, SessionGap: 30 * time.Minute}),
+		users:        make(map[string]uuid.UUID),
+		day:          time.Date(2026, 5, 10, 0, 0, 0, 0, time.UTC),
+	}
+	f.leaderboards.now = func() time.Time { return f.day.Add(6 * time.Hour) }
+	start := f.day.AddDate(0, 0, -3)
+
+	// Sessions of two hands each: won, won, lost, won. Best run of two.
+	alice := []leaderboardHand{
+		{0, 100, 400, true}, {5 * time.Minute, 100, 5000, true},
+		{2 * time.Hour, 50, 300, false}, {2*time.Hour + 5*time.Minute, 0, 300, true},
+		{4 * time.Hour, -300, 600, true}, {4*time.Hour + 5*time.Minute, -300, 600, false},
+		{6 * time.Hour, 100, 200, false}, {6*time.Hour + 5*time.Minute, 100, 200, false},
+	}
+	// One winning hand per session, every session won
+	winner := func(n int) []leaderboardHand {
+		hands := make([]leaderboardHand, n)
+		for i := range hands {
+			hands[i] = leaderboardHand{offset: time.Duration(i) * 2 * time.Hour, net: 50, pot: 800, showdown: i < 2}
+		}
+		hands[0].net = -50 // lost the first showdown
+		hands[0].pot = 300
+		return hands
+	}
+
+	f.addPlayer(t, "alice", uuid.MustParse("00000000-0000-0000-0000-00000000000a"), start, alice, false)
+	f.addPlayer(t, "bob", uuid.MustParse("00000000-0000-0000-0000-000000000001"), start, winner(6), false)
+	f.addPlayer(t, "frank", uuid.MustParse("00000000-0000-0000-0000-000000000002"), start, winner(6), false)
+	f.addPlayer(t, "dave", uuid.MustParse("00000000-0000-0000-0000-000000000003"), start, winner(7), false)
+	// Too few hands to count, however well they went
+	f.addPlayer(t, "carol", uuid.New(), start, []leaderboardHand{{0, 10000, 20000, true}, {time.Hour, 10000, 20000, true}}, false)
+	// Banned players are left off
+	f.addPlayer(t, "eve", uuid.New(), start, winner(9), true)
+
+	// The boards rank players from their daily totals
+	_, err := NewAggregator(repository.NewPlayerStatRepository(db), repository.NewHandHistoryRepository(db), 30*time.Minute).Backfill(100)
+	require.NoError(t, err)
+
+	return f
+}
+
+func (f *leaderboardFixture) addPlayer(t *testing.T, username string, id uuid.UUID, start time.Time, hands []leaderboardHand, banned bool) {
+	t.Helper()
+
+	user := models.User{ID: id, Username: username, Email: username + "@example.com", PasswordHash: "x"}
+	require.NoError(t, f.db.Create(&user).Error)
+	if banned {
+		require.NoError(t, f.db.Model(&user).Update("is_banned", true).Error)
+	}
+	f.users[username] = id
+
+	for i, hand := range hands {
+		at := start.Add(hand.offset)
+		history := models.HandHistory{
+			ID:             uuid.New(),
+			GameID:         uuid.New(),
+			UserID:         id,
+			HandNumber:     i + 1,
+			BigBlind:       100,
+			NetResult:      hand.net,
+			PotSize:        hand.pot,
+			WentToShowdown: hand.showdown,
+			IsWinner:       hand.net > 0,
+			StartedAt:      at,
+			FinishedAt:     at.Add(2 * time.Minute),
+		}
+		if hand.net > 0 {
+			history.AmountWon = hand.pot
+		}
+		require.NoError(t, f.db.Omit(clause.Associations).Create(&history).Error)
+	}
+}
+
+// ranked returns the usernames and values on a board in rank order
+func (f *leaderboardFixture) ranked(t *testing.T, metric models.LeaderboardMetric) ([]string, []float64) {
+	t.Helper()
+
+	board, err := f.leaderboards.Get(metric, f.users["alice"], pagination.PageRequest{Limit: 50})
+	require.NoError(t, err)
+
+	var names []string
+	var values []float64
+	for i, entry := range board.Entries.Items {
+		assert.Equal(t, i+1, entry.Rank)
+		names = append(names, entry.Username)
+		values = append(values, entry.Value)
+	}
+	return names, values
+}
+
+func TestLeaderboardRankings(t *testing.T) {
+	f := newLeaderboardFixture(t)
+	require.NoError(t, f.leaderboards.Refresh())
+
+	names, values := f.ranked(t, models.LeaderboardBBPer100)
+	assert.Equal(t, []string{"dave", "bob", "frank", "alice"}, names)
+	assert.InDeltaSlice(t, []float64{250.0 / 7, 200.0 / 6, 200.0 / 6, -18.75}, values, 1e-9)
+
+	// dave's seven hands break the tie with bob and frank, whose own tie
+	// goes to the lower user ID
+	names, values = f.ranked(t, models.LeaderboardBiggestPot)
+	assert.Equal(t, []string{"alice", "dave", "bob", "frank"}, names)
+	assert.Equal(t, []float64{5000, 800, 800, 800}, values)
+
+	names, values = f.ranked(t, models.LeaderboardWinningStreak)
+	assert.Equal(t, []string{"dave", "bob", "frank", "alice"}, names)
+	assert.Equal(t, []float64{6, 5, 5, 2}, values)
+
+	names, values = f.ranked(t, models.LeaderboardShowdownWinRate)
+	assert.Equal(t, []string{"alice", "dave", "bob", "frank"}, names)
+	assert.Equal(t, []float64{50, 50, 50, 50}, values)
+}
+
+func TestLeaderboardPagingAndOwnPlace(t *testing.T) {
+	f := newLeaderboardFixture(t)
+
+	// Nothing is ranked until the job has run
+	board, err := f.leaderboards.Get(models.LeaderboardBBPer100, f.users["alice"], pagination.PageRequest{Limit: 2})
+	require.NoError(t, err)
+	assert.Nil(t, board.Day)
+	assert.Empty(t, board.Entries.Items)
+	assert.Nil(t, board.Me)
+
+	require.NoError(t, f.leaderboards.Refresh())
+	require.NoError(t, f.leaderboards.Refresh()) // rebuilding replaces the day's boards
+
+	board, err = f.leaderboards.Get(models.LeaderboardBBPer100, f.users["alice"], pagination.PageRequest{Limit: 2})
+	require.NoError(t, err)
+	require.NotNil(t, board.Day)
+	assert.True(t, f.day.Equal(*board.Day))
+	assert.Equal(t, 5, board.MinHands)
+	require.Len(t, board.Entries.Items, 2)
+	require.True(t, board.Entries.HasMore)
+	require.NotNil(t, board.Me)
+	assert.Equal(t, 4, board.Me.Rank)
+	assert.Equal(t, 8, board.Me.HandsPlayed)
+
+	cursor, err := pagination.DecodeCursor(board.Entries.NextCursor)
+	require.NoError(t, err)
+	board, err = f.leaderboards.Get(models.LeaderboardBBPer100, f.users["carol"], pagination.PageRequest{Limit: 2, Cursor: cursor})
+	require.NoError(t, err)
+	require.Len(t, board.Entries.Items, 2)
+	assert.Equal(t, "frank", board.Entries.Items[0].Username)
+	assert.Equal(t, "alice", board.Entries.Items[1].Username)
+	assert.False(t, board.Entries.HasMore)
+	assert.Nil(t, board.Me, "players under the threshold are not ranked")
+
+	_, err = f.leaderboards.Get("most_chips", f.users["alice"], pagination.PageRequest{Limit: 2})
+	assert.ErrorIs(t, err, ErrUnknownLeaderboard)
+}
+
+func TestLeaderboardKeepsRecentDays(t *testing.T) {
+	f := newLeaderboardFixture(t)
+	today := f.day
+
+	f.day = today.AddDate(0, 0, -leaderboardKeepDays)
+	require.NoError(t, f.leaderboards.Refresh())
+	f.day = today.AddDate(0, 0, -1)
+	require.NoError(t, f.leaderboards.Refresh())
+	f.day = today
+	require.NoError(t, f.leaderboards.Refresh())
+
+	var days []time.Time
+	require.NoError(t, f.db.Model(&models.LeaderboardEntry{}).Distinct("day").Order("day").Pluck("day", &days).Error)
+	require.Len(t, days, 2)
+	assert.True(t, today.AddDate(0, 0, -1).Equal(days[0]))
+	assert.True(t, today.Equal(days[1]))
+}
+
+func TestLiveLeaderboards(t *testing.T) {
+	f := newLeaderboardFixture(t)
+
+	// gina played last month, so she only counts over all time
+	f.addPlayer(t, "gina", uuid.New(), f.day, nil, false)
+	lastMonth := f.day.AddDate(0, -1, 0)
+	require.NoError(t, f.db.Create(&models.PlayerStatAggregate{
+		UserID: f.users["gina"], Day: lastMonth, GameType: models.GameTypeTexasHoldem, SmallBlind: 50, BigBlind: 100,
+		FirstHandAt: lastMonth, Hands: 10, BigBlindsWon: 1000,
+	}).Error)
+
+	ranked := func(period models.LeaderboardPeriod, page pagination.PageRequest) ([]string, *RankedLeaderboard) {
+		t.Helper()
+		board, err := f.leaderboards.GetRanked(models.LeaderboardBBPer100, period, f.users["alice"], page)
+		require.NoError(t, err)
+		var names []string
+		for _, entry := range board.Entries.Items {
+			names = append(names, entry.Username)
+		}
+		return names, board
+	}
+
+	// Ranked live, with nothing materialized
+	names, board := ranked(models.LeaderboardPeriodAllTime, pagination.PageRequest{Limit: 50})
+	assert.Equal(t, []string{"gina", "dave", "bob", "frank", "alice"}, names)
+	require.NotNil(t, board.Me)
+	assert.Equal(t, 5, board.Me.Rank)
+	assert.Equal(t, 5, board.Me.Players)
+	assert.Equal(t, 100.0, board.Me.TopPercent)
+	assert.Equal(t, 20.0, board.Entries.Items[0].TopPercent)
+	assert.Equal(t, models.LeaderboardBBPer100, board.Entries.Items[0].Metric)
+
+	// The week began on Monday the 4th, before the fixture's hands
+	for _, period := range []models.LeaderboardPeriod{models.LeaderboardPeriodWeek, models.LeaderboardPeriodMonth} {
+		names, board = ranked(period, pagination.PageRequest{Limit: 50})
+		assert.Equal(t, []string{"dave", "bob", "frank", "alice"}, names, period)
+		assert.Equal(t, 4, board.Me.Rank, period)
+		assert.Equal(t, 100.0, board.Me.TopPercent, period)
+	}
+
+	names, board = ranked(models.LeaderboardPeriodDay, pagination.PageRequest{Limit: 50})
+	assert.Empty(t, names)
+	assert.Nil(t, board.Me)
+
+	names, board = ranked(models.LeaderboardPeriodWeek, pagination.PageRequest{Limit: 3})
+	assert.Equal(t, []string{"dave", "bob", "frank"}, names)
+	require.True(t, board.Entries.HasMore)
+	cursor, err := pagination.DecodeCursor(board.Entries.NextCursor)
+	require.NoError(t, err)
+	names, board = ranked(models.LeaderboardPeriodWeek, pagination.PageRequest{Limit: 3, Cursor: cursor})
+	assert.Equal(t, []string{"alice"}, names)
+	assert.False(t, board.Entries.HasMore)
+
+	ranks, err := f.leaderboards.Ranks(f.users["dave"], models.LeaderboardPeriodWeek)
+	require.NoError(t, err)
+	require.Len(t, ranks, len(models.LeaderboardMetrics))
+	for i, rank := range ranks {
+		assert.Equal(t, models.LeaderboardMetrics[i], rank.Metric)
+		assert.Equal(t, 4, rank.Players)
+	}
+	assert.Equal(t, 1, ranks[0].Rank)
+	assert.Equal(t, 25.0, ranks[0].TopPercent)
+
+	ranks, err = f.leaderboards.Ranks(f.users["gina"], models.LeaderboardPeriodAllTime)
+	require.NoError(t, err)
+	require.Len(t, ranks, 1, "only ranked on the boards her totals count towards")
+	assert.Equal(t, models.LeaderboardBBPer100, ranks[0].Metric)
+
+	_, err = f.leaderboards.GetRanked("most_chips", models.LeaderboardPeriodWeek, f.users["alice"], pagination.PageRequest{Limit: 2})
+	assert.ErrorIs(t, err, ErrUnknownLeaderboard)
+	_, err = f.leaderboards.Ranks(f.users["alice"], "fortnight")
+	assert.ErrorIs(t, err, repository.ErrUnknownLeaderboardPeriod)
+}
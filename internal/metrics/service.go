@@ -2,24 +2,56 @@ package metrics
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/primoPoker/server/internal/config"
 	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/pagination"
 	"github.com/primoPoker/server/internal/repository"
+	"github.com/primoPoker/server/pkg/poker"
 )
 
 // Service handles player metrics calculations
 type Service struct {
-	handHistoryRepo *repository.HandHistoryRepository
-	userRepo        *repository.UserRepository
+	handHistoryRepo    repository.HandHistoryStore
+	aggregates         *repository.PlayerStatRepository
+	userRepo           repository.UserStore
+	sessionGap         time.Duration
+	hudEnabled         bool
+	hudMinHands        int
+	headToHeadMinHands int
+
+	cache     Cache
+	flightsMu sync.Mutex
+	flights   map[string]*flight
+}
+
+// NewService creates a new metrics service, caching player metrics in
+// memory unless the configured TTL is zero. Lifetime and period metrics
+// are summed from the totals in aggregates.
+func NewService(handHistoryRepo repository.HandHistoryStore, aggregates *repository.PlayerStatRepository, userRepo repository.UserStore, cfg config.MetricsConfig) *Service {
+	var cache Cache
+	if cfg.CacheTTL > 0 {
+		cache = NewLRUCache(cfg.CacheSize, cfg.CacheTTL)
+	}
+	return NewServiceWithCache(handHistoryRepo, aggregates, userRepo, cfg, cache)
 }
 
-// NewService creates a new metrics service
-func NewService(handHistoryRepo *repository.HandHistoryRepository, userRepo *repository.UserRepository) *Service {
+// NewServiceWithCache creates a new metrics service using cache, which may
+// be nil to always calculate metrics afresh
+func NewServiceWithCache(handHistoryRepo repository.HandHistoryStore, aggregates *repository.PlayerStatRepository, userRepo repository.UserStore, cfg config.MetricsConfig, cache Cache) *Service {
 	return &Service{
-		handHistoryRepo: handHistoryRepo,
-		userRepo:        userRepo,
+		handHistoryRepo:    handHistoryRepo,
+		aggregates:         aggregates,
+		userRepo:           userRepo,
+		sessionGap:         cfg.SessionGap,
+		hudEnabled:         cfg.HUDEnabled,
+		hudMinHands:        cfg.HUDMinHands,
+		headToHeadMinHands: cfg.HeadToHeadMinHands,
+		cache:              cache,
+		flights:            make(map[string]*flight),
 	}
 }
 
@@ -44,18 +76,33 @@ type PlayerMetrics struct {
 	PFRPercent     float64 `json:"pfr_percent"`     // Pre-Flop Raise
 	ThreeBetPercent float64 `json:"three_bet_percent"` // 3-bet frequency
 	FoldToThreeBetPercent float64 `json:"fold_to_three_bet_percent"` // Fold to 3-bet
+	FourBetPercent        float64 `json:"four_bet_percent"`          // 4-bet frequency
+	FoldToFourBetPercent  float64 `json:"fold_to_four_bet_percent"`  // Fold to 4-bet
+	
+	// Blind Stealing
+	AttemptToStealPercent  float64 `json:"attempt_to_steal_percent"`   // Open-raise from CO, BTN or SB when folded to
+	FoldToStealPercent     float64 `json:"fold_to_steal_percent"`      // Fold in the blinds to a steal
+	ThreeBetVsStealPercent float64 `json:"three_bet_vs_steal_percent"` // Re-raise in the blinds against a steal
+	WalkPercent            float64 `json:"walk_percent"`               // Big blinds won uncontested
 	
 	// Post-Flop Play
 	CBetPercent         float64 `json:"cbet_percent"`          // Continuation bet
 	FoldToCBetPercent   float64 `json:"fold_to_cbet_percent"`  // Fold to c-bet
 	AggressionFactor    float64 `json:"aggression_factor"`     // (Bet + Raise) / Call
 	
-	// Showdown Statistics
-	WentToShowdown      int     `json:"went_to_showdown"`
-	WonAtShowdown       int     `json:"won_at_showdown"`
-	ShowdownWinRate     float64 `json:"showdown_win_rate"`
+	// Showdown Statistics, out of the hands where the player saw the flop
+	FlopsSeen           int     `json:"flops_seen"`
+	WTSDPercent         float64 `json:"wtsd_percent"` // Went to showdown
+	WSDPercent          float64 `json:"wsd_percent"`  // Won money at showdown, of showdowns
+	WWSFPercent         float64 `json:"wwsf_percent"` // Won money when saw flop, at showdown or not
 	WonDollarAtShowdown int64   `json:"won_dollar_at_showdown"`
 	
+	// Deprecated: counted over every hand, including those that never
+	// reached the flop; use FlopsSeen, WTSDPercent and WSDPercent
+	WentToShowdown  int     `json:"went_to_showdown"`
+	WonAtShowdown   int     `json:"won_at_showdown"`
+	ShowdownWinRate float64 `json:"showdown_win_rate"`
+	
 	// Financial Statistics
 	TotalWagered    int64   `json:"total_wagered"`
 	TotalWon        int64   `json:"total_won"`
@@ -64,239 +111,286 @@ type PlayerMetrics struct {
 	AvgWinAmount    float64 `json:"avg_win_amount"`
 	BiggestWin      int64   `json:"biggest_win"`
 	BiggestLoss     int64   `json:"biggest_loss"`
+	BBPer100        float64 `json:"bb_per_100"` // Big blinds won per 100 hands, each hand in its own big blind
+	TableTime       int     `json:"table_time"` // Seconds at the table, leaving out breaks as long as the session gap
+	ChipsPerHour    float64 `json:"chips_per_hour"`
+	BBPerHour       float64 `json:"bb_per_hour"`
+	SmallSample     bool    `json:"small_sample"` // Too few hands for the win rates to mean much
+	
+	// Variance covers the swings in the player's results over the period
+	Variance *Variance `json:"variance"`
+	
+	// Stakes breaks the totals down by game type and blind level
+	Stakes []StakeMetrics `json:"stakes,omitempty"`
+}
+
+// GetPlayerMetrics calculates comprehensive player metrics for a given time
+// period, overall and for each stake played, from the hands passing filter.
+// Results are cached until the user plays another hand or the TTL passes.
+func (s *Service) GetPlayerMetrics(userID uuid.UUID, since *time.Time, filter Filter) (*PlayerMetrics, error) {
+	key := CacheKey{UserID: userID, Since: since, Filter: filter}
+	return s.cachedMetrics(key, func() (*PlayerMetrics, error) {
+		return s.calculatePlayerMetrics(userID, since, filter)
+	})
 }
 
-// GetPlayerMetrics calculates comprehensive player metrics for a given time period
-func (s *Service) GetPlayerMetrics(userID uuid.UUID, since *time.Time) (*PlayerMetrics, error) {
+// calculatePlayerMetrics calculates the user's metrics from their stored
+// totals, or from their latest hands when the service has none
+func (s *Service) calculatePlayerMetrics(userID uuid.UUID, since *time.Time, filter Filter) (*PlayerMetrics, error) {
 	// Get user information
 	user, err := s.userRepo.GetByID(userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 	
+	if s.aggregates != nil {
+		return s.calculateFromAggregates(userID, user.Username, since, filter)
+	}
+	
 	// Get hand history for the period
 	var hands []models.HandHistory
 	if since != nil {
 		hands, err = s.handHistoryRepo.GetHandsByTimeRange(userID, *since, time.Now())
 	} else {
 		// Get all hands (use a reasonable limit for performance)
-		hands, err = s.handHistoryRepo.GetUserHandHistory(userID, 10000, 0)
+		var page *pagination.PageResponse[models.HandHistory]
+		page, err = s.handHistoryRepo.GetUserHandHistory(userID, pagination.PageRequest{Limit: 10000})
+		if page != nil {
+			hands = page.Items
+		}
 	}
 	
 	if err != nil {
 		return nil, fmt.Errorf("failed to get hand history: %w", err)
 	}
 	
+	hands = filter.apply(hands)
 	if len(hands) == 0 {
 		return s.emptyMetrics(userID, user.Username, since), nil
 	}
 	
-	return s.calculateMetrics(userID, user.Username, hands, since)
-}
-
-// calculateMetrics performs the comprehensive metrics calculation
-func (s *Service) calculateMetrics(userID uuid.UUID, username string, hands []models.HandHistory, since *time.Time) (*PlayerMetrics, error) {
-	metrics := &PlayerMetrics{
-		UserID:   userID,
-		Username: username,
-	}
-	
-	// Set time period
-	if since != nil {
-		metrics.PeriodStart = *since
-	} else {
-		metrics.PeriodStart = hands[len(hands)-1].StartedAt // oldest hand
-	}
-	metrics.PeriodEnd = time.Now()
-	
-	// Initialize counters
-	var (
-		totalHands = len(hands)
-		handsWon = 0
-		handsFolded = 0
-		wentToShowdown = 0
-		wonAtShowdown = 0
-		
-		// Financial tracking
-		totalWagered int64 = 0
-		totalWon int64 = 0
-		biggestWin int64 = 0
-		biggestLoss int64 = 0
-		potSizeSum float64 = 0
-		
-		// Action tracking for advanced metrics
-		preFlopRaises = 0
-		preFlopVPIP = 0
-		threeBets = 0
-		foldToThreeBets = 0
-		cBets = 0
-		foldToCBets = 0
-		
-		// Aggression tracking
-		aggressiveActions = 0
-		passiveActions = 0
-		
-		wonDollarAtShowdown int64 = 0
-	)
-	
-	// Process each hand
-	for _, hand := range hands {
-		// Basic statistics
-		if hand.IsWinner {
-			handsWon++
-		}
-		if hand.FoldedPhase != "" {
-			handsFolded++
-		}
-		if hand.WentToShowdown {
-			wentToShowdown++
-			if hand.IsWinner {
-				wonAtShowdown++
-				wonDollarAtShowdown += hand.AmountWon
-			}
-		}
-		
-		// Financial statistics
-		wagered := hand.StartingChips - hand.EndingChips + hand.AmountWon
-		totalWagered += wagered
-		totalWon += hand.AmountWon
-		
-		if hand.NetResult > biggestWin {
-			biggestWin = hand.NetResult
-		}
-		if hand.NetResult < biggestLoss {
-			biggestLoss = hand.NetResult
-		}
-		
-		potSizeSum += float64(hand.PotSize)
-		
-		// Advanced metrics calculation
-		s.calculateHandMetrics(&hand, &preFlopVPIP, &preFlopRaises, &threeBets, &foldToThreeBets, &cBets, &foldToCBets, &aggressiveActions, &passiveActions)
-	}
-	
-	// Calculate percentages and averages
-	metrics.HandsPlayed = totalHands
-	metrics.HandsWon = handsWon
-	metrics.HandsLost = totalHands - handsWon - handsFolded
-	metrics.HandsFolded = handsFolded
-	
-	if totalHands > 0 {
-		metrics.WinRate = float64(handsWon) / float64(totalHands) * 100.0
-		metrics.VPIPPercent = float64(preFlopVPIP) / float64(totalHands) * 100.0
-		metrics.PFRPercent = float64(preFlopRaises) / float64(totalHands) * 100.0
-		metrics.AvgPotSize = potSizeSum / float64(totalHands)
-	}
-	
-	// 3-bet calculations (estimate based on raising actions)
-	if preFlopRaises > 0 {
-		metrics.ThreeBetPercent = float64(threeBets) / float64(preFlopRaises) * 100.0
-	}
-	
-	// Fold to 3-bet calculations
-	threeBeOpportunities := threeBets + foldToThreeBets
-	if threeBeOpportunities > 0 {
-		metrics.FoldToThreeBetPercent = float64(foldToThreeBets) / float64(threeBeOpportunities) * 100.0
-	}
-	
-	// C-bet calculations (post-flop continuation betting)
-	cBetOpportunities := cBets + foldToCBets
-	if cBetOpportunities > 0 {
-		metrics.CBetPercent = float64(cBets) / float64(cBetOpportunities) * 100.0
-		metrics.FoldToCBetPercent = float64(foldToCBets) / float64(cBetOpportunities) * 100.0
-	}
-	
-	// Aggression factor
-	if passiveActions > 0 {
-		metrics.AggressionFactor = float64(aggressiveActions) / float64(passiveActions)
-	} else if aggressiveActions > 0 {
-		metrics.AggressionFactor = 999.0 // Very aggressive
+	metrics, err := s.calculateMetrics(userID, user.Username, hands, since)
+	if err != nil {
+		return nil, err
 	}
 	
-	// Showdown statistics
-	metrics.WentToShowdown = wentToShowdown
-	metrics.WonAtShowdown = wonAtShowdown
-	if wentToShowdown > 0 {
-		metrics.ShowdownWinRate = float64(wonAtShowdown) / float64(wentToShowdown) * 100.0
+	metrics.Stakes, err = s.calculateStakeMetrics(userID, user.Username, hands, since)
+	if err != nil {
+		return nil, err
 	}
-	metrics.WonDollarAtShowdown = wonDollarAtShowdown
 	
-	// Financial metrics
-	metrics.TotalWagered = totalWagered
-	metrics.TotalWon = totalWon
-	metrics.NetResult = totalWon - totalWagered
-	if handsWon > 0 {
-		metrics.AvgWinAmount = float64(totalWon) / float64(handsWon)
+	return metrics, nil
+}
+
+// calculateMetrics performs the comprehensive metrics calculation
+func (s *Service) calculateMetrics(userID uuid.UUID, username string, hands []models.HandHistory, since *time.Time) (*PlayerMetrics, error) {
+	var totals tally
+	times := tableTimes(hands, s.sessionGap)
+	for i := range hands {
+		totals.addHand(&hands[i], times[i])
 	}
-	metrics.BiggestWin = biggestWin
-	metrics.BiggestLoss = biggestLoss
-	
+	metrics := totals.metrics(userID, username, since)
+	metrics.Variance = calculateVariance(hands, s.sessionGap)
 	return metrics, nil
 }
 
+// actionCounts tallies the betting actions behind the frequency metrics.
+// The *Chances and faced* counts are the hands where the player had the
+// option; action only returns to a player after a raise, so each comes up
+// at most once a hand.
+type actionCounts struct {
+	vpip int
+	pfr  int
+
+	threeBetChances int
+	threeBets       int
+	facedThreeBet   int
+	foldsToThreeBet int
+	fourBetChances  int
+	fourBets        int
+	facedFourBet    int
+	foldsToFourBet  int
+
+	stealChances     int
+	steals           int
+	facedSteal       int
+	foldsToSteal     int
+	threeBetsVsSteal int
+	bigBlindHands    int
+	walks            int
+
+	cBets       int
+	foldToCBets int
+
+	aggressive int
+	passive    int
+}
+
 // calculateHandMetrics extracts metrics from individual hand actions
-func (s *Service) calculateHandMetrics(hand *models.HandHistory, preFlopVPIP, preFlopRaises, threeBets, foldToThreeBets, cBets, foldToCBets, aggressiveActions, passiveActions *int) {
-	// Analyze pre-flop actions for VPIP and PFR
-	voluntarilyPutMoney := false
-	raisedPreFlop := false
-	
-	for _, action := range hand.PreFlopActions {
-		switch action.Action {
-		case models.ActionBet, models.ActionRaise:
-			voluntarilyPutMoney = true
-			raisedPreFlop = true
-			*aggressiveActions++
-			
-			// Simple heuristic for 3-bet: if amount is significantly higher than previous
-			if action.Amount > hand.BigBlind*3 {
-				*threeBets++
-			}
-		case models.ActionCall:
-			voluntarilyPutMoney = true
-			*passiveActions++
-		case models.ActionCheck:
-			*passiveActions++
-		case models.ActionFold:
-			// If folding to a large raise, count as fold to 3-bet
-			if action.ChipsBefore-action.ChipsAfter == 0 { // didn't put money in
-				*foldToThreeBets++
-			}
-		}
-	}
-	
-	if voluntarilyPutMoney {
-		*preFlopVPIP++
-	}
-	if raisedPreFlop {
-		*preFlopRaises++
-	}
+func calculateHandMetrics(hand *models.HandHistory, counts *actionCounts) {
+	countPreFlop(hand, counts)
 	
 	// Analyze post-flop actions for c-bet
 	postFlopActions := append(hand.FlopActions, hand.TurnActions...)
 	postFlopActions = append(postFlopActions, hand.RiverActions...)
 	
 	for _, action := range postFlopActions {
+		if action.PlayerID != hand.UserID {
+			continue
+		}
 		switch action.Action {
 		case models.ActionBet:
-			*cBets++
-			*aggressiveActions++
+			counts.cBets++
+			counts.aggressive++
 		case models.ActionRaise:
-			*aggressiveActions++
+			counts.aggressive++
 		case models.ActionCall:
-			*passiveActions++
+			counts.passive++
 		case models.ActionCheck:
-			*passiveActions++
+			counts.passive++
 		case models.ActionFold:
-			*foldToCBets++
+			counts.foldToCBets++
 		}
 	}
 }
 
+// countPreFlop walks the whole table's pre-flop actions, tracking how many
+// raises the player faced each time they acted. The blinds are not in the
+// action list, so the first raise is the open and the next one a 3-bet.
+// Steal statistics need positions, which hands recorded before they were
+// stored lack.
+func countPreFlop(hand *models.HandHistory, counts *actionCounts) {
+	var (
+		raises      int   // raises so far, the open being the first
+		heroLevel   int   // raise the player last made: 1 open, 2 3-bet, 3 4-bet
+		highest     int64 // most any player has put in
+		committed   = make(map[uuid.UUID]int64)
+		voluntarily bool
+		raised      bool
+		acted       bool
+		unopened    = true // only folds so far
+		stealing    bool   // a steal is the only action since the folds
+		position    = poker.Position(hand.Position)
+	)
+
+	if position == poker.PositionBB {
+		counts.bigBlindHands++
+	}
+
+	for _, action := range hand.PreFlopActions {
+		committed[action.PlayerID] += action.Amount
+		isRaise := action.Action == models.ActionBet || action.Action == models.ActionRaise ||
+			(action.Action == models.ActionAllIn && committed[action.PlayerID] > highest)
+		if committed[action.PlayerID] > highest {
+			highest = committed[action.PlayerID]
+		}
+		folded := action.Action == models.ActionFold
+
+		if action.PlayerID == hand.UserID {
+			if !acted && unopened && position.IsStealPosition() {
+				counts.stealChances++
+				if isRaise {
+					counts.steals++
+				}
+			}
+			if stealing && position.IsBlind() {
+				counts.facedSteal++
+				switch {
+				case folded:
+					counts.foldsToSteal++
+				case isRaise:
+					counts.threeBetsVsSteal++
+				}
+			}
+			acted = true
+
+			switch raises {
+			case 1:
+				counts.threeBetChances++
+				if isRaise {
+					counts.threeBets++
+				}
+			case 2:
+				counts.fourBetChances++
+				if isRaise {
+					counts.fourBets++
+				}
+				if heroLevel == 1 {
+					counts.facedThreeBet++
+					if folded {
+						counts.foldsToThreeBet++
+					}
+				}
+			case 3:
+				if heroLevel == 2 {
+					counts.facedFourBet++
+					if folded {
+						counts.foldsToFourBet++
+					}
+				}
+			}
+
+			switch {
+			case isRaise:
+				voluntarily, raised = true, true
+				counts.aggressive++
+			case action.Action == models.ActionCall || action.Action == models.ActionAllIn:
+				voluntarily = true
+				counts.passive++
+			case action.Action == models.ActionCheck:
+				counts.passive++
+			}
+		}
+
+		if !folded {
+			stealing = unopened && isRaise && poker.Position(action.Position).IsStealPosition()
+			unopened = false
+		}
+		if isRaise {
+			raises++
+			if action.PlayerID == hand.UserID {
+				heroLevel = raises
+			}
+		}
+	}
+
+	if position == poker.PositionBB && !acted && unopened {
+		counts.walks++
+	}
+	if voluntarily {
+		counts.vpip++
+	}
+	if raised {
+		counts.pfr++
+	}
+}
+
+// sawFlop checks if the player was still in the hand when the flop was
+// dealt. Hands recorded without board cards count as reaching the flop when
+// anyone acted on it.
+func sawFlop(hand *models.HandHistory) bool {
+	if hand.FoldedPhase == models.HandPhasePreFlop {
+		return false
+	}
+	return hand.FlopCard1Rank != "" || len(hand.FlopActions) > 0
+}
+
+// percentOf returns n as a percentage of total, or zero when total is zero
+func percentOf(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total) * 100.0
+}
+
 // emptyMetrics returns empty metrics structure for users with no hands
 func (s *Service) emptyMetrics(userID uuid.UUID, username string, since *time.Time) *PlayerMetrics {
 	metrics := &PlayerMetrics{
 		UserID:   userID,
 		Username: username,
 		PeriodEnd: time.Now(),
+		SmallSample: true,
+		Variance: &Variance{},
 	}
 	
 	if since != nil {
@@ -308,8 +402,24 @@ func (s *Service) emptyMetrics(userID uuid.UUID, username string, since *time.Ti
 	return metrics
 }
 
+// MetricsComparison is a player's metrics over two periods and how their
+// win rates moved from the first to the second
+type MetricsComparison struct {
+	Period1 *PlayerMetrics `json:"period1"`
+	Period2 *PlayerMetrics `json:"period2"`
+	Change  RateChange     `json:"change"`
+}
+
+// RateChange is the second period's win rates less the first's
+type RateChange struct {
+	BBPer100     float64 `json:"bb_per_100"`
+	BBPerHour    float64 `json:"bb_per_hour"`
+	ChipsPerHour float64 `json:"chips_per_hour"`
+	SmallSample  bool    `json:"small_sample"` // Either period has too few hands to compare
+}
+
 // GetPlayerMetricsComparison compares player metrics across different time periods
-func (s *Service) GetPlayerMetricsComparison(userID uuid.UUID, period1Start, period1End, period2Start, period2End time.Time) (map[string]*PlayerMetrics, error) {
+func (s *Service) GetPlayerMetricsComparison(userID uuid.UUID, period1Start, period1End, period2Start, period2End time.Time) (*MetricsComparison, error) {
 	period1Metrics, err := s.getMetricsForPeriod(userID, period1Start, period1End)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get period 1 metrics: %w", err)
@@ -320,9 +430,15 @@ func (s *Service) GetPlayerMetricsComparison(userID uuid.UUID, period1Start, per
 		return nil, fmt.Errorf("failed to get period 2 metrics: %w", err)
 	}
 	
-	return map[string]*PlayerMetrics{
-		"period1": period1Metrics,
-		"period2": period2Metrics,
+	return &MetricsComparison{
+		Period1: period1Metrics,
+		Period2: period2Metrics,
+		Change: RateChange{
+			BBPer100:     period2Metrics.BBPer100 - period1Metrics.BBPer100,
+			BBPerHour:    period2Metrics.BBPerHour - period1Metrics.BBPerHour,
+			ChipsPerHour: period2Metrics.ChipsPerHour - period1Metrics.ChipsPerHour,
+			SmallSample:  period1Metrics.SmallSample || period2Metrics.SmallSample,
+		},
 	}, nil
 }
 
@@ -6,45 +6,312 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/pkg/poker"
 	"github.com/stretchr/testify/assert"
 )
 
+// preFlop builds a hand whose pre-flop actions are taken in turn by the
+// given players, hero being the first ID
+func preFlop(hero uuid.UUID, steps ...models.PlayerActionRecord) models.HandHistory {
+	return models.HandHistory{UserID: hero, BigBlind: 100, PreFlopActions: steps}
+}
+
+func act(player uuid.UUID, action models.PlayerAction, amount int64) models.PlayerActionRecord {
+	return models.PlayerActionRecord{PlayerID: player, Action: action, Amount: amount}
+}
+
 func TestCalculateHandMetrics(t *testing.T) {
-	service := &Service{}
-	
-	userID := uuid.New()
-	hand := models.HandHistory{
-		UserID:     userID,
-		BigBlind:   100,
-		PreFlopActions: []models.PlayerActionRecord{
-			{
-				PlayerID: userID,
-				Action:   models.ActionRaise,
-				Amount:   400, // 4x big blind - should count as 3-bet
+	hero, villain, other := uuid.New(), uuid.New(), uuid.New()
+
+	cases := []struct {
+		name string
+		hand models.HandHistory
+		want actionCounts
+	}{
+		{
+			name: "limped pot",
+			hand: preFlop(hero,
+				act(villain, models.ActionCall, 100),
+				act(hero, models.ActionCall, 100),
+				act(other, models.ActionCheck, 0),
+			),
+			want: actionCounts{vpip: 1, passive: 1},
+		},
+		{
+			name: "hero opens a single-raised pot",
+			hand: preFlop(hero,
+				act(hero, models.ActionRaise, 300),
+				act(villain, models.ActionCall, 300),
+				act(other, models.ActionFold, 0),
+			),
+			want: actionCounts{vpip: 1, pfr: 1, aggressive: 1},
+		},
+		{
+			name: "hero calls an open",
+			hand: preFlop(hero,
+				act(villain, models.ActionRaise, 300),
+				act(hero, models.ActionCall, 300),
+			),
+			want: actionCounts{vpip: 1, threeBetChances: 1, passive: 1},
+		},
+		{
+			name: "hero 3-bets an open of any size",
+			hand: preFlop(hero,
+				act(villain, models.ActionRaise, 250),
+				act(hero, models.ActionRaise, 250),
+				act(villain, models.ActionFold, 0),
+			),
+			want: actionCounts{vpip: 1, pfr: 1, threeBetChances: 1, threeBets: 1, aggressive: 1},
+		},
+		{
+			name: "a big open is not a 3-bet",
+			hand: preFlop(hero,
+				act(other, models.ActionFold, 0),
+				act(hero, models.ActionRaise, 600),
+			),
+			want: actionCounts{vpip: 1, pfr: 1, aggressive: 1},
+		},
+		{
+			name: "hero opens and folds to a 3-bet",
+			hand: preFlop(hero,
+				act(hero, models.ActionRaise, 300),
+				act(villain, models.ActionRaise, 900),
+				act(other, models.ActionFold, 0),
+				act(hero, models.ActionFold, 0),
+			),
+			want: actionCounts{vpip: 1, pfr: 1, aggressive: 1, facedThreeBet: 1, foldsToThreeBet: 1, fourBetChances: 1},
+		},
+		{
+			name: "hero opens and 4-bets",
+			hand: preFlop(hero,
+				act(hero, models.ActionRaise, 300),
+				act(villain, models.ActionRaise, 900),
+				act(hero, models.ActionAllIn, 9700),
+				act(villain, models.ActionFold, 0),
+			),
+			want: actionCounts{vpip: 1, pfr: 1, aggressive: 2, facedThreeBet: 1, fourBetChances: 1, fourBets: 1},
+		},
+		{
+			name: "hero cold calls a 3-bet",
+			hand: preFlop(hero,
+				act(villain, models.ActionRaise, 300),
+				act(other, models.ActionRaise, 900),
+				act(hero, models.ActionCall, 1000),
+			),
+			want: actionCounts{vpip: 1, passive: 1, fourBetChances: 1},
+		},
+		{
+			name: "hero 3-bets and folds to a 4-bet",
+			hand: preFlop(hero,
+				act(villain, models.ActionRaise, 300),
+				act(hero, models.ActionRaise, 900),
+				act(villain, models.ActionRaise, 1800),
+				act(hero, models.ActionFold, 0),
+			),
+			want: actionCounts{vpip: 1, pfr: 1, aggressive: 1, threeBetChances: 1, threeBets: 1, facedFourBet: 1, foldsToFourBet: 1},
+		},
+		{
+			name: "a short all-in call is not a raise",
+			hand: preFlop(hero,
+				act(villain, models.ActionRaise, 300),
+				act(other, models.ActionAllIn, 150),
+				act(hero, models.ActionRaise, 900),
+			),
+			want: actionCounts{vpip: 1, pfr: 1, aggressive: 1, threeBetChances: 1, threeBets: 1},
+		},
+		{
+			name: "hero folds without a chance to raise back",
+			hand: preFlop(hero,
+				act(hero, models.ActionFold, 0),
+				act(villain, models.ActionRaise, 300),
+				act(other, models.ActionRaise, 900),
+			),
+			want: actionCounts{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var counts actionCounts
+			calculateHandMetrics(&tc.hand, &counts)
+			assert.Equal(t, tc.want, counts)
+		})
+	}
+}
+
+// seated gives an action the position it was taken from
+func seated(position poker.Position, record models.PlayerActionRecord) models.PlayerActionRecord {
+	record.Position = string(position)
+	return record
+}
+
+func TestStealStatistics(t *testing.T) {
+	hero, utg, co, btn, sb, bb := uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New()
+
+	cases := []struct {
+		name     string
+		position poker.Position
+		actions  []models.PlayerActionRecord
+		want     actionCounts
+	}{
+		{
+			name:     "button open after two folds is a steal",
+			position: poker.PositionButton,
+			actions: []models.PlayerActionRecord{
+				seated(poker.PositionUTG, act(utg, models.ActionFold, 0)),
+				seated(poker.PositionCO, act(co, models.ActionFold, 0)),
+				seated(poker.PositionButton, act(hero, models.ActionRaise, 250)),
+				seated(poker.PositionSB, act(sb, models.ActionFold, 0)),
+				seated(poker.PositionBB, act(bb, models.ActionFold, 0)),
+			},
+			want: actionCounts{vpip: 1, pfr: 1, aggressive: 1, stealChances: 1, steals: 1},
+		},
+		{
+			name:     "button open after a limper is not a steal",
+			position: poker.PositionButton,
+			actions: []models.PlayerActionRecord{
+				seated(poker.PositionUTG, act(utg, models.ActionCall, 100)),
+				seated(poker.PositionCO, act(co, models.ActionFold, 0)),
+				seated(poker.PositionButton, act(hero, models.ActionRaise, 400)),
 			},
+			want: actionCounts{vpip: 1, pfr: 1, aggressive: 1},
 		},
-		FlopActions: []models.PlayerActionRecord{
-			{
-				PlayerID: userID,
-				Action:   models.ActionBet,
-				Amount:   300,
+		{
+			name:     "button limp when folded to is a missed steal",
+			position: poker.PositionButton,
+			actions: []models.PlayerActionRecord{
+				seated(poker.PositionUTG, act(utg, models.ActionFold, 0)),
+				seated(poker.PositionButton, act(hero, models.ActionCall, 100)),
+			},
+			want: actionCounts{vpip: 1, passive: 1, stealChances: 1},
+		},
+		{
+			name:     "UTG open is never a steal",
+			position: poker.PositionUTG,
+			actions: []models.PlayerActionRecord{
+				seated(poker.PositionUTG, act(hero, models.ActionRaise, 300)),
 			},
+			want: actionCounts{vpip: 1, pfr: 1, aggressive: 1},
+		},
+		{
+			name:     "big blind folds to a cutoff steal",
+			position: poker.PositionBB,
+			actions: []models.PlayerActionRecord{
+				seated(poker.PositionUTG, act(utg, models.ActionFold, 0)),
+				seated(poker.PositionCO, act(co, models.ActionRaise, 300)),
+				seated(poker.PositionButton, act(btn, models.ActionFold, 0)),
+				seated(poker.PositionSB, act(sb, models.ActionFold, 0)),
+				seated(poker.PositionBB, act(hero, models.ActionFold, 0)),
+			},
+			want: actionCounts{bigBlindHands: 1, facedSteal: 1, foldsToSteal: 1, threeBetChances: 1},
+		},
+		{
+			name:     "small blind 3-bets a button steal",
+			position: poker.PositionSB,
+			actions: []models.PlayerActionRecord{
+				seated(poker.PositionButton, act(btn, models.ActionRaise, 250)),
+				seated(poker.PositionSB, act(hero, models.ActionRaise, 1000)),
+			},
+			want: actionCounts{vpip: 1, pfr: 1, aggressive: 1, facedSteal: 1, threeBetsVsSteal: 1, threeBetChances: 1, threeBets: 1},
+		},
+		{
+			name:     "a call after the steal ends it for the big blind",
+			position: poker.PositionBB,
+			actions: []models.PlayerActionRecord{
+				seated(poker.PositionButton, act(btn, models.ActionRaise, 250)),
+				seated(poker.PositionSB, act(sb, models.ActionCall, 200)),
+				seated(poker.PositionBB, act(hero, models.ActionFold, 0)),
+			},
+			want: actionCounts{bigBlindHands: 1, threeBetChances: 1},
+		},
+		{
+			name:     "everyone folds to the big blind",
+			position: poker.PositionBB,
+			actions: []models.PlayerActionRecord{
+				seated(poker.PositionCO, act(co, models.ActionFold, 0)),
+				seated(poker.PositionButton, act(btn, models.ActionFold, 0)),
+				seated(poker.PositionSB, act(sb, models.ActionFold, 0)),
+			},
+			want: actionCounts{bigBlindHands: 1, walks: 1},
 		},
 	}
-	
-	var (
-		preFlopVPIP, preFlopRaises, threeBets, foldToThreeBets int
-		cBets, foldToCBets, aggressiveActions, passiveActions int
-	)
-	
-	service.calculateHandMetrics(&hand, &preFlopVPIP, &preFlopRaises, &threeBets, &foldToThreeBets, &cBets, &foldToCBets, &aggressiveActions, &passiveActions)
-	
-	assert.Equal(t, 1, preFlopVPIP)     // Put money in voluntarily
-	assert.Equal(t, 1, preFlopRaises)   // Raised pre-flop
-	assert.Equal(t, 1, threeBets)       // 4x BB should count as 3-bet
-	assert.Equal(t, 1, cBets)           // Bet on flop (continuation bet)
-	assert.Equal(t, 2, aggressiveActions) // Raise + Bet
-	assert.Equal(t, 0, passiveActions)  // No calls or checks
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			hand := preFlop(hero, tc.actions...)
+			hand.Position = string(tc.position)
+
+			var counts actionCounts
+			calculateHandMetrics(&hand, &counts)
+			assert.Equal(t, tc.want, counts)
+		})
+	}
+}
+
+func TestReRaisePercentages(t *testing.T) {
+	service := &Service{}
+	hero, villain := uuid.New(), uuid.New()
+
+	hands := []models.HandHistory{
+		// 3-bet, then folded to the 4-bet
+		preFlop(hero, act(villain, models.ActionRaise, 300), act(hero, models.ActionRaise, 900), act(villain, models.ActionRaise, 1800), act(hero, models.ActionFold, 0)),
+		// Called an open
+		preFlop(hero, act(villain, models.ActionRaise, 300), act(hero, models.ActionCall, 300)),
+		// Opened, folded to a 3-bet
+		preFlop(hero, act(hero, models.ActionRaise, 300), act(villain, models.ActionRaise, 900), act(hero, models.ActionFold, 0)),
+		// Opened, called a 3-bet
+		preFlop(hero, act(hero, models.ActionRaise, 300), act(villain, models.ActionRaise, 900), act(hero, models.ActionCall, 600)),
+	}
+
+	metrics, err := service.calculateMetrics(hero, "hero", hands, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(50), metrics.ThreeBetPercent)       // 1 of 2 chances
+	assert.Equal(t, float64(50), metrics.FoldToThreeBetPercent) // 1 of 2 faced
+	assert.Equal(t, float64(0), metrics.FourBetPercent)         // 0 of 2 chances
+	assert.Equal(t, float64(100), metrics.FoldToFourBetPercent) // 1 of 1 faced
+}
+
+func TestShowdownPercentages(t *testing.T) {
+	service := &Service{}
+	hero := uuid.New()
+
+	flop := func(h models.HandHistory) models.HandHistory {
+		h.FlopCard1Rank, h.FlopCard2Rank, h.FlopCard3Rank = "A", "7", "2"
+		return h
+	}
+	hand := func(h models.HandHistory) models.HandHistory {
+		h.UserID = hero
+		h.BigBlind = 100
+		return h
+	}
+
+	hands := []models.HandHistory{
+		// Ended pre-flop: won by raising, lost by folding. Neither saw a flop.
+		hand(models.HandHistory{IsWinner: true, AmountWon: 250}),
+		hand(models.HandHistory{FoldedPhase: models.HandPhasePreFlop}),
+		// Folded pre-flop although the flop was dealt to the others
+		flop(hand(models.HandHistory{FoldedPhase: models.HandPhasePreFlop})),
+		// Took the pot on the turn without a showdown
+		flop(hand(models.HandHistory{IsWinner: true, AmountWon: 600})),
+		// Folded on the flop
+		flop(hand(models.HandHistory{FoldedPhase: models.HandPhaseFlop})),
+		// Won a showdown, lost one, and chopped one
+		flop(hand(models.HandHistory{WentToShowdown: true, IsWinner: true, AmountWon: 1200})),
+		flop(hand(models.HandHistory{WentToShowdown: true})),
+		flop(hand(models.HandHistory{WentToShowdown: true, IsWinner: true, AmountWon: 400})),
+	}
+
+	metrics, err := service.calculateMetrics(hero, "hero", hands, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, metrics.FlopsSeen)
+	assert.Equal(t, float64(60), metrics.WTSDPercent)    // 3 of 5 flops
+	assert.InDelta(t, 66.667, metrics.WSDPercent, 0.001) // 2 of 3 showdowns
+	assert.Equal(t, float64(60), metrics.WWSFPercent)    // 3 of 5 flops
+	assert.Equal(t, int64(1600), metrics.WonDollarAtShowdown)
+
+	// The deprecated counters are unchanged
+	assert.Equal(t, 3, metrics.WentToShowdown)
+	assert.Equal(t, 2, metrics.WonAtShowdown)
 }
 
 func TestEmptyMetrics(t *testing.T) {
@@ -0,0 +1,273 @@
+package metrics
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/pagination"
+)
+
+// sessionBatchSize is how many hands are read at a time while looking for
+// session boundaries
+var sessionBatchSize = 500
+
+// ErrSessionNotFound is returned for a session ID that does not start one
+// of the user's sessions
+var ErrSessionNotFound = errors.New("session not found")
+
+// errSessionComplete stops streaming hands once a session's end is found
+var errSessionComplete = errors.New("session complete")
+
+// Session is a stretch of play without a break as long as the session
+// gap. Hands at tables played at the same time all count towards it.
+type Session struct {
+	// ID is the ID of the session's first hand
+	ID          uuid.UUID      `json:"id"`
+	StartedAt   time.Time      `json:"started_at"`
+	EndedAt     time.Time      `json:"ended_at"`
+	Duration    int            `json:"duration"` // seconds
+	HandsPlayed int            `json:"hands_played"`
+	NetResult   int64          `json:"net_result"`
+	BBPer100    float64        `json:"bb_per_100"`
+	BiggestPot  int64          `json:"biggest_pot"`
+	Tables      []SessionTable `json:"tables"`
+
+	hands []models.HandHistory
+}
+
+// SessionTable is one table played during a session
+type SessionTable struct {
+	GameID      uuid.UUID `json:"game_id"`
+	Name        string    `json:"name"`
+	HandsPlayed int       `json:"hands_played"`
+	NetResult   int64     `json:"net_result"`
+}
+
+// SessionHand is one hand of a session
+type SessionHand struct {
+	ID         uuid.UUID `json:"id"`
+	GameID     uuid.UUID `json:"game_id"`
+	TableName  string    `json:"table_name"`
+	HandNumber int       `json:"hand_number"`
+	StartedAt  time.Time `json:"started_at"`
+	BigBlind   int64     `json:"big_blind"`
+	PotSize    int64     `json:"pot_size"`
+	NetResult  int64     `json:"net_result"`
+	IsWinner   bool      `json:"is_winner"`
+}
+
+// SessionDetail is a session with its hands in the order they were played
+type SessionDetail struct {
+	Session
+	Hands []SessionHand `json:"hands"`
+}
+
+// GetSessions returns a page of a user's sessions, newest first. The
+// cursor is the start of the last session on the previous page.
+func (s *Service) GetSessions(userID uuid.UUID, page pagination.PageRequest) (*pagination.PageResponse[Session], error) {
+	// Walk back through the user's hands until enough sessions are known
+	// to be complete; the oldest one seen may still continue further back
+	var hands []models.HandHistory
+	var sessions []Session
+	cursor := page.Cursor
+	for {
+		batch, err := s.handHistoryRepo.GetUserHandHistory(userID, pagination.PageRequest{Limit: sessionBatchSize, Cursor: cursor})
+		if err != nil {
+			return nil, err
+		}
+		hands = append(hands, batch.Items...)
+
+		sessions = inferSessions(hands, s.sessionGap)
+		if batch.HasMore && len(sessions) > 0 {
+			sessions = sessions[1:]
+		}
+		if !batch.HasMore || len(sessions) > page.Limit {
+			break
+		}
+
+		last := hands[len(hands)-1]
+		next := pagination.TimeCursor(last.StartedAt, last.ID.String())
+		cursor = &next
+	}
+
+	// Newest first
+	for i, j := 0, len(sessions)-1; i < j; i, j = i+1, j-1 {
+		sessions[i], sessions[j] = sessions[j], sessions[i]
+	}
+
+	return pagination.NewPage(sessions, page, func(session Session) pagination.Cursor {
+		return pagination.TimeCursor(session.StartedAt, session.ID.String())
+	}), nil
+}
+
+// GetSession returns one of a user's sessions with its hands
+func (s *Service) GetSession(userID, sessionID uuid.UUID) (*SessionDetail, error) {
+	first, err := s.handHistoryRepo.GetByID(sessionID)
+	if err != nil || first.UserID != userID {
+		return nil, ErrSessionNotFound
+	}
+
+	// The hand only starts a session if nothing played before it ran on
+	// to within the gap
+	previous, err := s.handHistoryRepo.GetLastFinishedBefore(userID, first.StartedAt, first.ID)
+	if err != nil {
+		return nil, err
+	}
+	if previous != nil && first.StartedAt.Sub(previous.FinishedAt) < s.sessionGap {
+		return nil, ErrSessionNotFound
+	}
+
+	var hands []models.HandHistory
+	err = s.handHistoryRepo.StreamUserHands(userID, first.StartedAt, time.Now(), sessionBatchSize, func(batch []models.HandHistory) error {
+		hands = append(hands, batch...)
+		if len(inferSessions(hands, s.sessionGap)) > 1 {
+			return errSessionComplete
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errSessionComplete) {
+		return nil, err
+	}
+
+	sessions := inferSessions(hands, s.sessionGap)
+	if len(sessions) == 0 || sessions[0].ID != first.ID {
+		return nil, ErrSessionNotFound
+	}
+	session := sessions[0]
+
+	detail := &SessionDetail{Session: session, Hands: make([]SessionHand, len(session.hands))}
+	for i, hand := range session.hands {
+		detail.Hands[i] = SessionHand{
+			ID:         hand.ID,
+			GameID:     hand.GameID,
+			TableName:  hand.TableName,
+			HandNumber: hand.HandNumber,
+			StartedAt:  hand.StartedAt,
+			BigBlind:   hand.BigBlind,
+			PotSize:    hand.PotSize,
+			NetResult:  hand.NetResult,
+			IsWinner:   hand.IsWinner,
+		}
+	}
+	return detail, nil
+}
+
+// inferSessions groups hands into sessions, oldest first. A new session
+// starts when a hand begins at least gap after every earlier hand has
+// finished, so hands at overlapping tables share a session.
+func inferSessions(hands []models.HandHistory, gap time.Duration) []Session {
+	sorted := make([]models.HandHistory, len(hands))
+	copy(sorted, hands)
+	sort.Slice(sorted, func(i, j int) bool {
+		if !sorted[i].StartedAt.Equal(sorted[j].StartedAt) {
+			return sorted[i].StartedAt.Before(sorted[j].StartedAt)
+		}
+		return sorted[i].ID.String() < sorted[j].ID.String()
+	})
+
+	var sessions []Session
+	var current []models.HandHistory
+	var lastFinish time.Time
+	for _, hand := range sorted {
+		if len(current) > 0 && hand.StartedAt.Sub(lastFinish) >= gap {
+			sessions = append(sessions, newSession(current))
+			current = nil
+		}
+		if len(current) == 0 || hand.FinishedAt.After(lastFinish) {
+			lastFinish = hand.FinishedAt
+		}
+		current = append(current, hand)
+	}
+	if len(current) > 0 {
+		sessions = append(sessions, newSession(current))
+	}
+	return sessions
+}
+
+// newSession summarises a session's hands, given in the order played
+func newSession(hands []models.HandHistory) Session {
+	session := Session{
+		ID:          hands[0].ID,
+		StartedAt:   hands[0].StartedAt,
+		HandsPlayed: len(hands),
+		hands:       hands,
+	}
+
+	var bigBlindsWon float64
+	tables := make(map[uuid.UUID]int)
+	for _, hand := range hands {
+		if hand.FinishedAt.After(session.EndedAt) {
+			session.EndedAt = hand.FinishedAt
+		}
+		session.NetResult += hand.NetResult
+		if hand.BigBlind > 0 {
+			bigBlindsWon += float64(hand.NetResult) / float64(hand.BigBlind)
+		}
+		if hand.PotSize > session.BiggestPot {
+			session.BiggestPot = hand.PotSize
+		}
+
+		i, ok := tables[hand.GameID]
+		if !ok {
+			i = len(session.Tables)
+			tables[hand.GameID] = i
+			session.Tables = append(session.Tables, SessionTable{GameID: hand.GameID, Name: hand.TableName})
+		}
+		session.Tables[i].HandsPlayed++
+		session.Tables[i].NetResult += hand.NetResult
+	}
+
+	session.Duration = int(session.EndedAt.Sub(session.StartedAt).Seconds())
+	session.BBPer100 = bigBlindsWon / float64(len(hands)) * 100.0
+	return session
+}
+
+// tableTime returns how much time at the table a hand adds to the hands
+// started before it, given the latest any of those finished. The wait since
+// then counts unless it is a break of at least gap, and time already
+// covered by an overlapping table is not counted twice, so over a run of
+// hands the total is the length of their sessions.
+func tableTime(hand *models.HandHistory, lastFinish time.Time, gap time.Duration) time.Duration {
+	from := hand.StartedAt
+	if !lastFinish.IsZero() && hand.StartedAt.Sub(lastFinish) < gap {
+		from = lastFinish
+	}
+	if !hand.FinishedAt.After(from) {
+		return 0
+	}
+	return hand.FinishedAt.Sub(from)
+}
+
+// tableTimes returns the table time each hand adds, indexed like hands,
+// taking them in the order they started
+func tableTimes(hands []models.HandHistory, gap time.Duration) []time.Duration {
+	times := make([]time.Duration, len(hands))
+	var lastFinish time.Time
+	for _, i := range startOrder(hands) {
+		times[i] = tableTime(&hands[i], lastFinish, gap)
+		if hands[i].FinishedAt.After(lastFinish) {
+			lastFinish = hands[i].FinishedAt
+		}
+	}
+	return times
+}
+
+// startOrder returns the indexes of hands in the order they started, ties
+// broken by ID as the repository orders them
+func startOrder(hands []models.HandHistory) []int {
+	order := make([]int, len(hands))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		a, b := &hands[order[i]], &hands[order[j]]
+		if !a.StartedAt.Equal(b.StartedAt) {
+			return a.StartedAt.Before(b.StartedAt)
+		}
+		return a.ID.String() < b.ID.String()
+	})
+	return order
+}
@@ -0,0 +1,250 @@
+package metrics
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/pagination"
+	"github.com/primoPoker/server/internal/repository"
+	"github.com/primoPoker/server/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+var (
+	sessionUser = uuid.MustParse("00000000-0000-0000-0000-0000000000aa")
+	tableA      = uuid.MustParse("00000000-0000-0000-0000-00000000000a")
+	tableB      = uuid.MustParse("00000000-0000-0000-0000-00000000000b")
+	tableC      = uuid.MustParse("00000000-0000-0000-0000-00000000000c")
+)
+
+// sessionHands is a user's hands with crafted gaps, against a 45 minute
+// session gap:
+//
+//   - hands 1-4 run past midnight, the last starting 44m59s after the
+//     previous one finished
+//   - hand 5 starts exactly 45 minutes after hand 4, so begins a session
+//   - hands 6-9 multi-table; hand 9 is 72 minutes after hand 8 finished but
+//     only 40 after the long hand 7 at another table did
+//   - hand 10 starts exactly 45 minutes after hand 9
+func sessionHands() []models.HandHistory {
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	at := func(days, hour, min, sec int) time.Time {
+		return day.AddDate(0, 0, days).Add(time.Duration(hour)*time.Hour + time.Duration(min)*time.Minute + time.Duration(sec)*time.Second)
+	}
+
+	hand := func(n int, table uuid.UUID, name string, bigBlind int64, start, end time.Time, net, pot int64) models.HandHistory {
+		return models.HandHistory{
+			ID:         uuid.MustParse(fmt.Sprintf("00000000-0000-0000-0000-%012d", n)),
+			GameID:     table,
+			UserID:     sessionUser,
+			HandNumber: n,
+			TableName:  name,
+			SmallBlind: bigBlind / 2,
+			BigBlind:   bigBlind,
+			NetResult:  net,
+			PotSize:    pot,
+			IsWinner:   net > 0,
+			StartedAt:  start,
+			FinishedAt: end,
+		}
+	}
+
+	return []models.HandHistory{
+		hand(1, tableA, "Alpha", 100, at(0, 23, 30, 0), at(0, 23, 32, 0), 200, 400),
+		hand(2, tableA, "Alpha", 100, at(0, 23, 50, 0), at(0, 23, 53, 0), -100, 300),
+		hand(3, tableA, "Alpha", 100, at(1, 0, 30, 0), at(1, 0, 33, 0), 300, 700),
+		hand(4, tableA, "Alpha", 100, at(1, 1, 17, 59), at(1, 1, 20, 0), -50, 150),
+		hand(5, tableA, "Alpha", 100, at(1, 2, 5, 0), at(1, 2, 7, 0), -100, 200),
+		hand(6, tableB, "Bravo", 100, at(1, 10, 0, 0), at(1, 10, 5, 0), 150, 350),
+		hand(7, tableC, "Charlie", 200, at(1, 10, 2, 0), at(1, 10, 40, 0), -400, 1200),
+		hand(8, tableB, "Bravo", 100, at(1, 10, 6, 0), at(1, 10, 8, 0), 50, 250),
+		hand(9, tableB, "Bravo", 100, at(1, 11, 20, 0), at(1, 11, 22, 0), 100, 300),
+		hand(10, tableC, "Charlie", 200, at(1, 12, 7, 0), at(1, 12, 9, 0), 600, 900),
+	}
+}
+
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		require.NoError(t, os.MkdirAll("testdata", 0o755))
+		require.NoError(t, os.WriteFile(path, got, 0o644))
+	}
+
+	want, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(want), string(got))
+}
+
+func TestInferSessionsGolden(t *testing.T) {
+	hands := sessionHands()
+
+	// Input order must not matter
+	shuffled := []models.HandHistory{hands[9], hands[6], hands[0], hands[8], hands[3], hands[5], hands[1], hands[4], hands[7], hands[2]}
+	sessions := inferSessions(shuffled, 45*time.Minute)
+
+	got, err := json.MarshalIndent(sessions, "", "  ")
+	require.NoError(t, err)
+	checkGolden(t, "sessions.json.golden", append(got, '\n'))
+
+	var bounds [][]int
+	for _, session := range sessions {
+		var numbers []int
+		for _, hand := range session.hands {
+			numbers = append(numbers, hand.HandNumber)
+		}
+		bounds = append(bounds, numbers)
+	}
+	assert.Equal(t, [][]int{{1, 2, 3, 4}, {5}, {6, 7, 8, 9}, {10}}, bounds)
+}
+
+func TestTableTimeMatchesSessions(t *testing.T) {
+	hands := sessionHands()
+	shuffled := []models.HandHistory{hands[9], hands[6], hands[0], hands[8], hands[3], hands[5], hands[1], hands[4], hands[7], hands[2]}
+
+	var sessionTime time.Duration
+	for _, session := range inferSessions(shuffled, 45*time.Minute) {
+		sessionTime += session.EndedAt.Sub(session.StartedAt)
+	}
+
+	var total time.Duration
+	for _, spent := range tableTimes(shuffled, 45*time.Minute) {
+		total += spent
+	}
+	assert.Equal(t, sessionTime, total, "breaks between sessions are left out and overlapping tables counted once")
+
+	// Added one hand at a time, as the aggregator does
+	var lastFinish time.Time
+	total = 0
+	for i := range hands {
+		total += tableTime(&hands[i], lastFinish, 45*time.Minute)
+		if hands[i].FinishedAt.After(lastFinish) {
+			lastFinish = hands[i].FinishedAt
+		}
+	}
+	assert.Equal(t, sessionTime, total)
+}
+
+func TestWinRates(t *testing.T) {
+	start := time.Date(2026, 3, 1, 20, 0, 0, 0, time.UTC)
+	won := func(bigBlind, net int64, at time.Time) models.HandHistory {
+		return models.HandHistory{
+			ID:            uuid.New(),
+			BigBlind:      bigBlind,
+			StartingChips: 10000,
+			EndingChips:   10000 + net,
+			AmountWon:     2 * net,
+			NetResult:     net,
+			IsWinner:      true,
+			StartedAt:     at,
+			FinishedAt:    at.Add(10 * time.Minute),
+		}
+	}
+	first := won(100, 500, start)
+	second := won(200, 1000, start.Add(30*time.Minute))
+	service := &Service{sessionGap: 45 * time.Minute}
+
+	// Ten minutes is too short to say anything per hour
+	metrics, err := service.calculateMetrics(sessionUser, "hero", []models.HandHistory{first}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 600, metrics.TableTime)
+	assert.Zero(t, metrics.ChipsPerHour)
+	assert.Zero(t, metrics.BBPerHour)
+	assert.True(t, metrics.SmallSample)
+
+	// The wait between the hands is table time, making forty minutes
+	metrics, err = service.calculateMetrics(sessionUser, "hero", []models.HandHistory{second, first}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2400, metrics.TableTime)
+	assert.InDelta(t, 500.0, metrics.BBPer100, 1e-9)
+	assert.InDelta(t, 2250.0, metrics.ChipsPerHour, 1e-9)
+	assert.InDelta(t, 15.0, metrics.BBPerHour, 1e-9)
+	assert.True(t, metrics.SmallSample)
+}
+
+// newSessionService stores the crafted hands and returns a service over them
+func newSessionService(t *testing.T) *Service {
+	t.Helper()
+
+	db := testutil.NewDB(t, &models.User{}, &models.Game{}, &models.HandHistory{})
+	hands := sessionHands()
+	require.NoError(t, db.Create(&hands).Error)
+
+	// Another player's hand in the middle of the first session
+	other := hands[1]
+	other.ID = uuid.New()
+	other.UserID = uuid.New()
+	require.NoError(t, db.Create(&other).Error)
+
+	return &Service{
+		handHistoryRepo: repository.NewHandHistoryRepository(db),
+		sessionGap:      45 * time.Minute,
+	}
+}
+
+func TestGetSessionsPaging(t *testing.T) {
+	// Small batches so sessions span several reads
+	batchSize := sessionBatchSize
+	sessionBatchSize = 3
+	t.Cleanup(func() { sessionBatchSize = batchSize })
+
+	service := newSessionService(t)
+
+	page, err := service.GetSessions(sessionUser, pagination.PageRequest{Limit: 3})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 3)
+	assert.True(t, page.HasMore)
+	assert.Equal(t, []int{1, 4, 1}, []int{page.Items[0].HandsPlayed, page.Items[1].HandsPlayed, page.Items[2].HandsPlayed})
+	assert.Equal(t, "00000000-0000-0000-0000-000000000010", page.Items[0].ID.String())
+	assert.Equal(t, "00000000-0000-0000-0000-000000000006", page.Items[1].ID.String())
+	assert.Len(t, page.Items[1].Tables, 2)
+	assert.Equal(t, int64(1200), page.Items[1].BiggestPot)
+
+	cursor, err := pagination.DecodeCursor(page.NextCursor)
+	require.NoError(t, err)
+	page, err = service.GetSessions(sessionUser, pagination.PageRequest{Limit: 3, Cursor: cursor})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.False(t, page.HasMore)
+
+	first := page.Items[0]
+	assert.Equal(t, "00000000-0000-0000-0000-000000000001", first.ID.String())
+	assert.Equal(t, 4, first.HandsPlayed)
+	assert.Equal(t, int64(350), first.NetResult)
+	assert.InDelta(t, 87.5, first.BBPer100, 0.001) // 3.5bb over 4 hands
+	assert.Equal(t, int64(700), first.BiggestPot)
+	assert.Equal(t, 110*60, first.Duration) // 23:30 to 01:20
+}
+
+func TestGetSession(t *testing.T) {
+	service := newSessionService(t)
+
+	detail, err := service.GetSession(sessionUser, uuid.MustParse("00000000-0000-0000-0000-000000000006"))
+	require.NoError(t, err)
+	require.Len(t, detail.Hands, 4)
+	assert.Equal(t, []int{6, 7, 8, 9}, []int{detail.Hands[0].HandNumber, detail.Hands[1].HandNumber, detail.Hands[2].HandNumber, detail.Hands[3].HandNumber})
+	assert.Equal(t, int64(-100), detail.NetResult)
+
+	detail, err = service.GetSession(sessionUser, uuid.MustParse("00000000-0000-0000-0000-000000000010"))
+	require.NoError(t, err)
+	assert.Len(t, detail.Hands, 1)
+
+	// A hand in the middle of a session does not name one
+	_, err = service.GetSession(sessionUser, uuid.MustParse("00000000-0000-0000-0000-000000000009"))
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+
+	// Nor does someone else's session
+	_, err = service.GetSession(uuid.New(), uuid.MustParse("00000000-0000-0000-0000-000000000001"))
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+}
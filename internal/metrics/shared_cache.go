@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/primoPoker/server/internal/cache"
+)
+
+// sharedCacheTimeout bounds each round trip to the shared cache; a slow
+// cache counts as a miss
+const sharedCacheTimeout = 250 * time.Millisecond
+
+// SharedCache is a Cache kept in a store shared by every instance, so a
+// hand played at a table on one instance clears the player's metrics on
+// all of them. Each user's entries are keyed by a generation that
+// InvalidateUser replaces, so invalidating needs no scan of the user's
+// entries; those of older generations are unreachable and expire on
+// their own.
+type SharedCache struct {
+	store cache.Store
+	ttl   time.Duration
+	now   func() time.Time
+}
+
+// NewSharedCache creates a cache holding entries in store for ttl each
+func NewSharedCache(store cache.Store, ttl time.Duration) *SharedCache {
+	return &SharedCache{store: store, ttl: ttl, now: time.Now}
+}
+
+// generationKey holds the current generation of a user's entries
+func generationKey(userID uuid.UUID) string {
+	return "metrics-generation:" + userID.String()
+}
+
+// entryKey names an entry within the user's current generation
+func (c *SharedCache) entryKey(ctx context.Context, key CacheKey) (string, error) {
+	generation, err := c.store.Get(ctx, generationKey(key.UserID))
+	if errors.Is(err, cache.ErrMiss) {
+		generation, err = "0", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return "metrics:" + generation + ":" + key.String(), nil
+}
+
+// Get returns the cached metrics for key
+func (c *SharedCache) Get(key CacheKey) (*PlayerMetrics, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), sharedCacheTimeout)
+	defer cancel()
+
+	name, err := c.entryKey(ctx, key)
+	if err != nil {
+		return nil, false
+	}
+	data, err := c.store.Get(ctx, name)
+	if err != nil {
+		return nil, false
+	}
+
+	var metrics PlayerMetrics
+	if err := json.Unmarshal([]byte(data), &metrics); err != nil {
+		return nil, false
+	}
+	return &metrics, true
+}
+
+// Set caches metrics for key
+func (c *SharedCache) Set(key CacheKey, metrics *PlayerMetrics) {
+	ctx, cancel := context.WithTimeout(context.Background(), sharedCacheTimeout)
+	defer cancel()
+
+	data, err := json.Marshal(metrics)
+	if err != nil {
+		return
+	}
+	name, err := c.entryKey(ctx, key)
+	if err == nil {
+		err = c.store.Set(ctx, name, string(data), c.ttl)
+	}
+	if err != nil {
+		logrus.WithError(err).Debug("Failed to cache player metrics")
+	}
+}
+
+// InvalidateUser starts a new generation of the user's entries. The
+// generation outlives every entry of the one before it, so an expired
+// generation never brings old entries back.
+func (c *SharedCache) InvalidateUser(userID uuid.UUID) {
+	ctx, cancel := context.WithTimeout(context.Background(), sharedCacheTimeout)
+	defer cancel()
+
+	generation := strconv.FormatInt(c.now().UnixNano(), 10)
+	if err := c.store.Set(ctx, generationKey(userID), generation, 2*c.ttl); err != nil {
+		logrus.WithError(err).WithField("user_id", userID).Warn("Failed to invalidate cached player metrics")
+	}
+}
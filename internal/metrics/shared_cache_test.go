@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/internal/cache"
+)
+
+func TestSharedCache(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	// Two instances sharing one Redis
+	east := NewSharedCache(cache.NewRedis(client), time.Minute)
+	west := NewSharedCache(cache.NewRedis(client), time.Minute)
+
+	alice, bob := CacheKey{UserID: uuid.New()}, CacheKey{UserID: uuid.New()}
+	east.Set(alice, &PlayerMetrics{UserID: alice.UserID, HandsPlayed: 7})
+	east.Set(bob, &PlayerMetrics{UserID: bob.UserID, HandsPlayed: 3})
+
+	got, ok := west.Get(alice)
+	require.True(t, ok, "an entry cached on one instance is served by the others")
+	assert.Equal(t, 7, got.HandsPlayed)
+	assert.Equal(t, alice.UserID, got.UserID)
+
+	west.InvalidateUser(alice.UserID)
+	_, ok = east.Get(alice)
+	assert.False(t, ok, "invalidating on one instance clears the entry on all")
+	_, ok = east.Get(bob)
+	assert.True(t, ok, "other users' entries stay")
+
+	east.Set(alice, &PlayerMetrics{HandsPlayed: 8})
+	got, ok = west.Get(alice)
+	require.True(t, ok)
+	assert.Equal(t, 8, got.HandsPlayed)
+
+	server.FastForward(time.Minute)
+	_, ok = west.Get(alice)
+	assert.False(t, ok, "entries expire")
+
+	// An unreachable cache is a miss
+	server.Close()
+	_, ok = west.Get(bob)
+	assert.False(t, ok)
+	west.Set(bob, &PlayerMetrics{})
+	west.InvalidateUser(bob.UserID)
+}
@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/primoPoker/server/internal/models"
+)
+
+// Filter narrows the hands metrics are calculated from. Zero values match
+// everything.
+type Filter struct {
+	GameType    models.GameType
+	MinBigBlind int64
+	MaxBigBlind int64
+}
+
+// Validate checks the filter's game type and stake range
+func (f Filter) Validate() error {
+	switch f.GameType {
+	case "", models.GameTypeTexasHoldem, models.GameTypeOmaha, models.GameTypeStud:
+	default:
+		return errors.New("unknown game type")
+	}
+	if f.MinBigBlind < 0 || f.MaxBigBlind < 0 {
+		return errors.New("big blind bounds must not be negative")
+	}
+	if f.MaxBigBlind > 0 && f.MinBigBlind > f.MaxBigBlind {
+		return errors.New("minimum big blind is above the maximum")
+	}
+	return nil
+}
+
+// matches checks if a hand passes the filter
+func (f Filter) matches(hand *models.HandHistory) bool {
+	return f.matchesStake(handStake(hand))
+}
+
+// matchesStake checks if hands at a stake pass the filter
+func (f Filter) matchesStake(stake stakeKey) bool {
+	if f.GameType != "" && stake.gameType != f.GameType {
+		return false
+	}
+	if f.MinBigBlind > 0 && stake.bigBlind < f.MinBigBlind {
+		return false
+	}
+	if f.MaxBigBlind > 0 && stake.bigBlind > f.MaxBigBlind {
+		return false
+	}
+	return true
+}
+
+// apply returns the hands that pass the filter, in their original order
+func (f Filter) apply(hands []models.HandHistory) []models.HandHistory {
+	if f == (Filter{}) {
+		return hands
+	}
+	var matched []models.HandHistory
+	for i := range hands {
+		if f.matches(&hands[i]) {
+			matched = append(matched, hands[i])
+		}
+	}
+	return matched
+}
+
+// StakeMetrics is a player's metrics for one game type at one blind level
+type StakeMetrics struct {
+	GameType   models.GameType `json:"game_type"`
+	SmallBlind int64           `json:"small_blind"`
+	BigBlind   int64           `json:"big_blind"`
+	PlayerMetrics
+}
+
+// stakeKey identifies the bucket a hand belongs to
+type stakeKey struct {
+	gameType   models.GameType
+	smallBlind int64
+	bigBlind   int64
+}
+
+// handStake returns the stake a hand was played at
+func handStake(hand *models.HandHistory) stakeKey {
+	return stakeKey{
+		gameType:   handGameType(hand),
+		smallBlind: hand.SmallBlind,
+		bigBlind:   hand.BigBlind,
+	}
+}
+
+// handGameType returns the game type a hand was played at. Hands whose game
+// was not loaded are counted as Texas Hold'em, the only game dealt today.
+func handGameType(hand *models.HandHistory) models.GameType {
+	if hand.Game.GameType == "" {
+		return models.GameTypeTexasHoldem
+	}
+	return hand.Game.GameType
+}
+
+// calculateStakeMetrics splits hands by game type and blind level and
+// calculates metrics for each, ordered by game type then stakes
+func (s *Service) calculateStakeMetrics(userID uuid.UUID, username string, hands []models.HandHistory, since *time.Time) ([]StakeMetrics, error) {
+	tallies := make(map[stakeKey]*tally)
+	times := tableTimes(hands, s.sessionGap)
+	for i := range hands {
+		stakeTally(tallies, handStake(&hands[i])).addHand(&hands[i], times[i])
+	}
+	return stakeMetrics(userID, username, tallies, since), nil
+}
+
+// stakeTally returns the tally for a stake, adding an empty one if needed
+func stakeTally(tallies map[stakeKey]*tally, stake stakeKey) *tally {
+	t, ok := tallies[stake]
+	if !ok {
+		t = &tally{}
+		tallies[stake] = t
+	}
+	return t
+}
+
+// stakeMetrics derives the metrics for each stake's tally, ordered by game
+// type then stakes
+func stakeMetrics(userID uuid.UUID, username string, tallies map[stakeKey]*tally, since *time.Time) []StakeMetrics {
+	stakes := make([]StakeMetrics, 0, len(tallies))
+	for key, t := range tallies {
+		stakes = append(stakes, StakeMetrics{
+			GameType:      key.gameType,
+			SmallBlind:    key.smallBlind,
+			BigBlind:      key.bigBlind,
+			PlayerMetrics: *t.metrics(userID, username, since),
+		})
+	}
+
+	sort.Slice(stakes, func(i, j int) bool {
+		a, b := stakes[i], stakes[j]
+		if a.GameType != b.GameType {
+			return a.GameType < b.GameType
+		}
+		if a.BigBlind != b.BigBlind {
+			return a.BigBlind < b.BigBlind
+		}
+		return a.SmallBlind < b.SmallBlind
+	})
+
+	return stakes
+}
@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stakeHand returns a hand at the given stakes with the given result
+func stakeHand(userID uuid.UUID, gameType models.GameType, smallBlind, bigBlind, net int64, at time.Time) models.HandHistory {
+	hand := models.HandHistory{
+		UserID:        userID,
+		SmallBlind:    smallBlind,
+		BigBlind:      bigBlind,
+		StartingChips: 10000,
+		EndingChips:   10000 + net,
+		NetResult:     net,
+		PotSize:       4 * bigBlind,
+		StartedAt:     at,
+		Game:          models.Game{GameType: gameType},
+	}
+	if net > 0 {
+		hand.IsWinner = true
+		hand.AmountWon = net + bigBlind
+		hand.StartingChips -= bigBlind
+	}
+	return hand
+}
+
+func TestCalculateStakeMetrics(t *testing.T) {
+	service := &Service{}
+	userID := uuid.New()
+	now := time.Now()
+
+	// Newest first, as the repository returns them
+	hands := []models.HandHistory{
+		stakeHand(userID, models.GameTypeTexasHoldem, 500, 1000, -3000, now),
+		stakeHand(userID, models.GameTypeTexasHoldem, 50, 100, 400, now.Add(-time.Minute)),
+		stakeHand(userID, models.GameTypeOmaha, 50, 100, -100, now.Add(-2*time.Minute)),
+		stakeHand(userID, models.GameTypeTexasHoldem, 500, 1000, -1000, now.Add(-3*time.Minute)),
+		stakeHand(userID, models.GameTypeTexasHoldem, 50, 100, 200, now.Add(-4*time.Minute)),
+		stakeHand(userID, "", 50, 100, -100, now.Add(-5*time.Minute)),
+	}
+
+	overall, err := service.calculateMetrics(userID, "alice", hands, nil)
+	require.NoError(t, err)
+	stakes, err := service.calculateStakeMetrics(userID, "alice", hands, nil)
+	require.NoError(t, err)
+
+	require.Len(t, stakes, 3)
+	assert.Equal(t, models.GameTypeOmaha, stakes[0].GameType)
+	assert.Equal(t, models.GameTypeTexasHoldem, stakes[1].GameType)
+	assert.Equal(t, int64(100), stakes[1].BigBlind)
+	assert.Equal(t, int64(1000), stakes[2].BigBlind)
+
+	// Crushing the small game, losing at the big one
+	assert.Equal(t, 3, stakes[1].HandsPlayed)
+	assert.InDelta(t, 166.67, stakes[1].BBPer100, 0.01) // 5bb over 3 hands
+	assert.Equal(t, 2, stakes[2].HandsPlayed)
+	assert.InDelta(t, -200, stakes[2].BBPer100, 0.01) // -4bb over 2 hands
+
+	var played, won int
+	var net, totalWon, wagered int64
+	var bigBlinds float64
+	for _, stake := range stakes {
+		played += stake.HandsPlayed
+		won += stake.HandsWon
+		net += stake.NetResult
+		totalWon += stake.TotalWon
+		wagered += stake.TotalWagered
+		bigBlinds += stake.BBPer100 * float64(stake.HandsPlayed) / 100
+	}
+	assert.Equal(t, overall.HandsPlayed, played)
+	assert.Equal(t, overall.HandsWon, won)
+	assert.Equal(t, overall.NetResult, net)
+	assert.Equal(t, overall.TotalWon, totalWon)
+	assert.Equal(t, overall.TotalWagered, wagered)
+	assert.InDelta(t, overall.BBPer100*float64(overall.HandsPlayed)/100, bigBlinds, 0.0001)
+}
+
+func TestFilter(t *testing.T) {
+	userID := uuid.New()
+	now := time.Now()
+	hands := []models.HandHistory{
+		stakeHand(userID, models.GameTypeTexasHoldem, 500, 1000, 0, now),
+		stakeHand(userID, models.GameTypeOmaha, 50, 100, 0, now),
+		stakeHand(userID, models.GameTypeTexasHoldem, 50, 100, 0, now),
+		stakeHand(userID, models.GameTypeTexasHoldem, 10, 20, 0, now),
+	}
+
+	assert.Len(t, Filter{}.apply(hands), 4)
+	assert.Len(t, Filter{GameType: models.GameTypeOmaha}.apply(hands), 1)
+	assert.Len(t, Filter{MinBigBlind: 100}.apply(hands), 3)
+	assert.Len(t, Filter{MaxBigBlind: 100}.apply(hands), 3)
+	assert.Len(t, Filter{GameType: models.GameTypeTexasHoldem, MinBigBlind: 100, MaxBigBlind: 100}.apply(hands), 1)
+
+	assert.NoError(t, Filter{GameType: models.GameTypeStud, MinBigBlind: 100, MaxBigBlind: 100}.Validate())
+	assert.Error(t, Filter{GameType: "razz"}.Validate())
+	assert.Error(t, Filter{MinBigBlind: -1}.Validate())
+	assert.Error(t, Filter{MinBigBlind: 200, MaxBigBlind: 100}.Validate())
+}
@@ -0,0 +1,142 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/primoPoker/server/pkg/poker"
+)
+
+// gridRanks are the ranks along each side of the starting hand grid, from
+// the top left corner
+const gridRanks = "AKQJT98765432"
+
+// StartingHand is how one of the 169 distinct starting hands has played
+type StartingHand struct {
+	Hand        string  `json:"hand"` // e.g. AA, AKs or AKo
+	Count       int     `json:"count"`
+	VPIPPercent float64 `json:"vpip_percent"`
+	NetResult   int64   `json:"net_result"`
+	BBPer100    float64 `json:"bb_per_100"`
+}
+
+// StartingHandFilter narrows the hands in the starting hand report. Zero
+// values match everything; a zero To is now.
+type StartingHandFilter struct {
+	Position poker.Position
+	From     time.Time
+	To       time.Time
+}
+
+// Validate checks the filter's position and date range
+func (f StartingHandFilter) Validate() error {
+	if f.Position != "" && !f.Position.IsValid() {
+		return errors.New("unknown position")
+	}
+	if !f.To.IsZero() && f.To.Before(f.From) {
+		return errors.New("to must not be before from")
+	}
+	return nil
+}
+
+// GetStartingHands returns the user's results with each starting hand as
+// the 13x13 grid, row by row: pairs run down the diagonal, suited hands sit
+// above it and offsuit hands below
+func (s *Service) GetStartingHands(userID uuid.UUID, filter StartingHandFilter) ([]StartingHand, error) {
+	to := filter.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+
+	totals, err := s.handHistoryRepo.GetStartingHandTotals(userID, string(filter.Position), filter.From, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get starting hand totals: %w", err)
+	}
+
+	grid := newStartingHandGrid()
+	vpipHands := make([]int, len(grid))
+	bigBlindsWon := make([]float64, len(grid))
+	for _, total := range totals {
+		i, ok := startingHandIndex(total.Rank1, total.Rank2, total.Suited)
+		if !ok {
+			continue
+		}
+		grid[i].Count += total.Hands
+		grid[i].NetResult += total.NetResult
+		vpipHands[i] += total.VPIPHands
+		bigBlindsWon[i] += total.BigBlindsWon
+	}
+
+	for i := range grid {
+		if grid[i].Count == 0 {
+			continue
+		}
+		grid[i].VPIPPercent = percentOf(vpipHands[i], grid[i].Count)
+		grid[i].BBPer100 = bigBlindsWon[i] / float64(grid[i].Count) * 100.0
+	}
+	return grid, nil
+}
+
+// newStartingHandGrid returns the 169 starting hands, named and unplayed
+func newStartingHandGrid() []StartingHand {
+	size := len(gridRanks)
+	grid := make([]StartingHand, 0, size*size)
+	for row := 0; row < size; row++ {
+		for col := 0; col < size; col++ {
+			var name string
+			switch {
+			case row == col:
+				name = gridRanks[row:row+1] + gridRanks[col:col+1]
+			case row < col:
+				name = gridRanks[row:row+1] + gridRanks[col:col+1] + "s"
+			default:
+				name = gridRanks[col:col+1] + gridRanks[row:row+1] + "o"
+			}
+			grid = append(grid, StartingHand{Hand: name})
+		}
+	}
+	return grid
+}
+
+// startingHandIndex finds the grid cell of two hole cards from their
+// stored ranks, in either order
+func startingHandIndex(rank1, rank2 string, suited bool) (int, bool) {
+	high, ok := gridRank(rank1)
+	if !ok {
+		return 0, false
+	}
+	low, ok := gridRank(rank2)
+	if !ok {
+		return 0, false
+	}
+	if low < high {
+		high, low = low, high
+	}
+
+	switch {
+	case high == low:
+		if suited {
+			return 0, false
+		}
+		return high*len(gridRanks) + high, true
+	case suited:
+		return high*len(gridRanks) + low, true
+	default:
+		return low*len(gridRanks) + high, true
+	}
+}
+
+// gridRank returns how far a stored rank is from the top of the grid
+func gridRank(rank string) (int, bool) {
+	if rank == poker.Ten.String() {
+		rank = "T"
+	}
+	if len(rank) != 1 {
+		return 0, false
+	}
+	i := strings.Index(gridRanks, rank)
+	return i, i >= 0
+}
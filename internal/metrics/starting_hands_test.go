@@ -0,0 +1,160 @@
+package metrics
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/repository"
+	"github.com/primoPoker/server/internal/testutil"
+	"github.com/primoPoker/server/pkg/poker"
+)
+
+func TestStartingHandGrid(t *testing.T) {
+	grid := newStartingHandGrid()
+	require.Len(t, grid, 169)
+	assert.Equal(t, "AA", grid[0].Hand)
+	assert.Equal(t, "AKs", grid[1].Hand)
+	assert.Equal(t, "A2s", grid[12].Hand)
+	assert.Equal(t, "AKo", grid[13].Hand)
+	assert.Equal(t, "KK", grid[14].Hand)
+	assert.Equal(t, "22", grid[168].Hand)
+
+	seen := make(map[string]bool)
+	for _, cell := range grid {
+		assert.False(t, seen[cell.Hand], "%s appears twice", cell.Hand)
+		seen[cell.Hand] = true
+	}
+}
+
+func TestStartingHandIndex(t *testing.T) {
+	tests := []struct {
+		rank1, suit1 string
+		rank2, suit2 string
+		want         string
+	}{
+		{"A", "Spades", "A", "Hearts", "AA"},
+		{"A", "Spades", "K", "Spades", "AKs"},
+		{"K", "Spades", "A", "Spades", "AKs"},
+		{"A", "Spades", "K", "Hearts", "AKo"},
+		{"K", "Clubs", "A", "Diamonds", "AKo"},
+		{"10", "Hearts", "10", "Clubs", "TT"},
+		{"J", "Diamonds", "10", "Diamonds", "JTs"},
+		{"10", "Clubs", "9", "Spades", "T9o"},
+		{"2", "Hearts", "7", "Hearts", "72s"},
+		{"7", "Hearts", "2", "Clubs", "72o"},
+		{"3", "Clubs", "2", "Spades", "32o"},
+	}
+
+	grid := newStartingHandGrid()
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s%s %s%s", tt.rank1, tt.suit1, tt.rank2, tt.suit2), func(t *testing.T) {
+			i, ok := startingHandIndex(tt.rank1, tt.rank2, tt.suit1 == tt.suit2)
+			require.True(t, ok)
+			assert.Equal(t, tt.want, grid[i].Hand)
+		})
+	}
+
+	for _, rank := range []string{"", "1", "11", "a"} {
+		_, ok := startingHandIndex(rank, "A", false)
+		assert.False(t, ok, "rank %q", rank)
+	}
+}
+
+func TestStartingHandFilterValidate(t *testing.T) {
+	now := time.Now()
+	assert.NoError(t, StartingHandFilter{}.Validate())
+	assert.NoError(t, StartingHandFilter{Position: poker.PositionButton, From: now.Add(-time.Hour), To: now}.Validate())
+	assert.Error(t, StartingHandFilter{Position: "dealer"}.Validate())
+	assert.Error(t, StartingHandFilter{From: now, To: now.Add(-time.Hour)}.Validate())
+}
+
+func TestGetStartingHands(t *testing.T) {
+	db := testutil.NewDB(t, &models.User{}, &models.Game{}, &models.HandHistory{})
+	service := &Service{handHistoryRepo: repository.NewHandHistoryRepository(db)}
+
+	user := uuid.New()
+	start := time.Now().Add(-time.Hour)
+	hand := func(rank1, suit1, rank2, suit2 string, position poker.Position, vpip bool, net int64, age time.Duration) models.HandHistory {
+		h := models.HandHistory{
+			ID:            uuid.New(),
+			GameID:        uuid.New(),
+			UserID:        user,
+			HoleCard1Rank: rank1,
+			HoleCard1Suit: suit1,
+			HoleCard2Rank: rank2,
+			HoleCard2Suit: suit2,
+			Position:      string(position),
+			BigBlind:      100,
+			NetResult:     net,
+			StartedAt:     start.Add(-age),
+			FinishedAt:    start.Add(-age + time.Minute),
+		}
+		if vpip {
+			h.VPIPPercent = 100
+		}
+		return h
+	}
+
+	hands := []models.HandHistory{
+		hand("A", "Spades", "K", "Spades", poker.PositionButton, true, 600, 0),
+		hand("K", "Hearts", "A", "Hearts", poker.PositionBB, true, -200, 0),
+		hand("A", "Clubs", "K", "Hearts", poker.PositionButton, false, 0, 0),
+		hand("7", "Clubs", "2", "Hearts", poker.PositionBB, false, -100, 0),
+		hand("10", "Clubs", "10", "Hearts", poker.PositionCO, true, 300, 48*time.Hour),
+		// Not dealt in, so not on the grid
+		hand("", "", "", "", poker.PositionButton, false, 0, 0),
+	}
+	require.NoError(t, db.Create(&hands).Error)
+
+	cell := func(grid []StartingHand, name string) StartingHand {
+		for _, c := range grid {
+			if c.Hand == name {
+				return c
+			}
+		}
+		t.Fatalf("%s is not on the grid", name)
+		return StartingHand{}
+	}
+
+	grid, err := service.GetStartingHands(user, StartingHandFilter{})
+	require.NoError(t, err)
+	require.Len(t, grid, 169)
+
+	suited := cell(grid, "AKs")
+	assert.Equal(t, 2, suited.Count)
+	assert.Equal(t, int64(400), suited.NetResult)
+	assert.Equal(t, float64(100), suited.VPIPPercent)
+	assert.Equal(t, float64(200), suited.BBPer100) // 4bb over 2 hands
+
+	offsuit := cell(grid, "AKo")
+	assert.Equal(t, 1, offsuit.Count)
+	assert.Zero(t, offsuit.VPIPPercent)
+	assert.Equal(t, 1, cell(grid, "72o").Count)
+	assert.Equal(t, 1, cell(grid, "TT").Count)
+
+	var total int
+	for _, c := range grid {
+		total += c.Count
+	}
+	assert.Equal(t, 5, total)
+
+	// Only the button
+	grid, err = service.GetStartingHands(user, StartingHandFilter{Position: poker.PositionButton})
+	require.NoError(t, err)
+	assert.Equal(t, 1, cell(grid, "AKs").Count)
+	assert.Equal(t, int64(600), cell(grid, "AKs").NetResult)
+	assert.Equal(t, 1, cell(grid, "AKo").Count)
+	assert.Zero(t, cell(grid, "72o").Count)
+
+	// Only the last day
+	grid, err = service.GetStartingHands(user, StartingHandFilter{From: start.Add(-24 * time.Hour)})
+	require.NoError(t, err)
+	assert.Zero(t, cell(grid, "TT").Count)
+	assert.Equal(t, 2, cell(grid, "AKs").Count)
+}
@@ -0,0 +1,209 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/repository"
+)
+
+// ErrUnknownPeriod is returned for a summary period other than a day or week
+var ErrUnknownPeriod = errors.New("unknown summary period")
+
+// summaryPeriods are the periods rolled up, shortest first
+var summaryPeriods = []models.SummaryPeriod{models.SummaryPeriodDay, models.SummaryPeriodWeek}
+
+// periodStart returns the start of the day or week (from Monday, UTC)
+// containing t
+func periodStart(period models.SummaryPeriod, t time.Time) time.Time {
+	day := dayStart(t)
+	if period == models.SummaryPeriodWeek {
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	}
+	return day
+}
+
+// periodEnd returns the end of the period starting at start
+func periodEnd(period models.SummaryPeriod, start time.Time) time.Time {
+	if period == models.SummaryPeriodWeek {
+		return start.AddDate(0, 0, 7)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// Summarizer rolls each player's hands up into a HandSummary for every day
+// and week they played
+type Summarizer struct {
+	hands *repository.HandHistoryRepository
+	now   func() time.Time
+}
+
+// NewSummarizer creates a summarizer reading and storing through hands
+func NewSummarizer(hands *repository.HandHistoryRepository) *Summarizer {
+	return &Summarizer{hands: hands, now: time.Now}
+}
+
+// RollUp stores a summary for every player who played in the period
+// starting at start, returning how many were written. Rolling a period up
+// again replaces its summaries.
+func (s *Summarizer) RollUp(period models.SummaryPeriod, start time.Time) (int, error) {
+	end := periodEnd(period, start)
+	userIDs, err := s.hands.GetPlayerIDsBetween(start, end)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list players: %w", err)
+	}
+
+	for i, userID := range userIDs {
+		summary, err := summarize(s.hands, userID, period, start)
+		if err != nil {
+			return i, err
+		}
+		if err := s.hands.UpsertSummary(summary); err != nil {
+			return i, fmt.Errorf("failed to store summary of %s: %w", userID, err)
+		}
+	}
+	return len(userIDs), nil
+}
+
+// RollUpCompleted rolls up the latest day and week to have ended
+func (s *Summarizer) RollUpCompleted() (int, error) {
+	var written int
+	for _, period := range summaryPeriods {
+		current := periodStart(period, s.now())
+		n, err := s.RollUp(period, periodStart(period, current.Add(-time.Nanosecond)))
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// Backfill rolls up every day and week from the one containing from to
+// the latest to have ended
+func (s *Summarizer) Backfill(from time.Time) (int, error) {
+	var written int
+	for _, period := range summaryPeriods {
+		current := periodStart(period, s.now())
+		for start := periodStart(period, from); start.Before(current); start = periodEnd(period, start) {
+			n, err := s.RollUp(period, start)
+			written += n
+			if err != nil {
+				return written, fmt.Errorf("failed to roll up %s of %s: %w", period, start.Format("2006-01-02"), err)
+			}
+		}
+	}
+	return written, nil
+}
+
+// summarize totals a player's hands started in the period
+func summarize(hands repository.HandHistoryStore, userID uuid.UUID, period models.SummaryPeriod, start time.Time) (*models.HandSummary, error) {
+	end := periodEnd(period, start)
+	summary := &models.HandSummary{
+		UserID:      userID,
+		Period:      period,
+		PeriodStart: start,
+		PeriodEnd:   end,
+	}
+
+	var totals tally
+	err := hands.StreamUserHands(userID, start, end, sessionBatchSize, func(batch []models.HandHistory) error {
+		for i := range batch {
+			hand := &batch[i]
+			if !hand.StartedAt.Before(end) {
+				continue
+			}
+			totals.addHand(hand, 0)
+			countHoleCards(hand, summary)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hand history: %w", err)
+	}
+
+	metrics := totals.metrics(userID, "", &start)
+	summary.TotalHands = metrics.HandsPlayed
+	summary.HandsWon = metrics.HandsWon
+	summary.HandsLost = metrics.HandsLost
+	summary.HandsFolded = metrics.HandsFolded
+	summary.WinRate = metrics.WinRate
+	summary.TotalWagered = metrics.TotalWagered
+	summary.TotalWon = metrics.TotalWon
+	summary.NetResult = metrics.NetResult
+	summary.AvgPotSize = metrics.AvgPotSize
+	summary.AvgWinAmount = metrics.AvgWinAmount
+	summary.VPIPPercent = metrics.VPIPPercent
+	summary.PFRPercent = metrics.PFRPercent
+	summary.AggressionFactor = metrics.AggressionFactor
+	summary.FoldToSteal = metrics.FoldToStealPercent
+	return summary, nil
+}
+
+// countHoleCards adds a hand's hole cards to the premium hand counters.
+// Ace-deuce counts as connected, as it makes the wheel.
+func countHoleCards(hand *models.HandHistory, summary *models.HandSummary) {
+	first, ok := gridRank(hand.HoleCard1Rank)
+	if !ok {
+		return
+	}
+	second, ok := gridRank(hand.HoleCard2Rank)
+	if !ok {
+		return
+	}
+
+	gap := first - second
+	if gap < 0 {
+		gap = -gap
+	}
+	switch {
+	case gap == 0:
+		summary.PocketPairs++
+	case gap == 1 || gap == len(gridRanks)-1:
+		summary.ConnectedCards++
+	}
+	if gap != 0 && hand.HoleCard1Suit != "" && hand.HoleCard1Suit == hand.HoleCard2Suit {
+		summary.SuitedCards++
+	}
+}
+
+// GetSummaries returns a user's day or week summaries for the periods
+// starting in [from, to), oldest first. Periods that have ended are read
+// from the rolled up summaries; the one still running is totalled from its
+// hands so far.
+func (s *Service) GetSummaries(userID uuid.UUID, period models.SummaryPeriod, from, to time.Time) ([]models.HandSummary, error) {
+	if period != models.SummaryPeriodDay && period != models.SummaryPeriodWeek {
+		return nil, ErrUnknownPeriod
+	}
+
+	from = periodStart(period, from)
+	current := periodStart(period, time.Now())
+	summaries, err := s.handHistoryRepo.GetSummaries(userID, period, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get summaries: %w", err)
+	}
+
+	// Only rolled up periods are complete
+	complete := summaries[:0]
+	for _, summary := range summaries {
+		if summary.PeriodStart.Before(current) {
+			complete = append(complete, summary)
+		}
+	}
+	summaries = complete
+
+	if !current.Before(from) && current.Before(to) {
+		running, err := summarize(s.handHistoryRepo, userID, period, current)
+		if err != nil {
+			return nil, err
+		}
+		if running.TotalHands > 0 {
+			summaries = append(summaries, *running)
+		}
+	}
+	return summaries, nil
+}
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/internal/models"
+)
+
+func TestPeriodStart(t *testing.T) {
+	sunday := time.Date(2026, 4, 5, 23, 59, 0, 0, time.UTC)
+	monday := time.Date(2026, 4, 6, 0, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, time.Date(2026, 3, 30, 0, 0, 0, 0, time.UTC), periodStart(models.SummaryPeriodWeek, sunday))
+	assert.Equal(t, monday, periodStart(models.SummaryPeriodWeek, monday))
+	assert.Equal(t, time.Date(2026, 4, 5, 0, 0, 0, 0, time.UTC), periodStart(models.SummaryPeriodDay, sunday))
+}
+
+func TestRolledUpWeekMatchesDirectCalculation(t *testing.T) {
+	f := newAggregateFixture(t)
+	hands := f.aggregator.hands
+	summarizer := NewSummarizer(hands)
+	summarizer.now = func() time.Time { return time.Date(2026, 4, 10, 9, 0, 0, 0, time.UTC) }
+
+	// The fixture's hands all fall in the week starting Monday 30 March
+	week := time.Date(2026, 3, 30, 0, 0, 0, 0, time.UTC)
+	written, err := summarizer.RollUpCompleted()
+	require.NoError(t, err)
+	assert.Equal(t, 1, written, "nobody played on the latest day to end")
+
+	summaries, err := hands.GetSummaries(f.user.ID, models.SummaryPeriodWeek, week, week.AddDate(0, 0, 7))
+	require.NoError(t, err)
+	require.Len(t, summaries, 1)
+	summary := summaries[0]
+
+	direct, err := f.service.calculateMetrics(f.user.ID, f.user.Username, f.hands, &week)
+	require.NoError(t, err)
+	assert.Nil(t, summary.GameID)
+	assert.Equal(t, week, summary.PeriodStart.UTC())
+	assert.Equal(t, week.AddDate(0, 0, 7), summary.PeriodEnd.UTC())
+	assert.Equal(t, direct.HandsPlayed, summary.TotalHands)
+	assert.Equal(t, direct.HandsWon, summary.HandsWon)
+	assert.Equal(t, direct.HandsLost, summary.HandsLost)
+	assert.Equal(t, direct.HandsFolded, summary.HandsFolded)
+	assert.InDelta(t, direct.WinRate, summary.WinRate, 1e-9)
+	assert.Equal(t, direct.TotalWagered, summary.TotalWagered)
+	assert.Equal(t, direct.TotalWon, summary.TotalWon)
+	assert.Equal(t, direct.NetResult, summary.NetResult)
+	assert.InDelta(t, direct.AvgPotSize, summary.AvgPotSize, 1e-9)
+	assert.InDelta(t, direct.AvgWinAmount, summary.AvgWinAmount, 1e-9)
+	assert.InDelta(t, direct.VPIPPercent, summary.VPIPPercent, 1e-9)
+	assert.InDelta(t, direct.PFRPercent, summary.PFRPercent, 1e-9)
+	assert.InDelta(t, direct.AggressionFactor, summary.AggressionFactor, 1e-9)
+	assert.InDelta(t, direct.FoldToStealPercent, summary.FoldToSteal, 1e-9)
+
+	// Each of the five hole card pairs was dealt twelve times
+	assert.Equal(t, 12, summary.PocketPairs)
+	assert.Equal(t, 24, summary.SuitedCards, "KQs and A2s")
+	assert.Equal(t, 36, summary.ConnectedCards, "KQ, A2 and T9")
+
+	// Backfilling adds the days and replaces the week rather than adding
+	// another
+	written, err = summarizer.Backfill(time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, 6, written, "five days played and the week")
+
+	summaries, err = hands.GetSummaries(f.user.ID, models.SummaryPeriodWeek, week, week.AddDate(0, 0, 7))
+	require.NoError(t, err)
+	require.Len(t, summaries, 1)
+	assert.Equal(t, direct.NetResult, summaries[0].NetResult)
+
+	days, err := f.service.GetSummaries(f.user.ID, models.SummaryPeriodDay, week, week.AddDate(0, 0, 7))
+	require.NoError(t, err)
+	require.Len(t, days, 5)
+	var total int
+	var net int64
+	for _, day := range days {
+		total += day.TotalHands
+		net += day.NetResult
+	}
+	assert.Equal(t, len(f.hands), total)
+	assert.Equal(t, direct.NetResult, net)
+
+	_, err = f.service.GetSummaries(f.user.ID, "month", week, week.AddDate(0, 0, 7))
+	assert.ErrorIs(t, err, ErrUnknownPeriod)
+}
@@ -0,0 +1,277 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/primoPoker/server/internal/models"
+)
+
+// tally is the running totals metrics are derived from. Tallies add up, so
+// the ones stored per day and stake combine into those of any run of days.
+type tally struct {
+	hands       int
+	handsWon    int
+	handsFolded int
+	firstHandAt time.Time
+
+	showdowns           int
+	showdownsWon        int
+	wonDollarAtShowdown int64
+	flopsSeen           int
+	flopShowdowns       int
+	flopShowdownsWon    int
+	wonWhenSawFlop      int
+
+	totalWagered  int64
+	totalWon      int64
+	biggestWin    int64
+	biggestLoss   int64
+	biggestPotWon int64
+	potSizeSum    int64
+	bigBlindsWon  float64
+	rakePaid      int64
+	tableTime     time.Duration
+
+	counts actionCounts
+}
+
+// minRateTableTime is the least table time hourly rates are worked out
+// over; below it a single hand would swing them wildly
+const minRateTableTime = 15 * time.Minute
+
+// smallSampleHands is how many hands it takes before the win rates are
+// worth reading much into
+const smallSampleHands = 100
+
+// addHand counts one of the player's hands, which added tableTime to their
+// time at the table. A hand against bots counts for nothing.
+func (t *tally) addHand(hand *models.HandHistory, tableTime time.Duration) {
+	if hand.AgainstBots {
+		return
+	}
+	if t.hands == 0 || hand.StartedAt.Before(t.firstHandAt) {
+		t.firstHandAt = hand.StartedAt
+	}
+	t.hands++
+
+	if hand.IsWinner {
+		t.handsWon++
+	}
+	if hand.FoldedPhase != "" {
+		t.handsFolded++
+	}
+	if hand.WentToShowdown {
+		t.showdowns++
+		if hand.IsWinner {
+			t.showdownsWon++
+			t.wonDollarAtShowdown += hand.AmountWon
+		}
+	}
+	if sawFlop(hand) {
+		t.flopsSeen++
+		if hand.AmountWon > 0 {
+			t.wonWhenSawFlop++
+		}
+		if hand.WentToShowdown {
+			t.flopShowdowns++
+			if hand.AmountWon > 0 {
+				t.flopShowdownsWon++
+			}
+		}
+	}
+
+	t.totalWagered += hand.StartingChips - hand.EndingChips + hand.AmountWon
+	t.totalWon += hand.AmountWon
+	if hand.NetResult > t.biggestWin {
+		t.biggestWin = hand.NetResult
+	}
+	if hand.NetResult < t.biggestLoss {
+		t.biggestLoss = hand.NetResult
+	}
+	if hand.AmountWon > 0 && hand.PotSize > t.biggestPotWon {
+		t.biggestPotWon = hand.PotSize
+	}
+	t.potSizeSum += hand.PotSize
+	if hand.BigBlind > 0 {
+		t.bigBlindsWon += float64(hand.NetResult) / float64(hand.BigBlind)
+	}
+	t.rakePaid += rakePaid(hand)
+	t.tableTime += tableTime
+
+	calculateHandMetrics(hand, &t.counts)
+}
+
+// merge adds another tally's totals to this one
+func (t *tally) merge(other *tally) {
+	if other.hands == 0 {
+		return
+	}
+	if t.hands == 0 || other.firstHandAt.Before(t.firstHandAt) {
+		t.firstHandAt = other.firstHandAt
+	}
+	t.hands += other.hands
+	t.handsWon += other.handsWon
+	t.handsFolded += other.handsFolded
+
+	t.showdowns += other.showdowns
+	t.showdownsWon += other.showdownsWon
+	t.wonDollarAtShowdown += other.wonDollarAtShowdown
+	t.flopsSeen += other.flopsSeen
+	t.flopShowdowns += other.flopShowdowns
+	t.flopShowdownsWon += other.flopShowdownsWon
+	t.wonWhenSawFlop += other.wonWhenSawFlop
+
+	t.totalWagered += other.totalWagered
+	t.totalWon += other.totalWon
+	if other.biggestWin > t.biggestWin {
+		t.biggestWin = other.biggestWin
+	}
+	if other.biggestLoss < t.biggestLoss {
+		t.biggestLoss = other.biggestLoss
+	}
+	if other.biggestPotWon > t.biggestPotWon {
+		t.biggestPotWon = other.biggestPotWon
+	}
+	t.potSizeSum += other.potSizeSum
+	t.bigBlindsWon += other.bigBlindsWon
+	t.rakePaid += other.rakePaid
+	t.tableTime += other.tableTime
+
+	t.counts.merge(&other.counts)
+}
+
+// rakePaid is the player's share of the rake taken from a hand, split
+// evenly between its winners with any odd chips on the first. Hands
+// recorded without a table-level record paid none.
+func rakePaid(hand *models.HandHistory) int64 {
+	if hand.Hand == nil || hand.Hand.Rake == 0 {
+		return 0
+	}
+	winners := hand.Hand.Winners
+	for i, winner := range winners {
+		if winner == hand.UserID {
+			share := hand.Hand.Rake / int64(len(winners))
+			if i == 0 {
+				share += hand.Hand.Rake % int64(len(winners))
+			}
+			return share
+		}
+	}
+	return 0
+}
+
+// merge adds another hand's or period's action counts to these
+func (c *actionCounts) merge(other *actionCounts) {
+	c.vpip += other.vpip
+	c.pfr += other.pfr
+
+	c.threeBetChances += other.threeBetChances
+	c.threeBets += other.threeBets
+	c.facedThreeBet += other.facedThreeBet
+	c.foldsToThreeBet += other.foldsToThreeBet
+	c.fourBetChances += other.fourBetChances
+	c.fourBets += other.fourBets
+	c.facedFourBet += other.facedFourBet
+	c.foldsToFourBet += other.foldsToFourBet
+
+	c.stealChances += other.stealChances
+	c.steals += other.steals
+	c.facedSteal += other.facedSteal
+	c.foldsToSteal += other.foldsToSteal
+	c.threeBetsVsSteal += other.threeBetsVsSteal
+	c.bigBlindHands += other.bigBlindHands
+	c.walks += other.walks
+
+	c.cBets += other.cBets
+	c.foldToCBets += other.foldToCBets
+
+	c.aggressive += other.aggressive
+	c.passive += other.passive
+}
+
+// metrics derives a player's metrics from the totals. The period starts at
+// since, or at the first hand counted when since is nil.
+func (t *tally) metrics(userID uuid.UUID, username string, since *time.Time) *PlayerMetrics {
+	metrics := &PlayerMetrics{
+		UserID:    userID,
+		Username:  username,
+		PeriodEnd: time.Now(),
+	}
+	if since != nil {
+		metrics.PeriodStart = *since
+	} else {
+		metrics.PeriodStart = t.firstHandAt
+	}
+
+	metrics.HandsPlayed = t.hands
+	metrics.HandsWon = t.handsWon
+	metrics.HandsLost = t.hands - t.handsWon - t.handsFolded
+	metrics.HandsFolded = t.handsFolded
+
+	if t.hands > 0 {
+		metrics.WinRate = float64(t.handsWon) / float64(t.hands) * 100.0
+		metrics.VPIPPercent = float64(t.counts.vpip) / float64(t.hands) * 100.0
+		metrics.PFRPercent = float64(t.counts.pfr) / float64(t.hands) * 100.0
+		metrics.AvgPotSize = float64(t.potSizeSum) / float64(t.hands)
+		metrics.BBPer100 = t.bigBlindsWon / float64(t.hands) * 100.0
+	}
+
+	// Re-raise frequencies, each out of the spots where it was possible
+	metrics.ThreeBetPercent = percentOf(t.counts.threeBets, t.counts.threeBetChances)
+	metrics.FoldToThreeBetPercent = percentOf(t.counts.foldsToThreeBet, t.counts.facedThreeBet)
+	metrics.FourBetPercent = percentOf(t.counts.fourBets, t.counts.fourBetChances)
+	metrics.FoldToFourBetPercent = percentOf(t.counts.foldsToFourBet, t.counts.facedFourBet)
+
+	// Stealing the blinds and defending them
+	metrics.AttemptToStealPercent = percentOf(t.counts.steals, t.counts.stealChances)
+	metrics.FoldToStealPercent = percentOf(t.counts.foldsToSteal, t.counts.facedSteal)
+	metrics.ThreeBetVsStealPercent = percentOf(t.counts.threeBetsVsSteal, t.counts.facedSteal)
+	metrics.WalkPercent = percentOf(t.counts.walks, t.counts.bigBlindHands)
+
+	// C-bet calculations (post-flop continuation betting)
+	cBetOpportunities := t.counts.cBets + t.counts.foldToCBets
+	if cBetOpportunities > 0 {
+		metrics.CBetPercent = float64(t.counts.cBets) / float64(cBetOpportunities) * 100.0
+		metrics.FoldToCBetPercent = float64(t.counts.foldToCBets) / float64(cBetOpportunities) * 100.0
+	}
+
+	// Aggression factor
+	if t.counts.passive > 0 {
+		metrics.AggressionFactor = float64(t.counts.aggressive) / float64(t.counts.passive)
+	} else if t.counts.aggressive > 0 {
+		metrics.AggressionFactor = 999.0 // Very aggressive
+	}
+
+	// Showdown statistics
+	metrics.FlopsSeen = t.flopsSeen
+	metrics.WTSDPercent = percentOf(t.flopShowdowns, t.flopsSeen)
+	metrics.WSDPercent = percentOf(t.flopShowdownsWon, t.flopShowdowns)
+	metrics.WWSFPercent = percentOf(t.wonWhenSawFlop, t.flopsSeen)
+	metrics.WentToShowdown = t.showdowns
+	metrics.WonAtShowdown = t.showdownsWon
+	if t.showdowns > 0 {
+		metrics.ShowdownWinRate = float64(t.showdownsWon) / float64(t.showdowns) * 100.0
+	}
+	metrics.WonDollarAtShowdown = t.wonDollarAtShowdown
+
+	// Financial metrics
+	metrics.TotalWagered = t.totalWagered
+	metrics.TotalWon = t.totalWon
+	metrics.NetResult = t.totalWon - t.totalWagered
+	if t.handsWon > 0 {
+		metrics.AvgWinAmount = float64(t.totalWon) / float64(t.handsWon)
+	}
+	metrics.BiggestWin = t.biggestWin
+	metrics.BiggestLoss = t.biggestLoss
+
+	// Win rates, normalised for stakes and for time played
+	metrics.TableTime = int(t.tableTime.Seconds())
+	if t.tableTime >= minRateTableTime {
+		metrics.ChipsPerHour = float64(metrics.NetResult) / t.tableTime.Hours()
+		metrics.BBPerHour = t.bigBlindsWon / t.tableTime.Hours()
+	}
+	metrics.SmallSample = t.hands < smallSampleHands
+
+	return metrics
+}
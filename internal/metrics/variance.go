@@ -0,0 +1,195 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/primoPoker/server/internal/models"
+)
+
+// Variance is how far a player's results swing, worked out over their hands
+// in the order they started. A hand or session that breaks even is neither
+// a win nor a loss: it leaves streaks running rather than ending them, so
+// the hands folded without putting chips in do not cut every streak short.
+type Variance struct {
+	StdDevBB float64 `json:"std_dev_bb"` // Standard deviation of per-hand results in big blinds
+
+	LongestWinStreak         int `json:"longest_win_streak"`  // Hands won in a row
+	LongestLoseStreak        int `json:"longest_lose_streak"` // Hands lost in a row
+	CurrentStreak            int `json:"current_streak"`      // Hands in the streak still running, negative for losses
+	LongestWinSessionStreak  int `json:"longest_win_session_streak"`
+	LongestLoseSessionStreak int `json:"longest_lose_session_streak"`
+
+	MaxDownswing   int64   `json:"max_downswing"`    // Most chips lost from a peak of the running total
+	MaxDownswingBB float64 `json:"max_downswing_bb"` // The same in big blinds, each hand in its own big blind
+}
+
+// streak follows runs of winning and losing results
+type streak struct {
+	current     int // positive while winning, negative while losing
+	longestWin  int
+	longestLose int
+}
+
+// add extends the run with a result, which breaking even leaves alone
+func (s *streak) add(result float64) {
+	switch {
+	case result > 0:
+		if s.current < 0 {
+			s.current = 0
+		}
+		s.current++
+		if s.current > s.longestWin {
+			s.longestWin = s.current
+		}
+	case result < 0:
+		if s.current > 0 {
+			s.current = 0
+		}
+		s.current--
+		if -s.current > s.longestLose {
+			s.longestLose = -s.current
+		}
+	}
+}
+
+// drawdown follows a running total and the most it has fallen from a peak.
+// The total starts at zero, which counts as the first peak.
+type drawdown struct {
+	total float64
+	peak  float64
+	max   float64
+}
+
+// add moves the running total by result
+func (d *drawdown) add(result float64) {
+	d.total += result
+	if d.total > d.peak {
+		d.peak = d.total
+	}
+	if d.peak-d.total > d.max {
+		d.max = d.peak - d.total
+	}
+}
+
+// varianceTally accumulates a Variance from hands added in the order they
+// started. Unlike a tally it cannot be merged, as streaks and downswings
+// depend on the order of every hand.
+type varianceTally struct {
+	gap time.Duration
+
+	// Running mean and sum of squared deviations of the results in big
+	// blinds (Welford's method), over the hands with a big blind
+	bbHands int
+	bbMean  float64
+	bbM2    float64
+
+	hands    streak
+	sessions streak
+	chips    drawdown
+	bigBlind drawdown
+
+	started    bool
+	lastFinish time.Time
+	sessionNet int64
+}
+
+// newVarianceTally creates a tally that starts a new session after a break
+// of at least gap, as inferSessions does
+func newVarianceTally(gap time.Duration) *varianceTally {
+	return &varianceTally{gap: gap}
+}
+
+// addHand counts the next hand to have started, unless it was against bots
+func (v *varianceTally) addHand(hand *models.HandHistory) {
+	if hand.AgainstBots {
+		return
+	}
+	if v.started && hand.StartedAt.Sub(v.lastFinish) >= v.gap {
+		v.sessions.add(float64(v.sessionNet))
+		v.sessionNet = 0
+	}
+	if !v.started || hand.FinishedAt.After(v.lastFinish) {
+		v.lastFinish = hand.FinishedAt
+	}
+	v.started = true
+	v.sessionNet += hand.NetResult
+
+	v.hands.add(float64(hand.NetResult))
+	v.chips.add(float64(hand.NetResult))
+	if hand.BigBlind > 0 {
+		bb := float64(hand.NetResult) / float64(hand.BigBlind)
+		v.bigBlind.add(bb)
+
+		v.bbHands++
+		delta := bb - v.bbMean
+		v.bbMean += delta / float64(v.bbHands)
+		v.bbM2 += delta * (bb - v.bbMean)
+	}
+}
+
+// variance returns the statistics of the hands added so far, counting the
+// session still open as finished
+func (v *varianceTally) variance() *Variance {
+	sessions := v.sessions
+	if v.started {
+		sessions.add(float64(v.sessionNet))
+	}
+
+	variance := &Variance{
+		LongestWinStreak:         v.hands.longestWin,
+		LongestLoseStreak:        v.hands.longestLose,
+		CurrentStreak:            v.hands.current,
+		LongestWinSessionStreak:  sessions.longestWin,
+		LongestLoseSessionStreak: sessions.longestLose,
+		MaxDownswing:             int64(v.chips.max),
+		MaxDownswingBB:           v.bigBlind.max,
+	}
+	// A sample of one hand says nothing about its spread
+	if v.bbHands > 1 {
+		variance.StdDevBB = math.Sqrt(v.bbM2 / float64(v.bbHands-1))
+	}
+	return variance
+}
+
+// calculateVariance works out the variance of hands given in any order
+func calculateVariance(hands []models.HandHistory, gap time.Duration) *Variance {
+	v := newVarianceTally(gap)
+	for _, i := range startOrder(hands) {
+		v.addHand(&hands[i])
+	}
+	return v.variance()
+}
+
+// streamVariance works out the variance of a user's hands since the given
+// time, or of every hand they have played when since is nil, reading them
+// from the repository a batch at a time
+func (s *Service) streamVariance(userID uuid.UUID, since *time.Time, filter Filter) (*Variance, error) {
+	var from time.Time
+	if since != nil {
+		from = *since
+	}
+
+	v := newVarianceTally(s.sessionGap)
+	addBatch := func(batch []models.HandHistory) error {
+		for i := range batch {
+			if filter.matches(&batch[i]) {
+				v.addHand(&batch[i])
+			}
+		}
+		return nil
+	}
+
+	// Archived hands come before the live ones
+	now := time.Now()
+	if err := s.handHistoryRepo.StreamUserArchivedHands(userID, from, now, sessionBatchSize, addBatch); err != nil {
+		return nil, fmt.Errorf("failed to get archived hand history: %w", err)
+	}
+	if err := s.handHistoryRepo.StreamUserHands(userID, from, now, sessionBatchSize, addBatch); err != nil {
+		return nil, fmt.Errorf("failed to get hand history: %w", err)
+	}
+	return v.variance(), nil
+}
@@ -0,0 +1,142 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/primoPoker/server/internal/models"
+)
+
+// varianceHands builds one hand per result at a 100 chip big blind, each
+// starting the given number of minutes into the day and lasting a minute
+func varianceHands(starts []int, nets []int64) []models.HandHistory {
+	day := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	hands := make([]models.HandHistory, len(nets))
+	for i, net := range nets {
+		start := day.Add(time.Duration(starts[i]) * time.Minute)
+		hands[i] = models.HandHistory{
+			ID:         uuid.New(),
+			BigBlind:   100,
+			NetResult:  net,
+			StartedAt:  start,
+			FinishedAt: start.Add(time.Minute),
+		}
+	}
+	return hands
+}
+
+// oneSession returns start times for n hands played in one session, each a
+// minute after the last finished
+func oneSession(n int) []int {
+	starts := make([]int, n)
+	for i := range starts {
+		starts[i] = i * 2
+	}
+	return starts
+}
+
+func TestVariance(t *testing.T) {
+	cases := []struct {
+		name string
+		nets []int64
+		want Variance
+	}{
+		{
+			// Running total 300 200 -200 0 500 -200 -100: down 500 from
+			// the first peak, then 700 from the higher second one
+			name: "drawdown from the highest peak",
+			nets: []int64{300, -100, -400, 200, 500, -700, 100},
+			want: Variance{
+				StdDevBB:                 4.180453,
+				LongestWinStreak:         2,
+				LongestLoseStreak:        2,
+				CurrentStreak:            1,
+				LongestLoseSessionStreak: 1,
+				MaxDownswing:             700,
+				MaxDownswingBB:           7,
+			},
+		},
+		{
+			// Losing from the first hand falls from the starting zero
+			name: "drawdown from the start",
+			nets: []int64{-200, -100, 50},
+			want: Variance{
+				StdDevBB:                 1.258306,
+				LongestWinStreak:         1,
+				LongestLoseStreak:        2,
+				CurrentStreak:            1,
+				LongestLoseSessionStreak: 1,
+				MaxDownswing:             300,
+				MaxDownswingBB:           3,
+			},
+		},
+		{
+			name: "every hand won",
+			nets: []int64{100, 200, 300},
+			want: Variance{
+				StdDevBB:                1,
+				LongestWinStreak:        3,
+				CurrentStreak:           3,
+				LongestWinSessionStreak: 1,
+			},
+		},
+		{
+			name: "single hand",
+			nets: []int64{-250},
+			want: Variance{
+				LongestLoseStreak:        1,
+				CurrentStreak:            -1,
+				LongestLoseSessionStreak: 1,
+				MaxDownswing:             250,
+				MaxDownswingBB:           2.5,
+			},
+		},
+		{
+			// Hands breaking even neither extend nor end a streak
+			name: "ties",
+			nets: []int64{100, 0, 100, 0, -100, 0, -100},
+			want: Variance{
+				StdDevBB:                 0.816497,
+				LongestWinStreak:         2,
+				LongestLoseStreak:        2,
+				CurrentStreak:            -2,
+				LongestWinSessionStreak:  0,
+				LongestLoseSessionStreak: 0,
+				MaxDownswing:             200,
+				MaxDownswingBB:           2,
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := calculateVariance(varianceHands(oneSession(len(tc.nets)), tc.nets), 45*time.Minute)
+			assert.InDelta(t, tc.want.StdDevBB, got.StdDevBB, 1e-6)
+			got.StdDevBB = tc.want.StdDevBB
+			assert.Equal(t, tc.want, *got)
+		})
+	}
+}
+
+func TestVarianceSessionStreaks(t *testing.T) {
+	// Sessions an hour apart: up, up, even, up, down, down
+	starts := []int{0, 2, 60, 120, 122, 180, 240, 242, 300}
+	nets := []int64{200, -100, 50, 100, -100, 300, -200, 100, -50}
+
+	got := calculateVariance(varianceHands(starts, nets), 45*time.Minute)
+	assert.Equal(t, 3, got.LongestWinSessionStreak, "the even session does not end the run")
+	assert.Equal(t, 2, got.LongestLoseSessionStreak)
+}
+
+func TestVarianceTakesHandsInStartOrder(t *testing.T) {
+	hands := varianceHands(oneSession(4), []int64{500, -300, -300, 200})
+	hands[0], hands[3] = hands[3], hands[0]
+	hands[1], hands[2] = hands[2], hands[1]
+
+	got := calculateVariance(hands, 45*time.Minute)
+	assert.Equal(t, int64(600), got.MaxDownswing)
+	assert.Equal(t, 1, got.CurrentStreak)
+}
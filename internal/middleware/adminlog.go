@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+
+	"github.com/primoPoker/server/internal/logging"
+	"github.com/primoPoker/server/internal/models"
+)
+
+// maxAuditBody is how much of a request body the admin access log keeps;
+// a longer body is recorded as truncated rather than parsed
+const maxAuditBody = 8 << 10
+
+// redacted replaces the values of body fields the access log may not keep
+const redacted = "[REDACTED]"
+
+// AuditStore records admin requests in the audit log
+type AuditStore interface {
+	Create(entry *models.AuditLog) error
+	RecordAccess(id uuid.UUID, access models.AuditAccess) error
+}
+
+// auditTargetVars are the route variables naming what an admin request
+// acts on, most specific first
+var auditTargetVars = []struct {
+	name   string
+	target models.AuditTargetType
+}{
+	{"reportId", models.AuditTargetReport},
+	{"userId", models.AuditTargetUser},
+	{"gameId", models.AuditTargetGame},
+	{"flag", models.AuditTargetFlag},
+}
+
+// AdminAccessLog records every request to the admin routes it wraps: who
+// made it, the route and its variables, the body, the status and how long
+// it took. The record is added to the audit entry the handler wrote for
+// its action, or written as an entry of its own when there was none, so
+// each request leaves exactly one entry; it is logged as well.
+//
+// Only the body fields listed in bodyFields for the request's route
+// template are kept; the values of all others, and every field of routes
+// with no list, are redacted. Free text such as reasons is already kept
+// in the entry itself, so it is better left off the lists.
+func AdminAccessLog(store AuditStore, bodyFields map[string][]string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			trail, r := withAuditTrail(r)
+
+			body := &cappedBuffer{limit: maxAuditBody}
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.TeeReader(r.Body, body), r.Body}
+
+			wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(wrapped, r)
+
+			route := routeTemplate(r)
+			vars := mux.Vars(r)
+			access := models.AuditAccess{
+				Method:      r.Method,
+				Route:       route,
+				RequestBody: redactBody(body, bodyFields[route]),
+				Status:      wrapped.statusCode,
+				LatencyMS:   time.Since(start).Milliseconds(),
+				RequestID:   logging.RequestID(r.Context()),
+			}
+			if len(vars) > 0 {
+				access.RouteVars, _ = json.Marshal(vars)
+			}
+
+			log := logging.FromContext(r.Context()).WithFields(logrus.Fields{
+				"admin_access": true,
+				"method":       access.Method,
+				"route":        access.Route,
+				"route_vars":   vars,
+				"body":         string(access.RequestBody),
+				"status":       access.Status,
+				"latency_ms":   access.LatencyMS,
+			})
+
+			var err error
+			if trail.entry != nil && trail.entry.ID != uuid.Nil {
+				err = store.RecordAccess(trail.entry.ID, access)
+			} else {
+				err = store.Create(accessEntry(r, vars, access))
+			}
+			if err != nil {
+				log.WithError(err).Error("Failed to write admin request to the audit log")
+				return
+			}
+			log.Info("Admin request")
+		})
+	}
+}
+
+// accessEntry is the audit entry of a request whose handler wrote none
+func accessEntry(r *http.Request, vars map[string]string, access models.AuditAccess) *models.AuditLog {
+	entry := &models.AuditLog{
+		Action:      "admin_request",
+		TargetType:  models.AuditTargetRequest,
+		IPAddress:   ClientIP(r),
+		AuditAccess: access,
+	}
+	entry.ActorUsername, _ = r.Context().Value("username").(string)
+	if userID, ok := r.Context().Value("user_id").(string); ok {
+		if actorID, err := uuid.Parse(userID); err == nil {
+			entry.ActorID = &actorID
+		}
+	}
+	for _, v := range auditTargetVars {
+		if id, ok := vars[v.name]; ok {
+			entry.TargetType, entry.TargetID = v.target, id
+			break
+		}
+	}
+	return entry
+}
+
+// redactBody returns the captured body with the values of fields not in
+// allowed redacted, or nil if there was none
+func redactBody(body *cappedBuffer, allowed []string) json.RawMessage {
+	if body.truncated {
+		return json.RawMessage(`{"truncated":true}`)
+	}
+	if len(bytes.TrimSpace(body.buf.Bytes())) == 0 {
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body.buf.Bytes(), &fields); err != nil {
+		return json.RawMessage(`{"invalid":true}`)
+	}
+	for name := range fields {
+		if !slices.Contains(allowed, name) {
+			fields[name] = json.RawMessage(`"` + redacted + `"`)
+		}
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// cappedBuffer keeps the first limit bytes written to it
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.buf.Len(); len(p) > room {
+		b.truncated = true
+		b.buf.Write(p[:room])
+	} else {
+		b.buf.Write(p)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/pagination"
+	"github.com/primoPoker/server/internal/repository"
+	"github.com/primoPoker/server/internal/testutil"
+)
+
+// adminRouter serves an audited config change and an unaudited read on
+// admin routes wrapped like the server's, signed in as actor
+func adminRouter(repo *repository.AuditLogRepository, actor uuid.UUID) http.Handler {
+	router := mux.NewRouter()
+	admin := router.PathPrefix("/api/v1/admin").Subrouter()
+	admin.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), "user_id", actor.String())
+			next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, "username", "root")))
+		})
+	})
+	admin.Use(AdminAccessLog(repo, map[string][]string{
+		"/api/v1/admin/games/{gameId}/config": {"small_blind", "big_blind"},
+	}))
+	admin.Use(RequireAudit)
+
+	admin.HandleFunc("/games/{gameId}/config", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "bad body", http.StatusBadRequest)
+			return
+		}
+		entry := &models.AuditLog{Action: "update_game_config", TargetType: models.AuditTargetGame, TargetID: mux.Vars(r)["gameId"], Reason: "tilted"}
+		if err := repo.Create(entry); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		MarkAudited(r, entry)
+		w.WriteHeader(http.StatusOK)
+	}).Methods("PUT")
+	admin.HandleFunc("/audit", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET")
+	return router
+}
+
+// auditRows lists every audit entry
+func auditRows(t *testing.T, repo *repository.AuditLogRepository) []models.AuditLog {
+	page, err := repo.List(repository.AuditLogFilter{}, pagination.PageRequest{Limit: 100})
+	require.NoError(t, err)
+	return page.Items
+}
+
+func TestAdminAccessLogCompletesTheHandlersEntry(t *testing.T) {
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+
+	repo := repository.NewAuditLogRepository(testutil.NewDB(t, &models.AuditLog{}))
+	actor := uuid.New()
+	router := adminRouter(repo, actor)
+
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/admin/games/g1/config",
+		strings.NewReader(`{"small_blind": 100, "big_blind": 200, "reason": "password is hunter2"}`))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	rows := auditRows(t, repo)
+	require.Len(t, rows, 1, "the access log adds to the handler's entry")
+	row := rows[0]
+	assert.Equal(t, "update_game_config", row.Action)
+	assert.Equal(t, "g1", row.TargetID)
+	assert.Equal(t, http.MethodPut, row.Method)
+	assert.Equal(t, "/api/v1/admin/games/{gameId}/config", row.Route)
+	assert.JSONEq(t, `{"gameId": "g1"}`, string(row.RouteVars))
+	assert.JSONEq(t, `{"small_blind": 100, "big_blind": 200, "reason": "[REDACTED]"}`, string(row.RequestBody))
+	assert.Equal(t, http.StatusOK, row.Status)
+	assert.GreaterOrEqual(t, row.LatencyMS, int64(0))
+
+	for _, entry := range hook.AllEntries() {
+		line, err := entry.String()
+		require.NoError(t, err)
+		assert.NotContains(t, line, "hunter2")
+	}
+}
+
+func TestAdminAccessLogRecordsRequestsWithoutAnEntry(t *testing.T) {
+	repo := repository.NewAuditLogRepository(testutil.NewDB(t, &models.AuditLog{}))
+	actor := uuid.New()
+	router := adminRouter(repo, actor)
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/admin/audit", nil))
+
+	rows := auditRows(t, repo)
+	require.Len(t, rows, 1)
+	assert.Equal(t, "admin_request", rows[0].Action)
+	assert.Equal(t, models.AuditTargetRequest, rows[0].TargetType)
+	require.NotNil(t, rows[0].ActorID)
+	assert.Equal(t, actor, *rows[0].ActorID)
+	assert.Equal(t, "root", rows[0].ActorUsername)
+	assert.Equal(t, http.StatusOK, rows[0].Status)
+	assert.Empty(t, rows[0].RequestBody)
+
+	// A refused change is recorded against what it targeted
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/api/v1/admin/games/g2/config", strings.NewReader(`{"small_blind":`)))
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rows = auditRows(t, repo)
+	require.Len(t, rows, 2)
+	refused := rows[slices.IndexFunc(rows, func(row models.AuditLog) bool { return row.Method == http.MethodPut })]
+	assert.Equal(t, models.AuditTargetGame, refused.TargetType)
+	assert.Equal(t, "g2", refused.TargetID)
+	assert.Equal(t, http.StatusBadRequest, refused.Status)
+	assert.JSONEq(t, `{"invalid": true}`, string(refused.RequestBody))
+}
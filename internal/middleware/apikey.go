@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/time/rate"
+
+	"github.com/primoPoker/server/internal/auth"
+	"github.com/primoPoker/server/internal/models"
+)
+
+// APIKeyHeader carries an API key in place of a bearer token
+const APIKeyHeader = "X-API-Key"
+
+// API keys are rate limited per key rather than per IP, so a bot neither
+// shares its owner's interactive budget nor loses its own to other clients
+// behind the same address
+const apiKeyRateLimitPerMinute = 300
+
+var apiKeyLimiters = newLimiterSet()
+
+// apiKeyLimiter returns the rate limiter of an API key
+func apiKeyLimiter(keyID uuid.UUID) *rate.Limiter {
+	limit := rate.Every(time.Minute / apiKeyRateLimitPerMinute)
+	return apiKeyLimiters.get(keyID.String(), limit, apiKeyRateLimitPerMinute/10, time.Now())
+}
+
+// allowAPIKey reports whether a request made with an API key is within
+// the key's limit
+func allowAPIKey(keyID uuid.UUID) bool {
+	if allowed, ok := allowShared("api_key:"+keyID.String(), apiKeyRateLimitPerMinute, apiKeyRateLimitPerMinute/10); ok {
+		return allowed
+	}
+	return apiKeyLimiter(keyID).Allow()
+}
+
+// authenticateAPIKey authenticates a request by its X-API-Key header and
+// adds the key's user and scopes to its context. It writes the error
+// response itself when the key is refused.
+func authenticateAPIKey(authService *auth.Service, w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	user, key, err := authService.AuthenticateAPIKey(r.Header.Get(APIKeyHeader))
+	if err != nil {
+		// Bad keys count against the client's IP like any other request
+		if !allowIP(ClientIP(r)) {
+			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+			return nil, false
+		}
+		if errors.Is(err, auth.ErrAccountDisabled) {
+			http.Error(w, "Account is disabled", http.StatusForbidden)
+			return nil, false
+		}
+		http.Error(w, "Invalid API key", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	if !allowAPIKey(key.ID) {
+		http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+		return nil, false
+	}
+
+	ctx := context.WithValue(r.Context(), "user_id", user.ID.String())
+	ctx = context.WithValue(ctx, "username", user.Username)
+	ctx = context.WithValue(ctx, "role", string(user.Role))
+	ctx = context.WithValue(ctx, "api_key_id", key.ID.String())
+	ctx = context.WithValue(ctx, "api_key_scopes", key.ScopeList())
+	return r.WithContext(ctx), true
+}
+
+// APIKeyAuth authenticates requests that carry an API key and lets the rest
+// through unchanged. It runs ahead of RateLimit, which leaves authenticated
+// keys to their own limit, and of JWTAuthMiddleware, which accepts them in
+// place of a bearer token.
+func APIKeyAuth(authService *auth.Service) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get(APIKeyHeader) != "" {
+				var ok bool
+				if r, ok = authenticateAPIKey(authService, w, r); !ok {
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireScope restricts requests authenticated with an API key to keys
+// granting scope. Session requests act with the user's full access and are
+// not affected.
+func RequireScope(scope models.APIScope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !hasScope(r, scope) {
+				http.Error(w, "API key lacks the "+string(scope)+" scope", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ScopeByMethod requires the read scope of API keys for safe methods and
+// the play scope for everything else
+func ScopeByMethod(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scope := models.ScopePlay
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			scope = models.ScopeRead
+		}
+		RequireScope(scope)(next).ServeHTTP(w, r)
+	})
+}
+
+// RequireSession refuses requests authenticated with an API key, for
+// account management that only the user themselves should do
+func RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value("api_key_scopes").([]models.APIScope); ok {
+			http.Error(w, "This endpoint cannot be used with an API key", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// hasScope checks if a request may act with a scope
+func hasScope(r *http.Request, scope models.APIScope) bool {
+	scopes, ok := r.Context().Value("api_key_scopes").([]models.APIScope)
+	if !ok {
+		return true
+	}
+	for _, s := range scopes {
+		if s.Includes(scope) {
+			return true
+		}
+	}
+	return false
+}
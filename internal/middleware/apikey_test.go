@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/internal/models"
+)
+
+func TestAPIKeyAuthenticatesProtectedRoutes(t *testing.T) {
+	service := newAuthService(t)
+	user, _ := signIn(t, service, "bot-owner", models.RolePlayer)
+	secret, _, err := service.CreateAPIKey(user.ID, "bot", []models.APIScope{models.ScopeRead}, nil)
+	require.NoError(t, err)
+
+	var userID string
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ = r.Context().Value("user_id").(string)
+	})
+	handler := APIKeyAuth(service)(JWTAuthMiddleware(service)(ScopeByMethod(ok)))
+
+	call := func(method, key string) int {
+		req := httptest.NewRequest(method, "/api/v1/games", nil)
+		if key != "" {
+			req.Header.Set(APIKeyHeader, key)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	assert.Equal(t, http.StatusOK, call("GET", secret))
+	assert.Equal(t, user.ID.String(), userID)
+
+	// A read-only key cannot act
+	assert.Equal(t, http.StatusForbidden, call("POST", secret))
+	assert.Equal(t, http.StatusUnauthorized, call("GET", secret+"x"))
+	assert.Equal(t, http.StatusUnauthorized, call("GET", ""))
+}
+
+func TestAPIKeyScopesAndSessions(t *testing.T) {
+	service := newAuthService(t)
+	user, token := signIn(t, service, "admin", models.RoleAdmin)
+	playKey, _, err := service.CreateAPIKey(user.ID, "bot", []models.APIScope{models.ScopePlay}, nil)
+	require.NoError(t, err)
+	adminKey, _, err := service.CreateAPIKey(user.ID, "tools", []models.APIScope{models.ScopeAdmin}, nil)
+	require.NoError(t, err)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	authenticate := func(next http.Handler) http.Handler {
+		return APIKeyAuth(service)(JWTAuthMiddleware(service)(next))
+	}
+	adminRoute := authenticate(RequireScope(models.ScopeAdmin)(RequireRole(service, models.RoleAdmin)(ok)))
+	accountRoute := authenticate(RequireSession(ok))
+
+	call := func(handler http.Handler, header, value string) int {
+		req := httptest.NewRequest("POST", "/api/v1/admin/games/1/close", nil)
+		req.Header.Set(header, value)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	assert.Equal(t, http.StatusForbidden, call(adminRoute, APIKeyHeader, playKey))
+	assert.Equal(t, http.StatusOK, call(adminRoute, APIKeyHeader, adminKey))
+	assert.Equal(t, http.StatusOK, call(adminRoute, "Authorization", "Bearer "+token))
+
+	// Keys cannot manage the account, whatever their scope
+	assert.Equal(t, http.StatusForbidden, call(accountRoute, APIKeyHeader, adminKey))
+	assert.Equal(t, http.StatusOK, call(accountRoute, "Authorization", "Bearer "+token))
+}
+
+func TestAPIKeyRateLimitedPerKey(t *testing.T) {
+	service := newAuthService(t)
+	user, _ := signIn(t, service, "bot-owner", models.RolePlayer)
+	secret, key, err := service.CreateAPIKey(user.ID, "bot", []models.APIScope{models.ScopePlay}, nil)
+	require.NoError(t, err)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := APIKeyAuth(service)(RateLimit(ok))
+
+	call := func(withKey bool) int {
+		req := httptest.NewRequest("GET", "/api/v1/games", nil)
+		req.RemoteAddr = "203.0.113.50:1234"
+		if withKey {
+			req.Header.Set(APIKeyHeader, secret)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	// Exhaust the address's interactive budget; the key keeps its own
+	for call(false) != http.StatusTooManyRequests {
+	}
+	assert.Equal(t, http.StatusOK, call(true))
+
+	limiter := apiKeyLimiter(key.ID)
+	for limiter.Allow() {
+	}
+	assert.Equal(t, http.StatusTooManyRequests, call(true))
+}
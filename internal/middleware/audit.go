@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/primoPoker/server/internal/models"
+)
+
+// auditTrailKey is the context key of a request's auditTrail
+type auditTrailKey struct{}
+
+// auditTrail notes whether a request's action reached the audit log, and
+// in which entry
+type auditTrail struct {
+	recorded bool
+	entry    *models.AuditLog
+}
+
+// RequireAudit makes every change through the routes it wraps leave an
+// audit entry. Handlers call MarkAudited once theirs is written; a change
+// about to be reported as a success without one is answered with a 500
+// and logged as an error instead, so an admin endpoint that skips the
+// audit log fails the first time it is used. Reads are exempt.
+func RequireAudit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		trail, r := withAuditTrail(r)
+		next.ServeHTTP(&auditedWriter{ResponseWriter: w, trail: trail, request: r}, r)
+	})
+}
+
+// withAuditTrail returns the audit trail of r, adding one if it has none
+func withAuditTrail(r *http.Request) (*auditTrail, *http.Request) {
+	if trail, ok := r.Context().Value(auditTrailKey{}).(*auditTrail); ok {
+		return trail, r
+	}
+	trail := &auditTrail{}
+	return trail, r.WithContext(context.WithValue(r.Context(), auditTrailKey{}, trail))
+}
+
+// MarkAudited notes that the request's action has been written to the
+// audit log as entry, which the access log adds the request to
+func MarkAudited(r *http.Request, entry *models.AuditLog) {
+	if trail, ok := r.Context().Value(auditTrailKey{}).(*auditTrail); ok {
+		trail.recorded = true
+		trail.entry = entry
+	}
+}
+
+// auditedWriter holds back a successful response to a request whose
+// action was never audited
+type auditedWriter struct {
+	http.ResponseWriter
+	trail       *auditTrail
+	request     *http.Request
+	wroteHeader bool
+	refused     bool
+}
+
+func (w *auditedWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	if code >= 200 && code < 300 && !w.trail.recorded {
+		logrus.WithFields(logrus.Fields{
+			"method": w.request.Method,
+			"route":  routeTemplate(w.request),
+		}).Error("Admin action was not written to the audit log")
+
+		w.refused = true
+		http.Error(w.ResponseWriter, "Action was not audited", http.StatusInternalServerError)
+		return
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *auditedWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.refused {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
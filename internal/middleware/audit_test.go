@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// adminAction answers with status, marking the request audited if audit is set
+func adminAction(status int, audit bool) http.Handler {
+	return RequireAudit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if audit {
+			MarkAudited(r, nil)
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(`{"success":true}`))
+	}))
+}
+
+func TestRequireAudit(t *testing.T) {
+	serve := func(handler http.Handler, method string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(method, "/api/v1/admin/games/g1/close", nil))
+		return rr
+	}
+
+	rr := serve(adminAction(http.StatusOK, true), http.MethodPost)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"success":true}`, rr.Body.String())
+
+	// A change reported as a success without an entry is refused
+	rr = serve(adminAction(http.StatusOK, false), http.MethodPost)
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "success")
+
+	// Failures have nothing to audit, and reads are exempt
+	assert.Equal(t, http.StatusNotFound, serve(adminAction(http.StatusNotFound, false), http.MethodPost).Code)
+	assert.Equal(t, http.StatusOK, serve(adminAction(http.StatusOK, false), http.MethodGet).Code)
+}
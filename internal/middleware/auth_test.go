@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/internal/auth"
+	"github.com/primoPoker/server/internal/config"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/repository"
+	"github.com/primoPoker/server/internal/testutil"
+)
+
+// signIn creates a user with the given role and returns it with an access token
+func signIn(t *testing.T, service *auth.Service, username string, role models.Role) (*models.User, string) {
+	t.Helper()
+
+	user, err := service.CreateUser(username, "correct-horse-42", username+"@example.com")
+	require.NoError(t, err)
+	require.NoError(t, service.SetRole(user.ID, role))
+
+	tokens, err := service.StartSession(user, "127.0.0.1", "test")
+	require.NoError(t, err)
+	return user, tokens.AccessToken
+}
+
+// newAuthService returns an auth service backed by a fresh database
+func newAuthService(t *testing.T) *auth.Service {
+	t.Helper()
+
+	db := testutil.NewDB(t, &models.User{}, &models.Session{}, &models.RotatedRefreshToken{}, &models.LoginEvent{}, &models.APIKey{})
+	return auth.NewService("test-secret", config.SecurityConfig{JWTExpirationHours: 1},
+		repository.NewUserRepository(db), repository.NewSessionRepository(db), repository.NewLoginEventRepository(db), repository.NewAPIKeyRepository(db))
+}
+
+func TestJWTAuthAcceptsServiceTokens(t *testing.T) {
+	service := newAuthService(t)
+	user, token := signIn(t, service, "alice", models.RolePlayer)
+
+	var userID, sessionID string
+	handler := JWTAuthMiddleware(service)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ = r.Context().Value("user_id").(string)
+		sessionID, _ = r.Context().Value("session_id").(string)
+	}))
+
+	req := httptest.NewRequest("GET", "/api/v1/games", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, user.ID.String(), userID)
+	assert.NotEmpty(t, sessionID)
+
+	// A token signed with any other secret is turned away
+	other := auth.NewService("other-secret", config.SecurityConfig{}, nil, nil, nil, nil)
+	forged, err := other.GenerateToken(user, uuid.New())
+	require.NoError(t, err)
+
+	req = httptest.NewRequest("GET", "/api/v1/games", nil)
+	req.Header.Set("Authorization", "Bearer "+forged)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestRequireRoleAdminRoute(t *testing.T) {
+	service := newAuthService(t)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := JWTAuthMiddleware(service)(RequireRole(service, models.RoleAdmin)(ok))
+
+	get := func(token string) int {
+		req := httptest.NewRequest("GET", "/api/v1/admin/stats", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	_, playerToken := signIn(t, service, "player", models.RolePlayer)
+	_, moderatorToken := signIn(t, service, "moderator", models.RoleModerator)
+	admin, adminToken := signIn(t, service, "admin", models.RoleAdmin)
+
+	assert.Equal(t, http.StatusForbidden, get(playerToken))
+	assert.Equal(t, http.StatusForbidden, get(moderatorToken))
+	assert.Equal(t, http.StatusOK, get(adminToken))
+
+	// Demotion applies to tokens that were issued before it
+	require.NoError(t, service.SetRole(admin.ID, models.RolePlayer))
+	assert.Equal(t, http.StatusForbidden, get(adminToken))
+}
+
+func TestRoleIncludes(t *testing.T) {
+	assert.True(t, models.RoleAdmin.Includes(models.RoleModerator))
+	assert.True(t, models.RoleModerator.Includes(models.RolePlayer))
+	assert.False(t, models.RoleModerator.Includes(models.RoleAdmin))
+	assert.False(t, models.RolePlayer.Includes(models.RoleModerator))
+	assert.True(t, models.Role("").Includes(models.RolePlayer))
+	assert.False(t, models.Role("root").IsValid())
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+)
+
+// BodyLimits are the largest request bodies, in bytes, accepted by the
+// routes signing in and managing accounts, by the game routes, by avatar
+// uploads, and by everything else. A limit left at zero takes the default
+// one.
+type BodyLimits struct {
+	Auth    int64
+	Game    int64
+	Avatar  int64
+	Default int64
+}
+
+// DefaultBodyLimit caps request bodies on routes with no limit of their own
+const DefaultBodyLimit = 64 << 10
+
+// limit returns the body limit of a request
+func (l BodyLimits) limit(r *http.Request) int64 {
+	var limit int64
+	switch ClassifyRoute(r) {
+	case RouteClassAuth:
+		limit = l.Auth
+	case RouteClassGame:
+		limit = l.Game
+	}
+	// The public sign-in routes are in the default class for rate limits
+	if strings.HasPrefix(r.URL.Path, "/api/v1/auth/") {
+		limit = l.Auth
+	}
+	if r.URL.Path == "/api/v1/users/me/avatar" {
+		limit = l.Avatar
+	}
+	if limit <= 0 {
+		limit = l.Default
+	}
+	if limit <= 0 {
+		limit = DefaultBodyLimit
+	}
+	return limit
+}
+
+// LimitBody caps the body of each request at the limit of its route
+// class. Reading past the limit fails with an *http.MaxBytesError, which
+// handlers answer with 413 and the server follows by closing the
+// connection rather than reading the rest.
+func LimitBody(limits BodyLimits) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, limits.limit(r))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBodyLimitsByRouteClass(t *testing.T) {
+	limits := BodyLimits{Auth: 4096, Game: 8192, Avatar: 1 << 20, Default: 65536}
+
+	for path, limit := range map[string]int64{
+		"/api/v1/auth/login":        4096,
+		"/api/v1/users/me/password": 4096,
+		"/api/v1/games/1/join":      8192,
+		"/api/v1/users/me/avatar":   1 << 20,
+		"/api/v1/clubs":             65536,
+	} {
+		assert.Equal(t, limit, limits.limit(httptest.NewRequest("POST", path, nil)), path)
+	}
+
+	assert.Equal(t, int64(65536), BodyLimits{Default: 65536}.limit(httptest.NewRequest("POST", "/api/v1/games", nil)), "unset classes take the default")
+	assert.Equal(t, int64(DefaultBodyLimit), BodyLimits{}.limit(httptest.NewRequest("POST", "/api/v1/clubs", nil)))
+}
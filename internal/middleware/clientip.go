@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+)
+
+// trustedProxies are the networks of the proxies in front of the server,
+// whose forwarding headers ClientIP believes
+var trustedProxies atomic.Pointer[[]netip.Prefix]
+
+// SetTrustedProxies sets the proxies whose X-Forwarded-For and X-Real-IP
+// headers ClientIP believes, each an IP address or a CIDR range. With none
+// the headers are ignored, and a request comes from the address that
+// connected.
+func SetTrustedProxies(proxies []string) error {
+	prefixes := make([]netip.Prefix, 0, len(proxies))
+	for _, proxy := range proxies {
+		prefix, err := parseTrustedProxy(proxy)
+		if err != nil {
+			return err
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	trustedProxies.Store(&prefixes)
+	return nil
+}
+
+// parseTrustedProxy parses a trusted proxy, given as an IP address or a
+// CIDR range
+func parseTrustedProxy(proxy string) (netip.Prefix, error) {
+	proxy = strings.TrimSpace(proxy)
+	if strings.Contains(proxy, "/") {
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(proxy)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+	}
+	return netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()), nil
+}
+
+// isTrustedProxy checks if addr is one of the trusted proxies
+func isTrustedProxy(addr netip.Addr) bool {
+	prefixes := trustedProxies.Load()
+	if prefixes == nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range *prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address of the client making a request. Forwarding
+// headers are only believed when a trusted proxy connected: X-Forwarded-For
+// is read from the right, past the trusted proxies that added to it, and
+// the first address that is not one of them is the client's. Anything
+// further left was sent by the client and could be made up.
+func ClientIP(r *http.Request) string {
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	client, err := netip.ParseAddr(remote)
+	if err != nil || !isTrustedProxy(client) {
+		return remote
+	}
+
+	hops := forwardedFor(r)
+	if len(hops) == 0 {
+		if realIP, err := parseHop(r.Header.Get("X-Real-IP")); err == nil {
+			return realIP.String()
+		}
+		return client.Unmap().String()
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := parseHop(hops[i])
+		if err != nil {
+			// Nothing from here on can be believed, so the client is the
+			// last address a trusted proxy vouched for
+			break
+		}
+		client = hop
+		if !isTrustedProxy(hop) {
+			break
+		}
+	}
+	return client.Unmap().String()
+}
+
+// forwardedFor returns the addresses in a request's X-Forwarded-For
+// headers, the client first and the proxy nearest the server last
+func forwardedFor(r *http.Request) []string {
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	return hops
+}
+
+// parseHop parses an address in a forwarding header, which some proxies
+// give with a port
+func parseHop(hop string) (netip.Addr, error) {
+	hop = strings.TrimSpace(hop)
+	if addrPort, err := netip.ParseAddrPort(hop); err == nil {
+		return addrPort.Addr(), nil
+	}
+	return netip.ParseAddr(hop)
+}
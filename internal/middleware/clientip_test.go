@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientIP(t *testing.T) {
+	require.NoError(t, SetTrustedProxies([]string{"10.0.0.0/8", "192.0.2.7"}))
+	t.Cleanup(func() { require.NoError(t, SetTrustedProxies(nil)) })
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		realIP     string
+		want       string
+	}{
+		{name: "direct", remoteAddr: "203.0.113.5:4321", want: "203.0.113.5"},
+		{name: "direct over IPv6", remoteAddr: "[2001:db8::5]:4321", want: "2001:db8::5"},
+		{
+			name:       "headers from a client that is not a proxy are ignored",
+			remoteAddr: "203.0.113.5:4321",
+			forwarded:  []string{"198.51.100.1"},
+			realIP:     "198.51.100.2",
+			want:       "203.0.113.5",
+		},
+		{
+			name:       "through a trusted proxy",
+			remoteAddr: "10.0.0.2:80",
+			forwarded:  []string{"203.0.113.5"},
+			want:       "203.0.113.5",
+		},
+		{
+			name:       "an address the client made up is passed over",
+			remoteAddr: "10.0.0.2:80",
+			forwarded:  []string{"198.51.100.1, 203.0.113.5"},
+			want:       "203.0.113.5",
+		},
+		{
+			name:       "through a chain of trusted proxies",
+			remoteAddr: "10.0.0.2:80",
+			forwarded:  []string{"198.51.100.1, 203.0.113.5", "192.0.2.7, 10.1.2.3"},
+			want:       "203.0.113.5",
+		},
+		{
+			name:       "an address that is not one stops the walk",
+			remoteAddr: "10.0.0.2:80",
+			forwarded:  []string{"203.0.113.5, not-an-ip, 10.1.2.3"},
+			want:       "10.1.2.3",
+		},
+		{
+			name:       "only trusted proxies forwarded it",
+			remoteAddr: "10.0.0.2:80",
+			forwarded:  []string{"10.9.9.9:5555, 10.1.2.3"},
+			want:       "10.9.9.9",
+		},
+		{
+			name:       "X-Real-IP from a trusted proxy",
+			remoteAddr: "192.0.2.7:80",
+			realIP:     "203.0.113.5",
+			want:       "203.0.113.5",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, forwarded := range tt.forwarded {
+				req.Header.Add("X-Forwarded-For", forwarded)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			assert.Equal(t, tt.want, ClientIP(req))
+		})
+	}
+}
+
+func TestClientIPTrustsNoProxyByDefault(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.2:80"
+	req.Header.Set("X-Forwarded-For", "203.0.113.5")
+	assert.Equal(t, "10.0.0.2", ClientIP(req))
+
+	assert.Error(t, SetTrustedProxies([]string{"10.0.0.0/8", "proxy.internal"}))
+}
@@ -0,0 +1,222 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultCompressionMinSize is the smallest response Compress gzips when
+// no size is given; below it the gzip header and CPU cost outweigh the
+// bytes saved
+const DefaultCompressionMinSize = 1024
+
+// incompressibleTypes are content types already compressed, which gzip
+// would only make larger
+var incompressibleTypes = []string{
+	"image/", "video/", "audio/", "font/woff",
+	"application/gzip", "application/x-gzip", "application/zip",
+	"application/pdf", "application/octet-stream",
+}
+
+var gzipWriters = sync.Pool{
+	New: func() any { return gzip.NewWriter(nil) },
+}
+
+// Compress gzips responses of at least minSize bytes for clients that
+// accept gzip. Responses already encoded or of an already compressed
+// type are sent as they are. A handler that flushes is streaming: what it
+// wrote before its first flush decides whether the rest is compressed,
+// whatever its size, and each flush pushes what has been compressed so
+// far to the client. WebSocket upgrades pass straight through.
+func Compress(minSize int) func(http.Handler) http.Handler {
+	if minSize <= 0 {
+		minSize = DefaultCompressionMinSize
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Upgrade") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// The response depends on Accept-Encoding whether or not this
+			// client gets it compressed, so caches must key on it
+			w.Header().Add("Vary", "Accept-Encoding")
+			if r.Method == http.MethodHead || !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, minSize: minSize, status: http.StatusOK}
+			defer cw.close()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.TrimSpace(coding)
+		if !strings.EqualFold(coding, "gzip") && coding != "*" {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.ReplaceAll(params, " ", ""), "q="); ok {
+			if weight, err := strconv.ParseFloat(q, 64); err == nil && weight == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// compressWriter holds a response back until it has seen enough of it to
+// decide whether to compress, then either gzips the rest or passes it on
+type compressWriter struct {
+	http.ResponseWriter
+	minSize int
+
+	status      int
+	wroteHeader bool   // WriteHeader was called by the handler
+	decided     bool   // Headers were sent and gz chosen or not
+	buf         []byte // Body held back until the decision
+	gz          *gzip.Writer
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	cw.status = code
+	// Informational and bodiless responses are sent as they are
+	if code < http.StatusOK || code == http.StatusNoContent || code == http.StatusNotModified {
+		cw.decide(false)
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.decided {
+		cw.buf = append(cw.buf, p...)
+		if len(cw.buf) < cw.minSize {
+			return len(p), nil
+		}
+		held := cw.buf
+		cw.buf = nil
+		if err := cw.start(held, cw.compressible(held)); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if cw.gz != nil {
+		return cw.gz.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// Flush sends what has been written so far, compressing a response that
+// is being streamed
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		held := cw.buf
+		cw.buf = nil
+		if err := cw.start(held, len(held) > 0 && cw.compressible(held)); err != nil {
+			return
+		}
+	}
+	if cw.gz != nil {
+		cw.gz.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack hands the connection over to a handler taking it for itself
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := cw.ResponseWriter.(http.Hijacker)
+	if !ok || cw.decided {
+		return nil, nil, fmt.Errorf("response cannot be hijacked")
+	}
+	cw.decided = true
+	return hijacker.Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// compressible reports whether the response is worth compressing, judging
+// by its headers and, without a Content-Type, by its first bytes
+func (cw *compressWriter) compressible(body []byte) bool {
+	header := cw.Header()
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+	contentType = strings.ToLower(contentType)
+	for _, prefix := range incompressibleTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// decide sends the headers without compressing anything held back
+func (cw *compressWriter) decide(compress bool) {
+	held := cw.buf
+	cw.buf = nil
+	cw.start(held, compress)
+}
+
+// start sends the headers, choosing whether to compress, then the body
+// held back so far
+func (cw *compressWriter) start(held []byte, compress bool) error {
+	cw.decided = true
+	if compress {
+		header := cw.Header()
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		header.Del("Accept-Ranges")
+		cw.gz = gzipWriters.Get().(*gzip.Writer)
+		cw.gz.Reset(cw.ResponseWriter)
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+	if len(held) == 0 {
+		return nil
+	}
+	_, err := cw.Write(held)
+	return err
+}
+
+// close sends a response too small to compress, or finishes the gzip
+// stream of one that was compressed
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		if !cw.wroteHeader && len(cw.buf) == 0 {
+			// Nothing was written; leave the response to net/http
+			return
+		}
+		cw.decide(false)
+		return
+	}
+	if cw.gz != nil {
+		cw.gz.Close()
+		cw.gz.Reset(nil)
+		gzipWriters.Put(cw.gz)
+		cw.gz = nil
+	}
+}
@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/internal/game"
+)
+
+// compressed serves body as contentType through Compress and returns the
+// response to a client sending acceptEncoding
+func compressed(t testing.TB, contentType, body, acceptEncoding string) *httptest.ResponseRecorder {
+	handler := Compress(100)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+		io.WriteString(w, body)
+	}))
+	req := httptest.NewRequest("GET", "/api/v1/games", nil)
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+// gunzip decompresses a response body
+func gunzip(t testing.TB, body io.Reader) string {
+	reader, err := gzip.NewReader(body)
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestCompressGzipsLargeResponses(t *testing.T) {
+	body := `{"games":[` + strings.Repeat(`{"name":"Table","small_blind":50},`, 20) + `{}]}`
+
+	rr := compressed(t, "application/json", body, "br, gzip")
+	assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", rr.Header().Get("Vary"))
+	assert.Empty(t, rr.Header().Get("Content-Length"), "the handler's length is of the uncompressed body")
+	assert.Less(t, rr.Body.Len(), len(body))
+	assert.Equal(t, body, gunzip(t, rr.Body))
+}
+
+func TestCompressLeavesResponsesAsTheyAre(t *testing.T) {
+	large := strings.Repeat("x", 500)
+	for name, tc := range map[string]struct {
+		contentType, body, acceptEncoding string
+	}{
+		"not accepted":        {"application/json", large, ""},
+		"refused":             {"application/json", large, "gzip;q=0, identity"},
+		"below the threshold": {"application/json", `{"ok":true}`, "gzip"},
+		"already compressed":  {"image/png", large, "gzip"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			rr := compressed(t, tc.contentType, tc.body, tc.acceptEncoding)
+			assert.Empty(t, rr.Header().Get("Content-Encoding"))
+			assert.Equal(t, "Accept-Encoding", rr.Header().Get("Vary"))
+			assert.Equal(t, fmt.Sprint(len(tc.body)), rr.Header().Get("Content-Length"))
+			assert.Equal(t, tc.body, rr.Body.String())
+		})
+	}
+}
+
+func TestCompressStreamsOnFlush(t *testing.T) {
+	flushed := make(chan struct{})
+	handler := Compress(1 << 20)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"hand_histories":[`)
+		w.(http.Flusher).Flush()
+		<-flushed
+		io.WriteString(w, `]}`)
+	}))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	req, err := http.NewRequest("GET", server.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	// The first chunk arrives before the handler finishes, compressed even
+	// though it is far below the threshold
+	assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+	reader, err := gzip.NewReader(resp.Body)
+	require.NoError(t, err)
+	first := make([]byte, len(`{"hand_histories":[`))
+	_, err = io.ReadFull(reader, first)
+	require.NoError(t, err)
+	assert.Equal(t, `{"hand_histories":[`, string(first))
+
+	close(flushed)
+	rest, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, `]}`, string(rest))
+}
+
+func TestCompressPassesWebSocketUpgradesThrough(t *testing.T) {
+	var hijackable bool
+	handler := Compress(0)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, hijackable = w.(http.Hijacker)
+		w.WriteHeader(http.StatusSwitchingProtocols)
+	}))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	req, err := http.NewRequest("GET", server.URL+"/ws", nil)
+	require.NoError(t, err)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.True(t, hijackable, "the upgrade reaches the server's own writer")
+	assert.Empty(t, resp.Header.Get("Vary"))
+	assert.Empty(t, resp.Header.Get("Content-Encoding"))
+}
+
+// BenchmarkCompressListGames measures the CPU gzip costs on a lobby of 500
+// tables, against serving it uncompressed
+func BenchmarkCompressListGames(b *testing.B) {
+	manager := game.NewManager()
+	for i := 0; i < 500; i++ {
+		_, err := manager.CreateGame(fmt.Sprintf("table-%d", i), fmt.Sprintf("Table %d", i))
+		require.NoError(b, err)
+	}
+	body, err := json.Marshal(manager.ListGames())
+	require.NoError(b, err)
+
+	list := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	})
+	for name, handler := range map[string]http.Handler{
+		"plain": list,
+		"gzip":  Compress(DefaultCompressionMinSize)(list),
+	} {
+		b.Run(name, func(b *testing.B) {
+			req := httptest.NewRequest("GET", "/api/v1/games", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			b.SetBytes(int64(len(body)))
+			b.ReportAllocs()
+
+			var sent int
+			for i := 0; i < b.N; i++ {
+				rr := httptest.NewRecorder()
+				handler.ServeHTTP(rr, req)
+				sent = rr.Body.Len()
+			}
+			b.ReportMetric(float64(sent), "bytes/response")
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/primoPoker/server/internal/cache"
+	"github.com/primoPoker/server/internal/logging"
+)
+
+// IdempotencyKeyHeader names a client-chosen key that makes retrying a
+// request safe: the first request with the key runs, and repeats of it get
+// the first one's response
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// Idempotency keys are remembered for a day and may be up to 255 bytes
+const (
+	idempotencyTTL          = 24 * time.Hour
+	maxIdempotencyKeyLength = 255
+	idempotencyTimeout      = 250 * time.Millisecond
+)
+
+// idempotencyPending marks a key whose first request is still running
+const idempotencyPending = "pending"
+
+// storedResponse is the response replayed for a repeated idempotency key
+type storedResponse struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+}
+
+// Idempotency replays the response to a request carrying an
+// Idempotency-Key the same user has sent before, instead of running it
+// again. Keys are kept in store, so with a shared store a retry that lands
+// on another instance is caught too. It must run after JWTAuthMiddleware;
+// requests without a key, safe methods, and requests while the store is
+// unreachable pass straight through. A request that fails with a server
+// error releases its key so it can be retried.
+func Idempotency(store cache.Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			userID, _ := r.Context().Value("user_id").(string)
+			if key == "" || userID == "" || isSafeMethod(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				http.Error(w, "Idempotency-Key is too long", http.StatusBadRequest)
+				return
+			}
+
+			name := "idempotency:" + userID + ":" + r.Method + ":" + r.URL.Path + ":" + key
+			ctx, cancel := context.WithTimeout(context.Background(), idempotencyTimeout)
+			claimed, err := store.SetNX(ctx, name, idempotencyPending, idempotencyTTL)
+			var previous string
+			if err == nil && !claimed {
+				previous, err = store.Get(ctx, name)
+			}
+			cancel()
+			if errors.Is(err, cache.ErrMiss) {
+				// The first request failed and released the key just now
+				claimed, err = true, nil
+			}
+			if err != nil {
+				logging.FromContext(r.Context()).WithError(err).Warn("Idempotency keys unavailable, running request unchecked")
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !claimed {
+				replayResponse(w, previous)
+				return
+			}
+
+			recorder := &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(recorder, r)
+			recorder.save(store, name)
+		})
+	}
+}
+
+// replayResponse writes a stored response, or a conflict while the first
+// request is still running
+func replayResponse(w http.ResponseWriter, previous string) {
+	var response storedResponse
+	if previous == idempotencyPending || json.Unmarshal([]byte(previous), &response) != nil {
+		http.Error(w, "A request with this Idempotency-Key is in progress", http.StatusConflict)
+		return
+	}
+
+	if response.ContentType != "" {
+		w.Header().Set("Content-Type", response.ContentType)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(response.Status)
+	w.Write(response.Body)
+}
+
+// isSafeMethod reports whether a method does not change anything, so
+// repeating it needs no protection
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// recordingWriter passes a response through while keeping a copy of it
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// save stores the recorded response under the idempotency key, or
+// releases the key if the request failed on the server's side
+func (w *recordingWriter) save(store cache.Store, name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), idempotencyTimeout)
+	defer cancel()
+
+	if w.status >= http.StatusInternalServerError {
+		if err := store.Delete(ctx, name); err != nil {
+			logrus.WithError(err).Warn("Failed to release idempotency key")
+		}
+		return
+	}
+
+	data, err := json.Marshal(storedResponse{
+		Status:      w.status,
+		ContentType: w.Header().Get("Content-Type"),
+		Body:        w.body.Bytes(),
+	})
+	if err == nil {
+		err = store.Set(ctx, name, string(data), idempotencyTTL)
+	}
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to store idempotent response")
+	}
+}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/primoPoker/server/internal/cache"
+)
+
+func TestIdempotency(t *testing.T) {
+	runs := 0
+	status := http.StatusCreated
+	handler := Idempotency(cache.NewMemory())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		runs++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(`{"run":` + strconv.Itoa(runs) + `}`))
+	}))
+	call := func(method, userID, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/v1/games", nil)
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		req = req.WithContext(context.WithValue(req.Context(), "user_id", userID))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	first := call("POST", "alice", "create-1")
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Equal(t, `{"run":1}`, first.Body.String())
+
+	// A retry gets the first response without running again
+	retry := call("POST", "alice", "create-1")
+	assert.Equal(t, 1, runs)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, `{"run":1}`, retry.Body.String())
+	assert.Equal(t, "application/json", retry.Header().Get("Content-Type"))
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+
+	// Keys belong to one user, and requests without one always run
+	call("POST", "bob", "create-1")
+	call("POST", "alice", "")
+	call("GET", "alice", "create-1")
+	assert.Equal(t, 4, runs)
+
+	// A server error releases the key for the retry
+	status = http.StatusInternalServerError
+	call("POST", "alice", "create-2")
+	status = http.StatusCreated
+	assert.Equal(t, http.StatusCreated, call("POST", "alice", "create-2").Code)
+	assert.Equal(t, 6, runs)
+
+	assert.Equal(t, http.StatusBadRequest, call("POST", "alice", strings.Repeat("k", 256)).Code)
+}
+
+func TestIdempotencyKeyInProgress(t *testing.T) {
+	store := cache.NewMemory()
+	var retry *httptest.ResponseRecorder
+	var handler http.Handler
+	handler = Idempotency(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if retry == nil {
+			// The client retries before the first request has finished
+			retry = httptest.NewRecorder()
+			handler.ServeHTTP(retry, r)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("POST", "/api/v1/games/1/join", nil)
+	req.Header.Set(IdempotencyKeyHeader, "join-1")
+	req = req.WithContext(context.WithValue(req.Context(), "user_id", "alice"))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, http.StatusConflict, retry.Code)
+}
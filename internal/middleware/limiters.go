@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Rate limiter state is kept per client until the client has been idle for
+// the idle TTL, and no more than the entry cap is kept per kind of client
+const (
+	DefaultRateLimiterIdleTTL    = 10 * time.Minute
+	DefaultRateLimiterMaxEntries = 100000
+)
+
+// limiterSweepInterval is how often idle rate limiters are evicted
+const limiterSweepInterval = time.Minute
+
+var (
+	limiterIdleTTL    atomic.Int64
+	limiterMaxEntries atomic.Int64
+
+	// limiterSets are every set of rate limiters, swept together
+	limiterSets   []*limiterSet
+	limiterSetsMu sync.Mutex
+)
+
+func init() {
+	SetRateLimiterRetention(0, 0)
+
+	go func() {
+		ticker := time.NewTicker(limiterSweepInterval)
+		defer ticker.Stop()
+
+		for now := range ticker.C {
+			sweepLimiters(now)
+		}
+	}()
+}
+
+// SetRateLimiterRetention sets how long a client's rate limiter is kept
+// after its last request and how many are kept per kind of client, the
+// least recently used going first once there are more. Zero keeps the
+// default.
+func SetRateLimiterRetention(idleTTL time.Duration, maxEntries int) {
+	if idleTTL <= 0 {
+		idleTTL = DefaultRateLimiterIdleTTL
+	}
+	if maxEntries <= 0 {
+		maxEntries = DefaultRateLimiterMaxEntries
+	}
+	limiterIdleTTL.Store(int64(idleTTL))
+	limiterMaxEntries.Store(int64(maxEntries))
+}
+
+// LimiterStats counts the rate limiters held in memory
+type LimiterStats struct {
+	Entries int    // Clients with a rate limiter
+	Evicted uint64 // Rate limiters dropped for being idle or over the cap
+}
+
+// RateLimiterStats returns the rate limiters held across IPs, API keys and
+// users
+func RateLimiterStats() LimiterStats {
+	limiterSetsMu.Lock()
+	defer limiterSetsMu.Unlock()
+
+	var stats LimiterStats
+	for _, set := range limiterSets {
+		entries, evicted := set.stats()
+		stats.Entries += entries
+		stats.Evicted += evicted
+	}
+	return stats
+}
+
+// sweepLimiters evicts the rate limiters idle for longer than the idle TTL
+func sweepLimiters(now time.Time) {
+	cutoff := now.Add(-time.Duration(limiterIdleTTL.Load()))
+
+	limiterSetsMu.Lock()
+	sets := limiterSets
+	limiterSetsMu.Unlock()
+
+	for _, set := range sets {
+		set.evictIdle(cutoff)
+	}
+}
+
+// limiterSet holds a token bucket per client key in least recently used
+// order, so idle clients and those over the cap are evicted from the back
+type limiterSet struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // of *limiterEntry, most recently used first
+	evicted uint64
+}
+
+// limiterEntry is a client's token bucket and when it was last used
+type limiterEntry struct {
+	key        string
+	limiter    *rate.Limiter
+	lastAccess time.Time
+}
+
+// newLimiterSet creates a set of rate limiters swept with all the others
+func newLimiterSet() *limiterSet {
+	set := &limiterSet{entries: make(map[string]*list.Element), order: list.New()}
+
+	limiterSetsMu.Lock()
+	limiterSets = append(limiterSets, set)
+	limiterSetsMu.Unlock()
+	return set
+}
+
+// get returns the rate limiter of key, created or moved to limit and burst
+// as needed, and marks it used at now
+func (s *limiterSet) get(key string, limit rate.Limit, burst int, now time.Time) *rate.Limiter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if element, ok := s.entries[key]; ok {
+		entry := element.Value.(*limiterEntry)
+		entry.lastAccess = now
+		s.order.MoveToFront(element)
+		if entry.limiter.Limit() != limit || entry.limiter.Burst() != burst {
+			// The limit was changed since this client was first seen
+			entry.limiter.SetLimit(limit)
+			entry.limiter.SetBurst(burst)
+		}
+		return entry.limiter
+	}
+
+	entry := &limiterEntry{key: key, limiter: rate.NewLimiter(limit, burst), lastAccess: now}
+	s.entries[key] = s.order.PushFront(entry)
+	for maxEntries := int(limiterMaxEntries.Load()); s.order.Len() > maxEntries; {
+		s.remove(s.order.Back())
+	}
+	return entry.limiter
+}
+
+// evictIdle drops the rate limiters last used before cutoff. A client
+// evicted early starts again with a full bucket, which is no more than a
+// new client gets.
+func (s *limiterSet) evictIdle(cutoff time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for back := s.order.Back(); back != nil && back.Value.(*limiterEntry).lastAccess.Before(cutoff); back = s.order.Back() {
+		s.remove(back)
+	}
+}
+
+// remove drops an entry; s.mu must be held
+func (s *limiterSet) remove(element *list.Element) {
+	delete(s.entries, s.order.Remove(element).(*limiterEntry).key)
+	s.evicted++
+}
+
+// stats returns how many rate limiters are held and have been evicted
+func (s *limiterSet) stats() (int, uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len(), s.evicted
+}
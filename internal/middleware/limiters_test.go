@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestLimiterSetEvictsIdleEntries(t *testing.T) {
+	set := newLimiterSet()
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	limit := rate.Every(time.Second)
+
+	exhausted := set.get("198.51.100.1", limit, 1, start)
+	require.True(t, exhausted.Allow())
+	set.get("198.51.100.2", limit, 1, start)
+	set.get("198.51.100.1", limit, 1, start.Add(8*time.Minute))
+
+	set.evictIdle(start.Add(5 * time.Minute))
+	entries, evicted := set.stats()
+	assert.Equal(t, 1, entries, "only the client seen since the cutoff is kept")
+	assert.Equal(t, uint64(1), evicted)
+
+	// The client still active keeps its spent budget
+	assert.Same(t, exhausted, set.get("198.51.100.1", limit, 1, start.Add(9*time.Minute)))
+	assert.False(t, exhausted.Allow())
+}
+
+func TestLimiterSetEvictsLeastRecentlyUsedOverCap(t *testing.T) {
+	SetRateLimiterRetention(0, 2)
+	defer SetRateLimiterRetention(0, 0)
+
+	set := newLimiterSet()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	limit := rate.Every(time.Second)
+
+	first := set.get("a", limit, 1, now)
+	set.get("b", limit, 1, now.Add(time.Second))
+	set.get("a", limit, 1, now.Add(2*time.Second))
+	set.get("c", limit, 1, now.Add(3*time.Second))
+
+	entries, evicted := set.stats()
+	assert.Equal(t, 2, entries)
+	assert.Equal(t, uint64(1), evicted)
+	assert.Same(t, first, set.get("a", limit, 1, now.Add(4*time.Second)), "a was used after b, so b went first")
+}
+
+func TestRateLimitBurstIsConfigurable(t *testing.T) {
+	SetRateLimit(60, 3)
+	defer SetRateLimit(DefaultRateLimitPerMinute, 0)
+
+	handler := RateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for i := 0; i < 3; i++ {
+		require.Equal(t, http.StatusOK, call(handler, "/api/v1/auth/login", "203.0.113.90", "").Code, "request %d", i)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, call(handler, "/api/v1/auth/login", "203.0.113.90", "").Code)
+}
+
+func TestRateLimitersConcurrentAccess(t *testing.T) {
+	SetRateLimiterRetention(0, 50)
+	defer SetRateLimiterRetention(0, 0)
+
+	handler := RateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	users := NewMemoryRateLimiter()
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				ip := fmt.Sprintf("192.0.2.%d", (worker*200+i)%100)
+				req := httptest.NewRequest("GET", "/api/v1/auth/login", nil)
+				req.RemoteAddr = ip + ":1234"
+				handler.ServeHTTP(httptest.NewRecorder(), req)
+				users.Allow(fmt.Sprintf("user:%d:default", i%75), 60)
+				if i%50 == 0 {
+					sweepLimiters(time.Now().Add(time.Hour))
+					RateLimiterStats()
+				}
+			}
+		}(worker)
+	}
+	wg.Wait()
+
+	entries, _ := users.limiters.stats()
+	assert.LessOrEqual(t, entries, 50)
+	entries, _ = ipLimiters.stats()
+	assert.LessOrEqual(t, entries, 50)
+}
@@ -3,29 +3,87 @@ package middleware
 import (
 	"context"
 	"net/http"
+	"strconv"
 	"strings"
-	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/time/rate"
 
 	"github.com/primoPoker/server/internal/auth"
+	"github.com/primoPoker/server/internal/logging"
+	"github.com/primoPoker/server/internal/models"
 )
 
-// CORS middleware
-func CORS(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Accept, Authorization, Content-Type, X-CSRF-Token")
+// CORSConfig controls which browser origins may call the API
+type CORSConfig struct {
+	// AllowedOrigins lists the exact origins (scheme://host[:port]) allowed
+	AllowedOrigins []string
+	// AllowAnyOrigin echoes back whatever origin asks; only for development
+	AllowAnyOrigin bool
+	// MaxAge is how long browsers may cache a preflight response
+	MaxAge time.Duration
+}
 
-		if r.Method == "OPTIONS" {
-			return
-		}
+// CORS middleware. Only allowed origins are echoed back, never "*", since
+// requests carry credentials. Preflight requests are answered here with 204
+// (or 403 for an unknown origin) and never reach the wrapped handler.
+func CORS(cfg CORSConfig) func(http.Handler) http.Handler {
+	return LiveCORS(func() CORSConfig { return cfg })
+}
 
-		next.ServeHTTP(w, r)
-	})
+// LiveCORS is CORS with its configuration read from current on every
+// request, so allowed origins can be changed while the server runs
+func LiveCORS(current func() CORSConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cfg := current()
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Origin")
+			if !cfg.AllowAnyOrigin && !originAllowed(cfg.AllowedOrigins, origin) {
+				if preflight {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+			w.Header().Set("Access-Control-Expose-Headers", logging.Header)
+
+			if preflight {
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Headers", "Accept, Authorization, Content-Type, X-CSRF-Token, "+logging.Header)
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge.Seconds())))
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// originAllowed reports whether origin is one of the allowed origins
+func originAllowed(allowed []string, origin string) bool {
+	for _, candidate := range allowed {
+		if strings.TrimSuffix(candidate, "/") == origin {
+			return true
+		}
+	}
+	return false
 }
 
 // Logging middleware
@@ -38,13 +96,13 @@ func Logging(next http.Handler) http.Handler {
 
 		next.ServeHTTP(wrapped, r)
 
-		logrus.WithFields(logrus.Fields{
+		logging.FromContext(r.Context()).WithFields(logrus.Fields{
 			"method":     r.Method,
 			"url":        r.URL.String(),
 			"status":     wrapped.statusCode,
 			"duration":   time.Since(start),
 			"user_agent": r.UserAgent(),
-			"remote_ip":  getClientIP(r),
+			"remote_ip":  ClientIP(r),
 		}).Info("HTTP request")
 	})
 }
@@ -60,47 +118,56 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
-// getClientIP extracts the client IP from the request
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header first
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		ips := strings.Split(xff, ",")
-		return strings.TrimSpace(ips[0])
-	}
-
-	// Check X-Real-IP header
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return xri
+// Flush lets streaming handlers push chunks through the wrapper
+func (rw *responseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
 	}
-
-	// Fall back to RemoteAddr
-	return strings.Split(r.RemoteAddr, ":")[0]
 }
 
 // Rate limiting middleware
 var (
-	rateLimiters = make(map[string]*rate.Limiter)
-	rateLimiterMu sync.RWMutex
+	ipLimiters = newLimiterSet()
+
+	// rateLimitPerMinute is how many requests each client IP may make a
+	// minute, in bursts of up to rateLimitBurst
+	rateLimitPerMinute atomic.Int64
+	rateLimitBurst     atomic.Int64
 )
 
+// DefaultRateLimitPerMinute is the IP rate limit until SetRateLimit is called
+const DefaultRateLimitPerMinute = 100
+
+func init() {
+	SetRateLimit(DefaultRateLimitPerMinute, 0)
+}
+
+// SetRateLimit sets how many requests a minute each client IP may make
+// and how many at once; a burst of zero is a tenth of the rate. Clients
+// already seen move to the new limit on their next request.
+func SetRateLimit(perMinute, burst int) {
+	if perMinute <= 0 {
+		perMinute = DefaultRateLimitPerMinute
+	}
+	if burst <= 0 {
+		burst = max(perMinute/10, 1)
+	}
+	rateLimitPerMinute.Store(int64(perMinute))
+	rateLimitBurst.Store(int64(burst))
+}
+
+// RateLimit limits each client IP on the routes that need no sign-in;
+// signed-in users are limited by UserRateLimit instead, so players sharing
+// an address are not throttled together
 func RateLimit(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ip := getClientIP(r)
-		
-		rateLimiterMu.RLock()
-		limiter, exists := rateLimiters[ip]
-		rateLimiterMu.RUnlock()
-
-		if !exists {
-			// Create new rate limiter for this IP (100 requests per minute)
-			limiter = rate.NewLimiter(rate.Every(time.Minute/100), 10)
-			
-			rateLimiterMu.Lock()
-			rateLimiters[ip] = limiter
-			rateLimiterMu.Unlock()
+		// Requests authenticated with an API key were limited per key already
+		if _, ok := r.Context().Value("api_key_id").(string); ok {
+			next.ServeHTTP(w, r)
+			return
 		}
 
-		if !limiter.Allow() {
+		if !allowIP(ClientIP(r)) {
 			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
 			return
 		}
@@ -109,6 +176,12 @@ func RateLimit(next http.Handler) http.Handler {
 	})
 }
 
+// ipLimiter returns the rate limiter of a client IP
+func ipLimiter(ip string) *rate.Limiter {
+	limit := rate.Every(time.Minute / time.Duration(rateLimitPerMinute.Load()))
+	return ipLimiters.get(ip, limit, int(rateLimitBurst.Load()), time.Now())
+}
+
 // Security headers middleware
 func SecurityHeaders(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -131,36 +204,44 @@ func SecurityHeaders(next http.Handler) http.Handler {
 	})
 }
 
-// JWTAuthMiddleware creates a JWT authentication middleware with the given auth service
+// JWTAuthMiddleware creates a JWT authentication middleware with the given
+// auth service. Requests already authenticated by APIKeyAuth pass through.
 func JWTAuthMiddleware(authService *auth.Service) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := r.Context().Value("api_key_id").(string); ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
 			// Get token from Authorization header
 			authHeader := r.Header.Get("Authorization")
 			if authHeader == "" {
-				http.Error(w, "Authorization header required", http.StatusUnauthorized)
+				rejectUnauthenticated(w, r, "Authorization header required")
 				return
 			}
 
 			// Extract token from "Bearer <token>"
 			parts := strings.SplitN(authHeader, " ", 2)
 			if len(parts) != 2 || parts[0] != "Bearer" {
-				http.Error(w, "Invalid authorization header format", http.StatusUnauthorized)
+				rejectUnauthenticated(w, r, "Invalid authorization header format")
 				return
 			}
 
 			token := parts[1]
 
-			// Validate token
-			user, err := authService.ValidateToken(token)
+			// Validate token and the session it was issued for
+			user, sessionID, err := authService.ValidateSession(token)
 			if err != nil {
-				http.Error(w, "Invalid token", http.StatusUnauthorized)
+				rejectUnauthenticated(w, r, "Invalid token")
 				return
 			}
 
 			// Add user info to request context
-			ctx := context.WithValue(r.Context(), "user_id", user.ID)
+			ctx := context.WithValue(r.Context(), "user_id", user.ID.String())
 			ctx = context.WithValue(ctx, "username", user.Username)
+			ctx = context.WithValue(ctx, "role", string(user.Role))
+			ctx = context.WithValue(ctx, "session_id", sessionID.String())
 			r = r.WithContext(ctx)
 
 			next.ServeHTTP(w, r)
@@ -168,20 +249,53 @@ func JWTAuthMiddleware(authService *auth.Service) func(http.Handler) http.Handle
 	}
 }
 
-// Cleanup rate limiters periodically to prevent memory leaks
-func init() {
-	go func() {
-		ticker := time.NewTicker(5 * time.Minute)
-		defer ticker.Stop()
-
-		for range ticker.C {
-			rateLimiterMu.Lock()
-			// In a real implementation, you'd track last access times
-			// and remove old entries. For simplicity, we'll clear all every 5 minutes
-			if len(rateLimiters) > 1000 {
-				rateLimiters = make(map[string]*rate.Limiter)
-			}
-			rateLimiterMu.Unlock()
+// rejectUnauthenticated turns away a request JWTAuthMiddleware could not
+// authenticate. Such requests have no user to be limited as, so they count
+// against the client IP's limit instead.
+func rejectUnauthenticated(w http.ResponseWriter, r *http.Request, message string) {
+	if !allowIP(ClientIP(r)) {
+		http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+		return
+	}
+	http.Error(w, message, http.StatusUnauthorized)
+}
+
+// DeletedAccount matches requests whose bearer token was issued to an
+// account that has since been deleted. Deleting an account revokes the
+// session that asked for it, so a retry of that request is routed by this to
+// a handler confirming the deletion rather than refused by JWTAuthMiddleware.
+func DeletedAccount(authService *auth.Service) mux.MatcherFunc {
+	return func(r *http.Request, _ *mux.RouteMatch) bool {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			return false
 		}
-	}()
+		_, deleted := authService.DeletedAccountID(token)
+		return deleted
+	}
+}
+
+// RequireRole restricts a route to users holding role or a higher one; it
+// must run after JWTAuthMiddleware. The role is re-read from the database
+// rather than trusted from the token or cache, so revoking it is immediate.
+func RequireRole(authService *auth.Service, role models.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userIDStr, _ := r.Context().Value("user_id").(string)
+			userID, err := uuid.Parse(userIDStr)
+			if err != nil {
+				http.Error(w, "Authentication required", http.StatusUnauthorized)
+				return
+			}
+
+			current, err := authService.CurrentRole(userID)
+			if err != nil || !current.Includes(role) {
+				http.Error(w, "Insufficient permissions", http.StatusForbidden)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), "role", string(current))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
 }
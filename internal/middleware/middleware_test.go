@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func corsHandler(cfg CORSConfig) (http.Handler, *bool) {
+	called := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusOK)
+	})
+	return CORS(cfg)(next), &called
+}
+
+var testCORSConfig = CORSConfig{
+	AllowedOrigins: []string{"https://play.example.com"},
+	MaxAge:         10 * time.Minute,
+}
+
+func TestCORSAllowedOrigin(t *testing.T) {
+	handler, called := corsHandler(testCORSConfig)
+
+	req := httptest.NewRequest("GET", "/api/v1/games", nil)
+	req.Header.Set("Origin", "https://play.example.com")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.True(t, *called)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "https://play.example.com", rr.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", rr.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "Origin", rr.Header().Get("Vary"))
+}
+
+func TestCORSDisallowedOrigin(t *testing.T) {
+	handler, called := corsHandler(testCORSConfig)
+
+	req := httptest.NewRequest("GET", "/api/v1/games", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.True(t, *called)
+	assert.Empty(t, rr.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, rr.Header().Get("Access-Control-Allow-Credentials"))
+}
+
+func TestCORSPreflight(t *testing.T) {
+	handler, called := corsHandler(testCORSConfig)
+
+	req := httptest.NewRequest("OPTIONS", "/api/v1/games", nil)
+	req.Header.Set("Origin", "https://play.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.False(t, *called)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.Equal(t, "https://play.example.com", rr.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, rr.Header().Get("Access-Control-Allow-Methods"), "POST")
+	assert.Contains(t, rr.Header().Get("Access-Control-Allow-Headers"), "Authorization")
+	assert.Equal(t, "600", rr.Header().Get("Access-Control-Max-Age"))
+}
+
+func TestCORSPreflightDisallowedOrigin(t *testing.T) {
+	handler, called := corsHandler(testCORSConfig)
+
+	req := httptest.NewRequest("OPTIONS", "/api/v1/games", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.False(t, *called)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Empty(t, rr.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORSDevelopmentAllowsAnyOrigin(t *testing.T) {
+	handler, _ := corsHandler(CORSConfig{AllowAnyOrigin: true})
+
+	req := httptest.NewRequest("GET", "/api/v1/games", nil)
+	req.Header.Set("Origin", "http://localhost:5173")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, "http://localhost:5173", rr.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORSWithoutOrigin(t *testing.T) {
+	handler, called := corsHandler(testCORSConfig)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/health", nil))
+
+	assert.True(t, *called)
+	assert.Empty(t, rr.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestRateLimitChangesAtRuntime(t *testing.T) {
+	defer SetRateLimit(DefaultRateLimitPerMinute, 0)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := RateLimit(ok)
+	call := func() int {
+		req := httptest.NewRequest("GET", "/api/v1/games", nil)
+		req.RemoteAddr = "203.0.113.60:1234"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	// 600 a minute allows bursts of 60
+	SetRateLimit(600, 0)
+	for i := 0; i < 20; i++ {
+		require.Equal(t, http.StatusOK, call(), "request %d", i)
+	}
+
+	// At 10 a minute the same client is down to bursts of one at once
+	SetRateLimit(10, 0)
+	assert.Equal(t, http.StatusOK, call())
+	assert.Equal(t, http.StatusTooManyRequests, call())
+}
+
+func TestLiveCORSReadsOriginsPerRequest(t *testing.T) {
+	cfg := testCORSConfig
+	handler := LiveCORS(func() CORSConfig { return cfg })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	allowed := func() string {
+		req := httptest.NewRequest("GET", "/api/v1/games", nil)
+		req.Header.Set("Origin", "https://new.example.com")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Header().Get("Access-Control-Allow-Origin")
+	}
+
+	assert.Empty(t, allowed())
+	cfg.AllowedOrigins = append(cfg.AllowedOrigins, "https://new.example.com/")
+	assert.Equal(t, "https://new.example.com", allowed())
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/primoPoker/server/internal/logging"
+)
+
+// Recover answers a request whose handler panicked with 500 and logs the
+// panic with its stack, the request ID and, when it runs after
+// JWTAuthMiddleware, the user; error reporting picks it up from the log.
+// http.ErrAbortHandler is raised again, as the server expects.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+			logging.Panic(logging.FromContext(r.Context()).WithFields(logrus.Fields{
+				"method": r.Method,
+				"route":  routeTemplate(r),
+			}), recovered)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/internal/logging"
+)
+
+func TestRecoverAnswersPanicsWith500(t *testing.T) {
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+
+	handler := RequestID(Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("dealt from an empty deck")
+	})))
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/games/1/action", nil)
+	req.Header.Set(logging.Header, "fold-17")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	require.Len(t, hook.AllEntries(), 1)
+	entry := hook.LastEntry()
+	assert.Equal(t, logrus.ErrorLevel, entry.Level)
+	assert.Equal(t, "dealt from an empty deck", entry.Data["panic"])
+	assert.Equal(t, "fold-17", entry.Data["request_id"])
+	assert.Contains(t, entry.Data[logging.StackField], "TestRecoverAnswersPanicsWith500")
+}
+
+func TestRecoverLeavesAbortedHandlersToTheServer(t *testing.T) {
+	handler := Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
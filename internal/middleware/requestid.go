@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/primoPoker/server/internal/logging"
+)
+
+// RequestID gives each request an ID, taken from its X-Request-ID header
+// when the client sent a usable one and generated otherwise. The ID is
+// stored in the request context for logging.FromContext and returned in
+// the response's X-Request-ID header, where error responses pick it up.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := logging.NewRequestID(r.Header.Get(logging.Header))
+		w.Header().Set(logging.Header, requestID)
+		next.ServeHTTP(w, r.WithContext(logging.NewContext(r.Context(), requestID)))
+	})
+}
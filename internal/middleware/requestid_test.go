@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/internal/logging"
+)
+
+func TestRequestIDRoundTripsIntoLogs(t *testing.T) {
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+
+	var seen string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = logging.RequestID(r.Context())
+		logging.FromContext(r.Context()).Info("Handling request")
+	})
+	handler := RequestID(Logging(next))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/games/1/action", nil)
+	req.Header.Set(logging.Header, "raise-0932")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, "raise-0932", rr.Header().Get(logging.Header))
+	assert.Equal(t, "raise-0932", seen)
+	require.Len(t, hook.AllEntries(), 2)
+	for _, entry := range hook.AllEntries() {
+		assert.Equal(t, "raise-0932", entry.Data["request_id"], entry.Message)
+	}
+}
+
+func TestRequestIDGeneratedWhenMissingOrUnusable(t *testing.T) {
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, header := range []string{"", "line\nbreak"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/games", nil)
+		req.Header.Set(logging.Header, header)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		_, err := uuid.Parse(rr.Header().Get(logging.Header))
+		assert.NoError(t, err, "a new ID replaces %q", header)
+	}
+}
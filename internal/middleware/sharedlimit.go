@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/primoPoker/server/internal/cache"
+)
+
+// sharedLimitTimeout bounds a shared rate limit check, after which the
+// instance's own limiter decides
+const sharedLimitTimeout = 100 * time.Millisecond
+
+// sharedLimits holds the buckets rate limits are taken from when they
+// are shared between instances
+var sharedLimits atomic.Pointer[sharedLimiter]
+
+// sharedLimiter wraps the buckets so they can be swapped atomically
+type sharedLimiter struct {
+	buckets cache.Buckets
+}
+
+// SetRateLimitBuckets takes the IP and API key rate limits from buckets,
+// so a client is held to one limit however many instances it reaches
+// rather than one per instance. Nil goes back to limiting on each
+// instance.
+func SetRateLimitBuckets(buckets cache.Buckets) {
+	if buckets == nil {
+		sharedLimits.Store(nil)
+		return
+	}
+	sharedLimits.Store(&sharedLimiter{buckets: buckets})
+}
+
+// allowIP reports whether a request from a client IP is within its limit
+func allowIP(ip string) bool {
+	if allowed, ok := allowShared("ip:"+ip, int(rateLimitPerMinute.Load()), int(rateLimitBurst.Load())); ok {
+		return allowed
+	}
+	return ipLimiter(ip).Allow()
+}
+
+// allowShared takes a request from key's shared bucket. ok is false when
+// limits are not shared or the buckets could not be reached, leaving the
+// decision to the in-memory limiter.
+func allowShared(key string, perMinute, burst int) (allowed, ok bool) {
+	shared := sharedLimits.Load()
+	if shared == nil {
+		return false, false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sharedLimitTimeout)
+	defer cancel()
+
+	bucket, err := shared.buckets.Take(ctx, "ratelimit:"+key, perMinute, burst)
+	if err != nil {
+		logrus.WithError(err).Debug("Shared rate limit unavailable, limiting in memory")
+		return false, false
+	}
+	return bucket.Allowed, true
+}
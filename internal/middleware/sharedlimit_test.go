@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/internal/cache"
+)
+
+// redisClient connects a new client to server, as another instance would
+func redisClient(t *testing.T, server *miniredis.Miniredis) *redis.Client {
+	client := redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestSharedRateLimit(t *testing.T) {
+	server := miniredis.RunT(t)
+	clock := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	server.SetTime(clock)
+
+	SetRateLimitBuckets(cache.NewRedisBuckets(redisClient(t, server)))
+	defer SetRateLimitBuckets(nil)
+	SetRateLimit(60, 5)
+	defer SetRateLimit(DefaultRateLimitPerMinute, 0)
+
+	handler := RateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	call := func() int {
+		req := httptest.NewRequest("GET", "/api/v1/games", nil)
+		req.RemoteAddr = "203.0.113.70:1234"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	// The burst is available at once, and taken from a bucket in Redis
+	// where other instances see it
+	for i := 0; i < 5; i++ {
+		require.Equal(t, http.StatusOK, call(), "request %d", i)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, call())
+	assert.True(t, server.Exists("bucket:ratelimit:ip:203.0.113.70"))
+
+	// The bucket refills at the per-minute rate
+	server.SetTime(clock.Add(time.Second))
+	assert.Equal(t, http.StatusOK, call())
+	assert.Equal(t, http.StatusTooManyRequests, call())
+
+	// Without Redis each instance limits on its own again
+	server.Close()
+	assert.Equal(t, http.StatusOK, call())
+}
+
+func TestSharedRateLimiterSharesBudget(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.SetTime(time.Date(2024, 1, 1, 12, 0, 15, 0, time.UTC))
+
+	first := NewSharedRateLimiter(cache.NewRedisBuckets(redisClient(t, server)), NewMemoryRateLimiter())
+	second := NewSharedRateLimiter(cache.NewRedisBuckets(redisClient(t, server)), NewMemoryRateLimiter())
+
+	assert.True(t, first.Allow("user:erin:default", 20).Allowed)
+	result := second.Allow("user:erin:default", 20)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+	assert.Equal(t, 20, result.Limit)
+	assert.Equal(t, 6*time.Second, result.Reset, "two tokens at one every three seconds")
+	assert.False(t, first.Allow("user:erin:default", 20).Allowed, "instances draw on one budget")
+
+	server.Close()
+	assert.True(t, first.Allow("user:erin:default", 20).Allowed, "unreachable buckets fall back to memory")
+}
+
+func TestSharedRateLimiterConcurrentClients(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.SetTime(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+
+	// Two instances, each with its own connection, hammer one user's
+	// budget of a tenth of 600 a minute at once
+	limiters := []*SharedRateLimiter{
+		NewSharedRateLimiter(cache.NewRedisBuckets(redisClient(t, server)), NewMemoryRateLimiter()),
+		NewSharedRateLimiter(cache.NewRedisBuckets(redisClient(t, server)), NewMemoryRateLimiter()),
+	}
+	var allowed atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(limiter *SharedRateLimiter) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if limiter.Allow("user:frank:game", 600).Allowed {
+					allowed.Add(1)
+				}
+			}
+		}(limiters[i%2])
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(60), allowed.Load(), "the clients together get exactly one burst")
+}
+
+func TestIdempotencySharedBetweenInstances(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	runs := 0
+	instance := func() http.Handler {
+		return Idempotency(cache.NewRedis(redisClient(t, server)))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			runs++
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"table":"t1"}`))
+		}))
+	}
+	first, second := instance(), instance()
+	call := func(handler http.Handler) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/games", strings.NewReader(`{}`))
+		req.Header.Set(IdempotencyKeyHeader, "create-1")
+		req = req.WithContext(context.WithValue(req.Context(), "user_id", "alice"))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	assert.Equal(t, http.StatusCreated, call(first).Code)
+	retry := call(second)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, `{"table":"t1"}`, retry.Body.String())
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, 1, runs, "a retry reaching another instance does not run again")
+
+	// Keys expire with their TTL
+	server.FastForward(25 * time.Hour)
+	call(second)
+	assert.Equal(t, 2, runs)
+}
@@ -0,0 +1,182 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+
+	"github.com/primoPoker/server/internal/cache"
+)
+
+// RouteClass groups the routes that draw on the same per-user budget
+type RouteClass string
+
+// Route classes, from strictest to most generous
+const (
+	// RouteClassExport covers data exports, which are expensive to build
+	RouteClassExport RouteClass = "export"
+	// RouteClassAuth covers the routes managing passwords, sessions and
+	// API keys
+	RouteClassAuth RouteClass = "auth"
+	// RouteClassDefault covers every other authenticated route
+	RouteClassDefault RouteClass = "default"
+	// RouteClassGame covers playing at tables
+	RouteClassGame RouteClass = "game"
+)
+
+// UserRateLimits are how many requests a minute each signed-in user may
+// make to each route class. A class left at zero shares the default
+// budget; a zero default leaves users unlimited.
+type UserRateLimits struct {
+	Auth    int
+	Game    int
+	Export  int
+	Default int
+}
+
+// perMinute returns the budget of a route class
+func (l UserRateLimits) perMinute(class RouteClass) int {
+	limit := 0
+	switch class {
+	case RouteClassAuth:
+		limit = l.Auth
+	case RouteClassGame:
+		limit = l.Game
+	case RouteClassExport:
+		limit = l.Export
+	}
+	if limit <= 0 {
+		return l.Default
+	}
+	return limit
+}
+
+// RateLimitResult is a rate limiter's decision on one request
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int           // Requests allowed a minute
+	Remaining int           // Requests left before the limit is reached
+	Reset     time.Duration // Until the budget is whole again
+}
+
+// RateLimiter counts requests against per-minute budgets by key. The
+// in-memory limiter keeps its counts on one instance; a shared limiter
+// counts them wherever every instance sees them.
+type RateLimiter interface {
+	Allow(key string, perMinute int) RateLimitResult
+}
+
+// MemoryRateLimiter limits each key with a token bucket refilling at the
+// per-minute rate, with bursts of a tenth of it. Idle keys are evicted
+// like the IP limiters are.
+type MemoryRateLimiter struct {
+	limiters *limiterSet
+}
+
+// NewMemoryRateLimiter creates a rate limiter keeping its state in memory
+func NewMemoryRateLimiter() *MemoryRateLimiter {
+	return &MemoryRateLimiter{limiters: newLimiterSet()}
+}
+
+// Allow counts a request against key's budget
+func (m *MemoryRateLimiter) Allow(key string, perMinute int) RateLimitResult {
+	limit, burst := rate.Every(time.Minute/time.Duration(perMinute)), max(perMinute/10, 1)
+	limiter := m.limiters.get(key, limit, burst, time.Now())
+
+	allowed := limiter.Allow()
+	tokens := limiter.Tokens()
+	return RateLimitResult{
+		Allowed:   allowed,
+		Limit:     perMinute,
+		Remaining: max(int(tokens), 0),
+		Reset:     time.Duration((float64(burst) - tokens) / float64(limit) * float64(time.Second)),
+	}
+}
+
+// SharedRateLimiter limits each key with a token bucket shared by every
+// instance, shaped like the in-memory limiter's, falling back to another
+// limiter while the buckets cannot be reached
+type SharedRateLimiter struct {
+	buckets  cache.Buckets
+	fallback RateLimiter
+}
+
+// NewSharedRateLimiter creates a rate limiter taking from buckets
+func NewSharedRateLimiter(buckets cache.Buckets, fallback RateLimiter) *SharedRateLimiter {
+	return &SharedRateLimiter{buckets: buckets, fallback: fallback}
+}
+
+// Allow counts a request against key's budget
+func (s *SharedRateLimiter) Allow(key string, perMinute int) RateLimitResult {
+	ctx, cancel := context.WithTimeout(context.Background(), sharedLimitTimeout)
+	defer cancel()
+
+	bucket, err := s.buckets.Take(ctx, "ratelimit:"+key, perMinute, max(perMinute/10, 1))
+	if err != nil {
+		logrus.WithError(err).Debug("Shared rate limit unavailable, limiting in memory")
+		return s.fallback.Allow(key, perMinute)
+	}
+	return RateLimitResult{
+		Allowed:   bucket.Allowed,
+		Limit:     perMinute,
+		Remaining: bucket.Remaining,
+		Reset:     bucket.Reset,
+	}
+}
+
+// ClassifyRoute places a request in the route class whose budget it draws on
+func ClassifyRoute(r *http.Request) RouteClass {
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1")
+	switch {
+	case strings.HasSuffix(path, "/export"):
+		return RouteClassExport
+	case path == "/users/me" || strings.HasPrefix(path, "/users/me/password") ||
+		strings.HasPrefix(path, "/users/me/sessions") || strings.HasPrefix(path, "/users/me/api-keys"):
+		return RouteClassAuth
+	case strings.HasPrefix(path, "/games"):
+		return RouteClassGame
+	default:
+		return RouteClassDefault
+	}
+}
+
+// UserRateLimit limits each signed-in user to their budget for the route
+// class of the request, wherever they connect from; it must run after
+// JWTAuthMiddleware. Requests with an API key were limited per key
+// already. Every response carries the budget in X-RateLimit headers.
+func UserRateLimit(limiter RateLimiter, limits UserRateLimits) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, _ := r.Context().Value("user_id").(string)
+			if _, ok := r.Context().Value("api_key_id").(string); ok || userID == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			class := ClassifyRoute(r)
+			perMinute := limits.perMinute(class)
+			if perMinute <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			result := limiter.Allow("user:"+userID+":"+string(class), perMinute)
+			reset := strconv.Itoa(int((result.Reset + time.Second - 1) / time.Second))
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("X-RateLimit-Reset", reset)
+			if !result.Allowed {
+				w.Header().Set("Retry-After", reset)
+				http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
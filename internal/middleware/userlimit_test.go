@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rateLimitedRoutes returns a public route limited per IP and a protected
+// one limited per user, signed in as the user named in X-Test-User
+func rateLimitedRoutes(limits UserRateLimits) (public, protected http.Handler) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	signIn := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), "user_id", r.Header.Get("X-Test-User"))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+	return RateLimit(ok), signIn(UserRateLimit(NewMemoryRateLimiter(), limits)(ok))
+}
+
+// call makes a request from an IP, as a user when one is given
+func call(handler http.Handler, path, ip, user string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	req.RemoteAddr = ip + ":1234"
+	if user != "" {
+		req.Header.Set("X-Test-User", user)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestUserRateLimitFollowsUserAcrossIPs(t *testing.T) {
+	SetRateLimit(50, 0)
+	defer SetRateLimit(DefaultRateLimitPerMinute, 0)
+	public, protected := rateLimitedRoutes(UserRateLimits{Default: 50})
+
+	for i := 0; i < 5; i++ {
+		rr := call(protected, "/api/v1/leaderboard", "198.51.100.1", "alice")
+		require.Equal(t, http.StatusOK, rr.Code, "request %d", i)
+		assert.Equal(t, "50", rr.Header().Get("X-RateLimit-Limit"))
+	}
+
+	// The IP is allowed but the user is exhausted, wherever they connect from
+	rr := call(protected, "/api/v1/leaderboard", "198.51.100.2", "alice")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "0", rr.Header().Get("X-RateLimit-Remaining"))
+	assert.NotEmpty(t, rr.Header().Get("Retry-After"))
+
+	assert.Equal(t, http.StatusOK, call(protected, "/api/v1/leaderboard", "198.51.100.1", "bob").Code, "other users behind the IP are unaffected")
+	assert.Equal(t, http.StatusOK, call(public, "/api/v1/auth/login", "198.51.100.1", "").Code, "signed-in requests are not counted against the IP")
+}
+
+func TestIPRateLimitSparesSignedInUsers(t *testing.T) {
+	SetRateLimit(50, 0)
+	defer SetRateLimit(DefaultRateLimitPerMinute, 0)
+	public, protected := rateLimitedRoutes(UserRateLimits{Default: 50})
+
+	for i := 0; i < 5; i++ {
+		require.Equal(t, http.StatusOK, call(public, "/api/v1/auth/login", "198.51.100.10", "").Code, "request %d", i)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, call(public, "/api/v1/auth/login", "198.51.100.10", "").Code)
+
+	// The IP is exhausted but a player behind it still has their own budget
+	assert.Equal(t, http.StatusOK, call(protected, "/api/v1/games", "198.51.100.10", "carol").Code)
+
+	// Requests that fail to sign in have no user, so the IP pays for them
+	service := newAuthService(t)
+	jwt := JWTAuthMiddleware(service)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	assert.Equal(t, http.StatusTooManyRequests, call(jwt, "/api/v1/games", "198.51.100.10", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, call(jwt, "/api/v1/games", "198.51.100.11", "").Code)
+}
+
+func TestUserRateLimitBudgetsPerRouteClass(t *testing.T) {
+	_, protected := rateLimitedRoutes(UserRateLimits{Default: 50, Export: 10})
+
+	assert.Equal(t, http.StatusOK, call(protected, "/api/v1/hands/export", "198.51.100.20", "dave").Code)
+	rr := call(protected, "/api/v1/metrics/me/export", "198.51.100.20", "dave")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code, "exports share one strict budget")
+	assert.Equal(t, "10", rr.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, http.StatusOK, call(protected, "/api/v1/games", "198.51.100.20", "dave").Code, "other classes keep their budget")
+
+	for path, class := range map[string]RouteClass{
+		"/api/v1/users/me":              RouteClassAuth,
+		"/api/v1/users/me/password":     RouteClassAuth,
+		"/api/v1/users/me/api-keys/1":   RouteClassAuth,
+		"/api/v1/users/me/export":       RouteClassExport,
+		"/api/v1/users/me/achievements": RouteClassDefault,
+		"/api/v1/games/1/join":          RouteClassGame,
+		"/api/v1/leaderboard":           RouteClassDefault,
+	} {
+		assert.Equal(t, class, ClassifyRoute(httptest.NewRequest("GET", path, nil)), path)
+	}
+}
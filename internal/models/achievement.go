@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Achievement is a milestone players unlock through play and show on their
+// profile as a badge. The rules that unlock each one live in code; these
+// rows are seeded from them at startup.
+type Achievement struct {
+	Code        string    `json:"code" gorm:"primaryKey;size:50"`
+	Name        string    `json:"name" gorm:"not null;size:100"`
+	Description string    `json:"description" gorm:"size:255"`
+	Badge       string    `json:"badge" gorm:"size:50"` // Icon shown on profiles
+	SortOrder   int       `json:"-"`
+	CreatedAt   time.Time `json:"-"`
+	UpdatedAt   time.Time `json:"-"`
+}
+
+// UserAchievement records a player unlocking an achievement. Each can only
+// be unlocked once per player.
+type UserAchievement struct {
+	ID              uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID          uuid.UUID  `json:"user_id" gorm:"type:uuid;not null;uniqueIndex:idx_user_achievements_user_code"`
+	AchievementCode string     `json:"achievement_code" gorm:"not null;size:50;uniqueIndex:idx_user_achievements_user_code"`
+	HandID          *uuid.UUID `json:"hand_id,omitempty" gorm:"type:uuid"` // The hand that unlocked it
+	UnlockedAt      time.Time  `json:"unlocked_at"`
+
+	Achievement Achievement `json:"achievement" gorm:"foreignKey:AchievementCode;references:Code"`
+}
+
+// BeforeCreate will set a UUID rather than numeric ID
+func (a *UserAchievement) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return nil
+}
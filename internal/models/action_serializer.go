@@ -0,0 +1,77 @@
+package models
+
+import (
+	"context"
+	"reflect"
+	"sync"
+
+	"gorm.io/gorm/schema"
+)
+
+func init() {
+	schema.RegisterSerializer("actions", actionSerializer{})
+}
+
+// actionSerializer stores a street's actions as JSON, like the json
+// serializer. Every player's row of a hand holds the same actions, so under
+// a context from WithActionEncoding each street is encoded only once.
+type actionSerializer struct{}
+
+// Scan implements schema.SerializerInterface
+func (actionSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	return schema.JSONSerializer{}.Scan(ctx, field, dst, dbValue)
+}
+
+// Value implements schema.SerializerValuerInterface
+func (actionSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	actions, _ := fieldValue.([]PlayerActionRecord)
+	encodings, ok := ctx.Value(actionEncodingsKey{}).(*actionEncodings)
+	if !ok || len(actions) == 0 {
+		return schema.JSONSerializer{}.Value(ctx, field, dst, fieldValue)
+	}
+	return encodings.encode(actions, func() (interface{}, error) {
+		return schema.JSONSerializer{}.Value(ctx, field, dst, fieldValue)
+	})
+}
+
+type actionEncodingsKey struct{}
+
+// actionEncodings holds the JSON of each street encoded so far, keyed by
+// the slice it was encoded from
+type actionEncodings struct {
+	mu      sync.Mutex
+	encoded map[actionSlice]interface{}
+}
+
+// actionSlice identifies a slice of actions by its backing array and length
+type actionSlice struct {
+	first *PlayerActionRecord
+	len   int
+}
+
+// WithActionEncoding returns a context under which hand histories sharing a
+// street's action slice encode it once. The slices must not change while
+// the context is in use.
+func WithActionEncoding(ctx context.Context) context.Context {
+	return context.WithValue(ctx, actionEncodingsKey{}, &actionEncodings{
+		encoded: make(map[actionSlice]interface{}),
+	})
+}
+
+// encode returns the encoding of actions, calling fn for it the first time
+func (e *actionEncodings) encode(actions []PlayerActionRecord, fn func() (interface{}, error)) (interface{}, error) {
+	key := actionSlice{first: &actions[0], len: len(actions)}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if value, ok := e.encoded[key]; ok {
+		return value, nil
+	}
+	value, err := fn()
+	if err != nil {
+		return nil, err
+	}
+	e.encoded[key] = value
+	return value, nil
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// APIScope limits what a request authenticated with an API key may do
+type APIScope string
+
+const (
+	ScopeRead  APIScope = "read"
+	ScopePlay  APIScope = "play"
+	ScopeAdmin APIScope = "admin"
+)
+
+// scopeRank orders scopes so that each includes the ones below it
+var scopeRank = map[APIScope]int{
+	ScopeRead:  0,
+	ScopePlay:  1,
+	ScopeAdmin: 2,
+}
+
+// IsValid checks if the scope is a known scope
+func (s APIScope) IsValid() bool {
+	_, ok := scopeRank[s]
+	return ok
+}
+
+// Includes checks if the scope grants at least the access of another
+func (s APIScope) Includes(other APIScope) bool {
+	rank, ok := scopeRank[s]
+	return ok && rank >= scopeRank[other]
+}
+
+// APIKey lets a user's bots and tools call the API without a browser
+// session. Only a hash of the key is stored; the key itself is shown once.
+type APIKey struct {
+	ID         uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID     uuid.UUID  `json:"-" gorm:"type:uuid;not null;index"`
+	Name       string     `json:"name" gorm:"not null;size:100"`
+	Prefix     string     `json:"prefix" gorm:"not null;size:16"`
+	KeyHash    string     `json:"-" gorm:"uniqueIndex;not null;size:64"`
+	Scopes     string     `json:"-" gorm:"not null;size:100"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at"`
+
+	// Relationships
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// BeforeCreate will set a UUID rather than numeric ID
+func (k *APIKey) BeforeCreate(tx *gorm.DB) error {
+	if k.ID == uuid.Nil {
+		k.ID = uuid.New()
+	}
+	return nil
+}
+
+// IsActive checks if the key has neither expired nor been revoked
+func (k *APIKey) IsActive(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// ScopeList returns the scopes granted to the key
+func (k *APIKey) ScopeList() []APIScope {
+	var scopes []APIScope
+	for _, s := range strings.Split(k.Scopes, ",") {
+		if s != "" {
+			scopes = append(scopes, APIScope(s))
+		}
+	}
+	return scopes
+}
+
+// SetScopes stores the scopes granted to the key
+func (k *APIKey) SetScopes(scopes []APIScope) {
+	names := make([]string, len(scopes))
+	for i, s := range scopes {
+		names[i] = string(s)
+	}
+	k.Scopes = strings.Join(names, ",")
+}
+
+// HasScope checks if any of the key's scopes grants a scope
+func (k *APIKey) HasScope(scope APIScope) bool {
+	for _, s := range k.ScopeList() {
+		if s.Includes(scope) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AuditTargetType is the kind of thing an audited action was taken on
+type AuditTargetType string
+
+const (
+	AuditTargetUser      AuditTargetType = "user"
+	AuditTargetGame      AuditTargetType = "game"
+	AuditTargetReport    AuditTargetType = "report"
+	AuditTargetRetention AuditTargetType = "retention"
+	AuditTargetSettings  AuditTargetType = "settings"
+	AuditTargetFlag      AuditTargetType = "flag"
+	// AuditTargetRequest marks an admin request that recorded no action of
+	// its own, such as a read or a refused change
+	AuditTargetRequest AuditTargetType = "request"
+)
+
+// AuditLog records an administrative or financial action: who took it, on
+// what, why, and what the target looked like before and after
+type AuditLog struct {
+	ID            uuid.UUID       `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	ActorID       *uuid.UUID      `json:"actor_id,omitempty" gorm:"type:uuid;index:idx_audit_logs_actor_created,priority:1"`
+	ActorUsername string          `json:"actor_username" gorm:"size:50"`
+	Action        string          `json:"action" gorm:"not null;size:50;index"`
+	TargetType    AuditTargetType `json:"target_type" gorm:"not null;size:20;index:idx_audit_logs_target,priority:1"`
+	TargetID      string          `json:"target_id,omitempty" gorm:"size:64;index:idx_audit_logs_target,priority:2"`
+	Before        json.RawMessage `json:"before,omitempty" gorm:"type:jsonb"`
+	After         json.RawMessage `json:"after,omitempty" gorm:"type:jsonb"`
+	Reason        string          `json:"reason,omitempty" gorm:"size:1000"`
+	IPAddress     string          `json:"ip_address" gorm:"size:45"`
+	AuditAccess   `gorm:"embedded"`
+
+	CreatedAt time.Time `json:"created_at" gorm:"index:idx_audit_logs_actor_created,priority:2;index"`
+}
+
+// AuditAccess is the request an audited action came in on, as the admin
+// routes' access log saw it
+type AuditAccess struct {
+	Method    string          `json:"method,omitempty" gorm:"size:10"`
+	Route     string          `json:"route,omitempty" gorm:"size:200"`
+	RouteVars json.RawMessage `json:"route_vars,omitempty" gorm:"type:jsonb"`
+	// RequestBody keeps the fields allowed for the route, with the values
+	// of any others redacted
+	RequestBody json.RawMessage `json:"request_body,omitempty" gorm:"type:jsonb"`
+	Status      int             `json:"status,omitempty"`
+	LatencyMS   int64           `json:"latency_ms,omitempty"`
+	RequestID   string          `json:"request_id,omitempty" gorm:"size:128"`
+}
+
+// BeforeCreate will set a UUID rather than numeric ID
+func (l *AuditLog) BeforeCreate(tx *gorm.DB) error {
+	if l.ID == uuid.Nil {
+		l.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ClubRole is what a member may do in their club
+type ClubRole string
+
+const (
+	ClubRoleOwner  ClubRole = "owner"  // Created the club; cannot leave it
+	ClubRoleAdmin  ClubRole = "admin"  // Helps the owner run the club
+	ClubRoleMember ClubRole = "member" // Plays at the club's tables
+)
+
+// Club is a private group of players with tables only its members can join
+// and a leaderboard of the hands played at them. Players join with the
+// club's join code.
+type Club struct {
+	ID          uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Name        string         `json:"name" gorm:"not null;size:100"`
+	Description string         `json:"description" gorm:"size:500"`
+	OwnerID     uuid.UUID      `json:"owner_id" gorm:"type:uuid;not null;index"`
+	JoinCode    string         `json:"join_code,omitempty" gorm:"not null;size:16;uniqueIndex"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+
+	Members []ClubMember `json:"members,omitempty" gorm:"foreignKey:ClubID"`
+}
+
+// BeforeCreate will set a UUID rather than numeric ID
+func (c *Club) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return nil
+}
+
+// ClubMember is a user's membership of a club
+type ClubMember struct {
+	ID       uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	ClubID   uuid.UUID `json:"club_id" gorm:"type:uuid;not null;uniqueIndex:idx_club_members_club_user,priority:1"`
+	UserID   uuid.UUID `json:"user_id" gorm:"type:uuid;not null;uniqueIndex:idx_club_members_club_user,priority:2;index"`
+	Role     ClubRole  `json:"role" gorm:"not null;size:10;default:'member'"`
+	JoinedAt time.Time `json:"joined_at"`
+
+	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+// BeforeCreate will set a UUID rather than numeric ID
+func (m *ClubMember) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == uuid.Nil {
+		m.ID = uuid.New()
+	}
+	return nil
+}
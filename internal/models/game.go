@@ -45,6 +45,7 @@ type Game struct {
 	// Game State
 	CurrentHand     int           `json:"current_hand" gorm:"default:0"`
 	TotalHands      int           `json:"total_hands" gorm:"default:0"`
+	VoidedHands     int           `json:"voided_hands" gorm:"default:0"` // Hands cut short by a restart, never recorded
 	TotalPot        int64         `json:"total_pot" gorm:"default:0"`
 	CurrentPot      int64         `json:"current_pot" gorm:"default:0"`
 	DealerPosition  int           `json:"dealer_position" gorm:"default:0"`
@@ -61,6 +62,7 @@ type Game struct {
 	Duration    int        `json:"duration"` // seconds
 	
 	// Metadata
+	ClubID      *uuid.UUID     `json:"club_id,omitempty" gorm:"type:uuid;index"` // Only the club's members may join
 	IsPrivate   bool           `json:"is_private" gorm:"default:false"`
 	Password    string         `json:"-" gorm:"size:255"`
 	Description string         `json:"description" gorm:"size:500"`
@@ -77,11 +79,12 @@ type Game struct {
 	HandHistories  []HandHistory       `json:"hand_histories,omitempty" gorm:"foreignKey:GameID"`
 }
 
-// GameParticipation represents a user's participation in a game
+// GameParticipation represents a user's participation in a game. A user
+// has at most one active participation in each game.
 type GameParticipation struct {
 	ID       uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	GameID   uuid.UUID `json:"game_id" gorm:"type:uuid;not null"`
-	UserID   uuid.UUID `json:"user_id" gorm:"type:uuid;not null"`
+	GameID   uuid.UUID `json:"game_id" gorm:"type:uuid;not null;uniqueIndex:idx_game_participations_active,where:is_active = true;index:idx_game_participations_user_game,priority:2"`
+	UserID   uuid.UUID `json:"user_id" gorm:"type:uuid;not null;uniqueIndex:idx_game_participations_active,where:is_active = true;index:idx_game_participations_user_game,priority:1"`
 	
 	// Player State
 	SeatPosition    int   `json:"seat_position" gorm:"not null"`
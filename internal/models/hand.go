@@ -0,0 +1,101 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Hand is the table-level record of a hand: what every player dealt in
+// shared, stored once. Each player's HandHistory row references it.
+type Hand struct {
+	ID         uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	GameID     uuid.UUID `json:"game_id" gorm:"type:uuid;not null;uniqueIndex:idx_hands_game_hand,priority:1"`
+	HandNumber int       `json:"hand_number" gorm:"not null;uniqueIndex:idx_hands_game_hand,priority:2"`
+
+	TableName      string `json:"table_name" gorm:"size:100"`
+	DealerPosition int    `json:"dealer_position"`
+	PlayersDealtIn int    `json:"players_dealt_in"`
+	SmallBlind     int64  `json:"small_blind"`
+	BigBlind       int64  `json:"big_blind"`
+
+	// Board holds the community cards dealt, in order, as space-separated
+	// two-character codes such as "Ah Td 7c"
+	Board string `json:"board" gorm:"size:14"`
+	// BoardSuited is the most board cards sharing a suit, so four to a
+	// flush is BoardSuited >= 4
+	BoardSuited int `json:"board_suited" gorm:"not null;default:0;index"`
+	// BoardPaired is set when two board cards share a rank, and
+	// BoardStraight when three fall within a straight's five ranks
+	BoardPaired   bool `json:"board_paired" gorm:"not null;default:false;index"`
+	BoardStraight bool `json:"board_straight" gorm:"not null;default:false;index"`
+
+	Pot     int64       `json:"pot" gorm:"index"`
+	Rake    int64       `json:"rake" gorm:"not null;default:0"`
+	Winners []uuid.UUID `json:"winners" gorm:"serializer:json"`
+	// Summary tells the hand in words for free-text search. Hole cards are
+	// only named for players who showed them down.
+	Summary string `json:"summary" gorm:"type:text;not null;default:''"`
+
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// BoardCards returns the community cards dealt, in order
+func (h *Hand) BoardCards() []string {
+	return strings.Fields(h.Board)
+}
+
+// boardRanks orders the ranks of card codes, with the ace high at 14
+const boardRanks = "23456789TJQKA"
+
+// SetBoard stores the community cards, given as two-character codes, and
+// the texture they make
+func (h *Hand) SetBoard(cards []string) {
+	h.Board = strings.Join(cards, " ")
+	h.BoardSuited, h.BoardPaired, h.BoardStraight = 0, false, false
+
+	suited := make(map[byte]int)
+	ranks := make(map[int]bool)
+	for _, card := range cards {
+		if len(card) != 2 {
+			continue
+		}
+		suited[card[1]]++
+		if suited[card[1]] > h.BoardSuited {
+			h.BoardSuited = suited[card[1]]
+		}
+
+		rank := strings.IndexByte(boardRanks, card[0]) + 2
+		if rank < 2 {
+			continue
+		}
+		if ranks[rank] {
+			h.BoardPaired = true
+		}
+		ranks[rank] = true
+	}
+
+	// The ace also plays low, in the five-high straight
+	ranks[1] = ranks[14]
+	for low := 1; low <= 10 && !h.BoardStraight; low++ {
+		within := 0
+		for rank := low; rank < low+5; rank++ {
+			if ranks[rank] {
+				within++
+			}
+		}
+		h.BoardStraight = within >= 3
+	}
+}
+
+// BeforeCreate will set a UUID rather than numeric ID
+func (h *Hand) BeforeCreate(tx *gorm.DB) error {
+	if h.ID == uuid.Nil {
+		h.ID = uuid.New()
+	}
+	return nil
+}
@@ -30,19 +30,55 @@ const (
 	ActionAllIn   PlayerAction = "all_in"
 )
 
-// HandHistory represents a complete poker hand record
+// TableSize groups hands by how many players were dealt in
+type TableSize string
+
+const (
+	TableSizeHeadsUp  TableSize = "heads_up"  // Two players
+	TableSizeSixMax   TableSize = "six_max"   // Three to six players
+	TableSizeFullRing TableSize = "full_ring" // Seven or more players
+)
+
+// PlayersDealtIn returns the fewest and most players dealt into a hand at
+// the table size, with no upper bound when most is zero. ok is false for
+// an unknown size.
+func (s TableSize) PlayersDealtIn() (fewest, most int, ok bool) {
+	switch s {
+	case TableSizeHeadsUp:
+		return 2, 2, true
+	case TableSizeSixMax:
+		return 3, 6, true
+	case TableSizeFullRing:
+		return 7, 0, true
+	default:
+		return 0, 0, false
+	}
+}
+
+// HandHistoryArchiveTable holds hand histories past the retention period.
+// It has the columns of hand_histories, so its rows scan into HandHistory.
+const HandHistoryArchiveTable = "hand_histories_archive"
+
+// HandHistory represents a complete poker hand record, one row for each
+// player dealt into the hand
 type HandHistory struct {
 	ID       uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	GameID   uuid.UUID `json:"game_id" gorm:"type:uuid;not null"`
-	UserID   uuid.UUID `json:"user_id" gorm:"type:uuid;not null"`
+	GameID   uuid.UUID `json:"game_id" gorm:"type:uuid;not null;index:idx_hand_histories_game_hand;uniqueIndex:idx_hand_histories_hand_user,priority:1"`
+	UserID   uuid.UUID `json:"user_id" gorm:"type:uuid;not null;index:idx_hand_histories_user_started,priority:1;index:idx_hand_histories_user_winner,priority:1;uniqueIndex:idx_hand_histories_hand_user,priority:3"`
+	// HandID is the table-level record of the hand, for hands recorded
+	// since it was kept
+	HandID   *uuid.UUID `json:"hand_id,omitempty" gorm:"type:uuid;index"`
 	
 	// Hand Identification
-	HandNumber      int       `json:"hand_number" gorm:"not null"`
+	HandNumber      int       `json:"hand_number" gorm:"not null;index:idx_hand_histories_game_hand;uniqueIndex:idx_hand_histories_hand_user,priority:2"`
 	TableName       string    `json:"table_name" gorm:"size:100"`
 	DealerPosition  int       `json:"dealer_position"`
 	SeatPosition    int       `json:"seat_position"`
+	Position        string    `json:"position,omitempty" gorm:"size:8"` // Betting position, e.g. BTN or UTG
+	PlayersDealtIn  int       `json:"players_dealt_in" gorm:"not null;default:0"` // Zero for hands recorded before it was kept
 	
-	// Hand Cards
+	// Hand Cards. The board is kept on the table-level Hand; these columns
+	// repeat it for hands recorded before it was, and for older readers.
 	HoleCard1Rank   string `json:"hole_card1_rank" gorm:"size:2"`
 	HoleCard1Suit   string `json:"hole_card1_suit" gorm:"size:10"`
 	HoleCard2Rank   string `json:"hole_card2_rank" gorm:"size:2"`
@@ -70,26 +106,29 @@ type HandHistory struct {
 	AmountWon       int64 `json:"amount_won"`
 	
 	// Player Actions Summary
-	PreFlopActions  []PlayerActionRecord `json:"pre_flop_actions" gorm:"serializer:json"`
-	FlopActions     []PlayerActionRecord `json:"flop_actions" gorm:"serializer:json"`
-	TurnActions     []PlayerActionRecord `json:"turn_actions" gorm:"serializer:json"`
-	RiverActions    []PlayerActionRecord `json:"river_actions" gorm:"serializer:json"`
+	PreFlopActions  []PlayerActionRecord `json:"pre_flop_actions" gorm:"serializer:actions"`
+	FlopActions     []PlayerActionRecord `json:"flop_actions" gorm:"serializer:actions"`
+	TurnActions     []PlayerActionRecord `json:"turn_actions" gorm:"serializer:actions"`
+	RiverActions    []PlayerActionRecord `json:"river_actions" gorm:"serializer:actions"`
 	
 	// Hand Result
 	HandRank        string    `json:"hand_rank" gorm:"size:50"`
-	BestHand        string    `json:"best_hand" gorm:"size:200"`
-	IsWinner        bool      `json:"is_winner" gorm:"default:false"`
+	BestHand        string    `json:"best_hand" gorm:"size:200"` // Compact notation, "As Kd ..."; older rows read "A♠ K♦ ...", which poker.ParseCards also reads
+	IsWinner        bool      `json:"is_winner" gorm:"default:false;index:idx_hand_histories_user_winner,priority:2"`
 	WentToShowdown  bool      `json:"went_to_showdown" gorm:"default:false"`
 	FoldedPhase     HandPhase `json:"folded_phase,omitempty" gorm:"size:20"`
+	// AgainstBots marks a hand bots were dealt into. It is kept in the
+	// player's history but left out of their metrics and leaderboards.
+	AgainstBots     bool      `json:"against_bots" gorm:"not null;default:false"`
 	
 	// Statistics
-	VPIPPercent     float64 `json:"vpip_percent"` // Voluntarily Put $ In Pot
+	VPIPPercent     float64 `json:"vpip_percent" gorm:"column:vpip_percent"` // Voluntarily Put $ In Pot
 	PFRPercent      float64 `json:"pfr_percent"`  // Pre-Flop Raise
 	AggressionFactor float64 `json:"aggression_factor"`
 	WinRate        float64 `json:"win_rate"`
 	
 	// Timestamps
-	StartedAt  time.Time `json:"started_at"`
+	StartedAt  time.Time `json:"started_at" gorm:"index:idx_hand_histories_user_started,priority:2,sort:desc;index:idx_hand_histories_started"`
 	FinishedAt time.Time `json:"finished_at"`
 	Duration   int       `json:"duration"` // seconds
 	
@@ -98,8 +137,9 @@ type HandHistory struct {
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 	
 	// Relationships
-	Game Game `json:"game,omitempty" gorm:"foreignKey:GameID"`
-	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Game Game  `json:"game,omitempty" gorm:"foreignKey:GameID"`
+	User User  `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Hand *Hand `json:"hand,omitempty" gorm:"foreignKey:HandID"`
 }
 
 // PlayerActionRecord represents a single action taken by a player
@@ -111,13 +151,25 @@ type PlayerActionRecord struct {
 	Timestamp  time.Time    `json:"timestamp"`
 	ChipsBefore int64       `json:"chips_before"`
 	ChipsAfter  int64       `json:"chips_after"`
+	Position    string      `json:"position,omitempty"`
 }
 
-// HandSummary provides a condensed view of hand statistics
+// SummaryPeriod is the length of time a hand summary covers
+type SummaryPeriod string
+
+const (
+	SummaryPeriodDay  SummaryPeriod = "day"
+	SummaryPeriodWeek SummaryPeriod = "week" // Monday to Sunday, UTC
+)
+
+// HandSummary provides a condensed view of hand statistics. Summaries are
+// rolled up for each player's days and weeks; one covering every table has
+// no game.
 type HandSummary struct {
-	ID             uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	UserID         uuid.UUID `json:"user_id" gorm:"type:uuid;not null"`
-	GameID         uuid.UUID `json:"game_id" gorm:"type:uuid;not null"`
+	ID             uuid.UUID     `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID         uuid.UUID     `json:"user_id" gorm:"type:uuid;not null;uniqueIndex:idx_hand_summaries_user_period"`
+	GameID         *uuid.UUID    `json:"game_id,omitempty" gorm:"type:uuid"`
+	Period         SummaryPeriod `json:"period" gorm:"size:10;uniqueIndex:idx_hand_summaries_user_period"`
 	
 	// Aggregated Statistics
 	TotalHands     int     `json:"total_hands"`
@@ -145,7 +197,7 @@ type HandSummary struct {
 	ConnectedCards int `json:"connected_cards"`
 	
 	// Time Period
-	PeriodStart time.Time `json:"period_start"`
+	PeriodStart time.Time `json:"period_start" gorm:"uniqueIndex:idx_hand_summaries_user_period"`
 	PeriodEnd   time.Time `json:"period_end"`
 	
 	CreatedAt time.Time      `json:"created_at"`
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// HandSettlement marks a hand whose results have been stored. Its key is
+// claimed in the same transaction as the hand's writes, so a hand replayed
+// after a failure is only ever paid out once.
+type HandSettlement struct {
+	GameID     uuid.UUID `json:"game_id" gorm:"type:uuid;primaryKey"`
+	HandNumber int       `json:"hand_number" gorm:"primaryKey;autoIncrement:false"`
+	SettledAt  time.Time `json:"settled_at"`
+}
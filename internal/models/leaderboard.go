@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LeaderboardMetric is a statistic players are ranked by
+type LeaderboardMetric string
+
+const (
+	LeaderboardBBPer100        LeaderboardMetric = "bb_per_100"
+	LeaderboardBiggestPot      LeaderboardMetric = "biggest_pot"
+	LeaderboardWinningStreak   LeaderboardMetric = "winning_streak"
+	LeaderboardShowdownWinRate LeaderboardMetric = "showdown_win_rate"
+)
+
+// LeaderboardMetrics lists every metric with a leaderboard
+var LeaderboardMetrics = []LeaderboardMetric{
+	LeaderboardBBPer100,
+	LeaderboardBiggestPot,
+	LeaderboardWinningStreak,
+	LeaderboardShowdownWinRate,
+}
+
+// IsValid checks if the metric has a leaderboard
+func (m LeaderboardMetric) IsValid() bool {
+	for _, metric := range LeaderboardMetrics {
+		if m == metric {
+			return true
+		}
+	}
+	return false
+}
+
+// LeaderboardPeriod is how far back a live leaderboard counts hands
+type LeaderboardPeriod string
+
+const (
+	LeaderboardPeriodDay     LeaderboardPeriod = "day"
+	LeaderboardPeriodWeek    LeaderboardPeriod = "week" // Monday to Sunday, UTC
+	LeaderboardPeriodMonth   LeaderboardPeriod = "month"
+	LeaderboardPeriodAllTime LeaderboardPeriod = "all_time"
+)
+
+// Start returns when the period containing now began (UTC), or the zero
+// time for all time. ok is false for an unknown period.
+func (p LeaderboardPeriod) Start(now time.Time) (start time.Time, ok bool) {
+	now = now.UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	switch p {
+	case LeaderboardPeriodDay:
+		return day, true
+	case LeaderboardPeriodWeek:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7), true
+	case LeaderboardPeriodMonth:
+		return day.AddDate(0, 0, 1-day.Day()), true
+	case LeaderboardPeriodAllTime:
+		return time.Time{}, true
+	}
+	return time.Time{}, false
+}
+
+// LeaderboardEntry is a player's place on one leaderboard as materialized
+// on a given day. Ranks are unique within a day's board: ties go to the
+// player with more hands, then to the lower user ID.
+type LeaderboardEntry struct {
+	Day         time.Time         `json:"day" gorm:"primaryKey"`
+	Metric      LeaderboardMetric `json:"metric" gorm:"size:30;primaryKey"`
+	UserID      uuid.UUID         `json:"user_id" gorm:"type:uuid;primaryKey"`
+	Rank        int               `json:"rank" gorm:"not null;index"`
+	Username    string            `json:"username" gorm:"size:50"`
+	Value       float64           `json:"value"`
+	HandsPlayed int               `json:"hands_played"`
+	CreatedAt   time.Time         `json:"created_at"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// LoginOutcome is the result of a sign-in attempt
+type LoginOutcome string
+
+const (
+	LoginSucceeded          LoginOutcome = "success"
+	LoginInvalidCredentials LoginOutcome = "invalid_credentials"
+	LoginLocked             LoginOutcome = "locked"
+	LoginThrottled          LoginOutcome = "throttled"
+	LoginDisabled           LoginOutcome = "disabled"
+)
+
+// LoginEvent records one sign-in attempt. Attempts against usernames that
+// do not exist are kept too, without a user ID.
+type LoginEvent struct {
+	ID        uuid.UUID    `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID    *uuid.UUID   `json:"-" gorm:"type:uuid;index:idx_login_events_user_created"`
+	Username  string       `json:"-" gorm:"size:50"`
+	Method    string       `json:"method" gorm:"not null;size:20"`
+	IPAddress string       `json:"ip_address" gorm:"size:45"`
+	UserAgent string       `json:"user_agent" gorm:"size:500"`
+	Outcome   LoginOutcome `json:"outcome" gorm:"not null;size:30"`
+
+	// DeviceFingerprint identifies the browser and address a login came from
+	DeviceFingerprint string `json:"-" gorm:"size:64;index"`
+	NewDevice         bool   `json:"new_device"`
+
+	CreatedAt time.Time `json:"created_at" gorm:"index:idx_login_events_user_created;index"`
+}
+
+// BeforeCreate will set a UUID rather than numeric ID
+func (e *LoginEvent) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// OAuthIdentity links a user to an account at an external identity provider,
+// keyed by the provider's stable subject ID rather than the email address
+type OAuthIdentity struct {
+	ID       uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID   uuid.UUID `json:"user_id" gorm:"type:uuid;not null;index"`
+	Provider string    `json:"provider" gorm:"not null;size:20;uniqueIndex:idx_oauth_provider_subject"`
+	Subject  string    `json:"-" gorm:"not null;size:255;uniqueIndex:idx_oauth_provider_subject"`
+	Email    string    `json:"email" gorm:"size:255"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at"`
+
+	// Relationships
+	User User `json:"-" gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
+
+// BeforeCreate will set a UUID rather than numeric ID
+func (i *OAuthIdentity) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == uuid.Nil {
+		i.ID = uuid.New()
+	}
+	return nil
+}
+
+// TableName keeps "OAuth" as one word in the table name
+func (OAuthIdentity) TableName() string {
+	return "oauth_identities"
+}
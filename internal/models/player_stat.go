@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PlayerStatAggregate holds the running totals behind a player's metrics
+// for the hands they played on one day (UTC) at one stake. Rows are
+// updated as hands are stored, so metrics over whole days are summed from
+// them rather than recalculated from every hand.
+type PlayerStatAggregate struct {
+	UserID     uuid.UUID `json:"user_id" gorm:"type:uuid;primaryKey"`
+	Day        time.Time `json:"day" gorm:"primaryKey"`
+	GameType   GameType  `json:"game_type" gorm:"size:20;primaryKey"`
+	SmallBlind int64     `json:"small_blind" gorm:"primaryKey;autoIncrement:false"`
+	BigBlind   int64     `json:"big_blind" gorm:"primaryKey;autoIncrement:false"`
+
+	FirstHandAt time.Time `json:"first_hand_at"`
+	Hands       int       `json:"hands"`
+	HandsWon    int       `json:"hands_won"`
+	HandsFolded int       `json:"hands_folded"`
+
+	// Showdowns
+	Showdowns           int   `json:"showdowns"`
+	ShowdownsWon        int   `json:"showdowns_won"`
+	WonDollarAtShowdown int64 `json:"won_dollar_at_showdown"`
+	FlopsSeen           int   `json:"flops_seen"`
+	FlopShowdowns       int   `json:"flop_showdowns"`
+	FlopShowdownsWon    int   `json:"flop_showdowns_won"`
+	WonWhenSawFlop      int   `json:"won_when_saw_flop"`
+
+	// Money
+	TotalWagered  int64         `json:"total_wagered"`
+	TotalWon      int64         `json:"total_won"`
+	BiggestWin    int64         `json:"biggest_win"`
+	BiggestLoss   int64         `json:"biggest_loss"`
+	BiggestPotWon int64         `json:"biggest_pot_won"`
+	PotSizeSum    int64         `json:"pot_size_sum"`
+	BigBlindsWon  float64       `json:"big_blinds_won"`
+	RakePaid      int64         `json:"rake_paid"`  // The player's share of the rake taken from pots they won
+	TableTime     time.Duration `json:"table_time"` // Time at the table the day's hands added
+
+	// Pre-flop tallies; the chances and faced counts are the hands where
+	// the player had the option
+	VPIPHands        int `json:"vpip_hands" gorm:"column:vpip_hands"`
+	PFRHands         int `json:"pfr_hands"`
+	ThreeBetChances  int `json:"three_bet_chances"`
+	ThreeBets        int `json:"three_bets"`
+	FacedThreeBet    int `json:"faced_three_bet"`
+	FoldsToThreeBet  int `json:"folds_to_three_bet"`
+	FourBetChances   int `json:"four_bet_chances"`
+	FourBets         int `json:"four_bets"`
+	FacedFourBet     int `json:"faced_four_bet"`
+	FoldsToFourBet   int `json:"folds_to_four_bet"`
+	StealChances     int `json:"steal_chances"`
+	Steals           int `json:"steals"`
+	FacedSteal       int `json:"faced_steal"`
+	FoldsToSteal     int `json:"folds_to_steal"`
+	ThreeBetsVsSteal int `json:"three_bets_vs_steal"`
+	BigBlindHands    int `json:"big_blind_hands"`
+	Walks            int `json:"walks"`
+
+	// Post-flop tallies
+	CBets             int `json:"cbets"`
+	FoldToCBets       int `json:"fold_to_cbets"`
+	AggressiveActions int `json:"aggressive_actions"`
+	PassiveActions    int `json:"passive_actions"`
+
+	UpdatedAt time.Time `json:"updated_at"`
+}
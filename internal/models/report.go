@@ -0,0 +1,113 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ReportCategory classifies what a player is being reported for
+type ReportCategory string
+
+const (
+	ReportCategoryAbusiveChat ReportCategory = "abusive_chat"
+	ReportCategoryCollusion   ReportCategory = "collusion"
+	ReportCategoryCheating    ReportCategory = "cheating"
+	ReportCategoryOther       ReportCategory = "other"
+)
+
+// IsValid checks if the category is one players may choose
+func (c ReportCategory) IsValid() bool {
+	switch c {
+	case ReportCategoryAbusiveChat, ReportCategoryCollusion, ReportCategoryCheating, ReportCategoryOther:
+		return true
+	}
+	return false
+}
+
+// ReportStatus represents where a report is in the review queue
+type ReportStatus string
+
+const (
+	ReportStatusOpen     ReportStatus = "open"
+	ReportStatusResolved ReportStatus = "resolved"
+)
+
+// ReportOutcome is the decision an admin reached on a report
+type ReportOutcome string
+
+const (
+	ReportOutcomeDismiss ReportOutcome = "dismiss"
+	ReportOutcomeWarn    ReportOutcome = "warn"
+	ReportOutcomeBan     ReportOutcome = "ban"
+)
+
+// IsValid checks if the outcome is a known resolution
+func (o ReportOutcome) IsValid() bool {
+	switch o {
+	case ReportOutcomeDismiss, ReportOutcomeWarn, ReportOutcomeBan:
+		return true
+	}
+	return false
+}
+
+// PlayerReport is a complaint one player filed about another at a table
+type PlayerReport struct {
+	ID          uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	ReporterID  uuid.UUID      `json:"reporter_id" gorm:"type:uuid;not null;index"`
+	SubjectID   uuid.UUID      `json:"subject_id" gorm:"type:uuid;not null;index"`
+	GameID      string         `json:"game_id" gorm:"not null;size:64;index"`
+	HandNumber  *int           `json:"hand_number,omitempty"`
+	Category    ReportCategory `json:"category" gorm:"not null;size:20"`
+	Description string         `json:"description" gorm:"size:2000"`
+
+	// Table activity captured when the report was filed
+	Context ReportContext `json:"context" gorm:"serializer:json"`
+
+	// Review
+	Status         ReportStatus  `json:"status" gorm:"not null;default:'open';index"`
+	Outcome        ReportOutcome `json:"outcome,omitempty" gorm:"size:20"`
+	ResolutionNote string        `json:"resolution_note,omitempty" gorm:"size:1000"`
+	ResolvedBy     string        `json:"resolved_by,omitempty" gorm:"size:50"`
+	ResolvedAt     *time.Time    `json:"resolved_at,omitempty"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Relationships
+	Reporter User `json:"-" gorm:"foreignKey:ReporterID"`
+	Subject  User `json:"-" gorm:"foreignKey:SubjectID"`
+}
+
+// ReportContext is a snapshot of recent table activity attached to a report
+type ReportContext struct {
+	HandNumber int                 `json:"hand_number"`
+	Chat       []ReportChatMessage `json:"chat"`
+	Actions    []ReportAction      `json:"actions"`
+}
+
+// ReportChatMessage is a chat line captured in a report's context
+type ReportChatMessage struct {
+	UserID  string          `json:"user_id"`
+	Message json.RawMessage `json:"message"`
+	Time    time.Time       `json:"time"`
+}
+
+// ReportAction is a betting action captured in a report's context
+type ReportAction struct {
+	PlayerID string    `json:"player_id"`
+	Action   string    `json:"action"`
+	Amount   int64     `json:"amount"`
+	Time     time.Time `json:"time"`
+}
+
+// BeforeCreate will set a UUID rather than numeric ID
+func (r *PlayerReport) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
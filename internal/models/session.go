@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Session represents a signed-in device, backed by a refresh token
+type Session struct {
+	ID               uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID           uuid.UUID  `json:"user_id" gorm:"type:uuid;not null;index"`
+	RefreshTokenHash string     `json:"-" gorm:"uniqueIndex;not null;size:64"`
+	IPAddress        string     `json:"ip_address" gorm:"size:45"`
+	UserAgent        string     `json:"user_agent" gorm:"size:500"`
+	LastUsedAt       time.Time  `json:"last_used_at"`
+	ExpiresAt        time.Time  `json:"expires_at" gorm:"index"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Relationships
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// BeforeCreate will set a UUID rather than numeric ID
+func (s *Session) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
+
+// IsActive checks if the session has neither expired nor been revoked
+func (s *Session) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// RotatedRefreshToken records a refresh token that has already been exchanged.
+// Seeing one again means the token was copied, so the whole session is revoked.
+type RotatedRefreshToken struct {
+	TokenHash string    `json:"-" gorm:"primaryKey;size:64"`
+	SessionID uuid.UUID `json:"session_id" gorm:"type:uuid;not null;index"`
+	RotatedAt time.Time `json:"rotated_at"`
+
+	// Relationships
+	Session Session `json:"-" gorm:"foreignKey:SessionID;constraint:OnDelete:CASCADE"`
+}
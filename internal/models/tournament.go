@@ -0,0 +1,155 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TournamentStatus represents the lifecycle state of a tournament
+type TournamentStatus string
+
+const (
+	TournamentStatusScheduled   TournamentStatus = "scheduled"
+	TournamentStatusRegistering TournamentStatus = "registering"
+	TournamentStatusRunning     TournamentStatus = "running"
+	TournamentStatusFinished    TournamentStatus = "finished"
+	TournamentStatusCancelled   TournamentStatus = "cancelled"
+)
+
+// BlindLevel is one step of a tournament's blind structure
+type BlindLevel struct {
+	Level      int   `json:"level"`
+	SmallBlind int64 `json:"small_blind"`
+	BigBlind   int64 `json:"big_blind"`
+	Ante       int64 `json:"ante"`
+	Minutes    int   `json:"minutes"`
+}
+
+// PayoutTier is the share of the prize pool paid to a finishing place
+type PayoutTier struct {
+	Place   int     `json:"place"`
+	Percent float64 `json:"percent"`
+}
+
+// Tournament represents a multi-table tournament
+type Tournament struct {
+	ID          uuid.UUID        `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Name        string           `json:"name" gorm:"not null;size:100"`
+	GameType    GameType         `json:"game_type" gorm:"not null;default:'texas_holdem'"`
+	Status      TournamentStatus `json:"status" gorm:"not null;default:'scheduled';index"`
+	Description string           `json:"description" gorm:"size:500"`
+
+	// Structure
+	BuyIn          int64        `json:"buy_in" gorm:"not null"`
+	StartingStack  int64        `json:"starting_stack" gorm:"not null"`
+	MinPlayers     int          `json:"min_players" gorm:"not null;default:2"`
+	MaxPlayers     int          `json:"max_players" gorm:"not null"`
+	TableSize      int          `json:"table_size" gorm:"not null;default:9"`
+	BlindStructure []BlindLevel `json:"blind_structure" gorm:"serializer:json"`
+	Payouts        []PayoutTier `json:"payouts" gorm:"serializer:json"`
+
+	// Schedule
+	RegistrationOpensAt  time.Time  `json:"registration_opens_at"`
+	RegistrationClosesAt time.Time  `json:"registration_closes_at"`
+	StartsAt             time.Time  `json:"starts_at"`
+	StartedAt            *time.Time `json:"started_at"`
+	FinishedAt           *time.Time `json:"finished_at"`
+
+	// Progress
+	CurrentLevel   int        `json:"current_level" gorm:"default:0"`
+	LevelStartedAt *time.Time `json:"level_started_at"`
+	PrizePool      int64      `json:"prize_pool" gorm:"default:0"`
+
+	// Timestamps
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// Relationships
+	Registrations []TournamentRegistration `json:"registrations,omitempty" gorm:"foreignKey:TournamentID"`
+}
+
+// TournamentRegistration represents a player's entry into a tournament
+type TournamentRegistration struct {
+	ID           uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	TournamentID uuid.UUID `json:"tournament_id" gorm:"type:uuid;not null;uniqueIndex:idx_tournament_user"`
+	UserID       uuid.UUID `json:"user_id" gorm:"type:uuid;not null;uniqueIndex:idx_tournament_user"`
+
+	// Entry
+	BuyInPaid    int64     `json:"buy_in_paid" gorm:"not null"`
+	RegisteredAt time.Time `json:"registered_at"`
+
+	// In-play state, maintained by the tournament engine
+	Chips          int64  `json:"chips" gorm:"default:0"`
+	TableID        string `json:"table_id" gorm:"size:100"`
+	SeatPosition   int    `json:"seat_position" gorm:"default:0"`
+	IsEliminated   bool   `json:"is_eliminated" gorm:"default:false"`
+	FinishPosition int    `json:"finish_position" gorm:"default:0"`
+	Prize          int64  `json:"prize" gorm:"default:0"`
+
+	// Timestamps
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Relationships
+	Tournament Tournament `json:"-" gorm:"foreignKey:TournamentID"`
+	User       User       `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+// BeforeCreate will set a UUID rather than numeric ID
+func (t *Tournament) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
+
+func (tr *TournamentRegistration) BeforeCreate(tx *gorm.DB) error {
+	if tr.ID == uuid.Nil {
+		tr.ID = uuid.New()
+	}
+	if tr.RegisteredAt.IsZero() {
+		tr.RegisteredAt = time.Now()
+	}
+	return nil
+}
+
+// IsRegistrationOpen checks whether new entries are accepted at the given time.
+// The player cap is enforced separately since it needs the registration count.
+func (t *Tournament) IsRegistrationOpen(now time.Time) bool {
+	if t.Status != TournamentStatusScheduled && t.Status != TournamentStatusRegistering {
+		return false
+	}
+	if !t.RegistrationOpensAt.IsZero() && now.Before(t.RegistrationOpensAt) {
+		return false
+	}
+	return now.Before(t.RegistrationClosesAt)
+}
+
+// HasStarted checks if the tournament is underway or over
+func (t *Tournament) HasStarted() bool {
+	return t.Status == TournamentStatusRunning || t.Status == TournamentStatusFinished
+}
+
+// GetCurrentBlindLevel returns the blind level in play, or nil before the start
+func (t *Tournament) GetCurrentBlindLevel() *BlindLevel {
+	if !t.HasStarted() || len(t.BlindStructure) == 0 {
+		return nil
+	}
+	index := t.CurrentLevel
+	if index >= len(t.BlindStructure) {
+		index = len(t.BlindStructure) - 1
+	}
+	return &t.BlindStructure[index]
+}
+
+// GetPayoutAmounts converts the payout percentages into chip amounts for the current prize pool
+func (t *Tournament) GetPayoutAmounts() map[int]int64 {
+	amounts := make(map[int]int64, len(t.Payouts))
+	for _, tier := range t.Payouts {
+		amounts[tier.Place] = int64(float64(t.PrizePool) * tier.Percent / 100.0)
+	}
+	return amounts
+}
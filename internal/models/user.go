@@ -7,6 +7,37 @@ import (
 	"gorm.io/gorm"
 )
 
+// Role controls what a user may do beyond playing
+type Role string
+
+const (
+	RolePlayer    Role = "player"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+// roleRank orders roles so that each includes the ones below it
+var roleRank = map[Role]int{
+	RolePlayer:    0,
+	RoleModerator: 1,
+	RoleAdmin:     2,
+}
+
+// IsValid checks if the role is a known role
+func (r Role) IsValid() bool {
+	_, ok := roleRank[r]
+	return ok
+}
+
+// Includes checks if the role grants at least the permissions of another
+func (r Role) Includes(other Role) bool {
+	if r == "" {
+		r = RolePlayer
+	}
+	rank, ok := roleRank[r]
+	return ok && rank >= roleRank[other]
+}
+
 // User represents a poker player
 type User struct {
 	ID           uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
@@ -31,8 +62,11 @@ type User struct {
 	IsActive      bool      `json:"is_active" gorm:"default:true"`
 	IsVerified    bool      `json:"is_verified" gorm:"default:false"`
 	IsBanned      bool      `json:"is_banned" gorm:"default:false"`
-	LastLoginAt   *time.Time `json:"last_login_at"`
+	Role          Role      `json:"role" gorm:"not null;default:'player';size:20"`
+	LastLoginAt   *time.Time `json:"last_login_at" gorm:"index"`
 	LoginAttempts int       `json:"-" gorm:"default:0"`
+	LastFailedLoginAt *time.Time `json:"-"`
+	LockedUntil       *time.Time `json:"-"`
 	
 	// Preferences
 	Timezone     string `json:"timezone" gorm:"default:'UTC';size:50"`
@@ -83,18 +117,21 @@ func (u *User) CanPlay() bool {
 	return u.IsActive && !u.IsBanned && u.ChipBalance > 0
 }
 
-// UpdateLoginAttempts increments failed login attempts
-func (u *User) UpdateLoginAttempts(tx *gorm.DB) error {
-	u.LoginAttempts++
-	if u.LoginAttempts >= 5 {
-		u.IsBanned = true
-	}
-	return tx.Save(u).Error
+// HasRole checks if the user holds a role or one above it
+func (u *User) HasRole(role Role) bool {
+	return u.Role.Includes(role)
+}
+
+// IsLocked checks if too many failed logins have temporarily locked the account
+func (u *User) IsLocked(now time.Time) bool {
+	return u.LockedUntil != nil && now.Before(*u.LockedUntil)
 }
 
 // ResetLoginAttempts clears failed login attempts on successful login
 func (u *User) ResetLoginAttempts(tx *gorm.DB) error {
 	u.LoginAttempts = 0
+	u.LastFailedLoginAt = nil
+	u.LockedUntil = nil
 	now := time.Now()
 	u.LastLoginAt = &now
 	return tx.Save(u).Error
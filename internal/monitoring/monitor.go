@@ -0,0 +1,237 @@
+// Package monitoring exposes the server's operational metrics to
+// Prometheus. Subsystems are not instrumented with counters of their own
+// here: each reports a Stats snapshot that is read at scrape time.
+package monitoring
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/primoPoker/server/internal/database"
+	"github.com/primoPoker/server/internal/game"
+	"github.com/primoPoker/server/internal/gcp"
+	"github.com/primoPoker/server/internal/middleware"
+	"github.com/primoPoker/server/internal/websocket"
+)
+
+const namespace = "primopoker"
+
+// Monitor holds the server's metrics in a registry of its own
+type Monitor struct {
+	registry        *prometheus.Registry
+	requestDuration *prometheus.HistogramVec
+}
+
+// New creates a monitor exporting HTTP request metrics along with the Go
+// runtime and process collectors
+func New() *Monitor {
+	m := &Monitor{
+		registry: prometheus.NewRegistry(),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Duration of HTTP requests by route, method and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requestDuration,
+	)
+	return m
+}
+
+// ObserveRequest records a finished HTTP request
+func (m *Monitor) ObserveRequest(route, method string, status int, duration time.Duration) {
+	m.requestDuration.WithLabelValues(route, method, strconv.Itoa(status)).Observe(duration.Seconds())
+}
+
+// WatchGames exports the manager's running games, seated players and
+// completed hands
+func (m *Monitor) WatchGames(manager *game.Manager) {
+	m.registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "game",
+			Name:      "active_games",
+			Help:      "Games currently running.",
+		}, func() float64 { return float64(manager.Stats().Games) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "game",
+			Name:      "seated_players",
+			Help:      "Players seated across all running games.",
+		}, func() float64 { return float64(manager.Stats().SeatedPlayers) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "game",
+			Name:      "hands_completed_total",
+			Help:      "Hands played to completion since the server started.",
+		}, func() float64 { return float64(manager.Stats().HandsCompleted) }),
+	)
+}
+
+// WatchHub exports the WebSocket hub's connections and message counters
+func (m *Monitor) WatchHub(hub *websocket.Hub) {
+	m.registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "websocket",
+			Name:      "connections",
+			Help:      "Connected WebSocket clients.",
+		}, func() float64 { return float64(hub.Stats().Connections) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "websocket",
+			Name:      "watched_games",
+			Help:      "Games with at least one connected client.",
+		}, func() float64 { return float64(hub.Stats().Games) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "websocket",
+			Name:      "messages_sent_total",
+			Help:      "Messages queued to WebSocket clients.",
+		}, func() float64 { return float64(hub.Stats().MessagesSent) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "websocket",
+			Name:      "messages_dropped_total",
+			Help:      "Messages dropped because a client's send buffer was full.",
+		}, func() float64 { return float64(hub.Stats().MessagesDropped) }),
+	)
+}
+
+// WatchRateLimiters exports how many clients' rate limiters are held in
+// memory and how many have been evicted, from stats
+func (m *Monitor) WatchRateLimiters(stats func() middleware.LimiterStats) {
+	m.registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "rate_limiters",
+			Help:      "Clients whose rate limit state is held in memory.",
+		}, func() float64 { return float64(stats().Entries) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "rate_limiters_evicted_total",
+			Help:      "Rate limiters dropped for being idle or over the entry cap.",
+		}, func() float64 { return float64(stats().Evicted) }),
+	)
+}
+
+// WatchEvents exports the counters of the publisher sending table events
+// to Pub/Sub
+func (m *Monitor) WatchEvents(publisher *gcp.EventPublisher) {
+	m.registry.MustRegister(
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "events",
+			Name:      "published_total",
+			Help:      "Table events published to Pub/Sub.",
+		}, func() float64 { return float64(publisher.Stats().Published) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "events",
+			Name:      "dropped_total",
+			Help:      "Table events dropped because the publish queue was full.",
+		}, func() float64 { return float64(publisher.Stats().Dropped) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "events",
+			Name:      "failed_total",
+			Help:      "Table events Pub/Sub did not accept.",
+		}, func() float64 { return float64(publisher.Stats().Failed) }),
+	)
+}
+
+// WatchErrors exports the counters of the reporter sending errors to
+// Error Reporting
+func (m *Monitor) WatchErrors(reporter *gcp.ErrorReporter) {
+	m.registry.MustRegister(
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "errors",
+			Name:      "reported_total",
+			Help:      "Errors and recovered panics sent to Error Reporting.",
+		}, func() float64 { return float64(reporter.Stats().Reported) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "errors",
+			Name:      "dropped_total",
+			Help:      "Error reports dropped because the report queue was full.",
+		}, func() float64 { return float64(reporter.Stats().Dropped) }),
+	)
+}
+
+// WatchDB exports the connection pool statistics of db
+func (m *Monitor) WatchDB(db *sql.DB, name string) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// WatchHealth exports the latest readiness check of db: whether the
+// database answered, how long the round trip took and the transactions
+// open on it. The check runs when the readiness probe calls it, not at
+// scrape time, so these stay unset until the first probe.
+func (m *Monitor) WatchHealth(db *database.DB) {
+	report := func(value func(database.HealthReport) float64) func() float64 {
+		return func() float64 {
+			last, ok := db.LastHealth()
+			if !ok {
+				return 0
+			}
+			return value(last)
+		}
+	}
+	m.registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "database",
+			Name:      "up",
+			Help:      "Whether the database answered the latest readiness check.",
+		}, report(func(r database.HealthReport) float64 { return boolValue(r.Status != database.HealthDown) })),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "database",
+			Name:      "degraded",
+			Help:      "Whether the latest readiness check found the database slow.",
+		}, report(func(r database.HealthReport) float64 { return boolValue(r.Status == database.HealthDegraded) })),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "database",
+			Name:      "ping_latency_seconds",
+			Help:      "Round trip of the latest readiness check's ping.",
+		}, report(func(r database.HealthReport) float64 { return r.Latency.Seconds() })),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "database",
+			Name:      "active_transactions",
+			Help:      "Transactions open on the primary at the latest readiness check.",
+		}, report(func(r database.HealthReport) float64 {
+			if r.ActiveTransactions == nil {
+				return 0
+			}
+			return float64(*r.ActiveTransactions)
+		})),
+	)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func (m *Monitor) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
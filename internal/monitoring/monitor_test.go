@@ -0,0 +1,81 @@
+package monitoring
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/internal/database"
+	"github.com/primoPoker/server/internal/game"
+	"github.com/primoPoker/server/internal/gcp"
+	"github.com/primoPoker/server/internal/middleware"
+	"github.com/primoPoker/server/internal/testutil"
+	"github.com/primoPoker/server/internal/websocket"
+)
+
+func TestScrape(t *testing.T) {
+	monitor := New()
+
+	manager := game.NewManager()
+	_, err := manager.CreateGame("table-1", "Table 1")
+	require.NoError(t, err)
+	require.NoError(t, manager.JoinGame(context.Background(), "table-1", "player-1", "alice", 10000))
+	monitor.WatchGames(manager)
+
+	monitor.WatchHub(websocket.NewHub())
+	monitor.WatchEvents(&gcp.EventPublisher{})
+	monitor.WatchRateLimiters(func() middleware.LimiterStats { return middleware.LimiterStats{Entries: 3, Evicted: 7} })
+
+	sqlDB, err := testutil.NewDB(t).DB()
+	require.NoError(t, err)
+	monitor.WatchDB(sqlDB, "primopoker")
+
+	db := database.NewReplicatedDB(testutil.NewDB(t), nil)
+	monitor.WatchHealth(db)
+	db.Check(context.Background())
+
+	router := mux.NewRouter()
+	router.Use(middleware.Instrument(monitor))
+	router.HandleFunc("/api/v1/games/{gameId}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}).Methods("GET")
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/games/abc", nil))
+
+	server := httptest.NewServer(monitor.Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	scraped := string(body)
+
+	for _, series := range []string{
+		`primopoker_http_request_duration_seconds_count{method="GET",route="/api/v1/games/{gameId}",status="404"} 1`,
+		"primopoker_game_active_games 1",
+		"primopoker_game_seated_players 1",
+		"primopoker_game_hands_completed_total 0",
+		"primopoker_websocket_connections 0",
+		"primopoker_websocket_messages_sent_total 0",
+		"primopoker_websocket_messages_dropped_total 0",
+		"primopoker_events_published_total 0",
+		"primopoker_events_dropped_total 0",
+		"primopoker_http_rate_limiters 3",
+		"primopoker_http_rate_limiters_evicted_total 7",
+		`go_sql_open_connections{db_name="primopoker"}`,
+		"primopoker_database_up 1",
+		"primopoker_database_ping_latency_seconds",
+		"primopoker_database_active_transactions 0",
+		"go_goroutines",
+	} {
+		assert.Contains(t, scraped, series)
+	}
+}
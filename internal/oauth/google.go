@@ -0,0 +1,70 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/idtoken"
+)
+
+// GoogleProvider signs users in with Google accounts
+type GoogleProvider struct {
+	config *oauth2.Config
+}
+
+// NewGoogleProvider creates a Google provider for an OAuth client
+func NewGoogleProvider(clientID, clientSecret, redirectURL string) *GoogleProvider {
+	return &GoogleProvider{
+		config: &oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  redirectURL,
+			Endpoint:     google.Endpoint,
+			Scopes:       []string{"openid", "email", "profile"},
+		},
+	}
+}
+
+// Name identifies the provider
+func (p *GoogleProvider) Name() string {
+	return "google"
+}
+
+// AuthCodeURL returns Google's consent page URL
+func (p *GoogleProvider) AuthCodeURL(state string) string {
+	return p.config.AuthCodeURL(state, oauth2.SetAuthURLParam("prompt", "select_account"))
+}
+
+// Exchange trades an authorization code for the ID token it comes with and
+// verifies that token's signature, issuer and audience
+func (p *GoogleProvider) Exchange(ctx context.Context, code string) (*Identity, error) {
+	token, err := p.config.Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrExchangeFailed, err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, fmt.Errorf("%w: missing from token response", ErrInvalidIDToken)
+	}
+
+	payload, err := idtoken.Validate(ctx, rawIDToken, p.config.ClientID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	if payload.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	}
+
+	identity := &Identity{
+		Provider: p.Name(),
+		Subject:  payload.Subject,
+	}
+	identity.Email, _ = payload.Claims["email"].(string)
+	identity.EmailVerified, _ = payload.Claims["email_verified"].(bool)
+	identity.Name, _ = payload.Claims["name"].(string)
+
+	return identity, nil
+}
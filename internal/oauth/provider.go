@@ -0,0 +1,33 @@
+// Package oauth signs users in through third-party identity providers.
+package oauth
+
+import (
+	"context"
+	"errors"
+)
+
+// Provider errors
+var (
+	ErrExchangeFailed = errors.New("oauth code exchange failed")
+	ErrInvalidIDToken = errors.New("invalid id token")
+)
+
+// Identity is what a provider vouches for about the user who signed in
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider runs the authorization code flow against one identity provider.
+// Each provider is registered under its Name, which appears in the route.
+type Provider interface {
+	// Name identifies the provider, e.g. "google"
+	Name() string
+	// AuthCodeURL returns the provider's consent page URL carrying state
+	AuthCodeURL(state string) string
+	// Exchange trades an authorization code for the verified identity
+	Exchange(ctx context.Context, code string) (*Identity, error)
+}
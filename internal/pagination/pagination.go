@@ -0,0 +1,198 @@
+// Package pagination implements cursor-based (keyset) pagination shared by
+// the list endpoints. A cursor is an opaque token encoding the sort key and
+// ID of the last item on a page; the next page starts strictly after it, so
+// rows inserted while a client is paging never shift or repeat results.
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Default and maximum page sizes
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+)
+
+// ErrInvalidCursor is returned when a cursor cannot be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks a position in an ordered list by sort key and ID
+type Cursor struct {
+	Key string `json:"k"`
+	ID  string `json:"i"`
+}
+
+// TimeCursor builds a cursor for a timestamp sort key
+func TimeCursor(key time.Time, id string) Cursor {
+	return Cursor{Key: key.UTC().Format(time.RFC3339Nano), ID: id}
+}
+
+// Int64Cursor builds a cursor for an integer sort key
+func Int64Cursor(key int64, id string) Cursor {
+	return Cursor{Key: strconv.FormatInt(key, 10), ID: id}
+}
+
+// Float64Cursor builds a cursor for a floating-point sort key, such as a
+// relevance score
+func Float64Cursor(key float64, id string) Cursor {
+	return Cursor{Key: strconv.FormatFloat(key, 'g', -1, 64), ID: id}
+}
+
+// Encode returns the opaque form of the cursor
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Time decodes a timestamp sort key
+func (c Cursor) Time() (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, c.Key)
+	if err != nil {
+		return time.Time{}, ErrInvalidCursor
+	}
+	return t, nil
+}
+
+// Int64 decodes an integer sort key
+func (c Cursor) Int64() (int64, error) {
+	n, err := strconv.ParseInt(c.Key, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	return n, nil
+}
+
+// Float64 decodes a floating-point sort key
+func (c Cursor) Float64() (float64, error) {
+	f, err := strconv.ParseFloat(c.Key, 64)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	return f, nil
+}
+
+// DecodeCursor parses an opaque cursor
+func DecodeCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// PageRequest describes which page a client asked for. Offset is only
+// honoured when no cursor is given and will be removed once clients have
+// moved to cursors.
+type PageRequest struct {
+	Limit  int
+	Cursor *Cursor
+	Offset int
+}
+
+// PageResponse is the envelope returned by paginated list endpoints
+type PageResponse[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// ParseRequest reads limit, cursor and the deprecated offset from the query string
+func ParseRequest(r *http.Request) (PageRequest, error) {
+	query := r.URL.Query()
+	req := PageRequest{Limit: DefaultLimit}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return req, errors.New("invalid limit")
+		}
+		req.Limit = n
+	}
+	if req.Limit > MaxLimit {
+		req.Limit = MaxLimit
+	}
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		c, err := DecodeCursor(cursor)
+		if err != nil {
+			return req, err
+		}
+		req.Cursor = c
+	}
+
+	if offset := query.Get("offset"); offset != "" {
+		n, err := strconv.Atoi(offset)
+		if err != nil || n < 0 {
+			return req, errors.New("invalid offset")
+		}
+		req.Offset = n
+	}
+
+	return req, nil
+}
+
+// UsesOffset reports whether the request relies on deprecated offset paging
+func (p PageRequest) UsesOffset() bool {
+	return p.Cursor == nil && p.Offset > 0
+}
+
+// Keyset describes the ordering a paginated query walks: a sort column plus
+// a unique tie-breaker so the order is total
+type Keyset struct {
+	Column     string
+	IDColumn   string
+	Descending bool
+}
+
+// Apply orders query by the keyset and restricts it to rows after the
+// request's cursor, whose sort key has already been decoded into key. One
+// row more than the limit is fetched so NewPage can tell if more remain.
+func (k Keyset) Apply(query *gorm.DB, req PageRequest, key interface{}) *gorm.DB {
+	direction, comparison := " ASC", " > "
+	if k.Descending {
+		direction, comparison = " DESC", " < "
+	}
+
+	if req.Cursor != nil {
+		query = query.Where(
+			"("+k.Column+comparison+"?) OR ("+k.Column+" = ? AND "+k.IDColumn+comparison+"?)",
+			key, key, req.Cursor.ID,
+		)
+	} else if req.Offset > 0 {
+		query = query.Offset(req.Offset)
+	}
+
+	return query.Order(k.Column + direction).Order(k.IDColumn + direction).Limit(req.Limit + 1)
+}
+
+// NewPage trims the extra row fetched by Apply and builds the response,
+// using cursorFor to derive the next cursor from the last item kept
+func NewPage[T any](items []T, req PageRequest, cursorFor func(T) Cursor) *PageResponse[T] {
+	page := &PageResponse[T]{Items: items}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+
+	if len(items) > req.Limit {
+		page.Items = items[:req.Limit]
+		page.HasMore = true
+	}
+	if page.HasMore && len(page.Items) > 0 {
+		page.NextCursor = cursorFor(page.Items[len(page.Items)-1]).Encode()
+	}
+
+	return page
+}
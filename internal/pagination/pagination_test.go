@@ -0,0 +1,176 @@
+package pagination
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type row struct {
+	ID        string `gorm:"primaryKey"`
+	Score     int64
+	StartedAt time.Time
+}
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&row{}))
+	return db
+}
+
+var (
+	scoreKeyset = Keyset{Column: "score", IDColumn: "id", Descending: true}
+	timeKeyset  = Keyset{Column: "started_at", IDColumn: "id", Descending: true}
+	base        = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+)
+
+func fetchByScore(t *testing.T, db *gorm.DB, req PageRequest) *PageResponse[row] {
+	t.Helper()
+
+	var key interface{}
+	if req.Cursor != nil {
+		score, err := req.Cursor.Int64()
+		require.NoError(t, err)
+		key = score
+	}
+
+	var rows []row
+	require.NoError(t, scoreKeyset.Apply(db, req, key).Find(&rows).Error)
+	return NewPage(rows, req, func(r row) Cursor { return Int64Cursor(r.Score, r.ID) })
+}
+
+func TestKeysetStableAcrossInserts(t *testing.T) {
+	db := newTestDB(t)
+
+	// Duplicate scores force the ID tie-breaker to matter
+	var original []string
+	for i := 0; i < 10; i++ {
+		r := row{ID: fmt.Sprintf("id-%02d", i), Score: int64(100 - (i/2)*10)}
+		require.NoError(t, db.Create(&r).Error)
+		original = append(original, r.ID)
+	}
+
+	req := PageRequest{Limit: 3}
+	page := fetchByScore(t, db, req)
+	require.Len(t, page.Items, 3)
+	require.True(t, page.HasMore)
+
+	// Rows inserted mid-pagination, both ahead of the cursor and tied with it
+	last := page.Items[len(page.Items)-1]
+	require.NoError(t, db.Create(&row{ID: "new-top", Score: 1000}).Error)
+	require.NoError(t, db.Create(&row{ID: "id-00a", Score: last.Score}).Error)
+
+	seen := map[string]bool{}
+	var order []row
+	for {
+		for _, item := range page.Items {
+			assert.False(t, seen[item.ID], "duplicate %s", item.ID)
+			seen[item.ID] = true
+			order = append(order, item)
+		}
+		if !page.HasMore {
+			break
+		}
+
+		cursor, err := DecodeCursor(page.NextCursor)
+		require.NoError(t, err)
+		page = fetchByScore(t, db, PageRequest{Limit: 3, Cursor: cursor})
+	}
+
+	for _, id := range original {
+		assert.True(t, seen[id], "missing %s", id)
+	}
+	assert.False(t, seen["new-top"], "row inserted before the cursor must not appear")
+
+	for i := 1; i < len(order); i++ {
+		prev, cur := order[i-1], order[i]
+		assert.True(t, prev.Score > cur.Score || (prev.Score == cur.Score && prev.ID > cur.ID),
+			"%s before %s breaks ordering", prev.ID, cur.ID)
+	}
+}
+
+func TestKeysetTimeCursor(t *testing.T) {
+	db := newTestDB(t)
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, db.Create(&row{ID: fmt.Sprintf("h-%d", i), StartedAt: base.Add(time.Duration(i) * time.Minute)}).Error)
+	}
+
+	var ids []string
+	req := PageRequest{Limit: 2}
+	for {
+		var key interface{}
+		if req.Cursor != nil {
+			startedAt, err := req.Cursor.Time()
+			require.NoError(t, err)
+			key = startedAt
+		}
+
+		var rows []row
+		require.NoError(t, timeKeyset.Apply(db, req, key).Find(&rows).Error)
+		page := NewPage(rows, req, func(r row) Cursor { return TimeCursor(r.StartedAt, r.ID) })
+		for _, item := range page.Items {
+			ids = append(ids, item.ID)
+		}
+		if !page.HasMore {
+			break
+		}
+
+		cursor, err := DecodeCursor(page.NextCursor)
+		require.NoError(t, err)
+		req = PageRequest{Limit: 2, Cursor: cursor}
+	}
+
+	assert.Equal(t, []string{"h-4", "h-3", "h-2", "h-1", "h-0"}, ids)
+}
+
+func TestKeysetOffsetFallback(t *testing.T) {
+	db := newTestDB(t)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, db.Create(&row{ID: fmt.Sprintf("id-%d", i), Score: int64(i)}).Error)
+	}
+
+	page := fetchByScore(t, db, PageRequest{Limit: 2, Offset: 2})
+	require.Len(t, page.Items, 2)
+	assert.Equal(t, "id-2", page.Items[0].ID)
+	assert.True(t, page.HasMore)
+	assert.NotEmpty(t, page.NextCursor)
+}
+
+func TestParseRequest(t *testing.T) {
+	req, err := ParseRequest(httptest.NewRequest("GET", "/?limit=1000", nil))
+	require.NoError(t, err)
+	assert.Equal(t, MaxLimit, req.Limit)
+	assert.Nil(t, req.Cursor)
+
+	cursor := Int64Cursor(42, "abc")
+	req, err = ParseRequest(httptest.NewRequest("GET", "/?cursor="+cursor.Encode(), nil))
+	require.NoError(t, err)
+	assert.Equal(t, DefaultLimit, req.Limit)
+	require.NotNil(t, req.Cursor)
+	assert.Equal(t, cursor, *req.Cursor)
+
+	_, err = ParseRequest(httptest.NewRequest("GET", "/?cursor=not-a-cursor", nil))
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	req, err = ParseRequest(httptest.NewRequest("GET", "/?offset=20", nil))
+	require.NoError(t, err)
+	assert.True(t, req.UsesOffset())
+}
+
+func TestNewPageEmpty(t *testing.T) {
+	page := NewPage[row](nil, PageRequest{Limit: 10}, func(r row) Cursor { return Cursor{} })
+	assert.NotNil(t, page.Items)
+	assert.False(t, page.HasMore)
+	assert.Empty(t, page.NextCursor)
+}
@@ -0,0 +1,81 @@
+//go:build ignore
+
+// gen_breached writes breached.txt from the SecLists list of the 10,000
+// most common passwords. Run it with go generate ./internal/password.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// sourceURL is the list in SecLists
+const sourceURL = "https://raw.githubusercontent.com/danielmiessler/SecLists/master/Passwords/Common-Credentials/10k-most-common.txt"
+
+// minEntries guards against writing a truncated or error page download
+const minEntries = 9000
+
+func main() {
+	source := flag.String("source", sourceURL, "URL or file to read the list from")
+	out := flag.String("out", "breached.txt", "file to write")
+	flag.Parse()
+
+	in, err := open(*source)
+	if err != nil {
+		log.Fatalf("Failed to open %s: %v", *source, err)
+	}
+	defer in.Close()
+
+	// One per line in lower case, as the rule compares them, keeping the
+	// list's order so the most common come first
+	var entries []string
+	seen := make(map[string]struct{})
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		entry := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if entry == "" {
+			continue
+		}
+		if _, ok := seen[entry]; ok {
+			continue
+		}
+		seen[entry] = struct{}{}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatalf("Failed to read %s: %v", *source, err)
+	}
+	if len(entries) < minEntries {
+		log.Fatalf("Only %d passwords in %s, expected at least %d", len(entries), *source, minEntries)
+	}
+
+	if err := os.WriteFile(*out, []byte(strings.Join(entries, "\n")+"\n"), 0o644); err != nil {
+		log.Fatalf("Failed to write %s: %v", *out, err)
+	}
+	fmt.Printf("Wrote %d passwords to %s\n", len(entries), *out)
+}
+
+// open reads source from the web if it is a URL and from disk otherwise
+func open(source string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(source, "https://") && !strings.HasPrefix(source, "http://") {
+		return os.Open(source)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(source)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.Body, nil
+}
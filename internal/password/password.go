@@ -0,0 +1,70 @@
+// Package password hashes and verifies user passwords.
+//
+// New hashes are always made with the primary algorithm. Hashes from older
+// algorithms or parameters still verify, and Verify reports when one should
+// be replaced, so accounts move to the current scheme as their owners sign in.
+package password
+
+import "errors"
+
+// Password errors
+var (
+	ErrMismatch         = errors.New("password does not match")
+	ErrUnknownAlgorithm = errors.New("unknown password hash algorithm")
+	ErrMalformedHash    = errors.New("malformed password hash")
+)
+
+// Hasher is one password hashing algorithm
+type Hasher interface {
+	// Hash returns an encoded hash of the password, including its salt
+	// and parameters
+	Hash(password string) (string, error)
+	// Verify checks a password against a hash, returning ErrMismatch if it
+	// does not match
+	Verify(password, hash string) error
+	// Recognizes checks if a hash was produced by this algorithm
+	Recognizes(hash string) bool
+	// NeedsRehash checks if a hash of this algorithm was made with
+	// parameters other than the hasher's current ones
+	NeedsRehash(hash string) bool
+}
+
+// Policy hashes with a primary algorithm and still verifies legacy ones
+type Policy struct {
+	primary Hasher
+	legacy  []Hasher
+}
+
+// NewPolicy creates a policy hashing with primary and accepting hashes of
+// primary or any of the legacy algorithms
+func NewPolicy(primary Hasher, legacy ...Hasher) *Policy {
+	return &Policy{primary: primary, legacy: legacy}
+}
+
+// Hash hashes a password with the primary algorithm
+func (p *Policy) Hash(password string) (string, error) {
+	return p.primary.Hash(password)
+}
+
+// Verify checks a password against a hash of any accepted algorithm. When it
+// matches, rehash reports whether the hash should be replaced with a fresh
+// one from Hash.
+func (p *Policy) Verify(password, hash string) (rehash bool, err error) {
+	if p.primary.Recognizes(hash) {
+		if err := p.primary.Verify(password, hash); err != nil {
+			return false, err
+		}
+		return p.primary.NeedsRehash(hash), nil
+	}
+
+	for _, hasher := range p.legacy {
+		if hasher.Recognizes(hash) {
+			if err := hasher.Verify(password, hash); err != nil {
+				return false, err
+			}
+			return true, nil
+		}
+	}
+
+	return false, ErrUnknownAlgorithm
+}
@@ -0,0 +1,116 @@
+package password
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testParams keep the tests fast; production uses DefaultArgon2Params
+var testParams = Argon2Params{Memory: 8 * 1024, Time: 1, Threads: 1}
+
+func TestArgon2idRoundTrip(t *testing.T) {
+	hasher := NewArgon2id(testParams)
+
+	hash, err := hasher.Hash("correct horse")
+	require.NoError(t, err)
+	assert.True(t, hasher.Recognizes(hash))
+	assert.Contains(t, hash, "$argon2id$v=19$m=8192,t=1,p=1$")
+
+	assert.NoError(t, hasher.Verify("correct horse", hash))
+	assert.ErrorIs(t, hasher.Verify("wrong horse", hash), ErrMismatch)
+	assert.False(t, hasher.NeedsRehash(hash))
+
+	// The same password never hashes the same way twice
+	again, err := hasher.Hash("correct horse")
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, again)
+}
+
+func TestArgon2idVerifiesWithStoredParams(t *testing.T) {
+	hash, err := NewArgon2id(testParams).Hash("correct horse")
+	require.NoError(t, err)
+
+	stronger := NewArgon2id(Argon2Params{Memory: 16 * 1024, Time: 2, Threads: 1})
+	assert.NoError(t, stronger.Verify("correct horse", hash))
+	assert.True(t, stronger.NeedsRehash(hash))
+}
+
+func TestArgon2idMalformedHash(t *testing.T) {
+	hasher := NewArgon2id(testParams)
+
+	for _, hash := range []string{
+		"",
+		"$argon2id$",
+		"$argon2id$v=19$m=8192,t=1,p=1$c2FsdA",
+		"$argon2id$v=19$m=x,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=8192,t=1,p=1$!!!$a2V5",
+	} {
+		assert.ErrorIs(t, hasher.Verify("password", hash), ErrMalformedHash, "hash %q", hash)
+	}
+}
+
+func TestPolicyMigratesBcrypt(t *testing.T) {
+	legacy := NewBcrypt(4)
+	policy := NewPolicy(NewArgon2id(testParams), legacy)
+
+	old, err := legacy.Hash("correct horse")
+	require.NoError(t, err)
+
+	rehash, err := policy.Verify("correct horse", old)
+	require.NoError(t, err)
+	assert.True(t, rehash)
+
+	_, err = policy.Verify("wrong horse", old)
+	assert.ErrorIs(t, err, ErrMismatch)
+
+	current, err := policy.Hash("correct horse")
+	require.NoError(t, err)
+	rehash, err = policy.Verify("correct horse", current)
+	require.NoError(t, err)
+	assert.False(t, rehash)
+}
+
+func TestPolicyUnknownAlgorithm(t *testing.T) {
+	policy := NewPolicy(NewArgon2id(testParams), NewBcrypt(4))
+
+	for _, hash := range []string{"", "plaintext", "$1$md5crypt"} {
+		_, err := policy.Verify("plaintext", hash)
+		assert.ErrorIs(t, err, ErrUnknownAlgorithm)
+	}
+}
+
+// BenchmarkArgon2id measures one hash at a range of parameters. Pick the
+// strongest setting that keeps a login well under 200ms on production
+// hardware, e.g.
+//
+//	go test ./internal/password -run '^$' -bench Argon2id
+func BenchmarkArgon2id(b *testing.B) {
+	for _, params := range []Argon2Params{
+		{Memory: 19 * 1024, Time: 2, Threads: 1},
+		{Memory: 46 * 1024, Time: 1, Threads: 1},
+		{Memory: 64 * 1024, Time: 3, Threads: 4},
+		{Memory: 128 * 1024, Time: 3, Threads: 4},
+	} {
+		hasher := NewArgon2id(params)
+		b.Run(fmt.Sprintf("m=%d,t=%d,p=%d", params.Memory, params.Time, params.Threads), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := hasher.Hash("correct horse battery staple"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkBcrypt is the baseline being migrated away from
+func BenchmarkBcrypt(b *testing.B) {
+	hasher := NewBcrypt(0)
+	for i := 0; i < b.N; i++ {
+		if _, err := hasher.Hash("correct horse battery staple"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
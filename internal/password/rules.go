@@ -0,0 +1,123 @@
+package password
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// breachedList holds common passwords from public breach corpora, one per
+// line in lower case. gen_breached.go generates it from the SecLists list of
+// the 10,000 most common.
+//
+//go:generate go run gen_breached.go
+//go:embed breached.txt
+var breachedList string
+
+// breached is the set of passwords in breachedList
+var breached = func() map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, line := range strings.Split(breachedList, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			set[line] = struct{}{}
+		}
+	}
+	return set
+}()
+
+// minIdentifierLength is the shortest username or email name that is
+// checked for inside a password; shorter ones match too much by accident
+const minIdentifierLength = 3
+
+// Rules is the policy a new password has to satisfy
+type Rules struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+}
+
+// Violation is one rule a password breaks
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every rule a password breaks
+type ValidationError struct {
+	Violations []Violation
+}
+
+// Error implements the error interface
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Message
+	}
+	return "password " + strings.Join(messages, ", ")
+}
+
+// Check validates a password for the account with the given username and
+// email, returning a *ValidationError listing every rule it breaks
+func (r Rules) Check(password, username, email string) error {
+	var violations []Violation
+	fail := func(rule, message string) {
+		violations = append(violations, Violation{Rule: rule, Message: message})
+	}
+
+	if len([]rune(password)) < r.MinLength {
+		fail("min_length", fmt.Sprintf("must be at least %d characters long", r.MinLength))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, c := range password {
+		switch {
+		case unicode.IsUpper(c):
+			upper = true
+		case unicode.IsLower(c):
+			lower = true
+		case unicode.IsDigit(c):
+			digit = true
+		case unicode.IsPunct(c) || unicode.IsSymbol(c) || unicode.IsSpace(c):
+			symbol = true
+		}
+	}
+	if r.RequireUpper && !upper {
+		fail("uppercase", "must contain an uppercase letter")
+	}
+	if r.RequireLower && !lower {
+		fail("lowercase", "must contain a lowercase letter")
+	}
+	if r.RequireDigit && !digit {
+		fail("digit", "must contain a digit")
+	}
+	if r.RequireSymbol && !symbol {
+		fail("symbol", "must contain a symbol")
+	}
+
+	lowered := strings.ToLower(password)
+	if containsIdentifier(lowered, username) {
+		fail("contains_username", "must not contain your username")
+	}
+	emailName, _, _ := strings.Cut(email, "@")
+	if containsIdentifier(lowered, email) || containsIdentifier(lowered, emailName) {
+		fail("contains_email", "must not contain your email address")
+	}
+
+	if _, ok := breached[lowered]; ok {
+		fail("breached", "is too common and appears in known data breaches")
+	}
+
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+// containsIdentifier checks if a lower-cased password contains an identifier
+func containsIdentifier(password, identifier string) bool {
+	identifier = strings.ToLower(strings.TrimSpace(identifier))
+	return len(identifier) >= minIdentifierLength && strings.Contains(password, identifier)
+}
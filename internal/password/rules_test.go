@@ -0,0 +1,89 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// violatedRules returns the rules a password breaks
+func violatedRules(t *testing.T, rules Rules, password, username, email string) []string {
+	t.Helper()
+
+	err := rules.Check(password, username, email)
+	if err == nil {
+		return nil
+	}
+	var invalid *ValidationError
+	require.ErrorAs(t, err, &invalid)
+
+	names := make([]string, len(invalid.Violations))
+	for i, v := range invalid.Violations {
+		names[i] = v.Rule
+	}
+	return names
+}
+
+func TestRulesMinLength(t *testing.T) {
+	rules := Rules{MinLength: 10}
+
+	assert.Equal(t, []string{"min_length"}, violatedRules(t, rules, "tide-pool", "bob", "bob@example.com"))
+	assert.Empty(t, violatedRules(t, rules, "tide-pools!", "bob", "bob@example.com"))
+	// Length counts characters, not bytes
+	assert.Equal(t, []string{"min_length"}, violatedRules(t, rules, "ñññññññññ", "bob", "bob@example.com"))
+}
+
+func TestRulesCharacterClasses(t *testing.T) {
+	rules := Rules{RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true}
+
+	assert.ElementsMatch(t, []string{"uppercase", "digit", "symbol"},
+		violatedRules(t, rules, "lowercaseonly", "bob", "bob@example.com"))
+	assert.ElementsMatch(t, []string{"lowercase"},
+		violatedRules(t, rules, "UPPER-CASE-7", "bob", "bob@example.com"))
+	assert.Empty(t, violatedRules(t, rules, "Mixed-Case-7", "bob", "bob@example.com"))
+
+	// Classes are only enforced when configured
+	assert.Empty(t, violatedRules(t, Rules{}, "lowercaseonly", "bob", "bob@example.com"))
+}
+
+func TestRulesRejectsIdentifiers(t *testing.T) {
+	rules := Rules{}
+
+	assert.Equal(t, []string{"contains_username"}, violatedRules(t, rules, "my-AliceW-pass", "alicew", "aw@example.com"))
+	assert.Equal(t, []string{"contains_email"}, violatedRules(t, rules, "x-wonder-x", "alice", "wonder@example.com"))
+	assert.Equal(t, []string{"contains_email"}, violatedRules(t, rules, "me@wonderland.example", "alice", "me@wonderland.example"))
+
+	// Very short names would match too many passwords to be useful
+	assert.Empty(t, violatedRules(t, rules, "baloney-sandwich", "al", "lo@example.com"))
+}
+
+func TestRulesRejectsBreached(t *testing.T) {
+	rules := Rules{MinLength: 8}
+
+	assert.Equal(t, []string{"breached"}, violatedRules(t, rules, "password123", "bob", "bob@example.com"))
+	assert.Equal(t, []string{"breached"}, violatedRules(t, rules, "Password123", "bob", "bob@example.com"))
+	assert.Equal(t, []string{"breached"}, violatedRules(t, rules, "qwertyuiop", "bob", "bob@example.com"))
+	assert.Empty(t, violatedRules(t, rules, "violet-harbor-lamp", "bob", "bob@example.com"))
+}
+
+func TestRulesRejectsTheWholeBreachedList(t *testing.T) {
+	entries := strings.Fields(breachedList)
+	require.NotEmpty(t, entries)
+
+	// The least common are rejected as surely as the most
+	for _, entry := range entries[len(entries)-5:] {
+		assert.Contains(t, violatedRules(t, Rules{}, entry, "bob", "bob@example.com"), "breached", entry)
+	}
+}
+
+func TestValidationErrorListsEveryRule(t *testing.T) {
+	err := Rules{MinLength: 12, RequireDigit: true}.Check("alice", "alice", "alice@example.com")
+
+	var invalid *ValidationError
+	require.ErrorAs(t, err, &invalid)
+	assert.Len(t, invalid.Violations, 4)
+	assert.Equal(t, "password must be at least 12 characters long, must contain a digit, "+
+		"must not contain your username, must not contain your email address", err.Error())
+}
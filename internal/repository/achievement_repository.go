@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/primoPoker/server/internal/models"
+)
+
+// AchievementRepository handles achievements and the players who unlocked
+// them
+type AchievementRepository struct {
+	db *gorm.DB
+}
+
+// NewAchievementRepository creates a new achievement repository
+func NewAchievementRepository(db *gorm.DB) *AchievementRepository {
+	return &AchievementRepository{db: db}
+}
+
+// Seed creates the given achievements, updating the wording of any that
+// already exist
+func (r *AchievementRepository) Seed(achievements []models.Achievement) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "code"}},
+		DoUpdates: clause.AssignmentColumns([]string{"name", "description", "badge", "sort_order", "updated_at"}),
+	}).Create(&achievements).Error
+}
+
+// List gets every achievement in display order
+func (r *AchievementRepository) List() ([]models.Achievement, error) {
+	var achievements []models.Achievement
+	err := r.db.Order("sort_order ASC").Find(&achievements).Error
+	return achievements, err
+}
+
+// ListUnlocked gets the achievements a user has unlocked, first unlocked
+// first
+func (r *AchievementRepository) ListUnlocked(userID uuid.UUID) ([]models.UserAchievement, error) {
+	var unlocked []models.UserAchievement
+	err := r.db.Preload("Achievement").
+		Where("user_id = ?", userID).
+		Order("unlocked_at ASC").
+		Find(&unlocked).Error
+	return unlocked, err
+}
+
+// Unlock records a user unlocking an achievement, reporting false without
+// error when they already had it
+func (r *AchievementRepository) Unlock(unlock *models.UserAchievement) (bool, error) {
+	result := r.db.Omit(clause.Associations).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "achievement_code"}},
+		DoNothing: true,
+	}).Create(unlock)
+	return result.RowsAffected == 1, result.Error
+}
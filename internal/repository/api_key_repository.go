@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/primoPoker/server/internal/models"
+	"gorm.io/gorm"
+)
+
+// APIKeyRepository handles API key database operations
+type APIKeyRepository struct {
+	db *gorm.DB
+}
+
+// NewAPIKeyRepository creates a new API key repository
+func NewAPIKeyRepository(db *gorm.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+// Create creates a new API key
+func (r *APIKeyRepository) Create(key *models.APIKey) error {
+	return r.db.Create(key).Error
+}
+
+// GetByHash gets an API key by the hash of the key
+func (r *APIKeyRepository) GetByHash(hash string) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.db.First(&key, "key_hash = ?", hash).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// ListByUser lists a user's unrevoked API keys, newest first
+func (r *APIKeyRepository) ListByUser(userID uuid.UUID) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := r.db.Where("user_id = ? AND revoked_at IS NULL", userID).
+		Order("created_at DESC").
+		Find(&keys).Error
+	return keys, err
+}
+
+// CountActiveByUser counts a user's unrevoked, unexpired API keys
+func (r *APIKeyRepository) CountActiveByUser(userID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.APIKey{}).
+		Where("user_id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", userID, time.Now()).
+		Count(&count).Error
+	return count, err
+}
+
+// Revoke revokes an API key belonging to a user, reporting whether one was found
+func (r *APIKeyRepository) Revoke(userID, keyID uuid.UUID) (bool, error) {
+	result := r.db.Model(&models.APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", keyID, userID).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// TouchLastUsed records that an API key was used
+func (r *APIKeyRepository) TouchLastUsed(keyID uuid.UUID, at time.Time) error {
+	return r.db.Model(&models.APIKey{}).
+		Where("id = ?", keyID).
+		Update("last_used_at", at).Error
+}
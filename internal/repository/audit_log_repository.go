@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/pagination"
+	"gorm.io/gorm"
+)
+
+// AuditLogRepository handles audit log database operations
+type AuditLogRepository struct {
+	db *gorm.DB
+}
+
+// NewAuditLogRepository creates a new audit log repository. Pass the
+// transaction making a change to record it atomically with the change.
+func NewAuditLogRepository(db *gorm.DB) *AuditLogRepository {
+	return &AuditLogRepository{db: db}
+}
+
+// Create records an audit entry
+func (r *AuditLogRepository) Create(entry *models.AuditLog) error {
+	return r.db.Create(entry).Error
+}
+
+// RecordAccess adds the request an entry's action came in on to the entry
+func (r *AuditLogRepository) RecordAccess(id uuid.UUID, access models.AuditAccess) error {
+	return r.db.Model(&models.AuditLog{}).Where("id = ?", id).
+		Select("method", "route", "route_vars", "request_body", "status", "latency_ms", "request_id").
+		Updates(&models.AuditLog{AuditAccess: access}).Error
+}
+
+// AuditLogFilter narrows the entries List returns. Zero fields do not
+// filter.
+type AuditLogFilter struct {
+	ActorID    *uuid.UUID
+	TargetType models.AuditTargetType
+	TargetID   string
+	From       time.Time
+	To         time.Time
+}
+
+// List gets a page of audit entries passing filter, most recent first
+func (r *AuditLogRepository) List(filter AuditLogFilter, page pagination.PageRequest) (*pagination.PageResponse[models.AuditLog], error) {
+	var key interface{}
+	if page.Cursor != nil {
+		createdAt, err := page.Cursor.Time()
+		if err != nil {
+			return nil, err
+		}
+		key = createdAt
+	}
+
+	query := r.db.Model(&models.AuditLog{})
+	if filter.ActorID != nil {
+		query = query.Where("actor_id = ?", *filter.ActorID)
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+
+	var entries []models.AuditLog
+	if err := auditLogKeyset.Apply(query, page, key).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	return pagination.NewPage(entries, page, func(entry models.AuditLog) pagination.Cursor {
+		return pagination.TimeCursor(entry.CreatedAt, entry.ID.String())
+	}), nil
+}
+
+// auditLogKeyset lists audit entries newest first
+var auditLogKeyset = pagination.Keyset{Column: "created_at", IDColumn: "id", Descending: true}
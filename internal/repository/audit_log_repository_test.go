@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/pagination"
+	"github.com/primoPoker/server/internal/testutil"
+)
+
+func TestListAuditLog(t *testing.T) {
+	repo := NewAuditLogRepository(testutil.NewDB(t, &models.AuditLog{}))
+	admin, moderator := uuid.New(), uuid.New()
+	start := time.Date(2026, 7, 1, 9, 0, 0, 0, time.UTC)
+
+	entries := []models.AuditLog{
+		{ActorID: &admin, Action: "close_game", TargetType: models.AuditTargetGame, TargetID: "table-1", CreatedAt: start},
+		{ActorID: &moderator, Action: "resolve_report", TargetType: models.AuditTargetReport, TargetID: uuid.NewString(), CreatedAt: start.Add(time.Hour)},
+		{ActorID: &admin, Action: "set_user_role", TargetType: models.AuditTargetUser, TargetID: moderator.String(),
+			Before: []byte(`{"role":"player"}`), After: []byte(`{"role":"moderator"}`), CreatedAt: start.Add(2 * time.Hour)},
+	}
+	for i := range entries {
+		require.NoError(t, repo.Create(&entries[i]))
+	}
+
+	actions := func(filter AuditLogFilter) []string {
+		page, err := repo.List(filter, pagination.PageRequest{Limit: 10})
+		require.NoError(t, err)
+		var got []string
+		for _, entry := range page.Items {
+			got = append(got, entry.Action)
+		}
+		return got
+	}
+
+	assert.Equal(t, []string{"set_user_role", "resolve_report", "close_game"}, actions(AuditLogFilter{}))
+	assert.Equal(t, []string{"set_user_role", "close_game"}, actions(AuditLogFilter{ActorID: &admin}))
+	assert.Equal(t, []string{"set_user_role"}, actions(AuditLogFilter{TargetType: models.AuditTargetUser, TargetID: moderator.String()}))
+	assert.Equal(t, []string{"resolve_report"}, actions(AuditLogFilter{From: start.Add(time.Minute), To: start.Add(2 * time.Hour)}))
+
+	page, err := repo.List(AuditLogFilter{TargetType: models.AuditTargetUser}, pagination.PageRequest{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.JSONEq(t, `{"role":"player"}`, string(page.Items[0].Before))
+	assert.JSONEq(t, `{"role":"moderator"}`, string(page.Items[0].After))
+}
+
+func TestResolveReportAuditsInTransaction(t *testing.T) {
+	db := testutil.NewDB(t, &models.User{}, &models.PlayerReport{}, &models.AuditLog{})
+	reports := NewReportRepository(db)
+	audit := NewAuditLogRepository(db)
+
+	subject := &models.User{ID: uuid.New(), Username: "subject", Email: "subject@example.com", PasswordHash: "x", IsActive: true}
+	require.NoError(t, db.Create(subject).Error)
+	report := &models.PlayerReport{ReporterID: uuid.New(), SubjectID: subject.ID, GameID: "table-1", Category: models.ReportCategoryCollusion}
+	require.NoError(t, reports.Create(report))
+
+	// An entry that cannot be written undoes the ban and the resolution
+	invalid := &models.AuditLog{Action: "resolve_report", TargetID: report.ID.String()}
+	require.NoError(t, db.Exec(`CREATE TRIGGER refuse_untargeted BEFORE INSERT ON audit_logs
+		WHEN NEW.target_type = '' BEGIN SELECT RAISE(ABORT, 'audit entry has no target type'); END`).Error)
+	_, err := reports.Resolve(report.ID, models.ReportOutcomeBan, "colluding", "mod", invalid)
+	require.Error(t, err)
+
+	stored, err := reports.GetByID(report.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.ReportStatusOpen, stored.Status)
+	var banned models.User
+	require.NoError(t, db.First(&banned, "id = ?", subject.ID).Error)
+	assert.False(t, banned.IsBanned)
+
+	entry := &models.AuditLog{Action: "resolve_report", TargetType: models.AuditTargetReport, TargetID: report.ID.String()}
+	_, err = reports.Resolve(report.ID, models.ReportOutcomeBan, "colluding", "mod", entry)
+	require.NoError(t, err)
+
+	page, err := audit.List(AuditLogFilter{TargetType: models.AuditTargetReport, TargetID: report.ID.String()}, pagination.PageRequest{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, entry.ID, page.Items[0].ID)
+}
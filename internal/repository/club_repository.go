@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/primoPoker/server/internal/models"
+)
+
+// ClubRepository handles club and club membership database operations
+type ClubRepository struct {
+	db *gorm.DB
+}
+
+// NewClubRepository creates a new club repository
+func NewClubRepository(db *gorm.DB) *ClubRepository {
+	return &ClubRepository{db: db}
+}
+
+// Create creates a club with a new join code, making its owner its first
+// member in the same transaction
+func (r *ClubRepository) Create(club *models.Club) error {
+	code, err := generateJoinCode()
+	if err != nil {
+		return err
+	}
+	club.JoinCode = code
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Members").Create(club).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.ClubMember{
+			ClubID:   club.ID,
+			UserID:   club.OwnerID,
+			Role:     models.ClubRoleOwner,
+			JoinedAt: club.CreatedAt,
+		}).Error
+	})
+}
+
+// GetByID gets a club by ID
+func (r *ClubRepository) GetByID(id uuid.UUID) (*models.Club, error) {
+	var club models.Club
+	if err := r.db.First(&club, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &club, nil
+}
+
+// ListForUser gets the clubs a user belongs to, oldest first
+func (r *ClubRepository) ListForUser(userID uuid.UUID) ([]models.Club, error) {
+	var clubs []models.Club
+	err := r.db.Joins("JOIN club_members ON club_members.club_id = clubs.id").
+		Where("club_members.user_id = ?", userID).
+		Order("clubs.created_at ASC").
+		Find(&clubs).Error
+	return clubs, err
+}
+
+// ClubIDs gets the IDs of the clubs a user belongs to
+func (r *ClubRepository) ClubIDs(userID uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.db.Model(&models.ClubMember{}).
+		Joins("JOIN clubs ON clubs.id = club_members.club_id AND clubs.deleted_at IS NULL").
+		Where("club_members.user_id = ?", userID).
+		Pluck("club_members.club_id", &ids).Error
+	return ids, err
+}
+
+// GetMember gets a user's membership of a club, failing with
+// ErrNotClubMember if they do not belong to it
+func (r *ClubRepository) GetMember(clubID, userID uuid.UUID) (*models.ClubMember, error) {
+	var member models.ClubMember
+	err := r.db.First(&member, "club_id = ? AND user_id = ?", clubID, userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotClubMember
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// IsMember reports whether a user belongs to a club that still exists
+func (r *ClubRepository) IsMember(clubID, userID uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.ClubMember{}).
+		Joins("JOIN clubs ON clubs.id = club_members.club_id AND clubs.deleted_at IS NULL").
+		Where("club_members.club_id = ? AND club_members.user_id = ?", clubID, userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ListMembers gets a club's members with their users, in the order they
+// joined
+func (r *ClubRepository) ListMembers(clubID uuid.UUID) ([]models.ClubMember, error) {
+	var members []models.ClubMember
+	err := r.db.Preload("User").
+		Where("club_id = ?", clubID).
+		Order("joined_at ASC").
+		Find(&members).Error
+	return members, err
+}
+
+// Join adds a user to the club with a join code
+func (r *ClubRepository) Join(joinCode string, userID uuid.UUID) (*models.Club, error) {
+	var club models.Club
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&club, "join_code = ?", joinCode).Error; err != nil {
+			return err
+		}
+
+		var existing int64
+		if err := tx.Model(&models.ClubMember{}).
+			Where("club_id = ? AND user_id = ?", club.ID, userID).
+			Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return ErrAlreadyClubMember
+		}
+
+		return tx.Create(&models.ClubMember{
+			ClubID:   club.ID,
+			UserID:   userID,
+			Role:     models.ClubRoleMember,
+			JoinedAt: time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &club, nil
+}
+
+// Leave removes a user from a club. The owner cannot leave their club.
+func (r *ClubRepository) Leave(clubID, userID uuid.UUID) error {
+	member, err := r.GetMember(clubID, userID)
+	if err != nil {
+		return err
+	}
+	if member.Role == models.ClubRoleOwner {
+		return ErrClubOwnerLeaving
+	}
+	return r.db.Delete(member).Error
+}
+
+// ClubLeaderboardEntry is a member's results at their club's tables
+type ClubLeaderboardEntry struct {
+	UserID      uuid.UUID `json:"user_id"`
+	Username    string    `json:"username"`
+	HandsPlayed int       `json:"hands_played"`
+	HandsWon    int       `json:"hands_won"`
+	NetResult   int64     `json:"net_result"`
+}
+
+// Leaderboard ranks a club's members by their net result over the hands
+// they played at the club's tables since the given time, if not zero.
+// Hands played by former members, and against bots, are left out.
+func (r *ClubRepository) Leaderboard(clubID uuid.UUID, since time.Time, limit int) ([]ClubLeaderboardEntry, error) {
+	query := r.db.Table("hand_histories").
+		Select(`hand_histories.user_id, users.username, COUNT(*) AS hands_played,
+			SUM(CASE WHEN hand_histories.is_winner THEN 1 ELSE 0 END) AS hands_won,
+			SUM(hand_histories.net_result) AS net_result`).
+		Joins("JOIN games ON games.id = hand_histories.game_id").
+		Joins("JOIN club_members ON club_members.club_id = games.club_id AND club_members.user_id = hand_histories.user_id").
+		Joins("JOIN users ON users.id = hand_histories.user_id").
+		Where("games.club_id = ? AND hand_histories.deleted_at IS NULL AND hand_histories.against_bots = ?", clubID, false)
+	if !since.IsZero() {
+		query = query.Where("hand_histories.started_at >= ?", since)
+	}
+
+	var entries []ClubLeaderboardEntry
+	err := query.Group("hand_histories.user_id, users.username").
+		Order("net_result DESC, hands_played DESC, hand_histories.user_id ASC").
+		Limit(limit).
+		Scan(&entries).Error
+	return entries, err
+}
+
+// generateJoinCode returns a random code players join a club with
+func generateJoinCode() (string, error) {
+	buf := make([]byte, 5)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/testutil"
+)
+
+func TestClubMembership(t *testing.T) {
+	repo := NewClubRepository(testutil.NewDB(t, &models.User{}, &models.Club{}, &models.ClubMember{}))
+	owner, player := uuid.New(), uuid.New()
+
+	club := &models.Club{Name: "Home Game", OwnerID: owner}
+	require.NoError(t, repo.Create(club))
+	assert.Len(t, club.JoinCode, 8)
+
+	joined, err := repo.Join(club.JoinCode, player)
+	require.NoError(t, err)
+	assert.Equal(t, club.ID, joined.ID)
+	_, err = repo.Join(club.JoinCode, player)
+	assert.ErrorIs(t, err, ErrAlreadyClubMember)
+
+	members, err := repo.ListMembers(club.ID)
+	require.NoError(t, err)
+	require.Len(t, members, 2)
+	assert.Equal(t, models.ClubRoleOwner, members[0].Role)
+	assert.Equal(t, models.ClubRoleMember, members[1].Role)
+
+	ids, err := repo.ClubIDs(player)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{club.ID}, ids)
+
+	assert.ErrorIs(t, repo.Leave(club.ID, owner), ErrClubOwnerLeaving)
+	require.NoError(t, repo.Leave(club.ID, player))
+	assert.ErrorIs(t, repo.Leave(club.ID, player), ErrNotClubMember)
+
+	member, err := repo.IsMember(club.ID, player)
+	require.NoError(t, err)
+	assert.False(t, member)
+}
+
+func TestClubLeaderboard(t *testing.T) {
+	db := testutil.NewDB(t, &models.User{}, &models.Game{}, &models.HandHistory{}, &models.Club{}, &models.ClubMember{})
+	repo := NewClubRepository(db)
+
+	users := make([]models.User, 3)
+	for i, name := range []string{"alice", "bob", "carol"} {
+		users[i] = models.User{ID: uuid.New(), Username: name, Email: name + "@example.com", PasswordHash: "x"}
+		require.NoError(t, db.Create(&users[i]).Error)
+	}
+	alice, bob, carol := users[0].ID, users[1].ID, users[2].ID
+
+	club := &models.Club{Name: "Home Game", OwnerID: alice}
+	require.NoError(t, repo.Create(club))
+	_, err := repo.Join(club.JoinCode, bob)
+	require.NoError(t, err)
+
+	clubTable := models.Game{ID: uuid.New(), Name: "Club", SmallBlind: 1, BigBlind: 2, BuyIn: 200, ClubID: &club.ID}
+	openTable := models.Game{ID: uuid.New(), Name: "Open", SmallBlind: 1, BigBlind: 2, BuyIn: 200}
+	require.NoError(t, db.Create(&clubTable).Error)
+	require.NoError(t, db.Create(&openTable).Error)
+
+	start := time.Date(2026, 8, 1, 20, 0, 0, 0, time.UTC)
+	hand := 0
+	play := func(table models.Game, userID uuid.UUID, net int64, at time.Time) {
+		hand++
+		require.NoError(t, db.Create(&models.HandHistory{
+			GameID: table.ID, UserID: userID, HandNumber: hand, NetResult: net, IsWinner: net > 0,
+			SmallBlind: 1, BigBlind: 2, StartedAt: at, FinishedAt: at,
+		}).Error)
+	}
+	play(clubTable, alice, 40, start)
+	play(clubTable, alice, -10, start.Add(time.Hour))
+	play(clubTable, bob, 50, start.Add(time.Hour))
+	play(clubTable, carol, 500, start)  // Not a member
+	play(openTable, alice, 1000, start) // Not at a club table
+
+	entries, err := repo.Leaderboard(club.ID, time.Time{}, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []ClubLeaderboardEntry{
+		{UserID: bob, Username: "bob", HandsPlayed: 1, HandsWon: 1, NetResult: 50},
+		{UserID: alice, Username: "alice", HandsPlayed: 2, HandsWon: 1, NetResult: 30},
+	}, entries)
+
+	entries, err = repo.Leaderboard(club.ID, start.Add(time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, int64(-10), entries[1].NetResult, "earlier hands are left out")
+}
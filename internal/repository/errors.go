@@ -0,0 +1,37 @@
+package repository
+
+import "errors"
+
+// Tournament registration errors
+var (
+	ErrRegistrationClosed = errors.New("tournament registration is closed")
+	ErrTournamentFull     = errors.New("tournament is full")
+	ErrAlreadyRegistered  = errors.New("already registered for tournament")
+	ErrNotRegistered      = errors.New("not registered for tournament")
+	ErrTournamentStarted  = errors.New("tournament has already started")
+	ErrTournamentNotDue   = errors.New("tournament is not due to start")
+)
+
+// Chip balance errors
+var (
+	ErrInsufficientBalance = errors.New("insufficient chip balance")
+	ErrInvalidChipAmount   = errors.New("chip amount must be positive")
+)
+
+// Player report errors
+var (
+	ErrReportResolved = errors.New("report has already been resolved")
+)
+
+// Club membership errors
+var (
+	ErrAlreadyClubMember = errors.New("already a member of this club")
+	ErrNotClubMember     = errors.New("not a member of this club")
+	ErrClubOwnerLeaving  = errors.New("the club's owner cannot leave it")
+)
+
+// Leaderboard errors
+var (
+	ErrUnknownLeaderboard       = errors.New("unknown leaderboard")
+	ErrUnknownLeaderboardPeriod = errors.New("leaderboard period must be day, week, month or all_time")
+)
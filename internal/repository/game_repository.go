@@ -5,7 +5,9 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/pagination"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // GameRepository handles game database operations
@@ -23,6 +25,11 @@ func (r *GameRepository) Create(game *models.Game) error {
 	return r.db.Create(game).Error
 }
 
+// EnsureExists creates a game unless one with its ID is already stored
+func (r *GameRepository) EnsureExists(game *models.Game) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(game).Error
+}
+
 // GetByID gets a game by ID with participations
 func (r *GameRepository) GetByID(id uuid.UUID) (*models.Game, error) {
 	var game models.Game
@@ -43,10 +50,10 @@ func (r *GameRepository) Delete(id uuid.UUID) error {
 	return r.db.Delete(&models.Game{}, id).Error
 }
 
-// GetActiveGames gets all active games
+// GetActiveGames gets all active games with their players
 func (r *GameRepository) GetActiveGames() ([]models.Game, error) {
 	var games []models.Game
-	err := r.db.Preload("Participations").Where("status IN ?", []models.GameStatus{
+	err := r.db.Preload("Participations").Preload("Participations.User").Where("status IN ?", []models.GameStatus{
 		models.GameStatusWaiting,
 		models.GameStatusActive,
 	}).Find(&games).Error
@@ -63,12 +70,20 @@ func (r *GameRepository) GetUserGames(userID uuid.UUID) ([]models.Game, error) {
 	return games, err
 }
 
-// GetGameHistory gets finished games with optional filters
-func (r *GameRepository) GetGameHistory(limit, offset int, userID *uuid.UUID) ([]models.Game, error) {
-	query := r.db.Where("status = ?", models.GameStatusFinished).
+// GetGameHistory gets a page of finished games, most recently finished first, optionally limited to one player's games
+func (r *GameRepository) GetGameHistory(page pagination.PageRequest, userID *uuid.UUID) (*pagination.PageResponse[models.Game], error) {
+	var key interface{}
+	if page.Cursor != nil {
+		finishedAt, err := page.Cursor.Time()
+		if err != nil {
+			return nil, err
+		}
+		key = finishedAt
+	}
+
+	query := r.db.Where("games.status = ? AND games.finished_at IS NOT NULL", models.GameStatusFinished).
 		Preload("Participations").
-		Preload("Participations.User").
-		Order("finished_at DESC")
+		Preload("Participations.User")
 
 	if userID != nil {
 		query = query.Joins("JOIN game_participations ON game_participations.game_id = games.id").
@@ -76,10 +91,19 @@ func (r *GameRepository) GetGameHistory(limit, offset int, userID *uuid.UUID) ([
 	}
 
 	var games []models.Game
-	err := query.Limit(limit).Offset(offset).Find(&games).Error
-	return games, err
+	err := gameHistoryKeyset.Apply(query, page, key).Find(&games).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return pagination.NewPage(games, page, func(game models.Game) pagination.Cursor {
+		return pagination.TimeCursor(*game.FinishedAt, game.ID.String())
+	}), nil
 }
 
+// gameHistoryKeyset orders finished games most recent first
+var gameHistoryKeyset = pagination.Keyset{Column: "games.finished_at", IDColumn: "games.id", Descending: true}
+
 // JoinGame adds a user to a game
 func (r *GameRepository) JoinGame(gameID, userID uuid.UUID, buyInAmount int64, seatPosition int) (*models.GameParticipation, error) {
 	participation := &models.GameParticipation{
@@ -99,6 +123,17 @@ func (r *GameRepository) JoinGame(gameID, userID uuid.UUID, buyInAmount int64, s
 	return participation, nil
 }
 
+// EnsureParticipation stores a user's participation in a game unless they
+// already have an active one
+func (r *GameRepository) EnsureParticipation(participation *models.GameParticipation) error {
+	return r.EnsureParticipationWithTransaction(r.db, participation)
+}
+
+// EnsureParticipationWithTransaction is EnsureParticipation run in tx
+func (r *GameRepository) EnsureParticipationWithTransaction(tx *gorm.DB, participation *models.GameParticipation) error {
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(participation).Error
+}
+
 // LeaveGame marks a user as inactive in a game
 func (r *GameRepository) LeaveGame(gameID, userID uuid.UUID) error {
 	now := time.Now()
@@ -120,7 +155,7 @@ func (r *GameRepository) UpdateGameStatus(gameID uuid.UUID, status models.GameSt
 	if status == models.GameStatusActive {
 		now := time.Now()
 		updates["started_at"] = &now
-	} else if status == models.GameStatusFinished {
+	} else if status == models.GameStatusFinished || status == models.GameStatusAbandoned {
 		now := time.Now()
 		updates["finished_at"] = &now
 	}
@@ -128,13 +163,63 @@ func (r *GameRepository) UpdateGameStatus(gameID uuid.UUID, status models.GameSt
 	return r.db.Model(&models.Game{}).Where("id = ?", gameID).Updates(updates).Error
 }
 
+// UpdateGameState updates a game's state columns, e.g. between hands
+func (r *GameRepository) UpdateGameState(gameID uuid.UUID, state map[string]interface{}) error {
+	return r.UpdateGameStateWithTransaction(r.db, gameID, state)
+}
+
+// UpdateGameStateWithTransaction is UpdateGameState run in tx
+func (r *GameRepository) UpdateGameStateWithTransaction(tx *gorm.DB, gameID uuid.UUID, state map[string]interface{}) error {
+	state["updated_at"] = time.Now()
+	return tx.Model(&models.Game{}).Where("id = ?", gameID).Updates(state).Error
+}
+
+// LeaveAll marks every player still active in a game as having left
+func (r *GameRepository) LeaveAll(gameID uuid.UUID) error {
+	now := time.Now()
+	return r.db.Model(&models.GameParticipation{}).
+		Where("game_id = ? AND is_active = ?", gameID, true).
+		Updates(map[string]interface{}{
+			"is_active": false,
+			"left_at":   &now,
+		}).Error
+}
+
 // UpdateParticipationStats updates player statistics for a game
 func (r *GameRepository) UpdateParticipationStats(gameID, userID uuid.UUID, stats map[string]interface{}) error {
-	return r.db.Model(&models.GameParticipation{}).
-		Where("game_id = ? AND user_id = ?", gameID, userID).
+	return r.UpdateParticipationStatsWithTransaction(r.db, gameID, userID, stats)
+}
+
+// UpdateParticipationStatsWithTransaction updates the statistics of a
+// player's active participation in a game within a transaction
+func (r *GameRepository) UpdateParticipationStatsWithTransaction(tx *gorm.DB, gameID, userID uuid.UUID, stats map[string]interface{}) error {
+	return tx.Model(&models.GameParticipation{}).
+		Where("game_id = ? AND user_id = ? AND is_active = ?", gameID, userID, true).
 		Updates(stats).Error
 }
 
+// UpdateLastParticipation updates the statistics of a user's most recent
+// participation in a game, whether or not they are still seated. It reports
+// false if the user has never joined the game.
+func (r *GameRepository) UpdateLastParticipation(gameID, userID uuid.UUID, stats map[string]interface{}) (bool, error) {
+	return r.UpdateLastParticipationWithTransaction(r.db, gameID, userID, stats)
+}
+
+// UpdateLastParticipationWithTransaction is UpdateLastParticipation run in
+// tx
+func (r *GameRepository) UpdateLastParticipationWithTransaction(tx *gorm.DB, gameID, userID uuid.UUID, stats map[string]interface{}) (bool, error) {
+	last := tx.Model(&models.GameParticipation{}).
+		Select("id").
+		Where("game_id = ? AND user_id = ?", gameID, userID).
+		Order("joined_at DESC").
+		Limit(1)
+	result := tx.Model(&models.GameParticipation{}).Where("id = (?)", last).Updates(stats)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
 // GetParticipation gets a specific game participation
 func (r *GameRepository) GetParticipation(gameID, userID uuid.UUID) (*models.GameParticipation, error) {
 	var participation models.GameParticipation
@@ -145,26 +230,96 @@ func (r *GameRepository) GetParticipation(gameID, userID uuid.UUID) (*models.Gam
 	return &participation, nil
 }
 
-// GetAvailableGames gets games that can be joined
-func (r *GameRepository) GetAvailableGames(limit int) ([]models.Game, error) {
-	var games []models.Game
-	err := r.db.Where("status = ?", models.GameStatusWaiting).
-		Preload("Participations").
-		Order("created_at DESC").
-		Limit(limit).
-		Find(&games).Error
+// LobbyGame is a waiting game as the lobby lists it, without its
+// participations
+type LobbyGame struct {
+	ID         uuid.UUID       `json:"id"`
+	Name       string          `json:"name"`
+	GameType   models.GameType `json:"game_type"`
+	SmallBlind int64           `json:"small_blind"`
+	BigBlind   int64           `json:"big_blind"`
+	BuyIn      int64           `json:"buy_in"`
+	MinBuyIn   int64           `json:"min_buy_in"`
+	MaxBuyIn   int64           `json:"max_buy_in"`
+	MaxPlayers int             `json:"max_players"`
+	Players    int             `json:"players"`
+	IsPrivate  bool            `json:"is_private"`
+	ClubID     *uuid.UUID      `json:"club_id,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
 
-	// Filter games that have space
-	var availableGames []models.Game
-	for _, game := range games {
-		if len(game.Participations) < game.MaxPlayers {
-			availableGames = append(availableGames, game)
+// LobbyFilter narrows the games GetAvailableGames lists. Zero fields do not
+// filter, except that clubs' tables are only listed for Clubs.
+type LobbyFilter struct {
+	GameType    models.GameType
+	MinBigBlind int64
+	MaxBigBlind int64
+	PublicOnly  bool
+
+	// Clubs are the clubs whose tables may be listed; other clubs' tables
+	// never are. ClubsOnly leaves out tables open to everyone.
+	Clubs     []uuid.UUID
+	ClubsOnly bool
+}
+
+// GetAvailableGames gets a page of waiting games with a seat free, newest
+// first. Seats are counted in the database, so participations are never
+// loaded.
+func (r *GameRepository) GetAvailableGames(filter LobbyFilter, page pagination.PageRequest) (*pagination.PageResponse[LobbyGame], error) {
+	var key interface{}
+	if page.Cursor != nil {
+		createdAt, err := page.Cursor.Time()
+		if err != nil {
+			return nil, err
 		}
+		key = createdAt
+	}
+
+	query := r.db.Model(&models.Game{}).
+		Select(`games.id, games.name, games.game_type, games.small_blind, games.big_blind,
+			games.buy_in, games.min_buy_in, games.max_buy_in, games.max_players,
+			COUNT(game_participations.id) AS players, games.is_private, games.club_id, games.created_at`).
+		Joins("LEFT JOIN game_participations ON game_participations.game_id = games.id AND game_participations.deleted_at IS NULL").
+		Where("games.status = ?", models.GameStatusWaiting).
+		Group("games.id").
+		Having("COUNT(game_participations.id) < games.max_players")
+
+	if filter.GameType != "" {
+		query = query.Where("games.game_type = ?", filter.GameType)
+	}
+	if filter.MinBigBlind > 0 {
+		query = query.Where("games.big_blind >= ?", filter.MinBigBlind)
+	}
+	if filter.MaxBigBlind > 0 {
+		query = query.Where("games.big_blind <= ?", filter.MaxBigBlind)
+	}
+	if filter.PublicOnly {
+		query = query.Where("games.is_private = ?", false)
+	}
+	switch {
+	case filter.ClubsOnly && len(filter.Clubs) == 0:
+		query = query.Where("1 = 0")
+	case filter.ClubsOnly:
+		query = query.Where("games.club_id IN ?", filter.Clubs)
+	case len(filter.Clubs) > 0:
+		query = query.Where("(games.club_id IS NULL OR games.club_id IN ?)", filter.Clubs)
+	default:
+		query = query.Where("games.club_id IS NULL")
+	}
+
+	var games []LobbyGame
+	if err := lobbyKeyset.Apply(query, page, key).Scan(&games).Error; err != nil {
+		return nil, err
 	}
 
-	return availableGames, err
+	return pagination.NewPage(games, page, func(game LobbyGame) pagination.Cursor {
+		return pagination.TimeCursor(game.CreatedAt, game.ID.String())
+	}), nil
 }
 
+// lobbyKeyset orders waiting games newest first
+var lobbyKeyset = pagination.Keyset{Column: "games.created_at", IDColumn: "games.id", Descending: true}
+
 // SetGameWinner sets the winner of a game
 func (r *GameRepository) SetGameWinner(gameID, winnerID uuid.UUID) error {
 	return r.db.Model(&models.Game{}).Where("id = ?", gameID).Updates(map[string]interface{}{
@@ -254,6 +409,11 @@ func (r *GameRepository) CreateWithTransaction(tx *gorm.DB, game *models.Game) e
 	return tx.Create(game).Error
 }
 
+// EnsureExistsWithTransaction is EnsureExists run in tx
+func (r *GameRepository) EnsureExistsWithTransaction(tx *gorm.DB, game *models.Game) error {
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(game).Error
+}
+
 // UpdateWithTransaction updates a game within a transaction
 func (r *GameRepository) UpdateWithTransaction(tx *gorm.DB, game *models.Game) error {
 	return tx.Save(game).Error
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/pagination"
+	"github.com/primoPoker/server/internal/testutil"
+)
+
+// lobbyClub and otherClub have a table each in lobbyFixture
+var (
+	lobbyClub = uuid.MustParse("7a0c6a52-2f4e-4d0b-9c35-7e4d1b3e9a01")
+	otherClub = uuid.MustParse("7a0c6a52-2f4e-4d0b-9c35-7e4d1b3e9a02")
+)
+
+// lobbyFixture stores a spread of games and returns them with each one's
+// participations, as the lobby filtered them before it was done in SQL
+func lobbyFixture(t *testing.T, db *gorm.DB) []models.Game {
+	t.Helper()
+
+	created := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	game := func(name string, status models.GameStatus, gameType models.GameType, bigBlind int64, maxPlayers, seated int, private bool) models.Game {
+		created = created.Add(time.Minute)
+		g := models.Game{
+			ID: uuid.New(), Name: name, Status: status, GameType: gameType, MaxPlayers: maxPlayers, MinPlayers: 2,
+			SmallBlind: bigBlind / 2, BigBlind: bigBlind, BuyIn: bigBlind * 100, IsPrivate: private, CreatedAt: created,
+		}
+		for seat := 0; seat < seated; seat++ {
+			g.Participations = append(g.Participations, models.GameParticipation{
+				ID: uuid.New(), UserID: uuid.New(), SeatPosition: seat, BuyInAmount: g.BuyIn, CurrentChips: g.BuyIn, IsActive: true,
+			})
+		}
+		return g
+	}
+
+	games := []models.Game{
+		game("empty", models.GameStatusWaiting, models.GameTypeTexasHoldem, 20, 6, 0, false),
+		game("one seat left", models.GameStatusWaiting, models.GameTypeTexasHoldem, 20, 6, 5, false),
+		game("full", models.GameStatusWaiting, models.GameTypeTexasHoldem, 20, 2, 2, false),
+		game("omaha", models.GameStatusWaiting, models.GameTypeOmaha, 50, 9, 3, false),
+		game("high stakes", models.GameStatusWaiting, models.GameTypeTexasHoldem, 1000, 6, 1, false),
+		game("private", models.GameStatusWaiting, models.GameTypeTexasHoldem, 20, 6, 1, true),
+		game("running", models.GameStatusActive, models.GameTypeTexasHoldem, 20, 6, 3, false),
+		game("deleted", models.GameStatusWaiting, models.GameTypeTexasHoldem, 20, 6, 0, false),
+		game("seat freed", models.GameStatusWaiting, models.GameTypeTexasHoldem, 20, 2, 2, false),
+		game("club", models.GameStatusWaiting, models.GameTypeTexasHoldem, 20, 6, 1, false),
+		game("other club", models.GameStatusWaiting, models.GameTypeTexasHoldem, 20, 6, 1, false),
+	}
+	games[9].ClubID, games[10].ClubID = &lobbyClub, &otherClub
+	for i := range games {
+		require.NoError(t, db.Create(&games[i]).Error)
+	}
+	require.NoError(t, db.Delete(&games[7]).Error)
+	require.NoError(t, db.Delete(&games[8].Participations[1]).Error)
+
+	// Reload as the old lobby query did
+	var loaded []models.Game
+	require.NoError(t, db.Where("status = ?", models.GameStatusWaiting).Preload("Participations").Order("created_at DESC").Find(&loaded).Error)
+	return loaded
+}
+
+// availableInMemory is the lobby filter GetAvailableGames replaced
+func availableInMemory(games []models.Game, filter LobbyFilter) []uuid.UUID {
+	var ids []uuid.UUID
+	for _, game := range games {
+		if len(game.Participations) >= game.MaxPlayers ||
+			(filter.GameType != "" && game.GameType != filter.GameType) ||
+			(filter.MinBigBlind > 0 && game.BigBlind < filter.MinBigBlind) ||
+			(filter.MaxBigBlind > 0 && game.BigBlind > filter.MaxBigBlind) ||
+			(filter.PublicOnly && game.IsPrivate) ||
+			!listedForClubs(game, filter) {
+			continue
+		}
+		ids = append(ids, game.ID)
+	}
+	return ids
+}
+
+func TestGetAvailableGames(t *testing.T) {
+	for driver, open := range map[string]func(testing.TB, ...interface{}) *gorm.DB{
+		"sqlite":   testutil.NewDB,
+		"postgres": testutil.NewPostgresDB,
+	} {
+		t.Run(driver, func(t *testing.T) {
+			db := open(t, &models.User{}, &models.Game{}, &models.GameParticipation{})
+			games := lobbyFixture(t, db)
+			repo := NewGameRepository(db)
+
+			for name, filter := range map[string]LobbyFilter{
+				"all":         {},
+				"game type":   {GameType: models.GameTypeOmaha},
+				"stakes":      {MinBigBlind: 20, MaxBigBlind: 50},
+				"public only": {PublicOnly: true},
+				"with clubs":  {Clubs: []uuid.UUID{lobbyClub}},
+				"clubs only":  {Clubs: []uuid.UUID{lobbyClub}, ClubsOnly: true},
+				"no clubs":    {ClubsOnly: true},
+			} {
+				page, err := repo.GetAvailableGames(filter, pagination.PageRequest{Limit: 50})
+				require.NoError(t, err, name)
+
+				var ids []uuid.UUID
+				for _, game := range page.Items {
+					ids = append(ids, game.ID)
+				}
+				assert.Equal(t, availableInMemory(games, filter), ids, name)
+				assert.False(t, page.HasMore, name)
+			}
+
+			page, err := repo.GetAvailableGames(LobbyFilter{}, pagination.PageRequest{Limit: 50})
+			require.NoError(t, err)
+			require.NotEmpty(t, page.Items)
+			freed := page.Items[0]
+			assert.Equal(t, "seat freed", freed.Name)
+			assert.Equal(t, 1, freed.Players)
+			assert.Equal(t, 2, freed.MaxPlayers)
+		})
+	}
+}
+
+func TestGetAvailableGamesPages(t *testing.T) {
+	db := testutil.NewDB(t, &models.User{}, &models.Game{}, &models.GameParticipation{})
+	want := availableInMemory(lobbyFixture(t, db), LobbyFilter{})
+	repo := NewGameRepository(db)
+
+	var got []uuid.UUID
+	page := pagination.PageRequest{Limit: 2}
+	for {
+		result, err := repo.GetAvailableGames(LobbyFilter{}, page)
+		require.NoError(t, err)
+		for _, game := range result.Items {
+			got = append(got, game.ID)
+		}
+		if !result.HasMore {
+			break
+		}
+		page.Cursor, err = pagination.DecodeCursor(result.NextCursor)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, want, got)
+}
+
+// listedForClubs reports whether a game's club lets the lobby list it
+func listedForClubs(game models.Game, filter LobbyFilter) bool {
+	if game.ClubID == nil {
+		return !filter.ClubsOnly
+	}
+	for _, club := range filter.Clubs {
+		if club == *game.ClubID {
+			return true
+		}
+	}
+	return false
+}
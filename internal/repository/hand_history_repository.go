@@ -5,7 +5,9 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/pagination"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // HandHistoryRepository handles hand history database operations
@@ -23,33 +25,96 @@ func (r *HandHistoryRepository) Create(handHistory *models.HandHistory) error {
 	return r.db.Create(handHistory).Error
 }
 
+// CreateWith creates a hand history record and runs fn in the same
+// transaction, keeping neither if fn fails
+func (r *HandHistoryRepository) CreateWith(handHistory *models.HandHistory, fn func(tx *gorm.DB) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(handHistory).Error; err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
+
+// GetPlayerIDs gets the ID of every user with hand history
+func (r *HandHistoryRepository) GetPlayerIDs() ([]uuid.UUID, error) {
+	var userIDs []uuid.UUID
+	err := r.db.Model(&models.HandHistory{}).Distinct().Pluck("user_id", &userIDs).Error
+	return userIDs, err
+}
+
+// GetPlayerIDsBetween gets the ID of every user with a hand started in
+// [from, to)
+func (r *HandHistoryRepository) GetPlayerIDsBetween(from, to time.Time) ([]uuid.UUID, error) {
+	var userIDs []uuid.UUID
+	err := r.db.Model(&models.HandHistory{}).
+		Where("started_at >= ? AND started_at < ?", from, to).
+		Distinct().
+		Pluck("user_id", &userIDs).Error
+	return userIDs, err
+}
+
+// CountUserHands counts every hand a user has played
+func (r *HandHistoryRepository) CountUserHands(userID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.HandHistory{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+// GetLatestUserHands gets a user's most recently started hands, newest
+// first
+func (r *HandHistoryRepository) GetLatestUserHands(userID uuid.UUID, limit int) ([]models.HandHistory, error) {
+	var hands []models.HandHistory
+	err := r.db.Where("user_id = ?", userID).
+		Order("started_at DESC").
+		Order("id DESC").
+		Limit(limit).
+		Find(&hands).Error
+	return hands, err
+}
+
 // GetByID gets a hand history by ID
 func (r *HandHistoryRepository) GetByID(id uuid.UUID) (*models.HandHistory, error) {
 	var handHistory models.HandHistory
-	err := r.db.Preload("User").Preload("Game").First(&handHistory, "id = ?", id).Error
+	err := r.db.Preload("User").Preload("Game").Preload("Hand").First(&handHistory, "id = ?", id).Error
 	if err != nil {
 		return nil, err
 	}
 	return &handHistory, nil
 }
 
-// GetUserHandHistory gets hand history for a specific user
-func (r *HandHistoryRepository) GetUserHandHistory(userID uuid.UUID, limit, offset int) ([]models.HandHistory, error) {
+// GetUserHandHistory gets a page of a user's hand history, newest first
+func (r *HandHistoryRepository) GetUserHandHistory(userID uuid.UUID, page pagination.PageRequest) (*pagination.PageResponse[models.HandHistory], error) {
+	var key interface{}
+	if page.Cursor != nil {
+		startedAt, err := page.Cursor.Time()
+		if err != nil {
+			return nil, err
+		}
+		key = startedAt
+	}
+
 	var hands []models.HandHistory
-	err := r.db.Where("user_id = ?", userID).
-		Preload("Game").
-		Order("started_at DESC").
-		Limit(limit).
-		Offset(offset).
-		Find(&hands).Error
-	return hands, err
+	query := r.db.Where("user_id = ?", userID).Preload("Game").Preload("Hand")
+	err := handHistoryKeyset.Apply(query, page, key).Find(&hands).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return pagination.NewPage(hands, page, func(hand models.HandHistory) pagination.Cursor {
+		return pagination.TimeCursor(hand.StartedAt, hand.ID.String())
+	}), nil
 }
 
+// handHistoryKeyset orders hand histories newest first
+var handHistoryKeyset = pagination.Keyset{Column: "started_at", IDColumn: "id", Descending: true}
+
 // GetGameHandHistory gets hand history for a specific game
 func (r *HandHistoryRepository) GetGameHandHistory(gameID uuid.UUID) ([]models.HandHistory, error) {
 	var hands []models.HandHistory
 	err := r.db.Where("game_id = ?", gameID).
 		Preload("User").
+		Preload("Hand").
 		Order("hand_number ASC").
 		Find(&hands).Error
 	return hands, err
@@ -64,18 +129,193 @@ func (r *HandHistoryRepository) GetUserGameHandHistory(userID, gameID uuid.UUID)
 	return hands, err
 }
 
+// StreamUserHands walks a user's hands in a time range in chronological
+// order, handing them to fn in batches with their games. It pages with a
+// (started_at, id) keyset so large histories never have to be loaded at
+// once.
+func (r *HandHistoryRepository) StreamUserHands(userID uuid.UUID, from, to time.Time, batchSize int, fn func([]models.HandHistory) error) error {
+	return streamUserHands(r.db, userID, from, to, batchSize, fn)
+}
+
+// StreamUserArchivedHands walks a user's hands in a time range that have
+// been moved to the archive, like StreamUserHands. A database without the
+// archive table has archived nothing.
+func (r *HandHistoryRepository) StreamUserArchivedHands(userID uuid.UUID, from, to time.Time, batchSize int, fn func([]models.HandHistory) error) error {
+	if !r.db.Migrator().HasTable(models.HandHistoryArchiveTable) {
+		return nil
+	}
+	return streamUserHands(r.db.Table(models.HandHistoryArchiveTable), userID, from, to, batchSize, fn)
+}
+
+// streamUserHands pages through a user's hands in the table db queries
+func streamUserHands(db *gorm.DB, userID uuid.UUID, from, to time.Time, batchSize int, fn func([]models.HandHistory) error) error {
+	var (
+		lastStartedAt time.Time
+		lastID        uuid.UUID
+		first         = true
+	)
+
+	for {
+		query := db.Where("user_id = ? AND started_at BETWEEN ? AND ?", userID, from, to)
+		if !first {
+			query = query.Where("started_at > ? OR (started_at = ? AND id > ?)", lastStartedAt, lastStartedAt, lastID)
+		}
+
+		var batch []models.HandHistory
+		err := query.Preload("Game").Preload("Hand").Order("started_at ASC").Order("id ASC").Limit(batchSize).Find(&batch).Error
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+
+		if err := fn(batch); err != nil {
+			return err
+		}
+
+		if len(batch) < batchSize {
+			return nil
+		}
+
+		last := batch[len(batch)-1]
+		lastStartedAt, lastID, first = last.StartedAt, last.ID, false
+	}
+}
+
+// GetLastFinishedBefore gets the user's hand that finished last among those
+// started before the given hand in (started_at, id) order, or nil when
+// there are none
+func (r *HandHistoryRepository) GetLastFinishedBefore(userID uuid.UUID, startedAt time.Time, id uuid.UUID) (*models.HandHistory, error) {
+	return lastFinishedBefore(r.db, userID, startedAt, id)
+}
+
+// GetLastFinishedBeforeWithTransaction is GetLastFinishedBefore run in tx
+func (r *HandHistoryRepository) GetLastFinishedBeforeWithTransaction(tx *gorm.DB, userID uuid.UUID, startedAt time.Time, id uuid.UUID) (*models.HandHistory, error) {
+	return lastFinishedBefore(tx, userID, startedAt, id)
+}
+
+func lastFinishedBefore(db *gorm.DB, userID uuid.UUID, startedAt time.Time, id uuid.UUID) (*models.HandHistory, error) {
+	var hands []models.HandHistory
+	err := db.Where("user_id = ?", userID).
+		Where("started_at < ? OR (started_at = ? AND id < ?)", startedAt, startedAt, id).
+		Order("finished_at DESC").
+		Limit(1).
+		Find(&hands).Error
+	if err != nil || len(hands) == 0 {
+		return nil, err
+	}
+	return &hands[0], nil
+}
+
+// GetHandsParticipants gets every player's row for the given hands, where
+// gameIDs[i] and handNumbers[i] identify one hand
+func (r *HandHistoryRepository) GetHandsParticipants(gameIDs []uuid.UUID, handNumbers []int) ([]models.HandHistory, error) {
+	if len(gameIDs) == 0 {
+		return nil, nil
+	}
+
+	wanted := make(map[uuid.UUID]map[int]bool)
+	for i, gameID := range gameIDs {
+		if wanted[gameID] == nil {
+			wanted[gameID] = make(map[int]bool)
+		}
+		wanted[gameID][handNumbers[i]] = true
+	}
+
+	var rows []models.HandHistory
+	err := r.db.Where("game_id IN ? AND hand_number IN ?", gameIDs, handNumbers).
+		Preload("User").
+		Preload("Game").
+		Preload("Hand").
+		Order("seat_position ASC").
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// The IN/IN filter over-selects across games; keep only requested pairs
+	participants := rows[:0]
+	for _, row := range rows {
+		if wanted[row.GameID][row.HandNumber] {
+			participants = append(participants, row)
+		}
+	}
+
+	return participants, nil
+}
+
+// GetSharedHands gets both players' rows of every hand the two were dealt
+// into together, matched on game and hand number, in the order the hands
+// started
+func (r *HandHistoryRepository) GetSharedHands(userID, opponentID uuid.UUID) ([]models.HandHistory, error) {
+	players := []uuid.UUID{userID, opponentID}
+
+	var rows []models.HandHistory
+	err := r.db.Where("user_id IN ?", players).
+		Where(`EXISTS (SELECT 1 FROM hand_histories other
+			WHERE other.game_id = hand_histories.game_id
+			AND other.hand_number = hand_histories.hand_number
+			AND other.user_id IN ? AND other.user_id <> hand_histories.user_id
+			AND other.deleted_at IS NULL)`, players).
+		Order("started_at ASC").
+		Order("game_id ASC").
+		Order("hand_number ASC").
+		Find(&rows).Error
+	return rows, err
+}
+
 // Update updates a hand history record
 func (r *HandHistoryRepository) Update(handHistory *models.HandHistory) error {
 	return r.db.Save(handHistory).Error
 }
 
-// GetUserStats gets aggregated statistics for a user
-func (r *HandHistoryRepository) GetUserStats(userID uuid.UUID, since *time.Time) (*models.HandSummary, error) {
-	query := r.db.Model(&models.HandHistory{}).Where("user_id = ?", userID)
-	
-	if since != nil {
-		query = query.Where("started_at >= ?", *since)
+// StatsFilter narrows the hands GetUserStats totals. Zero fields do not
+// filter.
+type StatsFilter struct {
+	Since       *time.Time
+	GameType    models.GameType
+	MinBigBlind int64
+	MaxBigBlind int64
+	// TableSize leaves out hands recorded before the players dealt in
+	// were, as their table size is unknown
+	TableSize   models.TableSize
+	WinnersOnly bool
+}
+
+// apply adds the filter's conditions to a query on hand_histories. Hands
+// against bots are always left out.
+func (f StatsFilter) apply(query *gorm.DB) *gorm.DB {
+	query = query.Where("hand_histories.against_bots = ?", false)
+	if f.Since != nil {
+		query = query.Where("hand_histories.started_at >= ?", *f.Since)
+	}
+	if f.GameType != "" {
+		query = query.Joins("JOIN games ON games.id = hand_histories.game_id").
+			Where("games.game_type = ?", f.GameType)
+	}
+	if f.MinBigBlind > 0 {
+		query = query.Where("hand_histories.big_blind >= ?", f.MinBigBlind)
 	}
+	if f.MaxBigBlind > 0 {
+		query = query.Where("hand_histories.big_blind <= ?", f.MaxBigBlind)
+	}
+	if fewest, most, ok := f.TableSize.PlayersDealtIn(); ok {
+		query = query.Where("hand_histories.players_dealt_in >= ?", fewest)
+		if most > 0 {
+			query = query.Where("hand_histories.players_dealt_in <= ?", most)
+		}
+	}
+	if f.WinnersOnly {
+		query = query.Where("hand_histories.is_winner = ?", true)
+	}
+	return query
+}
+
+// GetUserStats gets aggregated statistics over the user's hands passing
+// filter, totalled in the database
+func (r *HandHistoryRepository) GetUserStats(userID uuid.UUID, filter StatsFilter) (*models.HandSummary, error) {
+	query := filter.apply(r.db.Model(&models.HandHistory{}).Where("hand_histories.user_id = ?", userID))
 
 	var stats struct {
 		TotalHands     int
@@ -91,14 +331,14 @@ func (r *HandHistoryRepository) GetUserStats(userID uuid.UUID, since *time.Time)
 
 	err := query.Select(`
 		COUNT(*) as total_hands,
-		SUM(CASE WHEN is_winner THEN 1 ELSE 0 END) as hands_won,
-		SUM(CASE WHEN folded_phase IS NOT NULL THEN 1 ELSE 0 END) as hands_folded,
-		SUM(starting_chips - ending_chips + amount_won) as total_wagered,
-		SUM(amount_won) as total_won,
-		AVG(pot_size) as avg_pot_size,
-		AVG(vpip_percent) as vpip_percent,
-		AVG(pfr_percent) as pfr_percent,
-		AVG(aggression_factor) as aggression_factor
+		COALESCE(SUM(CASE WHEN hand_histories.is_winner THEN 1 ELSE 0 END), 0) as hands_won,
+		COALESCE(SUM(CASE WHEN hand_histories.folded_phase <> '' THEN 1 ELSE 0 END), 0) as hands_folded,
+		COALESCE(SUM(hand_histories.starting_chips - hand_histories.ending_chips + hand_histories.amount_won), 0) as total_wagered,
+		COALESCE(SUM(hand_histories.amount_won), 0) as total_won,
+		COALESCE(AVG(hand_histories.pot_size), 0) as avg_pot_size,
+		COALESCE(AVG(hand_histories.vpip_percent), 0) as vpip_percent,
+		COALESCE(AVG(hand_histories.pfr_percent), 0) as pfr_percent,
+		COALESCE(AVG(hand_histories.aggression_factor), 0) as aggression_factor
 	`).Scan(&stats).Error
 
 	if err != nil {
@@ -120,6 +360,10 @@ func (r *HandHistoryRepository) GetUserStats(userID uuid.UUID, since *time.Time)
 		AggressionFactor: stats.AggressionFactor,
 	}
 
+	if filter.Since != nil {
+		summary.PeriodStart = *filter.Since
+	}
+
 	if stats.TotalHands > 0 {
 		summary.WinRate = float64(stats.HandsWon) / float64(stats.TotalHands) * 100.0
 		if stats.HandsWon > 0 {
@@ -130,10 +374,53 @@ func (r *HandHistoryRepository) GetUserStats(userID uuid.UUID, since *time.Time)
 	return summary, nil
 }
 
+// StartingHandTotals sums a user's hands dealt one pair of hole card ranks,
+// suited or not. The ranks are in the order the cards were dealt, so the
+// same starting hand can come back as two rows.
+type StartingHandTotals struct {
+	Rank1        string
+	Rank2        string
+	Suited       bool
+	Hands        int
+	VPIPHands    int `gorm:"column:vpip_hands"`
+	NetResult    int64
+	BigBlindsWon float64
+}
+
+// GetStartingHandTotals gets a user's results for each pair of hole cards
+// dealt between from and to, optionally only from one position, in hands
+// not played against bots
+func (r *HandHistoryRepository) GetStartingHandTotals(userID uuid.UUID, position string, from, to time.Time) ([]StartingHandTotals, error) {
+	const suited = "CASE WHEN hole_card1_suit = hole_card2_suit THEN 1 ELSE 0 END"
+
+	query := r.db.Model(&models.HandHistory{}).
+		Where("user_id = ? AND started_at BETWEEN ? AND ?", userID, from, to).
+		Where("hole_card1_rank <> '' AND hole_card2_rank <> ''").
+		Where("against_bots = ?", false)
+	if position != "" {
+		query = query.Where("position = ?", position)
+	}
+
+	var totals []StartingHandTotals
+	err := query.Select(`
+		hole_card1_rank as rank1,
+		hole_card2_rank as rank2,
+		` + suited + ` as suited,
+		COUNT(*) as hands,
+		SUM(CASE WHEN vpip_percent > 0 THEN 1 ELSE 0 END) as vpip_hands,
+		SUM(net_result) as net_result,
+		COALESCE(SUM(net_result * 1.0 / NULLIF(big_blind, 0)), 0) as big_blinds_won
+	`).
+		Group("hole_card1_rank, hole_card2_rank, " + suited).
+		Scan(&totals).Error
+	return totals, err
+}
+
 // GetHandsByTimeRange gets hands within a specific time range
 func (r *HandHistoryRepository) GetHandsByTimeRange(userID uuid.UUID, startTime, endTime time.Time) ([]models.HandHistory, error) {
 	var hands []models.HandHistory
 	err := r.db.Where("user_id = ? AND started_at BETWEEN ? AND ?", userID, startTime, endTime).
+		Preload("Game").
 		Order("started_at ASC").
 		Find(&hands).Error
 	return hands, err
@@ -200,6 +487,32 @@ func (r *HandHistoryRepository) GetSummaryByPeriod(userID uuid.UUID, periodStart
 	return &summary, nil
 }
 
+// UpsertSummary stores a player's summary for a period, replacing the one
+// already rolled up for it
+func (r *HandHistoryRepository) UpsertSummary(summary *models.HandSummary) error {
+	return r.db.Omit(clause.Associations).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "period"}, {Name: "period_start"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"total_hands", "hands_won", "hands_lost", "hands_folded", "win_rate",
+			"total_wagered", "total_won", "net_result", "avg_pot_size", "avg_win_amount",
+			"vp_ip_percent", "pfr_percent", "aggression_factor", "fold_to_steal",
+			"pocket_pairs", "suited_cards", "connected_cards",
+			"period_end", "updated_at", "deleted_at",
+		}),
+	}).Create(summary).Error
+}
+
+// GetSummaries gets a user's summaries of one length starting in
+// [from, to), oldest first
+func (r *HandHistoryRepository) GetSummaries(userID uuid.UUID, period models.SummaryPeriod, from, to time.Time) ([]models.HandSummary, error) {
+	var summaries []models.HandSummary
+	err := r.db.Where("user_id = ? AND period = ?", userID, period).
+		Where("period_start >= ? AND period_start < ?", from, to).
+		Order("period_start ASC").
+		Find(&summaries).Error
+	return summaries, err
+}
+
 // GetUserBestHands gets user's best performing hands
 func (r *HandHistoryRepository) GetUserBestHands(userID uuid.UUID, limit int) ([]models.HandHistory, error) {
 	var hands []models.HandHistory
@@ -235,11 +548,70 @@ func (r *HandHistoryRepository) Delete(id uuid.UUID) error {
 	return r.db.Delete(&models.HandHistory{}, id).Error
 }
 
+// Transaction runs fn in a transaction, committing unless it fails
+func (r *HandHistoryRepository) Transaction(fn func(tx *gorm.DB) error) error {
+	return r.db.Transaction(fn)
+}
+
+// ClaimSettlement records that a hand is being settled, reporting false if
+// it was settled before
+func (r *HandHistoryRepository) ClaimSettlement(gameID uuid.UUID, handNumber int) (bool, error) {
+	return r.ClaimSettlementWithTransaction(r.db, gameID, handNumber)
+}
+
+// ClaimSettlementWithTransaction records that a hand is being settled in
+// tx, reporting false if it was settled before
+func (r *HandHistoryRepository) ClaimSettlementWithTransaction(tx *gorm.DB, gameID uuid.UUID, handNumber int) (bool, error) {
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.HandSettlement{
+		GameID:     gameID,
+		HandNumber: handNumber,
+		SettledAt:  time.Now(),
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// CreateHand creates the table-level record of a hand
+func (r *HandHistoryRepository) CreateHand(hand *models.Hand) error {
+	return r.CreateHandWithTransaction(r.db, hand)
+}
+
+// CreateHandWithTransaction creates the table-level record of a hand
+// within a transaction
+func (r *HandHistoryRepository) CreateHandWithTransaction(tx *gorm.DB, hand *models.Hand) error {
+	return tx.Create(hand).Error
+}
+
 // CreateWithTransaction creates a hand history within a transaction
 func (r *HandHistoryRepository) CreateWithTransaction(tx *gorm.DB, handHistory *models.HandHistory) error {
 	return tx.Create(handHistory).Error
 }
 
+// handHistoryBatchSize is how many hand histories are inserted per
+// statement, enough for every seat at a full table
+const handHistoryBatchSize = 10
+
+// CreateBatch creates the hand histories of one hand together in a single
+// transaction
+func (r *HandHistoryRepository) CreateBatch(handHistories []models.HandHistory) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return r.CreateBatchWithTransaction(tx, handHistories)
+	})
+}
+
+// CreateBatchWithTransaction creates the hand histories of one hand
+// together within a transaction. The street actions they share are encoded
+// once for all of them.
+func (r *HandHistoryRepository) CreateBatchWithTransaction(tx *gorm.DB, handHistories []models.HandHistory) error {
+	if len(handHistories) == 0 {
+		return nil
+	}
+	return tx.WithContext(models.WithActionEncoding(tx.Statement.Context)).
+		CreateInBatches(handHistories, handHistoryBatchSize).Error
+}
+
 // UpdateWithTransaction updates a hand history within a transaction
 func (r *HandHistoryRepository) UpdateWithTransaction(tx *gorm.DB, handHistory *models.HandHistory) error {
 	return tx.Save(handHistory).Error
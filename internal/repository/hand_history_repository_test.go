@@ -0,0 +1,252 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/testutil"
+)
+
+// fullTableHand builds the rows of one hand dealt to nine players, sharing
+// each street's actions as the hand writer does
+func fullTableHand(gameID uuid.UUID, handNumber int) []models.HandHistory {
+	start := time.Date(2026, 5, 1, 20, 0, 0, 0, time.UTC)
+	players := make([]uuid.UUID, 9)
+	for i := range players {
+		players[i] = uuid.New()
+	}
+
+	streets := make([][]models.PlayerActionRecord, 4)
+	for street := range streets {
+		for i, playerID := range players {
+			streets[street] = append(streets[street], models.PlayerActionRecord{
+				PlayerID:    playerID,
+				Username:    fmt.Sprintf("player%d", i),
+				Action:      models.ActionCall,
+				Amount:      100,
+				Timestamp:   start.Add(time.Duration(street*len(players)+i) * time.Second),
+				ChipsBefore: 10000 - int64(street)*100,
+				ChipsAfter:  9900 - int64(street)*100,
+				Position:    "MP",
+			})
+		}
+	}
+
+	rows := make([]models.HandHistory, len(players))
+	for i, playerID := range players {
+		rows[i] = models.HandHistory{
+			GameID:         gameID,
+			UserID:         playerID,
+			HandNumber:     handNumber,
+			SeatPosition:   i,
+			PreFlopActions: streets[0],
+			FlopActions:    streets[1],
+			TurnActions:    streets[2],
+			RiverActions:   streets[3],
+			StartedAt:      start,
+			FinishedAt:     start.Add(time.Minute),
+		}
+	}
+	return rows
+}
+
+func TestHandHistoryCreateBatch(t *testing.T) {
+	db := testutil.NewDB(t, &models.User{}, &models.Game{}, &models.HandHistory{})
+	repo := NewHandHistoryRepository(db)
+	gameID := uuid.New()
+
+	rows := fullTableHand(gameID, 1)
+	rows[0].TurnActions, rows[0].RiverActions = nil, nil
+	require.NoError(t, repo.CreateBatch(rows))
+
+	var stored []models.HandHistory
+	require.NoError(t, db.Where("game_id = ?", gameID).Order("seat_position").Find(&stored).Error)
+	require.Len(t, stored, 9)
+	for i, row := range stored {
+		assert.NotEqual(t, uuid.Nil, row.ID)
+		assert.Equal(t, rows[i].UserID, row.UserID)
+		assert.Equal(t, rows[i].PreFlopActions, row.PreFlopActions, "shared actions are stored for every player")
+		assert.Equal(t, rows[i].RiverActions, row.RiverActions)
+	}
+	assert.Empty(t, stored[0].TurnActions)
+
+	assert.NoError(t, repo.CreateBatch(nil))
+}
+
+func TestHandHistoryUniquePerPlayerHand(t *testing.T) {
+	db := testutil.NewDB(t, &models.User{}, &models.Game{}, &models.HandHistory{})
+	repo := NewHandHistoryRepository(db)
+
+	rows := fullTableHand(uuid.New(), 1)
+	require.NoError(t, repo.Create(&rows[0]))
+
+	duplicate := rows[0]
+	duplicate.ID = uuid.Nil
+	assert.Error(t, repo.Create(&duplicate), "a player's hand is stored once")
+	assert.Error(t, repo.CreateBatch(rows), "a batch repeating a stored row is refused whole")
+
+	var count int64
+	require.NoError(t, db.Model(&models.HandHistory{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}
+
+// queryPlan returns SQLite's plan for the statement a query builds
+func queryPlan(t *testing.T, db *gorm.DB, query func(tx *gorm.DB) *gorm.DB) string {
+	t.Helper()
+
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB { return query(tx) })
+	rows, err := db.Raw("EXPLAIN QUERY PLAN " + sql).Rows()
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var plan []string
+	for rows.Next() {
+		var id, parent, unused int
+		var detail string
+		require.NoError(t, rows.Scan(&id, &parent, &unused, &detail))
+		plan = append(plan, detail)
+	}
+	return fmt.Sprint(plan)
+}
+
+func TestHandHistoryQueriesUseIndexes(t *testing.T) {
+	db := testutil.NewDB(t, &models.User{}, &models.Game{}, &models.HandHistory{})
+	userID := uuid.New()
+	since := time.Now().Add(-24 * time.Hour)
+
+	plan := queryPlan(t, db, func(tx *gorm.DB) *gorm.DB {
+		var hands []models.HandHistory
+		return tx.Where("user_id = ? AND started_at >= ? AND started_at < ?", userID, since, time.Now()).
+			Order("started_at DESC").Find(&hands)
+	})
+	assert.Contains(t, plan, "idx_hand_histories_user_started", "a player's hands in a time range are read by index")
+	assert.NotContains(t, plan, "TEMP B-TREE", "and come out in order")
+
+	plan = queryPlan(t, db, func(tx *gorm.DB) *gorm.DB {
+		var count int64
+		return tx.Model(&models.HandHistory{}).Where("user_id = ? AND is_winner = ?", userID, true).Count(&count)
+	})
+	assert.Contains(t, plan, "idx_hand_histories_user_winner")
+
+	plan = queryPlan(t, db, func(tx *gorm.DB) *gorm.DB {
+		var hands []models.HandHistory
+		return tx.Where("game_id = ? AND hand_number = ?", uuid.New(), 3).Find(&hands)
+	})
+	assert.Contains(t, plan, "idx_hand_histories_")
+}
+
+func TestGetUserStatsFilters(t *testing.T) {
+	db := testutil.NewDB(t, &models.User{}, &models.Game{}, &models.HandHistory{})
+	repo := NewHandHistoryRepository(db)
+	hero := uuid.New()
+	recent := time.Date(2026, 5, 1, 20, 0, 0, 0, time.UTC)
+
+	holdem := models.Game{ID: uuid.New(), Name: "Holdem", GameType: models.GameTypeTexasHoldem, SmallBlind: 50, BigBlind: 100}
+	omaha := models.Game{ID: uuid.New(), Name: "Omaha", GameType: models.GameTypeOmaha, SmallBlind: 50, BigBlind: 100}
+	require.NoError(t, db.Create(&[]models.Game{holdem, omaha}).Error)
+
+	hands := []struct {
+		game     models.Game
+		bigBlind int64
+		dealt    int
+		won      bool
+		at       time.Time
+	}{
+		{holdem, 100, 2, true, recent},
+		{holdem, 100, 6, false, recent},
+		{holdem, 1000, 9, true, recent},
+		{omaha, 100, 4, false, recent},
+		{omaha, 1000, 2, true, recent.AddDate(-1, 0, 0)},
+		{holdem, 100, 0, false, recent}, // Recorded before players dealt in were
+	}
+	for i, hand := range hands {
+		row := models.HandHistory{
+			GameID: hand.game.ID, UserID: hero, HandNumber: i + 1,
+			BigBlind: hand.bigBlind, PlayersDealtIn: hand.dealt,
+			StartingChips: 1000, EndingChips: 900, AmountWon: 0,
+			StartedAt: hand.at, FinishedAt: hand.at.Add(time.Minute),
+		}
+		if hand.won {
+			row.IsWinner, row.EndingChips, row.AmountWon = true, 1200, 300
+		} else {
+			row.FoldedPhase = models.HandPhasePreFlop
+		}
+		require.NoError(t, repo.Create(&row))
+	}
+
+	// Someone else's hand at the same table never counts
+	require.NoError(t, repo.Create(&models.HandHistory{
+		GameID: holdem.ID, UserID: uuid.New(), HandNumber: 1, BigBlind: 100, PlayersDealtIn: 2,
+		IsWinner: true, StartedAt: recent, FinishedAt: recent,
+	}))
+
+	since := recent.AddDate(0, -1, 0)
+	tests := []struct {
+		name   string
+		filter StatsFilter
+		hands  int
+		won    int
+	}{
+		{"everything", StatsFilter{}, 6, 3},
+		{"since", StatsFilter{Since: &since}, 5, 2},
+		{"game type", StatsFilter{GameType: models.GameTypeOmaha}, 2, 1},
+		{"minimum big blind", StatsFilter{MinBigBlind: 1000}, 2, 2},
+		{"maximum big blind", StatsFilter{MaxBigBlind: 100}, 4, 1},
+		{"heads up", StatsFilter{TableSize: models.TableSizeHeadsUp}, 2, 2},
+		{"six max", StatsFilter{TableSize: models.TableSizeSixMax}, 2, 0},
+		{"full ring", StatsFilter{TableSize: models.TableSizeFullRing}, 1, 1},
+		{"winners only", StatsFilter{WinnersOnly: true}, 3, 3},
+		{"combined", StatsFilter{GameType: models.GameTypeTexasHoldem, MaxBigBlind: 100, WinnersOnly: true}, 1, 1},
+		{"nothing matches", StatsFilter{GameType: models.GameTypeStud}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats, err := repo.GetUserStats(hero, tt.filter)
+			require.NoError(t, err)
+			assert.Equal(t, tt.hands, stats.TotalHands)
+			assert.Equal(t, tt.won, stats.HandsWon)
+			assert.Equal(t, tt.hands-tt.won, stats.HandsFolded, "every hand lost was folded")
+			assert.Equal(t, int64(tt.won)*300, stats.TotalWon)
+		})
+	}
+}
+
+func BenchmarkHandHistoryInsert(b *testing.B) {
+	b.Run("PerRow", func(b *testing.B) {
+		repo := NewHandHistoryRepository(testutil.NewDB(b, &models.User{}, &models.Game{}, &models.HandHistory{}))
+		gameID := uuid.New()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			rows := fullTableHand(gameID, i)
+			err := repo.Transaction(func(tx *gorm.DB) error {
+				for j := range rows {
+					if err := repo.CreateWithTransaction(tx, &rows[j]); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("Batch", func(b *testing.B) {
+		repo := NewHandHistoryRepository(testutil.NewDB(b, &models.User{}, &models.Game{}, &models.HandHistory{}))
+		gameID := uuid.New()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := repo.CreateBatch(fullTableHand(gameID, i)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
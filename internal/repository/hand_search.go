@@ -0,0 +1,295 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/pagination"
+)
+
+// HandSearchOrder is the order hand search results come in
+type HandSearchOrder string
+
+const (
+	HandSearchByDate      HandSearchOrder = "date"      // Newest first
+	HandSearchByRelevance HandSearchOrder = "relevance" // Best match to the text first
+)
+
+// BoardTexture is a shape of board a hand search can ask for
+type BoardTexture string
+
+const (
+	BoardFlushPossible    BoardTexture = "flush_possible"    // Three or more cards of a suit
+	BoardPaired           BoardTexture = "paired"            // Two or more cards of a rank
+	BoardStraightPossible BoardTexture = "straight_possible" // Three cards within a straight's ranks
+)
+
+// boardTextures are the conditions on hands each texture adds
+var boardTextures = map[BoardTexture]string{
+	BoardFlushPossible:    "hands.board_suited >= 3",
+	BoardPaired:           "hands.board_paired = true",
+	BoardStraightPossible: "hands.board_straight = true",
+}
+
+// holeCardRanks orders the ranks a starting hand is written with
+const holeCardRanks = "23456789TJQKA"
+
+// HoleCards is a starting hand: two ranks as stored, such as "A" or "10",
+// and whether the cards share a suit, nil when either will do
+type HoleCards struct {
+	High   string
+	Low    string
+	Suited *bool
+}
+
+// ParseHoleCards reads a starting hand as players write it: AA, AKs, AKo
+// or AK for either, with T for a ten
+func ParseHoleCards(s string) (HoleCards, error) {
+	invalid := fmt.Errorf("hole cards must be written like AA, AKs, AKo or AK, not %q", s)
+	if len(s) != 2 && len(s) != 3 {
+		return HoleCards{}, invalid
+	}
+
+	high := strings.IndexByte(holeCardRanks, strings.ToUpper(s[:1])[0])
+	low := strings.IndexByte(holeCardRanks, strings.ToUpper(s[1:2])[0])
+	if high < 0 || low < 0 {
+		return HoleCards{}, invalid
+	}
+	if low > high {
+		high, low = low, high
+	}
+
+	cards := HoleCards{High: storedRank(holeCardRanks[high]), Low: storedRank(holeCardRanks[low])}
+	if len(s) == 3 {
+		suited := strings.ToLower(s[2:])
+		if high == low || (suited != "s" && suited != "o") {
+			return HoleCards{}, invalid
+		}
+		isSuited := suited == "s"
+		cards.Suited = &isSuited
+	}
+	return cards, nil
+}
+
+// storedRank is how hand histories store a rank
+func storedRank(rank byte) string {
+	if rank == 'T' {
+		return "10"
+	}
+	return string(rank)
+}
+
+// HandSearch finds a user's hands by what happened in them. Every
+// condition set must hold. Only hands with a table-level record, those
+// recorded since it was kept, are searched.
+type HandSearch struct {
+	// Text is words the hand's summary must contain
+	Text      string
+	HoleCards *HoleCards
+	Board     []BoardTexture
+	MinPot    *int64
+	MaxPot    *int64
+	// MinResult and MaxResult bound the user's net result in the hand,
+	// which is negative when they lost chips
+	MinResult *int64
+	MaxResult *int64
+	// Opponent is the username of another player dealt into the hand
+	Opponent string
+	Order    HandSearchOrder
+}
+
+// Validate checks the search can be run
+func (s HandSearch) Validate() error {
+	for _, texture := range s.Board {
+		if _, ok := boardTextures[texture]; !ok {
+			return fmt.Errorf("board must be flush_possible, paired or straight_possible, not %q", texture)
+		}
+	}
+	if s.MinPot != nil && s.MaxPot != nil && *s.MinPot > *s.MaxPot {
+		return errors.New("min_pot must not be above max_pot")
+	}
+	if s.MinResult != nil && s.MaxResult != nil && *s.MinResult > *s.MaxResult {
+		return errors.New("min_result must not be above max_result")
+	}
+	switch s.Order {
+	case "", HandSearchByDate:
+	case HandSearchByRelevance:
+		if strings.TrimSpace(s.Text) == "" {
+			return errors.New("sorting by relevance needs text to search for")
+		}
+	default:
+		return fmt.Errorf("sort must be date or relevance, not %q", s.Order)
+	}
+	return nil
+}
+
+// HandSearchResult is a hand found by a search, with how well it matched
+// the search's text
+type HandSearchResult struct {
+	models.HandHistory
+	Relevance float64 `json:"relevance,omitempty"`
+}
+
+// Hand search results are ordered by their date or relevance, then ID
+var (
+	handSearchByDate      = pagination.Keyset{Column: "matches.started_at", IDColumn: "matches.id", Descending: true}
+	handSearchByRelevance = pagination.Keyset{Column: "matches.relevance", IDColumn: "matches.id", Descending: true}
+)
+
+// SearchHands gets a page of the user's hands matching search. The hands
+// are found, ordered and paged in one query over their IDs, then loaded.
+// Text is matched with full-text search on Postgres, which stems the
+// words and ranks hands by them, and by substring on other databases,
+// which rank hands by how often the words occur.
+func (r *HandHistoryRepository) SearchHands(userID uuid.UUID, search HandSearch, page pagination.PageRequest) (*pagination.PageResponse[HandSearchResult], error) {
+	if err := search.Validate(); err != nil {
+		return nil, err
+	}
+
+	postgres := r.db.Dialector.Name() == "postgres"
+	relevance, relevanceArgs := search.relevance(postgres)
+	matches := search.apply(r.db.Model(&models.HandHistory{}).
+		Joins("JOIN hands ON hands.id = hand_histories.hand_id").
+		Where("hand_histories.user_id = ?", userID), postgres).
+		Select("hand_histories.id, hand_histories.started_at, "+relevance+" AS relevance", relevanceArgs...)
+
+	keyset := handSearchByDate
+	var key interface{}
+	if search.Order == HandSearchByRelevance {
+		keyset = handSearchByRelevance
+	}
+	if page.Cursor != nil {
+		var err error
+		if search.Order == HandSearchByRelevance {
+			key, err = page.Cursor.Float64()
+		} else {
+			key, err = page.Cursor.Time()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var found []struct {
+		ID        uuid.UUID
+		StartedAt time.Time
+		Relevance float64
+	}
+	if err := keyset.Apply(r.db.Table("(?) AS matches", matches), page, key).Find(&found).Error; err != nil {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, len(found))
+	for i, match := range found {
+		ids[i] = match.ID
+	}
+	var hands []models.HandHistory
+	if len(ids) > 0 {
+		if err := r.db.Where("id IN ?", ids).Preload("Game").Preload("Hand").Find(&hands).Error; err != nil {
+			return nil, err
+		}
+	}
+	byID := make(map[uuid.UUID]models.HandHistory, len(hands))
+	for _, hand := range hands {
+		byID[hand.ID] = hand
+	}
+
+	results := make([]HandSearchResult, 0, len(found))
+	for _, match := range found {
+		if hand, ok := byID[match.ID]; ok {
+			results = append(results, HandSearchResult{HandHistory: hand, Relevance: match.Relevance})
+		}
+	}
+
+	return pagination.NewPage(results, page, func(result HandSearchResult) pagination.Cursor {
+		if search.Order == HandSearchByRelevance {
+			return pagination.Float64Cursor(result.Relevance, result.ID.String())
+		}
+		return pagination.TimeCursor(result.StartedAt, result.ID.String())
+	}), nil
+}
+
+// apply adds the search's conditions to a query on hand_histories joined
+// to hands
+func (s HandSearch) apply(query *gorm.DB, postgres bool) *gorm.DB {
+	if cards := s.HoleCards; cards != nil {
+		query = query.Where(
+			"((hand_histories.hole_card1_rank = ? AND hand_histories.hole_card2_rank = ?) OR (hand_histories.hole_card1_rank = ? AND hand_histories.hole_card2_rank = ?))",
+			cards.High, cards.Low, cards.Low, cards.High,
+		)
+		if cards.Suited != nil && *cards.Suited {
+			query = query.Where("hand_histories.hole_card1_suit = hand_histories.hole_card2_suit")
+		} else if cards.Suited != nil {
+			query = query.Where("hand_histories.hole_card1_suit <> hand_histories.hole_card2_suit")
+		}
+	}
+	for _, texture := range s.Board {
+		query = query.Where(boardTextures[texture])
+	}
+	if s.MinPot != nil {
+		query = query.Where("hands.pot >= ?", *s.MinPot)
+	}
+	if s.MaxPot != nil {
+		query = query.Where("hands.pot <= ?", *s.MaxPot)
+	}
+	if s.MinResult != nil {
+		query = query.Where("hand_histories.net_result >= ?", *s.MinResult)
+	}
+	if s.MaxResult != nil {
+		query = query.Where("hand_histories.net_result <= ?", *s.MaxResult)
+	}
+	if s.Opponent != "" {
+		query = query.Where(`EXISTS (SELECT 1 FROM hand_histories AS opponent JOIN users ON users.id = opponent.user_id
+			WHERE opponent.hand_id = hand_histories.hand_id AND opponent.user_id <> hand_histories.user_id
+			AND opponent.deleted_at IS NULL AND users.username = ?)`, s.Opponent)
+	}
+
+	if postgres && strings.TrimSpace(s.Text) != "" {
+		query = query.Where("to_tsvector('english', hands.summary) @@ plainto_tsquery('english', ?)", s.Text)
+	} else {
+		for _, word := range searchWords(s.Text) {
+			query = query.Where(`LOWER(hands.summary) LIKE ? ESCAPE '\'`, "%"+escapeLike(word)+"%")
+		}
+	}
+	return query
+}
+
+// relevance is the SQL scoring how well a hand matches the search's text,
+// and its arguments. Without text every hand scores zero.
+func (s HandSearch) relevance(postgres bool) (string, []interface{}) {
+	if strings.TrimSpace(s.Text) == "" {
+		return "0.0", nil
+	}
+	if postgres {
+		return "ts_rank(to_tsvector('english', hands.summary), plainto_tsquery('english', ?))::float8", []interface{}{s.Text}
+	}
+
+	// Count each word's occurrences by how much shorter removing it makes
+	// the summary
+	words := searchWords(s.Text)
+	terms := make([]string, len(words))
+	args := make([]interface{}, 0, 2*len(words))
+	for i, word := range words {
+		terms[i] = "(LENGTH(hands.summary) - LENGTH(REPLACE(LOWER(hands.summary), ?, ''))) * 1.0 / ?"
+		args = append(args, word, utf8.RuneCountInString(word))
+	}
+	return "(" + strings.Join(terms, " + ") + ")", args
+}
+
+// searchWords splits text into the lower-case words a summary is searched
+// for
+func searchWords(text string) []string {
+	return strings.Fields(strings.ToLower(text))
+}
+
+// escapeLike escapes the LIKE wildcards in s, with backslash as the escape
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,215 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/pagination"
+	"github.com/primoPoker/server/internal/testutil"
+)
+
+// searchFixture stores hands the hero played, named by what sets each
+// apart, and returns the hero's ID with each hand's number by name
+func searchFixture(t *testing.T, db *gorm.DB) (uuid.UUID, map[string]int) {
+	t.Helper()
+
+	users := make(map[string]models.User)
+	for _, name := range []string{"hero", "villain", "fish"} {
+		user := models.User{ID: uuid.New(), Username: name, Email: name + "@example.com", PasswordHash: "x"}
+		require.NoError(t, db.Create(&user).Error)
+		users[name] = user
+	}
+	table := models.Game{ID: uuid.New(), Name: "Search", SmallBlind: 10, BigBlind: 20}
+	require.NoError(t, db.Create(&table).Error)
+
+	hands := []struct {
+		name     string
+		hole     [4]string // Ranks and suits
+		board    []string
+		pot      int64
+		net      int64
+		opponent string
+		summary  string
+	}{
+		{"aces cracked", [4]string{"A", "Spades", "A", "Hearts"}, []string{"Kh", "Qh", "Jh", "2c", "3d"}, 4000, -2000, "villain",
+			"hero showed As Ah, pocket aces, for Pair and lost 2000. villain showed Th 9h, ten nine suited, for Flush and won 4000."},
+		{"set on paired board", [4]string{"7", "Clubs", "7", "Diamonds"}, []string{"7h", "7s", "Kd"}, 300, 150, "fish",
+			"hero showed 7c 7d, pocket sevens, for Four of a Kind and won 300."},
+		{"ace king suited", [4]string{"A", "Diamonds", "K", "Diamonds"}, []string{"2d", "8d", "Jd"}, 800, 400, "villain",
+			"hero showed Ad Kd, ace king suited, for High Card and won 800. villain folded on the flop and lost 400."},
+		{"ace king offsuit", [4]string{"K", "Clubs", "A", "Hearts"}, nil, 30, -10, "fish",
+			"hero folded on the pre-flop and lost 10. fish won 30 uncontested."},
+		{"straight board", [4]string{"10", "Clubs", "2", "Hearts"}, []string{"9s", "8d", "6c"}, 1200, -600, "villain",
+			"villain showed aces, pocket aces, and won 1200. Aces again."},
+	}
+
+	numbers := make(map[string]int)
+	start := time.Date(2026, 7, 1, 20, 0, 0, 0, time.UTC)
+	for i, hand := range hands {
+		record := models.Hand{ID: uuid.New(), GameID: table.ID, HandNumber: i + 1, Pot: hand.pot, Summary: hand.summary,
+			StartedAt: start.Add(time.Duration(i) * time.Minute)}
+		record.SetBoard(hand.board)
+		require.NoError(t, db.Create(&record).Error)
+
+		for _, player := range []string{"hero", hand.opponent} {
+			row := models.HandHistory{
+				GameID: table.ID, UserID: users[player].ID, HandID: &record.ID, HandNumber: i + 1,
+				PotSize: hand.pot, NetResult: -hand.net, StartedAt: record.StartedAt, FinishedAt: record.StartedAt,
+			}
+			if player == "hero" {
+				row.NetResult = hand.net
+				row.HoleCard1Rank, row.HoleCard1Suit, row.HoleCard2Rank, row.HoleCard2Suit = hand.hole[0], hand.hole[1], hand.hole[2], hand.hole[3]
+			}
+			require.NoError(t, db.Create(&row).Error)
+		}
+		numbers[hand.name] = i + 1
+	}
+
+	// A hand recorded before the table-level record was kept is never found
+	require.NoError(t, db.Create(&models.HandHistory{
+		GameID: table.ID, UserID: users["hero"].ID, HandNumber: 99, HoleCard1Rank: "A", HoleCard2Rank: "A",
+		StartedAt: start, FinishedAt: start,
+	}).Error)
+
+	return users["hero"].ID, numbers
+}
+
+// found returns the hand numbers of a page of search results
+func found(page *pagination.PageResponse[HandSearchResult]) []int {
+	numbers := []int{}
+	for _, result := range page.Items {
+		numbers = append(numbers, result.HandNumber)
+	}
+	return numbers
+}
+
+func TestSearchHands(t *testing.T) {
+	db := testutil.NewDB(t, &models.User{}, &models.Game{}, &models.Hand{}, &models.HandHistory{})
+	repo := NewHandHistoryRepository(db)
+	hero, hand := searchFixture(t, db)
+
+	cards := func(s string) *HoleCards {
+		parsed, err := ParseHoleCards(s)
+		require.NoError(t, err)
+		return &parsed
+	}
+	chips := func(n int64) *int64 { return &n }
+
+	tests := []struct {
+		name   string
+		search HandSearch
+		want   []string // Newest first
+	}{
+		{"everything", HandSearch{}, []string{"straight board", "ace king offsuit", "ace king suited", "set on paired board", "aces cracked"}},
+		{"pocket pair", HandSearch{HoleCards: cards("AA")}, []string{"aces cracked"}},
+		{"either suit", HandSearch{HoleCards: cards("KA")}, []string{"ace king offsuit", "ace king suited"}},
+		{"suited", HandSearch{HoleCards: cards("AKs")}, []string{"ace king suited"}},
+		{"offsuit", HandSearch{HoleCards: cards("AKo")}, []string{"ace king offsuit"}},
+		{"ten", HandSearch{HoleCards: cards("T2o")}, []string{"straight board"}},
+		{"flush possible", HandSearch{Board: []BoardTexture{BoardFlushPossible}}, []string{"ace king suited", "aces cracked"}},
+		{"paired board", HandSearch{Board: []BoardTexture{BoardPaired}}, []string{"set on paired board"}},
+		{"straight possible", HandSearch{Board: []BoardTexture{BoardStraightPossible}}, []string{"straight board", "aces cracked"}},
+		{"minimum pot", HandSearch{MinPot: chips(1000)}, []string{"straight board", "aces cracked"}},
+		{"maximum pot", HandSearch{MaxPot: chips(300)}, []string{"ace king offsuit", "set on paired board"}},
+		{"losses", HandSearch{MaxResult: chips(-1)}, []string{"straight board", "ace king offsuit", "aces cracked"}},
+		{"result range", HandSearch{MinResult: chips(100), MaxResult: chips(200)}, []string{"set on paired board"}},
+		{"opponent", HandSearch{Opponent: "fish"}, []string{"ace king offsuit", "set on paired board"}},
+		{"unknown opponent", HandSearch{Opponent: "nobody"}, []string{}},
+		{"text", HandSearch{Text: "Pocket Aces"}, []string{"straight board", "aces cracked"}},
+		{"every word", HandSearch{Text: "lost aces"}, []string{"aces cracked"}},
+		{"wildcards are literal", HandSearch{Text: "100%"}, []string{}},
+		{"combined", HandSearch{HoleCards: cards("AA"), Board: []BoardTexture{BoardFlushPossible, BoardStraightPossible},
+			MinPot: chips(1000), MaxResult: chips(0), Opponent: "villain", Text: "lost"}, []string{"aces cracked"}},
+		{"combined, one condition failing", HandSearch{HoleCards: cards("AA"), Board: []BoardTexture{BoardFlushPossible},
+			Opponent: "fish"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := repo.SearchHands(hero, tt.search, pagination.PageRequest{Limit: 50})
+			require.NoError(t, err)
+
+			want := []int{}
+			for _, name := range tt.want {
+				want = append(want, hand[name])
+			}
+			assert.Equal(t, want, found(page))
+			assert.False(t, page.HasMore)
+		})
+	}
+
+	t.Run("relevance", func(t *testing.T) {
+		page, err := repo.SearchHands(hero, HandSearch{Text: "aces", Order: HandSearchByRelevance}, pagination.PageRequest{Limit: 50})
+		require.NoError(t, err)
+		assert.Equal(t, []int{hand["straight board"], hand["aces cracked"]}, found(page), "the hand saying aces most often comes first")
+		assert.Greater(t, page.Items[0].Relevance, page.Items[1].Relevance)
+		require.NotNil(t, page.Items[0].Hand)
+		assert.Equal(t, "Search", page.Items[0].Game.Name)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, search := range []HandSearch{
+			{Order: HandSearchByRelevance},
+			{Order: "popularity"},
+			{Board: []BoardTexture{"rainbow"}},
+			{MinPot: chips(10), MaxPot: chips(5)},
+			{MinResult: chips(10), MaxResult: chips(5)},
+		} {
+			_, err := repo.SearchHands(hero, search, pagination.PageRequest{Limit: 50})
+			assert.Error(t, err, "%+v", search)
+		}
+	})
+}
+
+func TestSearchHandsPages(t *testing.T) {
+	db := testutil.NewDB(t, &models.User{}, &models.Game{}, &models.Hand{}, &models.HandHistory{})
+	repo := NewHandHistoryRepository(db)
+	hero, _ := searchFixture(t, db)
+
+	for _, search := range []HandSearch{
+		{},
+		{Text: "and", Order: HandSearchByRelevance},
+	} {
+		all, err := repo.SearchHands(hero, search, pagination.PageRequest{Limit: 50})
+		require.NoError(t, err)
+
+		var got []int
+		page := pagination.PageRequest{Limit: 2}
+		for {
+			result, err := repo.SearchHands(hero, search, page)
+			require.NoError(t, err)
+			got = append(got, found(result)...)
+			if !result.HasMore {
+				break
+			}
+			page.Cursor, err = pagination.DecodeCursor(result.NextCursor)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, found(all), got, "order %q", search.Order)
+	}
+}
+
+func TestParseHoleCards(t *testing.T) {
+	suited, offsuit := true, false
+	for input, want := range map[string]HoleCards{
+		"AA":  {High: "A", Low: "A"},
+		"ak":  {High: "A", Low: "K"},
+		"KAs": {High: "A", Low: "K", Suited: &suited},
+		"T9o": {High: "10", Low: "9", Suited: &offsuit},
+		"2TO": {High: "10", Low: "2", Suited: &offsuit},
+	} {
+		got, err := ParseHoleCards(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, want, got, input)
+	}
+
+	for _, input := range []string{"", "A", "AKx", "AAs", "1A", "AKso"} {
+		_, err := ParseHoleCards(input)
+		assert.Error(t, err, input)
+	}
+}
@@ -0,0 +1,326 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/pagination"
+)
+
+// LeaderboardRepository materializes and reads the daily leaderboards
+type LeaderboardRepository struct {
+	db     *gorm.DB
+	reader *gorm.DB
+}
+
+// NewLeaderboardRepository creates a new leaderboard repository
+func NewLeaderboardRepository(db *gorm.DB) *LeaderboardRepository {
+	return &LeaderboardRepository{db: db, reader: db}
+}
+
+// WithReader returns a repository that reads boards from reader, such as
+// a read replica. Materializing writes, so it stays on the original
+// connection.
+func (r *LeaderboardRepository) WithReader(reader *gorm.DB) *LeaderboardRepository {
+	return &LeaderboardRepository{db: r.db, reader: reader}
+}
+
+// LeaderboardOptions are the rules a day's leaderboards are built with
+type LeaderboardOptions struct {
+	// MinHands is how many hands a player needs to appear on any board
+	MinHands int
+	// SessionGap is the break between hands that starts a new session,
+	// for the winning streak board
+	SessionGap time.Duration
+	// KeepDays is how many days of boards are kept, including day
+	KeepDays int
+	// Now is when a live board's period is counted back from, the
+	// current time when zero
+	Now time.Time
+}
+
+// LeaderboardRank is a player's place on a leaderboard ranked live over
+// a period, out of the players ranked. TopPercent is the share of them
+// placed at or above the player, so the best of twenty is in the top 5%.
+type LeaderboardRank struct {
+	Metric      models.LeaderboardMetric `json:"metric"`
+	UserID      uuid.UUID                `json:"user_id"`
+	Username    string                   `json:"username"`
+	Rank        int                      `json:"rank"`
+	Value       float64                  `json:"value"`
+	HandsPlayed int                      `json:"hands_played"`
+	Players     int                      `json:"players"`
+	TopPercent  float64                  `json:"top_percent"`
+}
+
+// Materialize ranks every eligible player on each leaderboard and stores
+// the boards for day, replacing any built earlier that day. Boards are
+// ranked from players' daily totals, archived hands included, except the
+// winning streak, which walks their hand history. Boards older than the
+// options keep are deleted.
+func (r *LeaderboardRepository) Materialize(day time.Time, opts LeaderboardOptions) error {
+	epoch := epochSeconds(r.db)
+	params := leaderboardParams(time.Time{}, opts)
+	params["day"] = day
+	params["now"] = time.Now()
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("day = ?", day).Delete(&models.LeaderboardEntry{}).Error; err != nil {
+			return err
+		}
+
+		for _, metric := range models.LeaderboardMetrics {
+			params["metric"] = metric
+			ranked := fmt.Sprintf(rankedLeaderboardSQL, leaderboardScores[metric](epoch))
+			if err := tx.Exec(fmt.Sprintf(materializeLeaderboardSQL, ranked), params).Error; err != nil {
+				return fmt.Errorf("failed to build %s leaderboard: %w", metric, err)
+			}
+		}
+
+		if opts.KeepDays > 0 {
+			cutoff := day.AddDate(0, 0, -opts.KeepDays+1)
+			if err := tx.Where("day < ?", cutoff).Delete(&models.LeaderboardEntry{}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// LatestLeaderboardDay returns the day of the newest materialized boards,
+// or the zero time when none have been built yet
+func (r *LeaderboardRepository) LatestLeaderboardDay() (time.Time, error) {
+	var entry models.LeaderboardEntry
+	err := r.reader.Order("day DESC").Limit(1).Find(&entry).Error
+	return entry.Day, err
+}
+
+// GetLeaderboard gets a page of one of a day's boards, best rank first
+func (r *LeaderboardRepository) GetLeaderboard(day time.Time, metric models.LeaderboardMetric, page pagination.PageRequest) (*pagination.PageResponse[models.LeaderboardEntry], error) {
+	var key interface{}
+	if page.Cursor != nil {
+		rank, err := page.Cursor.Int64()
+		if err != nil {
+			return nil, err
+		}
+		key = rank
+	}
+
+	var entries []models.LeaderboardEntry
+	query := r.reader.Where("day = ? AND metric = ?", day, metric)
+	if err := leaderboardRankKeyset.Apply(query, page, key).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	return pagination.NewPage(entries, page, func(entry models.LeaderboardEntry) pagination.Cursor {
+		return pagination.Int64Cursor(int64(entry.Rank), entry.UserID.String())
+	}), nil
+}
+
+// GetLeaderboardEntry gets a player's place on one of a day's boards
+func (r *LeaderboardRepository) GetLeaderboardEntry(day time.Time, metric models.LeaderboardMetric, userID uuid.UUID) (*models.LeaderboardEntry, error) {
+	var entry models.LeaderboardEntry
+	err := r.reader.Where("day = ? AND metric = ? AND user_id = ?", day, metric, userID).First(&entry).Error
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// GetLeaderboardPage gets a page of a leaderboard ranked live from the
+// hands played since period began, best rank first. The board is ranked
+// afresh for each page, so players can move between pages as hands are
+// played.
+func (r *LeaderboardRepository) GetLeaderboardPage(metric models.LeaderboardMetric, period models.LeaderboardPeriod, opts LeaderboardOptions, page pagination.PageRequest) (*pagination.PageResponse[LeaderboardRank], error) {
+	query, err := r.ranked(metric, period, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var key interface{}
+	if page.Cursor != nil {
+		rank, err := page.Cursor.Int64()
+		if err != nil {
+			return nil, err
+		}
+		key = rank
+	}
+
+	var ranks []LeaderboardRank
+	if err := leaderboardRankKeyset.Apply(query, page, key).Find(&ranks).Error; err != nil {
+		return nil, err
+	}
+	for i := range ranks {
+		ranks[i].fill(metric)
+	}
+
+	return pagination.NewPage(ranks, page, func(rank LeaderboardRank) pagination.Cursor {
+		return pagination.Int64Cursor(int64(rank.Rank), rank.UserID.String())
+	}), nil
+}
+
+// GetUserRank gets a player's place on a leaderboard ranked live from the
+// hands played since period began. It returns gorm.ErrRecordNotFound when
+// the player is not ranked.
+func (r *LeaderboardRepository) GetUserRank(metric models.LeaderboardMetric, period models.LeaderboardPeriod, userID uuid.UUID, opts LeaderboardOptions) (*LeaderboardRank, error) {
+	query, err := r.ranked(metric, period, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var rank LeaderboardRank
+	if err := query.Where("user_id = ?", userID).Take(&rank).Error; err != nil {
+		return nil, err
+	}
+	rank.fill(metric)
+	return &rank, nil
+}
+
+// ranked returns a query over a leaderboard ranked live. The metric picks
+// one of the fixed scores CTEs, never SQL from the caller, so an unknown
+// one is refused rather than built.
+func (r *LeaderboardRepository) ranked(metric models.LeaderboardMetric, period models.LeaderboardPeriod, opts LeaderboardOptions) (*gorm.DB, error) {
+	scores, ok := leaderboardScores[metric]
+	if !ok {
+		return nil, ErrUnknownLeaderboard
+	}
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	since, ok := period.Start(now)
+	if !ok {
+		return nil, ErrUnknownLeaderboardPeriod
+	}
+
+	ranked := r.reader.Raw(fmt.Sprintf(rankedLeaderboardSQL, scores(epochSeconds(r.reader))), leaderboardParams(since, opts))
+	return r.reader.Table("(?) AS ranked", ranked), nil
+}
+
+// fill sets the rank's metric and works out its top percent
+func (r *LeaderboardRank) fill(metric models.LeaderboardMetric) {
+	r.Metric = metric
+	if r.Players > 0 {
+		r.TopPercent = float64(r.Rank) * 100 / float64(r.Players)
+	}
+}
+
+// leaderboardParams are the named parameters of rankedLeaderboardSQL,
+// counting hands from since
+func leaderboardParams(since time.Time, opts LeaderboardOptions) map[string]interface{} {
+	return map[string]interface{}{
+		"since":     since,
+		"min_hands": opts.MinHands,
+		"gap":       int64(opts.SessionGap / time.Second),
+	}
+}
+
+// leaderboardRankKeyset orders a board by rank, which is unique within it
+var leaderboardRankKeyset = pagination.Keyset{Column: "rank", IDColumn: "user_id"}
+
+// materializeLeaderboardSQL stores a ranked board, filled in from
+// rankedLeaderboardSQL, as the day's entries
+const materializeLeaderboardSQL = `
+INSERT INTO leaderboard_entries (day, metric, user_id, rank, username, value, hands_played, created_at)
+SELECT @day, @metric, user_id, rank, username, value, hands_played, @now
+FROM (%s) AS ranked`
+
+// rankedLeaderboardSQL ranks the players in a scores CTE, filled in per
+// metric, who have played enough hands since @since. Ties go to the
+// player with more hands and then to the lower user ID, so ranks are
+// stable between runs; each row also counts the players ranked.
+const rankedLeaderboardSQL = `
+WITH eligible AS (
+	SELECT user_id, SUM(hands) AS hands
+	FROM player_stat_aggregates
+	WHERE day >= @since
+	GROUP BY user_id
+	HAVING SUM(hands) >= @min_hands
+),
+%s
+SELECT scores.user_id, users.username, scores.value, eligible.hands AS hands_played,
+	ROW_NUMBER() OVER (ORDER BY scores.value DESC, eligible.hands DESC, scores.user_id ASC) AS rank,
+	COUNT(*) OVER () AS players
+FROM scores
+JOIN eligible ON eligible.user_id = scores.user_id
+JOIN users ON users.id = scores.user_id
+WHERE users.deleted_at IS NULL AND NOT users.is_banned`
+
+// leaderboardScores builds each board's scores CTE, giving every player
+// a value to rank by from the hands since @since. Its keys are the only
+// metrics a board can be ranked by. epoch converts a timestamp column to Unix seconds.
+var leaderboardScores = map[models.LeaderboardMetric]func(epoch func(column string) string) string{
+	models.LeaderboardBBPer100: func(func(string) string) string {
+		return `scores AS (
+	SELECT user_id, SUM(big_blinds_won) * 100.0 / SUM(hands) AS value
+	FROM player_stat_aggregates
+	WHERE day >= @since
+	GROUP BY user_id
+)`
+	},
+
+	models.LeaderboardBiggestPot: func(func(string) string) string {
+		return `scores AS (
+	SELECT user_id, MAX(biggest_pot_won) * 1.0 AS value
+	FROM player_stat_aggregates
+	WHERE biggest_pot_won > 0 AND day >= @since
+	GROUP BY user_id
+)`
+	},
+
+	models.LeaderboardShowdownWinRate: func(func(string) string) string {
+		return `scores AS (
+	SELECT user_id, SUM(showdowns_won) * 100.0 / SUM(showdowns) AS value
+	FROM player_stat_aggregates
+	WHERE showdowns > 0 AND day >= @since
+	GROUP BY user_id
+)`
+	},
+
+	// Sessions split where a hand starts at least the gap after every
+	// earlier hand has finished, as in the sessions report. A streak is a
+	// run of consecutive sessions each won: numbering the winning sessions
+	// leaves the same difference from the session number along each run.
+	models.LeaderboardWinningStreak: func(epoch func(string) string) string {
+		return `hand_gaps AS (
+	SELECT id, user_id, started_at, net_result,
+		MAX(finished_at) OVER (PARTITION BY user_id ORDER BY started_at, id ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING) AS previous_finish
+	FROM hand_histories
+	WHERE deleted_at IS NULL AND NOT against_bots AND started_at >= @since
+),
+session_hands AS (
+	SELECT user_id, net_result,
+		SUM(CASE WHEN previous_finish IS NULL OR ` + epoch("started_at") + ` - ` + epoch("previous_finish") + ` >= @gap THEN 1 ELSE 0 END)
+			OVER (PARTITION BY user_id ORDER BY started_at, id ROWS UNBOUNDED PRECEDING) AS session
+	FROM hand_gaps
+),
+sessions AS (
+	SELECT user_id, session, SUM(net_result) AS net
+	FROM session_hands
+	GROUP BY user_id, session
+),
+winning_runs AS (
+	SELECT user_id, session - ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY session) AS run
+	FROM sessions
+	WHERE net > 0
+),
+scores AS (
+	SELECT user_id, MAX(length) * 1.0 AS value
+	FROM (SELECT user_id, run, COUNT(*) AS length FROM winning_runs GROUP BY user_id, run) runs
+	GROUP BY user_id
+)`
+	},
+}
+
+// epochSeconds returns a function converting a timestamp column to Unix
+// seconds in the database's SQL dialect
+func epochSeconds(db *gorm.DB) func(column string) string {
+	if db.Dialector.Name() == "postgres" {
+		return func(column string) string { return "EXTRACT(EPOCH FROM " + column + ")" }
+	}
+	return func(column string) string { return "CAST(strftime('%s', " + column + ") AS INTEGER)" }
+}
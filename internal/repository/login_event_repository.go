@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/pagination"
+	"gorm.io/gorm"
+)
+
+// LoginEventRepository handles login history database operations
+type LoginEventRepository struct {
+	db *gorm.DB
+}
+
+// NewLoginEventRepository creates a new login event repository
+func NewLoginEventRepository(db *gorm.DB) *LoginEventRepository {
+	return &LoginEventRepository{db: db}
+}
+
+// Create records a login event
+func (r *LoginEventRepository) Create(event *models.LoginEvent) error {
+	return r.db.Create(event).Error
+}
+
+// ListByUser lists a user's login events, most recent first
+func (r *LoginEventRepository) ListByUser(userID uuid.UUID, page pagination.PageRequest) (*pagination.PageResponse[models.LoginEvent], error) {
+	var key interface{}
+	if page.Cursor != nil {
+		createdAt, err := page.Cursor.Time()
+		if err != nil {
+			return nil, err
+		}
+		key = createdAt
+	}
+
+	var events []models.LoginEvent
+	query := r.db.Where("user_id = ?", userID)
+	err := loginEventKeyset.Apply(query, page, key).Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return pagination.NewPage(events, page, func(event models.LoginEvent) pagination.Cursor {
+		return pagination.TimeCursor(event.CreatedAt, event.ID.String())
+	}), nil
+}
+
+// loginEventKeyset lists login history newest first
+var loginEventKeyset = pagination.Keyset{Column: "created_at", IDColumn: "id", Descending: true}
+
+// IsNewDevice checks if a user has never signed in successfully from a
+// device fingerprint before. A user with no successful sign-ins at all has
+// nothing to compare against, so firstLogin is reported instead.
+func (r *LoginEventRepository) IsNewDevice(userID uuid.UUID, fingerprint string) (isNew, firstLogin bool, err error) {
+	var successes int64
+	err = r.db.Model(&models.LoginEvent{}).
+		Where("user_id = ? AND outcome = ?", userID, models.LoginSucceeded).
+		Count(&successes).Error
+	if err != nil || successes == 0 {
+		return false, successes == 0, err
+	}
+
+	var matches int64
+	err = r.db.Model(&models.LoginEvent{}).
+		Where("user_id = ? AND outcome = ? AND device_fingerprint = ?", userID, models.LoginSucceeded, fingerprint).
+		Count(&matches).Error
+	return matches == 0, false, err
+}
+
+// DeleteBefore deletes login events older than cutoff
+func (r *LoginEventRepository) DeleteBefore(cutoff time.Time) (int64, error) {
+	result := r.db.Where("created_at < ?", cutoff).Delete(&models.LoginEvent{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/pagination"
+	"github.com/primoPoker/server/internal/testutil"
+)
+
+func newLoginEvent(userID uuid.UUID, fingerprint string, outcome models.LoginOutcome, at time.Time) *models.LoginEvent {
+	return &models.LoginEvent{
+		UserID:            &userID,
+		Method:            "password",
+		IPAddress:         "192.0.2.1",
+		Outcome:           outcome,
+		DeviceFingerprint: fingerprint,
+		CreatedAt:         at,
+	}
+}
+
+func TestLoginEventListByUser(t *testing.T) {
+	repo := NewLoginEventRepository(testutil.NewDB(t, &models.LoginEvent{}))
+
+	alice, bob := uuid.New(), uuid.New()
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, repo.Create(newLoginEvent(alice, "laptop", models.LoginSucceeded, start.Add(time.Duration(i)*time.Minute))))
+	}
+	require.NoError(t, repo.Create(newLoginEvent(bob, "phone", models.LoginSucceeded, start)))
+
+	first, err := repo.ListByUser(alice, pagination.PageRequest{Limit: 3})
+	require.NoError(t, err)
+	require.Len(t, first.Items, 3)
+	assert.True(t, first.HasMore)
+	assert.True(t, first.Items[0].CreatedAt.After(first.Items[1].CreatedAt), "newest first")
+
+	cursor, err := pagination.DecodeCursor(first.NextCursor)
+	require.NoError(t, err)
+	second, err := repo.ListByUser(alice, pagination.PageRequest{Limit: 3, Cursor: cursor})
+	require.NoError(t, err)
+	assert.Len(t, second.Items, 2)
+	assert.False(t, second.HasMore)
+
+	for _, event := range append(first.Items, second.Items...) {
+		assert.Equal(t, alice, *event.UserID)
+	}
+}
+
+func TestLoginEventIsNewDevice(t *testing.T) {
+	repo := NewLoginEventRepository(testutil.NewDB(t, &models.LoginEvent{}))
+	userID := uuid.New()
+
+	isNew, first, err := repo.IsNewDevice(userID, "laptop")
+	require.NoError(t, err)
+	assert.False(t, isNew)
+	assert.True(t, first)
+
+	// Failed attempts do not make a device known
+	require.NoError(t, repo.Create(newLoginEvent(userID, "laptop", models.LoginInvalidCredentials, time.Now())))
+	_, first, err = repo.IsNewDevice(userID, "laptop")
+	require.NoError(t, err)
+	assert.True(t, first)
+
+	require.NoError(t, repo.Create(newLoginEvent(userID, "laptop", models.LoginSucceeded, time.Now())))
+	isNew, first, err = repo.IsNewDevice(userID, "laptop")
+	require.NoError(t, err)
+	assert.False(t, isNew)
+	assert.False(t, first)
+
+	isNew, _, err = repo.IsNewDevice(userID, "phone")
+	require.NoError(t, err)
+	assert.True(t, isNew)
+}
+
+func TestLoginEventDeleteBefore(t *testing.T) {
+	repo := NewLoginEventRepository(testutil.NewDB(t, &models.LoginEvent{}))
+	userID := uuid.New()
+
+	now := time.Now()
+	require.NoError(t, repo.Create(newLoginEvent(userID, "laptop", models.LoginSucceeded, now.Add(-100*24*time.Hour))))
+	require.NoError(t, repo.Create(newLoginEvent(userID, "laptop", models.LoginSucceeded, now.Add(-time.Hour))))
+
+	deleted, err := repo.DeleteBefore(now.Add(-90 * 24 * time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	page, err := repo.ListByUser(userID, pagination.PageRequest{Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, page.Items, 1)
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/primoPoker/server/internal/models"
+)
+
+// PlayerStatRepository handles the stored running totals behind player
+// metrics
+type PlayerStatRepository struct {
+	db *gorm.DB
+}
+
+// NewPlayerStatRepository creates a new player stat repository
+func NewPlayerStatRepository(db *gorm.DB) *PlayerStatRepository {
+	return &PlayerStatRepository{db: db}
+}
+
+// GetUserAggregates gets a user's totals for every day from the one
+// starting at from
+func (r *PlayerStatRepository) GetUserAggregates(userID uuid.UUID, from time.Time) ([]models.PlayerStatAggregate, error) {
+	var aggregates []models.PlayerStatAggregate
+	err := r.db.Where("user_id = ? AND day >= ?", userID, from).
+		Order("day ASC").
+		Find(&aggregates).Error
+	return aggregates, err
+}
+
+// GetUserAggregatesBetween gets a user's totals for the days from the one
+// starting at from up to, but not including, the one starting at to
+func (r *PlayerStatRepository) GetUserAggregatesBetween(userID uuid.UUID, from, to time.Time) ([]models.PlayerStatAggregate, error) {
+	var aggregates []models.PlayerStatAggregate
+	err := r.db.Where("user_id = ? AND day >= ? AND day < ?", userID, from, to).
+		Order("day ASC").
+		Find(&aggregates).Error
+	return aggregates, err
+}
+
+// DailyTotal is a user's totals for one day, summed over every stake
+type DailyTotal struct {
+	Day          time.Time `json:"day"`
+	Hands        int       `json:"hands"`
+	HandsWon     int       `json:"hands_won"`
+	Showdowns    int       `json:"showdowns"`
+	VPIPHands    int       `json:"vpip_hands"`
+	NetResult    int64     `json:"net_result"`
+	BigBlindsWon float64   `json:"big_blinds_won"`
+	RakePaid     int64     `json:"rake_paid"`
+}
+
+// GetDailyTotals sums a user's totals per day, for the days from the one
+// starting at from up to, but not including, the one starting at to
+func (r *PlayerStatRepository) GetDailyTotals(userID uuid.UUID, from, to time.Time) ([]DailyTotal, error) {
+	var totals []DailyTotal
+	err := r.db.Model(&models.PlayerStatAggregate{}).
+		Select(`day, SUM(hands) AS hands, SUM(hands_won) AS hands_won, SUM(showdowns) AS showdowns,
+			SUM(vpip_hands) AS vpip_hands, SUM(total_won - total_wagered) AS net_result,
+			SUM(big_blinds_won) AS big_blinds_won, SUM(rake_paid) AS rake_paid`).
+		Where("user_id = ? AND day >= ? AND day < ?", userID, from, to).
+		Group("day").
+		Order("day ASC").
+		Scan(&totals).Error
+	return totals, err
+}
+
+// LockAggregate gets the totals row with the key of the given one within
+// tx, creating it empty first if needed. The row stays locked until tx
+// ends.
+func (r *PlayerStatRepository) LockAggregate(tx *gorm.DB, key models.PlayerStatAggregate) (*models.PlayerStatAggregate, error) {
+	empty := models.PlayerStatAggregate{
+		UserID:     key.UserID,
+		Day:        key.Day,
+		GameType:   key.GameType,
+		SmallBlind: key.SmallBlind,
+		BigBlind:   key.BigBlind,
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&empty).Error; err != nil {
+		return nil, err
+	}
+
+	var aggregate models.PlayerStatAggregate
+	err := whereAggregateKey(tx.Clauses(clause.Locking{Strength: "UPDATE"}), &empty).First(&aggregate).Error
+	if err != nil {
+		return nil, err
+	}
+	return &aggregate, nil
+}
+
+// SaveAggregate writes a locked totals row back within tx
+func (r *PlayerStatRepository) SaveAggregate(tx *gorm.DB, aggregate *models.PlayerStatAggregate) error {
+	return whereAggregateKey(tx.Model(&models.PlayerStatAggregate{}), aggregate).
+		Select("*").
+		Updates(aggregate).Error
+}
+
+// ReplaceUserAggregates swaps all of a user's totals for the given rows
+func (r *PlayerStatRepository) ReplaceUserAggregates(userID uuid.UUID, aggregates []models.PlayerStatAggregate) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.PlayerStatAggregate{}).Error; err != nil {
+			return err
+		}
+		if len(aggregates) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(aggregates, 500).Error
+	})
+}
+
+// whereAggregateKey narrows a query to the row with the aggregate's key
+func whereAggregateKey(db *gorm.DB, aggregate *models.PlayerStatAggregate) *gorm.DB {
+	return db.Where("user_id = ? AND day = ? AND game_type = ? AND small_blind = ? AND big_blind = ?",
+		aggregate.UserID, aggregate.Day, aggregate.GameType, aggregate.SmallBlind, aggregate.BigBlind)
+}
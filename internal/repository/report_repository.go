@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/pagination"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReportRepository handles player report database operations
+type ReportRepository struct {
+	db *gorm.DB
+}
+
+// NewReportRepository creates a new report repository
+func NewReportRepository(db *gorm.DB) *ReportRepository {
+	return &ReportRepository{db: db}
+}
+
+// Create creates a new report
+func (r *ReportRepository) Create(report *models.PlayerReport) error {
+	return r.db.Create(report).Error
+}
+
+// GetByID gets a report by ID
+func (r *ReportRepository) GetByID(id uuid.UUID) (*models.PlayerReport, error) {
+	var report models.PlayerReport
+	err := r.db.First(&report, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// CountByReporterSince counts the reports a user has filed since the given time
+func (r *ReportRepository) CountByReporterSince(reporterID uuid.UUID, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.PlayerReport{}).
+		Where("reporter_id = ? AND created_at > ?", reporterID, since).
+		Count(&count).Error
+	return count, err
+}
+
+// ListByStatus gets a page of reports with the given status, oldest first
+func (r *ReportRepository) ListByStatus(status models.ReportStatus, page pagination.PageRequest) (*pagination.PageResponse[models.PlayerReport], error) {
+	var key interface{}
+	if page.Cursor != nil {
+		createdAt, err := page.Cursor.Time()
+		if err != nil {
+			return nil, err
+		}
+		key = createdAt
+	}
+
+	var reports []models.PlayerReport
+	query := r.db.Omit("context").Where("status = ?", status)
+	err := reportQueueKeyset.Apply(query, page, key).Find(&reports).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return pagination.NewPage(reports, page, func(report models.PlayerReport) pagination.Cursor {
+		return pagination.TimeCursor(report.CreatedAt, report.ID.String())
+	}), nil
+}
+
+// reportQueueKeyset works through reports in the order they were filed
+var reportQueueKeyset = pagination.Keyset{Column: "created_at", IDColumn: "id"}
+
+// Resolve closes an open report with an outcome. A ban outcome bans the
+// subject in the same transaction, so a report is never marked resolved
+// without its outcome being applied. audit, if given, is recorded in that
+// transaction too.
+func (r *ReportRepository) Resolve(id uuid.UUID, outcome models.ReportOutcome, note, resolvedBy string, audit *models.AuditLog) (*models.PlayerReport, error) {
+	var report models.PlayerReport
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&report, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if report.Status != models.ReportStatusOpen {
+			return ErrReportResolved
+		}
+
+		if outcome == models.ReportOutcomeBan {
+			if err := NewUserRepository(tx).BanUser(report.SubjectID, note); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		report.Status = models.ReportStatusResolved
+		report.Outcome = outcome
+		report.ResolutionNote = note
+		report.ResolvedBy = resolvedBy
+		report.ResolvedAt = &now
+
+		err := tx.Model(&report).Updates(map[string]interface{}{
+			"status":          report.Status,
+			"outcome":         report.Outcome,
+			"resolution_note": report.ResolutionNote,
+			"resolved_by":     report.ResolvedBy,
+			"resolved_at":     report.ResolvedAt,
+		}).Error
+		if err != nil || audit == nil {
+			return err
+		}
+		return NewAuditLogRepository(tx).Create(audit)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
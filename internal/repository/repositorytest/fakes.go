@@ -0,0 +1,585 @@
+// Package repositorytest provides in-memory fakes of the repository stores
+// for tests of code that reads through them, such as the HTTP handlers and
+// the metrics service, so those tests need no database.
+package repositorytest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/pagination"
+	"github.com/primoPoker/server/internal/repository"
+)
+
+var (
+	_ repository.UserStore        = (*Users)(nil)
+	_ repository.GameStore        = (*Games)(nil)
+	_ repository.HandHistoryStore = (*HandHistories)(nil)
+)
+
+// Users is a UserStore holding users in memory. Every method fails with
+// Err when it is set.
+type Users struct {
+	mu             sync.Mutex
+	users          map[uuid.UUID]models.User
+	participations map[uuid.UUID][]models.GameParticipation
+	hands          *HandHistories
+
+	Err error
+}
+
+// NewUsers creates a store holding users. Account exports include the
+// user's hands in hands, which may be nil.
+func NewUsers(hands *HandHistories, users ...models.User) *Users {
+	s := &Users{
+		users:          make(map[uuid.UUID]models.User),
+		participations: make(map[uuid.UUID][]models.GameParticipation),
+		hands:          hands,
+	}
+	for _, user := range users {
+		s.Add(user)
+	}
+	return s
+}
+
+// Add stores user, replacing any user with the same ID
+func (s *Users) Add(user models.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[user.ID] = user
+}
+
+// AddParticipation stores a seat the user took at a game
+func (s *Users) AddParticipation(participation models.GameParticipation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.participations[participation.UserID] = append(s.participations[participation.UserID], participation)
+}
+
+// GetByID gets a user by ID
+func (s *Users) GetByID(id uuid.UUID) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	user, ok := s.users[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &user, nil
+}
+
+// GetByUsername gets a user by username
+func (s *Users) GetByUsername(username string) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	for _, user := range s.users {
+		if user.Username == username {
+			return &user, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// GetTopPlayers gets a page of users by total winnings, highest first
+func (s *Users) GetTopPlayers(page pagination.PageRequest) (*pagination.PageResponse[models.User], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Err != nil {
+		return nil, s.Err
+	}
+
+	users := make([]models.User, 0, len(s.users))
+	for _, user := range s.users {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].TotalWinnings != users[j].TotalWinnings {
+			return users[i].TotalWinnings > users[j].TotalWinnings
+		}
+		return users[i].ID.String() > users[j].ID.String()
+	})
+
+	return pageOf(users, page, func(user models.User) pagination.Cursor {
+		return pagination.Int64Cursor(user.TotalWinnings, user.ID.String())
+	})
+}
+
+// ExportAccount passes the user and their participations to fn, then
+// their hands to handFn in batches
+func (s *Users) ExportAccount(userID uuid.UUID, batchSize int, fn func(*models.User, []models.GameParticipation) error, handFn func([]models.HandHistory) error) error {
+	user, err := s.GetByID(userID)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	participations := append([]models.GameParticipation(nil), s.participations[userID]...)
+	s.mu.Unlock()
+
+	if err := fn(user, participations); err != nil {
+		return err
+	}
+	if s.hands == nil {
+		return nil
+	}
+
+	far := time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+	return s.hands.StreamUserHands(userID, time.Time{}, far, batchSize, handFn)
+}
+
+// Games is a GameStore holding finished games in memory
+type Games struct {
+	mu      sync.Mutex
+	history []models.Game
+	players map[uuid.UUID][]uuid.UUID
+
+	Err error
+}
+
+// NewGames creates a store with no games
+func NewGames() *Games {
+	return &Games{players: make(map[uuid.UUID][]uuid.UUID)}
+}
+
+// AddFinished stores a finished game the given users played in
+func (s *Games) AddFinished(game models.Game, players ...uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.history = append(s.history, game)
+	s.players[game.ID] = players
+}
+
+// GetGameHistory gets a page of finished games, most recently finished
+// first, optionally only those userID played in
+func (s *Games) GetGameHistory(page pagination.PageRequest, userID *uuid.UUID) (*pagination.PageResponse[models.Game], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Err != nil {
+		return nil, s.Err
+	}
+
+	games := make([]models.Game, 0, len(s.history))
+	for _, game := range s.history {
+		if userID == nil || containsID(s.players[game.ID], *userID) {
+			games = append(games, game)
+		}
+	}
+	sort.Slice(games, func(i, j int) bool {
+		a, b := finishedAt(games[i]), finishedAt(games[j])
+		if !a.Equal(b) {
+			return a.After(b)
+		}
+		return games[i].ID.String() > games[j].ID.String()
+	})
+
+	return pageOf(games, page, func(game models.Game) pagination.Cursor {
+		return pagination.TimeCursor(finishedAt(game), game.ID.String())
+	})
+}
+
+func finishedAt(game models.Game) time.Time {
+	if game.FinishedAt == nil {
+		return time.Time{}
+	}
+	return *game.FinishedAt
+}
+
+// HandHistories is a HandHistoryStore holding hands, archived hands and
+// summaries in memory. Starting hand totals are not worked out from the
+// hands; GetStartingHandTotals returns StartingHands. Searches are not run
+// either; SearchHands keeps the search in LastSearch and finds every hand.
+type HandHistories struct {
+	mu        sync.Mutex
+	hands     []models.HandHistory
+	archived  []models.HandHistory
+	summaries []models.HandSummary
+
+	StartingHands []repository.StartingHandTotals
+	LastSearch    *repository.HandSearch
+	Err           error
+}
+
+// NewHandHistories creates a store holding hands
+func NewHandHistories(hands ...models.HandHistory) *HandHistories {
+	s := &HandHistories{}
+	s.Add(hands...)
+	return s
+}
+
+// Add stores live hands
+func (s *HandHistories) Add(hands ...models.HandHistory) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hands = append(s.hands, hands...)
+}
+
+// AddArchived stores hands that have been moved to the archive
+func (s *HandHistories) AddArchived(hands ...models.HandHistory) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.archived = append(s.archived, hands...)
+}
+
+// AddSummary stores a summary
+func (s *HandHistories) AddSummary(summary models.HandSummary) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.summaries = append(s.summaries, summary)
+}
+
+// GetByID gets a live hand by ID
+func (s *HandHistories) GetByID(id uuid.UUID) (*models.HandHistory, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	for _, hand := range s.hands {
+		if hand.ID == id {
+			return &hand, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// GetUserHandHistory gets a page of a user's hands, newest first
+func (s *HandHistories) GetUserHandHistory(userID uuid.UUID, page pagination.PageRequest) (*pagination.PageResponse[models.HandHistory], error) {
+	hands, err := s.userHands(s.hands, userID, func(*models.HandHistory) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+
+	// Newest first is the reverse of the order hands are kept in
+	for i, j := 0, len(hands)-1; i < j; i, j = i+1, j-1 {
+		hands[i], hands[j] = hands[j], hands[i]
+	}
+
+	return pageOf(hands, page, func(hand models.HandHistory) pagination.Cursor {
+		return pagination.TimeCursor(hand.StartedAt, hand.ID.String())
+	})
+}
+
+// GetHandsByTimeRange gets a user's hands started between startTime and
+// endTime, oldest first
+func (s *HandHistories) GetHandsByTimeRange(userID uuid.UUID, startTime, endTime time.Time) ([]models.HandHistory, error) {
+	return s.userHands(s.hands, userID, startedBetween(startTime, endTime))
+}
+
+// GetLastFinishedBefore gets the user's hand that finished last among those
+// started before the given hand, or nil when there are none
+func (s *HandHistories) GetLastFinishedBefore(userID uuid.UUID, startedAt time.Time, id uuid.UUID) (*models.HandHistory, error) {
+	earlier, err := s.userHands(s.hands, userID, func(hand *models.HandHistory) bool {
+		return handBefore(hand.StartedAt, hand.ID, startedAt, id)
+	})
+	if err != nil || len(earlier) == 0 {
+		return nil, err
+	}
+
+	last := earlier[0]
+	for _, hand := range earlier[1:] {
+		if hand.FinishedAt.After(last.FinishedAt) {
+			last = hand
+		}
+	}
+	return &last, nil
+}
+
+// GetHandsParticipants gets every player's row for the given hands, where
+// gameIDs[i] and handNumbers[i] identify one hand
+func (s *HandHistories) GetHandsParticipants(gameIDs []uuid.UUID, handNumbers []int) ([]models.HandHistory, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Err != nil {
+		return nil, s.Err
+	}
+
+	var rows []models.HandHistory
+	for _, hand := range s.hands {
+		for i, gameID := range gameIDs {
+			if hand.GameID == gameID && hand.HandNumber == handNumbers[i] {
+				rows = append(rows, hand)
+				break
+			}
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].SeatPosition < rows[j].SeatPosition })
+	return rows, nil
+}
+
+// GetSharedHands gets both players' rows of every hand the two were dealt
+// into together, in the order the hands started
+func (s *HandHistories) GetSharedHands(userID, opponentID uuid.UUID) ([]models.HandHistory, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Err != nil {
+		return nil, s.Err
+	}
+
+	type handKey struct {
+		gameID     uuid.UUID
+		handNumber int
+	}
+	seated := make(map[handKey]map[uuid.UUID]bool)
+	for _, hand := range s.hands {
+		key := handKey{hand.GameID, hand.HandNumber}
+		if seated[key] == nil {
+			seated[key] = make(map[uuid.UUID]bool)
+		}
+		seated[key][hand.UserID] = true
+	}
+
+	var rows []models.HandHistory
+	for _, hand := range s.hands {
+		players := seated[handKey{hand.GameID, hand.HandNumber}]
+		if (hand.UserID == userID || hand.UserID == opponentID) && players[userID] && players[opponentID] {
+			rows = append(rows, hand)
+		}
+	}
+	sortOldestFirst(rows)
+	return rows, nil
+}
+
+// GetStartingHandTotals returns StartingHands
+func (s *HandHistories) GetStartingHandTotals(userID uuid.UUID, position string, from, to time.Time) ([]repository.StartingHandTotals, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	return s.StartingHands, nil
+}
+
+// SearchHands validates the search and keeps it in LastSearch, then pages
+// through all the user's hands, newest first
+func (s *HandHistories) SearchHands(userID uuid.UUID, search repository.HandSearch, page pagination.PageRequest) (*pagination.PageResponse[repository.HandSearchResult], error) {
+	if err := search.Validate(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.LastSearch = &search
+	s.mu.Unlock()
+
+	hands, err := s.GetUserHandHistory(userID, page)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]repository.HandSearchResult, len(hands.Items))
+	for i, hand := range hands.Items {
+		results[i] = repository.HandSearchResult{HandHistory: hand}
+	}
+	return &pagination.PageResponse[repository.HandSearchResult]{Items: results, NextCursor: hands.NextCursor, HasMore: hands.HasMore}, nil
+}
+
+// GetSummaries gets a user's summaries of one length starting in
+// [from, to), oldest first
+func (s *HandHistories) GetSummaries(userID uuid.UUID, period models.SummaryPeriod, from, to time.Time) ([]models.HandSummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Err != nil {
+		return nil, s.Err
+	}
+
+	var summaries []models.HandSummary
+	for _, summary := range s.summaries {
+		if summary.UserID == userID && summary.Period == period &&
+			!summary.PeriodStart.Before(from) && summary.PeriodStart.Before(to) {
+			summaries = append(summaries, summary)
+		}
+	}
+	sort.SliceStable(summaries, func(i, j int) bool { return summaries[i].PeriodStart.Before(summaries[j].PeriodStart) })
+	return summaries, nil
+}
+
+// GetUserStats totals a user's live hands passing filter. Hands filtered
+// by game type are matched on their preloaded Game.
+func (s *HandHistories) GetUserStats(userID uuid.UUID, filter repository.StatsFilter) (*models.HandSummary, error) {
+	hands, err := s.userHands(s.hands, userID, func(hand *models.HandHistory) bool {
+		return statsFilterKeeps(filter, hand)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &models.HandSummary{UserID: userID, TotalHands: len(hands)}
+	if filter.Since != nil {
+		summary.PeriodStart = *filter.Since
+	}
+	for _, hand := range hands {
+		switch {
+		case hand.IsWinner:
+			summary.HandsWon++
+		case hand.FoldedPhase != "":
+			summary.HandsFolded++
+		}
+		summary.TotalWagered += hand.StartingChips - hand.EndingChips + hand.AmountWon
+		summary.TotalWon += hand.AmountWon
+		summary.AvgPotSize += float64(hand.PotSize)
+		summary.VPIPPercent += hand.VPIPPercent
+		summary.PFRPercent += hand.PFRPercent
+		summary.AggressionFactor += hand.AggressionFactor
+	}
+	summary.HandsLost = summary.TotalHands - summary.HandsWon - summary.HandsFolded
+	summary.NetResult = summary.TotalWon - summary.TotalWagered
+
+	if n := float64(len(hands)); n > 0 {
+		summary.AvgPotSize /= n
+		summary.VPIPPercent /= n
+		summary.PFRPercent /= n
+		summary.AggressionFactor /= n
+		summary.WinRate = float64(summary.HandsWon) / n * 100.0
+		if summary.HandsWon > 0 {
+			summary.AvgWinAmount = float64(summary.TotalWon) / float64(summary.HandsWon)
+		}
+	}
+	return summary, nil
+}
+
+// StreamUserHands hands a user's live hands started between from and to to
+// fn in batches, oldest first
+func (s *HandHistories) StreamUserHands(userID uuid.UUID, from, to time.Time, batchSize int, fn func([]models.HandHistory) error) error {
+	hands, err := s.userHands(s.hands, userID, startedBetween(from, to))
+	if err != nil {
+		return err
+	}
+	return inBatches(hands, batchSize, fn)
+}
+
+// StreamUserArchivedHands hands a user's archived hands started between
+// from and to to fn in batches, oldest first
+func (s *HandHistories) StreamUserArchivedHands(userID uuid.UUID, from, to time.Time, batchSize int, fn func([]models.HandHistory) error) error {
+	hands, err := s.userHands(s.archived, userID, startedBetween(from, to))
+	if err != nil {
+		return err
+	}
+	return inBatches(hands, batchSize, fn)
+}
+
+// userHands copies the user's hands in from that keep accepts, oldest first
+func (s *HandHistories) userHands(from []models.HandHistory, userID uuid.UUID, keep func(*models.HandHistory) bool) ([]models.HandHistory, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Err != nil {
+		return nil, s.Err
+	}
+
+	var hands []models.HandHistory
+	for i := range from {
+		if from[i].UserID == userID && keep(&from[i]) {
+			hands = append(hands, from[i])
+		}
+	}
+	sortOldestFirst(hands)
+	return hands, nil
+}
+
+func startedBetween(from, to time.Time) func(*models.HandHistory) bool {
+	return func(hand *models.HandHistory) bool {
+		return !hand.StartedAt.Before(from) && !hand.StartedAt.After(to)
+	}
+}
+
+// statsFilterKeeps reports whether a hand passes a stats filter
+func statsFilterKeeps(filter repository.StatsFilter, hand *models.HandHistory) bool {
+	if filter.Since != nil && hand.StartedAt.Before(*filter.Since) {
+		return false
+	}
+	if filter.GameType != "" && hand.Game.GameType != filter.GameType {
+		return false
+	}
+	if filter.MinBigBlind > 0 && hand.BigBlind < filter.MinBigBlind {
+		return false
+	}
+	if filter.MaxBigBlind > 0 && hand.BigBlind > filter.MaxBigBlind {
+		return false
+	}
+	if fewest, most, ok := filter.TableSize.PlayersDealtIn(); ok {
+		if hand.PlayersDealtIn < fewest || (most > 0 && hand.PlayersDealtIn > most) {
+			return false
+		}
+	}
+	return !filter.WinnersOnly || hand.IsWinner
+}
+
+// handBefore reports whether one hand comes before another in
+// (started_at, id) order
+func handBefore(startedAt time.Time, id uuid.UUID, otherStartedAt time.Time, otherID uuid.UUID) bool {
+	if !startedAt.Equal(otherStartedAt) {
+		return startedAt.Before(otherStartedAt)
+	}
+	return id.String() < otherID.String()
+}
+
+func sortOldestFirst(hands []models.HandHistory) {
+	sort.SliceStable(hands, func(i, j int) bool {
+		return handBefore(hands[i].StartedAt, hands[i].ID, hands[j].StartedAt, hands[j].ID)
+	})
+}
+
+func inBatches(hands []models.HandHistory, batchSize int, fn func([]models.HandHistory) error) error {
+	for start := 0; start < len(hands); start += batchSize {
+		end := start + batchSize
+		if end > len(hands) {
+			end = len(hands)
+		}
+		if err := fn(hands[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pageOf cuts the page asked for out of items, which are in list order.
+// A cursor resumes after the item it was made from.
+func pageOf[T any](items []T, page pagination.PageRequest, cursorFor func(T) pagination.Cursor) (*pagination.PageResponse[T], error) {
+	start := 0
+	switch {
+	case page.Cursor != nil:
+		start = len(items)
+		for i, item := range items {
+			if cursorFor(item) == *page.Cursor {
+				start = i + 1
+				break
+			}
+		}
+	case page.Offset > 0:
+		start = page.Offset
+	}
+	if start > len(items) {
+		start = len(items)
+	}
+
+	end := start + page.Limit + 1
+	if end > len(items) {
+		end = len(items)
+	}
+	return pagination.NewPage(append([]T(nil), items[start:end]...), page, cursorFor), nil
+}
+
+func containsID(ids []uuid.UUID, id uuid.UUID) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,237 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/primoPoker/server/internal/models"
+)
+
+// RetentionRepository removes soft-deleted records for good and moves old
+// hand histories to the archive
+type RetentionRepository struct {
+	db *gorm.DB
+}
+
+// NewRetentionRepository creates a new retention repository
+func NewRetentionRepository(db *gorm.DB) *RetentionRepository {
+	return &RetentionRepository{db: db}
+}
+
+// GetDeletedUsersBefore gets the IDs of up to limit users soft deleted
+// before cutoff
+func (r *RetentionRepository) GetDeletedUsersBefore(cutoff time.Time, limit int) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.db.Unscoped().Model(&models.User{}).
+		Where("deleted_at < ?", cutoff).
+		Order("deleted_at").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// CountDeletedUsersBefore counts the users soft deleted before cutoff
+func (r *RetentionRepository) CountDeletedUsersBefore(cutoff time.Time) (int64, error) {
+	var count int64
+	err := r.db.Unscoped().Model(&models.User{}).Where("deleted_at < ?", cutoff).Count(&count).Error
+	return count, err
+}
+
+// GetDeletedGamesBefore gets the IDs of up to limit games soft deleted
+// before cutoff
+func (r *RetentionRepository) GetDeletedGamesBefore(cutoff time.Time, limit int) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.db.Unscoped().Model(&models.Game{}).
+		Where("deleted_at < ?", cutoff).
+		Order("deleted_at").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// CountDeletedGamesBefore counts the games soft deleted before cutoff
+func (r *RetentionRepository) CountDeletedGamesBefore(cutoff time.Time) (int64, error) {
+	var count int64
+	err := r.db.Unscoped().Model(&models.Game{}).Where("deleted_at < ?", cutoff).Count(&count).Error
+	return count, err
+}
+
+// PurgeUser removes a soft-deleted user and everything stored about them,
+// archived hands included. Games they won are kept without a winner.
+func (r *RetentionRepository) PurgeUser(userID uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		sessions := tx.Model(&models.Session{}).Select("id").Where("user_id = ?", userID)
+		if err := tx.Unscoped().Where("session_id IN (?)", sessions).Delete(&models.RotatedRefreshToken{}).Error; err != nil {
+			return err
+		}
+
+		owned := []interface{}{
+			&models.Session{},
+			&models.APIKey{},
+			&models.OAuthIdentity{},
+			&models.LoginEvent{},
+			&models.UserAchievement{},
+			&models.LeaderboardEntry{},
+			&models.PlayerStatAggregate{},
+			&models.HandSummary{},
+			&models.TournamentRegistration{},
+			&models.GameParticipation{},
+			&models.HandHistory{},
+		}
+		for _, model := range owned {
+			if err := tx.Unscoped().Where("user_id = ?", userID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Unscoped().Table(models.HandHistoryArchiveTable).Where("user_id = ?", userID).Delete(&models.HandHistory{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Where("reporter_id = ? OR subject_id = ?", userID, userID).Delete(&models.PlayerReport{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Model(&models.Game{}).Where("winner_id = ?", userID).Update("winner_id", nil).Error; err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", userID).Delete(&models.User{}).Error
+	})
+}
+
+// PurgeGame removes a soft-deleted game with its participations and hands,
+// archived ones included. Summaries that point at it are kept without it.
+func (r *RetentionRepository) PurgeGame(gameID uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		owned := []interface{}{
+			&models.GameParticipation{},
+			&models.HandHistory{},
+			&models.HandSettlement{},
+		}
+		for _, model := range owned {
+			if err := tx.Unscoped().Where("game_id = ?", gameID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Unscoped().Table(models.HandHistoryArchiveTable).Where("game_id = ?", gameID).Delete(&models.HandHistory{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("game_id = ?", gameID).Delete(&models.Hand{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Model(&models.HandSummary{}).Where("game_id = ?", gameID).Update("game_id", nil).Error; err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", gameID).Delete(&models.Game{}).Error
+	})
+}
+
+// CountHandsBefore counts the hand histories started before cutoff that
+// are still live
+func (r *RetentionRepository) CountHandsBefore(cutoff time.Time) (int64, error) {
+	var count int64
+	err := r.db.Unscoped().Model(&models.HandHistory{}).Where("started_at < ?", cutoff).Count(&count).Error
+	return count, err
+}
+
+// GetUnaggregatedPlayersBefore gets the IDs of the players with more hands
+// started before cutoff, live or archived, than their stored totals for
+// the days before it count. cutoff must be the start of a day.
+func (r *RetentionRepository) GetUnaggregatedPlayersBefore(cutoff time.Time) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.db.Raw(unaggregatedPlayersSQL, map[string]interface{}{"cutoff": cutoff}).Scan(&ids).Error
+	return ids, err
+}
+
+// ArchiveHandsBefore moves up to batchSize of the oldest hand histories
+// started before cutoff to the archive in one transaction, and returns how
+// many it moved. Unless it is nil, save is passed the hands before they
+// are moved; if it fails, none are.
+func (r *RetentionRepository) ArchiveHandsBefore(cutoff time.Time, batchSize int, save func([]models.HandHistory) error) (int64, error) {
+	stmt := &gorm.Statement{DB: r.db}
+	if err := stmt.Parse(&models.HandHistory{}); err != nil {
+		return 0, err
+	}
+	columns := make([]string, len(stmt.Schema.DBNames))
+	for i, name := range stmt.Schema.DBNames {
+		columns[i] = stmt.Quote(name)
+	}
+	columnList := strings.Join(columns, ", ")
+
+	var moved int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		oldest := tx.Unscoped().Model(&models.HandHistory{}).
+			Where("started_at < ?", cutoff).
+			Order("started_at").
+			Limit(batchSize)
+
+		var ids []uuid.UUID
+		if save == nil {
+			if err := oldest.Pluck("id", &ids).Error; err != nil {
+				return err
+			}
+		} else {
+			var hands []models.HandHistory
+			if err := oldest.Find(&hands).Error; err != nil {
+				return err
+			}
+			if len(hands) > 0 {
+				if err := save(hands); err != nil {
+					return err
+				}
+			}
+			for _, hand := range hands {
+				ids = append(ids, hand.ID)
+			}
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		insert := fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM hand_histories WHERE id IN ?",
+			stmt.Quote(models.HandHistoryArchiveTable), columnList, columnList)
+		if err := tx.Exec(insert, ids).Error; err != nil {
+			return fmt.Errorf("failed to copy hands to archive: %w", err)
+		}
+
+		result := tx.Unscoped().Where("id IN ?", ids).Delete(&models.HandHistory{})
+		if result.Error != nil {
+			return result.Error
+		}
+		moved = result.RowsAffected
+		return nil
+	})
+	return moved, err
+}
+
+// unaggregatedPlayersSQL compares each player's hands before @cutoff with
+// what their stored totals for those days count. Soft-deleted hands are
+// left out on both sides of a rebuild, so they are left out here too.
+const unaggregatedPlayersSQL = `
+SELECT hands.user_id
+FROM (
+	SELECT user_id, COUNT(*) AS hands
+	FROM (
+		SELECT user_id FROM hand_histories
+		WHERE started_at < @cutoff AND deleted_at IS NULL
+		UNION ALL
+		SELECT user_id FROM hand_histories_archive
+		WHERE started_at < @cutoff AND deleted_at IS NULL
+	) AS played
+	GROUP BY user_id
+) AS hands
+LEFT JOIN (
+	SELECT user_id, SUM(hands) AS hands
+	FROM player_stat_aggregates
+	WHERE day < @cutoff
+	GROUP BY user_id
+) AS totals ON totals.user_id = hands.user_id
+WHERE COALESCE(totals.hands, 0) < hands.hands`
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/primoPoker/server/internal/models"
+	"gorm.io/gorm"
+)
+
+// SessionRepository handles session database operations
+type SessionRepository struct {
+	db *gorm.DB
+}
+
+// NewSessionRepository creates a new session repository
+func NewSessionRepository(db *gorm.DB) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+// Create creates a new session
+func (r *SessionRepository) Create(session *models.Session) error {
+	return r.db.Create(session).Error
+}
+
+// GetByID gets a session by ID
+func (r *SessionRepository) GetByID(id uuid.UUID) (*models.Session, error) {
+	var session models.Session
+	err := r.db.First(&session, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// GetByRefreshTokenHash gets a session by the hash of its refresh token
+func (r *SessionRepository) GetByRefreshTokenHash(hash string) (*models.Session, error) {
+	var session models.Session
+	err := r.db.First(&session, "refresh_token_hash = ?", hash).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// GetActiveByUser gets a user's unexpired, unrevoked sessions, most recently used first
+func (r *SessionRepository) GetActiveByUser(userID uuid.UUID) ([]models.Session, error) {
+	var sessions []models.Session
+	err := r.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// HasUsedIP checks if a user has ever signed in from an IP address
+func (r *SessionRepository) HasUsedIP(userID uuid.UUID, ipAddress string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.Session{}).
+		Where("user_id = ? AND ip_address = ?", userID, ipAddress).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// Rotate replaces a session's refresh token and records where it was used
+// from. The old token is remembered so later reuse can be detected. It
+// reports false if the old token was no longer current, meaning another
+// request already rotated it.
+func (r *SessionRepository) Rotate(id uuid.UUID, oldTokenHash, newTokenHash, ipAddress, userAgent string, expiresAt time.Time) (bool, error) {
+	rotated := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&models.Session{}).
+			Where("id = ? AND refresh_token_hash = ?", id, oldTokenHash).
+			Updates(map[string]interface{}{
+				"refresh_token_hash": newTokenHash,
+				"ip_address":         ipAddress,
+				"user_agent":         userAgent,
+				"last_used_at":       now,
+				"expires_at":         expiresAt,
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		rotated = true
+		return tx.Create(&models.RotatedRefreshToken{
+			TokenHash: oldTokenHash,
+			SessionID: id,
+			RotatedAt: now,
+		}).Error
+	})
+	return rotated && err == nil, err
+}
+
+// GetRotatedToken gets a refresh token that has already been exchanged
+func (r *SessionRepository) GetRotatedToken(hash string) (*models.RotatedRefreshToken, error) {
+	var token models.RotatedRefreshToken
+	err := r.db.First(&token, "token_hash = ?", hash).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// RevokeByID revokes a session regardless of its owner
+func (r *SessionRepository) RevokeByID(sessionID uuid.UUID) error {
+	return r.db.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// Revoke revokes a single session belonging to a user, reporting whether one was found
+func (r *SessionRepository) Revoke(userID, sessionID uuid.UUID) (bool, error) {
+	result := r.db.Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// RevokeAllExcept revokes every active session of a user other than the given one
+func (r *SessionRepository) RevokeAllExcept(userID, keepSessionID uuid.UUID) (int64, error) {
+	result := r.db.Model(&models.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keepSessionID).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/pagination"
+)
+
+// UserStore is the part of the user repository the HTTP handlers and the
+// metrics service read through
+type UserStore interface {
+	GetByID(id uuid.UUID) (*models.User, error)
+	GetByUsername(username string) (*models.User, error)
+	GetTopPlayers(page pagination.PageRequest) (*pagination.PageResponse[models.User], error)
+	ExportAccount(userID uuid.UUID, batchSize int, fn func(*models.User, []models.GameParticipation) error, handFn func([]models.HandHistory) error) error
+}
+
+// GameStore is the part of the game repository the HTTP handlers read
+// through
+type GameStore interface {
+	GetGameHistory(page pagination.PageRequest, userID *uuid.UUID) (*pagination.PageResponse[models.Game], error)
+}
+
+// HandHistoryStore is the part of the hand history repository the HTTP
+// handlers, hand exports and the metrics service read through
+type HandHistoryStore interface {
+	GetByID(id uuid.UUID) (*models.HandHistory, error)
+	GetUserHandHistory(userID uuid.UUID, page pagination.PageRequest) (*pagination.PageResponse[models.HandHistory], error)
+	GetHandsByTimeRange(userID uuid.UUID, startTime, endTime time.Time) ([]models.HandHistory, error)
+	GetLastFinishedBefore(userID uuid.UUID, startedAt time.Time, id uuid.UUID) (*models.HandHistory, error)
+	GetHandsParticipants(gameIDs []uuid.UUID, handNumbers []int) ([]models.HandHistory, error)
+	GetSharedHands(userID, opponentID uuid.UUID) ([]models.HandHistory, error)
+	GetStartingHandTotals(userID uuid.UUID, position string, from, to time.Time) ([]StartingHandTotals, error)
+	GetSummaries(userID uuid.UUID, period models.SummaryPeriod, from, to time.Time) ([]models.HandSummary, error)
+	GetUserStats(userID uuid.UUID, filter StatsFilter) (*models.HandSummary, error)
+	SearchHands(userID uuid.UUID, search HandSearch, page pagination.PageRequest) (*pagination.PageResponse[HandSearchResult], error)
+	StreamUserHands(userID uuid.UUID, from, to time.Time, batchSize int, fn func([]models.HandHistory) error) error
+	StreamUserArchivedHands(userID uuid.UUID, from, to time.Time, batchSize int, fn func([]models.HandHistory) error) error
+}
+
+var (
+	_ UserStore        = (*UserRepository)(nil)
+	_ GameStore        = (*GameRepository)(nil)
+	_ HandHistoryStore = (*HandHistoryRepository)(nil)
+)
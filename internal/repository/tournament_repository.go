@@ -0,0 +1,258 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/primoPoker/server/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TournamentRepository handles tournament database operations
+type TournamentRepository struct {
+	db *gorm.DB
+}
+
+// NewTournamentRepository creates a new tournament repository
+func NewTournamentRepository(db *gorm.DB) *TournamentRepository {
+	return &TournamentRepository{db: db}
+}
+
+// Create creates a new tournament
+func (r *TournamentRepository) Create(tournament *models.Tournament) error {
+	return r.db.Create(tournament).Error
+}
+
+// GetByID gets a tournament by ID
+func (r *TournamentRepository) GetByID(id uuid.UUID) (*models.Tournament, error) {
+	var tournament models.Tournament
+	err := r.db.First(&tournament, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &tournament, nil
+}
+
+// Update updates a tournament
+func (r *TournamentRepository) Update(tournament *models.Tournament) error {
+	return r.db.Save(tournament).Error
+}
+
+// ListActive gets tournaments that are upcoming or running, soonest first
+func (r *TournamentRepository) ListActive() ([]models.Tournament, error) {
+	var tournaments []models.Tournament
+	err := r.db.Where("status IN ?", []models.TournamentStatus{
+		models.TournamentStatusScheduled,
+		models.TournamentStatusRegistering,
+		models.TournamentStatusRunning,
+	}).Order("starts_at ASC").Find(&tournaments).Error
+	return tournaments, err
+}
+
+// CountRegistrations gets the number of registered players per tournament
+func (r *TournamentRepository) CountRegistrations(tournamentIDs []uuid.UUID) (map[uuid.UUID]int64, error) {
+	counts := make(map[uuid.UUID]int64, len(tournamentIDs))
+	if len(tournamentIDs) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		TournamentID uuid.UUID
+		Count        int64
+	}
+	err := r.db.Model(&models.TournamentRegistration{}).
+		Select("tournament_id, COUNT(*) AS count").
+		Where("tournament_id IN ?", tournamentIDs).
+		Group("tournament_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		counts[row.TournamentID] = row.Count
+	}
+	return counts, nil
+}
+
+// GetRegistrations gets all entries for a tournament, chip leaders first
+func (r *TournamentRepository) GetRegistrations(tournamentID uuid.UUID) ([]models.TournamentRegistration, error) {
+	var registrations []models.TournamentRegistration
+	err := r.db.Preload("User").
+		Where("tournament_id = ?", tournamentID).
+		Order("is_eliminated ASC, chips DESC, registered_at ASC").
+		Find(&registrations).Error
+	return registrations, err
+}
+
+// Register enters a user into a tournament, debiting the buy-in from their
+// balance and adding it to the prize pool in a single transaction
+func (r *TournamentRepository) Register(tournamentID, userID uuid.UUID) (*models.TournamentRegistration, error) {
+	var registration *models.TournamentRegistration
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		tournament, err := r.lockTournament(tx, tournamentID)
+		if err != nil {
+			return err
+		}
+
+		if !tournament.IsRegistrationOpen(time.Now()) {
+			return ErrRegistrationClosed
+		}
+
+		var existing int64
+		if err := tx.Model(&models.TournamentRegistration{}).
+			Where("tournament_id = ? AND user_id = ?", tournamentID, userID).
+			Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return ErrAlreadyRegistered
+		}
+
+		var registered int64
+		if err := tx.Model(&models.TournamentRegistration{}).
+			Where("tournament_id = ?", tournamentID).
+			Count(&registered).Error; err != nil {
+			return err
+		}
+		if tournament.MaxPlayers > 0 && registered >= int64(tournament.MaxPlayers) {
+			return ErrTournamentFull
+		}
+
+		if tournament.BuyIn > 0 {
+			if _, err := NewUserRepository(tx).DebitChips(userID, tournament.BuyIn); err != nil {
+				return err
+			}
+		}
+
+		registration = &models.TournamentRegistration{
+			TournamentID: tournamentID,
+			UserID:       userID,
+			BuyInPaid:    tournament.BuyIn,
+			Chips:        tournament.StartingStack,
+		}
+		if err := tx.Create(registration).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(tournament).
+			Update("prize_pool", gorm.Expr("prize_pool + ?", tournament.BuyIn)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return registration, nil
+}
+
+// Unregister removes a user from a tournament that has not started and
+// refunds the buy-in they paid in a single transaction
+func (r *TournamentRepository) Unregister(tournamentID, userID uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		tournament, err := r.lockTournament(tx, tournamentID)
+		if err != nil {
+			return err
+		}
+
+		if tournament.HasStarted() {
+			return ErrTournamentStarted
+		}
+
+		var registration models.TournamentRegistration
+		err = tx.First(&registration, "tournament_id = ? AND user_id = ?", tournamentID, userID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotRegistered
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Delete(&registration).Error; err != nil {
+			return err
+		}
+
+		if registration.BuyInPaid > 0 {
+			if _, err := NewUserRepository(tx).CreditChips(userID, registration.BuyInPaid); err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(tournament).
+			Update("prize_pool", gorm.Expr("prize_pool - ?", registration.BuyInPaid)).Error
+	})
+}
+
+// Start starts a tournament due to start at startsAt, or cancels it with
+// every buy-in refunded when fewer than its minimum players registered,
+// in a single transaction. A tournament that has already started is left
+// alone with ErrTournamentStarted, and one cancelled or moved to another
+// time with ErrTournamentNotDue.
+func (r *TournamentRepository) Start(id uuid.UUID, startsAt, now time.Time) (*models.Tournament, error) {
+	var tournament *models.Tournament
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		tournament, err = r.lockTournament(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if tournament.HasStarted() {
+			return ErrTournamentStarted
+		}
+		if tournament.Status == models.TournamentStatusCancelled || !tournament.StartsAt.Equal(startsAt) {
+			return ErrTournamentNotDue
+		}
+
+		var registrations []models.TournamentRegistration
+		if err := tx.Where("tournament_id = ?", id).Find(&registrations).Error; err != nil {
+			return err
+		}
+
+		if len(registrations) < tournament.MinPlayers {
+			for _, registration := range registrations {
+				if registration.BuyInPaid > 0 {
+					if _, err := NewUserRepository(tx).CreditChips(registration.UserID, registration.BuyInPaid); err != nil {
+						return err
+					}
+				}
+			}
+			tournament.Status = models.TournamentStatusCancelled
+			tournament.PrizePool = 0
+			return tx.Model(tournament).Updates(map[string]interface{}{
+				"status":     tournament.Status,
+				"prize_pool": tournament.PrizePool,
+			}).Error
+		}
+
+		tournament.Status = models.TournamentStatusRunning
+		tournament.StartedAt = &now
+		tournament.CurrentLevel = 1
+		tournament.LevelStartedAt = &now
+		return tx.Model(tournament).Updates(map[string]interface{}{
+			"status":           tournament.Status,
+			"started_at":       tournament.StartedAt,
+			"current_level":    tournament.CurrentLevel,
+			"level_started_at": tournament.LevelStartedAt,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tournament, nil
+}
+
+// lockTournament loads a tournament row for update so concurrent
+// registrations are serialised against the player cap
+func (r *TournamentRepository) lockTournament(tx *gorm.DB, id uuid.UUID) (*models.Tournament, error) {
+	var tournament models.Tournament
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&tournament, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &tournament, nil
+}
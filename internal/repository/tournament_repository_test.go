@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/testutil"
+)
+
+// newTournamentDB opens a database with a tournament open for registration
+// until the hour is out
+func newTournamentDB(t *testing.T, buyIn int64, maxPlayers int) (*gorm.DB, *models.Tournament) {
+	t.Helper()
+
+	db := testutil.NewDB(t, &models.User{}, &models.Tournament{}, &models.TournamentRegistration{})
+	tournament := &models.Tournament{
+		Name:                 "Sunday Major",
+		Status:               models.TournamentStatusRegistering,
+		BuyIn:                buyIn,
+		StartingStack:        5000,
+		MinPlayers:           2,
+		MaxPlayers:           maxPlayers,
+		RegistrationClosesAt: time.Now().Add(time.Hour),
+		StartsAt:             time.Now().Add(time.Hour),
+	}
+	require.NoError(t, db.Create(tournament).Error)
+	return db, tournament
+}
+
+// newPlayer stores a user with a chip balance
+func newPlayer(t *testing.T, db *gorm.DB, name string, balance int64) *models.User {
+	t.Helper()
+
+	user := &models.User{Username: name, Email: name + "@example.com", PasswordHash: "x", ChipBalance: balance}
+	require.NoError(t, db.Create(user).Error)
+	return user
+}
+
+// balanceOf reads a user's chip balance back
+func balanceOf(t *testing.T, db *gorm.DB, userID uuid.UUID) int64 {
+	t.Helper()
+
+	user, err := NewUserRepository(db).GetByID(userID)
+	require.NoError(t, err)
+	return user.ChipBalance
+}
+
+func TestTournamentRegisterAndUnregister(t *testing.T) {
+	db, tournament := newTournamentDB(t, 250, 10)
+	repo := NewTournamentRepository(db)
+	alice := newPlayer(t, db, "alice", 1000)
+
+	registration, err := repo.Register(tournament.ID, alice.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(250), registration.BuyInPaid)
+	assert.Equal(t, int64(5000), registration.Chips)
+	assert.Equal(t, int64(750), balanceOf(t, db, alice.ID))
+
+	stored, err := repo.GetByID(tournament.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(250), stored.PrizePool)
+
+	// Registering twice neither charges nor enters the player again
+	_, err = repo.Register(tournament.ID, alice.ID)
+	assert.ErrorIs(t, err, ErrAlreadyRegistered)
+	assert.Equal(t, int64(750), balanceOf(t, db, alice.ID))
+
+	// Unregistering refunds the buy-in and takes it out of the prize pool
+	require.NoError(t, repo.Unregister(tournament.ID, alice.ID))
+	assert.Equal(t, int64(1000), balanceOf(t, db, alice.ID))
+	stored, err = repo.GetByID(tournament.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), stored.PrizePool)
+	counts, err := repo.CountRegistrations([]uuid.UUID{tournament.ID})
+	require.NoError(t, err)
+	assert.Zero(t, counts[tournament.ID])
+
+	assert.ErrorIs(t, repo.Unregister(tournament.ID, alice.ID), ErrNotRegistered)
+	assert.Equal(t, int64(1000), balanceOf(t, db, alice.ID), "refunded once")
+}
+
+func TestTournamentRegisterWithoutTheBuyIn(t *testing.T) {
+	db, tournament := newTournamentDB(t, 250, 10)
+	repo := NewTournamentRepository(db)
+	alice := newPlayer(t, db, "alice", 100)
+
+	_, err := repo.Register(tournament.ID, alice.ID)
+	assert.ErrorIs(t, err, ErrInsufficientBalance)
+
+	// The transaction is rolled back whole
+	assert.Equal(t, int64(100), balanceOf(t, db, alice.ID))
+	counts, err := repo.CountRegistrations([]uuid.UUID{tournament.ID})
+	require.NoError(t, err)
+	assert.Zero(t, counts[tournament.ID])
+	stored, err := repo.GetByID(tournament.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), stored.PrizePool)
+}
+
+func TestTournamentRegistrationClosed(t *testing.T) {
+	db, tournament := newTournamentDB(t, 250, 10)
+	repo := NewTournamentRepository(db)
+	alice := newPlayer(t, db, "alice", 1000)
+	bob := newPlayer(t, db, "bob", 1000)
+
+	_, err := repo.Register(tournament.ID, alice.ID)
+	require.NoError(t, err)
+
+	require.NoError(t, db.Model(tournament).Update("registration_closes_at", time.Now().Add(-time.Minute)).Error)
+	_, err = repo.Register(tournament.ID, bob.ID)
+	assert.ErrorIs(t, err, ErrRegistrationClosed)
+	assert.Equal(t, int64(1000), balanceOf(t, db, bob.ID))
+
+	// Once it has started, players can no longer take their buy-in back
+	require.NoError(t, db.Model(tournament).Update("status", models.TournamentStatusRunning).Error)
+	assert.ErrorIs(t, repo.Unregister(tournament.ID, alice.ID), ErrTournamentStarted)
+	assert.Equal(t, int64(750), balanceOf(t, db, alice.ID))
+}
+
+func TestTournamentRegistrationCapHoldsUnderConcurrentRegistrations(t *testing.T) {
+	db, tournament := newTournamentDB(t, 100, 3)
+	repo := NewTournamentRepository(db)
+
+	players := make([]*models.User, 6)
+	for i, name := range []string{"alice", "bob", "carol", "dave", "erin", "frank"} {
+		players[i] = newPlayer(t, db, name, 1000)
+	}
+
+	errs := make([]error, len(players))
+	var wg sync.WaitGroup
+	for i, player := range players {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = repo.Register(tournament.ID, player.ID)
+		}()
+	}
+	wg.Wait()
+
+	registered := 0
+	for i, err := range errs {
+		if err == nil {
+			registered++
+			assert.Equal(t, int64(900), balanceOf(t, db, players[i].ID))
+			continue
+		}
+		assert.ErrorIs(t, err, ErrTournamentFull)
+		assert.Equal(t, int64(1000), balanceOf(t, db, players[i].ID), "turned away without being charged")
+	}
+	assert.Equal(t, 3, registered)
+
+	stored, err := repo.GetByID(tournament.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(300), stored.PrizePool)
+}
@@ -1,11 +1,14 @@
 package repository
 
 import (
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/pagination"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // UserRepository handles user database operations
@@ -33,6 +36,15 @@ func (r *UserRepository) GetByID(id uuid.UUID) (*models.User, error) {
 	return &user, nil
 }
 
+// IsDeleted reports whether a user's account has been deleted
+func (r *UserRepository) IsDeleted(id uuid.UUID) (bool, error) {
+	var user models.User
+	if err := r.db.Unscoped().Select("id", "deleted_at").First(&user, "id = ?", id).Error; err != nil {
+		return false, err
+	}
+	return user.DeletedAt.Valid, nil
+}
+
 // GetByUsername gets a user by username
 func (r *UserRepository) GetByUsername(username string) (*models.User, error) {
 	var user models.User
@@ -53,19 +65,64 @@ func (r *UserRepository) GetByEmail(email string) (*models.User, error) {
 	return &user, nil
 }
 
-// Update updates a user
-func (r *UserRepository) Update(user *models.User) error {
-	return r.db.Save(user).Error
-}
-
 // Delete soft deletes a user
 func (r *UserRepository) Delete(id uuid.UUID) error {
 	return r.db.Delete(&models.User{}, id).Error
 }
 
-// UpdateChipBalance updates user's chip balance
-func (r *UserRepository) UpdateChipBalance(userID uuid.UUID, amount int64) error {
-	return r.db.Model(&models.User{}).Where("id = ?", userID).Update("chip_balance", amount).Error
+// UpdatePasswordHash replaces a user's password hash
+func (r *UserRepository) UpdatePasswordHash(userID uuid.UUID, hash string) error {
+	return r.db.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"password_hash": hash,
+		"updated_at":    time.Now(),
+	}).Error
+}
+
+// CreditChips adds amount to a user's chip balance and returns the new balance
+func (r *UserRepository) CreditChips(userID uuid.UUID, amount int64) (int64, error) {
+	if amount <= 0 {
+		return 0, ErrInvalidChipAmount
+	}
+	return r.changeChipBalance(userID, amount)
+}
+
+// DebitChips takes amount from a user's chip balance and returns the new
+// balance. It fails with ErrInsufficientBalance, leaving the balance as it
+// was, if the user holds fewer chips than amount.
+func (r *UserRepository) DebitChips(userID uuid.UUID, amount int64) (int64, error) {
+	if amount <= 0 {
+		return 0, ErrInvalidChipAmount
+	}
+	return r.changeChipBalance(userID, -amount)
+}
+
+// changeChipBalance adds delta to a user's chip balance in the database
+// rather than writing back a balance read earlier, so concurrent changes are
+// never lost. A debit only applies while the balance covers it.
+func (r *UserRepository) changeChipBalance(userID uuid.UUID, delta int64) (int64, error) {
+	var balance int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&models.User{}).Where("id = ?", userID)
+		if delta < 0 {
+			query = query.Where("chip_balance >= ?", -delta)
+		}
+		result := query.Updates(map[string]interface{}{
+			"chip_balance": gorm.Expr("chip_balance + ?", delta),
+			"updated_at":   time.Now(),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if err := tx.Model(&models.User{}).Select("chip_balance").Where("id = ?", userID).Take(&balance).Error; err != nil {
+			return err
+		}
+		if result.RowsAffected == 0 {
+			return ErrInsufficientBalance
+		}
+		return nil
+	})
+	return balance, err
 }
 
 // UpdateStats updates user statistics
@@ -73,13 +130,31 @@ func (r *UserRepository) UpdateStats(userID uuid.UUID, stats map[string]interfac
 	return r.db.Model(&models.User{}).Where("id = ?", userID).Updates(stats).Error
 }
 
-// GetTopPlayers gets top players by total winnings
-func (r *UserRepository) GetTopPlayers(limit int) ([]models.User, error) {
+// GetTopPlayers gets a page of the leaderboard, ordered by total winnings
+func (r *UserRepository) GetTopPlayers(page pagination.PageRequest) (*pagination.PageResponse[models.User], error) {
+	var key interface{}
+	if page.Cursor != nil {
+		winnings, err := page.Cursor.Int64()
+		if err != nil {
+			return nil, err
+		}
+		key = winnings
+	}
+
 	var users []models.User
-	err := r.db.Order("total_winnings DESC").Limit(limit).Find(&users).Error
-	return users, err
+	err := leaderboardKeyset.Apply(r.db, page, key).Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return pagination.NewPage(users, page, func(user models.User) pagination.Cursor {
+		return pagination.Int64Cursor(user.TotalWinnings, user.ID.String())
+	}), nil
 }
 
+// leaderboardKeyset orders players by total winnings, highest first
+var leaderboardKeyset = pagination.Keyset{Column: "total_winnings", IDColumn: "id", Descending: true}
+
 // GetActiveUsers gets users who have been active recently
 func (r *UserRepository) GetActiveUsers(since time.Time) ([]models.User, error) {
 	var users []models.User
@@ -106,6 +181,51 @@ func (r *UserRepository) UnbanUser(userID uuid.UUID) error {
 	}).Error
 }
 
+// UpdateRole changes a user's role
+func (r *UserRepository) UpdateRole(userID uuid.UUID, role models.Role) error {
+	result := r.db.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"role":       role,
+		"updated_at": time.Now(),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// UpdateAvatar sets the URL of a user's avatar
+func (r *UserRepository) UpdateAvatar(userID uuid.UUID, avatar string) error {
+	result := r.db.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"avatar":     avatar,
+		"updated_at": time.Now(),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// PromoteUsers gives the named users a role if they do not already hold it
+func (r *UserRepository) PromoteUsers(usernames []string, role models.Role) (int64, error) {
+	if len(usernames) == 0 {
+		return 0, nil
+	}
+
+	result := r.db.Model(&models.User{}).
+		Where("username IN ? AND role <> ?", usernames, role).
+		Updates(map[string]interface{}{
+			"role":       role,
+			"updated_at": time.Now(),
+		})
+	return result.RowsAffected, result.Error
+}
+
 // SearchUsers searches for users by username or display name
 func (r *UserRepository) SearchUsers(query string, limit int) ([]models.User, error) {
 	var users []models.User
@@ -129,12 +249,48 @@ func (r *UserRepository) GetUserWithStats(userID uuid.UUID) (*models.User, error
 func (r *UserRepository) UpdateLastLogin(userID uuid.UUID) error {
 	now := time.Now()
 	return r.db.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
-		"last_login_at":   &now,
-		"login_attempts":  0,
-		"updated_at":      now,
+		"last_login_at":        &now,
+		"login_attempts":       0,
+		"last_failed_login_at": nil,
+		"locked_until":         nil,
+		"updated_at":           now,
 	}).Error
 }
 
+// RecordFailedLogin adds weight to a user's failed login count and locks the
+// account for window once the count reaches limit. Failures older than window
+// no longer count. It returns when the lock ends, or nil if not locked.
+func (r *UserRepository) RecordFailedLogin(userID uuid.UUID, weight, limit int, window time.Duration) (*time.Time, error) {
+	var lockedUntil *time.Time
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", userID).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		attempts := user.LoginAttempts
+		if user.LastFailedLoginAt == nil || now.Sub(*user.LastFailedLoginAt) > window {
+			attempts = 0
+		}
+		attempts += weight
+
+		updates := map[string]interface{}{
+			"login_attempts":       attempts,
+			"last_failed_login_at": now,
+			"updated_at":           now,
+		}
+		if attempts >= limit {
+			until := now.Add(window)
+			updates["locked_until"] = until
+			lockedUntil = &until
+		}
+
+		return tx.Model(&user).Updates(updates).Error
+	})
+	return lockedUntil, err
+}
+
 // IncrementLoginAttempts increments failed login attempts
 func (r *UserRepository) IncrementLoginAttempts(userID uuid.UUID) error {
 	return r.db.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
@@ -163,6 +319,34 @@ func (r *UserRepository) IsEmailAvailable(email string) (bool, error) {
 	return count == 0, nil
 }
 
+// GetByOAuthIdentity gets the user linked to a provider's subject
+func (r *UserRepository) GetByOAuthIdentity(provider, subject string) (*models.User, error) {
+	var user models.User
+	err := r.db.Joins("JOIN oauth_identities ON oauth_identities.user_id = users.id").
+		Where("oauth_identities.provider = ? AND oauth_identities.subject = ?", provider, subject).
+		First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// CreateWithOAuthIdentity creates a user together with its first linked identity
+func (r *UserRepository) CreateWithOAuthIdentity(user *models.User, identity *models.OAuthIdentity) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		identity.UserID = user.ID
+		return tx.Create(identity).Error
+	})
+}
+
+// LinkOAuthIdentity links a provider identity to an existing user
+func (r *UserRepository) LinkOAuthIdentity(identity *models.OAuthIdentity) error {
+	return r.db.Create(identity).Error
+}
+
 // GetUsersByIDs gets multiple users by their IDs
 func (r *UserRepository) GetUsersByIDs(ids []uuid.UUID) ([]models.User, error) {
 	var users []models.User
@@ -179,3 +363,136 @@ func (r *UserRepository) CreateWithTransaction(tx *gorm.DB, user *models.User) e
 func (r *UserRepository) UpdateWithTransaction(tx *gorm.DB, user *models.User) error {
 	return tx.Save(user).Error
 }
+
+// GetGameParticipations gets every table a user has sat at, newest first
+func (r *UserRepository) GetGameParticipations(userID uuid.UUID) ([]models.GameParticipation, error) {
+	var participations []models.GameParticipation
+	err := r.db.Preload("Game").
+		Where("user_id = ?", userID).
+		Order("joined_at DESC").
+		Find(&participations).Error
+	return participations, err
+}
+
+// AnonymizedUsername is the name a deleted user's records are shown under
+func AnonymizedUsername(userID uuid.UUID) string {
+	return "deleted-" + userID.String()
+}
+
+// DeleteAccount anonymizes and soft deletes a user in a single transaction:
+// PII is cleared, every session and API key is revoked, linked sign-in
+// identities and login history are removed, and the user's name is replaced
+// in other players' hand histories so those records stay intact without it.
+// Running it again for an already deleted user is a no-op.
+func (r *UserRepository) DeleteAccount(userID uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Unscoped().First(&user, "id = ?", userID).Error; err != nil {
+			return err
+		}
+		if user.DeletedAt.Valid {
+			return nil
+		}
+
+		alias := AnonymizedUsername(userID)
+
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"username":      alias,
+			"email":         alias + "@deleted.invalid",
+			"password_hash": "",
+			"display_name":  "",
+			"avatar":        "",
+			"is_active":     false,
+			"last_login_at": nil,
+		}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.Session{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.APIKey{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", userID).Delete(&models.OAuthIdentity{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", userID).Delete(&models.LoginEvent{}).Error; err != nil {
+			return err
+		}
+
+		if err := anonymizeActionRecords(tx, userID, alias); err != nil {
+			return err
+		}
+
+		return tx.Delete(&user).Error
+	})
+}
+
+// anonymizeActionRecords rewrites the username on a player's actions in
+// every row of every hand they took part in
+func anonymizeActionRecords(tx *gorm.DB, userID uuid.UUID, alias string) error {
+	hands := tx.Model(&models.HandHistory{}).Select("game_id, hand_number").Where("user_id = ?", userID)
+
+	var batch []models.HandHistory
+	return tx.Where("(game_id, hand_number) IN (?)", hands).
+		FindInBatches(&batch, 200, func(batchTx *gorm.DB, _ int) error {
+			for i := range batch {
+				row := &batch[i]
+				changed := false
+				for _, records := range [][]models.PlayerActionRecord{
+					row.PreFlopActions, row.FlopActions, row.TurnActions, row.RiverActions,
+				} {
+					for j := range records {
+						if records[j].PlayerID == userID && records[j].Username != alias {
+							records[j].Username = alias
+							changed = true
+						}
+					}
+				}
+				if !changed {
+					continue
+				}
+
+				if err := tx.Model(row).
+					Select("pre_flop_actions", "flop_actions", "turn_actions", "river_actions").
+					Updates(row).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
+
+// ExportAccount reads everything held about a user from one consistent
+// snapshot: the profile and participations are passed to fn, then hand
+// histories are streamed to handFn in batches
+func (r *UserRepository) ExportAccount(userID uuid.UUID, batchSize int, fn func(*models.User, []models.GameParticipation) error, handFn func([]models.HandHistory) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		users := NewUserRepository(tx)
+
+		user, err := users.GetByID(userID)
+		if err != nil {
+			return err
+		}
+
+		participations, err := users.GetGameParticipations(userID)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(user, participations); err != nil {
+			return err
+		}
+
+		far := time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+		return NewHandHistoryRepository(tx).StreamUserHands(userID, time.Time{}, far, batchSize, handFn)
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+}
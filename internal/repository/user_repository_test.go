@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/testutil"
+)
+
+func newChipUser(t *testing.T, repo *UserRepository, balance int64) uuid.UUID {
+	t.Helper()
+
+	user := &models.User{ID: uuid.New(), Username: "chips", Email: "chips@example.com", PasswordHash: "x", ChipBalance: balance}
+	require.NoError(t, repo.Create(user))
+	return user.ID
+}
+
+func TestChipBalanceChanges(t *testing.T) {
+	repo := NewUserRepository(testutil.NewDB(t, &models.User{}))
+	userID := newChipUser(t, repo, 1000)
+
+	balance, err := repo.DebitChips(userID, 400)
+	require.NoError(t, err)
+	assert.Equal(t, int64(600), balance)
+
+	balance, err = repo.DebitChips(userID, 700)
+	assert.ErrorIs(t, err, ErrInsufficientBalance)
+	assert.Equal(t, int64(600), balance, "a refused debit leaves the balance alone")
+
+	balance, err = repo.CreditChips(userID, 150)
+	require.NoError(t, err)
+	assert.Equal(t, int64(750), balance)
+
+	_, err = repo.CreditChips(userID, -150)
+	assert.ErrorIs(t, err, ErrInvalidChipAmount)
+	_, err = repo.DebitChips(userID, 0)
+	assert.ErrorIs(t, err, ErrInvalidChipAmount)
+
+	_, err = repo.CreditChips(uuid.New(), 150)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestChipBalanceConcurrentChanges(t *testing.T) {
+	repo := NewUserRepository(testutil.NewDB(t, &models.User{}))
+	userID := newChipUser(t, repo, 1000)
+
+	// Twice as many debits as the balance covers race a run of credits
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	refused := 0
+	for i := 0; i < 40; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := repo.CreditChips(userID, 25)
+			assert.NoError(t, err)
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := repo.DebitChips(userID, 50)
+			if err != nil {
+				assert.ErrorIs(t, err, ErrInsufficientBalance)
+				mu.Lock()
+				refused++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	user, err := repo.GetByID(userID)
+	require.NoError(t, err)
+	assert.Equal(t, 1000+40*25-int64(40-refused)*50, user.ChipBalance)
+	assert.GreaterOrEqual(t, user.ChipBalance, int64(0))
+}
+
+func TestDeleteAccount(t *testing.T) {
+	db := testutil.NewDB(t, &models.User{}, &models.Session{}, &models.APIKey{}, &models.OAuthIdentity{},
+		&models.LoginEvent{}, &models.HandHistory{})
+	repo := NewUserRepository(db)
+
+	gameID := uuid.New()
+	shared := fullTableHand(gameID, 1)
+	other := fullTableHand(gameID, 2)
+	require.NoError(t, db.Create(&shared).Error)
+	require.NoError(t, db.Create(&other).Error)
+
+	userID := shared[0].UserID
+	now := time.Now()
+	require.NoError(t, repo.Create(&models.User{
+		ID: userID, Username: "player0", Email: "player0@example.com", PasswordHash: "x",
+		DisplayName: "Player Zero", Avatar: "avatars/player0.png", LastLoginAt: &now,
+	}))
+	require.NoError(t, db.Create(&models.Session{UserID: userID, RefreshTokenHash: "refresh", ExpiresAt: now.Add(time.Hour)}).Error)
+	require.NoError(t, db.Create(&models.APIKey{UserID: userID, Name: "bot", Prefix: "pk_", KeyHash: "key", Scopes: "read"}).Error)
+	require.NoError(t, db.Create(&models.OAuthIdentity{UserID: userID, Provider: "google", Subject: "123"}).Error)
+	require.NoError(t, db.Create(&models.LoginEvent{UserID: &userID, Method: "password", Outcome: models.LoginSucceeded}).Error)
+
+	require.NoError(t, repo.DeleteAccount(userID))
+
+	_, err := repo.GetByID(userID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	var user models.User
+	require.NoError(t, db.Unscoped().First(&user, "id = ?", userID).Error)
+	alias := AnonymizedUsername(userID)
+	assert.Equal(t, alias, user.Username)
+	assert.Equal(t, alias+"@deleted.invalid", user.Email)
+	assert.Empty(t, user.PasswordHash)
+	assert.Empty(t, user.DisplayName)
+	assert.Empty(t, user.Avatar)
+	assert.False(t, user.IsActive)
+	assert.Nil(t, user.LastLoginAt)
+
+	deleted, err := repo.IsDeleted(userID)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+
+	var liveSessions, liveKeys, identities, logins int64
+	require.NoError(t, db.Model(&models.Session{}).Where("user_id = ? AND revoked_at IS NULL", userID).Count(&liveSessions).Error)
+	require.NoError(t, db.Model(&models.APIKey{}).Where("user_id = ? AND revoked_at IS NULL", userID).Count(&liveKeys).Error)
+	require.NoError(t, db.Model(&models.OAuthIdentity{}).Where("user_id = ?", userID).Count(&identities).Error)
+	require.NoError(t, db.Model(&models.LoginEvent{}).Where("user_id = ?", userID).Count(&logins).Error)
+	assert.Zero(t, liveSessions)
+	assert.Zero(t, liveKeys)
+	assert.Zero(t, identities)
+	assert.Zero(t, logins)
+
+	// Every player's copy of the hand names the deleted player by the alias,
+	// and the other players keep their names
+	var rows []models.HandHistory
+	require.NoError(t, db.Where("game_id = ?", gameID).Find(&rows).Error)
+	require.Len(t, rows, 18)
+	renamed := 0
+	for _, row := range rows {
+		for _, records := range [][]models.PlayerActionRecord{
+			row.PreFlopActions, row.FlopActions, row.TurnActions, row.RiverActions,
+		} {
+			for _, record := range records {
+				if record.PlayerID == userID {
+					assert.Equal(t, alias, record.Username)
+					renamed++
+				} else {
+					assert.NotEqual(t, alias, record.Username)
+				}
+			}
+		}
+	}
+	assert.Equal(t, 9*4, renamed, "one action a street in each of the nine copies")
+
+	// Running it again leaves the account as the first run did
+	require.NoError(t, repo.DeleteAccount(userID))
+	var again models.User
+	require.NoError(t, db.Unscoped().First(&again, "id = ?", userID).Error)
+	assert.Equal(t, user.DeletedAt, again.DeletedAt)
+	assert.Equal(t, alias, again.Username)
+}
@@ -0,0 +1,118 @@
+package retention
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/storage"
+)
+
+// Manifest lists the batches of hands a run wrote to the archive bucket.
+// It is written last, and only batches whose hands were moved out of the
+// database are listed; any other object under the run is left from a
+// batch that failed, and its hands are in a later run.
+type Manifest struct {
+	ArchivedBefore time.Time       `json:"archived_before"`
+	StartedAt      time.Time       `json:"started_at"`
+	Hands          int64           `json:"hands"`
+	Batches        []ManifestBatch `json:"batches"`
+}
+
+// ManifestBatch is an object holding a batch of hands as gzipped JSON
+type ManifestBatch struct {
+	Name           string    `json:"name"`
+	Hands          int       `json:"hands"`
+	FirstStartedAt time.Time `json:"first_started_at"`
+	LastStartedAt  time.Time `json:"last_started_at"`
+	SHA256         string    `json:"sha256"`
+}
+
+// archiveRun writes the hands a run archives to a bucket, under a prefix
+// of its own
+type archiveRun struct {
+	bucket   storage.Bucket
+	prefix   string
+	manifest Manifest
+	pending  *ManifestBatch
+}
+
+func newArchiveRun(bucket storage.Bucket, cutoff, started time.Time) *archiveRun {
+	return &archiveRun{
+		bucket: bucket,
+		prefix: fmt.Sprintf("hands/%s/%s/", cutoff.Format("2006-01-02"), started.Format("20060102T150405Z")),
+		manifest: Manifest{
+			ArchivedBefore: cutoff,
+			StartedAt:      started,
+		},
+	}
+}
+
+// write writes a batch of hands to an object, to be listed in the
+// manifest once the hands have been moved
+func (a *archiveRun) write(hands []models.HandHistory) error {
+	name := fmt.Sprintf("%sbatch-%06d.json.gz", a.prefix, len(a.manifest.Batches)+1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	object := a.bucket.NewWriter(ctx, name, storage.ObjectOptions{ContentType: "application/gzip"})
+
+	hash := sha256.New()
+	compressed := gzip.NewWriter(io.MultiWriter(object, hash))
+	if err := json.NewEncoder(compressed).Encode(hands); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if err := compressed.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if err := object.Close(); err != nil {
+		return fmt.Errorf("failed to store %s: %w", name, err)
+	}
+
+	a.pending = &ManifestBatch{
+		Name:           name,
+		Hands:          len(hands),
+		FirstStartedAt: hands[0].StartedAt,
+		LastStartedAt:  hands[len(hands)-1].StartedAt,
+		SHA256:         hex.EncodeToString(hash.Sum(nil)),
+	}
+	return nil
+}
+
+// moved lists the batch last written, now its hands are out of the
+// database
+func (a *archiveRun) moved() {
+	if a.pending == nil {
+		return
+	}
+	a.manifest.Batches = append(a.manifest.Batches, *a.pending)
+	a.manifest.Hands += int64(a.pending.Hands)
+	a.pending = nil
+}
+
+// writeManifest writes the manifest of the run and returns its name
+func (a *archiveRun) writeManifest() (string, error) {
+	name := a.prefix + "manifest.json"
+
+	data, err := json.MarshalIndent(a.manifest, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	object := a.bucket.NewWriter(ctx, name, storage.ObjectOptions{ContentType: "application/json"})
+	if _, err := object.Write(data); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if err := object.Close(); err != nil {
+		return "", fmt.Errorf("failed to store %s: %w", name, err)
+	}
+	return name, nil
+}
@@ -0,0 +1,258 @@
+// Package retention removes soft-deleted users and games once they have
+// been kept long enough, and moves old hand histories out of the live
+// table into the archive, and into a bucket when one is set.
+package retention
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/repository"
+	"github.com/primoPoker/server/internal/storage"
+)
+
+// DefaultBatchSize is how many rows each step moves when the policy does
+// not say
+const DefaultBatchSize = 1000
+
+// Policy is how long records are kept
+type Policy struct {
+	// PurgeDeletedAfter is how long soft-deleted users and games are kept
+	// before they are removed for good; zero keeps them forever
+	PurgeDeletedAfter time.Duration
+	// ArchiveHandsAfterMonths is the age in months at which hand histories
+	// are archived; zero keeps them all live
+	ArchiveHandsAfterMonths int
+	// BatchSize is how many hands are archived in each transaction, and
+	// how many users or games are looked up at a time
+	BatchSize int
+}
+
+// Totals rebuilds players' stored metrics totals. It is implemented by
+// metrics.Aggregator.
+type Totals interface {
+	RebuildUser(userID uuid.UUID, batchSize int) error
+}
+
+// Report is what a run removed and archived, or on a dry run what it
+// would have
+type Report struct {
+	DryRun bool `json:"dry_run"`
+
+	DeletedBefore *time.Time `json:"deleted_before,omitempty"`
+	UsersPurged   int64      `json:"users_purged"`
+	GamesPurged   int64      `json:"games_purged"`
+
+	ArchivedBefore *time.Time `json:"archived_before,omitempty"`
+	TotalsRebuilt  int        `json:"totals_rebuilt"`
+	HandsArchived  int64      `json:"hands_archived"`
+	// ArchiveManifest names the manifest of the hands written to the
+	// archive bucket
+	ArchiveManifest string `json:"archive_manifest,omitempty"`
+}
+
+// Job applies a retention policy
+type Job struct {
+	repo   *repository.RetentionRepository
+	totals Totals
+	policy Policy
+	bucket storage.Bucket
+	now    func() time.Time
+}
+
+// NewJob creates a job applying policy through repo. Players' totals are
+// rebuilt through totals before any of their hands are archived.
+func NewJob(repo *repository.RetentionRepository, totals Totals, policy Policy) *Job {
+	if policy.BatchSize <= 0 {
+		policy.BatchSize = DefaultBatchSize
+	}
+	return &Job{repo: repo, totals: totals, policy: policy, now: time.Now}
+}
+
+// SetArchive has archived hands also written to bucket, each batch as
+// gzipped JSON, with a manifest of every run's batches
+func (j *Job) SetArchive(bucket storage.Bucket) {
+	j.bucket = bucket
+}
+
+// Enabled reports whether the policy removes or archives anything
+func (j *Job) Enabled() bool {
+	return j.policy.PurgeDeletedAfter > 0 || j.policy.ArchiveHandsAfterMonths > 0
+}
+
+// Run purges the users and games deleted before the policy's cutoff, then
+// archives the hands older than its age. Each user, game and batch of
+// hands is moved in its own transaction, so a run stopped part way keeps
+// what it has done and the next picks up from there.
+func (j *Job) Run() (*Report, error) {
+	report := j.cutoffs()
+
+	if report.DeletedBefore != nil {
+		if err := j.purgeUsers(*report.DeletedBefore, report); err != nil {
+			return report, err
+		}
+		if err := j.purgeGames(*report.DeletedBefore, report); err != nil {
+			return report, err
+		}
+	}
+
+	if report.ArchivedBefore != nil {
+		if err := j.archiveHands(*report.ArchivedBefore, report); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// DryRun reports what Run would remove and archive without changing
+// anything
+func (j *Job) DryRun() (*Report, error) {
+	report := j.cutoffs()
+	report.DryRun = true
+
+	var err error
+	if report.DeletedBefore != nil {
+		if report.UsersPurged, err = j.repo.CountDeletedUsersBefore(*report.DeletedBefore); err != nil {
+			return nil, fmt.Errorf("failed to count deleted users: %w", err)
+		}
+		if report.GamesPurged, err = j.repo.CountDeletedGamesBefore(*report.DeletedBefore); err != nil {
+			return nil, fmt.Errorf("failed to count deleted games: %w", err)
+		}
+	}
+
+	if report.ArchivedBefore != nil {
+		players, err := j.repo.GetUnaggregatedPlayersBefore(*report.ArchivedBefore)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check player totals: %w", err)
+		}
+		report.TotalsRebuilt = len(players)
+
+		if report.HandsArchived, err = j.repo.CountHandsBefore(*report.ArchivedBefore); err != nil {
+			return nil, fmt.Errorf("failed to count hands to archive: %w", err)
+		}
+	}
+	return report, nil
+}
+
+// cutoffs starts a report with the policy's cutoffs as of now. Hands are
+// archived whole days at a time, so the totals of the days archived can be
+// checked against the hands.
+func (j *Job) cutoffs() *Report {
+	now := j.now().UTC()
+	report := &Report{}
+
+	if j.policy.PurgeDeletedAfter > 0 {
+		deletedBefore := now.Add(-j.policy.PurgeDeletedAfter)
+		report.DeletedBefore = &deletedBefore
+	}
+
+	if j.policy.ArchiveHandsAfterMonths > 0 {
+		age := now.AddDate(0, -j.policy.ArchiveHandsAfterMonths, 0)
+		archivedBefore := time.Date(age.Year(), age.Month(), age.Day(), 0, 0, 0, 0, time.UTC)
+		report.ArchivedBefore = &archivedBefore
+	}
+	return report
+}
+
+// purgeUsers removes the users deleted before cutoff one at a time
+func (j *Job) purgeUsers(cutoff time.Time, report *Report) error {
+	for {
+		userIDs, err := j.repo.GetDeletedUsersBefore(cutoff, j.policy.BatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to list deleted users: %w", err)
+		}
+
+		for _, userID := range userIDs {
+			if err := j.repo.PurgeUser(userID); err != nil {
+				return fmt.Errorf("failed to purge user %s: %w", userID, err)
+			}
+			report.UsersPurged++
+		}
+
+		if len(userIDs) < j.policy.BatchSize {
+			return nil
+		}
+	}
+}
+
+// purgeGames removes the games deleted before cutoff one at a time
+func (j *Job) purgeGames(cutoff time.Time, report *Report) error {
+	for {
+		gameIDs, err := j.repo.GetDeletedGamesBefore(cutoff, j.policy.BatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to list deleted games: %w", err)
+		}
+
+		for _, gameID := range gameIDs {
+			if err := j.repo.PurgeGame(gameID); err != nil {
+				return fmt.Errorf("failed to purge game %s: %w", gameID, err)
+			}
+			report.GamesPurged++
+		}
+
+		if len(gameIDs) < j.policy.BatchSize {
+			return nil
+		}
+	}
+}
+
+// archiveHands moves the hands started before cutoff to the archive a
+// batch at a time, first rebuilding the totals of any player they do not
+// all count in. Metrics are summed from the totals, so archived hands
+// still count in them.
+func (j *Job) archiveHands(cutoff time.Time, report *Report) error {
+	players, err := j.repo.GetUnaggregatedPlayersBefore(cutoff)
+	if err != nil {
+		return fmt.Errorf("failed to check player totals: %w", err)
+	}
+
+	for _, userID := range players {
+		if err := j.totals.RebuildUser(userID, j.policy.BatchSize); err != nil {
+			return fmt.Errorf("failed to rebuild totals of %s: %w", userID, err)
+		}
+		report.TotalsRebuilt++
+	}
+
+	if j.bucket == nil {
+		return j.moveHands(cutoff, report, nil)
+	}
+
+	run := newArchiveRun(j.bucket, cutoff, j.now().UTC())
+	err = j.moveHands(cutoff, report, run)
+	if len(run.manifest.Batches) > 0 {
+		// Listing what was moved matters even if the run then failed
+		name, manifestErr := run.writeManifest()
+		if manifestErr != nil && err == nil {
+			err = fmt.Errorf("failed to write archive manifest: %w", manifestErr)
+		}
+		report.ArchiveManifest = name
+	}
+	return err
+}
+
+// moveHands moves the hands started before cutoff to the archive, writing
+// each batch to run first unless it is nil
+func (j *Job) moveHands(cutoff time.Time, report *Report, run *archiveRun) error {
+	var save func([]models.HandHistory) error
+	if run != nil {
+		save = run.write
+	}
+
+	for {
+		moved, err := j.repo.ArchiveHandsBefore(cutoff, j.policy.BatchSize, save)
+		if err != nil {
+			return fmt.Errorf("failed to archive hands: %w", err)
+		}
+		report.HandsArchived += moved
+		if run != nil {
+			run.moved()
+		}
+
+		if moved < int64(j.policy.BatchSize) {
+			return nil
+		}
+	}
+}
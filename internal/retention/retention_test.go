@@ -0,0 +1,311 @@
+package retention
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/primoPoker/server/internal/config"
+	"github.com/primoPoker/server/internal/database"
+	"github.com/primoPoker/server/internal/metrics"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/repository"
+	"github.com/primoPoker/server/internal/storage"
+	"github.com/primoPoker/server/internal/testutil"
+)
+
+// retentionFixture is a database with every table and the archive, and a
+// job keeping hands for a year and deleted records for thirty days
+type retentionFixture struct {
+	db         *gorm.DB
+	job        *Job
+	hands      *repository.HandHistoryRepository
+	aggregator *metrics.Aggregator
+	service    *metrics.Service
+	game       models.Game
+	now        time.Time
+	handNumber int
+}
+
+func newRetentionFixture(t *testing.T) *retentionFixture {
+	t.Helper()
+
+	db := testutil.NewDB(t, database.Models()...)
+	require.NoError(t, database.NewReplicatedDB(db, nil).AutoMigrate())
+
+	hands := repository.NewHandHistoryRepository(db)
+	stats := repository.NewPlayerStatRepository(db)
+	cfg := config.MetricsConfig{SessionGap: 45 * time.Minute}
+
+	f := &retentionFixture{
+		db:         db,
+		hands:      hands,
+		aggregator: metrics.NewAggregator(stats, hands, cfg.SessionGap),
+		service:    metrics.NewServiceWithCache(hands, stats, repository.NewUserRepository(db), cfg, nil),
+		game:       models.Game{ID: uuid.New(), Name: "Table", GameType: models.GameTypeTexasHoldem, SmallBlind: 50, BigBlind: 100},
+		now:        time.Date(2026, 6, 15, 12, 0, 0, 0, time.UTC),
+	}
+	require.NoError(t, db.Create(&f.game).Error)
+
+	f.job = NewJob(repository.NewRetentionRepository(db), f.aggregator, Policy{
+		PurgeDeletedAfter:       30 * 24 * time.Hour,
+		ArchiveHandsAfterMonths: 12,
+		BatchSize:               3,
+	})
+	f.job.now = func() time.Time { return f.now }
+	return f
+}
+
+func (f *retentionFixture) createUser(t *testing.T, name string) models.User {
+	t.Helper()
+
+	user := models.User{ID: uuid.New(), Username: name, Email: name + "@example.com", PasswordHash: "x"}
+	require.NoError(t, f.db.Create(&user).Error)
+	return user
+}
+
+// storeHand stores a hand the player won or lost, adding it to their
+// totals as the hand writer does unless aggregate is false
+func (f *retentionFixture) storeHand(t *testing.T, userID uuid.UUID, at time.Time, won bool, aggregate bool) models.HandHistory {
+	t.Helper()
+
+	f.handNumber++
+	hand := models.HandHistory{
+		ID:            uuid.New(),
+		GameID:        f.game.ID,
+		UserID:        userID,
+		HandNumber:    f.handNumber,
+		SmallBlind:    f.game.SmallBlind,
+		BigBlind:      f.game.BigBlind,
+		StartingChips: 10000,
+		EndingChips:   9800,
+		NetResult:     -200,
+		PotSize:       400,
+		StartedAt:     at,
+		FinishedAt:    at.Add(time.Minute),
+		PreFlopActions: []models.PlayerActionRecord{
+			{PlayerID: userID, Action: models.ActionCall, Amount: 100},
+		},
+	}
+	if won {
+		hand.IsWinner, hand.AmountWon = true, 400
+		hand.EndingChips, hand.NetResult = 10200, 200
+	}
+
+	if !aggregate {
+		require.NoError(t, f.hands.Create(&hand))
+		return hand
+	}
+	require.NoError(t, f.hands.CreateWith(&hand, func(tx *gorm.DB) error {
+		return f.aggregator.AddHand(tx, &hand)
+	}))
+	return hand
+}
+
+// metrics gets a player's all-time metrics, without the periods they were
+// calculated over
+func (f *retentionFixture) metrics(t *testing.T, userID uuid.UUID) *metrics.PlayerMetrics {
+	t.Helper()
+
+	m, err := f.service.GetPlayerMetrics(userID, nil, metrics.Filter{})
+	require.NoError(t, err)
+	m.PeriodStart, m.PeriodEnd = time.Time{}, time.Time{}
+	for i := range m.Stakes {
+		m.Stakes[i].PeriodStart, m.Stakes[i].PeriodEnd = time.Time{}, time.Time{}
+	}
+	return m
+}
+
+func (f *retentionFixture) count(t *testing.T, query *gorm.DB) int64 {
+	t.Helper()
+
+	var count int64
+	require.NoError(t, query.Count(&count).Error)
+	return count
+}
+
+func TestArchiveKeepsMetrics(t *testing.T) {
+	f := newRetentionFixture(t)
+	hero := f.createUser(t, "hero")
+	latecomer := f.createUser(t, "latecomer")
+
+	old := f.now.AddDate(-1, -2, 0)
+	for i := 0; i < 7; i++ {
+		f.storeHand(t, hero.ID, old.Add(time.Duration(i)*time.Hour), i%3 == 0, true)
+	}
+	for i := 0; i < 2; i++ {
+		f.storeHand(t, hero.ID, f.now.AddDate(0, -1, i), i == 0, true)
+	}
+
+	// Hands stored before totals were kept, never backfilled
+	for i := 0; i < 4; i++ {
+		f.storeHand(t, latecomer.ID, old.Add(time.Duration(i)*time.Hour), i%2 == 0, false)
+	}
+	f.storeHand(t, latecomer.ID, f.now.AddDate(0, 0, -2), true, true)
+
+	require.NoError(t, f.aggregator.RebuildUser(hero.ID, 100))
+	heroBefore := f.metrics(t, hero.ID)
+
+	// What the latecomer's metrics should be once their totals count
+	// every hand
+	require.NoError(t, f.aggregator.RebuildUser(latecomer.ID, 100))
+	latecomerBefore := f.metrics(t, latecomer.ID)
+	require.NoError(t, f.db.Where("user_id = ? AND day < ?", latecomer.ID, f.now.AddDate(0, -1, 0)).
+		Delete(&models.PlayerStatAggregate{}).Error)
+
+	dryRun, err := f.job.DryRun()
+	require.NoError(t, err)
+	assert.True(t, dryRun.DryRun)
+	assert.Equal(t, int64(11), dryRun.HandsArchived)
+	assert.Equal(t, 1, dryRun.TotalsRebuilt)
+	assert.Equal(t, int64(14), f.count(t, f.db.Model(&models.HandHistory{})), "a dry run moves nothing")
+
+	report, err := f.job.Run()
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), report.HandsArchived)
+	assert.Equal(t, 1, report.TotalsRebuilt)
+	require.NotNil(t, report.ArchivedBefore)
+	assert.Equal(t, time.Date(2025, 6, 15, 0, 0, 0, 0, time.UTC), *report.ArchivedBefore)
+
+	assert.Equal(t, int64(3), f.count(t, f.db.Model(&models.HandHistory{})))
+	assert.Equal(t, int64(11), f.count(t, f.db.Table(models.HandHistoryArchiveTable)))
+
+	assert.Equal(t, heroBefore, f.metrics(t, hero.ID))
+	assert.Equal(t, latecomerBefore, f.metrics(t, latecomer.ID))
+
+	// A rebuild after archiving still counts the archived hands
+	require.NoError(t, f.aggregator.RebuildUser(hero.ID, 2))
+	assert.Equal(t, heroBefore, f.metrics(t, hero.ID))
+
+	report, err = f.job.Run()
+	require.NoError(t, err)
+	assert.Zero(t, report.HandsArchived)
+	assert.Zero(t, report.TotalsRebuilt)
+}
+
+func TestArchiveWritesBatchesToTheBucket(t *testing.T) {
+	f := newRetentionFixture(t)
+	bucket := storage.NewMemory("archive")
+	f.job.SetArchive(bucket)
+	hero := f.createUser(t, "hero")
+
+	old := f.now.AddDate(-1, -2, 0)
+	var archived []models.HandHistory
+	for i := 0; i < 5; i++ {
+		archived = append(archived, f.storeHand(t, hero.ID, old.Add(time.Duration(i)*time.Hour), i%2 == 0, true))
+	}
+	f.storeHand(t, hero.ID, f.now.AddDate(0, -1, 0), true, true)
+
+	report, err := f.job.Run()
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), report.HandsArchived)
+	assert.Equal(t, "hands/2025-06-15/20260615T120000Z/manifest.json", report.ArchiveManifest)
+
+	object, ok := bucket.Object(report.ArchiveManifest)
+	require.True(t, ok)
+	var manifest Manifest
+	require.NoError(t, json.Unmarshal(object.Data, &manifest))
+	assert.Equal(t, int64(5), manifest.Hands)
+	require.Len(t, manifest.Batches, 2, "three hands to a batch")
+	assert.Equal(t, "hands/2025-06-15/20260615T120000Z/batch-000001.json.gz", manifest.Batches[0].Name)
+
+	var stored []models.HandHistory
+	for _, batch := range manifest.Batches {
+		object, ok := bucket.Object(batch.Name)
+		require.True(t, ok, batch.Name)
+		sum := sha256.Sum256(object.Data)
+		assert.Equal(t, batch.SHA256, hex.EncodeToString(sum[:]))
+
+		reader, err := gzip.NewReader(bytes.NewReader(object.Data))
+		require.NoError(t, err)
+		var hands []models.HandHistory
+		require.NoError(t, json.NewDecoder(reader).Decode(&hands))
+		assert.Len(t, hands, batch.Hands)
+		stored = append(stored, hands...)
+	}
+	require.Len(t, stored, len(archived))
+	for i, hand := range stored {
+		assert.Equal(t, archived[i].ID, hand.ID)
+		assert.Equal(t, archived[i].NetResult, hand.NetResult)
+	}
+
+	report, err = f.job.Run()
+	require.NoError(t, err)
+	assert.Empty(t, report.ArchiveManifest, "a run archiving nothing writes no manifest")
+}
+
+func TestPurgeDeletedRecords(t *testing.T) {
+	f := newRetentionFixture(t)
+	gone := f.createUser(t, "gone")
+	recent := f.createUser(t, "recent")
+	stays := f.createUser(t, "stays")
+
+	f.storeHand(t, gone.ID, f.now.AddDate(0, -3, 0), true, true)
+	f.storeHand(t, stays.ID, f.now.AddDate(0, -3, 0), false, true)
+	require.NoError(t, f.db.Create(&models.Session{ID: uuid.New(), UserID: gone.ID, ExpiresAt: f.now}).Error)
+	require.NoError(t, f.db.Create(&models.GameParticipation{ID: uuid.New(), GameID: f.game.ID, UserID: gone.ID}).Error)
+	require.NoError(t, f.db.Model(&f.game).Update("winner_id", gone.ID).Error)
+
+	deletedGame := models.Game{ID: uuid.New(), Name: "Old", GameType: models.GameTypeTexasHoldem, SmallBlind: 5, BigBlind: 10}
+	require.NoError(t, f.db.Create(&deletedGame).Error)
+	require.NoError(t, f.db.Create(&models.GameParticipation{ID: uuid.New(), GameID: deletedGame.ID, UserID: stays.ID}).Error)
+
+	softDelete := func(model interface{}, id uuid.UUID, at time.Time) {
+		require.NoError(t, f.db.Unscoped().Model(model).Where("id = ?", id).Update("deleted_at", at).Error)
+	}
+	softDelete(&models.User{}, gone.ID, f.now.AddDate(0, 0, -31))
+	softDelete(&models.User{}, recent.ID, f.now.AddDate(0, 0, -29))
+	softDelete(&models.Game{}, deletedGame.ID, f.now.AddDate(0, 0, -40))
+
+	dryRun, err := f.job.DryRun()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), dryRun.UsersPurged)
+	assert.Equal(t, int64(1), dryRun.GamesPurged)
+	assert.Equal(t, int64(3), f.count(t, f.db.Unscoped().Model(&models.User{})), "a dry run removes nothing")
+
+	report, err := f.job.Run()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), report.UsersPurged)
+	assert.Equal(t, int64(1), report.GamesPurged)
+
+	assert.Zero(t, f.count(t, f.db.Unscoped().Model(&models.User{}).Where("id = ?", gone.ID)))
+	assert.Zero(t, f.count(t, f.db.Unscoped().Model(&models.HandHistory{}).Where("user_id = ?", gone.ID)))
+	assert.Zero(t, f.count(t, f.db.Unscoped().Model(&models.PlayerStatAggregate{}).Where("user_id = ?", gone.ID)))
+	assert.Zero(t, f.count(t, f.db.Unscoped().Model(&models.Session{}).Where("user_id = ?", gone.ID)))
+	assert.Zero(t, f.count(t, f.db.Unscoped().Model(&models.Game{}).Where("id = ?", deletedGame.ID)))
+	assert.Zero(t, f.count(t, f.db.Unscoped().Model(&models.GameParticipation{}).Where("game_id = ?", deletedGame.ID)))
+
+	// Recently deleted users and everyone else's records stay
+	assert.Equal(t, int64(1), f.count(t, f.db.Unscoped().Model(&models.User{}).Where("id = ?", recent.ID)))
+	assert.Equal(t, int64(1), f.count(t, f.db.Model(&models.HandHistory{}).Where("user_id = ?", stays.ID)))
+
+	var game models.Game
+	require.NoError(t, f.db.First(&game, "id = ?", f.game.ID).Error)
+	assert.Nil(t, game.WinnerID)
+}
+
+func TestDisabledPolicy(t *testing.T) {
+	f := newRetentionFixture(t)
+	hero := f.createUser(t, "hero")
+	f.storeHand(t, hero.ID, f.now.AddDate(-3, 0, 0), true, true)
+	require.NoError(t, f.db.Delete(&hero).Error)
+
+	job := NewJob(repository.NewRetentionRepository(f.db), f.aggregator, Policy{})
+	assert.False(t, job.Enabled())
+
+	report, err := job.Run()
+	require.NoError(t, err)
+	assert.Nil(t, report.DeletedBefore)
+	assert.Nil(t, report.ArchivedBefore)
+	assert.Equal(t, int64(1), f.count(t, f.db.Model(&models.HandHistory{})))
+	assert.Equal(t, int64(1), f.count(t, f.db.Unscoped().Model(&models.User{})))
+}
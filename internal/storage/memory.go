@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Object is an object held by a Memory bucket
+type Object struct {
+	Data []byte
+	ObjectOptions
+}
+
+// Memory is a Bucket held in memory, for tests. Its URLs are not served.
+type Memory struct {
+	name string
+
+	mu      sync.Mutex
+	objects map[string]Object
+}
+
+// NewMemory creates an empty bucket called name
+func NewMemory(name string) *Memory {
+	return &Memory{
+		name:    name,
+		objects: make(map[string]Object),
+	}
+}
+
+// NewWriter starts writing an object, stored when the writer is closed
+// unless ctx is done by then
+func (m *Memory) NewWriter(ctx context.Context, name string, opts ObjectOptions) io.WriteCloser {
+	return &memoryWriter{ctx: ctx, bucket: m, name: name, opts: opts}
+}
+
+// Delete removes an object, or returns ErrNotFound
+func (m *Memory) Delete(ctx context.Context, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.objects[name]; !ok {
+		return ErrNotFound
+	}
+	delete(m.objects, name)
+	return nil
+}
+
+// SignedURL returns a URL for the object, carrying when it expires
+func (m *Memory) SignedURL(ctx context.Context, name string, ttl time.Duration) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.objects[name]; !ok {
+		return "", ErrNotFound
+	}
+	return fmt.Sprintf("%s?expires=%d", m.PublicURL(name), time.Now().Add(ttl).Unix()), nil
+}
+
+// PublicURL returns the URL of an object
+func (m *Memory) PublicURL(name string) string {
+	return "memory://" + m.name + "/" + (&url.URL{Path: name}).EscapedPath()
+}
+
+// Object returns an object and whether the bucket holds it
+func (m *Memory) Object(name string) (Object, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	object, ok := m.objects[name]
+	return object, ok
+}
+
+// Names lists the names of the objects under prefix, in order
+func (m *Memory) Names(prefix string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var names []string
+	for name := range m.objects {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// memoryWriter buffers an object until it is closed
+type memoryWriter struct {
+	ctx    context.Context
+	bucket *Memory
+	name   string
+	opts   ObjectOptions
+	buf    bytes.Buffer
+}
+
+func (w *memoryWriter) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return w.buf.Write(p)
+}
+
+func (w *memoryWriter) Close() error {
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+
+	w.bucket.mu.Lock()
+	defer w.bucket.mu.Unlock()
+
+	w.bucket.objects[w.name] = Object{Data: w.buf.Bytes(), ObjectOptions: w.opts}
+	return nil
+}
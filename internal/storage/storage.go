@@ -0,0 +1,91 @@
+// Package storage keeps files that should neither be served by the server
+// nor held in the database: large hand history exports, archived hands and
+// avatars. Buckets are kept in Cloud Storage; Memory holds them for tests.
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrNotFound is returned for an object a bucket does not hold
+var ErrNotFound = errors.New("object not found")
+
+// ObjectOptions are how an object is served when it is fetched
+type ObjectOptions struct {
+	ContentType        string
+	ContentEncoding    string
+	ContentDisposition string
+	CacheControl       string
+}
+
+// Bucket holds objects by name
+type Bucket interface {
+	// NewWriter starts writing an object, which is stored when the writer
+	// is closed. Cancelling ctx before then abandons it, leaving any
+	// object already under the name as it was.
+	NewWriter(ctx context.Context, name string, opts ObjectOptions) io.WriteCloser
+	// Delete removes an object, or returns ErrNotFound
+	Delete(ctx context.Context, name string) error
+	// SignedURL returns a URL the object can be fetched from without
+	// credentials until ttl has passed
+	SignedURL(ctx context.Context, name string, ttl time.Duration) (string, error)
+	// PublicURL returns the URL of an object in a publicly readable bucket
+	PublicURL(name string) string
+}
+
+// SpillWriter holds what is written to it in memory until it grows past a
+// limit, then moves it to an object and writes the rest there. Small files
+// are served straight from memory; large ones are never held whole.
+type SpillWriter struct {
+	ctx    context.Context
+	bucket Bucket
+	name   string
+	opts   ObjectOptions
+	limit  int
+	buf    bytes.Buffer
+	object io.WriteCloser
+}
+
+// NewSpillWriter creates a writer holding up to limit bytes before it
+// spills to the object name in bucket
+func NewSpillWriter(ctx context.Context, bucket Bucket, name string, opts ObjectOptions, limit int) *SpillWriter {
+	return &SpillWriter{ctx: ctx, bucket: bucket, name: name, opts: opts, limit: limit}
+}
+
+// Write holds p, or writes it to the object once the limit is passed
+func (s *SpillWriter) Write(p []byte) (int, error) {
+	if s.object == nil {
+		if s.buf.Len()+len(p) <= s.limit {
+			return s.buf.Write(p)
+		}
+		s.object = s.bucket.NewWriter(s.ctx, s.name, s.opts)
+		if _, err := s.object.Write(s.buf.Bytes()); err != nil {
+			return 0, err
+		}
+		s.buf.Reset()
+	}
+	return s.object.Write(p)
+}
+
+// Spilled reports whether the writer has moved to the object
+func (s *SpillWriter) Spilled() bool {
+	return s.object != nil
+}
+
+// Bytes returns what is held in memory, which is everything written until
+// the writer spills
+func (s *SpillWriter) Bytes() []byte {
+	return s.buf.Bytes()
+}
+
+// Close stores the object, if the writer has spilled
+func (s *SpillWriter) Close() error {
+	if s.object == nil {
+		return nil
+	}
+	return s.object.Close()
+}
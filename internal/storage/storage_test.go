@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpillWriterHoldsSmallFilesInMemory(t *testing.T) {
+	bucket := NewMemory("exports")
+	spill := NewSpillWriter(context.Background(), bucket, "small.txt", ObjectOptions{}, 16)
+
+	_, err := io.WriteString(spill, "eight by")
+	require.NoError(t, err)
+	_, err = io.WriteString(spill, "tes more")
+	require.NoError(t, err)
+	require.NoError(t, spill.Close())
+
+	assert.False(t, spill.Spilled())
+	assert.Equal(t, "eight bytes more", string(spill.Bytes()))
+	assert.Empty(t, bucket.Names(""), "nothing is stored")
+}
+
+func TestSpillWriterMovesLargeFilesToTheBucket(t *testing.T) {
+	bucket := NewMemory("exports")
+	opts := ObjectOptions{ContentType: "text/plain; charset=utf-8"}
+	spill := NewSpillWriter(context.Background(), bucket, "large.txt", opts, 16)
+
+	_, err := io.WriteString(spill, "sixteen bytes ok")
+	require.NoError(t, err)
+	_, err = io.WriteString(spill, ", and then some")
+	require.NoError(t, err)
+	assert.True(t, spill.Spilled())
+	assert.Empty(t, spill.Bytes())
+
+	_, stored := bucket.Object("large.txt")
+	assert.False(t, stored, "the object is stored once closed")
+	require.NoError(t, spill.Close())
+
+	object, stored := bucket.Object("large.txt")
+	require.True(t, stored)
+	assert.Equal(t, "sixteen bytes ok, and then some", string(object.Data))
+	assert.Equal(t, opts, object.ObjectOptions)
+}
+
+func TestSpillWriterAbandonedWithItsContext(t *testing.T) {
+	bucket := NewMemory("exports")
+	ctx, cancel := context.WithCancel(context.Background())
+	spill := NewSpillWriter(ctx, bucket, "failed.txt", ObjectOptions{}, 4)
+
+	_, err := io.WriteString(spill, "more than four bytes")
+	require.NoError(t, err)
+	cancel()
+
+	assert.Error(t, spill.Close())
+	assert.Empty(t, bucket.Names(""), "an abandoned export is not stored")
+}
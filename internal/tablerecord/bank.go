@@ -0,0 +1,105 @@
+package tablerecord
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"context"
+	"github.com/primoPoker/server/internal/database"
+	"github.com/primoPoker/server/internal/game"
+	"github.com/primoPoker/server/internal/models"
+)
+
+// Users is the part of repository.UserRepository a Bank moves chips through
+type Users interface {
+	CreditChips(userID uuid.UUID, amount int64) (int64, error)
+	DebitChips(userID uuid.UUID, amount int64) (int64, error)
+}
+
+// Work runs a function in a database.UnitOfWork. *database.DB is one.
+type Work interface {
+	UnitOfWork(ctx context.Context, fn func(uow *database.UnitOfWork) error) error
+}
+
+// Bank takes buy-ins from and cashes stacks out to users' chip balances. It
+// implements game.Bank.
+type Bank struct {
+	users Users
+
+	// With work set, a buy-in at a stored table is debited in the same
+	// transaction as the player's seat is stored, and store told of it
+	work  Work
+	store *Store
+}
+
+// NewBank creates a bank over users' stored balances
+func NewBank(users Users) *Bank {
+	return &Bank{users: users}
+}
+
+// StoreSeats makes the bank store the seat of each player buying in at a
+// stored table in the same transaction as their buy-in is debited, so a
+// player is never charged for a seat that was not stored. store is told of
+// the seats so it unseats the players when they leave.
+func (b *Bank) StoreSeats(work Work, store *Store) {
+	b.work, b.store = work, store
+}
+
+// BuyIn debits a player's buy-in from their balance
+func (b *Bank) BuyIn(ctx context.Context, recordID string, seat game.SeatState) error {
+	userID, err := uuid.Parse(seat.PlayerID)
+	if err != nil {
+		return fmt.Errorf("invalid player ID: %w", err)
+	}
+
+	gameID, err := uuid.Parse(recordID)
+	if b.work == nil || err != nil {
+		_, err = b.users.DebitChips(userID, seat.BuyIn)
+		return err
+	}
+
+	err = b.work.UnitOfWork(ctx, func(uow *database.UnitOfWork) error {
+		if _, err := uow.Users().DebitChips(userID, seat.BuyIn); err != nil {
+			return err
+		}
+		return uow.Games().EnsureParticipation(&models.GameParticipation{
+			GameID:       gameID,
+			UserID:       userID,
+			SeatPosition: seat.SeatPosition,
+			BuyInAmount:  seat.BuyIn,
+			CurrentChips: seat.Chips,
+			IsActive:     true,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	if b.store != nil {
+		b.store.seat(gameID, userID)
+	}
+	return nil
+}
+
+// CashOut credits a player's stack to their balance. A failure is logged
+// with the amount owed so it can be paid by hand.
+func (b *Bank) CashOut(playerID string, amount int64) {
+	log := logrus.WithFields(logrus.Fields{
+		"user_id": playerID,
+		"amount":  amount,
+	})
+
+	userID, err := uuid.Parse(playerID)
+	if err != nil {
+		log.Warn("Not cashing out a player without a UUID")
+		return
+	}
+
+	balance, err := b.users.CreditChips(userID, amount)
+	if err != nil {
+		log.WithError(err).Error("Failed to cash out player")
+		return
+	}
+	log.WithField("balance", balance).Debug("Cashed out player")
+}
@@ -0,0 +1,108 @@
+package tablerecord
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"context"
+	"github.com/primoPoker/server/internal/database"
+	"github.com/primoPoker/server/internal/game"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/repository"
+	"github.com/primoPoker/server/internal/testutil"
+)
+
+func TestBankMovesChipsWithPlayers(t *testing.T) {
+	db := testutil.NewDB(t, &models.User{})
+	users := repository.NewUserRepository(db)
+	var players []string
+	for _, name := range []string{"alice", "bob", "carol"} {
+		user := models.User{ID: uuid.New(), Username: name, Email: name + "@example.com", PasswordHash: "x", ChipBalance: 15000}
+		require.NoError(t, db.Create(&user).Error)
+		players = append(players, user.ID.String())
+	}
+	balance := func(playerID string) int64 {
+		user, err := users.GetByID(uuid.MustParse(playerID))
+		require.NoError(t, err)
+		return user.ChipBalance
+	}
+
+	manager := game.NewManager()
+	manager.SetBank(NewBank(users))
+	table, err := manager.CreateGame("banked", "Banked")
+	require.NoError(t, err)
+
+	require.NoError(t, manager.JoinGame(context.Background(), table.ID, players[0], "alice", 10000))
+	require.NoError(t, manager.JoinGame(context.Background(), table.ID, players[1], "bob", 10000))
+	assert.Equal(t, int64(5000), balance(players[0]), "the buy-in is taken as the player sits")
+
+	err = manager.JoinGame(context.Background(), table.ID, players[2], "carol", 20000)
+	assert.ErrorIs(t, err, repository.ErrInsufficientBalance)
+	assert.Len(t, table.GetGameState("").Players, 2, "players who cannot cover the buy-in are not seated")
+	assert.Equal(t, int64(15000), balance(players[2]))
+
+	// Joining a table twice refunds the second buy-in
+	assert.Error(t, manager.JoinGame(context.Background(), table.ID, players[0], "alice", 5000))
+	assert.Equal(t, int64(5000), balance(players[0]))
+
+	folder := table.GetGameState("").CurrentPlayer
+	require.NoError(t, manager.ProcessAction(context.Background(), table.ID, folder, game.Fold, 0))
+
+	stacks, err := manager.CloseGame(context.Background(), table.ID)
+	require.NoError(t, err)
+	for _, playerID := range players[:2] {
+		assert.Equal(t, 5000+stacks[playerID], balance(playerID), "stacks are paid back as the table closes")
+	}
+	assert.Equal(t, int64(30000), balance(players[0])+balance(players[1]), "no chips are made or lost")
+}
+
+func TestBankStoresSeatWithBuyIn(t *testing.T) {
+	db := testutil.NewDB(t, &models.User{}, &models.Game{}, &models.GameParticipation{})
+	users := repository.NewUserRepository(db)
+	players := make(map[string]uuid.UUID)
+	for _, name := range []string{"alice", "bob", "carol"} {
+		user := models.User{ID: uuid.New(), Username: name, Email: name + "@example.com", PasswordHash: "x", ChipBalance: 15000}
+		if name == "carol" {
+			user.ChipBalance = 5000
+		}
+		require.NoError(t, db.Create(&user).Error)
+		players[name] = user.ID
+	}
+	balance := func(name string) int64 {
+		user, err := users.GetByID(players[name])
+		require.NoError(t, err)
+		return user.ChipBalance
+	}
+	seats := func() []models.GameParticipation {
+		var seats []models.GameParticipation
+		require.NoError(t, db.Find(&seats).Error)
+		return seats
+	}
+
+	store := NewStore(repository.NewGameRepository(db), 16)
+	bank := NewBank(users)
+	bank.StoreSeats(database.NewReplicatedDB(db, nil), store)
+	manager := game.NewManager()
+	manager.SetStore(store)
+	manager.SetBank(bank)
+	table, err := manager.CreateGame("seated", "Seated")
+	require.NoError(t, err)
+
+	require.NoError(t, manager.JoinGame(context.Background(), table.ID, players["alice"].String(), "alice", 10000))
+	stored := seats()
+	require.Len(t, stored, 1, "the seat is stored as the buy-in is taken")
+	assert.Equal(t, players["alice"], stored[0].UserID)
+	assert.Equal(t, int64(10000), stored[0].BuyInAmount)
+	assert.Equal(t, int64(5000), balance("alice"))
+
+	err = manager.JoinGame(context.Background(), table.ID, players["carol"].String(), "carol", 10000)
+	assert.ErrorIs(t, err, repository.ErrInsufficientBalance)
+	assert.Len(t, seats(), 1, "a refused buy-in stores no seat")
+
+	require.NoError(t, db.Migrator().DropTable(&models.GameParticipation{}))
+	assert.Error(t, manager.JoinGame(context.Background(), table.ID, players["bob"].String(), "bob", 10000))
+	assert.Equal(t, int64(15000), balance("bob"), "a seat that cannot be stored takes no buy-in")
+}
@@ -0,0 +1,37 @@
+package tablerecord
+
+import (
+	"github.com/google/uuid"
+)
+
+// Members is the part of repository.ClubRepository Clubs checks membership
+// through
+type Members interface {
+	IsMember(clubID, userID uuid.UUID) (bool, error)
+}
+
+// Clubs checks players against clubs' stored members. It implements
+// game.Clubs.
+type Clubs struct {
+	members Members
+}
+
+// NewClubs creates a membership check over stored club members
+func NewClubs(members Members) *Clubs {
+	return &Clubs{members: members}
+}
+
+// IsMember reports whether a player belongs to a club. Players and clubs
+// without a UUID belong to nothing.
+func (c *Clubs) IsMember(clubID, playerID string) (bool, error) {
+	club, err := uuid.Parse(clubID)
+	if err != nil {
+		return false, nil
+	}
+	userID, err := uuid.Parse(playerID)
+	if err != nil {
+		return false, nil
+	}
+
+	return c.members.IsMember(club, userID)
+}
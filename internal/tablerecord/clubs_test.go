@@ -0,0 +1,52 @@
+package tablerecord
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"context"
+	"github.com/primoPoker/server/internal/game"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/repository"
+	"github.com/primoPoker/server/internal/testutil"
+)
+
+func TestClubTablesSeatOnlyMembers(t *testing.T) {
+	db := testutil.NewDB(t, &models.User{}, &models.Club{}, &models.ClubMember{})
+	clubs := repository.NewClubRepository(db)
+	owner, member, outsider := uuid.New(), uuid.New(), uuid.New()
+
+	club := &models.Club{Name: "Thursday Game", OwnerID: owner}
+	require.NoError(t, clubs.Create(club))
+	_, err := clubs.Join(club.JoinCode, member)
+	require.NoError(t, err)
+
+	manager := game.NewManager()
+	manager.SetClubs(NewClubs(clubs))
+	table, err := manager.CreateGame("club", "Club Table", game.WithClub(club.ID.String()))
+	require.NoError(t, err)
+	open, err := manager.CreateGame("open", "Open Table")
+	require.NoError(t, err)
+
+	require.NoError(t, manager.JoinGame(context.Background(), table.ID, owner.String(), "owner", 10000))
+	require.NoError(t, manager.JoinGame(context.Background(), table.ID, member.String(), "member", 10000))
+
+	err = manager.JoinGame(context.Background(), table.ID, outsider.String(), "outsider", 10000)
+	assert.ErrorIs(t, err, game.ErrNotClubMember)
+	assert.Len(t, table.GetGameState("").Players, 2, "non-members are not seated")
+	assert.NoError(t, manager.JoinGame(context.Background(), open.ID, outsider.String(), "outsider", 10000), "other tables stay open to everyone")
+
+	// Leaving the club closes its tables to the player
+	require.NoError(t, clubs.Leave(club.ID, member))
+	require.NoError(t, manager.LeaveGame(context.Background(), table.ID, member.String()))
+	assert.ErrorIs(t, manager.JoinGame(context.Background(), table.ID, member.String(), "member", 10000), game.ErrNotClubMember)
+
+	// Without a membership check no one sits at a club's table
+	unchecked := game.NewManager()
+	closed, err := unchecked.CreateGame("club", "Club Table", game.WithClub(club.ID.String()))
+	require.NoError(t, err)
+	assert.ErrorIs(t, unchecked.JoinGame(context.Background(), closed.ID, owner.String(), "owner", 10000), game.ErrNotClubMember)
+}
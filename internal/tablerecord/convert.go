@@ -0,0 +1,127 @@
+package tablerecord
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/primoPoker/server/internal/game"
+	"github.com/primoPoker/server/internal/models"
+)
+
+// gameStatuses maps live table statuses to stored game statuses
+var gameStatuses = map[game.TableStatus]models.GameStatus{
+	game.TableWaiting:   models.GameStatusWaiting,
+	game.TablePlaying:   models.GameStatusActive,
+	game.TableFinished:  models.GameStatusFinished,
+	game.TableAbandoned: models.GameStatusAbandoned,
+}
+
+// Keys of the table settings stored in a game's settings
+const (
+	settingTemplateID = "template_id"
+	settingAnte       = "ante"
+)
+
+// Game builds the Game row of a table being opened. Tables with a UUID are
+// stored under it; any other ID is replaced by a new one when the row is
+// created.
+func Game(table game.TableState) *models.Game {
+	record := &models.Game{
+		Name:        table.Name,
+		GameType:    models.GameTypeTexasHoldem,
+		Status:      gameStatuses[table.Status],
+		MaxPlayers:  table.MaxPlayers,
+		MinPlayers:  table.MinPlayers,
+		SmallBlind:  table.SmallBlind,
+		BigBlind:    table.BigBlind,
+		BuyIn:       table.BuyIn,
+		MinBuyIn:    table.MinBuyIn,
+		MaxBuyIn:    table.MaxBuyIn,
+		CurrentHand: table.HandNumber,
+		TurnTimeout: int(table.TurnTimeout / time.Second),
+	}
+	if table.TemplateID != "" || table.Ante > 0 {
+		record.Settings = map[string]any{}
+		if table.TemplateID != "" {
+			record.Settings[settingTemplateID] = table.TemplateID
+		}
+		if table.Ante > 0 {
+			record.Settings[settingAnte] = table.Ante
+		}
+	}
+	if id, err := uuid.Parse(table.ID); err == nil {
+		record.ID = id
+	}
+	if clubID, err := uuid.Parse(table.ClubID); err == nil {
+		record.ClubID = &clubID
+	}
+	return record
+}
+
+// TableState builds the state of a stored game to reopen it as a live table
+// under its stored ID, with the players still active in it seated
+func TableState(record models.Game) game.TableState {
+	table := game.TableState{
+		ID:          record.ID.String(),
+		RecordID:    record.ID.String(),
+		Name:        record.Name,
+		Status:      game.TableWaiting,
+		SmallBlind:  record.SmallBlind,
+		BigBlind:    record.BigBlind,
+		BuyIn:       record.BuyIn,
+		MinBuyIn:    record.MinBuyIn,
+		MaxBuyIn:    record.MaxBuyIn,
+		MinPlayers:  record.MinPlayers,
+		MaxPlayers:  record.MaxPlayers,
+		TurnTimeout: time.Duration(record.TurnTimeout) * time.Second,
+		HandNumber:  record.CurrentHand,
+	}
+	if record.ClubID != nil {
+		table.ClubID = record.ClubID.String()
+	}
+	table.TemplateID, _ = record.Settings[settingTemplateID].(string)
+	// Settings read back from the database are decoded from JSON, so their
+	// numbers are float64
+	switch ante := record.Settings[settingAnte].(type) {
+	case float64:
+		table.Ante = int64(ante)
+	case int64:
+		table.Ante = ante
+	}
+	for _, participation := range record.Participations {
+		if !participation.IsActive {
+			continue
+		}
+		table.Seats = append(table.Seats, game.SeatState{
+			PlayerID:     participation.UserID.String(),
+			Username:     participation.User.Username,
+			SeatPosition: participation.SeatPosition,
+			BuyIn:        participation.BuyInAmount,
+			Chips:        participation.CurrentChips,
+		})
+	}
+	return table
+}
+
+// gameState builds the columns of a game updated at a hand boundary; the
+// totals of each hand played are settled with its hand history. The
+// current pot is only non-zero while a hand is in progress, which is how a
+// hand cut short by a restart is told apart. The status of a closing table
+// is left to UpdateGameStatus, which also stamps when it finished.
+func gameState(table game.TableState) map[string]interface{} {
+	state := map[string]interface{}{
+		"current_hand":    table.HandNumber,
+		"dealer_position": table.DealerSeat,
+		"current_pot":     table.Pot,
+	}
+	switch table.Status {
+	case game.TableWaiting:
+		state["status"] = models.GameStatusWaiting
+	case game.TablePlaying:
+		state["status"] = models.GameStatusActive
+		state["started_at"] = gorm.Expr("COALESCE(started_at, ?)", time.Now())
+	}
+	return state
+}
@@ -0,0 +1,251 @@
+// Package tablerecord persists live tables as Game rows, with a
+// GameParticipation for each player seated. The game engine reports each
+// table's state at hand boundaries to a Store, which writes it from its own
+// goroutine so play never waits on the database. A Bank moves the chips
+// players bring to and take from tables through their stored balances.
+package tablerecord
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/primoPoker/server/internal/game"
+	"github.com/primoPoker/server/internal/models"
+)
+
+// DefaultQueueSize is how many table states may wait to be written
+const DefaultQueueSize = 1024
+
+// Games is the part of repository.GameRepository a Store writes through
+type Games interface {
+	Create(game *models.Game) error
+	GetActiveGames() ([]models.Game, error)
+	UpdateGameState(gameID uuid.UUID, state map[string]interface{}) error
+	UpdateGameStatus(gameID uuid.UUID, status models.GameStatus) error
+	EnsureParticipation(participation *models.GameParticipation) error
+	LeaveGame(gameID, userID uuid.UUID) error
+	LeaveAll(gameID uuid.UUID) error
+	UpdateParticipationStats(gameID, userID uuid.UUID, stats map[string]interface{}) error
+}
+
+// Store stores live tables. It implements game.TableStore.
+type Store struct {
+	games Games
+
+	// seated holds the players with an active participation in each game
+	seatMu sync.Mutex
+	seated map[uuid.UUID]map[uuid.UUID]bool
+
+	mu     sync.RWMutex
+	queue  chan game.TableState
+	closed bool
+	done   chan struct{}
+}
+
+// NewStore creates a store holding up to queueSize table states in memory
+func NewStore(games Games, queueSize int) *Store {
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+	return &Store{
+		games:  games,
+		seated: make(map[uuid.UUID]map[uuid.UUID]bool),
+		queue:  make(chan game.TableState, queueSize),
+		done:   make(chan struct{}),
+	}
+}
+
+// Run writes queued table states until the store is closed
+func (s *Store) Run() {
+	defer close(s.done)
+	for table := range s.queue {
+		s.save(table)
+	}
+}
+
+// Close stops accepting table states and waits for the queued ones to be
+// written
+func (s *Store) Close() {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+
+	<-s.done
+}
+
+// CreateTable stores a table as it opens
+func (s *Store) CreateTable(table game.TableState) (string, error) {
+	record := Game(table)
+	if err := s.games.Create(record); err != nil {
+		return "", err
+	}
+	return record.ID.String(), nil
+}
+
+// SaveTable queues a table's state to be written. It never blocks: when
+// the queue is full the state is dropped and logged.
+func (s *Store) SaveTable(table game.TableState) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return
+	}
+
+	select {
+	case s.queue <- table:
+	default:
+		logrus.WithFields(logrus.Fields{
+			"game_id":     table.RecordID,
+			"hand_number": table.HandNumber,
+		}).Error("Table state queue is full, dropping state")
+	}
+}
+
+// ReopenTables returns the games a previous run left waiting or active,
+// with their players seated as they were after the last hand stored. A
+// hand that was in progress is counted as voided: it never finished, so
+// it has no hand history and is missing from every player's metrics.
+func (s *Store) ReopenTables() ([]game.TableState, error) {
+	records, err := s.games.GetActiveGames()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get open games: %w", err)
+	}
+
+	tables := make([]game.TableState, 0, len(records))
+	for _, record := range records {
+		state := map[string]interface{}{"status": models.GameStatusWaiting}
+		if record.CurrentPot > 0 {
+			logrus.WithFields(logrus.Fields{
+				"game_id":     record.ID,
+				"hand_number": record.CurrentHand,
+			}).Warn("Voiding hand cut short by a restart")
+			state["voided_hands"] = gorm.Expr("voided_hands + ?", 1)
+			state["current_pot"] = 0
+		}
+		if err := s.games.UpdateGameState(record.ID, state); err != nil {
+			return nil, fmt.Errorf("failed to reopen game %s: %w", record.ID, err)
+		}
+
+		table := TableState(record)
+		seated := make(map[uuid.UUID]bool, len(table.Seats))
+		for _, seat := range table.Seats {
+			seated[uuid.MustParse(seat.PlayerID)] = true
+		}
+		s.seatMu.Lock()
+		s.seated[record.ID] = seated
+		s.seatMu.Unlock()
+
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+// save writes one table state: the game's own columns, then its seats
+func (s *Store) save(table game.TableState) {
+	log := logrus.WithFields(logrus.Fields{
+		"game_id":     table.RecordID,
+		"hand_number": table.HandNumber,
+	})
+
+	gameID, err := uuid.Parse(table.RecordID)
+	if err != nil {
+		log.Warn("Not storing state of a table without a UUID")
+		return
+	}
+
+	if err := s.games.UpdateGameState(gameID, gameState(table)); err != nil {
+		log.WithError(err).Error("Failed to store table state")
+		return
+	}
+	s.saveSeats(gameID, table, log)
+
+	if table.Status == game.TableFinished || table.Status == game.TableAbandoned {
+		if err := s.games.LeaveAll(gameID); err != nil {
+			log.WithError(err).Error("Failed to unseat players of closed table")
+		}
+		if err := s.games.UpdateGameStatus(gameID, gameStatuses[table.Status]); err != nil {
+			log.WithError(err).Error("Failed to store closed table")
+		}
+		s.seatMu.Lock()
+		delete(s.seated, gameID)
+		s.seatMu.Unlock()
+	}
+}
+
+// seat records that a player's seat at a game has been stored, so the
+// first table state without them unseats them. A state taken before they
+// sat may do so too; the next one then seats them again.
+func (s *Store) seat(gameID, userID uuid.UUID) {
+	s.seatMu.Lock()
+	defer s.seatMu.Unlock()
+
+	seated := s.seated[gameID]
+	if seated == nil {
+		seated = make(map[uuid.UUID]bool)
+		s.seated[gameID] = seated
+	}
+	seated[userID] = true
+}
+
+// saveSeats brings a game's participations in line with its seats: players
+// newly seated join it, those seated are updated with their chips, and
+// those no longer seated leave it. A player's first hand may be settled
+// before their seat is stored, in which case their participation is kept.
+func (s *Store) saveSeats(gameID uuid.UUID, table game.TableState, log *logrus.Entry) {
+	s.seatMu.Lock()
+	defer s.seatMu.Unlock()
+
+	seated := s.seated[gameID]
+	if seated == nil {
+		seated = make(map[uuid.UUID]bool)
+		s.seated[gameID] = seated
+	}
+
+	present := make(map[uuid.UUID]bool, len(table.Seats))
+	for _, seat := range table.Seats {
+		userID, err := uuid.Parse(seat.PlayerID)
+		if err != nil {
+			continue
+		}
+		present[userID] = true
+
+		if !seated[userID] {
+			err := s.games.EnsureParticipation(&models.GameParticipation{
+				GameID:       gameID,
+				UserID:       userID,
+				SeatPosition: seat.SeatPosition,
+				BuyInAmount:  seat.BuyIn,
+				CurrentChips: seat.Chips,
+				IsActive:     true,
+			})
+			if err != nil {
+				log.WithError(err).WithField("user_id", userID).Error("Failed to store seated player")
+				continue
+			}
+			seated[userID] = true
+		}
+		err = s.games.UpdateParticipationStats(gameID, userID, map[string]interface{}{"current_chips": seat.Chips})
+		if err != nil {
+			log.WithError(err).WithField("user_id", userID).Error("Failed to store player's chips")
+		}
+	}
+
+	for userID := range seated {
+		if present[userID] {
+			continue
+		}
+		if err := s.games.LeaveGame(gameID, userID); err != nil {
+			log.WithError(err).WithField("user_id", userID).Error("Failed to store player leaving")
+			continue
+		}
+		delete(seated, userID)
+	}
+}
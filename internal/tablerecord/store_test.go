@@ -0,0 +1,380 @@
+package tablerecord
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"context"
+	"github.com/primoPoker/server/internal/database"
+	"github.com/primoPoker/server/internal/game"
+	"github.com/primoPoker/server/internal/handrecord"
+	"github.com/primoPoker/server/internal/metrics"
+	"github.com/primoPoker/server/internal/models"
+	"github.com/primoPoker/server/internal/repository"
+	"github.com/primoPoker/server/internal/testutil"
+)
+
+// server is one run of the game server: a manager storing its tables and
+// settling their hands
+type server struct {
+	manager *game.Manager
+	store   *Store
+	writer  *handrecord.Writer
+}
+
+func startServer(t *testing.T, db *gorm.DB) *server {
+	t.Helper()
+
+	hands := repository.NewHandHistoryRepository(db)
+	games := repository.NewGameRepository(db)
+	aggregator := metrics.NewAggregator(repository.NewPlayerStatRepository(db), hands, 0)
+	srv := &server{
+		manager: game.NewManager(),
+		store:   NewStore(games, 16),
+		writer:  handrecord.NewWriter(database.NewReplicatedDB(db, nil), aggregator, 16),
+	}
+	go srv.store.Run()
+	go srv.writer.Run()
+	srv.manager.SetStore(srv.store)
+	srv.manager.SetHandObserver(srv.writer)
+	return srv
+}
+
+func newGames(t *testing.T) (*gorm.DB, *repository.GameRepository, []string) {
+	t.Helper()
+
+	db := testutil.NewDB(t, &models.User{}, &models.Game{}, &models.GameParticipation{},
+		&models.HandHistory{}, &models.HandSettlement{}, &models.PlayerStatAggregate{})
+	var players []string
+	for _, name := range []string{"alice", "bob"} {
+		user := models.User{ID: uuid.New(), Username: name, Email: name + "@example.com", PasswordHash: "x"}
+		require.NoError(t, db.Create(&user).Error)
+		players = append(players, user.ID.String())
+	}
+	return db, repository.NewGameRepository(db), players
+}
+
+// stored waits for the server's writes and returns the table's row
+func stored(t *testing.T, srv *server, games *repository.GameRepository, recordID string) *models.Game {
+	t.Helper()
+
+	srv.writer.Close()
+	srv.store.Close()
+	record, err := games.GetByID(uuid.MustParse(recordID))
+	require.NoError(t, err)
+	return record
+}
+
+// participation finds a player's participation in a stored game
+func participation(t *testing.T, record *models.Game, playerID string) models.GameParticipation {
+	t.Helper()
+
+	for _, p := range record.Participations {
+		if p.UserID.String() == playerID {
+			return p
+		}
+	}
+	t.Fatalf("no participation for %s", playerID)
+	return models.GameParticipation{}
+}
+
+func TestStoreFollowsTable(t *testing.T) {
+	db, games, players := newGames(t)
+	srv := startServer(t, db)
+
+	table, err := srv.manager.CreateGame(uuid.New().String(), "Stored")
+	require.NoError(t, err)
+	assert.Equal(t, table.ID, table.RecordID(), "tables with a UUID are stored under it")
+
+	record, err := games.GetByID(uuid.MustParse(table.ID))
+	require.NoError(t, err, "the row is written as the table opens")
+	assert.Equal(t, models.GameStatusWaiting, record.Status)
+	assert.Equal(t, int64(50), record.SmallBlind)
+	assert.Equal(t, 30, record.TurnTimeout)
+
+	// Seating the second player deals the first hand, which one folds
+	require.NoError(t, srv.manager.JoinGame(context.Background(), table.ID, players[0], "alice", 10000))
+	require.NoError(t, srv.manager.JoinGame(context.Background(), table.ID, players[1], "bob", 10000))
+	folder := table.GetGameState("").CurrentPlayer
+	require.NoError(t, srv.manager.ProcessAction(context.Background(), table.ID, folder, game.Fold, 0))
+
+	stacks, err := srv.manager.CloseGame(context.Background(), table.ID)
+	require.NoError(t, err)
+
+	record = stored(t, srv, games, table.ID)
+	assert.Equal(t, models.GameStatusFinished, record.Status)
+	assert.NotNil(t, record.StartedAt)
+	assert.NotNil(t, record.FinishedAt)
+	assert.Equal(t, 1, record.CurrentHand)
+	assert.Equal(t, 1, record.TotalHands)
+	assert.Zero(t, record.CurrentPot, "nothing is left in the pot once closed")
+	assert.Equal(t, int64(150), record.TotalPot)
+
+	require.Len(t, record.Participations, 2)
+	for _, playerID := range players {
+		p := participation(t, record, playerID)
+		assert.False(t, p.IsActive, "players leave a closed table")
+		assert.NotNil(t, p.LeftAt)
+		assert.Equal(t, int64(10000), p.BuyInAmount)
+		assert.Equal(t, stacks[playerID], p.CurrentChips)
+		assert.Equal(t, 1, p.HandsPlayed)
+
+		// The button posts the small blind heads-up and folds it
+		if playerID == folder {
+			assert.Equal(t, int64(9950), p.CurrentChips)
+			assert.Equal(t, 1, p.HandsFolded)
+			assert.Zero(t, p.HandsWon)
+			assert.Equal(t, int64(50), p.TotalLosses)
+		} else {
+			assert.Equal(t, int64(10050), p.CurrentChips)
+			assert.Equal(t, 1, p.HandsWon)
+			assert.Equal(t, int64(50), p.TotalWinnings)
+		}
+	}
+}
+
+func TestStoreAbandonsTableClosedBeforeDealing(t *testing.T) {
+	db, games, _ := newGames(t)
+	srv := startServer(t, db)
+
+	table, err := srv.manager.CreateGame("lobby-table", "Empty")
+	require.NoError(t, err)
+	recordID := table.RecordID()
+	assert.NotEqual(t, table.ID, recordID, "other IDs are replaced by a UUID")
+
+	_, err = srv.manager.CloseGame(context.Background(), table.ID)
+	require.NoError(t, err)
+
+	record := stored(t, srv, games, recordID)
+	assert.Equal(t, "Empty", record.Name)
+	assert.Equal(t, models.GameStatusAbandoned, record.Status)
+	assert.NotNil(t, record.FinishedAt)
+	assert.Nil(t, record.StartedAt)
+}
+
+// seats returns each player's seat and stack at a live table
+func seats(table *game.Game) map[string]game.PlayerState {
+	players := make(map[string]game.PlayerState)
+	for _, player := range table.GetGameState("").Players {
+		players[player.ID] = player
+	}
+	return players
+}
+
+// openTable starts a heads-up table and deals its first hand
+func openTable(t *testing.T, srv *server, players []string) *game.Game {
+	t.Helper()
+
+	table, err := srv.manager.CreateGame(uuid.New().String(), "Survivor", game.WithBlinds(100, 200))
+	require.NoError(t, err)
+	require.NoError(t, srv.manager.JoinGame(context.Background(), table.ID, players[0], "alice", 10000))
+	require.NoError(t, srv.manager.JoinGame(context.Background(), table.ID, players[1], "bob", 10000))
+	return table
+}
+
+func TestRestoreTablesBetweenHands(t *testing.T) {
+	db, games, players := newGames(t)
+	first := startServer(t, db)
+
+	table := openTable(t, first, players)
+	require.NoError(t, first.manager.ProcessAction(context.Background(), table.ID, table.GetGameState("").CurrentPlayer, game.Fold, 0))
+	before := seats(table)
+
+	// The server stops before the next hand is dealt
+	stored(t, first, games, table.ID)
+
+	second := startServer(t, db)
+	restored, err := second.manager.RestoreTables()
+	require.NoError(t, err)
+	assert.Equal(t, 1, restored)
+
+	reopened, err := second.manager.GetGame(table.ID)
+	require.NoError(t, err)
+	state := reopened.GetGameState("")
+	assert.Equal(t, game.WaitingForPlayers, state.Phase)
+	assert.Equal(t, 1, state.HandNumber)
+	assert.Equal(t, int64(200), reopened.BigBlind)
+
+	after := seats(reopened)
+	require.Len(t, after, 2)
+	for _, playerID := range players {
+		assert.Equal(t, before[playerID].SeatPosition, after[playerID].SeatPosition)
+		assert.Equal(t, before[playerID].ChipCount, after[playerID].ChipCount)
+		assert.False(t, after[playerID].Connected, "seats are held until players rejoin")
+		assert.Equal(t, []string{table.ID}, second.manager.GetPlayerGames(playerID))
+	}
+
+	// Rejoining takes the held seat back whatever the buy-in, and play
+	// carries on from the next hand number once both are back
+	require.NoError(t, second.manager.JoinGame(context.Background(), table.ID, players[0], "alice", 20000))
+	assert.Equal(t, game.WaitingForPlayers, reopened.GetGameState("").Phase)
+	require.NoError(t, second.manager.JoinGame(context.Background(), table.ID, players[1], "bob", 20000))
+	assert.Equal(t, 2, reopened.GetGameState("").HandNumber)
+
+	record := stored(t, second, games, table.ID)
+	assert.Equal(t, models.GameStatusActive, record.Status)
+	assert.Equal(t, 2, record.CurrentHand)
+	assert.Equal(t, 1, record.TotalHands)
+	assert.Zero(t, record.VoidedHands)
+	require.Len(t, record.Participations, 2, "rejoining players keep their participation")
+	for _, playerID := range players {
+		p := participation(t, record, playerID)
+		assert.True(t, p.IsActive)
+		assert.Equal(t, before[playerID].ChipCount, p.CurrentChips, "stacks are stored as they were before the hand")
+	}
+}
+
+func TestRestoreTablesVoidsInterruptedHand(t *testing.T) {
+	db, games, players := newGames(t)
+	first := startServer(t, db)
+
+	// The server stops with the blinds and a call in the pot
+	table := openTable(t, first, players)
+	require.NoError(t, first.manager.ProcessAction(context.Background(), table.ID, table.GetGameState("").CurrentPlayer, game.Call, 0))
+	record := stored(t, first, games, table.ID)
+	assert.Equal(t, int64(300), record.CurrentPot)
+
+	second := startServer(t, db)
+	_, err := second.manager.RestoreTables()
+	require.NoError(t, err)
+
+	reopened, err := second.manager.GetGame(table.ID)
+	require.NoError(t, err)
+	for _, playerID := range players {
+		assert.Equal(t, int64(10000), seats(reopened)[playerID].ChipCount, "bets are returned")
+	}
+
+	record, err = games.GetByID(uuid.MustParse(table.ID))
+	require.NoError(t, err)
+	assert.Equal(t, models.GameStatusWaiting, record.Status)
+	assert.Equal(t, 1, record.VoidedHands)
+	assert.Zero(t, record.TotalHands)
+	assert.Zero(t, record.CurrentPot)
+
+	// The voided hand's number is not dealt again
+	require.NoError(t, second.manager.JoinGame(context.Background(), table.ID, players[0], "alice", 10000))
+	require.NoError(t, second.manager.JoinGame(context.Background(), table.ID, players[1], "bob", 10000))
+	assert.Equal(t, 2, reopened.GetGameState("").HandNumber)
+	second.writer.Close()
+	second.store.Close()
+}
+
+func TestShutdownFinishesHandsBeingPlayed(t *testing.T) {
+	db, games, players := newGames(t)
+	first := startServer(t, db)
+	table := openTable(t, first, players)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- first.manager.Shutdown(ctx)
+	}()
+
+	// Nothing new starts, but the hand is waited for while it is played
+	require.Eventually(t, func() bool {
+		return errors.Is(first.manager.ForceStartGame(table.ID), game.ErrShuttingDown)
+	}, time.Second, 5*time.Millisecond)
+	_, err := first.manager.CreateGame(uuid.New().String(), "Late")
+	assert.ErrorIs(t, err, game.ErrShuttingDown)
+	assert.ErrorIs(t, first.manager.JoinGame(context.Background(), table.ID, uuid.New().String(), "carol", 10000), game.ErrShuttingDown)
+	select {
+	case err := <-stopped:
+		t.Fatalf("shutdown returned with a hand being played: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.NoError(t, first.manager.ProcessAction(context.Background(), table.ID, table.GetGameState("").CurrentPlayer, game.Fold, 0))
+	require.NoError(t, <-stopped)
+	state := table.GetGameState("")
+	assert.Equal(t, 1, state.HandNumber, "no other hand is dealt")
+	before := seats(table)
+
+	record := stored(t, first, games, table.ID)
+	assert.Equal(t, models.GameStatusActive, record.Status, "the table is left open for the next run")
+	assert.Equal(t, 1, record.TotalHands)
+	assert.Zero(t, record.CurrentPot)
+
+	second := startServer(t, db)
+	restored, err := second.manager.RestoreTables()
+	require.NoError(t, err)
+	assert.Equal(t, 1, restored)
+	reopened, err := second.manager.GetGame(table.ID)
+	require.NoError(t, err)
+	for _, playerID := range players {
+		assert.Equal(t, before[playerID].ChipCount, seats(reopened)[playerID].ChipCount)
+	}
+	second.writer.Close()
+	second.store.Close()
+}
+
+func TestShutdownVoidsHandsPastDeadline(t *testing.T) {
+	db, games, players := newGames(t)
+	first := startServer(t, db)
+	table := openTable(t, first, players)
+	require.NoError(t, first.manager.ProcessAction(context.Background(), table.ID, table.GetGameState("").CurrentPlayer, game.Call, 0))
+
+	// The deadline has passed before anyone acts again
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := first.manager.Shutdown(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Contains(t, err.Error(), table.ID)
+
+	state := table.GetGameState("")
+	assert.Equal(t, game.WaitingForPlayers, state.Phase)
+	assert.Zero(t, state.Pot)
+	for _, playerID := range players {
+		assert.Equal(t, int64(10000), seats(table)[playerID].ChipCount, "bets are returned")
+	}
+
+	record := stored(t, first, games, table.ID)
+	assert.Equal(t, int64(400), record.CurrentPot, "the hand is stored as cut short")
+
+	second := startServer(t, db)
+	_, err = second.manager.RestoreTables()
+	require.NoError(t, err)
+	reopened, err := second.manager.GetGame(table.ID)
+	require.NoError(t, err)
+	for _, playerID := range players {
+		assert.Equal(t, int64(10000), seats(reopened)[playerID].ChipCount)
+	}
+	record, err = games.GetByID(uuid.MustParse(table.ID))
+	require.NoError(t, err)
+	assert.Equal(t, 1, record.VoidedHands)
+	second.writer.Close()
+	second.store.Close()
+}
+
+func TestStoreFollowsMovedPlayer(t *testing.T) {
+	db, games, players := newGames(t)
+	srv := startServer(t, db)
+	from := openTable(t, srv, players)
+	require.NoError(t, srv.manager.ProcessAction(context.Background(), from.ID, from.GetGameState("").CurrentPlayer, game.Fold, 0))
+	to, err := srv.manager.CreateGame(uuid.New().String(), "Destination", game.WithBlinds(100, 200))
+	require.NoError(t, err)
+
+	stack := seats(from)[players[0]].ChipCount
+	_, err = srv.manager.MovePlayer(from.ID, to.ID, players[0])
+	require.NoError(t, err)
+
+	record := stored(t, srv, games, from.ID)
+	p := participation(t, record, players[0])
+	assert.False(t, p.IsActive, "the player leaves the table they were moved from")
+	assert.NotNil(t, p.LeftAt)
+	assert.True(t, participation(t, record, players[1]).IsActive)
+
+	record, err = games.GetByID(uuid.MustParse(to.ID))
+	require.NoError(t, err)
+	require.Len(t, record.Participations, 1)
+	p = participation(t, record, players[0])
+	assert.True(t, p.IsActive)
+	assert.Equal(t, stack, p.CurrentChips)
+	assert.Equal(t, stack, p.BuyInAmount, "the stack moved is what they brought to the table")
+}
@@ -0,0 +1,88 @@
+package tasks
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// localAttempts is how many times a local task is run before it is given
+// up on, localRetryDelay apart
+const (
+	localAttempts   = 5
+	localRetryDelay = 30 * time.Second
+)
+
+// Local runs tasks from timers in this process. Its tasks are lost when the
+// process stops, so it is for development and single instances; callers
+// schedule again at startup what they still need.
+type Local struct {
+	run Runner
+
+	mu     sync.Mutex
+	timers map[string]*time.Timer
+	closed bool
+}
+
+// NewLocal creates a scheduler running tasks with run
+func NewLocal(run Runner) *Local {
+	return &Local{
+		run:    run,
+		timers: make(map[string]*time.Timer),
+	}
+}
+
+// Schedule runs a task at its time, unless one with its ID is already
+// waiting to run
+func (l *Local) Schedule(ctx context.Context, task Task) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return nil
+	}
+	if _, scheduled := l.timers[task.ID]; scheduled {
+		return nil
+	}
+	l.timers[task.ID] = time.AfterFunc(time.Until(task.RunAt), func() { l.fire(task, 1) })
+	return nil
+}
+
+// fire runs a task, trying it again later if it fails
+func (l *Local) fire(task Task, attempt int) {
+	err := l.run(context.Background(), task)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return
+	}
+	if err == nil || attempt >= localAttempts {
+		if err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{
+				"task_id":  task.ID,
+				"attempts": attempt,
+			}).Error("Giving up on task")
+		}
+		delete(l.timers, task.ID)
+		return
+	}
+
+	logrus.WithError(err).WithField("task_id", task.ID).Warn("Task failed, trying again")
+	l.timers[task.ID] = time.AfterFunc(localRetryDelay, func() { l.fire(task, attempt+1) })
+}
+
+// Close stops the tasks still waiting to run
+func (l *Local) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.closed = true
+	for id, timer := range l.timers {
+		timer.Stop()
+		delete(l.timers, id)
+	}
+}
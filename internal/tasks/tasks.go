@@ -0,0 +1,96 @@
+// Package tasks schedules operations to run later, such as starting a
+// tournament, closing a table once its players have been warned, or
+// freeing the seat of a player who never came back after a restart. On
+// GCP they are scheduled on Cloud Tasks, which keeps them through restarts
+// and delivers each as a signed request to the server's task endpoint; a
+// single instance in development runs them from timers of its own.
+package tasks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Type is the operation a task runs
+type Type string
+
+const (
+	// StartTournament starts a tournament, or cancels it when too few
+	// players registered
+	StartTournament Type = "start_tournament"
+	// CloseTable warns a table's players that it is closing, or closes it
+	CloseTable Type = "close_table"
+	// KickIdlePlayer frees the seat of a player who has not come back to it
+	KickIdlePlayer Type = "kick_idle_player"
+)
+
+// Path is where the server takes delivery of tasks, followed by the type
+const Path = "/internal/tasks/"
+
+// SignatureHeader carries the signature of a delivered task's body
+const SignatureHeader = "X-Task-Signature"
+
+// Task is an operation to run at a time
+type Task struct {
+	// ID names the task. Scheduling a task under the ID of one already
+	// scheduled does nothing, and a task is run once however many times
+	// it is delivered.
+	ID      string          `json:"id"`
+	Type    Type            `json:"type"`
+	RunAt   time.Time       `json:"run_at"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// invalidIDChars are the characters Cloud Tasks does not allow in names
+var invalidIDChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// New creates a task of a type, named by key among the tasks of that type,
+// to run payload at runAt
+func New(taskType Type, key string, runAt time.Time, payload interface{}) (Task, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Task{}, fmt.Errorf("failed to encode %s task: %w", taskType, err)
+	}
+	return Task{
+		ID:      string(taskType) + "-" + invalidIDChars.ReplaceAllString(key, "-"),
+		Type:    taskType,
+		RunAt:   runAt,
+		Payload: data,
+	}, nil
+}
+
+// Scheduler schedules tasks to run
+type Scheduler interface {
+	Schedule(ctx context.Context, task Task) error
+}
+
+// Runner runs a task that has come due. An error has it tried again.
+type Runner func(ctx context.Context, task Task) error
+
+// Sign returns the signature of a task's body under key
+func Sign(key, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is that of body under key. Nothing
+// verifies without a key.
+func Verify(key, body []byte, signature string) bool {
+	if len(key) == 0 {
+		return false
+	}
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
@@ -0,0 +1,72 @@
+package tasks
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	body := []byte(`{"id":"close_table-t1","type":"close_table"}`)
+	signature := Sign(key, body)
+
+	assert.True(t, Verify(key, body, signature))
+	assert.False(t, Verify(key, []byte(`{"id":"close_table-t2","type":"close_table"}`), signature))
+	assert.False(t, Verify([]byte("another key"), body, signature))
+	assert.False(t, Verify(key, body, ""))
+	assert.False(t, Verify(nil, body, Sign(nil, body)), "nothing verifies without a key")
+}
+
+func TestNewNamesTasksAsCloudTasksAllows(t *testing.T) {
+	task, err := New(CloseTable, "table:1/late night", time.Now(), map[string]string{"game_id": "table:1"})
+	require.NoError(t, err)
+	assert.Equal(t, "close_table-table-1-late-night", task.ID)
+	assert.JSONEq(t, `{"game_id": "table:1"}`, string(task.Payload))
+}
+
+func TestLocalRunsEachTaskOnce(t *testing.T) {
+	var runs atomic.Int32
+	ran := make(chan Task, 4)
+	local := NewLocal(func(ctx context.Context, task Task) error {
+		runs.Add(1)
+		ran <- task
+		return nil
+	})
+	defer local.Close()
+
+	task, err := New(KickIdlePlayer, "table-1-alice", time.Now().Add(20*time.Millisecond), nil)
+	require.NoError(t, err)
+	require.NoError(t, local.Schedule(context.Background(), task))
+	require.NoError(t, local.Schedule(context.Background(), task), "scheduling it again does nothing")
+
+	select {
+	case got := <-ran:
+		assert.Equal(t, task.ID, got.ID)
+	case <-time.After(time.Second):
+		t.Fatal("task did not run")
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(1), runs.Load())
+}
+
+func TestLocalCloseStopsWaitingTasks(t *testing.T) {
+	var runs atomic.Int32
+	local := NewLocal(func(ctx context.Context, task Task) error {
+		runs.Add(1)
+		return nil
+	})
+
+	task, err := New(StartTournament, "sunday", time.Now().Add(20*time.Millisecond), nil)
+	require.NoError(t, err)
+	require.NoError(t, local.Schedule(context.Background(), task))
+	local.Close()
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Zero(t, runs.Load())
+	assert.Empty(t, local.timers)
+}
@@ -0,0 +1,80 @@
+// Package testutil provides helpers shared by tests that need a database.
+package testutil
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+// NewDB opens a private in-memory SQLite database and migrates the given
+// models into it.
+//
+// The models are written for Postgres and use function defaults such as
+// gen_random_uuid() that SQLite cannot parse, so those defaults are dropped
+// from the cached schemas before migrating. Every model sets its own ID in
+// BeforeCreate, so nothing relies on them.
+func NewDB(t testing.TB, models ...interface{}) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+
+	// Each connection to :memory: gets its own database
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("get sqlite handle: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	Portable(t, db, models...)
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	return db
+}
+
+// Portable drops the function defaults SQLite cannot parse from the models'
+// cached schemas in db, for tests that create tables other than through
+// NewDB, e.g. by running migrations. Models a model belongs to are made
+// portable too, as AutoMigrate creates their tables with it.
+func Portable(t testing.TB, db *gorm.DB, models ...interface{}) {
+	t.Helper()
+
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			t.Fatalf("parse %T: %v", model, err)
+		}
+		portable(stmt.Schema, make(map[*schema.Schema]bool))
+	}
+}
+
+// portable drops the function defaults from s and the schemas it belongs to
+func portable(s *schema.Schema, seen map[*schema.Schema]bool) {
+	if seen[s] {
+		return
+	}
+	seen[s] = true
+
+	for _, field := range s.Fields {
+		if strings.Contains(field.DefaultValue, "(") {
+			field.HasDefaultValue = false
+			field.DefaultValue = ""
+			field.DefaultValueInterface = nil
+		}
+	}
+	for _, rel := range s.Relationships.BelongsTo {
+		portable(rel.FieldSchema, seen)
+	}
+}
@@ -0,0 +1,62 @@
+package testutil
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// PostgresDSNEnv names the variable holding the Postgres server tests run
+// against. Tests needing Postgres are skipped when it is unset.
+const PostgresDSNEnv = "TEST_POSTGRES_DSN"
+
+// NewPostgresDB migrates the given models into a schema of its own on the
+// server at TEST_POSTGRES_DSN, dropped when the test ends, or skips the
+// test if the variable is unset. Use it beside NewDB for queries whose SQL
+// the two drivers might read differently.
+func NewPostgresDB(t testing.TB, models ...interface{}) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv(PostgresDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s not set", PostgresDSNEnv)
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open postgres: %v", err)
+	}
+
+	// The search path is set per connection, so keep to one
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("get postgres handle: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+
+	schema := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	if err := db.Exec(fmt.Sprintf("CREATE SCHEMA %s", schema)).Error; err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Exec(fmt.Sprintf("DROP SCHEMA %s CASCADE", schema))
+		sqlDB.Close()
+	})
+	if err := db.Exec(fmt.Sprintf("SET search_path TO %s", schema)).Error; err != nil {
+		t.Fatalf("set search path: %v", err)
+	}
+
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	return db
+}
@@ -0,0 +1,60 @@
+// Package tracing sets up OpenTelemetry tracing, so a request can be
+// followed from its handler through the game manager, the database and
+// the WebSocket notifications it causes as one trace.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	texporter "github.com/GoogleCloudPlatform/opentelemetry-operations-go/exporter/trace"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+
+	"github.com/primoPoker/server/internal/config"
+)
+
+// ServiceName names the server in the traces it exports
+const ServiceName = "primopoker-server"
+
+// Setup installs the global tracer provider, which every package's tracer
+// starts its spans through. It exports to the configured exporter, samples
+// cfg.SampleRate of the traces no caller started, and trace context is
+// propagated in W3C headers. It returns a function that flushes and stops
+// the provider. With no exporter configured nothing is installed and spans
+// are not recorded.
+func Setup(ctx context.Context, cfg config.TracingConfig, projectID string) (func(context.Context) error, error) {
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+	switch cfg.Exporter {
+	case "":
+		return func(context.Context) error { return nil }, nil
+	case "cloudtrace":
+		exporter, err = texporter.New(texporter.WithProjectID(projectID))
+	case "otlp":
+		exporter, err = otlptracehttp.New(ctx)
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s trace exporter: %w", cfg.Exporter, err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRate))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
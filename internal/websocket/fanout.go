@@ -0,0 +1,107 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/primoPoker/server/internal/cache"
+)
+
+const (
+	// Game broadcasts that may wait to be relayed to the other instances.
+	fanoutQueueSize = 1024
+
+	// Time allowed to relay one broadcast.
+	fanoutTimeout = time.Second
+
+	// Time waited before subscribing again after the relay failed.
+	fanoutRetry = time.Second
+)
+
+// relayedMessage is a game broadcast relayed between instances, marked
+// with the instance it was broadcast on
+type relayedMessage struct {
+	Origin  string  `json:"origin"`
+	Message Message `json:"message"`
+}
+
+// SetFanout relays the hub's game broadcasts to the other instances
+// through fanout, and delivers theirs to the clients here, so players at
+// a table see its updates whichever instance they are connected to.
+// instance names this instance: its own broadcasts, which its clients
+// already have, are skipped when they come back. Call it before Run; the
+// hub starts with broadcasts reaching its own clients only.
+func (h *Hub) SetFanout(fanout cache.Fanout, instance string) {
+	h.fanout = fanout
+	h.instance = instance
+	h.fanoutQueue = make(chan GameMessage, fanoutQueueSize)
+}
+
+// queueRelay queues a game broadcast to be relayed. It never blocks: when
+// the queue is full the broadcast only reaches the clients here.
+func (h *Hub) queueRelay(gameID string, message Message) {
+	if h.fanout == nil {
+		return
+	}
+
+	select {
+	case h.fanoutQueue <- GameMessage{GameID: gameID, Message: message}:
+	default:
+		if h.relaysDropped.Add(1)%100 == 1 {
+			logrus.WithFields(logrus.Fields{
+				"game_id": gameID,
+				"dropped": h.relaysDropped.Load(),
+			}).Error("Broadcast relay queue is full, broadcasts only reach this instance")
+		}
+	}
+}
+
+// relay publishes queued broadcasts one at a time, so each game's reach
+// the other instances in the order they were broadcast
+func (h *Hub) relay() {
+	for gameMsg := range h.fanoutQueue {
+		data, err := json.Marshal(relayedMessage{Origin: h.instance, Message: gameMsg.Message})
+		if err != nil {
+			logrus.WithError(err).WithField("game_id", gameMsg.GameID).Error("Failed to encode game broadcast")
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), fanoutTimeout)
+		err = h.fanout.Publish(ctx, gameMsg.GameID, data)
+		cancel()
+		if err != nil {
+			logrus.WithError(err).WithField("game_id", gameMsg.GameID).Warn("Failed to relay game broadcast")
+		}
+	}
+}
+
+// receive delivers the other instances' broadcasts to the clients here,
+// subscribing again whenever the subscription ends
+func (h *Hub) receive() {
+	for {
+		err := h.fanout.Subscribe(context.Background(), h.deliverRelayed)
+		logrus.WithError(err).Warn("Broadcast relay subscription ended, subscribing again")
+		time.Sleep(fanoutRetry)
+	}
+}
+
+// deliverRelayed delivers a relayed broadcast to the game's clients here,
+// as it was sent, unless it was broadcast here
+func (h *Hub) deliverRelayed(gameID string, data []byte) {
+	var relayed relayedMessage
+	if err := json.Unmarshal(data, &relayed); err != nil {
+		logrus.WithError(err).WithField("game_id", gameID).Warn("Failed to decode relayed game broadcast")
+		return
+	}
+	if relayed.Origin == h.instance {
+		return
+	}
+
+	h.gameMessage <- GameMessage{
+		GameID:  gameID,
+		Message: relayed.Message,
+	}
+}
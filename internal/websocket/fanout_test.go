@@ -0,0 +1,90 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub/v2/apiv1/pubsubpb"
+	"cloud.google.com/go/pubsub/v2/pstest"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/primoPoker/server/internal/cache"
+	"github.com/primoPoker/server/internal/gcp"
+)
+
+// assertBroadcastsCrossInstances broadcasts on each hub in turn and checks
+// a player at the table on the other hub gets it, exactly once
+func assertBroadcastsCrossInstances(t *testing.T, east, west *Hub) {
+	alice := &Client{ID: "1", UserID: "alice", GameID: "table-1", send: make(chan Message, 8), hub: east}
+	bob := &Client{ID: "2", UserID: "bob", GameID: "table-1", send: make(chan Message, 8), hub: west}
+	east.registerClient(alice)
+	west.registerClient(bob)
+
+	receive := func(client *Client) Message {
+		select {
+		case message := <-client.send:
+			return message
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s got no broadcast", client.UserID)
+			return Message{}
+		}
+	}
+
+	sent := Message{ID: "m1", Type: MessageTypeGameState, GameID: "table-1", Data: json.RawMessage(`{"pot":150}`), Timestamp: time.Now().UTC()}
+	east.BroadcastToGame(context.Background(), "table-1", sent)
+	assert.Equal(t, sent, receive(alice))
+	assert.Equal(t, sent, receive(bob), "a broadcast reaches the table's players on other instances as it was sent")
+
+	west.BroadcastToGame(context.Background(), "table-1", Message{ID: "m2", Type: MessageTypePlayerLeft, GameID: "table-1"})
+	assert.Equal(t, "m2", receive(bob).ID)
+	assert.Equal(t, "m2", receive(alice).ID)
+
+	assert.Never(t, func() bool { return len(alice.send)+len(bob.send) > 0 }, 200*time.Millisecond, 10*time.Millisecond,
+		"a broadcast coming back to the instance it was made on is not delivered again")
+}
+
+func TestFanoutThroughRedis(t *testing.T) {
+	server := miniredis.RunT(t)
+	east, west := NewHub(), NewHub()
+	for instance, hub := range map[string]*Hub{"east": east, "west": west} {
+		client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+		t.Cleanup(func() { client.Close() })
+		hub.SetFanout(cache.NewRedisFanout(client), instance)
+		go hub.Run()
+	}
+	require.Eventually(t, func() bool { return server.PubSubNumPat() == 2 }, 5*time.Second, 10*time.Millisecond)
+
+	assertBroadcastsCrossInstances(t, east, west)
+}
+
+func TestFanoutThroughPubSub(t *testing.T) {
+	ctx := context.Background()
+	server := pstest.NewServer()
+	t.Cleanup(func() { server.Close() })
+	_, err := server.GServer.CreateTopic(ctx, &pubsubpb.Topic{Name: "projects/primopoker/topics/broadcasts"})
+	require.NoError(t, err)
+
+	east, west := NewHub(), NewHub()
+	for instance, hub := range map[string]*Hub{"east": east, "west": west} {
+		conn, err := grpc.NewClient(server.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		require.NoError(t, err)
+		fanout, err := gcp.NewBroadcastFanout(ctx, "primopoker", "broadcasts", instance, option.WithGRPCConn(conn))
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			fanout.Close()
+			conn.Close()
+		})
+		hub.SetFanout(fanout, instance)
+		go hub.Run()
+	}
+
+	assertBroadcastsCrossInstances(t, east, west)
+}
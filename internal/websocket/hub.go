@@ -1,28 +1,49 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/sirupsen/logrus"
+
+	"github.com/primoPoker/server/internal/cache"
+	"github.com/primoPoker/server/internal/flags"
+	"github.com/primoPoker/server/internal/logging"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
+	// Maximum message size allowed from peer.
+	maxMessageSize = 512
+
+	// Chat messages kept per game as context for player reports.
+	maxChatHistory = 50
+)
+
+// Timings are the deadlines of a client connection
+type Timings struct {
 	// Time allowed to write a message to the peer.
-	writeWait = 10 * time.Second
+	WriteWait time.Duration
 
 	// Time allowed to read the next pong message from the peer.
-	pongWait = 60 * time.Second
+	PongWait time.Duration
 
-	// Send pings to peer with this period. Must be less than pongWait.
-	pingPeriod = (pongWait * 9) / 10
+	// Send pings to peer with this period. Must be less than PongWait.
+	PingPeriod time.Duration
+}
 
-	// Maximum message size allowed from peer.
-	maxMessageSize = 512
-)
+// DefaultTimings are the hub's timings until SetTimings is called
+var DefaultTimings = Timings{
+	WriteWait:  10 * time.Second,
+	PongWait:   60 * time.Second,
+	PingPeriod: 54 * time.Second,
+}
 
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
@@ -47,10 +68,18 @@ const (
 	MessageTypeHeartbeat   MessageType = "heartbeat"
 	MessageTypePlayerJoined MessageType = "player_joined"
 	MessageTypePlayerLeft   MessageType = "player_left"
+	MessageTypeAdminNotice  MessageType = "admin_notice"
+	MessageTypeNewDeviceLogin MessageType = "new_device_login"
+	MessageTypeAchievementUnlocked MessageType = "achievement_unlocked"
+	MessageTypeTableClosed  MessageType = "table_closed"
+	MessageTypeServerShutdown MessageType = "server_shutdown"
 )
 
 // Message represents a WebSocket message
 type Message struct {
+	// ID is chosen by the client for the messages it sends, and echoed in
+	// replies; it is logged as the request ID of what the message triggers
+	ID        string          `json:"id,omitempty"`
 	Type      MessageType     `json:"type"`
 	GameID    string          `json:"game_id,omitempty"`
 	PlayerID  string          `json:"player_id,omitempty"`
@@ -58,6 +87,13 @@ type Message struct {
 	Timestamp time.Time       `json:"timestamp"`
 }
 
+// ChatEntry is a chat message retained for moderation
+type ChatEntry struct {
+	UserID  string          `json:"user_id"`
+	Message json.RawMessage `json:"message"`
+	Time    time.Time       `json:"time"`
+}
+
 // Client represents a WebSocket client connection
 type Client struct {
 	ID     string
@@ -67,6 +103,9 @@ type Client struct {
 	send   chan Message
 	hub    *Hub
 	mu     sync.RWMutex
+
+	// Closes send only once, however many ways the client is dropped
+	closeOnce sync.Once
 }
 
 // Hub maintains the set of active clients and broadcasts messages
@@ -92,6 +131,42 @@ type Hub struct {
 	// Send message to specific user
 	userMessage chan UserMessage
 
+	// Recent chat by game
+	chatHistory map[string][]ChatEntry
+
+	// Messages queued to clients, and those dropped because a client's
+	// send buffer was full
+	messagesSent    atomic.Uint64
+	messagesDropped atomic.Uint64
+
+	// Connection deadlines, read on each use so they can be changed while
+	// clients are connected
+	timings atomic.Pointer[Timings]
+
+	// Users connected here and to the other instances, with the updates
+	// waiting to be recorded and those dropped because too many were
+	// waiting
+	presence        cache.Presence
+	presenceQueue   chan presenceUpdate
+	presenceDropped atomic.Uint64
+
+	// Feature flags checked before sending through features being rolled out
+	features *flags.Flags
+
+	// Relays game broadcasts to and from the other instances, naming this
+	// one, with the broadcasts waiting to be relayed and those dropped
+	// because too many were waiting
+	fanout        cache.Fanout
+	instance      string
+	fanoutQueue   chan GameMessage
+	relaysDropped atomic.Uint64
+
+	// Shutting down: no client is taken once closing is set, and the
+	// clients' writers are waited for to send them their close
+	closing  atomic.Bool
+	shutdown chan chan struct{}
+	pumps    sync.WaitGroup
+
 	mu sync.RWMutex
 }
 
@@ -109,7 +184,7 @@ type UserMessage struct {
 
 // NewHub creates a new WebSocket hub
 func NewHub() *Hub {
-	return &Hub{
+	hub := &Hub{
 		gameClients: make(map[string]map[*Client]bool),
 		userClients: make(map[string]*Client),
 		register:    make(chan *Client),
@@ -117,11 +192,36 @@ func NewHub() *Hub {
 		broadcast:   make(chan Message),
 		gameMessage: make(chan GameMessage),
 		userMessage: make(chan UserMessage),
+		chatHistory: make(map[string][]ChatEntry),
+		shutdown:    make(chan chan struct{}),
 	}
+	hub.SetTimings(DefaultTimings)
+	return hub
+}
+
+// SetTimings changes the connection deadlines. Deadlines already set run
+// out as they were, and clients connected before keep their ping period.
+func (h *Hub) SetTimings(timings Timings) {
+	h.timings.Store(&timings)
+}
+
+// SetFlags sets the feature flags the hub checks; call it before Run
+func (h *Hub) SetFlags(features *flags.Flags) {
+	h.features = features
+}
+
+// Timings returns the connection deadlines in effect
+func (h *Hub) Timings() Timings {
+	return *h.timings.Load()
 }
 
 // Run starts the hub
 func (h *Hub) Run() {
+	if h.fanout != nil {
+		go h.relay()
+		go h.receive()
+	}
+
 	for {
 		select {
 		case client := <-h.register:
@@ -138,6 +238,10 @@ func (h *Hub) Run() {
 
 		case userMsg := <-h.userMessage:
 			h.sendToUser(userMsg.UserID, userMsg.Message)
+
+		case done := <-h.shutdown:
+			h.disconnectAll()
+			close(done)
 		}
 	}
 }
@@ -147,6 +251,12 @@ func (h *Hub) registerClient(client *Client) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
+	// A client connecting as the hub shuts down is closed at once
+	if h.closing.Load() {
+		client.closeSend()
+		return
+	}
+
 	// Register client for game
 	if client.GameID != "" {
 		if h.gameClients[client.GameID] == nil {
@@ -157,9 +267,10 @@ func (h *Hub) registerClient(client *Client) {
 
 	// Register client for user (replace existing connection)
 	if oldClient, exists := h.userClients[client.UserID]; exists {
-		close(oldClient.send)
+		oldClient.closeSend()
 	}
 	h.userClients[client.UserID] = client
+	h.join(client, presencePings*h.Timings().PingPeriod)
 
 	logrus.WithFields(logrus.Fields{
 		"client_id": client.ID,
@@ -188,9 +299,10 @@ func (h *Hub) unregisterClient(client *Client) {
 	// Unregister from user clients
 	if h.userClients[client.UserID] == client {
 		delete(h.userClients, client.UserID)
+		h.leave(client)
 	}
 
-	close(client.send)
+	client.closeSend()
 
 	logrus.WithFields(logrus.Fields{
 		"client_id": client.ID,
@@ -207,9 +319,12 @@ func (h *Hub) broadcastToAll(message Message) {
 	for _, client := range h.userClients {
 		select {
 		case client.send <- message:
+			h.messagesSent.Add(1)
 		default:
-			close(client.send)
+			h.messagesDropped.Add(1)
+			client.closeSend()
 			delete(h.userClients, client.UserID)
+			h.leave(client)
 		}
 	}
 }
@@ -227,8 +342,10 @@ func (h *Hub) broadcastToGame(gameID string, message Message) {
 	for client := range clients {
 		select {
 		case client.send <- message:
+			h.messagesSent.Add(1)
 		default:
-			close(client.send)
+			h.messagesDropped.Add(1)
+			client.closeSend()
 			delete(clients, client)
 		}
 	}
@@ -246,18 +363,30 @@ func (h *Hub) sendToUser(userID string, message Message) {
 
 	select {
 	case client.send <- message:
+		h.messagesSent.Add(1)
 	default:
-		close(client.send)
+		h.messagesDropped.Add(1)
+		client.closeSend()
 		delete(h.userClients, userID)
+		h.leave(client)
 	}
 }
 
-// BroadcastToGame sends a message to all clients in a game
-func (h *Hub) BroadcastToGame(gameID string, message Message) {
+// BroadcastToGame sends a message to all clients in a game, on this
+// instance and, with a fanout set, on the others. The wait for the hub to
+// take it is recorded as a span of ctx's trace.
+func (h *Hub) BroadcastToGame(ctx context.Context, gameID string, message Message) {
+	_, span := tracer.Start(ctx, "Hub.BroadcastToGame", trace.WithAttributes(
+		attribute.String("game_id", gameID),
+		attribute.String("type", string(message.Type)),
+	))
+	defer span.End()
+
 	h.gameMessage <- GameMessage{
 		GameID:  gameID,
 		Message: message,
 	}
+	h.queueRelay(gameID, message)
 }
 
 // SendToUser sends a message to a specific user
@@ -270,6 +399,10 @@ func (h *Hub) SendToUser(userID string, message Message) {
 
 // UpgradeConnection upgrades an HTTP connection to WebSocket
 func (h *Hub) UpgradeConnection(w http.ResponseWriter, r *http.Request, userID, gameID string) (*Client, error) {
+	if h.closing.Load() {
+		return nil, ErrHubClosed
+	}
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		return nil, err
@@ -284,6 +417,20 @@ func (h *Hub) UpgradeConnection(w http.ResponseWriter, r *http.Request, userID,
 		hub:    h,
 	}
 
+	// The writer is counted before the hub can start waiting for writers,
+	// or not at all
+	h.mu.RLock()
+	if h.closing.Load() {
+		h.mu.RUnlock()
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseServiceRestart, ErrHubClosed.Error()),
+			time.Now().Add(h.Timings().WriteWait))
+		conn.Close()
+		return nil, ErrHubClosed
+	}
+	h.pumps.Add(1)
+	h.mu.RUnlock()
+
 	// Register client
 	h.register <- client
 
@@ -300,14 +447,15 @@ func (c *Client) readPump() {
 		c.hub.unregister <- c
 		c.conn.Close()
 	}()
+	defer c.recoverPanic()
 
 	c.conn.SetReadLimit(maxMessageSize)
-	if err := c.conn.SetReadDeadline(time.Now().Add(pongWait)); err != nil {
+	if err := c.conn.SetReadDeadline(time.Now().Add(c.hub.Timings().PongWait)); err != nil {
 		logrus.WithError(err).Error("Failed to set read deadline")
 		return
 	}
 	c.conn.SetPongHandler(func(string) error {
-		if err := c.conn.SetReadDeadline(time.Now().Add(pongWait)); err != nil {
+		if err := c.conn.SetReadDeadline(time.Now().Add(c.hub.Timings().PongWait)); err != nil {
 			logrus.WithError(err).Error("Failed to set pong read deadline")
 		}
 		return nil
@@ -333,21 +481,29 @@ func (c *Client) readPump() {
 
 // writePump pumps messages from the hub to the websocket connection
 func (c *Client) writePump() {
+	pingPeriod := c.hub.Timings().PingPeriod
 	ticker := time.NewTicker(pingPeriod)
 	defer func() {
 		ticker.Stop()
 		c.conn.Close()
+		c.hub.pumps.Done()
 	}()
+	defer c.recoverPanic()
 
 	for {
 		select {
 		case message, ok := <-c.send:
-			if err := c.conn.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
+			if err := c.conn.SetWriteDeadline(time.Now().Add(c.hub.Timings().WriteWait)); err != nil {
 				logrus.WithError(err).Error("Failed to set write deadline")
 				return
 			}
 			if !ok {
-				if err := c.conn.WriteMessage(websocket.CloseMessage, []byte{}); err != nil {
+				// Clients closed as the server shuts down are told it is restarting
+				closeMessage := []byte{}
+				if c.hub.closing.Load() {
+					closeMessage = websocket.FormatCloseMessage(websocket.CloseServiceRestart, ErrHubClosed.Error())
+				}
+				if err := c.conn.WriteMessage(websocket.CloseMessage, closeMessage); err != nil {
 					logrus.WithError(err).Error("Failed to write close message")
 				}
 				return
@@ -359,46 +515,76 @@ func (c *Client) writePump() {
 			}
 
 		case <-ticker.C:
-			if err := c.conn.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
+			if err := c.conn.SetWriteDeadline(time.Now().Add(c.hub.Timings().WriteWait)); err != nil {
 				logrus.WithError(err).Error("Failed to set ping write deadline")
 				return
 			}
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
+			c.hub.refresh(c, presencePings*pingPeriod)
 		}
 	}
 }
 
+// recoverPanic logs a panic in one of the client's pumps; the pump's own
+// deferred cleanup still closes the connection
+func (c *Client) recoverPanic() {
+	if recovered := recover(); recovered != nil {
+		logging.Panic(logrus.WithFields(logrus.Fields{
+			"client_id": c.ID,
+			"user_id":   c.UserID,
+			"game_id":   c.GameID,
+		}), recovered)
+	}
+}
+
 // handleMessage handles incoming messages from the client
 func (c *Client) handleMessage(message Message) {
-	logrus.WithFields(logrus.Fields{
+	ctx := logging.NewContext(context.Background(), logging.NewRequestID(message.ID))
+	log := logging.FromContext(ctx).WithFields(logrus.Fields{
 		"client_id": c.ID,
 		"user_id":   c.UserID,
 		"type":      message.Type,
 		"game_id":   message.GameID,
-	}).Debug("Received message")
+	})
+	log.Debug("Received message")
 
 	switch message.Type {
 	case MessageTypeHeartbeat:
 		// Respond with heartbeat
 		response := Message{
+			ID:        message.ID,
 			Type:      MessageTypeHeartbeat,
 			Timestamp: time.Now(),
 		}
 		c.send <- response
 
 	case MessageTypeAction, MessageTypeJoinGame, MessageTypeLeaveGame, MessageTypeChat:
+		if message.Type == MessageTypeChat {
+			gameID := message.GameID
+			if gameID == "" {
+				gameID = c.GameID
+			}
+			c.hub.recordChat(gameID, c.UserID, message.Data)
+		}
+
 		// Forward to appropriate handler (this would be handled by the game manager)
 		// For now, we'll just log it
-		logrus.WithFields(logrus.Fields{
-			"type":    message.Type,
-			"user_id": c.UserID,
-			"game_id": message.GameID,
-		}).Info("Message received for processing")
+		log.Info("Message received for processing")
 
 	default:
-		logrus.WithField("type", message.Type).Warn("Unknown message type")
+		log.Warn("Unknown message type")
+		data, _ := json.Marshal(map[string]string{
+			"error":      "Unknown message type",
+			"request_id": logging.RequestID(ctx),
+		})
+		c.SendMessage(Message{
+			ID:        message.ID,
+			Type:      MessageTypeError,
+			Data:      data,
+			Timestamp: time.Now(),
+		})
 	}
 }
 
@@ -425,36 +611,104 @@ func generateClientID() string {
 	return time.Now().Format("20060102150405") + "-" + string(rune(time.Now().Nanosecond()%1000))
 }
 
-// GetConnectedUsers returns the list of connected users in a game
+// GetConnectedUsers returns the users following a game on this instance
+// or, with a shared presence set, on any other
 func (h *Hub) GetConnectedUsers(gameID string) []string {
 	h.mu.RLock()
-	defer h.mu.RUnlock()
-
-	clients, exists := h.gameClients[gameID]
-	if !exists {
-		return nil
-	}
-
-	userIDs := make([]string, 0, len(clients))
+	var userIDs []string
 	seen := make(map[string]bool)
-
-	for client := range clients {
+	for client := range h.gameClients[gameID] {
 		if !seen[client.UserID] {
 			userIDs = append(userIDs, client.UserID)
 			seen[client.UserID] = true
 		}
 	}
+	h.mu.RUnlock()
+
+	for _, userID := range h.gameUsers(gameID) {
+		if !seen[userID] {
+			userIDs = append(userIDs, userID)
+			seen[userID] = true
+		}
+	}
 
 	return userIDs
 }
 
-// IsUserConnected checks if a user is connected
-func (h *Hub) IsUserConnected(userID string) bool {
+// recordChat keeps a chat message for moderation, dropping the oldest once
+// a game holds maxChatHistory entries
+func (h *Hub) recordChat(gameID, userID string, message json.RawMessage) {
+	if gameID == "" {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	history := append(h.chatHistory[gameID], ChatEntry{
+		UserID:  userID,
+		Message: message,
+		Time:    time.Now(),
+	})
+	if len(history) > maxChatHistory {
+		history = history[len(history)-maxChatHistory:]
+	}
+	h.chatHistory[gameID] = history
+}
+
+// RecentChat returns a copy of the chat retained for a game, oldest first
+func (h *Hub) RecentChat(gameID string) []ChatEntry {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
+	history := h.chatHistory[gameID]
+	entries := make([]ChatEntry, len(history))
+	copy(entries, history)
+	return entries
+}
+
+// HubStats is a snapshot of the hub's connections and message counters
+type HubStats struct {
+	Connections     int    `json:"connections"`
+	Games           int    `json:"games"`
+	MessagesSent    uint64 `json:"messages_sent"`
+	MessagesDropped uint64 `json:"messages_dropped"`
+}
+
+// Stats counts the connected clients, the games they are watching and the
+// messages sent and dropped since the hub started
+func (h *Hub) Stats() HubStats {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return HubStats{
+		Connections:     len(h.userClients),
+		Games:           len(h.gameClients),
+		MessagesSent:    h.messagesSent.Load(),
+		MessagesDropped: h.messagesDropped.Load(),
+	}
+}
+
+// IsUserConnected checks if a user is connected to this instance or,
+// with a shared presence set, to any other
+func (h *Hub) IsUserConnected(userID string) bool {
+	h.mu.RLock()
 	_, exists := h.userClients[userID]
-	return exists
+	h.mu.RUnlock()
+	if exists {
+		return true
+	}
+	if h.presence == nil {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), presenceTimeout)
+	defer cancel()
+	online, err := h.presence.IsOnline(ctx, userID)
+	if err != nil {
+		logrus.WithError(err).WithField("user_id", userID).Warn("Failed to look up user presence")
+	}
+	return online
 }
 
 // NewTimestamp returns a new timestamp
@@ -0,0 +1,118 @@
+package websocket
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/primoPoker/server/internal/cache"
+)
+
+const (
+	// Time allowed for each presence update or lookup.
+	presenceTimeout = 250 * time.Millisecond
+
+	// Ping periods a connection's presence lasts without being refreshed,
+	// so one late refresh does not take a user offline.
+	presencePings = 2
+
+	// Presence updates that may wait to be recorded.
+	presenceQueueSize = 1024
+)
+
+// presenceUpdate is a connection's presence waiting to be recorded: a join
+// lasting ttl, or a leave when ttl is zero
+type presenceUpdate struct {
+	client *Client
+	ttl    time.Duration
+}
+
+// SetPresence records the hub's connections in presence, which may be
+// shared with other instances so IsUserConnected and GetConnectedUsers see
+// users connected to any of them. Connections are recorded one at a time
+// on a goroutine of their own, started here, so a slow presence store
+// holds up neither the hub nor its clients. Call it before Run; without it
+// the hub knows only its own connections.
+func (h *Hub) SetPresence(presence cache.Presence) {
+	h.presence = presence
+	h.presenceQueue = make(chan presenceUpdate, presenceQueueSize)
+	go h.recordPresence()
+}
+
+// join queues a client's connection to this instance to be recorded,
+// lasting ttl
+func (h *Hub) join(client *Client, ttl time.Duration) {
+	h.queuePresence(presenceUpdate{client: client, ttl: ttl})
+}
+
+// leave queues a client's connection to this instance to be recorded as
+// closed
+func (h *Hub) leave(client *Client) {
+	h.queuePresence(presenceUpdate{client: client})
+}
+
+// queuePresence queues a presence update. It never blocks, as it is called
+// with the hub locked: when the queue is full the update is dropped, and a
+// join left unrecorded or a leave recorded late lapses with its ttl.
+func (h *Hub) queuePresence(update presenceUpdate) {
+	if h.presence == nil {
+		return
+	}
+
+	select {
+	case h.presenceQueue <- update:
+	default:
+		if h.presenceDropped.Add(1)%100 == 1 {
+			logrus.WithFields(logrus.Fields{
+				"user_id": update.client.UserID,
+				"dropped": h.presenceDropped.Load(),
+			}).Error("Presence queue is full, presence is left to lapse")
+		}
+	}
+}
+
+// recordPresence records queued presence updates in the order they were
+// queued, so a user's leave is never overtaken by the join before it
+func (h *Hub) recordPresence() {
+	for update := range h.presenceQueue {
+		ctx, cancel := context.WithTimeout(context.Background(), presenceTimeout)
+		client := update.client
+		if update.ttl > 0 {
+			if err := h.presence.Join(ctx, client.UserID, client.GameID, update.ttl); err != nil {
+				logrus.WithError(err).WithField("user_id", client.UserID).Warn("Failed to record user presence")
+			}
+		} else if err := h.presence.Leave(ctx, client.UserID, client.GameID); err != nil {
+			logrus.WithError(err).WithField("user_id", client.UserID).Warn("Failed to clear user presence")
+		}
+		cancel()
+	}
+}
+
+// refresh extends a client's presence for another ttl, unless it has been
+// replaced by a newer connection or unregistered. It is called as the
+// client is pinged, so the presence of a connection lapses soon after the
+// instance holding it stops.
+func (h *Hub) refresh(client *Client, ttl time.Duration) {
+	h.mu.RLock()
+	current := h.userClients[client.UserID] == client
+	h.mu.RUnlock()
+	if current {
+		h.join(client, ttl)
+	}
+}
+
+// gameUsers returns the users following a game on any instance, or none
+// without a presence set
+func (h *Hub) gameUsers(gameID string) []string {
+	if h.presence == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), presenceTimeout)
+	defer cancel()
+	users, err := h.presence.GameUsers(ctx, gameID)
+	if err != nil {
+		logrus.WithError(err).WithField("game_id", gameID).Warn("Failed to look up game presence")
+	}
+	return users
+}
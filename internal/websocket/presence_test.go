@@ -0,0 +1,162 @@
+package websocket
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/internal/cache"
+)
+
+// recorded waits for the presence updates queued at each hub to be
+// recorded: updates are recorded in order, so once a marker queued after
+// them is, so are they
+func recorded(t *testing.T, hubs ...*Hub) {
+	t.Helper()
+	for _, hub := range hubs {
+		marker := &Client{UserID: "marker-" + strconv.Itoa(int(markers.Add(1)))}
+		hub.join(marker, time.Hour)
+		require.Eventually(t, func() bool {
+			online, err := hub.presence.IsOnline(context.Background(), marker.UserID)
+			return err == nil && online
+		}, 5*time.Second, time.Millisecond)
+	}
+}
+
+// markers counts the markers recorded has queued
+var markers atomic.Int64
+
+func TestPresenceAcrossInstances(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	defer client.Close()
+
+	east, west := NewHub(), NewHub()
+	east.SetPresence(cache.NewRedisPresence(client, "east"))
+	west.SetPresence(cache.NewRedisPresence(client, "west"))
+
+	alice := &Client{ID: "1", UserID: "alice", GameID: "table-1", send: make(chan Message, 1), hub: east}
+	east.registerClient(alice)
+	recorded(t, east)
+	assert.True(t, east.IsUserConnected("alice"))
+	assert.True(t, west.IsUserConnected("alice"), "a user connected to another instance is connected")
+	assert.False(t, west.IsUserConnected("bob"))
+
+	bob := &Client{ID: "2", UserID: "bob", GameID: "table-1", send: make(chan Message, 1), hub: west}
+	west.registerClient(bob)
+	recorded(t, west)
+	assert.ElementsMatch(t, []string{"alice", "bob"}, east.GetConnectedUsers("table-1"))
+	assert.ElementsMatch(t, []string{"alice", "bob"}, west.GetConnectedUsers("table-1"))
+	assert.Empty(t, west.GetConnectedUsers("table-2"))
+
+	east.unregisterClient(alice)
+	recorded(t, east)
+	assert.False(t, west.IsUserConnected("alice"))
+	assert.Equal(t, []string{"bob"}, east.GetConnectedUsers("table-1"))
+}
+
+func TestPresenceLapsesWithoutRefresh(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	crashed, live, observer := NewHub(), NewHub(), NewHub()
+	crashed.SetPresence(cache.NewRedisPresence(client, "crashed"))
+	live.SetPresence(cache.NewRedisPresence(client, "live"))
+	observer.SetPresence(cache.NewRedisPresence(client, "observer"))
+	ttl := presencePings * DefaultTimings.PingPeriod
+
+	alice := &Client{ID: "1", UserID: "alice", GameID: "table-1", send: make(chan Message, 1), hub: crashed}
+	bob := &Client{ID: "2", UserID: "bob", GameID: "table-1", send: make(chan Message, 1), hub: live}
+	crashed.registerClient(alice)
+	live.registerClient(bob)
+	recorded(t, crashed, live)
+	assert.True(t, live.IsUserConnected("alice"))
+
+	// The crashed instance never refreshes or closes its connections
+	server.FastForward(ttl / 2)
+	live.refresh(bob, ttl)
+	recorded(t, live)
+	server.FastForward(ttl / 2)
+
+	assert.False(t, live.IsUserConnected("alice"), "a connection nobody refreshes lapses")
+	assert.Equal(t, []string{"bob"}, live.GetConnectedUsers("table-1"))
+	assert.True(t, observer.IsUserConnected("bob"))
+
+	// A replaced connection is not refreshed
+	replaced := &Client{ID: "3", UserID: "bob", GameID: "table-2", send: make(chan Message, 1), hub: live}
+	live.registerClient(replaced)
+	live.refresh(bob, time.Hour)
+	recorded(t, live)
+	assert.Empty(t, observer.GetConnectedUsers("table-1"))
+	assert.Equal(t, []string{"bob"}, observer.GetConnectedUsers("table-2"))
+}
+
+func TestPresenceDefaultsToThisInstance(t *testing.T) {
+	hub := NewHub()
+	alice := &Client{ID: "1", UserID: "alice", GameID: "table-1", send: make(chan Message, 1), hub: hub}
+
+	hub.registerClient(alice)
+	assert.True(t, hub.IsUserConnected("alice"))
+	assert.Equal(t, []string{"alice"}, hub.GetConnectedUsers("table-1"))
+	other := NewHub()
+	assert.False(t, other.IsUserConnected("alice"), "hubs do not share presence unless told to")
+	assert.Empty(t, other.GetConnectedUsers("table-1"))
+
+	hub.unregisterClient(alice)
+	assert.False(t, hub.IsUserConnected("alice"))
+	assert.Empty(t, hub.GetConnectedUsers("table-1"))
+}
+
+func TestSlowPresenceHoldsUpNoBroadcast(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	hub, other := NewHub(), NewHub()
+	hub.SetPresence(cache.NewRedisPresence(client, "east"))
+	other.SetPresence(cache.NewRedisPresence(client, "west"))
+	go hub.Run()
+
+	// Redis stops answering while players come and go
+	server.Lock()
+	locked := true
+	defer func() {
+		if locked {
+			server.Unlock()
+		}
+	}()
+
+	clients := make([]*Client, 5)
+	start := time.Now()
+	for i := range clients {
+		clients[i] = &Client{ID: string(rune('a' + i)), UserID: string(rune('a' + i)), GameID: "table-1",
+			send: make(chan Message, 1), hub: hub}
+		hub.register <- clients[i]
+	}
+	hub.unregister <- clients[0]
+	hub.BroadcastToGame(context.Background(), "table-1", Message{Type: MessageTypeGameState})
+	for _, client := range clients[1:] {
+		select {
+		case message := <-client.send:
+			assert.Equal(t, MessageTypeGameState, message.Type)
+		case <-time.After(time.Second):
+			t.Fatal("the broadcast waited on the presence store")
+		}
+	}
+	assert.Less(t, time.Since(start), presenceTimeout, "no update was waited for")
+
+	// Once Redis answers again the updates are recorded in order
+	server.Unlock()
+	locked = false
+	assert.Eventually(t, func() bool {
+		users := other.GetConnectedUsers("table-1")
+		return len(users) == 4 && !other.IsUserConnected("a")
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,92 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrHubClosed is returned for connections made once the hub is shutting down
+var ErrHubClosed = errors.New("websocket hub is shutting down")
+
+// ShutdownRetryAfter is how long clients are told to wait before they
+// reconnect when the server shuts down, for another instance to take over
+// their tables
+const ShutdownRetryAfter = 5 * time.Second
+
+// ShutdownNotice is the data of a server_shutdown message
+type ShutdownNotice struct {
+	// RetryAfter is how many seconds to wait before reconnecting
+	RetryAfter int `json:"retry_after"`
+}
+
+// Shutdown tells every client connected here that the server is going away
+// and when to reconnect, then closes their connections. No connection is
+// taken from then on. It waits until each client has been sent its close,
+// or ctx is done. The hub must be running.
+func (h *Hub) Shutdown(ctx context.Context) error {
+	if !h.closing.CompareAndSwap(false, true) {
+		return nil
+	}
+
+	done := make(chan struct{})
+	select {
+	case h.shutdown <- done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	<-done
+
+	closed := make(chan struct{})
+	go func() {
+		h.pumps.Wait()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// disconnectAll sends every client the shutdown notice and closes it
+func (h *Hub) disconnectAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	data, _ := json.Marshal(ShutdownNotice{RetryAfter: int(ShutdownRetryAfter / time.Second)})
+	notice := Message{
+		Type:      MessageTypeServerShutdown,
+		Data:      data,
+		Timestamp: time.Now(),
+	}
+	for userID, client := range h.userClients {
+		select {
+		case client.send <- notice:
+			h.messagesSent.Add(1)
+		default:
+			h.messagesDropped.Add(1)
+		}
+		client.closeSend()
+		delete(h.userClients, userID)
+		h.leave(client)
+	}
+
+	// Connections replaced by a newer one may still be following a game
+	for gameID, clients := range h.gameClients {
+		for client := range clients {
+			client.closeSend()
+		}
+		delete(h.gameClients, gameID)
+	}
+}
+
+// closeSend closes the client's send channel, so its writer sends the
+// connection's close and stops
+func (c *Client) closeSend() {
+	c.closeOnce.Do(func() {
+		close(c.send)
+	})
+}
@@ -0,0 +1,57 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShutdownDisconnectsClients(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := hub.UpgradeConnection(w, r, r.URL.Query().Get("user_id"), "table-1")
+		if errors.Is(err, ErrHubClosed) {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(server.Close)
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "?user_id="
+
+	conn, _, err := websocket.DefaultDialer.Dial(url+"alice", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Eventually(t, func() bool { return hub.IsUserConnected("alice") }, time.Second, 5*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, hub.Shutdown(ctx))
+	assert.False(t, hub.IsUserConnected("alice"))
+
+	// The client is told when to reconnect, then the connection is closed
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	var message Message
+	require.NoError(t, conn.ReadJSON(&message))
+	assert.Equal(t, MessageTypeServerShutdown, message.Type)
+	var notice ShutdownNotice
+	require.NoError(t, json.Unmarshal(message.Data, &notice))
+	assert.Equal(t, 5, notice.RetryAfter)
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseServiceRestart), "got %v", err)
+
+	// No connection is taken from then on
+	_, resp, err := websocket.DefaultDialer.Dial(url+"bob", nil)
+	require.Error(t, err)
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.NoError(t, hub.Shutdown(ctx), "shutting down again does nothing")
+}
@@ -0,0 +1,6 @@
+package websocket
+
+import "go.opentelemetry.io/otel"
+
+// tracer starts the spans of broadcasts
+var tracer = otel.Tracer("github.com/primoPoker/server/internal/websocket")
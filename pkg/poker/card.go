@@ -1,9 +1,10 @@
 package poker
 
 import (
+	"encoding/json"
 	"fmt"
-	"math/rand"
-	"time"
+	"strings"
+	"sync/atomic"
 )
 
 // Suit represents a card suit
@@ -68,6 +69,137 @@ func (c Card) String() string {
 	return fmt.Sprintf("%s%s", c.Rank, c.Suit.Symbol())
 }
 
+// rankCodes and suitCodes are the characters of compact card notation,
+// indexed by rank and suit
+const (
+	rankCodes = "  23456789TJQKA"
+	suitCodes = "hdcs"
+)
+
+// Code returns the card in compact notation: the rank, with T for ten,
+// then the suit's lower-case initial, as in "As" or "Th"
+func (c Card) Code() string {
+	return string([]byte{rankCodes[c.Rank], suitCodes[c.Suit]})
+}
+
+// ParseCard reads a card in compact notation. Case is ignored, a ten may
+// be written 10, and the suit may be a symbol, so "As", "th", "10H" and
+// the "10♥" String writes all parse.
+func ParseCard(s string) (Card, error) {
+	if s == "" {
+		return Card{}, fmt.Errorf("invalid card %q: want a rank and a suit, such as As or 10h", s)
+	}
+
+	var card Card
+	rank, suit := strings.ToUpper(s), ""
+	for i, symbol := range suitSymbols {
+		if strings.HasSuffix(rank, symbol) {
+			rank, suit = strings.TrimSuffix(rank, symbol), suitCodes[i:i+1]
+		}
+	}
+	if suit == "" {
+		rank, suit = rank[:len(rank)-1], strings.ToLower(rank[len(rank)-1:])
+	}
+
+	i := strings.Index(suitCodes, suit)
+	if suit == "" || i < 0 {
+		return Card{}, fmt.Errorf("invalid card %q: suit must be h, d, c or s", s)
+	}
+	card.Suit = Suit(i)
+
+	if rank == "10" {
+		rank = "T"
+	}
+	i = strings.Index(rankCodes, rank)
+	if len(rank) != 1 || rank == " " || i < 0 {
+		return Card{}, fmt.Errorf("invalid card %q: rank must be 2 to 10, T, J, Q, K or A", s)
+	}
+	card.Rank = Rank(i)
+	return card, nil
+}
+
+// ParseCards reads cards in compact notation separated by spaces or commas,
+// such as "As Kd" or "10h,9h". A card given twice is an error.
+func ParseCards(s string) ([]Card, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' })
+	cards := make([]Card, 0, len(fields))
+	for _, field := range fields {
+		card, err := ParseCard(field)
+		if err != nil {
+			return nil, err
+		}
+		for _, seen := range cards {
+			if seen == card {
+				return nil, fmt.Errorf("card %s is given twice", card.Code())
+			}
+		}
+		cards = append(cards, card)
+	}
+	return cards, nil
+}
+
+// legacyCardJSON is set while cards are written to JSON as numbers
+var legacyCardJSON atomic.Bool
+
+// SetLegacyCardJSON sets whether cards are written to JSON in the old
+// {"rank":14,"suit":3} form, for clients that have not moved to compact
+// notation. Cards in either form are always read.
+func SetLegacyCardJSON(legacy bool) {
+	legacyCardJSON.Store(legacy)
+}
+
+// legacyCard is a card's old JSON form
+type legacyCard struct {
+	Rank Rank `json:"rank"`
+	Suit Suit `json:"suit"`
+}
+
+// MarshalJSON writes the card as a string in compact notation, or in the
+// old form while legacy card JSON is set
+func (c Card) MarshalJSON() ([]byte, error) {
+	if legacyCardJSON.Load() {
+		return json.Marshal(legacyCard(c))
+	}
+	return json.Marshal(c.Code())
+}
+
+// UnmarshalJSON reads a card in compact notation or the old form
+func (c *Card) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '{' {
+		var legacy legacyCard
+		if err := json.Unmarshal(data, &legacy); err != nil {
+			return err
+		}
+		if legacy.Rank < Two || legacy.Rank > Ace || legacy.Suit < Hearts || legacy.Suit > Spades {
+			return fmt.Errorf("invalid card %s", data)
+		}
+		*c = Card(legacy)
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	return c.UnmarshalText([]byte(s))
+}
+
+// MarshalText writes the card in compact notation, so cards can key JSON
+// objects
+func (c Card) MarshalText() ([]byte, error) {
+	return []byte(c.Code()), nil
+}
+
+// UnmarshalText reads a card in compact notation
+func (c *Card) UnmarshalText(text []byte) error {
+	card, err := ParseCard(string(text))
+	if err != nil {
+		return err
+	}
+	*c = card
+	return nil
+}
+
 // Value returns the numerical value of the card for comparison
 func (c Card) Value() int {
 	return int(c.Rank)
@@ -75,33 +207,56 @@ func (c Card) Value() int {
 
 // Deck represents a deck of cards
 type Deck struct {
-	Cards []Card `json:"cards"`
-	rng   *rand.Rand
+	Cards    []Card `json:"cards"`
+	shuffler Shuffler
+	lowest   Rank // Two for a full deck, Six for a short deck
 }
 
-// NewDeck creates a new standard 52-card deck
+// NewDeck creates a new standard 52-card deck shuffled with crypto/rand
 func NewDeck() *Deck {
+	return NewDeckWithShuffler(CryptoShuffler{})
+}
+
+// NewDeckWithShuffler creates a new standard 52-card deck that shuffles
+// with the given shuffler
+func NewDeckWithShuffler(shuffler Shuffler) *Deck {
+	return newDeck(Two, shuffler)
+}
+
+// NewShortDeck creates a new 36-card deck of sixes to aces, for short deck
+// hold'em, shuffled with crypto/rand
+func NewShortDeck() *Deck {
+	return NewShortDeckWithShuffler(CryptoShuffler{})
+}
+
+// NewShortDeckWithShuffler creates a new 36-card short deck that shuffles
+// with the given shuffler
+func NewShortDeckWithShuffler(shuffler Shuffler) *Deck {
+	return newDeck(Six, shuffler)
+}
+
+func newDeck(lowest Rank, shuffler Shuffler) *Deck {
 	deck := &Deck{
-		Cards: make([]Card, 0, 52),
-		rng:   rand.New(rand.NewSource(time.Now().UnixNano())),
+		Cards:    make([]Card, 0, 4*int(Ace-lowest+1)),
+		shuffler: shuffler,
+		lowest:   lowest,
 	}
+	deck.fill()
+	return deck
+}
 
-	// Create all 52 cards
+// fill puts every card from the deck's lowest rank up in the deck
+func (d *Deck) fill() {
 	for suit := Hearts; suit <= Spades; suit++ {
-		for rank := Two; rank <= Ace; rank++ {
-			deck.Cards = append(deck.Cards, NewCard(rank, suit))
+		for rank := d.lowest; rank <= Ace; rank++ {
+			d.Cards = append(d.Cards, NewCard(rank, suit))
 		}
 	}
-
-	return deck
 }
 
-// Shuffle shuffles the deck using Fisher-Yates algorithm
+// Shuffle shuffles the deck with its shuffler
 func (d *Deck) Shuffle() {
-	for i := len(d.Cards) - 1; i > 0; i-- {
-		j := d.rng.Intn(i + 1)
-		d.Cards[i], d.Cards[j] = d.Cards[j], d.Cards[i]
-	}
+	d.shuffler.Shuffle(d.Cards)
 }
 
 // Deal deals the top card from the deck
@@ -138,16 +293,9 @@ func (d *Deck) Remaining() int {
 	return len(d.Cards)
 }
 
-// Reset resets the deck to a full 52-card deck and shuffles it
+// Reset puts every card back in the deck and shuffles it
 func (d *Deck) Reset() {
 	d.Cards = d.Cards[:0]
-	
-	// Recreate all 52 cards
-	for suit := Hearts; suit <= Spades; suit++ {
-		for rank := Two; rank <= Ace; rank++ {
-			d.Cards = append(d.Cards, NewCard(rank, suit))
-		}
-	}
-	
+	d.fill()
 	d.Shuffle()
 }
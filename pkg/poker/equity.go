@@ -0,0 +1,526 @@
+package poker
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultEquityIterations is how many boards a Monte Carlo run deals
+	// when no count is given
+	DefaultEquityIterations = 100000
+	// DefaultExhaustiveLimit is the most boards that are enumerated rather
+	// than sampled when no limit is given. A flop all-in between two
+	// players leaves 990 boards; before the flop there are 1.7 million.
+	DefaultExhaustiveLimit = 50000
+)
+
+// EquityOptions tune how Equity works out its odds. The zero value uses
+// the defaults.
+type EquityOptions struct {
+	// Iterations is how many boards a Monte Carlo run deals
+	Iterations int
+	// ExhaustiveLimit is the most boards left to come that are all dealt
+	// out instead of sampled
+	ExhaustiveLimit int
+	// Seed makes a Monte Carlo run repeatable when non-zero; otherwise it
+	// is seeded from the clock
+	Seed int64
+	// Workers is how many goroutines share a Monte Carlo run, GOMAXPROCS
+	// when zero. Runs with the same seed only repeat with the same number
+	// of workers.
+	Workers int
+}
+
+// PlayerEquity is one player's chances of the pot, as percentages of the
+// boards dealt. Equity is their expected share of the pot: their wins plus
+// their part of each split.
+type PlayerEquity struct {
+	Win    float64 `json:"win"`
+	Tie    float64 `json:"tie"`
+	Lose   float64 `json:"lose"`
+	Equity float64 `json:"equity"`
+}
+
+// EquityResult is every player's chances, in the order their hole cards
+// were given, and how they were found
+type EquityResult struct {
+	Players    []PlayerEquity `json:"players"`
+	Boards     int            `json:"boards"`
+	Exhaustive bool           `json:"exhaustive"`
+}
+
+// equityTally counts a run's outcomes for each player. Boards count by
+// how likely the hole cards they were dealt with are, so the tallies are
+// weights rather than counts.
+type equityTally struct {
+	boards int
+	total  float64
+	wins   []float64
+	ties   []float64
+	shares []float64
+}
+
+func newEquityTally(players int) *equityTally {
+	return &equityTally{wins: make([]float64, players), ties: make([]float64, players), shares: make([]float64, players)}
+}
+
+func (t *equityTally) add(other *equityTally) {
+	t.boards += other.boards
+	t.total += other.total
+	for i := range t.wins {
+		t.wins[i] += other.wins[i]
+		t.ties[i] += other.ties[i]
+		t.shares[i] += other.shares[i]
+	}
+}
+
+// result turns the tally into percentages
+func (t *equityTally) result(exhaustive bool) *EquityResult {
+	result := &EquityResult{Players: make([]PlayerEquity, len(t.wins)), Boards: t.boards, Exhaustive: exhaustive}
+	for i := range result.Players {
+		win := t.wins[i] * 100 / t.total
+		tie := t.ties[i] * 100 / t.total
+		result.Players[i] = PlayerEquity{
+			Win:    win,
+			Tie:    tie,
+			Lose:   100 - win - tie,
+			Equity: t.shares[i] * 100 / t.total,
+		}
+	}
+	return result
+}
+
+// Equity works out each Hold'em player's chances of winning, splitting and
+// losing the pot from their hole cards, the board so far and any dead
+// cards known to be out of the deck. When few enough boards are left to
+// come every one is dealt out; otherwise boards are sampled at random
+// across several goroutines.
+func Equity(holeCards [][]Card, board []Card, dead []Card, opts EquityOptions) (*EquityResult, error) {
+	if len(holeCards) < 2 {
+		return nil, errors.New("equity needs at least two players")
+	}
+	if err := checkBoard(board); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[Card]bool)
+	for i, hole := range holeCards {
+		if len(hole) != 2 {
+			return nil, fmt.Errorf("player %d must have 2 hole cards, not %d", i+1, len(hole))
+		}
+		if err := checkCards(seen, hole); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkCards(seen, board); err != nil {
+		return nil, err
+	}
+	if err := checkCards(seen, dead); err != nil {
+		return nil, err
+	}
+
+	deck := deckWithout(seen)
+	toCome := 5 - len(board)
+	if len(deck) < toCome {
+		return nil, errors.New("not enough cards left to finish the board")
+	}
+
+	newHands := func() *equityHands {
+		hands := newEquityHands(len(holeCards), board)
+		for p, hole := range holeCards {
+			hands.hold(p, [2]Card{hole[0], hole[1]})
+		}
+		return hands
+	}
+	if boardsLeft(len(deck), toCome) <= exhaustiveLimit(opts) {
+		tally := newEquityTally(len(holeCards))
+		newHands().enumerate(tally, deck, 1)
+		return tally.result(true), nil
+	}
+
+	tally := sampleEquity(len(holeCards), opts, func() equityDealer {
+		hands := newHands()
+		remaining := append([]Card(nil), deck...)
+		return func(rng *rand.Rand, tally *equityTally) {
+			hands.draw(rng, remaining, 0)
+			hands.settle(tally, 1)
+		}
+	})
+	return tally.result(false), nil
+}
+
+// HandVsRange works out the equity of known hole cards against a range of
+// hands, each counted by its weight. The first player is the hand and the
+// second the range.
+func HandVsRange(hand []Card, villain *Range, board []Card, dead []Card, opts EquityOptions) (*EquityResult, error) {
+	if len(hand) != 2 {
+		return nil, fmt.Errorf("hand must have 2 hole cards, not %d", len(hand))
+	}
+	seen := make(map[Card]bool)
+	for _, cards := range [][]Card{hand, board, dead} {
+		if err := checkCards(seen, cards); err != nil {
+			return nil, err
+		}
+	}
+	hero := []WeightedCombo{{Cards: [2]Card{hand[0], hand[1]}, Weight: 1}}
+	return rangeEquity(hero, villain.Combos(nil), board, dead, opts)
+}
+
+// RangeVsRange works out the equity of one range of hands against
+// another. Every pair of hands the two could hold together counts by the
+// product of their weights.
+func RangeVsRange(hero, villain *Range, board []Card, dead []Card, opts EquityOptions) (*EquityResult, error) {
+	return rangeEquity(hero.Combos(nil), villain.Combos(nil), board, dead, opts)
+}
+
+// rangeEquity works out the equity between two lists of hole cards. It
+// deals out every board for every pair of hands when that comes to few
+// enough boards, and otherwise samples a pair of hands by weight for each
+// board, drawing again when the two share a card.
+func rangeEquity(hero, villain []WeightedCombo, board, dead []Card, opts EquityOptions) (*EquityResult, error) {
+	if err := checkBoard(board); err != nil {
+		return nil, err
+	}
+	seen := make(map[Card]bool)
+	if err := checkCards(seen, board); err != nil {
+		return nil, err
+	}
+	if err := checkCards(seen, dead); err != nil {
+		return nil, err
+	}
+
+	sides := [2][]WeightedCombo{liveCombos(hero, seen), liveCombos(villain, seen)}
+	for i, combos := range sides {
+		if len(combos) == 0 {
+			return nil, fmt.Errorf("player %d has no hands left once the board and dead cards are out", i+1)
+		}
+	}
+	pairs := 0
+	for _, h := range sides[0] {
+		for _, v := range sides[1] {
+			if !h.overlaps(v) {
+				pairs++
+			}
+		}
+	}
+	if pairs == 0 {
+		return nil, errors.New("the two players cannot hold hands from their ranges at the same time")
+	}
+
+	deck := deckWithout(seen)
+	toCome := 5 - len(board)
+	if len(deck)-4 < toCome {
+		return nil, errors.New("not enough cards left to finish the board")
+	}
+
+	if boardsLeft(len(deck)-4, toCome) <= exhaustiveLimit(opts)/pairs {
+		tally := newEquityTally(2)
+		hands := newEquityHands(2, board)
+		remaining := make([]Card, 0, len(deck))
+		for _, h := range sides[0] {
+			for _, v := range sides[1] {
+				if h.overlaps(v) {
+					continue
+				}
+				remaining = remaining[:0]
+				for _, card := range deck {
+					if card != h.Cards[0] && card != h.Cards[1] && card != v.Cards[0] && card != v.Cards[1] {
+						remaining = append(remaining, card)
+					}
+				}
+				hands.hold(0, h.Cards)
+				hands.hold(1, v.Cards)
+				hands.enumerate(tally, remaining, h.Weight*v.Weight)
+			}
+		}
+		return tally.result(true), nil
+	}
+
+	picks := [2][]float64{cumulativeWeights(sides[0]), cumulativeWeights(sides[1])}
+	tally := sampleEquity(2, opts, func() equityDealer {
+		hands := newEquityHands(2, board)
+		remaining := append([]Card(nil), deck...)
+		return func(rng *rand.Rand, tally *equityTally) {
+			var h, v WeightedCombo
+			for {
+				h, v = pickCombo(rng, sides[0], picks[0]), pickCombo(rng, sides[1], picks[1])
+				if !h.overlaps(v) {
+					break
+				}
+			}
+			hands.hold(0, h.Cards)
+			hands.hold(1, v.Cards)
+			hands.draw(rng, remaining, cardBit(h.Cards[0])|cardBit(h.Cards[1])|cardBit(v.Cards[0])|cardBit(v.Cards[1]))
+			hands.settle(tally, 1)
+		}
+	})
+	return tally.result(false), nil
+}
+
+// overlaps reports whether two pairs of hole cards share a card
+func (c WeightedCombo) overlaps(other WeightedCombo) bool {
+	for _, card := range c.Cards {
+		if card == other.Cards[0] || card == other.Cards[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// liveCombos drops the hole cards that use a card already seen
+func liveCombos(combos []WeightedCombo, seen map[Card]bool) []WeightedCombo {
+	var live []WeightedCombo
+	for _, combo := range combos {
+		if !seen[combo.Cards[0]] && !seen[combo.Cards[1]] {
+			live = append(live, combo)
+		}
+	}
+	return live
+}
+
+// cumulativeWeights sums the combos' weights in order, for picking one
+func cumulativeWeights(combos []WeightedCombo) []float64 {
+	sums := make([]float64, len(combos))
+	total := 0.0
+	for i, combo := range combos {
+		total += combo.Weight
+		sums[i] = total
+	}
+	return sums
+}
+
+// pickCombo picks one of the combos at random by weight
+func pickCombo(rng *rand.Rand, combos []WeightedCombo, sums []float64) WeightedCombo {
+	target := rng.Float64() * sums[len(sums)-1]
+	i := sort.SearchFloat64s(sums, target)
+	if i == len(combos) {
+		i--
+	}
+	return combos[i]
+}
+
+// checkBoard checks the board holds a number of cards it can be dealt to
+func checkBoard(board []Card) error {
+	switch len(board) {
+	case 0, 3, 4, 5:
+		return nil
+	}
+	return fmt.Errorf("board must have 0, 3, 4 or 5 cards, not %d", len(board))
+}
+
+// checkCards checks the cards are real and none has been seen already,
+// then marks them as seen
+func checkCards(seen map[Card]bool, cards []Card) error {
+	for _, card := range cards {
+		if err := checkCard(card); err != nil {
+			return err
+		}
+		if seen[card] {
+			return fmt.Errorf("card %s is given twice", card.Code())
+		}
+		seen[card] = true
+	}
+	return nil
+}
+
+// checkCard returns an error for a card that is not in a deck
+func checkCard(card Card) error {
+	if card.Rank < Two || card.Rank > Ace || card.Suit < Hearts || card.Suit > Spades {
+		return fmt.Errorf("invalid card %v", card)
+	}
+	return nil
+}
+
+// deckWithout lists the cards of a deck that have not been seen
+func deckWithout(seen map[Card]bool) []Card {
+	var deck []Card
+	for suit := Hearts; suit <= Spades; suit++ {
+		for rank := Two; rank <= Ace; rank++ {
+			if card := NewCard(rank, suit); !seen[card] {
+				deck = append(deck, card)
+			}
+		}
+	}
+	return deck
+}
+
+// cardBit is a card's bit in a mask of cards
+func cardBit(card Card) uint64 {
+	return 1 << (uint(card.Suit)*16 + uint(card.Rank))
+}
+
+func exhaustiveLimit(opts EquityOptions) int {
+	if opts.ExhaustiveLimit == 0 {
+		return DefaultExhaustiveLimit
+	}
+	return opts.ExhaustiveLimit
+}
+
+// boardsLeft counts the ways of dealing k more board cards from n, capped
+// so large counts do not overflow
+func boardsLeft(n, k int) int {
+	count := 1
+	for i := 0; i < k; i++ {
+		count = count * (n - i) / (i + 1)
+		if count > 1<<40 {
+			return 1 << 40
+		}
+	}
+	return count
+}
+
+// equityDealer deals and settles one random board
+type equityDealer func(rng *rand.Rand, tally *equityTally)
+
+// sampleEquity deals the configured number of random boards, split
+// between workers that each draw from their own seeded source with a
+// dealer of their own
+func sampleEquity(players int, opts EquityOptions, newDealer func() equityDealer) *equityTally {
+	iterations := opts.Iterations
+	if iterations <= 0 {
+		iterations = DefaultEquityIterations
+	}
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > iterations {
+		workers = iterations
+	}
+	seed := opts.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	tallies := make([]*equityTally, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		boards := iterations / workers
+		if w < iterations%workers {
+			boards++
+		}
+		wg.Add(1)
+		go func(w, boards int) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(seed + int64(w)))
+			tally := newEquityTally(players)
+			deal := newDealer()
+			for b := 0; b < boards; b++ {
+				deal(rng, tally)
+			}
+			tallies[w] = tally
+		}(w, boards)
+	}
+	wg.Wait()
+
+	total := newEquityTally(players)
+	for _, tally := range tallies {
+		total.add(tally)
+	}
+	return total
+}
+
+// equityHands are each player's seven cards, their hole cards and then the
+// board, with the cards still to come dealt into place for each board
+type equityHands struct {
+	cards     [][7]Card
+	strengths []Strength
+	board     int // cards on the board before any are dealt
+}
+
+func newEquityHands(players int, board []Card) *equityHands {
+	hands := &equityHands{cards: make([][7]Card, players), strengths: make([]Strength, players), board: len(board)}
+	for p := range hands.cards {
+		copy(hands.cards[p][2:], board)
+	}
+	return hands
+}
+
+// hold gives a player their hole cards
+func (h *equityHands) hold(p int, hole [2]Card) {
+	h.cards[p][0], h.cards[p][1] = hole[0], hole[1]
+}
+
+// deal puts card at position i of the board in every player's hand
+func (h *equityHands) deal(i int, card Card) {
+	for p := range h.cards {
+		h.cards[p][2+i] = card
+	}
+}
+
+// enumerate deals out every way of finishing the board from deck
+func (h *equityHands) enumerate(tally *equityTally, deck []Card, weight float64) {
+	toCome := 5 - h.board
+	picks := make([]int, toCome)
+	for i := range picks {
+		picks[i] = i
+	}
+	for {
+		for i, pick := range picks {
+			h.deal(h.board+i, deck[pick])
+		}
+		h.settle(tally, weight)
+
+		// Move on to the next combination of deck positions
+		i := toCome - 1
+		for i >= 0 && picks[i] == len(deck)-toCome+i {
+			i--
+		}
+		if i < 0 {
+			return
+		}
+		picks[i]++
+		for j := i + 1; j < toCome; j++ {
+			picks[j] = picks[j-1] + 1
+		}
+	}
+}
+
+// draw finishes the board at random from remaining, partly shuffling it
+// to draw only the cards needed and passing over any card in used
+func (h *equityHands) draw(rng *rand.Rand, remaining []Card, used uint64) {
+	for i := 0; i < 5-h.board; {
+		j := i + rng.Intn(len(remaining)-i)
+		if cardBit(remaining[j])&used != 0 {
+			continue
+		}
+		remaining[i], remaining[j] = remaining[j], remaining[i]
+		h.deal(h.board+i, remaining[i])
+		i++
+	}
+}
+
+// settle finds who wins the board dealt and counts it
+func (h *equityHands) settle(tally *equityTally, weight float64) {
+	var best Strength
+	winners := 0
+	for p := range h.cards {
+		h.strengths[p] = StandardRules.evaluate(h.cards[p][:])
+		switch {
+		case h.strengths[p] > best:
+			best, winners = h.strengths[p], 1
+		case h.strengths[p] == best:
+			winners++
+		}
+	}
+
+	tally.boards++
+	tally.total += weight
+	for p, strength := range h.strengths {
+		if strength != best {
+			continue
+		}
+		if winners == 1 {
+			tally.wins[p] += weight
+		} else {
+			tally.ties[p] += weight
+		}
+		tally.shares[p] += weight / float64(winners)
+	}
+}
@@ -0,0 +1,161 @@
+package poker
+
+import "math/bits"
+
+// Strength orders hands: of two hands the stronger wins, and hands of equal
+// strength split the pot. The hand's place in its ruleset's order of hand
+// ranks sits above up to five card ranks, four bits each, that break ties
+// between hands of that rank, most significant first. The ruleset is kept
+// in the top bits, so strengths from different rulesets never tie; they
+// must not be compared.
+type Strength uint32
+
+// strengthRankShift is where a strength keeps its hand rank, and
+// strengthRulesetShift its ruleset
+const (
+	strengthRankShift    = 20
+	strengthRulesetShift = 28
+)
+
+// Rank returns the rank of the hand
+func (s Strength) Rank() HandRank {
+	return handOrders[s.Ruleset()][s>>strengthRankShift&0xf]
+}
+
+// Ruleset returns the rules the hand was ranked under
+func (s Strength) Ruleset() Ruleset {
+	return Ruleset(s >> strengthRulesetShift)
+}
+
+// Kickers returns the card ranks that break ties between hands of the
+// strength's rank, in the order they are compared
+func (s Strength) Kickers() []Rank {
+	return s.appendKickers(nil)
+}
+
+// appendKickers appends the strength's kickers to kickers
+func (s Strength) appendKickers(kickers []Rank) []Rank {
+	for slot := 0; slot < 5; slot++ {
+		if rank := Rank(s >> (16 - 4*slot) & 0xf); rank != 0 {
+			kickers = append(kickers, rank)
+		}
+	}
+	return kickers
+}
+
+// EvaluateSeven finds the strength of the best five-card hand in seven
+// cards, a player's hole cards and the board, along with its rank. It
+// works on bitmasks of the ranks held in each suit rather than trying
+// every five-card hand, and does not allocate.
+func EvaluateSeven(cards []Card) (Strength, HandRank) {
+	return StandardRules.EvaluateSeven(cards)
+}
+
+// evaluate finds the strength of the best five-card hand in five to seven
+// cards. Seven cards cannot hold both a flush and a full house, so a flush
+// is settled before the ranks are counted whichever of the two ranks
+// higher.
+func (r Ruleset) evaluate(cards []Card) Strength {
+	var suits [4]uint16
+	var counts [Ace + 1]uint8
+	for _, card := range cards {
+		suits[card.Suit] |= 1 << card.Rank
+		counts[card.Rank]++
+	}
+
+	for _, suited := range suits {
+		if bits.OnesCount16(suited) < 5 {
+			continue
+		}
+		switch high := r.straightHigh(suited); high {
+		case 0:
+			return r.rankedAs(Flush).withTop(0, suited, 5)
+		case Ace:
+			return r.rankedAs(RoyalFlush).with(0, Ace)
+		default:
+			return r.rankedAs(StraightFlush).with(0, high)
+		}
+	}
+
+	var quads, trips, pairs uint16
+	for rank := Two; rank <= Ace; rank++ {
+		switch counts[rank] {
+		case 4:
+			quads |= 1 << rank
+		case 3:
+			trips |= 1 << rank
+		case 2:
+			pairs |= 1 << rank
+		}
+	}
+	all := suits[0] | suits[1] | suits[2] | suits[3]
+
+	if quads != 0 {
+		quad := highest(quads)
+		return r.rankedAs(FourOfAKind).with(0, quad).withTop(1, all&^(1<<quad), 1)
+	}
+	if trips != 0 && bits.OnesCount16(trips|pairs) >= 2 {
+		// A second set of trips plays as the pair
+		trip := highest(trips)
+		return r.rankedAs(FullHouse).with(0, trip).with(1, highest((trips|pairs)&^(1<<trip)))
+	}
+	if high := r.straightHigh(all); high != 0 {
+		return r.rankedAs(Straight).with(0, high)
+	}
+	if trips != 0 {
+		trip := highest(trips)
+		return r.rankedAs(ThreeOfAKind).with(0, trip).withTop(1, all&^(1<<trip), 2)
+	}
+	if bits.OnesCount16(pairs) >= 2 {
+		// A third pair can only play as the kicker
+		high := highest(pairs)
+		low := highest(pairs &^ (1 << high))
+		return r.rankedAs(TwoPair).with(0, high).with(1, low).withTop(2, all&^(1<<high|1<<low), 1)
+	}
+	if pairs != 0 {
+		pair := highest(pairs)
+		return r.rankedAs(OnePair).with(0, pair).withTop(1, all&^(1<<pair), 3)
+	}
+	return r.rankedAs(HighCard).withTop(0, all, 5)
+}
+
+// rankedAs starts the strength of a hand of the given rank
+func (r Ruleset) rankedAs(rank HandRank) Strength {
+	return Strength(r)<<strengthRulesetShift | handPositions[r][rank]<<strengthRankShift
+}
+
+// with puts rank in one of the strength's five tie-breaking slots
+func (s Strength) with(slot int, rank Rank) Strength {
+	return s | Strength(rank)<<(16-4*slot)
+}
+
+// withTop puts the n highest ranks in mask in the slots from slot on
+func (s Strength) withTop(slot int, mask uint16, n int) Strength {
+	for ; n > 0 && mask != 0; n-- {
+		rank := highest(mask)
+		s = s.with(slot, rank)
+		mask &^= 1 << rank
+		slot++
+	}
+	return s
+}
+
+// highest returns the highest rank in a rank bitmask
+func highest(mask uint16) Rank {
+	return Rank(bits.Len16(mask) - 1)
+}
+
+// straightHigh returns the top card of the highest straight in a rank
+// bitmask, or 0 when there is none. The ace also plays low, so the wheel
+// is five high, or nine high in short deck.
+func (r Ruleset) straightHigh(mask uint16) Rank {
+	if mask&(1<<Ace) != 0 {
+		mask |= lowAces[r]
+	}
+	// A bit survives only where it and the four ranks below it are held
+	runs := mask & (mask << 1) & (mask << 2) & (mask << 3) & (mask << 4)
+	if runs == 0 {
+		return 0
+	}
+	return highest(runs)
+}
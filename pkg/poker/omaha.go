@@ -0,0 +1,173 @@
+package poker
+
+// omahaHolePairs and omahaBoardTriples list the ways of picking exactly
+// two of the four hole cards and three of the five board cards
+var (
+	omahaHolePairs    = [6][2]int{{0, 1}, {0, 2}, {0, 3}, {1, 2}, {1, 3}, {2, 3}}
+	omahaBoardTriples = [10][3]int{
+		{0, 1, 2}, {0, 1, 3}, {0, 1, 4}, {0, 2, 3}, {0, 2, 4},
+		{0, 3, 4}, {1, 2, 3}, {1, 2, 4}, {1, 3, 4}, {2, 3, 4},
+	}
+)
+
+// LowHand is a hand that qualifies for the low half of an Omaha hi-lo pot:
+// five cards of different ranks, eight or below, with aces low. Straights
+// and flushes do not count against it.
+type LowHand struct {
+	Cards []Card `json:"cards"`
+	// Ranks are the cards' ranks, highest first, with an ace as 1
+	Ranks []int `json:"ranks"`
+	Value int   `json:"value"` // Lower values are better lows
+}
+
+// CompareLowHands compares two lows, returns 1 if l1 wins, -1 if l2 wins,
+// 0 for tie. Any low beats a nil one.
+func CompareLowHands(l1, l2 *LowHand) int {
+	switch {
+	case l1 == nil && l2 == nil:
+		return 0
+	case l2 == nil:
+		return 1
+	case l1 == nil:
+		return -1
+	case l1.Value < l2.Value:
+		return 1
+	case l1.Value > l2.Value:
+		return -1
+	}
+	return 0
+}
+
+// EvaluateOmaha finds the best Omaha hand, which must use exactly two of
+// the four hole cards and exactly three of the five board cards
+func EvaluateOmaha(holeCards [4]Card, board [5]Card) *Hand {
+	var best [5]Card
+	var bestStrength Strength
+	forEachOmahaHand(holeCards, board, func(cards [5]Card) {
+		if strength := StandardRules.evaluate(cards[:]); strength > bestStrength {
+			best, bestStrength = cards, strength
+		}
+	})
+	return NewHand(best[:])
+}
+
+// EvaluateOmahaHiLo finds the best Omaha high hand and the best eight or
+// better low, which is nil when no two hole cards and three board cards
+// make one. The high and the low may use different hole cards.
+func EvaluateOmahaHiLo(holeCards [4]Card, board [5]Card) (*Hand, *LowHand) {
+	return EvaluateOmaha(holeCards, board), EvaluateOmahaLow(holeCards, board)
+}
+
+// EvaluateOmahaLow finds the best eight or better low from exactly two hole
+// cards and three board cards, or nil when there is none
+func EvaluateOmahaLow(holeCards [4]Card, board [5]Card) *LowHand {
+	var low *LowHand
+	forEachOmahaHand(holeCards, board, func(cards [5]Card) {
+		if candidate := newLowHand(cards); CompareLowHands(candidate, low) > 0 {
+			low = candidate
+		}
+	})
+	return low
+}
+
+// HiLoHand is a player's hands at a hi-lo showdown. Low is nil when they
+// have no qualifying low.
+type HiLoHand struct {
+	High *Hand
+	Low  *LowHand
+}
+
+// SplitHiLoPot divides a pot between the best high hands and the best
+// lows, returning each player's share in the order the hands were given.
+// The high takes the whole pot when no one qualifies for low, and
+// otherwise half of it, with the odd chip. Tied hands split their half,
+// so a player who wins high and ties for low is quartered. Chips that do
+// not divide evenly go one each to the tied players given first, so pass
+// the hands in seat order starting left of the button.
+func SplitHiLoPot(pot int, hands []HiLoHand) []int {
+	shares := make([]int, len(hands))
+	if len(hands) == 0 {
+		return shares
+	}
+
+	var highs, lows []int
+	for i, hand := range hands {
+		switch {
+		case len(highs) == 0 || CompareHands(hand.High, hands[highs[0]].High) > 0:
+			highs = []int{i}
+		case CompareHands(hand.High, hands[highs[0]].High) == 0:
+			highs = append(highs, i)
+		}
+		if hand.Low == nil {
+			continue
+		}
+		switch {
+		case len(lows) == 0 || CompareLowHands(hand.Low, hands[lows[0]].Low) > 0:
+			lows = []int{i}
+		case CompareLowHands(hand.Low, hands[lows[0]].Low) == 0:
+			lows = append(lows, i)
+		}
+	}
+
+	highHalf := pot
+	if len(lows) > 0 {
+		highHalf = pot - pot/2
+		splitShare(shares, pot/2, lows)
+	}
+	splitShare(shares, highHalf, highs)
+	return shares
+}
+
+// splitShare divides chips evenly between the winners, the first of them
+// taking any left over one at a time
+func splitShare(shares []int, chips int, winners []int) {
+	for i, winner := range winners {
+		shares[winner] += chips / len(winners)
+		if i < chips%len(winners) {
+			shares[winner]++
+		}
+	}
+}
+
+// forEachOmahaHand calls fn with each of the 60 five-card hands made of two
+// hole cards and three board cards
+func forEachOmahaHand(holeCards [4]Card, board [5]Card, fn func(cards [5]Card)) {
+	for _, pair := range omahaHolePairs {
+		for _, triple := range omahaBoardTriples {
+			fn([5]Card{
+				holeCards[pair[0]], holeCards[pair[1]],
+				board[triple[0]], board[triple[1]], board[triple[2]],
+			})
+		}
+	}
+}
+
+// newLowHand returns the low five cards make, or nil if they do not
+// qualify
+func newLowHand(cards [5]Card) *LowHand {
+	var held [9]bool
+	for _, card := range cards {
+		rank := lowRank(card.Rank)
+		if rank > 8 || held[rank] {
+			return nil
+		}
+		held[rank] = true
+	}
+
+	low := &LowHand{Cards: append([]Card(nil), cards[:]...), Ranks: make([]int, 0, 5)}
+	for rank := 8; rank >= 1; rank-- {
+		if held[rank] {
+			low.Ranks = append(low.Ranks, rank)
+			low.Value = low.Value*10 + rank
+		}
+	}
+	return low
+}
+
+// lowRank is a card's rank when playing for low, where an ace is 1
+func lowRank(rank Rank) int {
+	if rank == Ace {
+		return 1
+	}
+	return int(rank)
+}
@@ -0,0 +1,226 @@
+package poker
+
+import "fmt"
+
+// DrawType names a way a hand can improve on the next card
+type DrawType string
+
+const (
+	FlushDraw DrawType = "flush_draw"
+	// OpenEndedDraw includes double gutshots, which have as many outs
+	OpenEndedDraw DrawType = "open_ended_straight_draw"
+	Gutshot       DrawType = "gutshot"
+	Overcards     DrawType = "overcards"
+)
+
+// Draw is one way a hand can improve and the cards that complete it.
+// Tainted outs complete the draw but also put a flush or a full house on
+// offer to anyone holding the right cards, so they may not win.
+type Draw struct {
+	Type    DrawType `json:"type"`
+	Outs    []Card   `json:"outs"`
+	Count   int      `json:"count"`
+	Tainted []Card   `json:"tainted,omitempty"`
+}
+
+// OutsResult is what a hand holds now and how it can improve. Outs are
+// every card that completes one of the draws, each counted once.
+type OutsResult struct {
+	Hand  HandRank `json:"hand"`
+	Draws []Draw   `json:"draws"`
+	Combo bool     `json:"combo"` // A flush draw and a straight draw at once
+	Outs  []Card   `json:"outs"`
+	Count int      `json:"count"`
+}
+
+// Outs finds the draws in hole cards and a flop or turn, and the cards to
+// come that complete them, leaving out the dead cards. A card only counts
+// when the hole cards play in the hand it makes, so one that pairs the
+// board into the same hand for everyone is no out.
+func Outs(hole, board, dead []Card) (*OutsResult, error) {
+	o, err := newOutsFinder(hole, board, dead)
+	if err != nil {
+		return nil, err
+	}
+	result := &OutsResult{Hand: o.current.Rank()}
+
+	if o.current.Rank() < Flush {
+		result.addDraw(o.draw(FlushDraw, func(_ HandRank, cards []Card) bool {
+			return hasFlush(cards)
+		}))
+	}
+	if o.current.Rank() < Straight {
+		straights := o.draw(Gutshot, func(_ HandRank, cards []Card) bool {
+			return hasStraight(cards)
+		})
+		ranks := make(map[Rank]bool)
+		for _, card := range straights.Outs {
+			ranks[card.Rank] = true
+		}
+		if len(ranks) > 1 {
+			straights.Type = OpenEndedDraw
+		}
+		result.addDraw(straights)
+	}
+	if o.current.Rank() == HighCard {
+		result.addDraw(o.draw(Overcards, func(rank HandRank, cards []Card) bool {
+			card := cards[len(cards)-1]
+			return rank == OnePair && card.Rank > o.boardHigh && (card.Rank == hole[0].Rank || card.Rank == hole[1].Rank)
+		}))
+	}
+
+	var flush, straight bool
+	for _, draw := range result.Draws {
+		flush = flush || draw.Type == FlushDraw
+		straight = straight || draw.Type == OpenEndedDraw || draw.Type == Gutshot
+	}
+	result.Combo = flush && straight
+	return result, nil
+}
+
+// OutsTo finds the cards to come that improve hole cards and a flop or
+// turn to at least the target hand, leaving out the dead cards. As with
+// Outs, the hole cards must play in the improved hand.
+func OutsTo(hole, board, dead []Card, target HandRank) ([]Card, error) {
+	o, err := newOutsFinder(hole, board, dead)
+	if err != nil {
+		return nil, err
+	}
+	return o.draw("", func(rank HandRank, _ []Card) bool { return rank >= target }).Outs, nil
+}
+
+// addDraw keeps a draw with any outs and adds them to the total
+func (r *OutsResult) addDraw(draw Draw) {
+	if draw.Count == 0 {
+		return
+	}
+	r.Draws = append(r.Draws, draw)
+	for _, card := range draw.Outs {
+		if !containsCard(r.Outs, card) {
+			r.Outs = append(r.Outs, card)
+		}
+	}
+	r.Count = len(r.Outs)
+}
+
+// outsFinder tries each card that could come next against a hand
+type outsFinder struct {
+	cards     [7]Card // the hole cards, the board and the card to come
+	size      int     // cards held before the card to come
+	board     []Card
+	current   Strength
+	boardHigh Rank
+	deck      []Card
+}
+
+func newOutsFinder(hole, board, dead []Card) (*outsFinder, error) {
+	if len(hole) != 2 {
+		return nil, fmt.Errorf("hand must have 2 hole cards, not %d", len(hole))
+	}
+	if len(board) != 3 && len(board) != 4 {
+		return nil, fmt.Errorf("outs need a flop or a turn, not %d board cards", len(board))
+	}
+	seen := make(map[Card]bool)
+	for _, cards := range [][]Card{hole, board, dead} {
+		if err := checkCards(seen, cards); err != nil {
+			return nil, err
+		}
+	}
+
+	o := &outsFinder{size: 2 + len(board), board: board, deck: deckWithout(seen)}
+	copy(o.cards[:], hole)
+	copy(o.cards[2:], board)
+	o.current = StandardRules.evaluate(o.cards[:o.size])
+	for _, card := range board {
+		if card.Rank > o.boardHigh {
+			o.boardHigh = card.Rank
+		}
+	}
+	return o, nil
+}
+
+// draw collects the cards that lift the hand to a better rank and complete
+// the draw, along with those of them that are tainted. completes is given
+// the rank of the hand made and its cards, the card to come last.
+func (o *outsFinder) draw(drawType DrawType, completes func(rank HandRank, cards []Card) bool) Draw {
+	draw := Draw{Type: drawType}
+	var boardCards [5]Card
+	copy(boardCards[:], o.board)
+	for _, card := range o.deck {
+		o.cards[o.size] = card
+		made := StandardRules.evaluate(o.cards[:o.size+1])
+		if made.Rank() <= o.current.Rank() || !completes(made.Rank(), o.cards[:o.size+1]) {
+			continue
+		}
+
+		// The hole cards must make the hand better than the board can
+		boardCards[len(o.board)] = card
+		onBoard := boardCards[:len(o.board)+1]
+		if made.Rank() <= StandardRules.evaluate(onBoard).Rank() {
+			continue
+		}
+
+		draw.Outs = append(draw.Outs, card)
+		if boardThreatens(onBoard, made.Rank()) {
+			draw.Tainted = append(draw.Tainted, card)
+		}
+	}
+	draw.Count = len(draw.Outs)
+	return draw
+}
+
+// boardThreatens reports whether a board lets someone beat a hand of the
+// given rank with a flush, three or more of a suit, or a full house, a
+// pair or better
+func boardThreatens(board []Card, rank HandRank) bool {
+	var suits [4]int
+	var ranks [Ace + 1]int
+	for _, card := range board {
+		suits[card.Suit]++
+		ranks[card.Rank]++
+	}
+	for _, count := range suits {
+		if count >= 3 && rank < Flush {
+			return true
+		}
+	}
+	for _, count := range ranks {
+		if count >= 2 && rank < FullHouse {
+			return true
+		}
+	}
+	return false
+}
+
+// hasFlush reports whether five of the cards share a suit, whatever better
+// hand they might also make
+func hasFlush(cards []Card) bool {
+	var suits [4]int
+	for _, card := range cards {
+		suits[card.Suit]++
+		if suits[card.Suit] == 5 {
+			return true
+		}
+	}
+	return false
+}
+
+// hasStraight reports whether the cards hold five ranks in a row, whatever
+// better hand they might also make
+func hasStraight(cards []Card) bool {
+	var ranks uint16
+	for _, card := range cards {
+		ranks |= 1 << card.Rank
+	}
+	return StandardRules.straightHigh(ranks) != 0
+}
+
+// containsCard reports whether card is among cards
+func containsCard(cards []Card, card Card) bool {
+	for _, c := range cards {
+		if c == card {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package poker
+
+// Position is a seat's place in the betting order relative to the button
+type Position string
+
+const (
+	PositionUTG    Position = "UTG"
+	PositionUTG1   Position = "UTG+1"
+	PositionUTG2   Position = "UTG+2"
+	PositionMP     Position = "MP"
+	PositionLJ     Position = "LJ"
+	PositionHJ     Position = "HJ"
+	PositionCO     Position = "CO"
+	PositionButton Position = "BTN"
+	PositionSB     Position = "SB"
+	PositionBB     Position = "BB"
+)
+
+// laterPositions name the seats after UTG, latest last
+var laterPositions = []Position{PositionUTG1, PositionUTG2, PositionMP, PositionLJ, PositionHJ, PositionCO}
+
+// MiddlePositions names the n seats between the big blind and the button,
+// in the order they act. The first to act is always UTG and the seat
+// before the button always the cutoff, with names filled in from the
+// button backwards as tables get fuller.
+func MiddlePositions(n int) []Position {
+	if n <= 0 {
+		return nil
+	}
+	if n > len(laterPositions)+1 {
+		n = len(laterPositions) + 1
+	}
+
+	positions := make([]Position, 0, n)
+	positions = append(positions, PositionUTG)
+	return append(positions, laterPositions[len(laterPositions)-(n-1):]...)
+}
+
+// IsValid checks if the position is one of the named positions
+func (p Position) IsValid() bool {
+	switch p {
+	case PositionUTG, PositionButton, PositionSB, PositionBB:
+		return true
+	}
+	for _, later := range laterPositions {
+		if p == later {
+			return true
+		}
+	}
+	return false
+}
+
+// IsStealPosition checks if an open from the position counts as a steal:
+// the cutoff, the button and the small blind
+func (p Position) IsStealPosition() bool {
+	return p == PositionCO || p == PositionButton || p == PositionSB
+}
+
+// IsBlind checks if the position posts a blind
+func (p Position) IsBlind() bool {
+	return p == PositionSB || p == PositionBB
+}
@@ -0,0 +1,292 @@
+package poker
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// StartingHand is one of the 169 kinds of hole cards: a pair, or two ranks
+// either suited or offsuit. High is the higher rank, and equal to Low for
+// a pair, which is never suited.
+type StartingHand struct {
+	High   Rank
+	Low    Rank
+	Suited bool
+}
+
+// IsPair reports whether both hole cards are of the same rank
+func (h StartingHand) IsPair() bool {
+	return h.High == h.Low
+}
+
+// String returns the hand in range notation, as in "QQ", "AKs" or "T9o"
+func (h StartingHand) String() string {
+	s := string([]byte{rankCodes[h.High], rankCodes[h.Low]})
+	switch {
+	case h.IsPair():
+		return s
+	case h.Suited:
+		return s + "s"
+	}
+	return s + "o"
+}
+
+// Combos lists every pair of hole cards of this kind, higher card first:
+// six for a pair, four suited and twelve offsuit
+func (h StartingHand) Combos() [][2]Card {
+	var combos [][2]Card
+	for high := Hearts; high <= Spades; high++ {
+		for low := Hearts; low <= Spades; low++ {
+			switch {
+			case h.IsPair() && low <= high:
+				continue
+			case !h.IsPair() && h.Suited != (high == low):
+				continue
+			}
+			combos = append(combos, [2]Card{NewCard(h.High, high), NewCard(h.Low, low)})
+		}
+	}
+	return combos
+}
+
+// WeightedCombo is a pair of hole cards in a range, with how often the
+// range holds them
+type WeightedCombo struct {
+	Cards  [2]Card `json:"cards"`
+	Weight float64 `json:"weight"`
+}
+
+// Range is a set of starting hands a player might hold, each with a weight
+// between 0 and 1 for how often they would play it
+type Range struct {
+	weights map[StartingHand]float64
+}
+
+// NewRange creates an empty range
+func NewRange() *Range {
+	return &Range{weights: make(map[StartingHand]float64)}
+}
+
+// ParseRange reads a range in the usual notation: entries separated by
+// commas or spaces, each a pair ("77"), or two ranks suited ("AKs"),
+// offsuit ("AKo") or both ("AK"). A trailing "+" raises the lower card as
+// far as it goes, so "22+" is every pair and "ATs+" is ATs to AKs, and a
+// dash spans two hands sharing the higher card, as in "A5s-A2s" or
+// "99-66". An entry may end with a weight, as in "AQo:0.5"; the last entry
+// to name a hand sets its weight.
+func ParseRange(notation string) (*Range, error) {
+	r := NewRange()
+	entries := strings.FieldsFunc(notation, func(c rune) bool { return c == ',' || c == ' ' })
+	for _, entry := range entries {
+		hands, weight, err := parseRangeEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		for _, hand := range hands {
+			r.weights[hand] = weight
+		}
+	}
+	return r, nil
+}
+
+// parseRangeEntry reads one entry of a range into the hands it names
+func parseRangeEntry(entry string) ([]StartingHand, float64, error) {
+	notation, weight := entry, 1.0
+	if i := strings.IndexByte(entry, ':'); i >= 0 {
+		var err error
+		notation = entry[:i]
+		weight, err = strconv.ParseFloat(entry[i+1:], 64)
+		if err != nil || weight <= 0 || weight > 1 {
+			return nil, 0, fmt.Errorf("invalid range entry %q: weight must be above 0 and at most 1", entry)
+		}
+	}
+
+	if from, to, ok := strings.Cut(notation, "-"); ok {
+		first, err := parseHandNotation(entry, from)
+		if err != nil {
+			return nil, 0, err
+		}
+		last, err := parseHandNotation(entry, to)
+		if err != nil {
+			return nil, 0, err
+		}
+		if first.pair != last.pair || (!first.pair && (first.high != last.high || first.suits != last.suits)) {
+			return nil, 0, fmt.Errorf("invalid range entry %q: a span must run between pairs or hands sharing the higher card", entry)
+		}
+		if first.low < last.low {
+			first, last = last, first
+		}
+		return spanHands(first, last.low, first.low), weight, nil
+	}
+
+	plus := strings.HasSuffix(notation, "+")
+	hand, err := parseHandNotation(entry, strings.TrimSuffix(notation, "+"))
+	if err != nil {
+		return nil, 0, err
+	}
+	switch {
+	case plus && hand.pair:
+		return spanHands(hand, hand.low, Ace), weight, nil
+	case plus:
+		return spanHands(hand, hand.low, hand.high-1), weight, nil
+	}
+	return spanHands(hand, hand.low, hand.low), weight, nil
+}
+
+// handNotation is a starting hand as written in a range, which may leave
+// out whether it is suited to mean both
+type handNotation struct {
+	high, low Rank
+	pair      bool
+	suits     byte // 's', 'o' or 0 for both
+}
+
+// parseHandNotation reads a hand such as "AKs", "KQ" or "77"
+func parseHandNotation(entry, s string) (handNotation, error) {
+	invalid := fmt.Errorf("invalid range entry %q: hands are two ranks, such as 77, then s or o, such as AKs", entry)
+	s = strings.ToUpper(s)
+	if len(s) != 2 && len(s) != 3 {
+		return handNotation{}, invalid
+	}
+
+	var hand handNotation
+	high, low := strings.IndexByte(rankCodes, s[0]), strings.IndexByte(rankCodes, s[1])
+	if high < int(Two) || low < int(Two) {
+		return handNotation{}, invalid
+	}
+	hand.high, hand.low = Rank(high), Rank(low)
+	if hand.low > hand.high {
+		hand.high, hand.low = hand.low, hand.high
+	}
+	hand.pair = hand.high == hand.low
+
+	if len(s) == 3 {
+		hand.suits = s[2] + 'a' - 'A'
+		if hand.pair || (hand.suits != 's' && hand.suits != 'o') {
+			return handNotation{}, invalid
+		}
+	}
+	return hand, nil
+}
+
+// spanHands lists the hands like hand with lower cards from low to high,
+// or the pairs from low to high when hand is a pair
+func spanHands(hand handNotation, low, high Rank) []StartingHand {
+	var hands []StartingHand
+	for rank := low; rank <= high; rank++ {
+		if hand.pair {
+			hands = append(hands, StartingHand{High: rank, Low: rank})
+			continue
+		}
+		if hand.suits != 'o' {
+			hands = append(hands, StartingHand{High: hand.high, Low: rank, Suited: true})
+		}
+		if hand.suits != 's' {
+			hands = append(hands, StartingHand{High: hand.high, Low: rank})
+		}
+	}
+	return hands
+}
+
+// Add puts a starting hand in the range with the given weight, replacing
+// any weight it had; a weight of 0 or less takes it out
+func (r *Range) Add(hand StartingHand, weight float64) {
+	if weight <= 0 {
+		delete(r.weights, hand)
+		return
+	}
+	r.weights[hand] = weight
+}
+
+// Weight returns how often the range holds a starting hand, 0 if never
+func (r *Range) Weight(hand StartingHand) float64 {
+	return r.weights[hand]
+}
+
+// Hands lists the starting hands in the range: pairs from the highest,
+// then suited and then offsuit hands by their higher and lower cards
+func (r *Range) Hands() []StartingHand {
+	hands := make([]StartingHand, 0, len(r.weights))
+	for hand := range r.weights {
+		hands = append(hands, hand)
+	}
+	sort.Slice(hands, func(i, j int) bool {
+		a, b := hands[i], hands[j]
+		if handGroup(a) != handGroup(b) {
+			return handGroup(a) < handGroup(b)
+		}
+		if a.High != b.High {
+			return a.High > b.High
+		}
+		return a.Low > b.Low
+	})
+	return hands
+}
+
+// handGroup orders pairs before suited hands before offsuit ones
+func handGroup(hand StartingHand) int {
+	switch {
+	case hand.IsPair():
+		return 0
+	case hand.Suited:
+		return 1
+	}
+	return 2
+}
+
+// Combos expands the range into every pair of hole cards it holds, leaving
+// out any that use a dead card
+func (r *Range) Combos(dead []Card) []WeightedCombo {
+	isDead := make(map[Card]bool, len(dead))
+	for _, card := range dead {
+		isDead[card] = true
+	}
+
+	var combos []WeightedCombo
+	for _, hand := range r.Hands() {
+		for _, cards := range hand.Combos() {
+			if !isDead[cards[0]] && !isDead[cards[1]] {
+				combos = append(combos, WeightedCombo{Cards: cards, Weight: r.weights[hand]})
+			}
+		}
+	}
+	return combos
+}
+
+// String writes the range in its shortest canonical notation, so two
+// ranges holding the same hands at the same weights read the same. Runs
+// of hands that differ only in their lower card are joined with "+" or a
+// dash, as ParseRange reads them.
+func (r *Range) String() string {
+	var entries []string
+	hands := r.Hands()
+	for i := 0; i < len(hands); {
+		// A run continues while each next hand is one rank lower and
+		// held at the same weight
+		top, weight := hands[i], r.weights[hands[i]]
+		j := i + 1
+		for j < len(hands) && handGroup(hands[j]) == handGroup(top) && r.weights[hands[j]] == weight &&
+			hands[j].Low == hands[j-1].Low-1 && (top.IsPair() || hands[j].High == top.High) {
+			j++
+		}
+		bottom := hands[j-1]
+
+		var entry string
+		switch {
+		case top == bottom:
+			entry = top.String()
+		case top.IsPair() && top.High == Ace, !top.IsPair() && top.Low == top.High-1:
+			entry = bottom.String() + "+"
+		default:
+			entry = top.String() + "-" + bottom.String()
+		}
+		if weight != 1 {
+			entry += ":" + strconv.FormatFloat(weight, 'g', -1, 64)
+		}
+		entries = append(entries, entry)
+		i = j
+	}
+	return strings.Join(entries, ", ")
+}
@@ -0,0 +1,59 @@
+package poker
+
+// Ruleset decides which hands can be made and how they rank against each
+// other. Hands are only comparable within one ruleset.
+type Ruleset int
+
+const (
+	// StandardRules rank hands made from the full 52-card deck
+	StandardRules Ruleset = iota
+	// ShortDeckRules rank hands made from the 36-card six-and-up deck. A
+	// flush is rarer there and beats a full house, and the ace plays low
+	// in A-6-7-8-9 rather than A-2-3-4-5. Three of a kind still loses to
+	// a straight.
+	ShortDeckRules
+)
+
+var rulesetNames = []string{"standard", "short_deck"}
+
+func (r Ruleset) String() string {
+	return rulesetNames[r]
+}
+
+// handOrders lists each ruleset's hand ranks from weakest to strongest
+var handOrders = [...][10]HandRank{
+	StandardRules:  {HighCard, OnePair, TwoPair, ThreeOfAKind, Straight, Flush, FullHouse, FourOfAKind, StraightFlush, RoyalFlush},
+	ShortDeckRules: {HighCard, OnePair, TwoPair, ThreeOfAKind, Straight, FullHouse, Flush, FourOfAKind, StraightFlush, RoyalFlush},
+}
+
+// handPositions is where each hand rank sits in its ruleset's order
+var handPositions = func() (positions [len(handOrders)][10]Strength) {
+	for ruleset, order := range handOrders {
+		for position, rank := range order {
+			positions[ruleset][rank] = Strength(position)
+		}
+	}
+	return positions
+}()
+
+// lowAces is the bit an ace also sets in a rank bitmask so it can play
+// low, just below the lowest rank of the ruleset's deck
+var lowAces = [...]uint16{
+	StandardRules:  1 << 1,
+	ShortDeckRules: 1 << Five,
+}
+
+// EvaluateSeven finds the strength of the best five-card hand in seven
+// cards under the ruleset, along with its rank
+func (r Ruleset) EvaluateSeven(cards []Card) (Strength, HandRank) {
+	if len(cards) != 7 {
+		panic("Must provide exactly 7 cards")
+	}
+	strength := r.evaluate(cards)
+	return strength, strength.Rank()
+}
+
+// NewHand creates a hand from five cards ranked under the ruleset
+func (r Ruleset) NewHand(cards []Card) *Hand {
+	return newHand(r, cards)
+}
@@ -0,0 +1,63 @@
+package poker
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"math/rand/v2"
+)
+
+// Shuffler puts cards in a random order. Decks shuffle through one so the
+// source of randomness can be swapped: unpredictable at the tables, and
+// reproducible in tests or when a shuffle must be proven fair afterwards.
+type Shuffler interface {
+	Shuffle(cards []Card)
+}
+
+// CryptoShuffler shuffles with randomness from crypto/rand, so no one can
+// predict the order from earlier shuffles or the time. It is the default.
+type CryptoShuffler struct{}
+
+// Shuffle runs a Fisher-Yates shuffle over the cards. It panics if the
+// system's secure random source fails, rather than deal from a deck that
+// might be predictable.
+func (CryptoShuffler) Shuffle(cards []Card) {
+	fisherYates(rand.New(cryptoSource{}), cards)
+}
+
+// cryptoSource reads random numbers from crypto/rand
+type cryptoSource struct{}
+
+func (cryptoSource) Uint64() uint64 {
+	var b [8]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		panic("poker: secure random source failed: " + err.Error())
+	}
+	return binary.LittleEndian.Uint64(b[:])
+}
+
+// SeededShuffler shuffles in an order fixed by a 32-byte seed, using the
+// ChaCha8 generator. Two shufflers with the same seed give the same run
+// of shuffles, which lets tests replay hands and lets a seed committed to
+// before the deal prove afterwards the deck was not stacked.
+type SeededShuffler struct {
+	rng *rand.Rand
+}
+
+// NewSeededShuffler creates a shuffler whose shuffles follow from seed
+func NewSeededShuffler(seed [32]byte) *SeededShuffler {
+	return &SeededShuffler{rng: rand.New(rand.NewChaCha8(seed))}
+}
+
+// Shuffle runs a Fisher-Yates shuffle over the cards with the next numbers
+// from the seed
+func (s *SeededShuffler) Shuffle(cards []Card) {
+	fisherYates(s.rng, cards)
+}
+
+// fisherYates shuffles the cards, each order being equally likely
+func fisherYates(rng *rand.Rand, cards []Card) {
+	for i := len(cards) - 1; i > 0; i-- {
+		j := rng.IntN(i + 1)
+		cards[i], cards[j] = cards[j], cards[i]
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/internal/game"
+	"github.com/primoPoker/server/pkg/poker"
+)
+
+// cashOuts is a bank that records what it pays out
+type cashOuts struct {
+	mu   sync.Mutex
+	paid map[string]int64
+}
+
+func (b *cashOuts) BuyIn(ctx context.Context, recordID string, seat game.SeatState) error {
+	return nil
+}
+
+func (b *cashOuts) CashOut(playerID string, amount int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.paid[playerID] += amount
+}
+
+// bots returns the seats bots play at the table
+func bots(state game.GameState) []game.PlayerState {
+	var seated []game.PlayerState
+	for _, player := range state.Players {
+		if player.IsBot && player.Connected {
+			seated = append(seated, player)
+		}
+	}
+	return seated
+}
+
+func TestBotsSeedTableUntilPlayersSit(t *testing.T) {
+	ctx := context.Background()
+	m := game.NewManager()
+	bank := &cashOuts{paid: make(map[string]int64)}
+	m.SetBank(bank)
+	observer := &recordingObserver{manager: m}
+	m.AddObserver(observer)
+
+	g, err := m.CreateGame("game1", "Seeded", game.WithBots(2), func(config *game.GameConfig) {
+		config.BotDelay = 10 * time.Millisecond
+		config.HandDelay = 20 * time.Millisecond
+	})
+	require.NoError(t, err)
+
+	// The bots sit down as the table opens, but deal no hand to themselves
+	state := g.GetGameState("")
+	assert.Len(t, bots(state), 2)
+	assert.Equal(t, game.WaitingForPlayers, state.Phase)
+	assert.Equal(t, 2, m.ListGames()[0].Bots)
+	assert.Equal(t, 2, m.ListGames()[0].PlayerCount)
+
+	// A player sitting down sends a bot away and is dealt in against the other
+	require.NoError(t, m.JoinGame(ctx, "game1", "alice", "Alice", 10000))
+	state = g.GetGameState("alice")
+	assert.Len(t, bots(state), 1)
+	assert.Len(t, state.Players, 2)
+	assert.Equal(t, 1, state.HandNumber)
+
+	// The bot acts on its own; Alice checks or calls whatever it does
+	require.Eventually(t, func() bool {
+		state := g.GetGameState("alice")
+		if state.CurrentPlayer == "alice" && m.ProcessAction(ctx, "game1", "alice", game.Check, 0) != nil {
+			m.ProcessAction(ctx, "game1", "alice", game.Call, 0)
+		}
+		return state.HandNumber >= 3
+	}, 5*time.Second, 5*time.Millisecond, "hands go on whoever folds")
+
+	observer.mu.Lock()
+	require.NotEmpty(t, observer.results)
+	hand := observer.results[0]
+	observer.mu.Unlock()
+	require.Len(t, hand.Players, 2)
+	for _, player := range hand.Players {
+		assert.Equal(t, player.ID != "alice", player.Bot)
+	}
+
+	// With Alice gone the bots are back to two, waiting for someone
+	require.NoError(t, m.LeaveGame(ctx, "game1", "alice"))
+	require.Eventually(t, func() bool {
+		state := g.GetGameState("")
+		return state.Phase == game.WaitingForPlayers && len(bots(state)) == 2
+	}, 5*time.Second, 5*time.Millisecond)
+
+	// She is cashed out as she leaves, or once the hand she left is paid,
+	// so nobody is left to cash out when the table closes
+	require.Eventually(t, func() bool {
+		return len(m.GetPlayerGames("alice")) == 0
+	}, 5*time.Second, 5*time.Millisecond)
+	stacks, err := m.CloseGame(ctx, "game1")
+	require.NoError(t, err)
+	assert.Empty(t, stacks)
+	bank.mu.Lock()
+	defer bank.mu.Unlock()
+	for playerID := range bank.paid {
+		assert.Equal(t, "alice", playerID, "bots are never cashed out")
+	}
+}
+
+func TestBotsLeaveASeatFree(t *testing.T) {
+	ctx := context.Background()
+	m := game.NewManager()
+	g, err := m.CreateGame("game1", "Seeded", game.WithBots(3), game.WithPlayerLimits(2, 3))
+	require.NoError(t, err)
+	require.Len(t, bots(g.GetGameState("")), 2, "bots never fill the table")
+
+	// Bob sits down in the hand Alice was dealt against a bot, which stays
+	// until the hand is over
+	require.NoError(t, m.JoinGame(ctx, "game1", "alice", "Alice", 10000))
+	require.Len(t, bots(g.GetGameState("")), 1)
+	require.NoError(t, m.JoinGame(ctx, "game1", "bob", "Bob", 10000))
+	state := g.GetGameState("")
+	assert.Len(t, bots(state), 1)
+	assert.Len(t, state.Players, 3)
+	assert.Equal(t, 1, state.HandNumber)
+
+	// Closed without waiting for Alice to act, the hand is voided
+	_, err = closeNow(m, "game1")
+	require.NoError(t, err)
+}
+
+// pushover is a bot that folds until told to play on, then checks or calls
+// everything
+type pushover struct {
+	playing atomic.Bool
+}
+
+func (b *pushover) Decide(turn game.BotTurn) (game.PlayerAction, int64) {
+	switch {
+	case !b.playing.Load():
+		return game.Fold, 0
+	case turn.ToCall == 0:
+		return game.Check, 0
+	}
+	return game.Call, 0
+}
+
+// stackOf returns a player's stack as the table shows it
+func stackOf(g *game.Game, playerID string) int64 {
+	for _, player := range g.GetGameState("").Players {
+		if player.ID == playerID {
+			return player.ChipCount
+		}
+	}
+	return 0
+}
+
+// playAgainstBot has Alice play the bot at a table seeded with two, each
+// time it is her turn taking the action given, until told to stop. She is
+// then cashed out, and what she was cashed out with returned.
+func playAgainstBot(t *testing.T, bot *pushover, play func(g *game.Game) (game.PlayerAction, bool)) int64 {
+	t.Helper()
+
+	ctx := context.Background()
+	m := game.NewManager()
+	bank := &cashOuts{paid: make(map[string]int64)}
+	m.SetBank(bank)
+	g, err := m.CreateGame("game1", "Seeded", game.WithBots(2, bot), func(config *game.GameConfig) {
+		config.BotDelay = 2 * time.Millisecond
+		config.HandDelay = 5 * time.Millisecond
+	})
+	require.NoError(t, err)
+	require.NoError(t, m.JoinGame(ctx, "game1", "alice", "Alice", 10000))
+
+	require.Eventually(t, func() bool {
+		if g.GetGameState("").CurrentPlayer != "alice" {
+			return false
+		}
+		action, done := play(g)
+		if !done {
+			m.ProcessAction(ctx, "game1", "alice", action, 0)
+		}
+		return done
+	}, 10*time.Second, time.Millisecond)
+
+	// Taken off the table on her turn, she folds the hand first
+	stack, err := m.KickPlayer("game1", "alice")
+	require.NoError(t, err)
+	_, err = m.CloseGame(ctx, "game1")
+	require.NoError(t, err)
+
+	bank.mu.Lock()
+	defer bank.mu.Unlock()
+	assert.Equal(t, map[string]int64{"alice": stack}, bank.paid)
+	return stack
+}
+
+func TestChipsWonFromBotsAreNotCashedOut(t *testing.T) {
+	// Alice takes the blinds off a bot that folds every hand, but leaves
+	// with no more than she brought
+	var won int64
+	paid := playAgainstBot(t, &pushover{}, func(g *game.Game) (game.PlayerAction, bool) {
+		won = stackOf(g, "alice") - 10000
+		return game.Call, won >= 500
+	})
+	assert.GreaterOrEqual(t, won, int64(500))
+	assert.Equal(t, int64(10000), paid)
+}
+
+func TestChipsLostToBotsAreTheOnesWonFromThem(t *testing.T) {
+	// Once the bot plays on, Alice folds back what she won from it and then
+	// some of her own, which is all she is cashed out with
+	bot := &pushover{}
+	var stack int64
+	paid := playAgainstBot(t, bot, func(g *game.Game) (game.PlayerAction, bool) {
+		stack = stackOf(g, "alice")
+		if stack >= 10500 {
+			bot.playing.Store(true)
+		}
+		if !bot.playing.Load() {
+			return game.Call, false
+		}
+		return game.Fold, stack <= 9500
+	})
+	assert.Less(t, paid, int64(10000))
+	assert.LessOrEqual(t, paid, stack)
+	assert.GreaterOrEqual(t, paid, stack-100, "all of her stack but the blind she folds as she goes")
+}
+
+func TestBotStrategies(t *testing.T) {
+	aces := []poker.Card{poker.NewCard(poker.Ace, poker.Spades), poker.NewCard(poker.Ace, poker.Hearts)}
+	trash := []poker.Card{poker.NewCard(poker.Seven, poker.Clubs), poker.NewCard(poker.Two, poker.Diamonds)}
+	facingBet := func(hole []poker.Card) game.BotTurn {
+		return game.BotTurn{
+			HoleCards: hole,
+			Phase:     game.PreFlop,
+			Pot:       750,
+			ToCall:    500,
+			MinRaise:  500,
+			Chips:     10000,
+			BigBlind:  100,
+			Opponents: 2,
+		}
+	}
+
+	action, _ := game.TightPassive{}.Decide(facingBet(aces))
+	assert.Equal(t, game.Call, action, "tight-passive calls a strong hand but never raises")
+	action, _ = game.TightPassive{}.Decide(facingBet(trash))
+	assert.Equal(t, game.Fold, action)
+	unopened := facingBet(trash)
+	unopened.ToCall = 0
+	action, _ = game.TightPassive{}.Decide(unopened)
+	assert.Equal(t, game.Check, action)
+
+	action, amount := game.LooseAggressive{}.Decide(facingBet(aces))
+	assert.Equal(t, game.Raise, action, "loose-aggressive raises a strong hand")
+	assert.GreaterOrEqual(t, amount, int64(500))
+	action, _ = game.LooseAggressive{}.Decide(facingBet(trash))
+	assert.Equal(t, game.Fold, action)
+
+	short := facingBet(aces)
+	short.Opponents = 1
+	short.Chips = 800
+	action, _ = game.LooseAggressive{}.Decide(short)
+	assert.Equal(t, game.AllIn, action, "without the chips to raise a strong hand goes all in")
+}
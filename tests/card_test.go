@@ -1,11 +1,13 @@
 package main
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/primoPoker/server/internal/game"
 	"github.com/primoPoker/server/pkg/poker"
 )
 
@@ -142,3 +144,127 @@ func TestCardValue(t *testing.T) {
 	assert.Equal(t, 13, poker.NewCard(poker.King, poker.Hearts).Value())
 	assert.Equal(t, 14, poker.NewCard(poker.Ace, poker.Hearts).Value())
 }
+
+func TestParseCard(t *testing.T) {
+	for input, want := range map[string]poker.Card{
+		"As":  poker.NewCard(poker.Ace, poker.Spades),
+		"AS":  poker.NewCard(poker.Ace, poker.Spades),
+		"th":  poker.NewCard(poker.Ten, poker.Hearts),
+		"10h": poker.NewCard(poker.Ten, poker.Hearts),
+		"10D": poker.NewCard(poker.Ten, poker.Diamonds),
+		"2c":  poker.NewCard(poker.Two, poker.Clubs),
+		"q♦":  poker.NewCard(poker.Queen, poker.Diamonds),
+	} {
+		card, err := poker.ParseCard(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, want, card, input)
+	}
+
+	for input, message := range map[string]string{
+		"":    "want a rank and a suit",
+		"1s":  "rank must be",
+		"11s": "rank must be",
+		"AX":  "suit must be",
+		"A":   "suit must be",
+		"s":   "rank must be",
+		" s":  "rank must be",
+		"As ": "suit must be",
+		"♠":   "rank must be",
+		"Ace": "suit must be",
+	} {
+		_, err := poker.ParseCard(input)
+		require.Error(t, err, "%q", input)
+		assert.Contains(t, err.Error(), message, "%q", input)
+	}
+}
+
+func TestCardRoundTrips(t *testing.T) {
+	for _, card := range poker.NewDeck().Cards {
+		parsed, err := poker.ParseCard(card.Code())
+		require.NoError(t, err)
+		assert.Equal(t, card, parsed)
+
+		parsed, err = poker.ParseCard(card.String())
+		require.NoError(t, err)
+		assert.Equal(t, card, parsed)
+
+		data, err := json.Marshal(card)
+		require.NoError(t, err)
+		var decoded poker.Card
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		assert.Equal(t, card, decoded)
+	}
+	assert.Equal(t, "Th", poker.NewCard(poker.Ten, poker.Hearts).Code())
+}
+
+func TestParseCards(t *testing.T) {
+	cards, err := poker.ParseCards("As Kd,10h, 2c")
+	require.NoError(t, err)
+	assert.Equal(t, []poker.Card{
+		poker.NewCard(poker.Ace, poker.Spades), poker.NewCard(poker.King, poker.Diamonds),
+		poker.NewCard(poker.Ten, poker.Hearts), poker.NewCard(poker.Two, poker.Clubs),
+	}, cards)
+
+	cards, err = poker.ParseCards("")
+	require.NoError(t, err)
+	assert.Empty(t, cards)
+
+	_, err = poker.ParseCards("As AX")
+	assert.ErrorContains(t, err, `"AX"`)
+	_, err = poker.ParseCards("As Kd as")
+	assert.ErrorContains(t, err, "As is given twice")
+}
+
+func TestCardJSON(t *testing.T) {
+	cards := []poker.Card{poker.NewCard(poker.Ace, poker.Spades), poker.NewCard(poker.Ten, poker.Diamonds)}
+	data, err := json.Marshal(cards)
+	require.NoError(t, err)
+	assert.JSONEq(t, `["As","Td"]`, string(data))
+
+	// Cards key objects in compact notation
+	data, err = json.Marshal(map[poker.Card]int{cards[0]: 1})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"As":1}`, string(data))
+	var counts map[poker.Card]int
+	require.NoError(t, json.Unmarshal(data, &counts))
+	assert.Equal(t, 1, counts[cards[0]])
+
+	// The old form is still read, and written while the flag is set
+	var decoded []poker.Card
+	require.NoError(t, json.Unmarshal([]byte(`[{"rank":14,"suit":3},"td"]`), &decoded))
+	assert.Equal(t, cards, decoded)
+
+	poker.SetLegacyCardJSON(true)
+	data, err = json.Marshal(cards)
+	poker.SetLegacyCardJSON(false)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"rank":14,"suit":3},{"rank":10,"suit":1}]`, string(data))
+
+	for _, input := range []string{`"1s"`, `"AX"`, `""`, `{"rank":1,"suit":0}`, `{"rank":14,"suit":4}`, `14`} {
+		var card poker.Card
+		assert.Error(t, json.Unmarshal([]byte(input), &card), input)
+	}
+}
+
+func TestGameStateCardJSON(t *testing.T) {
+	state := game.GameState{
+		CommunityCards: []poker.Card{poker.NewCard(poker.King, poker.Hearts), poker.NewCard(poker.Seven, poker.Clubs), poker.NewCard(poker.Two, poker.Spades)},
+		Players:        []game.PlayerState{{ID: "p1", HoleCards: []poker.Card{poker.NewCard(poker.Ace, poker.Spades), poker.NewCard(poker.Ace, poker.Hearts)}}},
+	}
+	data, err := json.Marshal(state)
+	require.NoError(t, err)
+
+	var raw struct {
+		CommunityCards []string `json:"community_cards"`
+		Players        []struct {
+			HoleCards []string `json:"hole_cards"`
+		} `json:"players"`
+	}
+	require.NoError(t, json.Unmarshal(data, &raw))
+	assert.Equal(t, []string{"Kh", "7c", "2s"}, raw.CommunityCards)
+	assert.Equal(t, []string{"As", "Ah"}, raw.Players[0].HoleCards)
+
+	var decoded game.GameState
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, state.CommunityCards, decoded.CommunityCards)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/pkg/poker"
+)
+
+func cards(t testing.TB, notation string) []poker.Card {
+	t.Helper()
+	parsed, err := poker.ParseCards(notation)
+	require.NoError(t, err)
+	return parsed
+}
+
+func TestEquityMonteCarlo(t *testing.T) {
+	tests := []struct {
+		name     string
+		hands    []string
+		win, tie []float64
+	}{
+		{"aces against kings", []string{"As Ah", "Ks Kh"}, []float64{81.9, 17.7}, []float64{0.4, 0.4}},
+		{"a pair against two overcards", []string{"Qs Qh", "Ad Kd"}, []float64{53.8, 45.8}, []float64{0.4, 0.4}},
+		{"dominated ace", []string{"Ac Kc", "Ad Qh"}, []float64{73.5, 22.1}, []float64{4.4, 4.4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var holeCards [][]poker.Card
+			for _, hand := range tt.hands {
+				holeCards = append(holeCards, cards(t, hand))
+			}
+			result, err := poker.Equity(holeCards, nil, nil, poker.EquityOptions{Iterations: 200000, Seed: 1})
+			require.NoError(t, err)
+			assert.False(t, result.Exhaustive)
+			assert.Equal(t, 200000, result.Boards)
+
+			for i, player := range result.Players {
+				assert.InDelta(t, tt.win[i], player.Win, 1, "player %d wins", i+1)
+				assert.InDelta(t, tt.tie[i], player.Tie, 0.5, "player %d ties", i+1)
+				assert.InDelta(t, 100, player.Win+player.Tie+player.Lose, 1e-9)
+			}
+		})
+	}
+}
+
+func TestEquityExhaustive(t *testing.T) {
+	// A flush draw and two overcards on the turn hit with 15 of the 44
+	// cards left
+	result, err := poker.Equity(
+		[][]poker.Card{cards(t, "As Ks"), cards(t, "Qh Qd")},
+		cards(t, "2s 7s Jc 4h"), nil, poker.EquityOptions{},
+	)
+	require.NoError(t, err)
+	assert.True(t, result.Exhaustive)
+	assert.Equal(t, 44, result.Boards)
+	assert.InDelta(t, 100*15.0/44, result.Players[0].Win, 1e-9)
+	assert.InDelta(t, 100*29.0/44, result.Players[1].Win, 1e-9)
+
+	// Dead cards are taken out of the deck
+	result, err = poker.Equity(
+		[][]poker.Card{cards(t, "As Ks"), cards(t, "Qh Qd")},
+		cards(t, "2s 7s Jc 4h"), cards(t, "3s 5s"), poker.EquityOptions{},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, 42, result.Boards)
+	assert.InDelta(t, 100*13.0/42, result.Players[0].Win, 1e-9)
+
+	// Every flop is dealt out when the limit allows it
+	result, err = poker.Equity(
+		[][]poker.Card{cards(t, "As Ks"), cards(t, "Qh Qd")},
+		cards(t, "2s 7s Jc"), nil, poker.EquityOptions{},
+	)
+	require.NoError(t, err)
+	assert.True(t, result.Exhaustive)
+	assert.Equal(t, 990, result.Boards)
+}
+
+func TestEquitySplitPots(t *testing.T) {
+	// The board plays for everyone
+	result, err := poker.Equity(
+		[][]poker.Card{cards(t, "2c 3d"), cards(t, "2h 3s"), cards(t, "4c 5d")},
+		cards(t, "Ts Js Qs Ks As"), nil, poker.EquityOptions{},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Boards)
+	for _, player := range result.Players {
+		assert.Equal(t, 100.0, player.Tie)
+		assert.InDelta(t, 100.0/3, player.Equity, 1e-9)
+	}
+
+	// Two of three players chop, the third loses
+	result, err = poker.Equity(
+		[][]poker.Card{cards(t, "Ac 2d"), cards(t, "Ad 3c"), cards(t, "Kc Qd")},
+		cards(t, "Ah 9s 8d 6c 4h"), nil, poker.EquityOptions{},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, 100.0, result.Players[0].Tie)
+	assert.Equal(t, 50.0, result.Players[0].Equity)
+	assert.Equal(t, 100.0, result.Players[2].Lose)
+	assert.Equal(t, 0.0, result.Players[2].Equity)
+}
+
+func TestEquitySeed(t *testing.T) {
+	holeCards := [][]poker.Card{cards(t, "Jc Tc"), cards(t, "8h 8d"), cards(t, "Ah 5s")}
+	run := func(seed int64) *poker.EquityResult {
+		result, err := poker.Equity(holeCards, nil, nil, poker.EquityOptions{Iterations: 20000, Seed: seed, Workers: 4})
+		require.NoError(t, err)
+		return result
+	}
+	assert.Equal(t, run(42), run(42), "the same seed deals the same boards")
+	assert.NotEqual(t, run(42), run(43))
+}
+
+func TestEquityErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		holeCards [][]poker.Card
+		board     []poker.Card
+		dead      []poker.Card
+		message   string
+	}{
+		{"one player", [][]poker.Card{cards(t, "As Ah")}, nil, nil, "at least two players"},
+		{"three hole cards", [][]poker.Card{cards(t, "As Ah Ad"), cards(t, "Ks Kh")}, nil, nil, "player 1 must have 2 hole cards"},
+		{"two board cards", [][]poker.Card{cards(t, "As Ah"), cards(t, "Ks Kh")}, cards(t, "2c 3c"), nil, "board must have"},
+		{"shared hole card", [][]poker.Card{cards(t, "As Ah"), cards(t, "As Kh")}, nil, nil, "card As is given twice"},
+		{"hole card on the board", [][]poker.Card{cards(t, "As Ah"), cards(t, "Ks Kh")}, cards(t, "2c 3c Ah"), nil, "card Ah is given twice"},
+		{"dead card in a hand", [][]poker.Card{cards(t, "As Ah"), cards(t, "Ks Kh")}, nil, cards(t, "Kh"), "card Kh is given twice"},
+		{"invalid card", [][]poker.Card{cards(t, "As Ah"), {{}, poker.NewCard(poker.Two, poker.Clubs)}}, nil, nil, "invalid card"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := poker.Equity(tt.holeCards, tt.board, tt.dead, poker.EquityOptions{})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.message)
+		})
+	}
+}
+
+func BenchmarkEquityPreflop(b *testing.B) {
+	holeCards := [][]poker.Card{cards(b, "As Ah"), cards(b, "Ks Kh")}
+	for i := 0; i < b.N; i++ {
+		poker.Equity(holeCards, nil, nil, poker.EquityOptions{Seed: 1})
+	}
+}
+
+func BenchmarkEquityFlop(b *testing.B) {
+	holeCards := [][]poker.Card{cards(b, "As Ks"), cards(b, "Qh Qd"), cards(b, "Jd Td")}
+	board := cards(b, "2s 7s Jc")
+	for i := 0; i < b.N; i++ {
+		poker.Equity(holeCards, board, nil, poker.EquityOptions{})
+	}
+}
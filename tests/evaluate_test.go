@@ -0,0 +1,461 @@
+package main
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/pkg/poker"
+)
+
+// referenceHand is the brute force evaluator EvaluateSeven replaced: every
+// five-card hand is ranked on its own and the best kept. Hands are
+// compared by rank and then kickers.
+type referenceHand struct {
+	rank    poker.HandRank
+	kickers []poker.Rank
+}
+
+func (h referenceHand) compare(other referenceHand) int {
+	if h.rank != other.rank {
+		return sign(int(h.rank) - int(other.rank))
+	}
+	for i := range h.kickers {
+		if h.kickers[i] != other.kickers[i] {
+			return sign(int(h.kickers[i]) - int(other.kickers[i]))
+		}
+	}
+	return 0
+}
+
+func sign(n int) int {
+	switch {
+	case n > 0:
+		return 1
+	case n < 0:
+		return -1
+	}
+	return 0
+}
+
+func referenceBest(cards []poker.Card) referenceHand {
+	var best *referenceHand
+	five := make([]poker.Card, 0, 5)
+	for i := 0; i < len(cards); i++ {
+		for j := i + 1; j < len(cards); j++ {
+			five = five[:0]
+			for k, card := range cards {
+				if k != i && k != j {
+					five = append(five, card)
+				}
+			}
+			hand := referenceFive(five)
+			if best == nil || hand.compare(*best) > 0 {
+				best = &hand
+			}
+		}
+	}
+	return *best
+}
+
+func referenceFive(cards []poker.Card) referenceHand {
+	cards = append([]poker.Card(nil), cards...)
+	sort.Slice(cards, func(i, j int) bool { return cards[i].Rank > cards[j].Rank })
+
+	ranks := make([]int, 15)
+	suits := make([]int, 4)
+	for _, card := range cards {
+		ranks[card.Rank]++
+		suits[card.Suit]++
+	}
+
+	isFlush := false
+	for _, count := range suits {
+		if count == 5 {
+			isFlush = true
+		}
+	}
+
+	var straightHigh poker.Rank
+	consecutive := 0
+	for rank := poker.Ace; rank >= poker.Two && straightHigh == 0; rank-- {
+		if ranks[rank] > 0 {
+			consecutive++
+			if consecutive == 5 {
+				straightHigh = rank + 4
+			}
+		} else {
+			consecutive = 0
+		}
+	}
+	if straightHigh == 0 && ranks[poker.Ace] > 0 && ranks[poker.Two] > 0 && ranks[poker.Three] > 0 && ranks[poker.Four] > 0 && ranks[poker.Five] > 0 {
+		straightHigh = poker.Five
+	}
+
+	if straightHigh != 0 && isFlush {
+		if straightHigh == poker.Ace {
+			return referenceHand{poker.RoyalFlush, []poker.Rank{poker.Ace}}
+		}
+		return referenceHand{poker.StraightFlush, []poker.Rank{straightHigh}}
+	}
+
+	var pairs, trips, quads, kickers []poker.Rank
+	for rank := poker.Ace; rank >= poker.Two; rank-- {
+		switch ranks[rank] {
+		case 4:
+			quads = append(quads, rank)
+		case 3:
+			trips = append(trips, rank)
+		case 2:
+			pairs = append(pairs, rank)
+		case 1:
+			kickers = append(kickers, rank)
+		}
+	}
+
+	switch {
+	case len(quads) == 1:
+		return referenceHand{poker.FourOfAKind, append(quads, kickers...)}
+	case len(trips) == 1 && len(pairs) == 1:
+		return referenceHand{poker.FullHouse, []poker.Rank{trips[0], pairs[0]}}
+	case isFlush:
+		return referenceHand{poker.Flush, kickers}
+	case straightHigh != 0:
+		return referenceHand{poker.Straight, []poker.Rank{straightHigh}}
+	case len(trips) == 1:
+		return referenceHand{poker.ThreeOfAKind, append(trips, kickers...)}
+	case len(pairs) == 2:
+		return referenceHand{poker.TwoPair, append(pairs, kickers...)}
+	case len(pairs) == 1:
+		return referenceHand{poker.OnePair, append(pairs, kickers...)}
+	}
+	return referenceHand{poker.HighCard, kickers}
+}
+
+// assertMatchesReference checks EvaluateSeven agrees with the brute force
+// on the hand's rank and kickers
+func assertMatchesReference(t *testing.T, cards []poker.Card) (poker.Strength, referenceHand) {
+	t.Helper()
+
+	strength, rank := poker.EvaluateSeven(cards)
+	want := referenceBest(cards)
+	assert.Equal(t, want.rank, rank, "%v", cards)
+	assert.Equal(t, want.rank, strength.Rank(), "%v", cards)
+	assert.Equal(t, want.kickers, strength.Kickers(), "%v", cards)
+	return strength, want
+}
+
+func randomSeven(rng *rand.Rand, deck []poker.Card) []poker.Card {
+	rng.Shuffle(len(deck), func(i, j int) { deck[i], deck[j] = deck[j], deck[i] })
+	return append([]poker.Card(nil), deck[:7]...)
+}
+
+func TestEvaluateSevenMatchesBruteForce(t *testing.T) {
+	samples := 200000
+	if testing.Short() {
+		samples = 20000
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	deck := poker.NewDeck().Cards
+	var previous poker.Strength
+	var previousHand referenceHand
+	for i := 0; i < samples; i++ {
+		strength, hand := assertMatchesReference(t, randomSeven(rng, deck))
+		if i > 0 {
+			assert.Equal(t, hand.compare(previousHand), sign(int(strength)-int(previous)), "strengths order hands as the brute force does")
+		}
+		previous, previousHand = strength, hand
+		if t.Failed() {
+			return
+		}
+	}
+}
+
+func TestEvaluateSevenStraightsAndFlushes(t *testing.T) {
+	deck := poker.NewDeck().Cards
+
+	// Every straight, wheel included, suited, four-suited and unsuited,
+	// with every pair of other cards: flushes in other suits, higher and
+	// lower straights, pairs and trips all fall in
+	for high := poker.Five; high <= poker.Ace; high++ {
+		for _, pattern := range [][5]poker.Suit{
+			{poker.Spades, poker.Spades, poker.Spades, poker.Spades, poker.Spades},
+			{poker.Spades, poker.Spades, poker.Spades, poker.Spades, poker.Hearts},
+			{poker.Hearts, poker.Spades, poker.Spades, poker.Spades, poker.Spades},
+			{poker.Spades, poker.Hearts, poker.Clubs, poker.Diamonds, poker.Spades},
+		} {
+			straight := make([]poker.Card, 5)
+			for i := range straight {
+				rank := high - poker.Rank(i)
+				if rank < poker.Two {
+					rank = poker.Ace
+				}
+				straight[i] = poker.NewCard(rank, pattern[i])
+			}
+
+			var rest []poker.Card
+			for _, card := range deck {
+				if !containsCard(straight, card) {
+					rest = append(rest, card)
+				}
+			}
+			for i := range rest {
+				for j := i + 1; j < len(rest); j++ {
+					assertMatchesReference(t, append(append([]poker.Card(nil), straight...), rest[i], rest[j]))
+				}
+			}
+			if t.Failed() {
+				return
+			}
+		}
+	}
+}
+
+func containsCard(cards []poker.Card, card poker.Card) bool {
+	for _, c := range cards {
+		if c == card {
+			return true
+		}
+	}
+	return false
+}
+
+func TestEvaluateSevenEdgeCases(t *testing.T) {
+	c := poker.NewCard
+	tests := []struct {
+		name    string
+		cards   []poker.Card
+		rank    poker.HandRank
+		kickers []poker.Rank
+	}{
+		{"wheel", []poker.Card{c(poker.Ace, poker.Spades), c(poker.Two, poker.Hearts), c(poker.Three, poker.Clubs), c(poker.Four, poker.Spades), c(poker.Five, poker.Diamonds), c(poker.Nine, poker.Hearts), c(poker.King, poker.Clubs)},
+			poker.Straight, []poker.Rank{poker.Five}},
+		{"six high beats the wheel", []poker.Card{c(poker.Ace, poker.Spades), c(poker.Two, poker.Hearts), c(poker.Three, poker.Clubs), c(poker.Four, poker.Spades), c(poker.Five, poker.Diamonds), c(poker.Six, poker.Hearts), c(poker.King, poker.Clubs)},
+			poker.Straight, []poker.Rank{poker.Six}},
+		{"steel wheel over a six high straight", []poker.Card{c(poker.Ace, poker.Spades), c(poker.Two, poker.Spades), c(poker.Three, poker.Spades), c(poker.Four, poker.Spades), c(poker.Five, poker.Spades), c(poker.Six, poker.Hearts), c(poker.King, poker.Clubs)},
+			poker.StraightFlush, []poker.Rank{poker.Five}},
+		{"royal", []poker.Card{c(poker.Ace, poker.Hearts), c(poker.King, poker.Hearts), c(poker.Queen, poker.Hearts), c(poker.Jack, poker.Hearts), c(poker.Ten, poker.Hearts), c(poker.Nine, poker.Hearts), c(poker.Two, poker.Clubs)},
+			poker.RoyalFlush, []poker.Rank{poker.Ace}},
+		{"six card flush plays the top five", []poker.Card{c(poker.Ace, poker.Clubs), c(poker.Jack, poker.Clubs), c(poker.Nine, poker.Clubs), c(poker.Seven, poker.Clubs), c(poker.Four, poker.Clubs), c(poker.Two, poker.Clubs), c(poker.King, poker.Hearts)},
+			poker.Flush, []poker.Rank{poker.Ace, poker.Jack, poker.Nine, poker.Seven, poker.Four}},
+		{"flush over a straight", []poker.Card{c(poker.Nine, poker.Clubs), c(poker.Eight, poker.Hearts), c(poker.Seven, poker.Clubs), c(poker.Six, poker.Clubs), c(poker.Five, poker.Clubs), c(poker.Two, poker.Clubs), c(poker.King, poker.Hearts)},
+			poker.Flush, []poker.Rank{poker.Nine, poker.Seven, poker.Six, poker.Five, poker.Two}},
+		{"two sets of trips", []poker.Card{c(poker.Nine, poker.Clubs), c(poker.Nine, poker.Hearts), c(poker.Nine, poker.Spades), c(poker.Queen, poker.Clubs), c(poker.Queen, poker.Hearts), c(poker.Queen, poker.Spades), c(poker.Ace, poker.Hearts)},
+			poker.FullHouse, []poker.Rank{poker.Queen, poker.Nine}},
+		{"quads with trips", []poker.Card{c(poker.Nine, poker.Clubs), c(poker.Nine, poker.Hearts), c(poker.Nine, poker.Spades), c(poker.Nine, poker.Diamonds), c(poker.Queen, poker.Hearts), c(poker.Queen, poker.Spades), c(poker.Queen, poker.Clubs)},
+			poker.FourOfAKind, []poker.Rank{poker.Nine, poker.Queen}},
+		{"three pairs", []poker.Card{c(poker.Two, poker.Clubs), c(poker.Two, poker.Hearts), c(poker.Jack, poker.Spades), c(poker.Jack, poker.Diamonds), c(poker.Four, poker.Hearts), c(poker.Four, poker.Spades), c(poker.Three, poker.Clubs)},
+			poker.TwoPair, []poker.Rank{poker.Jack, poker.Four, poker.Three}},
+		{"pair of aces", []poker.Card{c(poker.Ace, poker.Spades), c(poker.Ace, poker.Hearts), c(poker.King, poker.Clubs), c(poker.Nine, poker.Diamonds), c(poker.Seven, poker.Clubs), c(poker.Four, poker.Spades), c(poker.Two, poker.Hearts)},
+			poker.OnePair, []poker.Rank{poker.Ace, poker.King, poker.Nine, poker.Seven}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strength, rank := poker.EvaluateSeven(tt.cards)
+			assert.Equal(t, tt.rank, rank)
+			assert.Equal(t, tt.kickers, strength.Kickers())
+			assertMatchesReference(t, tt.cards)
+
+			best := poker.GetBestHand(tt.cards)
+			require.Len(t, best.Cards, 5)
+			assert.Equal(t, tt.rank, best.Rank)
+			assert.Equal(t, int(strength), best.Value)
+		})
+	}
+}
+
+func TestEvaluateSevenDoesNotAllocate(t *testing.T) {
+	cards := randomSeven(rand.New(rand.NewSource(2)), poker.NewDeck().Cards)
+	allocs := testing.AllocsPerRun(100, func() { poker.EvaluateSeven(cards) })
+	assert.Zero(t, allocs)
+}
+
+// benchmarkHands are random seven-card hands shared by the benchmarks
+func benchmarkHands() [][]poker.Card {
+	rng := rand.New(rand.NewSource(3))
+	deck := poker.NewDeck().Cards
+	hands := make([][]poker.Card, 1024)
+	for i := range hands {
+		hands[i] = randomSeven(rng, deck)
+	}
+	return hands
+}
+
+func BenchmarkEvaluateSeven(b *testing.B) {
+	hands := benchmarkHands()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		poker.EvaluateSeven(hands[i%len(hands)])
+	}
+}
+
+func BenchmarkGetBestHand(b *testing.B) {
+	hands := benchmarkHands()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		poker.GetBestHand(hands[i%len(hands)])
+	}
+}
+
+func BenchmarkBruteForce(b *testing.B) {
+	hands := benchmarkHands()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		referenceBest(hands[i%len(hands)])
+	}
+}
+
+// referenceBestOf is the brute force best hand of five or more cards
+func referenceBestOf(cards []poker.Card) referenceHand {
+	var best referenceHand
+	for chosen := 0; chosen < 1<<len(cards); chosen++ {
+		var five []poker.Card
+		for i, card := range cards {
+			if chosen&(1<<i) != 0 {
+				five = append(five, card)
+			}
+		}
+		if len(five) != 5 {
+			continue
+		}
+		if hand := referenceFive(five); best.kickers == nil || hand.compare(best) > 0 {
+			best = hand
+		}
+	}
+	return best
+}
+
+func TestBestHandFrom(t *testing.T) {
+	tests := []struct {
+		name    string
+		cards   string
+		rank    poker.HandRank
+		kickers []poker.Rank
+	}{
+		{"flop pair", "As Kd 9h Ac 2s", poker.OnePair, []poker.Rank{poker.Ace, poker.King, poker.Nine, poker.Two}},
+		{"flopped wheel", "As 2d 3h 4c 5s", poker.Straight, []poker.Rank{poker.Five}},
+		{"flop high card", "As Kd 9h 7c 2s", poker.HighCard, []poker.Rank{poker.Ace, poker.King, poker.Nine, poker.Seven, poker.Two}},
+		{"turn makes the flush", "Ah Kh 9h 7h 2s 3h", poker.Flush, []poker.Rank{poker.Ace, poker.King, poker.Nine, poker.Seven, poker.Three}},
+		{"turn counterfeits two pair", "9s 8s 9h 8h 2d 2c", poker.TwoPair, []poker.Rank{poker.Nine, poker.Eight, poker.Two}},
+		{"turn fills up", "Qs Qh 7d 7c Qd 2s", poker.FullHouse, []poker.Rank{poker.Queen, poker.Seven}},
+		{"river straight flush", "9s 8s 7s 6s 5s Ah Ad", poker.StraightFlush, []poker.Rank{poker.Nine}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := cards(t, tt.cards)
+			hand, err := poker.BestHandFrom(in)
+			require.NoError(t, err)
+			assert.Equal(t, tt.rank, hand.Rank)
+			assert.Equal(t, tt.kickers, hand.Kickers)
+			require.Len(t, hand.Cards, 5)
+			for _, card := range hand.Cards {
+				assert.Contains(t, in, card)
+			}
+		})
+	}
+}
+
+func TestBestHandFromMatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	deck := poker.NewDeck().Cards
+	for _, size := range []int{5, 6} {
+		for i := 0; i < 5000; i++ {
+			rng.Shuffle(len(deck), func(i, j int) { deck[i], deck[j] = deck[j], deck[i] })
+			in := deck[:size]
+			hand, err := poker.BestHandFrom(in)
+			require.NoError(t, err)
+			want := referenceBestOf(in)
+			assert.Equal(t, want.rank, hand.Rank, "%v", in)
+			assert.Equal(t, want.kickers, hand.Kickers, "%v", in)
+			if t.Failed() {
+				return
+			}
+		}
+	}
+}
+
+func TestBestHandFromRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		cards   []poker.Card
+		message string
+	}{
+		{"no cards", nil, "from 5 to 7 cards, not 0"},
+		{"four cards", cards(t, "As Kd 9h 7c"), "from 5 to 7 cards, not 4"},
+		{"eight cards", cards(t, "As Kd 9h 7c 2s 3s 4s 5s"), "from 5 to 7 cards, not 8"},
+		{"duplicate", append(cards(t, "As Kd 9h 7c"), poker.NewCard(poker.Ace, poker.Spades)), "card As is given twice"},
+		{"duplicate among seven", append(cards(t, "As Kd 9h 7c 2s 3s"), poker.NewCard(poker.Three, poker.Spades)), "card 3s is given twice"},
+		{"invalid card", append(cards(t, "As Kd 9h 7c"), poker.Card{}), "invalid card"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hand, err := poker.BestHandFrom(tt.cards)
+			assert.Nil(t, hand)
+			assert.ErrorContains(t, err, tt.message)
+		})
+	}
+
+	assert.Panics(t, func() {
+		poker.GetBestHand(append(cards(t, "As Kd 9h 7c 2s 3s"), poker.NewCard(poker.Ace, poker.Spades)))
+	})
+}
+
+func BenchmarkNewHand(b *testing.B) {
+	hands := benchmarkHands()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		poker.NewHand(hands[i%len(hands)][:5])
+	}
+}
+
+func BenchmarkCompareHands(b *testing.B) {
+	hands := benchmarkHands()
+	made := make([]*poker.Hand, len(hands))
+	for i, cards := range hands {
+		made[i] = poker.NewHand(cards[:5])
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		poker.CompareHands(made[i%len(made)], made[(i+1)%len(made)])
+	}
+}
+
+// BenchmarkShowdown evaluates a full nine-handed table at showdown: each
+// player's hole cards with the shared board, then the winners
+func BenchmarkShowdown(b *testing.B) {
+	rng := rand.New(rand.NewSource(6))
+	deck := poker.NewDeck().Cards
+	tables := make([][]poker.Card, 256)
+	for i := range tables {
+		rng.Shuffle(len(deck), func(i, j int) { deck[i], deck[j] = deck[j], deck[i] })
+		tables[i] = append([]poker.Card(nil), deck[:23]...)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	var seven [7]poker.Card
+	for i := 0; i < b.N; i++ {
+		table := tables[i%len(tables)]
+		copy(seven[2:], table[18:])
+		var best poker.Strength
+		winners := 0
+		for p := 0; p < 9; p++ {
+			seven[0], seven[1] = table[2*p], table[2*p+1]
+			switch strength, _ := poker.EvaluateSeven(seven[:]); {
+			case strength > best:
+				best, winners = strength, 1
+			case strength == best:
+				winners++
+			}
+		}
+	}
+}
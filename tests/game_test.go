@@ -2,10 +2,12 @@ package main
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"context"
 	"github.com/primoPoker/server/internal/game"
 )
 
@@ -108,117 +110,140 @@ func TestNewGame(t *testing.T) {
 	assert.Empty(t, g.PlayerOrder)
 }
 
-func TestGameAddPlayer(t *testing.T) {
-	config := game.GameConfig{
-		MaxPlayersPerTable: 6,
-		MinPlayersPerTable: 2,
-		SmallBlind:        50,
-		BigBlind:          100,
-		DefaultBuyIn:      10000,
+// templateTables lists the open tables of a template
+func templateTables(m *game.Manager, templateID string) []*game.GameInfo {
+	var tables []*game.GameInfo
+	for _, info := range m.ListGames() {
+		if info.TemplateID == templateID {
+			tables = append(tables, info)
+		}
 	}
-	
-	g := game.NewGame("game1", "Test Game", config)
-	player := game.NewPlayer("player1", "Alice", 10000, 0)
-	
-	// Add first player
-	err := g.AddPlayer(player)
+	return tables
+}
+
+func TestTemplateTableRespawnsWhenFull(t *testing.T) {
+	m := game.NewManager()
+	err := m.SetTemplates([]game.Template{{
+		ID: "micro", Name: "Micro 25/50", SmallBlind: 25, BigBlind: 50, Ante: 5,
+		MinBuyIn: 1000, MaxBuyIn: 5000, MaxPlayers: 2,
+	}})
 	require.NoError(t, err)
-	assert.Len(t, g.Players, 1)
-	assert.Contains(t, g.Players, "player1")
-	assert.Equal(t, []string{"player1"}, g.PlayerOrder)
-	
-	// Still waiting for more players
-	assert.Equal(t, game.WaitingForPlayers, g.Phase)
-	
-	// Add second player (should start the game)
-	player2 := game.NewPlayer("player2", "Bob", 10000, 1)
-	err = g.AddPlayer(player2)
+
+	tables := templateTables(m, "micro")
+	require.Len(t, tables, 1)
+	first := tables[0]
+	assert.Equal(t, "Micro 25/50", first.Name)
+	assert.Equal(t, int64(50), first.BigBlind)
+	assert.Equal(t, int64(5000), first.BuyIn, "the default buy-in is kept within the template's range")
+
+	assert.ErrorIs(t, m.JoinGame(context.Background(), first.ID, "player1", "Alice", 10000), game.ErrInvalidBuyIn)
+	require.NoError(t, m.JoinGame(context.Background(), first.ID, "player1", "Alice", 3000))
+	assert.Len(t, templateTables(m, "micro"), 1, "a table with a free seat is enough")
+
+	require.NoError(t, m.JoinGame(context.Background(), first.ID, "player2", "Bob", 3000))
+	tables = templateTables(m, "micro")
+	require.Len(t, tables, 2, "filling the table opens another")
+
+	g, err := m.GetGame(first.ID)
 	require.NoError(t, err)
-	assert.Len(t, g.Players, 2)
-	assert.Equal(t, game.PreFlop, g.Phase)
-	
-	// Can't add same player twice
-	err = g.AddPlayer(player)
-	assert.Error(t, err)
-}
+	assert.Equal(t, int64(5+5+25+50), g.Pot, "antes are posted with the blinds")
 
-func TestGameProcessAction(t *testing.T) {
-	config := game.GameConfig{
-		MaxPlayersPerTable: 6,
-		MinPlayersPerTable: 2,
-		SmallBlind:        50,
-		BigBlind:          100,
-		DefaultBuyIn:      10000,
+	var second *game.GameInfo
+	for _, table := range tables {
+		if table.ID != first.ID {
+			second = table
+		}
 	}
-	
-	g := game.NewGame("game1", "Test Game", config)
-	
-	// Add players
-	player1 := game.NewPlayer("player1", "Alice", 10000, 0)
-	player2 := game.NewPlayer("player2", "Bob", 10000, 1)
-	
-	g.AddPlayer(player1)
-	g.AddPlayer(player2)
-	
-	// Game should be in PreFlop phase
-	assert.Equal(t, game.PreFlop, g.Phase)
-	
-	// Process some actions
-	currentPlayerID := g.PlayerOrder[g.CurrentPlayer]
-	
-	// Call the big blind
-	err := g.ProcessAction(currentPlayerID, game.Call, 0)
+	require.NotNil(t, second)
+	assert.Equal(t, "Micro 25/50 #2", second.Name)
+
+	// An empty template table stays open while it is the one with seats
+	require.NoError(t, m.JoinGame(context.Background(), second.ID, "player3", "Carol", 2000))
+	require.NoError(t, m.LeaveGame(context.Background(), second.ID, "player3"))
+	_, err = m.GetGame(second.ID)
+	assert.NoError(t, err)
+}
+
+func TestTemplateTableReopensWhenClosed(t *testing.T) {
+	m := game.NewManager()
+	require.NoError(t, m.SetTemplates([]game.Template{{ID: "deep", Name: "Deep 500/1000", SmallBlind: 500, BigBlind: 1000}}))
+
+	tables := templateTables(m, "deep")
+	require.Len(t, tables, 1)
+	_, err := m.CloseGame(context.Background(), tables[0].ID)
 	require.NoError(t, err)
-	
-	// Should advance to next player or next phase
-	assert.NotEqual(t, game.GameOver, g.Phase)
+
+	reopened := templateTables(m, "deep")
+	require.Len(t, reopened, 1, "closing the template's table opens a fresh one")
+	assert.NotEqual(t, tables[0].ID, reopened[0].ID)
+
+	template, ok := m.Template("deep 500/1000")
+	assert.True(t, ok, "templates are found by name")
+	assert.Equal(t, "deep", template.ID)
 }
 
-func TestGameGetGameState(t *testing.T) {
-	config := game.GameConfig{
-		MaxPlayersPerTable: 6,
-		MinPlayersPerTable: 2,
-		SmallBlind:        50,
-		BigBlind:          100,
-		DefaultBuyIn:      10000,
-	}
-	
-	g := game.NewGame("game1", "Test Game", config)
-	player := game.NewPlayer("player1", "Alice", 10000, 0)
-	g.AddPlayer(player)
-	
-	state := g.GetGameState("player1")
-	
-	assert.Equal(t, "game1", state.GameID)
-	assert.Equal(t, g.Phase, state.Phase)
-	assert.Equal(t, g.Pot, state.Pot)
-	assert.Len(t, state.Players, 1)
-	assert.Equal(t, "player1", state.Players[0].ID)
-	assert.Equal(t, "Alice", state.Players[0].Username)
+func TestCleanupClosesIdleTables(t *testing.T) {
+	ctx := context.Background()
+	m := game.NewManager()
+
+	idle, err := m.CreateGame("idle", "Idle Table")
+	require.NoError(t, err)
+	busy, err := m.CreateGame("busy", "Busy Table")
+	require.NoError(t, err)
+	_, err = m.CreateGame("new", "New Table")
+	require.NoError(t, err)
+
+	require.NoError(t, m.JoinGame(ctx, "busy", "player2", "Bob", 10000))
+	idle.LastActivity = time.Now().Add(-2 * time.Hour)
+	busy.LastActivity = time.Now().Add(-2 * time.Hour)
+
+	var notified []string
+	closed := m.CleanupInactiveGames(time.Hour, func(gameID string) {
+		notified = append(notified, gameID)
+	})
+	assert.Equal(t, []string{"idle"}, closed)
+	assert.Equal(t, []string{"idle"}, notified)
+
+	_, err = m.GetGame("idle")
+	assert.ErrorIs(t, err, game.ErrGameNotFound)
+	assert.Equal(t, game.GameOver, idle.Phase)
+
+	_, err = m.GetGame("busy")
+	assert.NoError(t, err, "a table someone is seated at stays open")
+	_, err = m.GetGame("new")
+	assert.NoError(t, err, "an empty table stays open until it has been idle long enough")
+	assert.Equal(t, []string{"busy"}, m.GetPlayerGames("player2"))
 }
 
-func TestGameRemovePlayer(t *testing.T) {
-	config := game.GameConfig{
-		MaxPlayersPerTable: 6,
-		MinPlayersPerTable: 2,
-		SmallBlind:        50,
-		BigBlind:          100,
-		DefaultBuyIn:      10000,
-	}
-	
-	g := game.NewGame("game1", "Test Game", config)
-	player := game.NewPlayer("player1", "Alice", 10000, 0)
-	g.AddPlayer(player)
-	
-	// Remove player
-	err := g.RemovePlayer("player1")
+func TestClosingDuringHandDelayDealsNoHand(t *testing.T) {
+	ctx := context.Background()
+	m := game.NewManager()
+	g, err := m.CreateGame("game1", "Test Game", func(config *game.GameConfig) {
+		config.HandDelay = 20 * time.Millisecond
+	})
 	require.NoError(t, err)
-	
-	// Player should be marked as disconnected
-	assert.False(t, g.Players["player1"].Connected)
-	assert.False(t, g.Players["player1"].IsActive)
-	
-	// Can't remove non-existent player
-	err = g.RemovePlayer("nonexistent")
-	assert.Error(t, err)
+
+	require.NoError(t, m.JoinGame(ctx, "game1", "player1", "Alice", 10000))
+	require.NoError(t, m.JoinGame(ctx, "game1", "player2", "Bob", 10000))
+	require.Equal(t, 1, g.HandNumber)
+	// Check the hand down to the showdown
+	state := g.GetGameState("")
+	for i := 0; i < 10 && state.Phase != game.Showdown; i++ {
+		if m.ProcessAction(ctx, "game1", state.CurrentPlayer, game.Check, 0) != nil {
+			require.NoError(t, m.ProcessAction(ctx, "game1", state.CurrentPlayer, game.Call, 0))
+		}
+		state = g.GetGameState("")
+	}
+	require.Equal(t, game.Showdown, state.Phase, "the next hand waits for the delay")
+
+	// The last player to leave closes the table
+	require.NoError(t, m.LeaveGame(ctx, "game1", "player1"))
+	require.NoError(t, m.LeaveGame(ctx, "game1", "player2"))
+	_, err = m.GetGame("game1")
+	assert.ErrorIs(t, err, game.ErrGameNotFound)
+
+	time.Sleep(100 * time.Millisecond)
+	state = g.GetGameState("")
+	assert.Equal(t, game.GameOver, state.Phase)
+	assert.Equal(t, 1, state.HandNumber, "no hand is dealt at a closed table")
 }
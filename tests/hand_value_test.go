@@ -0,0 +1,108 @@
+package main
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/pkg/poker"
+)
+
+// referenceKey identifies hands the brute force ranks alike
+type referenceKey struct {
+	rank    poker.HandRank
+	kickers [5]poker.Rank
+}
+
+func (h referenceHand) key() referenceKey {
+	key := referenceKey{rank: h.rank}
+	copy(key.kickers[:], h.kickers)
+	return key
+}
+
+func TestHandValuesOrderEveryHand(t *testing.T) {
+	if testing.Short() {
+		t.Skip("ranks every five-card hand")
+	}
+
+	// Every hand the brute force ranks alike must share one value, and
+	// values must rise with the brute force's order
+	deck := poker.NewDeck().Cards
+	values := make(map[referenceKey]int)
+	var classes []referenceHand
+	five := make([]poker.Card, 5)
+	for a := 0; a < len(deck); a++ {
+		for b := a + 1; b < len(deck); b++ {
+			for c := b + 1; c < len(deck); c++ {
+				for d := c + 1; d < len(deck); d++ {
+					for e := d + 1; e < len(deck); e++ {
+						five[0], five[1], five[2], five[3], five[4] = deck[a], deck[b], deck[c], deck[d], deck[e]
+						want := referenceFive(five)
+						value := poker.NewHand(five).Value
+						if seen, ok := values[want.key()]; !ok {
+							values[want.key()] = value
+							classes = append(classes, want)
+						} else if seen != value {
+							t.Fatalf("%v is valued %d, but other %v %v hands %d", five, value, want.rank, want.kickers, seen)
+						}
+					}
+				}
+			}
+		}
+	}
+	require.Len(t, classes, 7462, "there are 7,462 distinct five-card hands")
+
+	sort.Slice(classes, func(i, j int) bool { return classes[i].compare(classes[j]) < 0 })
+	for i := 1; i < len(classes); i++ {
+		lower, higher := classes[i-1], classes[i]
+		require.Less(t, values[lower.key()], values[higher.key()],
+			"%v %v should be valued below %v %v", lower.rank, lower.kickers, higher.rank, higher.kickers)
+	}
+}
+
+func TestCompareHandsMatchesReference(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	deck := poker.NewDeck().Cards
+	for i := 0; i < 50000; i++ {
+		rng.Shuffle(len(deck), func(i, j int) { deck[i], deck[j] = deck[j], deck[i] })
+
+		first, second := deck[:5], deck[5:10]
+		want := referenceFive(first).compare(referenceFive(second))
+		require.Equal(t, want, poker.CompareHands(poker.NewHand(first), poker.NewHand(second)), "%v against %v", first, second)
+
+		first, second = deck[:7], deck[7:14]
+		want = referenceBest(first).compare(referenceBest(second))
+		require.Equal(t, want, poker.CompareHands(poker.GetBestHand(first), poker.GetBestHand(second)), "%v against %v", first, second)
+	}
+}
+
+// Values used to be summed from decimal multipliers, and five kickers at
+// ×100 apiece overflowed into the hand rank: a high card or flush with a
+// high enough top card outranked every hand above it
+func TestHandValueRegressions(t *testing.T) {
+	tests := []struct {
+		name     string
+		stronger string
+		weaker   string
+	}{
+		{"a royal flush beats ace high", "Ah Kh Qh Jh Th", "As Kd Qc Js 9h"},
+		{"a pair beats ace high", "2s 2d 3c 4s 5h", "As Kd Qc Js 9h"},
+		{"a seven high flush beats king high", "7c 5c 4c 3c 2c", "Ks Qd Jc 9s 8h"},
+		{"a full house beats an ace high flush", "2s 2d 2c 3s 3h", "Ah Kh Qh Jh 9h"},
+		{"four of a kind beats an ace high flush", "2s 2d 2c 2h 3h", "As Ks Qs Js 9s"},
+		{"a straight flush beats an ace high flush", "6d 5d 4d 3d 2d", "Ah Kh Qh Jh 9h"},
+		{"the last kicker of a flush counts", "Ah Kh Qh Jh 9h", "As Ks Qs Js 8s"},
+		{"the last kicker of a high card counts", "As Kd Qc Js 9h", "Ad Kc Qs Jh 8d"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stronger, weaker := poker.NewHand(cards(t, tt.stronger)), poker.NewHand(cards(t, tt.weaker))
+			assert.Equal(t, 1, poker.CompareHands(stronger, weaker))
+			assert.Equal(t, -1, poker.CompareHands(weaker, stronger))
+			assert.Greater(t, stronger.Value, weaker.Value)
+		})
+	}
+}
@@ -0,0 +1,545 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/internal/game"
+)
+
+// slowBank takes as long as a database round trip to take each buy-in and
+// pay each stack back
+type slowBank struct{}
+
+func (slowBank) BuyIn(ctx context.Context, recordID string, seat game.SeatState) error {
+	time.Sleep(200 * time.Microsecond)
+	return nil
+}
+
+func (slowBank) CashOut(playerID string, amount int64) {
+	time.Sleep(200 * time.Microsecond)
+}
+
+// stuckBank never finishes paying a stack back until it is released
+type stuckBank struct {
+	cashingOut chan string
+	release    chan struct{}
+}
+
+func (b *stuckBank) BuyIn(ctx context.Context, recordID string, seat game.SeatState) error {
+	return nil
+}
+
+func (b *stuckBank) CashOut(playerID string, amount int64) {
+	b.cashingOut <- playerID
+	<-b.release
+}
+
+// ledger is a bank that keeps each player's balance
+type ledger struct {
+	mu       sync.Mutex
+	balances map[string]int64
+}
+
+func (b *ledger) BuyIn(ctx context.Context, recordID string, seat game.SeatState) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.balances[seat.PlayerID] < seat.BuyIn {
+		return game.ErrInsufficientChips
+	}
+	b.balances[seat.PlayerID] -= seat.BuyIn
+	return nil
+}
+
+func (b *ledger) CashOut(playerID string, amount int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.balances[playerID] += amount
+}
+
+func (b *ledger) balance(playerID string) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.balances[playerID]
+}
+
+// closeNow closes a table without waiting for the hand being played there,
+// which is voided
+func closeNow(m *game.Manager, gameID string) (map[string]int64, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return m.CloseGame(ctx, gameID)
+}
+
+// waitingTables never deal, so players can come and go freely
+func waitingTables(config *game.GameConfig) {
+	config.MaxPlayersPerTable = 6
+	config.MinPlayersPerTable = 7
+}
+
+func TestManagerConcurrentJoins(t *testing.T) {
+	ctx := context.Background()
+	m := game.NewManager()
+	m.SetBank(slowBank{})
+	for i := 0; i < 5; i++ {
+		_, err := m.CreateGame(fmt.Sprintf("table-%d", i), "Test Game", waitingTables)
+		require.NoError(t, err)
+	}
+
+	// More players than seats join one table at once
+	var wg sync.WaitGroup
+	var seated, full atomic.Int32
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := m.JoinGame(ctx, "table-0", fmt.Sprintf("player%d", i), "Player", 10000)
+			switch {
+			case err == nil:
+				seated.Add(1)
+			case assert.ErrorIs(t, err, game.ErrGameFull):
+				full.Add(1)
+			}
+			m.ListGames()
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, int32(6), seated.Load())
+	assert.Equal(t, int32(14), full.Load())
+
+	g, err := m.GetGame("table-0")
+	require.NoError(t, err)
+	seats := make(map[int]bool)
+	for _, player := range g.GetGameState("").Players {
+		assert.False(t, seats[player.SeatPosition], "seat %d is taken twice", player.SeatPosition)
+		seats[player.SeatPosition] = true
+	}
+	assert.Len(t, seats, 6)
+
+	// One player joins every other table at once, past their limit
+	var joined atomic.Int32
+	for i := 1; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := m.JoinGame(ctx, fmt.Sprintf("table-%d", i), "grinder", "Grinder", 10000)
+			if err == nil {
+				joined.Add(1)
+			} else {
+				assert.ErrorIs(t, err, game.ErrTooManyTables)
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, int32(3), joined.Load())
+	assert.Len(t, m.GetPlayerGames("grinder"), 3)
+
+	for _, info := range m.ListGames() {
+		if info.ID == "table-0" {
+			assert.Equal(t, 6, info.PlayerCount, "the lobby shows joins at once")
+		}
+	}
+}
+
+func TestManagerLobbyOrder(t *testing.T) {
+	m := game.NewManager()
+	stakes := func(small, big int64) game.GameOption {
+		return func(config *game.GameConfig) {
+			waitingTables(config)
+			config.SmallBlind = small
+			config.BigBlind = big
+		}
+	}
+	for _, table := range []struct {
+		id         string
+		small, big int64
+	}{
+		{"high", 50, 100},
+		{"micro-b", 1, 2},
+		{"low", 5, 10},
+		{"micro-a", 1, 2},
+		{"straddle", 10, 10},
+	} {
+		_, err := m.CreateGame(table.id, "Test Game", stakes(table.small, table.big))
+		require.NoError(t, err)
+	}
+
+	ids := func() []string {
+		var ids []string
+		for _, info := range m.ListGames() {
+			ids = append(ids, info.ID)
+		}
+		return ids
+	}
+	want := []string{"micro-b", "micro-a", "low", "straddle", "high"}
+	assert.Equal(t, want, ids(), "lowest stakes first, then the oldest")
+	for i := 0; i < 10; i++ {
+		require.Equal(t, want, ids(), "the order is the same on every call")
+	}
+
+	require.NoError(t, m.JoinGame(context.Background(), "low", "alice", "Alice", 10000))
+	for _, info := range m.ListGames() {
+		if info.ID == "low" {
+			assert.Equal(t, 1, info.PlayerCount, "the lobby shows a join at once")
+		}
+	}
+	assert.Equal(t, want, ids())
+
+	_, err := m.CloseGame(context.Background(), "micro-b")
+	require.NoError(t, err)
+	assert.Equal(t, want[1:], ids())
+}
+
+// seatedAt returns the tables of those given a player is seated at
+func seatedAt(playerID string, tables ...*game.Game) []string {
+	var seated []string
+	for _, table := range tables {
+		for _, player := range table.GetGameState("").Players {
+			if player.ID == playerID {
+				seated = append(seated, table.ID)
+			}
+		}
+	}
+	return seated
+}
+
+func TestEliminatedPlayerKeepsTheirSeatUntilTheyLeave(t *testing.T) {
+	ctx := context.Background()
+	m := game.NewManager()
+	var tables []*game.Game
+	for i := 0; i < 3; i++ {
+		table, err := m.CreateGame(fmt.Sprintf("waiting-%d", i), "Test Game", waitingTables)
+		require.NoError(t, err)
+		tables = append(tables, table)
+	}
+	require.NoError(t, m.JoinGame(ctx, "waiting-0", "short", "Short", 2000))
+	require.NoError(t, m.JoinGame(ctx, "waiting-1", "short", "Short", 2000))
+
+	// The short stack goes all in at their third table and is called down,
+	// until a hand isn't split
+	var g *game.Game
+	for i := 0; ; i++ {
+		require.Less(t, i, 20, "every hand was split")
+		gameID := fmt.Sprintf("game%d", i)
+		var err error
+		g, err = m.CreateGame(gameID, "Test Game")
+		require.NoError(t, err)
+		require.NoError(t, m.JoinGame(ctx, gameID, "short", "Short", 2000))
+		require.NoError(t, m.JoinGame(ctx, gameID, "deep", "Deep", 10000))
+		require.ErrorIs(t, m.JoinGame(ctx, "waiting-2", "short", "Short", 2000), game.ErrTooManyTables)
+
+		for j := 0; j < 10; j++ {
+			state := g.GetGameState("")
+			if state.Phase != game.PreFlop && state.Phase != game.Flop && state.Phase != game.Turn && state.Phase != game.River {
+				break
+			}
+			switch {
+			case state.CurrentPlayer == "short":
+				require.NoError(t, m.ProcessAction(ctx, gameID, "short", game.AllIn, 0))
+			case m.ProcessAction(ctx, gameID, "deep", game.Check, 0) != nil:
+				require.NoError(t, m.ProcessAction(ctx, gameID, "deep", game.Call, 0))
+			}
+		}
+		var short int64
+		for _, player := range g.GetGameState("").Players {
+			if player.ID == "short" {
+				short = player.ChipCount
+			}
+		}
+		if short == 0 {
+			break
+		}
+		_, err = m.CloseGame(ctx, gameID)
+		require.NoError(t, err)
+	}
+
+	// They are still at the table with nothing in front of them, so it
+	// counts against their limit until they leave it
+	tables = append(tables, g)
+	assert.Equal(t, []string{"waiting-0", "waiting-1", g.ID}, m.GetPlayerGames("short"))
+	assert.Equal(t, seatedAt("short", tables...), m.GetPlayerGames("short"))
+	require.ErrorIs(t, m.JoinGame(ctx, "waiting-2", "short", "Short", 2000), game.ErrTooManyTables)
+
+	require.NoError(t, m.LeaveGame(ctx, g.ID, "short"))
+	assert.Equal(t, []string{"waiting-0", "waiting-1"}, m.GetPlayerGames("short"))
+	assert.Equal(t, seatedAt("short", tables...), m.GetPlayerGames("short"))
+	require.NoError(t, m.JoinGame(ctx, "waiting-2", "short", "Short", 2000))
+
+	var seats []string
+	for _, table := range m.PlayerTables("short") {
+		seats = append(seats, table.ID)
+	}
+	assert.Equal(t, []string{"waiting-0", "waiting-1", "waiting-2"}, seats)
+}
+
+func TestKickedPlayerKeepsTheTableUntilTheirSeatIsFreed(t *testing.T) {
+	ctx := context.Background()
+	m := game.NewManager()
+	g, err := m.CreateGame("game1", "Test Game")
+	require.NoError(t, err)
+	require.NoError(t, m.JoinGame(ctx, "game1", "alice", "Alice", 10000))
+	require.NoError(t, m.JoinGame(ctx, "game1", "bob", "Bob", 10000))
+	require.NoError(t, m.JoinGame(ctx, "game1", "carol", "Carol", 10000))
+
+	// Kicked in the middle of a hand, Carol keeps her seat until it ends
+	_, err = m.KickPlayer("game1", "carol")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"game1"}, m.GetPlayerGames("carol"))
+	assert.Equal(t, seatedAt("carol", g), m.GetPlayerGames("carol"))
+
+	state := g.GetGameState("")
+	require.NoError(t, m.ProcessAction(ctx, "game1", state.CurrentPlayer, game.Fold, 0))
+	require.Eventually(t, func() bool {
+		return len(m.GetPlayerGames("carol")) == 0
+	}, time.Second, 5*time.Millisecond)
+	assert.Empty(t, seatedAt("carol", g))
+	assert.Equal(t, seatedAt("alice", g), m.GetPlayerGames("alice"))
+	assert.Equal(t, seatedAt("bob", g), m.GetPlayerGames("bob"))
+}
+
+func TestLeavingCashesOutAndFreesTheSeat(t *testing.T) {
+	ctx := context.Background()
+	m := game.NewManager()
+	bank := &ledger{balances: map[string]int64{"alice": 20000, "bob": 20000}}
+	m.SetBank(bank)
+	g, err := m.CreateGame("game1", "Test Game")
+	require.NoError(t, err)
+	require.NoError(t, m.JoinGame(ctx, "game1", "alice", "Alice", 10000))
+	require.NoError(t, m.JoinGame(ctx, "game1", "bob", "Bob", 10000))
+	others := map[string]string{"alice": "bob", "bob": "alice"}
+
+	// Leaving on their turn, a player folds the hand and is cashed out with
+	// what they have left, their seat freed as the hand ends
+	leaver := g.GetGameState("").CurrentPlayer
+	stack := stackOf(g, leaver)
+	require.NoError(t, m.LeaveGame(ctx, "game1", leaver))
+	assert.Equal(t, 10000+stack, bank.balance(leaver))
+	assert.Empty(t, m.GetPlayerGames(leaver))
+	assert.Len(t, g.GetGameState("").Players, 1)
+
+	// So they can sit down again
+	require.NoError(t, m.JoinGame(ctx, "game1", leaver, "Leaver", 5000))
+	assert.Equal(t, 5000+stack, bank.balance(leaver))
+	assert.Equal(t, []string{"game1"}, m.GetPlayerGames(leaver))
+	require.NoError(t, m.ForceStartGame("game1"))
+
+	// A player leaving before their turn plays the hand out from there, and
+	// is cashed out with what they win once it is paid
+	state := g.GetGameState("")
+	acting := state.CurrentPlayer
+	leaver = others[acting]
+	balance, stack := bank.balance(leaver), stackOf(g, leaver)
+	require.NoError(t, m.LeaveGame(ctx, "game1", leaver))
+	assert.Equal(t, balance, bank.balance(leaver), "nobody is cashed out in the middle of a hand")
+	assert.Equal(t, []string{"game1"}, m.GetPlayerGames(leaver))
+	require.NoError(t, m.ProcessAction(ctx, "game1", acting, game.Fold, 0))
+	require.Eventually(t, func() bool {
+		return bank.balance(leaver) == balance+stack+state.Pot
+	}, time.Second, 5*time.Millisecond)
+	require.Eventually(t, func() bool {
+		return len(m.GetPlayerGames(leaver)) == 0
+	}, time.Second, 5*time.Millisecond)
+	assert.Len(t, g.GetGameState("").Players, 1)
+
+	// The last player to leave closes the table, and every chip is back
+	require.NoError(t, m.LeaveGame(ctx, "game1", acting))
+	_, err = m.GetGame("game1")
+	assert.ErrorIs(t, err, game.ErrGameNotFound)
+	assert.Equal(t, int64(40000), bank.balance("alice")+bank.balance("bob"))
+	assert.Empty(t, m.GetPlayerGames(acting))
+}
+
+func TestCloseGameWaitsForTheHandToBePaid(t *testing.T) {
+	ctx := context.Background()
+	m := game.NewManager()
+	g, err := m.CreateGame("game1", "Test Game")
+	require.NoError(t, err)
+	require.NoError(t, m.JoinGame(ctx, "game1", "alice", "Alice", 10000))
+	require.NoError(t, m.JoinGame(ctx, "game1", "bob", "Bob", 10000))
+	folder := g.GetGameState("").CurrentPlayer
+	winner := map[string]string{"alice": "bob", "bob": "alice"}[folder]
+	blind := 10000 - stackOf(g, folder)
+
+	// The table stays open while the hand is played out
+	type closed struct {
+		stacks map[string]int64
+		err    error
+	}
+	done := make(chan closed, 1)
+	go func() {
+		closeCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		stacks, err := m.CloseGame(closeCtx, "game1")
+		done <- closed{stacks, err}
+	}()
+	select {
+	case <-done:
+		t.Fatal("the table closed in the middle of a hand")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Once it is paid the winner is cashed out with the pot
+	require.NoError(t, m.ProcessAction(ctx, "game1", folder, game.Fold, 0))
+	result := <-done
+	require.NoError(t, result.err)
+	assert.Equal(t, map[string]int64{folder: 10000 - blind, winner: 10000 + blind}, result.stacks)
+	assert.Equal(t, 1, g.GetGameState("").HandNumber, "no hand is dealt while it closes")
+	_, err = m.GetGame("game1")
+	assert.ErrorIs(t, err, game.ErrGameNotFound)
+
+	// A hand still going when the wait runs out is voided
+	g, err = m.CreateGame("game2", "Test Game")
+	require.NoError(t, err)
+	require.NoError(t, m.JoinGame(ctx, "game2", "alice", "Alice", 10000))
+	require.NoError(t, m.JoinGame(ctx, "game2", "bob", "Bob", 10000))
+	closeCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	stacks, err := m.CloseGame(closeCtx, "game2")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"alice": 10000, "bob": 10000}, stacks)
+}
+
+func TestMovePlayer(t *testing.T) {
+	ctx := context.Background()
+	m := game.NewManager()
+	from, err := m.CreateGame("from", "From")
+	require.NoError(t, err)
+	to, err := m.CreateGame("to", "To", func(config *game.GameConfig) {
+		config.MaxPlayersPerTable = 2
+		config.MinPlayersPerTable = 3
+	})
+	require.NoError(t, err)
+	_, err = m.CreateGame("small", "Small", game.WithBuyIn(2000, 1000, 5000))
+	require.NoError(t, err)
+	require.NoError(t, m.JoinGame(ctx, "from", "alice", "Alice", 10000))
+	require.NoError(t, m.JoinGame(ctx, "from", "bob", "Bob", 10000))
+	require.NoError(t, m.JoinGame(ctx, "to", "carol", "Carol", 10000))
+
+	_, err = m.MovePlayer("from", "to", "alice")
+	assert.ErrorIs(t, err, game.ErrHandInProgress)
+
+	// Once the hand is over the player moves with the stack they won or lost
+	require.NoError(t, m.ProcessAction(ctx, "from", from.GetGameState("").CurrentPlayer, game.Fold, 0))
+	var stack int64
+	for _, player := range from.GetGameState("").Players {
+		if player.ID == "alice" {
+			stack = player.ChipCount
+		}
+	}
+	seat, err := m.MovePlayer("from", "to", "alice")
+	require.NoError(t, err)
+	assert.Equal(t, 1, seat)
+
+	state := to.GetGameState("alice")
+	require.Len(t, state.Players, 2)
+	for _, player := range state.Players {
+		if player.ID == "alice" {
+			assert.Equal(t, stack, player.ChipCount)
+			assert.Equal(t, 1, player.SeatPosition)
+		}
+	}
+	require.Len(t, from.GetGameState("").Players, 1)
+	assert.Equal(t, "bob", from.GetGameState("").Players[0].ID)
+	assert.Equal(t, []string{"to"}, m.GetPlayerGames("alice"))
+
+	_, err = m.MovePlayer("from", "to", "bob")
+	assert.ErrorIs(t, err, game.ErrGameFull)
+	_, err = m.MovePlayer("from", "small", "bob")
+	assert.ErrorIs(t, err, game.ErrStackOutOfRange)
+	_, err = m.MovePlayer("to", "to", "alice")
+	assert.ErrorIs(t, err, game.ErrPlayerAlreadyInGame)
+	_, err = m.MovePlayer("from", "small", "alice")
+	assert.ErrorIs(t, err, game.ErrPlayerNotInGame)
+	_, err = m.MovePlayer("from", "closed", "bob")
+	assert.ErrorIs(t, err, game.ErrGameNotFound)
+	assert.Equal(t, []string{"from"}, m.GetPlayerGames("bob"), "a refused move leaves the player where they were")
+}
+
+func TestManagerCashesOutUnlocked(t *testing.T) {
+	ctx := context.Background()
+	m := game.NewManager()
+	bank := &stuckBank{cashingOut: make(chan string), release: make(chan struct{})}
+	m.SetBank(bank)
+	for _, gameID := range []string{"game1", "game2"} {
+		_, err := m.CreateGame(gameID, "Test Game", waitingTables)
+		require.NoError(t, err)
+	}
+	require.NoError(t, m.JoinGame(ctx, "game1", "alice", "Alice", 10000))
+	require.NoError(t, m.JoinGame(ctx, "game1", "bob", "Bob", 10000))
+
+	kicked := make(chan int64)
+	go func() {
+		stack, _ := m.KickPlayer("game1", "alice")
+		kicked <- stack
+	}()
+	closed := make(chan map[string]int64)
+	assert.Equal(t, "alice", <-bank.cashingOut)
+	go func() {
+		stacks, _ := m.CloseGame(ctx, "game1")
+		closed <- stacks
+	}()
+	assert.Equal(t, "bob", <-bank.cashingOut)
+
+	// With both cash-outs stuck at the bank, the other tables carry on
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.Len(t, m.ListGames(), 1)
+		assert.NoError(t, m.JoinGame(ctx, "game2", "carol", "Carol", 10000))
+		_, err := m.CreateGame("game3", "Test Game")
+		assert.NoError(t, err)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the manager stayed locked while the bank paid out")
+	}
+
+	close(bank.release)
+	assert.Equal(t, int64(10000), <-kicked)
+	assert.Equal(t, map[string]int64{"bob": 10000}, <-closed)
+}
+
+// BenchmarkManagerJoinAndList has players joining and leaving 200 tables
+// at once, with buy-ins and cash-outs as slow as a database, while the
+// lobby is listed
+func BenchmarkManagerJoinAndList(b *testing.B) {
+	ctx := context.Background()
+	m := game.NewManager()
+	m.SetBank(slowBank{})
+	const tables = 200
+	for i := 0; i < tables; i++ {
+		if _, err := m.CreateGame(fmt.Sprintf("table-%d", i), "Bench Game", waitingTables); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	var players atomic.Int64
+	b.SetParallelism(8)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			n := players.Add(1)
+			if n%10 == 0 {
+				m.ListGames()
+				continue
+			}
+
+			gameID := fmt.Sprintf("table-%d", n%tables)
+			playerID := fmt.Sprintf("player%d", n)
+			if err := m.JoinGame(ctx, gameID, playerID, "Player", 10000); err != nil {
+				continue
+			}
+			if _, err := m.KickPlayer(gameID, playerID); err != nil {
+				b.Error(err)
+			}
+		}
+	})
+}
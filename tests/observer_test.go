@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/internal/game"
+)
+
+// recordingObserver keeps what it is told, reading the table back on each
+// state change as the hub does
+type recordingObserver struct {
+	manager *game.Manager
+
+	mu      sync.Mutex
+	events  []string
+	results []game.CompletedHand
+}
+
+func (r *recordingObserver) record(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recordingObserver) OnStateChanged(gameID string) {
+	if _, err := r.manager.GetGameState(gameID, ""); err != nil {
+		r.record("state gone")
+		return
+	}
+	r.record("state")
+}
+
+func (r *recordingObserver) OnHandStarted(gameID string, handNumber int) {
+	r.record(fmt.Sprintf("hand %d started", handNumber))
+}
+
+func (r *recordingObserver) OnHandResult(hand game.CompletedHand) {
+	r.mu.Lock()
+	r.results = append(r.results, hand)
+	r.mu.Unlock()
+	r.record(fmt.Sprintf("hand %d result", hand.HandNumber))
+}
+
+func (r *recordingObserver) OnPlayerEliminated(gameID string, player game.HandPlayer) {
+	r.record("eliminated " + player.ID)
+}
+
+func (r *recordingObserver) OnPhaseChanged(gameID string, phase game.GamePhase) {
+	r.record("phase " + phase.String())
+}
+
+// seen returns what the observer has been told so far
+func (r *recordingObserver) seen() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+// panickingObserver fails on everything it is told
+type panickingObserver struct {
+	game.NopObserver
+}
+
+func (panickingObserver) OnStateChanged(gameID string) {
+	panic("observer failed")
+}
+
+func TestObserverToldOfEngineDrivenHands(t *testing.T) {
+	ctx := context.Background()
+	m := game.NewManager()
+	m.AddObserver(panickingObserver{})
+	observer := &recordingObserver{manager: m}
+	m.AddObserver(observer)
+
+	g, err := m.CreateGame("game1", "Test Game", func(config *game.GameConfig) {
+		config.HandDelay = 20 * time.Millisecond
+	})
+	require.NoError(t, err)
+	require.NoError(t, m.JoinGame(ctx, "game1", "player1", "Alice", 10000))
+	require.NoError(t, m.JoinGame(ctx, "game1", "player2", "Bob", 10000))
+
+	// Check the hand down to the showdown
+	state := g.GetGameState("")
+	for i := 0; i < 10 && state.Phase != game.Showdown; i++ {
+		if m.ProcessAction(ctx, "game1", state.CurrentPlayer, game.Check, 0) != nil {
+			require.NoError(t, m.ProcessAction(ctx, "game1", state.CurrentPlayer, game.Call, 0))
+		}
+		state = g.GetGameState("")
+	}
+	require.Equal(t, game.Showdown, state.Phase)
+
+	// Nobody acts again: the next hand is dealt by the table's timer
+	require.Eventually(t, func() bool {
+		events := observer.seen()
+		return len(events) > 0 && events[len(events)-1] == "state" && len(only(events, "hand 2 started")) == 1
+	}, time.Second, 5*time.Millisecond)
+
+	events := observer.seen()
+	assert.Equal(t, []string{"state", "phase Pre-Flop", "hand 1 started", "state"}, events[:4])
+	assert.Equal(t, []string{
+		"phase Flop", "phase Turn", "phase River", "phase Showdown", "hand 1 result",
+	}, only(events, "phase Flop", "phase Turn", "phase River", "phase Showdown", "hand 1 result"))
+	assert.Equal(t, []string{
+		"phase Pre-Flop", "hand 1 started", "hand 1 result", "phase Pre-Flop", "hand 2 started",
+	}, only(events, "phase Pre-Flop", "hand 1 started", "hand 1 result", "hand 2 started"),
+		"the timer's hand is reported without anyone acting")
+	assert.NotContains(t, events, "state gone")
+
+	observer.mu.Lock()
+	require.Len(t, observer.results, 1)
+	assert.Equal(t, "game1", observer.results[0].GameID)
+	assert.Len(t, observer.results[0].Players, 2)
+	observer.mu.Unlock()
+
+	// An observer added later is told from then on
+	late := &recordingObserver{manager: m}
+	m.AddObserver(late)
+	_, err = closeNow(m, "game1")
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return len(late.seen()) == 2
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"phase Game Over", "state gone"}, late.seen())
+}
+
+func TestObserverToldOfEliminations(t *testing.T) {
+	ctx := context.Background()
+	m := game.NewManager()
+	observer := &recordingObserver{manager: m}
+	m.AddObserver(observer)
+
+	// The short stack goes all in and is called down until a hand isn't split
+	for i := 0; i < 20; i++ {
+		gameID := fmt.Sprintf("game%d", i)
+		g, err := m.CreateGame(gameID, "Test Game")
+		require.NoError(t, err)
+		require.NoError(t, m.JoinGame(ctx, gameID, "short", "Short", 2000))
+		require.NoError(t, m.JoinGame(ctx, gameID, "deep", "Deep", 10000))
+
+		for j := 0; j < 10 && g.GetGameState("").HandNumber == 1; j++ {
+			state := g.GetGameState("")
+			if state.Phase == game.Showdown {
+				break
+			}
+			switch {
+			case state.CurrentPlayer == "short":
+				require.NoError(t, m.ProcessAction(ctx, gameID, "short", game.AllIn, 0))
+			case m.ProcessAction(ctx, gameID, "deep", game.Check, 0) != nil:
+				require.NoError(t, m.ProcessAction(ctx, gameID, "deep", game.Call, 0))
+			}
+		}
+		require.Eventually(t, func() bool {
+			observer.mu.Lock()
+			defer observer.mu.Unlock()
+			return len(observer.results) == i+1
+		}, time.Second, 5*time.Millisecond)
+
+		observer.mu.Lock()
+		hand := observer.results[i]
+		observer.mu.Unlock()
+		for _, player := range hand.Players {
+			if player.EndingChips == 0 {
+				require.Eventually(t, func() bool {
+					return len(only(observer.seen(), "eliminated "+player.ID)) == 1
+				}, time.Second, 5*time.Millisecond)
+				assert.Equal(t, "short", player.ID, "only the short stack can lose everything")
+				return
+			}
+		}
+		_, err = m.CloseGame(ctx, gameID)
+		require.NoError(t, err)
+	}
+	t.Fatal("every hand was split")
+}
+
+// only keeps the events that are one of want, in order
+func only(events []string, want ...string) []string {
+	var kept []string
+	for _, event := range events {
+		for _, w := range want {
+			if event == w {
+				kept = append(kept, event)
+			}
+		}
+	}
+	return kept
+}
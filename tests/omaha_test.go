@@ -0,0 +1,213 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/pkg/poker"
+)
+
+func TestEvaluateOmaha(t *testing.T) {
+	c := poker.NewCard
+	tests := []struct {
+		name    string
+		hole    [4]poker.Card
+		board   [5]poker.Card
+		rank    poker.HandRank
+		kickers []poker.Rank
+	}{
+		{
+			"one heart does not make the board's flush",
+			[4]poker.Card{c(poker.Nine, poker.Hearts), c(poker.Nine, poker.Spades), c(poker.Five, poker.Diamonds), c(poker.Four, poker.Clubs)},
+			[5]poker.Card{c(poker.Ace, poker.Hearts), c(poker.King, poker.Hearts), c(poker.Queen, poker.Hearts), c(poker.Seven, poker.Hearts), c(poker.Two, poker.Clubs)},
+			poker.OnePair, []poker.Rank{poker.Nine, poker.Ace, poker.King, poker.Queen},
+		},
+		{
+			"two hearts do",
+			[4]poker.Card{c(poker.Nine, poker.Hearts), c(poker.Three, poker.Hearts), c(poker.Five, poker.Diamonds), c(poker.Four, poker.Clubs)},
+			[5]poker.Card{c(poker.Ace, poker.Hearts), c(poker.King, poker.Hearts), c(poker.Queen, poker.Hearts), c(poker.Seven, poker.Hearts), c(poker.Two, poker.Clubs)},
+			poker.Flush, []poker.Rank{poker.Ace, poker.King, poker.Queen, poker.Nine, poker.Three},
+		},
+		{
+			"quads on the board only fill up a pocket pair",
+			[4]poker.Card{c(poker.Ace, poker.Clubs), c(poker.Ace, poker.Diamonds), c(poker.Two, poker.Clubs), c(poker.Three, poker.Hearts)},
+			[5]poker.Card{c(poker.Seven, poker.Clubs), c(poker.Seven, poker.Diamonds), c(poker.Seven, poker.Hearts), c(poker.Seven, poker.Spades), c(poker.King, poker.Diamonds)},
+			poker.FullHouse, []poker.Rank{poker.Seven, poker.Ace},
+		},
+		{
+			"a straight on the board does not play",
+			[4]poker.Card{c(poker.Ace, poker.Clubs), c(poker.Ace, poker.Diamonds), c(poker.King, poker.Clubs), c(poker.King, poker.Hearts)},
+			[5]poker.Card{c(poker.Five, poker.Clubs), c(poker.Six, poker.Diamonds), c(poker.Seven, poker.Hearts), c(poker.Eight, poker.Spades), c(poker.Nine, poker.Diamonds)},
+			poker.OnePair, []poker.Rank{poker.Ace, poker.Nine, poker.Eight, poker.Seven},
+		},
+		{
+			"four suited hole cards still play only two",
+			[4]poker.Card{c(poker.Ace, poker.Spades), c(poker.King, poker.Spades), c(poker.Queen, poker.Spades), c(poker.Jack, poker.Spades)},
+			[5]poker.Card{c(poker.Ten, poker.Spades), c(poker.Two, poker.Hearts), c(poker.Three, poker.Diamonds), c(poker.Four, poker.Clubs), c(poker.Nine, poker.Hearts)},
+			poker.HighCard, []poker.Rank{poker.Ace, poker.King, poker.Ten, poker.Nine, poker.Four},
+		},
+		{
+			"a set from a pocket pair",
+			[4]poker.Card{c(poker.Jack, poker.Spades), c(poker.Jack, poker.Hearts), c(poker.Four, poker.Spades), c(poker.Five, poker.Spades)},
+			[5]poker.Card{c(poker.Jack, poker.Clubs), c(poker.Eight, poker.Hearts), c(poker.Three, poker.Diamonds), c(poker.Two, poker.Clubs), c(poker.King, poker.Hearts)},
+			poker.ThreeOfAKind, []poker.Rank{poker.Jack, poker.King, poker.Eight},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hand := poker.EvaluateOmaha(tt.hole, tt.board)
+			require.Len(t, hand.Cards, 5)
+			assert.Equal(t, tt.rank, hand.Rank)
+			assert.Equal(t, tt.kickers, hand.Kickers)
+
+			hole := 0
+			for _, card := range hand.Cards {
+				if containsCard(tt.hole[:], card) {
+					hole++
+				}
+			}
+			assert.Equal(t, 2, hole, "exactly two hole cards play")
+		})
+	}
+}
+
+func TestEvaluateOmahaHiLo(t *testing.T) {
+	c := poker.NewCard
+
+	// The low and the high can use different hole cards
+	high, low := poker.EvaluateOmahaHiLo(
+		[4]poker.Card{c(poker.Ace, poker.Clubs), c(poker.Two, poker.Diamonds), c(poker.King, poker.Hearts), c(poker.King, poker.Spades)},
+		[5]poker.Card{c(poker.King, poker.Clubs), c(poker.Three, poker.Hearts), c(poker.Four, poker.Spades), c(poker.Queen, poker.Diamonds), c(poker.Eight, poker.Clubs)},
+	)
+	assert.Equal(t, poker.ThreeOfAKind, high.Rank)
+	require.NotNil(t, low)
+	assert.Equal(t, []int{8, 4, 3, 2, 1}, low.Ranks)
+	assert.Len(t, low.Cards, 5)
+
+	// The wheel is the best low and a straight for high
+	high, low = poker.EvaluateOmahaHiLo(
+		[4]poker.Card{c(poker.Ace, poker.Clubs), c(poker.Two, poker.Diamonds), c(poker.King, poker.Hearts), c(poker.King, poker.Spades)},
+		[5]poker.Card{c(poker.Three, poker.Clubs), c(poker.Four, poker.Hearts), c(poker.Five, poker.Spades), c(poker.Nine, poker.Diamonds), c(poker.Queen, poker.Clubs)},
+	)
+	assert.Equal(t, poker.Straight, high.Rank)
+	assert.Equal(t, []poker.Rank{poker.Five}, high.Kickers)
+	require.NotNil(t, low)
+	assert.Equal(t, []int{5, 4, 3, 2, 1}, low.Ranks)
+
+	// Two low cards on the board are not enough, however low the hole cards
+	_, low = poker.EvaluateOmahaHiLo(
+		[4]poker.Card{c(poker.Ace, poker.Clubs), c(poker.Four, poker.Diamonds), c(poker.Five, poker.Hearts), c(poker.Six, poker.Spades)},
+		[5]poker.Card{c(poker.Two, poker.Clubs), c(poker.Three, poker.Hearts), c(poker.King, poker.Spades), c(poker.Queen, poker.Diamonds), c(poker.Jack, poker.Clubs)},
+	)
+	assert.Nil(t, low)
+
+	// A hole card pairing the board does not count twice
+	_, low = poker.EvaluateOmahaHiLo(
+		[4]poker.Card{c(poker.Ace, poker.Clubs), c(poker.Three, poker.Diamonds), c(poker.King, poker.Hearts), c(poker.King, poker.Spades)},
+		[5]poker.Card{c(poker.Ace, poker.Hearts), c(poker.Three, poker.Hearts), c(poker.Seven, poker.Spades), c(poker.Queen, poker.Diamonds), c(poker.Jack, poker.Clubs)},
+	)
+	assert.Nil(t, low)
+}
+
+func TestCompareLowHands(t *testing.T) {
+	c := poker.NewCard
+	board := [5]poker.Card{c(poker.Three, poker.Clubs), c(poker.Four, poker.Hearts), c(poker.Seven, poker.Spades), c(poker.King, poker.Diamonds), c(poker.Queen, poker.Clubs)}
+	lowest := func(hole [4]poker.Card) *poker.LowHand {
+		_, low := poker.EvaluateOmahaHiLo(hole, board)
+		require.NotNil(t, low)
+		return low
+	}
+
+	sevenFive := lowest([4]poker.Card{c(poker.Two, poker.Clubs), c(poker.Five, poker.Diamonds), c(poker.King, poker.Hearts), c(poker.King, poker.Spades)})
+	sevenSix := lowest([4]poker.Card{c(poker.Ace, poker.Clubs), c(poker.Six, poker.Diamonds), c(poker.King, poker.Hearts), c(poker.Queen, poker.Spades)})
+	alsoSevenFive := lowest([4]poker.Card{c(poker.Two, poker.Hearts), c(poker.Five, poker.Spades), c(poker.Jack, poker.Hearts), c(poker.Ten, poker.Spades)})
+
+	assert.Equal(t, []int{7, 5, 4, 3, 2}, sevenFive.Ranks)
+	assert.Equal(t, []int{7, 6, 4, 3, 1}, sevenSix.Ranks)
+	assert.Equal(t, 1, poker.CompareLowHands(sevenFive, sevenSix), "the second highest card decides")
+	assert.Equal(t, -1, poker.CompareLowHands(sevenSix, sevenFive))
+	assert.Equal(t, 0, poker.CompareLowHands(sevenFive, alsoSevenFive), "suits do not matter")
+	assert.Equal(t, 1, poker.CompareLowHands(sevenSix, nil))
+	assert.Equal(t, -1, poker.CompareLowHands(nil, sevenSix))
+	assert.Equal(t, 0, poker.CompareLowHands(nil, nil))
+}
+
+// omahaCards reads four hole cards and a five-card board
+func omahaCards(t *testing.T, hole, board string) ([4]poker.Card, [5]poker.Card) {
+	t.Helper()
+	var h [4]poker.Card
+	var b [5]poker.Card
+	require.Equal(t, 4, copy(h[:], cards(t, hole)))
+	require.Equal(t, 5, copy(b[:], cards(t, board)))
+	return h, b
+}
+
+func TestEvaluateOmahaLowTraps(t *testing.T) {
+	tests := []struct {
+		name  string
+		hole  string
+		board string
+		low   []int // nil for no qualifying low
+	}{
+		{"a deuce on the board counterfeits A-2", "Ac 2c Kd Ks", "2h 5d 7s Qc Jd", nil},
+		{"A-3 makes the low A-2 cannot", "Ad 3c Kh Qs", "2h 5d 7s Qc Jd", []int{7, 5, 3, 2, 1}},
+		{"the second pair of low cards plays when the first is counterfeited", "Ac 2c 3d 4d", "2h 5d 7s Qc Jd", []int{7, 5, 3, 2, 1}},
+		{"only two low cards on the board", "Ac 2c 3d 4d", "2h 5d Ks Qc Jd", nil},
+		{"a low board with high hole cards", "Kc Kd Qh Js", "2h 3d 4s 5c 6d", nil},
+		{"a nine is not low", "Ac 2c Kd Ks", "3h 5d 9s Qc Jd", nil},
+		{"a straight and a flush still make the wheel", "Ah 2h Kd Ks", "3h 4h 5h Qc Jd", []int{5, 4, 3, 2, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hole, board := omahaCards(t, tt.hole, tt.board)
+			low := poker.EvaluateOmahaLow(hole, board)
+			if tt.low == nil {
+				assert.Nil(t, low)
+				return
+			}
+			require.NotNil(t, low)
+			assert.Equal(t, tt.low, low.Ranks)
+		})
+	}
+}
+
+func TestSplitHiLoPot(t *testing.T) {
+	board := "2h 5d 7s Kc Kd"
+	hands := func(holes ...string) []poker.HiLoHand {
+		var hands []poker.HiLoHand
+		for _, hole := range holes {
+			h, b := omahaCards(t, hole, board)
+			high, low := poker.EvaluateOmahaHiLo(h, b)
+			hands = append(hands, poker.HiLoHand{High: high, Low: low})
+		}
+		return hands
+	}
+
+	tests := []struct {
+		name   string
+		pot    int
+		holes  []string
+		shares []int
+	}{
+		{"the high scoops without a low", 100, []string{"Ks Qs Jh Th", "Qd Qc 9h 9s"}, []int{100, 0}},
+		{"high and low split", 100, []string{"Ks Qs Jh Th", "Ac 3c Qh Js"}, []int{50, 50}},
+		{"the odd chip goes high", 101, []string{"Ks Qs Jh Th", "Ac 3c Qh Js"}, []int{51, 50}},
+		{"one player scoops both halves", 100, []string{"Ks 3s Ah Th", "Qd Qc 9h 9s"}, []int{100, 0}},
+		{"the high winner is quartered on the low", 100, []string{"Ks As 3h Th", "Ac 3c Qh Js", "Qd Qc 9h 9s"}, []int{75, 25, 0}},
+		{"quartered odd chips go to the first tied player", 102, []string{"Ks As 3h Th", "Ac 3c Qh Js"}, []int{77, 25}},
+		{"tied highs split their half", 100, []string{"Ks 9s Jh Th", "Kh 9d Jc Ts", "Ac 3c Qh Js"}, []int{25, 25, 50}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shares := poker.SplitHiLoPot(tt.pot, hands(tt.holes...))
+			assert.Equal(t, tt.shares, shares)
+			total := 0
+			for _, share := range shares {
+				total += share
+			}
+			assert.Equal(t, tt.pot, total, "every chip is awarded")
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/pkg/poker"
+)
+
+// drawOf returns the result's draw of the given type
+func drawOf(t *testing.T, result *poker.OutsResult, drawType poker.DrawType) poker.Draw {
+	t.Helper()
+	for _, draw := range result.Draws {
+		if draw.Type == drawType {
+			return draw
+		}
+	}
+	require.Failf(t, "missing draw", "no %s in %+v", drawType, result.Draws)
+	return poker.Draw{}
+}
+
+func TestOutsComboDraw(t *testing.T) {
+	hole, board := cards(t, "Jh Th"), cards(t, "9h 8c 2h")
+	result, err := poker.Outs(hole, board, nil)
+	require.NoError(t, err)
+	assert.Equal(t, poker.HighCard, result.Hand)
+	assert.True(t, result.Combo)
+
+	flush := drawOf(t, result, poker.FlushDraw)
+	assert.Equal(t, 9, flush.Count)
+	assert.Equal(t, cards(t, "8h"), flush.Tainted, "the eight of hearts pairs the board")
+
+	straight := drawOf(t, result, poker.OpenEndedDraw)
+	assert.Equal(t, 8, straight.Count, "the straight flush cards still count as straight outs")
+	assert.ElementsMatch(t, cards(t, "Qh Qd Qc Qs 7h 7d 7c 7s"), straight.Outs)
+
+	overcards := drawOf(t, result, poker.Overcards)
+	assert.ElementsMatch(t, cards(t, "Jd Jc Js Td Tc Ts"), overcards.Outs)
+	assert.Equal(t, 9+6+6, result.Count, "outs shared between draws count once")
+
+	outs, err := poker.OutsTo(hole, board, nil, poker.Straight)
+	require.NoError(t, err)
+	assert.Len(t, outs, 15, "the classic fifteen outs")
+
+	outs, err = poker.OutsTo(hole, board, cards(t, "Qs 7d Ah"), poker.Straight)
+	require.NoError(t, err)
+	assert.Len(t, outs, 12, "dead cards are not outs")
+}
+
+func TestOutsGutshotAgainstAFlushDraw(t *testing.T) {
+	result, err := poker.Outs(cards(t, "Kc Qd"), cards(t, "Jh 9h 3s"), nil)
+	require.NoError(t, err)
+	assert.False(t, result.Combo)
+
+	gutshot := drawOf(t, result, poker.Gutshot)
+	assert.ElementsMatch(t, cards(t, "Th Td Tc Ts"), gutshot.Outs)
+	assert.Equal(t, cards(t, "Th"), gutshot.Tainted, "the ten of hearts gives a flush draw its flush")
+	assert.ElementsMatch(t, cards(t, "Kh Kd Ks Qh Qc Qs"), drawOf(t, result, poker.Overcards).Outs)
+
+	// On the turn the same gutshot is one card from a flush for anyone
+	// holding two hearts
+	result, err = poker.Outs(cards(t, "Kc Qd"), cards(t, "Jh 9h 3s 2d"), nil)
+	require.NoError(t, err)
+	gutshot = drawOf(t, result, poker.Gutshot)
+	assert.Equal(t, 4, gutshot.Count)
+	assert.Equal(t, cards(t, "Th"), gutshot.Tainted)
+}
+
+func TestOutsCounterfeits(t *testing.T) {
+	// A four pairs the board: everyone has jacks and fours, and the ace
+	// only kicks
+	outs, err := poker.OutsTo(cards(t, "Ah Qd"), cards(t, "Jc Jd 4s"), nil, poker.TwoPair)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, cards(t, "Ad Ac As Qh Qc Qs"), outs)
+
+	// Two pair on the turn fills up only with the hole cards' ranks; a king
+	// or deuce pairs the board and counterfeits the eights
+	outs, err = poker.OutsTo(cards(t, "9h 8h"), cards(t, "9c 8d 2s Kc"), nil, poker.FullHouse)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, cards(t, "9d 9s 8c 8s"), outs)
+
+	result, err := poker.Outs(cards(t, "9h 8h"), cards(t, "9c 8d 2s Kc"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, poker.TwoPair, result.Hand)
+	assert.Empty(t, result.Draws, "no straight, flush or overcard draws")
+}
+
+func TestOutsNoDrawOnBoardOnlyHands(t *testing.T) {
+	// Four to a straight on the board: the eight makes the straight for
+	// everyone, so only the hole cards' ranks help
+	outs, err := poker.OutsTo(cards(t, "2c 2d"), cards(t, "Jh Ts 9d 7c"), nil, poker.Straight)
+	require.NoError(t, err)
+	assert.Empty(t, outs)
+}
+
+func TestOutsErrors(t *testing.T) {
+	_, err := poker.Outs(cards(t, "Ah Kh"), cards(t, "2c 3c 4c 5c 6c"), nil)
+	assert.ErrorContains(t, err, "flop or a turn")
+	_, err = poker.Outs(cards(t, "Ah"), cards(t, "2c 3c 4c"), nil)
+	assert.ErrorContains(t, err, "2 hole cards")
+	_, err = poker.Outs(cards(t, "Ah Kh"), cards(t, "2c 3c Ah"), nil)
+	assert.ErrorContains(t, err, "card Ah is given twice")
+	_, err = poker.OutsTo(cards(t, "Ah Kh"), cards(t, "2c 3c 4c"), cards(t, "2c"), poker.Flush)
+	assert.ErrorContains(t, err, "card 2c is given twice")
+}
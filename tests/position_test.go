@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/primoPoker/server/pkg/poker"
+)
+
+func TestMiddlePositions(t *testing.T) {
+	assert.Empty(t, poker.MiddlePositions(0))
+	assert.Equal(t, []poker.Position{poker.PositionUTG}, poker.MiddlePositions(1))
+	assert.Equal(t, []poker.Position{poker.PositionUTG, poker.PositionHJ, poker.PositionCO}, poker.MiddlePositions(3))
+	assert.Equal(t, []poker.Position{
+		poker.PositionUTG, poker.PositionUTG1, poker.PositionUTG2, poker.PositionMP,
+		poker.PositionLJ, poker.PositionHJ, poker.PositionCO,
+	}, poker.MiddlePositions(7))
+	assert.Len(t, poker.MiddlePositions(9), 7)
+}
+
+func TestPositionKinds(t *testing.T) {
+	assert.True(t, poker.PositionCO.IsStealPosition())
+	assert.True(t, poker.PositionSB.IsStealPosition())
+	assert.False(t, poker.PositionHJ.IsStealPosition())
+	assert.True(t, poker.PositionBB.IsBlind())
+	assert.False(t, poker.PositionButton.IsBlind())
+}
+
+func TestPositionIsValid(t *testing.T) {
+	assert.True(t, poker.PositionUTG2.IsValid())
+	assert.True(t, poker.PositionBB.IsValid())
+	assert.False(t, poker.Position("").IsValid())
+	assert.False(t, poker.Position("btn").IsValid())
+}
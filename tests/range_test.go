@@ -0,0 +1,185 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/pkg/poker"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		notation  string
+		hands     int
+		combos    int
+		canonical string
+	}{
+		{"22+", 13, 78, "22+"},
+		{"99-66", 4, 24, "99-66"},
+		{"66-99", 4, 24, "99-66"},
+		{"ATs+", 4, 16, "ATs+"},
+		{"A5s-A2s", 4, 16, "A5s-A2s"},
+		{"KQo", 1, 12, "KQo"},
+		{"KQo+", 1, 12, "KQo"},
+		{"AK", 2, 16, "AKs, AKo"},
+		{"kq, 76S", 3, 20, "KQs, 76s, KQo"},
+		{"22+, ATs+, KQo, A5s-A2s", 22, 122, "22+, ATs+, A5s-A2s, KQo"},
+		{"22+, 55, AKs, AK", 15, 94, "22+, AKs, AKo"},
+		{"T8s-T6s, T5s", 4, 16, "T8s-T5s"},
+		{"T9s-T6s, T5s", 5, 20, "T5s+"},
+		{"QQ+:0.5, KK", 3, 18, "AA:0.5, KK, QQ:0.5"},
+		{"ATs+:0.25 AJs", 4, 16, "AQs+:0.25, AJs, ATs:0.25"},
+		{"", 0, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.notation, func(t *testing.T) {
+			r, err := poker.ParseRange(tt.notation)
+			require.NoError(t, err)
+			assert.Len(t, r.Hands(), tt.hands)
+			assert.Len(t, r.Combos(nil), tt.combos)
+			assert.Equal(t, tt.canonical, r.String())
+
+			again, err := poker.ParseRange(r.String())
+			require.NoError(t, err)
+			assert.Equal(t, r, again, "the canonical form reads back the same")
+		})
+	}
+}
+
+func TestParseRangeWeights(t *testing.T) {
+	r, err := poker.ParseRange("QQ+:0.5, KK, AKs:0.75")
+	require.NoError(t, err)
+	assert.Equal(t, 0.5, r.Weight(poker.StartingHand{High: poker.Ace, Low: poker.Ace}))
+	assert.Equal(t, 1.0, r.Weight(poker.StartingHand{High: poker.King, Low: poker.King}), "the later entry wins")
+	assert.Equal(t, 0.75, r.Weight(poker.StartingHand{High: poker.Ace, Low: poker.King, Suited: true}))
+	assert.Equal(t, 0.0, r.Weight(poker.StartingHand{High: poker.Ace, Low: poker.King}))
+
+	for _, combo := range r.Combos(nil) {
+		if combo.Cards[0].Rank == poker.Ace && combo.Cards[1].Rank == poker.King {
+			assert.Equal(t, 0.75, combo.Weight)
+			assert.Equal(t, combo.Cards[0].Suit, combo.Cards[1].Suit)
+		}
+	}
+}
+
+func TestRangeCombos(t *testing.T) {
+	r, err := poker.ParseRange("AA, AKs, 72o")
+	require.NoError(t, err)
+	assert.Len(t, r.Combos(nil), 6+4+12)
+
+	// The ace of spades takes three aces and one suited AK
+	combos := r.Combos(cards(t, "As"))
+	assert.Len(t, combos, 3+3+12)
+	for _, combo := range combos {
+		assert.NotContains(t, combo.Cards, poker.NewCard(poker.Ace, poker.Spades))
+		assert.Greater(t, combo.Cards[0].Rank, poker.Rank(0))
+		assert.GreaterOrEqual(t, combo.Cards[0].Rank, combo.Cards[1].Rank, "the higher card comes first")
+	}
+}
+
+func TestParseRangeErrors(t *testing.T) {
+	for _, notation := range []string{
+		"A", "AKss", "AKx", "XY", "A1", "AAs", "AAo", "22++", "+",
+		"AKs-QJs", "22-AKs", "AKs-A2o", "AK-", "-AK",
+		"AK:2", "AK:0", "AK:-1", "AK:abc", "AK:",
+		"22+, garbage",
+	} {
+		t.Run(notation, func(t *testing.T) {
+			_, err := poker.ParseRange(notation)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestHandVsRange(t *testing.T) {
+	kings, err := poker.ParseRange("KK")
+	require.NoError(t, err)
+	result, err := poker.HandVsRange(cards(t, "As Ah"), kings, nil, nil, poker.EquityOptions{Iterations: 200000, Seed: 1})
+	require.NoError(t, err)
+	assert.False(t, result.Exhaustive)
+	assert.InDelta(t, 81.9, result.Players[0].Win, 1)
+
+	// On the river aces beat the six kings and lose to the three sets of
+	// deuces the board leaves
+	board := cards(t, "2c 7d 9h Js 3c")
+	r, err := poker.ParseRange("KK, 22")
+	require.NoError(t, err)
+	result, err = poker.HandVsRange(cards(t, "As Ah"), r, board, nil, poker.EquityOptions{})
+	require.NoError(t, err)
+	assert.True(t, result.Exhaustive)
+	assert.Equal(t, 9, result.Boards)
+	assert.InDelta(t, 100*6.0/9, result.Players[0].Win, 1e-9)
+	assert.InDelta(t, 100*3.0/9, result.Players[1].Win, 1e-9)
+
+	// Each hand counts by its weight
+	r, err = poker.ParseRange("KK, 22:0.5")
+	require.NoError(t, err)
+	result, err = poker.HandVsRange(cards(t, "As Ah"), r, board, nil, poker.EquityOptions{})
+	require.NoError(t, err)
+	assert.InDelta(t, 100*6.0/7.5, result.Players[0].Win, 1e-9)
+
+	// Hands the hero's cards block are left out of the range
+	r, err = poker.ParseRange("AA, KK")
+	require.NoError(t, err)
+	result, err = poker.HandVsRange(cards(t, "As Ah"), r, board, nil, poker.EquityOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 7, result.Boards)
+}
+
+func TestRangeVsRange(t *testing.T) {
+	aces, err := poker.ParseRange("AA")
+	require.NoError(t, err)
+	kings, err := poker.ParseRange("KK")
+	require.NoError(t, err)
+	result, err := poker.RangeVsRange(aces, kings, nil, nil, poker.EquityOptions{Iterations: 200000, Seed: 1})
+	require.NoError(t, err)
+	assert.InDelta(t, 81.9, result.Players[0].Win, 1)
+	assert.InDelta(t, 17.7, result.Players[1].Win, 1)
+
+	// The same range against itself splits the pot evenly
+	r, err := poker.ParseRange("88+, ATs+, KQo")
+	require.NoError(t, err)
+	result, err = poker.RangeVsRange(r, r, nil, nil, poker.EquityOptions{Iterations: 200000, Seed: 7})
+	require.NoError(t, err)
+	assert.InDelta(t, 50, result.Players[0].Equity, 1)
+	assert.InDelta(t, 100, result.Players[0].Equity+result.Players[1].Equity, 1e-9)
+
+	// Every pair of hands is dealt out on the turn
+	result, err = poker.RangeVsRange(aces, kings, cards(t, "2c 7d 9h Js"), nil, poker.EquityOptions{})
+	require.NoError(t, err)
+	assert.True(t, result.Exhaustive)
+	assert.Equal(t, 36*44, result.Boards)
+	assert.InDelta(t, 100*2.0/44, result.Players[1].Win, 1e-9, "kings hit one of two kings left")
+}
+
+func TestRangeEquityErrors(t *testing.T) {
+	aces, err := poker.ParseRange("AA")
+	require.NoError(t, err)
+
+	_, err = poker.RangeVsRange(aces, aces, cards(t, "Ac Ad Ah"), nil, poker.EquityOptions{})
+	assert.ErrorContains(t, err, "player 1 has no hands left")
+
+	_, err = poker.RangeVsRange(aces, aces, nil, cards(t, "Ac Ad"), poker.EquityOptions{})
+	assert.ErrorContains(t, err, "cannot hold hands from their ranges at the same time")
+
+	_, err = poker.HandVsRange(cards(t, "Ks Kh"), aces, cards(t, "Ks 2c 3d"), nil, poker.EquityOptions{})
+	assert.ErrorContains(t, err, "card Ks is given twice")
+
+	_, err = poker.HandVsRange(cards(t, "Ks"), aces, nil, nil, poker.EquityOptions{})
+	assert.ErrorContains(t, err, "hand must have 2 hole cards")
+
+	_, err = poker.RangeVsRange(aces, poker.NewRange(), nil, nil, poker.EquityOptions{})
+	assert.ErrorContains(t, err, "player 2 has no hands left")
+}
+
+func BenchmarkRangeVsRange(b *testing.B) {
+	hero, err := poker.ParseRange("22+, A2s+, KTs+, QJs, ATo+, KQo")
+	require.NoError(b, err)
+	villain, err := poker.ParseRange("TT+, AQs+, AKo")
+	require.NoError(b, err)
+	for i := 0; i < b.N; i++ {
+		poker.RangeVsRange(hero, villain, nil, nil, poker.EquityOptions{Seed: 1})
+	}
+}
@@ -0,0 +1,534 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/internal/game"
+	"github.com/primoPoker/server/pkg/poker"
+)
+
+// betAndCall has one player bet the big blind on the flop, turn and river
+// and the other call each time, taking the hand to showdown
+func betAndCall(bettor, caller string) []game.ScenarioStep {
+	var steps []game.ScenarioStep
+	for range 3 {
+		steps = append(steps,
+			game.ScenarioStep{Player: bettor, Action: game.Raise, Amount: 100},
+			game.ScenarioStep{Player: caller, Action: game.Call},
+		)
+	}
+	return steps
+}
+
+func TestScenarios(t *testing.T) {
+	headsUp := []game.ScenarioPlayer{{ID: "alice", Stack: 10000}, {ID: "bob", Stack: 10000}}
+	threeHanded := []game.ScenarioPlayer{{ID: "alice", Stack: 10000}, {ID: "bob", Stack: 10000}, {ID: "carol", Stack: 10000}}
+	acesOverKings := game.ScenarioDeal{
+		Hole:  map[string][]poker.Card{"alice": cards(t, "As Ah"), "bob": cards(t, "Kd Kc")},
+		Board: cards(t, "2c 7d 9h Js 3s"),
+	}
+	kingsUnderAces := game.ScenarioDeal{
+		Hole:  map[string][]poker.Card{"alice": cards(t, "Kd Kc"), "bob": cards(t, "As Ah")},
+		Board: cards(t, "2c 7d 9h Js 3s"),
+	}
+
+	scenarios := []game.Scenario{
+		{
+			Name:    "heads-up the button posts the small blind and acts first only before the flop",
+			Players: headsUp,
+			Deals:   []game.ScenarioDeal{acesOverKings},
+			Steps: append([]game.ScenarioStep{
+				{Expect: []game.Expectation{
+					game.ExpectPhase(game.PreFlop),
+					game.ExpectHandNumber(1),
+					game.ExpectButton("bob", "bob", "alice"),
+					game.ExpectPot(150),
+					game.ExpectStacks(map[string]int64{"alice": 9900, "bob": 9950}),
+					game.ExpectToAct("bob"),
+					game.ExpectLegal(game.Fold, game.Call, game.Raise, game.AllIn),
+				}},
+				{Player: "bob", Action: game.Check, Rejected: true},
+				// The big blind has the option once the button calls
+				{Player: "bob", Action: game.Call, Expect: []game.Expectation{
+					game.ExpectPot(200),
+					game.ExpectToAct("alice"),
+					game.ExpectLegal(game.Fold, game.Check, game.Raise, game.AllIn),
+				}},
+				{Player: "alice", Action: game.Check, Expect: []game.Expectation{
+					game.ExpectPhase(game.Flop),
+					game.ExpectBoard(cards(t, "2c 7d 9h")...),
+					game.ExpectToAct("alice"),
+				}},
+			}, betAndCall("alice", "bob")...),
+			Final: []game.Expectation{
+				game.ExpectPhase(game.Showdown),
+				game.ExpectBoard(cards(t, "2c 7d 9h Js 3s")...),
+				game.ExpectToAct(""),
+				game.ExpectPot(0),
+				game.ExpectWinners(map[string]int64{"alice": 800}),
+				game.ExpectStacks(map[string]int64{"alice": 10400, "bob": 9600}),
+			},
+		},
+		{
+			Name:    "a raise adds at least as much as the last one",
+			Players: headsUp,
+			Steps: []game.ScenarioStep{
+				{Player: "bob", Action: game.Raise, Amount: 50, Rejected: true},
+				{Player: "bob", Action: game.Raise, Amount: 200, Expect: []game.Expectation{
+					game.ExpectPot(400),
+					game.ExpectMinRaise(200),
+					game.ExpectToAct("alice"),
+				}},
+				{Player: "alice", Action: game.Raise, Amount: 100, Rejected: true},
+				{Player: "alice", Action: game.Raise, Amount: 300, Expect: []game.Expectation{
+					game.ExpectPot(900),
+					game.ExpectMinRaise(300),
+					game.ExpectToAct("bob"),
+				}},
+				{Player: "bob", Action: game.Raise, Amount: 200, Rejected: true},
+				{Player: "bob", Action: game.Call, Expect: []game.Expectation{
+					game.ExpectPhase(game.Flop),
+					game.ExpectPot(1200),
+				}},
+			},
+		},
+		{
+			Name:    "a raise bigger than the stack is refused, and one of all of it is all in",
+			Players: []game.ScenarioPlayer{{ID: "alice", Stack: 10000}, {ID: "bob", Stack: 1000}},
+			Steps: []game.ScenarioStep{
+				{Player: "bob", Action: game.Raise, Amount: 1000, Rejected: true},
+				{Player: "bob", Action: game.Raise, Amount: 900, Expect: []game.Expectation{
+					game.ExpectStacks(map[string]int64{"bob": 0}),
+					game.ExpectPot(1100),
+					game.ExpectToAct("alice"),
+				}},
+			},
+		},
+		{
+			Name:    "antes go into the pot without counting towards the call",
+			Config:  game.GameConfig{Ante: 25},
+			Players: headsUp,
+			Steps: []game.ScenarioStep{
+				{Expect: []game.Expectation{
+					game.ExpectPot(200),
+					game.ExpectStacks(map[string]int64{"alice": 9875, "bob": 9925}),
+				}},
+				{Player: "bob", Action: game.Call, Expect: []game.Expectation{
+					game.ExpectPot(250),
+					game.ExpectStacks(map[string]int64{"bob": 9875}),
+					game.ExpectToAct("alice"),
+					game.ExpectLegal(game.Fold, game.Check, game.Raise, game.AllIn),
+				}},
+			},
+		},
+		{
+			Name:    "a stack short of its blind posts it all and the board runs out",
+			Players: []game.ScenarioPlayer{{ID: "alice", Stack: 10000}, {ID: "bob", Stack: 30}},
+			Deals:   []game.ScenarioDeal{acesOverKings},
+			Steps: []game.ScenarioStep{
+				{Expect: []game.Expectation{
+					game.ExpectPot(130),
+					game.ExpectStacks(map[string]int64{"bob": 0}),
+					game.ExpectToAct("alice"),
+					game.ExpectLegal(game.Fold, game.Check, game.Raise, game.AllIn),
+				}},
+				{Player: "alice", Action: game.Check, Expect: []game.Expectation{game.ExpectToAct("alice")}},
+				{Player: "alice", Action: game.Check},
+				{Player: "alice", Action: game.Check},
+				{Player: "alice", Action: game.Check},
+			},
+			Final: []game.Expectation{
+				game.ExpectPhase(game.Showdown),
+				game.ExpectWinners(map[string]int64{"alice": 130}),
+				game.ExpectStacks(map[string]int64{"alice": 10030, "bob": 0}),
+			},
+		},
+		{
+			Name:    "calling for more than the stack goes all in",
+			Players: []game.ScenarioPlayer{{ID: "alice", Stack: 400}, {ID: "bob", Stack: 10000}},
+			Deals:   []game.ScenarioDeal{kingsUnderAces},
+			Steps: []game.ScenarioStep{
+				{Player: "bob", Action: game.Raise, Amount: 400},
+				{Player: "alice", Action: game.Call, Expect: []game.Expectation{
+					game.ExpectPot(900),
+					game.ExpectStacks(map[string]int64{"alice": 0}),
+					game.ExpectPhase(game.Flop),
+					game.ExpectToAct("bob"),
+				}},
+				{Player: "bob", Action: game.Check},
+				{Player: "bob", Action: game.Check},
+				{Player: "bob", Action: game.Check},
+			},
+			Final: []game.Expectation{
+				game.ExpectWinners(map[string]int64{"bob": 900}),
+				game.ExpectStacks(map[string]int64{"alice": 0, "bob": 10400}),
+			},
+		},
+		{
+			Name:    "first to act before the flop sits after the big blind",
+			Players: threeHanded,
+			Steps: []game.ScenarioStep{
+				{Expect: []game.Expectation{
+					game.ExpectButton("bob", "carol", "alice"),
+					game.ExpectToAct("bob"),
+				}},
+				{Player: "alice", Action: game.Call, Rejected: true},
+				{Player: "bob", Action: game.Call, Expect: []game.Expectation{game.ExpectToAct("carol")}},
+				{Player: "carol", Action: game.Call, Expect: []game.Expectation{game.ExpectToAct("alice")}},
+				// After the flop the small blind acts first
+				{Player: "alice", Action: game.Check, Expect: []game.Expectation{
+					game.ExpectPhase(game.Flop),
+					game.ExpectPot(300),
+					game.ExpectToAct("carol"),
+				}},
+			},
+		},
+		{
+			Name:    "the last player left in wins the pot without a showdown",
+			Players: threeHanded,
+			Steps: []game.ScenarioStep{
+				{Player: "bob", Action: game.Fold},
+				{Player: "carol", Action: game.Fold, Expect: []game.Expectation{
+					game.ExpectToAct(""),
+					game.ExpectBoard(),
+					game.ExpectPot(0),
+				}},
+			},
+			Final: []game.Expectation{
+				game.ExpectWinners(map[string]int64{"alice": 150}),
+				game.ExpectStacks(map[string]int64{"alice": 10050, "bob": 10000, "carol": 9950}),
+			},
+		},
+		{
+			Name:    "the button moves and new blinds apply from the next hand",
+			Players: headsUp,
+			Deals:   []game.ScenarioDeal{acesOverKings},
+			Steps: append(append([]game.ScenarioStep{
+				{Do: func(g *game.Game) error {
+					smallBlind, bigBlind := int64(100), int64(200)
+					return g.UpdateConfig(game.TableConfigUpdate{SmallBlind: &smallBlind, BigBlind: &bigBlind})
+				}, Expect: []game.Expectation{game.ExpectBlinds(50, 100), game.ExpectPot(150)}},
+				{Player: "bob", Action: game.Call},
+				{Player: "alice", Action: game.Check},
+			}, betAndCall("alice", "bob")...),
+				game.ScenarioStep{Expect: []game.Expectation{
+					game.ExpectStacks(map[string]int64{"alice": 10400, "bob": 9600}),
+				}},
+				// Nothing is dealt until the hand delay has passed
+				game.ScenarioStep{Wait: time.Second, Expect: []game.Expectation{
+					game.ExpectPhase(game.Showdown),
+					game.ExpectHandNumber(1),
+				}},
+				game.ScenarioStep{Wait: 4 * time.Second, Expect: []game.Expectation{
+					game.ExpectPhase(game.PreFlop),
+					game.ExpectHandNumber(2),
+					game.ExpectButton("alice", "alice", "bob"),
+					game.ExpectBlinds(100, 200),
+					game.ExpectPot(300),
+					game.ExpectStacks(map[string]int64{"alice": 10300, "bob": 9400}),
+					game.ExpectToAct("alice"),
+				}},
+			),
+		},
+		{
+			Name:    "a table short of its minimum deals only once forced",
+			Config:  game.GameConfig{MinPlayersPerTable: 3},
+			Players: headsUp,
+			Steps: []game.ScenarioStep{
+				{Do: func(g *game.Game) error {
+					smallBlind, bigBlind := int64(100), int64(200)
+					return g.UpdateConfig(game.TableConfigUpdate{SmallBlind: &smallBlind, BigBlind: &bigBlind})
+				}, Expect: []game.Expectation{
+					game.ExpectPhase(game.WaitingForPlayers),
+					game.ExpectBlinds(50, 100),
+				}},
+				{Do: func(g *game.Game) error {
+					invalid := int64(10)
+					return g.UpdateConfig(game.TableConfigUpdate{BigBlind: &invalid})
+				}, Err: game.ErrInvalidTableConfig},
+				{Do: func(g *game.Game) error { return g.ForceStart() }, Err: game.ErrNotEnoughPlayers},
+				{Do: func(g *game.Game) error {
+					g.MinPlayers = 2
+					return g.ForceStart()
+				}, Expect: []game.Expectation{
+					game.ExpectPhase(game.PreFlop),
+					game.ExpectBlinds(100, 200),
+					game.ExpectPot(300),
+				}},
+				{Do: func(g *game.Game) error { return g.ForceStart() }, Err: game.ErrHandInProgress},
+			},
+		},
+		{
+			Name:    "closing a table voids the hand being played",
+			Players: headsUp,
+			Steps: []game.ScenarioStep{
+				{Player: "bob", Action: game.Raise, Amount: 200},
+				{Do: func(g *game.Game) error {
+					want := map[string]int64{"alice": 10000, "bob": 10000}
+					if stacks := g.Close(); !maps.Equal(stacks, want) {
+						return fmt.Errorf("closing paid out %v, want %v", stacks, want)
+					}
+					return nil
+				}, Expect: []game.Expectation{
+					game.ExpectPhase(game.GameOver),
+					game.ExpectPot(0),
+				}},
+				{Player: "alice", Action: game.Call, Rejected: true},
+			},
+		},
+		{
+			Name:    "a player kicked out of turn is folded and cashed out",
+			Players: threeHanded,
+			Steps: []game.ScenarioStep{
+				{Do: func(g *game.Game) error {
+					if stack, err := g.Kick("carol"); err != nil || stack != 9950 {
+						return fmt.Errorf("kicking carol cashed out %d (%v), want 9950", stack, err)
+					}
+					return nil
+				}, Expect: []game.Expectation{
+					game.ExpectStacks(map[string]int64{"carol": 0}),
+					game.ExpectPot(150),
+					game.ExpectToAct("bob"),
+				}},
+				{Do: func(g *game.Game) error {
+					_, err := g.Kick("nobody")
+					return err
+				}, Err: game.ErrPlayerNotInGame},
+				// The small blind is skipped, having folded
+				{Player: "bob", Action: game.Call, Expect: []game.Expectation{game.ExpectToAct("alice")}},
+				{Player: "alice", Action: game.Check, Expect: []game.Expectation{
+					game.ExpectPhase(game.Flop),
+					game.ExpectToAct("alice"),
+				}},
+			},
+		},
+		{
+			Name:    "a table deals once a second player sits down, and seats each player once",
+			Players: []game.ScenarioPlayer{{ID: "alice", Stack: 10000}},
+			Steps: []game.ScenarioStep{
+				{Expect: []game.Expectation{
+					game.ExpectPhase(game.WaitingForPlayers),
+					game.ExpectHandNumber(0),
+					game.ExpectSeated("alice"),
+				}},
+				{Do: func(g *game.Game) error {
+					return g.AddPlayer(game.NewPlayer("bob", "bob", 10000, 1))
+				}, Expect: []game.Expectation{
+					game.ExpectPhase(game.PreFlop),
+					game.ExpectHandNumber(1),
+					game.ExpectSeated("alice", "bob"),
+				}},
+				{Do: func(g *game.Game) error {
+					return g.AddPlayer(game.NewPlayer("alice", "alice", 10000, 2))
+				}, Rejected: true, Expect: []game.Expectation{game.ExpectSeated("alice", "bob")}},
+			},
+		},
+		{
+			Name:    "players are shown their own hole cards and nobody else's",
+			Players: headsUp,
+			Deals:   []game.ScenarioDeal{acesOverKings},
+			Steps: []game.ScenarioStep{
+				{Expect: []game.Expectation{
+					game.ExpectSees("alice", map[string][]poker.Card{"alice": cards(t, "As Ah")}),
+					game.ExpectSees("bob", map[string][]poker.Card{"bob": cards(t, "Kd Kc")}),
+					game.ExpectSees("spectator", nil),
+				}},
+			},
+		},
+		{
+			Name:    "a player leaving keeps their seat disconnected, folding if it is their turn",
+			Players: threeHanded,
+			Steps: []game.ScenarioStep{
+				{Do: func(g *game.Game) error { return g.RemovePlayer("bob") }, Expect: []game.Expectation{
+					game.ExpectSeated("alice", "bob", "carol"),
+					game.ExpectDisconnected("bob"),
+					game.ExpectToAct("carol"),
+				}},
+				{Do: func(g *game.Game) error { return g.RemovePlayer("nobody") }, Rejected: true},
+				{Player: "carol", Action: game.Call},
+				{Player: "alice", Action: game.Check, Expect: []game.Expectation{
+					game.ExpectPhase(game.Flop),
+					game.ExpectPot(200),
+					game.ExpectStacks(map[string]int64{"alice": 9900, "bob": 10000, "carol": 9900}),
+				}},
+			},
+		},
+		{
+			Name:    "a multi-way all in makes a side pot the short stack cannot win",
+			Players: []game.ScenarioPlayer{{ID: "alice", Stack: 10000}, {ID: "bob", Stack: 1000}, {ID: "carol", Stack: 3000}},
+			Deals: []game.ScenarioDeal{{
+				Hole:  map[string][]poker.Card{"alice": cards(t, "Qs Qh"), "bob": cards(t, "As Ah"), "carol": cards(t, "Kd Kc")},
+				Board: cards(t, "2c 7d 9h Js 3s"),
+			}},
+			Steps: []game.ScenarioStep{
+				{Player: "bob", Action: game.AllIn, Expect: []game.Expectation{game.ExpectPot(1150)}},
+				{Player: "carol", Action: game.AllIn, Expect: []game.Expectation{game.ExpectPot(4100)}},
+				{Player: "alice", Action: game.Call, Expect: []game.Expectation{
+					game.ExpectPot(7000),
+					game.ExpectPhase(game.Flop),
+					game.ExpectToAct("alice"),
+				}},
+				{Player: "alice", Action: game.Check},
+				{Player: "alice", Action: game.Check},
+				{Player: "alice", Action: game.Check},
+			},
+			// Bob's aces win the 3000 all three put in, carol's kings the
+			// 4000 only she and alice did
+			Final: []game.Expectation{
+				game.ExpectPhase(game.Showdown),
+				game.ExpectWinners(map[string]int64{"bob": 3000, "carol": 4000}),
+				game.ExpectStacks(map[string]int64{"alice": 7000, "bob": 3000, "carol": 4000}),
+			},
+		},
+		{
+			Name:    "players tying for a side pot split it while the main pot goes to the best hand",
+			Players: []game.ScenarioPlayer{{ID: "alice", Stack: 10000}, {ID: "bob", Stack: 1000}, {ID: "carol", Stack: 3000}},
+			Deals: []game.ScenarioDeal{{
+				Hole:  map[string][]poker.Card{"alice": cards(t, "Kh Ks"), "bob": cards(t, "As Ah"), "carol": cards(t, "Kd Kc")},
+				Board: cards(t, "2c 7d 9h Js 3s"),
+			}},
+			Steps: []game.ScenarioStep{
+				{Player: "bob", Action: game.AllIn},
+				{Player: "carol", Action: game.AllIn},
+				{Player: "alice", Action: game.Call},
+				{Player: "alice", Action: game.Check},
+				{Player: "alice", Action: game.Check},
+				{Player: "alice", Action: game.Check},
+			},
+			Final: []game.Expectation{
+				game.ExpectWinners(map[string]int64{"alice": 2000, "bob": 3000, "carol": 2000}),
+				game.ExpectStacks(map[string]int64{"alice": 9000, "bob": 3000, "carol": 2000}),
+			},
+		},
+		{
+			Name:    "the button stays behind on the seat of a small blind who busts",
+			Players: []game.ScenarioPlayer{{ID: "alice", Stack: 10000}, {ID: "bob", Stack: 10000}, {ID: "carol", Stack: 500}, {ID: "dave", Stack: 10000}},
+			Deals: []game.ScenarioDeal{{
+				Hole:  map[string][]poker.Card{"carol": cards(t, "7c 2d"), "dave": cards(t, "As Ah")},
+				Board: cards(t, "Kd 9s 5c Jh 3c"),
+			}},
+			Steps: []game.ScenarioStep{
+				// Deals on once carol busts
+				{Do: func(g *game.Game) error {
+					g.MinPlayers = 2
+					return nil
+				}, Expect: []game.Expectation{
+					game.ExpectButton("bob", "carol", "dave"),
+					game.ExpectToAct("alice"),
+				}},
+				{Player: "alice", Action: game.Fold},
+				{Player: "bob", Action: game.Fold},
+				{Player: "carol", Action: game.AllIn, Expect: []game.Expectation{game.ExpectPot(600)}},
+				{Player: "dave", Action: game.Call, Expect: []game.Expectation{game.ExpectPot(1000)}},
+				{Player: "dave", Action: game.Check},
+				{Player: "dave", Action: game.Check},
+				{Player: "dave", Action: game.Check, Expect: []game.Expectation{
+					game.ExpectWinners(map[string]int64{"dave": 1000}),
+				}},
+				// The big blind moves on to alice and dave, who had it,
+				// posts the small blind, so the button is left dead on
+				// carol's seat rather than passing dave by
+				{Wait: 5 * time.Second, Expect: []game.Expectation{
+					game.ExpectHandNumber(2),
+					game.ExpectButton("carol", "dave", "alice"),
+					game.ExpectPot(150),
+					game.ExpectToAct("bob"),
+				}},
+			},
+			Final: []game.Expectation{
+				game.ExpectStacks(map[string]int64{"alice": 9900, "bob": 10000, "carol": 0, "dave": 10450}),
+			},
+		},
+		{
+			Name:    "a big blind who busts leaves a dead small blind that nobody posts",
+			Players: []game.ScenarioPlayer{{ID: "alice", Stack: 10000}, {ID: "bob", Stack: 10000}, {ID: "carol", Stack: 10000}, {ID: "dave", Stack: 100}},
+			Deals: []game.ScenarioDeal{{
+				Hole:  map[string][]poker.Card{"carol": cards(t, "As Ah"), "dave": cards(t, "7c 2d")},
+				Board: cards(t, "Kd 9s 5c Jh 3c"),
+			}},
+			Steps: []game.ScenarioStep{
+				// Deals on once dave busts
+				{Do: func(g *game.Game) error {
+					g.MinPlayers = 2
+					return nil
+				}},
+				{Player: "alice", Action: game.Fold},
+				{Player: "bob", Action: game.Fold},
+				{Player: "carol", Action: game.Call, Expect: []game.Expectation{
+					game.ExpectPot(200),
+					game.ExpectPhase(game.Flop),
+					game.ExpectToAct("carol"),
+				}},
+				{Player: "carol", Action: game.Check},
+				{Player: "carol", Action: game.Check},
+				{Player: "carol", Action: game.Check, Expect: []game.Expectation{
+					game.ExpectWinners(map[string]int64{"carol": 200}),
+				}},
+				{Wait: 5 * time.Second, Expect: []game.Expectation{
+					game.ExpectHandNumber(2),
+					game.ExpectButton("carol", "dave", "alice"),
+					game.ExpectPot(100),
+					game.ExpectToAct("bob"),
+				}},
+			},
+			Final: []game.Expectation{
+				game.ExpectStacks(map[string]int64{"alice": 9900, "bob": 10000, "carol": 10100, "dave": 0}),
+			},
+		},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.Name, func(t *testing.T) {
+			require.NoError(t, scenario.Run())
+		})
+	}
+}
+
+func TestScenarioReportsTheStepThatFailed(t *testing.T) {
+	scenario := game.Scenario{
+		Name:    "wrong pot",
+		Players: []game.ScenarioPlayer{{ID: "alice", Stack: 10000}, {ID: "bob", Stack: 10000}},
+		Steps: []game.ScenarioStep{
+			{Player: "bob", Action: game.Call},
+			{Player: "alice", Action: game.Raise, Amount: 100, Expect: []game.Expectation{game.ExpectPot(200)}},
+		},
+	}
+	err := scenario.Run()
+	require.Error(t, err)
+	assert.Equal(t, "wrong pot: step 2 (alice raises 100): pot is 300, want 200", err.Error())
+
+	scenario.Steps = []game.ScenarioStep{{Player: "alice", Action: game.Check}}
+	err = scenario.Run()
+	require.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "wrong pot: step 1 (alice: Check): not your turn"), err.Error())
+
+	scenario.Deals = []game.ScenarioDeal{{Hole: map[string][]poker.Card{"alice": cards(t, "As Ah"), "bob": cards(t, "As Kd")}}}
+	assert.ErrorContains(t, scenario.Run(), "deal 1: A♠ is dealt twice")
+}
+
+func TestFakeClockFiresTimersAsItMoves(t *testing.T) {
+	clock := game.NewFakeClock(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+	start := clock.Now()
+	var fired []time.Duration
+	clock.AfterFunc(2*time.Second, func() {
+		fired = append(fired, clock.Now().Sub(start))
+		// A timer started by another fires if it comes due in the same move
+		clock.AfterFunc(time.Second, func() { fired = append(fired, clock.Now().Sub(start)) })
+	})
+	stopped := clock.AfterFunc(time.Second, func() { t.Error("a stopped timer fired") })
+	assert.True(t, stopped.Stop())
+	assert.False(t, stopped.Stop())
+
+	clock.Advance(time.Second)
+	assert.Empty(t, fired)
+	clock.Advance(5 * time.Second)
+	assert.Equal(t, []time.Duration{2 * time.Second, 3 * time.Second}, fired)
+	assert.Equal(t, 6*time.Second, clock.Now().Sub(start))
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/pkg/poker"
+)
+
+func TestNewShortDeck(t *testing.T) {
+	deck := poker.NewShortDeck()
+	require.Len(t, deck.Cards, 36)
+	seen := make(map[poker.Card]bool)
+	for _, card := range deck.Cards {
+		assert.GreaterOrEqual(t, card.Rank, poker.Six)
+		assert.False(t, seen[card], "%s is in the deck twice", card.Code())
+		seen[card] = true
+	}
+
+	_, err := deck.DealMultiple(10)
+	require.NoError(t, err)
+	deck.Reset()
+	assert.ElementsMatch(t, poker.NewShortDeck().Cards, deck.Cards, "a short deck stays short when reset")
+	assert.Len(t, poker.NewDeck().Cards, 52)
+}
+
+func TestShortDeckRankings(t *testing.T) {
+	hand := func(rules poker.Ruleset, notation string) *poker.Hand {
+		return rules.NewHand(cards(t, notation))
+	}
+	tests := []struct {
+		name     string
+		stronger string
+		weaker   string
+	}{
+		{"a flush beats a full house", "Ah Jh 9h 7h 6h", "Ks Kh Kd 7c 7d"},
+		{"a straight beats three of a kind", "Ts 9h 8d 7c 6s", "As Ah Ad Kc Qd"},
+		{"the lowest straight beats three of a kind", "As 9h 8d 7c 6s", "Ks Kh Kd Qc Jd"},
+		{"six high beats the low ace straight", "Ts 9h 8d 7c 6s", "As 9h 8d 7c 6s"},
+		{"four of a kind beats a flush", "9s 9h 9d 9c 6s", "Ah Jh 9h 7h 6h"},
+		{"the low ace straight flush beats four of a kind", "Ah 9h 8h 7h 6h", "As Ac Ad Ah Ks"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, 1, poker.CompareHands(hand(poker.ShortDeckRules, tt.stronger), hand(poker.ShortDeckRules, tt.weaker)))
+			assert.Equal(t, -1, poker.CompareHands(hand(poker.ShortDeckRules, tt.weaker), hand(poker.ShortDeckRules, tt.stronger)))
+		})
+	}
+
+	// The standard order is untouched
+	assert.Equal(t, 1, poker.CompareHands(hand(poker.StandardRules, "Ks Kh Kd 7c 7d"), hand(poker.StandardRules, "Ah Jh 9h 7h 6h")))
+}
+
+func TestShortDeckStraights(t *testing.T) {
+	lowAce := poker.ShortDeckRules.NewHand(cards(t, "As 9h 8d 7c 6s"))
+	assert.Equal(t, poker.Straight, lowAce.Rank)
+	assert.Equal(t, []poker.Rank{poker.Nine}, lowAce.Kickers, "the ace plays low, under the six")
+	assert.Equal(t, poker.HighCard, poker.NewHand(cards(t, "As 9h 8d 7c 6s")).Rank)
+
+	wheel := poker.ShortDeckRules.NewHand(cards(t, "As 2h 3d 4c 5s"))
+	assert.Equal(t, poker.HighCard, wheel.Rank, "there is no wheel in short deck")
+	assert.Equal(t, poker.Straight, poker.NewHand(cards(t, "As 2h 3d 4c 5s")).Rank)
+
+	suited := poker.ShortDeckRules.NewHand(cards(t, "Ah 9h 8h 7h 6h"))
+	assert.Equal(t, poker.StraightFlush, suited.Rank)
+	assert.Equal(t, poker.RoyalFlush, poker.ShortDeckRules.NewHand(cards(t, "Ah Kh Qh Jh Th")).Rank)
+}
+
+func TestShortDeckSevenCards(t *testing.T) {
+	board := cards(t, "Kh Kd 7h 9h Ts")
+	flush, flushRank := poker.ShortDeckRules.EvaluateSeven(append(cards(t, "Ah 6h"), board...))
+	fullHouse, fullHouseRank := poker.ShortDeckRules.EvaluateSeven(append(cards(t, "Ks 7c"), board...))
+	assert.Equal(t, poker.Flush, flushRank)
+	assert.Equal(t, poker.FullHouse, fullHouseRank)
+	assert.Greater(t, flush, fullHouse)
+	assert.Equal(t, poker.ShortDeckRules, flush.Ruleset())
+
+	standardFlush, _ := poker.EvaluateSeven(append(cards(t, "Ah 6h"), board...))
+	standardFullHouse, _ := poker.EvaluateSeven(append(cards(t, "Ks 7c"), board...))
+	assert.Less(t, standardFlush, standardFullHouse)
+	assert.Equal(t, poker.StandardRules, standardFlush.Ruleset())
+
+	best := poker.ShortDeckRules.GetBestHand(append(cards(t, "As 6c"), cards(t, "7d 8s 9h Kc Qd")...))
+	assert.Equal(t, poker.Straight, best.Rank)
+	assert.ElementsMatch(t, cards(t, "As 6c 7d 8s 9h"), best.Cards)
+	assert.Equal(t, poker.ShortDeckRules, best.Ruleset)
+}
+
+func TestRulesetsDoNotMix(t *testing.T) {
+	short := poker.ShortDeckRules.NewHand(cards(t, "Ah Jh 9h 7h 6h"))
+	standard := poker.NewHand(cards(t, "Ah Jh 9h 7h 6h"))
+	assert.Equal(t, short.Rank, standard.Rank)
+	assert.NotEqual(t, short.Value, standard.Value, "values carry their ruleset")
+	assert.Panics(t, func() { poker.CompareHands(short, standard) })
+	assert.Panics(t, func() { poker.CompareHands(standard, short) })
+}
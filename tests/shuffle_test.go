@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/primoPoker/server/pkg/poker"
+)
+
+// firstCardChiSquared shuffles a fresh deck many times and measures how far
+// the counts of each card coming out first stray from even
+func firstCardChiSquared(t *testing.T, shuffler poker.Shuffler, shuffles int) float64 {
+	t.Helper()
+	counts := make(map[poker.Card]int)
+	for i := 0; i < shuffles; i++ {
+		deck := poker.NewDeckWithShuffler(shuffler)
+		deck.Shuffle()
+		card, err := deck.Deal()
+		require.NoError(t, err)
+		counts[card]++
+	}
+	require.Len(t, counts, 52, "every card comes out first sometimes")
+
+	expected := float64(shuffles) / 52
+	chiSquared := 0.0
+	for _, count := range counts {
+		diff := float64(count) - expected
+		chiSquared += diff * diff / expected
+	}
+	return chiSquared
+}
+
+func TestShufflersAreUniform(t *testing.T) {
+	// With 51 degrees of freedom a fair shuffle strays past 110 about
+	// three times in a million runs
+	const limit = 110
+	shufflers := map[string]poker.Shuffler{
+		"crypto": poker.CryptoShuffler{},
+		"seeded": poker.NewSeededShuffler([32]byte{1, 2, 3}),
+	}
+	for name, shuffler := range shufflers {
+		t.Run(name, func(t *testing.T) {
+			assert.Less(t, firstCardChiSquared(t, shuffler, 52000), float64(limit))
+		})
+	}
+}
+
+func TestSeededShufflerIsDeterministic(t *testing.T) {
+	seed := [32]byte{'p', 'r', 'i', 'm', 'o'}
+	deal := func(shuffler poker.Shuffler) [][]poker.Card {
+		deck := poker.NewDeckWithShuffler(shuffler)
+		var orders [][]poker.Card
+		for i := 0; i < 3; i++ {
+			deck.Reset()
+			orders = append(orders, append([]poker.Card(nil), deck.Cards...))
+		}
+		return orders
+	}
+
+	first := deal(poker.NewSeededShuffler(seed))
+	assert.Equal(t, first, deal(poker.NewSeededShuffler(seed)), "the same seed deals the same hands")
+	assert.NotEqual(t, first[0], first[1], "each shuffle moves on from the last")
+
+	other := seed
+	other[31] = 1
+	assert.NotEqual(t, first, deal(poker.NewSeededShuffler(other)))
+}
+
+func TestShufflesKeepEveryCard(t *testing.T) {
+	for _, shuffler := range []poker.Shuffler{poker.CryptoShuffler{}, poker.NewSeededShuffler([32]byte{})} {
+		deck := poker.NewDeckWithShuffler(shuffler)
+		deck.Shuffle()
+		assert.ElementsMatch(t, poker.NewDeck().Cards, deck.Cards)
+	}
+}
+
+func BenchmarkCryptoShuffle(b *testing.B) {
+	deck := poker.NewDeck()
+	for i := 0; i < b.N; i++ {
+		deck.Shuffle()
+	}
+}
+
+func BenchmarkSeededShuffle(b *testing.B) {
+	deck := poker.NewDeckWithShuffler(poker.NewSeededShuffler([32]byte{}))
+	for i := 0; i < b.N; i++ {
+		deck.Shuffle()
+	}
+}